@@ -22,6 +22,11 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == migrateSchemaCommand {
+		migrateSchema(os.Args[2:])
+		return
+	}
+
 	var config loki.ConfigWrapper
 
 	if err := cfg.DynamicUnmarshal(&config, os.Args[1:], flag.CommandLine); err != nil {
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/flagext"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/grafana/loki/pkg/loki"
+	"github.com/grafana/loki/pkg/storage/migrate"
+	"github.com/grafana/loki/pkg/util/cfg"
+	util_log "github.com/grafana/loki/pkg/util/log"
+	"github.com/grafana/loki/pkg/validation"
+)
+
+const migrateSchemaCommand = "migrate-schema"
+
+// migrateSchemaConfig is the Loki config along with the migrate-schema flags.
+type migrateSchemaConfig struct {
+	loki.ConfigWrapper `yaml:",inline"`
+
+	Migrate migrate.Config `yaml:"-"`
+}
+
+func (c *migrateSchemaConfig) RegisterFlags(f *flag.FlagSet) {
+	c.ConfigWrapper.RegisterFlags(f)
+	c.Migrate.RegisterFlags(f)
+}
+
+func (c *migrateSchemaConfig) Clone() flagext.Registerer {
+	return func(c migrateSchemaConfig) *migrateSchemaConfig {
+		return &c
+	}(*c)
+}
+
+func (c *migrateSchemaConfig) ApplyDynamicConfig() cfg.Source {
+	apply := c.ConfigWrapper.ApplyDynamicConfig()
+	return func(dst cfg.Cloneable) error {
+		r, ok := dst.(*migrateSchemaConfig)
+		if !ok {
+			return errors.New("dst is not a migrate-schema config")
+		}
+		return apply(&r.ConfigWrapper)
+	}
+}
+
+// migrateSchema rewrites the chunks of a period config with a newer period config of the Loki config file.
+func migrateSchema(args []string) {
+	var config migrateSchemaConfig
+
+	fs := flag.NewFlagSet(migrateSchemaCommand, flag.ExitOnError)
+	if err := cfg.DynamicUnmarshal(&config, args, fs); err != nil {
+		fmt.Fprintf(os.Stderr, "failed parsing config: %v\n", err)
+		os.Exit(1)
+	}
+	validation.SetDefaultLimitsForYAMLUnmarshalling(config.LimitsConfig)
+	util_log.InitLogger(&config.Server, prometheus.DefaultRegisterer)
+
+	if err := config.Validate(); err != nil {
+		level.Error(util_log.Logger).Log("msg", "validating config", "err", err.Error())
+		os.Exit(1)
+	}
+
+	// Migrating long periods requires lifting the query limits.
+	config.LimitsConfig.CardinalityLimit = 1e9
+	config.LimitsConfig.MaxQueryLength = 0
+	limits, err := validation.NewOverrides(config.LimitsConfig, nil)
+	util_log.CheckFatal("creating limits", err, util_log.Logger)
+
+	m, err := migrate.New(config.Migrate, config.StorageConfig, config.ChunkStoreConfig.StoreConfig, config.SchemaConfig.SchemaConfig, limits, util_log.Logger)
+	util_log.CheckFatal("creating migrator", err, util_log.Logger)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	err = m.Run(ctx)
+	m.Stop()
+	util_log.CheckFatal("migrating schema", err, util_log.Logger)
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"gopkg.in/alecthomas/kingpin.v2"
+
+	"github.com/grafana/loki/pkg/loki"
+	base_ruler "github.com/grafana/loki/pkg/ruler/base"
+	"github.com/grafana/loki/pkg/ruler/rulestore/objectclient"
+	"github.com/grafana/loki/pkg/storage/backup"
+	"github.com/grafana/loki/pkg/storage/chunk"
+	chunk_storage "github.com/grafana/loki/pkg/storage/chunk/storage"
+	"github.com/grafana/loki/pkg/storage/stores/shipper"
+	"github.com/grafana/loki/pkg/storage/stores/shipper/compactor/deletion"
+	"github.com/grafana/loki/pkg/util/cfg"
+	util_log "github.com/grafana/loki/pkg/util/log"
+	"github.com/grafana/loki/pkg/validation"
+)
+
+var (
+	backupCmd = app.Command("backup", `Snapshot the index files, the delete requests and the rules of a cluster
+in a secondary store.
+
+The objects are captured with their checksum in a manifest, stored last: a
+snapshot without manifest is incomplete. The chunks aren't captured, they are
+expected to be replicated by the object store.`)
+	backupFlags = registerSnapshotFlags(backupCmd)
+
+	restoreCmd = app.Command("restore", `Restore a snapshot into a fresh cluster.
+
+The periods of the schema config of the snapshot must be the first ones of the
+schema config of the cluster, and the objects must match their checksum.`)
+	restoreFlags = registerSnapshotFlags(restoreCmd)
+	restoreForce = restoreCmd.Flag("force", "Restore into a cluster which already has objects, overwriting the ones of the snapshot.").Bool()
+)
+
+// snapshotFlags are the flags of the backup and the restore commands.
+type snapshotFlags struct {
+	configFile         *string
+	snapshotConfigFile *string
+	snapshotStore      *string
+	snapshotName       *string
+	parallelism        *int
+}
+
+func registerSnapshotFlags(cmd *kingpin.CmdClause) snapshotFlags {
+	return snapshotFlags{
+		configFile:         cmd.Flag("config.file", "Loki config file of the cluster.").Required().ExistingFile(),
+		snapshotConfigFile: cmd.Flag("snapshot.config.file", "Loki config file of which the storage_config configures the store of the snapshots.").Required().ExistingFile(),
+		snapshotStore:      cmd.Flag("snapshot.store", "Type of the store of the snapshots, e.g. gcs, s3 or filesystem.").Required().String(),
+		snapshotName:       cmd.Flag("snapshot.name", "Name of the snapshot. The current time by default for a backup, the latest snapshot for a restore.").String(),
+		parallelism:        cmd.Flag("parallelism", "Number of objects copied in parallel.").Default("8").Int(),
+	}
+}
+
+func backupCluster(w io.Writer) error {
+	cluster, snapshots, err := backupFlags.load()
+	if err != nil {
+		return err
+	}
+	name := *backupFlags.snapshotName
+	if name == "" {
+		name = time.Now().UTC().Format("20060102T150405Z")
+	}
+	if strings.Contains(name, "/") {
+		return fmt.Errorf("invalid snapshot name %q", name)
+	}
+
+	manifest, err := backup.Backup(context.Background(), cluster, snapshots, name, backupFlags.options(false))
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(w, "Captured %d objects in snapshot %s.\n", len(manifest.Objects), name)
+	return nil
+}
+
+func restoreCluster(w io.Writer) error {
+	cluster, snapshots, err := restoreFlags.load()
+	if err != nil {
+		return err
+	}
+	name := *restoreFlags.snapshotName
+	if name == "" {
+		names, err := backup.List(context.Background(), snapshots)
+		if err != nil {
+			return err
+		}
+		if len(names) == 0 {
+			return fmt.Errorf("no snapshot found")
+		}
+		name = names[len(names)-1]
+	}
+
+	manifest, err := backup.Restore(context.Background(), cluster, snapshots, name, restoreFlags.options(*restoreForce))
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(w, "Restored %d objects of snapshot %s taken at %s.\n", len(manifest.Objects), name, manifest.CreatedAt.Format(time.RFC3339))
+	return nil
+}
+
+func (f snapshotFlags) options(force bool) backup.Options {
+	return backup.Options{
+		Parallelism: *f.parallelism,
+		Force:       force,
+		Logger:      log.With(util_log.Logger, "component", "backup"),
+	}
+}
+
+// load returns the stores of the cluster and the store of the snapshots.
+func (f snapshotFlags) load() (backup.Cluster, chunk.ObjectClient, error) {
+	clientMetrics := chunk_storage.NewClientMetrics()
+
+	config, err := loadLokiConfig(*f.configFile)
+	if err != nil {
+		return backup.Cluster{}, nil, err
+	}
+	if err := config.SchemaConfig.Validate(); err != nil {
+		return backup.Cluster{}, nil, err
+	}
+	cluster, err := clusterStores(config, clientMetrics)
+	if err != nil {
+		return backup.Cluster{}, nil, err
+	}
+
+	snapshotConfig, err := loadLokiConfig(*f.snapshotConfigFile)
+	if err != nil {
+		return backup.Cluster{}, nil, err
+	}
+	snapshots, err := chunk_storage.NewObjectClient(*f.snapshotStore, snapshotConfig.StorageConfig.Config, clientMetrics)
+	if err != nil {
+		return backup.Cluster{}, nil, fmt.Errorf("failed to create the client of the store of the snapshots: %w", err)
+	}
+	return cluster, snapshots, nil
+}
+
+// loadLokiConfig loads a Loki config file the way Loki does, with the defaults of the flags and the common config.
+func loadLokiConfig(file string) (loki.Config, error) {
+	var config loki.ConfigWrapper
+	fs := flag.NewFlagSet("loki", flag.ContinueOnError)
+	if err := cfg.DynamicUnmarshal(&config, []string{"-config.file=" + file}, fs); err != nil {
+		return loki.Config{}, fmt.Errorf("failed to parse the config file %s: %w", file, err)
+	}
+	validation.SetDefaultLimitsForYAMLUnmarshalling(config.LimitsConfig)
+	return config.Config, nil
+}
+
+// clusterStores returns the stores of the index files of the periods of the schema config, of the delete requests
+// and of the rules when they are stored in an object store.
+func clusterStores(config loki.Config, clientMetrics chunk_storage.ClientMetrics) (backup.Cluster, error) {
+	cluster := backup.Cluster{Schema: config.SchemaConfig.SchemaConfig}
+	excludeDeleteRequests := []string{deletion.DeleteRequestsTableName + "/"}
+
+	indexTypes := map[string]bool{}
+	for _, period := range config.SchemaConfig.Configs {
+		indexTypes[period.IndexType] = true
+	}
+	boltdb, tsdb := config.StorageConfig.BoltDBShipperConfig, config.StorageConfig.TSDBShipperConfig
+	if indexTypes[shipper.BoltDBShipperType] {
+		client, err := chunk_storage.NewObjectClient(boltdb.SharedStoreType, config.StorageConfig.Config, clientMetrics)
+		if err != nil {
+			return cluster, err
+		}
+		cluster.Stores = append(cluster.Stores, backup.Store{Kind: backup.KindBoltDBShipper, Client: client, Prefix: boltdb.SharedStoreKeyPrefix, Exclude: excludeDeleteRequests})
+	}
+	// the index files of both shippers sharing their store are captured once.
+	sharedStore := indexTypes[shipper.BoltDBShipperType] && tsdb.SharedStoreType == boltdb.SharedStoreType && tsdb.SharedStoreKeyPrefix == boltdb.SharedStoreKeyPrefix
+	if indexTypes[backup.KindTSDB] && !sharedStore {
+		client, err := chunk_storage.NewObjectClient(tsdb.SharedStoreType, config.StorageConfig.Config, clientMetrics)
+		if err != nil {
+			return cluster, err
+		}
+		cluster.Stores = append(cluster.Stores, backup.Store{Kind: backup.KindTSDB, Client: client, Prefix: tsdb.SharedStoreKeyPrefix, Exclude: excludeDeleteRequests})
+	}
+
+	if config.CompactorConfig.SharedStoreType != "" {
+		client, err := chunk_storage.NewObjectClient(config.CompactorConfig.SharedStoreType, config.StorageConfig.Config, clientMetrics)
+		if err != nil {
+			return cluster, err
+		}
+		cluster.Stores = append(cluster.Stores, backup.Store{Kind: backup.KindDeleteRequests, Client: client, Prefix: config.CompactorConfig.SharedStoreKeyPrefix + deletion.DeleteRequestsTableName + "/"})
+	}
+
+	if config.Ruler.StoreConfig.Type != "" {
+		client, err := base_ruler.NewLegacyRuleObjectClient(config.Ruler.StoreConfig, config.StorageConfig.Hedging, clientMetrics)
+		if err != nil {
+			return cluster, err
+		}
+		if client != nil {
+			cluster.Stores = append(cluster.Stores, backup.Store{Kind: backup.KindRules, Client: client, Prefix: objectclient.RulePrefix})
+		}
+	}
+	return cluster, nil
+}
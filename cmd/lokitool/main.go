@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/common/version"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"gopkg.in/yaml.v2"
+
+	"github.com/grafana/loki/pkg/storage"
+	"github.com/grafana/loki/pkg/storage/cost"
+	"github.com/grafana/loki/pkg/util/flagext"
+)
+
+var (
+	app = kingpin.New("lokitool", "A command-line tool to operate Loki.").Version(version.Print("lokitool"))
+
+	costCmd     = app.Command("cost", "Estimate the costs of the storage.")
+	estimateCmd = costCmd.Command("estimate", `Project the object counts, the storage bytes, the write requests
+and the index size of each period of a schema config.
+
+The projection is made for the data kept under retention at the given time,
+from the observed ingestion rate and number of active streams. Set --at after
+the start of a new period to plan a schema migration.`)
+
+	configFile       = estimateCmd.Flag("config.file", "Loki config file holding the schema_config, or a schema config file.").Required().ExistingFile()
+	ingestionRate    flagext.ByteSize
+	activeStreams    = estimateCmd.Flag("active-streams", "Number of active streams.").Required().Int()
+	labelsPerStream  = estimateCmd.Flag("labels-per-stream", "Average number of labels of the streams.").Default("6").Int()
+	compressionRatio = estimateCmd.Flag("compression-ratio", "Ratio of the ingested bytes to the bytes of the compressed chunks.").Default("8").Float64()
+	chunkTargetSize  = estimateCmd.Flag("chunk-target-size", "Compressed size the chunks are cut at, the chunk_target_size of the ingester config of the config file by default.").Int()
+	maxChunkAge      = estimateCmd.Flag("max-chunk-age", "Age the chunks are flushed at, the max_chunk_age of the ingester config of the config file by default.").Duration()
+	ingesters        = estimateCmd.Flag("ingesters", "Number of ingesters, uploading their index files.").Default("3").Int()
+	retention        = estimateCmd.Flag("retention", "Retention of the data, the retention_period of the limits config of the config file by default. 0 keeps the data forever.").Duration()
+	at               = estimateCmd.Flag("at", "Time the stored data is projected at, RFC3339. Now by default.").String()
+	outputMode       = estimateCmd.Flag("output", "Specify output mode [text, json].").Default("text").Short('o').Enum("text", "json")
+)
+
+// fileConfig holds the parts of the Loki config file the estimation depends on.
+type fileConfig struct {
+	SchemaConfig storage.SchemaConfig `yaml:"schema_config"`
+	Ingester     struct {
+		ChunkTargetSize int           `yaml:"chunk_target_size"`
+		MaxChunkAge     time.Duration `yaml:"max_chunk_age"`
+	} `yaml:"ingester"`
+	LimitsConfig struct {
+		RetentionPeriod model.Duration `yaml:"retention_period"`
+	} `yaml:"limits_config"`
+}
+
+func main() {
+	estimateCmd.Flag("ingestion-rate", "Observed ingestion rate per second, uncompressed, e.g. 10MB.").Required().SetValue(&ingestionRate)
+
+	switch kingpin.MustParse(app.Parse(os.Args[1:])) {
+	case estimateCmd.FullCommand():
+		if err := estimate(os.Stdout); err != nil {
+			log.Fatalf("Unable to estimate the costs: %s", err)
+		}
+	case backupCmd.FullCommand():
+		if err := backupCluster(os.Stdout); err != nil {
+			log.Fatalf("Unable to back up the cluster: %s", err)
+		}
+	case restoreCmd.FullCommand():
+		if err := restoreCluster(os.Stdout); err != nil {
+			log.Fatalf("Unable to restore the cluster: %s", err)
+		}
+	}
+}
+
+func estimate(w io.Writer) error {
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		return err
+	}
+
+	in := cost.Inputs{
+		IngestionRate:    float64(ingestionRate),
+		ActiveStreams:    *activeStreams,
+		LabelsPerStream:  *labelsPerStream,
+		CompressionRatio: *compressionRatio,
+		ChunkTargetSize:  firstInt(*chunkTargetSize, cfg.Ingester.ChunkTargetSize, 1572864),
+		MaxChunkAge:      firstDuration(*maxChunkAge, cfg.Ingester.MaxChunkAge, 2*time.Hour),
+		Ingesters:        *ingesters,
+		Retention:        firstDuration(*retention, time.Duration(cfg.LimitsConfig.RetentionPeriod), 0),
+		At:               time.Now().UTC(),
+	}
+	if *at != "" {
+		if in.At, err = time.Parse(time.RFC3339, *at); err != nil {
+			return fmt.Errorf("invalid time %q: %w", *at, err)
+		}
+	}
+
+	estimates, err := cost.Estimate(cfg.SchemaConfig.SchemaConfig, in)
+	if err != nil {
+		return err
+	}
+	if *outputMode == "json" {
+		return writeJSON(w, estimates)
+	}
+	return writeText(w, in, estimates)
+}
+
+func loadConfig(file string) (fileConfig, error) {
+	var cfg fileConfig
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return cfg, err
+	}
+	if err := yaml.Unmarshal(buf, &cfg); err != nil {
+		return cfg, fmt.Errorf("failed to parse the config file: %w", err)
+	}
+	// a schema config file only holds the periods.
+	if cfg.SchemaConfig.File == "" && len(cfg.SchemaConfig.Configs) == 0 {
+		cfg.SchemaConfig.File = file
+	}
+	if err := cfg.SchemaConfig.LoadFile(); err != nil {
+		return cfg, err
+	}
+	return cfg, cfg.SchemaConfig.Validate()
+}
+
+func writeText(w io.Writer, in cost.Inputs, estimates []cost.PeriodEstimate) error {
+	_, _ = fmt.Fprintf(w, "Projection at %s, chunks of %s on average.\n\n", in.At.Format(time.RFC3339), humanize.IBytes(uint64(in.ChunkSize())))
+
+	var total cost.PeriodEstimate
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "PERIOD\tSCHEMA\tINDEX\tOBJECT STORE\tRETENTION\tDATA FROM\tCHUNKS\tCHUNK BYTES\tINDEX TABLES\tINDEX OBJECTS\tINDEX BYTES\tPUT REQUESTS")
+	for _, e := range estimates {
+		dataFrom := "-"
+		if e.Through.After(e.From) {
+			dataFrom = e.From.UTC().Format("2006-01-02")
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%.0f\t%s\t%d\t%.0f\t%s\t%.0f\n",
+			e.Period.From.String(), e.Period.Schema, e.Period.IndexType, e.ObjectStore, formatRetention(e.Retention), dataFrom,
+			e.Chunks, humanize.IBytes(uint64(e.ChunkBytes)), e.IndexTables, e.IndexObjects, humanize.IBytes(uint64(e.IndexBytes)), e.PutRequests)
+
+		total.Chunks += e.Chunks
+		total.ChunkBytes += e.ChunkBytes
+		total.IndexTables += e.IndexTables
+		total.IndexObjects += e.IndexObjects
+		total.IndexBytes += e.IndexBytes
+		total.PutRequests += e.PutRequests
+	}
+	_, _ = fmt.Fprintf(tw, "TOTAL\t\t\t\t\t\t%.0f\t%s\t%d\t%.0f\t%s\t%.0f\n",
+		total.Chunks, humanize.IBytes(uint64(total.ChunkBytes)), total.IndexTables, total.IndexObjects, humanize.IBytes(uint64(total.IndexBytes)), total.PutRequests)
+	return tw.Flush()
+}
+
+type periodJSON struct {
+	From         string  `json:"from"`
+	Schema       string  `json:"schema"`
+	IndexType    string  `json:"index_type"`
+	ObjectStore  string  `json:"object_store"`
+	Retention    string  `json:"retention"`
+	DataFrom     string  `json:"data_from,omitempty"`
+	DataThrough  string  `json:"data_through,omitempty"`
+	Chunks       float64 `json:"chunks"`
+	ChunkBytes   float64 `json:"chunk_bytes"`
+	IndexTables  int     `json:"index_tables"`
+	IndexObjects float64 `json:"index_objects"`
+	IndexBytes   float64 `json:"index_bytes"`
+	PutRequests  float64 `json:"put_requests"`
+}
+
+func writeJSON(w io.Writer, estimates []cost.PeriodEstimate) error {
+	periods := make([]periodJSON, 0, len(estimates))
+	for _, e := range estimates {
+		p := periodJSON{
+			From:         e.Period.From.String(),
+			Schema:       e.Period.Schema,
+			IndexType:    e.Period.IndexType,
+			ObjectStore:  e.ObjectStore,
+			Retention:    formatRetention(e.Retention),
+			Chunks:       e.Chunks,
+			ChunkBytes:   e.ChunkBytes,
+			IndexTables:  e.IndexTables,
+			IndexObjects: e.IndexObjects,
+			IndexBytes:   e.IndexBytes,
+			PutRequests:  e.PutRequests,
+		}
+		// the periods not holding any data don't have any bounds.
+		if e.Through.After(e.From) {
+			p.DataFrom, p.DataThrough = e.From.UTC().Format(time.RFC3339), e.Through.UTC().Format(time.RFC3339)
+		}
+		periods = append(periods, p)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(periods)
+}
+
+func formatRetention(d time.Duration) string {
+	if d == 0 {
+		return "forever"
+	}
+	return model.Duration(d).String()
+}
+
+func firstInt(values ...int) int {
+	for _, v := range values {
+		if v != 0 {
+			return v
+		}
+	}
+	return 0
+}
+
+func firstDuration(values ...time.Duration) time.Duration {
+	for _, v := range values {
+		if v != 0 {
+			return v
+		}
+	}
+	return 0
+}
@@ -0,0 +1,87 @@
+package chunkenc
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// blockEntryWriter appends entries to the uncompressed bytes of a block.
+//
+// Up to chunkFormatV3 every entry is written as its timestamp (varint), the
+// length of its line (uvarint) and the line itself.
+// From chunkFormatV4 the first timestamp of a block is written as is, the
+// second one as the delta with the first, and all others as the delta of the
+// deltas (varint). Lines are front-coded: each entry stores the length of the
+// prefix it shares with the previous line (uvarint), the length of the
+// remaining suffix (uvarint) and the suffix itself. Regular timestamps and
+// structured lines then turn into long runs of tiny values which the block
+// compression handles much better.
+type blockEntryWriter struct {
+	buf    *bytes.Buffer
+	format byte
+	enc    [binary.MaxVarintLen64]byte
+
+	entries   int
+	prevTs    int64
+	prevDelta int64
+	prevLine  string
+}
+
+func newBlockEntryWriter(buf *bytes.Buffer, format byte) *blockEntryWriter {
+	return &blockEntryWriter{
+		buf:    buf,
+		format: format,
+	}
+}
+
+func (w *blockEntryWriter) write(ts int64, line string) {
+	if w.format < chunkFormatV4 {
+		w.putVarint(ts)
+		w.putUvarint(uint64(len(line)))
+		w.buf.WriteString(line)
+		return
+	}
+
+	switch w.entries {
+	case 0:
+		w.putVarint(ts)
+	case 1:
+		w.prevDelta = ts - w.prevTs
+		w.putVarint(w.prevDelta)
+	default:
+		delta := ts - w.prevTs
+		w.putVarint(delta - w.prevDelta)
+		w.prevDelta = delta
+	}
+	w.prevTs = ts
+	w.entries++
+
+	shared := sharedPrefixLen(w.prevLine, line)
+	w.putUvarint(uint64(shared))
+	w.putUvarint(uint64(len(line) - shared))
+	w.buf.WriteString(line[shared:])
+	w.prevLine = line
+}
+
+func (w *blockEntryWriter) putVarint(x int64) {
+	n := binary.PutVarint(w.enc[:], x)
+	w.buf.Write(w.enc[:n])
+}
+
+func (w *blockEntryWriter) putUvarint(x uint64) {
+	n := binary.PutUvarint(w.enc[:], x)
+	w.buf.Write(w.enc[:n])
+}
+
+// sharedPrefixLen returns the length of the longest common prefix of a and b.
+func sharedPrefixLen(a, b string) int {
+	n := len(a)
+	if len(b) < n {
+		n = len(b)
+	}
+	i := 0
+	for i < n && a[i] == b[i] {
+		i++
+	}
+	return i
+}
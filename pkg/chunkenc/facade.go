@@ -1,11 +1,18 @@
 package chunkenc
 
 import (
+	"context"
 	"io"
+	"time"
 
 	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
 
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/loki/pkg/logql/log"
 	"github.com/grafana/loki/pkg/storage/chunk/encoding"
+	"github.com/grafana/loki/pkg/storage/chunk/parquet"
+	"github.com/grafana/loki/pkg/util/filter"
 )
 
 // GzipLogChunk is a cortex encoding type for our chunks.
@@ -15,6 +22,9 @@ const GzipLogChunk = encoding.Encoding(128)
 // LogChunk is a cortex encoding type for our chunks.
 const LogChunk = encoding.Encoding(129)
 
+// ParquetLogChunk is a cortex encoding type for our chunks stored as Parquet files.
+const ParquetLogChunk = encoding.Encoding(130)
+
 func init() {
 	encoding.MustRegisterEncoding(GzipLogChunk, "GzipLogChunk", func() encoding.Chunk {
 		return &Facade{}
@@ -22,6 +32,9 @@ func init() {
 	encoding.MustRegisterEncoding(LogChunk, "LogChunk", func() encoding.Chunk {
 		return &Facade{}
 	})
+	encoding.MustRegisterEncoding(ParquetLogChunk, "ParquetLogChunk", func() encoding.Chunk {
+		return &Facade{parquet: true}
+	})
 }
 
 // Facade for compatibility with cortex chunk type, so we can use its chunk store.
@@ -29,6 +42,8 @@ type Facade struct {
 	c          Chunk
 	blockSize  int
 	targetSize int
+	// parquet tells whether the chunk is stored as a Parquet file.
+	parquet bool
 	encoding.Chunk
 }
 
@@ -41,26 +56,104 @@ func NewFacade(c Chunk, blockSize, targetSize int) encoding.Chunk {
 	}
 }
 
+// NewParquetFacade makes a new Facade storing the chunk as a Parquet file.
+func NewParquetFacade(c Chunk, blockSize, targetSize int) encoding.Chunk {
+	return &Facade{
+		c:          c,
+		blockSize:  blockSize,
+		targetSize: targetSize,
+		parquet:    true,
+	}
+}
+
 // Marshal implements encoding.Chunk.
 func (f Facade) Marshal(w io.Writer) error {
 	if f.c == nil {
 		return nil
 	}
+	if f.parquet {
+		return f.MarshalWithMetadata(w, nil, nil)
+	}
 	if _, err := f.c.WriteTo(w); err != nil {
 		return err
 	}
 	return nil
 }
 
+// SelfDescribing implements encoding.SelfDescribingChunk.
+func (f Facade) SelfDescribing() bool {
+	return f.parquet
+}
+
+// MarshalWithMetadata implements encoding.SelfDescribingChunk, it writes the entries of the chunk
+// as a Parquet file holding the metadata of the chunk store.
+func (f Facade) MarshalWithMetadata(w io.Writer, metric labels.Labels, metadata []byte) error {
+	if f.c == nil {
+		return nil
+	}
+	from, through := f.c.Bounds()
+	// the end of the iterator is exclusive.
+	it, err := f.c.Iterator(context.Background(), from, through.Add(time.Nanosecond), logproto.FORWARD, log.NewNoopPipeline().ForStream(labels.Labels{}))
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+
+	entries := make([]logproto.Entry, 0, f.c.Size())
+	for it.Next() {
+		entries = append(entries, it.Entry())
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+
+	var kv map[string]string
+	if metadata != nil {
+		kv = map[string]string{parquet.ChunkMetadataKey: string(metadata)}
+	}
+	stream := labels.NewBuilder(metric).Del(labels.MetricName).Labels().String()
+	return parquet.Write(w, stream, entries, kv)
+}
+
 // UnmarshalFromBuf implements encoding.Chunk.
 func (f *Facade) UnmarshalFromBuf(buf []byte) error {
 	var err error
+	if f.parquet {
+		f.c, err = newParquetChunk(buf, f.blockSize, f.targetSize)
+		return err
+	}
 	f.c, err = NewByteChunk(buf, f.blockSize, f.targetSize)
 	return err
 }
 
+// newParquetChunk returns a MemChunk holding the entries of a chunk stored as a Parquet file.
+func newParquetChunk(b []byte, blockSize, targetSize int) (*MemChunk, error) {
+	file, err := parquet.Open(b)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := file.Entries()
+	if err != nil {
+		return nil, err
+	}
+
+	if blockSize <= 0 {
+		blockSize = defaultBlockSize
+	}
+	c := NewMemChunk(EncSnappy, OrderedHeadBlockFmt, blockSize, targetSize)
+	for i := range entries {
+		if err := c.Append(&entries[i]); err != nil {
+			return nil, err
+		}
+	}
+	return c, c.Close()
+}
+
 // Encoding implements encoding.Chunk.
-func (Facade) Encoding() encoding.Encoding {
+func (f Facade) Encoding() encoding.Encoding {
+	if f.parquet {
+		return ParquetLogChunk
+	}
 	return LogChunk
 }
 
@@ -87,12 +180,18 @@ func (f Facade) LokiChunk() Chunk {
 }
 
 func (f Facade) Rebound(start, end model.Time) (encoding.Chunk, error) {
-	newChunk, err := f.c.Rebound(start.Time(), end.Time())
+	return f.ReboundWithFilter(start, end, nil)
+}
+
+// ReboundWithFilter builds a smaller chunk with the logs between start and end, omitting the lines filtered out by the filter.
+func (f Facade) ReboundWithFilter(start, end model.Time, filter filter.Func) (encoding.Chunk, error) {
+	newChunk, err := f.c.Rebound(start.Time(), end.Time(), filter)
 	if err != nil {
 		return nil, err
 	}
 	return &Facade{
-		c: newChunk,
+		c:       newChunk,
+		parquet: f.parquet,
 	}, nil
 }
 
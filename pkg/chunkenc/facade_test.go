@@ -0,0 +1,60 @@
+package chunkenc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/pkg/chunkenc/testdata"
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/loki/pkg/logql/log"
+	"github.com/grafana/loki/pkg/storage/chunk"
+	"github.com/grafana/loki/pkg/storage/chunk/parquet"
+)
+
+func TestParquetFacade(t *testing.T) {
+	c := NewMemChunk(EncSnappy, DefaultHeadBlockFmt, testBlockSize, testTargetSize)
+	for i := int64(1); i <= 1000; i++ {
+		require.NoError(t, c.Append(logprotoEntry(i*int64(time.Millisecond), testdata.LogString(i))))
+	}
+	require.NoError(t, c.Close())
+	from, through := c.Bounds()
+
+	metric := labels.Labels{{Name: labels.MetricName, Value: "logs"}, {Name: "app", Value: "foo"}}
+	ch := chunk.NewChunk("fake", model.Fingerprint(metric.Hash()), metric, NewParquetFacade(c, testBlockSize, testTargetSize), model.TimeFromUnixNano(from.UnixNano()), model.TimeFromUnixNano(through.UnixNano()))
+	require.NoError(t, ch.Encode())
+	encoded, err := ch.Encoded()
+	require.NoError(t, err)
+
+	// the chunk is a Parquet file holding its metadata.
+	require.True(t, parquet.IsFile(encoded))
+	f, err := parquet.Open(encoded)
+	require.NoError(t, err)
+	require.Equal(t, int64(c.Size()), f.NumRows())
+	_, ok := f.Metadata(parquet.ChunkMetadataKey)
+	require.True(t, ok)
+
+	decoded := chunk.Chunk{ChunkRef: ch.ChunkRef, ChecksumSet: true}
+	require.NoError(t, decoded.Decode(chunk.NewDecodeContext(), encoded))
+	require.Equal(t, ParquetLogChunk, decoded.Encoding)
+	require.Equal(t, metric, decoded.Metric)
+
+	expected, err := c.Iterator(context.Background(), from, through.Add(time.Nanosecond), logproto.FORWARD, log.NewNoopPipeline().ForStream(labels.Labels{}))
+	require.NoError(t, err)
+	actual, err := decoded.Data.(*Facade).LokiChunk().Iterator(context.Background(), from, through.Add(time.Nanosecond), logproto.FORWARD, log.NewNoopPipeline().ForStream(labels.Labels{}))
+	require.NoError(t, err)
+	for expected.Next() {
+		require.True(t, actual.Next())
+		require.Equal(t, expected.Entry(), actual.Entry())
+	}
+	require.False(t, actual.Next())
+
+	// rebounded chunks keep being stored as Parquet files.
+	rebounded, err := decoded.Data.Rebound(model.TimeFromUnixNano(from.UnixNano()), model.TimeFromUnixNano(through.UnixNano()))
+	require.NoError(t, err)
+	require.Equal(t, ParquetLogChunk, rebounded.Encoding())
+}
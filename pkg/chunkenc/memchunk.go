@@ -23,6 +23,7 @@ import (
 	"github.com/grafana/loki/pkg/logql/log"
 	"github.com/grafana/loki/pkg/logqlmodel/stats"
 	"github.com/grafana/loki/pkg/storage/chunk/encoding"
+	"github.com/grafana/loki/pkg/util/filter"
 	util_log "github.com/grafana/loki/pkg/util/log"
 )
 
@@ -31,6 +32,8 @@ const (
 	chunkFormatV1
 	chunkFormatV2
 	chunkFormatV3
+	// chunkFormatV4 is V3 with delta-of-delta timestamps and front-coded lines in blocks.
+	chunkFormatV4
 
 	DefaultChunkFormat = chunkFormatV3 // the currently used chunk format
 
@@ -174,7 +177,7 @@ func (hb *headBlock) Append(ts int64, line string) error {
 	return nil
 }
 
-func (hb *headBlock) Serialise(pool WriterPool) ([]byte, error) {
+func (hb *headBlock) Serialise(pool WriterPool, format byte) ([]byte, error) {
 	inBuf := serializeBytesBufferPool.Get().(*bytes.Buffer)
 	defer func() {
 		inBuf.Reset()
@@ -182,17 +185,11 @@ func (hb *headBlock) Serialise(pool WriterPool) ([]byte, error) {
 	}()
 	outBuf := &bytes.Buffer{}
 
-	encBuf := make([]byte, binary.MaxVarintLen64)
 	compressedWriter := pool.GetWriter(outBuf)
 	defer pool.PutWriter(compressedWriter)
+	w := newBlockEntryWriter(inBuf, format)
 	for _, logEntry := range hb.entries {
-		n := binary.PutVarint(encBuf, logEntry.t)
-		inBuf.Write(encBuf[:n])
-
-		n = binary.PutUvarint(encBuf, uint64(len(logEntry.s)))
-		inBuf.Write(encBuf[:n])
-
-		inBuf.WriteString(logEntry.s)
+		w.write(logEntry.t, logEntry.s)
 	}
 
 	if _, err := compressedWriter.Write(inBuf.Bytes()); err != nil {
@@ -345,6 +342,16 @@ func NewMemChunk(enc Encoding, head HeadBlockFmt, blockSize, targetSize int) *Me
 	}
 }
 
+// NewDeltaMemChunk returns a new in-mem chunk whose blocks store timestamps as
+// delta-of-delta varints and front-code lines before compressing them.
+// This improves the compression ratio of structured and repetitive logs, but
+// the chunks can't be read by versions of Loki which don't know this format.
+func NewDeltaMemChunk(enc Encoding, head HeadBlockFmt, blockSize, targetSize int) *MemChunk {
+	c := NewMemChunk(enc, head, blockSize, targetSize)
+	c.format = chunkFormatV4
+	return c
+}
+
 // NewByteChunk returns a MemChunk on the passed bytes.
 func NewByteChunk(b []byte, blockSize, targetSize int) (*MemChunk, error) {
 	bc := &MemChunk{
@@ -366,7 +373,7 @@ func NewByteChunk(b []byte, blockSize, targetSize int) (*MemChunk, error) {
 	switch version {
 	case chunkFormatV1:
 		bc.encoding = EncGZIP
-	case chunkFormatV2, chunkFormatV3:
+	case chunkFormatV2, chunkFormatV3, chunkFormatV4:
 		// format v2+ has a byte for block encoding.
 		enc := Encoding(db.byte())
 		if db.err() != nil {
@@ -401,7 +408,7 @@ func NewByteChunk(b []byte, blockSize, targetSize int) (*MemChunk, error) {
 
 		// Read offset and length.
 		blk.offset = db.uvarint()
-		if version == chunkFormatV3 {
+		if version >= chunkFormatV3 {
 			blk.uncompressedSize = db.uvarint()
 		}
 		l := db.uvarint()
@@ -460,7 +467,7 @@ func (c *MemChunk) BytesSize() int {
 		size += binary.MaxVarintLen64 // mint
 		size += binary.MaxVarintLen64 // maxt
 		size += binary.MaxVarintLen32 // offset
-		if c.format == chunkFormatV3 {
+		if c.format >= chunkFormatV3 {
 			size += binary.MaxVarintLen32 // uncompressed size
 		}
 		size += binary.MaxVarintLen32 // len(b)
@@ -534,7 +541,7 @@ func (c *MemChunk) WriteTo(w io.Writer) (int64, error) {
 		eb.putVarint64(b.mint)
 		eb.putVarint64(b.maxt)
 		eb.putUvarint(b.offset)
-		if c.format == chunkFormatV3 {
+		if c.format >= chunkFormatV3 {
 			eb.putUvarint(b.uncompressedSize)
 		}
 		eb.putUvarint(len(b.b))
@@ -713,7 +720,7 @@ func (c *MemChunk) reorder() error {
 
 	// Otherwise, we need to rebuild the blocks
 	from, to := c.Bounds()
-	newC, err := c.Rebound(from, to)
+	newC, err := c.Rebound(from, to, nil)
 	if err != nil {
 		return err
 	}
@@ -740,7 +747,7 @@ func (c *MemChunk) cut() error {
 		return nil
 	}
 
-	b, err := c.head.Serialise(getWriterPool(c.encoding))
+	b, err := c.head.Serialise(getWriterPool(c.encoding), c.format)
 	if err != nil {
 		return err
 	}
@@ -797,7 +804,7 @@ func (c *MemChunk) Iterator(ctx context.Context, mintT, maxtT time.Time, directi
 		}
 		lastMax = b.maxt
 
-		blockItrs = append(blockItrs, encBlock{c.encoding, b}.Iterator(ctx, pipeline))
+		blockItrs = append(blockItrs, encBlock{c.encoding, c.format, b}.Iterator(ctx, pipeline))
 	}
 
 	if !c.head.IsEmpty() {
@@ -871,7 +878,7 @@ func (c *MemChunk) SampleIterator(ctx context.Context, from, through time.Time,
 			ordered = false
 		}
 		lastMax = b.maxt
-		its = append(its, encBlock{c.encoding, b}.SampleIterator(ctx, extractor))
+		its = append(its, encBlock{c.encoding, c.format, b}.SampleIterator(ctx, extractor))
 	}
 
 	if !c.head.IsEmpty() {
@@ -903,14 +910,15 @@ func (c *MemChunk) Blocks(mintT, maxtT time.Time) []Block {
 
 	for _, b := range c.blocks {
 		if maxt >= b.mint && b.maxt >= mint {
-			blocks = append(blocks, encBlock{c.encoding, b})
+			blocks = append(blocks, encBlock{c.encoding, c.format, b})
 		}
 	}
 	return blocks
 }
 
-// Rebound builds a smaller chunk with logs having timestamp from start and end(both inclusive)
-func (c *MemChunk) Rebound(start, end time.Time) (Chunk, error) {
+// Rebound builds a smaller chunk with logs having timestamp from start and end(both inclusive),
+// omitting the lines filtered out by the filter if set.
+func (c *MemChunk) Rebound(start, end time.Time, filter filter.Func) (Chunk, error) {
 	// add a millisecond to end time because the Chunk.Iterator considers end time to be non-inclusive.
 	itr, err := c.Iterator(context.Background(), start, end.Add(time.Millisecond), logproto.FORWARD, log.NewNoopPipeline().ForStream(labels.Labels{}))
 	if err != nil {
@@ -928,9 +936,16 @@ func (c *MemChunk) Rebound(start, end time.Time) (Chunk, error) {
 		// For target chunk size I am using compressed size of original chunk since the newChunk should anyways be lower in size than that.
 		newChunk = NewMemChunk(c.Encoding(), c.headFmt, defaultBlockSize, c.CompressedSize())
 	}
+	// keep the block encoding of the original chunk, older formats are upgraded to the default one.
+	if c.format == chunkFormatV4 {
+		newChunk.format = chunkFormatV4
+	}
 
 	for itr.Next() {
 		entry := itr.Entry()
+		if filter != nil && filter(entry.Timestamp, entry.Line) {
+			continue
+		}
 		if err := newChunk.Append(&entry); err != nil {
 			return nil, err
 		}
@@ -952,7 +967,8 @@ func (c *MemChunk) Rebound(start, end time.Time) (Chunk, error) {
 // then allows us to bind a decoding context to a block when requested, but otherwise helps reduce the
 // chances of chunk<>block encoding drift in the codebase as the latter is parameterized by the former.
 type encBlock struct {
-	enc Encoding
+	enc    Encoding
+	format byte
 	block
 }
 
@@ -960,14 +976,14 @@ func (b encBlock) Iterator(ctx context.Context, pipeline log.StreamPipeline) ite
 	if len(b.b) == 0 {
 		return iter.NoopIterator
 	}
-	return newEntryIterator(ctx, getReaderPool(b.enc), b.b, pipeline)
+	return newEntryIterator(ctx, getReaderPool(b.enc), b.b, b.format, pipeline)
 }
 
 func (b encBlock) SampleIterator(ctx context.Context, extractor log.StreamSampleExtractor) iter.SampleIterator {
 	if len(b.b) == 0 {
 		return iter.NoopIterator
 	}
-	return newSampleIterator(ctx, getReaderPool(b.enc), b.b, extractor)
+	return newSampleIterator(ctx, getReaderPool(b.enc), b.b, b.format, extractor)
 }
 
 func (b block) Offset() int {
@@ -1006,7 +1022,7 @@ func (hb *headBlock) Iterator(ctx context.Context, direction logproto.Direction,
 			return
 		}
 		stats.AddHeadChunkBytes(int64(len(e.s)))
-		newLine, parsedLbs, ok := pipeline.ProcessString(e.s)
+		newLine, parsedLbs, ok := pipeline.ProcessString(e.t, e.s)
 		if !ok {
 			return
 		}
@@ -1056,7 +1072,7 @@ func (hb *headBlock) SampleIterator(ctx context.Context, mint, maxt int64, extra
 
 	for _, e := range hb.entries {
 		stats.AddHeadChunkBytes(int64(len(e.s)))
-		value, parsedLabels, ok := extractor.ProcessString(e.s)
+		value, parsedLabels, ok := extractor.ProcessString(e.t, e.s)
 		if !ok {
 			continue
 		}
@@ -1119,10 +1135,17 @@ type bufferedIterator struct {
 	currLine []byte // the current line, this is the same as the buffer but sliced the the line size.
 	currTs   int64
 
+	// state used to decode delta-of-delta timestamps and front-coded lines (chunkFormatV4).
+	format    byte
+	entries   int
+	prevTs    int64
+	prevDelta int64
+	prevLine  []byte
+
 	closed bool
 }
 
-func newBufferedIterator(ctx context.Context, pool ReaderPool, b []byte) *bufferedIterator {
+func newBufferedIterator(ctx context.Context, pool ReaderPool, b []byte, format byte) *bufferedIterator {
 	stats := stats.FromContext(ctx)
 	stats.AddCompressedBytes(int64(len(b)))
 	return &bufferedIterator{
@@ -1131,6 +1154,7 @@ func newBufferedIterator(ctx context.Context, pool ReaderPool, b []byte) *buffer
 		reader:    nil, // will be initialized later
 		bufReader: nil, // will be initialized later
 		pool:      pool,
+		format:    format,
 	}
 }
 
@@ -1169,6 +1193,22 @@ func (si *bufferedIterator) moveNext() (int64, []byte, bool) {
 		return 0, nil, false
 	}
 
+	var shared int
+	if si.format >= chunkFormatV4 {
+		ts = si.decodeTimestamp(ts)
+
+		p, err := binary.ReadUvarint(si.bufReader)
+		if err != nil {
+			si.err = err
+			return 0, nil, false
+		}
+		shared = int(p)
+		if shared > len(si.prevLine) {
+			si.err = fmt.Errorf("invalid shared prefix length %d, previous line length %d", shared, len(si.prevLine))
+			return 0, nil, false
+		}
+	}
+
 	l, err := binary.ReadUvarint(si.bufReader)
 	if err != nil {
 		if err != io.EOF {
@@ -1176,7 +1216,7 @@ func (si *bufferedIterator) moveNext() (int64, []byte, bool) {
 			return 0, nil, false
 		}
 	}
-	lineSize := int(l)
+	lineSize := shared + int(l)
 
 	if lineSize >= maxLineLength {
 		si.err = fmt.Errorf("line too long %d, maximum %d", lineSize, maxLineLength)
@@ -1194,12 +1234,15 @@ func (si *bufferedIterator) moveNext() (int64, []byte, bool) {
 			return 0, nil, false
 		}
 	}
+	// The prefix shared with the previous line is not stored again.
+	copy(si.buf[:shared], si.prevLine)
 	// Then process reading the line.
-	n, err := si.bufReader.Read(si.buf[:lineSize])
+	n, err := si.bufReader.Read(si.buf[shared:lineSize])
 	if err != nil && err != io.EOF {
 		si.err = err
 		return 0, nil, false
 	}
+	n += shared
 	for n < lineSize {
 		r, err := si.bufReader.Read(si.buf[n:lineSize])
 		if err != nil && err != io.EOF {
@@ -1208,9 +1251,28 @@ func (si *bufferedIterator) moveNext() (int64, []byte, bool) {
 		}
 		n += r
 	}
+	if si.format >= chunkFormatV4 {
+		si.prevLine = append(si.prevLine[:0], si.buf[:lineSize]...)
+	}
 	return ts, si.buf[:lineSize], true
 }
 
+// decodeTimestamp turns the delta-of-delta v read from a block back into a timestamp.
+func (si *bufferedIterator) decodeTimestamp(v int64) int64 {
+	switch si.entries {
+	case 0:
+		si.prevTs = v
+	case 1:
+		si.prevDelta = v
+		si.prevTs += v
+	default:
+		si.prevDelta += v
+		si.prevTs += si.prevDelta
+	}
+	si.entries++
+	return si.prevTs
+}
+
 func (si *bufferedIterator) Error() error { return si.err }
 
 func (si *bufferedIterator) Close() error {
@@ -1235,12 +1297,13 @@ func (si *bufferedIterator) close() {
 		BytesBufferPool.Put(si.buf)
 		si.buf = nil
 	}
+	si.prevLine = nil
 	si.origBytes = nil
 }
 
-func newEntryIterator(ctx context.Context, pool ReaderPool, b []byte, pipeline log.StreamPipeline) iter.EntryIterator {
+func newEntryIterator(ctx context.Context, pool ReaderPool, b []byte, format byte, pipeline log.StreamPipeline) iter.EntryIterator {
 	return &entryBufferedIterator{
-		bufferedIterator: newBufferedIterator(ctx, pool, b),
+		bufferedIterator: newBufferedIterator(ctx, pool, b, format),
 		pipeline:         pipeline,
 	}
 }
@@ -1263,7 +1326,7 @@ func (e *entryBufferedIterator) StreamHash() uint64 { return e.pipeline.BaseLabe
 
 func (e *entryBufferedIterator) Next() bool {
 	for e.bufferedIterator.Next() {
-		newLine, lbs, ok := e.pipeline.Process(e.currLine)
+		newLine, lbs, ok := e.pipeline.Process(e.currTs, e.currLine)
 		if !ok {
 			continue
 		}
@@ -1275,9 +1338,9 @@ func (e *entryBufferedIterator) Next() bool {
 	return false
 }
 
-func newSampleIterator(ctx context.Context, pool ReaderPool, b []byte, extractor log.StreamSampleExtractor) iter.SampleIterator {
+func newSampleIterator(ctx context.Context, pool ReaderPool, b []byte, format byte, extractor log.StreamSampleExtractor) iter.SampleIterator {
 	it := &sampleBufferedIterator{
-		bufferedIterator: newBufferedIterator(ctx, pool, b),
+		bufferedIterator: newBufferedIterator(ctx, pool, b, format),
 		extractor:        extractor,
 	}
 	return it
@@ -1294,7 +1357,7 @@ type sampleBufferedIterator struct {
 
 func (e *sampleBufferedIterator) Next() bool {
 	for e.bufferedIterator.Next() {
-		val, labels, ok := e.extractor.Process(e.currLine)
+		val, labels, ok := e.extractor.Process(e.currTs, e.currLine)
 		if !ok {
 			continue
 		}
@@ -219,7 +219,7 @@ func TestReadFormatV1(t *testing.T) {
 func TestRoundtripV2(t *testing.T) {
 	for _, f := range HeadBlockFmts {
 		for _, enc := range testEncoding {
-			for _, version := range []byte{chunkFormatV2, chunkFormatV3} {
+			for _, version := range []byte{chunkFormatV2, chunkFormatV3, chunkFormatV4} {
 				t.Run(enc.String(), func(t *testing.T) {
 					t.Parallel()
 
@@ -297,6 +297,71 @@ func TestRoundtripV3(t *testing.T) {
 	}
 }
 
+func TestDeltaMemChunk(t *testing.T) {
+	for _, f := range HeadBlockFmts {
+		for _, enc := range testEncoding {
+			f, enc := f, enc
+			t.Run(fmt.Sprintf("%v-%v", f, enc), func(t *testing.T) {
+				t.Parallel()
+
+				// entries with irregular timestamps, identical timestamps and lines sharing a prefix.
+				from := time.Unix(0, 1e18)
+				v3 := NewMemChunk(enc, f, 4*1024, 0)
+				v4 := NewDeltaMemChunk(enc, f, 4*1024, 0)
+				for i := 0; i < 2000; i++ {
+					e := &logproto.Entry{
+						Timestamp: from.Add(time.Duration(i/2)*time.Second + time.Duration(i/2%5)*time.Millisecond),
+						Line:      fmt.Sprintf(`level=info caller=handler.go:%d msg="request done" duration=%dms`, i%50, i%17),
+					}
+					require.NoError(t, v3.Append(e))
+					require.NoError(t, v4.Append(e))
+				}
+				// the last entry repeats the previous one in full.
+				require.NoError(t, v4.Append(&logproto.Entry{Timestamp: from.Add(time.Hour), Line: "foo"}))
+				require.NoError(t, v4.Append(&logproto.Entry{Timestamp: from.Add(time.Hour), Line: "foo"}))
+				require.NoError(t, v3.Append(&logproto.Entry{Timestamp: from.Add(time.Hour), Line: "foo"}))
+				require.NoError(t, v3.Append(&logproto.Entry{Timestamp: from.Add(time.Hour), Line: "foo"}))
+				require.NoError(t, v3.Close())
+				require.NoError(t, v4.Close())
+				require.Greater(t, v4.BlockCount(), 1)
+				require.Less(t, v4.CompressedSize(), v3.CompressedSize())
+
+				b, err := v4.Bytes()
+				require.NoError(t, err)
+				loaded, err := NewByteChunk(b, 4*1024, 0)
+				require.NoError(t, err)
+				require.Equal(t, chunkFormatV4, loaded.format)
+
+				for _, direction := range []logproto.Direction{logproto.FORWARD, logproto.BACKWARD} {
+					expected, err := v3.Iterator(context.Background(), from, from.Add(2*time.Hour), direction, noopStreamPipeline)
+					require.NoError(t, err)
+					actual, err := loaded.Iterator(context.Background(), from, from.Add(2*time.Hour), direction, noopStreamPipeline)
+					require.NoError(t, err)
+					for expected.Next() {
+						require.True(t, actual.Next())
+						require.Equal(t, expected.Entry(), actual.Entry())
+					}
+					require.False(t, actual.Next())
+					require.NoError(t, actual.Error())
+				}
+
+				expected := v3.SampleIterator(context.Background(), from, from.Add(2*time.Hour), countExtractor)
+				actual := loaded.SampleIterator(context.Background(), from, from.Add(2*time.Hour), countExtractor)
+				for expected.Next() {
+					require.True(t, actual.Next())
+					require.Equal(t, expected.Sample(), actual.Sample())
+				}
+				require.False(t, actual.Next())
+				require.NoError(t, actual.Error())
+
+				rebound, err := loaded.Rebound(from, from.Add(time.Second), nil)
+				require.NoError(t, err)
+				require.Equal(t, chunkFormatV4, rebound.(*MemChunk).format)
+			})
+		}
+	}
+}
+
 func TestSerialization(t *testing.T) {
 	for _, f := range HeadBlockFmts {
 		for _, enc := range testEncoding {
@@ -677,9 +742,11 @@ func BenchmarkWrite(b *testing.B) {
 
 type nomatchPipeline struct{}
 
-func (nomatchPipeline) BaseLabels() log.LabelsResult                         { return log.EmptyLabelsResult }
-func (nomatchPipeline) Process(line []byte) ([]byte, log.LabelsResult, bool) { return line, nil, false }
-func (nomatchPipeline) ProcessString(line string) (string, log.LabelsResult, bool) {
+func (nomatchPipeline) BaseLabels() log.LabelsResult { return log.EmptyLabelsResult }
+func (nomatchPipeline) Process(_ int64, line []byte) ([]byte, log.LabelsResult, bool) {
+	return line, nil, false
+}
+func (nomatchPipeline) ProcessString(_ int64, line string) (string, log.LabelsResult, bool) {
 	return line, nil, false
 }
 
@@ -1187,7 +1254,7 @@ func TestMemChunk_Rebound(t *testing.T) {
 		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
-			newChunk, err := originalChunk.Rebound(tc.sliceFrom, tc.sliceTo)
+			newChunk, err := originalChunk.Rebound(tc.sliceFrom, tc.sliceTo, nil)
 			if tc.err != nil {
 				require.Equal(t, tc.err, err)
 				return
@@ -1218,6 +1285,31 @@ func TestMemChunk_Rebound(t *testing.T) {
 	}
 }
 
+func TestMemChunk_ReboundWithFilter(t *testing.T) {
+	chkFrom := time.Unix(0, 0)
+	chkThrough := chkFrom.Add(time.Hour)
+	originalChunk := buildTestMemChunk(t, chkFrom, chkThrough)
+
+	// filter out the lines of even seconds within the first half of the chunk.
+	filterFunc := func(ts time.Time, line string) bool {
+		return ts.Before(chkFrom.Add(30*time.Minute)) && ts.Unix()%2 == 0 && line == ts.String()
+	}
+	newChunk, err := originalChunk.Rebound(chkFrom, chkThrough, filterFunc)
+	require.NoError(t, err)
+	require.Equal(t, originalChunk.Size()-900, newChunk.Size())
+
+	it, err := newChunk.Iterator(context.Background(), chkFrom, chkThrough, logproto.FORWARD, log.NewNoopPipeline().ForStream(labels.Labels{}))
+	require.NoError(t, err)
+	for it.Next() {
+		require.False(t, filterFunc(it.Entry().Timestamp, it.Entry().Line))
+	}
+	require.NoError(t, it.Error())
+
+	// a chunk without any line left is not built.
+	_, err = originalChunk.Rebound(chkFrom, chkThrough, func(_ time.Time, _ string) bool { return true })
+	require.Equal(t, encoding.ErrSliceNoDataInRange, err)
+}
+
 func buildTestMemChunk(t *testing.T, from, through time.Time) *MemChunk {
 	chk := NewMemChunk(EncGZIP, DefaultHeadBlockFmt, defaultBlockSize, 0)
 	for ; from.Before(through); from = from.Add(time.Second) {
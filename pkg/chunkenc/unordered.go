@@ -28,7 +28,7 @@ type HeadBlock interface {
 	CheckpointBytes(b []byte) ([]byte, error)
 	CheckpointSize() int
 	LoadBytes(b []byte) error
-	Serialise(pool WriterPool) ([]byte, error)
+	Serialise(pool WriterPool, format byte) ([]byte, error)
 	Reset()
 	Bounds() (mint, maxt int64)
 	Entries() int
@@ -227,7 +227,7 @@ func (hb *unorderedHeadBlock) Iterator(
 		mint,
 		maxt,
 		func(ts int64, line string) error {
-			newLine, parsedLbs, ok := pipeline.ProcessString(line)
+			newLine, parsedLbs, ok := pipeline.ProcessString(ts, line)
 			if !ok {
 				return nil
 			}
@@ -275,7 +275,7 @@ func (hb *unorderedHeadBlock) SampleIterator(
 		mint,
 		maxt,
 		func(ts int64, line string) error {
-			value, parsedLabels, ok := extractor.ProcessString(line)
+			value, parsedLabels, ok := extractor.ProcessString(ts, line)
 			if !ok {
 				return nil
 			}
@@ -319,7 +319,7 @@ func (hb *unorderedHeadBlock) SampleIterator(
 
 // nolint:unused
 // serialise is used in creating an ordered, compressed block from an unorderedHeadBlock
-func (hb *unorderedHeadBlock) Serialise(pool WriterPool) ([]byte, error) {
+func (hb *unorderedHeadBlock) Serialise(pool WriterPool, format byte) ([]byte, error) {
 	inBuf := serializeBytesBufferPool.Get().(*bytes.Buffer)
 	defer func() {
 		inBuf.Reset()
@@ -327,23 +327,17 @@ func (hb *unorderedHeadBlock) Serialise(pool WriterPool) ([]byte, error) {
 	}()
 	outBuf := &bytes.Buffer{}
 
-	encBuf := make([]byte, binary.MaxVarintLen64)
 	compressedWriter := pool.GetWriter(outBuf)
 	defer pool.PutWriter(compressedWriter)
 
+	w := newBlockEntryWriter(inBuf, format)
 	_ = hb.forEntries(
 		context.Background(),
 		logproto.FORWARD,
 		0,
 		math.MaxInt64,
 		func(ts int64, line string) error {
-			n := binary.PutVarint(encBuf, ts)
-			inBuf.Write(encBuf[:n])
-
-			n = binary.PutUvarint(encBuf, uint64(len(line)))
-			inBuf.Write(encBuf[:n])
-
-			inBuf.WriteString(line)
+			w.write(ts, line)
 			return nil
 		},
 	)
@@ -0,0 +1,232 @@
+// Package client is a client of the APIs of Loki: the push, query, tail, rules and delete APIs.
+//
+// The requests failing with a server error or a rate limit are retried with a backoff, and the tenant of the requests
+// is the one of the client unless another one is set in their context with WithTenantIDs.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/grafana/dskit/backoff"
+	json "github.com/json-iterator/go"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/loki/pkg/util/build"
+	serverutil "github.com/grafana/loki/pkg/util/server"
+)
+
+const maxErrMsgLen = 1024
+
+var defaultUserAgent = fmt.Sprintf("loki-client/%s", build.Version)
+
+// Config configures a Client.
+type Config struct {
+	// Address is the URL of Loki, or of the gateway in front of it.
+	Address string
+	// TenantID is the tenant of the requests, unless another one is set in their context.
+	TenantID string
+	// Username and Password authenticate the requests with basic auth.
+	Username string
+	Password string
+	// BearerToken authenticates the requests with a bearer token, it can't be combined with basic auth.
+	BearerToken string
+	// UserAgent is the user agent of the requests, loki-client/<version> when empty.
+	UserAgent string
+	// Backoff is the backoff of the requests retried, they aren't retried when its MaxRetries is 0.
+	Backoff backoff.Config
+	// HTTPClient sends the requests, a client without timeout when nil for the tail and the long queries to not fail.
+	HTTPClient *http.Client
+}
+
+// DefaultBackoff retries the requests up to 5 times, from 500ms to 10s apart.
+var DefaultBackoff = backoff.Config{
+	MinBackoff: 500 * time.Millisecond,
+	MaxBackoff: 10 * time.Second,
+	MaxRetries: 5,
+}
+
+// Client is a client of the APIs of Loki. It is safe for concurrent use.
+type Client struct {
+	cfg     Config
+	address *url.URL
+	client  *http.Client
+}
+
+// New makes a new Client.
+func New(cfg Config) (*Client, error) {
+	address, err := url.Parse(cfg.Address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %q: %w", cfg.Address, err)
+	}
+	if address.Scheme != "http" && address.Scheme != "https" {
+		return nil, fmt.Errorf("invalid address %q: the scheme must be http or https", cfg.Address)
+	}
+	if (cfg.Username != "" || cfg.Password != "") && cfg.BearerToken != "" {
+		return nil, fmt.Errorf("at most one of basic auth and bearer token can be configured")
+	}
+	if cfg.UserAgent == "" {
+		cfg.UserAgent = defaultUserAgent
+	}
+
+	c := &Client{
+		cfg:     cfg,
+		address: address,
+		client:  cfg.HTTPClient,
+	}
+	if c.client == nil {
+		c.client = &http.Client{}
+	}
+	return c, nil
+}
+
+// WithTenantIDs returns a context whose requests are sent for the tenants, the queries of several tenants reading the
+// logs of all of them.
+func WithTenantIDs(ctx context.Context, tenantIDs ...string) context.Context {
+	return user.InjectOrgID(ctx, strings.Join(tenantIDs, "|"))
+}
+
+// Error is the error of a request failing with a non 2xx status code.
+type Error struct {
+	StatusCode int
+	Message    string
+	// Retryable tells whether the request may succeed when retried.
+	Retryable bool
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("server returned HTTP status %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// request is a request sent to Loki.
+type request struct {
+	method      string
+	path        string
+	query       url.Values
+	body        []byte
+	contentType string
+}
+
+func (c *Client) url(path string, query url.Values) *url.URL {
+	u := *c.address
+	u.Path = joinPath(u.Path, path)
+	u.RawQuery = query.Encode()
+	return &u
+}
+
+func joinPath(base, p string) string {
+	joined := path.Join(base, p)
+	// path.Join drops the trailing slash of the path.
+	if strings.HasSuffix(p, "/") && !strings.HasSuffix(joined, "/") {
+		joined += "/"
+	}
+	return joined
+}
+
+func (c *Client) header(ctx context.Context) http.Header {
+	h := make(http.Header)
+	h.Set("User-Agent", c.cfg.UserAgent)
+
+	if orgID, err := user.ExtractOrgID(ctx); err == nil {
+		h.Set(user.OrgIDHeaderName, orgID)
+	} else if c.cfg.TenantID != "" {
+		h.Set(user.OrgIDHeaderName, c.cfg.TenantID)
+	}
+
+	if c.cfg.Username != "" || c.cfg.Password != "" {
+		h.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(c.cfg.Username+":"+c.cfg.Password)))
+	}
+	if c.cfg.BearerToken != "" {
+		h.Set("Authorization", "Bearer "+c.cfg.BearerToken)
+	}
+	return h
+}
+
+// do sends the request, retrying it with a backoff while it fails with a retryable error, and returns the body of its
+// response. The caller must close the body.
+func (c *Client) do(ctx context.Context, r request) (io.ReadCloser, error) {
+	b := backoff.New(ctx, c.cfg.Backoff)
+	for {
+		body, err := c.doOnce(ctx, r)
+		if err == nil {
+			return body, nil
+		}
+
+		var httpErr *Error
+		if c.cfg.Backoff.MaxRetries == 0 || (errors.As(err, &httpErr) && !httpErr.Retryable) {
+			return nil, err
+		}
+
+		b.Wait()
+		if !b.Ongoing() {
+			return nil, err
+		}
+	}
+}
+
+func (c *Client) doOnce(ctx context.Context, r request) (io.ReadCloser, error) {
+	var body io.Reader
+	if r.body != nil {
+		body = bytes.NewReader(r.body)
+	}
+	req, err := http.NewRequestWithContext(ctx, r.method, c.url(r.path, r.query).String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = c.header(ctx)
+	if r.contentType != "" {
+		req.Header.Set("Content-Type", r.contentType)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp.Body, nil
+	}
+
+	defer resp.Body.Close()
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrMsgLen))
+	return nil, &Error{
+		StatusCode: resp.StatusCode,
+		Message:    strings.TrimSpace(string(msg)),
+		// the requests failing with errors like parse errors or exceeded limits fail again when retried.
+		Retryable: (resp.StatusCode/100 == 5 || resp.StatusCode == http.StatusTooManyRequests) &&
+			resp.Header.Get(serverutil.ErrorRetryableHeader) != "false",
+	}
+}
+
+// doAndClose sends the request and discards the body of its response.
+func (c *Client) doAndClose(ctx context.Context, r request) error {
+	body, err := c.do(ctx, r)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(ioutil.Discard, body)
+	return body.Close()
+}
+
+// doJSON sends the request and decodes the JSON body of its response into out.
+func (c *Client) doJSON(ctx context.Context, r request, out interface{}) error {
+	body, err := c.do(ctx, r)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	if err := json.NewDecoder(body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode the response of %s: %w", r.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,409 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/gorilla/websocket"
+	"github.com/grafana/dskit/backoff"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+	"gopkg.in/yaml.v3"
+
+	"github.com/grafana/loki/pkg/loghttp"
+	"github.com/grafana/loki/pkg/logproto"
+)
+
+func newTestClient(t *testing.T, handler http.Handler, cfg Config) *Client {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	cfg.Address = server.URL
+	c, err := New(cfg)
+	require.NoError(t, err)
+	return c
+}
+
+func TestClient_Push(t *testing.T) {
+	var received []*http.Request
+	var pushed logproto.PushRequest
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r)
+		if len(received) == 1 {
+			http.Error(w, "ingester unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		buf, err := snappy.Decode(nil, body)
+		require.NoError(t, err)
+		require.NoError(t, proto.Unmarshal(buf, &pushed))
+		w.WriteHeader(http.StatusNoContent)
+	}), Config{
+		TenantID: "tenant",
+		Username: "user",
+		Password: "secret",
+		Backoff:  backoff.Config{MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond, MaxRetries: 2},
+	})
+
+	streams := []logproto.Stream{{
+		Labels:  `{foo="bar"}`,
+		Entries: []logproto.Entry{{Timestamp: time.Unix(1, 0).UTC(), Line: "hello"}},
+	}}
+	require.NoError(t, c.Push(context.Background(), streams))
+
+	// the push failing with a server error is retried.
+	require.Len(t, received, 2)
+	require.Equal(t, pushPath, received[1].URL.Path)
+	require.Equal(t, pushContentType, received[1].Header.Get("Content-Type"))
+	require.Equal(t, "tenant", received[1].Header.Get(user.OrgIDHeaderName))
+	username, password, ok := received[1].BasicAuth()
+	require.True(t, ok)
+	require.Equal(t, "user", username)
+	require.Equal(t, "secret", password)
+	require.Equal(t, streams, pushed.Streams)
+}
+
+func TestClient_Retries(t *testing.T) {
+	for _, tc := range []struct {
+		name             string
+		code             int
+		header           http.Header
+		expectedRequests int
+	}{
+		{name: "server error", code: http.StatusInternalServerError, expectedRequests: 3},
+		{name: "rate limited", code: http.StatusTooManyRequests, expectedRequests: 3},
+		{name: "bad request", code: http.StatusBadRequest, expectedRequests: 1},
+		{name: "not retryable server error", code: http.StatusInternalServerError, header: http.Header{"X-Loki-Error-Retryable": {"false"}}, expectedRequests: 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			requests := 0
+			c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				for k, v := range tc.header {
+					w.Header()[k] = v
+				}
+				http.Error(w, "failed", tc.code)
+			}), Config{Backoff: backoff.Config{MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond, MaxRetries: 3}})
+
+			_, err := c.Labels(context.Background(), time.Unix(0, 0), time.Unix(10, 0))
+			require.Error(t, err)
+			var httpErr *Error
+			require.ErrorAs(t, err, &httpErr)
+			require.Equal(t, tc.code, httpErr.StatusCode)
+			require.Equal(t, "failed", httpErr.Message)
+			require.Equal(t, tc.expectedRequests, requests)
+		})
+	}
+}
+
+func TestClient_TenantIDs(t *testing.T) {
+	var orgIDs []string
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		orgIDs = append(orgIDs, r.Header.Get(user.OrgIDHeaderName))
+		_, _ = w.Write([]byte(`{"status":"success","data":["foo"]}`))
+	}), Config{TenantID: "tenant"})
+
+	_, err := c.Labels(context.Background(), time.Unix(0, 0), time.Unix(10, 0))
+	require.NoError(t, err)
+	values, err := c.LabelValues(WithTenantIDs(context.Background(), "team-a", "team-b"), "foo", time.Unix(0, 0), time.Unix(10, 0))
+	require.NoError(t, err)
+	require.Equal(t, []string{"foo"}, values)
+	require.Equal(t, []string{"tenant", "team-a|team-b"}, orgIDs)
+}
+
+type testEntry struct {
+	ts   int64
+	line string
+}
+
+// queryRangeHandler serves the range queries from the entries of a stream.
+func queryRangeHandler(t *testing.T, entries []testEntry, requests *int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		require.Equal(t, queryRangePath, r.URL.Path)
+		params := r.URL.Query()
+		start, err := strconv.ParseInt(params.Get("start"), 10, 64)
+		require.NoError(t, err)
+		end, err := strconv.ParseInt(params.Get("end"), 10, 64)
+		require.NoError(t, err)
+		limit, err := strconv.Atoi(params.Get("limit"))
+		require.NoError(t, err)
+
+		var selected []testEntry
+		for _, e := range entries {
+			if e.ts >= start && e.ts < end {
+				selected = append(selected, e)
+			}
+		}
+		if params.Get("direction") == logproto.BACKWARD.String() {
+			sort.SliceStable(selected, func(i, j int) bool { return selected[i].ts > selected[j].ts })
+		}
+		if len(selected) > limit {
+			selected = selected[:limit]
+		}
+
+		values := make([][]string, 0, len(selected))
+		for _, e := range selected {
+			values = append(values, []string{strconv.FormatInt(e.ts, 10), e.line})
+		}
+		resp, err := json.Marshal(map[string]interface{}{
+			"status": "success",
+			"data": map[string]interface{}{
+				"resultType": "streams",
+				"result":     []interface{}{map[string]interface{}{"stream": map[string]string{"foo": "bar"}, "values": values}},
+			},
+		})
+		require.NoError(t, err)
+		_, _ = w.Write(resp)
+	})
+}
+
+func TestClient_Entries(t *testing.T) {
+	// entries 3 and 4 share their timestamp, as do entries 6, 7 and 8.
+	var entries []testEntry
+	for i, ts := range []int64{1, 2, 3, 3, 4, 5, 5, 5, 6, 7} {
+		entries = append(entries, testEntry{ts: ts, line: fmt.Sprintf("line %d", i)})
+	}
+
+	for _, tc := range []struct {
+		name      string
+		direction logproto.Direction
+		limit     int
+		batchSize int
+		expected  []string
+	}{
+		{
+			name:      "forward",
+			direction: logproto.FORWARD,
+			batchSize: 4,
+			expected:  []string{"line 0", "line 1", "line 2", "line 3", "line 4", "line 5", "line 6", "line 7", "line 8", "line 9"},
+		},
+		{
+			name:      "backward",
+			direction: logproto.BACKWARD,
+			batchSize: 4,
+			expected:  []string{"line 9", "line 8", "line 5", "line 6", "line 7", "line 4", "line 2", "line 3", "line 1", "line 0"},
+		},
+		{
+			name:      "limit",
+			direction: logproto.FORWARD,
+			limit:     5,
+			batchSize: 3,
+			expected:  []string{"line 0", "line 1", "line 2", "line 3", "line 4"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			requests := 0
+			c := newTestClient(t, queryRangeHandler(t, entries, &requests), Config{})
+
+			it := c.Entries(context.Background(), QueryRangeRequest{
+				Query:     `{foo="bar"}`,
+				Start:     time.Unix(0, 0),
+				End:       time.Unix(0, 100),
+				Limit:     tc.limit,
+				Direction: tc.direction,
+			}, tc.batchSize)
+
+			var lines []string
+			for it.Next() {
+				require.Equal(t, loghttp.LabelSet{"foo": "bar"}, it.At().Labels)
+				lines = append(lines, it.At().Line)
+			}
+			require.NoError(t, it.Err())
+			require.ElementsMatch(t, tc.expected, lines)
+			// the entries are returned in the direction of the query.
+			for i := 1; i < len(lines); i++ {
+				prev, cur := entryTs(entries, lines[i-1]), entryTs(entries, lines[i])
+				if tc.direction == logproto.FORWARD {
+					require.LessOrEqual(t, prev, cur)
+				} else {
+					require.GreaterOrEqual(t, prev, cur)
+				}
+			}
+			require.Greater(t, requests, 1)
+		})
+	}
+
+	t.Run("batch size too small", func(t *testing.T) {
+		requests := 0
+		c := newTestClient(t, queryRangeHandler(t, entries, &requests), Config{})
+
+		it := c.Entries(context.Background(), QueryRangeRequest{
+			Query:     `{foo="bar"}`,
+			Start:     time.Unix(0, 5),
+			End:       time.Unix(0, 100),
+			Direction: logproto.FORWARD,
+		}, 2)
+		for it.Next() {
+		}
+		require.Error(t, it.Err())
+	})
+}
+
+func entryTs(entries []testEntry, line string) int64 {
+	for _, e := range entries {
+		if e.line == line {
+			return e.ts
+		}
+	}
+	return -1
+}
+
+func TestClient_Tail(t *testing.T) {
+	var upgrader websocket.Upgrader
+	var mtx sync.Mutex
+	var tenant string
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, tailPath, r.URL.Path)
+		require.Equal(t, `{foo="bar"}`, r.URL.Query().Get("query"))
+		mtx.Lock()
+		tenant = r.Header.Get(user.OrgIDHeaderName)
+		mtx.Unlock()
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+		for i := 0; i < 2; i++ {
+			require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf(
+				`{"streams":[{"stream":{"foo":"bar"},"values":[["%d","line %d"]]}]}`, i+1, i))))
+		}
+		// the tail lasts until the client closes it.
+		_, _, _ = conn.ReadMessage()
+	}), Config{TenantID: "tenant"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	it, err := c.Tail(ctx, TailRequest{Query: `{foo="bar"}`})
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		require.True(t, it.Next())
+		require.Equal(t, fmt.Sprintf("line %d", i), it.At().Line)
+		require.Equal(t, time.Unix(0, int64(i+1)), it.At().Timestamp)
+	}
+
+	// the tail stops once the context is canceled.
+	cancel()
+	require.False(t, it.Next())
+	require.ErrorIs(t, it.Err(), context.Canceled)
+	mtx.Lock()
+	require.Equal(t, "tenant", tenant)
+	mtx.Unlock()
+}
+
+func TestClient_Rules(t *testing.T) {
+	stored := map[string][]rulefmt.RuleGroup{}
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == rulesPath+"/ns":
+			var rg rulefmt.RuleGroup
+			require.NoError(t, yaml.NewDecoder(r.Body).Decode(&rg))
+			stored["ns"] = append(stored["ns"], rg)
+			w.WriteHeader(http.StatusAccepted)
+		case r.Method == http.MethodGet && r.URL.Path == rulesPath:
+			if len(stored) == 0 {
+				http.Error(w, "no rule groups found", http.StatusNotFound)
+				return
+			}
+			require.NoError(t, yaml.NewEncoder(w).Encode(stored))
+		case r.Method == http.MethodGet && r.URL.Path == rulesPath+"/ns/group":
+			require.NoError(t, yaml.NewEncoder(w).Encode(stored["ns"][0]))
+		case r.Method == http.MethodDelete && r.URL.Path == rulesPath+"/ns":
+			delete(stored, "ns")
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			http.NotFound(w, r)
+		}
+	}), Config{})
+	ctx := context.Background()
+
+	ruleGroups, err := c.ListRuleGroups(ctx, "")
+	require.NoError(t, err)
+	require.Empty(t, ruleGroups)
+
+	var rg rulefmt.RuleGroup
+	require.NoError(t, yaml.Unmarshal([]byte(`
+name: group
+rules:
+  - alert: HighErrorRate
+    expr: sum(rate({app="foo"} |= "error" [5m])) > 10
+`), &rg))
+	require.NoError(t, c.SetRuleGroup(ctx, "ns", rg))
+
+	ruleGroups, err = c.ListRuleGroups(ctx, "")
+	require.NoError(t, err)
+	require.Len(t, ruleGroups["ns"], 1)
+	require.Equal(t, `sum(rate({app="foo"} |= "error" [5m])) > 10`, ruleGroups["ns"][0].Rules[0].Expr.Value)
+
+	group, err := c.GetRuleGroup(ctx, "ns", "group")
+	require.NoError(t, err)
+	require.Equal(t, "HighErrorRate", group.Rules[0].Alert.Value)
+
+	require.NoError(t, c.DeleteNamespace(ctx, "ns"))
+	require.Empty(t, stored)
+}
+
+func TestClient_Delete(t *testing.T) {
+	var added []*http.Request
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == deletePath:
+			added = append(added, r)
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodGet && r.URL.Path == deletePath:
+			require.Equal(t, DeleteRequestProcessed, r.URL.Query().Get("status"))
+			_, _ = w.Write([]byte(`[{"request_id":"1","start_time":1,"end_time":2,"selectors":["{foo=\"bar\"}"],"status":"processed","created_at":3}]`))
+		case r.Method == http.MethodGet && r.URL.Path == deleteAuditPath:
+			require.Equal(t, "1", r.URL.Query().Get("request_id"))
+			_, _ = w.Write([]byte(`[{"request_id":"1","lines":10,"chunks":1,"streams":[{"stream":"{foo=\"bar\"}","lines":10,"chunks":1}]}]`))
+		case r.Method == http.MethodPost && r.URL.Path == cancelDeleteRequestPath:
+			http.Error(w, "could not find delete request with given id", http.StatusNotFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}), Config{})
+	ctx := context.Background()
+
+	require.NoError(t, c.AddDeleteRequest(ctx, `{foo="bar"} |= "password"`, time.Unix(1, 0), time.Unix(2, 0)))
+	require.Len(t, added, 1)
+	require.Equal(t, `{foo="bar"} |= "password"`, added[0].URL.Query().Get("match[]"))
+	require.Equal(t, "1970-01-01T00:00:01Z", added[0].URL.Query().Get("start"))
+
+	deleteRequests, err := c.ListDeleteRequests(ctx, DeleteRequestProcessed)
+	require.NoError(t, err)
+	require.Equal(t, []DeleteRequest{{
+		RequestID: "1",
+		StartTime: 1000,
+		EndTime:   2000,
+		Selectors: []string{`{foo="bar"}`},
+		Status:    DeleteRequestProcessed,
+		CreatedAt: 3000,
+	}}, deleteRequests)
+
+	audits, err := c.DeleteRequestAudits(ctx, "1")
+	require.NoError(t, err)
+	require.Equal(t, []DeleteRequestAudit{{
+		RequestID: "1",
+		Lines:     10,
+		Chunks:    1,
+		Streams:   []StreamRemoval{{Stream: `{foo="bar"}`, Lines: 10, Chunks: 1}},
+	}}, audits)
+
+	err = c.CancelDeleteRequest(ctx, "unknown")
+	var httpErr *Error
+	require.ErrorAs(t, err, &httpErr)
+	require.Equal(t, http.StatusNotFound, httpErr.StatusCode)
+}
@@ -0,0 +1,95 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+const (
+	deletePath              = "/loki/api/admin/delete"
+	cancelDeleteRequestPath = "/loki/api/admin/cancel_delete_request"
+	deleteAuditPath         = "/loki/api/admin/delete_audit"
+)
+
+// The statuses of the delete requests.
+const (
+	DeleteRequestReceived  = "received"
+	DeleteRequestProcessed = "processed"
+)
+
+// DeleteRequest is a request deleting the entries of the streams matching the selectors between its start and end.
+type DeleteRequest struct {
+	RequestID string     `json:"request_id"`
+	StartTime model.Time `json:"start_time"`
+	EndTime   model.Time `json:"end_time"`
+	Selectors []string   `json:"selectors"`
+	Status    string     `json:"status"`
+	CreatedAt model.Time `json:"created_at"`
+}
+
+// StreamRemoval is the number of lines and chunks removed from a stream by a delete request.
+type StreamRemoval struct {
+	Stream string `json:"stream"`
+	Lines  int64  `json:"lines"`
+	Chunks int64  `json:"chunks"`
+}
+
+// DeleteRequestAudit is the audit trail of what a processed delete request removed.
+type DeleteRequestAudit struct {
+	RequestID string          `json:"request_id"`
+	Lines     int64           `json:"lines"`
+	Chunks    int64           `json:"chunks"`
+	Streams   []StreamRemoval `json:"streams"`
+}
+
+// AddDeleteRequest requests the deletion of the entries of the streams matching the selector between start and end,
+// the selector being optionally followed by line filters to only delete the lines matching them.
+func (c *Client) AddDeleteRequest(ctx context.Context, selector string, start, end time.Time) error {
+	params := url.Values{}
+	params.Set("match[]", selector)
+	params.Set("start", start.UTC().Format(time.RFC3339Nano))
+	params.Set("end", end.UTC().Format(time.RFC3339Nano))
+
+	return c.doAndClose(ctx, request{method: http.MethodPost, path: deletePath, query: params})
+}
+
+// ListDeleteRequests returns the delete requests having the status, all of them when it is empty.
+func (c *Client) ListDeleteRequests(ctx context.Context, status string) ([]DeleteRequest, error) {
+	params := url.Values{}
+	if status != "" {
+		params.Set("status", status)
+	}
+
+	var deleteRequests []DeleteRequest
+	if err := c.doJSON(ctx, request{method: http.MethodGet, path: deletePath, query: params}, &deleteRequests); err != nil {
+		return nil, err
+	}
+	return deleteRequests, nil
+}
+
+// CancelDeleteRequest cancels the delete request, which must not have been processed yet.
+func (c *Client) CancelDeleteRequest(ctx context.Context, requestID string) error {
+	params := url.Values{}
+	params.Set("request_id", requestID)
+
+	return c.doAndClose(ctx, request{method: http.MethodPost, path: cancelDeleteRequestPath, query: params})
+}
+
+// DeleteRequestAudits returns the audit trail of the processed delete request, of all of them when requestID is
+// empty.
+func (c *Client) DeleteRequestAudits(ctx context.Context, requestID string) ([]DeleteRequestAudit, error) {
+	params := url.Values{}
+	if requestID != "" {
+		params.Set("request_id", requestID)
+	}
+
+	var audits []DeleteRequestAudit
+	if err := c.doJSON(ctx, request{method: http.MethodGet, path: deleteAuditPath, query: params}, &audits); err != nil {
+		return nil, err
+	}
+	return audits, nil
+}
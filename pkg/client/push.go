@@ -0,0 +1,31 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+
+	"github.com/grafana/loki/pkg/logproto"
+)
+
+const (
+	pushPath        = "/loki/api/v1/push"
+	pushContentType = "application/x-protobuf"
+)
+
+// Push pushes the entries of the streams, in a snappy-compressed protobuf request.
+func (c *Client) Push(ctx context.Context, streams []logproto.Stream) error {
+	buf, err := proto.Marshal(&logproto.PushRequest{Streams: streams})
+	if err != nil {
+		return err
+	}
+
+	return c.doAndClose(ctx, request{
+		method:      http.MethodPost,
+		path:        pushPath,
+		body:        snappy.Encode(nil, buf),
+		contentType: pushContentType,
+	})
+}
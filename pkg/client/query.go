@@ -0,0 +1,260 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/grafana/loki/pkg/loghttp"
+	"github.com/grafana/loki/pkg/logproto"
+)
+
+const (
+	queryPath       = "/loki/api/v1/query"
+	queryRangePath  = "/loki/api/v1/query_range"
+	labelsPath      = "/loki/api/v1/labels"
+	labelValuesPath = "/loki/api/v1/label/%s/values"
+	seriesPath      = "/loki/api/v1/series"
+
+	defaultBatchSize = 1000
+)
+
+// Query runs an instant query, at ts, returning at most limit entries when it is a log query.
+func (c *Client) Query(ctx context.Context, query string, ts time.Time, limit int, direction logproto.Direction) (*loghttp.QueryResponse, error) {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("time", formatTime(ts))
+	params.Set("limit", strconv.Itoa(limit))
+	params.Set("direction", direction.String())
+
+	var resp loghttp.QueryResponse
+	if err := c.doJSON(ctx, request{method: http.MethodGet, path: queryPath, query: params}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// QueryRangeRequest is a range query.
+type QueryRangeRequest struct {
+	Query      string
+	Start, End time.Time
+	// Limit is the maximum number of entries returned by a log query.
+	Limit     int
+	Direction logproto.Direction
+	// Step and Interval are the step of a metric query and the interval of the entries of a log query, the defaults of
+	// Loki are used when 0.
+	Step, Interval time.Duration
+}
+
+func (r QueryRangeRequest) params() url.Values {
+	params := url.Values{}
+	params.Set("query", r.Query)
+	params.Set("start", formatTime(r.Start))
+	params.Set("end", formatTime(r.End))
+	params.Set("direction", r.Direction.String())
+	if r.Limit != 0 {
+		params.Set("limit", strconv.Itoa(r.Limit))
+	}
+	if r.Step != 0 {
+		params.Set("step", strconv.FormatFloat(r.Step.Seconds(), 'f', -1, 64))
+	}
+	if r.Interval != 0 {
+		params.Set("interval", strconv.FormatFloat(r.Interval.Seconds(), 'f', -1, 64))
+	}
+	return params
+}
+
+// QueryRange runs a range query.
+func (c *Client) QueryRange(ctx context.Context, req QueryRangeRequest) (*loghttp.QueryResponse, error) {
+	var resp loghttp.QueryResponse
+	if err := c.doJSON(ctx, request{method: http.MethodGet, path: queryRangePath, query: req.params()}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Labels returns the names of the labels of the streams between start and end.
+func (c *Client) Labels(ctx context.Context, start, end time.Time) ([]string, error) {
+	var resp loghttp.LabelResponse
+	if err := c.doJSON(ctx, request{method: http.MethodGet, path: labelsPath, query: timeRange(start, end)}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// LabelValues returns the values of the label of the streams between start and end.
+func (c *Client) LabelValues(ctx context.Context, name string, start, end time.Time) ([]string, error) {
+	var resp loghttp.LabelResponse
+	path := fmt.Sprintf(labelValuesPath, url.PathEscape(name))
+	if err := c.doJSON(ctx, request{method: http.MethodGet, path: path, query: timeRange(start, end)}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// Series returns the label sets of the streams matching any of the selectors between start and end.
+func (c *Client) Series(ctx context.Context, selectors []string, start, end time.Time) ([]loghttp.LabelSet, error) {
+	params := timeRange(start, end)
+	for _, selector := range selectors {
+		params.Add("match[]", selector)
+	}
+
+	var resp loghttp.SeriesResponse
+	if err := c.doJSON(ctx, request{method: http.MethodGet, path: seriesPath, query: params}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// StreamEntry is an entry of a stream.
+type StreamEntry struct {
+	Labels loghttp.LabelSet
+	loghttp.Entry
+}
+
+// Entries returns an iterator over the entries of the log query, paging through its range in batches of batchSize
+// entries, 1000 when 0. The entries are iterated in the direction of the query, up to the limit of the query when set.
+func (c *Client) Entries(ctx context.Context, req QueryRangeRequest, batchSize int) *EntryIterator {
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	return &EntryIterator{
+		ctx:       ctx,
+		client:    c,
+		req:       req,
+		batchSize: batchSize,
+	}
+}
+
+// EntryIterator iterates over the entries of a log query.
+type EntryIterator struct {
+	ctx       context.Context
+	client    *Client
+	req       QueryRangeRequest
+	batchSize int
+
+	batch    []StreamEntry
+	cur      StreamEntry
+	returned int
+	done     bool
+	err      error
+
+	// the entries at the boundary of the last batch, which the next batch returns again.
+	boundary     time.Time
+	boundarySeen map[string]struct{}
+}
+
+// Next advances the iterator to the next entry, it returns false when there are no more entries or on error.
+func (it *EntryIterator) Next() bool {
+	if it.err != nil || (it.req.Limit > 0 && it.returned >= it.req.Limit) {
+		return false
+	}
+	for len(it.batch) == 0 {
+		if it.done {
+			return false
+		}
+		if err := it.nextBatch(); err != nil {
+			it.err = err
+			return false
+		}
+	}
+
+	it.cur, it.batch = it.batch[0], it.batch[1:]
+	it.returned++
+	return true
+}
+
+// At returns the current entry.
+func (it *EntryIterator) At() StreamEntry {
+	return it.cur
+}
+
+// Err returns the error which stopped the iteration.
+func (it *EntryIterator) Err() error {
+	return it.err
+}
+
+func (it *EntryIterator) nextBatch() error {
+	req := it.req
+	req.Limit = it.batchSize
+	resp, err := it.client.QueryRange(it.ctx, req)
+	if err != nil {
+		return err
+	}
+	streams, ok := resp.Data.Result.(loghttp.Streams)
+	if !ok {
+		return fmt.Errorf("the query %s is not a log query, it returned a %s", it.req.Query, resp.Data.ResultType)
+	}
+
+	var entries []StreamEntry
+	for _, stream := range streams {
+		for _, entry := range stream.Entries {
+			entries = append(entries, StreamEntry{Labels: stream.Labels, Entry: entry})
+		}
+	}
+	forward := it.req.Direction == logproto.FORWARD
+	sort.SliceStable(entries, func(i, j int) bool {
+		if forward {
+			return entries[i].Timestamp.Before(entries[j].Timestamp)
+		}
+		return entries[i].Timestamp.After(entries[j].Timestamp)
+	})
+	// the range of the query is exhausted once a batch isn't full.
+	it.done = len(entries) < it.batchSize
+
+	// the entries at the boundary of the previous batch are returned again.
+	batch := make([]StreamEntry, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Timestamp.Equal(it.boundary) {
+			if _, ok := it.boundarySeen[entryKey(entry)]; ok {
+				continue
+			}
+		}
+		batch = append(batch, entry)
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	last := entries[len(entries)-1].Timestamp
+	if !last.Equal(it.boundary) {
+		it.boundary = last
+		it.boundarySeen = map[string]struct{}{}
+	} else if len(batch) == 0 && !it.done {
+		return fmt.Errorf("the batch size %d is too small, more entries have the timestamp %s", it.batchSize, last)
+	}
+	for _, entry := range entries {
+		if entry.Timestamp.Equal(last) {
+			it.boundarySeen[entryKey(entry)] = struct{}{}
+		}
+	}
+
+	// the next batch starts at the boundary, for the entries having its timestamp to not be missed.
+	if forward {
+		it.req.Start = last
+	} else {
+		// the end of a query is exclusive.
+		it.req.End = last.Add(time.Nanosecond)
+	}
+	it.batch = batch
+	return nil
+}
+
+func entryKey(entry StreamEntry) string {
+	return entry.Labels.String() + "\x00" + entry.Line
+}
+
+func timeRange(start, end time.Time) url.Values {
+	params := url.Values{}
+	params.Set("start", formatTime(start))
+	params.Set("end", formatTime(end))
+	return params
+}
+
+func formatTime(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
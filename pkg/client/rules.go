@@ -0,0 +1,85 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"gopkg.in/yaml.v3"
+)
+
+const rulesPath = "/loki/api/v1/rules"
+
+// ListRuleGroups returns the rule groups of the tenant by namespace, of all of them when namespace is empty.
+func (c *Client) ListRuleGroups(ctx context.Context, namespace string) (map[string][]rulefmt.RuleGroup, error) {
+	path := rulesPath
+	if namespace != "" {
+		path += "/" + url.PathEscape(namespace)
+	}
+
+	ruleGroups := map[string][]rulefmt.RuleGroup{}
+	if err := c.doYAML(ctx, request{method: http.MethodGet, path: path}, &ruleGroups); err != nil {
+		var httpErr *Error
+		// the ruler returns a 404 when the tenant has no rule groups.
+		if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotFound {
+			return map[string][]rulefmt.RuleGroup{}, nil
+		}
+		return nil, err
+	}
+	return ruleGroups, nil
+}
+
+// GetRuleGroup returns the rule group of the namespace.
+func (c *Client) GetRuleGroup(ctx context.Context, namespace, group string) (*rulefmt.RuleGroup, error) {
+	var ruleGroup rulefmt.RuleGroup
+	if err := c.doYAML(ctx, request{method: http.MethodGet, path: ruleGroupPath(namespace, group)}, &ruleGroup); err != nil {
+		return nil, err
+	}
+	return &ruleGroup, nil
+}
+
+// SetRuleGroup creates the rule group in the namespace, or replaces the rule group having its name.
+func (c *Client) SetRuleGroup(ctx context.Context, namespace string, ruleGroup rulefmt.RuleGroup) error {
+	payload, err := yaml.Marshal(&ruleGroup)
+	if err != nil {
+		return err
+	}
+
+	return c.doAndClose(ctx, request{
+		method:      http.MethodPost,
+		path:        rulesPath + "/" + url.PathEscape(namespace),
+		body:        payload,
+		contentType: "application/yaml",
+	})
+}
+
+// DeleteRuleGroup deletes the rule group of the namespace.
+func (c *Client) DeleteRuleGroup(ctx context.Context, namespace, group string) error {
+	return c.doAndClose(ctx, request{method: http.MethodDelete, path: ruleGroupPath(namespace, group)})
+}
+
+// DeleteNamespace deletes all the rule groups of the namespace.
+func (c *Client) DeleteNamespace(ctx context.Context, namespace string) error {
+	return c.doAndClose(ctx, request{method: http.MethodDelete, path: rulesPath + "/" + url.PathEscape(namespace)})
+}
+
+func ruleGroupPath(namespace, group string) string {
+	return fmt.Sprintf("%s/%s/%s", rulesPath, url.PathEscape(namespace), url.PathEscape(group))
+}
+
+// doYAML sends the request and decodes the YAML body of its response into out.
+func (c *Client) doYAML(ctx context.Context, r request, out interface{}) error {
+	body, err := c.do(ctx, r)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	if err := yaml.NewDecoder(body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode the response of %s: %w", r.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/grafana/loki/pkg/loghttp"
+	"github.com/grafana/loki/pkg/util/unmarshal"
+)
+
+const tailPath = "/loki/api/v1/tail"
+
+// TailRequest is a tail of a log query.
+type TailRequest struct {
+	Query string
+	// Start is the time from which the entries are tailed, now when zero.
+	Start time.Time
+	// DelayFor delays the entries tailed, for the late ones to not be missed.
+	DelayFor time.Duration
+	// Limit is the maximum number of entries returned at the start of the tail.
+	Limit int
+}
+
+// Tail tails the entries of the log query over a websocket, until the context is canceled or the iterator closed.
+func (c *Client) Tail(ctx context.Context, req TailRequest) (*TailIterator, error) {
+	params := url.Values{}
+	params.Set("query", req.Query)
+	if !req.Start.IsZero() {
+		params.Set("start", formatTime(req.Start))
+	}
+	if req.DelayFor != 0 {
+		params.Set("delay_for", strconv.Itoa(int(req.DelayFor.Seconds())))
+	}
+	if req.Limit != 0 {
+		params.Set("limit", strconv.Itoa(req.Limit))
+	}
+
+	u := c.url(tailPath, params)
+	switch u.Scheme {
+	case "http":
+		u.Scheme = "ws"
+	case "https":
+		u.Scheme = "wss"
+	}
+
+	dialer := websocket.Dialer{Proxy: http.ProxyFromEnvironment}
+	if transport, ok := c.client.Transport.(*http.Transport); ok && transport.TLSClientConfig != nil {
+		dialer.TLSClientConfig = transport.TLSClientConfig.Clone()
+	}
+
+	conn, resp, err := dialer.DialContext(ctx, u.String(), c.header(ctx))
+	if err != nil {
+		if resp == nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return nil, &Error{StatusCode: resp.StatusCode, Message: string(msg)}
+	}
+
+	it := &TailIterator{ctx: ctx, conn: conn, done: make(chan struct{})}
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = it.Close()
+		case <-it.done:
+		}
+	}()
+	return it, nil
+}
+
+// TailIterator iterates over the entries of a tail.
+type TailIterator struct {
+	ctx       context.Context
+	conn      *websocket.Conn
+	done      chan struct{}
+	closeOnce sync.Once
+
+	batch   []StreamEntry
+	cur     StreamEntry
+	dropped []loghttp.DroppedStream
+	err     error
+}
+
+// Next waits for the next entry, it returns false once the tail is closed or on error.
+func (it *TailIterator) Next() bool {
+	for len(it.batch) == 0 {
+		var resp loghttp.TailResponse
+		if err := unmarshal.ReadTailResponseJSON(&resp, it.conn); err != nil {
+			select {
+			case <-it.done:
+				// the tail was closed, by the caller or on the cancellation of the context.
+				it.err = it.ctx.Err()
+			default:
+				if !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+					it.err = fmt.Errorf("failed to read the tail response: %w", err)
+				}
+			}
+			return false
+		}
+
+		for _, stream := range resp.Streams {
+			for _, entry := range stream.Entries {
+				it.batch = append(it.batch, StreamEntry{Labels: stream.Labels, Entry: entry})
+			}
+		}
+		it.dropped = append(it.dropped, resp.DroppedStreams...)
+	}
+
+	it.cur, it.batch = it.batch[0], it.batch[1:]
+	return true
+}
+
+// At returns the current entry.
+func (it *TailIterator) At() StreamEntry {
+	return it.cur
+}
+
+// Dropped returns the streams whose entries were dropped by Loki since the last call, because the client was too slow.
+func (it *TailIterator) Dropped() []loghttp.DroppedStream {
+	dropped := it.dropped
+	it.dropped = nil
+	return dropped
+}
+
+// Err returns the error which stopped the tail.
+func (it *TailIterator) Err() error {
+	return it.err
+}
+
+// Close closes the tail.
+func (it *TailIterator) Close() error {
+	var err error
+	it.closeOnce.Do(func() {
+		close(it.done)
+		_ = it.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+		err = it.conn.Close()
+	})
+	return err
+}
@@ -4,10 +4,13 @@ import (
 	"context"
 	"flag"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/grafana/dskit/kv"
-	"github.com/grafana/dskit/limiter"
 	"github.com/grafana/dskit/ring"
 	ring_client "github.com/grafana/dskit/ring/client"
 	"github.com/grafana/dskit/services"
@@ -23,14 +26,19 @@ import (
 
 	"github.com/grafana/loki/pkg/distributor/clientpool"
 	"github.com/grafana/loki/pkg/ingester/client"
+	"github.com/grafana/loki/pkg/loghttp/push"
 	"github.com/grafana/loki/pkg/logproto"
 	"github.com/grafana/loki/pkg/logql/syntax"
+	"github.com/grafana/loki/pkg/notifications"
 	"github.com/grafana/loki/pkg/runtime"
 	"github.com/grafana/loki/pkg/storage/stores/shipper/compactor/retention"
 	"github.com/grafana/loki/pkg/tenant"
 	"github.com/grafana/loki/pkg/usagestats"
 	"github.com/grafana/loki/pkg/util"
+	"github.com/grafana/loki/pkg/util/flagext"
+	"github.com/grafana/loki/pkg/util/limiter"
 	util_log "github.com/grafana/loki/pkg/util/log"
+	serverutil "github.com/grafana/loki/pkg/util/server"
 	"github.com/grafana/loki/pkg/validation"
 )
 
@@ -48,6 +56,14 @@ type Config struct {
 	// Distributors ring
 	DistributorRing RingConfig `yaml:"ring,omitempty"`
 
+	LabelAdvisor LabelAdvisorConfig `yaml:"label_advisor"`
+
+	PushDebug bool `yaml:"push_debug"`
+
+	MaxDecompressedPushSize flagext.ByteSize `yaml:"max_decompressed_push_size"`
+
+	ForwardCompressedPush bool `yaml:"forward_compressed_push"`
+
 	// For testing.
 	factory ring_client.PoolFactory `yaml:"-"`
 }
@@ -55,6 +71,16 @@ type Config struct {
 // RegisterFlags registers distributor-related flags.
 func (cfg *Config) RegisterFlags(fs *flag.FlagSet) {
 	cfg.DistributorRing.RegisterFlags(fs)
+	cfg.LabelAdvisor.RegisterFlags(fs)
+	fs.BoolVar(&cfg.PushDebug, "distributor.push-debug", true, "Return the timings of the stages of the pushes sent with the X-Loki-Push-Debug header in the Server-Timing response header, including the addresses of the ingesters.")
+	_ = cfg.MaxDecompressedPushSize.Set(strconv.Itoa(push.DefaultMaxDecompressedSize))
+	fs.Var(&cfg.MaxDecompressedPushSize, "distributor.max-decompressed-push-size", "Maximum size of the decompressed push payloads. The larger payloads are rejected.")
+	fs.BoolVar(&cfg.ForwardCompressedPush, "distributor.forward-compressed-push", false, "Forward the zstd-compressed protobuf pushes to the ingesters without encoding and compressing them again, when the validation leaves them untouched and all their streams are sent to the same ingesters. All the ingesters must run a version supporting it.")
+}
+
+// Tee duplicates the streams accepted by the distributor to another destination.
+type Tee interface {
+	Duplicate(ctx context.Context, tenant string, streams []logproto.Stream) error
 }
 
 // Distributor coordinates replicates and distribution of log streams.
@@ -65,9 +91,12 @@ type Distributor struct {
 	clientCfg        client.Config
 	tenantConfigs    *runtime.TenantConfigs
 	tenantsRetention *retention.TenantsRetention
+	limits           Limits
 	ingestersRing    ring.ReadRing
 	validator        *Validator
 	pool             *ring_client.Pool
+	notifier         notifications.Notifier
+	tee              Tee
 
 	// The global rate limiter requires a distributors ring to count
 	// the number of healthy instances.
@@ -83,6 +112,11 @@ type Distributor struct {
 	ingestionRateLimiter *limiter.RateLimiter
 	labelCache           *lru.Cache
 
+	// Tracks the recent streams of the tenants, nil when disabled.
+	labelAdvisor *labelAdvisor
+	// Shards the streams pushed faster than their rate limit.
+	streamSharder *streamSharder
+
 	// metrics
 	ingesterAppends        *prometheus.CounterVec
 	ingesterAppendFailures *prometheus.CounterVec
@@ -90,7 +124,7 @@ type Distributor struct {
 }
 
 // New a distributor creates.
-func New(cfg Config, clientCfg client.Config, configs *runtime.TenantConfigs, ingestersRing ring.ReadRing, overrides *validation.Overrides, registerer prometheus.Registerer) (*Distributor, error) {
+func New(cfg Config, clientCfg client.Config, configs *runtime.TenantConfigs, ingestersRing ring.ReadRing, overrides *validation.Overrides, notifier notifications.Notifier, tee Tee, registerer prometheus.Registerer) (*Distributor, error) {
 	factory := cfg.factory
 	if factory == nil {
 		factory = func(addr string) (ring_client.PoolClient, error) {
@@ -98,6 +132,10 @@ func New(cfg Config, clientCfg client.Config, configs *runtime.TenantConfigs, in
 		}
 	}
 
+	if cfg.LabelAdvisor.Enabled && (cfg.LabelAdvisor.Window <= 0 || cfg.LabelAdvisor.MaxStreamsPerTenant <= 0) {
+		return nil, errors.New("the label advisor window and maximum number of streams per tenant must be positive")
+	}
+
 	validator, err := NewValidator(overrides)
 	if err != nil {
 		return nil, err
@@ -147,13 +185,17 @@ func New(cfg Config, clientCfg client.Config, configs *runtime.TenantConfigs, in
 		clientCfg:              clientCfg,
 		tenantConfigs:          configs,
 		tenantsRetention:       retention.NewTenantsRetention(overrides),
+		limits:                 overrides,
 		ingestersRing:          ingestersRing,
+		notifier:               notifier,
+		tee:                    tee,
 		distributorsRing:       distributorsRing,
 		distributorsLifecycler: distributorsLifecycler,
 		validator:              validator,
 		pool:                   clientpool.NewPool(clientCfg.PoolConfig, ingestersRing, factory, util_log.Logger),
 		ingestionRateLimiter:   limiter.NewRateLimiter(ingestionRateStrategy, 10*time.Second),
 		labelCache:             labelCache,
+		streamSharder:          newStreamSharder(),
 		rateLimitStrat:         rateLimitStrat,
 		ingesterAppends: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
 			Namespace: "loki",
@@ -171,6 +213,9 @@ func New(cfg Config, clientCfg client.Config, configs *runtime.TenantConfigs, in
 			Help:      "The configured replication factor.",
 		}),
 	}
+	if cfg.LabelAdvisor.Enabled {
+		d.labelAdvisor = newLabelAdvisor(cfg.LabelAdvisor)
+	}
 	d.replicationFactor.Set(float64(ingestersRing.ReplicationFactor()))
 	rfStats.Set(int64(ingestersRing.ReplicationFactor()))
 
@@ -191,11 +236,26 @@ func (d *Distributor) starting(ctx context.Context) error {
 }
 
 func (d *Distributor) running(ctx context.Context) error {
-	select {
-	case <-ctx.Done():
-		return nil
-	case err := <-d.subservicesWatcher.Chan():
-		return errors.Wrap(err, "distributor subservice failed")
+	var expireStreams <-chan time.Time
+	if d.labelAdvisor != nil {
+		ticker := time.NewTicker(d.cfg.LabelAdvisor.Window)
+		defer ticker.Stop()
+		expireStreams = ticker.C
+	}
+	updateStreamRates := time.NewTicker(streamRateInterval)
+	defer updateStreamRates.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-d.subservicesWatcher.Chan():
+			return errors.Wrap(err, "distributor subservice failed")
+		case now := <-expireStreams:
+			d.labelAdvisor.expire(now)
+		case <-updateStreamRates.C:
+			d.streamSharder.update()
+		}
 	}
 }
 
@@ -232,27 +292,42 @@ func (d *Distributor) Push(ctx context.Context, req *logproto.PushRequest) (*log
 		return &logproto.PushResponse{}, nil
 	}
 
+	timings := pushTimingsFromContext(ctx)
+	defer timings.observe(stagePush, "", time.Now())
+	start := time.Now()
+
 	// First we flatten out the request into a list of samples.
 	// We use the heuristic of 1 sample per TS to size the array.
 	// We also work out the hash value at the same time.
 	streams := make([]streamTracker, 0, len(req.Streams))
-	keys := make([]uint32, 0, len(req.Streams))
 	validatedSamplesSize := 0
 	validatedSamplesCount := 0
 
 	var validationErr error
 	validationContext := d.validator.getValidationContextForTime(time.Now(), userID)
 
+	// The compressed payload of the request is forwarded as it is to the ingesters, unless the validation
+	// changes the request or its streams aren't all sent to the same ingesters.
+	payload := compressedPayloadFromContext(ctx)
+
 	for _, stream := range req.Streams {
 		// Return early if stream does not contain any entries
 		if len(stream.Entries) == 0 {
 			continue
 		}
 
-		// Truncate first so subsequent steps have consistent line lengths
-		d.truncateLines(validationContext, &stream)
+		// Sanitize and truncate first so subsequent steps have consistent line lengths
+		sanitized := d.sanitizeLines(validationContext, &stream)
+		truncated := d.truncateLines(validationContext, &stream)
+		if sanitized || truncated {
+			payload = nil
+		}
 
+		labels := stream.Labels
 		stream.Labels, err = d.parseStreamLabels(validationContext, stream.Labels, &stream)
+		if err == nil && stream.Labels != labels {
+			payload = nil
+		}
 		if err != nil {
 			validationErr = err
 			validation.DiscardedSamples.WithLabelValues(validation.InvalidLabels, userID).Add(float64(len(stream.Entries)))
@@ -277,26 +352,74 @@ func (d *Distributor) Push(ctx context.Context, req *logproto.PushRequest) (*log
 		}
 		stream.Entries = stream.Entries[:n]
 
-		keys = append(keys, util.TokenFor(userID, stream.Labels))
 		streams = append(streams, streamTracker{stream: stream})
 	}
 
+	timings.observe(stageValidation, "", start)
+
 	// Return early if none of the streams contained entries
 	if len(streams) == 0 {
 		return &logproto.PushResponse{}, validationErr
 	}
 
 	now := time.Now()
-	if !d.ingestionRateLimiter.AllowN(now, userID, validatedSamplesSize) {
+	res := d.ingestionRateLimiter.ReserveN(now, userID, validatedSamplesSize)
+	timings.observe(stageRateLimit, "", now)
+	if !res.OK {
 		// Return a 429 to indicate to the client they are being rate limited
 		validation.DiscardedSamples.WithLabelValues(validation.RateLimited, userID).Add(float64(validatedSamplesCount))
 		validation.DiscardedBytes.WithLabelValues(validation.RateLimited, userID).Add(float64(validatedSamplesSize))
-		return nil, httpgrpc.Errorf(http.StatusTooManyRequests, validation.RateLimitedErrorMsg, userID, int(d.ingestionRateLimiter.Limit(now, userID)), validatedSamplesCount, validatedSamplesSize)
+		d.notifier.Notify(notifications.NewEvent(notifications.IngestionLimitReached, userID, map[string]string{
+			"limit": validation.RateLimited,
+		}))
+		return nil, serverutil.RateLimitedError(res, validation.RateLimitedErrorMsg, userID, int(res.Limit), validatedSamplesCount, validatedSamplesSize)
 	}
 
+	if d.labelAdvisor != nil {
+		streamLabels := make([]string, 0, len(streams))
+		for _, s := range streams {
+			streamLabels = append(streamLabels, s.stream.Labels)
+		}
+		d.labelAdvisor.observe(userID, streamLabels, now)
+	}
+
+	// The streams are duplicated while they are sent to the ingesters.
+	var teeErr chan error
+	if d.tee != nil {
+		teeStreams := make([]logproto.Stream, 0, len(streams))
+		for _, s := range streams {
+			teeStreams = append(teeStreams, s.stream)
+		}
+		teeErr = make(chan error, 1)
+		go func() {
+			start := time.Now()
+			err := d.tee.Duplicate(ctx, userID, teeStreams)
+			timings.observe(stageTee, "", start)
+			teeErr <- err
+		}()
+	}
+
+	start = time.Now()
+	if d.limits.ShardStreams(userID) {
+		streams, err = d.shardStreams(userID, streams)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// dropping the streams without entries or with invalid labels, and sharding streams, change the request.
+	if validationErr != nil || len(streams) != len(req.Streams) {
+		payload = nil
+	}
+	keys := make([]uint32, 0, len(streams))
+	for _, s := range streams {
+		keys = append(keys, util.TokenFor(userID, s.stream.Labels))
+	}
+	timings.observe(stageStreamMapping, "", start)
+
 	const maxExpectedReplicationSet = 5 // typical replication factor 3 plus one for inactive plus one for luck
 	var descs [maxExpectedReplicationSet]ring.InstanceDesc
 
+	start = time.Now()
 	samplesByIngester := map[string][]*streamTracker{}
 	ingesterDescs := map[string]ring.InstanceDesc{}
 	for i, key := range keys {
@@ -312,6 +435,13 @@ func (d *Distributor) Push(ctx context.Context, req *logproto.PushRequest) (*log
 			ingesterDescs[ingester.Addr] = ingester
 		}
 	}
+	timings.observe(stageRingLookup, "", start)
+	for _, samples := range samplesByIngester {
+		if len(samples) != len(streams) {
+			payload = nil
+			break
+		}
+	}
 
 	tracker := pushTracker{
 		done: make(chan struct{}, 1), // buffer avoids blocking if caller terminates - sendSamples() only sends once on each
@@ -327,27 +457,80 @@ func (d *Distributor) Push(ctx context.Context, req *logproto.PushRequest) (*log
 			if sp := opentracing.SpanFromContext(ctx); sp != nil {
 				localCtx = opentracing.ContextWithSpan(localCtx, sp)
 			}
-			d.sendSamples(localCtx, ingester, samples, &tracker)
+			if timings != nil {
+				localCtx = injectPushTimings(localCtx, timings)
+			}
+			d.sendSamples(localCtx, ingester, samples, payload, &tracker)
 		}(ingesterDescs[ingester], samples)
 	}
 	select {
 	case err := <-tracker.err:
 		return nil, err
 	case <-tracker.done:
+		if teeErr != nil {
+			select {
+			case err := <-teeErr:
+				if err != nil {
+					return nil, err
+				}
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
 		return &logproto.PushResponse{}, validationErr
 	case <-ctx.Done():
 		return nil, ctx.Err()
 	}
 }
 
-func (d *Distributor) truncateLines(vContext validationContext, stream *logproto.Stream) {
+// shardStreams shards the streams pushed faster than the per-stream rate limit of the tenant.
+func (d *Distributor) shardStreams(userID string, streams []streamTracker) ([]streamTracker, error) {
+	limit := float64(d.limits.PerStreamRateLimit(userID).Limit)
+	maxShards := d.limits.MaxStreamShards(userID)
+	distributors := 1
+	if d.distributorsLifecycler != nil {
+		if n := d.distributorsLifecycler.HealthyInstancesCount(); n > 0 {
+			distributors = n
+		}
+	}
+
+	sharded := make([]streamTracker, 0, len(streams))
+	for _, s := range streams {
+		bytes := 0
+		for _, e := range s.stream.Entries {
+			bytes += len(e.Line)
+		}
+		shards, next := d.streamSharder.shards(userID, s.stream.Labels, bytes, limit, distributors, maxShards)
+		if shards == 1 {
+			sharded = append(sharded, s)
+			continue
+		}
+		shardStreams, err := shardStream(s.stream, shards, next)
+		if err != nil {
+			return nil, httpgrpc.Errorf(http.StatusBadRequest, validation.InvalidLabelsErrorMsg, s.stream.Labels, err)
+		}
+		for _, stream := range shardStreams {
+			sharded = append(sharded, streamTracker{stream: stream})
+		}
+	}
+	return sharded, nil
+}
+
+// truncateLines truncates the lines longer than the max line size when configured, returning whether it did.
+func (d *Distributor) truncateLines(vContext validationContext, stream *logproto.Stream) bool {
 	if !vContext.maxLineSizeTruncate {
-		return
+		return false
 	}
 
 	var truncatedSamples, truncatedBytes int
 	for i, e := range stream.Entries {
 		if maxSize := vContext.maxLineSize; maxSize != 0 && len(e.Line) > maxSize {
+			// don't split a rune when the lines are meant to be valid UTF-8.
+			if h := vContext.invalidUTF8Handling; h == validation.InvalidUTF8Reject || h == validation.InvalidUTF8Sanitize {
+				for maxSize > 0 && !utf8.RuneStart(e.Line[maxSize]) {
+					maxSize--
+				}
+			}
 			stream.Entries[i].Line = e.Line[:maxSize]
 
 			truncatedSamples++
@@ -357,11 +540,96 @@ func (d *Distributor) truncateLines(vContext validationContext, stream *logproto
 
 	validation.MutatedSamples.WithLabelValues(validation.LineTooLong, vContext.userID).Add(float64(truncatedSamples))
 	validation.MutatedBytes.WithLabelValues(validation.LineTooLong, vContext.userID).Add(float64(truncatedBytes))
+	return truncatedSamples > 0
+}
+
+// sanitizeLines replaces the invalid UTF-8 sequences and strips the control characters of the lines,
+// as configured for the tenant, instead of discarding them. It returns whether it changed any line.
+func (d *Distributor) sanitizeLines(vContext validationContext, stream *logproto.Stream) bool {
+	sanitizeUTF8 := vContext.invalidUTF8Handling == validation.InvalidUTF8Sanitize
+	if !sanitizeUTF8 && !vContext.stripControlCharacters {
+		return false
+	}
+
+	var invalidSamples, invalidBytes, controlSamples, controlBytes int
+	for i := range stream.Entries {
+		if sanitizeUTF8 {
+			if line, n := sanitizeUTF8Line(stream.Entries[i].Line); n > 0 {
+				stream.Entries[i].Line = line
+				invalidSamples++
+				invalidBytes += n
+			}
+		}
+		if vContext.stripControlCharacters {
+			if line, n := stripControlCharacters(stream.Entries[i].Line); n > 0 {
+				stream.Entries[i].Line = line
+				controlSamples++
+				controlBytes += n
+			}
+		}
+	}
+
+	if invalidSamples > 0 {
+		validation.MutatedSamples.WithLabelValues(validation.InvalidUTF8, vContext.userID).Add(float64(invalidSamples))
+		validation.MutatedBytes.WithLabelValues(validation.InvalidUTF8, vContext.userID).Add(float64(invalidBytes))
+	}
+	if controlSamples > 0 {
+		validation.MutatedSamples.WithLabelValues(validation.ControlCharacters, vContext.userID).Add(float64(controlSamples))
+		validation.MutatedBytes.WithLabelValues(validation.ControlCharacters, vContext.userID).Add(float64(controlBytes))
+	}
+	return invalidSamples > 0 || controlSamples > 0
+}
+
+// sanitizeUTF8Line replaces each run of invalid UTF-8 bytes of the line with the Unicode replacement character,
+// returning the number of invalid bytes.
+func sanitizeUTF8Line(line string) (string, int) {
+	if utf8.ValidString(line) {
+		return line, 0
+	}
+	invalid := 0
+	for i := 0; i < len(line); {
+		r, size := utf8.DecodeRuneInString(line[i:])
+		if r == utf8.RuneError && size == 1 {
+			invalid++
+		}
+		i += size
+	}
+	return strings.ToValidUTF8(line, string(utf8.RuneError)), invalid
+}
+
+// stripControlCharacters removes the control characters of the line but the tabs and newlines,
+// returning the number of removed bytes. The invalid UTF-8 bytes are kept as they are.
+func stripControlCharacters(line string) (string, int) {
+	idx := strings.IndexFunc(line, isStrippedControlCharacter)
+	if idx < 0 {
+		return line, 0
+	}
+
+	var b strings.Builder
+	b.Grow(len(line))
+	b.WriteString(line[:idx])
+	stripped := 0
+	for i := idx; i < len(line); {
+		r, size := utf8.DecodeRuneInString(line[i:])
+		if isStrippedControlCharacter(r) {
+			stripped += size
+		} else {
+			b.WriteString(line[i : i+size])
+		}
+		i += size
+	}
+	return b.String(), stripped
+}
+
+func isStrippedControlCharacter(r rune) bool {
+	return r != '\t' && r != '\n' && unicode.IsControl(r)
 }
 
 // TODO taken from Cortex, see if we can refactor out an usable interface.
-func (d *Distributor) sendSamples(ctx context.Context, ingester ring.InstanceDesc, streamTrackers []*streamTracker, pushTracker *pushTracker) {
-	err := d.sendSamplesErr(ctx, ingester, streamTrackers)
+func (d *Distributor) sendSamples(ctx context.Context, ingester ring.InstanceDesc, streamTrackers []*streamTracker, payload []byte, pushTracker *pushTracker) {
+	start := time.Now()
+	err := d.sendSamplesErr(ctx, ingester, streamTrackers, payload)
+	pushTimingsFromContext(ctx).observe(stageIngester, ingester.Addr, start)
 
 	// If we succeed, decrement each sample's pending count by one.  If we reach
 	// the required number of successful puts on this sample, then decrement the
@@ -392,20 +660,25 @@ func (d *Distributor) sendSamples(ctx context.Context, ingester ring.InstanceDes
 }
 
 // TODO taken from Cortex, see if we can refactor out an usable interface.
-func (d *Distributor) sendSamplesErr(ctx context.Context, ingester ring.InstanceDesc, streams []*streamTracker) error {
+// The compressed payload, when not nil, is pushed instead of the streams, which it encodes.
+func (d *Distributor) sendSamplesErr(ctx context.Context, ingester ring.InstanceDesc, streams []*streamTracker, payload []byte) error {
 	c, err := d.pool.GetClientFor(ingester.Addr)
 	if err != nil {
 		return err
 	}
 
-	req := &logproto.PushRequest{
-		Streams: make([]logproto.Stream, len(streams)),
-	}
-	for i, s := range streams {
-		req.Streams[i] = s.stream
-	}
+	if pusher, ok := c.(client.CompressedPusherClient); ok && payload != nil {
+		_, err = pusher.PushCompressed(ctx, payload)
+	} else {
+		req := &logproto.PushRequest{
+			Streams: make([]logproto.Stream, len(streams)),
+		}
+		for i, s := range streams {
+			req.Streams[i] = s.stream
+		}
 
-	_, err = c.(logproto.PusherClient).Push(ctx, req)
+		_, err = c.(logproto.PusherClient).Push(ctx, req)
+	}
 	d.ingesterAppends.WithLabelValues(ingester.Addr).Inc()
 	if err != nil {
 		d.ingesterAppendFailures.WithLabelValues(ingester.Addr).Inc()
@@ -2,12 +2,14 @@ package distributor
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
 	"math/rand"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -29,9 +31,11 @@ import (
 
 	"github.com/grafana/loki/pkg/ingester/client"
 	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/loki/pkg/notifications"
 	"github.com/grafana/loki/pkg/runtime"
 	fe "github.com/grafana/loki/pkg/util/flagext"
 	loki_net "github.com/grafana/loki/pkg/util/net"
+	serverutil "github.com/grafana/loki/pkg/util/server"
 	"github.com/grafana/loki/pkg/util/test"
 	"github.com/grafana/loki/pkg/validation"
 )
@@ -95,7 +99,11 @@ func TestDistributor(t *testing.T) {
 
 			response, err := d.Push(ctx, request)
 			assert.Equal(t, tc.expectedResponse, response)
-			assert.Equal(t, tc.expectedError, err)
+			if tc.expectedError == nil {
+				assert.NoError(t, err)
+			} else {
+				assertHTTPError(t, tc.expectedError, err)
+			}
 		})
 	}
 }
@@ -115,6 +123,84 @@ func Test_SortLabelsOnPush(t *testing.T) {
 	require.Equal(t, `{a="b", buzz="f"}`, ingester.pushed[0].Streams[0].Labels)
 }
 
+type fakeTee struct {
+	tenant  string
+	streams []logproto.Stream
+	err     error
+}
+
+func (f *fakeTee) Duplicate(_ context.Context, tenant string, streams []logproto.Stream) error {
+	f.tenant = tenant
+	f.streams = streams
+	return f.err
+}
+
+func Test_TeeOnPush(t *testing.T) {
+	limits := &validation.Limits{}
+	flagext.DefaultValues(limits)
+	limits.EnforceMetricName = false
+	ingester := &mockIngester{}
+	d := prepare(t, limits, nil, func(addr string) (ring_client.PoolClient, error) { return ingester, nil })
+	defer services.StopAndAwaitTerminated(context.Background(), d) //nolint:errcheck
+	tee := &fakeTee{}
+	d.tee = tee
+
+	request := makeWriteRequest(10, 10)
+	request.Streams[0].Labels = `{buzz="f", a="b"}`
+	_, err := d.Push(ctx, request)
+	require.NoError(t, err)
+	require.Equal(t, "test", tee.tenant)
+	require.Len(t, tee.streams, 1)
+	require.Equal(t, `{a="b", buzz="f"}`, tee.streams[0].Labels)
+	require.Len(t, tee.streams[0].Entries, 10)
+
+	// the failures of the tee fail the push.
+	tee.err = errors.New("tee failed")
+	_, err = d.Push(ctx, makeWriteRequest(10, 10))
+	require.EqualError(t, err, "tee failed")
+}
+
+func Test_ForwardCompressedPush(t *testing.T) {
+	limits := &validation.Limits{}
+	flagext.DefaultValues(limits)
+	limits.EnforceMetricName = false
+	limits.MaxLineSize = 5
+	limits.MaxLineSizeTruncate = true
+	payload := []byte("compressed")
+
+	for _, tc := range []struct {
+		name       string
+		labels     string
+		lineSize   int
+		compressed bool
+	}{
+		{name: "untouched", labels: `{a="b", buzz="f"}`, lineSize: 5, compressed: true},
+		{name: "labels sorted", labels: `{buzz="f", a="b"}`, lineSize: 5},
+		{name: "lines truncated", labels: `{a="b", buzz="f"}`, lineSize: 10},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ingester := &mockIngester{}
+			d := prepare(t, limits, nil, func(addr string) (ring_client.PoolClient, error) { return ingester, nil })
+			defer services.StopAndAwaitTerminated(context.Background(), d) //nolint:errcheck
+
+			request := makeWriteRequest(10, tc.lineSize)
+			request.Streams[0].Labels = tc.labels
+			_, err := d.Push(injectCompressedPayload(ctx, payload), request)
+			require.NoError(t, err)
+			if !tc.compressed {
+				require.Empty(t, ingester.compressedPushes())
+				require.NotEmpty(t, ingester.pushed)
+				return
+			}
+			require.Empty(t, ingester.pushed)
+			require.NotEmpty(t, ingester.compressedPushes())
+			for _, pushed := range ingester.compressedPushes() {
+				require.Equal(t, payload, pushed)
+			}
+		})
+	}
+}
+
 func Test_TruncateLogLines(t *testing.T) {
 	setup := func() (*validation.Limits, *mockIngester) {
 		limits := &validation.Limits{}
@@ -138,6 +224,85 @@ func Test_TruncateLogLines(t *testing.T) {
 	})
 }
 
+func Test_SanitizeLogLines(t *testing.T) {
+	for _, tc := range []struct {
+		name                   string
+		invalidUTF8Handling    string
+		stripControlCharacters bool
+		maxLineSize            int
+		lines                  []string
+		expected               []string
+	}{
+		{
+			name:                "accepted as is",
+			invalidUTF8Handling: validation.InvalidUTF8Accept,
+			lines:               []string{"foo\xffbar", "foo\x00bar"},
+			expected:            []string{"foo\xffbar", "foo\x00bar"},
+		},
+		{
+			name:                "invalid utf8 sanitized",
+			invalidUTF8Handling: validation.InvalidUTF8Sanitize,
+			lines:               []string{"foo\xff\xfebar", "héllo", "foo\x00bar"},
+			expected:            []string{"foo\uFFFDbar", "héllo", "foo\x00bar"},
+		},
+		{
+			name:                "invalid utf8 rejected",
+			invalidUTF8Handling: validation.InvalidUTF8Reject,
+			lines:               []string{"foo\xffbar", "héllo"},
+			expected:            []string{"héllo"},
+		},
+		{
+			name:                   "control characters stripped",
+			invalidUTF8Handling:    validation.InvalidUTF8Accept,
+			stripControlCharacters: true,
+			lines:                  []string{"foo\x00\x1b[31mbar\u0085", "foo\tbar\nbaz", "foo\xffbar"},
+			expected:               []string{"foo[31mbar", "foo\tbar\nbaz", "foo\xffbar"},
+		},
+		{
+			name:                   "invalid utf8 sanitized and control characters stripped",
+			invalidUTF8Handling:    validation.InvalidUTF8Sanitize,
+			stripControlCharacters: true,
+			lines:                  []string{"\x00foo\xffbar"},
+			expected:               []string{"foo\uFFFDbar"},
+		},
+		{
+			name:                "truncated lines don't split runes",
+			invalidUTF8Handling: validation.InvalidUTF8Sanitize,
+			maxLineSize:         5,
+			lines:               []string{"fooé€", "foo\xffbar"},
+			expected:            []string{"fooé", "foo"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			limits := &validation.Limits{}
+			flagext.DefaultValues(limits)
+			limits.EnforceMetricName = false
+			limits.InvalidUTF8Handling = tc.invalidUTF8Handling
+			limits.StripControlChars = tc.stripControlCharacters
+			if tc.maxLineSize > 0 {
+				limits.MaxLineSize = fe.ByteSize(tc.maxLineSize)
+				limits.MaxLineSizeTruncate = true
+			}
+			ingester := &mockIngester{}
+
+			d := prepare(t, limits, nil, func(addr string) (ring_client.PoolClient, error) { return ingester, nil })
+			defer services.StopAndAwaitTerminated(context.Background(), d) //nolint:errcheck
+
+			req := makeWriteRequest(len(tc.lines), 10)
+			for i, line := range tc.lines {
+				req.Streams[0].Entries[i].Line = line
+			}
+			_, _ = d.Push(ctx, req)
+
+			var lines []string
+			for _, e := range ingester.pushed[0].Streams[0].Entries {
+				lines = append(lines, e.Line)
+			}
+			require.Equal(t, tc.expected, lines)
+		})
+	}
+}
+
 func Benchmark_SortLabelsOnPush(b *testing.B) {
 	limits := &validation.Limits{}
 	flagext.DefaultValues(limits)
@@ -301,13 +466,35 @@ func TestDistributor_PushIngestionRateLimiter(t *testing.T) {
 					assert.Nil(t, err)
 				} else {
 					assert.Nil(t, response)
-					assert.Equal(t, push.expectedError, err)
+					resp := assertHTTPError(t, push.expectedError, err)
+					// the missing bytes are available in less than a second.
+					assert.Equal(t, "1", httpHeader(resp, serverutil.RetryAfterHeader))
 				}
 			}
 		})
 	}
 }
 
+// assertHTTPError asserts the status code and the body of an HTTP error, and returns its response.
+func assertHTTPError(t *testing.T, expected, actual error) *httpgrpc.HTTPResponse {
+	expectedResp, ok := httpgrpc.HTTPResponseFromError(expected)
+	require.True(t, ok)
+	actualResp, ok := httpgrpc.HTTPResponseFromError(actual)
+	require.True(t, ok, "not an HTTP error: %v", actual)
+	assert.Equal(t, expectedResp.Code, actualResp.Code)
+	assert.Equal(t, string(expectedResp.Body), string(actualResp.Body))
+	return actualResp
+}
+
+func httpHeader(resp *httpgrpc.HTTPResponse, key string) string {
+	for _, h := range resp.Headers {
+		if h.Key == key && len(h.Values) > 0 {
+			return h.Values[0]
+		}
+	}
+	return ""
+}
+
 func prepare(t *testing.T, limits *validation.Limits, kvStore kv.Client, factory func(addr string) (ring_client.PoolClient, error)) *Distributor {
 	var (
 		distributorConfig Config
@@ -348,7 +535,7 @@ func prepare(t *testing.T, limits *validation.Limits, kvStore kv.Client, factory
 		}
 	}
 
-	d, err := New(distributorConfig, clientConfig, runtime.DefaultTenantConfigs(), ingestersRing, overrides, nil)
+	d, err := New(distributorConfig, clientConfig, runtime.DefaultTenantConfigs(), ingestersRing, overrides, notifications.Noop, nil, nil)
 	require.NoError(t, err)
 	require.NoError(t, services.StartAndAwaitRunning(context.Background(), d))
 
@@ -382,6 +569,9 @@ type mockIngester struct {
 	logproto.PusherClient
 
 	pushed []*logproto.PushRequest
+
+	mtx        sync.Mutex
+	compressed [][]byte
 }
 
 func (i *mockIngester) Push(ctx context.Context, in *logproto.PushRequest, opts ...grpc.CallOption) (*logproto.PushResponse, error) {
@@ -389,6 +579,19 @@ func (i *mockIngester) Push(ctx context.Context, in *logproto.PushRequest, opts
 	return nil, nil
 }
 
+func (i *mockIngester) PushCompressed(ctx context.Context, payload []byte, opts ...grpc.CallOption) (*logproto.PushResponse, error) {
+	i.mtx.Lock()
+	defer i.mtx.Unlock()
+	i.compressed = append(i.compressed, payload)
+	return nil, nil
+}
+
+func (i *mockIngester) compressedPushes() [][]byte {
+	i.mtx.Lock()
+	defer i.mtx.Unlock()
+	return i.compressed
+}
+
 func (i *mockIngester) Close() error {
 	return nil
 }
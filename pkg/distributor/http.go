@@ -1,8 +1,11 @@
 package distributor
 
 import (
+	"context"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-kit/log/level"
 	"github.com/weaveworks/common/httpgrpc"
@@ -16,12 +19,39 @@ import (
 	"github.com/grafana/loki/pkg/validation"
 )
 
+type compressedPayloadKey struct{}
+
+// injectCompressedPayload attaches the zstd-compressed protobuf encoding of the pushed request to the context.
+func injectCompressedPayload(ctx context.Context, payload []byte) context.Context {
+	return context.WithValue(ctx, compressedPayloadKey{}, payload)
+}
+
+// compressedPayloadFromContext returns the compressed payload of the pushed request, nil when there isn't any.
+func compressedPayloadFromContext(ctx context.Context) []byte {
+	payload, _ := ctx.Value(compressedPayloadKey{}).([]byte)
+	return payload
+}
+
 // PushHandler reads a snappy-compressed proto from the HTTP body.
 func (d *Distributor) PushHandler(w http.ResponseWriter, r *http.Request) {
 	logger := util_log.WithContext(r.Context(), util_log.Logger)
 	userID, _ := tenant.TenantID(r.Context())
-	req, err := push.ParseRequest(logger, userID, r, d.tenantsRetention)
+
+	ctx := r.Context()
+	var timings *pushTimings
+	if debug, _ := strconv.ParseBool(r.Header.Get(PushDebugHeader)); debug && d.cfg.PushDebug {
+		timings = &pushTimings{}
+		ctx = injectPushTimings(ctx, timings)
+	}
+
+	start := time.Now()
+	req, payload, err := push.ParseRequestWithPayload(logger, userID, r, d.tenantsRetention, d.cfg.MaxDecompressedPushSize.Val())
+	timings.observe(stageParse, "", start)
+	if d.cfg.ForwardCompressedPush && payload != nil {
+		ctx = injectCompressedPayload(ctx, payload)
+	}
 	if err != nil {
+		timings.setHeader(w)
 		if d.tenantConfigs.LogPushRequest(userID) {
 			level.Debug(logger).Log(
 				"msg", "push request failed",
@@ -44,19 +74,26 @@ func (d *Distributor) PushHandler(w http.ResponseWriter, r *http.Request) {
 		)
 	}
 
-	_, err = d.Push(r.Context(), req)
+	_, err = d.Push(ctx, req)
+	// the timings are returned whether the push succeeds or not.
+	timings.setHeader(w)
 	if err == nil {
 		if d.tenantConfigs.LogPushRequest(userID) {
 			level.Debug(logger).Log(
 				"msg", "push request successful",
 			)
 		}
+		// Tell the clients what's left of their ingestion rate so that they can self-throttle.
+		for k, v := range serverutil.RateLimitHeaders(d.ingestionRateLimiter.Usage(time.Now(), userID)) {
+			w.Header()[k] = v
+		}
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 
 	resp, ok := httpgrpc.HTTPResponseFromError(err)
 	if ok {
+		serverutil.CopyHeaders(w, resp.Headers)
 		body := string(resp.Body)
 		if d.tenantConfigs.LogPushRequest(userID) {
 			level.Debug(logger).Log(
@@ -102,3 +139,18 @@ func (d *Distributor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			</html>`
 	util.WriteHTMLResponse(w, noRingPage)
 }
+
+// LabelSuggestionsHandler suggests improvements of the labels of the streams
+// recently pushed by the tenant to this distributor.
+func (d *Distributor) LabelSuggestionsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := tenant.TenantID(r.Context())
+	if err != nil {
+		serverutil.JSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if d.labelAdvisor == nil {
+		serverutil.JSONError(w, http.StatusNotFound, "the label advisor is disabled")
+		return
+	}
+	util.WriteJSONResponse(w, d.labelAdvisor.suggest(userID, time.Now()))
+}
@@ -2,14 +2,20 @@ package distributor
 
 import (
 	"context"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/grafana/dskit/flagext"
+	ring_client "github.com/grafana/dskit/ring/client"
 	"github.com/grafana/dskit/services"
 	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
 
 	"github.com/grafana/loki/pkg/validation"
 )
@@ -57,3 +63,48 @@ func TestDistributorRingHandler(t *testing.T) {
 		require.NotContains(t, string(body), "<th>Instance ID</th>")
 	})
 }
+
+func TestPushHandler_Timings(t *testing.T) {
+	limits := &validation.Limits{}
+	flagext.DefaultValues(limits)
+	ingester := &streamsIngester{streams: map[string]int{}}
+	d := prepare(t, limits, nil, func(addr string) (ring_client.PoolClient, error) { return ingester, nil })
+	defer services.StopAndAwaitTerminated(context.Background(), d) //nolint:errcheck
+
+	push := func(debug string) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"streams":[{"stream":{"foo":"bar"},"values":[["%d","line"]]}]}`, time.Now().UnixNano())
+		req := httptest.NewRequest(http.MethodPost, "/loki/api/v1/push", strings.NewReader(body))
+		req = req.WithContext(user.InjectOrgID(req.Context(), "test"))
+		req.Header.Set("Content-Type", "application/json")
+		if debug != "" {
+			req.Header.Set(PushDebugHeader, debug)
+		}
+		w := httptest.NewRecorder()
+		d.PushHandler(w, req)
+		return w
+	}
+
+	w := push("")
+	require.Equal(t, http.StatusNoContent, w.Code)
+	require.Empty(t, w.Header().Get(ServerTimingHeader))
+
+	w = push("true")
+	require.Equal(t, http.StatusNoContent, w.Code)
+	timings := w.Header().Get(ServerTimingHeader)
+	for _, stage := range []string{
+		`parse;dur=[0-9.]+`,
+		`validation;dur=[0-9.]+`,
+		`rate_limit;dur=[0-9.]+`,
+		`stream_mapping;dur=[0-9.]+`,
+		`ring_lookup;dur=[0-9.]+`,
+		`ingester;desc="ingester[0-9]";dur=[0-9.]+`,
+		`push;dur=[0-9.]+`,
+	} {
+		require.Regexp(t, regexp.MustCompile(stage), timings)
+	}
+
+	d.cfg.PushDebug = false
+	w = push("true")
+	require.Equal(t, http.StatusNoContent, w.Code)
+	require.Empty(t, w.Header().Get(ServerTimingHeader))
+}
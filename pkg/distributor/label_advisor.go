@@ -0,0 +1,272 @@
+package distributor
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/grafana/loki/pkg/logql/syntax"
+)
+
+const (
+	// Suggestion types returned by the label advisor.
+	SuggestionStructuredMetadata     = "structured_metadata"
+	SuggestionUnboundedValues        = "unbounded_values"
+	SuggestionNearDuplicateLabels    = "near_duplicate_labels"
+	SuggestionNearDuplicateLabelsets = "near_duplicate_labelsets"
+
+	// minimum number of distinct values before a label is reported for its values.
+	minSuggestedValues = 10
+	// ratio of distinct values to streams above which the values of a label look unbounded.
+	unboundedValuesRatio = 0.5
+	// ratio of the values of a label that must look like identifiers.
+	identifierValuesRatio = 0.8
+	// maximum number of groups of near duplicate labelsets reported.
+	maxNearDuplicateLabelsets = 100
+)
+
+var (
+	identifierLabelName = regexp.MustCompile(`(?i)(^|_)(trace|span|request|session|correlation|transaction|user|customer|client|order)_?id$`)
+	identifierValue     = regexp.MustCompile(`^(?i:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}|[0-9a-f]{16,}|[0-9]+)$`)
+	labelNameSeparators = strings.NewReplacer("_", "", "-", "", ".", "")
+)
+
+// LabelAdvisorConfig configures the tracking of the recent streams of the tenants,
+// analyzed to suggest improvements of their labels.
+type LabelAdvisorConfig struct {
+	Enabled             bool          `yaml:"enabled"`
+	MaxStreamsPerTenant int           `yaml:"max_streams_per_tenant"`
+	Window              time.Duration `yaml:"window"`
+}
+
+// RegisterFlags registers the label advisor flags.
+func (cfg *LabelAdvisorConfig) RegisterFlags(f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, "distributor.label-advisor.enabled", false, "Track the recent streams of the tenants to suggest improvements of their labels at /distributor/label_suggestions.")
+	f.IntVar(&cfg.MaxStreamsPerTenant, "distributor.label-advisor.max-streams-per-tenant", 10000, "Maximum number of streams tracked per tenant.")
+	f.DurationVar(&cfg.Window, "distributor.label-advisor.window", time.Hour, "Streams not pushed for this long are no longer tracked.")
+}
+
+// LabelSuggestions are the suggestions made for the recent streams of a tenant.
+type LabelSuggestions struct {
+	Streams     int               `json:"streams"`
+	Suggestions []LabelSuggestion `json:"suggestions"`
+}
+
+// LabelSuggestion is a suggested improvement of the labels of a tenant.
+type LabelSuggestion struct {
+	Type      string   `json:"type"`
+	Labels    []string `json:"labels,omitempty"`
+	Labelsets []string `json:"labelsets,omitempty"`
+	Values    int      `json:"values,omitempty"`
+	Streams   int      `json:"streams,omitempty"`
+	Message   string   `json:"message"`
+}
+
+// labelAdvisor tracks the labels of the streams recently pushed by the tenants.
+type labelAdvisor struct {
+	cfg LabelAdvisorConfig
+
+	mtx     sync.Mutex
+	tenants map[string]map[string]time.Time // stream labels by tenant, with the time they were last pushed.
+}
+
+func newLabelAdvisor(cfg LabelAdvisorConfig) *labelAdvisor {
+	return &labelAdvisor{
+		cfg:     cfg,
+		tenants: map[string]map[string]time.Time{},
+	}
+}
+
+// observe records the labels of the streams pushed by a tenant.
+func (a *labelAdvisor) observe(userID string, streams []string, now time.Time) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	tracked, ok := a.tenants[userID]
+	if !ok {
+		tracked = map[string]time.Time{}
+		a.tenants[userID] = tracked
+	}
+	for _, stream := range streams {
+		if _, ok := tracked[stream]; !ok && len(tracked) >= a.cfg.MaxStreamsPerTenant {
+			a.expireStreams(tracked, now)
+			if len(tracked) >= a.cfg.MaxStreamsPerTenant {
+				continue
+			}
+		}
+		tracked[stream] = now
+	}
+}
+
+// expire stops tracking the streams not pushed within the window.
+func (a *labelAdvisor) expire(now time.Time) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	for userID, tracked := range a.tenants {
+		a.expireStreams(tracked, now)
+		if len(tracked) == 0 {
+			delete(a.tenants, userID)
+		}
+	}
+}
+
+func (a *labelAdvisor) expireStreams(tracked map[string]time.Time, now time.Time) {
+	for stream, lastPushed := range tracked {
+		if now.Sub(lastPushed) > a.cfg.Window {
+			delete(tracked, stream)
+		}
+	}
+}
+
+// suggest analyzes the recent streams of a tenant.
+func (a *labelAdvisor) suggest(userID string, now time.Time) LabelSuggestions {
+	a.mtx.Lock()
+	var streams []labels.Labels
+	if tracked, ok := a.tenants[userID]; ok {
+		a.expireStreams(tracked, now)
+		streams = make([]labels.Labels, 0, len(tracked))
+		for stream := range tracked {
+			ls, err := syntax.ParseLabels(stream)
+			if err != nil {
+				continue
+			}
+			streams = append(streams, ls)
+		}
+	}
+	a.mtx.Unlock()
+
+	return suggestLabels(streams)
+}
+
+type labelStats struct {
+	streams     int
+	values      map[string]struct{}
+	identifiers int // number of values looking like identifiers.
+}
+
+func suggestLabels(streams []labels.Labels) LabelSuggestions {
+	result := LabelSuggestions{
+		Streams:     len(streams),
+		Suggestions: []LabelSuggestion{},
+	}
+
+	stats := map[string]*labelStats{}
+	for _, ls := range streams {
+		for _, l := range ls {
+			s, ok := stats[l.Name]
+			if !ok {
+				s = &labelStats{values: map[string]struct{}{}}
+				stats[l.Name] = s
+			}
+			s.streams++
+			if _, ok := s.values[l.Value]; ok {
+				continue
+			}
+			s.values[l.Value] = struct{}{}
+			if isIdentifier(l.Value) {
+				s.identifiers++
+			}
+		}
+	}
+
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		s := stats[name]
+		values := len(s.values)
+		switch {
+		case values > 1 && (identifierLabelName.MatchString(name) ||
+			values >= minSuggestedValues && float64(s.identifiers) >= identifierValuesRatio*float64(values)):
+			result.Suggestions = append(result.Suggestions, LabelSuggestion{
+				Type:    SuggestionStructuredMetadata,
+				Labels:  []string{name},
+				Values:  values,
+				Streams: s.streams,
+				Message: fmt.Sprintf("label %s holds identifiers and creates a stream per value: keep them in the log line and extract them at query time", name),
+			})
+		case values >= minSuggestedValues && float64(values) >= unboundedValuesRatio*float64(s.streams):
+			result.Suggestions = append(result.Suggestions, LabelSuggestion{
+				Type:    SuggestionUnboundedValues,
+				Labels:  []string{name},
+				Values:  values,
+				Streams: s.streams,
+				Message: fmt.Sprintf("label %s has %d values for %d streams, its values look unbounded", name, values, s.streams),
+			})
+		}
+	}
+
+	// labels whose names only differ by their case or separators.
+	byNormalizedName := map[string][]string{}
+	for _, name := range names {
+		normalized := normalizeLabelName(name)
+		byNormalizedName[normalized] = append(byNormalizedName[normalized], name)
+	}
+	for _, name := range names {
+		similar := byNormalizedName[normalizeLabelName(name)]
+		if len(similar) < 2 || similar[0] != name {
+			continue
+		}
+		result.Suggestions = append(result.Suggestions, LabelSuggestion{
+			Type:    SuggestionNearDuplicateLabels,
+			Labels:  similar,
+			Message: fmt.Sprintf("labels %s look like the same label: use a single name", strings.Join(similar, ", ")),
+		})
+	}
+
+	// labelsets which only differ by the case or the separators of their names and values.
+	byNormalizedLabelset := map[string][]string{}
+	for _, ls := range streams {
+		normalized := make(labels.Labels, 0, len(ls))
+		for _, l := range ls {
+			normalized = append(normalized, labels.Label{
+				Name:  normalizeLabelName(l.Name),
+				Value: strings.ToLower(strings.TrimSpace(l.Value)),
+			})
+		}
+		sort.Sort(normalized)
+		key := normalized.String()
+		byNormalizedLabelset[key] = append(byNormalizedLabelset[key], ls.String())
+	}
+	var labelsets [][]string
+	for _, similar := range byNormalizedLabelset {
+		if len(similar) < 2 {
+			continue
+		}
+		sort.Strings(similar)
+		labelsets = append(labelsets, similar)
+	}
+	sort.Slice(labelsets, func(i, j int) bool { return labelsets[i][0] < labelsets[j][0] })
+	if len(labelsets) > maxNearDuplicateLabelsets {
+		labelsets = labelsets[:maxNearDuplicateLabelsets]
+	}
+	for _, similar := range labelsets {
+		result.Suggestions = append(result.Suggestions, LabelSuggestion{
+			Type:      SuggestionNearDuplicateLabelsets,
+			Labelsets: similar,
+			Streams:   len(similar),
+			Message:   fmt.Sprintf("%d streams only differ by the case or the separators of their labels: they are likely the same stream", len(similar)),
+		})
+	}
+
+	return result
+}
+
+func isIdentifier(value string) bool {
+	return identifierValue.MatchString(value) || net.ParseIP(value) != nil
+}
+
+func normalizeLabelName(name string) string {
+	return strings.ToLower(labelNameSeparators.Replace(name))
+}
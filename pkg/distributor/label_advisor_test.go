@@ -0,0 +1,92 @@
+package distributor
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLabelAdvisor_Observe(t *testing.T) {
+	a := newLabelAdvisor(LabelAdvisorConfig{Enabled: true, MaxStreamsPerTenant: 2, Window: time.Hour})
+	now := time.Unix(0, 0)
+
+	a.observe("1", []string{`{app="a"}`, `{app="b"}`, `{app="c"}`}, now)
+	a.observe("2", []string{`{app="a"}`}, now)
+	require.Len(t, a.tenants["1"], 2)
+	require.Len(t, a.tenants["2"], 1)
+
+	// the streams already tracked are refreshed when the tenant is at its limit.
+	a.observe("1", []string{`{app="a"}`}, now.Add(time.Hour))
+	require.Equal(t, now.Add(time.Hour), a.tenants["1"][`{app="a"}`])
+
+	// expired streams make room for new ones.
+	a.observe("1", []string{`{app="c"}`}, now.Add(90*time.Minute))
+	require.Len(t, a.tenants["1"], 2)
+	require.Contains(t, a.tenants["1"], `{app="c"}`)
+
+	a.expire(now.Add(2 * time.Hour))
+	require.Len(t, a.tenants["1"], 2)
+	require.NotContains(t, a.tenants, "2")
+
+	require.Equal(t, 2, a.suggest("1", now.Add(2*time.Hour)).Streams)
+	require.Equal(t, 1, a.suggest("1", now.Add(150*time.Minute)).Streams)
+	require.Equal(t, 0, a.suggest("unknown", now).Streams)
+}
+
+func TestLabelAdvisor_Suggest(t *testing.T) {
+	a := newLabelAdvisor(LabelAdvisorConfig{Enabled: true, MaxStreamsPerTenant: 1000, Window: time.Hour})
+	now := time.Now()
+
+	var streams []string
+	for i := 0; i < 20; i++ {
+		streams = append(streams,
+			fmt.Sprintf(`{app="api", trace_id="%016x"}`, i),
+			fmt.Sprintf(`{app="web", path="/users/%c%d"}`, 'a'+i%3, i),
+			fmt.Sprintf(`{app="db", pod="db-%d", host="10.0.0.%d"}`, i%2, i),
+		)
+	}
+	streams = append(streams,
+		`{app="proxy", env="prod", serviceName="proxy"}`,
+		`{app="Proxy", env="prod ", service_name="proxy"}`,
+	)
+	a.observe("1", streams, now)
+
+	suggestions := a.suggest("1", now)
+	require.Equal(t, len(streams), suggestions.Streams)
+	require.Equal(t, []LabelSuggestion{
+		{
+			Type:    SuggestionStructuredMetadata,
+			Labels:  []string{"host"},
+			Values:  20,
+			Streams: 20,
+			Message: "label host holds identifiers and creates a stream per value: keep them in the log line and extract them at query time",
+		},
+		{
+			Type:    SuggestionUnboundedValues,
+			Labels:  []string{"path"},
+			Values:  20,
+			Streams: 20,
+			Message: "label path has 20 values for 20 streams, its values look unbounded",
+		},
+		{
+			Type:    SuggestionStructuredMetadata,
+			Labels:  []string{"trace_id"},
+			Values:  20,
+			Streams: 20,
+			Message: "label trace_id holds identifiers and creates a stream per value: keep them in the log line and extract them at query time",
+		},
+		{
+			Type:    SuggestionNearDuplicateLabels,
+			Labels:  []string{"serviceName", "service_name"},
+			Message: "labels serviceName, service_name look like the same label: use a single name",
+		},
+		{
+			Type:      SuggestionNearDuplicateLabelsets,
+			Labelsets: []string{`{app="Proxy", env="prod ", service_name="proxy"}`, `{app="proxy", env="prod", serviceName="proxy"}`},
+			Streams:   2,
+			Message:   "2 streams only differ by the case or the separators of their labels: they are likely the same stream",
+		},
+	}, suggestions.Suggestions)
+}
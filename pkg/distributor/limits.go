@@ -1,11 +1,17 @@
 package distributor
 
-import "time"
+import (
+	"time"
+
+	"github.com/grafana/loki/pkg/validation"
+)
 
 // Limits is an interface for distributor limits/related configs
 type Limits interface {
 	MaxLineSize(userID string) int
 	MaxLineSizeTruncate(userID string) bool
+	InvalidUTF8Handling(userID string) string
+	StripControlCharacters(userID string) bool
 	EnforceMetricName(userID string) bool
 	MaxLabelNamesPerSeries(userID string) int
 	MaxLabelNameLength(userID string) int
@@ -14,4 +20,8 @@ type Limits interface {
 	CreationGracePeriod(userID string) time.Duration
 	RejectOldSamples(userID string) bool
 	RejectOldSamplesMaxAge(userID string) time.Duration
+
+	ShardStreams(userID string) bool
+	MaxStreamShards(userID string) int
+	PerStreamRateLimit(userID string) validation.RateLimit
 }
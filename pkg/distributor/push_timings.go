@@ -0,0 +1,86 @@
+package distributor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// PushDebugHeader requests the timings of the stages of a push, returned in the ServerTimingHeader.
+	PushDebugHeader = "X-Loki-Push-Debug"
+	// ServerTimingHeader holds the timings of the stages of a push, in the format of the Server-Timing header.
+	ServerTimingHeader = "Server-Timing"
+
+	// Stages of a push.
+	stageParse         = "parse"
+	stageValidation    = "validation"
+	stageRateLimit     = "rate_limit"
+	stageStreamMapping = "stream_mapping"
+	stageRingLookup    = "ring_lookup"
+	stageIngester      = "ingester"
+	stageTee           = "tee"
+	stagePush          = "push"
+)
+
+type pushTimingsKey struct{}
+
+// pushTimings records the durations of the stages of a push. The ingester stage is recorded once per replica, as the
+// pushes to the ingesters complete.
+type pushTimings struct {
+	mtx    sync.Mutex
+	stages []pushStage
+}
+
+type pushStage struct {
+	name     string
+	desc     string
+	duration time.Duration
+}
+
+func injectPushTimings(ctx context.Context, timings *pushTimings) context.Context {
+	return context.WithValue(ctx, pushTimingsKey{}, timings)
+}
+
+// pushTimingsFromContext returns the timings of the push, nil when they weren't requested.
+func pushTimingsFromContext(ctx context.Context) *pushTimings {
+	timings, _ := ctx.Value(pushTimingsKey{}).(*pushTimings)
+	return timings
+}
+
+// observe records the duration of a stage started at the given time. It is a noop on nil timings.
+func (t *pushTimings) observe(name, desc string, start time.Time) {
+	if t == nil {
+		return
+	}
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	t.stages = append(t.stages, pushStage{name: name, desc: desc, duration: time.Since(start)})
+}
+
+// header returns the timings in the format of the Server-Timing header, in milliseconds.
+func (t *pushTimings) header() string {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	metrics := make([]string, 0, len(t.stages))
+	for _, s := range t.stages {
+		metric := s.name
+		if s.desc != "" {
+			metric += fmt.Sprintf(";desc=%q", s.desc)
+		}
+		metrics = append(metrics, fmt.Sprintf("%s;dur=%.3f", metric, float64(s.duration)/float64(time.Millisecond)))
+	}
+	return strings.Join(metrics, ", ")
+}
+
+// setHeader sets the timings header of the response. It is a noop on nil timings.
+func (t *pushTimings) setHeader(w http.ResponseWriter) {
+	if t == nil {
+		return
+	}
+	w.Header().Set(ServerTimingHeader, t.header())
+}
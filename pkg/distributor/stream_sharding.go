@@ -0,0 +1,120 @@
+package distributor
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/loki/pkg/logql/syntax"
+)
+
+// streamRateInterval is the interval over which the rate of the streams is measured.
+const streamRateInterval = 10 * time.Second
+
+// streamSharder measures the rate of the streams pushed to the distributor, and shards the streams pushed faster than
+// their rate limit across as many streams as needed to stay under it, adding the stream shard label.
+type streamSharder struct {
+	mtx     sync.Mutex
+	tenants map[string]map[string]*streamRate // rate of the streams by labels, by tenant.
+}
+
+// streamRate is the rate of a stream.
+type streamRate struct {
+	// bytes pushed since the start of the current interval.
+	bytes int
+	// rate in bytes per second over the last interval.
+	rate float64
+	// shard the entries of the next push start with, rotated for the pushes of a few entries to spread over the shards.
+	next int
+}
+
+func newStreamSharder() *streamSharder {
+	return &streamSharder{
+		tenants: map[string]map[string]*streamRate{},
+	}
+}
+
+// shards records the bytes pushed to a stream, and returns the number of shards needed for the stream to stay under
+// the rate limit, and the shard the entries start with. The rate of the stream is the one measured by the distributor
+// times the number of distributors the pushes are balanced across.
+func (s *streamSharder) shards(userID, stream string, bytes int, limit float64, distributors, maxShards int) (int, int) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	tracked, ok := s.tenants[userID]
+	if !ok {
+		tracked = map[string]*streamRate{}
+		s.tenants[userID] = tracked
+	}
+	r, ok := tracked[stream]
+	if !ok {
+		r = &streamRate{}
+		tracked[stream] = r
+	}
+	r.bytes += bytes
+
+	if limit <= 0 {
+		return 1, 0
+	}
+	// the bytes pushed so far in the interval bound the rate, for the bursts to be sharded without waiting for the
+	// interval to end.
+	rate := math.Max(r.rate, float64(r.bytes)/streamRateInterval.Seconds())
+	shards := int(math.Ceil(rate * float64(distributors) / limit))
+	if shards > maxShards {
+		shards = maxShards
+	}
+	if shards <= 1 {
+		return 1, 0
+	}
+	next := r.next % shards
+	r.next = next + 1
+	return shards, next
+}
+
+// update computes the rate of the streams over the interval ended, and stops tracking the streams not pushed.
+func (s *streamSharder) update() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for userID, tracked := range s.tenants {
+		for stream, r := range tracked {
+			if r.bytes == 0 {
+				delete(tracked, stream)
+				continue
+			}
+			r.rate = float64(r.bytes) / streamRateInterval.Seconds()
+			r.bytes = 0
+		}
+		if len(tracked) == 0 {
+			delete(s.tenants, userID)
+		}
+	}
+}
+
+// shardStream splits the entries of the stream in contiguous parts, one per shard starting with the given one, with
+// the shard label added to the labels of the stream. The shards without entries are omitted.
+func shardStream(stream logproto.Stream, shards, next int) ([]logproto.Stream, error) {
+	ls, err := syntax.ParseLabels(stream.Labels)
+	if err != nil {
+		return nil, err
+	}
+	builder := labels.NewBuilder(ls)
+
+	sharded := make([]logproto.Stream, 0, shards)
+	for i := 0; i < shards; i++ {
+		entries := stream.Entries[i*len(stream.Entries)/shards : (i+1)*len(stream.Entries)/shards]
+		if len(entries) == 0 {
+			continue
+		}
+		builder.Set(logproto.StreamShardLabel, strconv.Itoa((next+i)%shards))
+		sharded = append(sharded, logproto.Stream{
+			Labels:  builder.Labels().String(),
+			Entries: entries,
+		})
+	}
+	return sharded, nil
+}
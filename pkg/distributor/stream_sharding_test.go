@@ -0,0 +1,112 @@
+package distributor
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafana/dskit/flagext"
+	ring_client "github.com/grafana/dskit/ring/client"
+	"github.com/grafana/dskit/services"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/loki/pkg/validation"
+)
+
+func TestStreamSharder(t *testing.T) {
+	s := newStreamSharder()
+
+	// 5KB pushed within the interval bound the rate to 512B/s.
+	shards, _ := s.shards("fake", `{app="foo"}`, 5*1024, 1024, 1, 8)
+	require.Equal(t, 1, shards)
+
+	// 10KB more put it over the limit.
+	shards, next := s.shards("fake", `{app="foo"}`, 10*1024, 1024, 1, 8)
+	require.Equal(t, 2, shards)
+	require.Equal(t, 0, next)
+	shards, next = s.shards("fake", `{app="foo"}`, 0, 1024, 1, 8)
+	require.Equal(t, 2, shards)
+	require.Equal(t, 1, next)
+
+	// the rate is measured across the distributors and the shards are capped.
+	shards, _ = s.shards("fake", `{app="foo"}`, 0, 1024, 10, 8)
+	require.Equal(t, 8, shards)
+
+	// the rate of the last interval is kept, and the streams not pushed are forgotten.
+	s.update()
+	shards, _ = s.shards("fake", `{app="foo"}`, 0, 1024, 1, 8)
+	require.Equal(t, 2, shards)
+	s.update()
+	s.update()
+	require.Empty(t, s.tenants)
+}
+
+func TestShardStream(t *testing.T) {
+	stream := logproto.Stream{Labels: `{app="foo"}`, Entries: make([]logproto.Entry, 5)}
+	sharded, err := shardStream(stream, 3, 2)
+	require.NoError(t, err)
+	require.Equal(t, []logproto.Stream{
+		{Labels: `{__stream_shard__="2", app="foo"}`, Entries: stream.Entries[0:1]},
+		{Labels: `{__stream_shard__="0", app="foo"}`, Entries: stream.Entries[1:3]},
+		{Labels: `{__stream_shard__="1", app="foo"}`, Entries: stream.Entries[3:5]},
+	}, sharded)
+
+	// the shards without entries are omitted.
+	sharded, err = shardStream(logproto.Stream{Labels: `{app="foo"}`, Entries: make([]logproto.Entry, 1)}, 3, 0)
+	require.NoError(t, err)
+	require.Len(t, sharded, 1)
+	require.Equal(t, `{__stream_shard__="2", app="foo"}`, sharded[0].Labels)
+}
+
+type streamsIngester struct {
+	mockIngester
+	mtx     sync.Mutex
+	streams map[string]int
+}
+
+func (i *streamsIngester) Push(_ context.Context, in *logproto.PushRequest, _ ...grpc.CallOption) (*logproto.PushResponse, error) {
+	i.mtx.Lock()
+	defer i.mtx.Unlock()
+	for _, s := range in.Streams {
+		i.streams[s.Labels] += len(s.Entries)
+	}
+	return nil, nil
+}
+
+func Test_ShardStreamsOnPush(t *testing.T) {
+	limits := &validation.Limits{}
+	flagext.DefaultValues(limits)
+	limits.EnforceMetricName = false
+	limits.ShardStreams = true
+	limits.MaxStreamShards = 4
+	require.NoError(t, limits.PerStreamRateLimit.Set("1KB"))
+	ingester := &streamsIngester{streams: map[string]int{}}
+	d := prepare(t, limits, nil, func(addr string) (ring_client.PoolClient, error) { return ingester, nil })
+	defer services.StopAndAwaitTerminated(context.Background(), d) //nolint:errcheck
+	tee := &fakeTee{}
+	d.tee = tee
+
+	// 100KB pushed are sharded across the maximum number of shards, the 3 replicas of each shard are pushed to the
+	// same ingester.
+	_, err := d.Push(ctx, makeWriteRequest(100, 1000))
+	require.NoError(t, err)
+	expected := map[string]int{
+		`{__stream_shard__="0", foo="bar"}`: 75,
+		`{__stream_shard__="1", foo="bar"}`: 75,
+		`{__stream_shard__="2", foo="bar"}`: 75,
+		`{__stream_shard__="3", foo="bar"}`: 75,
+	}
+	require.Eventually(t, func() bool {
+		ingester.mtx.Lock()
+		defer ingester.mtx.Unlock()
+		return reflect.DeepEqual(expected, ingester.streams)
+	}, time.Second, 10*time.Millisecond)
+
+	// the streams are duplicated unsharded.
+	require.Len(t, tee.streams, 1)
+	require.Equal(t, `{foo="bar"}`, tee.streams[0].Labels)
+}
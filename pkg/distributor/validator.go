@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/weaveworks/common/httpgrpc"
@@ -36,6 +37,9 @@ type validationContext struct {
 	maxLineSize         int
 	maxLineSizeTruncate bool
 
+	invalidUTF8Handling    string
+	stripControlCharacters bool
+
 	maxLabelNamesPerSeries int
 	maxLabelNameLength     int
 	maxLabelValueLength    int
@@ -51,6 +55,8 @@ func (v Validator) getValidationContextForTime(now time.Time, userID string) val
 		creationGracePeriod:    now.Add(v.CreationGracePeriod(userID)).UnixNano(),
 		maxLineSize:            v.MaxLineSize(userID),
 		maxLineSizeTruncate:    v.MaxLineSizeTruncate(userID),
+		invalidUTF8Handling:    v.InvalidUTF8Handling(userID),
+		stripControlCharacters: v.StripControlCharacters(userID),
 		maxLabelNamesPerSeries: v.MaxLabelNamesPerSeries(userID),
 		maxLabelNameLength:     v.MaxLabelNameLength(userID),
 		maxLabelValueLength:    v.MaxLabelValueLength(userID),
@@ -87,6 +93,12 @@ func (v Validator) ValidateEntry(ctx validationContext, labels string, entry log
 		return httpgrpc.Errorf(http.StatusBadRequest, validation.LineTooLongErrorMsg, maxSize, labels, len(entry.Line))
 	}
 
+	if ctx.invalidUTF8Handling == validation.InvalidUTF8Reject && !utf8.ValidString(entry.Line) {
+		validation.DiscardedSamples.WithLabelValues(validation.InvalidUTF8, ctx.userID).Inc()
+		validation.DiscardedBytes.WithLabelValues(validation.InvalidUTF8, ctx.userID).Add(float64(len(entry.Line)))
+		return httpgrpc.Errorf(http.StatusBadRequest, validation.InvalidUTF8ErrorMsg, labels)
+	}
+
 	return nil
 }
 
@@ -85,6 +85,24 @@ func TestValidator_ValidateEntry(t *testing.T) {
 			logproto.Entry{Timestamp: testTime, Line: "12345678901"},
 			httpgrpc.Errorf(http.StatusBadRequest, validation.LineTooLongErrorMsg, 10, testStreamLabels, 11),
 		},
+		{
+			"invalid utf8 accepted",
+			"test",
+			nil,
+			logproto.Entry{Timestamp: testTime, Line: "test\xff"},
+			nil,
+		},
+		{
+			"invalid utf8 rejected",
+			"test",
+			fakeLimits{
+				&validation.Limits{
+					InvalidUTF8Handling: validation.InvalidUTF8Reject,
+				},
+			},
+			logproto.Entry{Timestamp: testTime, Line: "test\xff"},
+			httpgrpc.Errorf(http.StatusBadRequest, validation.InvalidUTF8ErrorMsg, testStreamLabels),
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
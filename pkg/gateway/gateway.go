@@ -0,0 +1,208 @@
+package gateway
+
+import (
+	"flag"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/gorilla/mux"
+	"github.com/grafana/dskit/flagext"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/weaveworks/common/middleware"
+	"github.com/weaveworks/common/user"
+
+	loki_flagext "github.com/grafana/loki/pkg/util/flagext"
+)
+
+const (
+	routeWrite = "write"
+	routeRead  = "read"
+)
+
+// Config configures the gateway routing the requests of the clients to the Loki components.
+type Config struct {
+	DistributorURL   flagext.URLValue `yaml:"distributor_url"`
+	QueryFrontendURL flagext.URLValue `yaml:"query_frontend_url"`
+	RulerURL         flagext.URLValue `yaml:"ruler_url"`
+	CompactorURL     flagext.URLValue `yaml:"compactor_url"`
+	UsersFile        string           `yaml:"users_file"`
+	WriteLimits      RouteLimits      `yaml:"write_limits"`
+	ReadLimits       RouteLimits      `yaml:"read_limits"`
+}
+
+// RouteLimits are the limits of the requests of each tenant on a route of the gateway.
+type RouteLimits struct {
+	RequestRate  float64               `yaml:"request_rate"`
+	RequestBurst int                   `yaml:"request_burst"`
+	MaxBodySize  loki_flagext.ByteSize `yaml:"max_body_size"`
+}
+
+// RegisterFlagsWithPrefix registers flags with the prefix.
+func (cfg *RouteLimits) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.Float64Var(&cfg.RequestRate, prefix+".request-rate", 0, "Requests per second allowed per tenant on the route. 0 to disable.")
+	f.IntVar(&cfg.RequestBurst, prefix+".request-burst", 0, "Burst of requests allowed per tenant on the route above the request rate. Defaults to the request rate when 0.")
+	f.Var(&cfg.MaxBodySize, prefix+".max-body-size", "Maximum size of the body of the requests on the route. 0 to disable.")
+}
+
+// RegisterFlags registers flags.
+func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
+	f.Var(&cfg.DistributorURL, "gateway.distributor-url", "URL of the distributors the pushes are routed to.")
+	f.Var(&cfg.QueryFrontendURL, "gateway.query-frontend-url", "URL of the query frontends the queries are routed to.")
+	f.Var(&cfg.RulerURL, "gateway.ruler-url", "URL of the rulers the rules and alerts requests are routed to. Empty to not route them.")
+	f.Var(&cfg.CompactorURL, "gateway.compactor-url", "URL of the compactors the delete requests are routed to. Empty to not route them.")
+	f.StringVar(&cfg.UsersFile, "gateway.users-file", "", "YAML file of the users authenticated with basic auth and the tenants each of them can access. Empty to forward the X-Scope-OrgID header of the clients.")
+	cfg.WriteLimits.RegisterFlagsWithPrefix("gateway.write", f)
+	cfg.ReadLimits.RegisterFlagsWithPrefix("gateway.read", f)
+}
+
+// Validate validates the config.
+func (cfg *Config) Validate() error {
+	if cfg.DistributorURL.URL == nil {
+		return errors.New("the URL of the distributors is required")
+	}
+	if cfg.QueryFrontendURL.URL == nil {
+		return errors.New("the URL of the query frontends is required")
+	}
+	for _, limits := range []RouteLimits{cfg.WriteLimits, cfg.ReadLimits} {
+		if limits.RequestRate < 0 || limits.RequestBurst < 0 {
+			return errors.New("the request rate and burst of a route can't be negative")
+		}
+	}
+	return nil
+}
+
+type metrics struct {
+	requests *prometheus.CounterVec
+	rejected *prometheus.CounterVec
+}
+
+func newMetrics(reg prometheus.Registerer) *metrics {
+	return &metrics{
+		requests: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "loki",
+			Name:      "gateway_requests_total",
+			Help:      "Total number of requests routed by the gateway.",
+		}, []string{"route"}),
+		rejected: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "loki",
+			Name:      "gateway_rejected_requests_total",
+			Help:      "Total number of requests rejected by the gateway.",
+		}, []string{"route", "reason"}),
+	}
+}
+
+// Gateway authenticates the requests of the clients and routes them to the Loki components, applying the limits of
+// their routes.
+type Gateway struct {
+	cfg     Config
+	users   *Users
+	logger  log.Logger
+	metrics *metrics
+
+	writeLimiter *tenantLimiter
+	readLimiter  *tenantLimiter
+}
+
+// New makes a new Gateway.
+func New(cfg Config, logger log.Logger, reg prometheus.Registerer) (*Gateway, error) {
+	g := &Gateway{
+		cfg:          cfg,
+		logger:       log.With(logger, "component", "gateway"),
+		metrics:      newMetrics(reg),
+		writeLimiter: newTenantLimiter(cfg.WriteLimits),
+		readLimiter:  newTenantLimiter(cfg.ReadLimits),
+	}
+	if cfg.UsersFile != "" {
+		users, err := LoadUsers(cfg.UsersFile)
+		if err != nil {
+			return nil, err
+		}
+		g.users = users
+	}
+	return g, nil
+}
+
+// RegisterRoutes registers the routes of the gateway, authenticating the requests with the auth middleware once their
+// tenant is resolved.
+func (g *Gateway) RegisterRoutes(router *mux.Router, authMiddleware middleware.Interface) {
+	route := func(prefix, name string, target *url.URL, limiter *tenantLimiter, limits RouteLimits) {
+		handler := authMiddleware.Wrap(g.limit(name, limiter, limits, g.proxy(name, target)))
+		router.PathPrefix(prefix).Handler(g.authenticate(name, handler))
+	}
+
+	// The routes are matched in the order they are registered.
+	route("/loki/api/v1/push", routeWrite, g.cfg.DistributorURL.URL, g.writeLimiter, g.cfg.WriteLimits)
+	route("/api/prom/push", routeWrite, g.cfg.DistributorURL.URL, g.writeLimiter, g.cfg.WriteLimits)
+	if g.cfg.RulerURL.URL != nil {
+		for _, prefix := range []string{"/loki/api/v1/rules", "/api/prom/rules", "/prometheus/api/v1/rules", "/prometheus/api/v1/alerts"} {
+			route(prefix, routeRead, g.cfg.RulerURL.URL, g.readLimiter, g.cfg.ReadLimits)
+		}
+	}
+	if g.cfg.CompactorURL.URL != nil {
+		route("/loki/api/admin/", routeRead, g.cfg.CompactorURL.URL, g.readLimiter, g.cfg.ReadLimits)
+	}
+	route("/loki/api/v1/", routeRead, g.cfg.QueryFrontendURL.URL, g.readLimiter, g.cfg.ReadLimits)
+	route("/api/prom/", routeRead, g.cfg.QueryFrontendURL.URL, g.readLimiter, g.cfg.ReadLimits)
+}
+
+// authenticate sets the tenant of the request from the credentials of the user, when the users are configured.
+func (g *Gateway) authenticate(route string, next http.Handler) http.Handler {
+	if g.users == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok {
+			g.metrics.rejected.WithLabelValues(route, "unauthenticated").Inc()
+			w.Header().Set("WWW-Authenticate", `Basic realm="loki"`)
+			http.Error(w, "authentication required", http.StatusUnauthorized)
+			return
+		}
+		orgID, err := g.users.Authenticate(username, password, r.Header.Get(user.OrgIDHeaderName))
+		if err != nil {
+			g.metrics.rejected.WithLabelValues(route, "unauthorized").Inc()
+			level.Debug(g.logger).Log("msg", "unauthorized request", "user", username, "err", err)
+			if errors.Is(err, errInvalidCredentials) {
+				w.Header().Set("WWW-Authenticate", `Basic realm="loki"`)
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+
+		// the credentials of the user aren't forwarded to the components.
+		r.Header.Del("Authorization")
+		r.Header.Set(user.OrgIDHeaderName, orgID)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (g *Gateway) proxy(route string, target *url.URL) http.Handler {
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	director := proxy.Director
+	proxy.Director = func(r *http.Request) {
+		director(r)
+		r.Host = target.Host
+	}
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		// the body read through http.MaxBytesReader failed once it exceeded the limit of the route.
+		if strings.Contains(err.Error(), "request body too large") {
+			g.metrics.rejected.WithLabelValues(route, "body_too_large").Inc()
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		level.Warn(g.logger).Log("msg", "failed to route request", "path", r.URL.Path, "target", target.String(), "err", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		g.metrics.requests.WithLabelValues(route).Inc()
+		proxy.ServeHTTP(w, r)
+	})
+}
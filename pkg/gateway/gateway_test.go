@@ -0,0 +1,188 @@
+package gateway
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/gorilla/mux"
+	"github.com/grafana/dskit/flagext"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/middleware"
+	"github.com/weaveworks/common/user"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type backend struct {
+	*httptest.Server
+	requests []*http.Request
+}
+
+func newBackend(t *testing.T, name string) *backend {
+	b := &backend{}
+	b.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b.requests = append(b.requests, r)
+		_, _ = ioutil.ReadAll(r.Body)
+		_, _ = fmt.Fprint(w, name)
+	}))
+	t.Cleanup(b.Close)
+	return b
+}
+
+func urlValue(t *testing.T, s string) flagext.URLValue {
+	var u flagext.URLValue
+	require.NoError(t, u.Set(s))
+	return u
+}
+
+func newTestGateway(t *testing.T, cfg Config) *mux.Router {
+	g, err := New(cfg, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+	router := mux.NewRouter()
+	g.RegisterRoutes(router, middleware.AuthenticateUser)
+	return router
+}
+
+func do(router http.Handler, method, path, body string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	for k, values := range header {
+		for _, v := range values {
+			req.Header.Add(k, v)
+		}
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestGateway_Routes(t *testing.T) {
+	distributor, frontend, ruler := newBackend(t, "distributor"), newBackend(t, "frontend"), newBackend(t, "ruler")
+	router := newTestGateway(t, Config{
+		DistributorURL:   urlValue(t, distributor.URL),
+		QueryFrontendURL: urlValue(t, frontend.URL),
+		RulerURL:         urlValue(t, ruler.URL),
+	})
+	header := http.Header{user.OrgIDHeaderName: {"tenant"}}
+
+	for path, expected := range map[string]string{
+		"/loki/api/v1/push":                      "distributor",
+		"/api/prom/push":                         "distributor",
+		"/loki/api/v1/query_range?query=foo":     "frontend",
+		"/loki/api/v1/label/foo/values":          "frontend",
+		"/api/prom/query":                        "frontend",
+		"/loki/api/v1/rules/namespace":           "ruler",
+		"/prometheus/api/v1/alerts":              "ruler",
+		"/loki/api/admin/delete?query={foo=bar}": "",
+	} {
+		w := do(router, http.MethodPost, path, "", header)
+		if expected == "" {
+			// the compactor isn't routed.
+			require.Equal(t, http.StatusNotFound, w.Code, path)
+			continue
+		}
+		require.Equal(t, http.StatusOK, w.Code, path)
+		require.Equal(t, expected, w.Body.String(), path)
+	}
+	require.Equal(t, "tenant", frontend.requests[0].Header.Get(user.OrgIDHeaderName))
+	require.Equal(t, "query=foo", frontend.requests[0].URL.RawQuery)
+
+	// the tenant is required.
+	w := do(router, http.MethodGet, "/loki/api/v1/labels", "", nil)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestGateway_Users(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+	usersFile := filepath.Join(t.TempDir(), "users.yaml")
+	require.NoError(t, ioutil.WriteFile(usersFile, []byte(fmt.Sprintf(`
+users:
+  alice:
+    password_hash: %s
+    tenants: [team-a, team-b]
+`, hash)), 0o600))
+
+	distributor, frontend := newBackend(t, "distributor"), newBackend(t, "frontend")
+	router := newTestGateway(t, Config{
+		DistributorURL:   urlValue(t, distributor.URL),
+		QueryFrontendURL: urlValue(t, frontend.URL),
+		UsersFile:        usersFile,
+	})
+
+	basicAuth := func(username, password string, orgID string) http.Header {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.SetBasicAuth(username, password)
+		if orgID != "" {
+			req.Header.Set(user.OrgIDHeaderName, orgID)
+		}
+		return req.Header
+	}
+
+	for _, tc := range []struct {
+		name          string
+		header        http.Header
+		expectedCode  int
+		expectedOrgID string
+	}{
+		{name: "no credentials", header: http.Header{user.OrgIDHeaderName: {"team-a"}}, expectedCode: http.StatusUnauthorized},
+		{name: "invalid password", header: basicAuth("alice", "wrong", ""), expectedCode: http.StatusUnauthorized},
+		{name: "unknown user", header: basicAuth("bob", "secret", ""), expectedCode: http.StatusUnauthorized},
+		{name: "default tenant", header: basicAuth("alice", "secret", ""), expectedCode: http.StatusOK, expectedOrgID: "team-a"},
+		{name: "allowed tenant", header: basicAuth("alice", "secret", "team-b"), expectedCode: http.StatusOK, expectedOrgID: "team-b"},
+		{name: "allowed tenants", header: basicAuth("alice", "secret", "team-a|team-b"), expectedCode: http.StatusOK, expectedOrgID: "team-a|team-b"},
+		{name: "forbidden tenant", header: basicAuth("alice", "secret", "team-c"), expectedCode: http.StatusForbidden},
+		// the password verified is cached.
+		{name: "invalid password once verified", header: basicAuth("alice", "wrong", ""), expectedCode: http.StatusUnauthorized},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			frontend.requests = nil
+			w := do(router, http.MethodGet, "/loki/api/v1/labels", "", tc.header)
+			require.Equal(t, tc.expectedCode, w.Code)
+			if tc.expectedCode != http.StatusOK {
+				require.Empty(t, frontend.requests)
+				return
+			}
+			require.Len(t, frontend.requests, 1)
+			require.Equal(t, tc.expectedOrgID, frontend.requests[0].Header.Get(user.OrgIDHeaderName))
+			require.Empty(t, frontend.requests[0].Header.Get("Authorization"))
+		})
+	}
+}
+
+func TestGateway_Limits(t *testing.T) {
+	distributor, frontend := newBackend(t, "distributor"), newBackend(t, "frontend")
+	cfg := Config{
+		DistributorURL:   urlValue(t, distributor.URL),
+		QueryFrontendURL: urlValue(t, frontend.URL),
+		WriteLimits:      RouteLimits{RequestRate: 0.001, RequestBurst: 2, MaxBodySize: 10},
+	}
+	router := newTestGateway(t, cfg)
+
+	push := func(tenant, body string) *httptest.ResponseRecorder {
+		return do(router, http.MethodPost, "/loki/api/v1/push", body, http.Header{user.OrgIDHeaderName: {tenant}})
+	}
+
+	require.Equal(t, http.StatusOK, push("a", "entries").Code)
+	require.Equal(t, http.StatusRequestEntityTooLarge, push("a", "too many entries").Code)
+	require.Equal(t, http.StatusOK, push("a", "entries").Code)
+
+	// the burst of the tenant is exhausted.
+	w := push("a", "entries")
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.NotEmpty(t, w.Header().Get("Retry-After"))
+
+	// the tenants are limited independently.
+	require.Equal(t, http.StatusOK, push("b", "entries").Code)
+
+	// the read route isn't limited.
+	for i := 0; i < 5; i++ {
+		w = do(router, http.MethodGet, "/loki/api/v1/labels", strings.Repeat("x", 100), http.Header{user.OrgIDHeaderName: {"a"}})
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+	require.Len(t, distributor.requests, 3)
+}
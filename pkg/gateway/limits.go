@@ -0,0 +1,85 @@
+package gateway
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/weaveworks/common/user"
+	"golang.org/x/time/rate"
+
+	"github.com/grafana/loki/pkg/util/limiter"
+)
+
+// tenantLimiter limits the rate of the requests of each tenant on a route.
+type tenantLimiter struct {
+	limit rate.Limit
+	burst int
+	now   func() time.Time
+
+	mtx      sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+func newTenantLimiter(limits RouteLimits) *tenantLimiter {
+	if limits.RequestRate <= 0 {
+		return nil
+	}
+	burst := limits.RequestBurst
+	if burst == 0 {
+		burst = int(math.Max(1, math.Ceil(limits.RequestRate)))
+	}
+	return &tenantLimiter{
+		limit:    rate.Limit(limits.RequestRate),
+		burst:    burst,
+		now:      time.Now,
+		limiters: map[string]*rate.Limiter{},
+	}
+}
+
+// reserve takes a request of the tenant from its limiter.
+func (l *tenantLimiter) reserve(tenant string) limiter.Reservation {
+	l.mtx.Lock()
+	lim, ok := l.limiters[tenant]
+	if !ok {
+		lim = rate.NewLimiter(l.limit, l.burst)
+		l.limiters[tenant] = lim
+	}
+	l.mtx.Unlock()
+
+	return limiter.ReserveN(lim, l.now(), 1)
+}
+
+// limit applies the limits of the route to the requests of the tenants.
+func (g *Gateway) limit(route string, tenantLimiter *tenantLimiter, limits RouteLimits, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if maxBodySize := int64(limits.MaxBodySize); maxBodySize > 0 {
+			if r.ContentLength > maxBodySize {
+				g.metrics.rejected.WithLabelValues(route, "body_too_large").Inc()
+				http.Error(w, fmt.Sprintf("request body too large: %d bytes, limit %d", r.ContentLength, maxBodySize), http.StatusRequestEntityTooLarge)
+				return
+			}
+			// the bodies of an unknown length fail once they exceed the limit.
+			r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+		}
+
+		if tenantLimiter != nil {
+			orgID, err := user.ExtractOrgID(r.Context())
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			if res := tenantLimiter.reserve(orgID); !res.OK {
+				g.metrics.rejected.WithLabelValues(route, "rate_limited").Inc()
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(res.RetryAfter.Seconds()))))
+				http.Error(w, fmt.Sprintf("%s request rate limit (%v requests/s) exceeded for tenant %s", route, res.Limit, orgID), http.StatusTooManyRequests)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
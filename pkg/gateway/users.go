@@ -0,0 +1,111 @@
+package gateway
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v2"
+
+	"github.com/grafana/loki/pkg/tenant"
+	"github.com/grafana/loki/pkg/util"
+)
+
+var errInvalidCredentials = errors.New("invalid credentials")
+
+// User is a user of the gateway, authenticated with basic auth.
+type User struct {
+	// PasswordHash is the bcrypt hash of the password of the user, as generated by htpasswd -B.
+	PasswordHash string `yaml:"password_hash"`
+	// Tenants are the tenants the user can access, the first one being used when the request doesn't set any.
+	Tenants []string `yaml:"tenants"`
+}
+
+// Users are the users of the gateway, by name.
+type Users struct {
+	Users map[string]User `yaml:"users"`
+
+	// verified caches the hashes of the passwords verified, bcrypt being too slow to verify every request.
+	mtx      sync.RWMutex
+	verified map[string][sha256.Size]byte
+}
+
+// LoadUsers loads the users of the gateway from the YAML file.
+func LoadUsers(filename string) (*Users, error) {
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrap(err, "read gateway users file")
+	}
+	users := &Users{}
+	if err := yaml.UnmarshalStrict(buf, users); err != nil {
+		return nil, errors.Wrap(err, "parse gateway users file")
+	}
+	if err := users.validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid gateway users file")
+	}
+	return users, nil
+}
+
+func (u *Users) validate() error {
+	for name, user := range u.Users {
+		if user.PasswordHash == "" {
+			return fmt.Errorf("user %s has no password hash", name)
+		}
+		if len(user.Tenants) == 0 {
+			return fmt.Errorf("user %s has no tenant", name)
+		}
+		for _, tenantID := range user.Tenants {
+			if err := tenant.ValidTenantID(tenantID); err != nil {
+				return fmt.Errorf("user %s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Authenticate verifies the credentials of the user and returns the org ID of the request: the tenants of the
+// orgID asked for, separated by '|', which the user must all be allowed to access, or the first tenant of the user
+// when the request doesn't ask for any.
+func (u *Users) Authenticate(username, password, orgID string) (string, error) {
+	user, ok := u.Users[username]
+	if !ok || !u.verify(username, password, user.PasswordHash) {
+		return "", errInvalidCredentials
+	}
+
+	if orgID == "" {
+		return user.Tenants[0], nil
+	}
+	for _, tenantID := range strings.Split(orgID, "|") {
+		if !util.StringsContain(user.Tenants, tenantID) {
+			return "", fmt.Errorf("user %s is not allowed to access tenant %s", username, tenantID)
+		}
+	}
+	return orgID, nil
+}
+
+func (u *Users) verify(username, password, passwordHash string) bool {
+	sum := sha256.Sum256([]byte(password))
+
+	u.mtx.RLock()
+	verified, ok := u.verified[username]
+	u.mtx.RUnlock()
+	if ok {
+		return subtle.ConstantTimeCompare(sum[:], verified[:]) == 1
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(password)) != nil {
+		return false
+	}
+	u.mtx.Lock()
+	if u.verified == nil {
+		u.verified = map[string][sha256.Size]byte{}
+	}
+	u.verified[username] = sum
+	u.mtx.Unlock()
+	return true
+}
@@ -33,6 +33,7 @@ type HealthAndIngesterClient interface {
 
 type ClosableHealthAndIngesterClient struct {
 	logproto.PusherClient
+	CompressedPusherClient
 	logproto.QuerierClient
 	logproto.IngesterClient
 	grpc_health_v1.HealthClient
@@ -74,11 +75,12 @@ func New(cfg Config, addr string) (HealthAndIngesterClient, error) {
 		return nil, err
 	}
 	return ClosableHealthAndIngesterClient{
-		PusherClient:   logproto.NewPusherClient(conn),
-		QuerierClient:  logproto.NewQuerierClient(conn),
-		IngesterClient: logproto.NewIngesterClient(conn),
-		HealthClient:   grpc_health_v1.NewHealthClient(conn),
-		Closer:         conn,
+		PusherClient:           logproto.NewPusherClient(conn),
+		CompressedPusherClient: NewCompressedPusherClient(conn),
+		QuerierClient:          logproto.NewQuerierClient(conn),
+		IngesterClient:         logproto.NewIngesterClient(conn),
+		HealthClient:           grpc_health_v1.NewHealthClient(conn),
+		Closer:                 conn,
 	}, nil
 }
 
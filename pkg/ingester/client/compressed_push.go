@@ -0,0 +1,98 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+
+	"github.com/grafana/loki/pkg/logproto"
+)
+
+// ZstdPushCodecName is the content subtype of the pushes whose request is the zstd-compressed
+// protobuf encoding of the PushRequest, the responses being plain protobuf.
+const ZstdPushCodecName = "loki-zstd-push"
+
+func init() {
+	encoding.RegisterCodec(zstdPushCodec{})
+}
+
+// zstdDecoders pools the zstd decoders, which are costly to create.
+var zstdDecoders = sync.Pool{
+	New: func() interface{} {
+		decoder, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			panic(err)
+		}
+		return decoder
+	},
+}
+
+// compressedPushRequest is a zstd-compressed PushRequest, sent as it is.
+type compressedPushRequest []byte
+
+// zstdPushCodec sends the compressed push requests without encoding them again, and decompresses
+// them before decoding them. The other messages are encoded with protobuf.
+type zstdPushCodec struct{}
+
+func (zstdPushCodec) Marshal(v interface{}) ([]byte, error) {
+	switch m := v.(type) {
+	case compressedPushRequest:
+		return m, nil
+	case proto.Message:
+		return proto.Marshal(m)
+	default:
+		return nil, fmt.Errorf("failed to marshal, message is %T, want proto.Message", v)
+	}
+}
+
+func (zstdPushCodec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("failed to unmarshal, message is %T, want proto.Message", v)
+	}
+	if _, ok := v.(*logproto.PushRequest); ok {
+		decoder := zstdDecoders.Get().(*zstd.Decoder)
+		defer zstdDecoders.Put(decoder)
+		decompressed, err := decoder.DecodeAll(data, nil)
+		if err != nil {
+			return err
+		}
+		data = decompressed
+	}
+	return proto.Unmarshal(data, m)
+}
+
+func (zstdPushCodec) Name() string {
+	return ZstdPushCodecName
+}
+
+// CompressedPusherClient pushes the zstd-compressed protobuf encoding of a PushRequest to an ingester,
+// which saves encoding the request, and compressing it when the gRPC compression is enabled.
+// The ingesters must register the codec of the compressed pushes, which they do from this package.
+type CompressedPusherClient interface {
+	PushCompressed(ctx context.Context, payload []byte, opts ...grpc.CallOption) (*logproto.PushResponse, error)
+}
+
+type compressedPusherClient struct {
+	cc *grpc.ClientConn
+}
+
+// NewCompressedPusherClient returns a CompressedPusherClient pushing with the connection.
+func NewCompressedPusherClient(cc *grpc.ClientConn) CompressedPusherClient {
+	return &compressedPusherClient{cc: cc}
+}
+
+func (c *compressedPusherClient) PushCompressed(ctx context.Context, payload []byte, opts ...grpc.CallOption) (*logproto.PushResponse, error) {
+	out := new(logproto.PushResponse)
+	// the payload is already compressed, it isn't compressed again by gRPC.
+	opts = append(opts, grpc.CallContentSubtype(ZstdPushCodecName), grpc.UseCompressor(encoding.Identity))
+	if err := c.cc.Invoke(ctx, "/logproto.Pusher/Push", compressedPushRequest(payload), out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
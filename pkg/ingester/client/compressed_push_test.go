@@ -0,0 +1,45 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/encoding"
+
+	"github.com/grafana/loki/pkg/logproto"
+)
+
+func TestZstdPushCodec(t *testing.T) {
+	codec := encoding.GetCodec(ZstdPushCodecName)
+	require.NotNil(t, codec)
+
+	req := &logproto.PushRequest{
+		Streams: []logproto.Stream{
+			{
+				Labels:  `{foo="bar"}`,
+				Entries: []logproto.Entry{{Timestamp: time.Unix(0, 1).UTC(), Line: "fizzbuzz"}},
+			},
+		},
+	}
+	buf, err := req.Marshal()
+	require.NoError(t, err)
+	encoder, err := zstd.NewWriter(nil)
+	require.NoError(t, err)
+	payload := encoder.EncodeAll(buf, nil)
+
+	// the compressed requests are sent as they are.
+	data, err := codec.Marshal(compressedPushRequest(payload))
+	require.NoError(t, err)
+	require.Equal(t, payload, data)
+
+	var decoded logproto.PushRequest
+	require.NoError(t, codec.Unmarshal(data, &decoded))
+	require.Equal(t, *req, decoded)
+
+	// the responses are plain protobuf.
+	data, err = codec.Marshal(&logproto.PushResponse{})
+	require.NoError(t, err)
+	require.NoError(t, codec.Unmarshal(data, &logproto.PushResponse{}))
+}
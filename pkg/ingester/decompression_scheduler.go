@@ -0,0 +1,258 @@
+package ingester
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/grafana/loki/pkg/iter"
+	"github.com/grafana/loki/pkg/logproto"
+)
+
+// decompressionScheduler bounds the number of query batches being read
+// concurrently by the ingester. Reading a batch is where chunk blocks get
+// decompressed, so this caps the number of cores queries can use and keeps
+// appends from being starved by a single heavy query.
+//
+// Free slots are handed out to waiting tenants in round-robin order, so a
+// tenant with many concurrent queries cannot monopolize the slots.
+type decompressionScheduler struct {
+	mtx     sync.Mutex
+	slots   int
+	inUse   int
+	waiting map[string][]chan struct{}
+	// tenants with waiters, in the order they will be served.
+	queue []string
+
+	metrics *ingesterMetrics
+}
+
+// newDecompressionScheduler returns a scheduler with the given number of slots.
+// A nil scheduler is returned when slots <= 0, which disables scheduling.
+func newDecompressionScheduler(slots int, metrics *ingesterMetrics) *decompressionScheduler {
+	if slots <= 0 {
+		return nil
+	}
+	return &decompressionScheduler{
+		slots:   slots,
+		waiting: map[string][]chan struct{}{},
+		metrics: metrics,
+	}
+}
+
+// acquire blocks until a slot is available for the tenant or the context is done.
+func (s *decompressionScheduler) acquire(ctx context.Context, tenant string) error {
+	s.mtx.Lock()
+	if s.inUse < s.slots && len(s.queue) == 0 {
+		s.inUse++
+		s.mtx.Unlock()
+		return nil
+	}
+
+	ch := make(chan struct{})
+	if len(s.waiting[tenant]) == 0 {
+		s.queue = append(s.queue, tenant)
+	}
+	s.waiting[tenant] = append(s.waiting[tenant], ch)
+	s.metrics.queryDecompressionQueueLength.Inc()
+	s.mtx.Unlock()
+
+	start := time.Now()
+	defer func() {
+		s.metrics.queryDecompressionWaitSeconds.Observe(time.Since(start).Seconds())
+	}()
+
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	select {
+	case <-ch:
+		// The slot was handed over to us while the context was being cancelled,
+		// pass it on to the next waiter.
+		s.releaseLocked()
+	default:
+		s.removeWaiterLocked(tenant, ch)
+	}
+	return ctx.Err()
+}
+
+// release gives the slot back, handing it over to the next waiting tenant if any.
+func (s *decompressionScheduler) release() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.releaseLocked()
+}
+
+func (s *decompressionScheduler) releaseLocked() {
+	if len(s.queue) == 0 {
+		s.inUse--
+		return
+	}
+
+	tenant := s.queue[0]
+	s.queue = s.queue[1:]
+	waiters := s.waiting[tenant]
+	next := waiters[0]
+	if len(waiters) == 1 {
+		delete(s.waiting, tenant)
+	} else {
+		s.waiting[tenant] = waiters[1:]
+		// Requeue the tenant at the back so other tenants get served first.
+		s.queue = append(s.queue, tenant)
+	}
+	s.metrics.queryDecompressionQueueLength.Dec()
+	// The slot is transferred to the waiter, inUse stays the same.
+	close(next)
+}
+
+func (s *decompressionScheduler) removeWaiterLocked(tenant string, ch chan struct{}) {
+	waiters := s.waiting[tenant]
+	for i, w := range waiters {
+		if w == ch {
+			waiters = append(waiters[:i], waiters[i+1:]...)
+			break
+		}
+	}
+	s.metrics.queryDecompressionQueueLength.Dec()
+	if len(waiters) > 0 {
+		s.waiting[tenant] = waiters
+		return
+	}
+	delete(s.waiting, tenant)
+	for i, t := range s.queue {
+		if t == tenant {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			break
+		}
+	}
+}
+
+// scheduledIterator holds a scheduler slot while reading up to batchSize
+// entries, and releases it in between so the slot isn't held while the batch
+// is sent to the querier.
+type scheduledIterator struct {
+	ctx       context.Context
+	tenant    string
+	scheduler *decompressionScheduler
+	batchSize int
+
+	held bool
+	read int
+	err  error
+}
+
+func (s *scheduledIterator) next(next func() bool) bool {
+	if s.err != nil {
+		return false
+	}
+	if !s.held {
+		if err := s.scheduler.acquire(s.ctx, s.tenant); err != nil {
+			s.err = err
+			return false
+		}
+		s.held = true
+		s.read = 0
+	}
+	ok := next()
+	s.read++
+	if !ok || s.read >= s.batchSize {
+		s.releaseSlot()
+	}
+	return ok
+}
+
+func (s *scheduledIterator) releaseSlot() {
+	if s.held {
+		s.held = false
+		s.scheduler.release()
+	}
+}
+
+type scheduledEntryIterator struct {
+	iter.EntryIterator
+	scheduledIterator
+}
+
+// newScheduledEntryIterator returns an iterator reading batches of entries
+// through the scheduler. The iterator is returned unchanged when the scheduler is disabled.
+func newScheduledEntryIterator(ctx context.Context, s *decompressionScheduler, tenant string, it iter.EntryIterator) iter.EntryIterator {
+	if s == nil {
+		return it
+	}
+	return &scheduledEntryIterator{
+		EntryIterator: it,
+		scheduledIterator: scheduledIterator{
+			ctx:       ctx,
+			tenant:    tenant,
+			scheduler: s,
+			batchSize: queryBatchSize,
+		},
+	}
+}
+
+func (s *scheduledEntryIterator) Next() bool {
+	return s.next(s.EntryIterator.Next)
+}
+
+func (s *scheduledEntryIterator) Entry() logproto.Entry {
+	return s.EntryIterator.Entry()
+}
+
+func (s *scheduledEntryIterator) Error() error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.EntryIterator.Error()
+}
+
+func (s *scheduledEntryIterator) Close() error {
+	s.releaseSlot()
+	return s.EntryIterator.Close()
+}
+
+type scheduledSampleIterator struct {
+	iter.SampleIterator
+	scheduledIterator
+}
+
+// newScheduledSampleIterator returns an iterator reading batches of samples
+// through the scheduler. The iterator is returned unchanged when the scheduler is disabled.
+func newScheduledSampleIterator(ctx context.Context, s *decompressionScheduler, tenant string, it iter.SampleIterator) iter.SampleIterator {
+	if s == nil {
+		return it
+	}
+	return &scheduledSampleIterator{
+		SampleIterator: it,
+		scheduledIterator: scheduledIterator{
+			ctx:       ctx,
+			tenant:    tenant,
+			scheduler: s,
+			batchSize: queryBatchSampleSize,
+		},
+	}
+}
+
+func (s *scheduledSampleIterator) Next() bool {
+	return s.next(s.SampleIterator.Next)
+}
+
+func (s *scheduledSampleIterator) Sample() logproto.Sample {
+	return s.SampleIterator.Sample()
+}
+
+func (s *scheduledSampleIterator) Error() error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.SampleIterator.Error()
+}
+
+func (s *scheduledSampleIterator) Close() error {
+	s.releaseSlot()
+	return s.SampleIterator.Close()
+}
@@ -0,0 +1,102 @@
+package ingester
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/pkg/iter"
+	"github.com/grafana/loki/pkg/logproto"
+)
+
+func TestDecompressionScheduler_Disabled(t *testing.T) {
+	require.Nil(t, newDecompressionScheduler(0, nil))
+
+	it := iter.NewStreamIterator(logproto.Stream{Labels: `{foo="bar"}`})
+	require.Equal(t, it, newScheduledEntryIterator(context.Background(), nil, "fake", it))
+}
+
+func TestDecompressionScheduler_Fairness(t *testing.T) {
+	s := newDecompressionScheduler(1, newIngesterMetrics(prometheus.NewRegistry()))
+	ctx := context.Background()
+
+	require.NoError(t, s.acquire(ctx, "busy"))
+
+	// Queue two waiters for the busy tenant before a single one for the other tenant.
+	order := make(chan string, 3)
+	wait := func(tenant string) {
+		go func() {
+			require.NoError(t, s.acquire(ctx, tenant))
+			order <- tenant
+		}()
+		require.Eventually(t, func() bool {
+			s.mtx.Lock()
+			defer s.mtx.Unlock()
+			for _, t := range s.queue {
+				if t == tenant {
+					return true
+				}
+			}
+			return false
+		}, time.Second, time.Millisecond)
+	}
+	wait("busy")
+	wait("busy")
+	wait("quiet")
+
+	// Each release hands the slot to the next tenant in round-robin order.
+	s.release()
+	require.Equal(t, "busy", <-order)
+	s.release()
+	require.Equal(t, "quiet", <-order)
+	s.release()
+	require.Equal(t, "busy", <-order)
+	s.release()
+
+	require.Equal(t, 0, s.inUse)
+	require.Empty(t, s.queue)
+	require.Empty(t, s.waiting)
+}
+
+func TestDecompressionScheduler_Cancel(t *testing.T) {
+	s := newDecompressionScheduler(1, newIngesterMetrics(prometheus.NewRegistry()))
+	require.NoError(t, s.acquire(context.Background(), "fake"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.Equal(t, context.DeadlineExceeded, s.acquire(ctx, "fake"))
+	require.Empty(t, s.queue)
+	require.Empty(t, s.waiting)
+
+	s.release()
+	require.Equal(t, 0, s.inUse)
+}
+
+func TestScheduledEntryIterator(t *testing.T) {
+	s := newDecompressionScheduler(1, newIngesterMetrics(prometheus.NewRegistry()))
+
+	stream := logproto.Stream{Labels: `{foo="bar"}`}
+	for i := 0; i < queryBatchSize+10; i++ {
+		stream.Entries = append(stream.Entries, logproto.Entry{Timestamp: time.Unix(0, int64(i)), Line: "line"})
+	}
+	it := newScheduledEntryIterator(context.Background(), s, "fake", iter.NewStreamIterator(stream))
+
+	var count int
+	for it.Next() {
+		count++
+		// The slot is released once a full batch has been read.
+		if count == queryBatchSize {
+			require.Equal(t, 0, s.inUse)
+		} else {
+			require.Equal(t, 1, s.inUse)
+		}
+	}
+	require.NoError(t, it.Error())
+	require.Equal(t, queryBatchSize+10, count)
+	require.Equal(t, 0, s.inUse)
+	require.NoError(t, it.Close())
+	require.Equal(t, 0, s.inUse)
+}
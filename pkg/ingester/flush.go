@@ -91,6 +91,11 @@ var (
 		// 1h -> 8hr
 		Buckets: prometheus.LinearBuckets(1, 1, 8),
 	})
+	chunkContainers = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "loki",
+		Name:      "ingester_chunk_containers_stored_total",
+		Help:      "Total stored objects packing the small chunks of idle streams.",
+	})
 	flushedChunksStats            = usagestats.NewCounter("ingester_flushed_chunks")
 	flushedChunksBytesStats       = usagestats.NewStatistics("ingester_flushed_chunks_bytes")
 	flushedChunksLinesStats       = usagestats.NewStatistics("ingester_flushed_chunks_lines")
@@ -154,9 +159,15 @@ type flushOp struct {
 	userID    string
 	fp        model.Fingerprint
 	immediate bool
+
+	// idle streams whose chunks are packed in containers, instead of the stream fp.
+	idle []model.Fingerprint
 }
 
 func (o *flushOp) Key() string {
+	if len(o.idle) > 0 {
+		return fmt.Sprintf("%s-containers-%v", o.userID, o.immediate)
+	}
 	return fmt.Sprintf("%s-%s-%v", o.userID, o.fp, o.immediate)
 }
 
@@ -174,11 +185,59 @@ func (i *Ingester) sweepUsers(immediate, mayRemoveStreams bool) {
 }
 
 func (i *Ingester) sweepInstance(instance *instance, immediate, mayRemoveStreams bool) {
+	idle := &flushOp{userID: instance.instanceID, immediate: immediate}
 	_ = instance.streams.ForEach(func(s *stream) (bool, error) {
-		i.sweepStream(instance, s, immediate)
+		if from, ok := i.packableStream(s); ok {
+			if len(idle.idle) == 0 || from < idle.from {
+				idle.from = from
+			}
+			idle.idle = append(idle.idle, s.fp)
+		} else {
+			i.sweepStream(instance, s, immediate)
+		}
 		i.removeFlushedChunks(instance, s, mayRemoveStreams)
 		return true, nil
 	})
+
+	// the chunks of the idle streams of the tenant are flushed together.
+	if len(idle.idle) > 0 {
+		flushQueueIndex := int(uint64(idle.idle[0]) % uint64(i.cfg.ConcurrentFlushes))
+		i.flushQueues[flushQueueIndex].Enqueue(idle)
+	}
+}
+
+// packableStream tells whether the chunks of the stream are to be packed in containers
+// with the ones of the other idle streams of the tenant, and the start of its first chunk
+// to flush. The stream must be idle and the chunks to flush small enough.
+func (i *Ingester) packableStream(stream *stream) (model.Time, bool) {
+	if i.cfg.ChunkContainerMaxChunkSize <= 0 {
+		return 0, false
+	}
+
+	stream.chunkMtx.RLock()
+	defer stream.chunkMtx.RUnlock()
+	if len(stream.chunks) == 0 || time.Since(stream.chunks[len(stream.chunks)-1].lastUpdated) <= stream.maxChunkIdle() {
+		return 0, false
+	}
+
+	var (
+		from    model.Time
+		pending bool
+	)
+	for _, c := range stream.chunks {
+		if !c.flushed.IsZero() {
+			continue
+		}
+		if c.chunk.CompressedSize() > i.cfg.ChunkContainerMaxChunkSize {
+			return 0, false
+		}
+		if !pending {
+			firstTime, _ := c.chunk.Bounds()
+			from = model.TimeFromUnixNano(firstTime.UnixNano())
+			pending = true
+		}
+	}
+	return from, pending
 }
 
 func (i *Ingester) sweepStream(instance *instance, stream *stream, immediate bool) {
@@ -189,7 +248,7 @@ func (i *Ingester) sweepStream(instance *instance, stream *stream, immediate boo
 	}
 
 	lastChunk := stream.chunks[len(stream.chunks)-1]
-	shouldFlush, _ := i.shouldFlushChunk(&lastChunk)
+	shouldFlush, _ := i.shouldFlushChunk(stream, &lastChunk)
 	if len(stream.chunks) == 1 && !immediate && !shouldFlush {
 		return
 	}
@@ -197,8 +256,10 @@ func (i *Ingester) sweepStream(instance *instance, stream *stream, immediate boo
 	flushQueueIndex := int(uint64(stream.fp) % uint64(i.cfg.ConcurrentFlushes))
 	firstTime, _ := stream.chunks[0].chunk.Bounds()
 	i.flushQueues[flushQueueIndex].Enqueue(&flushOp{
-		model.TimeFromUnixNano(firstTime.UnixNano()), instance.instanceID,
-		stream.fp, immediate,
+		from:      model.TimeFromUnixNano(firstTime.UnixNano()),
+		userID:    instance.instanceID,
+		fp:        stream.fp,
+		immediate: immediate,
 	})
 }
 
@@ -217,7 +278,12 @@ func (i *Ingester) flushLoop(j int) {
 
 		level.Debug(util_log.Logger).Log("msg", "flushing stream", "userid", op.userID, "fp", op.fp, "immediate", op.immediate)
 
-		err := i.flushUserSeries(op.userID, op.fp, op.immediate)
+		var err error
+		if len(op.idle) > 0 {
+			err = i.flushUserContainers(op.userID, op.idle, op.immediate)
+		} else {
+			err = i.flushUserSeries(op.userID, op.fp, op.immediate)
+		}
 		if err != nil {
 			level.Error(util_log.WithUserID(op.userID, util_log.Logger)).Log("msg", "failed to flush user", "err", err)
 		}
@@ -253,6 +319,130 @@ func (i *Ingester) flushUserSeries(userID string, fp model.Fingerprint, immediat
 	return nil
 }
 
+// flushUserContainers flushes the chunks of the idle streams of the user at once,
+// packing the small ones in containers to write fewer objects.
+func (i *Ingester) flushUserContainers(userID string, fps []model.Fingerprint, immediate bool) error {
+	instance, ok := i.getInstanceByID(userID)
+	if !ok {
+		return nil
+	}
+
+	ctx := user.InjectOrgID(context.Background(), userID)
+	ctx, cancel := context.WithTimeout(ctx, i.cfg.FlushOpTimeout)
+	defer cancel()
+
+	type streamChunks struct {
+		cs         []*chunkDesc
+		wireChunks []chunk.Chunk
+		chunkMtx   sync.Locker
+	}
+	var (
+		streams    []streamChunks
+		wireChunks []chunk.Chunk
+	)
+	for _, fp := range fps {
+		cs, labels, chunkMtx := i.collectChunksToFlush(instance, fp, immediate)
+		if len(cs) < 1 {
+			continue
+		}
+		streamWireChunks, err := i.encodeChunks(userID, fp, labels, cs, chunkMtx)
+		if err != nil {
+			return err
+		}
+		streams = append(streams, streamChunks{cs: cs, wireChunks: streamWireChunks, chunkMtx: chunkMtx})
+		wireChunks = append(wireChunks, streamWireChunks...)
+	}
+	if len(wireChunks) == 0 {
+		return nil
+	}
+
+	containers, err := i.packChunks(userID, wireChunks)
+	if err != nil {
+		return err
+	}
+	if err := i.store.Put(ctx, wireChunks); err != nil {
+		return err
+	}
+	flushedChunksStats.Inc(int64(len(wireChunks)))
+	chunkContainers.Add(float64(containers))
+
+	for _, s := range streams {
+		i.reportFlushedChunks(userID, s.cs, s.wireChunks, s.chunkMtx)
+	}
+	return nil
+}
+
+// packChunks packs the small encoded chunks in containers of up to the max container
+// size, and returns the number of containers. The chunks of a container all belong to
+// the same period of the schema config, so that they are written by the same store.
+func (i *Ingester) packChunks(userID string, wireChunks []chunk.Chunk) (int, error) {
+	schemaCfg := chunk.SchemaConfig{Configs: i.store.GetTenantSchemaConfigs(userID)}
+
+	type container struct {
+		chunks []int
+		size   int
+	}
+	var (
+		containers int
+		open       = map[chunk.DayTime]*container{}
+	)
+	pack := func(c *container) error {
+		if len(c.chunks) < 2 {
+			return nil
+		}
+		contained := make([]chunk.Chunk, 0, len(c.chunks))
+		for _, j := range c.chunks {
+			contained = append(contained, wireChunks[j])
+		}
+		if err := chunk.PackChunks(contained); err != nil {
+			return err
+		}
+		for k, j := range c.chunks {
+			wireChunks[j].Container = contained[k].Container
+		}
+		containers++
+		return nil
+	}
+
+	for j := range wireChunks {
+		buf, err := wireChunks[j].Encoded()
+		if err != nil {
+			return 0, err
+		}
+		if len(buf) > i.cfg.ChunkContainerMaxChunkSize {
+			continue
+		}
+		from, err := schemaCfg.SchemaForTime(wireChunks[j].From)
+		if err != nil {
+			continue
+		}
+		through, err := schemaCfg.SchemaForTime(wireChunks[j].Through)
+		if err != nil || through.From != from.From {
+			continue
+		}
+
+		c := open[from.From]
+		if c == nil {
+			c = &container{}
+			open[from.From] = c
+		}
+		if c.size+len(buf) > i.cfg.ChunkContainerMaxSize {
+			if err := pack(c); err != nil {
+				return 0, err
+			}
+			*c = container{}
+		}
+		c.chunks = append(c.chunks, j)
+		c.size += len(buf)
+	}
+	for _, c := range open {
+		if err := pack(c); err != nil {
+			return 0, err
+		}
+	}
+	return containers, nil
+}
+
 func (i *Ingester) collectChunksToFlush(instance *instance, fp model.Fingerprint, immediate bool) ([]*chunkDesc, labels.Labels, *sync.RWMutex) {
 	var stream *stream
 	var ok bool
@@ -267,7 +457,7 @@ func (i *Ingester) collectChunksToFlush(instance *instance, fp model.Fingerprint
 
 	var result []*chunkDesc
 	for j := range stream.chunks {
-		shouldFlush, reason := i.shouldFlushChunk(&stream.chunks[j])
+		shouldFlush, reason := i.shouldFlushChunk(stream, &stream.chunks[j])
 		if immediate || shouldFlush {
 			// Ensure no more writes happen to this chunk.
 			if !stream.chunks[j].closed {
@@ -286,7 +476,7 @@ func (i *Ingester) collectChunksToFlush(instance *instance, fp model.Fingerprint
 	return result, stream.labels, &stream.chunkMtx
 }
 
-func (i *Ingester) shouldFlushChunk(chunk *chunkDesc) (bool, string) {
+func (i *Ingester) shouldFlushChunk(stream *stream, chunk *chunkDesc) (bool, string) {
 	// Append should close the chunk when the a new one is added.
 	if chunk.closed {
 		if chunk.synced {
@@ -295,11 +485,11 @@ func (i *Ingester) shouldFlushChunk(chunk *chunkDesc) (bool, string) {
 		return true, flushReasonFull
 	}
 
-	if time.Since(chunk.lastUpdated) > i.cfg.MaxChunkIdle {
+	if time.Since(chunk.lastUpdated) > stream.maxChunkIdle() {
 		return true, flushReasonIdle
 	}
 
-	if from, to := chunk.chunk.Bounds(); to.Sub(from) > i.cfg.MaxChunkAge {
+	if from, to := chunk.chunk.Bounds(); to.Sub(from) > stream.maxChunkAge() {
 		return true, flushReasonMaxAge
 	}
 
@@ -347,14 +537,31 @@ func (i *Ingester) flushChunks(ctx context.Context, fp model.Fingerprint, labelP
 		return err
 	}
 
+	wireChunks, err := i.encodeChunks(userID, fp, labelPairs, cs, chunkMtx)
+	if err != nil {
+		return err
+	}
+
+	if err := i.store.Put(ctx, wireChunks); err != nil {
+		return err
+	}
+	flushedChunksStats.Inc(int64(len(wireChunks)))
+
+	i.reportFlushedChunks(userID, cs, wireChunks, chunkMtx)
+	return nil
+}
+
+// encodeChunks closes and encodes the chunks of the stream to flush.
+func (i *Ingester) encodeChunks(userID string, fp model.Fingerprint, labelPairs labels.Labels, cs []*chunkDesc, chunkMtx sync.Locker) ([]chunk.Chunk, error) {
 	labelsBuilder := labels.NewBuilder(labelPairs)
 	labelsBuilder.Set(nameLabel, logsValue)
 	metric := labelsBuilder.Labels()
 
 	wireChunks := make([]chunk.Chunk, len(cs))
+	schemaCfg := chunk.SchemaConfig{Configs: i.store.GetTenantSchemaConfigs(userID)}
 
 	// use anonymous function to make lock releasing simpler.
-	err = func() error {
+	err := func() error {
 		chunkMtx.Lock()
 		defer chunkMtx.Unlock()
 
@@ -364,9 +571,14 @@ func (i *Ingester) flushChunks(ctx context.Context, fp model.Fingerprint, labelP
 				return err
 			}
 			firstTime, lastTime := loki_util.RoundToMilliseconds(c.chunk.Bounds())
+			facade := chunkenc.NewFacade(c.chunk, i.cfg.BlockSize, i.cfg.TargetChunkSize)
+			// the format of the stored chunks is set by the period they start in.
+			if period, err := schemaCfg.SchemaForTime(firstTime); err == nil && period.ChunkFormat == chunk.ChunkFormatParquet {
+				facade = chunkenc.NewParquetFacade(c.chunk, i.cfg.BlockSize, i.cfg.TargetChunkSize)
+			}
 			ch := chunk.NewChunk(
 				userID, fp, metric,
-				chunkenc.NewFacade(c.chunk, i.cfg.BlockSize, i.cfg.TargetChunkSize),
+				facade,
 				firstTime,
 				lastTime,
 			)
@@ -383,15 +595,14 @@ func (i *Ingester) flushChunks(ctx context.Context, fp model.Fingerprint, labelP
 	}()
 
 	if err != nil {
-		return err
-	}
-
-	if err := i.store.Put(ctx, wireChunks); err != nil {
-		return err
+		return nil, err
 	}
-	flushedChunksStats.Inc(int64(len(wireChunks)))
+	return wireChunks, nil
+}
 
-	// Record statistics only when actual put request did not return error.
+// reportFlushedChunks marks the chunks of the stream as flushed, and records their
+// statistics once they have been successfully stored.
+func (i *Ingester) reportFlushedChunks(userID string, cs []*chunkDesc, wireChunks []chunk.Chunk, chunkMtx sync.Locker) {
 	sizePerTenant := chunkSizePerTenant.WithLabelValues(userID)
 	countPerTenant := chunksPerTenant.WithLabelValues(userID)
 
@@ -432,6 +643,4 @@ func (i *Ingester) flushChunks(ctx context.Context, fp model.Fingerprint, labelP
 		flushedChunksAgeStats.Record(time.Since(firstTime).Seconds())
 		flushedChunksLifespanStats.Record(lastTime.Sub(firstTime).Hours())
 	}
-
-	return nil
 }
@@ -26,6 +26,7 @@ import (
 	"github.com/grafana/loki/pkg/logproto"
 	"github.com/grafana/loki/pkg/logql"
 	"github.com/grafana/loki/pkg/logql/log"
+	"github.com/grafana/loki/pkg/notifications"
 	"github.com/grafana/loki/pkg/runtime"
 	"github.com/grafana/loki/pkg/storage"
 	"github.com/grafana/loki/pkg/storage/chunk"
@@ -53,6 +54,40 @@ func TestChunkFlushingIdle(t *testing.T) {
 	store.checkData(t, testData)
 }
 
+func TestChunkFlushingIdleContainers(t *testing.T) {
+	cfg := defaultIngesterTestConfig(t)
+	cfg.FlushCheckPeriod = 20 * time.Millisecond
+	cfg.MaxChunkIdle = 100 * time.Millisecond
+	cfg.RetainPeriod = 500 * time.Millisecond
+	cfg.ChunkContainerMaxChunkSize = 64 * 1024
+	cfg.ChunkContainerMaxSize = 1024 * 1024
+
+	store, ing := newTestStore(t, cfg, nil)
+	defer services.StopAndAwaitTerminated(context.Background(), ing) //nolint:errcheck
+	store.setSchemaConfigs([]chunk.PeriodConfig{{From: chunk.DayTime{Time: 0}, Schema: "v12"}})
+	testData := pushTestSamples(t, ing)
+
+	// wait beyond idle time so samples flush
+	time.Sleep(cfg.MaxChunkIdle * 2)
+	store.checkData(t, testData)
+
+	// the chunks of the idle streams of each tenant are packed in a single container.
+	for userID := range testData {
+		chunks := store.getChunksForUser(userID)
+		require.Len(t, chunks, numSeries)
+		for _, c := range chunks {
+			require.True(t, c.Contained())
+			require.Equal(t, chunks[0].Container.ID, c.Container.ID)
+		}
+		contained, err := chunk.EncodeContainer(chunks)
+		require.NoError(t, err)
+		for _, c := range chunks {
+			decoded := chunk.Chunk{ChunkRef: c.ChunkRef, Container: c.Container, ChecksumSet: true}
+			require.NoError(t, decoded.DecodeContained(chunk.NewDecodeContext(), contained))
+		}
+	}
+}
+
 func TestChunkFlushingShutdown(t *testing.T) {
 	store, ing := newTestStore(t, defaultIngesterTestConfig(t), nil)
 	testData := pushTestSamples(t, ing)
@@ -238,11 +273,65 @@ func TestFlushMaxAge(t *testing.T) {
 	require.NoError(t, services.StopAndAwaitTerminated(context.Background(), ing))
 }
 
+func TestStreamChunkPolicies(t *testing.T) {
+	limitsCfg := defaultLimitsTestConfig()
+	limitsCfg.StreamChunkPolicies = []validation.StreamChunkPolicy{
+		{Selector: `{job="debug"}`, ChunkIdlePeriod: model.Duration(time.Minute), MaxChunkAge: model.Duration(10 * time.Minute)},
+		// longer than the config, so it doesn't apply.
+		{Selector: `{job="batch"}`, ChunkIdlePeriod: model.Duration(24 * time.Hour)},
+	}
+	require.NoError(t, limitsCfg.Validate())
+	limits, err := validation.NewOverrides(limitsCfg, nil)
+	require.NoError(t, err)
+	limiter := NewLimiter(limits, NilMetrics, &ringCountMock{count: 1}, 1)
+
+	cfg := defaultConfig()
+	cfg.MaxChunkIdle = time.Hour
+	cfg.MaxChunkAge = 2 * time.Hour
+	ing := &Ingester{cfg: *cfg}
+	inst := newInstance(cfg, "test", limiter, runtime.DefaultTenantConfigs(), noopWAL{}, NilMetrics, &OnceSwitch{}, nil, notifications.Noop)
+
+	now := time.Now()
+	streams := []string{`{job="debug"}`, `{job="batch"}`, `{job="api"}`}
+	for _, lbs := range streams {
+		require.NoError(t, inst.Push(context.Background(), &logproto.PushRequest{Streams: []logproto.Stream{
+			{Labels: lbs, Entries: []logproto.Entry{{Timestamp: now.Add(-15 * time.Minute), Line: "1"}, {Timestamp: now, Line: "2"}}},
+		}}))
+	}
+
+	for _, tc := range []struct {
+		labels       string
+		idle, maxAge time.Duration
+		reason       string
+	}{
+		{labels: `{job="debug"}`, idle: time.Minute, maxAge: 10 * time.Minute, reason: flushReasonMaxAge},
+		{labels: `{job="batch"}`, idle: time.Hour, maxAge: 2 * time.Hour},
+		{labels: `{job="api"}`, idle: time.Hour, maxAge: 2 * time.Hour},
+	} {
+		t.Run(tc.labels, func(t *testing.T) {
+			s, ok := inst.streams.Load(tc.labels)
+			require.True(t, ok)
+			require.Equal(t, tc.idle, s.maxChunkIdle())
+			require.Equal(t, tc.maxAge, s.maxChunkAge())
+
+			shouldFlush, reason := ing.shouldFlushChunk(s, &s.chunks[0])
+			require.Equal(t, tc.reason != "", shouldFlush)
+			require.Equal(t, tc.reason, reason)
+
+			s.chunks[0].lastUpdated = now.Add(-5 * time.Minute)
+			shouldFlush, _ = ing.shouldFlushChunk(s, &s.chunks[0])
+			require.Equal(t, tc.idle < 5*time.Minute || tc.reason != "", shouldFlush)
+		})
+	}
+}
+
 type testStore struct {
 	mtx sync.Mutex
 	// Chunks keyed by userID.
 	chunks map[string][]chunk.Chunk
 	onPut  func(ctx context.Context, chunks []chunk.Chunk) error
+
+	schemaConfigs []chunk.PeriodConfig
 }
 
 // Note: the ingester New() function creates it's own WAL first which we then override if specified.
@@ -336,7 +425,19 @@ func (s *testStore) GetChunkRefs(ctx context.Context, userID string, from, throu
 }
 
 func (s *testStore) GetSchemaConfigs() []chunk.PeriodConfig {
-	return nil
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.schemaConfigs
+}
+
+func (s *testStore) GetTenantSchemaConfigs(_ string) []chunk.PeriodConfig {
+	return s.GetSchemaConfigs()
+}
+
+func (s *testStore) setSchemaConfigs(configs []chunk.PeriodConfig) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.schemaConfigs = configs
 }
 
 func (s *testStore) Stop() {}
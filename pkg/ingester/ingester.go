@@ -18,6 +18,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/labels"
+	"go.uber.org/atomic"
 	"google.golang.org/grpc/health/grpc_health_v1"
 
 	"github.com/grafana/loki/pkg/chunkenc"
@@ -28,10 +29,10 @@ import (
 	"github.com/grafana/loki/pkg/logql"
 	"github.com/grafana/loki/pkg/logql/syntax"
 	"github.com/grafana/loki/pkg/logqlmodel/stats"
+	"github.com/grafana/loki/pkg/notifications"
 	"github.com/grafana/loki/pkg/runtime"
 	"github.com/grafana/loki/pkg/storage"
 	"github.com/grafana/loki/pkg/storage/chunk"
-	"github.com/grafana/loki/pkg/storage/stores/shipper"
 	"github.com/grafana/loki/pkg/tenant"
 	"github.com/grafana/loki/pkg/usagestats"
 	"github.com/grafana/loki/pkg/util"
@@ -76,9 +77,14 @@ type Config struct {
 	TargetChunkSize     int               `yaml:"chunk_target_size"`
 	ChunkEncoding       string            `yaml:"chunk_encoding"`
 	parsedEncoding      chunkenc.Encoding `yaml:"-"` // placeholder for validated encoding
+	ChunkDeltaEncoding  bool              `yaml:"chunk_delta_encoding"`
 	MaxChunkAge         time.Duration     `yaml:"max_chunk_age"`
 	AutoForgetUnhealthy bool              `yaml:"autoforget_unhealthy"`
 
+	// Packing of the small chunks of idle streams in containers.
+	ChunkContainerMaxChunkSize int `yaml:"chunk_container_max_chunk_size"`
+	ChunkContainerMaxSize      int `yaml:"chunk_container_max_size"`
+
 	// Synchronization settings. Used to make sure that ingesters cut their chunks at the same moments.
 	SyncPeriod         time.Duration `yaml:"sync_period"`
 	SyncMinUtilization float64       `yaml:"sync_min_utilization"`
@@ -100,6 +106,8 @@ type Config struct {
 	IndexShards int `yaml:"index_shards"`
 
 	MaxDroppedStreams int `yaml:"max_dropped_streams"`
+
+	QueryDecompressionConcurrency int `yaml:"query_decompression_concurrency"`
 }
 
 // RegisterFlags registers the flags.
@@ -116,14 +124,18 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&cfg.BlockSize, "ingester.chunks-block-size", 256*1024, "")
 	f.IntVar(&cfg.TargetChunkSize, "ingester.chunk-target-size", 1572864, "") // 1.5 MB
 	f.StringVar(&cfg.ChunkEncoding, "ingester.chunk-encoding", chunkenc.EncGZIP.String(), fmt.Sprintf("The algorithm to use for compressing chunk. (%s)", chunkenc.SupportedEncoding()))
+	f.BoolVar(&cfg.ChunkDeltaEncoding, "ingester.chunk-delta-encoding", false, "Store timestamps as delta-of-delta varints and front-code lines within chunk blocks before compressing them. Improves the compression ratio of structured logs, but the chunks can't be read by older versions of Loki.")
 	f.DurationVar(&cfg.SyncPeriod, "ingester.sync-period", 0, "How often to cut chunks to synchronize ingesters.")
 	f.Float64Var(&cfg.SyncMinUtilization, "ingester.sync-min-utilization", 0, "Minimum utilization of chunk when doing synchronization.")
 	f.IntVar(&cfg.MaxReturnedErrors, "ingester.max-ignored-stream-errors", 10, "Maximum number of ignored stream errors to return. 0 to return all errors.")
 	f.DurationVar(&cfg.MaxChunkAge, "ingester.max-chunk-age", 2*time.Hour, "Maximum chunk age before flushing.")
+	f.IntVar(&cfg.ChunkContainerMaxChunkSize, "ingester.chunk-container-max-chunk-size", 0, "Maximum size in bytes of the chunks of idle streams packed together in a single object when flushed, to write fewer objects for sparse tenants. 0 to disable.")
+	f.IntVar(&cfg.ChunkContainerMaxSize, "ingester.chunk-container-max-size", 4*1024*1024, "Maximum size in bytes of an object packing the chunks of idle streams.")
 	f.DurationVar(&cfg.QueryStoreMaxLookBackPeriod, "ingester.query-store-max-look-back-period", 0, "How far back should an ingester be allowed to query the store for data, for use only with boltdb-shipper index and filesystem object store. -1 for infinite.")
 	f.BoolVar(&cfg.AutoForgetUnhealthy, "ingester.autoforget-unhealthy", false, "Enable to remove unhealthy ingesters from the ring after `ring.kvstore.heartbeat_timeout`")
 	f.IntVar(&cfg.IndexShards, "ingester.index-shards", index.DefaultIndexShards, "Shard factor used in the ingesters for the in process reverse index. This MUST be evenly divisible by ALL schema shard factors or Loki will not start.")
 	f.IntVar(&cfg.MaxDroppedStreams, "ingester.tailer.max-dropped-streams", 10, "Maximum number of dropped streams to keep in memory during tailing")
+	f.IntVar(&cfg.QueryDecompressionConcurrency, "ingester.query-decompression-concurrency", 0, "Maximum number of query batches decompressed concurrently, shared fairly across tenants. 0 means unlimited.")
 }
 
 func (cfg *Config) Validate() error {
@@ -145,6 +157,10 @@ func (cfg *Config) Validate() error {
 		return fmt.Errorf("invalid ingester index shard factor: %d", cfg.IndexShards)
 	}
 
+	if cfg.ChunkContainerMaxChunkSize > 0 && cfg.ChunkContainerMaxSize < cfg.ChunkContainerMaxChunkSize {
+		return fmt.Errorf("invalid chunk container max size %d, smaller than the chunk container max chunk size %d", cfg.ChunkContainerMaxSize, cfg.ChunkContainerMaxChunkSize)
+	}
+
 	return nil
 }
 
@@ -159,6 +175,7 @@ type ChunkStore interface {
 	SelectSamples(ctx context.Context, req logql.SelectSampleParams) (iter.SampleIterator, error)
 	GetChunkRefs(ctx context.Context, userID string, from, through model.Time, matchers ...*labels.Matcher) ([][]chunk.Chunk, []*chunk.Fetcher, error)
 	GetSchemaConfigs() []chunk.PeriodConfig
+	GetTenantSchemaConfigs(userID string) []chunk.PeriodConfig
 }
 
 // Interface is an interface for the Ingester
@@ -171,6 +188,7 @@ type Interface interface {
 	CheckReady(ctx context.Context) error
 	FlushHandler(w http.ResponseWriter, _ *http.Request)
 	ShutdownHandler(w http.ResponseWriter, r *http.Request)
+	PrepareRestartHandler(w http.ResponseWriter, r *http.Request)
 	GetOrCreateInstance(instanceID string) *instance
 }
 
@@ -187,11 +205,13 @@ type Ingester struct {
 	instances    map[string]*instance
 	readonly     bool
 
+	// Set when the ingester is prepared for a restart, see PrepareRestartHandler.
+	restartPrepared *atomic.Bool
+
 	lifecycler        *ring.Lifecycler
 	lifecyclerWatcher *services.FailureWatcher
 
-	store           ChunkStore
-	periodicConfigs []chunk.PeriodConfig
+	store ChunkStore
 
 	loopDone    sync.WaitGroup
 	loopQuit    chan struct{}
@@ -204,6 +224,9 @@ type Ingester struct {
 
 	limiter *Limiter
 
+	// Bounds the number of query batches decompressed concurrently, nil if unlimited.
+	decompressionScheduler *decompressionScheduler
+
 	// Denotes whether the ingester should flush on shutdown.
 	// Currently only used by the WAL to signal when the disk is full.
 	flushOnShutdownSwitch *OnceSwitch
@@ -216,6 +239,7 @@ type Ingester struct {
 	wal WAL
 
 	chunkFilter storage.RequestChunkFilterer
+	notifier    notifications.Notifier
 }
 
 // New makes a new Ingester.
@@ -237,13 +261,15 @@ func New(cfg Config, clientConfig client.Config, store ChunkStore, limits *valid
 		tenantConfigs:         configs,
 		instances:             map[string]*instance{},
 		store:                 store,
-		periodicConfigs:       store.GetSchemaConfigs(),
 		loopQuit:              make(chan struct{}),
 		flushQueues:           make([]*util.PriorityQueue, cfg.ConcurrentFlushes),
 		tailersQuit:           make(chan struct{}),
 		metrics:               metrics,
 		flushOnShutdownSwitch: &OnceSwitch{},
+		notifier:              notifications.Noop,
+		restartPrepared:       atomic.NewBool(false),
 	}
+	i.decompressionScheduler = newDecompressionScheduler(cfg.QueryDecompressionConcurrency, metrics)
 	i.replayController = newReplayController(metrics, cfg.WAL, &replayFlusher{i})
 
 	if cfg.WAL.Enabled {
@@ -291,6 +317,11 @@ func (i *Ingester) SetChunkFilterer(chunkFilter storage.RequestChunkFilterer) {
 	i.chunkFilter = chunkFilter
 }
 
+// SetNotifier sets the notifier of the events of the ingester, e.g. tenants hitting the stream limit.
+func (i *Ingester) SetNotifier(notifier notifications.Notifier) {
+	i.notifier = notifier
+}
+
 // setupAutoForget looks for ring status if `AutoForgetUnhealthy` is enabled
 // when enabled, unhealthy ingesters that reach `ring.kvstore.heartbeat_timeout` are removed from the ring every `HeartbeatPeriod`
 func (i *Ingester) setupAutoForget() {
@@ -516,7 +547,8 @@ func (i *Ingester) loop() {
 	for {
 		select {
 		case <-flushTicker.C:
-			i.sweepUsers(false, true)
+			// Chunks of an ingester prepared for a restart are flushed right away.
+			i.sweepUsers(i.restartPrepared.Load(), true)
 
 		case <-i.loopQuit:
 			return
@@ -560,7 +592,7 @@ func (i *Ingester) GetOrCreateInstance(instanceID string) *instance {
 	defer i.instancesMtx.Unlock()
 	inst, ok = i.instances[instanceID]
 	if !ok {
-		inst = newInstance(&i.cfg, instanceID, i.limiter, i.tenantConfigs, i.wal, i.metrics, i.flushOnShutdownSwitch, i.chunkFilter)
+		inst = newInstance(&i.cfg, instanceID, i.limiter, i.tenantConfigs, i.wal, i.metrics, i.flushOnShutdownSwitch, i.chunkFilter, i.notifier)
 		i.instances[instanceID] = inst
 		activeTenantsStats.Set(int64(len(i.instances)))
 	}
@@ -600,6 +632,7 @@ func (i *Ingester) Query(req *logproto.QueryRequest, queryServer logproto.Querie
 		it = iter.NewMergeEntryIterator(ctx, []iter.EntryIterator{it, storeItr}, req.Direction)
 	}
 
+	it = newScheduledEntryIterator(ctx, i.decompressionScheduler, instanceID, it)
 	defer errUtil.LogErrorWithContext(ctx, "closing iterator", it.Close)
 
 	return sendBatches(ctx, it, queryServer, req.Limit)
@@ -637,24 +670,27 @@ func (i *Ingester) QuerySample(req *logproto.SampleQueryRequest, queryServer log
 		it = iter.NewMergeSampleIterator(ctx, []iter.SampleIterator{it, storeItr})
 	}
 
+	it = newScheduledSampleIterator(ctx, i.decompressionScheduler, instanceID, it)
 	defer errUtil.LogErrorWithContext(ctx, "closing iterator", it.Close)
 
 	return sendSampleBatches(ctx, it, queryServer)
 }
 
-// boltdbShipperMaxLookBack returns a max look back period only if active index type is boltdb-shipper.
-// max look back is limited to from time of boltdb-shipper config.
-// It considers previous periodic config's from time if that also has index type set to boltdb-shipper.
-func (i *Ingester) boltdbShipperMaxLookBack() time.Duration {
-	activePeriodicConfigIndex := storage.ActivePeriodConfig(i.periodicConfigs)
-	activePeriodicConfig := i.periodicConfigs[activePeriodicConfigIndex]
-	if activePeriodicConfig.IndexType != shipper.BoltDBShipperType {
+// boltdbShipperMaxLookBack returns a max look back period only if active index type of the tenant keeps the index in the object storage,
+// like boltdb-shipper. max look back is limited to from time of that config.
+// It considers previous periodic config's from time if that also keeps the index in the object storage.
+func (i *Ingester) boltdbShipperMaxLookBack(userID string) time.Duration {
+	// the periods of the store can be extended at runtime.
+	periodicConfigs := i.store.GetTenantSchemaConfigs(userID)
+	activePeriodicConfigIndex := storage.ActivePeriodConfig(periodicConfigs)
+	activePeriodicConfig := periodicConfigs[activePeriodicConfigIndex]
+	if !storage.IsObjectStorageIndex(activePeriodicConfig.IndexType) {
 		return 0
 	}
 
 	startTime := activePeriodicConfig.From
-	if activePeriodicConfigIndex != 0 && i.periodicConfigs[activePeriodicConfigIndex-1].IndexType == shipper.BoltDBShipperType {
-		startTime = i.periodicConfigs[activePeriodicConfigIndex-1].From
+	if activePeriodicConfigIndex != 0 && storage.IsObjectStorageIndex(periodicConfigs[activePeriodicConfigIndex-1].IndexType) {
+		startTime = periodicConfigs[activePeriodicConfigIndex-1].From
 	}
 
 	maxLookBack := time.Since(startTime.Time.Time())
@@ -668,7 +704,7 @@ func (i *Ingester) GetChunkIDs(ctx context.Context, req *logproto.GetChunkIDsReq
 		return nil, err
 	}
 
-	boltdbShipperMaxLookBack := i.boltdbShipperMaxLookBack()
+	boltdbShipperMaxLookBack := i.boltdbShipperMaxLookBack(orgID)
 	if boltdbShipperMaxLookBack == 0 {
 		return &logproto.GetChunkIDsResponse{}, nil
 	}
@@ -691,7 +727,7 @@ func (i *Ingester) GetChunkIDs(ctx context.Context, req *logproto.GetChunkIDsReq
 
 	// todo (Callum) ingester should maybe store the whole schema config?
 	s := chunk.SchemaConfig{
-		Configs: i.periodicConfigs,
+		Configs: i.store.GetTenantSchemaConfigs(orgID),
 	}
 
 	// build the response
@@ -723,7 +759,7 @@ func (i *Ingester) Label(ctx context.Context, req *logproto.LabelRequest) (*logp
 	}
 
 	// Only continue if the active index type is boltdb-shipper or QueryStore flag is true.
-	boltdbShipperMaxLookBack := i.boltdbShipperMaxLookBack()
+	boltdbShipperMaxLookBack := i.boltdbShipperMaxLookBack(userID)
 	if boltdbShipperMaxLookBack == 0 && !i.cfg.QueryStore {
 		return resp, nil
 	}
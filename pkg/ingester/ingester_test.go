@@ -259,8 +259,10 @@ func TestIngesterStreamLimitExceeded(t *testing.T) {
 }
 
 type mockStore struct {
-	mtx    sync.Mutex
-	chunks map[string][]chunk.Chunk
+	mtx                 sync.Mutex
+	chunks              map[string][]chunk.Chunk
+	periodConfigs       []chunk.PeriodConfig
+	tenantPeriodConfigs map[string][]chunk.PeriodConfig
 }
 
 func (s *mockStore) Put(ctx context.Context, chunks []chunk.Chunk) error {
@@ -289,7 +291,14 @@ func (s *mockStore) GetSeries(ctx context.Context, req logql.SelectLogParams) ([
 }
 
 func (s *mockStore) GetSchemaConfigs() []chunk.PeriodConfig {
-	return nil
+	return s.periodConfigs
+}
+
+func (s *mockStore) GetTenantSchemaConfigs(userID string) []chunk.PeriodConfig {
+	if configs, ok := s.tenantPeriodConfigs[userID]; ok {
+		return configs
+	}
+	return s.periodConfigs
 }
 
 func (s *mockStore) SetChunkFilterer(_ storage.RequestChunkFilterer) {
@@ -308,11 +317,11 @@ func (s *mockStore) LabelValuesForMetricName(ctx context.Context, userID string,
 	return []string{"val1", "val2"}, nil
 }
 
-func (s *mockStore) LabelNamesForMetricName(ctx context.Context, userID string, from, through model.Time, metricName string) ([]string, error) {
+func (s *mockStore) LabelNamesForMetricName(ctx context.Context, userID string, from, through model.Time, metricName string, matchers ...*labels.Matcher) ([]string, error) {
 	return nil, nil
 }
 
-func (s *mockStore) GetChunkFetcher(tm model.Time) *chunk.Fetcher {
+func (s *mockStore) GetChunkFetcher(_ string, tm model.Time) *chunk.Fetcher {
 	return nil
 }
 
@@ -327,10 +336,20 @@ type mockQuerierServer struct {
 func (*mockQuerierServer) SetTrailer(metadata.MD) {}
 
 func (m *mockQuerierServer) Send(resp *logproto.QueryResponse) error {
-	m.resps = append(m.resps, resp)
+	m.resps = append(m.resps, copyQueryResponse(resp))
 	return nil
 }
 
+// copyQueryResponse copies a response sent, as the gRPC server marshals it: it is reused once sent.
+func copyQueryResponse(resp *logproto.QueryResponse) *logproto.QueryResponse {
+	sent := &logproto.QueryResponse{Stats: resp.Stats, Streams: make([]logproto.Stream, len(resp.Streams))}
+	for i, s := range resp.Streams {
+		sent.Streams[i] = s
+		sent.Streams[i].Entries = append([]logproto.Entry(nil), s.Entries...)
+	}
+	return sent
+}
+
 func (m *mockQuerierServer) Context() context.Context {
 	return m.ctx
 }
@@ -474,11 +493,21 @@ func TestIngester_boltdbShipperMaxLookBack(t *testing.T) {
 		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
-			ingester := Ingester{periodicConfigs: tc.periodicConfigs}
-			mlb := ingester.boltdbShipperMaxLookBack()
+			ingester := Ingester{store: &mockStore{periodConfigs: tc.periodicConfigs}}
+			mlb := ingester.boltdbShipperMaxLookBack("fake")
 			require.InDelta(t, tc.expectedMaxLookBack, mlb, float64(time.Second))
 		})
 	}
+
+	// the periods of a tenant having its own ones are used for it.
+	ingester := Ingester{store: &mockStore{
+		periodConfigs: []chunk.PeriodConfig{{From: chunk.DayTime{Time: now.Add(-24 * time.Hour)}, IndexType: "bigtable"}},
+		tenantPeriodConfigs: map[string][]chunk.PeriodConfig{
+			"tenant": {{From: chunk.DayTime{Time: now.Add(-48 * time.Hour)}, IndexType: "tsdb"}},
+		},
+	}}
+	require.Zero(t, ingester.boltdbShipperMaxLookBack("fake"))
+	require.InDelta(t, time.Since(now.Add(-48*time.Hour).Time()), ingester.boltdbShipperMaxLookBack("tenant"), float64(time.Second))
 }
 
 func TestValidate(t *testing.T) {
@@ -22,8 +22,10 @@ import (
 	"github.com/grafana/loki/pkg/iter"
 	"github.com/grafana/loki/pkg/logproto"
 	"github.com/grafana/loki/pkg/logql"
+	"github.com/grafana/loki/pkg/logql/log"
 	"github.com/grafana/loki/pkg/logql/syntax"
 	"github.com/grafana/loki/pkg/logqlmodel/stats"
+	"github.com/grafana/loki/pkg/notifications"
 	"github.com/grafana/loki/pkg/querier/astmapper"
 	"github.com/grafana/loki/pkg/runtime"
 	"github.com/grafana/loki/pkg/storage"
@@ -88,9 +90,10 @@ type instance struct {
 	metrics *ingesterMetrics
 
 	chunkFilter storage.RequestChunkFilterer
+	notifier    notifications.Notifier
 }
 
-func newInstance(cfg *Config, instanceID string, limiter *Limiter, configs *runtime.TenantConfigs, wal WAL, metrics *ingesterMetrics, flushOnShutdownSwitch *OnceSwitch, chunkFilter storage.RequestChunkFilterer) *instance {
+func newInstance(cfg *Config, instanceID string, limiter *Limiter, configs *runtime.TenantConfigs, wal WAL, metrics *ingesterMetrics, flushOnShutdownSwitch *OnceSwitch, chunkFilter storage.RequestChunkFilterer, notifier notifications.Notifier) *instance {
 	i := &instance{
 		cfg:        cfg,
 		streams:    newStreamsMap(),
@@ -110,6 +113,7 @@ func newInstance(cfg *Config, instanceID string, limiter *Limiter, configs *runt
 		flushOnShutdownSwitch: flushOnShutdownSwitch,
 
 		chunkFilter: chunkFilter,
+		notifier:    notifier,
 	}
 	i.mapper = newFPMapper(i.getLabelsFromFingerprint)
 	return i
@@ -218,6 +222,9 @@ func (i *instance) createStream(pushReqStream logproto.Stream, record *WALRecord
 			bytes += len(e.Line)
 		}
 		validation.DiscardedBytes.WithLabelValues(validation.StreamLimit, i.instanceID).Add(float64(bytes))
+		i.notifier.Notify(notifications.NewEvent(notifications.IngestionLimitReached, i.instanceID, map[string]string{
+			"limit": validation.StreamLimit,
+		}))
 		return nil, httpgrpc.Errorf(http.StatusTooManyRequests, validation.StreamLimitErrorMsg)
 	}
 
@@ -237,6 +244,8 @@ func (i *instance) createStream(pushReqStream logproto.Stream, record *WALRecord
 
 	sortedLabels := i.index.Add(logproto.FromLabelsToLabelAdapters(labels), fp)
 	s := newStream(i.cfg, i.limiter, i.instanceID, fp, sortedLabels, i.limiter.UnorderedWrites(i.instanceID), i.metrics)
+	s.policyChunkIdle, s.policyChunkAge = i.limiter.ChunkPolicy(i.instanceID, sortedLabels)
+	s.outOfOrderWindow = i.limiter.OutOfOrderTimeWindow(i.instanceID)
 
 	// record will be nil when replaying the wal (we don't want to rewrite wal entries as we replay them).
 	if record != nil {
@@ -268,6 +277,8 @@ func (i *instance) createStream(pushReqStream logproto.Stream, record *WALRecord
 func (i *instance) createStreamByFP(ls labels.Labels, fp model.Fingerprint) *stream {
 	sortedLabels := i.index.Add(logproto.FromLabelsToLabelAdapters(ls), fp)
 	s := newStream(i.cfg, i.limiter, i.instanceID, fp, sortedLabels, i.limiter.UnorderedWrites(i.instanceID), i.metrics)
+	s.policyChunkIdle, s.policyChunkAge = i.limiter.ChunkPolicy(i.instanceID, sortedLabels)
+	s.outOfOrderWindow = i.limiter.OutOfOrderTimeWindow(i.instanceID)
 
 	i.streamsCreatedTotal.Inc()
 	memoryStreams.WithLabelValues(i.instanceID).Inc()
@@ -323,6 +334,7 @@ func (i *instance) Query(ctx context.Context, req logql.SelectLogParams) (iter.E
 	}
 
 	stats := stats.FromContext(ctx)
+	pipeline = log.PipelineWithParseStats(pipeline, stats)
 	var iters []iter.EntryIterator
 
 	shard, err := parseShardFromRequest(req.Shards)
@@ -361,6 +373,7 @@ func (i *instance) QuerySample(ctx context.Context, req logql.SelectSampleParams
 	}
 
 	stats := stats.FromContext(ctx)
+	extractor = log.SampleExtractorWithParseStats(extractor, stats)
 	var iters []iter.SampleIterator
 
 	var shard *astmapper.ShardAnnotation
@@ -689,15 +702,20 @@ func sendBatches(ctx context.Context, i iter.EntryIterator, queryServer QuerierQ
 		for !isDone(ctx) {
 			batch, size, err := iter.ReadBatch(i, queryBatchSize)
 			if err != nil {
+				iter.PutBatch(batch)
 				return err
 			}
 			if len(batch.Streams) == 0 {
+				iter.PutBatch(batch)
 				return nil
 			}
 			stats.AddIngesterBatch(int64(size))
 			batch.Stats = stats.Ingester()
 
-			if err := queryServer.Send(batch); err != nil {
+			// the batch is marshalled when sent, and released for the next ones.
+			err = queryServer.Send(batch)
+			iter.PutBatch(batch)
+			if err != nil {
 				return err
 			}
 
@@ -711,18 +729,22 @@ func sendBatches(ctx context.Context, i iter.EntryIterator, queryServer QuerierQ
 	for sent < limit && !isDone(queryServer.Context()) {
 		batch, batchSize, err := iter.ReadBatch(i, math.MinUint32(queryBatchSize, limit-sent))
 		if err != nil {
+			iter.PutBatch(batch)
 			return err
 		}
 		sent += batchSize
 
 		if len(batch.Streams) == 0 {
+			iter.PutBatch(batch)
 			return nil
 		}
 
 		stats.AddIngesterBatch(int64(batchSize))
 		batch.Stats = stats.Ingester()
 
-		if err := queryServer.Send(batch); err != nil {
+		err = queryServer.Send(batch)
+		iter.PutBatch(batch)
+		if err != nil {
 			return err
 		}
 		stats.Reset()
@@ -735,16 +757,21 @@ func sendSampleBatches(ctx context.Context, it iter.SampleIterator, queryServer
 	for !isDone(ctx) {
 		batch, size, err := iter.ReadSampleBatch(it, queryBatchSampleSize)
 		if err != nil {
+			iter.PutSampleBatch(batch)
 			return err
 		}
 		if len(batch.Series) == 0 {
+			iter.PutSampleBatch(batch)
 			return nil
 		}
 
 		stats.AddIngesterBatch(int64(size))
 		batch.Stats = stats.Ingester()
 
-		if err := queryServer.Send(batch); err != nil {
+		// the batch is marshalled when sent, and released for the next ones.
+		err = queryServer.Send(batch)
+		iter.PutSampleBatch(batch)
+		if err != nil {
 			return err
 		}
 
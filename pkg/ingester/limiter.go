@@ -6,6 +6,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/prometheus/prometheus/model/labels"
 	"golang.org/x/time/rate"
 
 	"github.com/grafana/loki/pkg/validation"
@@ -67,6 +68,22 @@ func (l *Limiter) UnorderedWrites(userID string) bool {
 	return l.limits.UnorderedWrites(userID)
 }
 
+// OutOfOrderTimeWindow returns how far behind the newest entry of a stream its entries are accepted, zero when unset.
+func (l *Limiter) OutOfOrderTimeWindow(userID string) time.Duration {
+	return l.limits.OutOfOrderTimeWindow(userID)
+}
+
+// ChunkPolicy returns the chunk idle period and the max chunk age of the first stream chunk policy
+// of the tenant matching the labels, zero when unset.
+func (l *Limiter) ChunkPolicy(userID string, lbs labels.Labels) (idle, maxAge time.Duration) {
+	for _, policy := range l.limits.StreamChunkPolicies(userID) {
+		if policy.Matches(lbs) {
+			return time.Duration(policy.ChunkIdlePeriod), time.Duration(policy.MaxChunkAge)
+		}
+	}
+	return 0, 0
+}
+
 // AssertMaxStreamsPerUser ensures limit has not been reached compared to the current
 // number of streams in input and returns an error if so.
 func (l *Limiter) AssertMaxStreamsPerUser(userID string, streams int) error {
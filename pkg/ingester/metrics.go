@@ -23,6 +23,8 @@ type ingesterMetrics struct {
 	walCorruptionsTotal     *prometheus.CounterVec
 	walLoggedBytesTotal     prometheus.Counter
 	walRecordsLogged        prometheus.Counter
+	walFsyncDuration        prometheus.Histogram
+	walFsyncFailures        prometheus.Counter
 
 	recoveredStreamsTotal prometheus.Counter
 	recoveredChunksTotal  prometheus.Counter
@@ -35,6 +37,9 @@ type ingesterMetrics struct {
 	limiterEnabled prometheus.Gauge
 
 	autoForgetUnhealthyIngestersTotal prometheus.Counter
+
+	queryDecompressionQueueLength prometheus.Gauge
+	queryDecompressionWaitSeconds prometheus.Histogram
 }
 
 // setRecoveryBytesInUse bounds the bytes reports to >= 0.
@@ -104,6 +109,15 @@ func newIngesterMetrics(r prometheus.Registerer) *ingesterMetrics {
 			Name: "loki_ingester_wal_records_logged_total",
 			Help: "Total number of WAL records logged.",
 		}),
+		walFsyncDuration: promauto.With(r).NewHistogram(prometheus.HistogramOpts{
+			Name:    "loki_ingester_wal_fsync_duration_seconds",
+			Help:    "Time taken to fsync the active WAL segment with the interval and always fsync policies.",
+			Buckets: prometheus.ExponentialBuckets(0.0005, 4, 8),
+		}),
+		walFsyncFailures: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Name: "loki_ingester_wal_fsync_failures_total",
+			Help: "Total number of failures to fsync the active WAL segment.",
+		}),
 		checkpointLoggedBytesTotal: promauto.With(r).NewCounter(prometheus.CounterOpts{
 			Name: "loki_ingester_checkpoint_logged_bytes_total",
 			Help: "Total number of bytes written to disk for checkpointing.",
@@ -148,5 +162,14 @@ func newIngesterMetrics(r prometheus.Registerer) *ingesterMetrics {
 			Name: "loki_ingester_autoforget_unhealthy_ingesters_total",
 			Help: "Total number of ingesters automatically forgotten",
 		}),
+		queryDecompressionQueueLength: promauto.With(r).NewGauge(prometheus.GaugeOpts{
+			Name: "loki_ingester_query_decompression_queue_length",
+			Help: "Number of query batches waiting for a decompression slot.",
+		}),
+		queryDecompressionWaitSeconds: promauto.With(r).NewHistogram(prometheus.HistogramOpts{
+			Name:    "loki_ingester_query_decompression_wait_seconds",
+			Help:    "Time spent by query batches waiting for a decompression slot.",
+			Buckets: prometheus.DefBuckets,
+		}),
 	}
 }
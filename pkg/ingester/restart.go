@@ -0,0 +1,110 @@
+package ingester
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/services"
+
+	util_log "github.com/grafana/loki/pkg/util/log"
+)
+
+// States of the ingester reported by the PrepareRestartHandler.
+const (
+	restartStateServing  = "serving"
+	restartStateFlushing = "flushing"
+	restartStateReady    = "ready"
+)
+
+// RestartStatus is the status of an ingester prepared for a restart.
+type RestartStatus struct {
+	ID              string `json:"id"`
+	Zone            string `json:"zone"`
+	State           string `json:"state"`
+	ReadOnly        bool   `json:"read_only"`
+	UnflushedChunks int    `json:"unflushed_chunks"`
+}
+
+// PrepareRestartHandler prepares the ingester to be restarted without handing
+// its chunks over, e.g. while rolling out the ingesters of a zone:
+//   - POST stops accepting writes and flushes all the chunks, the ingester
+//     keeps serving queries.
+//   - GET returns the restart status, with a 200 status code once all
+//     chunks are flushed and the ingester can be restarted, 503 otherwise.
+//   - DELETE accepts writes again.
+func (i *Ingester) PrepareRestartHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && i.State() != services.Running {
+		http.Error(w, "ingester isn't running", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		level.Info(util_log.Logger).Log("msg", "preparing ingester restart, stopping accepting writes")
+		i.setReadOnly(true)
+		i.restartPrepared.Store(true)
+		i.sweepUsers(true, true)
+	case http.MethodDelete:
+		level.Info(util_log.Logger).Log("msg", "cancelling ingester restart, accepting writes")
+		i.restartPrepared.Store(false)
+		i.setReadOnly(false)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	status := i.restartStatus()
+	code := http.StatusOK
+	if status.State != restartStateReady {
+		code = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		level.Error(util_log.Logger).Log("msg", "error writing restart status", "err", err)
+	}
+}
+
+func (i *Ingester) restartStatus() RestartStatus {
+	status := RestartStatus{
+		ID:       i.lifecycler.ID,
+		Zone:     i.lifecycler.Zone,
+		State:    restartStateServing,
+		ReadOnly: i.readonly,
+	}
+	if !i.restartPrepared.Load() {
+		return status
+	}
+
+	status.UnflushedChunks = i.unflushedChunks()
+	status.State = restartStateFlushing
+	if status.UnflushedChunks == 0 {
+		status.State = restartStateReady
+	}
+	return status
+}
+
+func (i *Ingester) setReadOnly(readonly bool) {
+	i.instancesMtx.Lock()
+	defer i.instancesMtx.Unlock()
+
+	i.readonly = readonly
+}
+
+// unflushedChunks returns the number of chunks not flushed yet.
+func (i *Ingester) unflushedChunks() int {
+	var count int
+	for _, instance := range i.getInstances() {
+		_ = instance.streams.ForEach(func(s *stream) (bool, error) {
+			s.chunkMtx.RLock()
+			defer s.chunkMtx.RUnlock()
+			for _, c := range s.chunks {
+				if c.flushed.IsZero() {
+					count++
+				}
+			}
+			return true, nil
+		})
+	}
+	return count
+}
@@ -0,0 +1,68 @@
+package ingester
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grafana/dskit/services"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+	"golang.org/x/net/context"
+
+	"github.com/grafana/loki/pkg/logproto"
+)
+
+func TestPrepareRestartHandler(t *testing.T) {
+	cfg := defaultIngesterTestConfig(t)
+	cfg.FlushCheckPeriod = 20 * time.Millisecond
+	cfg.LifecyclerConfig.Zone = "zone-a"
+
+	store, ing := newTestStore(t, cfg, nil)
+	defer services.StopAndAwaitTerminated(context.Background(), ing) //nolint:errcheck
+
+	testData := pushTestSamples(t, ing)
+
+	call := func(method string) (int, RestartStatus) {
+		w := httptest.NewRecorder()
+		ing.PrepareRestartHandler(w, httptest.NewRequest(method, "/ingester/prepare_restart", nil))
+
+		var status RestartStatus
+		if w.Code != http.StatusNoContent {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+		}
+		return w.Code, status
+	}
+
+	code, status := call(http.MethodGet)
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.Equal(t, RestartStatus{ID: "localhost", Zone: "zone-a", State: restartStateServing}, status)
+
+	_, status = call(http.MethodPost)
+	require.True(t, status.ReadOnly)
+	require.NotEqual(t, restartStateServing, status.State)
+
+	// The ingester is ready once all chunks are flushed.
+	require.Eventually(t, func() bool {
+		code, status = call(http.MethodGet)
+		return code == http.StatusOK
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, RestartStatus{ID: "localhost", Zone: "zone-a", State: restartStateReady, ReadOnly: true}, status)
+	store.checkData(t, testData)
+
+	ctx := user.InjectOrgID(context.Background(), "test")
+	req := &logproto.PushRequest{Streams: buildTestStreams(samplesPerSeries)}
+	_, err := ing.Push(ctx, req)
+	require.Equal(t, ErrReadOnly, err)
+
+	// Writes are accepted again once cancelled.
+	code, _ = call(http.MethodDelete)
+	require.Equal(t, http.StatusNoContent, code)
+	_, err = ing.Push(ctx, req)
+	require.NoError(t, err)
+	code, status = call(http.MethodGet)
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.Equal(t, restartStateServing, status.State)
+}
@@ -22,7 +22,9 @@ import (
 	"github.com/grafana/loki/pkg/logqlmodel/stats"
 	"github.com/grafana/loki/pkg/usagestats"
 	"github.com/grafana/loki/pkg/util/flagext"
+	"github.com/grafana/loki/pkg/util/limiter"
 	util_log "github.com/grafana/loki/pkg/util/log"
+	serverutil "github.com/grafana/loki/pkg/util/server"
 	"github.com/grafana/loki/pkg/validation"
 )
 
@@ -100,6 +102,14 @@ type stream struct {
 	entryCt int64
 
 	unorderedWrites bool
+	// how far behind the highest timestamp the unordered writes are accepted,
+	// half the max chunk age when unset.
+	outOfOrderWindow time.Duration
+
+	// chunk idle period and max chunk age of the stream chunk policy matching the stream,
+	// they only apply when shorter than the ones of the config.
+	policyChunkIdle time.Duration
+	policyChunkAge  time.Duration
 }
 
 type chunkDesc struct {
@@ -130,6 +140,30 @@ func newStream(cfg *Config, limits RateLimiterStrategy, tenant string, fp model.
 	}
 }
 
+// maxChunkIdle returns the idle period after which the chunks of the stream are flushed.
+func (s *stream) maxChunkIdle() time.Duration {
+	if s.policyChunkIdle > 0 && s.policyChunkIdle < s.cfg.MaxChunkIdle {
+		return s.policyChunkIdle
+	}
+	return s.cfg.MaxChunkIdle
+}
+
+// maxChunkAge returns the age after which the chunks of the stream are flushed.
+func (s *stream) maxChunkAge() time.Duration {
+	if s.policyChunkAge > 0 && s.policyChunkAge < s.cfg.MaxChunkAge {
+		return s.policyChunkAge
+	}
+	return s.cfg.MaxChunkAge
+}
+
+// unorderedWindow returns how far behind the highest timestamp of the stream the unordered writes are accepted.
+func (s *stream) unorderedWindow() time.Duration {
+	if s.outOfOrderWindow > 0 {
+		return s.outOfOrderWindow
+	}
+	return s.cfg.MaxChunkAge / 2
+}
+
 // consumeChunk manually adds a chunk to the stream that was received during
 // ingester chunk transfer.
 // Must hold chunkMtx
@@ -164,6 +198,9 @@ func (s *stream) setChunks(chunks []Chunk) (bytesAdded, entriesAdded int, err er
 }
 
 func (s *stream) NewChunk() *chunkenc.MemChunk {
+	if s.cfg.ChunkDeltaEncoding {
+		return chunkenc.NewDeltaMemChunk(s.cfg.parsedEncoding, headBlockType(s.unorderedWrites), s.cfg.BlockSize, s.cfg.TargetChunkSize)
+	}
 	return chunkenc.NewMemChunk(s.cfg.parsedEncoding, headBlockType(s.unorderedWrites), s.cfg.BlockSize, s.cfg.TargetChunkSize)
 }
 
@@ -262,8 +299,8 @@ func (s *stream) Push(
 			continue
 		}
 
-		// The validity window for unordered writes is the highest timestamp present minus 1/2 * max-chunk-age.
-		if !isReplay && s.unorderedWrites && !s.highestTs.IsZero() && s.highestTs.Add(-s.cfg.MaxChunkAge/2).After(entries[i].Timestamp) {
+		// The validity window for unordered writes is the highest timestamp present minus the out of order window.
+		if !isReplay && s.unorderedWrites && !s.highestTs.IsZero() && s.highestTs.Add(-s.unorderedWindow()).After(entries[i].Timestamp) {
 			failedEntriesWithError = append(failedEntriesWithError, entryWithError{&entries[i], chunkenc.ErrTooFarBehind})
 			outOfOrderSamples++
 			outOfOrderBytes += len(entries[i].Line)
@@ -363,6 +400,11 @@ func (s *stream) Push(
 
 		fmt.Fprintf(&buf, "total ignored: %d out of %d", len(failedEntriesWithError), len(entries))
 
+		if ok {
+			// Tell the clients how long to wait for the stream limiter to accept the entries it rejected.
+			res := limiter.UsageN(s.limiter.lim, time.Now(), rateLimitedBytes)
+			return bytesAdded, serverutil.ErrorWithHeaders(statusCode, serverutil.RateLimitHeaders(res), "%s", buf.String())
+		}
 		return bytesAdded, httpgrpc.Errorf(statusCode, buf.String())
 	}
 
@@ -19,6 +19,7 @@ import (
 	"github.com/grafana/loki/pkg/logproto"
 	"github.com/grafana/loki/pkg/logql/log"
 	"github.com/grafana/loki/pkg/util/flagext"
+	serverutil "github.com/grafana/loki/pkg/util/server"
 	"github.com/grafana/loki/pkg/validation"
 )
 
@@ -337,6 +338,11 @@ func TestPushRateLimit(t *testing.T) {
 	// Counter should be 2 now since the first line will be deduped.
 	_, err = s.Push(context.Background(), entries, recordPool.GetRecord(), 0, true)
 	require.Contains(t, err.Error(), (&validation.ErrStreamRateLimit{RateLimit: l.PerStreamRateLimit, Labels: s.labelsString, Bytes: flagext.ByteSize(len(entries[1].Line))}).Error())
+
+	// the 10 rejected bytes are available in a second.
+	resp, ok := httpgrpc.HTTPResponseFromError(err)
+	require.True(t, ok)
+	require.Contains(t, resp.Headers, &httpgrpc.Header{Key: serverutil.RetryAfterHeader, Values: []string{"1"}})
 }
 
 func TestReplayAppendIgnoresValidityWindow(t *testing.T) {
@@ -384,6 +390,41 @@ func TestReplayAppendIgnoresValidityWindow(t *testing.T) {
 
 }
 
+func TestOutOfOrderTimeWindow(t *testing.T) {
+	limits, err := validation.NewOverrides(defaultLimitsTestConfig(), nil)
+	require.NoError(t, err)
+	limiter := NewLimiter(limits, NilMetrics, &ringCountMock{count: 1}, 1)
+
+	cfg := defaultConfig()
+	cfg.MaxChunkAge = time.Minute
+
+	s := newStream(cfg, limiter, "fake", model.Fingerprint(0), labels.Labels{{Name: "foo", Value: "bar"}}, true, NilMetrics)
+	s.outOfOrderWindow = time.Hour
+
+	base := time.Unix(0, time.Now().UnixNano())
+	_, err = s.Push(context.Background(), []logproto.Entry{{Timestamp: base, Line: "1"}}, recordPool.GetRecord(), 0, true)
+	require.NoError(t, err)
+
+	// the entries within the window are accepted, further behind than half the max chunk age.
+	_, err = s.Push(context.Background(), []logproto.Entry{
+		{Timestamp: base.Add(-59 * time.Minute), Line: "2"},
+		{Timestamp: base.Add(-30 * time.Minute), Line: "3"},
+	}, recordPool.GetRecord(), 0, true)
+	require.NoError(t, err)
+
+	_, err = s.Push(context.Background(), []logproto.Entry{{Timestamp: base.Add(-61 * time.Minute), Line: "4"}}, recordPool.GetRecord(), 0, true)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), chunkenc.ErrTooFarBehind.Error())
+
+	it, err := s.Iterator(context.Background(), nil, base.Add(-2*time.Hour), base.Add(time.Second), logproto.FORWARD, log.NewNoopPipeline().ForStream(s.labels))
+	require.NoError(t, err)
+	iterEq(t, []logproto.Entry{
+		{Timestamp: base.Add(-59 * time.Minute), Line: "2"},
+		{Timestamp: base.Add(-30 * time.Minute), Line: "3"},
+		{Timestamp: base, Line: "1"},
+	}, it)
+}
+
 func iterEq(t *testing.T, exp []logproto.Entry, got iter.EntryIterator) {
 	var i int
 	for got.Next() {
@@ -2,6 +2,8 @@ package ingester
 
 import (
 	"flag"
+	"fmt"
+	"os"
 	"sync"
 	"time"
 
@@ -23,18 +25,42 @@ var (
 const walSegmentSize = wal.DefaultSegmentSize * 4
 const defaultCeiling = 4 << 30 // 4GB
 
+// Supported fsync policies of the WAL.
+const (
+	// WALFsyncNone leaves the segments to be fsynced when they are complete.
+	WALFsyncNone = "none"
+	// WALFsyncInterval fsyncs the active segment periodically.
+	WALFsyncInterval = "interval"
+	// WALFsyncAlways fsyncs the active segment before acknowledging the pushes.
+	WALFsyncAlways = "always"
+)
+
 type WALConfig struct {
 	Enabled             bool             `yaml:"enabled"`
 	Dir                 string           `yaml:"dir"`
 	CheckpointDuration  time.Duration    `yaml:"checkpoint_duration"`
 	FlushOnShutdown     bool             `yaml:"flush_on_shutdown"`
 	ReplayMemoryCeiling flagext.ByteSize `yaml:"replay_memory_ceiling"`
+	FsyncPolicy         string           `yaml:"fsync_policy"`
+	FsyncInterval       time.Duration    `yaml:"fsync_interval"`
 }
 
 func (cfg *WALConfig) Validate() error {
-	if cfg.Enabled && cfg.CheckpointDuration < 1 {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.CheckpointDuration < 1 {
 		return errors.Errorf("invalid checkpoint duration: %v", cfg.CheckpointDuration)
 	}
+	switch cfg.FsyncPolicy {
+	case WALFsyncNone, WALFsyncAlways:
+	case WALFsyncInterval:
+		if cfg.FsyncInterval <= 0 {
+			return errors.Errorf("invalid fsync interval: %v", cfg.FsyncInterval)
+		}
+	default:
+		return errors.Errorf("unsupported fsync policy %q, choose one of: %v, %v, %v", cfg.FsyncPolicy, WALFsyncNone, WALFsyncInterval, WALFsyncAlways)
+	}
 	return nil
 }
 
@@ -48,6 +74,8 @@ func (cfg *WALConfig) RegisterFlags(f *flag.FlagSet) {
 	// Need to set default here
 	cfg.ReplayMemoryCeiling = flagext.ByteSize(defaultCeiling)
 	f.Var(&cfg.ReplayMemoryCeiling, "ingester.wal-replay-memory-ceiling", "How much memory the WAL may use during replay before it needs to flush chunks to storage, i.e. 10GB. We suggest setting this to a high percentage (~75%) of available memory.")
+	f.StringVar(&cfg.FsyncPolicy, "ingester.wal-fsync-policy", WALFsyncNone, fmt.Sprintf("When the WAL segments are fsynced: %v when they are complete, leaving the entries of the active segment in the page cache, %v periodically, %v before acknowledging each push.", WALFsyncNone, WALFsyncInterval, WALFsyncAlways))
+	f.DurationVar(&cfg.FsyncInterval, "ingester.wal-fsync-interval", time.Second, "Interval at which the active WAL segment is fsynced with the interval fsync policy.")
 }
 
 // WAL interface allows us to have a no-op WAL when the WAL is disabled.
@@ -73,6 +101,11 @@ type walWrapper struct {
 
 	wait sync.WaitGroup
 	quit chan struct{}
+
+	// syncMtx guards the active segment, opened to be fsynced.
+	syncMtx       sync.Mutex
+	segment       *os.File
+	segmentNumber int
 }
 
 // newWAL creates a WAL object. If the WAL is disabled, then the returned WAL is a no-op WAL.
@@ -100,6 +133,10 @@ func newWAL(cfg WALConfig, registerer prometheus.Registerer, metrics *ingesterMe
 func (w *walWrapper) Start() {
 	w.wait.Add(1)
 	go w.run()
+	if w.cfg.FsyncPolicy == WALFsyncInterval {
+		w.wait.Add(1)
+		go w.syncLoop()
+	}
 }
 
 func (w *walWrapper) Log(record *WALRecord) error {
@@ -133,13 +170,79 @@ func (w *walWrapper) Log(record *WALRecord) error {
 			w.metrics.walRecordsLogged.Inc()
 			w.metrics.walLoggedBytesTotal.Add(float64(len(buf)))
 		}
+		if w.cfg.FsyncPolicy == WALFsyncAlways {
+			return w.sync()
+		}
 		return nil
 	}
 }
 
+// sync fsyncs the active segment, and the previous one when the WAL moved to a new segment since the last fsync.
+func (w *walWrapper) sync() error {
+	w.syncMtx.Lock()
+	defer w.syncMtx.Unlock()
+
+	_, last, err := wal.Segments(w.wal.Dir())
+	if err != nil {
+		return err
+	}
+	if w.segment != nil && last != w.segmentNumber {
+		err := w.fsync(w.segment)
+		_ = w.segment.Close()
+		w.segment = nil
+		if err != nil {
+			return err
+		}
+	}
+	if w.segment == nil {
+		// the segment is written by the WAL through its own file, fsyncing this one flushes the same file.
+		f, err := os.OpenFile(wal.SegmentName(w.wal.Dir(), last), os.O_WRONLY, 0)
+		if err != nil {
+			return err
+		}
+		w.segment, w.segmentNumber = f, last
+	}
+	return w.fsync(w.segment)
+}
+
+func (w *walWrapper) fsync(f *os.File) error {
+	start := time.Now()
+	err := f.Sync()
+	w.metrics.walFsyncDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		w.metrics.walFsyncFailures.Inc()
+		return errors.Wrapf(err, "failed to fsync WAL segment %s", f.Name())
+	}
+	return nil
+}
+
+// syncLoop fsyncs the active segment at the fsync interval.
+func (w *walWrapper) syncLoop() {
+	defer w.wait.Done()
+
+	ticker := time.NewTicker(w.cfg.FsyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := w.sync(); err != nil {
+				level.Error(util_log.Logger).Log("msg", "failed to fsync WAL", "err", err)
+			}
+		case <-w.quit:
+			return
+		}
+	}
+}
+
 func (w *walWrapper) Stop() error {
 	close(w.quit)
 	w.wait.Wait()
+	w.syncMtx.Lock()
+	if w.segment != nil {
+		_ = w.segment.Close()
+	}
+	w.syncMtx.Unlock()
+	// closing the WAL fsyncs the active segment.
 	err := w.wal.Close()
 	level.Info(util_log.Logger).Log("msg", "stopped", "component", "wal")
 	return err
@@ -0,0 +1,61 @@
+package ingester
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/tsdb/record"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWALConfig_Validate(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		cfg   WALConfig
+		valid bool
+	}{
+		{name: "disabled", cfg: WALConfig{FsyncPolicy: "unknown"}, valid: true},
+		{name: "none", cfg: WALConfig{Enabled: true, CheckpointDuration: time.Minute, FsyncPolicy: WALFsyncNone}, valid: true},
+		{name: "always", cfg: WALConfig{Enabled: true, CheckpointDuration: time.Minute, FsyncPolicy: WALFsyncAlways}, valid: true},
+		{name: "interval", cfg: WALConfig{Enabled: true, CheckpointDuration: time.Minute, FsyncPolicy: WALFsyncInterval, FsyncInterval: time.Second}, valid: true},
+		{name: "no interval", cfg: WALConfig{Enabled: true, CheckpointDuration: time.Minute, FsyncPolicy: WALFsyncInterval}},
+		{name: "unknown policy", cfg: WALConfig{Enabled: true, CheckpointDuration: time.Minute, FsyncPolicy: "unknown"}},
+		{name: "no checkpoint duration", cfg: WALConfig{Enabled: true, FsyncPolicy: WALFsyncNone}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.cfg.Validate()
+			if tc.valid {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestWALFsyncAlways(t *testing.T) {
+	metrics := newIngesterMetrics(prometheus.NewRegistry())
+	w, err := newWAL(WALConfig{
+		Enabled:            true,
+		Dir:                t.TempDir(),
+		CheckpointDuration: time.Hour,
+		FsyncPolicy:        WALFsyncAlways,
+	}, nil, metrics, nil)
+	require.NoError(t, err)
+	wrapper := w.(*walWrapper)
+
+	rec := &WALRecord{UserID: "fake", Series: []record.RefSeries{{Ref: 1, Labels: labels.Labels{{Name: "app", Value: "foo"}}}}}
+	require.NoError(t, w.Log(rec))
+	require.Equal(t, 0, wrapper.segmentNumber)
+
+	// the WAL moving to a new segment, the fsyncs follow it.
+	require.NoError(t, wrapper.wal.NextSegment())
+	require.NoError(t, w.Log(rec))
+	require.Equal(t, 1, wrapper.segmentNumber)
+	require.Equal(t, float64(0), testutil.ToFloat64(metrics.walFsyncFailures))
+
+	require.NoError(t, w.Stop())
+}
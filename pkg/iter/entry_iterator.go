@@ -1,7 +1,6 @@
 package iter
 
 import (
-	"container/heap"
 	"context"
 	"io"
 	"sort"
@@ -56,39 +55,6 @@ func (i *streamIterator) Close() error {
 	return nil
 }
 
-type iteratorHeap []EntryIterator
-
-func (h iteratorHeap) Len() int            { return len(h) }
-func (h iteratorHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
-func (h iteratorHeap) Peek() EntryIterator { return h[0] }
-func (h *iteratorHeap) Push(x interface{}) {
-	*h = append(*h, x.(EntryIterator))
-}
-
-func (h *iteratorHeap) Pop() interface{} {
-	old := *h
-	n := len(old)
-	x := old[n-1]
-	*h = old[0 : n-1]
-	return x
-}
-
-type iteratorSortHeap struct {
-	iteratorHeap
-	byAscendingTime bool
-}
-
-func (h iteratorSortHeap) Less(i, j int) bool {
-	t1, t2 := h.iteratorHeap[i].Entry().Timestamp.UnixNano(), h.iteratorHeap[j].Entry().Timestamp.UnixNano()
-	if t1 == t2 {
-		return h.iteratorHeap[i].StreamHash() < h.iteratorHeap[j].StreamHash()
-	}
-	if h.byAscendingTime {
-		return t1 < t2
-	}
-	return t1 > t2
-}
-
 // HeapIterator iterates over a heap of iterators with ability to push new iterators and get some properties like time of entry at peek and len
 // Not safe for concurrent use
 type HeapIterator interface {
@@ -98,43 +64,33 @@ type HeapIterator interface {
 	Push(EntryIterator)
 }
 
-// mergeEntryIterator iterates over a heap of iterators and merge duplicate entries.
+// mergeEntryIterator iterates over a loser tree of iterators and merge duplicate entries.
 type mergeEntryIterator struct {
-	heap interface {
-		heap.Interface
-		Peek() EntryIterator
-	}
+	tree       *entryLoserTree
 	is         []EntryIterator
 	prefetched bool
 	stats      *stats.Context
+	// errs are the errors of the tree, kept once it is given back to the pool.
+	errs []error
 
-	tuples    []tuple
 	currEntry entryWithLabels
-	errs      []error
 }
 
-// NewMergeEntryIterator returns a new iterator which uses a heap to merge together entries for multiple iterators and deduplicate entries if any.
+// NewMergeEntryIterator returns a new iterator which uses a loser tree to merge together entries for multiple iterators and deduplicate entries if any.
 // The iterator only order and merge entries across given `is` iterators, it does not merge entries within individual iterator.
 // This means using this iterator with a single iterator will result in the same result as the input iterator.
 // If you don't need to deduplicate entries, use `NewSortEntryIterator` instead.
 func NewMergeEntryIterator(ctx context.Context, is []EntryIterator, direction logproto.Direction) HeapIterator {
-	result := &mergeEntryIterator{is: is, stats: stats.FromContext(ctx)}
-	switch direction {
-	case logproto.BACKWARD:
-		result.heap = &iteratorSortHeap{iteratorHeap: make([]EntryIterator, 0, len(is)), byAscendingTime: false}
-	case logproto.FORWARD:
-		result.heap = &iteratorSortHeap{iteratorHeap: make([]EntryIterator, 0, len(is)), byAscendingTime: true}
-	default:
-		panic("bad direction")
+	return &mergeEntryIterator{
+		is:    is,
+		stats: stats.FromContext(ctx),
+		tree:  getEntryLoserTree(direction, len(is)),
 	}
-
-	result.tuples = make([]tuple, 0, len(is))
-	return result
 }
 
 // prefetch iterates over all inner iterators to merge together, calls Next() on
-// each of them to prefetch the first entry and pushes of them - who are not
-// empty - to the heap
+// each of them to prefetch the first entry and builds the tree out of the
+// ones who are not empty.
 func (i *mergeEntryIterator) prefetch() {
 	if i.prefetched {
 		return
@@ -142,111 +98,76 @@ func (i *mergeEntryIterator) prefetch() {
 
 	i.prefetched = true
 	for _, it := range i.is {
-		i.requeue(it, false)
+		i.tree.push(it)
 	}
+	i.tree.build()
 
 	// We can now clear the list of input iterators to merge, given they have all
-	// been processed and the non empty ones have been pushed to the heap
+	// been processed and the non empty ones have been added to the tree.
 	i.is = nil
 }
 
-// requeue pushes the input ei EntryIterator to the heap, advancing it via an ei.Next()
-// call unless the advanced input parameter is true. In this latter case it expects that
-// the iterator has already been advanced before calling requeue().
-//
-// If the iterator has no more entries or an error occur while advancing it, the iterator
-// is not pushed to the heap and any possible error captured, so that can be get via Error().
-func (i *mergeEntryIterator) requeue(ei EntryIterator, advanced bool) {
-	if advanced || ei.Next() {
-		heap.Push(i.heap, ei)
+// Push adds the iterator to the merge. This rebuilds the tree, so it should be
+// used for adding iterators from time to time, not for every entry.
+func (i *mergeEntryIterator) Push(ei EntryIterator) {
+	if i.tree == nil {
+		util.LogError("closing iterator", ei.Close)
 		return
 	}
-
-	if err := ei.Error(); err != nil {
-		i.errs = append(i.errs, err)
+	if !i.prefetched {
+		i.is = append(i.is, ei)
+		return
 	}
-	util.LogError("closing iterator", ei.Close)
-}
-
-func (i *mergeEntryIterator) Push(ei EntryIterator) {
-	i.requeue(ei, false)
-}
-
-type tuple struct {
-	logproto.Entry
-	EntryIterator
+	i.tree.push(ei)
+	i.tree.build()
 }
 
 func (i *mergeEntryIterator) Next() bool {
+	if i.tree == nil {
+		return false
+	}
 	i.prefetch()
 
-	if i.heap.Len() == 0 {
+	if i.tree.Len() == 0 {
 		return false
 	}
 
-	// shortcut for the last iterator.
-	if i.heap.Len() == 1 {
-		i.currEntry.entry = i.heap.Peek().Entry()
-		i.currEntry.labels = i.heap.Peek().Labels()
-		i.currEntry.streamHash = i.heap.Peek().StreamHash()
-
-		if !i.heap.Peek().Next() {
-			i.heap.Pop()
-		}
+	// shortcut when no other iterator is at the same entry timestamp.
+	if !i.tree.winnerHasTie() {
+		w := i.tree.winner()
+		i.setCurrent(w)
+		i.tree.advance(w)
 		return true
 	}
 
 	// We support multiple entries with the same timestamp, and we want to
 	// preserve their original order. We look at all the top entries in the
-	// heap with the same timestamp, and pop the ones whose common value
-	// occurs most often.
-	for i.heap.Len() > 0 {
-		next := i.heap.Peek()
-		entry := next.Entry()
-		if len(i.tuples) > 0 && (i.tuples[0].StreamHash() != next.StreamHash() || !i.tuples[0].Timestamp.Equal(entry.Timestamp)) {
-			break
-		}
-
-		heap.Pop(i.heap)
-		i.tuples = append(i.tuples, tuple{
-			Entry:         entry,
-			EntryIterator: next,
-		})
-	}
-
-	// shortcut if we have a single tuple.
-	if len(i.tuples) == 1 {
-		i.currEntry.entry = i.tuples[0].Entry
-		i.currEntry.labels = i.tuples[0].Labels()
-		i.currEntry.streamHash = i.tuples[0].StreamHash()
-		i.requeue(i.tuples[0].EntryIterator, false)
-		i.tuples = i.tuples[:0]
-		return true
-	}
-
-	// Find in tuples which entry occurs most often which, due to quorum based
-	// replication, is guaranteed to be the correct next entry.
-	t := i.tuples[0]
-	i.currEntry.entry = t.Entry
-	i.currEntry.labels = t.Labels()
-	i.currEntry.streamHash = i.tuples[0].StreamHash()
-
-	// Requeue the iterators, advancing them if they were consumed.
-	for j := range i.tuples {
-		if i.tuples[j].Line != i.currEntry.entry.Line {
-			i.requeue(i.tuples[j].EntryIterator, true)
+	// tree with the same timestamp and stream, and consume the ones whose
+	// value is the same as the first one: due to quorum based replication,
+	// these are duplicates of the same entry.
+	tuples := i.tree.popTies()
+	first := tuples[0]
+	i.setCurrent(first)
+	for _, l := range tuples {
+		if i.tree.leaves[l].entry.Line != i.currEntry.entry.Line {
 			continue
 		}
-		// we count as duplicates only if the tuple is not the one (t) used to fill the current entry
-		if i.tuples[j] != t {
+		// we count as duplicates only if the tuple is not the one used to fill the current entry
+		if l != first {
 			i.stats.AddDuplicates(1)
 		}
-		i.requeue(i.tuples[j].EntryIterator, false)
+		i.tree.advance(l)
 	}
-	i.tuples = i.tuples[:0]
 	return true
 }
 
+func (i *mergeEntryIterator) setCurrent(leaf int) {
+	l := &i.tree.leaves[leaf]
+	i.currEntry.entry = l.entry
+	i.currEntry.labels = l.it.Labels()
+	i.currEntry.streamHash = l.hash
+}
+
 func (i *mergeEntryIterator) Entry() logproto.Entry {
 	return i.currEntry.entry
 }
@@ -258,37 +179,59 @@ func (i *mergeEntryIterator) Labels() string {
 func (i *mergeEntryIterator) StreamHash() uint64 { return i.currEntry.streamHash }
 
 func (i *mergeEntryIterator) Error() error {
-	switch len(i.errs) {
+	errs := i.errs
+	if i.tree != nil {
+		errs = i.tree.errs
+	}
+	switch len(errs) {
 	case 0:
 		return nil
 	case 1:
-		return i.errs[0]
+		return errs[0]
 	default:
-		return util.MultiError(i.errs)
+		return util.MultiError(errs)
 	}
 }
 
 func (i *mergeEntryIterator) Close() error {
-	for i.heap.Len() > 0 {
-		if err := i.heap.Pop().(EntryIterator).Close(); err != nil {
+	for _, it := range i.is {
+		if err := it.Close(); err != nil {
 			return err
 		}
 	}
-	i.tuples = nil
+	i.is = nil
+	if i.tree == nil {
+		return nil
+	}
+	err := i.tree.close()
+	if err != nil {
+		return err
+	}
+	if len(i.tree.errs) > 0 {
+		i.errs = append([]error(nil), i.tree.errs...)
+	}
+	putEntryLoserTree(i.tree)
+	i.tree = nil
 	return nil
 }
 
 func (i *mergeEntryIterator) Peek() time.Time {
+	if i.tree == nil {
+		return time.Time{}
+	}
 	i.prefetch()
 
-	return i.heap.Peek().Entry().Timestamp
+	return i.tree.leaves[i.tree.winner()].entry.Timestamp
 }
 
-// Len returns the number of inner iterators on the heap, still having entries
+// Len returns the number of inner iterators in the tree, still having entries
 func (i *mergeEntryIterator) Len() int {
+	if i.tree == nil {
+		return 0
+	}
 	i.prefetch()
 
-	return i.heap.Len()
+	return i.tree.Len()
 }
 
 type entrySortIterator struct {
@@ -474,14 +417,21 @@ func NewQueryResponseIterator(resp *logproto.QueryResponse, direction logproto.D
 }
 
 type queryClientIterator struct {
-	client    logproto.Querier_QueryClient
+	client    QueryClient
 	direction logproto.Direction
 	err       error
 	curr      EntryIterator
 }
 
+// QueryClient is GRPC stream client with only method used by the QueryClientIterator
+type QueryClient interface {
+	Recv() (*logproto.QueryResponse, error)
+	Context() context.Context
+	CloseSend() error
+}
+
 // NewQueryClientIterator returns an iterator over a QueryClient.
-func NewQueryClientIterator(client logproto.Querier_QueryClient, direction logproto.Direction) EntryIterator {
+func NewQueryClientIterator(client QueryClient, direction logproto.Direction) EntryIterator {
 	return &queryClientIterator{
 		client:    client,
 		direction: direction,
@@ -796,41 +746,64 @@ func (i *reverseEntryIterator) Close() error {
 	return nil
 }
 
+// queryResponsePool and entriesPool pool the batches read by ReadBatch and released by PutBatch.
+var (
+	queryResponsePool = sync.Pool{
+		New: func() interface{} {
+			return &logproto.QueryResponse{}
+		},
+	}
+	entriesPool = sync.Pool{
+		New: func() interface{} {
+			return make([]logproto.Entry, 0, 32)
+		},
+	}
+)
+
 // ReadBatch reads a set of entries off an iterator.
+// The batch can be released with PutBatch once it isn't used anymore.
 func ReadBatch(i EntryIterator, size uint32) (*logproto.QueryResponse, uint32, error) {
 	var (
-		streams      = map[uint64]map[string]*logproto.Stream{}
-		respSize     uint32
-		streamsCount int
+		// streams indexes the streams of the result by hash and labels.
+		streams  = map[uint64]map[string]int{}
+		result   = queryResponsePool.Get().(*logproto.QueryResponse)
+		respSize uint32
 	)
 	for ; respSize < size && i.Next(); respSize++ {
 		labels, hash, entry := i.Labels(), i.StreamHash(), i.Entry()
 		mutatedStreams, ok := streams[hash]
 		if !ok {
-			mutatedStreams = map[string]*logproto.Stream{}
+			mutatedStreams = map[string]int{}
 			streams[hash] = mutatedStreams
 		}
-		mutatedStream, ok := mutatedStreams[labels]
+		idx, ok := mutatedStreams[labels]
 		if !ok {
-			streamsCount++
-			mutatedStream = &logproto.Stream{
-				Labels: labels,
-				Hash:   hash,
-			}
-			mutatedStreams[labels] = mutatedStream
+			idx = len(result.Streams)
+			result.Streams = append(result.Streams, logproto.Stream{
+				Labels:  labels,
+				Hash:    hash,
+				Entries: entriesPool.Get().([]logproto.Entry),
+			})
+			mutatedStreams[labels] = idx
 		}
-		mutatedStream.Entries = append(mutatedStream.Entries, entry)
+		result.Streams[idx].Entries = append(result.Streams[idx].Entries, entry)
 	}
+	return result, respSize, i.Error()
+}
 
-	result := logproto.QueryResponse{
-		Streams: make([]logproto.Stream, 0, streamsCount),
-	}
-	for _, mutatedStreams := range streams {
-		for _, s := range mutatedStreams {
-			result.Streams = append(result.Streams, *s)
+// PutBatch releases a batch read by ReadBatch, which must not be used anymore, e.g. once it is sent.
+func PutBatch(batch *logproto.QueryResponse) {
+	for i := range batch.Streams {
+		entries := batch.Streams[i].Entries
+		// the entries are cleared to not retain their lines.
+		for j := range entries {
+			entries[j] = logproto.Entry{}
 		}
+		entriesPool.Put(entries[:0]) // nolint:staticcheck
+		batch.Streams[i] = logproto.Stream{}
 	}
-	return &result, respSize, i.Error()
+	*batch = logproto.QueryResponse{Streams: batch.Streams[:0]}
+	queryResponsePool.Put(batch)
 }
 
 type peekingEntryIterator struct {
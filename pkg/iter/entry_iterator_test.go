@@ -845,3 +845,33 @@ func Test_EntrySortIterator(t *testing.T) {
 		}
 	})
 }
+
+func TestReadBatch(t *testing.T) {
+	it := NewStreamsIterator([]logproto.Stream{
+		{Labels: `{app="foo"}`, Hash: 1, Entries: []logproto.Entry{{Timestamp: time.Unix(0, 1), Line: "1"}, {Timestamp: time.Unix(0, 3), Line: "3"}}},
+		{Labels: `{app="bar"}`, Hash: 2, Entries: []logproto.Entry{{Timestamp: time.Unix(0, 2), Line: "2"}}},
+	}, logproto.FORWARD)
+
+	res, size, err := ReadBatch(it, 2)
+	require.NoError(t, err)
+	require.Equal(t, uint32(2), size)
+	require.Equal(t, []logproto.Stream{
+		{Labels: `{app="foo"}`, Hash: 1, Entries: []logproto.Entry{{Timestamp: time.Unix(0, 1), Line: "1"}}},
+		{Labels: `{app="bar"}`, Hash: 2, Entries: []logproto.Entry{{Timestamp: time.Unix(0, 2), Line: "2"}}},
+	}, res.Streams)
+
+	// the batches released are reused.
+	PutBatch(res)
+	res, size, err = ReadBatch(it, 2)
+	require.NoError(t, err)
+	require.Equal(t, uint32(1), size)
+	require.Equal(t, []logproto.Stream{
+		{Labels: `{app="foo"}`, Hash: 1, Entries: []logproto.Entry{{Timestamp: time.Unix(0, 3), Line: "3"}}},
+	}, res.Streams)
+	PutBatch(res)
+
+	res, size, err = ReadBatch(it, 2)
+	require.NoError(t, err)
+	require.Equal(t, uint32(0), size)
+	require.Empty(t, res.Streams)
+}
@@ -0,0 +1,296 @@
+package iter
+
+import (
+	"sync"
+
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/loki/pkg/util"
+)
+
+// entryLoserTree is a tournament tree of losers over entry iterators.
+//
+// Compared to a binary heap, replacing the winner only requires comparing it
+// against the loser stored at each level of its path to the root, which halves
+// the number of comparisons when merging many iterators. The current entry of
+// every iterator is cached in its leaf so comparisons don't go through the
+// iterator interface.
+type entryLoserTree struct {
+	byAscendingTime bool
+
+	leaves []loserTreeLeaf
+	// nodes[0] holds the index of the winning leaf, nodes[1:] hold the index
+	// of the leaf that lost the match played at that node.
+	nodes []int
+	// winners is scratch space used when (re)building the tree.
+	winners []int
+	// active is the number of leaves that still have entries.
+	active int
+
+	// buffers reused across calls to popTies.
+	ties []int
+	undo []loserTreeUndo
+
+	errs []error
+}
+
+type loserTreeUndo struct {
+	node, leaf int
+}
+
+type loserTreeLeaf struct {
+	it    EntryIterator
+	entry logproto.Entry
+	ts    int64
+	hash  uint64
+	done  bool
+	// parked leaves are temporarily taken out of the tournament by popTies.
+	parked bool
+}
+
+// maxPooledLoserTreeSize is the maximum number of leaves of the trees put back
+// in the pool, so that a single huge merge doesn't pin its buffers.
+const maxPooledLoserTreeSize = 1 << 16
+
+// loserTreePool reuses the trees and their buffers across merges: queries
+// create a merge iterator per split, shard and querier response.
+var loserTreePool = sync.Pool{
+	New: func() interface{} {
+		return &entryLoserTree{}
+	},
+}
+
+// getEntryLoserTree returns a tree from the pool. It must be given back with
+// putEntryLoserTree once closed.
+func getEntryLoserTree(direction logproto.Direction, size int) *entryLoserTree {
+	t := loserTreePool.Get().(*entryLoserTree)
+	switch direction {
+	case logproto.BACKWARD:
+		t.byAscendingTime = false
+	case logproto.FORWARD:
+		t.byAscendingTime = true
+	default:
+		panic("bad direction")
+	}
+	if cap(t.leaves) < size {
+		t.leaves = make([]loserTreeLeaf, 0, size)
+	}
+	return t
+}
+
+// putEntryLoserTree resets the tree and puts it back in the pool. The leaves
+// are cleared so the pooled tree doesn't retain the iterators nor their entries.
+func putEntryLoserTree(t *entryLoserTree) {
+	if cap(t.leaves) > maxPooledLoserTreeSize {
+		return
+	}
+	for i := range t.leaves {
+		t.leaves[i] = loserTreeLeaf{}
+	}
+	for i := range t.errs {
+		t.errs[i] = nil
+	}
+	t.leaves, t.nodes, t.winners = t.leaves[:0], t.nodes[:0], t.winners[:0]
+	t.ties, t.undo, t.errs = t.ties[:0], t.undo[:0], t.errs[:0]
+	t.active = 0
+	loserTreePool.Put(t)
+}
+
+// less tells if the entry of leaf a must be returned before the entry of leaf b.
+// Exhausted leaves always lose. Entries at the same timestamp are ordered by
+// stream hash then by the order in which iterators were given.
+func (t *entryLoserTree) less(a, b int) bool {
+	la, lb := &t.leaves[a], &t.leaves[b]
+	if la.done || la.parked {
+		return false
+	}
+	if lb.done || lb.parked {
+		return true
+	}
+	if la.ts != lb.ts {
+		if t.byAscendingTime {
+			return la.ts < lb.ts
+		}
+		return la.ts > lb.ts
+	}
+	if la.hash != lb.hash {
+		return la.hash < lb.hash
+	}
+	return a < b
+}
+
+// tie tells if leaves a and b are at the same timestamp of the same stream.
+func (t *entryLoserTree) tie(a, b int) bool {
+	la, lb := &t.leaves[a], &t.leaves[b]
+	if la.done || la.parked || lb.done || lb.parked {
+		return false
+	}
+	return la.ts == lb.ts && la.hash == lb.hash
+}
+
+// push adds the iterator to the tree, advancing it first. Iterators without
+// entries are closed right away.
+// The tree must be rebuilt by calling build() once all iterators are pushed.
+func (t *entryLoserTree) push(it EntryIterator) {
+	t.leaves = append(t.leaves, loserTreeLeaf{it: it})
+	t.next(len(t.leaves) - 1)
+	if !t.leaves[len(t.leaves)-1].done {
+		t.active++
+	}
+}
+
+// next advances the iterator of the given leaf, caching its current entry.
+func (t *entryLoserTree) next(i int) {
+	leaf := &t.leaves[i]
+	if leaf.it.Next() {
+		leaf.entry = leaf.it.Entry()
+		leaf.ts = leaf.entry.Timestamp.UnixNano()
+		leaf.hash = leaf.it.StreamHash()
+		return
+	}
+	leaf.done = true
+	leaf.entry = logproto.Entry{}
+	if err := leaf.it.Error(); err != nil {
+		t.errs = append(t.errs, err)
+	}
+	util.LogError("closing iterator", leaf.it.Close)
+}
+
+// build drops exhausted leaves and plays the whole tournament.
+func (t *entryLoserTree) build() {
+	leaves := t.leaves[:0]
+	for _, l := range t.leaves {
+		if !l.done {
+			leaves = append(leaves, l)
+		}
+	}
+	for i := len(leaves); i < len(t.leaves); i++ {
+		t.leaves[i] = loserTreeLeaf{}
+	}
+	t.leaves = leaves
+	t.active = len(leaves)
+
+	n := len(t.leaves)
+	if n == 0 {
+		t.nodes = t.nodes[:0]
+		return
+	}
+	if cap(t.nodes) < n {
+		t.nodes = make([]int, n)
+		t.winners = make([]int, 2*n)
+	}
+	t.nodes, t.winners = t.nodes[:n], t.winners[:2*n]
+
+	// Leaves are stored at positions [n, 2n) of an implicit binary tree, the
+	// children of node p being 2p and 2p+1.
+	for i := 0; i < n; i++ {
+		t.winners[n+i] = i
+	}
+	for p := n - 1; p >= 1; p-- {
+		l, r := t.winners[2*p], t.winners[2*p+1]
+		if t.less(r, l) {
+			t.winners[p], t.nodes[p] = r, l
+		} else {
+			t.winners[p], t.nodes[p] = l, r
+		}
+	}
+	t.nodes[0] = t.winners[1]
+}
+
+// Len returns the number of iterators that still have entries.
+func (t *entryLoserTree) Len() int {
+	return t.active
+}
+
+// winner returns the index of the leaf holding the next entry.
+// It must only be called when Len() > 0.
+func (t *entryLoserTree) winner() int {
+	return t.nodes[0]
+}
+
+// advance moves the given leaf to its next entry and replays its matches.
+func (t *entryLoserTree) advance(leaf int) {
+	t.next(leaf)
+	if t.leaves[leaf].done {
+		t.active--
+	}
+	t.fix(leaf)
+}
+
+// fix replays the matches of a leaf whose entry can only have moved later in
+// the ordering. For the winner this replays all matches up to the root. For
+// any other leaf the replay stops at the node where the leaf was recorded as
+// the loser: its new subtree winner still loses there.
+func (t *entryLoserTree) fix(leaf int) {
+	c := leaf
+	n := len(t.leaves)
+	for p := (leaf + n) / 2; p > 0; p /= 2 {
+		if t.nodes[p] == leaf {
+			t.nodes[p] = c
+			return
+		}
+		if t.less(t.nodes[p], c) {
+			t.nodes[p], c = c, t.nodes[p]
+		}
+	}
+	t.nodes[0] = c
+}
+
+// winnerHasTie tells if another leaf is at the same timestamp of the same stream
+// as the winner. If so it must have lost its match directly against the winner.
+func (t *entryLoserTree) winnerHasTie() bool {
+	w := t.nodes[0]
+	for p := (w + len(t.leaves)) / 2; p > 0; p /= 2 {
+		if t.tie(t.nodes[p], w) {
+			return true
+		}
+	}
+	return false
+}
+
+// popTies returns the winner and all the leaves tied with it, in order.
+// The tree is left untouched.
+func (t *entryLoserTree) popTies() []int {
+	t.ties, t.undo = t.ties[:0], t.undo[:0]
+	n := len(t.leaves)
+
+	ts, hash := t.leaves[t.nodes[0]].ts, t.leaves[t.nodes[0]].hash
+	for {
+		w := t.nodes[0]
+		l := &t.leaves[w]
+		if l.done || l.parked || l.ts != ts || l.hash != hash {
+			break
+		}
+		t.ties = append(t.ties, w)
+
+		// Park the winner, recording the nodes of its path to undo it afterwards.
+		for p := (w + n) / 2; p > 0; p /= 2 {
+			t.undo = append(t.undo, loserTreeUndo{node: p, leaf: t.nodes[p]})
+		}
+		t.undo = append(t.undo, loserTreeUndo{node: 0, leaf: w})
+		t.leaves[w].parked = true
+		t.fix(w)
+	}
+
+	for j := len(t.undo) - 1; j >= 0; j-- {
+		t.nodes[t.undo[j].node] = t.undo[j].leaf
+	}
+	for _, l := range t.ties {
+		t.leaves[l].parked = false
+	}
+	return t.ties
+}
+
+// close closes all the iterators that still have entries.
+func (t *entryLoserTree) close() error {
+	for i := range t.leaves {
+		if t.leaves[i].done {
+			continue
+		}
+		t.leaves[i].done = true
+		if err := t.leaves[i].it.Close(); err != nil {
+			return err
+		}
+	}
+	t.active = 0
+	return nil
+}
@@ -0,0 +1,166 @@
+package iter
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/pkg/logproto"
+)
+
+func TestMergeEntryIterator_Random(t *testing.T) {
+	for _, direction := range []logproto.Direction{logproto.FORWARD, logproto.BACKWARD} {
+		for _, streamsCount := range []int{1, 2, 3, 7, 64, 1000} {
+			t.Run(fmt.Sprintf("%s-%d", direction, streamsCount), func(t *testing.T) {
+				rnd := rand.New(rand.NewSource(int64(streamsCount)))
+				var (
+					streams  []logproto.Stream
+					expected []logproto.Entry
+				)
+				for i := 0; i < streamsCount; i++ {
+					s := logproto.Stream{Labels: fmt.Sprintf(`{i="%d"}`, i%3), Hash: uint64(i % 3)}
+					ts := int64(0)
+					for j := 0; j < rnd.Intn(50); j++ {
+						ts += int64(rnd.Intn(3) + 1)
+						s.Entries = append(s.Entries, logproto.Entry{Timestamp: time.Unix(0, ts), Line: fmt.Sprintf("%d-%d", i, j)})
+					}
+					expected = append(expected, s.Entries...)
+					if direction == logproto.BACKWARD {
+						for l, r := 0, len(s.Entries)-1; l < r; l, r = l+1, r-1 {
+							s.Entries[l], s.Entries[r] = s.Entries[r], s.Entries[l]
+						}
+					}
+					streams = append(streams, s)
+				}
+				sort.SliceStable(expected, func(i, j int) bool {
+					if direction == logproto.BACKWARD {
+						return expected[i].Timestamp.After(expected[j].Timestamp)
+					}
+					return expected[i].Timestamp.Before(expected[j].Timestamp)
+				})
+
+				var itrs []EntryIterator
+				for _, s := range streams {
+					itrs = append(itrs, NewStreamIterator(s))
+					// Add a replica of every stream.
+					itrs = append(itrs, NewStreamIterator(s))
+				}
+				it := NewMergeEntryIterator(context.Background(), itrs, direction)
+
+				var (
+					actual []logproto.Entry
+					prev   int64
+				)
+				for it.Next() {
+					ts := it.Entry().Timestamp.UnixNano()
+					if len(actual) > 0 {
+						if direction == logproto.FORWARD {
+							require.GreaterOrEqual(t, ts, prev)
+						} else {
+							require.LessOrEqual(t, ts, prev)
+						}
+					}
+					prev = ts
+					actual = append(actual, it.Entry())
+				}
+				require.NoError(t, it.Error())
+				require.NoError(t, it.Close())
+				require.ElementsMatch(t, expected, actual)
+			})
+		}
+	}
+}
+
+func TestMergeEntryIterator_Push(t *testing.T) {
+	it := NewMergeEntryIterator(context.Background(), []EntryIterator{
+		mkStreamIterator(offset(0, identity), defaultLabels),
+	}, logproto.FORWARD)
+
+	require.True(t, it.Next())
+	require.Equal(t, identity(0), it.Entry())
+	require.Equal(t, 1, it.Len())
+
+	// Push an iterator starting before the current entry of the first one.
+	it.Push(mkStreamIterator(offset(-5, identity), defaultLabels))
+	require.Equal(t, 2, it.Len())
+	require.Equal(t, identity(-5).Timestamp, it.Peek())
+
+	var count int
+	for it.Next() {
+		count++
+	}
+	// 5 entries before the first one, then 0 to 9 from the pushed iterator
+	// merged with 1 to 9 from the first one.
+	require.Equal(t, 15, count)
+	require.Equal(t, 0, it.Len())
+	require.NoError(t, it.Close())
+}
+
+func TestMergeEntryIterator_Pooled(t *testing.T) {
+	it := NewMergeEntryIterator(context.Background(), []EntryIterator{
+		mkStreamIterator(offset(0, identity), defaultLabels),
+		&errorIter{},
+	}, logproto.BACKWARD)
+	require.True(t, it.Next())
+	require.NoError(t, it.Close())
+	// the errors are kept once the tree is back in the pool.
+	require.EqualError(t, it.Error(), "error")
+	require.False(t, it.Next())
+	require.Equal(t, 0, it.Len())
+
+	// a reused tree doesn't keep anything from its previous merge.
+	it = NewMergeEntryIterator(context.Background(), []EntryIterator{
+		mkStreamIterator(offset(0, identity), defaultLabels),
+		mkStreamIterator(offset(0, identity), defaultLabels),
+	}, logproto.FORWARD)
+	var count int
+	for it.Next() {
+		require.Equal(t, identity(int64(count)), it.Entry())
+		count++
+	}
+	require.Equal(t, testSize, count)
+	require.NoError(t, it.Error())
+	require.NoError(t, it.Close())
+}
+
+func BenchmarkMergeEntryIterator(b *testing.B) {
+	for _, streamsCount := range []int{100, 1000, 10000} {
+		var (
+			ctx          = context.Background()
+			streams      = make([]logproto.Stream, streamsCount)
+			entriesCount = 100000
+		)
+		for i := 0; i < streamsCount; i++ {
+			streams[i].Labels = fmt.Sprintf(`{i="%d"}`, i)
+			streams[i].Hash = uint64(i)
+		}
+		for i := 0; i < entriesCount; i++ {
+			streams[i%streamsCount].Entries = append(streams[i%streamsCount].Entries, logproto.Entry{
+				Timestamp: time.Unix(0, int64(i)),
+				Line:      fmt.Sprintf("%d", i),
+			})
+		}
+
+		b.Run(fmt.Sprintf("streams=%d", streamsCount), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				itrs := make([]EntryIterator, 0, streamsCount)
+				for _, s := range streams {
+					itrs = append(itrs, NewStreamIterator(s))
+				}
+				b.StartTimer()
+				it := NewMergeEntryIterator(ctx, itrs, logproto.FORWARD)
+				for it.Next() {
+					it.Entry()
+				}
+				it.Close()
+			}
+		})
+	}
+}
@@ -669,39 +669,57 @@ func (i *timeRangedSampleIterator) Next() bool {
 	return ok
 }
 
-// ReadBatch reads a set of entries off an iterator.
+// sampleQueryResponsePool and samplesPool pool the batches read by ReadSampleBatch and released by PutSampleBatch.
+var (
+	sampleQueryResponsePool = sync.Pool{
+		New: func() interface{} {
+			return &logproto.SampleQueryResponse{}
+		},
+	}
+	samplesPool = sync.Pool{
+		New: func() interface{} {
+			return make([]logproto.Sample, 0, 32)
+		},
+	}
+)
+
+// ReadSampleBatch reads a set of samples off an iterator.
+// The batch can be released with PutSampleBatch once it isn't used anymore.
 func ReadSampleBatch(i SampleIterator, size uint32) (*logproto.SampleQueryResponse, uint32, error) {
 	var (
-		series      = map[uint64]map[string]*logproto.Series{}
-		respSize    uint32
-		seriesCount int
+		// series indexes the series of the result by hash and labels.
+		series   = map[uint64]map[string]int{}
+		result   = sampleQueryResponsePool.Get().(*logproto.SampleQueryResponse)
+		respSize uint32
 	)
 	for ; respSize < size && i.Next(); respSize++ {
 		labels, hash, sample := i.Labels(), i.StreamHash(), i.Sample()
 		streams, ok := series[hash]
 		if !ok {
-			streams = map[string]*logproto.Series{}
+			streams = map[string]int{}
 			series[hash] = streams
 		}
-		s, ok := streams[labels]
+		idx, ok := streams[labels]
 		if !ok {
-			seriesCount++
-			s = &logproto.Series{
+			idx = len(result.Series)
+			result.Series = append(result.Series, logproto.Series{
 				Labels:     labels,
 				StreamHash: hash,
-			}
-			streams[labels] = s
+				Samples:    samplesPool.Get().([]logproto.Sample),
+			})
+			streams[labels] = idx
 		}
-		s.Samples = append(s.Samples, sample)
+		result.Series[idx].Samples = append(result.Series[idx].Samples, sample)
 	}
+	return result, respSize, i.Error()
+}
 
-	result := logproto.SampleQueryResponse{
-		Series: make([]logproto.Series, 0, seriesCount),
-	}
-	for _, streams := range series {
-		for _, s := range streams {
-			result.Series = append(result.Series, *s)
-		}
+// PutSampleBatch releases a batch read by ReadSampleBatch, which must not be used anymore, e.g. once it is sent.
+func PutSampleBatch(batch *logproto.SampleQueryResponse) {
+	for i := range batch.Series {
+		samplesPool.Put(batch.Series[i].Samples[:0]) // nolint:staticcheck
+		batch.Series[i] = logproto.Series{}
 	}
-	return &result, respSize, i.Error()
+	*batch = logproto.SampleQueryResponse{Series: batch.Series[:0]}
+	sampleQueryResponsePool.Put(batch)
 }
@@ -0,0 +1,241 @@
+package iter
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/grafana/loki/pkg/logproto"
+)
+
+// entryOverhead approximates the memory used by a buffered entry besides its line and labels.
+const entryOverhead = 48
+
+// SpillBudget accounts for the bytes spilled to disk.
+type SpillBudget interface {
+	// Reserve fails when the bytes can't be spilled.
+	Reserve(bytes int64) error
+	Release(bytes int64)
+}
+
+// SpillConfig configures the spilling of the entries buffered by an iterator.
+type SpillConfig struct {
+	// Directory the sorted runs of entries are spilled to.
+	Directory string
+	// MemoryThreshold is the size of the buffered entries above which they are spilled.
+	MemoryThreshold int64
+	Budget          SpillBudget
+}
+
+type spillingSortIterator struct {
+	EntryIterator
+
+	files   []*os.File
+	spilled int64
+	budget  SpillBudget
+}
+
+// NewSpillingSortIterator returns an iterator which loads all or up to N entries of an existing
+// iterator, and then iterates over them in the given direction. When the buffered entries exceed
+// the memory threshold, they are sorted and spilled to a file, and the files are merged on output.
+func NewSpillingSortIterator(it EntryIterator, direction logproto.Direction, limit uint32, cfg SpillConfig) (EntryIterator, error) {
+	defer it.Close()
+
+	s := &spillingSortIterator{budget: cfg.Budget}
+	var (
+		buf  []entryWithLabels
+		size int64
+	)
+	for count := uint32(0); (limit == 0 || count < limit) && it.Next(); count++ {
+		e := entryWithLabels{entry: it.Entry(), labels: it.Labels(), streamHash: it.StreamHash()}
+		buf = append(buf, e)
+		size += int64(len(e.entry.Line) + len(e.labels) + entryOverhead)
+		if cfg.MemoryThreshold <= 0 || size < cfg.MemoryThreshold {
+			continue
+		}
+		if err := s.spill(buf, direction, cfg); err != nil {
+			s.Close()
+			return nil, err
+		}
+		buf, size = buf[:0], 0
+	}
+	if err := it.Error(); err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	sortEntries(buf, direction)
+	is := make([]EntryIterator, 0, len(s.files)+1)
+	for _, f := range s.files {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			s.Close()
+			return nil, err
+		}
+		is = append(is, &spillRunIterator{r: bufio.NewReader(f)})
+	}
+	is = append(is, &entriesIterator{entries: buf})
+	s.EntryIterator = NewSortEntryIterator(is, direction)
+	return s, nil
+}
+
+// spill writes the sorted entries to a new file.
+func (s *spillingSortIterator) spill(entries []entryWithLabels, direction logproto.Direction, cfg SpillConfig) error {
+	sortEntries(entries, direction)
+
+	var (
+		out     []byte
+		scratch [binary.MaxVarintLen64]byte
+	)
+	for _, e := range entries {
+		out = append(out, scratch[:binary.PutUvarint(scratch[:], uint64(len(e.labels)))]...)
+		out = append(out, e.labels...)
+		out = append(out, scratch[:binary.PutVarint(scratch[:], e.entry.Timestamp.UnixNano())]...)
+		out = append(out, scratch[:binary.PutUvarint(scratch[:], uint64(len(e.entry.Line)))]...)
+		out = append(out, e.entry.Line...)
+		out = append(out, scratch[:binary.PutUvarint(scratch[:], e.streamHash)]...)
+	}
+	if cfg.Budget != nil {
+		if err := cfg.Budget.Reserve(int64(len(out))); err != nil {
+			return err
+		}
+	}
+	s.spilled += int64(len(out))
+
+	f, err := ioutil.TempFile(cfg.Directory, "spill-")
+	if err != nil {
+		return errors.Wrap(err, "failed to create the spill file")
+	}
+	s.files = append(s.files, f)
+	if _, err := f.Write(out); err != nil {
+		return errors.Wrap(err, "failed to spill the entries")
+	}
+	return nil
+}
+
+func (s *spillingSortIterator) Close() error {
+	var err error
+	if s.EntryIterator != nil {
+		err = s.EntryIterator.Close()
+		s.EntryIterator = nil
+	}
+	for _, f := range s.files {
+		f.Close()
+		if rmErr := os.Remove(f.Name()); rmErr != nil && err == nil {
+			err = rmErr
+		}
+	}
+	s.files = nil
+	if s.budget != nil && s.spilled > 0 {
+		s.budget.Release(s.spilled)
+	}
+	s.spilled = 0
+	return err
+}
+
+func sortEntries(entries []entryWithLabels, direction logproto.Direction) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		if direction == logproto.FORWARD {
+			return entries[i].entry.Timestamp.Before(entries[j].entry.Timestamp)
+		}
+		return entries[i].entry.Timestamp.After(entries[j].entry.Timestamp)
+	})
+}
+
+// entriesIterator iterates over the entries kept in memory.
+type entriesIterator struct {
+	entries []entryWithLabels
+	cur     entryWithLabels
+}
+
+func (i *entriesIterator) Next() bool {
+	if len(i.entries) == 0 {
+		return false
+	}
+	i.cur, i.entries = i.entries[0], i.entries[1:]
+	return true
+}
+
+func (i *entriesIterator) Entry() logproto.Entry { return i.cur.entry }
+func (i *entriesIterator) Labels() string        { return i.cur.labels }
+func (i *entriesIterator) StreamHash() uint64    { return i.cur.streamHash }
+func (i *entriesIterator) Error() error          { return nil }
+func (i *entriesIterator) Close() error {
+	i.entries = nil
+	return nil
+}
+
+// spillRunIterator reads a run of entries spilled to a file.
+type spillRunIterator struct {
+	r   *bufio.Reader
+	cur entryWithLabels
+	err error
+}
+
+func (i *spillRunIterator) Next() bool {
+	if i.err != nil {
+		return false
+	}
+	cur, err := i.read()
+	if err != nil {
+		if err != io.EOF {
+			i.err = errors.Wrap(err, "failed to read the spilled entries")
+		}
+		return false
+	}
+	i.cur = cur
+	return true
+}
+
+func (i *spillRunIterator) read() (entryWithLabels, error) {
+	var e entryWithLabels
+	labels, err := i.readBytes()
+	if err != nil {
+		return e, err
+	}
+	ts, err := binary.ReadVarint(i.r)
+	if err != nil {
+		return e, unexpectedEOF(err)
+	}
+	line, err := i.readBytes()
+	if err != nil {
+		return e, unexpectedEOF(err)
+	}
+	hash, err := binary.ReadUvarint(i.r)
+	if err != nil {
+		return e, unexpectedEOF(err)
+	}
+	e.labels, e.streamHash = string(labels), hash
+	e.entry = logproto.Entry{Timestamp: time.Unix(0, ts), Line: string(line)}
+	return e, nil
+}
+
+func (i *spillRunIterator) readBytes() ([]byte, error) {
+	n, err := binary.ReadUvarint(i.r)
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(i.r, b); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return b, nil
+}
+
+func (i *spillRunIterator) Entry() logproto.Entry { return i.cur.entry }
+func (i *spillRunIterator) Labels() string        { return i.cur.labels }
+func (i *spillRunIterator) StreamHash() uint64    { return i.cur.streamHash }
+func (i *spillRunIterator) Error() error          { return i.err }
+func (i *spillRunIterator) Close() error          { return nil }
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
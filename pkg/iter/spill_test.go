@@ -0,0 +1,88 @@
+package iter
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/pkg/logproto"
+)
+
+type testSpillBudget struct {
+	limit, used int64
+}
+
+func (b *testSpillBudget) Reserve(bytes int64) error {
+	if b.used+bytes > b.limit {
+		return errors.New("spill budget exceeded")
+	}
+	b.used += bytes
+	return nil
+}
+
+func (b *testSpillBudget) Release(bytes int64) {
+	b.used -= bytes
+}
+
+func TestSpillingSortIterator(t *testing.T) {
+	dir := t.TempDir()
+	budget := &testSpillBudget{limit: 1 << 20}
+	it1 := mkStreamIterator(inverse(offset(testSize, identity)), defaultLabels)
+	it2 := mkStreamIterator(inverse(offset(testSize, identity)), "{foobar: \"bazbar\"}")
+	merged := NewMergeEntryIterator(context.Background(), []EntryIterator{it1, it2}, logproto.BACKWARD)
+
+	// a threshold of a few entries spills many runs.
+	sorted, err := NewSpillingSortIterator(merged, logproto.FORWARD, testSize, SpillConfig{Directory: dir, MemoryThreshold: 200, Budget: budget})
+	require.NoError(t, err)
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Greater(t, len(files), 1)
+	require.Greater(t, budget.used, int64(0))
+
+	// the most recent entries are kept, in time order.
+	var last time.Time
+	count := 0
+	for sorted.Next() {
+		require.False(t, sorted.Entry().Timestamp.Before(last))
+		require.GreaterOrEqual(t, sorted.Entry().Timestamp.Unix(), int64(testSize/2+1))
+		require.Contains(t, []string{defaultLabels, "{foobar: \"bazbar\"}"}, sorted.Labels())
+		last = sorted.Entry().Timestamp
+		count++
+	}
+	require.NoError(t, sorted.Error())
+	require.Equal(t, testSize, count)
+
+	// the spilled runs are removed and released on close.
+	require.NoError(t, sorted.Close())
+	files, err = ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, files)
+	require.Equal(t, int64(0), budget.used)
+}
+
+func TestSpillingSortIterator_InMemory(t *testing.T) {
+	sorted, err := NewSpillingSortIterator(mkStreamIterator(identity, defaultLabels), logproto.BACKWARD, 0, SpillConfig{Directory: t.TempDir(), MemoryThreshold: 1 << 20})
+	require.NoError(t, err)
+	for i := int64(testSize - 1); i >= 0; i-- {
+		require.True(t, sorted.Next())
+		require.Equal(t, identity(i), sorted.Entry())
+	}
+	require.False(t, sorted.Next())
+	require.NoError(t, sorted.Close())
+}
+
+func TestSpillingSortIterator_BudgetExceeded(t *testing.T) {
+	dir := t.TempDir()
+	budget := &testSpillBudget{limit: 100}
+	_, err := NewSpillingSortIterator(mkStreamIterator(identity, defaultLabels), logproto.BACKWARD, 0, SpillConfig{Directory: dir, MemoryThreshold: 200, Budget: budget})
+	require.Error(t, err)
+
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, files)
+	require.Equal(t, int64(0), budget.used)
+}
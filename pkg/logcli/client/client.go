@@ -19,6 +19,7 @@ import (
 	"github.com/grafana/loki/pkg/logproto"
 	"github.com/grafana/loki/pkg/util"
 	"github.com/grafana/loki/pkg/util/build"
+	serverutil "github.com/grafana/loki/pkg/util/server"
 )
 
 const (
@@ -58,6 +59,8 @@ type DefaultClient struct {
 	BearerTokenFile string
 	Retries         int
 	QueryTags       string
+	StrictParsing   bool
+	QuerySource     string
 }
 
 // Query uses the /api/v1/query endpoint to execute an instant query
@@ -211,6 +214,10 @@ func (c *DefaultClient) doRequest(path, query string, quiet bool, out interface{
 		}
 		if resp.StatusCode/100 != 2 {
 			buf, _ := ioutil.ReadAll(resp.Body) // nolint
+			// the requests failing with errors like parse errors or exceeded limits fail again when retried.
+			if resp.Header.Get(serverutil.ErrorRetryableHeader) == "false" {
+				attempts = 0
+			}
 			log.Printf("Error response from server: %s (%v) attempts remaining: %d", string(buf), err, attempts)
 			if err := resp.Body.Close(); err != nil {
 				log.Println("error closing body", err)
@@ -252,6 +259,14 @@ func (c *DefaultClient) getHTTPRequestHeader() (http.Header, error) {
 		h.Set("X-Query-Tags", c.QueryTags)
 	}
 
+	if c.StrictParsing {
+		h.Set("X-Query-Strict-Parsing", "true")
+	}
+
+	if c.QuerySource != "" {
+		h.Set("X-Query-Source", c.QuerySource)
+	}
+
 	if (c.Username != "" || c.Password != "") && (len(c.BearerToken) > 0 || len(c.BearerTokenFile) > 0) {
 		return nil, fmt.Errorf("at most one of HTTP basic auth (username/password), bearer-token & bearer-token-file is allowed to be configured")
 	}
@@ -3,9 +3,13 @@ package client
 import (
 	"encoding/base64"
 	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+
+	serverutil "github.com/grafana/loki/pkg/util/server"
 )
 
 func Test_buildURL(t *testing.T) {
@@ -54,6 +58,16 @@ func Test_getHTTPRequestHeader(t *testing.T) {
 		}, http.Header{
 			"Authorization": []string{"Basic " + base64.StdEncoding.EncodeToString([]byte("123:secure"))},
 		}, false},
+		{"strict-parsing", DefaultClient{
+			StrictParsing: true,
+		}, http.Header{
+			"X-Query-Strict-Parsing": []string{"true"},
+		}, false},
+		{"query-source", DefaultClient{
+			QuerySource: "store",
+		}, http.Header{
+			"X-Query-Source": []string{"store"},
+		}, false},
 		{"bearer-token", DefaultClient{
 			BearerToken: "secureToken",
 		}, http.Header{
@@ -78,3 +92,29 @@ func Test_getHTTPRequestHeader(t *testing.T) {
 		})
 	}
 }
+
+func Test_doRequestRetries(t *testing.T) {
+	for _, tc := range []struct {
+		name             string
+		status           int
+		message          string
+		expectedAttempts int
+	}{
+		{"retryable", http.StatusServiceUnavailable, "store unavailable", 3},
+		{"not retryable", http.StatusBadRequest, "parse error", 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			attempts := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts++
+				serverutil.JSONError(w, tc.status, tc.message)
+			}))
+			defer server.Close()
+
+			c := &DefaultClient{Address: server.URL, Retries: 2}
+			_, err := c.ListLabelNames(true, time.Now().Add(-time.Hour), time.Now())
+			assert.Error(t, err)
+			assert.Equal(t, tc.expectedAttempts, attempts)
+		})
+	}
+}
@@ -80,6 +80,7 @@ func (q *Query) DoQuery(c client.Client, out output.LogOutput, statistics bool)
 		if statistics {
 			q.printStats(resp.Data.Statistics)
 		}
+		q.printWarnings(resp.Warnings)
 		_, _ = q.printResult(resp.Data.Result, out, nil)
 	} else {
 		if q.Limit < q.BatchSize {
@@ -108,6 +109,7 @@ func (q *Query) DoQuery(c client.Client, out output.LogOutput, statistics bool)
 			if statistics {
 				q.printStats(resp.Data.Statistics)
 			}
+			q.printWarnings(resp.Warnings)
 
 			resultLength, lastEntry = q.printResult(resp.Data.Result, out, lastEntry)
 			// Was not a log stream query, or no results, no more batching
@@ -412,6 +414,15 @@ func (q *Query) printStats(stats stats.Result) {
 	stats.Log(kvLogger{Writer: writer})
 }
 
+func (q *Query) printWarnings(warnings []string) {
+	if q.Quiet {
+		return
+	}
+	for _, w := range warnings {
+		log.Println("Warning:", color.YellowString(w))
+	}
+}
+
 func (q *Query) resultsDirection() logproto.Direction {
 	if q.Forward {
 		return logproto.FORWARD
@@ -0,0 +1,40 @@
+package loghttp
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/loki/pkg/logql/syntax"
+)
+
+// IndexStatsResponse represents the http json response to an index stats query
+type IndexStatsResponse struct {
+	Status string                      `json:"status"`
+	Data   logproto.IndexStatsResponse `json:"data"`
+}
+
+var errIndexStatsQueryRequired = errors.New("the query parameter is required, e.g. {app=\"foo\"}")
+
+// ParseIndexStatsQuery parses an index stats request, of which the query is a stream selector.
+func ParseIndexStatsQuery(r *http.Request) (*logproto.IndexStatsRequest, error) {
+	start, end, err := bounds(r)
+	if err != nil {
+		return nil, err
+	}
+
+	q := query(r)
+	if q == "" {
+		return nil, errIndexStatsQueryRequired
+	}
+	// ensure the matchers are valid, before fanning out to the store.
+	if _, err := syntax.ParseMatchers(q); err != nil {
+		return nil, err
+	}
+
+	return &logproto.IndexStatsRequest{
+		Start:    start,
+		End:      end,
+		Matchers: q,
+	}, nil
+}
@@ -0,0 +1,32 @@
+package loghttp
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/pkg/logproto"
+)
+
+func TestParseIndexStatsQuery(t *testing.T) {
+	req, err := ParseIndexStatsQuery(withForm(url.Values{
+		"start": []string{"1000"},
+		"end":   []string{"2000"},
+		"query": []string{`{app="foo", env=~"prod|dev"}`},
+	}))
+	require.NoError(t, err)
+	require.Equal(t, &logproto.IndexStatsRequest{
+		Start:    time.Unix(1000, 0),
+		End:      time.Unix(2000, 0),
+		Matchers: `{app="foo", env=~"prod|dev"}`,
+	}, req)
+
+	_, err = ParseIndexStatsQuery(withForm(url.Values{}))
+	require.Equal(t, errIndexStatsQueryRequired, err)
+
+	// the queries with pipelines aren't selectors.
+	_, err = ParseIndexStatsQuery(withForm(url.Values{"query": []string{`{app="foo"} |= "bar"`}}))
+	require.Error(t, err)
+}
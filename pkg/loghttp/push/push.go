@@ -1,18 +1,23 @@
 package push
 
 import (
+	"bytes"
 	"compress/flate"
 	"compress/gzip"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"math"
 	"mime"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/dustin/go-humanize"
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/prometheus/model/labels"
@@ -47,13 +52,42 @@ var (
 
 const applicationJSON = "application/json"
 
+// DefaultMaxDecompressedSize is the default maximum size of the decompressed push payloads.
+const DefaultMaxDecompressedSize = 100 << 20
+
+// zstdDecoders pools the zstd decoders, which are costly to create. Each decodes with a single goroutine.
+var zstdDecoders = sync.Pool{
+	New: func() interface{} {
+		decoder, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			panic(err)
+		}
+		return decoder
+	},
+}
+
 type TenantsRetention interface {
 	RetentionPeriodFor(userID string, lbs labels.Labels) time.Duration
 }
 
-func ParseRequest(logger log.Logger, userID string, r *http.Request, tenantsRetention TenantsRetention) (*logproto.PushRequest, error) {
+// ParseRequest parses a push request, rejecting the payloads decompressed to more than maxDecompressedSize bytes.
+func ParseRequest(logger log.Logger, userID string, r *http.Request, tenantsRetention TenantsRetention, maxDecompressedSize int) (*logproto.PushRequest, error) {
+	req, _, err := ParseRequestWithPayload(logger, userID, r, tenantsRetention, maxDecompressedSize)
+	return req, err
+}
+
+// ParseRequestWithPayload parses a push request like ParseRequest, and also returns the body of the zstd-encoded
+// protobuf requests, which is the zstd-compressed protobuf encoding of the request. The payload is nil for the
+// other requests.
+func ParseRequestWithPayload(logger log.Logger, userID string, r *http.Request, tenantsRetention TenantsRetention, maxDecompressedSize int) (*logproto.PushRequest, []byte, error) {
+	contentType, _ /* params */, err := mime.ParseMediaType(r.Header.Get(contentType))
+	if err != nil {
+		return nil, nil, err
+	}
+
 	// Body
 	var body io.Reader
+	var payload *bytes.Buffer
 	// bodySize should always reflect the compressed size of the request body
 	bodySize := loki_util.NewSizeReader(r.Body)
 	contentEncoding := r.Header.Get(contentEnc)
@@ -61,26 +95,47 @@ func ParseRequest(logger log.Logger, userID string, r *http.Request, tenantsRete
 	case "":
 		body = bodySize
 	case "snappy":
-		// Snappy-decoding is done by `util.ParseProtoReader(..., util.RawSnappy)` below.
+		// Snappy-decoding is done by `util.ParseProtoReader(..., util.RawSnappy)` below
+		// for protobuf payloads, and by `decodeSnappyJSON` for JSON payloads.
 		// Pass on body bytes. Note: HTTP clients do not need to set this header,
 		// but they sometimes do. See #3407.
 		body = bodySize
+	case "zstd":
+		var compressed io.Reader = bodySize
+		if contentType != applicationJSON {
+			// the compressed body is kept to be forwarded as it is.
+			payload = &bytes.Buffer{}
+			if r.ContentLength > 0 {
+				payload.Grow(int(r.ContentLength))
+			}
+			compressed = io.TeeReader(bodySize, payload)
+		}
+		zstdReader := zstdDecoders.Get().(*zstd.Decoder)
+		if err := zstdReader.Reset(compressed); err != nil {
+			zstdDecoders.Put(zstdReader)
+			return nil, nil, err
+		}
+		defer func() {
+			// the decoder releases the body before being reused.
+			_ = zstdReader.Reset(nil)
+			zstdDecoders.Put(zstdReader)
+		}()
+		body = &maxSizeReader{r: zstdReader, max: maxDecompressedSize}
 	case "gzip":
 		gzipReader, err := gzip.NewReader(bodySize)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		defer gzipReader.Close()
-		body = gzipReader
+		body = &maxSizeReader{r: gzipReader, max: maxDecompressedSize}
 	case "deflate":
 		flateReader := flate.NewReader(bodySize)
 		defer flateReader.Close()
-		body = flateReader
+		body = &maxSizeReader{r: flateReader, max: maxDecompressedSize}
 	default:
-		return nil, fmt.Errorf("Content-Encoding %q not supported", contentEncoding)
+		return nil, nil, fmt.Errorf("Content-Encoding %q not supported", contentEncoding)
 	}
 
-	contentType := r.Header.Get(contentType)
 	var (
 		entriesSize      int64
 		streamLabelsSize int64
@@ -88,16 +143,17 @@ func ParseRequest(logger log.Logger, userID string, r *http.Request, tenantsRete
 		req              logproto.PushRequest
 	)
 
-	contentType, _ /* params */, err := mime.ParseMediaType(contentType)
-	if err != nil {
-		return nil, err
-	}
-
 	switch contentType {
 	case applicationJSON:
 
 		var err error
 
+		if contentEncoding == "snappy" {
+			if body, err = decodeSnappyJSON(body, maxDecompressedSize); err != nil {
+				return nil, nil, err
+			}
+		}
+
 		// todo once https://github.com/weaveworks/common/commit/73225442af7da93ec8f6a6e2f7c8aafaee3f8840 is in Loki.
 		// We can try to pass the body as bytes.buffer instead to avoid reading into another buffer.
 		if loghttp.GetVersion(r.RequestURI) == loghttp.VersionV1 {
@@ -107,14 +163,19 @@ func ParseRequest(logger log.Logger, userID string, r *http.Request, tenantsRete
 		}
 
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 	default:
 		// When no content-type header is set or when it is set to
-		// `application/x-protobuf`: expect snappy compression.
-		if err := util.ParseProtoReader(r.Context(), body, int(r.ContentLength), math.MaxInt32, &req, util.RawSnappy); err != nil {
-			return nil, err
+		// `application/x-protobuf`: expect snappy compression, unless the
+		// payload is zstd-encoded in which case zstd replaces snappy.
+		compression := util.RawSnappy
+		if contentEncoding == "zstd" {
+			compression = util.NoCompression
+		}
+		if err := util.ParseProtoReader(r.Context(), body, int(r.ContentLength), maxDecompressedSize, &req, compression); err != nil {
+			return nil, nil, err
 		}
 	}
 
@@ -126,7 +187,7 @@ func ParseRequest(logger log.Logger, userID string, r *http.Request, tenantsRete
 		if tenantsRetention != nil {
 			lbs, err := syntax.ParseLabels(s.Labels)
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 			retentionHours = fmt.Sprintf("%d", int64(math.Floor(tenantsRetention.RetentionPeriodFor(userID, lbs).Hours())))
 		}
@@ -160,5 +221,48 @@ func ParseRequest(logger log.Logger, userID string, r *http.Request, tenantsRete
 		"totalSize", humanize.Bytes(uint64(entriesSize+streamLabelsSize)),
 		"mostRecentLagMs", time.Since(mostRecentEntry).Milliseconds(),
 	)
-	return &req, nil
+	if payload == nil {
+		return &req, nil, nil
+	}
+	return &req, payload.Bytes(), nil
+}
+
+// decodeSnappyJSON decodes a JSON body compressed with the snappy block format,
+// which is the same format used for protobuf push payloads.
+func decodeSnappyJSON(body io.Reader, maxDecompressedSize int) (io.Reader, error) {
+	compressed, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	// the decoded length is checked before allocating the decoded payload.
+	size, err := snappy.DecodedLen(compressed)
+	if err != nil {
+		return nil, err
+	}
+	if size > maxDecompressedSize {
+		return nil, fmt.Errorf(messageSizeLargerErrFmt, size, maxDecompressedSize)
+	}
+	decoded, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(decoded), nil
+}
+
+const messageSizeLargerErrFmt = "decompressed push payload larger than max (%d vs %d)"
+
+// maxSizeReader fails once more than max bytes are read.
+type maxSizeReader struct {
+	r    io.Reader
+	read int
+	max  int
+}
+
+func (m *maxSizeReader) Read(p []byte) (int, error) {
+	n, err := m.r.Read(p)
+	m.read += n
+	if m.read > m.max {
+		return 0, fmt.Errorf(messageSizeLargerErrFmt, m.read, m.max)
+	}
+	return n, err
 }
@@ -8,9 +8,14 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/grafana/loki/pkg/logproto"
 	util_log "github.com/grafana/loki/pkg/util/log"
 )
 
@@ -40,6 +45,18 @@ func deflateString(source string) string {
 	return buf.String()
 }
 
+// Zstd source string and return compressed string
+func zstdString(source string) string {
+	zw, _ := zstd.NewWriter(nil)
+	defer zw.Close()
+	return string(zw.EncodeAll([]byte(source), nil))
+}
+
+// Snappy source string and return compressed string
+func snappyString(source string) string {
+	return string(snappy.Encode(nil, []byte(source)))
+}
+
 func TestParseRequest(t *testing.T) {
 	tests := []struct {
 		path            string
@@ -94,6 +111,27 @@ func TestParseRequest(t *testing.T) {
 			contentEncoding: `snappy`,
 			valid:           false,
 		},
+		{
+			path:            `/loki/api/v1/push`,
+			body:            snappyString(`{"streams": [{ "stream": { "foo": "bar2" }, "values": [ [ "1570818238000000000", "fizzbuzz" ] ] }]}`),
+			contentType:     `application/json`,
+			contentEncoding: `snappy`,
+			valid:           true,
+		},
+		{
+			path:            `/loki/api/v1/push`,
+			body:            zstdString(`{"streams": [{ "stream": { "foo": "bar2" }, "values": [ [ "1570818238000000000", "fizzbuzz" ] ] }]}`),
+			contentType:     `application/json`,
+			contentEncoding: `zstd`,
+			valid:           true,
+		},
+		{
+			path:            `/loki/api/v1/push`,
+			body:            gzipString(`{"streams": [{ "stream": { "foo": "bar2" }, "values": [ [ "1570818238000000000", "fizzbuzz" ] ] }]}`),
+			contentType:     `application/json`,
+			contentEncoding: `zstd`,
+			valid:           false,
+		},
 		{
 			path:            `/loki/api/v1/push`,
 			body:            gzipString(`{"streams": [{ "stream": { "foo": "bar2" }, "values": [ [ "1570818238000000000", "fizzbuzz" ] ] }]}`),
@@ -161,7 +199,7 @@ func TestParseRequest(t *testing.T) {
 		if len(test.contentEncoding) > 0 {
 			request.Header.Add("Content-Encoding", test.contentEncoding)
 		}
-		data, err := ParseRequest(util_log.Logger, "", request, nil)
+		data, err := ParseRequest(util_log.Logger, "", request, nil, DefaultMaxDecompressedSize)
 		if test.valid {
 			assert.Nil(t, err, "Should not give error for %d", index)
 			assert.NotNil(t, data, "Should give data for %d", index)
@@ -171,3 +209,76 @@ func TestParseRequest(t *testing.T) {
 		}
 	}
 }
+
+func TestParseRequest_ProtoZstd(t *testing.T) {
+	req := logproto.PushRequest{
+		Streams: []logproto.Stream{
+			{
+				Labels:  `{foo="bar"}`,
+				Entries: []logproto.Entry{{Timestamp: time.Unix(0, 1), Line: "fizzbuzz"}},
+			},
+		},
+	}
+	buf, err := req.Marshal()
+	require.NoError(t, err)
+
+	body := zstdString(string(buf))
+	request := httptest.NewRequest("POST", `/loki/api/v1/push`, strings.NewReader(body))
+	request.Header.Add("Content-Type", "application/x-protobuf")
+	request.Header.Add("Content-Encoding", "zstd")
+
+	data, payload, err := ParseRequestWithPayload(util_log.Logger, "", request, nil, DefaultMaxDecompressedSize)
+	require.NoError(t, err)
+	require.Len(t, data.Streams, 1)
+	require.Equal(t, `{foo="bar"}`, data.Streams[0].Labels)
+	require.Equal(t, "fizzbuzz", data.Streams[0].Entries[0].Line)
+	// the compressed body is returned as it is to be forwarded.
+	require.Equal(t, body, string(payload))
+}
+
+func TestParseRequestWithPayload_NotZstdProto(t *testing.T) {
+	body := `{"streams": [{ "stream": { "foo": "bar" }, "values": [ [ "1570818238000000000", "fizzbuzz" ] ] }]}`
+	for _, tc := range []struct {
+		contentEncoding string
+		body            string
+	}{
+		{contentEncoding: "zstd", body: zstdString(body)},
+		{contentEncoding: "gzip", body: gzipString(body)},
+	} {
+		t.Run(tc.contentEncoding, func(t *testing.T) {
+			request := httptest.NewRequest("POST", `/loki/api/v1/push`, strings.NewReader(tc.body))
+			request.Header.Add("Content-Type", "application/json")
+			request.Header.Add("Content-Encoding", tc.contentEncoding)
+			data, payload, err := ParseRequestWithPayload(util_log.Logger, "", request, nil, DefaultMaxDecompressedSize)
+			require.NoError(t, err)
+			require.Len(t, data.Streams, 1)
+			require.Nil(t, payload)
+		})
+	}
+}
+
+func TestParseRequest_MaxDecompressedSize(t *testing.T) {
+	body := `{"streams": [{ "stream": { "foo": "bar" }, "values": [ [ "1570818238000000000", "` + strings.Repeat("a", 10000) + `" ] ] }]}`
+	for _, tc := range []struct {
+		contentType     string
+		contentEncoding string
+		body            string
+	}{
+		{contentType: "application/json", contentEncoding: "snappy", body: snappyString(body)},
+		{contentType: "application/json", contentEncoding: "zstd", body: zstdString(body)},
+		{contentType: "application/json", contentEncoding: "gzip", body: gzipString(body)},
+		{contentType: "application/json", contentEncoding: "deflate", body: deflateString(body)},
+	} {
+		t.Run(tc.contentEncoding, func(t *testing.T) {
+			parse := func(maxSize int) error {
+				request := httptest.NewRequest("POST", `/loki/api/v1/push`, strings.NewReader(tc.body))
+				request.Header.Add("Content-Type", tc.contentType)
+				request.Header.Add("Content-Encoding", tc.contentEncoding)
+				_, err := ParseRequest(util_log.Logger, "", request, nil, maxSize)
+				return err
+			}
+			require.NoError(t, parse(len(body)))
+			require.Error(t, parse(1000))
+		})
+	}
+}
@@ -33,8 +33,9 @@ const (
 
 // QueryResponse represents the http json response to a Loki range and instant query
 type QueryResponse struct {
-	Status string            `json:"status"`
-	Data   QueryResponseData `json:"data"`
+	Status   string            `json:"status"`
+	Data     QueryResponseData `json:"data"`
+	Warnings []string          `json:"warnings,omitempty"`
 }
 
 func (q *QueryResponse) UnmarshalJSON(data []byte) error {
@@ -48,6 +49,25 @@ func (q *QueryResponse) UnmarshalJSON(data []byte) error {
 				return err
 			}
 			q.Data = responseData
+		case "warnings":
+			var (
+				warnings []string
+				parseErr error
+			)
+			if _, err := jsonparser.ArrayEach(value, func(value []byte, dataType jsonparser.ValueType, _ int, err error) {
+				w, err := jsonparser.ParseString(value)
+				if err != nil {
+					parseErr = err
+					return
+				}
+				warnings = append(warnings, w)
+			}); err != nil {
+				return err
+			}
+			if parseErr != nil {
+				return parseErr
+			}
+			q.Warnings = warnings
 		}
 		return nil
 	})
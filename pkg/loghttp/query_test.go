@@ -256,6 +256,15 @@ func Test_QueryResponseUnmarshal(t *testing.T) {
 				},
 			},
 		},
+		{
+			Status: "ok",
+			Data: QueryResponseData{
+				ResultType: "streams",
+				Result:     Streams{},
+				Statistics: stats.Result{},
+			},
+			Warnings: []string{"maximum of series (1) reached", `quoted "warning"`},
+		},
 	} {
 		tt := tt
 		t.Run("", func(t *testing.T) {
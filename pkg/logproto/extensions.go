@@ -21,3 +21,7 @@ func (xs Streams) Less(i, j int) bool { return xs[i].Labels <= xs[j].Labels }
 func (s Series) Len() int           { return len(s.Samples) }
 func (s Series) Swap(i, j int)      { s.Samples[i], s.Samples[j] = s.Samples[j], s.Samples[i] }
 func (s Series) Less(i, j int) bool { return s.Samples[i].Timestamp < s.Samples[j].Timestamp }
+
+// StreamShardLabel is the label added by the distributors to the shards of the streams pushed faster than their rate
+// limit. The queriers merge the shards of a stream back.
+const StreamShardLabel = "__stream_shard__"
@@ -1586,6 +1586,134 @@ func (m *GetChunkIDsResponse) GetChunkIDs() []string {
 	return nil
 }
 
+// IndexStatsRequest requests the statistics of the chunks of the streams matching the matchers, from the index.
+type IndexStatsRequest struct {
+	Start    time.Time `protobuf:"bytes,1,opt,name=start,proto3,stdtime" json:"start"`
+	End      time.Time `protobuf:"bytes,2,opt,name=end,proto3,stdtime" json:"end"`
+	Matchers string    `protobuf:"bytes,3,opt,name=matchers,proto3" json:"matchers,omitempty"`
+}
+
+func (m *IndexStatsRequest) Reset()      { *m = IndexStatsRequest{} }
+func (*IndexStatsRequest) ProtoMessage() {}
+func (*IndexStatsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_c28a5f14f1f4c79a, []int{29}
+}
+func (m *IndexStatsRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *IndexStatsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_IndexStatsRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *IndexStatsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_IndexStatsRequest.Merge(m, src)
+}
+func (m *IndexStatsRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *IndexStatsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_IndexStatsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_IndexStatsRequest proto.InternalMessageInfo
+
+func (m *IndexStatsRequest) GetStart() time.Time {
+	if m != nil {
+		return m.Start
+	}
+	return time.Time{}
+}
+
+func (m *IndexStatsRequest) GetEnd() time.Time {
+	if m != nil {
+		return m.End
+	}
+	return time.Time{}
+}
+
+func (m *IndexStatsRequest) GetMatchers() string {
+	if m != nil {
+		return m.Matchers
+	}
+	return ""
+}
+
+// IndexStatsResponse are statistics estimated from the index, without fetching the chunks.
+type IndexStatsResponse struct {
+	Streams uint64 `protobuf:"varint,1,opt,name=streams,proto3" json:"streams"`
+	Chunks  uint64 `protobuf:"varint,2,opt,name=chunks,proto3" json:"chunks"`
+	Bytes   uint64 `protobuf:"varint,3,opt,name=bytes,proto3" json:"bytes"`
+	Entries uint64 `protobuf:"varint,4,opt,name=entries,proto3" json:"entries"`
+}
+
+func (m *IndexStatsResponse) Reset()      { *m = IndexStatsResponse{} }
+func (*IndexStatsResponse) ProtoMessage() {}
+func (*IndexStatsResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_c28a5f14f1f4c79a, []int{30}
+}
+func (m *IndexStatsResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *IndexStatsResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_IndexStatsResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *IndexStatsResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_IndexStatsResponse.Merge(m, src)
+}
+func (m *IndexStatsResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *IndexStatsResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_IndexStatsResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_IndexStatsResponse proto.InternalMessageInfo
+
+func (m *IndexStatsResponse) GetStreams() uint64 {
+	if m != nil {
+		return m.Streams
+	}
+	return 0
+}
+
+func (m *IndexStatsResponse) GetChunks() uint64 {
+	if m != nil {
+		return m.Chunks
+	}
+	return 0
+}
+
+func (m *IndexStatsResponse) GetBytes() uint64 {
+	if m != nil {
+		return m.Bytes
+	}
+	return 0
+}
+
+func (m *IndexStatsResponse) GetEntries() uint64 {
+	if m != nil {
+		return m.Entries
+	}
+	return 0
+}
+
 // ChunkRef contains the metadata to reference a Chunk.
 // It is embedded by the Chunk type itself and used to generate the Chunk
 // checksum. So it is imported to take care of the JSON representation of the
@@ -1603,7 +1731,7 @@ type ChunkRef struct {
 func (m *ChunkRef) Reset()      { *m = ChunkRef{} }
 func (*ChunkRef) ProtoMessage() {}
 func (*ChunkRef) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c28a5f14f1f4c79a, []int{29}
+	return fileDescriptor_c28a5f14f1f4c79a, []int{31}
 }
 func (m *ChunkRef) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -1685,117 +1813,123 @@ func init() {
 	proto.RegisterType((*TailersCountResponse)(nil), "logproto.TailersCountResponse")
 	proto.RegisterType((*GetChunkIDsRequest)(nil), "logproto.GetChunkIDsRequest")
 	proto.RegisterType((*GetChunkIDsResponse)(nil), "logproto.GetChunkIDsResponse")
+	proto.RegisterType((*IndexStatsRequest)(nil), "logproto.IndexStatsRequest")
+	proto.RegisterType((*IndexStatsResponse)(nil), "logproto.IndexStatsResponse")
 	proto.RegisterType((*ChunkRef)(nil), "logproto.ChunkRef")
 }
 
 func init() { proto.RegisterFile("pkg/logproto/logproto.proto", fileDescriptor_c28a5f14f1f4c79a) }
 
 var fileDescriptor_c28a5f14f1f4c79a = []byte{
-	// 1654 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xc4, 0x58, 0x49, 0x6f, 0x1b, 0xc9,
-	0x15, 0x66, 0x71, 0x69, 0x92, 0x8f, 0x8b, 0x88, 0x92, 0x2c, 0x71, 0x38, 0x33, 0x6c, 0x4e, 0x63,
-	0x30, 0x26, 0xbc, 0x90, 0xb1, 0xb2, 0xd8, 0x96, 0xb3, 0x40, 0xb4, 0x62, 0x5b, 0xb6, 0x12, 0xdb,
-	0x2d, 0x05, 0x06, 0x0c, 0x04, 0x46, 0x8b, 0x2c, 0x91, 0x0d, 0xb1, 0xd9, 0x74, 0x57, 0xd3, 0x80,
-	0x80, 0x00, 0xc9, 0x0f, 0x48, 0x00, 0xe7, 0x14, 0xe4, 0x9e, 0x43, 0x90, 0x43, 0x0e, 0xf9, 0x13,
-	0x71, 0x6e, 0x3e, 0x1a, 0x3e, 0x30, 0x31, 0x7d, 0x09, 0x88, 0x1c, 0xfc, 0x0b, 0x82, 0xa0, 0xb6,
-	0x66, 0x91, 0x96, 0x62, 0xd3, 0x97, 0xb9, 0x88, 0xf5, 0x5e, 0xbd, 0xad, 0xbe, 0x7a, 0x4b, 0xb5,
-	0xe0, 0xf3, 0xe1, 0x71, 0xb7, 0xd9, 0xf7, 0xbb, 0xc3, 0xc0, 0x0f, 0xfd, 0x68, 0xd1, 0xe0, 0x7f,
-	0x71, 0x46, 0xd1, 0x15, 0xb3, 0xeb, 0xfb, 0xdd, 0x3e, 0x69, 0x72, 0xea, 0x70, 0x74, 0xd4, 0x0c,
-	0x5d, 0x8f, 0xd0, 0xd0, 0xf1, 0x86, 0x42, 0xb4, 0x72, 0xb9, 0xeb, 0x86, 0xbd, 0xd1, 0x61, 0xa3,
-	0xed, 0x7b, 0xcd, 0xae, 0xdf, 0xf5, 0x67, 0x92, 0x8c, 0x12, 0xd6, 0xd9, 0x4a, 0x8a, 0xd7, 0xa4,
-	0xdb, 0xa7, 0x7d, 0xcf, 0xef, 0x90, 0x7e, 0x93, 0x86, 0x4e, 0x48, 0xc5, 0x5f, 0x21, 0x61, 0x3d,
-	0x82, 0xdc, 0x83, 0x11, 0xed, 0xd9, 0xe4, 0xe9, 0x88, 0xd0, 0x10, 0xdf, 0x81, 0x34, 0x0d, 0x03,
-	0xe2, 0x78, 0xb4, 0x8c, 0x6a, 0x89, 0x7a, 0x6e, 0x73, 0xa3, 0x11, 0x05, 0xbb, 0xcf, 0x37, 0xb6,
-	0x3b, 0xce, 0x30, 0x24, 0x41, 0xeb, 0xdc, 0xeb, 0xb1, 0x69, 0x08, 0xd6, 0x74, 0x6c, 0x2a, 0x2d,
-	0x5b, 0x2d, 0xac, 0x22, 0xe4, 0x85, 0x61, 0x3a, 0xf4, 0x07, 0x94, 0x58, 0x7f, 0x8f, 0x43, 0xfe,
-	0xe1, 0x88, 0x04, 0x27, 0xca, 0x55, 0x05, 0x32, 0x94, 0xf4, 0x49, 0x3b, 0xf4, 0x83, 0x32, 0xaa,
-	0xa1, 0x7a, 0xd6, 0x8e, 0x68, 0xbc, 0x06, 0xa9, 0xbe, 0xeb, 0xb9, 0x61, 0x39, 0x5e, 0x43, 0xf5,
-	0x82, 0x2d, 0x08, 0xbc, 0x05, 0x29, 0x1a, 0x3a, 0x41, 0x58, 0x4e, 0xd4, 0x50, 0x3d, 0xb7, 0x59,
-	0x69, 0x08, 0xb4, 0x1a, 0x0a, 0x83, 0xc6, 0x81, 0x42, 0xab, 0x95, 0x79, 0x31, 0x36, 0x63, 0xcf,
-	0xff, 0x69, 0x22, 0x5b, 0xa8, 0xe0, 0x1f, 0x40, 0x82, 0x0c, 0x3a, 0xe5, 0xe4, 0x12, 0x9a, 0x4c,
-	0x01, 0x5f, 0x81, 0x6c, 0xc7, 0x0d, 0x48, 0x3b, 0x74, 0xfd, 0x41, 0x39, 0x55, 0x43, 0xf5, 0xe2,
-	0xe6, 0xea, 0x0c, 0x92, 0x1d, 0xb5, 0x65, 0xcf, 0xa4, 0xf0, 0x25, 0x30, 0x68, 0xcf, 0x09, 0x3a,
-	0xb4, 0x9c, 0xae, 0x25, 0xea, 0xd9, 0xd6, 0xda, 0x74, 0x6c, 0x96, 0x04, 0xe7, 0x92, 0xef, 0xb9,
-	0x21, 0xf1, 0x86, 0xe1, 0x89, 0x2d, 0x65, 0xf0, 0x05, 0x48, 0x77, 0x48, 0x9f, 0x84, 0x84, 0x96,
-	0x33, 0x1c, 0xf1, 0x92, 0x66, 0x9e, 0x6f, 0xd8, 0x4a, 0xe0, 0x6e, 0x32, 0x63, 0x94, 0xd2, 0xd6,
-	0x7f, 0x11, 0xe0, 0x7d, 0xc7, 0x1b, 0xf6, 0xc9, 0x47, 0xe3, 0x19, 0x21, 0x17, 0xff, 0x64, 0xe4,
-	0x12, 0xcb, 0x22, 0x37, 0x83, 0x21, 0xb9, 0x1c, 0x0c, 0xa9, 0x0f, 0xc0, 0x60, 0xed, 0x81, 0x21,
-	0x58, 0x1f, 0xca, 0xa1, 0xd9, 0x99, 0x13, 0xea, 0x34, 0xa5, 0xd9, 0x69, 0x12, 0x3c, 0x4e, 0xeb,
-	0xd7, 0x50, 0x90, 0x38, 0x8a, 0x4c, 0xc5, 0xdb, 0x1f, 0x5d, 0x03, 0xc5, 0x17, 0x63, 0x13, 0xcd,
-	0xea, 0x20, 0x4a, 0x7e, 0x7c, 0x91, 0xfb, 0x0e, 0xa9, 0xc4, 0x7b, 0xa5, 0x21, 0x4a, 0x6e, 0x77,
-	0xd0, 0x25, 0x94, 0x29, 0x26, 0x19, 0x54, 0xb6, 0x90, 0xb1, 0x7e, 0x05, 0xab, 0x73, 0xd7, 0x29,
-	0xc3, 0xb8, 0x06, 0x06, 0x25, 0x81, 0x4b, 0x54, 0x14, 0x1a, 0x20, 0xfb, 0x9c, 0xaf, 0xb9, 0xe7,
-	0xb4, 0x2d, 0xe5, 0x97, 0xf3, 0xfe, 0x57, 0x04, 0xf9, 0x3d, 0xe7, 0x90, 0xf4, 0x55, 0x1e, 0x61,
-	0x48, 0x0e, 0x1c, 0x8f, 0x48, 0x3c, 0xf9, 0x1a, 0xaf, 0x83, 0xf1, 0xcc, 0xe9, 0x8f, 0x88, 0x30,
-	0x99, 0xb1, 0x25, 0xb5, 0x6c, 0x45, 0xa2, 0x4f, 0xae, 0x48, 0x14, 0xe5, 0x95, 0x75, 0x1e, 0x0a,
-	0x32, 0x5e, 0x09, 0xd4, 0x2c, 0x38, 0x06, 0x54, 0x56, 0x05, 0x67, 0xfd, 0x1e, 0x41, 0x61, 0xee,
-	0xbe, 0xb0, 0x05, 0x46, 0x9f, 0xa9, 0x52, 0x71, 0xb8, 0x16, 0x4c, 0xc7, 0xa6, 0xe4, 0xd8, 0xf2,
-	0x97, 0xdd, 0x3e, 0x19, 0x84, 0x1c, 0xf7, 0x38, 0xc7, 0x7d, 0x7d, 0x86, 0xfb, 0x4f, 0x07, 0x61,
-	0x70, 0xa2, 0x2e, 0x7f, 0x85, 0xa1, 0xc8, 0x5a, 0x9f, 0x14, 0xb7, 0xd5, 0x02, 0x7f, 0x06, 0xc9,
-	0x9e, 0x43, 0x7b, 0x1c, 0x94, 0x64, 0x2b, 0x35, 0x1d, 0x9b, 0xe8, 0xb2, 0xcd, 0x59, 0xd6, 0x33,
-	0xc8, 0xeb, 0x46, 0xf0, 0x1d, 0xc8, 0x46, 0x2d, 0x9e, 0x07, 0xf5, 0xff, 0xa1, 0x28, 0x4a, 0x9f,
-	0xf1, 0x90, 0x72, 0x40, 0x66, 0xca, 0xf8, 0x0b, 0x48, 0xf6, 0xdd, 0x01, 0xe1, 0x17, 0x94, 0x6d,
-	0x65, 0xa6, 0x63, 0x93, 0xd3, 0x36, 0xff, 0x6b, 0x79, 0x60, 0x88, 0x1c, 0xc3, 0x5f, 0x2f, 0x7a,
-	0x4c, 0xb4, 0x0c, 0x61, 0x51, 0xb7, 0x66, 0x42, 0x8a, 0xa3, 0xc8, 0xcd, 0xa1, 0x56, 0x76, 0x3a,
-	0x36, 0x05, 0xc3, 0x16, 0x3f, 0xcc, 0x9d, 0x76, 0x46, 0xee, 0x8e, 0xd1, 0xf2, 0x98, 0xb7, 0x21,
-	0xbf, 0x47, 0xba, 0x4e, 0xfb, 0x44, 0x3a, 0x5d, 0x53, 0xe6, 0x98, 0x43, 0xa4, 0x6c, 0x7c, 0x05,
-	0xf9, 0xc8, 0xe3, 0x13, 0x8f, 0xca, 0x42, 0xcd, 0x45, 0xbc, 0x9f, 0x51, 0xeb, 0x8f, 0x08, 0x64,
-	0x76, 0x7f, 0xd4, 0xe5, 0xdd, 0x80, 0x34, 0xe5, 0x1e, 0xd5, 0xe5, 0xe9, 0x45, 0xc3, 0x37, 0x66,
-	0xd7, 0x26, 0x05, 0x6d, 0xb5, 0xc0, 0x0d, 0x00, 0x51, 0xbf, 0x77, 0x66, 0x07, 0x2b, 0x4e, 0xc7,
-	0xa6, 0xc6, 0xb5, 0xb5, 0xb5, 0xf5, 0x07, 0x04, 0xb9, 0x03, 0xc7, 0x8d, 0x0a, 0x67, 0x0d, 0x52,
-	0x4f, 0x59, 0x05, 0xcb, 0xca, 0x11, 0x04, 0x6b, 0x51, 0x1d, 0xd2, 0x77, 0x4e, 0x6e, 0xf9, 0x01,
-	0xb7, 0x59, 0xb0, 0x23, 0x7a, 0x36, 0xe6, 0x92, 0xa7, 0x8e, 0xb9, 0xd4, 0xd2, 0xcd, 0xfa, 0x6e,
-	0x32, 0x13, 0x2f, 0x25, 0xac, 0xdf, 0x22, 0xc8, 0x8b, 0xc8, 0x64, 0x89, 0xdc, 0x00, 0x43, 0x04,
-	0x2e, 0x73, 0xec, 0xcc, 0x8e, 0x06, 0x5a, 0x37, 0x93, 0x2a, 0xf8, 0x27, 0x50, 0xec, 0x04, 0xfe,
-	0x70, 0x48, 0x3a, 0xfb, 0xb2, 0x2d, 0xc6, 0x17, 0xdb, 0xe2, 0x8e, 0xbe, 0x6f, 0x2f, 0x88, 0x5b,
-	0xff, 0x60, 0x85, 0x28, 0x5a, 0x94, 0x84, 0x2a, 0x3a, 0x22, 0xfa, 0xe4, 0x79, 0x14, 0x5f, 0x76,
-	0x1e, 0xad, 0x83, 0xd1, 0x0d, 0xfc, 0xd1, 0x90, 0x96, 0x13, 0xa2, 0x4d, 0x08, 0x6a, 0xb9, 0x39,
-	0x65, 0xdd, 0x85, 0xa2, 0x3a, 0xca, 0x19, 0x7d, 0xba, 0xb2, 0xd8, 0xa7, 0x77, 0x3b, 0x64, 0x10,
-	0xba, 0x47, 0x6e, 0xd4, 0x79, 0xa5, 0xbc, 0xf5, 0x3b, 0x04, 0xa5, 0x45, 0x11, 0xfc, 0x63, 0x2d,
-	0xcd, 0x99, 0xb9, 0x6f, 0xce, 0x36, 0xd7, 0xe0, 0x7d, 0x90, 0xf2, 0x86, 0xa2, 0x4a, 0xa0, 0x72,
-	0x1d, 0x72, 0x1a, 0x9b, 0xcd, 0xbb, 0x63, 0xa2, 0x52, 0x92, 0x2d, 0x67, 0xb5, 0x18, 0x17, 0x69,
-	0xca, 0x89, 0xad, 0xf8, 0x35, 0xc4, 0x12, 0xba, 0x30, 0x77, 0x93, 0xf8, 0x1a, 0x24, 0x8f, 0x02,
-	0xdf, 0x5b, 0xea, 0x9a, 0xb8, 0x06, 0xfe, 0x1e, 0xc4, 0x43, 0x7f, 0xa9, 0x4b, 0x8a, 0x87, 0x3e,
-	0xbb, 0x23, 0x79, 0xf8, 0x04, 0x0f, 0x4e, 0x52, 0xd6, 0x5f, 0x10, 0xac, 0x30, 0x1d, 0x81, 0xc0,
-	0xcd, 0xde, 0x68, 0x70, 0x8c, 0xeb, 0x50, 0x62, 0x9e, 0x9e, 0xb8, 0x72, 0xac, 0x3d, 0x71, 0x3b,
-	0xf2, 0x98, 0x45, 0xc6, 0x57, 0xd3, 0x6e, 0xb7, 0x83, 0x37, 0x20, 0x3d, 0xa2, 0x42, 0x40, 0x9c,
-	0xd9, 0x60, 0xe4, 0x6e, 0x07, 0x5f, 0xd4, 0xdc, 0x31, 0xac, 0xb5, 0x97, 0x1d, 0xc7, 0xf0, 0x81,
-	0xe3, 0x06, 0x51, 0x6f, 0x39, 0x0f, 0x46, 0x9b, 0x39, 0x16, 0x79, 0xc2, 0xc6, 0x6a, 0x24, 0xcc,
-	0x03, 0xb2, 0xe5, 0xb6, 0xf5, 0x7d, 0xc8, 0x46, 0xda, 0xa7, 0x4e, 0xd3, 0x53, 0x6f, 0xc0, 0xba,
-	0x01, 0x2b, 0xa2, 0x67, 0x9e, 0xae, 0x9c, 0x3f, 0x4d, 0x39, 0xaf, 0x94, 0x3f, 0x87, 0x94, 0x40,
-	0x05, 0x43, 0xb2, 0xe3, 0x84, 0x8e, 0x52, 0x61, 0x6b, 0xab, 0x0c, 0xeb, 0x07, 0x81, 0x33, 0xa0,
-	0x47, 0x24, 0xe0, 0x42, 0x51, 0xee, 0x5a, 0xe7, 0x60, 0x95, 0xf5, 0x09, 0x12, 0xd0, 0x9b, 0xfe,
-	0x68, 0x10, 0xca, 0xf2, 0xb4, 0x2e, 0xc1, 0xda, 0x3c, 0x5b, 0xa6, 0xfa, 0x1a, 0xa4, 0xda, 0x8c,
-	0xc1, 0xad, 0x17, 0x6c, 0x41, 0x58, 0x7f, 0x42, 0x80, 0x6f, 0x93, 0x90, 0x9b, 0xde, 0xdd, 0xa1,
-	0xda, 0x7b, 0xd4, 0x73, 0xc2, 0x76, 0x8f, 0x04, 0x54, 0xbd, 0xcd, 0x14, 0xfd, 0x6d, 0xbc, 0x47,
-	0xad, 0x2b, 0xb0, 0x3a, 0x17, 0xa5, 0x3c, 0x53, 0x05, 0x32, 0x6d, 0xc9, 0x93, 0xef, 0x87, 0x88,
-	0xb6, 0xfe, 0x16, 0x87, 0x8c, 0xb8, 0x5b, 0x72, 0x84, 0xaf, 0x40, 0xee, 0x88, 0xe5, 0x5a, 0x30,
-	0x0c, 0x5c, 0x09, 0x41, 0xb2, 0xb5, 0x32, 0x1d, 0x9b, 0x3a, 0xdb, 0xd6, 0x09, 0x7c, 0x79, 0x21,
-	0xf1, 0x5a, 0x6b, 0x93, 0xb1, 0x69, 0xfc, 0x82, 0x25, 0xdf, 0x0e, 0x9b, 0x5e, 0x3c, 0x0d, 0x77,
-	0xa2, 0x74, 0xbc, 0x27, 0xab, 0x8d, 0x3f, 0x4e, 0x5b, 0x57, 0x59, 0xf8, 0xaf, 0xc7, 0xe6, 0x79,
-	0xed, 0x93, 0x6f, 0x18, 0xf8, 0x1e, 0x09, 0x7b, 0x64, 0x44, 0x9b, 0x6d, 0xdf, 0xf3, 0xfc, 0x41,
-	0x93, 0x7f, 0xd7, 0xf1, 0x43, 0xb3, 0x11, 0xcc, 0xd4, 0x65, 0x01, 0x1e, 0x40, 0x3a, 0xec, 0x05,
-	0xfe, 0xa8, 0xdb, 0xe3, 0xd3, 0x25, 0xd1, 0xda, 0x5a, 0xde, 0x9e, 0xb2, 0x60, 0xab, 0x05, 0xfe,
-	0x8a, 0xa1, 0x45, 0xda, 0xc7, 0x74, 0xe4, 0xf1, 0xf1, 0x54, 0x50, 0xcf, 0x9b, 0x88, 0x7d, 0xe1,
-	0x1b, 0xc8, 0x46, 0x9f, 0x45, 0x38, 0x07, 0xe9, 0x5b, 0xf7, 0xed, 0x47, 0xdb, 0xf6, 0x4e, 0x29,
-	0x86, 0xf3, 0x90, 0x69, 0x6d, 0xdf, 0xbc, 0xc7, 0x29, 0xb4, 0xb9, 0x0d, 0x06, 0xfb, 0x40, 0x24,
-	0x01, 0xbe, 0x0a, 0x49, 0xb6, 0xc2, 0xe7, 0x66, 0x15, 0xa5, 0x7d, 0x93, 0x56, 0xd6, 0x17, 0xd9,
-	0x32, 0x79, 0x63, 0x9b, 0xff, 0x49, 0x40, 0x9a, 0x3d, 0x9a, 0x59, 0xdf, 0xfc, 0x21, 0xa4, 0xf8,
-	0xfb, 0x19, 0x6b, 0xe2, 0xfa, 0xf7, 0x51, 0x65, 0xe3, 0x3d, 0xbe, 0xb2, 0xf3, 0x1d, 0x84, 0x7f,
-	0x0e, 0x39, 0xce, 0x94, 0xef, 0x95, 0x2f, 0x16, 0x9f, 0x0d, 0x73, 0x96, 0xbe, 0x3c, 0x63, 0x57,
-	0xb3, 0xb7, 0x05, 0x29, 0x5e, 0xc6, 0x7a, 0x34, 0xfa, 0x2b, 0x5b, 0x8f, 0x66, 0xee, 0x35, 0x6b,
-	0xc5, 0xf0, 0x75, 0x48, 0xb2, 0xea, 0xd3, 0xe1, 0xd0, 0x9e, 0x19, 0x3a, 0x1c, 0xfa, 0x8c, 0xe7,
-	0x6e, 0x7f, 0x14, 0xbd, 0x96, 0x36, 0x16, 0xc7, 0x86, 0x52, 0x2f, 0xbf, 0xbf, 0x11, 0x79, 0xbe,
-	0x2f, 0x9e, 0x0d, 0xaa, 0xee, 0xf1, 0x97, 0xf3, 0xae, 0x16, 0xda, 0x44, 0xa5, 0x7a, 0xd6, 0x76,
-	0x64, 0x70, 0x0f, 0x72, 0x5a, 0xcd, 0xe9, 0xb0, 0xbe, 0xdf, 0x30, 0x74, 0x58, 0x4f, 0x29, 0x54,
-	0x2b, 0xb6, 0xf9, 0x4b, 0xc8, 0xa8, 0xae, 0x8e, 0x1f, 0x42, 0x71, 0xbe, 0xa7, 0xe1, 0xcf, 0xb4,
-	0x68, 0xe6, 0x47, 0x45, 0xa5, 0xa6, 0x6d, 0x9d, 0xde, 0x08, 0x63, 0x75, 0xd4, 0x7a, 0xfc, 0xf2,
-	0x4d, 0x35, 0xf6, 0xea, 0x4d, 0x35, 0xf6, 0xee, 0x4d, 0x15, 0xfd, 0x66, 0x52, 0x45, 0x7f, 0x9e,
-	0x54, 0xd1, 0x8b, 0x49, 0x15, 0xbd, 0x9c, 0x54, 0xd1, 0xbf, 0x26, 0x55, 0xf4, 0xef, 0x49, 0x35,
-	0xf6, 0x6e, 0x52, 0x45, 0xcf, 0xdf, 0x56, 0x63, 0x2f, 0xdf, 0x56, 0x63, 0xaf, 0xde, 0x56, 0x63,
-	0x8f, 0xbf, 0xd6, 0xff, 0x23, 0x13, 0x38, 0x47, 0xce, 0xc0, 0x69, 0xf6, 0xfd, 0x63, 0xb7, 0xa9,
-	0xff, 0xc7, 0xe7, 0xd0, 0xe0, 0x3f, 0xdf, 0xfd, 0x5f, 0x00, 0x00, 0x00, 0xff, 0xff, 0x4b, 0xf9,
-	0x78, 0x9a, 0x08, 0x12, 0x00, 0x00,
+	// 1728 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xc4, 0x58, 0xcb, 0x6f, 0x1b, 0xc7,
+	0x19, 0xe7, 0x90, 0xcb, 0x25, 0xf9, 0x91, 0xa2, 0xd4, 0xb1, 0x2c, 0x31, 0x4c, 0xcc, 0x55, 0x16,
+	0x69, 0x4c, 0x24, 0x36, 0x59, 0xab, 0x8f, 0x38, 0x76, 0x1f, 0x10, 0xad, 0x26, 0x96, 0xe3, 0x36,
+	0xf1, 0xca, 0x45, 0x80, 0x00, 0x85, 0xb1, 0x22, 0x47, 0xe4, 0x42, 0x5c, 0x2e, 0xbd, 0x33, 0x0c,
+	0x2a, 0xa0, 0x40, 0xfb, 0x07, 0xb4, 0x40, 0x7a, 0x2a, 0x7a, 0x6e, 0x81, 0x16, 0x3d, 0xf4, 0xd0,
+	0x7f, 0xa2, 0xee, 0xcd, 0xc7, 0x20, 0x07, 0xb6, 0xa6, 0x2f, 0x05, 0xd1, 0x43, 0xfe, 0x82, 0xa2,
+	0x98, 0xd7, 0xee, 0x90, 0x96, 0x60, 0xd3, 0x17, 0x5f, 0xc4, 0xf9, 0xbe, 0xf9, 0x1e, 0x33, 0xbf,
+	0xf9, 0x5e, 0x2b, 0x78, 0x7d, 0x7c, 0xd2, 0x6f, 0x0f, 0xa3, 0xfe, 0x38, 0x8e, 0x58, 0x94, 0x2c,
+	0x5a, 0xe2, 0x2f, 0x2e, 0x6a, 0xba, 0xee, 0xf4, 0xa3, 0xa8, 0x3f, 0x24, 0x6d, 0x41, 0x1d, 0x4d,
+	0x8e, 0xdb, 0x2c, 0x08, 0x09, 0x65, 0x7e, 0x38, 0x96, 0xa2, 0xf5, 0xab, 0xfd, 0x80, 0x0d, 0x26,
+	0x47, 0xad, 0x6e, 0x14, 0xb6, 0xfb, 0x51, 0x3f, 0x4a, 0x25, 0x39, 0x25, 0xad, 0xf3, 0x95, 0x12,
+	0xdf, 0x51, 0x6e, 0x1f, 0x0e, 0xc3, 0xa8, 0x47, 0x86, 0x6d, 0xca, 0x7c, 0x46, 0xe5, 0x5f, 0x29,
+	0xe1, 0x7e, 0x0a, 0xe5, 0x4f, 0x26, 0x74, 0xe0, 0x91, 0x87, 0x13, 0x42, 0x19, 0xbe, 0x0d, 0x05,
+	0xca, 0x62, 0xe2, 0x87, 0xb4, 0x86, 0x76, 0x72, 0xcd, 0xf2, 0xee, 0x76, 0x2b, 0x39, 0xec, 0xa1,
+	0xd8, 0xd8, 0xeb, 0xf9, 0x63, 0x46, 0xe2, 0xce, 0xc5, 0xaf, 0xa6, 0x8e, 0x2d, 0x59, 0xf3, 0xa9,
+	0xa3, 0xb5, 0x3c, 0xbd, 0x70, 0xab, 0x50, 0x91, 0x86, 0xe9, 0x38, 0x1a, 0x51, 0xe2, 0xfe, 0x23,
+	0x0b, 0x95, 0x7b, 0x13, 0x12, 0x9f, 0x6a, 0x57, 0x75, 0x28, 0x52, 0x32, 0x24, 0x5d, 0x16, 0xc5,
+	0x35, 0xb4, 0x83, 0x9a, 0x25, 0x2f, 0xa1, 0xf1, 0x26, 0xe4, 0x87, 0x41, 0x18, 0xb0, 0x5a, 0x76,
+	0x07, 0x35, 0xd7, 0x3c, 0x49, 0xe0, 0x1b, 0x90, 0xa7, 0xcc, 0x8f, 0x59, 0x2d, 0xb7, 0x83, 0x9a,
+	0xe5, 0xdd, 0x7a, 0x4b, 0xa2, 0xd5, 0xd2, 0x18, 0xb4, 0xee, 0x6b, 0xb4, 0x3a, 0xc5, 0x47, 0x53,
+	0x27, 0xf3, 0xc5, 0xbf, 0x1c, 0xe4, 0x49, 0x15, 0xfc, 0x3d, 0xc8, 0x91, 0x51, 0xaf, 0x66, 0xad,
+	0xa0, 0xc9, 0x15, 0xf0, 0x35, 0x28, 0xf5, 0x82, 0x98, 0x74, 0x59, 0x10, 0x8d, 0x6a, 0xf9, 0x1d,
+	0xd4, 0xac, 0xee, 0x5e, 0x48, 0x21, 0xd9, 0xd7, 0x5b, 0x5e, 0x2a, 0x85, 0xaf, 0x80, 0x4d, 0x07,
+	0x7e, 0xdc, 0xa3, 0xb5, 0xc2, 0x4e, 0xae, 0x59, 0xea, 0x6c, 0xce, 0xa7, 0xce, 0x86, 0xe4, 0x5c,
+	0x89, 0xc2, 0x80, 0x91, 0x70, 0xcc, 0x4e, 0x3d, 0x25, 0x83, 0xdf, 0x81, 0x42, 0x8f, 0x0c, 0x09,
+	0x23, 0xb4, 0x56, 0x14, 0x88, 0x6f, 0x18, 0xe6, 0xc5, 0x86, 0xa7, 0x05, 0xee, 0x58, 0x45, 0x7b,
+	0xa3, 0xe0, 0xfe, 0x0f, 0x01, 0x3e, 0xf4, 0xc3, 0xf1, 0x90, 0xbc, 0x30, 0x9e, 0x09, 0x72, 0xd9,
+	0x97, 0x46, 0x2e, 0xb7, 0x2a, 0x72, 0x29, 0x0c, 0xd6, 0x6a, 0x30, 0xe4, 0x9f, 0x03, 0x83, 0x7b,
+	0x17, 0x6c, 0xc9, 0x7a, 0x5e, 0x0c, 0xa5, 0x77, 0xce, 0xe9, 0xdb, 0x6c, 0xa4, 0xb7, 0xc9, 0x89,
+	0x73, 0xba, 0xbf, 0x82, 0x35, 0x85, 0xa3, 0x8c, 0x54, 0xbc, 0xf7, 0xc2, 0x39, 0x50, 0x7d, 0x34,
+	0x75, 0x50, 0x9a, 0x07, 0x49, 0xf0, 0xe3, 0x77, 0x85, 0x6f, 0x46, 0x15, 0xde, 0xeb, 0x2d, 0x41,
+	0xb5, 0x0e, 0x46, 0x7d, 0x42, 0xb9, 0xa2, 0xc5, 0xa1, 0xf2, 0xa4, 0x8c, 0xfb, 0x4b, 0xb8, 0xb0,
+	0xf0, 0x9c, 0xea, 0x18, 0xd7, 0xc1, 0xa6, 0x24, 0x0e, 0x88, 0x3e, 0x85, 0x01, 0xc8, 0xa1, 0xe0,
+	0x1b, 0xee, 0x05, 0xed, 0x29, 0xf9, 0xd5, 0xbc, 0xff, 0x0d, 0x41, 0xe5, 0xae, 0x7f, 0x44, 0x86,
+	0x3a, 0x8e, 0x30, 0x58, 0x23, 0x3f, 0x24, 0x0a, 0x4f, 0xb1, 0xc6, 0x5b, 0x60, 0x7f, 0xee, 0x0f,
+	0x27, 0x44, 0x9a, 0x2c, 0x7a, 0x8a, 0x5a, 0x35, 0x23, 0xd1, 0x4b, 0x67, 0x24, 0x4a, 0xe2, 0xca,
+	0xbd, 0x0c, 0x6b, 0xea, 0xbc, 0x0a, 0xa8, 0xf4, 0x70, 0x1c, 0xa8, 0x92, 0x3e, 0x9c, 0xfb, 0x3b,
+	0x04, 0x6b, 0x0b, 0xef, 0x85, 0x5d, 0xb0, 0x87, 0x5c, 0x95, 0xca, 0xcb, 0x75, 0x60, 0x3e, 0x75,
+	0x14, 0xc7, 0x53, 0xbf, 0xfc, 0xf5, 0xc9, 0x88, 0x09, 0xdc, 0xb3, 0x02, 0xf7, 0xad, 0x14, 0xf7,
+	0x1f, 0x8f, 0x58, 0x7c, 0xaa, 0x1f, 0x7f, 0x9d, 0xa3, 0xc8, 0x4b, 0x9f, 0x12, 0xf7, 0xf4, 0x02,
+	0xbf, 0x06, 0xd6, 0xc0, 0xa7, 0x03, 0x01, 0x8a, 0xd5, 0xc9, 0xcf, 0xa7, 0x0e, 0xba, 0xea, 0x09,
+	0x96, 0xfb, 0x39, 0x54, 0x4c, 0x23, 0xf8, 0x36, 0x94, 0x92, 0x12, 0x5f, 0x43, 0xcf, 0x85, 0xa2,
+	0xaa, 0x7c, 0x66, 0x19, 0x15, 0x80, 0xa4, 0xca, 0xf8, 0x0d, 0xb0, 0x86, 0xc1, 0x88, 0x88, 0x07,
+	0x2a, 0x75, 0x8a, 0xf3, 0xa9, 0x23, 0x68, 0x4f, 0xfc, 0x75, 0x43, 0xb0, 0x65, 0x8c, 0xe1, 0xb7,
+	0x96, 0x3d, 0xe6, 0x3a, 0xb6, 0xb4, 0x68, 0x5a, 0x73, 0x20, 0x2f, 0x50, 0x14, 0xe6, 0x50, 0xa7,
+	0x34, 0x9f, 0x3a, 0x92, 0xe1, 0xc9, 0x1f, 0xee, 0xce, 0xb8, 0xa3, 0x70, 0xc7, 0x69, 0x75, 0xcd,
+	0x0f, 0xa1, 0x72, 0x97, 0xf4, 0xfd, 0xee, 0xa9, 0x72, 0xba, 0xa9, 0xcd, 0x71, 0x87, 0x48, 0xdb,
+	0x78, 0x13, 0x2a, 0x89, 0xc7, 0x07, 0x21, 0x55, 0x89, 0x5a, 0x4e, 0x78, 0x3f, 0xa1, 0xee, 0x1f,
+	0x10, 0xa8, 0xe8, 0x7e, 0xa1, 0xc7, 0xbb, 0x09, 0x05, 0x2a, 0x3c, 0xea, 0xc7, 0x33, 0x93, 0x46,
+	0x6c, 0xa4, 0xcf, 0xa6, 0x04, 0x3d, 0xbd, 0xc0, 0x2d, 0x00, 0x99, 0xbf, 0xb7, 0xd3, 0x8b, 0x55,
+	0xe7, 0x53, 0xc7, 0xe0, 0x7a, 0xc6, 0xda, 0xfd, 0x3d, 0x82, 0xf2, 0x7d, 0x3f, 0x48, 0x12, 0x67,
+	0x13, 0xf2, 0x0f, 0x79, 0x06, 0xab, 0xcc, 0x91, 0x04, 0x2f, 0x51, 0x3d, 0x32, 0xf4, 0x4f, 0x3f,
+	0x88, 0x62, 0x61, 0x73, 0xcd, 0x4b, 0xe8, 0xb4, 0xcd, 0x59, 0x67, 0xb6, 0xb9, 0xfc, 0xca, 0xc5,
+	0xfa, 0x8e, 0x55, 0xcc, 0x6e, 0xe4, 0xdc, 0xdf, 0x20, 0xa8, 0xc8, 0x93, 0xa9, 0x14, 0xb9, 0x09,
+	0xb6, 0x3c, 0xb8, 0x8a, 0xb1, 0x73, 0x2b, 0x1a, 0x18, 0xd5, 0x4c, 0xa9, 0xe0, 0x1f, 0x41, 0xb5,
+	0x17, 0x47, 0xe3, 0x31, 0xe9, 0x1d, 0xaa, 0xb2, 0x98, 0x5d, 0x2e, 0x8b, 0xfb, 0xe6, 0xbe, 0xb7,
+	0x24, 0xee, 0xfe, 0x93, 0x27, 0xa2, 0x2c, 0x51, 0x0a, 0xaa, 0xe4, 0x8a, 0xe8, 0xa5, 0xfb, 0x51,
+	0x76, 0xd5, 0x7e, 0xb4, 0x05, 0x76, 0x3f, 0x8e, 0x26, 0x63, 0x5a, 0xcb, 0xc9, 0x32, 0x21, 0xa9,
+	0xd5, 0xfa, 0x94, 0x7b, 0x07, 0xaa, 0xfa, 0x2a, 0xe7, 0xd4, 0xe9, 0xfa, 0x72, 0x9d, 0x3e, 0xe8,
+	0x91, 0x11, 0x0b, 0x8e, 0x83, 0xa4, 0xf2, 0x2a, 0x79, 0xf7, 0xb7, 0x08, 0x36, 0x96, 0x45, 0xf0,
+	0x0f, 0x8d, 0x30, 0xe7, 0xe6, 0xde, 0x3e, 0xdf, 0x5c, 0x4b, 0xd4, 0x41, 0x2a, 0x0a, 0x8a, 0x4e,
+	0x81, 0xfa, 0xfb, 0x50, 0x36, 0xd8, 0xbc, 0xdf, 0x9d, 0x10, 0x1d, 0x92, 0x7c, 0x99, 0xe6, 0x62,
+	0x56, 0x86, 0xa9, 0x20, 0x6e, 0x64, 0xaf, 0x23, 0x1e, 0xd0, 0x6b, 0x0b, 0x2f, 0x89, 0xaf, 0x83,
+	0x75, 0x1c, 0x47, 0xe1, 0x4a, 0xcf, 0x24, 0x34, 0xf0, 0x77, 0x20, 0xcb, 0xa2, 0x95, 0x1e, 0x29,
+	0xcb, 0x22, 0xfe, 0x46, 0xea, 0xf2, 0x39, 0x71, 0x38, 0x45, 0xb9, 0x7f, 0x45, 0xb0, 0xce, 0x75,
+	0x24, 0x02, 0xb7, 0x06, 0x93, 0xd1, 0x09, 0x6e, 0xc2, 0x06, 0xf7, 0xf4, 0x20, 0x50, 0x6d, 0xed,
+	0x41, 0xd0, 0x53, 0xd7, 0xac, 0x72, 0xbe, 0xee, 0x76, 0x07, 0x3d, 0xbc, 0x0d, 0x85, 0x09, 0x95,
+	0x02, 0xf2, 0xce, 0x36, 0x27, 0x0f, 0x7a, 0xf8, 0x5d, 0xc3, 0x1d, 0xc7, 0xda, 0x98, 0xec, 0x04,
+	0x86, 0x9f, 0xf8, 0x41, 0x9c, 0xd4, 0x96, 0xcb, 0x60, 0x77, 0xb9, 0x63, 0x19, 0x27, 0xbc, 0xad,
+	0x26, 0xc2, 0xe2, 0x40, 0x9e, 0xda, 0x76, 0xbf, 0x0b, 0xa5, 0x44, 0xfb, 0xcc, 0x6e, 0x7a, 0xe6,
+	0x0b, 0xb8, 0x37, 0x61, 0x5d, 0xd6, 0xcc, 0xb3, 0x95, 0x2b, 0x67, 0x29, 0x57, 0xb4, 0xf2, 0xeb,
+	0x90, 0x97, 0xa8, 0x60, 0xb0, 0x7a, 0x3e, 0xf3, 0xb5, 0x0a, 0x5f, 0xbb, 0x35, 0xd8, 0xba, 0x1f,
+	0xfb, 0x23, 0x7a, 0x4c, 0x62, 0x21, 0x94, 0xc4, 0xae, 0x7b, 0x11, 0x2e, 0xf0, 0x3a, 0x41, 0x62,
+	0x7a, 0x2b, 0x9a, 0x8c, 0x98, 0x4a, 0x4f, 0xf7, 0x0a, 0x6c, 0x2e, 0xb2, 0x55, 0xa8, 0x6f, 0x42,
+	0xbe, 0xcb, 0x19, 0xc2, 0xfa, 0x9a, 0x27, 0x09, 0xf7, 0x4f, 0x08, 0xf0, 0x87, 0x84, 0x09, 0xd3,
+	0x07, 0xfb, 0xd4, 0x98, 0x47, 0x43, 0x9f, 0x75, 0x07, 0x24, 0xa6, 0x7a, 0x36, 0xd3, 0xf4, 0xab,
+	0x98, 0x47, 0xdd, 0x6b, 0x70, 0x61, 0xe1, 0x94, 0xea, 0x4e, 0x75, 0x28, 0x76, 0x15, 0x4f, 0xcd,
+	0x0f, 0x09, 0xed, 0xfe, 0x11, 0xc1, 0x37, 0x0e, 0x46, 0x3d, 0xf2, 0x8b, 0x43, 0xe6, 0xb3, 0x57,
+	0x5a, 0xbc, 0x4c, 0x30, 0x73, 0x8b, 0x60, 0xba, 0x7f, 0x46, 0x80, 0xcd, 0x53, 0xaa, 0x8b, 0x7d,
+	0xd3, 0x1c, 0x63, 0x79, 0x2f, 0x2b, 0x9f, 0xf5, 0x9d, 0xc6, 0xdb, 0xaa, 0x0a, 0xeb, 0xac, 0x90,
+	0x12, 0x6d, 0x55, 0x72, 0x74, 0x44, 0xf3, 0x69, 0xe0, 0xe8, 0x94, 0x11, 0xe9, 0xda, 0x92, 0xd3,
+	0x80, 0x60, 0x78, 0xf2, 0x87, 0xfb, 0xd2, 0x43, 0x93, 0x95, 0xfa, 0x5a, 0x1e, 0x8c, 0xdc, 0xbf,
+	0x67, 0xa1, 0x28, 0x73, 0x85, 0x1c, 0xe3, 0x6b, 0x50, 0x3e, 0xe6, 0xb9, 0x1b, 0x8f, 0xe3, 0x40,
+	0x85, 0x94, 0xd5, 0x59, 0x9f, 0x4f, 0x1d, 0x93, 0xed, 0x99, 0x04, 0xbe, 0xba, 0x94, 0xc8, 0x9d,
+	0xcd, 0xd9, 0xd4, 0xb1, 0x7f, 0xc6, 0x93, 0x79, 0x9f, 0x1f, 0x5b, 0xa4, 0xf5, 0x7e, 0x92, 0xde,
+	0x1f, 0xa9, 0xea, 0x25, 0x86, 0xfd, 0xce, 0x7b, 0x1c, 0xd1, 0xaf, 0xa6, 0xce, 0x65, 0xe3, 0x13,
+	0x7a, 0x1c, 0x47, 0x21, 0x61, 0x03, 0x32, 0xa1, 0xed, 0x6e, 0x14, 0x86, 0xd1, 0xa8, 0x2d, 0xbe,
+	0x93, 0xc5, 0x3b, 0xf0, 0x91, 0x86, 0xab, 0xab, 0x82, 0x76, 0x1f, 0x0a, 0x6c, 0x10, 0x47, 0x93,
+	0xfe, 0x40, 0x5c, 0x31, 0xd7, 0xb9, 0xb1, 0xba, 0x3d, 0x6d, 0xc1, 0xd3, 0x0b, 0xfc, 0x26, 0x8f,
+	0x3e, 0xd2, 0x3d, 0xa1, 0x93, 0x50, 0xb4, 0xfb, 0x35, 0x3d, 0x2e, 0x26, 0xec, 0x77, 0xde, 0x86,
+	0x52, 0xf2, 0x99, 0x89, 0xcb, 0x50, 0xf8, 0xe0, 0x63, 0xef, 0xd3, 0x3d, 0x6f, 0x7f, 0x23, 0x83,
+	0x2b, 0x50, 0xec, 0xec, 0xdd, 0xfa, 0x48, 0x50, 0x68, 0x77, 0x0f, 0x6c, 0xfe, 0xc1, 0x4d, 0x62,
+	0xfc, 0x1e, 0x58, 0x7c, 0x85, 0x2f, 0xa6, 0x15, 0xca, 0xf8, 0xc6, 0xaf, 0x6f, 0x2d, 0xb3, 0x55,
+	0x31, 0xc8, 0xec, 0xfe, 0x37, 0x07, 0x05, 0xfe, 0x11, 0xc2, 0xfb, 0xd0, 0xf7, 0x21, 0x7f, 0x4f,
+	0x0c, 0x30, 0x86, 0xb8, 0xf9, 0xbd, 0x59, 0xdf, 0x7e, 0x86, 0xaf, 0xed, 0x7c, 0x0b, 0xe1, 0x9f,
+	0x42, 0x59, 0x30, 0xd5, 0xfc, 0xf7, 0xc6, 0xf2, 0x18, 0xb6, 0x60, 0xe9, 0xd2, 0x39, 0xbb, 0x86,
+	0xbd, 0x1b, 0x90, 0x17, 0x65, 0xd1, 0x3c, 0x8d, 0xf9, 0xd5, 0x52, 0xdf, 0x7e, 0x86, 0xaf, 0xb5,
+	0xf1, 0xfb, 0x60, 0xf1, 0x6a, 0x66, 0xc2, 0x61, 0x8c, 0x6d, 0xf5, 0xad, 0x65, 0xb6, 0xe1, 0xf6,
+	0x07, 0xc9, 0xf4, 0xb9, 0xbd, 0xdc, 0x86, 0xb5, 0x7a, 0xed, 0xd9, 0x8d, 0xc4, 0xf3, 0xc7, 0x50,
+	0x31, 0xeb, 0x28, 0xbe, 0xb4, 0xe8, 0x6a, 0xa9, 0xec, 0xd6, 0x1b, 0xe7, 0x6d, 0x27, 0x06, 0xef,
+	0x42, 0xd9, 0xa8, 0x61, 0x26, 0xac, 0xcf, 0x16, 0xe0, 0xfa, 0xa5, 0x73, 0x76, 0x93, 0xe7, 0xfe,
+	0x39, 0x14, 0x75, 0x97, 0xc4, 0xf7, 0xa0, 0xba, 0xd8, 0x23, 0xf0, 0x6b, 0xc6, 0x69, 0x16, 0x5b,
+	0x6f, 0x7d, 0xc7, 0xd8, 0x3a, 0xbb, 0xb1, 0x64, 0x9a, 0xa8, 0xf3, 0xd9, 0xe3, 0x27, 0x8d, 0xcc,
+	0x97, 0x4f, 0x1a, 0x99, 0xaf, 0x9f, 0x34, 0xd0, 0xaf, 0x67, 0x0d, 0xf4, 0x97, 0x59, 0x03, 0x3d,
+	0x9a, 0x35, 0xd0, 0xe3, 0x59, 0x03, 0xfd, 0x7b, 0xd6, 0x40, 0xff, 0x99, 0x35, 0x32, 0x5f, 0xcf,
+	0x1a, 0xe8, 0x8b, 0xa7, 0x8d, 0xcc, 0xe3, 0xa7, 0x8d, 0xcc, 0x97, 0x4f, 0x1b, 0x99, 0xcf, 0xde,
+	0x32, 0xff, 0xc3, 0x15, 0xfb, 0xc7, 0xfe, 0xc8, 0x6f, 0x0f, 0xa3, 0x93, 0xa0, 0x6d, 0xfe, 0x07,
+	0xed, 0xc8, 0x16, 0x3f, 0xdf, 0xfe, 0xff, 0x00, 0xc4, 0xc2, 0x2e, 0xca, 0x58, 0x13, 0x00, 0x00,
 }
 
 func (x Direction) String() string {
@@ -2717,6 +2851,69 @@ func (this *GetChunkIDsResponse) Equal(that interface{}) bool {
 	}
 	return true
 }
+func (this *IndexStatsRequest) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*IndexStatsRequest)
+	if !ok {
+		that2, ok := that.(IndexStatsRequest)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if !this.Start.Equal(that1.Start) {
+		return false
+	}
+	if !this.End.Equal(that1.End) {
+		return false
+	}
+	if this.Matchers != that1.Matchers {
+		return false
+	}
+	return true
+}
+func (this *IndexStatsResponse) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*IndexStatsResponse)
+	if !ok {
+		that2, ok := that.(IndexStatsResponse)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.Streams != that1.Streams {
+		return false
+	}
+	if this.Chunks != that1.Chunks {
+		return false
+	}
+	if this.Bytes != that1.Bytes {
+		return false
+	}
+	if this.Entries != that1.Entries {
+		return false
+	}
+	return true
+}
 func (this *ChunkRef) Equal(that interface{}) bool {
 	if that == nil {
 		return this == nil
@@ -3121,6 +3318,31 @@ func (this *GetChunkIDsResponse) GoString() string {
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
+func (this *IndexStatsRequest) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 7)
+	s = append(s, "&logproto.IndexStatsRequest{")
+	s = append(s, "Start: "+fmt.Sprintf("%#v", this.Start)+",\n")
+	s = append(s, "End: "+fmt.Sprintf("%#v", this.End)+",\n")
+	s = append(s, "Matchers: "+fmt.Sprintf("%#v", this.Matchers)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *IndexStatsResponse) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 8)
+	s = append(s, "&logproto.IndexStatsResponse{")
+	s = append(s, "Streams: "+fmt.Sprintf("%#v", this.Streams)+",\n")
+	s = append(s, "Chunks: "+fmt.Sprintf("%#v", this.Chunks)+",\n")
+	s = append(s, "Bytes: "+fmt.Sprintf("%#v", this.Bytes)+",\n")
+	s = append(s, "Entries: "+fmt.Sprintf("%#v", this.Entries)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
 func (this *ChunkRef) GoString() string {
 	if this == nil {
 		return "nil"
@@ -4943,6 +5165,95 @@ func (m *GetChunkIDsResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	return len(dAtA) - i, nil
 }
 
+func (m *IndexStatsRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *IndexStatsRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *IndexStatsRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Matchers) > 0 {
+		i -= len(m.Matchers)
+		copy(dAtA[i:], m.Matchers)
+		i = encodeVarintLogproto(dAtA, i, uint64(len(m.Matchers)))
+		i--
+		dAtA[i] = 0x1a
+	}
+	n18, err18 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.End, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.End):])
+	if err18 != nil {
+		return 0, err18
+	}
+	i -= n18
+	i = encodeVarintLogproto(dAtA, i, uint64(n18))
+	i--
+	dAtA[i] = 0x12
+	n19, err19 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.Start, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.Start):])
+	if err19 != nil {
+		return 0, err19
+	}
+	i -= n19
+	i = encodeVarintLogproto(dAtA, i, uint64(n19))
+	i--
+	dAtA[i] = 0xa
+	return len(dAtA) - i, nil
+}
+
+func (m *IndexStatsResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *IndexStatsResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *IndexStatsResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.Entries != 0 {
+		i = encodeVarintLogproto(dAtA, i, uint64(m.Entries))
+		i--
+		dAtA[i] = 0x20
+	}
+	if m.Bytes != 0 {
+		i = encodeVarintLogproto(dAtA, i, uint64(m.Bytes))
+		i--
+		dAtA[i] = 0x18
+	}
+	if m.Chunks != 0 {
+		i = encodeVarintLogproto(dAtA, i, uint64(m.Chunks))
+		i--
+		dAtA[i] = 0x10
+	}
+	if m.Streams != 0 {
+		i = encodeVarintLogproto(dAtA, i, uint64(m.Streams))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
 func (m *ChunkRef) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
@@ -5528,6 +5839,44 @@ func (m *GetChunkIDsResponse) Size() (n int) {
 	return n
 }
 
+func (m *IndexStatsRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = github_com_gogo_protobuf_types.SizeOfStdTime(m.Start)
+	n += 1 + l + sovLogproto(uint64(l))
+	l = github_com_gogo_protobuf_types.SizeOfStdTime(m.End)
+	n += 1 + l + sovLogproto(uint64(l))
+	l = len(m.Matchers)
+	if l > 0 {
+		n += 1 + l + sovLogproto(uint64(l))
+	}
+	return n
+}
+
+func (m *IndexStatsResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Streams != 0 {
+		n += 1 + sovLogproto(uint64(m.Streams))
+	}
+	if m.Chunks != 0 {
+		n += 1 + sovLogproto(uint64(m.Chunks))
+	}
+	if m.Bytes != 0 {
+		n += 1 + sovLogproto(uint64(m.Bytes))
+	}
+	if m.Entries != 0 {
+		n += 1 + sovLogproto(uint64(m.Entries))
+	}
+	return n
+}
+
 func (m *ChunkRef) Size() (n int) {
 	if m == nil {
 		return 0
@@ -5937,17 +6286,42 @@ func (this *GetChunkIDsResponse) String() string {
 	}, "")
 	return s
 }
-func (this *ChunkRef) String() string {
+func (this *IndexStatsRequest) String() string {
 	if this == nil {
 		return "nil"
 	}
-	s := strings.Join([]string{`&ChunkRef{`,
-		`Fingerprint:` + fmt.Sprintf("%v", this.Fingerprint) + `,`,
-		`UserID:` + fmt.Sprintf("%v", this.UserID) + `,`,
-		`From:` + fmt.Sprintf("%v", this.From) + `,`,
-		`Through:` + fmt.Sprintf("%v", this.Through) + `,`,
-		`Checksum:` + fmt.Sprintf("%v", this.Checksum) + `,`,
-		`}`,
+	s := strings.Join([]string{`&IndexStatsRequest{`,
+		`Start:` + strings.Replace(strings.Replace(fmt.Sprintf("%v", this.Start), "Timestamp", "types.Timestamp", 1), `&`, ``, 1) + `,`,
+		`End:` + strings.Replace(strings.Replace(fmt.Sprintf("%v", this.End), "Timestamp", "types.Timestamp", 1), `&`, ``, 1) + `,`,
+		`Matchers:` + fmt.Sprintf("%v", this.Matchers) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *IndexStatsResponse) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&IndexStatsResponse{`,
+		`Streams:` + fmt.Sprintf("%v", this.Streams) + `,`,
+		`Chunks:` + fmt.Sprintf("%v", this.Chunks) + `,`,
+		`Bytes:` + fmt.Sprintf("%v", this.Bytes) + `,`,
+		`Entries:` + fmt.Sprintf("%v", this.Entries) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *ChunkRef) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&ChunkRef{`,
+		`Fingerprint:` + fmt.Sprintf("%v", this.Fingerprint) + `,`,
+		`UserID:` + fmt.Sprintf("%v", this.UserID) + `,`,
+		`From:` + fmt.Sprintf("%v", this.From) + `,`,
+		`Through:` + fmt.Sprintf("%v", this.Through) + `,`,
+		`Checksum:` + fmt.Sprintf("%v", this.Checksum) + `,`,
+		`}`,
 	}, "")
 	return s
 }
@@ -9579,6 +9953,286 @@ func (m *GetChunkIDsResponse) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
+func (m *IndexStatsRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowLogproto
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: IndexStatsRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: IndexStatsRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Start", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLogproto
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthLogproto
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthLogproto
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if err := github_com_gogo_protobuf_types.StdTimeUnmarshal(&m.Start, dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field End", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLogproto
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthLogproto
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthLogproto
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if err := github_com_gogo_protobuf_types.StdTimeUnmarshal(&m.End, dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Matchers", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLogproto
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthLogproto
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthLogproto
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Matchers = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipLogproto(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthLogproto
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthLogproto
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *IndexStatsResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowLogproto
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: IndexStatsResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: IndexStatsResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Streams", wireType)
+			}
+			m.Streams = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLogproto
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Streams |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Chunks", wireType)
+			}
+			m.Chunks = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLogproto
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Chunks |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Bytes", wireType)
+			}
+			m.Bytes = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLogproto
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Bytes |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Entries", wireType)
+			}
+			m.Entries = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLogproto
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Entries |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipLogproto(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthLogproto
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthLogproto
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
 func (m *ChunkRef) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
@@ -47,6 +47,8 @@ type DownstreamEngine struct {
 	downstreamable Downstreamable
 	limits         Limits
 	metrics        *ShardingMetrics
+
+	maxLabelsCacheSize int
 }
 
 // NewDownstreamEngine constructs a *DownstreamEngine
@@ -58,6 +60,8 @@ func NewDownstreamEngine(opts EngineOpts, downstreamable Downstreamable, metrics
 		downstreamable: downstreamable,
 		metrics:        metrics,
 		limits:         limits,
+
+		maxLabelsCacheSize: opts.MaxLabelsCacheSize,
 	}
 }
 
@@ -71,7 +75,8 @@ func (ng *DownstreamEngine) Query(p Params, mapped syntax.Expr) Query {
 		parse: func(_ context.Context, _ string) (syntax.Expr, error) {
 			return mapped, nil
 		},
-		limits: ng.limits,
+		limits:             ng.limits,
+		maxLabelsCacheSize: ng.maxLabelsCacheSize,
 	}
 }
 
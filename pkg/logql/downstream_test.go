@@ -48,6 +48,11 @@ func TestMappingEquivalence(t *testing.T) {
 		{`sum(max(rate({a=~".+"}[1s])))`, false},
 		{`max(count(rate({a=~".+"}[1s])))`, false},
 		{`max(sum by (cluster) (rate({a=~".+"}[1s]))) / count(rate({a=~".+"}[1s]))`, false},
+		{`ewma_rate({a=~".+"}[5s])`, false},
+		{`sum by (a) (ewma_rate(2, {a=~".+"}[5s]))`, true},
+		{`sum(ewma_bytes_rate({a=~".+"}[5s]))`, true},
+		{`bytes_histogram_over_time({a=~".+"}[5s])`, false},
+		{`sum by (le) (bytes_histogram_over_time(1, {a=~".+"}[5s]))`, true},
 		// topk prefers already-seen values in tiebreakers. Since the test data generates
 		// the same log lines for each series & the resulting promql.Vectors aren't deterministically
 		// sorted by labels, we don't expect this to pass.
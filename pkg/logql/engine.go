@@ -100,11 +100,14 @@ type EngineOpts struct {
 	// MaxLookBackPeriod is the maximum amount of time to look back for log lines.
 	// only used for instant log queries.
 	MaxLookBackPeriod time.Duration `yaml:"max_look_back_period"`
+	// MaxLabelsCacheSize is the maximum number of label sets cached for a single query.
+	MaxLabelsCacheSize int `yaml:"max_labels_cache_size"`
 }
 
 func (opts *EngineOpts) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
 	f.DurationVar(&opts.Timeout, prefix+".engine.timeout", 5*time.Minute, "Timeout for query execution.")
 	f.DurationVar(&opts.MaxLookBackPeriod, prefix+".engine.max-lookback-period", 30*time.Second, "The maximum amount of time to look back for log lines. Used only for instant log queries.")
+	f.IntVar(&opts.MaxLabelsCacheSize, prefix+".engine.max-labels-cache-size", defaultMaxLabelsCacheSize, "The maximum number of label sets cached for a single query, so metric queries parse the labels of each series once. Each query can use up to this many more label sets in memory.")
 }
 
 func (opts *EngineOpts) applyDefault() {
@@ -114,14 +117,18 @@ func (opts *EngineOpts) applyDefault() {
 	if opts.MaxLookBackPeriod == 0 {
 		opts.MaxLookBackPeriod = 30 * time.Second
 	}
+	if opts.MaxLabelsCacheSize == 0 {
+		opts.MaxLabelsCacheSize = defaultMaxLabelsCacheSize
+	}
 }
 
 // Engine is the LogQL engine.
 type Engine struct {
-	logger    log.Logger
-	timeout   time.Duration
-	evaluator Evaluator
-	limits    Limits
+	logger             log.Logger
+	timeout            time.Duration
+	evaluator          Evaluator
+	limits             Limits
+	maxLabelsCacheSize int
 }
 
 // NewEngine creates a new LogQL Engine.
@@ -131,10 +138,11 @@ func NewEngine(opts EngineOpts, q Querier, l Limits, logger log.Logger) *Engine
 		logger = log.NewNopLogger()
 	}
 	return &Engine{
-		logger:    logger,
-		timeout:   opts.Timeout,
-		evaluator: NewDefaultEvaluator(q, opts.MaxLookBackPeriod),
-		limits:    l,
+		logger:             logger,
+		timeout:            opts.Timeout,
+		evaluator:          NewDefaultEvaluator(q, opts.MaxLookBackPeriod),
+		limits:             l,
+		maxLabelsCacheSize: opts.MaxLabelsCacheSize,
 	}
 }
 
@@ -148,8 +156,9 @@ func (ng *Engine) Query(params Params) Query {
 		parse: func(_ context.Context, query string) (syntax.Expr, error) {
 			return syntax.ParseExpr(query)
 		},
-		record: true,
-		limits: ng.limits,
+		record:             true,
+		limits:             ng.limits,
+		maxLabelsCacheSize: ng.maxLabelsCacheSize,
 	}
 }
 
@@ -167,6 +176,9 @@ type query struct {
 	limits    Limits
 	evaluator Evaluator
 	record    bool
+	warnings  []string
+	// maxLabelsCacheSize is the maximum number of label sets cached for the query.
+	maxLabelsCacheSize int
 }
 
 // Exec Implements `Query`. It handles instrumentation & defers to Eval.
@@ -188,6 +200,12 @@ func (q *query) Exec(ctx context.Context) (logqlmodel.Result, error) {
 
 	statResult := statsCtx.Result(time.Since(start), queueTime)
 	statResult.Log(level.Debug(log))
+	if httpreq.StrictParsing(ctx) {
+		q.warnings = append(q.warnings, parserErrorsWarnings(statResult)...)
+	}
+	if statResult.TotalChunksQuarantined() > 0 {
+		q.warnings = append(q.warnings, quarantinedChunksWarning)
+	}
 
 	status := "200"
 	if err != nil {
@@ -207,12 +225,14 @@ func (q *query) Exec(ctx context.Context) (logqlmodel.Result, error) {
 	return logqlmodel.Result{
 		Data:       data,
 		Statistics: statResult,
+		Warnings:   q.warnings,
 	}, err
 }
 
 func (q *query) Eval(ctx context.Context) (promql_parser.Value, error) {
 	ctx, cancel := context.WithTimeout(ctx, q.timeout)
 	defer cancel()
+	ctx = withLabelsCache(ctx, q.maxLabelsCacheSize)
 
 	expr, err := q.parse(ctx, q.params.Query())
 	if err != nil {
@@ -260,6 +280,11 @@ func (q *query) evalSample(ctx context.Context, expr syntax.SampleExpr) (promql_
 		return nil, err
 	}
 	maxSeries := validation.SmallestPositiveIntPerTenant(tenantIDs, q.limits.MaxQuerySeries)
+	if httpreq.SeriesLimitDisabled(ctx) {
+		// the caller limits the merged result of its sub-queries.
+		maxSeries = math.MaxInt
+	}
+	truncate := httpreq.TruncateSeries(ctx)
 	seriesIndex := map[uint64]*promql.Series{}
 
 	next, ts, vec := stepEvaluator.Next()
@@ -268,11 +293,15 @@ func (q *query) evalSample(ctx context.Context, expr syntax.SampleExpr) (promql_
 	}
 
 	// fail fast for the first step or instant query
-	if len(vec) > maxSeries {
+	if len(vec) > maxSeries && !truncate {
 		return nil, logqlmodel.NewSeriesLimitError(maxSeries)
 	}
 
 	if GetRangeType(q.params) == InstantType {
+		if len(vec) > maxSeries {
+			q.warnings = append(q.warnings, SeriesLimitWarning(maxSeries, len(vec)))
+			vec = truncateVector(vec, maxSeries)
+		}
 		sort.Slice(vec, func(i, j int) bool { return labels.Compare(vec[i].Metric, vec[j].Metric) < 0 })
 		return vec, nil
 	}
@@ -304,7 +333,8 @@ func (q *query) evalSample(ctx context.Context, expr syntax.SampleExpr) (promql_
 			})
 		}
 		// as we slowly build the full query for each steps, make sure we don't go over the limit of unique series.
-		if len(seriesIndex) > maxSeries {
+		// When truncating, all series are needed to find out which ones have the most samples.
+		if len(seriesIndex) > maxSeries && !truncate {
 			return nil, logqlmodel.NewSeriesLimitError(maxSeries)
 		}
 		next, ts, vec = stepEvaluator.Next()
@@ -318,6 +348,10 @@ func (q *query) evalSample(ctx context.Context, expr syntax.SampleExpr) (promql_
 		series = append(series, *s)
 	}
 	result := promql.Matrix(series)
+	if len(result) > maxSeries {
+		q.warnings = append(q.warnings, SeriesLimitWarning(maxSeries, len(result)))
+		result = truncateMatrix(result, maxSeries)
+	}
 	sort.Sort(result)
 
 	return result, stepEvaluator.Error()
@@ -285,6 +285,23 @@ func TestEngine_LogsInstantQuery(t *testing.T) {
 				promql.Sample{Point: promql.Point{T: 60 * 1000, V: 6}, Metric: labels.Labels{labels.Label{Name: "app", Value: "foo"}}},
 			},
 		},
+		{
+			`sum by (label_count()) (count_over_time({app=~"foo|bar|baz"} |~".+bar" [1m]))`, time.Unix(60, 0), logproto.FORWARD, 100,
+			[][]logproto.Series{
+				{
+					newSeries(testSize, factor(10, identity), `{app="foo", namespace="a"}`),
+					newSeries(testSize, factor(10, identity), `{app="bar"}`),
+					newSeries(testSize, factor(10, identity), `{app="baz", namespace="b"}`),
+				},
+			},
+			[]SelectSampleParams{
+				{&logproto.SampleQueryRequest{Start: time.Unix(0, 0), End: time.Unix(60, 0), Selector: `sum by (label_count()) (count_over_time({app=~"foo|bar|baz"} |~".+bar" [1m]))`}},
+			},
+			promql.Vector{
+				promql.Sample{Point: promql.Point{T: 60 * 1000, V: 6}, Metric: labels.Labels{labels.Label{Name: "label_count", Value: "1"}}},
+				promql.Sample{Point: promql.Point{T: 60 * 1000, V: 12}, Metric: labels.Labels{labels.Label{Name: "label_count", Value: "2"}}},
+			},
+		},
 		{
 			`sum(count_over_time({app=~"foo|bar"} |~".+bar" [1m])) by (namespace,app)`, time.Unix(60, 0), logproto.FORWARD, 100,
 			[][]logproto.Series{
@@ -2186,6 +2203,160 @@ func TestEngine_MaxSeries(t *testing.T) {
 	}
 }
 
+func TestEngine_MaxSeriesTruncate(t *testing.T) {
+	querier := &querierRecorder{
+		series: map[string][]logproto.Series{
+			"": {
+				newSeries(300, identity, `{app="foo"}`),
+				newSeries(60, identity, `{app="baz"}`),
+				newSeries(180, identity, `{app="bar"}`),
+			},
+		},
+	}
+	eng := NewEngine(EngineOpts{}, querier, &fakeLimits{maxSeries: 2}, log.NewNopLogger())
+	params := LiteralParams{
+		qs:        `count_over_time({app=~".+"}[1m])`,
+		start:     time.Unix(60, 0),
+		end:       time.Unix(300, 0),
+		step:      60 * time.Second,
+		direction: logproto.FORWARD,
+		limit:     1000,
+	}
+	ctx := user.InjectOrgID(context.Background(), "fake")
+
+	// The query fails by default.
+	_, err := eng.Query(params).Exec(ctx)
+	require.True(t, errors.Is(err, logqlmodel.ErrLimit))
+
+	// It returns the series with the most samples when asked to truncate the result.
+	ctx = context.WithValue(ctx, httpreq.QuerySeriesLimitStrategyHTTPHeader, httpreq.SeriesLimitStrategyTruncate)
+	res, err := eng.Query(params).Exec(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{SeriesLimitWarning(2, 3)}, res.Warnings)
+
+	matrix := res.Data.(promql.Matrix)
+	require.Len(t, matrix, 2)
+	require.Equal(t, `{app="bar"}`, matrix[0].Metric.String())
+	require.Equal(t, `{app="foo"}`, matrix[1].Metric.String())
+
+	// The sub-queries of a query truncated by the caller return all the series.
+	res, err = eng.Query(params).Exec(httpreq.WithoutSeriesLimit(ctx))
+	require.NoError(t, err)
+	require.Empty(t, res.Warnings)
+	require.Len(t, res.Data.(promql.Matrix), 3)
+}
+
+// parseErrorsQuerier records lines which failed to be parsed in the statistics of the queries.
+type parseErrorsQuerier struct {
+	jsonErrors, logfmtErrors int64
+}
+
+func (q parseErrorsQuerier) SelectLogs(ctx context.Context, _ SelectLogParams) (iter.EntryIterator, error) {
+	st := stats.FromContext(ctx)
+	st.AddParsedLines(10)
+	st.AddJSONParserErrors(q.jsonErrors)
+	st.AddLogfmtParserErrors(q.logfmtErrors)
+	return iter.NoopIterator, nil
+}
+
+func (q parseErrorsQuerier) SelectSamples(context.Context, SelectSampleParams) (iter.SampleIterator, error) {
+	return iter.NoopIterator, nil
+}
+
+func TestEngine_StrictParsing(t *testing.T) {
+	params := LiteralParams{
+		qs:        `{app="foo"} | json`,
+		start:     time.Unix(0, 0),
+		end:       time.Unix(60, 0),
+		step:      60 * time.Second,
+		direction: logproto.FORWARD,
+		limit:     1000,
+	}
+	ctx := user.InjectOrgID(context.Background(), "fake")
+	strictCtx := context.WithValue(ctx, httpreq.QueryStrictParsingHTTPHeader, true)
+
+	for _, tc := range []struct {
+		name             string
+		ctx              context.Context
+		querier          parseErrorsQuerier
+		expectedWarnings []string
+	}{
+		{
+			name:    "not strict",
+			ctx:     ctx,
+			querier: parseErrorsQuerier{jsonErrors: 2, logfmtErrors: 3},
+		},
+		{
+			name:    "no errors",
+			ctx:     strictCtx,
+			querier: parseErrorsQuerier{},
+		},
+		{
+			name:             "json errors",
+			ctx:              strictCtx,
+			querier:          parseErrorsQuerier{jsonErrors: 2},
+			expectedWarnings: []string{jsonParserErrorsWarning},
+		},
+		{
+			name:             "json and logfmt errors",
+			ctx:              strictCtx,
+			querier:          parseErrorsQuerier{jsonErrors: 2, logfmtErrors: 3},
+			expectedWarnings: []string{jsonParserErrorsWarning, logfmtParserErrorsWarning},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			eng := NewEngine(EngineOpts{}, tc.querier, NoLimits, log.NewNopLogger())
+			res, err := eng.Query(params).Exec(tc.ctx)
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedWarnings, res.Warnings)
+
+			// the failures are reported in the statistics either way.
+			require.Equal(t, tc.querier.jsonErrors+tc.querier.logfmtErrors, res.Statistics.Summary.TotalParseErrors)
+			require.Equal(t, float64(tc.querier.jsonErrors+tc.querier.logfmtErrors)/10, res.Statistics.Summary.ParseErrorRate)
+		})
+	}
+}
+
+// quarantineQuerier records chunks skipped because they are quarantined in the statistics of the queries.
+type quarantineQuerier struct {
+	quarantined int64
+}
+
+func (q quarantineQuerier) SelectLogs(ctx context.Context, _ SelectLogParams) (iter.EntryIterator, error) {
+	stats.FromContext(ctx).AddChunksQuarantined(q.quarantined)
+	return iter.NoopIterator, nil
+}
+
+func (q quarantineQuerier) SelectSamples(ctx context.Context, _ SelectSampleParams) (iter.SampleIterator, error) {
+	stats.FromContext(ctx).AddChunksQuarantined(q.quarantined)
+	return iter.NoopIterator, nil
+}
+
+func TestEngine_QuarantinedChunks(t *testing.T) {
+	ctx := user.InjectOrgID(context.Background(), "fake")
+	for _, qs := range []string{`{app="foo"}`, `count_over_time({app="foo"}[1m])`} {
+		params := LiteralParams{
+			qs:        qs,
+			start:     time.Unix(0, 0),
+			end:       time.Unix(60, 0),
+			step:      60 * time.Second,
+			direction: logproto.FORWARD,
+			limit:     1000,
+		}
+
+		eng := NewEngine(EngineOpts{}, quarantineQuerier{}, NoLimits, log.NewNopLogger())
+		res, err := eng.Query(params).Exec(ctx)
+		require.NoError(t, err)
+		require.Empty(t, res.Warnings)
+
+		eng = NewEngine(EngineOpts{}, quarantineQuerier{quarantined: 2}, NoLimits, log.NewNopLogger())
+		res, err = eng.Query(params).Exec(ctx)
+		require.NoError(t, err)
+		require.Equal(t, []string{quarantinedChunksWarning}, res.Warnings)
+		require.Equal(t, int64(2), res.Statistics.TotalChunksQuarantined())
+	}
+}
+
 // go test -mod=vendor ./pkg/logql/ -bench=.  -benchmem -memprofile memprofile.out -cpuprofile cpuprofile.out
 func BenchmarkRangeQuery100000(b *testing.B) {
 	benchmarkRangeQuery(int64(100000), b)
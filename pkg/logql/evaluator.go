@@ -186,7 +186,7 @@ func (ev *DefaultEvaluator) StepEvaluator(
 				if err != nil {
 					return nil, err
 				}
-				return rangeAggEvaluator(iter.NewPeekingSampleIterator(it), rangExpr, q, rangExpr.Left.Offset)
+				return rangeAggEvaluator(ctx, iter.NewPeekingSampleIterator(it), rangExpr, q, rangExpr.Left.Offset)
 			})
 		}
 		return vectorAggEvaluator(ctx, nextEv, e, q)
@@ -202,7 +202,7 @@ func (ev *DefaultEvaluator) StepEvaluator(
 		if err != nil {
 			return nil, err
 		}
-		return rangeAggEvaluator(iter.NewPeekingSampleIterator(it), e, q, e.Left.Offset)
+		return rangeAggEvaluator(ctx, iter.NewPeekingSampleIterator(it), e, q, e.Left.Offset)
 	case *syntax.BinOpExpr:
 		return binOpStepEvaluator(ctx, nextEv, e, q)
 	case *syntax.LabelReplaceExpr:
@@ -227,7 +227,15 @@ func vectorAggEvaluator(
 	}
 	lb := labels.NewBuilder(nil)
 	buf := make([]byte, 0, 1024)
-	sort.Strings(expr.Grouping.Groups)
+	groupLabels := expr.Grouping.Labels()
+	sort.Strings(groupLabels)
+	// groups caches the labels of each group across steps, they only depend on the grouping key.
+	groups := map[uint64]labels.Labels{}
+	// derived caches the labels of the series with the labels of the grouping functions across steps.
+	var derived map[uint64]labels.Labels
+	if len(expr.Grouping.Functions) > 0 {
+		derived = map[uint64]labels.Labels{}
+	}
 	return newStepEvaluator(func() (bool, int64, promql.Vector) {
 		next, ts, vec := nextEvaluator.Next()
 
@@ -242,34 +250,49 @@ func vectorAggEvaluator(
 		}
 		for _, s := range vec {
 			metric := s.Metric
+			if derived != nil {
+				h := metric.Hash()
+				m, ok := derived[h]
+				if !ok {
+					lb.Reset(metric)
+					for _, f := range expr.Grouping.Functions {
+						lb.Set(f.Name, f.Value(metric))
+					}
+					m = lb.Labels()
+					derived[h] = m
+				}
+				metric = m
+			}
 
 			var groupingKey uint64
 			if expr.Grouping.Without {
-				groupingKey, buf = metric.HashWithoutLabels(buf, expr.Grouping.Groups...)
+				groupingKey, buf = metric.HashWithoutLabels(buf, groupLabels...)
 			} else {
-				groupingKey, buf = metric.HashForLabels(buf, expr.Grouping.Groups...)
+				groupingKey, buf = metric.HashForLabels(buf, groupLabels...)
 			}
 			group, ok := result[groupingKey]
 			// Add a new group if it doesn't exist.
 			if !ok {
-				var m labels.Labels
-
-				if expr.Grouping.Without {
-					lb.Reset(metric)
-					lb.Del(expr.Grouping.Groups...)
-					lb.Del(labels.MetricName)
-					m = lb.Labels()
-				} else {
-					m = make(labels.Labels, 0, len(expr.Grouping.Groups))
-					for _, l := range metric {
-						for _, n := range expr.Grouping.Groups {
-							if l.Name == n {
-								m = append(m, l)
-								break
+				m, ok := groups[groupingKey]
+				if !ok {
+					if expr.Grouping.Without {
+						lb.Reset(metric)
+						lb.Del(groupLabels...)
+						lb.Del(labels.MetricName)
+						m = lb.Labels()
+					} else {
+						m = make(labels.Labels, 0, len(groupLabels))
+						for _, l := range metric {
+							for _, n := range groupLabels {
+								if l.Name == n {
+									m = append(m, l)
+									break
+								}
 							}
 						}
+						sort.Sort(m)
 					}
-					sort.Sort(m)
+					groups[groupingKey] = m
 				}
 				result[groupingKey] = &groupedAggregation{
 					labels:     m,
@@ -409,21 +432,28 @@ func vectorAggEvaluator(
 }
 
 func rangeAggEvaluator(
+	ctx context.Context,
 	it iter.PeekingSampleIterator,
 	expr *syntax.RangeAggregationExpr,
 	q Params,
 	o time.Duration,
 ) (StepEvaluator, error) {
-	agg, err := aggregator(expr)
-	if err != nil {
-		return nil, err
-	}
-	iter := newRangeVectorIterator(
-		it,
-		expr.Left.Interval.Nanoseconds(),
-		q.Step().Nanoseconds(),
-		q.Start().UnixNano(), q.End().UnixNano(), o.Nanoseconds(),
+	var (
+		iter                       RangeVectorIterator
+		selRange, step, start, end = expr.Left.Interval.Nanoseconds(), q.Step().Nanoseconds(), q.Start().UnixNano(), q.End().UnixNano()
 	)
+	if syntax.IsHistogramRangeOp(expr.Operation) {
+		iter = newHistogramRangeVectorIterator(it, histogramSchema(expr), selRange, step, start, end, o.Nanoseconds(), labelsCacheFromContext(ctx))
+	} else if agg := partialsAggregator(expr); agg != nil && useIncrementalRangeVector(selRange, step, start, end) {
+		// overlapping steps reuse the aggregates of the samples they share when possible.
+		iter = newIncrementalRangeVectorIterator(it, agg, selRange, step, start, end, o.Nanoseconds(), labelsCacheFromContext(ctx))
+	} else {
+		agg, err := aggregator(expr)
+		if err != nil {
+			return nil, err
+		}
+		iter = newRangeVectorIterator(it, agg, selRange, step, start, end, o.Nanoseconds(), labelsCacheFromContext(ctx))
+	}
 	if expr.Operation == syntax.OpRangeTypeAbsent {
 		return &absentRangeVectorEvaluator{
 			iter: iter,
@@ -432,12 +462,10 @@ func rangeAggEvaluator(
 	}
 	return &rangeVectorEvaluator{
 		iter: iter,
-		agg:  agg,
 	}, nil
 }
 
 type rangeVectorEvaluator struct {
-	agg  RangeVectorAggregator
 	iter RangeVectorIterator
 
 	err error
@@ -448,7 +476,7 @@ func (r *rangeVectorEvaluator) Next() (bool, int64, promql.Vector) {
 	if !next {
 		return false, 0, promql.Vector{}
 	}
-	ts, vec := r.iter.At(r.agg)
+	ts, vec := r.iter.At()
 	for _, s := range vec {
 		// Errors are not allowed in metrics.
 		if s.Metric.Has(logqlmodel.ErrorLabel) {
@@ -480,7 +508,7 @@ func (r *absentRangeVectorEvaluator) Next() (bool, int64, promql.Vector) {
 	if !next {
 		return false, 0, promql.Vector{}
 	}
-	ts, vec := r.iter.At(one)
+	ts, vec := r.iter.At()
 	for _, s := range vec {
 		// Errors are not allowed in metrics.
 		if s.Metric.Has(logqlmodel.ErrorLabel) {
@@ -0,0 +1,83 @@
+package logql
+
+import (
+	"context"
+	"sync"
+
+	"github.com/prometheus/prometheus/model/labels"
+	promql_parser "github.com/prometheus/prometheus/promql/parser"
+)
+
+type labelsCacheCtxKeyType string
+
+const (
+	labelsCacheKey labelsCacheCtxKeyType = "labelsCache"
+
+	// defaultMaxLabelsCacheSize is the default number of label sets cached for a single query.
+	defaultMaxLabelsCacheSize = 100000
+)
+
+// labelsCache caches label sets parsed from their string representation for
+// the duration of a query execution. Metric queries over many chunks receive
+// the same label strings for every sample, and each range aggregation of the
+// query would otherwise parse them again.
+//
+// The cached labels are shared by all the series and steps of the query, and
+// by the groups of the aggregations built from them: the evaluators must never
+// modify the labels of a sample in place, and always build new ones instead.
+//
+// A nil labelsCache is valid and parses labels without caching them.
+type labelsCache struct {
+	mtx    sync.RWMutex
+	parsed map[string]labels.Labels
+	max    int
+}
+
+func newLabelsCache(max int) *labelsCache {
+	return &labelsCache{
+		parsed: map[string]labels.Labels{},
+		max:    max,
+	}
+}
+
+// withLabelsCache returns a context holding a new labels cache of at most max
+// label sets, unless the context already has one.
+func withLabelsCache(ctx context.Context, max int) context.Context {
+	if labelsCacheFromContext(ctx) != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, labelsCacheKey, newLabelsCache(max))
+}
+
+// labelsCacheFromContext returns the labels cache of the query, or nil if there's none.
+func labelsCacheFromContext(ctx context.Context) *labelsCache {
+	c, _ := ctx.Value(labelsCacheKey).(*labelsCache)
+	return c
+}
+
+// Parse returns the labels for the given string, parsing it only the first
+// time it is seen. The returned labels are shared and must not be modified.
+func (c *labelsCache) Parse(s string) (labels.Labels, error) {
+	if c == nil {
+		return promql_parser.ParseMetric(s)
+	}
+
+	c.mtx.RLock()
+	lbs, ok := c.parsed[s]
+	c.mtx.RUnlock()
+	if ok {
+		return lbs, nil
+	}
+
+	lbs, err := promql_parser.ParseMetric(s)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mtx.Lock()
+	if len(c.parsed) < c.max {
+		c.parsed[s] = lbs
+	}
+	c.mtx.Unlock()
+	return lbs, nil
+}
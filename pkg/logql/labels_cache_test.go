@@ -0,0 +1,86 @@
+package logql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/prometheus/model/labels"
+	promql_parser "github.com/prometheus/prometheus/promql/parser"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+)
+
+func TestLabelsCache(t *testing.T) {
+	ctx := context.Background()
+	require.Nil(t, labelsCacheFromContext(ctx))
+
+	ctx = withLabelsCache(ctx, 2)
+	c := labelsCacheFromContext(ctx)
+	require.NotNil(t, c)
+	// an existing cache is kept for sub-queries.
+	require.Same(t, c, labelsCacheFromContext(withLabelsCache(ctx, 2)))
+
+	expected := labels.Labels{{Name: "app", Value: "foo"}, {Name: "level", Value: "error"}}
+	lbs, err := c.Parse(`{level="error", app="foo"}`)
+	require.NoError(t, err)
+	require.Equal(t, expected, lbs)
+	require.Len(t, c.parsed, 1)
+
+	lbs, err = c.Parse(`{level="error", app="foo"}`)
+	require.NoError(t, err)
+	require.Equal(t, expected, lbs)
+	require.Len(t, c.parsed, 1)
+
+	_, err = c.Parse(`{level=}`)
+	require.Error(t, err)
+	require.Len(t, c.parsed, 1)
+
+	// the labels parsed once the cache is full aren't cached.
+	_, err = c.Parse(`{app="bar"}`)
+	require.NoError(t, err)
+	_, err = c.Parse(`{app="buzz"}`)
+	require.NoError(t, err)
+	require.Len(t, c.parsed, 2)
+
+	// a nil cache parses without caching.
+	var nilCache *labelsCache
+	lbs, err = nilCache.Parse(`{level="error", app="foo"}`)
+	require.NoError(t, err)
+	require.Equal(t, expected, lbs)
+}
+
+func TestLabelsCache_Immutable(t *testing.T) {
+	eng := NewEngine(EngineOpts{}, getLocalQuerier(1000), &fakeLimits{maxSeries: 1000}, log.NewNopLogger())
+	for _, qs := range []string{
+		`sum by (app) (rate({app=~".+"}[1m]))`,
+		`sum without (bar) (count_over_time({app=~".+"}[1m]))`,
+		`topk(2, rate({app=~".+"}[1m]))`,
+		`label_replace(rate({app=~".+"}[1m]), "app", "$1-replaced", "bar", "(.*)")`,
+		`rate({app=~".+"}[1m]) / on (app, bar) count_over_time({app=~".+"}[1m])`,
+		`rate({app=~".+"}[1m]) * 2`,
+		`sum by (app) (rate({app=~".+"} | label_format bar="x" [1m]))`,
+	} {
+		t.Run(qs, func(t *testing.T) {
+			ctx := withLabelsCache(user.InjectOrgID(context.Background(), "fake"), defaultMaxLabelsCacheSize)
+			q := eng.Query(LiteralParams{
+				qs:    qs,
+				start: time.Unix(0, 0),
+				end:   time.Unix(1000, 0),
+				step:  time.Minute,
+			})
+			_, err := q.Exec(ctx)
+			require.NoError(t, err)
+
+			// the evaluators never modify the cached labels shared across series and steps.
+			c := labelsCacheFromContext(ctx)
+			require.NotEmpty(t, c.parsed)
+			for s, lbs := range c.parsed {
+				expected, err := promql_parser.ParseMetric(s)
+				require.NoError(t, err)
+				require.Equal(t, expected, lbs)
+			}
+		})
+	}
+}
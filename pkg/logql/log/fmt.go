@@ -4,17 +4,23 @@ import (
 	"bytes"
 	"fmt"
 	"strings"
+	"sync"
 	"text/template"
 	"text/template/parse"
+	"time"
 
 	"github.com/Masterminds/sprig/v3"
 	"github.com/grafana/regexp"
+	"github.com/prometheus/prometheus/model/labels"
 
 	"github.com/grafana/loki/pkg/logqlmodel"
 )
 
 const (
-	functionLineName = "__line__"
+	functionLineName      = "__line__"
+	functionTimestampName = "__timestamp__"
+
+	localDateLayout = "2006-01-02"
 )
 
 var (
@@ -43,6 +49,9 @@ var (
 		},
 	}
 
+	// locations caches the time zones loaded by the calendar functions, by name.
+	locations sync.Map
+
 	// sprig template functions
 	templateFunctions = []string{
 		"lower",
@@ -81,6 +90,7 @@ var (
 		"round",
 		"fromJson",
 		"date",
+		"dateInZone",
 		"toDate",
 		"now",
 		"unixEpoch",
@@ -99,19 +109,21 @@ func init() {
 type LineFormatter struct {
 	*template.Template
 	buf *bytes.Buffer
+	// data is the template data, reused across lines.
+	data map[string]string
 
 	currentLine []byte
+	currentTs   int64
 }
 
 // NewFormatter creates a new log line formatter from a given text template.
 func NewFormatter(tmpl string) (*LineFormatter, error) {
 	lf := &LineFormatter{
-		buf: bytes.NewBuffer(make([]byte, 4096)),
-	}
-	functions := make(map[string]interface{}, len(functionMap)+1)
-	for k, v := range functionMap {
-		functions[k] = v
+		buf:  bytes.NewBuffer(make([]byte, 4096)),
+		data: map[string]string{},
 	}
+	functions := timestampFunctions(&lf.currentTs)
+	// The line is only read by the template, whose output is always copied.
 	functions[functionLineName] = func() string {
 		return unsafeGetString(lf.currentLine)
 	}
@@ -126,17 +138,67 @@ func NewFormatter(tmpl string) (*LineFormatter, error) {
 func (lf *LineFormatter) Process(line []byte, lbs *LabelsBuilder) ([]byte, bool) {
 	lf.buf.Reset()
 	lf.currentLine = line
+	lf.currentTs = lbs.ts
 
-	if err := lf.Template.Execute(lf.buf, lbs.Labels().Map()); err != nil {
+	if err := lf.Template.Execute(lf.buf, labelsMap(lf.data, lbs.Labels())); err != nil {
 		lbs.SetErr(errTemplateFormat)
 		return line, true
 	}
-	// todo(cyriltovena): we might want to reuse the input line or a bytes buffer.
+	// The buffer is reused for the next line, so the result must be copied
+	// since it escapes the pipeline.
 	res := make([]byte, len(lf.buf.Bytes()))
 	copy(res, lf.buf.Bytes())
 	return res, true
 }
 
+// timestampFunctions returns the template functions, with the functions reading the timestamp of the line
+// being processed from ts: __timestamp__ and the calendar functions hourOfDay, dayOfWeek and localDate,
+// taking the name of the time zone of the calendar, UTC if empty.
+func timestampFunctions(ts *int64) template.FuncMap {
+	functions := make(template.FuncMap, len(functionMap)+5)
+	for k, v := range functionMap {
+		functions[k] = v
+	}
+	functions[functionTimestampName] = func() time.Time {
+		return time.Unix(0, *ts).UTC()
+	}
+	functions["hourOfDay"] = func(zone string) (int, error) {
+		t, err := inZone(*ts, zone)
+		if err != nil {
+			return 0, err
+		}
+		return t.Hour(), nil
+	}
+	functions["dayOfWeek"] = func(zone string) (string, error) {
+		t, err := inZone(*ts, zone)
+		if err != nil {
+			return "", err
+		}
+		return t.Weekday().String(), nil
+	}
+	functions["localDate"] = func(zone string) (string, error) {
+		t, err := inZone(*ts, zone)
+		if err != nil {
+			return "", err
+		}
+		return t.Format(localDateLayout), nil
+	}
+	return functions
+}
+
+// inZone returns the time of the timestamp in the time zone.
+func inZone(ts int64, zone string) (time.Time, error) {
+	if loc, ok := locations.Load(zone); ok {
+		return time.Unix(0, ts).In(loc.(*time.Location)), nil
+	}
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return time.Time{}, err
+	}
+	locations.Store(zone, loc)
+	return time.Unix(0, ts).In(loc), nil
+}
+
 func (lf *LineFormatter) RequiredLabelNames() []string {
 	return uniqueString(listNodeFields([]parse.Node{lf.Root}))
 }
@@ -229,6 +291,10 @@ type labelFormatter struct {
 type LabelsFormatter struct {
 	formats []labelFormatter
 	buf     *bytes.Buffer
+	// data is the template data, reused across lines.
+	data map[string]string
+
+	currentTs int64
 }
 
 // NewLabelsFormatter creates a new formatter that can format multiple labels at once.
@@ -238,23 +304,25 @@ func NewLabelsFormatter(fmts []LabelFmt) (*LabelsFormatter, error) {
 	if err := validate(fmts); err != nil {
 		return nil, err
 	}
-	formats := make([]labelFormatter, 0, len(fmts))
+	lf := &LabelsFormatter{
+		formats: make([]labelFormatter, 0, len(fmts)),
+		buf:     bytes.NewBuffer(make([]byte, 1024)),
+		data:    map[string]string{},
+	}
+	functions := timestampFunctions(&lf.currentTs)
 
 	for _, fm := range fmts {
 		toAdd := labelFormatter{LabelFmt: fm}
 		if !fm.Rename {
-			t, err := template.New("label").Option("missingkey=zero").Funcs(functionMap).Parse(fm.Value)
+			t, err := template.New("label").Option("missingkey=zero").Funcs(functions).Parse(fm.Value)
 			if err != nil {
 				return nil, fmt.Errorf("invalid template for label '%s': %s", fm.Name, err)
 			}
 			toAdd.tmpl = t
 		}
-		formats = append(formats, toAdd)
+		lf.formats = append(lf.formats, toAdd)
 	}
-	return &LabelsFormatter{
-		formats: formats,
-		buf:     bytes.NewBuffer(make([]byte, 1024)),
-	}, nil
+	return lf, nil
 }
 
 func validate(fmts []LabelFmt) error {
@@ -275,6 +343,7 @@ func validate(fmts []LabelFmt) error {
 
 func (lf *LabelsFormatter) Process(l []byte, lbs *LabelsBuilder) ([]byte, bool) {
 	var data interface{}
+	lf.currentTs = lbs.ts
 	for _, f := range lf.formats {
 		if f.Rename {
 			v, ok := lbs.Get(f.Value)
@@ -286,7 +355,7 @@ func (lf *LabelsFormatter) Process(l []byte, lbs *LabelsBuilder) ([]byte, bool)
 		}
 		lf.buf.Reset()
 		if data == nil {
-			data = lbs.Labels().Map()
+			data = labelsMap(lf.data, lbs.Labels())
 		}
 		if err := f.tmpl.Execute(lf.buf, data); err != nil {
 			lbs.SetErr(errTemplateFormat)
@@ -297,6 +366,18 @@ func (lf *LabelsFormatter) Process(l []byte, lbs *LabelsBuilder) ([]byte, bool)
 	return l, true
 }
 
+// labelsMap fills the given map with the labels, after removing its previous content.
+// This avoids allocating a new map for every line given to a template.
+func labelsMap(m map[string]string, lbs labels.Labels) map[string]string {
+	for k := range m {
+		delete(m, k)
+	}
+	for _, l := range lbs {
+		m[l.Name] = l.Value
+	}
+	return m
+}
+
 func (lf *LabelsFormatter) RequiredLabelNames() []string {
 	var names []string
 	for _, fm := range lf.formats {
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/stretchr/testify/require"
@@ -346,6 +347,60 @@ func mustNewLabelsFormatter(fmts []LabelFmt) *LabelsFormatter {
 	return lf
 }
 
+func Test_timestampFunctions(t *testing.T) {
+	// Monday 2022-03-07 23:30 UTC is Tuesday 2022-03-08 08:30 in Tokyo.
+	ts := time.Date(2022, 3, 7, 23, 30, 0, 0, time.UTC).UnixNano()
+	lbs := labels.Labels{{Name: "app", Value: "foo"}}
+
+	for _, tt := range []struct {
+		name    string
+		stage   Stage
+		want    string
+		wantLbs labels.Labels
+	}{
+		{
+			"timestamp",
+			newMustLineFormatter(`{{ dateInZone "2006-01-02T15:04" __timestamp__ "UTC" }} {{ __timestamp__ | unixEpoch }}`),
+			"2022-03-07T23:30 1646695800",
+			lbs,
+		},
+		{
+			"calendar in utc",
+			newMustLineFormatter(`{{ hourOfDay "" }} {{ dayOfWeek "UTC" }} {{ localDate "" }}`),
+			"23 Monday 2022-03-07",
+			lbs,
+		},
+		{
+			"calendar in zone",
+			newMustLineFormatter(`{{ hourOfDay "Asia/Tokyo" }} {{ dayOfWeek "Asia/Tokyo" }} {{ localDate "Asia/Tokyo" }}`),
+			"8 Tuesday 2022-03-08",
+			lbs,
+		},
+		{
+			"business hours label",
+			mustNewLabelsFormatter([]LabelFmt{NewTemplateLabelFmt("business_hours",
+				`{{ $h := hourOfDay "Europe/Paris" }}{{ if and (ge $h 9) (lt $h 18) }}true{{ else }}false{{ end }}`)}),
+			"line",
+			labels.Labels{{Name: "app", Value: "foo"}, {Name: "business_hours", Value: "false"}},
+		},
+		{
+			"unknown zone",
+			newMustLineFormatter(`{{ hourOfDay "Mars/Olympus" }}`),
+			"line",
+			labels.Labels{{Name: "app", Value: "foo"}, {Name: logqlmodel.ErrorLabel, Value: errTemplateFormat}},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPipeline([]Stage{tt.stage}).ForStream(lbs)
+			line, res, ok := p.ProcessString(ts, "line")
+			require.True(t, ok)
+			require.Equal(t, tt.want, line)
+			sort.Sort(tt.wantLbs)
+			require.Equal(t, tt.wantLbs, res.Labels())
+		})
+	}
+}
+
 func Test_validate(t *testing.T) {
 	tests := []struct {
 		name    string
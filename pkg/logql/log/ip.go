@@ -133,11 +133,12 @@ func (f *IPLabelFilter) filterTy(_ []byte, ty LabelFilterType, lbs *LabelsBuilde
 		return false
 	}
 
+	// the filter only reads the value, so we can avoid copying it.
 	switch ty {
 	case LabelFilterEqual:
-		return f.ip.filter([]byte(input))
+		return f.ip.filter(unsafeGetBytes(input))
 	case LabelFilterNotEqual:
-		return !f.ip.filter([]byte(input))
+		return !f.ip.filter(unsafeGetBytes(input))
 	}
 	return false
 }
@@ -190,7 +191,8 @@ func (f *ipFilter) filter(line []byte) bool {
 		if iplen < 0 {
 			return false, 0
 		}
-		ip, err := netaddr.ParseIP(string(line[start : start+iplen]))
+		// netaddr.ParseIP doesn't retain the string, so we can avoid copying the line.
+		ip, err := netaddr.ParseIP(unsafeGetString(line[start : start+iplen]))
 		if err == nil {
 			if containsIP(f.matcher, ip) {
 				return true, 0
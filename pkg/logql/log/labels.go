@@ -69,11 +69,15 @@ type BaseLabelsBuilder struct {
 	// nolint:structcheck
 	// https://github.com/golangci/golangci-lint/issues/826
 	err string
+	// ts is the timestamp in nanoseconds of the line being processed.
+	ts int64
 
 	groups            []string
 	parserKeyHints    ParserHint // label key hints for metric queries that allows to limit parser extractions to only this list of labels.
 	without, noLabels bool
 
+	parseStats ParseStats
+
 	resultCache map[uint64]LabelsResult
 	*hasher
 }
@@ -134,6 +138,21 @@ func (b *LabelsBuilder) Reset() {
 	b.err = ""
 }
 
+// recordParsedLine records a line processed by the json or logfmt parser, with the error category
+// of the parser when the line failed to be parsed.
+func (b *BaseLabelsBuilder) recordParsedLine(err string) {
+	if b.parseStats == nil {
+		return
+	}
+	b.parseStats.AddParsedLines(1)
+	switch err {
+	case errJSON:
+		b.parseStats.AddJSONParserErrors(1)
+	case errLogfmt:
+		b.parseStats.AddLogfmtParserErrors(1)
+	}
+}
+
 // ParserLabelHints returns a limited list of expected labels to extract for metric queries.
 // Returns nil when it's impossible to hint labels extractions.
 func (b *BaseLabelsBuilder) ParserLabelHints() ParserHint {
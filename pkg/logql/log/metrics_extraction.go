@@ -34,8 +34,8 @@ type SampleExtractor interface {
 // A StreamSampleExtractor never mutate the received line.
 type StreamSampleExtractor interface {
 	BaseLabels() LabelsResult
-	Process(line []byte) (float64, LabelsResult, bool)
-	ProcessString(line string) (float64, LabelsResult, bool)
+	Process(ts int64, line []byte) (float64, LabelsResult, bool)
+	ProcessString(ts int64, line string) (float64, LabelsResult, bool)
 }
 
 type lineSampleExtractor struct {
@@ -80,12 +80,13 @@ type streamLineSampleExtractor struct {
 	builder *LabelsBuilder
 }
 
-func (l *streamLineSampleExtractor) Process(line []byte) (float64, LabelsResult, bool) {
+func (l *streamLineSampleExtractor) Process(ts int64, line []byte) (float64, LabelsResult, bool) {
 	// short circuit.
 	if l.Stage == NoopStage {
 		return l.LineExtractor(line), l.builder.GroupedLabels(), true
 	}
 	l.builder.Reset()
+	l.builder.ts = ts
 	line, ok := l.Stage.Process(line, l.builder)
 	if !ok {
 		return 0, nil, false
@@ -93,9 +94,9 @@ func (l *streamLineSampleExtractor) Process(line []byte) (float64, LabelsResult,
 	return l.LineExtractor(line), l.builder.GroupedLabels(), true
 }
 
-func (l *streamLineSampleExtractor) ProcessString(line string) (float64, LabelsResult, bool) {
+func (l *streamLineSampleExtractor) ProcessString(ts int64, line string) (float64, LabelsResult, bool) {
 	// unsafe get bytes since we have the guarantee that the line won't be mutated.
-	return l.Process(unsafeGetBytes(line))
+	return l.Process(ts, unsafeGetBytes(line))
 }
 
 func (l *streamLineSampleExtractor) BaseLabels() LabelsResult { return l.builder.currentResult }
@@ -168,9 +169,10 @@ func (l *labelSampleExtractor) ForStream(labels labels.Labels) StreamSampleExtra
 	return res
 }
 
-func (l *streamLabelSampleExtractor) Process(line []byte) (float64, LabelsResult, bool) {
+func (l *streamLabelSampleExtractor) Process(ts int64, line []byte) (float64, LabelsResult, bool) {
 	// Apply the pipeline first.
 	l.builder.Reset()
+	l.builder.ts = ts
 	line, ok := l.preStage.Process(line, l.builder)
 	if !ok {
 		return 0, nil, false
@@ -194,9 +196,9 @@ func (l *streamLabelSampleExtractor) Process(line []byte) (float64, LabelsResult
 	return v, l.builder.GroupedLabels(), true
 }
 
-func (l *streamLabelSampleExtractor) ProcessString(line string) (float64, LabelsResult, bool) {
+func (l *streamLabelSampleExtractor) ProcessString(ts int64, line string) (float64, LabelsResult, bool) {
 	// unsafe get bytes since we have the guarantee that the line won't be mutated.
-	return l.Process(unsafeGetBytes(line))
+	return l.Process(ts, unsafeGetBytes(line))
 }
 
 func (l *streamLabelSampleExtractor) BaseLabels() LabelsResult { return l.builder.currentResult }
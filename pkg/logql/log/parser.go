@@ -62,8 +62,10 @@ func (j *JSONParser) Process(line []byte, lbs *LabelsBuilder) ([]byte, bool) {
 
 	if err := j.readObject(it); err != nil {
 		lbs.SetErr(errJSON)
+		lbs.recordParsedLine(errJSON)
 		return line, true
 	}
+	lbs.recordParsedLine("")
 	return line, true
 }
 
@@ -296,8 +298,10 @@ func (l *LogfmtParser) Process(line []byte, lbs *LabelsBuilder) ([]byte, bool) {
 	}
 	if l.dec.Err() != nil {
 		lbs.SetErr(errLogfmt)
+		lbs.recordParsedLine(errLogfmt)
 		return line, true
 	}
+	lbs.recordParsedLine("")
 	return line, true
 }
 
@@ -381,8 +385,10 @@ func (j *JSONExpressionParser) Process(line []byte, lbs *LabelsBuilder) ([]byte,
 
 	if !jsoniter.ConfigFastest.Valid(line) {
 		lbs.SetErr(errJSON)
+		lbs.recordParsedLine(errJSON)
 		return line, true
 	}
+	lbs.recordParsedLine("")
 
 	for identifier, paths := range j.expressions {
 		result := jsoniter.ConfigFastest.Get(line, paths...).ToString()
@@ -19,8 +19,35 @@ type Pipeline interface {
 // A StreamPipeline never mutate the received line.
 type StreamPipeline interface {
 	BaseLabels() LabelsResult
-	Process(line []byte) (resultLine []byte, resultLabels LabelsResult, skip bool)
-	ProcessString(line string) (resultLine string, resultLabels LabelsResult, skip bool)
+	Process(ts int64, line []byte) (resultLine []byte, resultLabels LabelsResult, skip bool)
+	ProcessString(ts int64, line string) (resultLine string, resultLabels LabelsResult, skip bool)
+}
+
+// ParseStats records the lines processed by the json and logfmt parsers of a pipeline,
+// and the ones which failed to be parsed by error category.
+type ParseStats interface {
+	AddParsedLines(i int64)
+	AddJSONParserErrors(i int64)
+	AddLogfmtParserErrors(i int64)
+}
+
+// PipelineWithParseStats makes the json and logfmt parsers of the pipeline record the lines they process in s.
+func PipelineWithParseStats(p Pipeline, s ParseStats) Pipeline {
+	if p, ok := p.(*pipeline); ok {
+		p.baseBuilder.parseStats = s
+	}
+	return p
+}
+
+// SampleExtractorWithParseStats makes the json and logfmt parsers of the extractor record the lines they process in s.
+func SampleExtractorWithParseStats(ex SampleExtractor, s ParseStats) SampleExtractor {
+	switch ex := ex.(type) {
+	case *lineSampleExtractor:
+		ex.baseBuilder.parseStats = s
+	case *labelSampleExtractor:
+		ex.baseBuilder.parseStats = s
+	}
+	return ex
 }
 
 // Stage is a single step of a Pipeline.
@@ -52,11 +79,11 @@ type noopStreamPipeline struct {
 	LabelsResult
 }
 
-func (n noopStreamPipeline) Process(line []byte) ([]byte, LabelsResult, bool) {
+func (n noopStreamPipeline) Process(_ int64, line []byte) ([]byte, LabelsResult, bool) {
 	return line, n.LabelsResult, true
 }
 
-func (n noopStreamPipeline) ProcessString(line string) (string, LabelsResult, bool) {
+func (n noopStreamPipeline) ProcessString(_ int64, line string) (string, LabelsResult, bool) {
 	return line, n.LabelsResult, true
 }
 
@@ -135,9 +162,10 @@ func (p *pipeline) ForStream(labels labels.Labels) StreamPipeline {
 	return res
 }
 
-func (p *streamPipeline) Process(line []byte) ([]byte, LabelsResult, bool) {
+func (p *streamPipeline) Process(ts int64, line []byte) ([]byte, LabelsResult, bool) {
 	var ok bool
 	p.builder.Reset()
+	p.builder.ts = ts
 	for _, s := range p.stages {
 		line, ok = s.Process(line, p.builder)
 		if !ok {
@@ -147,10 +175,10 @@ func (p *streamPipeline) Process(line []byte) ([]byte, LabelsResult, bool) {
 	return line, p.builder.LabelsResult(), true
 }
 
-func (p *streamPipeline) ProcessString(line string) (string, LabelsResult, bool) {
+func (p *streamPipeline) ProcessString(ts int64, line string) (string, LabelsResult, bool) {
 	// Stages only read from the line.
 	lb := unsafeGetBytes(line)
-	lb, lr, ok := p.Process(lb)
+	lb, lr, ok := p.Process(ts, lb)
 	// either the line is unchanged and we can just send back the same string.
 	// or we created a new buffer for it in which case it is still safe to avoid the string(byte) copy.
 	return unsafeGetString(lb), lr, ok
@@ -182,6 +210,9 @@ func ReduceStages(stages []Stage) Stage {
 	}
 }
 
+// unsafeGetBytes returns the bytes of the string without copying them.
+// The returned slice must never be mutated: stages only read the line they
+// are given and allocate a new one when they change it.
 func unsafeGetBytes(s string) []byte {
 	var buf []byte
 	p := unsafe.Pointer(&buf)
@@ -190,6 +221,10 @@ func unsafeGetBytes(s string) []byte {
 	return buf
 }
 
+// unsafeGetString returns a string sharing the memory of the given buffer.
+// It must only be used when the string doesn't outlive the buffer content,
+// for instance for lookups or parsing, or when the buffer is never reused.
+// Results escaping the pipeline, like label values, must be copied instead.
 func unsafeGetString(buf []byte) string {
 	return *((*string)(unsafe.Pointer(&buf)))
 }
@@ -12,12 +12,12 @@ import (
 
 func TestNoopPipeline(t *testing.T) {
 	lbs := labels.Labels{{Name: "foo", Value: "bar"}}
-	l, lbr, ok := NewNoopPipeline().ForStream(lbs).Process([]byte(""))
+	l, lbr, ok := NewNoopPipeline().ForStream(lbs).Process(0, []byte(""))
 	require.Equal(t, []byte(""), l)
 	require.Equal(t, NewLabelsResult(lbs, lbs.Hash()), lbr)
 	require.Equal(t, true, ok)
 
-	ls, lbr, ok := NewNoopPipeline().ForStream(lbs).ProcessString("")
+	ls, lbr, ok := NewNoopPipeline().ForStream(lbs).ProcessString(0, "")
 	require.Equal(t, "", ls)
 	require.Equal(t, NewLabelsResult(lbs, lbs.Hash()), lbr)
 	require.Equal(t, true, ok)
@@ -29,27 +29,120 @@ func TestPipeline(t *testing.T) {
 		NewStringLabelFilter(labels.MustNewMatcher(labels.MatchEqual, "foo", "bar")),
 		newMustLineFormatter("lbs {{.foo}}"),
 	})
-	l, lbr, ok := p.ForStream(lbs).Process([]byte("line"))
+	l, lbr, ok := p.ForStream(lbs).Process(0, []byte("line"))
 	require.Equal(t, []byte("lbs bar"), l)
 	require.Equal(t, NewLabelsResult(lbs, lbs.Hash()), lbr)
 	require.Equal(t, true, ok)
 
-	ls, lbr, ok := p.ForStream(lbs).ProcessString("line")
+	ls, lbr, ok := p.ForStream(lbs).ProcessString(0, "line")
 	require.Equal(t, "lbs bar", ls)
 	require.Equal(t, NewLabelsResult(lbs, lbs.Hash()), lbr)
 	require.Equal(t, true, ok)
 
-	l, lbr, ok = p.ForStream(labels.Labels{}).Process([]byte("line"))
+	l, lbr, ok = p.ForStream(labels.Labels{}).Process(0, []byte("line"))
 	require.Equal(t, []byte(nil), l)
 	require.Equal(t, nil, lbr)
 	require.Equal(t, false, ok)
 
-	ls, lbr, ok = p.ForStream(labels.Labels{}).ProcessString("line")
+	ls, lbr, ok = p.ForStream(labels.Labels{}).ProcessString(0, "line")
 	require.Equal(t, "", ls)
 	require.Equal(t, nil, lbr)
 	require.Equal(t, false, ok)
 }
 
+func TestPipeline_LineOwnership(t *testing.T) {
+	lbs := labels.Labels{{Name: "foo", Value: "bar"}}
+	ipFilter, err := NewIPLineFilter("127.0.0.1", labels.MatchEqual)
+	require.NoError(t, err)
+	labelsFmt, err := NewLabelsFormatter([]LabelFmt{NewTemplateLabelFmt("line", "{{.msg}}")})
+	require.NoError(t, err)
+
+	p := NewPipeline([]Stage{
+		ipFilter.ToStage(),
+		NewLogfmtParser(),
+		labelsFmt,
+		newMustLineFormatter("{{.msg}} {{__line__}}"),
+	}).ForStream(lbs)
+
+	first := []byte("ip=127.0.0.1 msg=first")
+	firstCopy := string(first)
+	l1, lbr1, ok := p.Process(0, first)
+	require.True(t, ok)
+	res1, lbs1 := string(l1), lbr1.String()
+
+	second := []byte("ip=127.0.0.1 msg=other")
+	_, _, ok = p.Process(0, second)
+	require.True(t, ok)
+
+	// Stages never mutate the given line, and results escaping the pipeline
+	// are not overwritten when processing the next line.
+	require.Equal(t, firstCopy, string(first))
+	require.Equal(t, res1, string(l1))
+	require.Equal(t, "first ip=127.0.0.1 msg=first", string(l1))
+	require.Equal(t, lbs1, lbr1.String())
+	require.Equal(t, `{foo="bar", ip="127.0.0.1", line="first", msg="first"}`, lbs1)
+}
+
+type parseStats struct {
+	parsed, jsonErrors, logfmtErrors int64
+}
+
+func (s *parseStats) AddParsedLines(i int64)        { s.parsed += i }
+func (s *parseStats) AddJSONParserErrors(i int64)   { s.jsonErrors += i }
+func (s *parseStats) AddLogfmtParserErrors(i int64) { s.logfmtErrors += i }
+
+func TestPipelineWithParseStats(t *testing.T) {
+	lbs := labels.Labels{{Name: "foo", Value: "bar"}}
+	lines := []string{`{"a":"b"}`, `a=b`, `a="b`, `not json`}
+
+	var st parseStats
+	p := PipelineWithParseStats(NewPipeline([]Stage{NewJSONParser()}), &st).ForStream(lbs)
+	for _, line := range lines {
+		_, _, ok := p.ProcessString(0, line)
+		require.True(t, ok)
+	}
+	require.Equal(t, parseStats{parsed: 4, jsonErrors: 3}, st)
+
+	// the lines filtered out before the parser are not recorded.
+	st = parseStats{}
+	p = PipelineWithParseStats(NewPipeline([]Stage{
+		mustFilter(NewFilter("a", labels.MatchEqual)).ToStage(),
+		NewLogfmtParser(),
+	}), &st).ForStream(lbs)
+	for _, line := range lines {
+		_, _, _ = p.ProcessString(0, line)
+	}
+	require.Equal(t, parseStats{parsed: 3, logfmtErrors: 2}, st)
+
+	// pipelines without parse stats don't record anything.
+	_, lbr, ok := NewPipeline([]Stage{NewJSONParser()}).ForStream(lbs).ProcessString(0, `not json`)
+	require.True(t, ok)
+	require.Equal(t, errJSON, lbr.Labels().Get(logqlmodel.ErrorLabel))
+}
+
+func TestSampleExtractorWithParseStats(t *testing.T) {
+	lbs := labels.Labels{{Name: "foo", Value: "bar"}}
+
+	var st parseStats
+	ex, err := NewLineSampleExtractor(CountExtractor, []Stage{NewLogfmtParser()}, nil, false, false)
+	require.NoError(t, err)
+	sp := SampleExtractorWithParseStats(ex, &st).ForStream(lbs)
+	for _, line := range []string{`a=b`, `a="b`} {
+		_, _, ok := sp.ProcessString(0, line)
+		require.True(t, ok)
+	}
+	require.Equal(t, parseStats{parsed: 2, logfmtErrors: 1}, st)
+
+	st = parseStats{}
+	ex, err = LabelExtractorWithStages("a", ConvertFloat, nil, false, false, []Stage{NewJSONParser()}, NoopStage)
+	require.NoError(t, err)
+	sp = SampleExtractorWithParseStats(ex, &st).ForStream(lbs)
+	for _, line := range []string{`{"a":"1"}`, `a=1`} {
+		_, _, _ = sp.ProcessString(0, line)
+	}
+	require.Equal(t, parseStats{parsed: 2, jsonErrors: 1}, st)
+}
+
 var (
 	resOK         bool
 	resLine       []byte
@@ -92,13 +185,13 @@ func Benchmark_Pipeline(b *testing.B) {
 	b.Run("pipeline bytes", func(b *testing.B) {
 		b.ResetTimer()
 		for n := 0; n < b.N; n++ {
-			resLine, resLbs, resOK = sp.Process(line)
+			resLine, resLbs, resOK = sp.Process(0, line)
 		}
 	})
 	b.Run("pipeline string", func(b *testing.B) {
 		b.ResetTimer()
 		for n := 0; n < b.N; n++ {
-			resLineString, resLbs, resOK = sp.ProcessString(lineString)
+			resLineString, resLbs, resOK = sp.ProcessString(0, lineString)
 		}
 	})
 
@@ -108,13 +201,13 @@ func Benchmark_Pipeline(b *testing.B) {
 	b.Run("line extractor bytes", func(b *testing.B) {
 		b.ResetTimer()
 		for n := 0; n < b.N; n++ {
-			resSample, resLbs, resOK = ex.Process(line)
+			resSample, resLbs, resOK = ex.Process(0, line)
 		}
 	})
 	b.Run("line extractor string", func(b *testing.B) {
 		b.ResetTimer()
 		for n := 0; n < b.N; n++ {
-			resSample, resLbs, resOK = ex.ProcessString(lineString)
+			resSample, resLbs, resOK = ex.ProcessString(0, lineString)
 		}
 	})
 
@@ -125,13 +218,13 @@ func Benchmark_Pipeline(b *testing.B) {
 	b.Run("label extractor bytes", func(b *testing.B) {
 		b.ResetTimer()
 		for n := 0; n < b.N; n++ {
-			resSample, resLbs, resOK = ex.Process(line)
+			resSample, resLbs, resOK = ex.Process(0, line)
 		}
 	})
 	b.Run("label extractor string", func(b *testing.B) {
 		b.ResetTimer()
 		for n := 0; n < b.N; n++ {
-			resSample, resLbs, resOK = ex.ProcessString(lineString)
+			resSample, resLbs, resOK = ex.ProcessString(0, lineString)
 		}
 	})
 }
@@ -164,7 +257,7 @@ func jsonBenchmark(b *testing.B, parser Stage) {
 	b.ResetTimer()
 	sp := p.ForStream(lbs)
 	for n := 0; n < b.N; n++ {
-		resLine, resLbs, resOK = sp.Process(line)
+		resLine, resLbs, resOK = sp.Process(0, line)
 
 		if !resOK {
 			b.Fatalf("resulting line not ok: %s\n", line)
@@ -187,7 +280,7 @@ func invalidJSONBenchmark(b *testing.B, parser Stage) {
 	b.ResetTimer()
 	sp := p.ForStream(labels.Labels{})
 	for n := 0; n < b.N; n++ {
-		resLine, resLbs, resOK = sp.Process(line)
+		resLine, resLbs, resOK = sp.Process(0, line)
 
 		if !resOK {
 			b.Fatalf("resulting line not ok: %s\n", line)
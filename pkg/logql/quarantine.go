@@ -0,0 +1,6 @@
+package logql
+
+// quarantinedChunksWarning is returned with the results of the queries which skipped chunks quarantined as
+// corrupt by the chunk scrubber of the compactor. It doesn't carry the number of chunks, found in the
+// statistics, so that the warnings of the subqueries of a query are merged into one.
+const quarantinedChunksWarning = "some chunks quarantined as corrupt were skipped, the results may be incomplete, see the totalChunksQuarantined statistics of the query"
@@ -9,7 +9,6 @@ import (
 
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/promql"
-	promql_parser "github.com/prometheus/prometheus/promql/parser"
 
 	"github.com/grafana/loki/pkg/iter"
 	"github.com/grafana/loki/pkg/logql/syntax"
@@ -17,30 +16,35 @@ import (
 )
 
 // RangeVectorAggregator aggregates samples for a given range of samples.
-// It receives the current milliseconds timestamp and the list of point within
-// the range.
-type RangeVectorAggregator func([]promql.Point) float64
+// It receives the end of the range and the list of point within the range,
+// both in nanoseconds.
+type RangeVectorAggregator func(ts int64, samples []promql.Point) float64
 
 // RangeVectorIterator iterates through a range of samples.
-// To fetch the current vector use `At` with a `RangeVectorAggregator`.
+// To fetch the current vector aggregated over the range use `At`.
 type RangeVectorIterator interface {
 	Next() bool
-	At(aggregator RangeVectorAggregator) (int64, promql.Vector)
+	At() (int64, promql.Vector)
 	Close() error
 	Error() error
 }
 
+// rangeVectorIterator aggregates all the samples of the range at each step.
 type rangeVectorIterator struct {
 	iter                                 iter.PeekingSampleIterator
+	agg                                  RangeVectorAggregator
 	selRange, step, end, current, offset int64
 	window                               map[string]*promql.Series
 	metrics                              map[string]labels.Labels
+	labelsCache                          *labelsCache
 	at                                   []promql.Sample
 }
 
 func newRangeVectorIterator(
 	it iter.PeekingSampleIterator,
-	selRange, step, start, end, offset int64) *rangeVectorIterator {
+	agg RangeVectorAggregator,
+	selRange, step, start, end, offset int64,
+	labelsCache *labelsCache) *rangeVectorIterator {
 	// forces at least one step.
 	if step == 0 {
 		step = 1
@@ -50,14 +54,16 @@ func newRangeVectorIterator(
 		end = end - offset
 	}
 	return &rangeVectorIterator{
-		iter:     it,
-		step:     step,
-		end:      end,
-		selRange: selRange,
-		current:  start - step, // first loop iteration will set it to start
-		offset:   offset,
-		window:   map[string]*promql.Series{},
-		metrics:  map[string]labels.Labels{},
+		iter:        it,
+		agg:         agg,
+		step:        step,
+		end:         end,
+		selRange:    selRange,
+		current:     start - step, // first loop iteration will set it to start
+		offset:      offset,
+		window:      map[string]*promql.Series{},
+		metrics:     map[string]labels.Labels{},
+		labelsCache: labelsCache,
 	}
 }
 
@@ -128,7 +134,7 @@ func (r *rangeVectorIterator) load(start, end int64) {
 			var metric labels.Labels
 			if metric, ok = r.metrics[lbs]; !ok {
 				var err error
-				metric, err = promql_parser.ParseMetric(lbs)
+				metric, err = r.labelsCache.Parse(lbs)
 				if err != nil {
 					_ = r.iter.Next()
 					continue
@@ -149,7 +155,7 @@ func (r *rangeVectorIterator) load(start, end int64) {
 	}
 }
 
-func (r *rangeVectorIterator) At(aggregator RangeVectorAggregator) (int64, promql.Vector) {
+func (r *rangeVectorIterator) At() (int64, promql.Vector) {
 	if r.at == nil {
 		r.at = make([]promql.Sample, 0, len(r.window))
 	}
@@ -159,7 +165,7 @@ func (r *rangeVectorIterator) At(aggregator RangeVectorAggregator) (int64, promq
 	for _, series := range r.window {
 		r.at = append(r.at, promql.Sample{
 			Point: promql.Point{
-				V: aggregator(series.Points),
+				V: r.agg(r.current, series.Points),
 				T: ts,
 			},
 			Metric: series.Metric,
@@ -186,6 +192,26 @@ func putSeries(s *promql.Series) {
 }
 
 func aggregator(r *syntax.RangeAggregationExpr) (RangeVectorAggregator, error) {
+	if syntax.IsEWMARangeOp(r.Operation) {
+		return ewmaRate(r.Left.Interval, ewmaHalfLife(r)), nil
+	}
+
+	agg, err := pointsAggregator(r)
+	if err != nil {
+		return nil, err
+	}
+	return withoutRangeEnd(agg), nil
+}
+
+// withoutRangeEnd adapts an aggregation of the points of the range to a RangeVectorAggregator.
+func withoutRangeEnd(agg func([]promql.Point) float64) RangeVectorAggregator {
+	return func(_ int64, samples []promql.Point) float64 {
+		return agg(samples)
+	}
+}
+
+// pointsAggregator returns the aggregation of the range operations not depending on the end of the range.
+func pointsAggregator(r *syntax.RangeAggregationExpr) (func([]promql.Point) float64, error) {
 	switch r.Operation {
 	case syntax.OpRangeTypeRate:
 		return rateLogs(r.Left.Interval, r.Left.Unwrap != nil), nil
@@ -218,6 +244,33 @@ func aggregator(r *syntax.RangeAggregationExpr) (RangeVectorAggregator, error) {
 	}
 }
 
+// ewmaHalfLife returns the half-life of the decay of the ewma rates, set in seconds by
+// their parameter and defaulting to a quarter of the range.
+func ewmaHalfLife(r *syntax.RangeAggregationExpr) time.Duration {
+	if r.Params != nil {
+		return time.Duration(*r.Params * float64(time.Second))
+	}
+	return r.Left.Interval / 4
+}
+
+// ewmaRate calculates the per-second rate of the samples values, smoothed by weighting each
+// sample with an exponential decay of its age: a sample halfLife old weighs half as much as
+// a sample at the end of the range. The weighted sum is divided by the weight of a sample per
+// second over the range, so a constant rate is unchanged.
+// The rate is linear in the samples: the rates of a set of series sum to the rate of their samples.
+func ewmaRate(selRange, halfLife time.Duration) RangeVectorAggregator {
+	decay := math.Ln2 / halfLife.Seconds()
+	weight := -math.Expm1(-decay*selRange.Seconds()) / decay
+	return func(ts int64, samples []promql.Point) float64 {
+		var sum float64
+		for _, p := range samples {
+			age := time.Duration(ts - p.T).Seconds()
+			sum += p.V * math.Exp(-decay*age)
+		}
+		return sum / weight
+	}
+}
+
 // rateLogs calculates the per-second rate of log lines.
 func rateLogs(selRange time.Duration, computeValues bool) func(samples []promql.Point) float64 {
 	return func(samples []promql.Point) float64 {
@@ -0,0 +1,119 @@
+package logql
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+
+	"github.com/grafana/loki/pkg/iter"
+	"github.com/grafana/loki/pkg/logql/syntax"
+)
+
+// histogramRangeVectorIterator returns the distribution of the samples of each series within the range.
+// Each non-empty bucket is returned as a sample counting the values of the bucket, labelled with
+// the upper bound of the bucket.
+type histogramRangeVectorIterator struct {
+	*rangeVectorIterator
+	schema int
+
+	// labels of the buckets of each series, by upper bound.
+	buckets map[string]map[float64]labels.Labels
+	counts  map[float64]float64
+}
+
+func newHistogramRangeVectorIterator(
+	it iter.PeekingSampleIterator,
+	schema int,
+	selRange, step, start, end, offset int64,
+	labelsCache *labelsCache) *histogramRangeVectorIterator {
+	return &histogramRangeVectorIterator{
+		rangeVectorIterator: newRangeVectorIterator(it, nil, selRange, step, start, end, offset, labelsCache),
+		schema:              schema,
+		buckets:             map[string]map[float64]labels.Labels{},
+		counts:              map[float64]float64{},
+	}
+}
+
+func (r *histogramRangeVectorIterator) At() (int64, promql.Vector) {
+	r.at = r.at[:0]
+	// convert ts from nano to milli seconds as the iterator work with nanoseconds
+	ts := r.current/1e+6 + r.offset/1e+6
+	for lbs, series := range r.window {
+		for _, p := range series.Points {
+			if math.IsNaN(p.V) {
+				continue
+			}
+			r.counts[histogramBucketUpperBound(p.V, r.schema)]++
+		}
+		for upperBound, count := range r.counts {
+			r.at = append(r.at, promql.Sample{
+				Point: promql.Point{
+					V: count,
+					T: ts,
+				},
+				Metric: r.bucketLabels(lbs, series.Metric, upperBound),
+			})
+			delete(r.counts, upperBound)
+		}
+	}
+	return ts, r.at
+}
+
+// bucketLabels returns the labels of the bucket of the series, which are the labels of the series with
+// the upper bound of the bucket as `le` label, like the buckets of Prometheus histograms.
+func (r *histogramRangeVectorIterator) bucketLabels(lbs string, metric labels.Labels, upperBound float64) labels.Labels {
+	buckets, ok := r.buckets[lbs]
+	if !ok {
+		buckets = map[float64]labels.Labels{}
+		r.buckets[lbs] = buckets
+	}
+	if bucket, ok := buckets[upperBound]; ok {
+		return bucket
+	}
+	bucket := labels.NewBuilder(metric).Set(labels.BucketLabel, strconv.FormatFloat(upperBound, 'g', -1, 64)).Labels()
+	buckets[upperBound] = bucket
+	return bucket
+}
+
+// histogramSchema returns the schema of the buckets of the histogram range aggregation,
+// set by its parameter and defaulting to 0, buckets bounded by the powers of 2.
+func histogramSchema(r *syntax.RangeAggregationExpr) int {
+	if r.Params != nil {
+		return int(*r.Params)
+	}
+	return 0
+}
+
+// histogramBucketUpperBound returns the upper bound of the bucket of v. The buckets are the exponential
+// buckets of Prometheus native histograms: with the schema n, the bucket i holds the values within
+// (base^(i-1), base^i] where base is 2^(2^-n). Negative values go to the mirrored buckets, and zero
+// and infinities have their own bucket.
+func histogramBucketUpperBound(v float64, schema int) float64 {
+	switch {
+	case v == 0 || math.IsInf(v, 0):
+		return v
+	case v < 0:
+		// -v is within (base^(i-1), base^i] so v is within [-base^i, -base^(i-1)).
+		return -histogramBucketBound(histogramBucketIndex(-v, schema)-1, schema)
+	default:
+		return histogramBucketBound(histogramBucketIndex(v, schema), schema)
+	}
+}
+
+// histogramBucketIndex returns the index of the bucket of the positive value v.
+func histogramBucketIndex(v float64, schema int) int {
+	i := int(math.Ceil(math.Log2(v) * math.Exp2(float64(schema))))
+	// corrects the rounding errors of the logarithm.
+	if histogramBucketBound(i-1, schema) >= v {
+		i--
+	} else if histogramBucketBound(i, schema) < v {
+		i++
+	}
+	return i
+}
+
+func histogramBucketBound(i, schema int) float64 {
+	return math.Exp2(float64(i) / math.Exp2(float64(schema)))
+}
@@ -0,0 +1,264 @@
+package logql
+
+import (
+	"math"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+
+	"github.com/grafana/loki/pkg/iter"
+	"github.com/grafana/loki/pkg/logql/syntax"
+)
+
+// partialAggregate aggregates the samples of a series within a single step, that is
+// within (end-step, end].
+type partialAggregate struct {
+	end                   int64
+	count, sum            float64
+	min, max, first, last float64
+}
+
+func (p *partialAggregate) add(v float64) {
+	if p.count == 0 {
+		p.min, p.max, p.first = v, v, v
+	}
+	p.count++
+	p.sum += v
+	p.last = v
+	if v < p.min || math.IsNaN(p.min) {
+		p.min = v
+	}
+	if v > p.max || math.IsNaN(p.max) {
+		p.max = v
+	}
+}
+
+// rangePartialsAggregator aggregates the partial aggregates of the steps of a range.
+type rangePartialsAggregator func([]partialAggregate) float64
+
+// partialsAggregator returns the rangePartialsAggregator of the range aggregation, or nil if
+// the aggregation can't be computed from partial aggregates.
+func partialsAggregator(r *syntax.RangeAggregationExpr) rangePartialsAggregator {
+	switch r.Operation {
+	case syntax.OpRangeTypeRate:
+		// the rate of unwrapped values is extrapolated from the samples.
+		if r.Left.Unwrap != nil {
+			return nil
+		}
+		selRange := r.Left.Interval.Seconds()
+		return func(partials []partialAggregate) float64 {
+			return countPartials(partials) / selRange
+		}
+	case syntax.OpRangeTypeCount:
+		return countPartials
+	case syntax.OpRangeTypeBytesRate:
+		selRange := r.Left.Interval.Seconds()
+		return func(partials []partialAggregate) float64 {
+			return sumPartials(partials) / selRange
+		}
+	case syntax.OpRangeTypeBytes, syntax.OpRangeTypeSum:
+		return sumPartials
+	case syntax.OpRangeTypeAvg:
+		return func(partials []partialAggregate) float64 {
+			return sumPartials(partials) / countPartials(partials)
+		}
+	case syntax.OpRangeTypeMax:
+		return maxPartials
+	case syntax.OpRangeTypeMin:
+		return minPartials
+	case syntax.OpRangeTypeFirst:
+		return func(partials []partialAggregate) float64 {
+			return partials[0].first
+		}
+	case syntax.OpRangeTypeLast:
+		return func(partials []partialAggregate) float64 {
+			return partials[len(partials)-1].last
+		}
+	default:
+		return nil
+	}
+}
+
+func countPartials(partials []partialAggregate) float64 {
+	var count float64
+	for _, p := range partials {
+		count += p.count
+	}
+	return count
+}
+
+func sumPartials(partials []partialAggregate) float64 {
+	var sum float64
+	for _, p := range partials {
+		sum += p.sum
+	}
+	return sum
+}
+
+func maxPartials(partials []partialAggregate) float64 {
+	max := partials[0].max
+	for _, p := range partials {
+		if p.max > max || math.IsNaN(max) {
+			max = p.max
+		}
+	}
+	return max
+}
+
+func minPartials(partials []partialAggregate) float64 {
+	min := partials[0].min
+	for _, p := range partials {
+		if p.min < min || math.IsNaN(min) {
+			min = p.min
+		}
+	}
+	return min
+}
+
+// useIncrementalRangeVector tells whether the steps of a range query can reuse the
+// partial aggregates of the previous steps: the ranges of the steps must overlap, and
+// the range must be a multiple of the step for the ranges to be made of whole steps.
+func useIncrementalRangeVector(selRange, step, start, end int64) bool {
+	return start < end && step > 0 && step < selRange && selRange%step == 0
+}
+
+type partialsSeries struct {
+	metric   labels.Labels
+	partials []partialAggregate
+}
+
+// incrementalRangeVectorIterator is a RangeVectorIterator for range queries whose steps overlap.
+// Each sample is aggregated once into the partial aggregate of its step, and the range of a step
+// is aggregated from the partial aggregates of the steps it covers instead of from all its samples.
+type incrementalRangeVectorIterator struct {
+	iter                                        iter.PeekingSampleIterator
+	agg                                         rangePartialsAggregator
+	selRange, step, start, end, current, offset int64
+	window                                      map[string]*partialsSeries
+	metrics                                     map[string]labels.Labels
+	labelsCache                                 *labelsCache
+	at                                          []promql.Sample
+}
+
+func newIncrementalRangeVectorIterator(
+	it iter.PeekingSampleIterator,
+	agg rangePartialsAggregator,
+	selRange, step, start, end, offset int64,
+	labelsCache *labelsCache) *incrementalRangeVectorIterator {
+	if offset != 0 {
+		start = start - offset
+		end = end - offset
+	}
+	return &incrementalRangeVectorIterator{
+		iter:        it,
+		agg:         agg,
+		selRange:    selRange,
+		step:        step,
+		start:       start,
+		end:         end,
+		current:     start - step, // first loop iteration will set it to start
+		offset:      offset,
+		window:      map[string]*partialsSeries{},
+		metrics:     map[string]labels.Labels{},
+		labelsCache: labelsCache,
+	}
+}
+
+func (r *incrementalRangeVectorIterator) Next() bool {
+	r.current = r.current + r.step
+	if r.current > r.end {
+		return false
+	}
+	rangeStart := r.current - r.selRange
+	r.popBack(rangeStart)
+	r.load(rangeStart, r.current)
+	return true
+}
+
+func (r *incrementalRangeVectorIterator) Close() error {
+	return r.iter.Close()
+}
+
+func (r *incrementalRangeVectorIterator) Error() error {
+	return r.iter.Error()
+}
+
+// popBack removes the partial aggregates of the steps out of the current range.
+func (r *incrementalRangeVectorIterator) popBack(newStart int64) {
+	for lbs, series := range r.window {
+		i := 0
+		for i < len(series.partials) && series.partials[i].end <= newStart {
+			i++
+		}
+		series.partials = series.partials[i:]
+		if len(series.partials) == 0 {
+			delete(r.window, lbs)
+		}
+	}
+}
+
+// stepEnd returns the end of the step the timestamp belongs to.
+func (r *incrementalRangeVectorIterator) stepEnd(ts int64) int64 {
+	d := ts - r.start
+	n := d / r.step
+	if d%r.step > 0 {
+		n++
+	}
+	return r.start + n*r.step
+}
+
+// load adds the samples up to the end of the current step to the partial aggregates.
+func (r *incrementalRangeVectorIterator) load(start, end int64) {
+	for lbs, sample, hasNext := r.iter.Peek(); hasNext; lbs, sample, hasNext = r.iter.Peek() {
+		if sample.Timestamp > end {
+			// not consuming the iterator as this belong to another step.
+			return
+		}
+		// the lower bound of the range is not inclusive
+		if sample.Timestamp <= start {
+			_ = r.iter.Next()
+			continue
+		}
+		series, ok := r.window[lbs]
+		if !ok {
+			var metric labels.Labels
+			if metric, ok = r.metrics[lbs]; !ok {
+				var err error
+				metric, err = r.labelsCache.Parse(lbs)
+				if err != nil {
+					_ = r.iter.Next()
+					continue
+				}
+				r.metrics[lbs] = metric
+			}
+			series = &partialsSeries{metric: metric}
+			r.window[lbs] = series
+		}
+
+		stepEnd := r.stepEnd(sample.Timestamp)
+		if n := len(series.partials); n == 0 || series.partials[n-1].end != stepEnd {
+			series.partials = append(series.partials, partialAggregate{end: stepEnd})
+		}
+		series.partials[len(series.partials)-1].add(sample.Value)
+		_ = r.iter.Next()
+	}
+}
+
+func (r *incrementalRangeVectorIterator) At() (int64, promql.Vector) {
+	if r.at == nil {
+		r.at = make([]promql.Sample, 0, len(r.window))
+	}
+	r.at = r.at[:0]
+	// convert ts from nano to milli seconds as the iterator work with nanoseconds
+	ts := r.current/1e+6 + r.offset/1e+6
+	for _, series := range r.window {
+		r.at = append(r.at, promql.Sample{
+			Point: promql.Point{
+				V: r.agg(series.partials),
+				T: ts,
+			},
+			Metric: series.metric,
+		})
+	}
+	return ts, r.at
+}
@@ -3,9 +3,12 @@ package logql
 import (
 	"context"
 	"fmt"
+	"math"
+	"sort"
 	"testing"
 	"time"
 
+	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/promql"
 	"github.com/stretchr/testify/require"
 
@@ -179,19 +182,95 @@ func Test_RangeVectorIterator(t *testing.T) {
 		t.Run(
 			fmt.Sprintf("logs[%s] - step: %s - offset: %s", time.Duration(tt.selRange), time.Duration(tt.step), time.Duration(tt.offset)),
 			func(t *testing.T) {
-				it := newRangeVectorIterator(newfakePeekingSampleIterator(), tt.selRange,
-					tt.step, tt.start.UnixNano(), tt.end.UnixNano(), tt.offset)
+				it := newRangeVectorIterator(newfakePeekingSampleIterator(), withoutRangeEnd(countOverTime), tt.selRange,
+					tt.step, tt.start.UnixNano(), tt.end.UnixNano(), tt.offset, newLabelsCache(defaultMaxLabelsCacheSize))
 
 				i := 0
 				for it.Next() {
-					ts, v := it.At(countOverTime)
+					ts, v := it.At()
 					require.ElementsMatch(t, tt.expectedVectors[i], v)
 					require.Equal(t, tt.expectedTs[i].UnixNano()/1e+6, ts)
 					i++
 				}
 				require.Equal(t, len(tt.expectedTs), i)
 				require.Equal(t, len(tt.expectedVectors), i)
+
+				if tt.selRange%tt.step != 0 {
+					return
+				}
+				incremental := newIncrementalRangeVectorIterator(newfakePeekingSampleIterator(), countPartials, tt.selRange,
+					tt.step, tt.start.UnixNano(), tt.end.UnixNano(), tt.offset, newLabelsCache(defaultMaxLabelsCacheSize))
+				i = 0
+				for incremental.Next() {
+					ts, v := incremental.At()
+					require.ElementsMatch(t, tt.expectedVectors[i], v)
+					require.Equal(t, tt.expectedTs[i].UnixNano()/1e+6, ts)
+					i++
+				}
+				require.Equal(t, len(tt.expectedTs), i)
+			})
+	}
+}
+
+func Test_IncrementalRangeVectorIterator(t *testing.T) {
+	var values []logproto.Sample
+	for i := int64(0); i < 500; i++ {
+		values = append(values, logproto.Sample{
+			Timestamp: time.Unix(i*7/3, i%1000*1e6).UnixNano(),
+			Hash:      uint64(i),
+			Value:     float64(i%11 - 5),
+		})
+	}
+	newIterator := func() iter.PeekingSampleIterator {
+		return iter.NewPeekingSampleIterator(iter.NewSortSampleIterator([]iter.SampleIterator{
+			iter.NewSeriesIterator(logproto.Series{Labels: labelFoo.String(), Samples: values, StreamHash: labelFoo.Hash()}),
+			iter.NewSeriesIterator(logproto.Series{Labels: labelBar.String(), Samples: values[100:300], StreamHash: labelBar.Hash()}),
+		}))
+	}
+
+	for _, query := range []string{
+		`count_over_time({app="foo"}[1m])`,
+		`rate({app="foo"}[1m])`,
+		`bytes_over_time({app="foo"}[1m])`,
+		`bytes_rate({app="foo"}[1m])`,
+		`sum_over_time({app="foo"} | unwrap foo [1m])`,
+		`avg_over_time({app="foo"} | unwrap foo [1m])`,
+		`max_over_time({app="foo"} | unwrap foo [1m])`,
+		`min_over_time({app="foo"} | unwrap foo [1m])`,
+		`first_over_time({app="foo"} | unwrap foo [1m])`,
+		`last_over_time({app="foo"} | unwrap foo [1m])`,
+	} {
+		for _, step := range []time.Duration{time.Second, 15 * time.Second, 20 * time.Second} {
+			t.Run(fmt.Sprintf("%s - step: %s", query, step), func(t *testing.T) {
+				expr, err := syntax.ParseSampleExpr(query)
+				require.NoError(t, err)
+				rangeExpr := expr.(*syntax.RangeAggregationExpr)
+				selRange := rangeExpr.Left.Interval.Nanoseconds()
+				start, end, offset := time.Unix(30, 0).UnixNano(), time.Unix(1200, 0).UnixNano(), (10 * time.Second).Nanoseconds()
+				require.True(t, useIncrementalRangeVector(selRange, step.Nanoseconds(), start, end))
+
+				agg, err := aggregator(rangeExpr)
+				require.NoError(t, err)
+				batch := newRangeVectorIterator(newIterator(), agg, selRange, step.Nanoseconds(), start, end, offset, newLabelsCache(defaultMaxLabelsCacheSize))
+				incremental := newIncrementalRangeVectorIterator(newIterator(), partialsAggregator(rangeExpr), selRange, step.Nanoseconds(), start, end, offset, newLabelsCache(defaultMaxLabelsCacheSize))
+
+				for batch.Next() {
+					require.True(t, incremental.Next())
+					expectedTs, expected := batch.At()
+					ts, actual := incremental.At()
+					require.Equal(t, expectedTs, ts)
+					require.Len(t, actual, len(expected))
+
+					sort.Slice(expected, func(i, j int) bool { return expected[i].Metric.String() < expected[j].Metric.String() })
+					sort.Slice(actual, func(i, j int) bool { return actual[i].Metric.String() < actual[j].Metric.String() })
+					for i := range expected {
+						require.Equal(t, expected[i].Metric, actual[i].Metric)
+						require.InDelta(t, expected[i].V, actual[i].V, 1e-9)
+					}
+				}
+				require.False(t, incremental.Next())
 			})
+		}
 	}
 }
 
@@ -201,8 +280,8 @@ func Test_RangeVectorIteratorBadLabels(t *testing.T) {
 			Labels:  "{badlabels=}",
 			Samples: samples,
 		}))
-	it := newRangeVectorIterator(badIterator, (30 * time.Second).Nanoseconds(),
-		(30 * time.Second).Nanoseconds(), time.Unix(10, 0).UnixNano(), time.Unix(100, 0).UnixNano(), 0)
+	it := newRangeVectorIterator(badIterator, withoutRangeEnd(countOverTime), (30 * time.Second).Nanoseconds(),
+		(30 * time.Second).Nanoseconds(), time.Unix(10, 0).UnixNano(), time.Unix(100, 0).UnixNano(), 0, nil)
 	ctx, cancel := context.WithCancel(context.Background())
 	go func() {
 		defer cancel()
@@ -215,3 +294,80 @@ func Test_RangeVectorIteratorBadLabels(t *testing.T) {
 	case <-ctx.Done():
 	}
 }
+
+func Test_EWMARate(t *testing.T) {
+	selRange, halfLife := 5*time.Minute, time.Minute
+	agg := ewmaRate(selRange, halfLife)
+	end := time.Unix(1000, 0).UnixNano()
+
+	// one sample per second over the range.
+	var constant []promql.Point
+	for ts := end - selRange.Nanoseconds() + time.Second.Nanoseconds(); ts <= end; ts += time.Second.Nanoseconds() {
+		constant = append(constant, promql.Point{T: ts, V: 1})
+	}
+	require.InDelta(t, 1, agg(end, constant), 0.01)
+
+	// a spike decays by half every half-life.
+	spike := []promql.Point{{T: end, V: 100}}
+	require.InDelta(t, agg(end, spike)/2, agg(end+halfLife.Nanoseconds(), spike), 1e-9)
+	require.InDelta(t, agg(end, spike)/4, agg(end+2*halfLife.Nanoseconds(), spike), 1e-9)
+
+	// the rate of merged samples is the sum of the rates, which makes it shardable.
+	merged := append(append([]promql.Point{}, constant...), spike...)
+	sort.Slice(merged, func(i, j int) bool { return merged[i].T < merged[j].T })
+	require.InDelta(t, agg(end, constant)+agg(end, spike), agg(end, merged), 1e-9)
+}
+
+func Test_HistogramBucketUpperBound(t *testing.T) {
+	for _, tc := range []struct {
+		v      float64
+		schema int
+		exp    float64
+	}{
+		{0, 0, 0},
+		{1, 0, 1},
+		{3, 0, 4},
+		{4, 0, 4},
+		{4.1, 0, 8},
+		{0.3, 0, 0.5},
+		{-3, 0, -2},
+		{-4, 0, -2},
+		{1.5, 1, 2},
+		{1.4, 1, math.Sqrt2},
+		{100, -1, 256},
+		{1000, 3, math.Exp2(10)},
+		{math.Inf(1), 0, math.Inf(1)},
+	} {
+		t.Run(fmt.Sprintf("%v schema %d", tc.v, tc.schema), func(t *testing.T) {
+			require.InDelta(t, tc.exp, histogramBucketUpperBound(tc.v, tc.schema), 1e-9)
+		})
+	}
+}
+
+func Test_HistogramRangeVectorIterator(t *testing.T) {
+	values := []logproto.Sample{
+		{Timestamp: time.Unix(1, 0).UnixNano(), Hash: 1, Value: 1},
+		{Timestamp: time.Unix(2, 0).UnixNano(), Hash: 2, Value: 3},
+		{Timestamp: time.Unix(3, 0).UnixNano(), Hash: 3, Value: 4},
+		{Timestamp: time.Unix(4, 0).UnixNano(), Hash: 4, Value: 0.3},
+		{Timestamp: time.Unix(5, 0).UnixNano(), Hash: 5, Value: 1},
+		{Timestamp: time.Unix(6, 0).UnixNano(), Hash: 6, Value: 1},
+	}
+	it := newHistogramRangeVectorIterator(
+		iter.NewPeekingSampleIterator(iter.NewSeriesIterator(logproto.Series{Labels: labelFoo.String(), Samples: values, StreamHash: labelFoo.Hash()})),
+		0, (5 * time.Second).Nanoseconds(), (5 * time.Second).Nanoseconds(), time.Unix(5, 0).UnixNano(), time.Unix(10, 0).UnixNano(), 0, newLabelsCache(defaultMaxLabelsCacheSize))
+
+	bucket := func(le string, v float64, ts int64) promql.Sample {
+		return promql.Sample{Point: promql.Point{T: ts, V: v}, Metric: labels.Labels{{Name: "app", Value: "foo"}, {Name: labels.BucketLabel, Value: le}}}
+	}
+	for _, expected := range []promql.Vector{
+		{bucket("0.5", 1, 5000), bucket("1", 2, 5000), bucket("4", 2, 5000)},
+		{bucket("1", 1, 10000)},
+	} {
+		require.True(t, it.Next())
+		_, actual := it.At()
+		sort.Slice(actual, func(i, j int) bool { return actual[i].Metric.String() < actual[j].Metric.String() })
+		require.Equal(t, expected, actual)
+	}
+	require.False(t, it.Next())
+}
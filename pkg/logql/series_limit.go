@@ -0,0 +1,92 @@
+package logql
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+)
+
+// SeriesLimitWarning is the warning returned with results truncated to the maximum number of series.
+func SeriesLimitWarning(maxSeries, total int) string {
+	return fmt.Sprintf("maximum of series (%d) reached for a single query, returning the %d series with the most samples out of %d", maxSeries, maxSeries, total)
+}
+
+// SeriesRank holds what series are ranked by when truncating a result to the
+// maximum number of series.
+type SeriesRank struct {
+	Labels  labels.Labels
+	Samples int
+	Sum     float64
+}
+
+// TopSeries returns which of the series must be kept to truncate them to the n
+// series with the most samples. Series with the same number of samples are
+// ranked by the sum of their values, then by their labels.
+func TopSeries(series []SeriesRank, n int) []bool {
+	kept := make([]bool, len(series))
+	if len(series) <= n {
+		for i := range kept {
+			kept[i] = true
+		}
+		return kept
+	}
+	ranks := make([]int, len(series))
+	for i := range ranks {
+		ranks[i] = i
+	}
+	sort.Slice(ranks, func(i, j int) bool {
+		a, b := &series[ranks[i]], &series[ranks[j]]
+		if a.Samples != b.Samples {
+			return a.Samples > b.Samples
+		}
+		if a.Sum != b.Sum {
+			return a.Sum > b.Sum
+		}
+		return labels.Compare(a.Labels, b.Labels) < 0
+	})
+	for _, i := range ranks[:n] {
+		kept[i] = true
+	}
+	return kept
+}
+
+// truncateMatrix keeps the n series of the matrix with the most samples.
+func truncateMatrix(m promql.Matrix, n int) promql.Matrix {
+	if len(m) <= n {
+		return m
+	}
+	ranks := make([]SeriesRank, len(m))
+	for i, s := range m {
+		ranks[i] = SeriesRank{Labels: s.Metric, Samples: len(s.Points)}
+		for _, p := range s.Points {
+			ranks[i].Sum += p.V
+		}
+	}
+	truncated := make(promql.Matrix, 0, n)
+	for i, keep := range TopSeries(ranks, n) {
+		if keep {
+			truncated = append(truncated, m[i])
+		}
+	}
+	return truncated
+}
+
+// truncateVector keeps the n samples of the vector with the highest values.
+func truncateVector(v promql.Vector, n int) promql.Vector {
+	if len(v) <= n {
+		return v
+	}
+	ranks := make([]SeriesRank, len(v))
+	for i, s := range v {
+		ranks[i] = SeriesRank{Labels: s.Metric, Samples: 1, Sum: s.V}
+	}
+	truncated := make(promql.Vector, 0, n)
+	for i, keep := range TopSeries(ranks, n) {
+		if keep {
+			truncated = append(truncated, v[i])
+		}
+	}
+	return truncated
+}
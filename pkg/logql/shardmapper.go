@@ -304,10 +304,17 @@ func (m ShardMapper) mapRangeAggregationExpr(expr *syntax.RangeAggregationExpr,
 		return expr
 	}
 	switch expr.Operation {
-	case syntax.OpRangeTypeCount, syntax.OpRangeTypeRate, syntax.OpRangeTypeBytesRate, syntax.OpRangeTypeBytes:
+	case syntax.OpRangeTypeCount, syntax.OpRangeTypeRate, syntax.OpRangeTypeBytesRate, syntax.OpRangeTypeBytes,
+		syntax.OpRangeTypeEWMARate, syntax.OpRangeTypeEWMABytesRate:
 		// count_over_time(x) -> count_over_time(x, shard=1) ++ count_over_time(x, shard=2)...
 		// rate(x) -> rate(x, shard=1) ++ rate(x, shard=2)...
-		// same goes for bytes_rate and bytes_over_time
+		// same goes for bytes_rate, bytes_over_time and the ewma rates
+		return m.mapSampleExpr(expr, r)
+	case syntax.OpRangeTypeHistogram, syntax.OpRangeTypeBytesHistogram:
+		// the buckets of a series come from a single shard, unless the samples are grouped.
+		if expr.Grouping != nil {
+			return expr
+		}
 		return m.mapSampleExpr(expr, r)
 	default:
 		return expr
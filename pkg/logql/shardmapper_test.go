@@ -135,6 +135,32 @@ func TestMappingStrings(t *testing.T) {
 				++ downstream<sum(rate({foo="bar"}[1m])), shard=1_of_2>
 			)`,
 		},
+		{
+			in: `sum by (cluster) (ewma_rate(30,{foo="bar"}[5m]))`,
+			out: `sum by (cluster) (
+				downstream<sum by (cluster) (ewma_rate(30,{foo="bar"}[5m])), shard=0_of_2>
+				++ downstream<sum by (cluster) (ewma_rate(30,{foo="bar"}[5m])), shard=1_of_2>
+			)`,
+		},
+		{
+			in: `ewma_bytes_rate({foo="bar"}[5m])`,
+			out: `downstream<ewma_bytes_rate({foo="bar"}[5m]), shard=0_of_2>
+				++ downstream<ewma_bytes_rate({foo="bar"}[5m]), shard=1_of_2>`,
+		},
+		{
+			in: `sum by (le) (bytes_histogram_over_time({foo="bar"}[5m]))`,
+			out: `sum by (le) (
+				downstream<sum by (le) (bytes_histogram_over_time({foo="bar"}[5m])), shard=0_of_2>
+				++ downstream<sum by (le) (bytes_histogram_over_time({foo="bar"}[5m])), shard=1_of_2>
+			)`,
+		},
+		{
+			in: `sum by (label_count()) (rate({foo="bar"}[5m]))`,
+			out: `sum by (label_count()) (
+				downstream<rate({foo="bar"}[5m]), shard=0_of_2>
+				++ downstream<rate({foo="bar"}[5m]), shard=1_of_2>
+			)`,
+		},
 		{
 			in: `max(count(rate({foo="bar"}[5m]))) / 2`,
 			out: `(max(
@@ -0,0 +1,25 @@
+package logql
+
+import (
+	"github.com/grafana/loki/pkg/logqlmodel/stats"
+)
+
+// Warnings returned with the results of the queries asking for strict parsing when some of their lines
+// failed to be parsed. They don't carry the number of failures, found in the statistics, so that the
+// warnings of the subqueries of a query are merged into one.
+const (
+	jsonParserErrorsWarning   = "some lines failed to be parsed by the json parser and were labelled with __error__=\"JSONParserErr\", see the parsing statistics of the query"
+	logfmtParserErrorsWarning = "some lines failed to be parsed by the logfmt parser and were labelled with __error__=\"LogfmtParserErr\", see the parsing statistics of the query"
+)
+
+// parserErrorsWarnings returns the warnings about the lines which failed to be parsed.
+func parserErrorsWarnings(r stats.Result) []string {
+	var warnings []string
+	if r.TotalJSONParserErrors() > 0 {
+		warnings = append(warnings, jsonParserErrorsWarning)
+	}
+	if r.TotalLogfmtParserErrors() > 0 {
+		warnings = append(warnings, logfmtParserErrorsWarning)
+	}
+	return warnings
+}
@@ -325,6 +325,34 @@ func (e *LineFilterExpr) Stage() (log.Stage, error) {
 	return f.ToStage(), nil
 }
 
+// LineFilterNeedles returns the strings the lines selected by the log selector contain as stored: the strings of its
+// `|=` line filters preceding the first stage changing the lines, like line_format or unpack.
+func LineFilterNeedles(expr LogSelectorExpr) []string {
+	p, ok := expr.(*PipelineExpr)
+	if !ok {
+		return nil
+	}
+	var needles []string
+	for _, stage := range p.MultiStages {
+		switch s := stage.(type) {
+		case *LineFilterExpr:
+			for f := s; f != nil; f = f.Left {
+				if f.Ty == labels.MatchEqual && f.Op == "" && f.Match != "" {
+					needles = append(needles, f.Match)
+				}
+			}
+		case *LabelFilterExpr, *LabelFmtExpr:
+		case *LabelParserExpr:
+			if s.Op == OpParserTypeUnpack {
+				return needles
+			}
+		default:
+			return needles
+		}
+	}
+	return needles
+}
+
 type LabelParserExpr struct {
 	Op    string
 	Param string
@@ -631,6 +659,14 @@ const (
 	OpRangeTypeLast      = "last_over_time"
 	OpRangeTypeAbsent    = "absent_over_time"
 
+	// range vector ops smoothing the rate of the range with an exponential decay of the samples.
+	OpRangeTypeEWMARate      = "ewma_rate"
+	OpRangeTypeEWMABytesRate = "ewma_bytes_rate"
+
+	// range vector ops returning the distribution of the samples of the range in histogram buckets.
+	OpRangeTypeHistogram      = "histogram_over_time"
+	OpRangeTypeBytesHistogram = "bytes_histogram_over_time"
+
 	// binops - logical/set
 	OpTypeOr     = "or"
 	OpTypeAnd    = "and"
@@ -669,6 +705,10 @@ const (
 	OpOn       = "on"
 	OpIgnoring = "ignoring"
 
+	// grouping functions
+	OpGroupingLabelCount = "label_count"
+	OpGroupingLabelsHash = "labels_hash"
+
 	OpGroupLeft  = "group_left"
 	OpGroupRight = "group_right"
 
@@ -683,6 +723,24 @@ const (
 	OpFilterIP = "ip"
 )
 
+// IsEWMARangeOp tells whether the range vector operation is an ewma rate, taking a half-life as optional parameter.
+func IsEWMARangeOp(op string) bool {
+	return op == OpRangeTypeEWMARate || op == OpRangeTypeEWMABytesRate
+}
+
+// Range of the schemas of the histogram buckets. As for Prometheus native histograms, the bounds
+// of the buckets of schema n are the powers of 2^(2^-n). Negative schemas are not supported as the
+// parameter of range aggregations can't be negative.
+const (
+	MinHistogramSchema = 0
+	MaxHistogramSchema = 8
+)
+
+// IsHistogramRangeOp tells whether the range vector operation returns histogram buckets, taking a schema as optional parameter.
+func IsHistogramRangeOp(op string) bool {
+	return op == OpRangeTypeHistogram || op == OpRangeTypeBytesHistogram
+}
+
 func IsComparisonOperator(op string) bool {
 	switch op {
 	case OpTypeCmpEQ, OpTypeNEQ, OpTypeGT, OpTypeGTE, OpTypeLT, OpTypeLTE:
@@ -722,7 +780,7 @@ type RangeAggregationExpr struct {
 func newRangeAggregationExpr(left *LogRange, operation string, gr *Grouping, stringParams *string) SampleExpr {
 	var params *float64
 	if stringParams != nil {
-		if operation != OpRangeTypeQuantile {
+		if operation != OpRangeTypeQuantile && !IsEWMARangeOp(operation) && !IsHistogramRangeOp(operation) {
 			panic(logqlmodel.NewParseError(fmt.Sprintf("parameter %s not supported for operation %s", *stringParams, operation), 0, 0))
 		}
 		var err error
@@ -754,23 +812,34 @@ func (e *RangeAggregationExpr) Selector() LogSelectorExpr {
 }
 
 func (e RangeAggregationExpr) validate() error {
+	if IsEWMARangeOp(e.Operation) && e.Params != nil && *e.Params <= 0 {
+		return fmt.Errorf("invalid half-life for %s: %v, it must be a positive number of seconds", e.Operation, *e.Params)
+	}
+	if IsHistogramRangeOp(e.Operation) && e.Params != nil {
+		if schema := *e.Params; schema != math.Trunc(schema) || schema < MinHistogramSchema || schema > MaxHistogramSchema {
+			return fmt.Errorf("invalid schema for %s: %v, it must be an integer between %d and %d", e.Operation, schema, MinHistogramSchema, MaxHistogramSchema)
+		}
+	}
 	if e.Grouping != nil {
+		if len(e.Grouping.Functions) > 0 {
+			return fmt.Errorf("grouping function %s not supported by range aggregations", e.Grouping.Functions[0])
+		}
 		switch e.Operation {
-		case OpRangeTypeAvg, OpRangeTypeStddev, OpRangeTypeStdvar, OpRangeTypeQuantile, OpRangeTypeMax, OpRangeTypeMin, OpRangeTypeFirst, OpRangeTypeLast:
+		case OpRangeTypeAvg, OpRangeTypeStddev, OpRangeTypeStdvar, OpRangeTypeQuantile, OpRangeTypeMax, OpRangeTypeMin, OpRangeTypeFirst, OpRangeTypeLast, OpRangeTypeHistogram:
 		default:
 			return fmt.Errorf("grouping not allowed for %s aggregation", e.Operation)
 		}
 	}
 	if e.Left.Unwrap != nil {
 		switch e.Operation {
-		case OpRangeTypeAvg, OpRangeTypeSum, OpRangeTypeMax, OpRangeTypeMin, OpRangeTypeStddev, OpRangeTypeStdvar, OpRangeTypeQuantile, OpRangeTypeRate, OpRangeTypeAbsent, OpRangeTypeFirst, OpRangeTypeLast:
+		case OpRangeTypeAvg, OpRangeTypeSum, OpRangeTypeMax, OpRangeTypeMin, OpRangeTypeStddev, OpRangeTypeStdvar, OpRangeTypeQuantile, OpRangeTypeRate, OpRangeTypeAbsent, OpRangeTypeFirst, OpRangeTypeLast, OpRangeTypeHistogram:
 			return nil
 		default:
 			return fmt.Errorf("invalid aggregation %s with unwrap", e.Operation)
 		}
 	}
 	switch e.Operation {
-	case OpRangeTypeBytes, OpRangeTypeBytesRate, OpRangeTypeCount, OpRangeTypeRate, OpRangeTypeAbsent:
+	case OpRangeTypeBytes, OpRangeTypeBytesRate, OpRangeTypeCount, OpRangeTypeRate, OpRangeTypeAbsent, OpRangeTypeEWMARate, OpRangeTypeEWMABytesRate, OpRangeTypeBytesHistogram:
 		return nil
 	default:
 		return fmt.Errorf("invalid aggregation %s without unwrap", e.Operation)
@@ -810,6 +879,9 @@ func (e *RangeAggregationExpr) Walk(f WalkFn) {
 type Grouping struct {
 	Groups  []string
 	Without bool
+	// Functions derive the values of additional grouping labels from the label set of the series,
+	// they are only supported when vector aggregations group by labels.
+	Functions []GroupingFunction
 }
 
 // impls Stringer
@@ -817,19 +889,93 @@ func (g Grouping) String() string {
 	var sb strings.Builder
 	if g.Without {
 		sb.WriteString(" without")
-	} else if len(g.Groups) > 0 {
+	} else if len(g.Groups) > 0 || len(g.Functions) > 0 {
 		sb.WriteString(" by")
 	}
 
-	if len(g.Groups) > 0 {
+	if len(g.Groups) > 0 || len(g.Functions) > 0 {
+		groups := make([]string, 0, len(g.Groups)+len(g.Functions))
+		groups = append(groups, g.Groups...)
+		for _, f := range g.Functions {
+			groups = append(groups, f.String())
+		}
 		sb.WriteString("(")
-		sb.WriteString(strings.Join(g.Groups, ","))
+		sb.WriteString(strings.Join(groups, ","))
 		sb.WriteString(")")
 	}
 
 	return sb.String()
 }
 
+// Labels returns the names of the labels the series are grouped by, including the labels of the grouping functions.
+func (g Grouping) Labels() []string {
+	if len(g.Functions) == 0 {
+		return g.Groups
+	}
+	groups := make([]string, 0, len(g.Groups)+len(g.Functions))
+	groups = append(groups, g.Groups...)
+	for _, f := range g.Functions {
+		groups = append(groups, f.Name)
+	}
+	return groups
+}
+
+func (g *Grouping) hasFunctions() bool {
+	return g != nil && len(g.Functions) > 0
+}
+
+func mustNewGrouping(without bool, gr *Grouping) *Grouping {
+	if without && len(gr.Functions) > 0 {
+		panic(logqlmodel.NewParseError(fmt.Sprintf("grouping function %s not supported with without", gr.Functions[0]), 0, 0))
+	}
+	gr.Without = without
+	return gr
+}
+
+// GroupingFunction derives the value of a grouping label from the label set of the series.
+// The label is named after the function.
+type GroupingFunction struct {
+	Name string
+	// Buckets is the number of buckets the label sets are hashed into by labels_hash.
+	Buckets uint64
+}
+
+func mustNewGroupingFunction(name string, buckets *string) GroupingFunction {
+	f := GroupingFunction{Name: name}
+	if buckets != nil {
+		var err error
+		if f.Buckets, err = strconv.ParseUint(*buckets, 10, 64); err != nil || f.Buckets == 0 {
+			panic(logqlmodel.NewParseError(fmt.Sprintf("invalid number of buckets for %s: %s, it must be a positive integer", name, *buckets), 0, 0))
+		}
+	}
+	return f
+}
+
+// impls Stringer
+func (f GroupingFunction) String() string {
+	if f.Name == OpGroupingLabelsHash {
+		return fmt.Sprintf("%s(%d)", f.Name, f.Buckets)
+	}
+	return f.Name + "()"
+}
+
+// Value returns the value of the grouping label of the series, the metric name isn't taken into account.
+func (f GroupingFunction) Value(lbs labels.Labels) string {
+	switch f.Name {
+	case OpGroupingLabelCount:
+		n := len(lbs)
+		if lbs.Has(labels.MetricName) {
+			n--
+		}
+		return strconv.Itoa(n)
+	case OpGroupingLabelsHash:
+		h, _ := lbs.HashWithoutLabels(nil)
+		return strconv.FormatUint(h%f.Buckets, 10)
+	default:
+		return ""
+	}
+}
+
 type VectorAggregationExpr struct {
 	Left SampleExpr
 
@@ -875,8 +1021,9 @@ func (e *VectorAggregationExpr) Extractor() (log.SampleExtractor, error) {
 	// inject in the range vector extractor the outer groups to improve performance.
 	// This is only possible if the operation is a sum. Anything else needs all labels.
 	if r, ok := e.Left.(*RangeAggregationExpr); ok && canInjectVectorGrouping(e.Operation, r.Operation) {
-		// if the range vec operation has no grouping we can push down the vec one.
-		if r.Grouping == nil {
+		// if the range vec operation has no grouping we can push down the vec one,
+		// unless grouping functions need all the labels of the series.
+		if r.Grouping == nil && !e.Grouping.hasFunctions() {
 			return r.extractor(e.Grouping)
 		}
 	}
@@ -889,7 +1036,8 @@ func canInjectVectorGrouping(vecOp, rangeOp string) bool {
 		return false
 	}
 	switch rangeOp {
-	case OpRangeTypeBytes, OpRangeTypeBytesRate, OpRangeTypeSum, OpRangeTypeRate, OpRangeTypeCount:
+	case OpRangeTypeBytes, OpRangeTypeBytesRate, OpRangeTypeSum, OpRangeTypeRate, OpRangeTypeCount, OpRangeTypeEWMARate, OpRangeTypeEWMABytesRate,
+		OpRangeTypeHistogram, OpRangeTypeBytesHistogram:
 		return true
 	default:
 		return false
@@ -908,6 +1056,10 @@ func (e *VectorAggregationExpr) String() string {
 
 // impl SampleExpr
 func (e *VectorAggregationExpr) Shardable() bool {
+	if e.Grouping.hasFunctions() {
+		// the labels derived by the grouping functions can't be derived again from the results of the shards.
+		return false
+	}
 	if e.Operation == OpTypeCount || e.Operation == OpTypeAvg {
 		// count is shardable is labels are not mutated
 		// otherwise distinct values can be counted twice per shard
@@ -1482,6 +1634,12 @@ var shardableOps = map[string]bool{
 	OpRangeTypeSum:       true,
 	OpRangeTypeMax:       true,
 	OpRangeTypeMin:       true,
+	// the ewma rates are linear in the samples, the rates of the shards can be summed.
+	OpRangeTypeEWMARate:      true,
+	OpRangeTypeEWMABytesRate: true,
+	// the buckets of histograms are counts, the buckets of the shards can be summed.
+	OpRangeTypeHistogram:      true,
+	OpRangeTypeBytesHistogram: true,
 
 	// binops - arith
 	OpTypeAdd: true,
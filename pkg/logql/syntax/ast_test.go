@@ -72,6 +72,8 @@ func Test_SampleExpr_String(t *testing.T) {
 		`absent_over_time( ( {job="mysql"} |="error" !="timeout" ) [10s] offset 10d )`,
 		`sum without(a) ( rate ( ( {job="mysql"} |="error" !="timeout" ) [10s] ) )`,
 		`sum by(a) (rate( ( {job="mysql"} |="error" !="timeout" ) [10s] ) )`,
+		`sum by(a, label_count()) (rate( ( {job="mysql"} |="error" !="timeout" ) [10s] ) )`,
+		`count by(labels_hash(16)) (count_over_time({job="mysql"}[5m]))`,
 		`sum(count_over_time({job="mysql"}[5m]))`,
 		`sum(count_over_time({job="mysql"}[5m] offset 10m))`,
 		`sum(count_over_time({job="mysql"} | json [5m]))`,
@@ -193,7 +195,7 @@ func Test_NilFilterDoesntPanic(t *testing.T) {
 
 			p, err := expr.Pipeline()
 			require.Nil(t, err)
-			_, _, ok := p.ForStream(labelBar).Process([]byte("bleepbloop"))
+			_, _, ok := p.ForStream(labelBar).Process(0, []byte("bleepbloop"))
 
 			require.True(t, ok)
 		})
@@ -287,7 +289,7 @@ func Test_FilterMatcher(t *testing.T) {
 			} else {
 				sp := p.ForStream(labelBar)
 				for _, lc := range tt.lines {
-					_, _, ok := sp.Process([]byte(lc.l))
+					_, _, ok := sp.Process(0, []byte(lc.l))
 					assert.Equalf(t, lc.e, ok, "query for line '%s' was %v and not %v", lc.l, ok, lc.e)
 				}
 			}
@@ -392,7 +394,7 @@ func BenchmarkContainsFilter(b *testing.B) {
 			sp := p.ForStream(labelBar)
 			for i := 0; i < b.N; i++ {
 				for _, line := range lines {
-					sp.Process(line)
+					sp.Process(0, line)
 				}
 			}
 		})
@@ -487,6 +489,28 @@ func Test_canInjectVectorGrouping(t *testing.T) {
 	}
 }
 
+func Test_GroupingFunction(t *testing.T) {
+	lbs := labels.FromStrings("app", "foo", "namespace", "a")
+	require.Equal(t, "2", GroupingFunction{Name: OpGroupingLabelCount}.Value(lbs))
+	require.Equal(t, "2", GroupingFunction{Name: OpGroupingLabelCount}.Value(labels.FromStrings("__name__", "logs", "app", "foo", "namespace", "a")))
+
+	// the hash bucket of a label set is stable.
+	hash := GroupingFunction{Name: OpGroupingLabelsHash, Buckets: 8}
+	bucket := hash.Value(lbs)
+	require.Equal(t, bucket, hash.Value(labels.FromStrings("app", "foo", "namespace", "a")))
+	require.Contains(t, []string{"0", "1", "2", "3", "4", "5", "6", "7"}, bucket)
+	require.Equal(t, "0", GroupingFunction{Name: OpGroupingLabelsHash, Buckets: 1}.Value(lbs))
+
+	// the grouping of the vector aggregation isn't injected in the range extractor, the functions need all the labels.
+	expr, err := ParseSampleExpr(`sum by (label_count()) (count_over_time({app="foo"}[1m]))`)
+	require.NoError(t, err)
+	extractor, err := expr.Extractor()
+	require.NoError(t, err)
+	_, res, ok := extractor.ForStream(lbs).Process(0, []byte("line"))
+	require.True(t, ok)
+	require.Equal(t, lbs, res.Labels())
+}
+
 func Test_MergeBinOpVectors_Filter(t *testing.T) {
 	res := MergeBinOp(
 		OpTypeGT,
@@ -506,3 +530,24 @@ func Test_MergeBinOpVectors_Filter(t *testing.T) {
 		Point: promql.Point{V: 2},
 	}, res)
 }
+
+func Test_LineFilterNeedles(t *testing.T) {
+	for _, tt := range []struct {
+		query    string
+		expected []string
+	}{
+		{`{app="foo"}`, nil},
+		{`{app="foo"} |= "bar"`, []string{"bar"}},
+		{`{app="foo"} |= "bar" != "baz" |~ "qu+x" |= ip("1.2.3.4") |= "quux"`, []string{"quux", "bar"}},
+		{`{app="foo"} |= "bar" | json | level="error" | label_format lvl=level |= "baz"`, []string{"bar", "baz"}},
+		// the lines filtered after they are changed aren't the lines stored.
+		{`{app="foo"} |= "bar" | line_format "{{.msg}}" |= "baz"`, []string{"bar"}},
+		{`{app="foo"} |= "bar" | unpack |= "baz"`, []string{"bar"}},
+	} {
+		t.Run(tt.query, func(t *testing.T) {
+			expr, err := ParseLogSelector(tt.query, true)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, LineFilterNeedles(expr))
+		})
+	}
+}
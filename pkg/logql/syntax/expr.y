@@ -13,6 +13,7 @@ import (
   Expr                    Expr
   Filter                  labels.MatchType
   Grouping                *Grouping
+  GroupingFunction        GroupingFunction
   Labels                  []string
   LogExpr                 LogSelectorExpr
   LogRangeExpr            *LogRange
@@ -63,6 +64,8 @@ import (
 %type <Expr>                  expr
 %type <Filter>                filter
 %type <Grouping>              grouping
+%type <Grouping>              groupingLabels
+%type <GroupingFunction>      groupingFunction
 %type <Labels>                labels
 %type <LogExpr>               logExpr
 %type <MetricExpr>            metricExpr
@@ -111,6 +114,7 @@ import (
                   BYTES_OVER_TIME BYTES_RATE BOOL JSON REGEXP LOGFMT PIPE LINE_FMT LABEL_FMT UNWRAP AVG_OVER_TIME SUM_OVER_TIME MIN_OVER_TIME
                   MAX_OVER_TIME STDVAR_OVER_TIME STDDEV_OVER_TIME QUANTILE_OVER_TIME BYTES_CONV DURATION_CONV DURATION_SECONDS_CONV
                   FIRST_OVER_TIME LAST_OVER_TIME ABSENT_OVER_TIME LABEL_REPLACE UNPACK OFFSET PATTERN IP ON IGNORING GROUP_LEFT GROUP_RIGHT
+                  EWMA_RATE EWMA_BYTES_RATE HISTOGRAM_OVER_TIME BYTES_HISTOGRAM_OVER_TIME LABEL_COUNT LABELS_HASH
 
 // Operators are listed with increasing precedence.
 %left <binOp> OR
@@ -468,6 +472,10 @@ rangeOp:
     | FIRST_OVER_TIME    { $$ = OpRangeTypeFirst }
     | LAST_OVER_TIME     { $$ = OpRangeTypeLast }
     | ABSENT_OVER_TIME   { $$ = OpRangeTypeAbsent }
+    | EWMA_RATE          { $$ = OpRangeTypeEWMARate }
+    | EWMA_BYTES_RATE    { $$ = OpRangeTypeEWMABytesRate }
+    | HISTOGRAM_OVER_TIME       { $$ = OpRangeTypeHistogram }
+    | BYTES_HISTOGRAM_OVER_TIME { $$ = OpRangeTypeBytesHistogram }
     ;
 
 offsetExpr:
@@ -478,9 +486,21 @@ labels:
     | labels COMMA IDENTIFIER    { $$ = append($1, $3) }
     ;
 
+groupingFunction:
+      LABEL_COUNT OPEN_PARENTHESIS CLOSE_PARENTHESIS         { $$ = mustNewGroupingFunction(OpGroupingLabelCount, nil) }
+    | LABELS_HASH OPEN_PARENTHESIS NUMBER CLOSE_PARENTHESIS  { $$ = mustNewGroupingFunction(OpGroupingLabelsHash, &$3) }
+    ;
+
+groupingLabels:
+      IDENTIFIER                               { $$ = &Grouping{ Groups: []string{ $1 } } }
+    | groupingFunction                         { $$ = &Grouping{ Functions: []GroupingFunction{ $1 } } }
+    | groupingLabels COMMA IDENTIFIER          { $1.Groups = append($1.Groups, $3); $$ = $1 }
+    | groupingLabels COMMA groupingFunction    { $1.Functions = append($1.Functions, $3); $$ = $1 }
+    ;
+
 grouping:
-      BY OPEN_PARENTHESIS labels CLOSE_PARENTHESIS        { $$ = &Grouping{ Without: false , Groups: $3 } }
-    | WITHOUT OPEN_PARENTHESIS labels CLOSE_PARENTHESIS   { $$ = &Grouping{ Without: true , Groups: $3 } }
+      BY OPEN_PARENTHESIS groupingLabels CLOSE_PARENTHESIS        { $$ = mustNewGrouping(false, $3) }
+    | WITHOUT OPEN_PARENTHESIS groupingLabels CLOSE_PARENTHESIS   { $$ = mustNewGrouping(true, $3) }
     | BY OPEN_PARENTHESIS CLOSE_PARENTHESIS               { $$ = &Grouping{ Without: false , Groups: nil } }
     | WITHOUT OPEN_PARENTHESIS CLOSE_PARENTHESIS          { $$ = &Grouping{ Without: true , Groups: nil } }
     ;
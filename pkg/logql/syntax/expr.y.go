@@ -16,6 +16,7 @@ type exprSymType struct {
 	Expr                  Expr
 	Filter                labels.MatchType
 	Grouping              *Grouping
+	GroupingFunction      GroupingFunction
 	Labels                []string
 	LogExpr               LogSelectorExpr
 	LogRangeExpr          *LogRange
@@ -127,21 +128,27 @@ const ON = 57408
 const IGNORING = 57409
 const GROUP_LEFT = 57410
 const GROUP_RIGHT = 57411
-const OR = 57412
-const AND = 57413
-const UNLESS = 57414
-const CMP_EQ = 57415
-const NEQ = 57416
-const LT = 57417
-const LTE = 57418
-const GT = 57419
-const GTE = 57420
-const ADD = 57421
-const SUB = 57422
-const MUL = 57423
-const DIV = 57424
-const MOD = 57425
-const POW = 57426
+const EWMA_RATE = 57412
+const EWMA_BYTES_RATE = 57413
+const HISTOGRAM_OVER_TIME = 57414
+const BYTES_HISTOGRAM_OVER_TIME = 57415
+const LABEL_COUNT = 57416
+const LABELS_HASH = 57417
+const OR = 57418
+const AND = 57419
+const UNLESS = 57420
+const CMP_EQ = 57421
+const NEQ = 57422
+const LT = 57423
+const LTE = 57424
+const GT = 57425
+const GTE = 57426
+const ADD = 57427
+const SUB = 57428
+const MUL = 57429
+const DIV = 57430
+const MOD = 57431
+const POW = 57432
 
 var exprToknames = [...]string{
 	"$end",
@@ -213,6 +220,12 @@ var exprToknames = [...]string{
 	"IGNORING",
 	"GROUP_LEFT",
 	"GROUP_RIGHT",
+	"EWMA_RATE",
+	"EWMA_BYTES_RATE",
+	"HISTOGRAM_OVER_TIME",
+	"BYTES_HISTOGRAM_OVER_TIME",
+	"LABEL_COUNT",
+	"LABELS_HASH",
 	"OR",
 	"AND",
 	"UNLESS",
@@ -244,132 +257,136 @@ var exprExca = [...]int{
 
 const exprPrivate = 57344
 
-const exprLast = 532
+const exprLast = 558
 
 var exprAct = [...]int{
 
-	248, 195, 76, 4, 176, 58, 164, 5, 169, 204,
-	67, 112, 50, 57, 122, 135, 69, 2, 45, 46,
-	47, 48, 49, 50, 72, 42, 43, 44, 51, 52,
-	55, 56, 53, 54, 45, 46, 47, 48, 49, 50,
-	43, 44, 51, 52, 55, 56, 53, 54, 45, 46,
-	47, 48, 49, 50, 47, 48, 49, 50, 131, 133,
-	134, 65, 320, 100, 178, 133, 134, 104, 63, 64,
-	148, 149, 228, 124, 188, 229, 227, 146, 147, 139,
-	251, 251, 137, 61, 256, 144, 51, 52, 55, 56,
-	53, 54, 45, 46, 47, 48, 49, 50, 253, 145,
-	294, 320, 294, 150, 151, 152, 153, 154, 155, 156,
-	157, 158, 159, 160, 161, 162, 163, 323, 119, 85,
-	132, 66, 340, 173, 335, 184, 179, 182, 183, 180,
-	181, 328, 166, 226, 191, 253, 116, 253, 317, 77,
-	78, 186, 265, 101, 265, 202, 198, 311, 191, 310,
-	254, 196, 206, 207, 199, 65, 286, 252, 75, 65,
-	77, 78, 63, 64, 194, 302, 63, 64, 327, 65,
-	260, 275, 214, 215, 216, 254, 63, 64, 325, 257,
-	65, 194, 252, 65, 165, 197, 65, 63, 64, 197,
-	63, 64, 253, 63, 64, 246, 249, 301, 255, 197,
-	258, 137, 100, 261, 104, 262, 265, 304, 250, 247,
-	197, 309, 259, 197, 285, 66, 197, 253, 263, 66,
-	269, 271, 274, 276, 119, 279, 277, 65, 82, 66,
-	206, 265, 251, 119, 63, 64, 308, 284, 166, 206,
-	66, 295, 116, 66, 191, 119, 66, 166, 200, 273,
-	287, 116, 289, 291, 126, 293, 100, 60, 272, 166,
-	292, 303, 288, 116, 219, 100, 192, 224, 305, 187,
-	225, 223, 86, 87, 88, 89, 90, 91, 92, 93,
-	94, 95, 96, 97, 98, 99, 206, 66, 125, 314,
-	315, 297, 298, 299, 100, 316, 119, 283, 167, 165,
-	206, 318, 319, 265, 265, 270, 206, 324, 267, 266,
-	167, 165, 213, 136, 116, 119, 15, 212, 12, 208,
-	330, 12, 331, 332, 12, 205, 138, 211, 222, 138,
-	210, 185, 6, 116, 336, 143, 19, 20, 33, 34,
-	36, 37, 35, 38, 39, 40, 41, 21, 22, 142,
-	141, 107, 109, 108, 81, 117, 118, 23, 24, 25,
-	26, 27, 28, 29, 74, 338, 334, 30, 31, 32,
-	18, 203, 110, 307, 111, 264, 220, 217, 209, 12,
-	201, 193, 130, 221, 218, 333, 322, 6, 16, 17,
-	128, 19, 20, 33, 34, 36, 37, 35, 38, 39,
-	40, 41, 21, 22, 127, 243, 321, 129, 244, 242,
-	80, 300, 23, 24, 25, 26, 27, 28, 29, 290,
-	281, 282, 30, 31, 32, 18, 140, 240, 79, 237,
-	241, 239, 238, 236, 12, 234, 339, 231, 235, 233,
-	232, 230, 6, 16, 17, 119, 19, 20, 33, 34,
-	36, 37, 35, 38, 39, 40, 41, 21, 22, 3,
-	337, 326, 313, 116, 312, 278, 68, 23, 24, 25,
-	26, 27, 28, 29, 268, 245, 190, 30, 31, 32,
-	18, 107, 109, 108, 189, 117, 118, 256, 280, 188,
-	187, 177, 113, 174, 172, 171, 329, 71, 16, 17,
-	73, 306, 110, 170, 111, 73, 177, 114, 168, 103,
-	175, 106, 105, 59, 120, 115, 121, 102, 84, 83,
-	11, 10, 9, 123, 14, 8, 296, 13, 7, 70,
-	62, 1,
+	255, 199, 80, 4, 211, 62, 168, 180, 173, 278,
+	71, 116, 208, 61, 54, 5, 139, 73, 2, 51,
+	52, 53, 54, 258, 76, 46, 47, 48, 55, 56,
+	59, 60, 57, 58, 49, 50, 51, 52, 53, 54,
+	47, 48, 55, 56, 59, 60, 57, 58, 49, 50,
+	51, 52, 53, 54, 55, 56, 59, 60, 57, 58,
+	49, 50, 51, 52, 53, 54, 263, 104, 182, 137,
+	138, 108, 49, 50, 51, 52, 53, 54, 135, 137,
+	138, 260, 123, 143, 69, 152, 153, 150, 151, 148,
+	261, 67, 68, 126, 141, 69, 170, 304, 65, 69,
+	120, 334, 67, 68, 149, 312, 67, 68, 154, 155,
+	156, 157, 158, 159, 160, 161, 162, 163, 164, 165,
+	166, 167, 334, 69, 89, 201, 316, 177, 210, 201,
+	67, 68, 260, 331, 259, 188, 183, 186, 187, 184,
+	185, 258, 81, 82, 356, 190, 136, 215, 258, 206,
+	70, 198, 128, 201, 169, 200, 69, 351, 202, 203,
+	214, 70, 105, 67, 68, 70, 264, 322, 261, 260,
+	198, 344, 325, 69, 322, 69, 221, 222, 223, 324,
+	67, 68, 67, 68, 343, 69, 201, 340, 79, 70,
+	81, 82, 67, 68, 339, 212, 213, 212, 213, 253,
+	256, 195, 262, 201, 265, 201, 104, 268, 108, 269,
+	318, 314, 257, 141, 254, 64, 266, 295, 123, 270,
+	123, 322, 70, 296, 210, 195, 323, 195, 281, 284,
+	286, 204, 170, 287, 170, 289, 120, 226, 120, 70,
+	123, 70, 235, 209, 192, 236, 234, 267, 322, 196,
+	304, 70, 231, 321, 191, 232, 230, 297, 120, 299,
+	301, 259, 303, 104, 305, 337, 123, 302, 313, 298,
+	130, 129, 104, 294, 293, 315, 311, 317, 280, 280,
+	170, 272, 354, 123, 120, 260, 276, 280, 280, 171,
+	169, 171, 169, 212, 213, 275, 260, 285, 283, 328,
+	329, 120, 140, 233, 104, 330, 282, 279, 123, 274,
+	12, 332, 333, 229, 307, 308, 309, 338, 142, 111,
+	113, 112, 12, 121, 122, 263, 120, 272, 220, 219,
+	142, 15, 273, 218, 346, 217, 347, 348, 189, 12,
+	114, 147, 115, 146, 111, 113, 112, 6, 121, 122,
+	352, 19, 20, 37, 38, 40, 41, 39, 42, 43,
+	44, 45, 21, 22, 145, 114, 85, 115, 78, 350,
+	320, 271, 23, 24, 25, 26, 27, 28, 29, 227,
+	224, 216, 30, 31, 32, 18, 205, 132, 197, 134,
+	228, 207, 225, 319, 33, 34, 35, 36, 349, 12,
+	336, 131, 250, 335, 133, 251, 249, 6, 310, 16,
+	17, 19, 20, 37, 38, 40, 41, 39, 42, 43,
+	44, 45, 21, 22, 247, 300, 244, 248, 246, 245,
+	243, 84, 23, 24, 25, 26, 27, 28, 29, 291,
+	292, 3, 30, 31, 32, 18, 241, 83, 72, 242,
+	240, 144, 355, 353, 33, 34, 35, 36, 238, 12,
+	345, 239, 237, 341, 327, 326, 288, 6, 277, 16,
+	17, 19, 20, 37, 38, 40, 41, 39, 42, 43,
+	44, 45, 21, 22, 86, 290, 252, 194, 181, 117,
+	193, 192, 23, 24, 25, 26, 27, 28, 29, 191,
+	178, 176, 30, 31, 32, 18, 175, 75, 342, 174,
+	77, 77, 181, 118, 33, 34, 35, 36, 172, 107,
+	179, 110, 109, 63, 124, 119, 125, 106, 88, 16,
+	17, 87, 90, 91, 92, 93, 94, 95, 96, 97,
+	98, 99, 100, 101, 102, 103, 11, 10, 9, 127,
+	14, 8, 306, 13, 7, 74, 66, 1,
 }
 var exprPact = [...]int{
 
-	309, -1000, -45, -1000, -1000, 213, 309, -1000, -1000, -1000,
-	-1000, -1000, 495, 341, 135, -1000, 421, 403, 331, -1000,
+	324, -1000, -51, -1000, -1000, 171, 324, -1000, -1000, -1000,
+	-1000, -1000, 505, 345, 165, -1000, 440, 424, 343, -1000,
 	-1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000,
 	-1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000,
-	-1000, -1000, 79, 79, 79, 79, 79, 79, 79, 79,
-	79, 79, 79, 79, 79, 79, 79, 213, -1000, 47,
-	310, -1000, 8, -1000, -1000, -1000, -1000, 264, 230, -45,
-	388, 366, -1000, 46, 306, 419, 327, 326, 312, -1000,
-	-1000, 309, 309, 11, 2, -1000, 309, 309, 309, 309,
-	309, 309, 309, 309, 309, 309, 309, 309, 309, 309,
-	-1000, -1000, -1000, -1000, 228, -1000, -1000, 498, -1000, 489,
-	-1000, 488, -1000, -1000, -1000, -1000, 291, 487, 501, 52,
-	-1000, -1000, -1000, 308, -1000, -1000, -1000, -1000, -1000, 500,
-	-1000, 484, 483, 478, 470, 242, 362, 172, 303, 224,
-	361, 364, 301, 295, 359, -31, 307, 304, 294, 289,
-	13, 13, -27, -27, -72, -72, -72, -72, -61, -61,
-	-61, -61, -61, -61, 228, 291, 291, 291, 358, -1000,
-	372, -1000, -1000, 240, -1000, 357, -1000, 371, 263, 68,
-	433, 431, 425, 423, 401, 469, -1000, -1000, -1000, -1000,
-	-1000, -1000, 114, 303, 169, 148, 166, 440, 155, 146,
-	114, 309, 194, 356, 285, -1000, -1000, 284, -1000, 468,
-	281, 234, 225, 147, 219, 228, 113, 498, 459, -1000,
-	486, 415, 274, -1000, -1000, -1000, 214, -1000, -1000, -1000,
+	-1000, -1000, -1000, -1000, -1000, -1000, 84, 84, 84, 84,
+	84, 84, 84, 84, 84, 84, 84, 84, 84, 84,
+	84, 171, -1000, 70, 303, -1000, 87, -1000, -1000, -1000,
+	-1000, 247, 246, -51, 385, 373, -1000, 66, 295, 444,
+	341, 320, 318, -1000, -1000, 324, 324, 21, 17, -1000,
+	324, 324, 324, 324, 324, 324, 324, 324, 324, 324,
+	324, 324, 324, 324, -1000, -1000, -1000, -1000, 215, -1000,
+	-1000, 504, -1000, 500, -1000, 495, -1000, -1000, -1000, -1000,
+	235, 494, 507, 56, -1000, -1000, -1000, 315, -1000, -1000,
+	-1000, -1000, -1000, 506, -1000, 493, 485, 484, 481, 225,
+	369, 161, 307, 207, 367, 384, 219, 123, 362, -37,
+	312, 310, 306, 305, -25, -25, -68, -68, -76, -76,
+	-76, -76, -13, -13, -13, -13, -13, -13, 215, 235,
+	235, 235, 361, -1000, 380, -1000, -1000, 213, -1000, 360,
+	-1000, 378, 248, 238, 454, 442, 422, 420, 398, 480,
+	-1000, -1000, -1000, -1000, -1000, -1000, 117, 307, 85, 125,
+	159, 278, 142, 223, 117, 324, 195, 352, 308, -1000,
+	-1000, -1000, 286, 272, 262, -1000, 462, 283, 282, 274,
+	273, 261, 215, 77, 504, 460, -1000, 483, 434, 251,
+	-1000, -1000, -1000, 250, -1000, -1000, -1000, -1000, -1000, -1000,
 	-1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000,
-	-1000, -1000, -1000, -1000, -1000, 190, -1000, 132, 145, 54,
-	145, 411, 17, 291, 17, 91, 236, 402, 173, 141,
-	-1000, -1000, 183, -1000, 309, 496, -1000, -1000, 354, 212,
-	-1000, 187, -1000, -1000, 125, -1000, 123, -1000, -1000, -1000,
-	-1000, -1000, -1000, 458, 456, -1000, 114, 54, 145, 54,
-	-1000, -1000, 228, -1000, 17, -1000, 115, -1000, -1000, -1000,
-	18, 397, 377, 93, 114, 154, -1000, 455, -1000, -1000,
-	-1000, -1000, 144, 107, -1000, 54, -1000, 491, 57, 54,
-	37, 17, 17, 376, -1000, -1000, 347, -1000, -1000, 100,
-	54, -1000, -1000, 17, 454, -1000, -1000, 346, 430, 98,
-	-1000,
+	-1000, -1000, 193, -1000, 199, 109, 37, 109, 417, -40,
+	235, -40, 88, 259, 399, 252, 81, -1000, -1000, 187,
+	-1000, 324, 121, -1000, 186, 386, -1000, 351, 229, -1000,
+	-1000, 202, -1000, -1000, 155, -1000, 148, -1000, -1000, -1000,
+	-1000, -1000, -1000, 459, 458, -1000, 117, 37, 109, 37,
+	-1000, -1000, 215, -1000, -40, -1000, 110, -1000, -1000, -1000,
+	78, 394, 391, 241, 117, 170, -1000, -1000, -1000, 163,
+	457, -1000, 503, -1000, -1000, -1000, 160, 147, -1000, 37,
+	-1000, 455, 57, 37, 19, -40, -40, 389, -1000, -1000,
+	-1000, 350, -1000, -1000, -1000, 133, 37, -1000, -1000, -40,
+	447, -1000, -1000, 263, 446, 120, -1000,
 }
 var exprPgo = [...]int{
 
-	0, 531, 16, 530, 2, 9, 459, 3, 15, 11,
-	529, 528, 527, 526, 7, 525, 524, 523, 522, 521,
-	520, 228, 519, 518, 517, 13, 5, 516, 515, 514,
-	6, 513, 83, 512, 511, 4, 510, 509, 8, 508,
-	1, 507, 492, 0,
+	0, 557, 17, 556, 2, 12, 4, 9, 441, 3,
+	16, 11, 555, 554, 553, 552, 15, 551, 550, 549,
+	548, 547, 546, 484, 531, 528, 527, 13, 5, 526,
+	525, 524, 6, 523, 98, 522, 521, 7, 520, 519,
+	8, 518, 1, 513, 489, 0,
 }
 var exprR1 = [...]int{
 
-	0, 1, 2, 2, 7, 7, 7, 7, 7, 7,
-	6, 6, 6, 8, 8, 8, 8, 8, 8, 8,
-	8, 8, 8, 8, 8, 8, 8, 8, 8, 8,
-	8, 8, 8, 8, 8, 8, 8, 8, 8, 40,
-	40, 40, 13, 13, 13, 11, 11, 11, 11, 15,
-	15, 15, 15, 15, 15, 20, 3, 3, 3, 3,
-	14, 14, 14, 10, 10, 9, 9, 9, 9, 25,
-	25, 26, 26, 26, 26, 26, 26, 17, 32, 32,
-	31, 31, 24, 24, 24, 24, 24, 37, 33, 35,
-	35, 36, 36, 36, 34, 30, 30, 30, 30, 30,
-	30, 30, 30, 30, 38, 39, 39, 42, 42, 41,
-	41, 29, 29, 29, 29, 29, 29, 29, 27, 27,
-	27, 27, 27, 27, 27, 28, 28, 28, 28, 28,
-	28, 28, 18, 18, 18, 18, 18, 18, 18, 18,
-	18, 18, 18, 18, 18, 18, 18, 22, 22, 23,
-	23, 23, 23, 21, 21, 21, 21, 21, 21, 21,
-	21, 19, 19, 19, 16, 16, 16, 16, 16, 16,
-	16, 16, 16, 12, 12, 12, 12, 12, 12, 12,
-	12, 12, 12, 12, 12, 12, 12, 43, 5, 5,
+	0, 1, 2, 2, 9, 9, 9, 9, 9, 9,
+	8, 8, 8, 10, 10, 10, 10, 10, 10, 10,
+	10, 10, 10, 10, 10, 10, 10, 10, 10, 10,
+	10, 10, 10, 10, 10, 10, 10, 10, 10, 42,
+	42, 42, 15, 15, 15, 13, 13, 13, 13, 17,
+	17, 17, 17, 17, 17, 22, 3, 3, 3, 3,
+	16, 16, 16, 12, 12, 11, 11, 11, 11, 27,
+	27, 28, 28, 28, 28, 28, 28, 19, 34, 34,
+	33, 33, 26, 26, 26, 26, 26, 39, 35, 37,
+	37, 38, 38, 38, 36, 32, 32, 32, 32, 32,
+	32, 32, 32, 32, 40, 41, 41, 44, 44, 43,
+	43, 31, 31, 31, 31, 31, 31, 31, 29, 29,
+	29, 29, 29, 29, 29, 30, 30, 30, 30, 30,
+	30, 30, 20, 20, 20, 20, 20, 20, 20, 20,
+	20, 20, 20, 20, 20, 20, 20, 24, 24, 25,
+	25, 25, 25, 23, 23, 23, 23, 23, 23, 23,
+	23, 21, 21, 21, 18, 18, 18, 18, 18, 18,
+	18, 18, 18, 14, 14, 14, 14, 14, 14, 14,
+	14, 14, 14, 14, 14, 14, 14, 14, 14, 14,
+	14, 45, 7, 7, 6, 6, 5, 5, 5, 5,
 	4, 4, 4, 4,
 }
 var exprR2 = [...]int{
@@ -392,84 +409,87 @@ var exprR2 = [...]int{
 	4, 5, 4, 1, 1, 2, 4, 5, 2, 4,
 	5, 1, 2, 2, 1, 1, 1, 1, 1, 1,
 	1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
-	1, 1, 1, 1, 1, 1, 1, 2, 1, 3,
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+	1, 2, 1, 3, 3, 4, 1, 1, 3, 3,
 	4, 4, 3, 3,
 }
 var exprChk = [...]int{
 
-	-1000, -1, -2, -6, -7, -14, 23, -11, -15, -18,
-	-19, -20, 15, -12, -16, 7, 79, 80, 61, 27,
+	-1000, -1, -2, -8, -9, -16, 23, -13, -17, -20,
+	-21, -22, 15, -14, -18, 7, 85, 86, 61, 27,
 	28, 38, 39, 48, 49, 50, 51, 52, 53, 54,
-	58, 59, 60, 29, 30, 33, 31, 32, 34, 35,
-	36, 37, 70, 71, 72, 79, 80, 81, 82, 83,
-	84, 73, 74, 77, 78, 75, 76, -25, -26, -31,
-	44, -32, -3, 21, 22, 14, 74, -7, -6, -2,
-	-10, 2, -9, 5, 23, 23, -4, 25, 26, 7,
-	7, 23, -21, -22, -23, 40, -21, -21, -21, -21,
-	-21, -21, -21, -21, -21, -21, -21, -21, -21, -21,
-	-26, -32, -24, -37, -30, -33, -34, 41, 43, 42,
-	62, 64, -9, -42, -41, -28, 23, 45, 46, 5,
-	-29, -27, 6, -17, 65, 24, 24, 16, 2, 19,
-	16, 12, 74, 13, 14, -8, 7, -14, 23, -7,
-	7, 23, 23, 23, -7, -2, 66, 67, 68, 69,
-	-2, -2, -2, -2, -2, -2, -2, -2, -2, -2,
-	-2, -2, -2, -2, -30, 71, 19, 70, -39, -38,
-	5, 6, 6, -30, 6, -36, -35, 5, 12, 74,
-	77, 78, 75, 76, 73, 23, -9, 6, 6, 6,
-	6, 2, 24, 19, 9, -40, -25, 44, -14, -8,
-	24, 19, -7, 7, -5, 24, 5, -5, 24, 19,
-	23, 23, 23, 23, -30, -30, -30, 19, 12, 24,
-	19, 12, 65, 8, 4, 7, 65, 8, 4, 7,
+	58, 59, 60, 70, 71, 72, 73, 29, 30, 33,
+	31, 32, 34, 35, 36, 37, 76, 77, 78, 85,
+	86, 87, 88, 89, 90, 79, 80, 83, 84, 81,
+	82, -27, -28, -33, 44, -34, -3, 21, 22, 14,
+	80, -9, -8, -2, -12, 2, -11, 5, 23, 23,
+	-4, 25, 26, 7, 7, 23, -23, -24, -25, 40,
+	-23, -23, -23, -23, -23, -23, -23, -23, -23, -23,
+	-23, -23, -23, -23, -28, -34, -26, -39, -32, -35,
+	-36, 41, 43, 42, 62, 64, -11, -44, -43, -30,
+	23, 45, 46, 5, -31, -29, 6, -19, 65, 24,
+	24, 16, 2, 19, 16, 12, 80, 13, 14, -10,
+	7, -16, 23, -9, 7, 23, 23, 23, -9, -2,
+	66, 67, 68, 69, -2, -2, -2, -2, -2, -2,
+	-2, -2, -2, -2, -2, -2, -2, -2, -32, 77,
+	19, 76, -41, -40, 5, 6, 6, -32, 6, -38,
+	-37, 5, 12, 80, 83, 84, 81, 82, 79, 23,
+	-11, 6, 6, 6, 6, 2, 24, 19, 9, -42,
+	-27, 44, -16, -10, 24, 19, -9, 7, -5, 24,
+	5, -6, 74, 75, -5, 24, 19, 23, 23, 23,
+	23, -32, -32, -32, 19, 12, 24, 19, 12, 65,
+	8, 4, 7, 65, 8, 4, 7, 8, 4, 7,
 	8, 4, 7, 8, 4, 7, 8, 4, 7, 8,
-	4, 7, 8, 4, 7, 6, -4, -8, -43, -40,
-	-25, 63, 9, 44, 9, -40, 47, 24, -40, -25,
-	24, -4, -7, 24, 19, 19, 24, 24, 6, -5,
-	24, -5, 24, 24, -5, 24, -5, -38, 6, -35,
-	2, 5, 6, 23, 23, 24, 24, -40, -25, -40,
-	8, -43, -30, -43, 9, 5, -13, 55, 56, 57,
-	9, 24, 24, -40, 24, -7, 5, 19, 24, 24,
-	24, 24, 6, 6, -4, -40, -43, 23, -43, -40,
-	44, 9, 9, 24, -4, 24, 6, 24, 24, 5,
-	-40, -43, -43, 9, 19, 24, -43, 6, 19, 6,
-	24,
+	4, 7, 6, -4, -10, -45, -42, -27, 63, 9,
+	44, 9, -42, 47, 24, -42, -27, 24, -4, -9,
+	24, 19, 19, 24, 23, 23, 24, 6, -7, 24,
+	5, -7, 24, 24, -7, 24, -7, -40, 6, -37,
+	2, 5, 6, 23, 23, 24, 24, -42, -27, -42,
+	8, -45, -32, -45, 9, 5, -15, 55, 56, 57,
+	9, 24, 24, -42, 24, -9, 5, -6, 24, 7,
+	19, 24, 19, 24, 24, 24, 6, 6, -4, -42,
+	-45, 23, -45, -42, 44, 9, 9, 24, -4, 24,
+	24, 6, 5, 24, 24, 5, -42, -45, -45, 9,
+	19, 24, -45, 6, 19, 6, 24,
 }
 var exprDef = [...]int{
 
 	0, -2, 1, 2, 3, 10, 0, 4, 5, 6,
 	7, 8, 0, 0, 0, 161, 0, 0, 0, 173,
 	174, 175, 176, 177, 178, 179, 180, 181, 182, 183,
-	184, 185, 186, 164, 165, 166, 167, 168, 169, 170,
-	171, 172, 147, 147, 147, 147, 147, 147, 147, 147,
-	147, 147, 147, 147, 147, 147, 147, 11, 69, 71,
-	0, 80, 0, 56, 57, 58, 59, 3, 2, 0,
-	0, 0, 63, 0, 0, 0, 0, 0, 0, 162,
-	163, 0, 0, 153, 154, 148, 0, 0, 0, 0,
+	184, 185, 186, 187, 188, 189, 190, 164, 165, 166,
+	167, 168, 169, 170, 171, 172, 147, 147, 147, 147,
+	147, 147, 147, 147, 147, 147, 147, 147, 147, 147,
+	147, 11, 69, 71, 0, 80, 0, 56, 57, 58,
+	59, 3, 2, 0, 0, 0, 63, 0, 0, 0,
+	0, 0, 0, 162, 163, 0, 0, 153, 154, 148,
 	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
-	70, 81, 72, 73, 74, 75, 76, 82, 83, 0,
-	85, 0, 95, 96, 97, 98, 0, 0, 0, 0,
-	109, 110, 78, 0, 77, 9, 12, 60, 61, 0,
-	62, 0, 0, 0, 0, 0, 0, 0, 0, 3,
-	161, 0, 0, 0, 3, 132, 0, 0, 155, 158,
-	133, 134, 135, 136, 137, 138, 139, 140, 141, 142,
-	143, 144, 145, 146, 100, 0, 0, 0, 87, 105,
-	0, 84, 86, 0, 88, 94, 91, 0, 0, 0,
-	0, 0, 0, 0, 0, 0, 64, 65, 66, 67,
-	68, 38, 45, 0, 13, 0, 0, 0, 0, 0,
-	49, 0, 3, 161, 0, 192, 188, 0, 193, 0,
-	0, 0, 0, 0, 101, 102, 103, 0, 0, 99,
-	0, 0, 0, 116, 123, 130, 0, 115, 122, 129,
-	111, 118, 125, 112, 119, 126, 113, 120, 127, 114,
-	121, 128, 117, 124, 131, 0, 47, 0, 14, 17,
-	33, 0, 21, 0, 25, 0, 0, 0, 0, 0,
-	37, 51, 3, 50, 0, 0, 190, 191, 0, 0,
-	150, 0, 152, 156, 0, 159, 0, 106, 104, 92,
+	0, 0, 0, 0, 70, 81, 72, 73, 74, 75,
+	76, 82, 83, 0, 85, 0, 95, 96, 97, 98,
+	0, 0, 0, 0, 109, 110, 78, 0, 77, 9,
+	12, 60, 61, 0, 62, 0, 0, 0, 0, 0,
+	0, 0, 0, 3, 161, 0, 0, 0, 3, 132,
+	0, 0, 155, 158, 133, 134, 135, 136, 137, 138,
+	139, 140, 141, 142, 143, 144, 145, 146, 100, 0,
+	0, 0, 87, 105, 0, 84, 86, 0, 88, 94,
+	91, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	64, 65, 66, 67, 68, 38, 45, 0, 13, 0,
+	0, 0, 0, 0, 49, 0, 3, 161, 0, 202,
+	196, 197, 0, 0, 0, 203, 0, 0, 0, 0,
+	0, 101, 102, 103, 0, 0, 99, 0, 0, 0,
+	116, 123, 130, 0, 115, 122, 129, 111, 118, 125,
+	112, 119, 126, 113, 120, 127, 114, 121, 128, 117,
+	124, 131, 0, 47, 0, 14, 17, 33, 0, 21,
+	0, 25, 0, 0, 0, 0, 0, 37, 51, 3,
+	50, 0, 0, 200, 0, 0, 201, 0, 0, 150,
+	192, 0, 152, 156, 0, 159, 0, 106, 104, 92,
 	93, 89, 90, 0, 0, 79, 46, 18, 34, 35,
-	187, 22, 41, 26, 29, 39, 0, 42, 43, 44,
-	15, 0, 0, 0, 52, 3, 189, 0, 149, 151,
-	157, 160, 0, 0, 48, 36, 30, 0, 16, 19,
-	0, 23, 27, 0, 53, 54, 0, 107, 108, 0,
-	20, 24, 28, 31, 0, 40, 32, 0, 0, 0,
-	55,
+	191, 22, 41, 26, 29, 39, 0, 42, 43, 44,
+	15, 0, 0, 0, 52, 3, 198, 199, 194, 0,
+	0, 149, 0, 151, 157, 160, 0, 0, 48, 36,
+	30, 0, 16, 19, 0, 23, 27, 0, 53, 54,
+	195, 0, 193, 107, 108, 0, 20, 24, 28, 31,
+	0, 40, 32, 0, 0, 0, 55,
 }
 var exprTok1 = [...]int{
 
@@ -485,7 +505,7 @@ var exprTok2 = [...]int{
 	52, 53, 54, 55, 56, 57, 58, 59, 60, 61,
 	62, 63, 64, 65, 66, 67, 68, 69, 70, 71,
 	72, 73, 74, 75, 76, 77, 78, 79, 80, 81,
-	82, 83, 84,
+	82, 83, 84, 85, 86, 87, 88, 89, 90,
 }
 var exprTok3 = [...]int{
 	0,
@@ -1759,36 +1779,88 @@ exprdefault:
 			exprVAL.RangeOp = OpRangeTypeAbsent
 		}
 	case 187:
+		exprDollar = exprS[exprpt-1 : exprpt+1]
+		{
+			exprVAL.RangeOp = OpRangeTypeEWMARate
+		}
+	case 188:
+		exprDollar = exprS[exprpt-1 : exprpt+1]
+		{
+			exprVAL.RangeOp = OpRangeTypeEWMABytesRate
+		}
+	case 189:
+		exprDollar = exprS[exprpt-1 : exprpt+1]
+		{
+			exprVAL.RangeOp = OpRangeTypeHistogram
+		}
+	case 190:
+		exprDollar = exprS[exprpt-1 : exprpt+1]
+		{
+			exprVAL.RangeOp = OpRangeTypeBytesHistogram
+		}
+	case 191:
 		exprDollar = exprS[exprpt-2 : exprpt+1]
 		{
 			exprVAL.OffsetExpr = newOffsetExpr(exprDollar[2].duration)
 		}
-	case 188:
+	case 192:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
 		{
 			exprVAL.Labels = []string{exprDollar[1].str}
 		}
-	case 189:
+	case 193:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
 		{
 			exprVAL.Labels = append(exprDollar[1].Labels, exprDollar[3].str)
 		}
-	case 190:
+	case 194:
+		exprDollar = exprS[exprpt-3 : exprpt+1]
+		{
+			exprVAL.GroupingFunction = mustNewGroupingFunction(OpGroupingLabelCount, nil)
+		}
+	case 195:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
 		{
-			exprVAL.Grouping = &Grouping{Without: false, Groups: exprDollar[3].Labels}
+			exprVAL.GroupingFunction = mustNewGroupingFunction(OpGroupingLabelsHash, &exprDollar[3].str)
 		}
-	case 191:
+	case 196:
+		exprDollar = exprS[exprpt-1 : exprpt+1]
+		{
+			exprVAL.Grouping = &Grouping{Groups: []string{exprDollar[1].str}}
+		}
+	case 197:
+		exprDollar = exprS[exprpt-1 : exprpt+1]
+		{
+			exprVAL.Grouping = &Grouping{Functions: []GroupingFunction{exprDollar[1].GroupingFunction}}
+		}
+	case 198:
+		exprDollar = exprS[exprpt-3 : exprpt+1]
+		{
+			exprDollar[1].Grouping.Groups = append(exprDollar[1].Grouping.Groups, exprDollar[3].str)
+			exprVAL.Grouping = exprDollar[1].Grouping
+		}
+	case 199:
+		exprDollar = exprS[exprpt-3 : exprpt+1]
+		{
+			exprDollar[1].Grouping.Functions = append(exprDollar[1].Grouping.Functions, exprDollar[3].GroupingFunction)
+			exprVAL.Grouping = exprDollar[1].Grouping
+		}
+	case 200:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
 		{
-			exprVAL.Grouping = &Grouping{Without: true, Groups: exprDollar[3].Labels}
+			exprVAL.Grouping = mustNewGrouping(false, exprDollar[3].Grouping)
 		}
-	case 192:
+	case 201:
+		exprDollar = exprS[exprpt-4 : exprpt+1]
+		{
+			exprVAL.Grouping = mustNewGrouping(true, exprDollar[3].Grouping)
+		}
+	case 202:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
 		{
 			exprVAL.Grouping = &Grouping{Without: false, Groups: nil}
 		}
-	case 193:
+	case 203:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
 		{
 			exprVAL.Grouping = &Grouping{Without: true, Groups: nil}
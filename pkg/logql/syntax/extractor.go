@@ -76,9 +76,9 @@ func (r RangeAggregationExpr) extractor(override *Grouping) (log.SampleExtractor
 	}
 	// otherwise we extract metrics from the log line.
 	switch r.Operation {
-	case OpRangeTypeRate, OpRangeTypeCount, OpRangeTypeAbsent:
+	case OpRangeTypeRate, OpRangeTypeCount, OpRangeTypeAbsent, OpRangeTypeEWMARate:
 		return log.NewLineSampleExtractor(log.CountExtractor, stages, groups, without, noLabels)
-	case OpRangeTypeBytes, OpRangeTypeBytesRate:
+	case OpRangeTypeBytes, OpRangeTypeBytesRate, OpRangeTypeEWMABytesRate, OpRangeTypeBytesHistogram:
 		return log.NewLineSampleExtractor(log.BytesExtractor, stages, groups, without, noLabels)
 	default:
 		return nil, fmt.Errorf(UnsupportedErr, r.Operation)
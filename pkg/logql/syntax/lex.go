@@ -91,6 +91,12 @@ var functionTokens = map[string]int{
 	OpRangeTypeLast:      LAST_OVER_TIME,
 	OpRangeTypeAbsent:    ABSENT_OVER_TIME,
 
+	OpRangeTypeEWMARate:      EWMA_RATE,
+	OpRangeTypeEWMABytesRate: EWMA_BYTES_RATE,
+
+	OpRangeTypeHistogram:      HISTOGRAM_OVER_TIME,
+	OpRangeTypeBytesHistogram: BYTES_HISTOGRAM_OVER_TIME,
+
 	// vec ops
 	OpTypeSum:      SUM,
 	OpTypeAvg:      AVG,
@@ -110,6 +116,10 @@ var functionTokens = map[string]int{
 
 	// filterOp
 	OpFilterIP: IP,
+
+	// grouping functions
+	OpGroupingLabelCount: LABEL_COUNT,
+	OpGroupingLabelsHash: LABELS_HASH,
 }
 
 type lexer struct {
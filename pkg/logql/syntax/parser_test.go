@@ -129,6 +129,80 @@ func TestParse(t *testing.T) {
 				Operation: OpRangeTypeBytesRate,
 			},
 		},
+		{
+			in: `ewma_rate({ foo = "bar" }[5m])`,
+			exp: &RangeAggregationExpr{
+				Left: &LogRange{
+					Left:     &MatchersExpr{Mts: []*labels.Matcher{mustNewMatcher(labels.MatchEqual, "foo", "bar")}},
+					Interval: 5 * time.Minute,
+				},
+				Operation: OpRangeTypeEWMARate,
+			},
+		},
+		{
+			in: `ewma_bytes_rate(30, { foo = "bar" }[5m])`,
+			exp: newRangeAggregationExpr(
+				&LogRange{
+					Left:     &MatchersExpr{Mts: []*labels.Matcher{mustNewMatcher(labels.MatchEqual, "foo", "bar")}},
+					Interval: 5 * time.Minute,
+				},
+				OpRangeTypeEWMABytesRate, nil, NewStringLabelFilter("30"),
+			),
+		},
+		{
+			in: `bytes_histogram_over_time({ foo = "bar" }[5m])`,
+			exp: &RangeAggregationExpr{
+				Left: &LogRange{
+					Left:     &MatchersExpr{Mts: []*labels.Matcher{mustNewMatcher(labels.MatchEqual, "foo", "bar")}},
+					Interval: 5 * time.Minute,
+				},
+				Operation: OpRangeTypeBytesHistogram,
+			},
+		},
+		{
+			in: `histogram_over_time(2, { foo = "bar" } | unwrap latency [5m]) by (namespace)`,
+			exp: newRangeAggregationExpr(
+				&LogRange{
+					Left:     &MatchersExpr{Mts: []*labels.Matcher{mustNewMatcher(labels.MatchEqual, "foo", "bar")}},
+					Interval: 5 * time.Minute,
+					Unwrap:   &UnwrapExpr{Identifier: "latency"},
+				},
+				OpRangeTypeHistogram, &Grouping{Groups: []string{"namespace"}}, NewStringLabelFilter("2"),
+			),
+		},
+		{
+			in: `sum by (app, label_count(), labels_hash(16)) (count_over_time({ foo = "bar" }[5m]))`,
+			exp: mustNewVectorAggregationExpr(
+				&RangeAggregationExpr{
+					Left: &LogRange{
+						Left:     &MatchersExpr{Mts: []*labels.Matcher{mustNewMatcher(labels.MatchEqual, "foo", "bar")}},
+						Interval: 5 * time.Minute,
+					},
+					Operation: OpRangeTypeCount,
+				},
+				OpTypeSum,
+				&Grouping{
+					Groups:    []string{"app"},
+					Functions: []GroupingFunction{{Name: OpGroupingLabelCount}, {Name: OpGroupingLabelsHash, Buckets: 16}},
+				},
+				nil,
+			),
+		},
+		{
+			in: `count by (label_count) (rate({ foo = "bar" }[5m]))`, // label_count is also a label name
+			exp: mustNewVectorAggregationExpr(
+				&RangeAggregationExpr{
+					Left: &LogRange{
+						Left:     &MatchersExpr{Mts: []*labels.Matcher{mustNewMatcher(labels.MatchEqual, "foo", "bar")}},
+						Interval: 5 * time.Minute,
+					},
+					Operation: OpRangeTypeRate,
+				},
+				OpTypeCount,
+				&Grouping{Groups: []string{"label_count"}},
+				nil,
+			),
+		},
 		{
 			in: `rate({ foo = "bar" }[5h])`,
 			exp: &RangeAggregationExpr{
@@ -2840,6 +2914,42 @@ func TestParse(t *testing.T) {
 			in:  `quantile_over_time({namespace="tns"} |= "level=error" | json |foo>=5,bar<25ms| unwrap latency [5m])`,
 			err: logqlmodel.NewParseError("parameter required for operation quantile_over_time", 0, 0),
 		},
+		{
+			in:  `ewma_rate(0,{namespace="tns"}[5m])`,
+			err: logqlmodel.NewParseError("invalid half-life for ewma_rate: 0, it must be a positive number of seconds", 0, 0),
+		},
+		{
+			in:  `ewma_rate({namespace="tns"} | json | unwrap latency [5m])`,
+			err: logqlmodel.NewParseError("invalid aggregation ewma_rate with unwrap", 0, 0),
+		},
+		{
+			in:  `histogram_over_time(9,{namespace="tns"} | json | unwrap latency [5m])`,
+			err: logqlmodel.NewParseError("invalid schema for histogram_over_time: 9, it must be an integer between 0 and 8", 0, 0),
+		},
+		{
+			in:  `bytes_histogram_over_time(0.5,{namespace="tns"}[5m])`,
+			err: logqlmodel.NewParseError("invalid schema for bytes_histogram_over_time: 0.5, it must be an integer between 0 and 8", 0, 0),
+		},
+		{
+			in:  `histogram_over_time({namespace="tns"}[5m])`,
+			err: logqlmodel.NewParseError("invalid aggregation histogram_over_time without unwrap", 0, 0),
+		},
+		{
+			in:  `sum by (labels_hash(0)) (rate({namespace="tns"}[5m]))`,
+			err: logqlmodel.NewParseError("invalid number of buckets for labels_hash: 0, it must be a positive integer", 0, 0),
+		},
+		{
+			in:  `sum by (labels_hash(1.5)) (rate({namespace="tns"}[5m]))`,
+			err: logqlmodel.NewParseError("invalid number of buckets for labels_hash: 1.5, it must be a positive integer", 0, 0),
+		},
+		{
+			in:  `sum without (label_count()) (rate({namespace="tns"}[5m]))`,
+			err: logqlmodel.NewParseError("grouping function label_count() not supported with without", 0, 0),
+		},
+		{
+			in:  `max_over_time({namespace="tns"} | json | unwrap latency [5m]) by (labels_hash(4))`,
+			err: logqlmodel.NewParseError("grouping function labels_hash(4) not supported by range aggregations", 0, 0),
+		},
 		{
 			in:  `quantile_over_time(foo,{namespace="tns"} |= "level=error" | json |foo>=5,bar<25ms| unwrap latency [5m])`,
 			err: logqlmodel.NewParseError("syntax error: unexpected IDENTIFIER, expecting NUMBER or { or (", 1, 20),
@@ -3040,7 +3150,7 @@ func Test_PipelineCombined(t *testing.T) {
 	p, err := expr.Pipeline()
 	require.Nil(t, err)
 	sp := p.ForStream(labels.Labels{})
-	line, lbs, ok := sp.Process([]byte(`level=debug ts=2020-10-02T10:10:42.092268913Z caller=logging.go:66 traceID=a9d4d8a928d8db1 msg="POST /api/prom/api/v1/query_range (200) 1.5s"`))
+	line, lbs, ok := sp.Process(0, []byte(`level=debug ts=2020-10-02T10:10:42.092268913Z caller=logging.go:66 traceID=a9d4d8a928d8db1 msg="POST /api/prom/api/v1/query_range (200) 1.5s"`))
 	require.True(t, ok)
 	require.Equal(
 		t,
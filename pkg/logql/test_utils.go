@@ -101,7 +101,7 @@ func processStream(in []logproto.Stream, pipeline log.Pipeline) []logproto.Strea
 	for _, stream := range in {
 		for _, e := range stream.Entries {
 			sp := pipeline.ForStream(mustParseLabels(stream.Labels))
-			if l, out, ok := sp.Process([]byte(e.Line)); ok {
+			if l, out, ok := sp.Process(e.Timestamp.UnixNano(), []byte(e.Line)); ok {
 				var s *logproto.Stream
 				var found bool
 				s, found = resByStream[out.String()]
@@ -129,7 +129,7 @@ func processSeries(in []logproto.Stream, ex log.SampleExtractor) []logproto.Seri
 	for _, stream := range in {
 		for _, e := range stream.Entries {
 			exs := ex.ForStream(mustParseLabels(stream.Labels))
-			if f, lbs, ok := exs.Process([]byte(e.Line)); ok {
+			if f, lbs, ok := exs.Process(e.Timestamp.UnixNano(), []byte(e.Line)); ok {
 				var s *logproto.Series
 				var found bool
 				s, found = resBySeries[lbs.String()]
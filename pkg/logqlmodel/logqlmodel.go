@@ -17,6 +17,8 @@ const PackedEntryKey = "_entry"
 type Result struct {
 	Data       parser.Value
 	Statistics stats.Result
+	// Warnings about a result that is valid but incomplete, e.g. truncated.
+	Warnings []string
 }
 
 // Streams is promql.Value
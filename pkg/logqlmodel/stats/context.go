@@ -22,6 +22,7 @@ package stats
 
 import (
 	"context"
+	"sort"
 	"sync"
 	"sync/atomic" //lint:ignore faillint we can't use go.uber.org/atomic with a protobuf struct without wrapping it.
 	"time"
@@ -94,9 +95,14 @@ func (c *Context) Reset() {
 func (c *Context) Result(execTime time.Duration, queueTime time.Duration) Result {
 	r := c.result
 
+	c.mtx.Lock()
+	periods := c.querier.Periods
+	c.mtx.Unlock()
+
 	r.Merge(Result{
 		Querier: Querier{
-			Store: c.store,
+			Store:   c.store,
+			Periods: periods,
 		},
 		Ingester: c.ingester,
 	})
@@ -140,6 +146,11 @@ func (r *Result) ComputeSummary(execTime time.Duration, queueTime time.Duration)
 	if queueTime != 0 {
 		r.Summary.QueueTime = queueTime.Seconds()
 	}
+	r.Summary.TotalParseErrors = r.TotalParseErrors()
+	r.Summary.ParseErrorRate = 0
+	if parsed := r.Querier.Store.Parsing.TotalLinesParsed + r.Ingester.Store.Parsing.TotalLinesParsed; parsed != 0 {
+		r.Summary.ParseErrorRate = float64(r.Summary.TotalParseErrors) / float64(parsed)
+	}
 }
 
 func (s *Store) Merge(m Store) {
@@ -152,10 +163,56 @@ func (s *Store) Merge(m Store) {
 	s.Chunk.DecompressedLines += m.Chunk.DecompressedLines
 	s.Chunk.CompressedBytes += m.Chunk.CompressedBytes
 	s.Chunk.TotalDuplicates += m.Chunk.TotalDuplicates
+	s.Parsing.Merge(m.Parsing)
+	s.TotalChunksQuarantined += m.TotalChunksQuarantined
+	s.TotalChunksBloomFiltered += m.TotalChunksBloomFiltered
+}
+
+func (p *Parsing) Merge(m Parsing) {
+	p.TotalLinesParsed += m.TotalLinesParsed
+	p.JsonParserErrors += m.JsonParserErrors
+	p.LogfmtParserErrors += m.LogfmtParserErrors
+}
+
+// TotalErrors returns the number of lines which failed to be parsed.
+func (p Parsing) TotalErrors() int64 {
+	return p.JsonParserErrors + p.LogfmtParserErrors
 }
 
 func (q *Querier) Merge(m Querier) {
 	q.Store.Merge(m.Store)
+	if len(m.Periods) == 0 {
+		return
+	}
+	// copy the periods, which can be shared with the merged results.
+	periods := make([]Period, len(q.Periods), len(q.Periods)+len(m.Periods))
+	copy(periods, q.Periods)
+	q.Periods = periods
+	for _, p := range m.Periods {
+		q.mergePeriod(p)
+	}
+}
+
+// mergePeriod merges the statistics of a period, the periods being sorted by start.
+func (q *Querier) mergePeriod(p Period) {
+	i := sort.Search(len(q.Periods), func(i int) bool {
+		return q.Periods[i].From >= p.From
+	})
+	if i < len(q.Periods) && q.Periods[i].From == p.From {
+		q.Periods[i].Merge(p)
+		return
+	}
+	q.Periods = append(q.Periods, Period{})
+	copy(q.Periods[i+1:], q.Periods[i:])
+	q.Periods[i] = p
+}
+
+// Merge merges the statistics of the same period.
+func (p *Period) Merge(m Period) {
+	p.TotalChunksRef += m.TotalChunksRef
+	p.TotalChunksDownloaded += m.TotalChunksDownloaded
+	p.ChunksDownloadTime += m.ChunksDownloadTime
+	p.ChunksDownloadedBytes += m.ChunksDownloadedBytes
 }
 
 func (i *Ingester) Merge(m Ingester) {
@@ -198,6 +255,14 @@ func (r Result) TotalChunksRef() int64 {
 	return r.Querier.Store.TotalChunksRef + r.Ingester.Store.TotalChunksRef
 }
 
+func (r Result) TotalChunksQuarantined() int64 {
+	return r.Querier.Store.TotalChunksQuarantined + r.Ingester.Store.TotalChunksQuarantined
+}
+
+func (r Result) TotalChunksBloomFiltered() int64 {
+	return r.Querier.Store.TotalChunksBloomFiltered + r.Ingester.Store.TotalChunksBloomFiltered
+}
+
 func (r Result) TotalDecompressedBytes() int64 {
 	return r.Querier.Store.Chunk.DecompressedBytes + r.Ingester.Store.Chunk.DecompressedBytes
 }
@@ -206,6 +271,18 @@ func (r Result) TotalDecompressedLines() int64 {
 	return r.Querier.Store.Chunk.DecompressedLines + r.Ingester.Store.Chunk.DecompressedLines
 }
 
+func (r Result) TotalParseErrors() int64 {
+	return r.Querier.Store.Parsing.TotalErrors() + r.Ingester.Store.Parsing.TotalErrors()
+}
+
+func (r Result) TotalJSONParserErrors() int64 {
+	return r.Querier.Store.Parsing.JsonParserErrors + r.Ingester.Store.Parsing.JsonParserErrors
+}
+
+func (r Result) TotalLogfmtParserErrors() int64 {
+	return r.Querier.Store.Parsing.LogfmtParserErrors + r.Ingester.Store.Parsing.LogfmtParserErrors
+}
+
 func (c *Context) AddIngesterBatch(size int64) {
 	atomic.AddInt64(&c.ingester.TotalBatches, 1)
 	atomic.AddInt64(&c.ingester.TotalLinesSent, size)
@@ -255,6 +332,33 @@ func (c *Context) AddChunksRef(i int64) {
 	atomic.AddInt64(&c.store.TotalChunksRef, i)
 }
 
+func (c *Context) AddChunksQuarantined(i int64) {
+	atomic.AddInt64(&c.store.TotalChunksQuarantined, i)
+}
+
+func (c *Context) AddChunksBloomFiltered(i int64) {
+	atomic.AddInt64(&c.store.TotalChunksBloomFiltered, i)
+}
+
+// AddPeriod adds the statistics of a period of the schema config, identified by its start.
+func (c *Context) AddPeriod(p Period) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.querier.mergePeriod(p)
+}
+
+func (c *Context) AddParsedLines(i int64) {
+	atomic.AddInt64(&c.store.Parsing.TotalLinesParsed, i)
+}
+
+func (c *Context) AddJSONParserErrors(i int64) {
+	atomic.AddInt64(&c.store.Parsing.JsonParserErrors, i)
+}
+
+func (c *Context) AddLogfmtParserErrors(i int64) {
+	atomic.AddInt64(&c.store.Parsing.LogfmtParserErrors, i)
+}
+
 // Log logs a query statistics result.
 func (r Result) Log(log log.Logger) {
 	_ = log.Log(
@@ -271,9 +375,14 @@ func (r Result) Log(log log.Logger) {
 		"Ingester.DecompressedLines", r.Ingester.Store.Chunk.DecompressedLines,
 		"Ingester.CompressedBytes", humanize.Bytes(uint64(r.Ingester.Store.Chunk.CompressedBytes)),
 		"Ingester.TotalDuplicates", r.Ingester.Store.Chunk.TotalDuplicates,
+		"Ingester.TotalLinesParsed", r.Ingester.Store.Parsing.TotalLinesParsed,
+		"Ingester.JSONParserErrors", r.Ingester.Store.Parsing.JsonParserErrors,
+		"Ingester.LogfmtParserErrors", r.Ingester.Store.Parsing.LogfmtParserErrors,
 
 		"Querier.TotalChunksRef", r.Querier.Store.TotalChunksRef,
 		"Querier.TotalChunksDownloaded", r.Querier.Store.TotalChunksDownloaded,
+		"Querier.TotalChunksQuarantined", r.Querier.Store.TotalChunksQuarantined,
+		"Querier.TotalChunksBloomFiltered", r.Querier.Store.TotalChunksBloomFiltered,
 		"Querier.ChunksDownloadTime", time.Duration(r.Querier.Store.ChunksDownloadTime),
 		"Querier.HeadChunkBytes", humanize.Bytes(uint64(r.Querier.Store.Chunk.HeadChunkBytes)),
 		"Querier.HeadChunkLines", r.Querier.Store.Chunk.HeadChunkLines,
@@ -281,7 +390,22 @@ func (r Result) Log(log log.Logger) {
 		"Querier.DecompressedLines", r.Querier.Store.Chunk.DecompressedLines,
 		"Querier.CompressedBytes", humanize.Bytes(uint64(r.Querier.Store.Chunk.CompressedBytes)),
 		"Querier.TotalDuplicates", r.Querier.Store.Chunk.TotalDuplicates,
+		"Querier.TotalLinesParsed", r.Querier.Store.Parsing.TotalLinesParsed,
+		"Querier.JSONParserErrors", r.Querier.Store.Parsing.JsonParserErrors,
+		"Querier.LogfmtParserErrors", r.Querier.Store.Parsing.LogfmtParserErrors,
 	)
+	for _, p := range r.Querier.Periods {
+		_ = log.Log(
+			"Querier.Period.From", p.From,
+			"Querier.Period.Store", p.Store,
+			"Querier.Period.ObjectStore", p.ObjectStore,
+			"Querier.Period.Schema", p.Schema,
+			"Querier.Period.TotalChunksRef", p.TotalChunksRef,
+			"Querier.Period.TotalChunksDownloaded", p.TotalChunksDownloaded,
+			"Querier.Period.ChunksDownloadTime", time.Duration(p.ChunksDownloadTime),
+			"Querier.Period.ChunksDownloadedBytes", humanize.Bytes(uint64(p.ChunksDownloadedBytes)),
+		)
+	}
 	r.Summary.Log(log)
 }
 
@@ -293,5 +417,7 @@ func (s Summary) Log(log log.Logger) {
 		"Summary.TotalLinesProcessed", s.TotalLinesProcessed,
 		"Summary.ExecTime", ConvertSecondsToNanoseconds(s.ExecTime),
 		"Summary.QueueTime", ConvertSecondsToNanoseconds(s.QueueTime),
+		"Summary.TotalParseErrors", s.TotalParseErrors,
+		"Summary.ParseErrorRate", s.ParseErrorRate,
 	)
 }
@@ -271,3 +271,81 @@ func TestIngester(t *testing.T) {
 		},
 	}, statsCtx.Ingester())
 }
+
+func TestResult_ParseErrors(t *testing.T) {
+	statsCtx, ctx := NewContext(context.Background())
+	statsCtx.AddParsedLines(30)
+	statsCtx.AddJSONParserErrors(3)
+	statsCtx.AddLogfmtParserErrors(1)
+	JoinIngesters(ctx, Ingester{
+		Store: Store{
+			Parsing: Parsing{
+				TotalLinesParsed: 10,
+				JsonParserErrors: 6,
+			},
+		},
+	})
+
+	res := statsCtx.Result(time.Second, 0)
+	require.Equal(t, Parsing{TotalLinesParsed: 30, JsonParserErrors: 3, LogfmtParserErrors: 1}, res.Querier.Store.Parsing)
+	require.Equal(t, Parsing{TotalLinesParsed: 10, JsonParserErrors: 6}, res.Ingester.Store.Parsing)
+	require.Equal(t, int64(9), res.TotalJSONParserErrors())
+	require.Equal(t, int64(1), res.TotalLogfmtParserErrors())
+	require.Equal(t, int64(10), res.Summary.TotalParseErrors)
+	require.Equal(t, 0.25, res.Summary.ParseErrorRate)
+
+	// the rate is computed again when merging results.
+	res.Merge(Result{Querier: Querier{Store: Store{Parsing: Parsing{TotalLinesParsed: 60}}}})
+	require.Equal(t, int64(10), res.Summary.TotalParseErrors)
+	require.Equal(t, 0.1, res.Summary.ParseErrorRate)
+}
+
+func TestResult_ChunksQuarantined(t *testing.T) {
+	statsCtx, _ := NewContext(context.Background())
+	statsCtx.AddChunksQuarantined(2)
+
+	res := statsCtx.Result(time.Second, 0)
+	require.Equal(t, int64(2), res.Querier.Store.TotalChunksQuarantined)
+
+	res.Merge(Result{Querier: Querier{Store: Store{TotalChunksQuarantined: 3}}})
+	require.Equal(t, int64(5), res.TotalChunksQuarantined())
+}
+
+func TestResult_ChunksBloomFiltered(t *testing.T) {
+	statsCtx, _ := NewContext(context.Background())
+	statsCtx.AddChunksBloomFiltered(2)
+
+	res := statsCtx.Result(time.Second, 0)
+	require.Equal(t, int64(2), res.Querier.Store.TotalChunksBloomFiltered)
+
+	res.Merge(Result{Ingester: Ingester{Store: Store{TotalChunksBloomFiltered: 3}}})
+	require.Equal(t, int64(5), res.TotalChunksBloomFiltered())
+}
+
+func TestResult_Periods(t *testing.T) {
+	statsCtx, _ := NewContext(context.Background())
+	statsCtx.AddPeriod(Period{From: "2022-01-01", Schema: "v12", TotalChunksRef: 2, TotalChunksDownloaded: 1, ChunksDownloadedBytes: 100})
+	statsCtx.AddPeriod(Period{From: "2020-01-01", Schema: "v11", TotalChunksRef: 3})
+	statsCtx.AddPeriod(Period{From: "2022-01-01", Schema: "v12", TotalChunksRef: 1, TotalChunksDownloaded: 1, ChunksDownloadedBytes: 50})
+
+	res := statsCtx.Result(time.Second, 0)
+	expected := []Period{
+		{From: "2020-01-01", Schema: "v11", TotalChunksRef: 3},
+		{From: "2022-01-01", Schema: "v12", TotalChunksRef: 3, TotalChunksDownloaded: 2, ChunksDownloadedBytes: 150},
+	}
+	require.Equal(t, expected, res.Querier.Periods)
+
+	// merging doesn't modify the periods of the merged result.
+	other := Result{Querier: Querier{Periods: []Period{
+		{From: "2021-01-01", Schema: "v11", TotalChunksRef: 1},
+		{From: "2022-01-01", Schema: "v12", TotalChunksRef: 1},
+	}}}
+	res.Merge(other)
+	require.Equal(t, []Period{
+		{From: "2020-01-01", Schema: "v11", TotalChunksRef: 3},
+		{From: "2021-01-01", Schema: "v11", TotalChunksRef: 1},
+		{From: "2022-01-01", Schema: "v12", TotalChunksRef: 4, TotalChunksDownloaded: 2, ChunksDownloadedBytes: 150},
+	}, res.Querier.Periods)
+	require.Equal(t, int64(1), other.Querier.Periods[1].TotalChunksRef)
+	require.Equal(t, int64(3), statsCtx.Result(time.Second, 0).Querier.Periods[1].TotalChunksRef)
+}
@@ -106,6 +106,10 @@ type Summary struct {
 	QueueTime float64 `protobuf:"fixed64,6,opt,name=queueTime,proto3" json:"queueTime"`
 	// Total of subqueries created to fulfill this query.
 	Subqueries int64 `protobuf:"varint,7,opt,name=subqueries,proto3" json:"subqueries"`
+	// Total lines which failed to be parsed by the json and logfmt parsers.
+	TotalParseErrors int64 `protobuf:"varint,8,opt,name=totalParseErrors,proto3" json:"totalParseErrors"`
+	// Ratio of the lines processed by the json and logfmt parsers which failed to be parsed.
+	ParseErrorRate float64 `protobuf:"fixed64,9,opt,name=parseErrorRate,proto3" json:"parseErrorRate"`
 }
 
 func (m *Summary) Reset()      { *m = Summary{} }
@@ -189,8 +193,24 @@ func (m *Summary) GetSubqueries() int64 {
 	return 0
 }
 
+func (m *Summary) GetTotalParseErrors() int64 {
+	if m != nil {
+		return m.TotalParseErrors
+	}
+	return 0
+}
+
+func (m *Summary) GetParseErrorRate() float64 {
+	if m != nil {
+		return m.ParseErrorRate
+	}
+	return 0
+}
+
 type Querier struct {
 	Store Store `protobuf:"bytes,1,opt,name=store,proto3" json:"store"`
+	// Statistics of the chunks of the store, by period of the schema config.
+	Periods []Period `protobuf:"bytes,2,rep,name=periods,proto3" json:"periods,omitempty"`
 }
 
 func (m *Querier) Reset()      { *m = Querier{} }
@@ -232,6 +252,121 @@ func (m *Querier) GetStore() Store {
 	return Store{}
 }
 
+func (m *Querier) GetPeriods() []Period {
+	if m != nil {
+		return m.Periods
+	}
+	return nil
+}
+
+// Period contains the statistics of the chunks of a period of the schema config.
+type Period struct {
+	// Start of the period, as YYYY-MM-DD.
+	From string `protobuf:"bytes,1,opt,name=from,proto3" json:"from"`
+	// Index type of the period.
+	Store string `protobuf:"bytes,2,opt,name=store,proto3" json:"store"`
+	// Store of the chunks of the period.
+	ObjectStore string `protobuf:"bytes,3,opt,name=objectStore,proto3" json:"objectStore"`
+	// Schema version of the period.
+	Schema string `protobuf:"bytes,4,opt,name=schema,proto3" json:"schema"`
+	// Total of chunk references fetched from the index of the period.
+	TotalChunksRef int64 `protobuf:"varint,5,opt,name=totalChunksRef,proto3" json:"totalChunksRef"`
+	// Total of chunks of the period downloaded.
+	TotalChunksDownloaded int64 `protobuf:"varint,6,opt,name=totalChunksDownloaded,proto3" json:"totalChunksDownloaded"`
+	// Time spent downloading the chunks of the period in nanoseconds.
+	ChunksDownloadTime int64 `protobuf:"varint,7,opt,name=chunksDownloadTime,proto3" json:"chunksDownloadTime"`
+	// Total bytes of the compressed chunks of the period downloaded.
+	ChunksDownloadedBytes int64 `protobuf:"varint,8,opt,name=chunksDownloadedBytes,proto3" json:"chunksDownloadedBytes"`
+}
+
+func (m *Period) Reset()      { *m = Period{} }
+func (*Period) ProtoMessage() {}
+func (*Period) Descriptor() ([]byte, []int) {
+	return fileDescriptor_6cdfe5d2aea33ebb, []int{3}
+}
+func (m *Period) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *Period) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_Period.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *Period) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Period.Merge(m, src)
+}
+func (m *Period) XXX_Size() int {
+	return m.Size()
+}
+func (m *Period) XXX_DiscardUnknown() {
+	xxx_messageInfo_Period.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Period proto.InternalMessageInfo
+
+func (m *Period) GetFrom() string {
+	if m != nil {
+		return m.From
+	}
+	return ""
+}
+
+func (m *Period) GetStore() string {
+	if m != nil {
+		return m.Store
+	}
+	return ""
+}
+
+func (m *Period) GetObjectStore() string {
+	if m != nil {
+		return m.ObjectStore
+	}
+	return ""
+}
+
+func (m *Period) GetSchema() string {
+	if m != nil {
+		return m.Schema
+	}
+	return ""
+}
+
+func (m *Period) GetTotalChunksRef() int64 {
+	if m != nil {
+		return m.TotalChunksRef
+	}
+	return 0
+}
+
+func (m *Period) GetTotalChunksDownloaded() int64 {
+	if m != nil {
+		return m.TotalChunksDownloaded
+	}
+	return 0
+}
+
+func (m *Period) GetChunksDownloadTime() int64 {
+	if m != nil {
+		return m.ChunksDownloadTime
+	}
+	return 0
+}
+
+func (m *Period) GetChunksDownloadedBytes() int64 {
+	if m != nil {
+		return m.ChunksDownloadedBytes
+	}
+	return 0
+}
+
 type Ingester struct {
 	// Total ingester reached for this query.
 	TotalReached int32 `protobuf:"varint,1,opt,name=totalReached,proto3" json:"totalReached"`
@@ -247,7 +382,7 @@ type Ingester struct {
 func (m *Ingester) Reset()      { *m = Ingester{} }
 func (*Ingester) ProtoMessage() {}
 func (*Ingester) Descriptor() ([]byte, []int) {
-	return fileDescriptor_6cdfe5d2aea33ebb, []int{3}
+	return fileDescriptor_6cdfe5d2aea33ebb, []int{4}
 }
 func (m *Ingester) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -317,14 +452,19 @@ type Store struct {
 	// Total number of chunks fetched.
 	TotalChunksDownloaded int64 `protobuf:"varint,2,opt,name=totalChunksDownloaded,proto3" json:"totalChunksDownloaded"`
 	// Time spent fetching chunks in nanoseconds.
-	ChunksDownloadTime int64 `protobuf:"varint,3,opt,name=chunksDownloadTime,proto3" json:"chunksDownloadTime"`
-	Chunk              Chunk `protobuf:"bytes,4,opt,name=chunk,proto3" json:"chunk"`
+	ChunksDownloadTime int64   `protobuf:"varint,3,opt,name=chunksDownloadTime,proto3" json:"chunksDownloadTime"`
+	Chunk              Chunk   `protobuf:"bytes,4,opt,name=chunk,proto3" json:"chunk"`
+	Parsing            Parsing `protobuf:"bytes,5,opt,name=parsing,proto3" json:"parsing"`
+	// Total of chunk references skipped because their chunks are quarantined as corrupt.
+	TotalChunksQuarantined int64 `protobuf:"varint,6,opt,name=totalChunksQuarantined,proto3" json:"totalChunksQuarantined"`
+	// Total of chunk references skipped because their blooms tell they can't match the line filters.
+	TotalChunksBloomFiltered int64 `protobuf:"varint,7,opt,name=totalChunksBloomFiltered,proto3" json:"totalChunksBloomFiltered"`
 }
 
 func (m *Store) Reset()      { *m = Store{} }
 func (*Store) ProtoMessage() {}
 func (*Store) Descriptor() ([]byte, []int) {
-	return fileDescriptor_6cdfe5d2aea33ebb, []int{4}
+	return fileDescriptor_6cdfe5d2aea33ebb, []int{5}
 }
 func (m *Store) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -381,6 +521,27 @@ func (m *Store) GetChunk() Chunk {
 	return Chunk{}
 }
 
+func (m *Store) GetParsing() Parsing {
+	if m != nil {
+		return m.Parsing
+	}
+	return Parsing{}
+}
+
+func (m *Store) GetTotalChunksQuarantined() int64 {
+	if m != nil {
+		return m.TotalChunksQuarantined
+	}
+	return 0
+}
+
+func (m *Store) GetTotalChunksBloomFiltered() int64 {
+	if m != nil {
+		return m.TotalChunksBloomFiltered
+	}
+	return 0
+}
+
 type Chunk struct {
 	// Total bytes processed but was already in memory. (found in the headchunk)
 	HeadChunkBytes int64 `protobuf:"varint,4,opt,name=headChunkBytes,proto3" json:"headChunkBytes"`
@@ -399,7 +560,7 @@ type Chunk struct {
 func (m *Chunk) Reset()      { *m = Chunk{} }
 func (*Chunk) ProtoMessage() {}
 func (*Chunk) Descriptor() ([]byte, []int) {
-	return fileDescriptor_6cdfe5d2aea33ebb, []int{5}
+	return fileDescriptor_6cdfe5d2aea33ebb, []int{6}
 }
 func (m *Chunk) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -470,65 +631,149 @@ func (m *Chunk) GetTotalDuplicates() int64 {
 	return 0
 }
 
+type Parsing struct {
+	// Total lines processed by the json and logfmt parsers.
+	TotalLinesParsed int64 `protobuf:"varint,1,opt,name=totalLinesParsed,proto3" json:"totalLinesParsed"`
+	// Total lines which failed to be parsed by the json parser. (JSONParserErr)
+	JsonParserErrors int64 `protobuf:"varint,2,opt,name=jsonParserErrors,proto3" json:"jsonParserErrors"`
+	// Total lines which failed to be parsed by the logfmt parser. (LogfmtParserErr)
+	LogfmtParserErrors int64 `protobuf:"varint,3,opt,name=logfmtParserErrors,proto3" json:"logfmtParserErrors"`
+}
+
+func (m *Parsing) Reset()      { *m = Parsing{} }
+func (*Parsing) ProtoMessage() {}
+func (*Parsing) Descriptor() ([]byte, []int) {
+	return fileDescriptor_6cdfe5d2aea33ebb, []int{7}
+}
+func (m *Parsing) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *Parsing) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_Parsing.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *Parsing) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Parsing.Merge(m, src)
+}
+func (m *Parsing) XXX_Size() int {
+	return m.Size()
+}
+func (m *Parsing) XXX_DiscardUnknown() {
+	xxx_messageInfo_Parsing.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Parsing proto.InternalMessageInfo
+
+func (m *Parsing) GetTotalLinesParsed() int64 {
+	if m != nil {
+		return m.TotalLinesParsed
+	}
+	return 0
+}
+
+func (m *Parsing) GetJsonParserErrors() int64 {
+	if m != nil {
+		return m.JsonParserErrors
+	}
+	return 0
+}
+
+func (m *Parsing) GetLogfmtParserErrors() int64 {
+	if m != nil {
+		return m.LogfmtParserErrors
+	}
+	return 0
+}
+
 func init() {
 	proto.RegisterType((*Result)(nil), "stats.Result")
 	proto.RegisterType((*Summary)(nil), "stats.Summary")
 	proto.RegisterType((*Querier)(nil), "stats.Querier")
+	proto.RegisterType((*Period)(nil), "stats.Period")
 	proto.RegisterType((*Ingester)(nil), "stats.Ingester")
 	proto.RegisterType((*Store)(nil), "stats.Store")
 	proto.RegisterType((*Chunk)(nil), "stats.Chunk")
+	proto.RegisterType((*Parsing)(nil), "stats.Parsing")
 }
 
 func init() { proto.RegisterFile("pkg/logqlmodel/stats/stats.proto", fileDescriptor_6cdfe5d2aea33ebb) }
 
 var fileDescriptor_6cdfe5d2aea33ebb = []byte{
-	// 734 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x55, 0x4d, 0x6f, 0xd3, 0x4c,
-	0x10, 0xb6, 0x93, 0xd7, 0x49, 0xba, 0x6f, 0xbf, 0xd8, 0xaa, 0x34, 0x80, 0x64, 0x57, 0x39, 0x55,
-	0x02, 0x12, 0xf1, 0x71, 0x01, 0xd1, 0x8b, 0x5b, 0x21, 0x55, 0x02, 0x51, 0xa6, 0x70, 0xe1, 0xe6,
-	0x38, 0xdb, 0xc4, 0xaa, 0xe3, 0x4d, 0xfd, 0x21, 0xe8, 0x8d, 0x1b, 0x47, 0xf8, 0x19, 0x5c, 0xf8,
-	0x09, 0xdc, 0x7b, 0xec, 0x81, 0x43, 0x4f, 0x16, 0x75, 0x2f, 0xc8, 0xa7, 0xfe, 0x04, 0xe4, 0x59,
-	0xc7, 0x8e, 0x1d, 0x47, 0xe2, 0x92, 0xcc, 0x3c, 0xcf, 0x3c, 0x33, 0xeb, 0x99, 0x59, 0x2d, 0xd9,
-	0x9e, 0x9c, 0x0c, 0x7b, 0x36, 0x1f, 0x9e, 0xda, 0x63, 0x3e, 0x60, 0x76, 0xcf, 0xf3, 0x0d, 0xdf,
-	0x13, 0xbf, 0xdd, 0x89, 0xcb, 0x7d, 0x4e, 0x15, 0x74, 0xee, 0x3e, 0x1c, 0x5a, 0xfe, 0x28, 0xe8,
-	0x77, 0x4d, 0x3e, 0xee, 0x0d, 0xf9, 0x90, 0xf7, 0x90, 0xed, 0x07, 0xc7, 0xe8, 0xa1, 0x83, 0x96,
-	0x50, 0x75, 0x7e, 0xca, 0xa4, 0x01, 0xcc, 0x0b, 0x6c, 0x9f, 0x3e, 0x23, 0x4d, 0x2f, 0x18, 0x8f,
-	0x0d, 0xf7, 0xac, 0x2d, 0x6f, 0xcb, 0x3b, 0xff, 0x3f, 0x5e, 0xed, 0x8a, 0xfc, 0x47, 0x02, 0xd5,
-	0xd7, 0xce, 0x43, 0x4d, 0x8a, 0x43, 0x6d, 0x1a, 0x06, 0x53, 0x23, 0x91, 0x9e, 0x06, 0xcc, 0xb5,
-	0x98, 0xdb, 0xae, 0x15, 0xa4, 0x6f, 0x05, 0x9a, 0x4b, 0xd3, 0x30, 0x98, 0x1a, 0x74, 0x97, 0xb4,
-	0x2c, 0x67, 0xc8, 0x3c, 0x9f, 0xb9, 0xed, 0x3a, 0x6a, 0xd7, 0x52, 0xed, 0x41, 0x0a, 0xeb, 0xeb,
-	0xa9, 0x38, 0x0b, 0x84, 0xcc, 0xea, 0xfc, 0xaa, 0x93, 0x66, 0x7a, 0x3e, 0xfa, 0x9e, 0x6c, 0xf5,
-	0xcf, 0x7c, 0xe6, 0x1d, 0xba, 0xdc, 0x64, 0x9e, 0xc7, 0x06, 0x87, 0xcc, 0x3d, 0x62, 0x26, 0x77,
-	0x06, 0xf8, 0x41, 0x75, 0xfd, 0x5e, 0x1c, 0x6a, 0x8b, 0x42, 0x60, 0x11, 0x91, 0xa4, 0xb5, 0x2d,
-	0xa7, 0x32, 0x6d, 0x2d, 0x4f, 0xbb, 0x20, 0x04, 0x16, 0x11, 0xf4, 0x80, 0x6c, 0xf8, 0xdc, 0x37,
-	0x6c, 0xbd, 0x50, 0x16, 0x7b, 0x50, 0xd7, 0xb7, 0xe2, 0x50, 0xab, 0xa2, 0xa1, 0x0a, 0xcc, 0x52,
-	0xbd, 0x2a, 0x94, 0x6a, 0xff, 0x57, 0x4a, 0x55, 0xa4, 0xa1, 0x0a, 0xa4, 0x3b, 0xa4, 0xc5, 0x3e,
-	0x31, 0xf3, 0x9d, 0x35, 0x66, 0x6d, 0x65, 0x5b, 0xde, 0x91, 0xf5, 0xe5, 0xa4, 0xf3, 0x53, 0x0c,
-	0x32, 0x8b, 0xde, 0x27, 0x4b, 0xa7, 0x01, 0x0b, 0x18, 0x86, 0x36, 0x30, 0x74, 0x25, 0x0e, 0xb5,
-	0x1c, 0x84, 0xdc, 0xa4, 0x5d, 0x42, 0xbc, 0xa0, 0x2f, 0x66, 0xee, 0xb5, 0x9b, 0x78, 0xb0, 0xd5,
-	0x38, 0xd4, 0x66, 0x50, 0x98, 0xb1, 0x3b, 0x2f, 0x48, 0x33, 0x5d, 0x1d, 0xfa, 0x88, 0x28, 0x9e,
-	0xcf, 0x5d, 0x96, 0x2e, 0xe5, 0xf2, 0x74, 0x29, 0x13, 0x4c, 0x5f, 0x49, 0x57, 0x43, 0x84, 0x80,
-	0xf8, 0xeb, 0xfc, 0xa8, 0x91, 0xd6, 0x74, 0x7b, 0xe8, 0x53, 0xb2, 0x8c, 0x1f, 0x0a, 0xcc, 0x30,
-	0x47, 0x4c, 0xac, 0x82, 0xa2, 0xaf, 0xc7, 0xa1, 0x56, 0xc0, 0xa1, 0xe0, 0xd1, 0x97, 0x84, 0xa2,
-	0xbf, 0x37, 0x0a, 0x9c, 0x13, 0xef, 0xb5, 0xe1, 0xa3, 0x56, 0xcc, 0xfb, 0x76, 0x1c, 0x6a, 0x15,
-	0x2c, 0x54, 0x60, 0x59, 0x75, 0x1d, 0x7d, 0x2f, 0x1d, 0x6f, 0x5e, 0x3d, 0xc5, 0xa1, 0xe0, 0xd1,
-	0xe7, 0x64, 0x35, 0x1f, 0xce, 0x11, 0x73, 0xfc, 0x74, 0x96, 0x34, 0x0e, 0xb5, 0x12, 0x03, 0x25,
-	0x3f, 0xef, 0x97, 0xf2, 0xcf, 0xfd, 0xfa, 0x5a, 0x23, 0x0a, 0xf2, 0x59, 0x61, 0xf1, 0x11, 0xc0,
-	0x8e, 0xd3, 0x9b, 0x93, 0x17, 0xce, 0x18, 0x28, 0xf9, 0xf4, 0x0d, 0xd9, 0x9c, 0x41, 0xf6, 0xf9,
-	0x47, 0xc7, 0xe6, 0xc6, 0x20, 0xeb, 0xda, 0x9d, 0x38, 0xd4, 0xaa, 0x03, 0xa0, 0x1a, 0x4e, 0x66,
-	0x60, 0x16, 0x30, 0x5c, 0xb5, 0x7a, 0x3e, 0x83, 0x79, 0x16, 0x2a, 0xb0, 0xa4, 0x23, 0x88, 0x62,
-	0x13, 0xf3, 0x8e, 0x60, 0xbd, 0xbc, 0x23, 0x18, 0x02, 0xe2, 0xaf, 0xf3, 0xa5, 0x4e, 0x14, 0xe4,
-	0x93, 0x8e, 0x8c, 0x98, 0x31, 0x10, 0xc1, 0xc9, 0xb5, 0x9b, 0x1d, 0x45, 0x91, 0x81, 0x92, 0x5f,
-	0xd0, 0xe2, 0x80, 0x70, 0x26, 0x65, 0x2d, 0x32, 0x50, 0xf2, 0xe9, 0x1e, 0xb9, 0x35, 0x60, 0x26,
-	0x1f, 0x4f, 0x5c, 0xbc, 0x98, 0xa2, 0x74, 0x03, 0xe5, 0x9b, 0x71, 0xa8, 0xcd, 0x93, 0x30, 0x0f,
-	0x95, 0x93, 0x88, 0x33, 0x34, 0xab, 0x93, 0x88, 0x63, 0xcc, 0x43, 0x74, 0x97, 0xac, 0x95, 0xcf,
-	0xd1, 0xc2, 0x14, 0x1b, 0x71, 0xa8, 0x95, 0x29, 0x28, 0x03, 0x89, 0x1c, 0xc7, 0xbb, 0x1f, 0x4c,
-	0x6c, 0xcb, 0x34, 0x12, 0xf9, 0x52, 0x2e, 0x2f, 0x51, 0x50, 0x06, 0xf4, 0xfe, 0xc5, 0x95, 0x2a,
-	0x5d, 0x5e, 0xa9, 0xd2, 0xcd, 0x95, 0x2a, 0x7f, 0x8e, 0x54, 0xf9, 0x7b, 0xa4, 0xca, 0xe7, 0x91,
-	0x2a, 0x5f, 0x44, 0xaa, 0xfc, 0x3b, 0x52, 0xe5, 0x3f, 0x91, 0x2a, 0xdd, 0x44, 0xaa, 0xfc, 0xed,
-	0x5a, 0x95, 0x2e, 0xae, 0x55, 0xe9, 0xf2, 0x5a, 0x95, 0x3e, 0x3c, 0x98, 0x7d, 0x05, 0x5d, 0xe3,
-	0xd8, 0x70, 0x8c, 0x9e, 0xcd, 0x4f, 0xac, 0x5e, 0xd5, 0x33, 0xda, 0x6f, 0xe0, 0x5b, 0xf8, 0xe4,
-	0x6f, 0x00, 0x00, 0x00, 0xff, 0xff, 0x4e, 0x99, 0x9a, 0xa3, 0x65, 0x07, 0x00, 0x00,
+	// 1048 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x56, 0x4d, 0x8f, 0xdc, 0x44,
+	0x13, 0x1e, 0xcf, 0xec, 0xec, 0xcc, 0xf4, 0x7e, 0xa6, 0xf3, 0x26, 0x71, 0xf2, 0x46, 0xf6, 0x6a,
+	0x4e, 0x2b, 0x11, 0x76, 0xb4, 0x81, 0x0b, 0x48, 0x91, 0x90, 0x13, 0x22, 0x45, 0x02, 0xb1, 0xa9,
+	0x05, 0x09, 0x71, 0xf3, 0x78, 0x7a, 0x67, 0x9c, 0xb5, 0xdd, 0xb3, 0x6d, 0x5b, 0xb0, 0xb7, 0xdc,
+	0xb8, 0xf2, 0x33, 0xb8, 0xf0, 0x13, 0xb8, 0x71, 0xc8, 0x71, 0x85, 0x84, 0x94, 0x93, 0xc5, 0xce,
+	0x5e, 0x90, 0x4f, 0x39, 0x72, 0x44, 0xae, 0xf6, 0xb7, 0x3d, 0x12, 0x08, 0x2e, 0x76, 0xd7, 0xf3,
+	0x54, 0x55, 0x57, 0x57, 0x57, 0x75, 0x37, 0x39, 0x58, 0x9e, 0xcf, 0x27, 0x0e, 0x9f, 0x5f, 0x38,
+	0x2e, 0x9f, 0x31, 0x67, 0xe2, 0x07, 0x66, 0xe0, 0xcb, 0xef, 0xd1, 0x52, 0xf0, 0x80, 0xd3, 0x3e,
+	0x0a, 0x0f, 0xde, 0x9f, 0xdb, 0xc1, 0x22, 0x9c, 0x1e, 0x59, 0xdc, 0x9d, 0xcc, 0xf9, 0x9c, 0x4f,
+	0x90, 0x9d, 0x86, 0x67, 0x28, 0xa1, 0x80, 0x23, 0x69, 0x35, 0xfe, 0x59, 0x21, 0x9b, 0xc0, 0xfc,
+	0xd0, 0x09, 0xe8, 0x47, 0x64, 0xe0, 0x87, 0xae, 0x6b, 0x8a, 0x4b, 0x55, 0x39, 0x50, 0x0e, 0xb7,
+	0x1e, 0xef, 0x1e, 0x49, 0xff, 0xa7, 0x12, 0x35, 0xf6, 0xde, 0x44, 0x7a, 0x27, 0x8e, 0xf4, 0x4c,
+	0x0d, 0xb2, 0x41, 0x62, 0x7a, 0x11, 0x32, 0x61, 0x33, 0xa1, 0x76, 0x2b, 0xa6, 0x2f, 0x25, 0x5a,
+	0x98, 0xa6, 0x6a, 0x90, 0x0d, 0xe8, 0x13, 0x32, 0xb4, 0xbd, 0x39, 0xf3, 0x03, 0x26, 0xd4, 0x1e,
+	0xda, 0xee, 0xa5, 0xb6, 0x2f, 0x52, 0xd8, 0xd8, 0x4f, 0x8d, 0x73, 0x45, 0xc8, 0x47, 0xe3, 0x5f,
+	0x37, 0xc8, 0x20, 0x8d, 0x8f, 0x7e, 0x45, 0xee, 0x4d, 0x2f, 0x03, 0xe6, 0x9f, 0x08, 0x6e, 0x31,
+	0xdf, 0x67, 0xb3, 0x13, 0x26, 0x4e, 0x99, 0xc5, 0xbd, 0x19, 0x2e, 0xa8, 0x67, 0xfc, 0x3f, 0x8e,
+	0xf4, 0x75, 0x2a, 0xb0, 0x8e, 0x48, 0xdc, 0x3a, 0xb6, 0xd7, 0xea, 0xb6, 0x5b, 0xb8, 0x5d, 0xa3,
+	0x02, 0xeb, 0x08, 0xfa, 0x82, 0xdc, 0x0e, 0x78, 0x60, 0x3a, 0x46, 0x65, 0x5a, 0xcc, 0x41, 0xcf,
+	0xb8, 0x17, 0x47, 0x7a, 0x1b, 0x0d, 0x6d, 0x60, 0xee, 0xea, 0xb3, 0xca, 0x54, 0xea, 0x46, 0xcd,
+	0x55, 0x95, 0x86, 0x36, 0x90, 0x1e, 0x92, 0x21, 0xfb, 0x8e, 0x59, 0x5f, 0xda, 0x2e, 0x53, 0xfb,
+	0x07, 0xca, 0xa1, 0x62, 0x6c, 0x27, 0x99, 0xcf, 0x30, 0xc8, 0x47, 0xf4, 0x3d, 0x32, 0xba, 0x08,
+	0x59, 0xc8, 0x50, 0x75, 0x13, 0x55, 0x77, 0xe2, 0x48, 0x2f, 0x40, 0x28, 0x86, 0xf4, 0x88, 0x10,
+	0x3f, 0x9c, 0xca, 0x3d, 0xf7, 0xd5, 0x01, 0x06, 0xb6, 0x1b, 0x47, 0x7a, 0x09, 0x85, 0xd2, 0x98,
+	0x7e, 0x42, 0xf6, 0x31, 0xba, 0x13, 0x53, 0xf8, 0xec, 0x53, 0x21, 0xb8, 0xf0, 0xd5, 0x21, 0x5a,
+	0xfd, 0x2f, 0x8e, 0xf4, 0x06, 0x07, 0x0d, 0x84, 0x7e, 0x4c, 0x76, 0x97, 0xb9, 0x08, 0x66, 0xc0,
+	0xd4, 0x11, 0xc6, 0x48, 0xe3, 0x48, 0xaf, 0x31, 0x50, 0x93, 0xc7, 0xaf, 0x15, 0x32, 0x48, 0x2b,
+	0x97, 0x1e, 0x93, 0xbe, 0x1f, 0x70, 0xc1, 0xd2, 0x9e, 0xd8, 0xce, 0x7a, 0x22, 0xc1, 0x8c, 0x9d,
+	0xb4, 0x32, 0xa5, 0x0a, 0xc8, 0x1f, 0x35, 0xc8, 0x60, 0xc9, 0x84, 0xcd, 0x67, 0xbe, 0xda, 0x3d,
+	0xe8, 0x1d, 0x6e, 0x3d, 0xde, 0x49, 0x8d, 0x4e, 0x10, 0x35, 0xee, 0xa7, 0x56, 0xb7, 0x52, 0xad,
+	0x47, 0xdc, 0xb5, 0x03, 0xe6, 0x2e, 0x83, 0x4b, 0xc8, 0x0c, 0xc7, 0xbf, 0xf4, 0xc8, 0xa6, 0x54,
+	0xa7, 0x0f, 0xc9, 0xc6, 0x99, 0xe0, 0x2e, 0x06, 0x30, 0x32, 0x86, 0x71, 0xa4, 0xa3, 0x0c, 0xf8,
+	0xa5, 0x7a, 0x16, 0x5f, 0x17, 0xe9, 0x51, 0x23, 0x9a, 0x63, 0xb2, 0xc5, 0xa7, 0xaf, 0x98, 0x15,
+	0x60, 0xc8, 0x58, 0x5f, 0x23, 0x63, 0x2f, 0x8e, 0xf4, 0x32, 0x0c, 0x65, 0x81, 0x8e, 0xc9, 0xa6,
+	0x6f, 0x2d, 0x98, 0x6b, 0x62, 0x09, 0x8d, 0x0c, 0x12, 0x47, 0x7a, 0x8a, 0x40, 0xfa, 0x4f, 0xf2,
+	0x8b, 0x39, 0x7f, 0xba, 0x08, 0xbd, 0x73, 0x1f, 0xd8, 0x19, 0x96, 0x4b, 0x4f, 0xe6, 0xb7, 0xca,
+	0x40, 0x4d, 0xa6, 0x5f, 0x90, 0x3b, 0x25, 0xe4, 0x19, 0xff, 0xd6, 0x73, 0xb8, 0x39, 0x63, 0x33,
+	0x2c, 0xa3, 0x9e, 0x71, 0x3f, 0x8e, 0xf4, 0x76, 0x05, 0x68, 0x87, 0xe9, 0x73, 0x42, 0xad, 0x0a,
+	0x86, 0x45, 0x29, 0xcb, 0xec, 0x6e, 0x1c, 0xe9, 0x2d, 0x2c, 0xb4, 0x60, 0x49, 0x60, 0x56, 0xcd,
+	0x37, 0xb6, 0x9a, 0x3a, 0x2c, 0x02, 0x6b, 0x55, 0x80, 0x76, 0x78, 0xfc, 0x53, 0x97, 0x0c, 0xb3,
+	0x73, 0x8c, 0x7e, 0x48, 0xb6, 0x31, 0x7c, 0x60, 0xa6, 0xb5, 0x60, 0xf2, 0x50, 0xea, 0x1b, 0xfb,
+	0x71, 0xa4, 0x57, 0x70, 0xa8, 0x48, 0xc9, 0xda, 0x4a, 0x8b, 0xfe, 0xdc, 0x0c, 0xd0, 0xb6, 0x5b,
+	0xac, 0xad, 0xc9, 0x42, 0x0b, 0x96, 0xcf, 0x6e, 0xa0, 0xec, 0xa7, 0x07, 0x4d, 0x31, 0x7b, 0x8a,
+	0x43, 0x45, 0xca, 0xb7, 0x19, 0x8f, 0x89, 0x53, 0xe6, 0x05, 0xea, 0x46, 0x6d, 0x9b, 0x73, 0x06,
+	0x6a, 0x72, 0xd1, 0x3a, 0xfd, 0xbf, 0xdb, 0x3a, 0xe3, 0x3f, 0x7b, 0xa4, 0x2f, 0x6b, 0xb0, 0x59,
+	0x5f, 0xca, 0xbf, 0xaf, 0xaf, 0xee, 0x7f, 0x5a, 0x5f, 0xbd, 0x7f, 0x5c, 0x5f, 0xc7, 0xa4, 0x8f,
+	0xa8, 0xba, 0x51, 0xc9, 0x08, 0xce, 0x57, 0x64, 0x04, 0x55, 0x40, 0xfe, 0x92, 0xab, 0x35, 0x39,
+	0x9d, 0x6c, 0x6f, 0xae, 0xf6, 0x2b, 0x57, 0xeb, 0x89, 0x44, 0x8b, 0xab, 0x35, 0x55, 0x83, 0x6c,
+	0x40, 0x81, 0xdc, 0x2d, 0x2d, 0xe7, 0x65, 0x68, 0x0a, 0xd3, 0x0b, 0x6c, 0x2f, 0xef, 0xb3, 0x07,
+	0x71, 0xa4, 0xaf, 0xd1, 0x80, 0x35, 0x38, 0xfd, 0x9a, 0xa8, 0x25, 0xc6, 0x70, 0x38, 0x77, 0x9f,
+	0xdb, 0x4e, 0xc0, 0x04, 0x9b, 0xa5, 0xfd, 0xf6, 0x30, 0x8e, 0xf4, 0xb5, 0x3a, 0xb0, 0x96, 0x19,
+	0x7f, 0xdf, 0x23, 0x7d, 0xc4, 0x93, 0xad, 0x5f, 0x30, 0x73, 0x86, 0x82, 0x6c, 0xbf, 0x52, 0xcd,
+	0x55, 0x19, 0xa8, 0xc9, 0x15, 0x5b, 0xac, 0xc4, 0xf2, 0xb1, 0x54, 0x65, 0xa0, 0x26, 0xd3, 0xa7,
+	0xe4, 0xd6, 0x8c, 0x59, 0xdc, 0x5d, 0x0a, 0xbc, 0x0b, 0xe5, 0xd4, 0x32, 0x55, 0x77, 0x92, 0xe3,
+	0xba, 0x41, 0x42, 0x13, 0xaa, 0x3b, 0x91, 0x31, 0x0c, 0xda, 0x9d, 0xc8, 0x30, 0x9a, 0x10, 0x7d,
+	0x42, 0xf6, 0xea, 0x71, 0xc8, 0x13, 0xe8, 0x76, 0x1c, 0xe9, 0x75, 0x0a, 0xea, 0x40, 0x62, 0x8e,
+	0x69, 0x7e, 0x16, 0x2e, 0x1d, 0xdb, 0x32, 0x13, 0xf3, 0x51, 0x61, 0x5e, 0xa3, 0xa0, 0x0e, 0x8c,
+	0x7f, 0x53, 0xc8, 0x20, 0xad, 0xae, 0xfc, 0x22, 0x96, 0xcf, 0x04, 0x53, 0xf8, 0x2c, 0x7b, 0x4c,
+	0x15, 0x17, 0x71, 0x89, 0x83, 0x06, 0x92, 0x78, 0x78, 0xe5, 0x73, 0x0f, 0x25, 0x91, 0x5e, 0xe5,
+	0xdd, 0xc2, 0x43, 0x9d, 0x83, 0x06, 0x92, 0x74, 0x9f, 0xc3, 0xe7, 0x67, 0x6e, 0x50, 0xf1, 0x51,
+	0xea, 0xbe, 0x26, 0x0b, 0x2d, 0x98, 0x31, 0xbd, 0xba, 0xd6, 0x3a, 0x6f, 0xaf, 0xb5, 0xce, 0xbb,
+	0x6b, 0x4d, 0x79, 0xbd, 0xd2, 0x94, 0x1f, 0x57, 0x9a, 0xf2, 0x66, 0xa5, 0x29, 0x57, 0x2b, 0x4d,
+	0xf9, 0x7d, 0xa5, 0x29, 0x7f, 0xac, 0xb4, 0xce, 0xbb, 0x95, 0xa6, 0xfc, 0x70, 0xa3, 0x75, 0xae,
+	0x6e, 0xb4, 0xce, 0xdb, 0x1b, 0xad, 0xf3, 0xcd, 0xa3, 0xf2, 0x83, 0x5a, 0x98, 0x67, 0xa6, 0x67,
+	0x4e, 0x1c, 0x7e, 0x6e, 0x4f, 0xda, 0x5e, 0xe4, 0xd3, 0x4d, 0x7c, 0x56, 0x7f, 0xf0, 0xd7, 0x00,
+	0x76, 0x3f, 0xd7, 0x36, 0xb0, 0x0b, 0x00, 0x00,
 }
 
 func (this *Result) Equal(that interface{}) bool {
@@ -601,6 +846,12 @@ func (this *Summary) Equal(that interface{}) bool {
 	if this.Subqueries != that1.Subqueries {
 		return false
 	}
+	if this.TotalParseErrors != that1.TotalParseErrors {
+		return false
+	}
+	if this.ParseErrorRate != that1.ParseErrorRate {
+		return false
+	}
 	return true
 }
 func (this *Querier) Equal(that interface{}) bool {
@@ -625,6 +876,59 @@ func (this *Querier) Equal(that interface{}) bool {
 	if !this.Store.Equal(&that1.Store) {
 		return false
 	}
+	if len(this.Periods) != len(that1.Periods) {
+		return false
+	}
+	for i := range this.Periods {
+		if !this.Periods[i].Equal(&that1.Periods[i]) {
+			return false
+		}
+	}
+	return true
+}
+func (this *Period) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*Period)
+	if !ok {
+		that2, ok := that.(Period)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.From != that1.From {
+		return false
+	}
+	if this.Store != that1.Store {
+		return false
+	}
+	if this.ObjectStore != that1.ObjectStore {
+		return false
+	}
+	if this.Schema != that1.Schema {
+		return false
+	}
+	if this.TotalChunksRef != that1.TotalChunksRef {
+		return false
+	}
+	if this.TotalChunksDownloaded != that1.TotalChunksDownloaded {
+		return false
+	}
+	if this.ChunksDownloadTime != that1.ChunksDownloadTime {
+		return false
+	}
+	if this.ChunksDownloadedBytes != that1.ChunksDownloadedBytes {
+		return false
+	}
 	return true
 }
 func (this *Ingester) Equal(that interface{}) bool {
@@ -694,6 +998,15 @@ func (this *Store) Equal(that interface{}) bool {
 	if !this.Chunk.Equal(&that1.Chunk) {
 		return false
 	}
+	if !this.Parsing.Equal(&that1.Parsing) {
+		return false
+	}
+	if this.TotalChunksQuarantined != that1.TotalChunksQuarantined {
+		return false
+	}
+	if this.TotalChunksBloomFiltered != that1.TotalChunksBloomFiltered {
+		return false
+	}
 	return true
 }
 func (this *Chunk) Equal(that interface{}) bool {
@@ -735,6 +1048,36 @@ func (this *Chunk) Equal(that interface{}) bool {
 	}
 	return true
 }
+func (this *Parsing) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*Parsing)
+	if !ok {
+		that2, ok := that.(Parsing)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.TotalLinesParsed != that1.TotalLinesParsed {
+		return false
+	}
+	if this.JsonParserErrors != that1.JsonParserErrors {
+		return false
+	}
+	if this.LogfmtParserErrors != that1.LogfmtParserErrors {
+		return false
+	}
+	return true
+}
 func (this *Result) GoString() string {
 	if this == nil {
 		return "nil"
@@ -751,7 +1094,7 @@ func (this *Summary) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 11)
+	s := make([]string, 0, 13)
 	s = append(s, "&stats.Summary{")
 	s = append(s, "BytesProcessedPerSecond: "+fmt.Sprintf("%#v", this.BytesProcessedPerSecond)+",\n")
 	s = append(s, "LinesProcessedPerSecond: "+fmt.Sprintf("%#v", this.LinesProcessedPerSecond)+",\n")
@@ -760,6 +1103,8 @@ func (this *Summary) GoString() string {
 	s = append(s, "ExecTime: "+fmt.Sprintf("%#v", this.ExecTime)+",\n")
 	s = append(s, "QueueTime: "+fmt.Sprintf("%#v", this.QueueTime)+",\n")
 	s = append(s, "Subqueries: "+fmt.Sprintf("%#v", this.Subqueries)+",\n")
+	s = append(s, "TotalParseErrors: "+fmt.Sprintf("%#v", this.TotalParseErrors)+",\n")
+	s = append(s, "ParseErrorRate: "+fmt.Sprintf("%#v", this.ParseErrorRate)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -767,9 +1112,33 @@ func (this *Querier) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 5)
+	s := make([]string, 0, 6)
 	s = append(s, "&stats.Querier{")
 	s = append(s, "Store: "+strings.Replace(this.Store.GoString(), `&`, ``, 1)+",\n")
+	if this.Periods != nil {
+		vs := make([]*Period, len(this.Periods))
+		for i := range vs {
+			vs[i] = &this.Periods[i]
+		}
+		s = append(s, "Periods: "+fmt.Sprintf("%#v", vs)+",\n")
+	}
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *Period) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 12)
+	s = append(s, "&stats.Period{")
+	s = append(s, "From: "+fmt.Sprintf("%#v", this.From)+",\n")
+	s = append(s, "Store: "+fmt.Sprintf("%#v", this.Store)+",\n")
+	s = append(s, "ObjectStore: "+fmt.Sprintf("%#v", this.ObjectStore)+",\n")
+	s = append(s, "Schema: "+fmt.Sprintf("%#v", this.Schema)+",\n")
+	s = append(s, "TotalChunksRef: "+fmt.Sprintf("%#v", this.TotalChunksRef)+",\n")
+	s = append(s, "TotalChunksDownloaded: "+fmt.Sprintf("%#v", this.TotalChunksDownloaded)+",\n")
+	s = append(s, "ChunksDownloadTime: "+fmt.Sprintf("%#v", this.ChunksDownloadTime)+",\n")
+	s = append(s, "ChunksDownloadedBytes: "+fmt.Sprintf("%#v", this.ChunksDownloadedBytes)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -791,12 +1160,15 @@ func (this *Store) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 8)
+	s := make([]string, 0, 10)
 	s = append(s, "&stats.Store{")
 	s = append(s, "TotalChunksRef: "+fmt.Sprintf("%#v", this.TotalChunksRef)+",\n")
 	s = append(s, "TotalChunksDownloaded: "+fmt.Sprintf("%#v", this.TotalChunksDownloaded)+",\n")
 	s = append(s, "ChunksDownloadTime: "+fmt.Sprintf("%#v", this.ChunksDownloadTime)+",\n")
 	s = append(s, "Chunk: "+strings.Replace(this.Chunk.GoString(), `&`, ``, 1)+",\n")
+	s = append(s, "Parsing: "+strings.Replace(this.Parsing.GoString(), `&`, ``, 1)+",\n")
+	s = append(s, "TotalChunksQuarantined: "+fmt.Sprintf("%#v", this.TotalChunksQuarantined)+",\n")
+	s = append(s, "TotalChunksBloomFiltered: "+fmt.Sprintf("%#v", this.TotalChunksBloomFiltered)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -815,6 +1187,18 @@ func (this *Chunk) GoString() string {
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
+func (this *Parsing) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 7)
+	s = append(s, "&stats.Parsing{")
+	s = append(s, "TotalLinesParsed: "+fmt.Sprintf("%#v", this.TotalLinesParsed)+",\n")
+	s = append(s, "JsonParserErrors: "+fmt.Sprintf("%#v", this.JsonParserErrors)+",\n")
+	s = append(s, "LogfmtParserErrors: "+fmt.Sprintf("%#v", this.LogfmtParserErrors)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
 func valueToGoStringStats(v interface{}, typ string) string {
 	rv := reflect.ValueOf(v)
 	if rv.IsNil() {
@@ -896,6 +1280,17 @@ func (m *Summary) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if m.ParseErrorRate != 0 {
+		i -= 8
+		encoding_binary.LittleEndian.PutUint64(dAtA[i:], uint64(math.Float64bits(float64(m.ParseErrorRate))))
+		i--
+		dAtA[i] = 0x49
+	}
+	if m.TotalParseErrors != 0 {
+		i = encodeVarintStats(dAtA, i, uint64(m.TotalParseErrors))
+		i--
+		dAtA[i] = 0x40
+	}
 	if m.Subqueries != 0 {
 		i = encodeVarintStats(dAtA, i, uint64(m.Subqueries))
 		i--
@@ -956,6 +1351,20 @@ func (m *Querier) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if len(m.Periods) > 0 {
+		for iNdEx := len(m.Periods) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Periods[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintStats(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x12
+		}
+	}
 	{
 		size, err := m.Store.MarshalToSizedBuffer(dAtA[:i])
 		if err != nil {
@@ -969,7 +1378,7 @@ func (m *Querier) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	return len(dAtA) - i, nil
 }
 
-func (m *Ingester) Marshal() (dAtA []byte, err error) {
+func (m *Period) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
 	n, err := m.MarshalToSizedBuffer(dAtA[:size])
@@ -979,33 +1388,104 @@ func (m *Ingester) Marshal() (dAtA []byte, err error) {
 	return dAtA[:n], nil
 }
 
-func (m *Ingester) MarshalTo(dAtA []byte) (int, error) {
+func (m *Period) MarshalTo(dAtA []byte) (int, error) {
 	size := m.Size()
 	return m.MarshalToSizedBuffer(dAtA[:size])
 }
 
-func (m *Ingester) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+func (m *Period) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	i := len(dAtA)
 	_ = i
 	var l int
 	_ = l
-	{
-		size, err := m.Store.MarshalToSizedBuffer(dAtA[:i])
-		if err != nil {
-			return 0, err
-		}
-		i -= size
-		i = encodeVarintStats(dAtA, i, uint64(size))
-	}
-	i--
-	dAtA[i] = 0x2a
-	if m.TotalLinesSent != 0 {
-		i = encodeVarintStats(dAtA, i, uint64(m.TotalLinesSent))
+	if m.ChunksDownloadedBytes != 0 {
+		i = encodeVarintStats(dAtA, i, uint64(m.ChunksDownloadedBytes))
 		i--
-		dAtA[i] = 0x20
+		dAtA[i] = 0x40
 	}
-	if m.TotalBatches != 0 {
-		i = encodeVarintStats(dAtA, i, uint64(m.TotalBatches))
+	if m.ChunksDownloadTime != 0 {
+		i = encodeVarintStats(dAtA, i, uint64(m.ChunksDownloadTime))
+		i--
+		dAtA[i] = 0x38
+	}
+	if m.TotalChunksDownloaded != 0 {
+		i = encodeVarintStats(dAtA, i, uint64(m.TotalChunksDownloaded))
+		i--
+		dAtA[i] = 0x30
+	}
+	if m.TotalChunksRef != 0 {
+		i = encodeVarintStats(dAtA, i, uint64(m.TotalChunksRef))
+		i--
+		dAtA[i] = 0x28
+	}
+	if len(m.Schema) > 0 {
+		i -= len(m.Schema)
+		copy(dAtA[i:], m.Schema)
+		i = encodeVarintStats(dAtA, i, uint64(len(m.Schema)))
+		i--
+		dAtA[i] = 0x22
+	}
+	if len(m.ObjectStore) > 0 {
+		i -= len(m.ObjectStore)
+		copy(dAtA[i:], m.ObjectStore)
+		i = encodeVarintStats(dAtA, i, uint64(len(m.ObjectStore)))
+		i--
+		dAtA[i] = 0x1a
+	}
+	if len(m.Store) > 0 {
+		i -= len(m.Store)
+		copy(dAtA[i:], m.Store)
+		i = encodeVarintStats(dAtA, i, uint64(len(m.Store)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if len(m.From) > 0 {
+		i -= len(m.From)
+		copy(dAtA[i:], m.From)
+		i = encodeVarintStats(dAtA, i, uint64(len(m.From)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *Ingester) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *Ingester) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *Ingester) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	{
+		size, err := m.Store.MarshalToSizedBuffer(dAtA[:i])
+		if err != nil {
+			return 0, err
+		}
+		i -= size
+		i = encodeVarintStats(dAtA, i, uint64(size))
+	}
+	i--
+	dAtA[i] = 0x2a
+	if m.TotalLinesSent != 0 {
+		i = encodeVarintStats(dAtA, i, uint64(m.TotalLinesSent))
+		i--
+		dAtA[i] = 0x20
+	}
+	if m.TotalBatches != 0 {
+		i = encodeVarintStats(dAtA, i, uint64(m.TotalBatches))
 		i--
 		dAtA[i] = 0x18
 	}
@@ -1042,6 +1522,26 @@ func (m *Store) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if m.TotalChunksBloomFiltered != 0 {
+		i = encodeVarintStats(dAtA, i, uint64(m.TotalChunksBloomFiltered))
+		i--
+		dAtA[i] = 0x38
+	}
+	if m.TotalChunksQuarantined != 0 {
+		i = encodeVarintStats(dAtA, i, uint64(m.TotalChunksQuarantined))
+		i--
+		dAtA[i] = 0x30
+	}
+	{
+		size, err := m.Parsing.MarshalToSizedBuffer(dAtA[:i])
+		if err != nil {
+			return 0, err
+		}
+		i -= size
+		i = encodeVarintStats(dAtA, i, uint64(size))
+	}
+	i--
+	dAtA[i] = 0x2a
 	{
 		size, err := m.Chunk.MarshalToSizedBuffer(dAtA[:i])
 		if err != nil {
@@ -1123,6 +1623,44 @@ func (m *Chunk) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	return len(dAtA) - i, nil
 }
 
+func (m *Parsing) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *Parsing) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *Parsing) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.LogfmtParserErrors != 0 {
+		i = encodeVarintStats(dAtA, i, uint64(m.LogfmtParserErrors))
+		i--
+		dAtA[i] = 0x18
+	}
+	if m.JsonParserErrors != 0 {
+		i = encodeVarintStats(dAtA, i, uint64(m.JsonParserErrors))
+		i--
+		dAtA[i] = 0x10
+	}
+	if m.TotalLinesParsed != 0 {
+		i = encodeVarintStats(dAtA, i, uint64(m.TotalLinesParsed))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
 func encodeVarintStats(dAtA []byte, offset int, v uint64) int {
 	offset -= sovStats(v)
 	base := offset
@@ -1176,6 +1714,12 @@ func (m *Summary) Size() (n int) {
 	if m.Subqueries != 0 {
 		n += 1 + sovStats(uint64(m.Subqueries))
 	}
+	if m.TotalParseErrors != 0 {
+		n += 1 + sovStats(uint64(m.TotalParseErrors))
+	}
+	if m.ParseErrorRate != 0 {
+		n += 9
+	}
 	return n
 }
 
@@ -1187,6 +1731,49 @@ func (m *Querier) Size() (n int) {
 	_ = l
 	l = m.Store.Size()
 	n += 1 + l + sovStats(uint64(l))
+	if len(m.Periods) > 0 {
+		for _, e := range m.Periods {
+			l = e.Size()
+			n += 1 + l + sovStats(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *Period) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.From)
+	if l > 0 {
+		n += 1 + l + sovStats(uint64(l))
+	}
+	l = len(m.Store)
+	if l > 0 {
+		n += 1 + l + sovStats(uint64(l))
+	}
+	l = len(m.ObjectStore)
+	if l > 0 {
+		n += 1 + l + sovStats(uint64(l))
+	}
+	l = len(m.Schema)
+	if l > 0 {
+		n += 1 + l + sovStats(uint64(l))
+	}
+	if m.TotalChunksRef != 0 {
+		n += 1 + sovStats(uint64(m.TotalChunksRef))
+	}
+	if m.TotalChunksDownloaded != 0 {
+		n += 1 + sovStats(uint64(m.TotalChunksDownloaded))
+	}
+	if m.ChunksDownloadTime != 0 {
+		n += 1 + sovStats(uint64(m.ChunksDownloadTime))
+	}
+	if m.ChunksDownloadedBytes != 0 {
+		n += 1 + sovStats(uint64(m.ChunksDownloadedBytes))
+	}
 	return n
 }
 
@@ -1230,6 +1817,14 @@ func (m *Store) Size() (n int) {
 	}
 	l = m.Chunk.Size()
 	n += 1 + l + sovStats(uint64(l))
+	l = m.Parsing.Size()
+	n += 1 + l + sovStats(uint64(l))
+	if m.TotalChunksQuarantined != 0 {
+		n += 1 + sovStats(uint64(m.TotalChunksQuarantined))
+	}
+	if m.TotalChunksBloomFiltered != 0 {
+		n += 1 + sovStats(uint64(m.TotalChunksBloomFiltered))
+	}
 	return n
 }
 
@@ -1260,6 +1855,24 @@ func (m *Chunk) Size() (n int) {
 	return n
 }
 
+func (m *Parsing) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.TotalLinesParsed != 0 {
+		n += 1 + sovStats(uint64(m.TotalLinesParsed))
+	}
+	if m.JsonParserErrors != 0 {
+		n += 1 + sovStats(uint64(m.JsonParserErrors))
+	}
+	if m.LogfmtParserErrors != 0 {
+		n += 1 + sovStats(uint64(m.LogfmtParserErrors))
+	}
+	return n
+}
+
 func sovStats(x uint64) (n int) {
 	return (math_bits.Len64(x|1) + 6) / 7
 }
@@ -1290,6 +1903,8 @@ func (this *Summary) String() string {
 		`ExecTime:` + fmt.Sprintf("%v", this.ExecTime) + `,`,
 		`QueueTime:` + fmt.Sprintf("%v", this.QueueTime) + `,`,
 		`Subqueries:` + fmt.Sprintf("%v", this.Subqueries) + `,`,
+		`TotalParseErrors:` + fmt.Sprintf("%v", this.TotalParseErrors) + `,`,
+		`ParseErrorRate:` + fmt.Sprintf("%v", this.ParseErrorRate) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -1298,8 +1913,31 @@ func (this *Querier) String() string {
 	if this == nil {
 		return "nil"
 	}
+	repeatedStringForPeriods := "[]Period{"
+	for _, f := range this.Periods {
+		repeatedStringForPeriods += strings.Replace(strings.Replace(f.String(), "Period", "Period", 1), `&`, ``, 1) + ","
+	}
+	repeatedStringForPeriods += "}"
 	s := strings.Join([]string{`&Querier{`,
 		`Store:` + strings.Replace(strings.Replace(this.Store.String(), "Store", "Store", 1), `&`, ``, 1) + `,`,
+		`Periods:` + repeatedStringForPeriods + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *Period) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&Period{`,
+		`From:` + fmt.Sprintf("%v", this.From) + `,`,
+		`Store:` + fmt.Sprintf("%v", this.Store) + `,`,
+		`ObjectStore:` + fmt.Sprintf("%v", this.ObjectStore) + `,`,
+		`Schema:` + fmt.Sprintf("%v", this.Schema) + `,`,
+		`TotalChunksRef:` + fmt.Sprintf("%v", this.TotalChunksRef) + `,`,
+		`TotalChunksDownloaded:` + fmt.Sprintf("%v", this.TotalChunksDownloaded) + `,`,
+		`ChunksDownloadTime:` + fmt.Sprintf("%v", this.ChunksDownloadTime) + `,`,
+		`ChunksDownloadedBytes:` + fmt.Sprintf("%v", this.ChunksDownloadedBytes) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -1327,6 +1965,9 @@ func (this *Store) String() string {
 		`TotalChunksDownloaded:` + fmt.Sprintf("%v", this.TotalChunksDownloaded) + `,`,
 		`ChunksDownloadTime:` + fmt.Sprintf("%v", this.ChunksDownloadTime) + `,`,
 		`Chunk:` + strings.Replace(strings.Replace(this.Chunk.String(), "Chunk", "Chunk", 1), `&`, ``, 1) + `,`,
+		`Parsing:` + strings.Replace(strings.Replace(this.Parsing.String(), "Parsing", "Parsing", 1), `&`, ``, 1) + `,`,
+		`TotalChunksQuarantined:` + fmt.Sprintf("%v", this.TotalChunksQuarantined) + `,`,
+		`TotalChunksBloomFiltered:` + fmt.Sprintf("%v", this.TotalChunksBloomFiltered) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -1346,6 +1987,18 @@ func (this *Chunk) String() string {
 	}, "")
 	return s
 }
+func (this *Parsing) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&Parsing{`,
+		`TotalLinesParsed:` + fmt.Sprintf("%v", this.TotalLinesParsed) + `,`,
+		`JsonParserErrors:` + fmt.Sprintf("%v", this.JsonParserErrors) + `,`,
+		`LogfmtParserErrors:` + fmt.Sprintf("%v", this.LogfmtParserErrors) + `,`,
+		`}`,
+	}, "")
+	return s
+}
 func valueToStringStats(v interface{}) string {
 	rv := reflect.ValueOf(v)
 	if rv.IsNil() {
@@ -1652,6 +2305,36 @@ func (m *Summary) Unmarshal(dAtA []byte) error {
 					break
 				}
 			}
+		case 8:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TotalParseErrors", wireType)
+			}
+			m.TotalParseErrors = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStats
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.TotalParseErrors |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 9:
+			if wireType != 1 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ParseErrorRate", wireType)
+			}
+			var v uint64
+			if (iNdEx + 8) > l {
+				return io.ErrUnexpectedEOF
+			}
+			v = uint64(encoding_binary.LittleEndian.Uint64(dAtA[iNdEx:]))
+			iNdEx += 8
+			m.ParseErrorRate = float64(math.Float64frombits(v))
 		default:
 			iNdEx = preIndex
 			skippy, err := skipStats(dAtA[iNdEx:])
@@ -1738,6 +2421,40 @@ func (m *Querier) Unmarshal(dAtA []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Periods", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStats
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthStats
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthStats
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Periods = append(m.Periods, Period{})
+			if err := m.Periods[len(m.Periods)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipStats(dAtA[iNdEx:])
@@ -1762,7 +2479,7 @@ func (m *Querier) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *Ingester) Unmarshal(dAtA []byte) error {
+func (m *Period) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -1785,17 +2502,17 @@ func (m *Ingester) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: Ingester: wiretype end group for non-group")
+			return fmt.Errorf("proto: Period: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: Ingester: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: Period: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field TotalReached", wireType)
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field From", wireType)
 			}
-			m.TotalReached = 0
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowStats
@@ -1805,16 +2522,29 @@ func (m *Ingester) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.TotalReached |= int32(b&0x7F) << shift
+				stringLen |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthStats
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthStats
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.From = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
 		case 2:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field TotalChunksMatched", wireType)
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Store", wireType)
 			}
-			m.TotalChunksMatched = 0
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowStats
@@ -1824,22 +2554,266 @@ func (m *Ingester) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.TotalChunksMatched |= int64(b&0x7F) << shift
+				stringLen |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		case 3:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field TotalBatches", wireType)
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthStats
 			}
-			m.TotalBatches = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowStats
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthStats
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Store = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ObjectStore", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStats
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthStats
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthStats
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ObjectStore = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Schema", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStats
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthStats
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthStats
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Schema = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TotalChunksRef", wireType)
+			}
+			m.TotalChunksRef = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStats
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.TotalChunksRef |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 6:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TotalChunksDownloaded", wireType)
+			}
+			m.TotalChunksDownloaded = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStats
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.TotalChunksDownloaded |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 7:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ChunksDownloadTime", wireType)
+			}
+			m.ChunksDownloadTime = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStats
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ChunksDownloadTime |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 8:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ChunksDownloadedBytes", wireType)
+			}
+			m.ChunksDownloadedBytes = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStats
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ChunksDownloadedBytes |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipStats(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthStats
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthStats
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *Ingester) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowStats
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Ingester: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: Ingester: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TotalReached", wireType)
+			}
+			m.TotalReached = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStats
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.TotalReached |= int32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TotalChunksMatched", wireType)
+			}
+			m.TotalChunksMatched = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStats
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.TotalChunksMatched |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TotalBatches", wireType)
+			}
+			m.TotalBatches = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStats
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
@@ -2043,6 +3017,77 @@ func (m *Store) Unmarshal(dAtA []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Parsing", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStats
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthStats
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthStats
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if err := m.Parsing.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 6:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TotalChunksQuarantined", wireType)
+			}
+			m.TotalChunksQuarantined = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStats
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.TotalChunksQuarantined |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 7:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TotalChunksBloomFiltered", wireType)
+			}
+			m.TotalChunksBloomFiltered = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStats
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.TotalChunksBloomFiltered |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipStats(dAtA[iNdEx:])
@@ -2234,6 +3279,116 @@ func (m *Chunk) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
+func (m *Parsing) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowStats
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Parsing: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: Parsing: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TotalLinesParsed", wireType)
+			}
+			m.TotalLinesParsed = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStats
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.TotalLinesParsed |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field JsonParserErrors", wireType)
+			}
+			m.JsonParserErrors = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStats
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.JsonParserErrors |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field LogfmtParserErrors", wireType)
+			}
+			m.LogfmtParserErrors = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStats
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.LogfmtParserErrors |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipStats(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthStats
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthStats
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
 func skipStats(dAtA []byte) (n int, err error) {
 	l := len(dAtA)
 	iNdEx := 0
@@ -6,11 +6,14 @@ import (
 	"github.com/grafana/dskit/flagext"
 	"github.com/grafana/dskit/netutil"
 
+	"github.com/grafana/loki/pkg/storage/chunk/alibaba"
 	"github.com/grafana/loki/pkg/storage/chunk/aws"
 	"github.com/grafana/loki/pkg/storage/chunk/azure"
+	"github.com/grafana/loki/pkg/storage/chunk/baidubce"
 	"github.com/grafana/loki/pkg/storage/chunk/gcp"
 	"github.com/grafana/loki/pkg/storage/chunk/hedging"
 	"github.com/grafana/loki/pkg/storage/chunk/openstack"
+	"github.com/grafana/loki/pkg/storage/chunk/tencent"
 	"github.com/grafana/loki/pkg/util"
 
 	util_log "github.com/grafana/loki/pkg/util/log"
@@ -54,12 +57,15 @@ func (c *Config) RegisterFlags(_ *flag.FlagSet) {
 }
 
 type Storage struct {
-	S3       aws.S3Config            `yaml:"s3"`
-	GCS      gcp.GCSConfig           `yaml:"gcs"`
-	Azure    azure.BlobStorageConfig `yaml:"azure"`
-	Swift    openstack.SwiftConfig   `yaml:"swift"`
-	FSConfig FilesystemConfig        `yaml:"filesystem"`
-	Hedging  hedging.Config          `yaml:"hedging"`
+	S3           aws.S3Config              `yaml:"s3"`
+	GCS          gcp.GCSConfig             `yaml:"gcs"`
+	Azure        azure.BlobStorageConfig   `yaml:"azure"`
+	Swift        openstack.SwiftConfig     `yaml:"swift"`
+	AlibabaCloud alibaba.OssConfig         `yaml:"alibabacloud"`
+	TencentCloud tencent.CosConfig         `yaml:"tencentcloud"`
+	BOS          baidubce.BOSStorageConfig `yaml:"bos"`
+	FSConfig     FilesystemConfig          `yaml:"filesystem"`
+	Hedging      hedging.Config            `yaml:"hedging"`
 }
 
 func (s *Storage) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
@@ -67,6 +73,9 @@ func (s *Storage) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
 	s.GCS.RegisterFlagsWithPrefix(prefix+".gcs", f)
 	s.Azure.RegisterFlagsWithPrefix(prefix+".azure", f)
 	s.Swift.RegisterFlagsWithPrefix(prefix+".swift", f)
+	s.AlibabaCloud.RegisterFlagsWithPrefix(prefix+".alibabacloud", f)
+	s.TencentCloud.RegisterFlagsWithPrefix(prefix+".tencentcloud", f)
+	s.BOS.RegisterFlagsWithPrefix(prefix+".bos", f)
 	s.FSConfig.RegisterFlagsWithPrefix(prefix+".filesystem", f)
 	s.Hedging.RegisterFlagsWithPrefix(prefix, f)
 }
@@ -1,11 +1,18 @@
 package loki
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"reflect"
 
 	"gopkg.in/yaml.v2"
+
+	loki_storage "github.com/grafana/loki/pkg/storage"
+	"github.com/grafana/loki/pkg/storage/chunk"
 )
 
 func yamlMarshalUnmarshal(in interface{}) (map[interface{}]interface{}, error) {
@@ -113,6 +120,71 @@ func configHandler(actualCfg interface{}, defaultCfg interface{}) http.HandlerFu
 	}
 }
 
+// schemaConfigValidationError is an invalid setting reported by the schema config validation endpoint.
+type schemaConfigValidationError struct {
+	Tenant string `json:"tenant,omitempty"`
+	// Period is the index of the period config, -1 for the errors of the whole schema config.
+	Period int    `json:"period"`
+	From   string `json:"from,omitempty"`
+	Field  string `json:"field,omitempty"`
+	Reason string `json:"reason"`
+}
+
+type schemaConfigValidationResponse struct {
+	Status string                        `json:"status"`
+	Errors []schemaConfigValidationError `json:"errors,omitempty"`
+}
+
+// schemaConfigValidationHandler validates the schema config in the request body, in the format of
+// the schema config file, and reports all its invalid settings at once.
+func schemaConfigValidationHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var cfg loki_storage.SchemaConfig
+	decoder := yaml.NewDecoder(bytes.NewReader(body))
+	decoder.SetStrict(true)
+	if err := decoder.Decode(&cfg.SchemaConfig); err != nil {
+		http.Error(w, fmt.Sprintf("failed to parse the schema config: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	resp := schemaConfigValidationResponse{Status: "success"}
+	status := http.StatusOK
+	if err := cfg.Validate(); err != nil {
+		resp.Status, status = "error", http.StatusBadRequest
+
+		var schemaErr *chunk.SchemaConfigError
+		if !errors.As(err, &schemaErr) {
+			schemaErr = &chunk.SchemaConfigError{Errors: []*chunk.PeriodConfigError{{Index: -1, Err: err}}}
+		}
+		for _, e := range schemaErr.Errors {
+			validationErr := schemaConfigValidationError{
+				Tenant: e.Tenant,
+				Period: e.Index,
+				Field:  e.Field,
+				Reason: e.Err.Error(),
+			}
+			if e.Index >= 0 {
+				validationErr.From = e.From.String()
+			}
+			resp.Errors = append(resp.Errors, validationErr)
+		}
+	}
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(data)
+}
+
 // writeYAMLResponse writes some YAML as a HTTP response.
 func writeYAMLResponse(w http.ResponseWriter, v interface{}) {
 	// There is not standardised content-type for YAML, text/plain ensures the
@@ -3,6 +3,7 @@ package loki
 import (
 	"io/ioutil"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -114,3 +115,73 @@ func TestConfigDiffHandler(t *testing.T) {
 	}
 
 }
+
+func TestSchemaConfigValidationHandler(t *testing.T) {
+	for _, tc := range []struct {
+		name               string
+		body               string
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			name: "valid",
+			body: `
+configs:
+- from: 2020-01-01
+  store: boltdb-shipper
+  object_store: filesystem
+  schema: v11
+  index:
+    prefix: index_
+    period: 24h
+`,
+			expectedStatusCode: 200,
+			expectedBody:       `{"status":"success"}`,
+		},
+		{
+			name: "all errors",
+			body: `
+configs:
+- from: 2020-01-02
+  store: aws-dynamo
+  schema: v11
+  index:
+    prefix: index_
+    period: 24h
+- from: 2020-01-01
+  store: boltdb
+  schema: v8
+`,
+			expectedStatusCode: 400,
+			expectedBody: `{"status":"error","errors":[` +
+				`{"period":0,"from":"2020-01-02","field":"chunks.prefix","reason":"schema config for chunks is missing the 'prefix' setting"},` +
+				`{"period":1,"from":"2020-01-01","field":"schema","reason":"invalid schema version"},` +
+				`{"period":1,"from":"2020-01-01","field":"from","reason":"from time in schemas must be distinct and in increasing order"}]}`,
+		},
+		{
+			name:               "no period config",
+			body:               `configs: []`,
+			expectedStatusCode: 400,
+			expectedBody:       `{"status":"error","errors":[{"period":-1,"reason":"must specify at least one schema configuration"}]}`,
+		},
+		{
+			name:               "unknown field",
+			body:               `foo: bar`,
+			expectedStatusCode: 400,
+			expectedBody:       "failed to parse the schema config: yaml: unmarshal errors:\n  line 1: field foo not found in type chunk.SchemaConfig\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "http://test.com/config/schema/validate", strings.NewReader(tc.body))
+			w := httptest.NewRecorder()
+
+			schemaConfigValidationHandler(w, req)
+			resp := w.Result()
+			assert.Equal(t, tc.expectedStatusCode, resp.StatusCode)
+
+			body, err := ioutil.ReadAll(resp.Body)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedBody, string(body))
+		})
+	}
+}
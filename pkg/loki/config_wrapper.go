@@ -73,11 +73,17 @@ func (c *ConfigWrapper) ApplyDynamicConfig() cfg.Source {
 			return errors.New("dst is not a Loki ConfigWrapper")
 		}
 
+		// the period configs loaded from the schema config file are used to set the defaults below.
+		if err := r.SchemaConfig.LoadFile(); err != nil {
+			return err
+		}
+
 		// If nobody has defined any frontend address, scheduler address, or downstream url
 		// we can default to using the query scheduler ring for scheduler discovery.
 		if r.Worker.FrontendAddress == "" &&
 			r.Worker.SchedulerAddress == "" &&
 			r.Frontend.DownstreamURL == "" &&
+			len(r.Frontend.Downstream.URLs) == 0 &&
 			r.Frontend.FrontendV2.SchedulerAddress == "" {
 			r.QueryScheduler.UseSchedulerRing = true
 		}
@@ -102,6 +108,10 @@ func (c *ConfigWrapper) ApplyDynamicConfig() cfg.Source {
 			betterBoltdbShipperDefaults(r, &defaults)
 		}
 
+		if len(r.SchemaConfig.Configs) > 0 && loki_storage.UsingTSDB(r.SchemaConfig.Configs) {
+			betterTSDBShipperDefaults(r, &defaults)
+		}
+
 		applyFIFOCacheConfig(r)
 		applyIngesterFinalSleep(r)
 		applyIngesterReplicationFactor(r)
@@ -127,6 +137,7 @@ func applyInstanceConfigs(r, defaults *ConfigWrapper) {
 		r.Distributor.DistributorRing.InstanceAddr = r.Common.InstanceAddr
 		r.Ruler.Ring.InstanceAddr = r.Common.InstanceAddr
 		r.QueryScheduler.SchedulerRing.InstanceAddr = r.Common.InstanceAddr
+		r.IndexGateway.Ring.InstanceAddr = r.Common.InstanceAddr
 		r.Frontend.FrontendV2.Addr = r.Common.InstanceAddr
 	}
 
@@ -136,6 +147,7 @@ func applyInstanceConfigs(r, defaults *ConfigWrapper) {
 		r.Distributor.DistributorRing.InstanceInterfaceNames = r.Common.InstanceInterfaceNames
 		r.Ruler.Ring.InstanceInterfaceNames = r.Common.InstanceInterfaceNames
 		r.QueryScheduler.SchedulerRing.InstanceInterfaceNames = r.Common.InstanceInterfaceNames
+		r.IndexGateway.Ring.InstanceInterfaceNames = r.Common.InstanceInterfaceNames
 		r.Frontend.FrontendV2.InfNames = r.Common.InstanceInterfaceNames
 	}
 }
@@ -245,6 +257,19 @@ func applyConfigToRings(r, defaults *ConfigWrapper, rc util.RingConfig, mergeWit
 		r.CompactorConfig.CompactorRing.ZoneAwarenessEnabled = rc.ZoneAwarenessEnabled
 		r.CompactorConfig.CompactorRing.KVStore = rc.KVStore
 	}
+
+	// Index Gateway
+	if mergeWithExisting || reflect.DeepEqual(r.IndexGateway.Ring.RingConfig, defaults.IndexGateway.Ring.RingConfig) {
+		r.IndexGateway.Ring.HeartbeatTimeout = rc.HeartbeatTimeout
+		r.IndexGateway.Ring.HeartbeatPeriod = rc.HeartbeatPeriod
+		r.IndexGateway.Ring.InstancePort = rc.InstancePort
+		r.IndexGateway.Ring.InstanceAddr = rc.InstanceAddr
+		r.IndexGateway.Ring.InstanceID = rc.InstanceID
+		r.IndexGateway.Ring.InstanceInterfaceNames = rc.InstanceInterfaceNames
+		r.IndexGateway.Ring.InstanceZone = rc.InstanceZone
+		r.IndexGateway.Ring.ZoneAwarenessEnabled = rc.ZoneAwarenessEnabled
+		r.IndexGateway.Ring.KVStore = rc.KVStore
+	}
 }
 
 func applyTokensFilePath(cfg *ConfigWrapper) error {
@@ -269,6 +294,13 @@ func applyTokensFilePath(cfg *ConfigWrapper) error {
 	}
 	cfg.QueryScheduler.SchedulerRing.TokensFilePath = f
 
+	// Index Gateway
+	f, err = tokensFile(cfg, "indexgateway.tokens")
+	if err != nil {
+		return err
+	}
+	cfg.IndexGateway.Ring.TokensFilePath = f
+
 	return nil
 }
 
@@ -340,6 +372,10 @@ func appendLoopbackInterface(cfg, defaults *ConfigWrapper) {
 	if reflect.DeepEqual(cfg.Ruler.Ring.InstanceInterfaceNames, defaults.Ruler.Ring.InstanceInterfaceNames) {
 		cfg.Ruler.Ring.InstanceInterfaceNames = append(cfg.Ruler.Ring.InstanceInterfaceNames, loopbackIface)
 	}
+
+	if reflect.DeepEqual(cfg.IndexGateway.Ring.InstanceInterfaceNames, defaults.IndexGateway.Ring.InstanceInterfaceNames) {
+		cfg.IndexGateway.Ring.InstanceInterfaceNames = append(cfg.IndexGateway.Ring.InstanceInterfaceNames, loopbackIface)
+	}
 }
 
 // applyMemberlistConfig will change the default ingester, distributor, ruler, and query scheduler ring configurations to use memberlist.
@@ -352,12 +388,13 @@ func applyMemberlistConfig(r *ConfigWrapper) {
 	r.Ruler.Ring.KVStore.Store = memberlistStr
 	r.QueryScheduler.SchedulerRing.KVStore.Store = memberlistStr
 	r.CompactorConfig.CompactorRing.KVStore.Store = memberlistStr
+	r.IndexGateway.Ring.KVStore.Store = memberlistStr
 }
 
 var ErrTooManyStorageConfigs = errors.New("too many storage configs provided in the common config, please only define one storage backend")
 
 // applyStorageConfig will attempt to apply a common storage config for either
-// s3, gcs, azure, or swift to all the places we create a storage client.
+// s3, gcs, azure, swift, alibabacloud, tencentcloud or bos to all the places we create a storage client.
 // If any specific configs for an object storage client have been provided elsewhere in the
 // configuration file, applyStorageConfig will not override them.
 // If multiple storage configurations are provided, applyStorageConfig will return an error
@@ -430,6 +467,41 @@ func applyStorageConfig(cfg, defaults *ConfigWrapper) error {
 		}
 	}
 
+	if !reflect.DeepEqual(cfg.Common.Storage.AlibabaCloud, defaults.StorageConfig.AlibabaCloudConfig) {
+		configsFound++
+
+		// the ruler can't store its rules in OSS, its storage is left as is.
+		applyConfig = func(r *ConfigWrapper) {
+			r.StorageConfig.AlibabaCloudConfig = r.Common.Storage.AlibabaCloud
+			r.CompactorConfig.SharedStoreType = chunk_storage.StorageTypeAlibabaCloud
+			r.StorageConfig.Hedging = r.Common.Storage.Hedging
+		}
+	}
+
+	if !reflect.DeepEqual(cfg.Common.Storage.TencentCloud, defaults.StorageConfig.TencentCloudConfig) {
+		configsFound++
+
+		applyConfig = func(r *ConfigWrapper) {
+			r.Ruler.StoreConfig.Type = "cos"
+			r.Ruler.StoreConfig.COS = r.Common.Storage.TencentCloud
+			r.StorageConfig.TencentCloudConfig = r.Common.Storage.TencentCloud
+			r.CompactorConfig.SharedStoreType = chunk_storage.StorageTypeTencentCloud
+			r.StorageConfig.Hedging = r.Common.Storage.Hedging
+		}
+	}
+
+	if !reflect.DeepEqual(cfg.Common.Storage.BOS, defaults.StorageConfig.BOSStorageConfig) {
+		configsFound++
+
+		applyConfig = func(r *ConfigWrapper) {
+			r.Ruler.StoreConfig.Type = "bos"
+			r.Ruler.StoreConfig.BOS = r.Common.Storage.BOS
+			r.StorageConfig.BOSStorageConfig = r.Common.Storage.BOS
+			r.CompactorConfig.SharedStoreType = chunk_storage.StorageTypeBOS
+			r.StorageConfig.Hedging = r.Common.Storage.Hedging
+		}
+	}
+
 	if configsFound > 1 {
 		return ErrTooManyStorageConfigs
 	}
@@ -466,6 +538,73 @@ func betterBoltdbShipperDefaults(cfg, defaults *ConfigWrapper) {
 	}
 }
 
+func betterTSDBShipperDefaults(cfg, defaults *ConfigWrapper) {
+	currentSchemaIdx := loki_storage.ActivePeriodConfig(cfg.SchemaConfig.Configs)
+	currentSchema := cfg.SchemaConfig.Configs[currentSchemaIdx]
+
+	if cfg.StorageConfig.TSDBShipperConfig.SharedStoreType == defaults.StorageConfig.TSDBShipperConfig.SharedStoreType {
+		cfg.StorageConfig.TSDBShipperConfig.SharedStoreType = currentSchema.ObjectType
+	}
+
+	if cfg.Common.PathPrefix != "" {
+		prefix := strings.TrimSuffix(cfg.Common.PathPrefix, "/")
+
+		if cfg.StorageConfig.TSDBShipperConfig.ActiveIndexDirectory == "" {
+			cfg.StorageConfig.TSDBShipperConfig.ActiveIndexDirectory = fmt.Sprintf("%s/tsdb-shipper-active", prefix)
+		}
+
+		if cfg.StorageConfig.TSDBShipperConfig.CacheLocation == "" {
+			cfg.StorageConfig.TSDBShipperConfig.CacheLocation = fmt.Sprintf("%s/tsdb-shipper-cache", prefix)
+		}
+	}
+}
+
+// applyTenantIndexStoreDefaults applies the defaults of betterBoltdbShipperDefaults and betterTSDBShipperDefaults to the
+// shippers only used by the periods of the tenant schema configs, which are loaded from the runtime config after the config.
+// The shared stores default to the object store of the current period of the first tenant using them.
+func applyTenantIndexStoreDefaults(cfg *Config) {
+	all := cfg.SchemaConfig.AllConfigs()
+	if len(all[0]) == 0 {
+		return
+	}
+	boltdbShipper := loki_storage.UsingBoltdbShipper(all[0])
+	tsdbShipper := loki_storage.UsingTSDB(all[0])
+	prefix := strings.TrimSuffix(cfg.Common.PathPrefix, "/")
+
+	for _, configs := range all[1:] {
+		currentSchema := configs[loki_storage.ActivePeriodConfig(configs)]
+
+		if !boltdbShipper && loki_storage.UsingBoltdbShipper(configs) {
+			boltdbShipper = true
+			if cfg.StorageConfig.BoltDBShipperConfig.SharedStoreType == "" {
+				cfg.StorageConfig.BoltDBShipperConfig.SharedStoreType = currentSchema.ObjectType
+			}
+			if cfg.CompactorConfig.SharedStoreType == "" {
+				cfg.CompactorConfig.SharedStoreType = currentSchema.ObjectType
+			}
+			if prefix != "" && cfg.StorageConfig.BoltDBShipperConfig.ActiveIndexDirectory == "" {
+				cfg.StorageConfig.BoltDBShipperConfig.ActiveIndexDirectory = fmt.Sprintf("%s/boltdb-shipper-active", prefix)
+			}
+			if prefix != "" && cfg.StorageConfig.BoltDBShipperConfig.CacheLocation == "" {
+				cfg.StorageConfig.BoltDBShipperConfig.CacheLocation = fmt.Sprintf("%s/boltdb-shipper-cache", prefix)
+			}
+		}
+
+		if !tsdbShipper && loki_storage.UsingTSDB(configs) {
+			tsdbShipper = true
+			if cfg.StorageConfig.TSDBShipperConfig.SharedStoreType == "" {
+				cfg.StorageConfig.TSDBShipperConfig.SharedStoreType = currentSchema.ObjectType
+			}
+			if prefix != "" && cfg.StorageConfig.TSDBShipperConfig.ActiveIndexDirectory == "" {
+				cfg.StorageConfig.TSDBShipperConfig.ActiveIndexDirectory = fmt.Sprintf("%s/tsdb-shipper-active", prefix)
+			}
+			if prefix != "" && cfg.StorageConfig.TSDBShipperConfig.CacheLocation == "" {
+				cfg.StorageConfig.TSDBShipperConfig.CacheLocation = fmt.Sprintf("%s/tsdb-shipper-cache", prefix)
+			}
+		}
+	}
+}
+
 // applyFIFOCacheConfig turns on FIFO cache for the chunk store and for the query range results,
 // but only if no other cache storage is configured (redis or memcache).
 //
@@ -11,16 +11,20 @@ import (
 	"time"
 
 	"github.com/grafana/dskit/netutil"
+	"github.com/prometheus/common/model"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/grafana/loki/pkg/distributor"
 	"github.com/grafana/loki/pkg/loki/common"
 	"github.com/grafana/loki/pkg/storage/bucket/swift"
+	"github.com/grafana/loki/pkg/storage/chunk"
 	"github.com/grafana/loki/pkg/storage/chunk/aws"
 	"github.com/grafana/loki/pkg/storage/chunk/azure"
+	"github.com/grafana/loki/pkg/storage/chunk/baidubce"
 	"github.com/grafana/loki/pkg/storage/chunk/gcp"
 	"github.com/grafana/loki/pkg/storage/chunk/storage"
+	"github.com/grafana/loki/pkg/storage/chunk/tencent"
 	"github.com/grafana/loki/pkg/util"
 	"github.com/grafana/loki/pkg/util/cfg"
 	util_log "github.com/grafana/loki/pkg/util/log"
@@ -442,6 +446,72 @@ memberlist:
 			assert.EqualValues(t, defaults.StorageConfig.FSConfig, config.StorageConfig.FSConfig)
 		})
 
+		t.Run("when common tencentcloud storage config is provided, ruler, storage and compactor config are defaulted to use it", func(t *testing.T) {
+			cosConfig := `common:
+  storage:
+    tencentcloud:
+      region: ap-guangzhou
+      bucket: loki-1250000000
+      secret_id: id
+      secret_key: supersecret`
+
+			config, defaults := testContext(cosConfig, nil)
+
+			assert.Equal(t, "cos", config.Ruler.StoreConfig.Type)
+			assert.Equal(t, "tencentcloud-cos", config.CompactorConfig.SharedStoreType)
+
+			for _, actual := range []tencent.CosConfig{
+				config.Ruler.StoreConfig.COS,
+				config.StorageConfig.TencentCloudConfig,
+			} {
+				assert.Equal(t, "ap-guangzhou", actual.Region)
+				assert.Equal(t, "loki-1250000000", actual.Bucket)
+				assert.Equal(t, "id", actual.SecretID)
+				assert.Equal(t, "supersecret", actual.SecretKey.Value)
+
+				assert.Equal(t, 30*time.Second, actual.RequestTimeout,
+					"unspecified request timeout should get default value")
+			}
+
+			//should remain empty
+			assert.EqualValues(t, defaults.Ruler.StoreConfig.S3, config.Ruler.StoreConfig.S3)
+			assert.EqualValues(t, defaults.StorageConfig.AWSStorageConfig.S3Config, config.StorageConfig.AWSStorageConfig.S3Config)
+			assert.EqualValues(t, defaults.StorageConfig.AlibabaCloudConfig, config.StorageConfig.AlibabaCloudConfig)
+		})
+
+		t.Run("when common bos storage config is provided, ruler, storage and compactor config are defaulted to use it", func(t *testing.T) {
+			bosConfig := `common:
+  storage:
+    bos:
+      bucket_name: loki
+      endpoint: gz.bcebos.com
+      access_key_id: id
+      secret_access_key: supersecret`
+
+			config, defaults := testContext(bosConfig, nil)
+
+			assert.Equal(t, "bos", config.Ruler.StoreConfig.Type)
+			assert.Equal(t, "bos", config.CompactorConfig.SharedStoreType)
+
+			for _, actual := range []baidubce.BOSStorageConfig{
+				config.Ruler.StoreConfig.BOS,
+				config.StorageConfig.BOSStorageConfig,
+			} {
+				assert.Equal(t, "loki", actual.Bucket)
+				assert.Equal(t, "gz.bcebos.com", actual.Endpoint)
+				assert.Equal(t, "id", actual.AccessKeyID)
+				assert.Equal(t, "supersecret", actual.SecretAccessKey.Value)
+
+				assert.Equal(t, 30*time.Second, actual.RequestTimeout,
+					"unspecified request timeout should get default value")
+			}
+
+			//should remain empty
+			assert.EqualValues(t, defaults.Ruler.StoreConfig.S3, config.Ruler.StoreConfig.S3)
+			assert.EqualValues(t, defaults.StorageConfig.AWSStorageConfig.S3Config, config.StorageConfig.AWSStorageConfig.S3Config)
+			assert.EqualValues(t, defaults.StorageConfig.TencentCloudConfig, config.StorageConfig.TencentCloudConfig)
+		})
+
 		t.Run("when common filesystem/local config is provided, ruler and storage config are defaulted to use it", func(t *testing.T) {
 			fsConfig := `common:
   storage:
@@ -962,6 +1032,7 @@ common:
 		assert.Equal(t, "/loki/ingester.tokens", config.Ingester.LifecyclerConfig.TokensFilePath)
 		assert.Equal(t, "/loki/compactor.tokens", config.CompactorConfig.CompactorRing.TokensFilePath)
 		assert.Equal(t, "/loki/scheduler.tokens", config.QueryScheduler.SchedulerRing.TokensFilePath)
+		assert.Equal(t, "/loki/indexgateway.tokens", config.IndexGateway.Ring.TokensFilePath)
 	})
 
 	t.Run("ingester config not applied to other rings if actual values set", func(t *testing.T) {
@@ -1003,6 +1074,7 @@ ingester:
 		assert.Equal(t, "etcd", config.Ruler.Ring.KVStore.Store)
 		assert.Equal(t, "etcd", config.QueryScheduler.SchedulerRing.KVStore.Store)
 		assert.Equal(t, "etcd", config.CompactorConfig.CompactorRing.KVStore.Store)
+		assert.Equal(t, "etcd", config.IndexGateway.Ring.KVStore.Store)
 	})
 
 	t.Run("memberlist configuration takes precedence over copying ingester config", func(t *testing.T) {
@@ -1025,6 +1097,7 @@ ingester:
 		assert.Equal(t, "memberlist", config.Ruler.Ring.KVStore.Store)
 		assert.Equal(t, "memberlist", config.QueryScheduler.SchedulerRing.KVStore.Store)
 		assert.Equal(t, "memberlist", config.CompactorConfig.CompactorRing.KVStore.Store)
+		assert.Equal(t, "memberlist", config.IndexGateway.Ring.KVStore.Store)
 	})
 }
 
@@ -1337,6 +1410,41 @@ storage_config:
 
 }
 
+func Test_applyTenantIndexStoreDefaults(t *testing.T) {
+	yamlContent := `
+common:
+  path_prefix: /loki
+schema_config:
+  configs:
+    - from: 2020-10-24
+      store: boltdb-shipper
+      object_store: filesystem
+      schema: v11
+      index:
+        prefix: index_
+        period: 24h`
+	config, _, err := configWrapperFromYAML(t, yamlContent, nil)
+	require.NoError(t, err)
+	require.Equal(t, "", config.StorageConfig.TSDBShipperConfig.SharedStoreType)
+	boltdbShipperConfig := config.StorageConfig.BoltDBShipperConfig
+
+	config.SchemaConfig.TenantConfigs = map[string]*chunk.SchemaConfig{
+		"tenant": {Configs: []chunk.PeriodConfig{{
+			From:       chunk.DayTime{Time: model.TimeFromUnix(0)},
+			IndexType:  "tsdb",
+			ObjectType: "s3",
+			Schema:     "v12",
+		}}},
+	}
+	applyTenantIndexStoreDefaults(&config.Config)
+
+	// the shipper of the index type only used by the tenant gets the defaults of the global ones.
+	require.Equal(t, "s3", config.StorageConfig.TSDBShipperConfig.SharedStoreType)
+	require.Equal(t, "/loki/tsdb-shipper-active", config.StorageConfig.TSDBShipperConfig.ActiveIndexDirectory)
+	require.Equal(t, "/loki/tsdb-shipper-cache", config.StorageConfig.TSDBShipperConfig.CacheLocation)
+	require.Equal(t, boltdbShipperConfig, config.StorageConfig.BoltDBShipperConfig)
+}
+
 func Test_replicationFactor(t *testing.T) {
 	t.Run("replication factor is applied when using memberlist", func(t *testing.T) {
 		yamlContent := `memberlist:
@@ -0,0 +1,274 @@
+// Package embedded runs a minimal single-tenant Loki inside another Go process, for tests and edge appliances.
+//
+// The instance runs all the modules of Loki in the process, its data being stored in memory or in a directory of the
+// filesystem. It is driven with the Go client of Loki, over the loopback interface:
+//
+//	l, err := embedded.Start(ctx, embedded.Config{Storage: embedded.StorageInMemory})
+//	if err != nil {
+//		return err
+//	}
+//	defer l.Stop()
+//
+//	err = l.Push(ctx, streams)
+//	resp, err := l.QueryRange(ctx, client.QueryRangeRequest{Query: `{app="foo"}`, Start: start, End: end})
+//
+// A process runs a single embedded instance at a time. The metrics of the instance are registered in a registry of
+// its own, which replaces the default registry of the process while it runs.
+package embedded
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/grafana/dskit/flagext"
+	"github.com/grafana/dskit/services"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/weaveworks/common/logging"
+
+	"github.com/grafana/loki/pkg/client"
+	"github.com/grafana/loki/pkg/loki"
+	"github.com/grafana/loki/pkg/storage/chunk"
+	chunk_storage "github.com/grafana/loki/pkg/storage/chunk/storage"
+	"github.com/grafana/loki/pkg/storage/stores/shipper"
+	util_log "github.com/grafana/loki/pkg/util/log"
+)
+
+const (
+	// StorageInMemory keeps the chunks and the index in memory, they are lost when the instance stops.
+	StorageInMemory = "inmemory"
+	// StorageFilesystem keeps the chunks and the index in the directory of the instance.
+	StorageFilesystem = "filesystem"
+
+	// TenantID is the tenant of the instance, which runs with the authentication disabled.
+	TenantID = "fake"
+)
+
+// Config configures an embedded instance.
+type Config struct {
+	// Storage is the storage of the chunks and the index, StorageInMemory or StorageFilesystem. StorageInMemory when
+	// empty.
+	Storage string
+	// Dir is the directory of the data of the instance: the WAL, the local files of the index and, when the storage
+	// is the filesystem, the chunks. A temporary directory removed on Stop is used when empty.
+	Dir string
+	// Configure customizes the config of Loki before it is validated, for example its limits.
+	Configure func(cfg *loki.Config)
+	// Registerer registers the metrics of the instance, a new registry when nil.
+	Registerer prometheus.Registerer
+}
+
+// Loki is an embedded instance. Its methods push and query the logs of the tenant of the instance.
+type Loki struct {
+	*client.Client
+
+	loki    *loki.Loki
+	manager *services.Manager
+	address string
+
+	dir, tempDir string
+	stopOnce     sync.Once
+	stopErr      error
+}
+
+// Start starts an embedded instance and returns once it is ready to receive the pushes and the queries.
+func Start(ctx context.Context, cfg Config) (*Loki, error) {
+	if cfg.Storage == "" {
+		cfg.Storage = StorageInMemory
+	}
+	if cfg.Storage != StorageInMemory && cfg.Storage != StorageFilesystem {
+		return nil, fmt.Errorf("unsupported storage %q, supported values: %s, %s", cfg.Storage, StorageInMemory, StorageFilesystem)
+	}
+
+	l := &Loki{dir: cfg.Dir}
+	if l.dir == "" {
+		dir, err := ioutil.TempDir("", "loki-embedded")
+		if err != nil {
+			return nil, err
+		}
+		l.dir, l.tempDir = dir, dir
+	}
+
+	if err := l.start(ctx, cfg); err != nil {
+		l.removeTempDir()
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Loki) start(ctx context.Context, cfg Config) error {
+	lokiCfg, err := newConfig(l.dir, cfg)
+	if err != nil {
+		return err
+	}
+	l.address = fmt.Sprintf("http://%s:%d", lokiCfg.Server.HTTPListenAddress, lokiCfg.Server.HTTPListenPort)
+
+	reg := cfg.Registerer
+	if reg == nil {
+		reg = prometheus.NewRegistry()
+	}
+	// the modules register their metrics in the default registerer, which is replaced for the instances started one
+	// after the other to not register the same metrics twice.
+	prometheus.DefaultRegisterer = reg
+	if gatherer, ok := reg.(prometheus.Gatherer); ok {
+		prometheus.DefaultGatherer = gatherer
+	}
+
+	l.loki, err = loki.New(lokiCfg)
+	if err != nil {
+		return err
+	}
+	l.manager, err = l.loki.Start(ctx, loki.RunOpts{})
+	if err != nil {
+		return err
+	}
+
+	l.Client, err = client.New(client.Config{Address: l.address, TenantID: TenantID})
+	if err != nil {
+		_ = l.Stop()
+		return err
+	}
+	if err := l.awaitReady(ctx); err != nil {
+		_ = l.Stop()
+		return err
+	}
+	return nil
+}
+
+// newConfig returns the config of a single binary Loki keeping its data in dir, the common config being applied to the
+// other sections the same as for a config file.
+func newConfig(dir string, cfg Config) (loki.Config, error) {
+	httpPort, err := freePort()
+	if err != nil {
+		return loki.Config{}, err
+	}
+	grpcPort, err := freePort()
+	if err != nil {
+		return loki.Config{}, err
+	}
+
+	var c loki.ConfigWrapper
+	flagext.DefaultValues(&c)
+
+	c.Target = []string{loki.All}
+	c.AuthEnabled = false
+	c.Server.HTTPListenAddress = "127.0.0.1"
+	c.Server.HTTPListenPort = httpPort
+	c.Server.GRPCListenAddress = "127.0.0.1"
+	c.Server.GRPCListenPort = grpcPort
+	c.Server.Log = logging.GoKit(util_log.Logger)
+	c.Common.PathPrefix = dir
+	c.Common.ReplicationFactor = 1
+	c.Common.InstanceAddr = "127.0.0.1"
+	c.Common.Ring.KVStore.Store = "inmemory"
+	c.Common.Ring.InstanceAddr = "127.0.0.1"
+	c.UsageReport.Enabled = false
+
+	objectStore := chunk_storage.StorageTypeInMemory
+	if cfg.Storage == StorageFilesystem {
+		objectStore = chunk_storage.StorageTypeFileSystem
+		c.Common.Storage.FSConfig.ChunksDirectory = filepath.Join(dir, "chunks")
+		c.Common.Storage.FSConfig.RulesDirectory = filepath.Join(dir, "rules")
+	}
+
+	c.SchemaConfig.Configs = []chunk.PeriodConfig{{
+		From:        chunk.DayTime{Time: model.TimeFromUnix(time.Date(2020, 10, 24, 0, 0, 0, 0, time.UTC).Unix())},
+		IndexType:   shipper.BoltDBShipperType,
+		ObjectType:  objectStore,
+		Schema:      "v11",
+		IndexTables: chunk.PeriodicTableConfig{Prefix: "index_", Period: 24 * time.Hour},
+	}}
+
+	if err := c.ApplyDynamicConfig()(&c); err != nil {
+		return loki.Config{}, err
+	}
+
+	// the ingester is ready once in the ring, and the query frontend once the schedulers in the ring are looked up.
+	c.Ingester.LifecyclerConfig.MinReadyDuration = 0
+	c.Frontend.FrontendV2.DNSLookupPeriod = time.Second
+	if cfg.Storage == StorageInMemory {
+		c.StorageConfig.BoltDBShipperConfig.SharedStoreType = chunk_storage.StorageTypeInMemory
+		c.CompactorConfig.SharedStoreType = chunk_storage.StorageTypeInMemory
+		c.Ruler.StoreConfig.Type = "local"
+		c.Ruler.StoreConfig.Local.Directory = filepath.Join(dir, "rules")
+	}
+
+	if cfg.Configure != nil {
+		cfg.Configure(&c.Config)
+	}
+	if err := c.Config.Validate(); err != nil {
+		return loki.Config{}, fmt.Errorf("invalid config: %w", err)
+	}
+	return c.Config, nil
+}
+
+// awaitReady waits for the instance to be ready, the query frontend being ready once a querier is connected to it.
+func (l *Loki) awaitReady(ctx context.Context) error {
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, l.address+"/ready", nil)
+		if err != nil {
+			return err
+		}
+		if resp, err := http.DefaultClient.Do(req); err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("the instance is not ready: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// Address returns the address of the HTTP API of the instance.
+func (l *Loki) Address() string {
+	return l.address
+}
+
+// Stop stops the instance and removes its temporary directory.
+func (l *Loki) Stop() error {
+	l.stopOnce.Do(func() {
+		l.manager.StopAsync()
+		l.stopErr = l.manager.AwaitStopped(context.Background())
+		if l.stopErr == nil {
+			if failed := l.manager.ServicesByState()[services.Failed]; len(failed) > 0 {
+				l.stopErr = errors.New("failed services")
+			}
+		}
+		l.removeTempDir()
+	})
+	return l.stopErr
+}
+
+func (l *Loki) removeTempDir() {
+	if l.tempDir == "" {
+		return
+	}
+	if err := os.RemoveAll(l.tempDir); err != nil {
+		util_log.Logger.Log("msg", "failed to remove the directory of the embedded instance", "dir", l.tempDir, "err", err)
+	}
+}
+
+func freePort() (int, error) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer lis.Close()
+	return lis.Addr().(*net.TCPAddr).Port, nil
+}
@@ -0,0 +1,66 @@
+package embedded
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/pkg/client"
+	"github.com/grafana/loki/pkg/loghttp"
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/loki/pkg/loki"
+)
+
+func TestLoki_PushQuery(t *testing.T) {
+	for _, storage := range []string{StorageInMemory, StorageFilesystem} {
+		t.Run(storage, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+
+			l, err := Start(ctx, Config{Storage: storage, Dir: t.TempDir()})
+			require.NoError(t, err)
+			defer func() {
+				require.NoError(t, l.Stop())
+			}()
+
+			now := time.Now()
+			require.NoError(t, l.Push(ctx, []logproto.Stream{{
+				Labels: `{app="foo"}`,
+				Entries: []logproto.Entry{
+					{Timestamp: now.Add(-2 * time.Second), Line: "line 1"},
+					{Timestamp: now.Add(-time.Second), Line: "line 2"},
+				},
+			}}))
+
+			resp, err := l.QueryRange(ctx, client.QueryRangeRequest{
+				Query:     `{app="foo"}`,
+				Start:     now.Add(-time.Minute),
+				End:       now.Add(time.Minute),
+				Direction: logproto.FORWARD,
+			})
+			require.NoError(t, err)
+
+			streams := resp.Data.Result.(loghttp.Streams)
+			require.Len(t, streams, 1)
+			require.Len(t, streams[0].Entries, 2)
+			require.Equal(t, "line 1", streams[0].Entries[0].Line)
+
+			labels, err := l.Labels(ctx, now.Add(-time.Minute), now.Add(time.Minute))
+			require.NoError(t, err)
+			require.Contains(t, labels, "app")
+		})
+	}
+}
+
+func TestStart_Configure(t *testing.T) {
+	_, err := Start(context.Background(), Config{Storage: "s3"})
+	require.Error(t, err)
+
+	// the config is validated once customized.
+	_, err = Start(context.Background(), Config{Configure: func(cfg *loki.Config) {
+		cfg.StorageConfig.BoltDBShipperConfig.SharedStoreKeyPrefix = "/index"
+	}})
+	require.Error(t, err)
+}
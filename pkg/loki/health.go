@@ -0,0 +1,118 @@
+package loki
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gogo/status"
+	"github.com/grafana/dskit/grpcutil"
+	"github.com/grafana/dskit/services"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// moduleStatusHeader is the gRPC header of the health responses listing the state of each module.
+	moduleStatusHeader = "loki-module-status"
+
+	healthWatchInterval = time.Second
+)
+
+// moduleHealthCheck implements grpc_health_v1.HealthServer. The empty service reports the health
+// of the whole instance, as the gRPC services registered on the server do, and a module name
+// reports the health of this module only, so that partially degraded instances can be routed around.
+// The state of each module is sent in the loki-module-status header of the responses.
+type moduleHealthCheck struct {
+	instance      *grpcutil.HealthCheck
+	modules       map[string]services.Service
+	grpcServices  map[string]grpc.ServiceInfo
+	watchInterval time.Duration
+}
+
+func newModuleHealthCheck(sm *services.Manager, modules map[string]services.Service, grpcServices map[string]grpc.ServiceInfo) *moduleHealthCheck {
+	return &moduleHealthCheck{
+		instance:      grpcutil.NewHealthCheck(sm),
+		modules:       modules,
+		grpcServices:  grpcServices,
+		watchInterval: healthWatchInterval,
+	}
+}
+
+// Check implements grpc_health_v1.HealthServer.
+func (h *moduleHealthCheck) Check(ctx context.Context, req *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	st, ok := h.status(ctx, req.Service)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "unknown service %s", req.Service)
+	}
+	_ = grpc.SetHeader(ctx, h.moduleStatus())
+	return &grpc_health_v1.HealthCheckResponse{Status: st}, nil
+}
+
+// Watch implements grpc_health_v1.HealthServer, sending the status of the service each time it changes.
+func (h *moduleHealthCheck) Watch(req *grpc_health_v1.HealthCheckRequest, stream grpc_health_v1.Health_WatchServer) error {
+	if err := stream.SendHeader(h.moduleStatus()); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(h.watchInterval)
+	defer ticker.Stop()
+
+	last := grpc_health_v1.HealthCheckResponse_ServingStatus(-1)
+	for {
+		st, ok := h.status(stream.Context(), req.Service)
+		if !ok {
+			st = grpc_health_v1.HealthCheckResponse_SERVICE_UNKNOWN
+		}
+		if st != last {
+			if err := stream.Send(&grpc_health_v1.HealthCheckResponse{Status: st}); err != nil {
+				return err
+			}
+			last = st
+		}
+
+		select {
+		case <-stream.Context().Done():
+			return status.Error(codes.Canceled, "stream has ended")
+		case <-ticker.C:
+		}
+	}
+}
+
+// status returns the serving status of the instance, a gRPC service or a module, and false if the service is unknown.
+func (h *moduleHealthCheck) status(ctx context.Context, service string) (grpc_health_v1.HealthCheckResponse_ServingStatus, bool) {
+	if _, ok := h.grpcServices[service]; service == "" || ok {
+		res, err := h.instance.Check(ctx, nil)
+		if err != nil {
+			return grpc_health_v1.HealthCheckResponse_NOT_SERVING, true
+		}
+		return res.Status, true
+	}
+
+	s, ok := h.modules[service]
+	if !ok || s == nil {
+		return grpc_health_v1.HealthCheckResponse_UNKNOWN, false
+	}
+	// like the whole instance, modules keep serving while stopping.
+	switch s.State() {
+	case services.Running, services.Stopping:
+		return grpc_health_v1.HealthCheckResponse_SERVING, true
+	default:
+		return grpc_health_v1.HealthCheckResponse_NOT_SERVING, true
+	}
+}
+
+// moduleStatus returns the header listing the state of each module as module=state.
+func (h *moduleHealthCheck) moduleStatus() metadata.MD {
+	states := make([]string, 0, len(h.modules))
+	for name, s := range h.modules {
+		if s != nil {
+			states = append(states, fmt.Sprintf("%s=%s", name, s.State()))
+		}
+	}
+	sort.Strings(states)
+	return metadata.MD{moduleStatusHeader: states}
+}
@@ -0,0 +1,103 @@
+package loki
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/grafana/dskit/services"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestModuleHealthCheck(t *testing.T) {
+	ctx := context.Background()
+	fail := make(chan struct{})
+	ingester := services.NewBasicService(nil, func(ctx context.Context) error {
+		select {
+		case <-fail:
+			return errors.New("failed")
+		case <-ctx.Done():
+			return nil
+		}
+	}, nil)
+	distributor := services.NewIdleService(nil, nil)
+	sm, err := services.NewManager(ingester, distributor)
+	require.NoError(t, err)
+	require.NoError(t, services.StartManagerAndAwaitHealthy(ctx, sm))
+	defer func() { _ = services.StopManagerAndAwaitStopped(ctx, sm) }()
+
+	listener := bufconn.Listen(1024)
+	server := grpc.NewServer()
+	healthCheck := newModuleHealthCheck(sm,
+		map[string]services.Service{"ingester": ingester, "distributor": distributor, "server": nil},
+		map[string]grpc.ServiceInfo{"logproto.Pusher": {}},
+	)
+	healthCheck.watchInterval = 10 * time.Millisecond
+	grpc_health_v1.RegisterHealthServer(server, healthCheck)
+	go func() { _ = server.Serve(listener) }()
+	defer server.Stop()
+
+	conn, err := grpc.DialContext(ctx, "bufnet", grpc.WithInsecure(), grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+		return listener.Dial()
+	}))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := grpc_health_v1.NewHealthClient(conn)
+
+	check := func(service string) (grpc_health_v1.HealthCheckResponse_ServingStatus, metadata.MD) {
+		var header metadata.MD
+		res, err := client.Check(ctx, &grpc_health_v1.HealthCheckRequest{Service: service}, grpc.Header(&header))
+		require.NoError(t, err)
+		return res.Status, header
+	}
+
+	st, header := check("")
+	require.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, st)
+	require.Equal(t, []string{"distributor=Running", "ingester=Running"}, header.Get(moduleStatusHeader))
+	st, _ = check("logproto.Pusher")
+	require.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, st)
+	st, _ = check("ingester")
+	require.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, st)
+
+	for _, service := range []string{"server", "unknown"} {
+		_, err = client.Check(ctx, &grpc_health_v1.HealthCheckRequest{Service: service})
+		require.Equal(t, codes.NotFound, status.Code(err), service)
+	}
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	watch, err := client.Watch(watchCtx, &grpc_health_v1.HealthCheckRequest{Service: "ingester"})
+	require.NoError(t, err)
+	res, err := watch.Recv()
+	require.NoError(t, err)
+	require.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, res.Status)
+
+	// a failed module degrades the instance, the other modules keep serving.
+	close(fail)
+	require.Error(t, ingester.AwaitTerminated(ctx))
+	res, err = watch.Recv()
+	require.NoError(t, err)
+	require.Equal(t, grpc_health_v1.HealthCheckResponse_NOT_SERVING, res.Status)
+
+	st, header = check("")
+	require.Equal(t, grpc_health_v1.HealthCheckResponse_NOT_SERVING, st)
+	require.Equal(t, []string{"distributor=Running", "ingester=Failed"}, header.Get(moduleStatusHeader))
+	st, _ = check("ingester")
+	require.Equal(t, grpc_health_v1.HealthCheckResponse_NOT_SERVING, st)
+	st, _ = check("distributor")
+	require.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, st)
+
+	unknown, err := client.Watch(watchCtx, &grpc_health_v1.HealthCheckRequest{Service: "unknown"})
+	require.NoError(t, err)
+	res, err = unknown.Recv()
+	require.NoError(t, err)
+	require.Equal(t, grpc_health_v1.HealthCheckResponse_SERVICE_UNKNOWN, res.Status)
+}
@@ -13,7 +13,6 @@ import (
 	"github.com/felixge/fgprof"
 	"github.com/go-kit/log/level"
 	"github.com/grafana/dskit/flagext"
-	"github.com/grafana/dskit/grpcutil"
 	"github.com/grafana/dskit/kv/memberlist"
 	"github.com/grafana/dskit/modules"
 	"github.com/grafana/dskit/ring"
@@ -25,12 +24,15 @@ import (
 	"github.com/weaveworks/common/server"
 	"github.com/weaveworks/common/signals"
 	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 
 	"github.com/grafana/loki/pkg/distributor"
+	"github.com/grafana/loki/pkg/gateway"
 	"github.com/grafana/loki/pkg/ingester"
 	"github.com/grafana/loki/pkg/ingester/client"
 	"github.com/grafana/loki/pkg/loki/common"
 	"github.com/grafana/loki/pkg/lokifrontend"
+	"github.com/grafana/loki/pkg/notifications"
 	"github.com/grafana/loki/pkg/querier"
 	"github.com/grafana/loki/pkg/querier/queryrange"
 	basetripper "github.com/grafana/loki/pkg/querier/queryrange/queryrangebase"
@@ -39,11 +41,15 @@ import (
 	base_ruler "github.com/grafana/loki/pkg/ruler/base"
 	"github.com/grafana/loki/pkg/ruler/rulestore"
 	"github.com/grafana/loki/pkg/runtime"
+	"github.com/grafana/loki/pkg/scheduledqueries"
 	"github.com/grafana/loki/pkg/scheduler"
 	"github.com/grafana/loki/pkg/storage"
 	"github.com/grafana/loki/pkg/storage/chunk"
 	chunk_storage "github.com/grafana/loki/pkg/storage/chunk/storage"
 	"github.com/grafana/loki/pkg/storage/stores/shipper/compactor"
+	"github.com/grafana/loki/pkg/storage/stores/shipper/indexgateway"
+	"github.com/grafana/loki/pkg/storage/verify"
+	"github.com/grafana/loki/pkg/tenantmigration"
 	"github.com/grafana/loki/pkg/tracing"
 	"github.com/grafana/loki/pkg/usagestats"
 	"github.com/grafana/loki/pkg/util"
@@ -60,6 +66,8 @@ type Config struct {
 	HTTPPrefix   string                 `yaml:"http_prefix"`
 	BallastBytes int                    `yaml:"ballast_bytes"`
 
+	GRPCReflectionEnabled bool `yaml:"grpc_reflection_enabled"`
+
 	Common           common.Config            `yaml:"common,omitempty"`
 	Server           server.Config            `yaml:"server,omitempty"`
 	Distributor      distributor.Config       `yaml:"distributor,omitempty"`
@@ -79,8 +87,14 @@ type Config struct {
 	MemberlistKV     memberlist.KVConfig      `yaml:"memberlist"`
 	Tracing          tracing.Config           `yaml:"tracing"`
 	CompactorConfig  compactor.Config         `yaml:"compactor,omitempty"`
+	IndexGateway     indexgateway.Config      `yaml:"index_gateway"`
 	QueryScheduler   scheduler.Config         `yaml:"query_scheduler"`
 	UsageReport      usagestats.Config        `yaml:"analytics"`
+	ScheduledQueries scheduledqueries.Config  `yaml:"scheduled_queries,omitempty"`
+	Notifications    notifications.Config     `yaml:"notifications,omitempty"`
+	ConfigVerify     verify.Config            `yaml:"config_verify,omitempty"`
+	TenantMigration  tenantmigration.Config   `yaml:"tenant_migration,omitempty"`
+	Gateway          gateway.Config           `yaml:"gateway,omitempty"`
 }
 
 // RegisterFlags registers flag.
@@ -96,6 +110,7 @@ func (c *Config) RegisterFlags(f *flag.FlagSet) {
 	f.BoolVar(&c.AuthEnabled, "auth.enabled", true, "Set to false to disable auth.")
 	f.IntVar(&c.BallastBytes, "config.ballast-bytes", 0, "The amount of virtual memory to reserve as a ballast in order to optimise "+
 		"garbage collection. Larger ballasts result in fewer garbage collection passes, reducing compute overhead at the cost of memory usage.")
+	f.BoolVar(&c.GRPCReflectionEnabled, "server.grpc-reflection-enabled", false, "Enable the gRPC reflection service, listing the gRPC services of the server to clients like grpcurl.")
 
 	c.registerServerFlagsWithChangedDefaultValues(f)
 	c.Common.RegisterFlags(f)
@@ -116,8 +131,14 @@ func (c *Config) RegisterFlags(f *flag.FlagSet) {
 	c.MemberlistKV.RegisterFlags(f)
 	c.Tracing.RegisterFlags(f)
 	c.CompactorConfig.RegisterFlags(f)
+	c.IndexGateway.RegisterFlags(f)
 	c.QueryScheduler.RegisterFlags(f)
 	c.UsageReport.RegisterFlags(f)
+	c.ScheduledQueries.RegisterFlags(f)
+	c.Notifications.RegisterFlags(f)
+	c.ConfigVerify.RegisterFlags(f)
+	c.TenantMigration.RegisterFlags(f)
+	c.Gateway.RegisterFlags(f)
 }
 
 func (c *Config) registerServerFlagsWithChangedDefaultValues(fs *flag.FlagSet) {
@@ -182,12 +203,47 @@ func (c *Config) Validate() error {
 	if err := c.StorageConfig.BoltDBShipperConfig.Validate(); err != nil {
 		return errors.Wrap(err, "invalid boltdb-shipper config")
 	}
+	if err := c.StorageConfig.TSDBShipperConfig.Validate(); err != nil {
+		return errors.Wrap(err, "invalid tsdb-shipper config")
+	}
+	if err := c.StorageConfig.ChunkQuarantine.Validate(); err != nil {
+		return errors.Wrap(err, "invalid chunk quarantine config")
+	}
+	if err := c.StorageConfig.ChunkBlooms.Validate(); err != nil {
+		return errors.Wrap(err, "invalid chunk blooms config")
+	}
 	if err := c.CompactorConfig.Validate(); err != nil {
 		return errors.Wrap(err, "invalid compactor config")
 	}
+	if err := c.IndexGateway.Validate(); err != nil {
+		return errors.Wrap(err, "invalid index gateway config")
+	}
 	if err := c.ChunkStoreConfig.Validate(util_log.Logger); err != nil {
 		return errors.Wrap(err, "invalid chunk store config")
 	}
+	if err := c.ScheduledQueries.Validate(); err != nil {
+		return errors.Wrap(err, "invalid scheduled queries config")
+	}
+	if err := c.Notifications.Validate(); err != nil {
+		return errors.Wrap(err, "invalid notifications config")
+	}
+	if err := c.ConfigVerify.Validate(); err != nil {
+		return errors.Wrap(err, "invalid config-verify config")
+	}
+	if err := c.TenantMigration.Validate(); err != nil {
+		return errors.Wrap(err, "invalid tenant migration config")
+	}
+	if c.isModuleEnabled(Gateway) {
+		if err := c.Gateway.Validate(); err != nil {
+			return errors.Wrap(err, "invalid gateway config")
+		}
+		// the gateway routes the paths the other components serve.
+		for _, m := range []string{All, Read, Write, Distributor, Querier, QueryFrontend, Ruler, Compactor} {
+			if c.isModuleEnabled(m) {
+				return fmt.Errorf("the %s module can't run along with the %s module", Gateway, m)
+			}
+		}
+	}
 	// TODO(cyriltovena): remove when MaxLookBackPeriod in the storage will be fully deprecated.
 	if c.ChunkStoreConfig.MaxLookBackPeriod > 0 {
 		c.LimitsConfig.MaxQueryLookback = c.ChunkStoreConfig.MaxLookBackPeriod
@@ -239,6 +295,7 @@ type Loki struct {
 	ingesterQuerier          *querier.IngesterQuerier
 	Store                    storage.Store
 	tableManager             *chunk.TableManager
+	schemaConfigWatcher      *storage.SchemaConfigWatcher
 	frontend                 Frontend
 	ruler                    *base_ruler.Ruler
 	RulerStorage             rulestore.RuleStore
@@ -249,7 +306,12 @@ type Loki struct {
 	compactor                *compactor.Compactor
 	QueryFrontEndTripperware basetripper.Tripperware
 	queryScheduler           *scheduler.Scheduler
+	indexGatewayRingManager  *indexgateway.RingManager
 	usageReport              *usagestats.Reporter
+	scheduledQueries         *scheduledqueries.Scheduler
+	notifier                 notifications.Notifier
+	tenantMigrator           *tenantmigration.Migrator
+	storageHealth            *chunk_storage.HealthChecker
 
 	clientMetrics chunk_storage.ClientMetrics
 
@@ -280,6 +342,8 @@ func (t *Loki) setupAuthMiddleware() {
 		// Also don't check auth for these gRPC methods, since single call is used for multiple users (or no user like health check).
 		[]string{
 			"/grpc.health.v1.Health/Check",
+			"/grpc.health.v1.Health/Watch",
+			"/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo",
 			"/logproto.Ingester/TransferChunks",
 			"/frontend.Frontend/Process",
 			"/frontend.Frontend/NotifyClientShutdown",
@@ -314,6 +378,7 @@ func (t *Loki) bindConfigEndpoint(opts RunOpts) {
 		configEndpointHandlerFn = opts.CustomConfigEndpointHandlerFn
 	}
 	t.Server.HTTP.Path("/config").Methods("GET").HandlerFunc(configEndpointHandlerFn)
+	t.Server.HTTP.Path("/config/schema/validate").Methods("POST").HandlerFunc(schemaConfigValidationHandler)
 }
 
 // ListTargets prints a list of available user visible targets and their
@@ -336,11 +401,70 @@ func (t *Loki) ListTargets() {
 
 // Run starts Loki running, and blocks until a Loki stops.
 func (t *Loki) Run(opts RunOpts) error {
-	serviceMap, err := t.ModuleManager.InitModuleServices(t.Cfg.Target...)
+	sm, err := t.initServiceManager(opts)
 	if err != nil {
 		return err
 	}
 
+	// Setup signal handler. If signal arrives, we stop the manager, which stops all the services.
+	handler := signals.NewHandler(t.Server.Log)
+	go func() {
+		handler.Loop()
+		sm.StopAsync()
+	}()
+
+	// Start all services. This can really only fail if some service is already
+	// in other state than New, which should not be the case.
+	err = sm.StartAsync(context.Background())
+	if err == nil {
+		// Wait until service manager stops. It can stop in two ways:
+		// 1) Signal is received and manager is stopped.
+		// 2) Any service fails.
+		err = sm.AwaitStopped(context.Background())
+	}
+
+	// If there is no error yet (= service manager started and then stopped without problems),
+	// but any service failed, report that failure as an error to caller.
+	if err == nil {
+		if failed := sm.ServicesByState()[services.Failed]; len(failed) > 0 {
+			for _, f := range failed {
+				if f.FailureCase() != modules.ErrStopProcess {
+					// Details were reported via failure listener before
+					err = errors.New("failed services")
+					break
+				}
+			}
+		}
+	}
+	return err
+}
+
+// Start starts Loki without blocking, for it to be embedded in another process. It returns once all the modules are
+// running, the returned manager stopping them. Unlike Run, Loki doesn't stop on the signals of the process.
+func (t *Loki) Start(ctx context.Context, opts RunOpts) (*services.Manager, error) {
+	sm, err := t.initServiceManager(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := sm.StartAsync(context.Background()); err != nil {
+		return nil, err
+	}
+	if err := sm.AwaitHealthy(ctx); err != nil {
+		sm.StopAsync()
+		_ = sm.AwaitStopped(context.Background())
+		return nil, err
+	}
+	return sm, nil
+}
+
+// initServiceManager initializes the services of the modules of the targets and the handlers of the whole instance.
+func (t *Loki) initServiceManager(opts RunOpts) (*services.Manager, error) {
+	serviceMap, err := t.ModuleManager.InitModuleServices(t.Cfg.Target...)
+	if err != nil {
+		return nil, err
+	}
+
 	t.serviceMap = serviceMap
 	t.Server.HTTP.Path("/services").Methods("GET").Handler(http.HandlerFunc(t.servicesHandler))
 	t.Server.HTTP.NotFoundHandler = http.HandlerFunc(serverutil.NotFoundHandler)
@@ -353,13 +477,17 @@ func (t *Loki) Run(opts RunOpts) error {
 
 	sm, err := services.NewManager(servs...)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// before starting servers, register /ready handler. It should reflect entire Loki.
 	t.Server.HTTP.Path("/ready").Methods("GET").Handler(t.readyHandler(sm))
 
-	grpc_health_v1.RegisterHealthServer(t.Server.GRPC, grpcutil.NewHealthCheck(sm))
+	// The health of each module is checked with its name, the health of the whole instance with any gRPC service of the server.
+	grpc_health_v1.RegisterHealthServer(t.Server.GRPC, newModuleHealthCheck(sm, serviceMap, t.Server.GRPC.GetServiceInfo()))
+	if t.Cfg.GRPCReflectionEnabled {
+		reflection.Register(t.Server.GRPC)
+	}
 
 	// Config endpoint adds a way to see the config and the changes compared to the defaults.
 	t.bindConfigEndpoint(opts)
@@ -392,38 +520,7 @@ func (t *Loki) Run(opts RunOpts) error {
 	}
 
 	sm.AddListener(services.NewManagerListener(healthy, stopped, serviceFailed))
-
-	// Setup signal handler. If signal arrives, we stop the manager, which stops all the services.
-	handler := signals.NewHandler(t.Server.Log)
-	go func() {
-		handler.Loop()
-		sm.StopAsync()
-	}()
-
-	// Start all services. This can really only fail if some service is already
-	// in other state than New, which should not be the case.
-	err = sm.StartAsync(context.Background())
-	if err == nil {
-		// Wait until service manager stops. It can stop in two ways:
-		// 1) Signal is received and manager is stopped.
-		// 2) Any service fails.
-		err = sm.AwaitStopped(context.Background())
-	}
-
-	// If there is no error yet (= service manager started and then stopped without problems),
-	// but any service failed, report that failure as an error to caller.
-	if err == nil {
-		if failed := sm.ServicesByState()[services.Failed]; len(failed) > 0 {
-			for _, f := range failed {
-				if f.FailureCase() != modules.ErrStopProcess {
-					// Details were reported via failure listener before
-					err = errors.New("failed services")
-					break
-				}
-			}
-		}
-	}
-	return err
+	return sm, nil
 }
 
 func (t *Loki) readyHandler(sm *services.Manager) http.HandlerFunc {
@@ -459,6 +556,15 @@ func (t *Loki) readyHandler(sm *services.Manager) http.HandlerFunc {
 			}
 		}
 
+		// The storage backends are probed to stop sending requests to the instances which can't reach them,
+		// for example because their credentials expired.
+		if t.storageHealth != nil {
+			if err := t.storageHealth.CheckReady(r.Context()); err != nil {
+				http.Error(w, "Storage not ready: "+err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+		}
+
 		http.Error(w, "ready", http.StatusOK)
 	}
 }
@@ -485,8 +591,17 @@ func (t *Loki) setupModuleManager() error {
 	mm.RegisterModule(TableManager, t.initTableManager)
 	mm.RegisterModule(Compactor, t.initCompactor)
 	mm.RegisterModule(IndexGateway, t.initIndexGateway)
+	mm.RegisterModule(IndexGatewayRing, t.initIndexGatewayRing, modules.UserInvisibleModule)
 	mm.RegisterModule(QueryScheduler, t.initQueryScheduler)
 	mm.RegisterModule(UsageReport, t.initUsageReport)
+	mm.RegisterModule(ScheduledQueries, t.initScheduledQueries)
+	mm.RegisterModule(Notifications, t.initNotifications, modules.UserInvisibleModule)
+	mm.RegisterModule(FilesystemRetention, t.initFilesystemRetention, modules.UserInvisibleModule)
+	mm.RegisterModule(StorageHealth, t.initStorageHealth, modules.UserInvisibleModule)
+	mm.RegisterModule(ConfigVerify, t.initConfigVerify)
+	mm.RegisterModule(SchemaConfigWatcher, t.initSchemaConfigWatcher, modules.UserInvisibleModule)
+	mm.RegisterModule(TenantMigration, t.initTenantMigration, modules.UserInvisibleModule)
+	mm.RegisterModule(Gateway, t.initGateway)
 
 	mm.RegisterModule(All, nil)
 	mm.RegisterModule(Read, nil)
@@ -499,25 +614,33 @@ func (t *Loki) setupModuleManager() error {
 		Overrides:                {RuntimeConfig},
 		OverridesExporter:        {Overrides, Server},
 		TenantConfigs:            {RuntimeConfig},
-		Distributor:              {Ring, Server, Overrides, TenantConfigs, UsageReport},
-		Store:                    {Overrides},
-		Ingester:                 {Store, Server, MemberlistKV, TenantConfigs, UsageReport},
-		Querier:                  {Store, Ring, Server, IngesterQuerier, TenantConfigs, UsageReport},
+		Distributor:              {Ring, Server, Overrides, TenantConfigs, UsageReport, Notifications, TenantMigration},
+		Store:                    {Overrides, SchemaConfigWatcher, StorageHealth, IndexGatewayRing},
+		Ingester:                 {Store, Server, MemberlistKV, TenantConfigs, UsageReport, Notifications},
+		Querier:                  {Store, Ring, Server, IngesterQuerier, TenantConfigs, UsageReport, TenantMigration},
 		QueryFrontendTripperware: {Server, Overrides, TenantConfigs},
 		QueryFrontend:            {QueryFrontendTripperware, UsageReport},
 		QueryScheduler:           {Server, Overrides, MemberlistKV, UsageReport},
 		Ruler:                    {Ring, Server, Store, RulerStorage, IngesterQuerier, Overrides, TenantConfigs, UsageReport},
-		TableManager:             {Server, UsageReport},
-		Compactor:                {Server, Overrides, MemberlistKV, UsageReport},
-		IndexGateway:             {Server, Overrides, UsageReport},
+		TableManager:             {Server, RuntimeConfig, UsageReport, SchemaConfigWatcher},
+		Compactor:                {Server, Overrides, MemberlistKV, UsageReport, Notifications},
+		IndexGateway:             {Server, Overrides, UsageReport, IndexGatewayRing},
+		IndexGatewayRing:         {RuntimeConfig, Server, MemberlistKV, Overrides},
 		IngesterQuerier:          {Ring},
-		All:                      {QueryScheduler, QueryFrontend, Querier, Ingester, Distributor, Ruler, Compactor},
+		ScheduledQueries:         {Ring, Server, Store, IngesterQuerier, Overrides, UsageReport},
+		Notifications:            {},
+		ConfigVerify:             {Server, RuntimeConfig},
+		TenantMigration:          {Ring, Server},
+		FilesystemRetention:      {Server},
+		StorageHealth:            {Server},
+		Gateway:                  {Server},
+		All:                      {QueryScheduler, QueryFrontend, Querier, Ingester, Distributor, Ruler, Compactor, FilesystemRetention},
 		Read:                     {QueryScheduler, QueryFrontend, Querier, Ruler, Compactor},
 		Write:                    {Ingester, Distributor},
 	}
 
-	// Add IngesterQuerier as a dependency for store when target is either querier, ruler, or read.
-	if t.Cfg.isModuleEnabled(Querier) || t.Cfg.isModuleEnabled(Ruler) || t.Cfg.isModuleEnabled(Read) {
+	// Add IngesterQuerier as a dependency for store when target is either querier, ruler, read or scheduled-queries.
+	if t.Cfg.isModuleEnabled(Querier) || t.Cfg.isModuleEnabled(Ruler) || t.Cfg.isModuleEnabled(Read) || t.Cfg.isModuleEnabled(ScheduledQueries) {
 		deps[Store] = append(deps[Store], IngesterQuerier)
 	}
 
@@ -18,6 +18,7 @@ import (
 	"github.com/go-kit/log/level"
 	"github.com/grafana/dskit/kv/codec"
 	"github.com/grafana/dskit/kv/memberlist"
+	"github.com/grafana/dskit/modules"
 	"github.com/grafana/dskit/ring"
 	"github.com/grafana/dskit/runtimeconfig"
 	"github.com/grafana/dskit/services"
@@ -30,6 +31,7 @@ import (
 	"github.com/weaveworks/common/user"
 
 	"github.com/grafana/loki/pkg/distributor"
+	"github.com/grafana/loki/pkg/gateway"
 	"github.com/grafana/loki/pkg/ingester"
 	"github.com/grafana/loki/pkg/logproto"
 	"github.com/grafana/loki/pkg/logql"
@@ -37,24 +39,32 @@ import (
 	"github.com/grafana/loki/pkg/lokifrontend/frontend/transport"
 	"github.com/grafana/loki/pkg/lokifrontend/frontend/v1/frontendv1pb"
 	"github.com/grafana/loki/pkg/lokifrontend/frontend/v2/frontendv2pb"
+	"github.com/grafana/loki/pkg/notifications"
 	"github.com/grafana/loki/pkg/querier"
 	"github.com/grafana/loki/pkg/querier/queryrange"
 	"github.com/grafana/loki/pkg/ruler"
 	base_ruler "github.com/grafana/loki/pkg/ruler/base"
 	"github.com/grafana/loki/pkg/runtime"
+	"github.com/grafana/loki/pkg/scheduledqueries"
 	"github.com/grafana/loki/pkg/scheduler"
 	"github.com/grafana/loki/pkg/scheduler/schedulerpb"
 	loki_storage "github.com/grafana/loki/pkg/storage"
 	"github.com/grafana/loki/pkg/storage/chunk"
 	"github.com/grafana/loki/pkg/storage/chunk/cache"
+	chunk_local "github.com/grafana/loki/pkg/storage/chunk/local"
 	chunk_storage "github.com/grafana/loki/pkg/storage/chunk/storage"
 	chunk_util "github.com/grafana/loki/pkg/storage/chunk/util"
 	"github.com/grafana/loki/pkg/storage/stores/shipper"
+	"github.com/grafana/loki/pkg/storage/stores/shipper/bloom"
 	"github.com/grafana/loki/pkg/storage/stores/shipper/compactor"
 	"github.com/grafana/loki/pkg/storage/stores/shipper/indexgateway"
 	"github.com/grafana/loki/pkg/storage/stores/shipper/indexgateway/indexgatewaypb"
+	"github.com/grafana/loki/pkg/storage/stores/shipper/quarantine"
 	"github.com/grafana/loki/pkg/storage/stores/shipper/uploads"
+	"github.com/grafana/loki/pkg/storage/verify"
+	"github.com/grafana/loki/pkg/tenantmigration"
 	"github.com/grafana/loki/pkg/usagestats"
+	"github.com/grafana/loki/pkg/util"
 	"github.com/grafana/loki/pkg/util/httpreq"
 	util_log "github.com/grafana/loki/pkg/util/log"
 	serverutil "github.com/grafana/loki/pkg/util/server"
@@ -84,11 +94,20 @@ const (
 	MemberlistKV             string = "memberlist-kv"
 	Compactor                string = "compactor"
 	IndexGateway             string = "index-gateway"
+	IndexGatewayRing         string = "index-gateway-ring"
 	QueryScheduler           string = "query-scheduler"
 	All                      string = "all"
 	Read                     string = "read"
 	Write                    string = "write"
 	UsageReport              string = "usage-report"
+	ScheduledQueries         string = "scheduled-queries"
+	Notifications            string = "notifications"
+	ConfigVerify             string = "config-verify"
+	SchemaConfigWatcher      string = "schema-config-watcher"
+	TenantMigration          string = "tenant-migration"
+	FilesystemRetention      string = "filesystem-retention"
+	StorageHealth            string = "storage-health"
+	Gateway                  string = "gateway"
 )
 
 func (t *Loki) initServer() (services.Service, error) {
@@ -153,12 +172,23 @@ func (t *Loki) initRuntimeConfig() (services.Service, error) {
 		return nil, nil
 	}
 
-	t.Cfg.RuntimeConfig.Loader = loadRuntimeConfig
+	t.Cfg.RuntimeConfig.Loader = t.loadRuntimeConfig
 
 	// make sure to set default limits before we start loading configuration into memory
 	validation.SetDefaultLimitsForYAMLUnmarshalling(t.Cfg.LimitsConfig)
 
-	var err error
+	// Stores are built once at startup, so tenant schema configs are read before the
+	// runtime config manager starts and aren't reloaded afterwards.
+	tenantSchemaConfigs, err := loadTenantSchemaConfigs(t.Cfg.RuntimeConfig.LoadPath)
+	if err != nil {
+		return nil, err
+	}
+	t.Cfg.SchemaConfig.TenantConfigs = tenantSchemaConfigs
+	if err := t.Cfg.SchemaConfig.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid schema config: %w", err)
+	}
+	applyTenantIndexStoreDefaults(&t.Cfg)
+
 	t.runtimeConfig, err = runtimeconfig.New(t.Cfg.RuntimeConfig, prometheus.WrapRegistererWithPrefix("loki_", prometheus.DefaultRegisterer), util_log.Logger)
 	t.TenantLimits = newtenantLimitsFromRuntimeConfig(t.runtimeConfig)
 	return t.runtimeConfig, err
@@ -193,8 +223,12 @@ func (t *Loki) initTenantConfigs() (_ services.Service, err error) {
 func (t *Loki) initDistributor() (services.Service, error) {
 	t.Cfg.Distributor.DistributorRing.KVStore.Multi.ConfigProvider = multiClientRuntimeConfigChannel(t.runtimeConfig)
 	t.Cfg.Distributor.DistributorRing.KVStore.MemberlistKV = t.MemberlistKV.GetMemberlistKV
+	var tee distributor.Tee
+	if t.tenantMigrator != nil {
+		tee = t.tenantMigrator
+	}
 	var err error
-	t.distributor, err = distributor.New(t.Cfg.Distributor, t.Cfg.IngesterClient, t.tenantConfigs, t.ring, t.overrides, prometheus.DefaultRegisterer)
+	t.distributor, err = distributor.New(t.Cfg.Distributor, t.Cfg.IngesterClient, t.tenantConfigs, t.ring, t.overrides, t.notifier, tee, prometheus.DefaultRegisterer)
 	if err != nil {
 		return nil, err
 	}
@@ -211,9 +245,15 @@ func (t *Loki) initDistributor() (services.Service, error) {
 	).Wrap(http.HandlerFunc(t.distributor.PushHandler))
 
 	t.Server.HTTP.Path("/distributor/ring").Methods("GET", "POST").Handler(t.distributor)
+	t.Server.HTTP.Path("/distributor/label_suggestions").Methods("GET").Handler(middleware.Merge(
+		serverutil.RecoveryHTTPMiddleware,
+		t.HTTPAuthMiddleware,
+	).Wrap(http.HandlerFunc(t.distributor.LabelSuggestionsHandler)))
 
 	t.Server.HTTP.Path("/api/prom/push").Methods("POST").Handler(pushHandler)
 	t.Server.HTTP.Path("/loki/api/v1/push").Methods("POST").Handler(pushHandler)
+	// Pushes of the tenants migrating from another cluster.
+	t.Server.HTTP.Path(tenantmigration.PushPath).Methods("POST").Handler(tenantmigration.PushHandler(pushHandler))
 	return t.distributor, nil
 }
 
@@ -234,11 +274,15 @@ func (t *Loki) initQuerier() (services.Service, error) {
 		return nil, err
 	}
 
+	var singleTenantQuerier querier.Querier = q
+	if t.tenantMigrator != nil {
+		singleTenantQuerier = tenantmigration.NewFederatedQuerier(q, t.tenantMigrator)
+	}
 	if t.Cfg.Querier.MultiTenantQueriesEnabled {
-		t.Querier = querier.NewMultiTenantQuerier(q, util_log.Logger)
+		t.Querier = querier.NewMultiTenantQuerier(singleTenantQuerier, util_log.Logger)
 		tenant.WithDefaultResolver(tenant.NewMultiResolver())
 	} else {
-		t.Querier = q
+		t.Querier = singleTenantQuerier
 	}
 
 	querierWorkerServiceConfig := querier.WorkerServiceConfig{
@@ -265,6 +309,7 @@ func (t *Loki) initQuerier() (services.Service, error) {
 		"/loki/api/v1/labels":              http.HandlerFunc(t.querierAPI.LabelHandler),
 		"/loki/api/v1/label/{name}/values": http.HandlerFunc(t.querierAPI.LabelHandler),
 		"/loki/api/v1/series":              http.HandlerFunc(t.querierAPI.SeriesHandler),
+		"/loki/api/v1/index/stats":         http.HandlerFunc(t.querierAPI.IndexStatsHandler),
 
 		"/api/prom/query":               httpMiddleware.Wrap(http.HandlerFunc(t.querierAPI.LogQueryHandler)),
 		"/api/prom/label":               http.HandlerFunc(t.querierAPI.LabelHandler),
@@ -284,6 +329,9 @@ func (t *Loki) initQuerier() (services.Service, error) {
 	alwaysExternalHandlers := map[string]http.Handler{
 		"/loki/api/v1/tail": http.HandlerFunc(t.querierAPI.TailHandler),
 		"/api/prom/tail":    http.HandlerFunc(t.querierAPI.TailHandler),
+		// Selections of the tenants migrating with another cluster, never federated.
+		tenantmigration.SelectLogsPath:    tenantmigration.SelectLogsHandler(q),
+		tenantmigration.SelectSamplesPath: tenantmigration.SelectSamplesHandler(q),
 	}
 
 	svc, err := querier.InitWorkerService(
@@ -302,6 +350,11 @@ func (t *Loki) initQuerier() (services.Service, error) {
 	if svc != nil {
 		svc.AddListener(deleteRequestsStoreListener(deleteStore))
 	}
+	if t.Cfg.Querier.Warmup.FrontendAddress != "" {
+		// the querier only becomes ready once its caches are warmed up by the queries recently received by the frontend.
+		engine := logql.NewEngine(t.Cfg.Querier.Engine, t.Querier, t.overrides, logger)
+		svc = querier.NewWarmupService(t.Cfg.Querier.Warmup, engine, svc, util_log.Logger)
+	}
 	return svc, nil
 }
 
@@ -310,10 +363,12 @@ func (t *Loki) initIngester() (_ services.Service, err error) {
 	t.Cfg.Ingester.LifecyclerConfig.RingConfig.KVStore.MemberlistKV = t.MemberlistKV.GetMemberlistKV
 	t.Cfg.Ingester.LifecyclerConfig.ListenPort = t.Cfg.Server.GRPCListenPort
 
-	t.Ingester, err = ingester.New(t.Cfg.Ingester, t.Cfg.IngesterClient, t.Store, t.overrides, t.tenantConfigs, prometheus.DefaultRegisterer)
+	i, err := ingester.New(t.Cfg.Ingester, t.Cfg.IngesterClient, t.Store, t.overrides, t.tenantConfigs, prometheus.DefaultRegisterer)
 	if err != nil {
 		return
 	}
+	i.SetNotifier(t.notifier)
+	t.Ingester = i
 
 	if t.Cfg.Ingester.Wrapper != nil {
 		t.Ingester = t.Cfg.Ingester.Wrapper.Wrap(t.Ingester)
@@ -328,10 +383,89 @@ func (t *Loki) initIngester() (_ services.Service, err error) {
 	)
 	t.Server.HTTP.Path("/flush").Methods("GET", "POST").Handler(httpMiddleware.Wrap(http.HandlerFunc(t.Ingester.FlushHandler)))
 	t.Server.HTTP.Methods("POST").Path("/ingester/flush_shutdown").Handler(httpMiddleware.Wrap(http.HandlerFunc(t.Ingester.ShutdownHandler)))
+	t.Server.HTTP.Methods("GET", "POST", "DELETE").Path("/ingester/prepare_restart").Handler(httpMiddleware.Wrap(http.HandlerFunc(t.Ingester.PrepareRestartHandler)))
 
 	return t.Ingester, nil
 }
 
+func (t *Loki) initNotifications() (services.Service, error) {
+	if !t.Cfg.Notifications.Enabled() {
+		t.notifier = notifications.Noop
+		return nil, nil
+	}
+
+	m := notifications.NewManager(t.Cfg.Notifications, prometheus.DefaultRegisterer, log.With(util_log.Logger, "component", "notifications"))
+	t.notifier = m
+	return m, nil
+}
+
+func (t *Loki) initConfigVerify() (services.Service, error) {
+	newTableClient := func(name string) (chunk.TableClient, error) {
+		return chunk_storage.NewTableClient(name, t.Cfg.StorageConfig.Config, prometheus.NewRegistry())
+	}
+	newObjectClient := func(name string) (chunk.ObjectClient, error) {
+		return chunk_storage.NewObjectClient(name, t.Cfg.StorageConfig.Config, t.clientMetrics)
+	}
+	verifier := verify.New(t.Cfg.ConfigVerify, t.Cfg.SchemaConfig.SchemaConfig, newTableClient, newObjectClient, log.With(util_log.Logger, "component", "config-verify"))
+
+	// The verification runs once, stopping Loki when done.
+	return services.NewBasicService(nil, func(ctx context.Context) error {
+		// The module manager fails modules stopping before it saw them running.
+		if err := t.serviceMap[ConfigVerify].AwaitRunning(ctx); err != nil {
+			return err
+		}
+
+		report := verifier.Run(ctx)
+		if err := report.Write(os.Stdout, t.Cfg.ConfigVerify.Format); err != nil {
+			return err
+		}
+		if report.Failed() {
+			return errors.New("config verification failed, see the report for the failed checks")
+		}
+		level.Info(util_log.Logger).Log("msg", "config verification succeeded")
+		return modules.ErrStopProcess
+	}, nil), nil
+}
+
+func (t *Loki) initScheduledQueries() (services.Service, error) {
+	if len(t.Cfg.ScheduledQueries.Queries) == 0 {
+		level.Info(util_log.Logger).Log("msg", "no scheduled queries configured, not starting the scheduled queries module")
+		return nil, nil
+	}
+
+	deleteStore, err := t.deleteRequestsStore()
+	if err != nil {
+		return nil, err
+	}
+
+	q, err := querier.New(t.Cfg.Querier, t.Store, t.ingesterQuerier, t.overrides, deleteStore)
+	if err != nil {
+		return nil, err
+	}
+
+	logger := log.With(util_log.Logger, "component", "scheduled-queries")
+	engine := logql.NewEngine(t.Cfg.Querier.Engine, q, t.overrides, logger)
+
+	newObjectClient := func(store string) (chunk.ObjectClient, error) {
+		return chunk_storage.NewObjectClient(store, t.Cfg.StorageConfig.Config, t.clientMetrics)
+	}
+
+	t.scheduledQueries, err = scheduledqueries.NewScheduler(t.Cfg.ScheduledQueries, engine, newObjectClient, prometheus.DefaultRegisterer, logger)
+	if err != nil {
+		return nil, err
+	}
+	return t.scheduledQueries, nil
+}
+
+func (t *Loki) initSchemaConfigWatcher() (services.Service, error) {
+	if t.Cfg.SchemaConfig.File == "" {
+		return nil, nil
+	}
+
+	t.schemaConfigWatcher = loki_storage.NewSchemaConfigWatcher(t.Cfg.SchemaConfig, util_log.Logger, prometheus.DefaultRegisterer)
+	return t.schemaConfigWatcher, nil
+}
+
 func (t *Loki) initTableManager() (services.Service, error) {
 	err := t.Cfg.SchemaConfig.Load()
 	if err != nil {
@@ -356,31 +490,126 @@ func (t *Loki) initTableManager() (services.Service, error) {
 
 	reg := prometheus.WrapRegistererWith(prometheus.Labels{"component": "table-manager-store"}, prometheus.DefaultRegisterer)
 
-	tableClient, err := chunk_storage.NewTableClient(lastConfig.IndexType, t.Cfg.StorageConfig.Config, reg)
+	indexTableClient, err := chunk_storage.NewTableClient(lastConfig.IndexType, t.Cfg.StorageConfig.Config, reg)
 	if err != nil {
 		return nil, err
 	}
+	// the tables of the tenant periods using index types no global period uses are managed with their own clients.
+	tenantTableClients := map[string]chunk.TableClient{}
+	for _, indexType := range tenantOnlyIndexTypes(t.Cfg.SchemaConfig.SchemaConfig) {
+		tenantReg := prometheus.WrapRegistererWith(prometheus.Labels{"component": "table-manager-store-" + indexType}, prometheus.DefaultRegisterer)
+		tenantTableClients[indexType], err = chunk_storage.NewTableClient(indexType, t.Cfg.StorageConfig.Config, tenantReg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	tableClient := chunk.NewIndexTypeTableClient(indexTableClient, tenantTableClients, t.Cfg.SchemaConfig.SchemaConfig)
 
 	bucketClient, err := chunk_storage.NewBucketClient(t.Cfg.StorageConfig.Config)
 	util_log.CheckFatal("initializing bucket client", err, util_log.Logger)
 
-	t.tableManager, err = chunk.NewTableManager(t.Cfg.TableManager, t.Cfg.SchemaConfig.SchemaConfig, maxChunkAgeForTableManager, tableClient, bucketClient, nil, prometheus.DefaultRegisterer)
+	var archiver chunk.TableArchiver
+	if store := t.Cfg.TableManager.RetentionArchiveStore; store != "" {
+		if _, ok := indexTableClient.(chunk.TableExporter); !ok {
+			return nil, fmt.Errorf("the tables of the index type %s can't be archived", lastConfig.IndexType)
+		}
+		for indexType, client := range tenantTableClients {
+			if _, ok := client.(chunk.TableExporter); !ok {
+				return nil, fmt.Errorf("the tables of the index type %s can't be archived", indexType)
+			}
+		}
+		exporter := tableClient.(chunk.TableExporter)
+		objectClient, err := chunk_storage.NewObjectClient(store, t.Cfg.StorageConfig.Config, t.clientMetrics)
+		if err != nil {
+			return nil, err
+		}
+		archiver = chunk.NewObjectTableArchiver(exporter, objectClient, t.Cfg.TableManager.RetentionArchivePrefix)
+	}
+
+	t.tableManager, err = chunk.NewTableManager(t.Cfg.TableManager, t.Cfg.SchemaConfig.SchemaConfig, maxChunkAgeForTableManager, tableClient, bucketClient, archiver, nil, prometheus.DefaultRegisterer)
 	if err != nil {
 		return nil, err
 	}
 
+	if t.schemaConfigWatcher != nil {
+		t.schemaConfigWatcher.AddPeriodConfigAdder(t.tableManager)
+	}
+
 	return t.tableManager, nil
 }
 
+// tenantOnlyIndexTypes returns the index types of the tenant periods which no global period uses.
+func tenantOnlyIndexTypes(cfg chunk.SchemaConfig) []string {
+	globalIndexTypes := map[string]struct{}{}
+	for _, p := range cfg.Configs {
+		globalIndexTypes[p.IndexType] = struct{}{}
+	}
+	var indexTypes []string
+	for _, configs := range cfg.AllConfigs()[1:] {
+		for _, p := range configs {
+			if _, ok := globalIndexTypes[p.IndexType]; !ok {
+				globalIndexTypes[p.IndexType] = struct{}{}
+				indexTypes = append(indexTypes, p.IndexType)
+			}
+		}
+	}
+	return indexTypes
+}
+
+// initFilesystemRetention deletes the chunk files out of retention of the filesystem object store.
+// The index files are left to the table manager and the compactor.
+func (t *Loki) initFilesystemRetention() (services.Service, error) {
+	fsCfg := t.Cfg.StorageConfig.FSConfig
+	if fsCfg.Directory == "" || fsCfg.RetentionPeriod == 0 {
+		return nil, nil
+	}
+	if fsCfg.RetentionSweepInterval <= 0 {
+		return nil, errors.New("the retention sweep interval of the filesystem object store must be positive")
+	}
+	return chunk_local.NewFSRetentionSweeper(fsCfg, filesystemRetentionIgnoredPrefixes(t.Cfg), log.With(util_log.Logger, "component", "filesystem-retention"), prometheus.DefaultRegisterer), nil
+}
+
+// filesystemRetentionIgnoredPrefixes returns the prefixes of the objects which aren't chunks in the object store:
+// the index of the shippers and of the compactor, the locks of the compactors, the chunk quarantine and blooms.
+func filesystemRetentionIgnoredPrefixes(cfg Config) []string {
+	var prefixes []string
+	for _, prefix := range []string{
+		cfg.StorageConfig.BoltDBShipperConfig.SharedStoreKeyPrefix,
+		cfg.StorageConfig.TSDBShipperConfig.SharedStoreKeyPrefix,
+		cfg.CompactorConfig.SharedStoreKeyPrefix,
+		cfg.CompactorConfig.ShardingTableLockPrefix,
+		cfg.StorageConfig.ChunkQuarantine.KeyPrefix,
+		cfg.StorageConfig.ChunkBlooms.KeyPrefix,
+	} {
+		if prefix != "" && !util.StringsContain(prefixes, prefix) {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	return prefixes
+}
+
+// initStorageHealth probes the index and object stores of the schema, the failures are surfaced by the readiness.
+func (t *Loki) initStorageHealth() (_ services.Service, err error) {
+	if !t.Cfg.StorageConfig.HealthCheck.Enabled {
+		return nil, nil
+	}
+	t.storageHealth, err = loki_storage.NewHealthChecker(t.Cfg.StorageConfig, t.Cfg.SchemaConfig, t.clientMetrics,
+		log.With(util_log.Logger, "component", "storage-health"), prometheus.DefaultRegisterer)
+	if err != nil {
+		return nil, err
+	}
+	return t.storageHealth, nil
+}
+
 func (t *Loki) initStore() (_ services.Service, err error) {
 	// If RF > 1 and current or upcoming index type is boltdb-shipper then disable index dedupe and write dedupe cache.
 	// This is to ensure that index entries are replicated to all the boltdb files in ingesters flushing replicated data.
-	if t.Cfg.Ingester.LifecyclerConfig.RingConfig.ReplicationFactor > 1 && loki_storage.UsingBoltdbShipper(t.Cfg.SchemaConfig.Configs) {
+	if t.Cfg.Ingester.LifecyclerConfig.RingConfig.ReplicationFactor > 1 && loki_storage.AnyTenantUsing(t.Cfg.SchemaConfig.SchemaConfig, loki_storage.UsingBoltdbShipper) {
 		t.Cfg.ChunkStoreConfig.DisableIndexDeduplication = true
 		t.Cfg.ChunkStoreConfig.WriteDedupeCacheConfig = cache.Config{}
 	}
 
-	if loki_storage.UsingBoltdbShipper(t.Cfg.SchemaConfig.Configs) {
+	if loki_storage.AnyTenantUsing(t.Cfg.SchemaConfig.SchemaConfig, loki_storage.UsingBoltdbShipper) {
 		t.Cfg.StorageConfig.BoltDBShipperConfig.IngesterName = t.Cfg.Ingester.LifecyclerConfig.ID
 		switch true {
 		case t.Cfg.isModuleEnabled(Ingester), t.Cfg.isModuleEnabled(Write):
@@ -404,24 +633,46 @@ func (t *Loki) initStore() (_ services.Service, err error) {
 			// and queried as part of live data until the cache TTL expires on the index entry.
 			t.Cfg.Ingester.RetainPeriod = t.Cfg.StorageConfig.IndexCacheValidity + 1*time.Minute
 			t.Cfg.StorageConfig.BoltDBShipperConfig.IngesterDBRetainPeriod = boltdbShipperQuerierIndexUpdateDelay(t.Cfg) + 2*time.Minute
-		case t.Cfg.isModuleEnabled(Querier), t.Cfg.isModuleEnabled(Ruler), t.Cfg.isModuleEnabled(Read):
+		case t.Cfg.isModuleEnabled(Querier), t.Cfg.isModuleEnabled(Ruler), t.Cfg.isModuleEnabled(Read), t.Cfg.isModuleEnabled(ScheduledQueries):
 			// We do not want query to do any updates to index
 			t.Cfg.StorageConfig.BoltDBShipperConfig.Mode = shipper.ModeReadOnly
+			if t.Cfg.IndexGateway.Mode == indexgateway.RingMode {
+				t.Cfg.StorageConfig.BoltDBShipperConfig.IndexGatewayClientConfig.Mode = indexgateway.RingMode
+				t.Cfg.StorageConfig.BoltDBShipperConfig.IndexGatewayClientConfig.Ring = t.indexGatewayRingManager.Ring
+				t.Cfg.StorageConfig.BoltDBShipperConfig.IndexGatewayClientConfig.Limits = t.overrides
+			}
 		default:
 			t.Cfg.StorageConfig.BoltDBShipperConfig.Mode = shipper.ModeReadWrite
 			t.Cfg.StorageConfig.BoltDBShipperConfig.IngesterDBRetainPeriod = boltdbShipperQuerierIndexUpdateDelay(t.Cfg) + 2*time.Minute
 		}
 	}
 
+	if loki_storage.AnyTenantUsing(t.Cfg.SchemaConfig.SchemaConfig, loki_storage.UsingTSDB) {
+		t.Cfg.StorageConfig.TSDBShipperConfig.IngesterName = t.Cfg.Ingester.LifecyclerConfig.ID
+		switch true {
+		case t.Cfg.isModuleEnabled(Ingester), t.Cfg.isModuleEnabled(Write):
+			t.Cfg.StorageConfig.TSDBShipperConfig.Mode = shipper.ModeWriteOnly
+		case t.Cfg.isModuleEnabled(Querier), t.Cfg.isModuleEnabled(Ruler), t.Cfg.isModuleEnabled(Read), t.Cfg.isModuleEnabled(ScheduledQueries):
+			t.Cfg.StorageConfig.TSDBShipperConfig.Mode = shipper.ModeReadOnly
+		default:
+			t.Cfg.StorageConfig.TSDBShipperConfig.Mode = shipper.ModeReadWrite
+		}
+	}
+
+	// the overrides of the runtime config are validated when loaded, the default limits here.
+	if err := loki_storage.ValidateDataLocality(&t.Cfg.StorageConfig, t.Cfg.SchemaConfig.SchemaConfig, t.overrides, model.Now()); err != nil {
+		return nil, err
+	}
+
 	chunkStore, err := chunk_storage.NewStore(t.Cfg.StorageConfig.Config, t.Cfg.ChunkStoreConfig.StoreConfig, t.Cfg.SchemaConfig.SchemaConfig, t.overrides, t.clientMetrics, prometheus.DefaultRegisterer, nil, util_log.Logger)
 	if err != nil {
 		return
 	}
 
-	if loki_storage.UsingBoltdbShipper(t.Cfg.SchemaConfig.Configs) {
-		boltdbShipperMinIngesterQueryStoreDuration := boltdbShipperMinIngesterQueryStoreDuration(t.Cfg)
+	if loki_storage.AnyTenantUsing(t.Cfg.SchemaConfig.SchemaConfig, loki_storage.UsingObjectStorageIndex) {
+		boltdbShipperMinIngesterQueryStoreDuration := objectStorageIndexMinIngesterQueryStoreDuration(t.Cfg)
 		switch true {
-		case t.Cfg.isModuleEnabled(Querier), t.Cfg.isModuleEnabled(Ruler), t.Cfg.isModuleEnabled(Read):
+		case t.Cfg.isModuleEnabled(Querier), t.Cfg.isModuleEnabled(Ruler), t.Cfg.isModuleEnabled(Read), t.Cfg.isModuleEnabled(ScheduledQueries):
 			// Do not use the AsyncStore if the querier is configured with QueryStoreOnly set to true
 			if t.Cfg.Querier.QueryStoreOnly {
 				break
@@ -436,14 +687,21 @@ func (t *Loki) initStore() (_ services.Service, err error) {
 			// We do not want to use AsyncStore otherwise it would start spiraling around doing queries over and over again to the ingesters and store.
 			// ToDo: See if we can avoid doing this when not running loki in clustered mode.
 			t.Cfg.Ingester.QueryStore = true
-			boltdbShipperConfigIdx := loki_storage.ActivePeriodConfig(t.Cfg.SchemaConfig.Configs)
-			if t.Cfg.SchemaConfig.Configs[boltdbShipperConfigIdx].IndexType != shipper.BoltDBShipperType {
-				boltdbShipperConfigIdx++
-			}
-			mlb, err := calculateMaxLookBack(t.Cfg.SchemaConfig.Configs[boltdbShipperConfigIdx], t.Cfg.Ingester.QueryStoreMaxLookBackPeriod,
-				boltdbShipperMinIngesterQueryStoreDuration)
-			if err != nil {
-				return nil, err
+			// the max look back must suit the current or upcoming object storage index period of every tenant.
+			var mlb time.Duration
+			for _, configs := range t.Cfg.SchemaConfig.AllConfigs() {
+				if !loki_storage.UsingObjectStorageIndex(configs) {
+					continue
+				}
+				boltdbShipperConfigIdx := loki_storage.ActivePeriodConfig(configs)
+				if !loki_storage.IsObjectStorageIndex(configs[boltdbShipperConfigIdx].IndexType) {
+					boltdbShipperConfigIdx++
+				}
+				mlb, err = calculateMaxLookBack(configs[boltdbShipperConfigIdx], t.Cfg.Ingester.QueryStoreMaxLookBackPeriod,
+					boltdbShipperMinIngesterQueryStoreDuration)
+				if err != nil {
+					return nil, err
+				}
 			}
 			t.Cfg.Ingester.QueryStoreMaxLookBackPeriod = mlb
 		}
@@ -454,12 +712,60 @@ func (t *Loki) initStore() (_ services.Service, err error) {
 		return
 	}
 
-	return services.NewIdleService(nil, func(_ error) error {
+	t.Store.SetDataLocality(t.overrides)
+
+	if t.schemaConfigWatcher != nil {
+		t.schemaConfigWatcher.AddPeriodConfigAdder(t.Store.(chunk.PeriodConfigAdder))
+	}
+
+	// the compactor quarantines the chunks and builds their blooms in the shared store of boltdb-shipper.
+	var filters []services.Service
+	if t.Cfg.StorageConfig.ChunkQuarantine.Enabled {
+		objectClient, err := t.sharedStoreObjectClient("chunk quarantine")
+		if err != nil {
+			return nil, err
+		}
+		quarantineFilter := quarantine.NewFilter(quarantine.NewStore(objectClient, t.Cfg.StorageConfig.ChunkQuarantine.KeyPrefix),
+			t.Cfg.StorageConfig.ChunkQuarantine.RefreshInterval, log.With(util_log.Logger, "component", "chunk-quarantine"))
+		t.Store.SetChunkQuarantine(quarantineFilter)
+		filters = append(filters, quarantineFilter)
+	}
+	if t.Cfg.StorageConfig.ChunkBlooms.Enabled {
+		objectClient, err := t.sharedStoreObjectClient("chunk blooms")
+		if err != nil {
+			return nil, err
+		}
+		bloomFilter := bloom.NewFilter(bloom.NewStore(objectClient, t.Cfg.StorageConfig.ChunkBlooms.KeyPrefix),
+			t.Cfg.StorageConfig.ChunkBlooms.RefreshInterval, log.With(util_log.Logger, "component", "chunk-blooms"))
+		t.Store.SetChunkBlooms(bloomFilter)
+		filters = append(filters, bloomFilter)
+	}
+
+	return services.NewIdleService(func(ctx context.Context) error {
+		for _, f := range filters {
+			if err := services.StartAndAwaitRunning(ctx, f); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, func(_ error) error {
+		for _, f := range filters {
+			_ = services.StopAndAwaitTerminated(context.Background(), f)
+		}
 		t.Store.Stop()
 		return nil
 	}), nil
 }
 
+// sharedStoreObjectClient returns a client of the shared store of boltdb-shipper, required by the feature.
+func (t *Loki) sharedStoreObjectClient(feature string) (chunk.ObjectClient, error) {
+	sharedStoreType := t.Cfg.StorageConfig.BoltDBShipperConfig.SharedStoreType
+	if sharedStoreType == "" {
+		return nil, fmt.Errorf("the %s requires the shared store of boltdb-shipper", feature)
+	}
+	return chunk_storage.NewObjectClient(sharedStoreType, t.Cfg.StorageConfig.Config, t.clientMetrics)
+}
+
 func (t *Loki) initIngesterQuerier() (_ services.Service, err error) {
 	t.ingesterQuerier, err = querier.NewIngesterQuerier(t.Cfg.IngesterClient, t.ring, t.Cfg.Querier.ExtraQueryDelay)
 	if err != nil {
@@ -501,6 +807,7 @@ func (t *Loki) initQueryFrontend() (_ services.Service, err error) {
 		FrontendV1:    t.Cfg.Frontend.FrontendV1,
 		FrontendV2:    t.Cfg.Frontend.FrontendV2,
 		DownstreamURL: t.Cfg.Frontend.DownstreamURL,
+		Downstream:    t.Cfg.Frontend.Downstream,
 	}
 	roundTripper, frontendV1, frontendV2, err := frontend.InitFrontend(
 		combinedCfg,
@@ -521,11 +828,20 @@ func (t *Loki) initQueryFrontend() (_ services.Service, err error) {
 		frontendv2pb.RegisterFrontendForQuerierServer(t.Server.GRPC, frontendV2)
 		t.frontend = frontendV2
 		level.Debug(util_log.Logger).Log("msg", "using query frontend", "version", "v2")
+	} else if downstream, ok := roundTripper.(*frontend.DownstreamRoundTripper); ok {
+		t.frontend = downstream
+		level.Debug(util_log.Logger).Log("msg", "using downstream queriers")
 	} else {
 		level.Debug(util_log.Logger).Log("msg", "no query frontend configured")
 	}
 
 	roundTripper = t.QueryFrontEndTripperware(roundTripper)
+	if t.Cfg.QueryRange.RecentQueriesTracked > 0 {
+		// Tracks the queries received, fetched by the queriers to warm their caches up on startup.
+		recentQueries := queryrange.NewRecentQueries(t.Cfg.QueryRange.RecentQueriesTracked)
+		roundTripper = recentQueries.Wrap(roundTripper)
+		t.Server.HTTP.Path(queryrange.RecentQueriesPath).Methods("GET").Handler(http.HandlerFunc(recentQueries.Handler))
+	}
 
 	frontendHandler := transport.NewHandler(t.Cfg.Frontend.Handler, roundTripper, util_log.Logger, prometheus.DefaultRegisterer)
 	if t.Cfg.Frontend.CompressResponses {
@@ -534,6 +850,12 @@ func (t *Loki) initQueryFrontend() (_ services.Service, err error) {
 
 	frontendHandler = middleware.Merge(
 		httpreq.ExtractQueryTagsMiddleware(),
+		httpreq.ExtractSeriesLimitStrategyMiddleware(),
+		httpreq.ExtractQueryLimitsOverrideMiddleware(),
+		httpreq.ExtractQueryStrictParsingMiddleware(),
+		httpreq.ExtractQuerySourceMiddleware(),
+		httpreq.ExtractQueryRoleMiddleware(),
+		httpreq.ExtractQueryScopeMiddleware(),
 		serverutil.RecoveryHTTPMiddleware,
 		t.HTTPAuthMiddleware,
 		queryrange.StatsHTTPMiddleware,
@@ -571,6 +893,7 @@ func (t *Loki) initQueryFrontend() (_ services.Service, err error) {
 	t.Server.HTTP.Path("/loki/api/v1/labels").Methods("GET", "POST").Handler(frontendHandler)
 	t.Server.HTTP.Path("/loki/api/v1/label/{name}/values").Methods("GET", "POST").Handler(frontendHandler)
 	t.Server.HTTP.Path("/loki/api/v1/series").Methods("GET", "POST").Handler(frontendHandler)
+	t.Server.HTTP.Path("/loki/api/v1/index/stats").Methods("GET", "POST").Handler(frontendHandler)
 	t.Server.HTTP.Path("/api/prom/query").Methods("GET", "POST").Handler(frontendHandler)
 	t.Server.HTTP.Path("/api/prom/label").Methods("GET", "POST").Handler(frontendHandler)
 	t.Server.HTTP.Path("/api/prom/label/{name}/values").Methods("GET", "POST").Handler(frontendHandler)
@@ -713,6 +1036,7 @@ func (t *Loki) initMemberlistKV() (services.Service, error) {
 	t.Cfg.MemberlistKV.Codecs = []codec.Codec{
 		ring.GetCodec(),
 		usagestats.JSONCodec,
+		tenantmigration.JSONCodec,
 	}
 
 	dnsProviderReg := prometheus.WrapRegistererWithPrefix(
@@ -733,7 +1057,7 @@ func (t *Loki) initCompactor() (services.Service, error) {
 	t.Cfg.CompactorConfig.CompactorRing.ListenPort = t.Cfg.Server.GRPCListenPort
 	t.Cfg.CompactorConfig.CompactorRing.KVStore.MemberlistKV = t.MemberlistKV.GetMemberlistKV
 
-	if !loki_storage.UsingBoltdbShipper(t.Cfg.SchemaConfig.Configs) {
+	if !loki_storage.AnyTenantUsing(t.Cfg.SchemaConfig.SchemaConfig, loki_storage.UsingBoltdbShipper) {
 		level.Info(util_log.Logger).Log("msg", "Not using boltdb-shipper index, not starting compactor")
 		return nil, nil
 	}
@@ -742,16 +1066,20 @@ func (t *Loki) initCompactor() (services.Service, error) {
 	if err != nil {
 		return nil, err
 	}
-	t.compactor, err = compactor.NewCompactor(t.Cfg.CompactorConfig, t.Cfg.StorageConfig.Config, t.Cfg.SchemaConfig, t.overrides, t.clientMetrics, prometheus.DefaultRegisterer)
+	t.Cfg.CompactorConfig.ChunkQuarantine = t.Cfg.StorageConfig.ChunkQuarantine
+	t.Cfg.CompactorConfig.ChunkBlooms = t.Cfg.StorageConfig.ChunkBlooms
+	t.compactor, err = compactor.NewCompactor(t.Cfg.CompactorConfig, t.Cfg.StorageConfig.Config, t.Cfg.SchemaConfig, t.overrides, t.clientMetrics, t.notifier, prometheus.DefaultRegisterer)
 	if err != nil {
 		return nil, err
 	}
 
 	t.Server.HTTP.Path("/compactor/ring").Methods("GET", "POST").Handler(t.compactor)
-	if t.Cfg.CompactorConfig.RetentionEnabled {
+	t.Server.HTTP.Path("/compactor/retention/undelete").Methods("POST").Handler(t.HTTPAuthMiddleware.Wrap(http.HandlerFunc(t.compactor.UndeleteHandler)))
+	if t.compactor.DeleteRequestsHandler != nil {
 		t.Server.HTTP.Path("/loki/api/admin/delete").Methods("PUT", "POST").Handler(t.HTTPAuthMiddleware.Wrap(http.HandlerFunc(t.compactor.DeleteRequestsHandler.AddDeleteRequestHandler)))
 		t.Server.HTTP.Path("/loki/api/admin/delete").Methods("GET").Handler(t.HTTPAuthMiddleware.Wrap(http.HandlerFunc(t.compactor.DeleteRequestsHandler.GetAllDeleteRequestsHandler)))
 		t.Server.HTTP.Path("/loki/api/admin/cancel_delete_request").Methods("PUT", "POST").Handler(t.HTTPAuthMiddleware.Wrap(http.HandlerFunc(t.compactor.DeleteRequestsHandler.CancelDeleteRequestHandler)))
+		t.Server.HTTP.Path("/loki/api/admin/delete_audit").Methods("GET").Handler(t.HTTPAuthMiddleware.Wrap(http.HandlerFunc(t.compactor.DeleteRequestsHandler.GetDeleteRequestAuditHandler)))
 	}
 
 	return t.compactor, nil
@@ -759,6 +1087,10 @@ func (t *Loki) initCompactor() (services.Service, error) {
 
 func (t *Loki) initIndexGateway() (services.Service, error) {
 	t.Cfg.StorageConfig.BoltDBShipperConfig.Mode = shipper.ModeReadOnly
+	if t.Cfg.IndexGateway.Mode == indexgateway.RingMode {
+		// only the index of the tenants owned by the instance in the ring is kept query ready.
+		t.Cfg.StorageConfig.BoltDBShipperConfig.OwnsTenant = t.indexGatewayRingManager.OwnsTenant
+	}
 	objectClient, err := chunk_storage.NewObjectClient(t.Cfg.StorageConfig.BoltDBShipperConfig.SharedStoreType, t.Cfg.StorageConfig.Config, t.clientMetrics)
 	if err != nil {
 		return nil, err
@@ -774,6 +1106,29 @@ func (t *Loki) initIndexGateway() (services.Service, error) {
 	return gateway, nil
 }
 
+func (t *Loki) initIndexGatewayRing() (services.Service, error) {
+	if t.Cfg.IndexGateway.Mode != indexgateway.RingMode {
+		return nil, nil
+	}
+	// the index gateways join the ring while the components querying the index only watch it.
+	server := t.Cfg.isModuleEnabled(IndexGateway)
+	if !server && !t.Cfg.isModuleEnabled(Querier) && !t.Cfg.isModuleEnabled(Ruler) && !t.Cfg.isModuleEnabled(Read) && !t.Cfg.isModuleEnabled(ScheduledQueries) {
+		return nil, nil
+	}
+
+	t.Cfg.IndexGateway.Ring.ListenPort = t.Cfg.Server.GRPCListenPort
+	t.Cfg.IndexGateway.Ring.KVStore.MemberlistKV = t.MemberlistKV.GetMemberlistKV
+
+	rm, err := indexgateway.NewRingManager(server, t.Cfg.IndexGateway, t.overrides, util_log.Logger, prometheus.DefaultRegisterer)
+	if err != nil {
+		return nil, err
+	}
+
+	t.Server.HTTP.Path("/indexgateway/ring").Methods("GET", "POST").Handler(rm)
+	t.indexGatewayRingManager = rm
+	return rm, nil
+}
+
 func (t *Loki) initQueryScheduler() (services.Service, error) {
 	// Set some config sections from other config sections in the config struct
 	t.Cfg.QueryScheduler.SchedulerRing.ListenPort = t.Cfg.Server.GRPCListenPort
@@ -820,9 +1175,36 @@ func (t *Loki) initUsageReport() (services.Service, error) {
 	return ur, nil
 }
 
+func (t *Loki) initTenantMigration() (services.Service, error) {
+	if !t.Cfg.TenantMigration.Enabled {
+		return nil, nil
+	}
+
+	// The migrations are stored in the KV store of the ingesters ring.
+	t.Cfg.Ingester.LifecyclerConfig.RingConfig.KVStore.MemberlistKV = t.MemberlistKV.GetMemberlistKV
+	m, err := tenantmigration.NewMigrator(t.Cfg.TenantMigration, t.Cfg.Ingester.LifecyclerConfig.RingConfig.KVStore, util_log.Logger, prometheus.DefaultRegisterer)
+	if err != nil {
+		return nil, err
+	}
+	t.tenantMigrator = m
+
+	t.Server.HTTP.Path("/tenant-migration/tenants").Methods("GET").HandlerFunc(m.TenantsHandler)
+	t.Server.HTTP.Path("/tenant-migration/tenants/{tenant}").Methods("POST", "DELETE").HandlerFunc(m.TenantHandler)
+	return m, nil
+}
+
+func (t *Loki) initGateway() (services.Service, error) {
+	g, err := gateway.New(t.Cfg.Gateway, util_log.Logger, prometheus.DefaultRegisterer)
+	if err != nil {
+		return nil, err
+	}
+	g.RegisterRoutes(t.Server.HTTP, t.HTTPAuthMiddleware)
+	return nil, nil
+}
+
 func (t *Loki) deleteRequestsStore() (deletion.DeleteRequestsStore, error) {
 	deleteStore := deletion.NewNoOpDeleteRequestsStore()
-	if loki_storage.UsingBoltdbShipper(t.Cfg.SchemaConfig.Configs) {
+	if loki_storage.AnyTenantUsing(t.Cfg.SchemaConfig.SchemaConfig, loki_storage.UsingBoltdbShipper) {
 		indexClient, err := chunk_storage.NewIndexClient(shipper.BoltDBShipperType, t.Cfg.StorageConfig.Config, t.Cfg.SchemaConfig.SchemaConfig, t.overrides, prometheus.DefaultRegisterer)
 		if err != nil {
 			return nil, err
@@ -880,6 +1262,25 @@ func boltdbShipperMinIngesterQueryStoreDuration(cfg Config) time.Duration {
 	return cfg.Ingester.MaxChunkAge + boltdbShipperIngesterIndexUploadDelay() + boltdbShipperQuerierIndexUpdateDelay(cfg) + 2*time.Minute
 }
 
+// tsdbMinIngesterQueryStoreDuration returns minimum duration(with some buffer) ingesters should query their stores to
+// avoid missing any logs or chunk ids due to the index of flushed chunks being built and uploaded periodically with tsdb.
+func tsdbMinIngesterQueryStoreDuration(cfg Config) time.Duration {
+	return cfg.Ingester.MaxChunkAge + cfg.StorageConfig.TSDBShipperConfig.BuildInterval + cfg.StorageConfig.TSDBShipperConfig.ResyncInterval + 2*time.Minute
+}
+
+// objectStorageIndexMinIngesterQueryStoreDuration returns the minimum duration ingesters should query their stores for the
+// index types keeping the index in the object storage which are used by the current or the next period config.
+func objectStorageIndexMinIngesterQueryStoreDuration(cfg Config) time.Duration {
+	var minDuration time.Duration
+	if loki_storage.AnyTenantUsing(cfg.SchemaConfig.SchemaConfig, loki_storage.UsingBoltdbShipper) {
+		minDuration = boltdbShipperMinIngesterQueryStoreDuration(cfg)
+	}
+	if d := tsdbMinIngesterQueryStoreDuration(cfg); loki_storage.AnyTenantUsing(cfg.SchemaConfig.SchemaConfig, loki_storage.UsingTSDB) && d > minDuration {
+		minDuration = d
+	}
+	return minDuration
+}
+
 // NewServerService constructs service from Server component.
 // servicesToWaitFor is called when server is stopping, and should return all
 // services that need to terminate before server actually stops.
@@ -4,6 +4,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/grafana/dskit/flagext"
+	"github.com/stretchr/testify/require"
+
 	"github.com/grafana/loki/pkg/storage/chunk"
 )
 
@@ -81,3 +84,25 @@ func Test_calculateMaxLookBack(t *testing.T) {
 		})
 	}
 }
+
+func Test_tenantOnlyIndexTypes(t *testing.T) {
+	cfg := chunk.SchemaConfig{
+		Configs: []chunk.PeriodConfig{{IndexType: "boltdb"}, {IndexType: "boltdb-shipper"}},
+	}
+	require.Empty(t, tenantOnlyIndexTypes(cfg))
+
+	cfg.TenantConfigs = map[string]*chunk.SchemaConfig{
+		"a": {Configs: []chunk.PeriodConfig{{IndexType: "boltdb"}, {IndexType: "tsdb"}}},
+		"b": {Configs: []chunk.PeriodConfig{{IndexType: "boltdb-shipper"}, {IndexType: "tsdb"}}},
+	}
+	require.Equal(t, []string{"tsdb"}, tenantOnlyIndexTypes(cfg))
+}
+
+func Test_filesystemRetentionIgnoredPrefixes(t *testing.T) {
+	var cfg Config
+	flagext.DefaultValues(&cfg)
+	require.ElementsMatch(t, []string{"index/", "tsdb-index/", "compactor-locks/", "quarantine/", "blooms/"}, filesystemRetentionIgnoredPrefixes(cfg))
+
+	cfg.CompactorConfig.SharedStoreKeyPrefix = "compactor-index/"
+	require.Contains(t, filesystemRetentionIgnoredPrefixes(cfg), "compactor-index/")
+}
@@ -3,13 +3,18 @@ package loki
 import (
 	"fmt"
 	"io"
+	"os"
+	"reflect"
 
 	"github.com/go-kit/log/level"
 	"github.com/grafana/dskit/kv"
 	"github.com/grafana/dskit/runtimeconfig"
+	"github.com/prometheus/common/model"
 	"gopkg.in/yaml.v2"
 
 	"github.com/grafana/loki/pkg/runtime"
+	loki_storage "github.com/grafana/loki/pkg/storage"
+	"github.com/grafana/loki/pkg/storage/chunk"
 	util_log "github.com/grafana/loki/pkg/util/log"
 	"github.com/grafana/loki/pkg/validation"
 )
@@ -21,6 +26,10 @@ type runtimeConfigValues struct {
 	TenantLimits map[string]*validation.Limits `yaml:"overrides"`
 	TenantConfig map[string]*runtime.Config    `yaml:"configs"`
 
+	// TenantSchemaConfigs are only read when Loki starts, changing them requires a restart. The ones
+	// changed afterwards are validated but not used until then.
+	TenantSchemaConfigs map[string]*chunk.SchemaConfig `yaml:"schema_configs"`
+
 	Multi kv.MultiRuntimeConfig `yaml:"multi_kv_config"`
 }
 
@@ -35,6 +44,19 @@ func (r runtimeConfigValues) validate() error {
 			return fmt.Errorf("invalid override for tenant %s: %w", t, err)
 		}
 	}
+	for t, c := range r.TenantSchemaConfigs {
+		if c == nil {
+			level.Warn(util_log.Logger).Log("msg", "skipping empty tenant schema config definition", "tenant", t)
+			continue
+		}
+
+		if len(c.Configs) == 0 {
+			return fmt.Errorf("invalid schema config for tenant %s: at least one period config is required", t)
+		}
+		if err := c.Validate(); err != nil {
+			return fmt.Errorf("invalid schema config for tenant %s: %w", t, err)
+		}
+	}
 	return nil
 }
 
@@ -52,6 +74,79 @@ func loadRuntimeConfig(r io.Reader) (interface{}, error) {
 	return overrides, nil
 }
 
+// loadTenantSchemaConfigs reads the schema configs of the tenants from the runtime config file.
+func loadTenantSchemaConfigs(path string) (map[string]*chunk.SchemaConfig, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read runtime config file: %w", err)
+	}
+	defer f.Close()
+
+	cfg, err := loadRuntimeConfig(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load runtime config file: %w", err)
+	}
+	return cfg.(*runtimeConfigValues).TenantSchemaConfigs, nil
+}
+
+// loadRuntimeConfig loads the runtime config, refusing the overrides pinning tenants to stores while their data is
+// scheduled to other stores by the schema config.
+func (t *Loki) loadRuntimeConfig(r io.Reader) (interface{}, error) {
+	cfg, err := loadRuntimeConfig(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.validateTenantSchemaConfigs(cfg.(*runtimeConfigValues).TenantSchemaConfigs); err != nil {
+		return nil, err
+	}
+	if t.Store == nil {
+		return cfg, nil
+	}
+
+	overrides, err := validation.NewOverrides(t.Cfg.LimitsConfig, staticTenantLimits(cfg.(*runtimeConfigValues).TenantLimits))
+	if err != nil {
+		return nil, err
+	}
+	schemaCfg := chunk.SchemaConfig{Configs: t.Store.GetSchemaConfigs(), TenantConfigs: t.Cfg.SchemaConfig.TenantConfigs}
+	if err := loki_storage.ValidateDataLocality(&t.Cfg.StorageConfig, schemaCfg, overrides, model.Now()); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// validateTenantSchemaConfigs checks the tenant schema configs of a runtime config being loaded against
+// the global periods, warning when they differ from the ones read at startup since they need a restart.
+func (t *Loki) validateTenantSchemaConfigs(tenantCfgs map[string]*chunk.SchemaConfig) error {
+	if len(tenantCfgs) == 0 && len(t.Cfg.SchemaConfig.TenantConfigs) == 0 {
+		return nil
+	}
+	configs := t.Cfg.SchemaConfig.Configs
+	if t.Store != nil {
+		configs = t.Store.GetSchemaConfigs()
+	}
+	// copy the periods in use, since validating applies their defaults.
+	schemaCfg := loki_storage.SchemaConfig{SchemaConfig: chunk.SchemaConfig{Configs: append([]chunk.PeriodConfig(nil), configs...), TenantConfigs: tenantCfgs}}
+	if err := schemaCfg.Validate(); err != nil {
+		return err
+	}
+
+	if !reflect.DeepEqual(tenantCfgs, t.Cfg.SchemaConfig.TenantConfigs) {
+		level.Warn(util_log.Logger).Log("msg", "the tenant schema configs of the runtime config changed, they are only used after a restart")
+	}
+	return nil
+}
+
+// staticTenantLimits are the limits of the tenants of a runtime config being loaded.
+type staticTenantLimits map[string]*validation.Limits
+
+func (l staticTenantLimits) AllByUserID() map[string]*validation.Limits {
+	return l
+}
+
+func (l staticTenantLimits) TenantLimits(userID string) *validation.Limits {
+	return l[userID]
+}
+
 type tenantLimitsFromRuntimeConfig struct {
 	c *runtimeconfig.Manager
 }
@@ -5,6 +5,7 @@ import (
 	"flag"
 	"io"
 	"io/ioutil"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -16,6 +17,8 @@ import (
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/stretchr/testify/require"
 
+	loki_storage "github.com/grafana/loki/pkg/storage"
+	"github.com/grafana/loki/pkg/storage/chunk"
 	"github.com/grafana/loki/pkg/validation"
 )
 
@@ -129,3 +132,129 @@ func Test_NoOverrides(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, time.Duration(defaults.QuerySplitDuration), overrides.QuerySplitDuration("foo"))
 }
+
+func Test_LoadTenantSchemaConfigs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "runtime.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`
+schema_configs:
+    "29":
+        configs:
+            - from: 2020-10-24
+              store: boltdb-shipper
+              object_store: filesystem
+              schema: v11
+              index:
+                  prefix: tenant_29_index_
+                  period: 24h
+`), 0o644))
+
+	configs, err := loadTenantSchemaConfigs(path)
+	require.NoError(t, err)
+	require.Len(t, configs, 1)
+	require.Len(t, configs["29"].Configs, 1)
+	require.Equal(t, "tenant_29_index_", configs["29"].Configs[0].IndexTables.Prefix)
+	require.Equal(t, 24*time.Hour, configs["29"].Configs[0].IndexTables.Period)
+
+	_, err = loadRuntimeConfig(strings.NewReader(`
+schema_configs:
+    "29":
+        configs: []
+`))
+	require.EqualError(t, err, "invalid schema config for tenant 29: at least one period config is required")
+}
+
+// schemaConfigsStore is a store of which only the periods are used.
+type schemaConfigsStore struct {
+	loki_storage.Store
+	configs []chunk.PeriodConfig
+}
+
+func (s schemaConfigsStore) GetSchemaConfigs() []chunk.PeriodConfig {
+	return s.configs
+}
+
+func Test_LoadRuntimeConfig_DataLocality(t *testing.T) {
+	flagset := flag.NewFlagSet("", flag.PanicOnError)
+	var defaults validation.Limits
+	defaults.RegisterFlags(flagset)
+	require.NoError(t, flagset.Parse(nil))
+	validation.SetDefaultLimitsForYAMLUnmarshalling(defaults)
+
+	l := &Loki{Cfg: Config{LimitsConfig: defaults}}
+	l.Cfg.StorageConfig.BoltDBShipperConfig.SharedStoreType = "gcs"
+	l.Store = schemaConfigsStore{configs: []chunk.PeriodConfig{
+		{From: chunk.DayTime{Time: 0}, IndexType: "boltdb-shipper", ObjectType: "gcs"},
+	}}
+
+	_, err := l.loadRuntimeConfig(strings.NewReader(`
+overrides:
+    "29":
+        allowed_object_stores: gcs
+`))
+	require.NoError(t, err)
+
+	_, err = l.loadRuntimeConfig(strings.NewReader(`
+overrides:
+    "29":
+        allowed_object_stores: azure
+`))
+	require.EqualError(t, err, "invalid allowed object stores: the data of tenant 29 is pinned to the stores azure, the period config starting at 1970-01-01 uses the store gcs")
+}
+
+func Test_LoadRuntimeConfig_TenantSchemaConfigs(t *testing.T) {
+	l := &Loki{}
+	l.Store = schemaConfigsStore{configs: []chunk.PeriodConfig{
+		{
+			From:        chunk.DayTime{Time: 0},
+			IndexType:   "boltdb-shipper",
+			ObjectType:  "filesystem",
+			Schema:      "v11",
+			IndexTables: chunk.PeriodicTableConfig{Prefix: "index_", Period: 24 * time.Hour},
+		},
+	}}
+
+	_, err := l.loadRuntimeConfig(strings.NewReader(`
+schema_configs:
+    "29":
+        configs:
+            - from: 2020-10-24
+              store: boltdb-shipper
+              object_store: filesystem
+              schema: v11
+              index:
+                  prefix: tenant_29_index_
+                  period: 24h
+`))
+	require.NoError(t, err)
+
+	// the tenant periods can use another index store.
+	_, err = l.loadRuntimeConfig(strings.NewReader(`
+schema_configs:
+    "29":
+        configs:
+            - from: 2020-10-24
+              store: tsdb
+              object_store: filesystem
+              schema: v12
+              index:
+                  prefix: tenant_29_index_
+                  period: 24h
+`))
+	require.NoError(t, err)
+
+	// but not share the tables of the periods using another index store.
+	_, err = l.loadRuntimeConfig(strings.NewReader(`
+schema_configs:
+    "29":
+        configs:
+            - from: 2020-10-24
+              store: tsdb
+              object_store: filesystem
+              schema: v11
+              index:
+                  prefix: index_
+                  period: 24h
+`))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "tenant 29: period config 0 starting at 2020-10-24: index.prefix: a tenant period config sharing its index prefix with an overlapping period config must have the same store")
+}
@@ -3,6 +3,7 @@ package lokifrontend
 import (
 	"flag"
 
+	"github.com/grafana/loki/pkg/lokifrontend/frontend"
 	"github.com/grafana/loki/pkg/lokifrontend/frontend/transport"
 	v1 "github.com/grafana/loki/pkg/lokifrontend/frontend/v1"
 	v2 "github.com/grafana/loki/pkg/lokifrontend/frontend/v2"
@@ -13,8 +14,9 @@ type Config struct {
 	FrontendV1 v1.Config               `yaml:",inline"`
 	FrontendV2 v2.Config               `yaml:",inline"`
 
-	CompressResponses bool   `yaml:"compress_responses"`
-	DownstreamURL     string `yaml:"downstream_url"`
+	CompressResponses bool                      `yaml:"compress_responses"`
+	DownstreamURL     string                    `yaml:"downstream_url"`
+	Downstream        frontend.DownstreamConfig `yaml:"downstream"`
 
 	TailProxyURL string `yaml:"tail_proxy_url"`
 }
@@ -27,6 +29,7 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 
 	f.BoolVar(&cfg.CompressResponses, "querier.compress-http-responses", false, "Compress HTTP responses.")
 	f.StringVar(&cfg.DownstreamURL, "frontend.downstream-url", "", "URL of downstream Prometheus.")
+	cfg.Downstream.RegisterFlags(f)
 
 	f.StringVar(&cfg.TailProxyURL, "frontend.tail-proxy-url", "", "URL of querier for tail proxy.")
 }
@@ -21,7 +21,8 @@ type CombinedFrontendConfig struct {
 	FrontendV1 v1.Config               `yaml:",inline"`
 	FrontendV2 v2.Config               `yaml:",inline"`
 
-	DownstreamURL string `yaml:"downstream_url"`
+	DownstreamURL string           `yaml:"downstream_url"`
+	Downstream    DownstreamConfig `yaml:"downstream"`
 }
 
 func (cfg *CombinedFrontendConfig) RegisterFlags(f *flag.FlagSet) {
@@ -30,6 +31,7 @@ func (cfg *CombinedFrontendConfig) RegisterFlags(f *flag.FlagSet) {
 	cfg.FrontendV2.RegisterFlags(f)
 
 	f.StringVar(&cfg.DownstreamURL, "frontend.downstream-url", "", "URL of downstream Prometheus.")
+	cfg.Downstream.RegisterFlags(f)
 }
 
 // InitFrontend initializes frontend (either V1 -- without scheduler, or V2 -- with scheduler) or no frontend at
@@ -40,10 +42,17 @@ func (cfg *CombinedFrontendConfig) RegisterFlags(f *flag.FlagSet) {
 // (if there are no errors), and it uses the returned frontend (if any).
 func InitFrontend(cfg CombinedFrontendConfig, ring ring.ReadRing, limits v1.Limits, grpcListenPort int, log log.Logger, reg prometheus.Registerer) (http.RoundTripper, *v1.Frontend, *v2.Frontend, error) {
 	switch {
-	case cfg.DownstreamURL != "":
-		// If the user has specified a downstream Prometheus, then we should use that.
-		rt, err := NewDownstreamRoundTripper(cfg.DownstreamURL, http.DefaultTransport)
-		return rt, nil, nil, err
+	case cfg.DownstreamURL != "" || len(cfg.Downstream.URLs) > 0:
+		// If the user has specified downstream queriers, then we should use them.
+		urls := cfg.Downstream.URLs
+		if cfg.DownstreamURL != "" {
+			urls = append([]string{cfg.DownstreamURL}, urls...)
+		}
+		rt, err := NewDownstreamRoundTripper(urls, cfg.Downstream, nil, log, reg)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return rt, nil, nil, nil
 	case cfg.FrontendV2.SchedulerAddress != "" || ring != nil:
 		// If query-scheduler address is configured, use Frontend.
 		if cfg.FrontendV2.Addr == "" {
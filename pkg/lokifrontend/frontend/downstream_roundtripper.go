@@ -1,29 +1,135 @@
 package frontend
 
 import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"path"
+	"time"
 
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	dstls "github.com/grafana/dskit/crypto/tls"
+	"github.com/grafana/dskit/flagext"
+	"github.com/grafana/dskit/services"
 	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/atomic"
 )
 
-// RoundTripper that forwards requests to downstream URL.
-type downstreamRoundTripper struct {
-	downstreamURL *url.URL
-	transport     http.RoundTripper
+// DownstreamConfig configures the forwarding of the queries to the downstream queriers, when the frontend runs in
+// downstream URL mode.
+type DownstreamConfig struct {
+	URLs                flagext.StringSliceCSV `yaml:"urls"`
+	HealthCheckPath     string                 `yaml:"health_check_path"`
+	HealthCheckInterval time.Duration          `yaml:"health_check_interval"`
+	HealthCheckTimeout  time.Duration          `yaml:"health_check_timeout"`
+	MaxRetries          int                    `yaml:"max_retries"`
+	TLSEnabled          bool                   `yaml:"tls_enabled"`
+	TLS                 dstls.ClientConfig     `yaml:",inline"`
 }
 
-func NewDownstreamRoundTripper(downstreamURL string, transport http.RoundTripper) (http.RoundTripper, error) {
-	u, err := url.Parse(downstreamURL)
-	if err != nil {
-		return nil, err
+// RegisterFlags registers the flags of the downstream queriers.
+func (cfg *DownstreamConfig) RegisterFlags(f *flag.FlagSet) {
+	f.Var(&cfg.URLs, "frontend.downstream.urls", "Comma separated list of the URLs of the downstream queriers the queries are balanced across, besides -frontend.downstream-url.")
+	f.StringVar(&cfg.HealthCheckPath, "frontend.downstream.health-check-path", "/ready", "Path of the downstream queriers checked for their health.")
+	f.DurationVar(&cfg.HealthCheckInterval, "frontend.downstream.health-check-interval", 5*time.Second, "How often the health of the downstream queriers is checked. The queries are sent to the healthy ones. 0 to disable the health checks.")
+	f.DurationVar(&cfg.HealthCheckTimeout, "frontend.downstream.health-check-timeout", time.Second, "Timeout of the health checks of the downstream queriers.")
+	f.IntVar(&cfg.MaxRetries, "frontend.downstream.max-retries", 2, "Maximum number of times the GET queries failing with a network error or a 502, 503 or 504 status are retried on the next downstream querier.")
+	f.BoolVar(&cfg.TLSEnabled, "frontend.downstream.tls-enabled", false, "Enable TLS for the connections to the downstream queriers.")
+	cfg.TLS.RegisterFlagsWithPrefix("frontend.downstream", f)
+}
+
+// downstreamEndpoint is a downstream querier.
+type downstreamEndpoint struct {
+	url     *url.URL
+	healthy atomic.Bool
+}
+
+// DownstreamRoundTripper forwards the requests to the downstream queriers in round robin, skipping the unhealthy
+// ones, and retries the idempotent requests failing on the next querier. It checks the health of the queriers
+// while running.
+type DownstreamRoundTripper struct {
+	services.Service
+
+	cfg       DownstreamConfig
+	endpoints []*downstreamEndpoint
+	next      atomic.Uint64
+	transport http.RoundTripper
+	logger    log.Logger
+
+	retries         prometheus.Counter
+	endpointHealthy *prometheus.GaugeVec
+}
+
+// NewDownstreamRoundTripper returns a round tripper forwarding the requests to the downstream queriers of the URLs.
+// The transport of the config is used when transport is nil.
+func NewDownstreamRoundTripper(urls []string, cfg DownstreamConfig, transport http.RoundTripper, logger log.Logger, reg prometheus.Registerer) (*DownstreamRoundTripper, error) {
+	if len(urls) == 0 {
+		return nil, errors.New("no downstream URL")
+	}
+	if transport == nil {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		if cfg.TLSEnabled {
+			tlsConfig, err := cfg.TLS.GetTLSConfig()
+			if err != nil {
+				return nil, err
+			}
+			t.TLSClientConfig = tlsConfig
+		}
+		transport = t
 	}
 
-	return &downstreamRoundTripper{downstreamURL: u, transport: transport}, nil
+	d := &DownstreamRoundTripper{
+		cfg:       cfg,
+		transport: transport,
+		logger:    log.With(logger, "component", "frontend-downstream"),
+		retries: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Namespace: "loki",
+			Name:      "frontend_downstream_retries_total",
+			Help:      "Total number of requests retried on another downstream querier.",
+		}),
+		endpointHealthy: promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "loki",
+			Name:      "frontend_downstream_endpoint_healthy",
+			Help:      "Whether a downstream querier is healthy.",
+		}, []string{"endpoint"}),
+	}
+	for _, rawURL := range urls {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return nil, err
+		}
+		e := &downstreamEndpoint{url: u}
+		d.setHealthy(e, true)
+		d.endpoints = append(d.endpoints, e)
+	}
+
+	if cfg.HealthCheckInterval > 0 {
+		d.Service = services.NewTimerService(cfg.HealthCheckInterval, d.checkHealth, d.checkHealth, nil)
+	} else {
+		d.Service = services.NewIdleService(nil, nil)
+	}
+	return d, nil
+}
+
+// CheckReady reports the frontend ready when a downstream querier is healthy.
+func (d *DownstreamRoundTripper) CheckReady(_ context.Context) error {
+	for _, e := range d.endpoints {
+		if e.healthy.Load() {
+			return nil
+		}
+	}
+	return errors.New("no healthy downstream querier")
 }
 
-func (d downstreamRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+func (d *DownstreamRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
 	tracer, span := opentracing.GlobalTracer(), opentracing.SpanFromContext(r.Context())
 	if tracer != nil && span != nil {
 		carrier := opentracing.HTTPHeadersCarrier(r.Header)
@@ -33,9 +139,115 @@ func (d downstreamRoundTripper) RoundTrip(r *http.Request) (*http.Response, erro
 		}
 	}
 
-	r.URL.Scheme = d.downstreamURL.Scheme
-	r.URL.Host = d.downstreamURL.Host
-	r.URL.Path = path.Join(d.downstreamURL.Path, r.URL.Path)
-	r.Host = ""
-	return d.transport.RoundTrip(r)
+	attempts := 1
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		attempts += d.cfg.MaxRetries
+	}
+	endpoints := d.pick()
+	for i := 0; ; i++ {
+		e := endpoints[i%len(endpoints)]
+		resp, err := d.roundTrip(r, e)
+		if i == attempts-1 || r.Context().Err() != nil || !retryable(resp, err) {
+			return resp, err
+		}
+		if resp != nil {
+			_, _ = io.Copy(ioutil.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+		level.Warn(d.logger).Log("msg", "retrying request on the next downstream querier", "endpoint", e.url.Host, "status", status(resp), "err", err)
+		d.retries.Inc()
+	}
+}
+
+// pick returns the healthy downstream queriers, starting with the next one in round robin. All the queriers are
+// returned when none is healthy.
+func (d *DownstreamRoundTripper) pick() []*downstreamEndpoint {
+	start := int(d.next.Inc() % uint64(len(d.endpoints)))
+	healthy := make([]*downstreamEndpoint, 0, len(d.endpoints))
+	for i := range d.endpoints {
+		if e := d.endpoints[(start+i)%len(d.endpoints)]; e.healthy.Load() {
+			healthy = append(healthy, e)
+		}
+	}
+	if len(healthy) == 0 {
+		for i := range d.endpoints {
+			healthy = append(healthy, d.endpoints[(start+i)%len(d.endpoints)])
+		}
+	}
+	return healthy
+}
+
+func (d *DownstreamRoundTripper) roundTrip(r *http.Request, e *downstreamEndpoint) (*http.Response, error) {
+	req := r.Clone(r.Context())
+	req.URL.Scheme = e.url.Scheme
+	req.URL.Host = e.url.Host
+	req.URL.Path = path.Join(e.url.Path, r.URL.Path)
+	req.Host = ""
+	resp, err := d.transport.RoundTrip(req)
+	if err != nil && r.Context().Err() == nil && d.cfg.HealthCheckInterval > 0 {
+		// the querier is skipped until its health check succeeds.
+		d.setHealthy(e, false)
+	}
+	return resp, err
+}
+
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func status(resp *http.Response) string {
+	if resp == nil {
+		return ""
+	}
+	return resp.Status
+}
+
+// checkHealth checks the health of each downstream querier.
+func (d *DownstreamRoundTripper) checkHealth(ctx context.Context) error {
+	for _, e := range d.endpoints {
+		err := d.checkEndpoint(ctx, e)
+		if healthy := err == nil; healthy != e.healthy.Load() {
+			level.Info(d.logger).Log("msg", "downstream querier health changed", "endpoint", e.url.Host, "healthy", healthy, "err", err)
+			d.setHealthy(e, healthy)
+		}
+	}
+	return nil
+}
+
+func (d *DownstreamRoundTripper) checkEndpoint(ctx context.Context, e *downstreamEndpoint) error {
+	ctx, cancel := context.WithTimeout(ctx, d.cfg.HealthCheckTimeout)
+	defer cancel()
+
+	u := *e.url
+	u.Path = path.Join(u.Path, d.cfg.HealthCheckPath)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := d.transport.RoundTrip(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("health check returned %s", resp.Status)
+	}
+	return nil
+}
+
+func (d *DownstreamRoundTripper) setHealthy(e *downstreamEndpoint, healthy bool) {
+	e.healthy.Store(healthy)
+	value := 0.0
+	if healthy {
+		value = 1
+	}
+	d.endpointHealthy.WithLabelValues(e.url.Host).Set(value)
 }
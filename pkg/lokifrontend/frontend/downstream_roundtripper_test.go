@@ -0,0 +1,124 @@
+package frontend
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/services"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+func newDownstreamServer(t *testing.T, name string, status *atomic.Int32, requests *atomic.Int32) *httptest.Server {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ready" {
+			w.WriteHeader(int(status.Load()))
+			return
+		}
+		requests.Inc()
+		w.WriteHeader(int(status.Load()))
+		_, _ = w.Write([]byte(name + r.URL.Path))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func roundTrip(t *testing.T, rt http.RoundTripper, method string) (int, string) {
+	req, err := http.NewRequest(method, "http://frontend/loki/api/v1/query_range", nil)
+	require.NoError(t, err)
+	resp, err := rt.RoundTrip(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, string(body)
+}
+
+func TestDownstreamRoundTripper(t *testing.T) {
+	var statusA, statusB, requestsA, requestsB atomic.Int32
+	statusA.Store(http.StatusOK)
+	statusB.Store(http.StatusOK)
+	a := newDownstreamServer(t, "a", &statusA, &requestsA)
+	b := newDownstreamServer(t, "b", &statusB, &requestsB)
+
+	cfg := DownstreamConfig{HealthCheckPath: "/ready", HealthCheckTimeout: time.Second, MaxRetries: 1}
+	rt, err := NewDownstreamRoundTripper([]string{a.URL, b.URL}, cfg, http.DefaultTransport, log.NewNopLogger(), prometheus.NewRegistry())
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), rt))
+	defer services.StopAndAwaitTerminated(context.Background(), rt) //nolint:errcheck
+
+	// the requests are balanced across the queriers.
+	for i := 0; i < 4; i++ {
+		status, _ := roundTrip(t, rt, http.MethodGet)
+		require.Equal(t, http.StatusOK, status)
+	}
+	require.Equal(t, int32(2), requestsA.Load())
+	require.Equal(t, int32(2), requestsB.Load())
+
+	// the GET requests failing are retried on the next querier.
+	statusA.Store(http.StatusServiceUnavailable)
+	for i := 0; i < 4; i++ {
+		status, body := roundTrip(t, rt, http.MethodGet)
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, "b/loki/api/v1/query_range", body)
+	}
+	require.Equal(t, float64(2), testutil.ToFloat64(rt.retries))
+
+	// the other requests aren't.
+	statuses := map[int]int{}
+	for i := 0; i < 2; i++ {
+		status, _ := roundTrip(t, rt, http.MethodPost)
+		statuses[status]++
+	}
+	require.Equal(t, map[int]int{http.StatusOK: 1, http.StatusServiceUnavailable: 1}, statuses)
+
+	// the unhealthy querier is skipped.
+	require.NoError(t, rt.checkHealth(context.Background()))
+	requestsA.Store(0)
+	for i := 0; i < 4; i++ {
+		status, _ := roundTrip(t, rt, http.MethodPost)
+		require.Equal(t, http.StatusOK, status)
+	}
+	require.Equal(t, int32(0), requestsA.Load())
+	require.NoError(t, rt.CheckReady(context.Background()))
+
+	// the queriers are all tried when none is healthy.
+	statusB.Store(http.StatusServiceUnavailable)
+	require.NoError(t, rt.checkHealth(context.Background()))
+	require.Error(t, rt.CheckReady(context.Background()))
+	status, _ := roundTrip(t, rt, http.MethodGet)
+	require.Equal(t, http.StatusServiceUnavailable, status)
+	require.Equal(t, int32(1), requestsA.Load())
+
+	statusA.Store(http.StatusOK)
+	require.NoError(t, rt.checkHealth(context.Background()))
+	require.NoError(t, rt.CheckReady(context.Background()))
+}
+
+func TestDownstreamRoundTripper_NetworkError(t *testing.T) {
+	var status, requests atomic.Int32
+	status.Store(http.StatusOK)
+	up := newDownstreamServer(t, "up", &status, &requests)
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	cfg := DownstreamConfig{HealthCheckPath: "/ready", HealthCheckInterval: time.Hour, HealthCheckTimeout: time.Second, MaxRetries: 1}
+	rt, err := NewDownstreamRoundTripper([]string{down.URL, up.URL}, cfg, http.DefaultTransport, log.NewNopLogger(), prometheus.NewRegistry())
+	require.NoError(t, err)
+
+	for i := 0; i < 4; i++ {
+		status, body := roundTrip(t, rt, http.MethodGet)
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, "up/loki/api/v1/query_range", body)
+	}
+	// the querier failing is skipped after its first failure.
+	require.Equal(t, float64(1), testutil.ToFloat64(rt.retries))
+	require.False(t, rt.endpoints[0].healthy.Load())
+}
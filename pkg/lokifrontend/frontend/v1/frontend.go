@@ -5,6 +5,7 @@ import (
 	"flag"
 	"fmt"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/go-kit/log"
@@ -22,10 +23,11 @@ import (
 	"github.com/grafana/loki/pkg/tenant"
 	"github.com/grafana/loki/pkg/util"
 	lokigrpc "github.com/grafana/loki/pkg/util/httpgrpc"
+	serverutil "github.com/grafana/loki/pkg/util/server"
 	"github.com/grafana/loki/pkg/util/validation"
 )
 
-var errTooManyRequest = httpgrpc.Errorf(http.StatusTooManyRequests, "too many outstanding requests")
+const errTooManyRequest = "too many outstanding requests"
 
 // Config for a Frontend.
 type Config struct {
@@ -56,6 +58,11 @@ type Frontend struct {
 	requestQueue *queue.RequestQueue
 	activeUsers  *util.ActiveUsersCleanupService
 
+	// queueWaitsMtx guards the time the last dequeued request of each tenant waited in the queue,
+	// which the tenants are told to wait for when their queue is full.
+	queueWaitsMtx sync.Mutex
+	queueWaits    map[string]time.Duration
+
 	// Subservices manager.
 	subservices        *services.Manager
 	subservicesWatcher *services.FailureWatcher
@@ -68,6 +75,7 @@ type Frontend struct {
 }
 
 type request struct {
+	tenantID    string
 	enqueueTime time.Time
 	queueSpan   opentracing.Span
 	originalCtx context.Context
@@ -80,9 +88,10 @@ type request struct {
 // New creates a new frontend. Frontend implements service, and must be started and stopped.
 func New(cfg Config, limits Limits, log log.Logger, registerer prometheus.Registerer) (*Frontend, error) {
 	f := &Frontend{
-		cfg:    cfg,
-		log:    log,
-		limits: limits,
+		cfg:        cfg,
+		log:        log,
+		limits:     limits,
+		queueWaits: map[string]time.Duration{},
 		queueLength: promauto.With(registerer).NewGaugeVec(prometheus.GaugeOpts{
 			Name: "cortex_query_frontend_queue_length",
 			Help: "Number of queries in the queue.",
@@ -145,6 +154,10 @@ func (f *Frontend) stopping(_ error) error {
 func (f *Frontend) cleanupInactiveUserMetrics(user string) {
 	f.queueLength.DeleteLabelValues(user)
 	f.discardedRequests.DeleteLabelValues(user)
+
+	f.queueWaitsMtx.Lock()
+	delete(f.queueWaits, user)
+	f.queueWaitsMtx.Unlock()
 }
 
 // RoundTripGRPC round trips a proto (instead of a HTTP request).
@@ -193,7 +206,8 @@ func (f *Frontend) Process(server frontendv1pb.Frontend_ProcessServer) error {
 		return err
 	}
 
-	f.requestQueue.RegisterQuerierConnection(querierID)
+	// Querier pools are only supported by the query scheduler.
+	f.requestQueue.RegisterQuerierConnection(querierID, "")
 	defer f.requestQueue.UnregisterQuerierConnection(querierID)
 
 	lastUserIndex := queue.FirstUser()
@@ -207,7 +221,9 @@ func (f *Frontend) Process(server frontendv1pb.Frontend_ProcessServer) error {
 
 		req := reqWrapper.(*request)
 
-		f.queueDuration.Observe(time.Since(req.enqueueTime).Seconds())
+		queueWait := time.Since(req.enqueueTime)
+		f.queueDuration.Observe(queueWait.Seconds())
+		f.observeQueueWait(req.tenantID, queueWait)
 		req.queueSpan.Finish()
 
 		/*
@@ -318,15 +334,35 @@ func (f *Frontend) queueRequest(ctx context.Context, req *request) error {
 	maxQueriers := validation.SmallestPositiveNonZeroIntPerTenant(tenantIDs, f.limits.MaxQueriersPerUser)
 
 	joinedTenantID := tenant.JoinTenantIDs(tenantIDs)
+	req.tenantID = joinedTenantID
 	f.activeUsers.UpdateUserTimestamp(joinedTenantID, now)
 
-	err = f.requestQueue.EnqueueRequest(joinedTenantID, req, maxQueriers, nil)
+	err = f.requestQueue.EnqueueRequest(joinedTenantID, req, maxQueriers, "", nil)
 	if err == queue.ErrTooManyRequests {
-		return errTooManyRequest
+		headers := http.Header{}
+		headers.Set(serverutil.RetryAfterHeader, serverutil.RetryAfterSeconds(f.retryAfter(joinedTenantID)))
+		return serverutil.ErrorWithHeaders(http.StatusTooManyRequests, headers, errTooManyRequest)
 	}
 	return err
 }
 
+func (f *Frontend) observeQueueWait(tenantID string, wait time.Duration) {
+	f.queueWaitsMtx.Lock()
+	defer f.queueWaitsMtx.Unlock()
+	f.queueWaits[tenantID] = wait
+}
+
+// retryAfter returns how long the tenant should wait before retrying when its queue is full: the
+// requests are dequeued about as fast as the last dequeued one waited in the queue.
+func (f *Frontend) retryAfter(tenantID string) time.Duration {
+	f.queueWaitsMtx.Lock()
+	defer f.queueWaitsMtx.Unlock()
+	if wait := f.queueWaits[tenantID]; wait > time.Second {
+		return wait
+	}
+	return time.Second
+}
+
 // CheckReady determines if the query frontend is ready.  Function parameters/return
 // chosen to match the same method in the ingester
 func (f *Frontend) CheckReady(_ context.Context) error {
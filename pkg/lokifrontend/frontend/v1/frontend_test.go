@@ -23,6 +23,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/uber/jaeger-client-go"
 	"github.com/uber/jaeger-client-go/config"
+	"github.com/weaveworks/common/httpgrpc"
 	httpgrpc_server "github.com/weaveworks/common/httpgrpc/server"
 	"github.com/weaveworks/common/middleware"
 	"github.com/weaveworks/common/user"
@@ -33,6 +34,7 @@ import (
 	"github.com/grafana/loki/pkg/lokifrontend/frontend/v1/frontendv1pb"
 	querier_worker "github.com/grafana/loki/pkg/querier/worker"
 	"github.com/grafana/loki/pkg/scheduler/queue"
+	serverutil "github.com/grafana/loki/pkg/util/server"
 )
 
 const (
@@ -134,7 +136,7 @@ func TestFrontendCheckReady(t *testing.T) {
 				),
 			}
 			for i := 0; i < tt.connectedClients; i++ {
-				f.requestQueue.RegisterQuerierConnection("test")
+				f.requestQueue.RegisterQuerierConnection("test", "")
 			}
 			err := f.CheckReady(context.Background())
 			errMsg := ""
@@ -224,6 +226,29 @@ func TestFrontendMetricsCleanup(t *testing.T) {
 	}
 }
 
+func TestFrontendQueueFullRetryAfter(t *testing.T) {
+	f, err := New(Config{MaxOutstandingPerTenant: 1}, limits{}, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+	require.NoError(t, err)
+	ctx := user.InjectOrgID(context.Background(), "1")
+
+	require.NoError(t, f.queueRequest(ctx, &request{}))
+	for _, tc := range []struct {
+		queueWait  time.Duration
+		retryAfter string
+	}{
+		// the tenants wait at least a second.
+		{0, "1"},
+		{2500 * time.Millisecond, "3"},
+	} {
+		f.observeQueueWait("1", tc.queueWait)
+		err = f.queueRequest(ctx, &request{})
+		resp, ok := httpgrpc.HTTPResponseFromError(err)
+		require.True(t, ok)
+		require.Equal(t, int32(http.StatusTooManyRequests), resp.Code)
+		require.Equal(t, []*httpgrpc.Header{{Key: serverutil.RetryAfterHeader, Values: []string{tc.retryAfter}}}, resp.Headers)
+	}
+}
+
 func testFrontend(t *testing.T, config Config, handler http.Handler, test func(addr string, frontend *Frontend), matchMaxConcurrency bool, reg prometheus.Registerer) {
 	logger := log.NewNopLogger()
 
@@ -0,0 +1,78 @@
+package notifications
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Config configures the notifications of operational events.
+type Config struct {
+	QueueCapacity      int             `yaml:"queue_capacity"`
+	Timeout            time.Duration   `yaml:"timeout"`
+	LimitEventInterval time.Duration   `yaml:"limit_event_interval"`
+	Webhooks           []WebhookConfig `yaml:"webhooks"`
+}
+
+// RegisterFlags adds the flags required to config this to the given FlagSet.
+func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
+	f.IntVar(&cfg.QueueCapacity, "notifications.queue-capacity", 1000, "Number of events queued for delivery. Events are dropped when the queue is full.")
+	f.DurationVar(&cfg.Timeout, "notifications.timeout", 10*time.Second, "Timeout for delivering an event to a webhook.")
+	f.DurationVar(&cfg.LimitEventInterval, "notifications.limit-event-interval", 5*time.Minute, "Minimum interval between two ingestion limit events for the same tenant and limit.")
+}
+
+// Validate validates the config.
+func (cfg *Config) Validate() error {
+	if cfg.QueueCapacity <= 0 {
+		return errors.New("notifications queue capacity must be positive")
+	}
+
+	names := make(map[string]struct{}, len(cfg.Webhooks))
+	for _, wh := range cfg.Webhooks {
+		if _, ok := names[wh.Name]; ok {
+			return fmt.Errorf("duplicate notification webhook name %q", wh.Name)
+		}
+		names[wh.Name] = struct{}{}
+
+		if err := wh.Validate(); err != nil {
+			return fmt.Errorf("invalid notification webhook %q: %w", wh.Name, err)
+		}
+	}
+	return nil
+}
+
+// Enabled tells if any receiver of events is configured.
+func (cfg *Config) Enabled() bool {
+	return len(cfg.Webhooks) > 0
+}
+
+// WebhookConfig is an HTTP endpoint events are posted to as JSON.
+type WebhookConfig struct {
+	Name    string            `yaml:"name"`
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"`
+	// Events are the types of events sent to the webhook, all of them if empty.
+	Events []EventType `yaml:"events"`
+}
+
+// Validate validates the webhook config.
+func (cfg *WebhookConfig) Validate() error {
+	if cfg.Name == "" {
+		return errors.New("name is required")
+	}
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return fmt.Errorf("invalid url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("url must be http or https: %q", cfg.URL)
+	}
+	for _, e := range cfg.Events {
+		if err := validEventType(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
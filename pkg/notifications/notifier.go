@@ -0,0 +1,232 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/services"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// EventType is the type of an operational event.
+type EventType string
+
+const (
+	// IngestionLimitReached is emitted when the pushes of a tenant are rejected because of a limit.
+	IngestionLimitReached EventType = "ingestion_limit_reached"
+	// DeleteRequestCompleted is emitted when the logs of a delete request have been deleted.
+	DeleteRequestCompleted EventType = "delete_request_completed"
+	// RetentionRunFinished is emitted when the compactor finished applying retention.
+	RetentionRunFinished EventType = "retention_run_finished"
+	// SchemaPeriodActivated is emitted when a new period config of the schema config starts.
+	SchemaPeriodActivated EventType = "schema_period_activated"
+)
+
+// EventTypes are all the types of events.
+var EventTypes = []EventType{IngestionLimitReached, DeleteRequestCompleted, RetentionRunFinished, SchemaPeriodActivated}
+
+// Event is an operational event.
+type Event struct {
+	Type      EventType         `json:"type"`
+	Tenant    string            `json:"tenant,omitempty"`
+	Timestamp time.Time         `json:"timestamp"`
+	Details   map[string]string `json:"details,omitempty"`
+}
+
+// NewEvent returns an event of the given type happening now.
+func NewEvent(typ EventType, tenant string, details map[string]string) Event {
+	return Event{Type: typ, Tenant: tenant, Timestamp: time.Now().UTC(), Details: details}
+}
+
+// Notifier is notified of operational events. Notify must not block, it is
+// called on the hot path of pushes.
+type Notifier interface {
+	Notify(e Event)
+}
+
+type noopNotifier struct{}
+
+func (noopNotifier) Notify(Event) {}
+
+// Noop is a Notifier discarding all events.
+var Noop Notifier = noopNotifier{}
+
+// Receiver delivers events to an external system.
+type Receiver interface {
+	Name() string
+	Send(ctx context.Context, e Event) error
+}
+
+type receiver struct {
+	Receiver
+	events map[EventType]bool
+}
+
+func (r receiver) accepts(typ EventType) bool {
+	return len(r.events) == 0 || r.events[typ]
+}
+
+type metrics struct {
+	sent    *prometheus.CounterVec
+	dropped *prometheus.CounterVec
+}
+
+func newMetrics(r prometheus.Registerer) *metrics {
+	return &metrics{
+		sent: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "loki",
+			Name:      "notifications_sent_total",
+			Help:      "Total number of notifications sent to a receiver, by event type and status.",
+		}, []string{"receiver", "event", "status"}),
+		dropped: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "loki",
+			Name:      "notifications_dropped_total",
+			Help:      "Total number of notifications dropped because the queue was full.",
+		}, []string{"event"}),
+	}
+}
+
+// Manager queues the events it is notified of and delivers them to its
+// receivers in the background.
+type Manager struct {
+	services.Service
+
+	cfg       Config
+	receivers []receiver
+	queue     chan Event
+
+	// lastLimitEvents is the time of the last ingestion limit event per tenant and limit, the events older than
+	// the limit interval being pruned every interval.
+	lastLimitEventsMtx    sync.Mutex
+	lastLimitEvents       map[string]time.Time
+	lastLimitEventsPruned time.Time
+
+	metrics *metrics
+	logger  log.Logger
+}
+
+// NewManager creates a manager delivering events to the webhooks of the config.
+func NewManager(cfg Config, r prometheus.Registerer, logger log.Logger) *Manager {
+	m := &Manager{
+		cfg:             cfg,
+		queue:           make(chan Event, cfg.QueueCapacity),
+		lastLimitEvents: map[string]time.Time{},
+		metrics:         newMetrics(r),
+		logger:          logger,
+	}
+
+	for _, wh := range cfg.Webhooks {
+		m.AddReceiver(newWebhook(wh, cfg.Timeout), wh.Events...)
+	}
+
+	m.Service = services.NewBasicService(nil, m.running, m.stopping)
+	return m
+}
+
+// AddReceiver adds a receiver of the given types of events, or of all events
+// if none are given. It must be called before the manager is started.
+func (m *Manager) AddReceiver(r Receiver, events ...EventType) {
+	rcv := receiver{Receiver: r, events: make(map[EventType]bool, len(events))}
+	for _, e := range events {
+		rcv.events[e] = true
+	}
+	m.receivers = append(m.receivers, rcv)
+}
+
+// Notify queues the event, or drops it if the queue is full. Ingestion limit
+// events are only queued once per limit interval for a tenant and limit.
+func (m *Manager) Notify(e Event) {
+	if e.Type == IngestionLimitReached && !m.allowLimitEvent(e) {
+		return
+	}
+
+	select {
+	case m.queue <- e:
+	default:
+		m.metrics.dropped.WithLabelValues(string(e.Type)).Inc()
+	}
+}
+
+func (m *Manager) allowLimitEvent(e Event) bool {
+	key := e.Tenant + "/" + e.Details["limit"]
+
+	m.lastLimitEventsMtx.Lock()
+	defer m.lastLimitEventsMtx.Unlock()
+
+	if last, ok := m.lastLimitEvents[key]; ok && e.Timestamp.Sub(last) < m.cfg.LimitEventInterval {
+		return false
+	}
+	m.lastLimitEvents[key] = e.Timestamp
+	m.pruneLimitEvents(e.Timestamp)
+	return true
+}
+
+// pruneLimitEvents forgets the limit events which no longer hold back the next ones. It must be called with the
+// limit events lock held.
+func (m *Manager) pruneLimitEvents(now time.Time) {
+	if now.Sub(m.lastLimitEventsPruned) < m.cfg.LimitEventInterval {
+		return
+	}
+	m.lastLimitEventsPruned = now
+	for key, last := range m.lastLimitEvents {
+		if now.Sub(last) >= m.cfg.LimitEventInterval {
+			delete(m.lastLimitEvents, key)
+		}
+	}
+}
+
+func (m *Manager) running(ctx context.Context) error {
+	for {
+		select {
+		case e := <-m.queue:
+			// Deliveries in flight are bounded by the timeout, not interrupted by shutdown.
+			m.deliver(context.Background(), e)
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// stopping delivers the events left in the queue.
+func (m *Manager) stopping(_ error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), m.cfg.Timeout)
+	defer cancel()
+
+	for {
+		select {
+		case e := <-m.queue:
+			m.deliver(ctx, e)
+		default:
+			return nil
+		}
+	}
+}
+
+func (m *Manager) deliver(ctx context.Context, e Event) {
+	for _, r := range m.receivers {
+		if !r.accepts(e.Type) {
+			continue
+		}
+
+		status := "success"
+		if err := r.Send(ctx, e); err != nil {
+			status = "failure"
+			level.Warn(m.logger).Log("msg", "failed to send notification", "receiver", r.Name(), "event", e.Type, "tenant", e.Tenant, "err", err)
+		}
+		m.metrics.sent.WithLabelValues(r.Name(), string(e.Type), status).Inc()
+	}
+}
+
+func validEventType(typ EventType) error {
+	for _, t := range EventTypes {
+		if t == typ {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown event type %q", typ)
+}
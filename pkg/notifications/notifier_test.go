@@ -0,0 +1,155 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/flagext"
+	"github.com/grafana/dskit/services"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingReceiver struct {
+	mtx    sync.Mutex
+	events []Event
+}
+
+func (r *recordingReceiver) Name() string { return "recording" }
+
+func (r *recordingReceiver) Send(_ context.Context, e Event) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.events = append(r.events, e)
+	return nil
+}
+
+func (r *recordingReceiver) received() []Event {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return append([]Event(nil), r.events...)
+}
+
+func newTestConfig() Config {
+	var cfg Config
+	flagext.DefaultValues(&cfg)
+	return cfg
+}
+
+func TestManager_Notify(t *testing.T) {
+	var (
+		received = make(chan Event, 10)
+		srv      = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, "secret", r.Header.Get("Authorization"))
+			var e Event
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&e))
+			received <- e
+		}))
+	)
+	defer srv.Close()
+
+	cfg := newTestConfig()
+	cfg.Webhooks = []WebhookConfig{{
+		Name:    "ops",
+		URL:     srv.URL,
+		Headers: map[string]string{"Authorization": "secret"},
+		Events:  []EventType{DeleteRequestCompleted},
+	}}
+	require.NoError(t, cfg.Validate())
+
+	m := NewManager(cfg, prometheus.NewRegistry(), log.NewNopLogger())
+	all := &recordingReceiver{}
+	m.AddReceiver(all)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), m))
+
+	m.Notify(NewEvent(RetentionRunFinished, "", map[string]string{"status": "success"}))
+	m.Notify(NewEvent(DeleteRequestCompleted, "tenant", map[string]string{"request_id": "1"}))
+
+	select {
+	case e := <-received:
+		require.Equal(t, DeleteRequestCompleted, e.Type)
+		require.Equal(t, "tenant", e.Tenant)
+		require.Equal(t, map[string]string{"request_id": "1"}, e.Details)
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not called")
+	}
+
+	require.NoError(t, services.StopAndAwaitTerminated(context.Background(), m))
+	require.Empty(t, received)
+	require.Len(t, all.received(), 2)
+	require.Equal(t, float64(1), testutil.ToFloat64(m.metrics.sent.WithLabelValues("ops", string(DeleteRequestCompleted), "success")))
+}
+
+func TestManager_ThrottlesLimitEvents(t *testing.T) {
+	m := NewManager(newTestConfig(), prometheus.NewRegistry(), log.NewNopLogger())
+	r := &recordingReceiver{}
+	m.AddReceiver(r)
+
+	event := func(tenant, limit string, ts time.Time) Event {
+		return Event{Type: IngestionLimitReached, Tenant: tenant, Timestamp: ts, Details: map[string]string{"limit": limit}}
+	}
+	now := time.Now()
+	m.Notify(event("a", "rate_limited", now))
+	m.Notify(event("a", "rate_limited", now.Add(time.Minute)))
+	m.Notify(event("a", "stream_limit", now.Add(time.Minute)))
+	m.Notify(event("b", "rate_limited", now.Add(time.Minute)))
+	m.Notify(event("a", "rate_limited", now.Add(6*time.Minute)))
+
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), m))
+	require.NoError(t, services.StopAndAwaitTerminated(context.Background(), m))
+	require.Len(t, r.received(), 4)
+}
+
+func TestManager_PrunesLimitEvents(t *testing.T) {
+	cfg := newTestConfig()
+	m := NewManager(cfg, prometheus.NewRegistry(), log.NewNopLogger())
+
+	event := func(tenant string, ts time.Time) Event {
+		return Event{Type: IngestionLimitReached, Tenant: tenant, Timestamp: ts, Details: map[string]string{"limit": "rate_limited"}}
+	}
+	now := time.Now()
+	m.Notify(event("a", now))
+	m.Notify(event("b", now.Add(time.Minute)))
+	require.Len(t, m.lastLimitEvents, 2)
+
+	// the events older than the interval are forgotten.
+	m.Notify(event("c", now.Add(2*cfg.LimitEventInterval+time.Minute)))
+	require.Len(t, m.lastLimitEvents, 1)
+	require.Contains(t, m.lastLimitEvents, "c/rate_limited")
+}
+
+func TestManager_DropsEventsWhenQueueIsFull(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.QueueCapacity = 1
+	m := NewManager(cfg, prometheus.NewRegistry(), log.NewNopLogger())
+
+	m.Notify(NewEvent(RetentionRunFinished, "", nil))
+	m.Notify(NewEvent(RetentionRunFinished, "", nil))
+	require.Equal(t, float64(1), testutil.ToFloat64(m.metrics.dropped.WithLabelValues(string(RetentionRunFinished))))
+}
+
+func TestWebhookConfig_Validate(t *testing.T) {
+	for _, tc := range []struct {
+		cfg WebhookConfig
+		err string
+	}{
+		{cfg: WebhookConfig{Name: "a", URL: "https://example.com/hook"}},
+		{cfg: WebhookConfig{URL: "https://example.com/hook"}, err: "name is required"},
+		{cfg: WebhookConfig{Name: "a", URL: "ftp://example.com"}, err: `url must be http or https: "ftp://example.com"`},
+		{cfg: WebhookConfig{Name: "a", URL: "https://example.com/hook", Events: []EventType{"foo"}}, err: `unknown event type "foo"`},
+	} {
+		err := tc.cfg.Validate()
+		if tc.err == "" {
+			require.NoError(t, err)
+			continue
+		}
+		require.EqualError(t, err, tc.err)
+	}
+}
@@ -0,0 +1,69 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// Webhook posts payloads to an HTTP endpoint. It is shared with the other components
+// delivering data to webhooks, like the scheduled queries.
+type Webhook struct {
+	URL string
+	// Headers are added to the requests, overriding the Content-Type as well.
+	Headers map[string]string
+	Client  *http.Client
+}
+
+// Post sends the body in a POST request, failing when the endpoint doesn't reply with a 2xx status.
+func (w *Webhook) Post(ctx context.Context, contentType string, body io.Reader) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range w.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("webhook returned status %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// webhook posts events as JSON to an HTTP endpoint.
+type webhook struct {
+	name string
+	Webhook
+}
+
+func newWebhook(cfg WebhookConfig, timeout time.Duration) *webhook {
+	return &webhook{
+		name:    cfg.Name,
+		Webhook: Webhook{URL: cfg.URL, Headers: cfg.Headers, Client: &http.Client{Timeout: timeout}},
+	}
+}
+
+func (w *webhook) Name() string { return w.name }
+
+func (w *webhook) Send(ctx context.Context, e Event) error {
+	body, err := jsoniter.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return w.Post(ctx, "application/json", bytes.NewReader(body))
+}
@@ -87,6 +87,10 @@ func (q *QuerierAPI) RangeQueryHandler(w http.ResponseWriter, r *http.Request) {
 		serverutil.WriteError(err, w)
 		return
 	}
+	if len(result.Warnings) > 0 {
+		// Incomplete results must not end up in the results cache of the query frontend.
+		w.Header().Set("Cache-Control", "no-store")
+	}
 	if err := marshal.WriteQueryResponseJSON(result, w); err != nil {
 		serverutil.WriteError(err, w)
 		return
@@ -126,6 +130,10 @@ func (q *QuerierAPI) InstantQueryHandler(w http.ResponseWriter, r *http.Request)
 		serverutil.WriteError(err, w)
 		return
 	}
+	if len(result.Warnings) > 0 {
+		// Incomplete results must not end up in the results cache of the query frontend.
+		w.Header().Set("Cache-Control", "no-store")
+	}
 
 	if err := marshal.WriteQueryResponseJSON(result, w); err != nil {
 		serverutil.WriteError(err, w)
@@ -361,6 +369,27 @@ func (q *QuerierAPI) SeriesHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// IndexStatsHandler is a http.HandlerFunc for index stats queries.
+func (q *QuerierAPI) IndexStatsHandler(w http.ResponseWriter, r *http.Request) {
+	req, err := loghttp.ParseIndexStatsQuery(r)
+	if err != nil {
+		serverutil.WriteError(httpgrpc.Errorf(http.StatusBadRequest, err.Error()), w)
+		return
+	}
+
+	resp, err := q.querier.IndexStats(r.Context(), req)
+	if err != nil {
+		serverutil.WriteError(err, w)
+		return
+	}
+
+	err = marshal.WriteIndexStatsResponseJSON(*resp, w)
+	if err != nil {
+		serverutil.WriteError(err, w)
+		return
+	}
+}
+
 // parseRegexQuery parses regex and query querystring from httpRequest and returns the combined LogQL query.
 // This is used only to keep regexp query string support until it gets fully deprecated.
 func parseRegexQuery(httpRequest *http.Request) (string, error) {
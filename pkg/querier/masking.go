@@ -0,0 +1,156 @@
+package querier
+
+import (
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/grafana/loki/pkg/iter"
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/loki/pkg/logql/syntax"
+	"github.com/grafana/loki/pkg/validation"
+)
+
+// maskingPolicies returns the query masking policies of the user which mask the results for the role.
+func maskingPolicies(limits *validation.Overrides, userID, role string) []validation.QueryMaskingPolicy {
+	var policies []validation.QueryMaskingPolicy
+	for _, policy := range limits.QueryMaskingPolicies(userID) {
+		if !policy.Unmasked(role) {
+			policies = append(policies, policy)
+		}
+	}
+	return policies
+}
+
+// masker masks the lines and the label values of the streams matching the selectors of the policies.
+// It caches the policies matching each stream, so it must not be shared across goroutines.
+type masker struct {
+	policies []validation.QueryMaskingPolicy
+	streams  map[string]maskedStream
+}
+
+func newMasker(policies []validation.QueryMaskingPolicy) *masker {
+	return &masker{
+		policies: policies,
+		streams:  map[string]maskedStream{},
+	}
+}
+
+// maskedStream holds the policies matching a stream, and its masked labels.
+type maskedStream struct {
+	policies []validation.QueryMaskingPolicy
+	labels   string
+}
+
+func (s maskedStream) mask(v string) string {
+	for _, policy := range s.policies {
+		v = policy.Mask(v)
+	}
+	return v
+}
+
+func (m *masker) stream(original string) maskedStream {
+	if s, ok := m.streams[original]; ok {
+		return s
+	}
+
+	s := maskedStream{labels: original}
+	lbls, err := syntax.ParseLabels(original)
+	if err != nil {
+		// masks the whole labels with all the policies, rather than leaking the stream.
+		s.policies = m.policies
+		s.labels = s.mask(original)
+		m.streams[original] = s
+		return s
+	}
+	for _, policy := range m.policies {
+		if policy.Matches(lbls) {
+			s.policies = append(s.policies, policy)
+		}
+	}
+	if len(s.policies) > 0 {
+		builder := labels.NewBuilder(lbls)
+		for _, l := range lbls {
+			builder.Set(l.Name, s.mask(l.Value))
+		}
+		s.labels = builder.Labels().String()
+	}
+	m.streams[original] = s
+	return s
+}
+
+// maskedEntryIterator masks the lines and the label values of the entries of the iterator.
+type maskedEntryIterator struct {
+	iter.EntryIterator
+	*masker
+}
+
+func newMaskedEntryIterator(it iter.EntryIterator, policies []validation.QueryMaskingPolicy) iter.EntryIterator {
+	return &maskedEntryIterator{
+		EntryIterator: it,
+		masker:        newMasker(policies),
+	}
+}
+
+func (i *maskedEntryIterator) Entry() logproto.Entry {
+	entry := i.EntryIterator.Entry()
+	entry.Line = i.stream(i.EntryIterator.Labels()).mask(entry.Line)
+	return entry
+}
+
+func (i *maskedEntryIterator) Labels() string {
+	return i.stream(i.EntryIterator.Labels()).labels
+}
+
+// maskedSampleIterator masks the label values of the samples of the iterator.
+type maskedSampleIterator struct {
+	iter.SampleIterator
+	*masker
+}
+
+func newMaskedSampleIterator(it iter.SampleIterator, policies []validation.QueryMaskingPolicy) iter.SampleIterator {
+	return &maskedSampleIterator{
+		SampleIterator: it,
+		masker:         newMasker(policies),
+	}
+}
+
+func (i *maskedSampleIterator) Labels() string {
+	return i.stream(i.SampleIterator.Labels()).labels
+}
+
+// maskedTailClient masks the streams tailed from an ingester.
+type maskedTailClient struct {
+	logproto.Querier_TailClient
+	*masker
+}
+
+func maskTailClients(clients map[string]logproto.Querier_TailClient, policies []validation.QueryMaskingPolicy) map[string]logproto.Querier_TailClient {
+	if len(policies) == 0 {
+		return clients
+	}
+	masked := make(map[string]logproto.Querier_TailClient, len(clients))
+	for addr, client := range clients {
+		masked[addr] = &maskedTailClient{
+			Querier_TailClient: client,
+			masker:             newMasker(policies),
+		}
+	}
+	return masked
+}
+
+func (c *maskedTailClient) Recv() (*logproto.TailResponse, error) {
+	resp, err := c.Querier_TailClient.Recv()
+	if err != nil {
+		return nil, err
+	}
+	if resp.Stream != nil {
+		s := c.stream(resp.Stream.Labels)
+		resp.Stream.Labels = s.labels
+		for i := range resp.Stream.Entries {
+			resp.Stream.Entries[i].Line = s.mask(resp.Stream.Entries[i].Line)
+		}
+	}
+	for _, dropped := range resp.DroppedStreams {
+		dropped.Labels = c.stream(dropped.Labels).labels
+	}
+	return resp, nil
+}
@@ -0,0 +1,90 @@
+package querier
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/loki/pkg/iter"
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/loki/pkg/logql"
+	"github.com/grafana/loki/pkg/util/httpreq"
+	"github.com/grafana/loki/pkg/validation"
+)
+
+func TestQuerier_MaskingPolicies(t *testing.T) {
+	defaultLimits := defaultLimitsTestConfig()
+	defaultLimits.QueryMaskingPolicies = []validation.QueryMaskingPolicy{
+		{Selector: `{app="payments"}`, Patterns: []string{`\d{4}-\d{4}`, `user=\w+`}, UnmaskedRoles: []string{"admin"}},
+		{Selector: `{app="payments", env="prod"}`, Patterns: []string{`token=\w+`}, Replacement: "***"},
+	}
+	require.NoError(t, defaultLimits.Validate())
+	limits, err := validation.NewOverrides(defaultLimits, nil)
+	require.NoError(t, err)
+
+	streams := []logproto.Stream{
+		{Labels: `{app="payments", env="prod"}`, Entries: []logproto.Entry{{Timestamp: time.Unix(1, 0), Line: "card=1234-5678 token=abc"}}},
+		{Labels: `{app="payments", env="dev", user="user=bob"}`, Entries: []logproto.Entry{{Timestamp: time.Unix(2, 0), Line: "user=bob paid"}}},
+		{Labels: `{app="api"}`, Entries: []logproto.Entry{{Timestamp: time.Unix(3, 0), Line: "card=1234-5678 user=bob"}}},
+	}
+
+	for _, tc := range []struct {
+		role     string
+		expected []logproto.Stream
+	}{
+		{
+			role: "",
+			expected: []logproto.Stream{
+				{Labels: `{app="payments", env="prod"}`, Entries: []logproto.Entry{{Timestamp: time.Unix(1, 0), Line: "card=<redacted> ***"}}},
+				{Labels: `{app="payments", env="dev", user="<redacted>"}`, Entries: []logproto.Entry{{Timestamp: time.Unix(2, 0), Line: "<redacted> paid"}}},
+				streams[2],
+			},
+		},
+		{
+			role: "admin",
+			expected: []logproto.Stream{
+				{Labels: `{app="payments", env="prod"}`, Entries: []logproto.Entry{{Timestamp: time.Unix(1, 0), Line: "card=1234-5678 ***"}}},
+				streams[1],
+				streams[2],
+			},
+		},
+	} {
+		t.Run(tc.role, func(t *testing.T) {
+			store := newStoreMock()
+			store.On("SelectLogs", mock.Anything, mock.Anything).Return(iter.NewStreamsIterator(streams, logproto.FORWARD), nil)
+
+			q, err := newQuerier(
+				mockQuerierConfig(),
+				mockIngesterClientConfig(),
+				newIngesterClientMockFactory(newQuerierClientMock()),
+				mockReadRingWithOneActiveIngester(),
+				&mockDeleteGettter{},
+				store, limits)
+			require.NoError(t, err)
+
+			ctx := httpreq.InjectQuerySource(user.InjectOrgID(context.Background(), "test"), httpreq.QuerySourceStore)
+			if tc.role != "" {
+				ctx = httpreq.InjectQueryRole(ctx, tc.role)
+			}
+			res, err := q.SelectLogs(ctx, logql.SelectLogParams{QueryRequest: &logproto.QueryRequest{
+				Selector:  `{app=~".+"}`,
+				Limit:     10,
+				Start:     time.Unix(0, 0),
+				End:       time.Unix(10, 0),
+				Direction: logproto.FORWARD,
+			}})
+			require.NoError(t, err)
+
+			var actual []logproto.Stream
+			for res.Next() {
+				actual = append(actual, logproto.Stream{Labels: res.Labels(), Entries: []logproto.Entry{res.Entry()}})
+			}
+			require.NoError(t, res.Error())
+			require.Equal(t, tc.expected, actual)
+		})
+	}
+}
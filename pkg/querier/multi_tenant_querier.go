@@ -8,6 +8,7 @@ import (
 	"github.com/weaveworks/common/user"
 
 	"github.com/grafana/loki/pkg/iter"
+	"github.com/grafana/loki/pkg/logproto"
 	"github.com/grafana/loki/pkg/logql"
 	"github.com/grafana/loki/pkg/logql/syntax"
 	"github.com/grafana/loki/pkg/tenant"
@@ -141,3 +142,28 @@ func NewTenantSampleIterator(iter iter.SampleIterator, id string) *TenantSampleI
 func (i *TenantSampleIterator) Labels() string {
 	return i.relabel.relabel(i.SampleIterator.Labels())
 }
+
+// IndexStats sums the statistics of the tenants.
+func (q *MultiTenantQuerier) IndexStats(ctx context.Context, req *logproto.IndexStatsRequest) (*logproto.IndexStatsResponse, error) {
+	tenantIDs, err := tenant.TenantIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tenantIDs) == 1 {
+		return q.Querier.IndexStats(ctx, req)
+	}
+
+	res := &logproto.IndexStatsResponse{}
+	for _, id := range tenantIDs {
+		stats, err := q.Querier.IndexStats(user.InjectOrgID(ctx, id), req)
+		if err != nil {
+			return nil, err
+		}
+		res.Streams += stats.Streams
+		res.Chunks += stats.Chunks
+		res.Bytes += stats.Bytes
+		res.Entries += stats.Entries
+	}
+	return res, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"flag"
 	"net/http"
+	"sort"
 	"time"
 
 	"github.com/grafana/loki/pkg/storage/stores/shipper/compactor/deletion"
@@ -18,9 +19,12 @@ import (
 	"github.com/grafana/loki/pkg/loghttp"
 	"github.com/grafana/loki/pkg/logproto"
 	"github.com/grafana/loki/pkg/logql"
+	"github.com/grafana/loki/pkg/logql/syntax"
 	"github.com/grafana/loki/pkg/storage"
 	"github.com/grafana/loki/pkg/tenant"
 	listutil "github.com/grafana/loki/pkg/util"
+	"github.com/grafana/loki/pkg/util/flagext"
+	"github.com/grafana/loki/pkg/util/httpreq"
 	"github.com/grafana/loki/pkg/util/spanlogger"
 	util_validation "github.com/grafana/loki/pkg/util/validation"
 	"github.com/grafana/loki/pkg/validation"
@@ -51,6 +55,9 @@ type Config struct {
 	QueryStoreOnly                bool             `yaml:"query_store_only"`
 	QueryIngesterOnly             bool             `yaml:"query_ingester_only"`
 	MultiTenantQueriesEnabled     bool             `yaml:"multi_tenant_queries_enabled"`
+	SpillDirectory                string           `yaml:"spill_directory"`
+	SpillMemoryThreshold          flagext.ByteSize `yaml:"spill_memory_threshold"`
+	Warmup                        WarmupConfig     `yaml:"warmup"`
 }
 
 // RegisterFlags register flags.
@@ -64,6 +71,10 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.BoolVar(&cfg.QueryStoreOnly, "querier.query-store-only", false, "Queriers should only query the store and not try to query any ingesters")
 	f.BoolVar(&cfg.QueryIngesterOnly, "querier.query-ingester-only", false, "Queriers should only query the ingesters and not try to query any store")
 	f.BoolVar(&cfg.MultiTenantQueriesEnabled, "querier.multi-tenant-queries-enabled", false, "Enable queries across multiple tenants. (Experimental)")
+	f.StringVar(&cfg.SpillDirectory, "querier.spill-directory", "", "Directory the log entries buffered to be sorted are spilled to when they exceed -querier.spill-memory-threshold, instead of being kept in memory. Empty to keep them in memory.")
+	_ = cfg.SpillMemoryThreshold.Set("64MB")
+	f.Var(&cfg.SpillMemoryThreshold, "querier.spill-memory-threshold", "Size of the log entries buffered in memory by a query above which they are sorted and spilled to -querier.spill-directory.")
+	cfg.Warmup.RegisterFlags(f)
 }
 
 // Validate validates the config.
@@ -71,6 +82,12 @@ func (cfg *Config) Validate() error {
 	if cfg.QueryStoreOnly && cfg.QueryIngesterOnly {
 		return errors.New("querier.query_store_only and querier.query_store_only cannot both be true")
 	}
+	if cfg.SpillDirectory != "" && cfg.SpillMemoryThreshold <= 0 {
+		return errors.New("querier.spill_memory_threshold must be positive when querier.spill_directory is set")
+	}
+	if cfg.Warmup.FrontendAddress != "" && cfg.Warmup.Concurrency <= 0 {
+		return errors.New("querier.warmup.concurrency must be positive when querier.warmup.frontend_address is set")
+	}
 	return nil
 }
 
@@ -79,6 +96,7 @@ type Querier interface {
 	logql.Querier
 	Label(ctx context.Context, req *logproto.LabelRequest) (*logproto.LabelResponse, error)
 	Series(ctx context.Context, req *logproto.SeriesRequest) (*logproto.SeriesResponse, error)
+	IndexStats(ctx context.Context, req *logproto.IndexStatsRequest) (*logproto.IndexStatsResponse, error)
 	Tail(ctx context.Context, req *logproto.TailRequest) (*Tailer, error)
 }
 
@@ -89,6 +107,7 @@ type SingleTenantQuerier struct {
 	limits          *validation.Overrides
 	ingesterQuerier *IngesterQuerier
 	deleteGetter    deleteGetter
+	spillBudgets    *spillBudgets
 }
 
 type deleteGetter interface {
@@ -103,6 +122,7 @@ func New(cfg Config, store storage.Store, ingesterQuerier *IngesterQuerier, limi
 		ingesterQuerier: ingesterQuerier,
 		limits:          limits,
 		deleteGetter:    d,
+		spillBudgets:    newSpillBudgets(limits),
 	}, nil
 }
 
@@ -114,12 +134,17 @@ func (q *SingleTenantQuerier) SelectLogs(ctx context.Context, params logql.Selec
 		return nil, err
 	}
 
+	params.Selector, err = q.scopeQuery(ctx, params.Selector)
+	if err != nil {
+		return nil, err
+	}
+
 	params.QueryRequest.Deletes, err = q.deletesForUser(ctx, params.Start, params.End)
 	if err != nil {
 		return nil, err
 	}
 
-	ingesterQueryInterval, storeQueryInterval := q.buildQueryIntervals(params.Start, params.End)
+	ingesterQueryInterval, storeQueryInterval := q.queryIntervals(ctx, params.Start, params.End)
 
 	iters := []iter.EntryIterator{}
 	if !q.cfg.QueryStoreOnly && ingesterQueryInterval != nil {
@@ -155,10 +180,18 @@ func (q *SingleTenantQuerier) SelectLogs(ctx context.Context, params logql.Selec
 
 		iters = append(iters, storeIter)
 	}
+
+	var it iter.EntryIterator
 	if len(iters) == 1 {
-		return iters[0], nil
+		it = iters[0]
+	} else {
+		it = iter.NewMergeEntryIterator(ctx, iters, params.Direction)
 	}
-	return iter.NewMergeEntryIterator(ctx, iters, params.Direction), nil
+	it = newUnshardedEntryIterator(it)
+	if policies := q.maskingPolicies(ctx); len(policies) > 0 {
+		it = newMaskedEntryIterator(it, policies)
+	}
+	return it, nil
 }
 
 func (q *SingleTenantQuerier) SelectSamples(ctx context.Context, params logql.SelectSampleParams) (iter.SampleIterator, error) {
@@ -168,12 +201,17 @@ func (q *SingleTenantQuerier) SelectSamples(ctx context.Context, params logql.Se
 		return nil, err
 	}
 
+	params.Selector, err = q.scopeQuery(ctx, params.Selector)
+	if err != nil {
+		return nil, err
+	}
+
 	params.SampleQueryRequest.Deletes, err = q.deletesForUser(ctx, params.Start, params.End)
 	if err != nil {
 		return nil, err
 	}
 
-	ingesterQueryInterval, storeQueryInterval := q.buildQueryIntervals(params.Start, params.End)
+	ingesterQueryInterval, storeQueryInterval := q.queryIntervals(ctx, params.Start, params.End)
 
 	iters := []iter.SampleIterator{}
 	if !q.cfg.QueryStoreOnly && ingesterQueryInterval != nil {
@@ -205,7 +243,21 @@ func (q *SingleTenantQuerier) SelectSamples(ctx context.Context, params logql.Se
 
 		iters = append(iters, storeIter)
 	}
-	return iter.NewMergeSampleIterator(ctx, iters), nil
+
+	it := newUnshardedSampleIterator(iter.NewMergeSampleIterator(ctx, iters))
+	if policies := q.maskingPolicies(ctx); len(policies) > 0 {
+		it = newMaskedSampleIterator(it, policies)
+	}
+	return it, nil
+}
+
+// maskingPolicies returns the query masking policies of the tenant which mask the results for the role of the caller.
+func (q *SingleTenantQuerier) maskingPolicies(ctx context.Context) []validation.QueryMaskingPolicy {
+	userID, err := tenant.TenantID(ctx)
+	if err != nil {
+		return nil
+	}
+	return maskingPolicies(q.limits, userID, httpreq.QueryRole(ctx))
 }
 
 func (q *SingleTenantQuerier) deletesForUser(ctx context.Context, startT, endT time.Time) ([]*logproto.Delete, error) {
@@ -238,6 +290,22 @@ func (q *SingleTenantQuerier) deletesForUser(ctx context.Context, startT, endT t
 	return deletes, nil
 }
 
+// queryIntervals returns the intervals to query the ingesters and the store for, the whole query
+// interval for the source the query is restricted to if any.
+func (q *SingleTenantQuerier) queryIntervals(ctx context.Context, queryStart, queryEnd time.Time) (*interval, *interval) {
+	i := &interval{
+		start: queryStart,
+		end:   queryEnd,
+	}
+	switch httpreq.QuerySource(ctx) {
+	case httpreq.QuerySourceIngesters:
+		return i, nil
+	case httpreq.QuerySourceStore:
+		return nil, i
+	}
+	return q.buildQueryIntervals(queryStart, queryEnd)
+}
+
 func (q *SingleTenantQuerier) buildQueryIntervals(queryStart, queryEnd time.Time) (*interval, *interval) {
 	// limitQueryInterval is a flag for whether store queries should be limited to start time of ingester queries.
 	limitQueryInterval := false
@@ -324,12 +392,20 @@ func (q *SingleTenantQuerier) Label(ctx context.Context, req *logproto.LabelRequ
 		return nil, err
 	}
 
+	scope, err := q.queryScope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(scope) > 0 {
+		return q.scopedLabel(ctx, req)
+	}
+
 	// Enforce the query timeout while querying backends
 	ctx, cancel := context.WithDeadline(ctx, time.Now().Add(q.cfg.QueryTimeout))
 	defer cancel()
 
 	var ingesterValues [][]string
-	if !q.cfg.QueryStoreOnly {
+	if !q.cfg.QueryStoreOnly && httpreq.QueryIngesters(ctx) {
 		ingesterValues, err = q.ingesterQuerier.Label(ctx, req)
 		if err != nil {
 			return nil, err
@@ -337,7 +413,7 @@ func (q *SingleTenantQuerier) Label(ctx context.Context, req *logproto.LabelRequ
 	}
 
 	var storeValues []string
-	if !q.cfg.QueryIngesterOnly {
+	if !q.cfg.QueryIngesterOnly && httpreq.QueryStore(ctx) {
 		from, through := model.TimeFromUnixNano(req.Start.UnixNano()), model.TimeFromUnixNano(req.End.UnixNano())
 		if req.Values {
 			storeValues, err = q.store.LabelValuesForMetricName(ctx, userID, from, through, "logs", req.Name)
@@ -353,11 +429,47 @@ func (q *SingleTenantQuerier) Label(ctx context.Context, req *logproto.LabelRequ
 	}
 
 	results := append(ingesterValues, storeValues)
+	values := listutil.MergeStringLists(results...)
+	if !req.Values {
+		// the shards of the streams are merged back by the queries.
+		for i, name := range values {
+			if name == logproto.StreamShardLabel {
+				values = append(values[:i], values[i+1:]...)
+				break
+			}
+		}
+	}
 	return &logproto.LabelResponse{
-		Values: listutil.MergeStringLists(results...),
+		Values: values,
 	}, nil
 }
 
+// scopedLabel returns the label names or values of the series in the scope of the query, as the index of the
+// labels can't be restricted to the streams of the scope.
+func (q *SingleTenantQuerier) scopedLabel(ctx context.Context, req *logproto.LabelRequest) (*logproto.LabelResponse, error) {
+	series, err := q.Series(ctx, &logproto.SeriesRequest{Start: *req.Start, End: *req.End})
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]struct{}{}
+	for _, s := range series.Series {
+		for name, value := range s.Labels {
+			if !req.Values {
+				values[name] = struct{}{}
+			} else if name == req.Name {
+				values[value] = struct{}{}
+			}
+		}
+	}
+	res := &logproto.LabelResponse{Values: make([]string, 0, len(values))}
+	for v := range values {
+		res.Values = append(res.Values, v)
+	}
+	sort.Strings(res.Values)
+	return res, nil
+}
+
 // Check implements the grpc healthcheck
 func (*SingleTenantQuerier) Check(_ context.Context, _ *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
 	return &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING}, nil
@@ -385,6 +497,12 @@ func (q *SingleTenantQuerier) Tail(ctx context.Context, req *logproto.TailReques
 		return nil, err
 	}
 
+	// the history is scoped by SelectLogs.
+	req.Query, err = q.scopeQuery(ctx, req.Query)
+	if err != nil {
+		return nil, err
+	}
+
 	// Enforce the query timeout except when tailing, otherwise the tailing
 	// will be terminated once the query timeout is reached
 	tailCtx := ctx
@@ -395,23 +513,25 @@ func (q *SingleTenantQuerier) Tail(ctx context.Context, req *logproto.TailReques
 	if err != nil {
 		return nil, err
 	}
+	policies := q.maskingPolicies(ctx)
 
 	histIterators, err := q.SelectLogs(queryCtx, histReq)
 	if err != nil {
 		return nil, err
 	}
 
-	reversedIterator, err := iter.NewReversedIter(histIterators, req.Limit, true)
+	reversedIterator, err := q.sortEntries(ctx, histIterators, logproto.FORWARD, req.Limit)
 	if err != nil {
 		return nil, err
 	}
 
 	return newTailer(
 		time.Duration(req.DelayFor)*time.Second,
-		tailClients,
+		maskTailClients(unshardTailClients(tailClients), policies),
 		reversedIterator,
 		func(connectedIngestersAddr []string) (map[string]logproto.Querier_TailClient, error) {
-			return q.ingesterQuerier.TailDisconnectedIngesters(tailCtx, req, connectedIngestersAddr)
+			clients, err := q.ingesterQuerier.TailDisconnectedIngesters(tailCtx, req, connectedIngestersAddr)
+			return maskTailClients(unshardTailClients(clients), policies), err
 		},
 		q.cfg.TailMaxDuration,
 		tailerWaitEntryThrottle,
@@ -429,6 +549,10 @@ func (q *SingleTenantQuerier) Series(ctx context.Context, req *logproto.SeriesRe
 		return nil, err
 	}
 
+	if req.Groups, err = q.scopeGroups(ctx, req.Groups); err != nil {
+		return nil, err
+	}
+
 	// Enforce the query timeout while querying backends
 	ctx, cancel := context.WithDeadline(ctx, time.Now().Add(q.cfg.QueryTimeout))
 	defer cancel()
@@ -436,13 +560,45 @@ func (q *SingleTenantQuerier) Series(ctx context.Context, req *logproto.SeriesRe
 	return q.awaitSeries(ctx, req)
 }
 
+// IndexStats returns the statistics of the chunks of the streams matching the matchers, estimated from the index of
+// the store. The data not flushed by the ingesters yet isn't counted.
+func (q *SingleTenantQuerier) IndexStats(ctx context.Context, req *logproto.IndexStatsRequest) (*logproto.IndexStatsResponse, error) {
+	userID, err := tenant.TenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	start, end, err := validateQueryTimeRangeLimits(ctx, userID, q.limits, req.Start, req.End)
+	if err != nil {
+		return nil, err
+	}
+	selector, err := q.scopeQuery(ctx, req.Matchers)
+	if err != nil {
+		return nil, err
+	}
+	matchers, err := syntax.ParseMatchers(selector)
+	if err != nil {
+		return nil, httpgrpc.Errorf(http.StatusBadRequest, err.Error())
+	}
+	if q.cfg.QueryIngesterOnly || !httpreq.QueryStore(ctx) {
+		return &logproto.IndexStatsResponse{}, nil
+	}
+
+	// Enforce the query timeout while querying the store
+	ctx, cancel := context.WithDeadline(ctx, time.Now().Add(q.cfg.QueryTimeout))
+	defer cancel()
+
+	from, through := listutil.RoundToMilliseconds(start, end)
+	return q.store.Stats(ctx, from, through, matchers...)
+}
+
 func (q *SingleTenantQuerier) awaitSeries(ctx context.Context, req *logproto.SeriesRequest) (*logproto.SeriesResponse, error) {
 	// buffer the channels to the # of calls they're expecting su
 	series := make(chan [][]logproto.SeriesIdentifier, 2)
 	errs := make(chan error, 2)
 
 	// fetch series from ingesters and store concurrently
-	if q.cfg.QueryStoreOnly {
+	if q.cfg.QueryStoreOnly || !httpreq.QueryIngesters(ctx) {
 		series <- [][]logproto.SeriesIdentifier{}
 	} else {
 		go func() {
@@ -457,7 +613,9 @@ func (q *SingleTenantQuerier) awaitSeries(ctx context.Context, req *logproto.Ser
 		}()
 	}
 
-	if !q.cfg.QueryIngesterOnly {
+	if q.cfg.QueryIngesterOnly || !httpreq.QueryStore(ctx) {
+		series <- [][]logproto.SeriesIdentifier{}
+	} else {
 		go func() {
 			storeValues, err := q.seriesForMatchers(ctx, req.Start, req.End, req.GetGroups(), req.Shards)
 			if err != nil {
@@ -481,6 +639,8 @@ func (q *SingleTenantQuerier) awaitSeries(ctx context.Context, req *logproto.Ser
 	deduped := make(map[string]logproto.SeriesIdentifier)
 	for _, set := range sets {
 		for _, s := range set {
+			// the shards of the streams are merged back.
+			delete(s.Labels, logproto.StreamShardLabel)
 			key := loghttp.LabelSet(s.Labels).String()
 			if _, exists := deduped[key]; !exists {
 				deduped[key] = s
@@ -256,6 +256,9 @@ func newStoreMock() *storeMock {
 }
 
 func (s *storeMock) SetChunkFilterer(storage.RequestChunkFilterer) {}
+func (s *storeMock) SetChunkQuarantine(storage.ChunkQuarantine)    {}
+func (s *storeMock) SetChunkBlooms(storage.ChunkBlooms)            {}
+func (s *storeMock) SetDataLocality(storage.DataLocalityLimits)    {}
 
 func (s *storeMock) SelectLogs(ctx context.Context, req logql.SelectLogParams) (iter.EntryIterator, error) {
 	args := s.Called(ctx, req)
@@ -293,12 +296,12 @@ func (s *storeMock) LabelValuesForMetricName(ctx context.Context, userID string,
 	return args.Get(0).([]string), args.Error(1)
 }
 
-func (s *storeMock) LabelNamesForMetricName(ctx context.Context, userID string, from, through model.Time, metricName string) ([]string, error) {
+func (s *storeMock) LabelNamesForMetricName(ctx context.Context, userID string, from, through model.Time, metricName string, matchers ...*labels.Matcher) ([]string, error) {
 	args := s.Called(ctx, userID, from, through, metricName)
 	return args.Get(0).([]string), args.Error(1)
 }
 
-func (s *storeMock) GetChunkFetcher(_ model.Time) *chunk.Fetcher {
+func (s *storeMock) GetChunkFetcher(_ string, _ model.Time) *chunk.Fetcher {
 	panic("don't call me please")
 }
 
@@ -306,6 +309,10 @@ func (s *storeMock) GetSchemaConfigs() []chunk.PeriodConfig {
 	panic("don't call me please")
 }
 
+func (s *storeMock) GetTenantSchemaConfigs(_ string) []chunk.PeriodConfig {
+	panic("don't call me please")
+}
+
 func (s *storeMock) GetSeries(ctx context.Context, req logql.SelectLogParams) ([]logproto.SeriesIdentifier, error) {
 	args := s.Called(ctx, req)
 	res := args.Get(0)
@@ -315,6 +322,15 @@ func (s *storeMock) GetSeries(ctx context.Context, req logql.SelectLogParams) ([
 	return res.([]logproto.SeriesIdentifier), args.Error(1)
 }
 
+func (s *storeMock) Stats(ctx context.Context, from, through model.Time, matchers ...*labels.Matcher) (*logproto.IndexStatsResponse, error) {
+	args := s.Called(ctx, from, through, matchers)
+	res := args.Get(0)
+	if res == nil {
+		return nil, args.Error(1)
+	}
+	return res.(*logproto.IndexStatsResponse), args.Error(1)
+}
+
 func (s *storeMock) Stop() {
 }
 
@@ -471,6 +487,10 @@ func (q *querierMock) Series(ctx context.Context, req *logproto.SeriesRequest) (
 	return nil, errors.New("querierMock.Series() has not been mocked")
 }
 
+func (q *querierMock) IndexStats(ctx context.Context, req *logproto.IndexStatsRequest) (*logproto.IndexStatsResponse, error) {
+	return nil, errors.New("querierMock.IndexStats() has not been mocked")
+}
+
 func (q *querierMock) Tail(ctx context.Context, req *logproto.TailRequest) (*Tailer, error) {
 	return nil, errors.New("querierMock.Tail() has not been mocked")
 }
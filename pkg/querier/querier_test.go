@@ -24,6 +24,7 @@ import (
 	"github.com/grafana/loki/pkg/logproto"
 	"github.com/grafana/loki/pkg/logql"
 	"github.com/grafana/loki/pkg/storage"
+	"github.com/grafana/loki/pkg/util/httpreq"
 	"github.com/grafana/loki/pkg/validation"
 )
 
@@ -432,6 +433,73 @@ func TestQuerier_IngesterMaxQueryLookback(t *testing.T) {
 	}
 }
 
+func TestQuerier_QuerySource(t *testing.T) {
+	limits, err := validation.NewOverrides(defaultLimitsTestConfig(), nil)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		source         string
+		end            time.Time
+		queryIngesters bool
+		queryStore     bool
+	}{
+		{source: "", end: time.Now(), queryIngesters: true, queryStore: true},
+		{source: httpreq.QuerySourceIngesters, end: time.Now(), queryIngesters: true},
+		{source: httpreq.QuerySourceStore, end: time.Now(), queryStore: true},
+		// the source is queried for the whole interval of the query.
+		{source: httpreq.QuerySourceIngesters, end: time.Now().Add(-2 * time.Hour), queryIngesters: true},
+	} {
+		t.Run(tc.source, func(t *testing.T) {
+			req := logproto.QueryRequest{
+				Selector:  `{app="foo"}`,
+				Limit:     1000,
+				Start:     tc.end.Add(-6 * time.Hour),
+				End:       tc.end,
+				Direction: logproto.FORWARD,
+			}
+
+			queryClient := newQueryClientMock()
+			ingesterClient := newQuerierClientMock()
+			if tc.queryIngesters {
+				ingesterClient.On("Query", mock.Anything, mock.Anything, mock.Anything).Return(queryClient, nil)
+				queryClient.On("Recv").Return(mockQueryResponse([]logproto.Stream{mockStream(1, 1)}), nil).Once()
+				queryClient.On("Recv").Return(nil, io.EOF).Once()
+			}
+
+			store := newStoreMock()
+			if tc.queryStore {
+				store.On("SelectLogs", mock.Anything, mock.Anything).Return(mockStreamIterator(0, 1), nil)
+			}
+
+			conf := mockQuerierConfig()
+			conf.QueryIngestersWithin = time.Hour
+			q, err := newQuerier(
+				conf,
+				mockIngesterClientConfig(),
+				newIngesterClientMockFactory(ingesterClient),
+				mockReadRingWithOneActiveIngester(),
+				&mockDeleteGettter{},
+				store, limits)
+			require.NoError(t, err)
+
+			ctx := user.InjectOrgID(context.Background(), "test")
+			if tc.source != "" {
+				ctx = context.WithValue(ctx, httpreq.QuerySourceHTTPHeader, tc.source)
+			}
+
+			res, err := q.SelectLogs(ctx, logql.SelectLogParams{QueryRequest: &req})
+			require.Nil(t, err)
+
+			// since streams are loaded lazily, force iterators to exhaust
+			for res.Next() {
+			}
+			queryClient.AssertExpectations(t)
+			ingesterClient.AssertExpectations(t)
+			store.AssertExpectations(t)
+		})
+	}
+}
+
 func TestQuerier_concurrentTailLimits(t *testing.T) {
 	request := logproto.TailRequest{
 		Query:    "{type=\"test\"}",
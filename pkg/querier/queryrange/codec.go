@@ -270,6 +270,21 @@ func (Codec) EncodeRequest(ctx context.Context, r queryrangebase.Request) (*http
 	if queryTags != "" {
 		header.Set(string(httpreq.QueryTagsHTTPHeader), queryTags)
 	}
+	if httpreq.StrictParsing(ctx) {
+		header.Set(string(httpreq.QueryStrictParsingHTTPHeader), "true")
+	}
+	if httpreq.SeriesLimitDisabled(ctx) {
+		header.Set(string(httpreq.QueryNoSeriesLimitHTTPHeader), "true")
+	}
+	if source := httpreq.QuerySource(ctx); source != "" {
+		header.Set(string(httpreq.QuerySourceHTTPHeader), source)
+	}
+	if role := httpreq.QueryRole(ctx); role != "" {
+		header.Set(string(httpreq.QueryRoleHTTPHeader), role)
+	}
+	if scope, _ := httpreq.QueryScope(ctx); scope != "" {
+		header.Set(string(httpreq.QueryScopeHTTPHeader), scope)
+	}
 
 	switch request := r.(type) {
 	case *LokiRequest:
@@ -431,15 +446,17 @@ func (Codec) DecodeResponse(ctx context.Context, r *http.Response, req queryrang
 		}
 		switch string(resp.Data.ResultType) {
 		case loghttp.ResultTypeMatrix:
-			return &LokiPromResponse{
-				Response: &queryrangebase.PrometheusResponse{
-					Status: resp.Status,
-					Data: queryrangebase.PrometheusData{
-						ResultType: loghttp.ResultTypeMatrix,
-						Result:     toProtoMatrix(resp.Data.Result.(loghttp.Matrix)),
-					},
-					Headers: convertPrometheusResponseHeadersToPointers(httpResponseHeadersToPromResponseHeaders(r.Header)),
+			promRes := &queryrangebase.PrometheusResponse{
+				Status: resp.Status,
+				Data: queryrangebase.PrometheusData{
+					ResultType: loghttp.ResultTypeMatrix,
+					Result:     toProtoMatrix(resp.Data.Result.(loghttp.Matrix)),
 				},
+				Headers: convertPrometheusResponseHeadersToPointers(httpResponseHeadersToPromResponseHeaders(r.Header)),
+			}
+			addWarnings(promRes, resp.Warnings...)
+			return &LokiPromResponse{
+				Response:   promRes,
 				Statistics: resp.Data.Statistics,
 			}, nil
 		case loghttp.ResultTypeStream:
@@ -471,15 +488,17 @@ func (Codec) DecodeResponse(ctx context.Context, r *http.Response, req queryrang
 				Headers: httpResponseHeadersToPromResponseHeaders(r.Header),
 			}, nil
 		case loghttp.ResultTypeVector:
-			return &LokiPromResponse{
-				Response: &queryrangebase.PrometheusResponse{
-					Status: resp.Status,
-					Data: queryrangebase.PrometheusData{
-						ResultType: loghttp.ResultTypeVector,
-						Result:     toProtoVector(resp.Data.Result.(loghttp.Vector)),
-					},
-					Headers: convertPrometheusResponseHeadersToPointers(httpResponseHeadersToPromResponseHeaders(r.Header)),
+			promRes := &queryrangebase.PrometheusResponse{
+				Status: resp.Status,
+				Data: queryrangebase.PrometheusData{
+					ResultType: loghttp.ResultTypeVector,
+					Result:     toProtoVector(resp.Data.Result.(loghttp.Vector)),
 				},
+				Headers: convertPrometheusResponseHeadersToPointers(httpResponseHeadersToPromResponseHeaders(r.Header)),
+			}
+			addWarnings(promRes, resp.Warnings...)
+			return &LokiPromResponse{
+				Response:   promRes,
 				Statistics: resp.Data.Statistics,
 			}, nil
 		default:
@@ -563,14 +582,17 @@ func (Codec) MergeResponse(responses ...queryrangebase.Response) (queryrangebase
 	case *LokiPromResponse:
 
 		promResponses := make([]queryrangebase.Response, 0, len(responses))
+		var warnings []string
 		for _, res := range responses {
 			mergedStats.Merge(res.(*LokiPromResponse).Statistics)
 			promResponses = append(promResponses, res.(*LokiPromResponse).Response)
+			warnings = append(warnings, responseWarnings(res.(*LokiPromResponse).Response)...)
 		}
 		promRes, err := queryrangebase.PrometheusCodec.MergeResponse(promResponses...)
 		if err != nil {
 			return nil, err
 		}
+		addWarnings(promRes.(*queryrangebase.PrometheusResponse), warnings...)
 		return &LokiPromResponse{
 			Response:   promRes.(*queryrangebase.PrometheusResponse),
 			Statistics: mergedStats,
@@ -18,6 +18,7 @@ import (
 	"github.com/grafana/loki/pkg/logproto"
 	"github.com/grafana/loki/pkg/logqlmodel/stats"
 	"github.com/grafana/loki/pkg/querier/queryrange/queryrangebase"
+	"github.com/grafana/loki/pkg/util/httpreq"
 )
 
 func init() {
@@ -253,6 +254,31 @@ func Test_codec_EncodeRequest(t *testing.T) {
 	require.Equal(t, toEncode.Direction, req.(*LokiRequest).Direction)
 	require.Equal(t, toEncode.Limit, req.(*LokiRequest).Limit)
 	require.Equal(t, "/loki/api/v1/query_range", req.(*LokiRequest).Path)
+	require.Empty(t, got.Header.Get(string(httpreq.QueryStrictParsingHTTPHeader)))
+
+	// the strict parsing of the query is forwarded to the queriers.
+	ctx = context.WithValue(ctx, httpreq.QueryStrictParsingHTTPHeader, true)
+	got, err = LokiCodec.EncodeRequest(ctx, toEncode)
+	require.NoError(t, err)
+	require.Equal(t, "true", got.Header.Get(string(httpreq.QueryStrictParsingHTTPHeader)))
+
+	// so is the source the query is restricted to.
+	ctx = context.WithValue(ctx, httpreq.QuerySourceHTTPHeader, httpreq.QuerySourceStore)
+	got, err = LokiCodec.EncodeRequest(ctx, toEncode)
+	require.NoError(t, err)
+	require.Equal(t, httpreq.QuerySourceStore, got.Header.Get(string(httpreq.QuerySourceHTTPHeader)))
+
+	// and the role of the caller, which the queriers mask the results for.
+	ctx = httpreq.InjectQueryRole(ctx, "admin")
+	got, err = LokiCodec.EncodeRequest(ctx, toEncode)
+	require.NoError(t, err)
+	require.Equal(t, "admin", got.Header.Get(string(httpreq.QueryRoleHTTPHeader)))
+
+	// and the scope of the query.
+	ctx = httpreq.InjectQueryScope(ctx, `{team="a"}`)
+	got, err = LokiCodec.EncodeRequest(ctx, toEncode)
+	require.NoError(t, err)
+	require.Equal(t, `{team="a"}`, got.Header.Get(string(httpreq.QueryScopeHTTPHeader)))
 }
 
 func Test_codec_series_EncodeRequest(t *testing.T) {
@@ -885,7 +911,14 @@ var (
 				},
 				"chunksDownloadTime": 0,
 				"totalChunksRef": 0,
-				"totalChunksDownloaded": 0
+				"totalChunksDownloaded": 0,
+				"totalChunksQuarantined": 0,
+				"totalChunksBloomFiltered": 0,
+				"parsing": {
+					"totalLinesParsed": 0,
+					"jsonParserErrors": 0,
+					"logfmtParserErrors": 0
+				}
 			},
 			"totalBatches": 6,
 			"totalChunksMatched": 7,
@@ -904,7 +937,14 @@ var (
 				},
 				"chunksDownloadTime": 16,
 				"totalChunksRef": 17,
-				"totalChunksDownloaded": 18
+				"totalChunksDownloaded": 18,
+				"totalChunksQuarantined": 0,
+				"totalChunksBloomFiltered": 0,
+				"parsing": {
+					"totalLinesParsed": 0,
+					"jsonParserErrors": 0,
+					"logfmtParserErrors": 0
+				}
 			}
 		},
 		"summary": {
@@ -914,7 +954,9 @@ var (
 			"queueTime": 21,
 			"subqueries": 1,
 			"totalBytesProcessed": 24,
-			"totalLinesProcessed": 25
+			"totalLinesProcessed": 25,
+			"totalParseErrors": 0,
+			"parseErrorRate": 0
 		}
 	},`
 	matrixString = `{
@@ -218,11 +218,13 @@ func ResponseToResult(resp queryrangebase.Response) (logqlmodel.Result, error) {
 			return logqlmodel.Result{
 				Statistics: r.Statistics,
 				Data:       sampleStreamToVector(r.Response.Data.Result),
+				Warnings:   responseWarnings(r.Response),
 			}, nil
 		}
 		return logqlmodel.Result{
 			Statistics: r.Statistics,
 			Data:       sampleStreamToMatrix(r.Response.Data.Result),
+			Warnings:   responseWarnings(r.Response),
 		}, nil
 
 	default:
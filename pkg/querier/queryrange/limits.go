@@ -18,12 +18,19 @@ import (
 	"github.com/grafana/loki/pkg/querier/queryrange/queryrangebase"
 	"github.com/grafana/loki/pkg/tenant"
 	"github.com/grafana/loki/pkg/util"
+	"github.com/grafana/loki/pkg/util/httpreq"
 	"github.com/grafana/loki/pkg/util/spanlogger"
 	"github.com/grafana/loki/pkg/util/validation"
 )
 
 const (
 	limitErrTmpl = "maximum of series (%d) reached for a single query"
+
+	queryRangeErrTmpl = "the query time range exceeds the max query range of the tenant (query range: %s, limit: %s), reduce the time range of the query"
+	queryAgeErrTmpl   = "the query starts before the max query age of the tenant (query start: %s, limit: %s ago), move the start of the query closer to now"
+
+	// warningsHeaderName is the response header holding the warnings of a metric query response.
+	warningsHeaderName = "X-Loki-Warnings"
 )
 
 var (
@@ -38,6 +45,9 @@ type Limits interface {
 	MaxQuerySeries(string) int
 	MaxEntriesLimitPerQuery(string) int
 	MinShardingLookback(string) time.Duration
+	MaxQueryRange(string) time.Duration
+	MaxQueryAge(string) time.Duration
+	QueryLimitsOverrideEnabled(string) bool
 }
 
 type limits struct {
@@ -99,12 +109,18 @@ func (l limitsMiddleware) Do(ctx context.Context, r queryrangebase.Request) (que
 		return nil, httpgrpc.Errorf(http.StatusBadRequest, err.Error())
 	}
 
+	if err := l.enforceGuardrails(ctx, tenantIDs, r); err != nil {
+		return nil, err
+	}
+
 	// Clamp the time range based on the max query lookback.
 
 	if maxQueryLookback := validation.SmallestPositiveNonZeroDurationPerTenant(tenantIDs, l.MaxQueryLookback); maxQueryLookback > 0 {
 		minStartTime := util.TimeToMillis(time.Now().Add(-maxQueryLookback))
+		// the lookback applies to the data read, which the offsets shift back.
+		start, end := offsetTimeRange(r)
 
-		if r.GetEnd() < minStartTime {
+		if end < minStartTime {
 			// The request is fully outside the allowed range, so we can return an
 			// empty response.
 			level.Debug(log).Log(
@@ -116,14 +132,18 @@ func (l limitsMiddleware) Do(ctx context.Context, r queryrangebase.Request) (que
 			return NewEmptyResponse(r)
 		}
 
-		if r.GetStart() < minStartTime {
+		if start < minStartTime {
 			// Replace the start time in the request.
+			updated := r.GetStart() + minStartTime - start
+			if updated > r.GetEnd() {
+				updated = r.GetEnd()
+			}
 			level.Debug(log).Log(
 				"msg", "the start time of the query has been manipulated because of the 'max query lookback' setting",
 				"original", util.FormatTimeMillis(r.GetStart()),
-				"updated", util.FormatTimeMillis(minStartTime))
+				"updated", util.FormatTimeMillis(updated))
 
-			r = r.WithStartEnd(minStartTime, r.GetEnd())
+			r = r.WithStartEnd(updated, r.GetEnd())
 		}
 	}
 
@@ -138,6 +158,74 @@ func (l limitsMiddleware) Do(ctx context.Context, r queryrangebase.Request) (que
 	return l.next.Do(ctx, r)
 }
 
+// enforceGuardrails rejects queries exceeding the max query range or max query
+// age, unless the override header is set and all tenants allow it.
+func (l limitsMiddleware) enforceGuardrails(ctx context.Context, tenantIDs []string, r queryrangebase.Request) error {
+	if httpreq.OverrideQueryLimits(ctx) && l.overrideAllowed(tenantIDs) {
+		return nil
+	}
+
+	if maxQueryRange := validation.SmallestPositiveNonZeroDurationPerTenant(tenantIDs, l.MaxQueryRange); maxQueryRange > 0 {
+		queryRange := timestamp.Time(r.GetEnd()).Sub(timestamp.Time(r.GetStart()))
+		if queryRange > maxQueryRange {
+			return httpgrpc.Errorf(http.StatusBadRequest, queryRangeErrTmpl, queryRange, maxQueryRange)
+		}
+	}
+
+	if maxQueryAge := validation.SmallestPositiveNonZeroDurationPerTenant(tenantIDs, l.MaxQueryAge); maxQueryAge > 0 {
+		if r.GetStart() < util.TimeToMillis(time.Now().Add(-maxQueryAge)) {
+			return httpgrpc.Errorf(http.StatusBadRequest, queryAgeErrTmpl, util.FormatTimeMillis(r.GetStart()), maxQueryAge)
+		}
+	}
+	return nil
+}
+
+func (l limitsMiddleware) overrideAllowed(tenantIDs []string) bool {
+	for _, id := range tenantIDs {
+		if !l.QueryLimitsOverrideEnabled(id) {
+			return false
+		}
+	}
+	return true
+}
+
+type seriesTruncateMiddleware struct {
+	Limits
+	next queryrangebase.Handler
+}
+
+// NewSeriesTruncateMiddleware creates a new Middleware that truncates the metric query responses to the maximum number
+// of series, instead of failing, when the request asks for it. The sub-queries of the request are neither limited nor
+// truncated, so the series with the most samples are chosen once, out of the merged response.
+func NewSeriesTruncateMiddleware(l Limits) queryrangebase.Middleware {
+	return queryrangebase.MiddlewareFunc(func(next queryrangebase.Handler) queryrangebase.Handler {
+		return seriesTruncateMiddleware{
+			next:   next,
+			Limits: l,
+		}
+	})
+}
+
+func (s seriesTruncateMiddleware) Do(ctx context.Context, r queryrangebase.Request) (queryrangebase.Response, error) {
+	if !httpreq.TruncateSeries(ctx) {
+		return s.next.Do(ctx, r)
+	}
+	tenantIDs, err := tenant.TenantIDs(ctx)
+	if err != nil {
+		return nil, httpgrpc.Errorf(http.StatusBadRequest, err.Error())
+	}
+	maxSeries := validation.SmallestPositiveIntPerTenant(tenantIDs, s.MaxQuerySeries)
+
+	res, err := s.next.Do(httpreq.WithoutSeriesLimit(ctx), r)
+	if err != nil {
+		return nil, err
+	}
+	if promRes, ok := res.(*LokiPromResponse); ok {
+		truncateSeries(promRes, maxSeries)
+	}
+	return res, nil
+}
+
 type seriesLimiter struct {
 	hashes map[uint64]struct{}
 	rw     sync.RWMutex
@@ -166,6 +254,10 @@ func (slm seriesLimiterMiddleware) Wrap(next queryrangebase.Handler) queryrangeb
 }
 
 func (sl *seriesLimiter) Do(ctx context.Context, req queryrangebase.Request) (queryrangebase.Response, error) {
+	// the merged response is truncated instead, see seriesTruncateMiddleware.
+	if httpreq.SeriesLimitDisabled(ctx) {
+		return sl.next.Do(ctx, req)
+	}
 	// no need to fire a request if the limit is already reached.
 	if sl.isLimitReached() {
 		return nil, httpgrpc.Errorf(http.StatusBadRequest, limitErrTmpl, sl.maxSeries)
@@ -201,6 +293,70 @@ func (sl *seriesLimiter) isLimitReached() bool {
 	return len(sl.hashes) > sl.maxSeries
 }
 
+// truncateSeries keeps the maxSeries series of the response with the most samples,
+// adding a warning to the response if any series was dropped.
+func truncateSeries(res *LokiPromResponse, maxSeries int) {
+	if res.Response == nil || len(res.Response.Data.Result) <= maxSeries {
+		return
+	}
+	result := res.Response.Data.Result
+	ranks := make([]logql.SeriesRank, len(result))
+	for i, s := range result {
+		ranks[i] = logql.SeriesRank{Labels: logproto.FromLabelAdaptersToLabels(s.Labels), Samples: len(s.Samples)}
+		for _, sample := range s.Samples {
+			ranks[i].Sum += sample.Value
+		}
+	}
+	// keep the original order of the series.
+	truncated := make([]queryrangebase.SampleStream, 0, maxSeries)
+	for i, keep := range logql.TopSeries(ranks, maxSeries) {
+		if keep {
+			truncated = append(truncated, result[i])
+		}
+	}
+	res.Response.Data.Result = truncated
+	addWarnings(res.Response, logql.SeriesLimitWarning(maxSeries, len(result)))
+}
+
+// addWarnings adds warnings to the response, skipping the ones it already has.
+// Warnings are carried as a response header between middlewares.
+func addWarnings(res *queryrangebase.PrometheusResponse, warnings ...string) {
+	if len(warnings) == 0 {
+		return
+	}
+	var header *queryrangebase.PrometheusResponseHeader
+	for _, h := range res.Headers {
+		if h.GetName() == warningsHeaderName {
+			header = h
+			break
+		}
+	}
+	if header == nil {
+		header = &queryrangebase.PrometheusResponseHeader{Name: warningsHeaderName}
+		res.Headers = append(res.Headers, header)
+	}
+Outer:
+	for _, w := range warnings {
+		for _, existing := range header.Values {
+			if existing == w {
+				continue Outer
+			}
+		}
+		header.Values = append(header.Values, w)
+	}
+}
+
+// responseWarnings returns the warnings of the response.
+func responseWarnings(res *queryrangebase.PrometheusResponse) []string {
+	var warnings []string
+	for _, h := range res.GetHeaders() {
+		if h.GetName() == warningsHeaderName {
+			warnings = append(warnings, h.GetValues()...)
+		}
+	}
+	return warnings
+}
+
 type limitedRoundTripper struct {
 	next   http.RoundTripper
 	limits Limits
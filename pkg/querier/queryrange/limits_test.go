@@ -3,6 +3,7 @@ package queryrange
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"sync"
 	"testing"
@@ -14,10 +15,14 @@ import (
 	"github.com/weaveworks/common/user"
 	"go.uber.org/atomic"
 
+	"github.com/grafana/loki/pkg/loghttp"
 	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/loki/pkg/logql"
 	"github.com/grafana/loki/pkg/logqlmodel"
 	"github.com/grafana/loki/pkg/querier/queryrange/queryrangebase"
 	"github.com/grafana/loki/pkg/storage/chunk"
+	"github.com/grafana/loki/pkg/util"
+	"github.com/grafana/loki/pkg/util/httpreq"
 	util_log "github.com/grafana/loki/pkg/util/log"
 	"github.com/grafana/loki/pkg/util/marshal"
 )
@@ -136,6 +141,151 @@ func Test_seriesLimiter(t *testing.T) {
 	require.LessOrEqual(t, *c, 4)
 }
 
+func Test_seriesLimiterTruncate(t *testing.T) {
+	cfg := testConfig
+	cfg.CacheResults = false
+	l := WithSplitByLimits(fakeLimits{maxSeries: 1, maxQueryParallelism: 2}, time.Hour)
+	tpw, stopper, err := NewTripperware(cfg, util_log.Logger, l, chunk.SchemaConfig{}, nil)
+	if stopper != nil {
+		defer stopper.Stop()
+	}
+	require.NoError(t, err)
+
+	lreq := &LokiRequest{
+		Query:     `rate({app="foo"} |= "foo"[1m])`,
+		Limit:     1000,
+		Step:      30000, // 30sec
+		StartTs:   testTime.Add(-6 * time.Hour),
+		EndTs:     testTime,
+		Direction: logproto.FORWARD,
+		Path:      "/query_range",
+	}
+
+	ctx := user.InjectOrgID(context.Background(), "1")
+	ctx = context.WithValue(ctx, httpreq.QuerySeriesLimitStrategyHTTPHeader, httpreq.SeriesLimitStrategyTruncate)
+	req, err := LokiCodec.EncodeRequest(ctx, lreq)
+	require.NoError(t, err)
+
+	req = req.WithContext(ctx)
+	err = user.InjectOrgIDIntoHTTPRequest(ctx, req)
+	require.NoError(t, err)
+
+	rt, err := newfakeRoundTripper()
+	require.NoError(t, err)
+	defer rt.Close()
+
+	// every split returns 2 series, the second one having more samples.
+	rt.setHandler(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		// the queriers don't truncate the series of the splits.
+		require.Empty(t, r.Header.Get(string(httpreq.QuerySeriesLimitStrategyHTTPHeader)))
+		if err := marshal.WriteQueryResponseJSON(logqlmodel.Result{
+			Data: append(promql.Matrix{
+				{
+					Points: []promql.Point{
+						{T: toMs(testTime.Add(-4 * time.Hour)), V: 1},
+						{T: toMs(testTime.Add(-4*time.Hour + 30*time.Second)), V: 1},
+					},
+					Metric: []labels.Label{{Name: "job", Value: "anotherjob"}},
+				},
+			}, matrix...),
+		}, rw); err != nil {
+			panic(err)
+		}
+	}))
+
+	resp, err := tpw(rt).RoundTrip(req)
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	var res loghttp.QueryResponse
+	require.NoError(t, res.UnmarshalJSON(body))
+	require.Equal(t, []string{logql.SeriesLimitWarning(1, 2)}, res.Warnings)
+	result := res.Data.Result.(loghttp.Matrix)
+	require.Len(t, result, 1)
+	require.Equal(t, "anotherjob", string(result[0].Metric["job"]))
+}
+
+func Test_seriesLimiterTruncateAcrossSplits(t *testing.T) {
+	cfg := testConfig
+	cfg.CacheResults = false
+	l := WithSplitByLimits(fakeLimits{maxSeries: 1, maxQueryParallelism: 2}, time.Hour)
+	tpw, stopper, err := NewTripperware(cfg, util_log.Logger, l, chunk.SchemaConfig{}, nil)
+	if stopper != nil {
+		defer stopper.Stop()
+	}
+	require.NoError(t, err)
+
+	lreq := &LokiRequest{
+		Query:     `rate({app="foo"} |= "foo"[1m])`,
+		Limit:     1000,
+		Step:      30000, // 30sec
+		StartTs:   testTime.Add(-6 * time.Hour),
+		EndTs:     testTime,
+		Direction: logproto.FORWARD,
+		Path:      "/query_range",
+	}
+
+	ctx := user.InjectOrgID(context.Background(), "1")
+	ctx = context.WithValue(ctx, httpreq.QuerySeriesLimitStrategyHTTPHeader, httpreq.SeriesLimitStrategyTruncate)
+	req, err := LokiCodec.EncodeRequest(ctx, lreq)
+	require.NoError(t, err)
+
+	req = req.WithContext(ctx)
+	err = user.InjectOrgIDIntoHTTPRequest(ctx, req)
+	require.NoError(t, err)
+
+	rt, err := newfakeRoundTripper()
+	require.NoError(t, err)
+	defer rt.Close()
+
+	// The series "a" has the most samples in every split but the first one,
+	// where "b" has so many that it has the most samples overall.
+	series := func(name string, start time.Time, n int) promql.Series {
+		s := promql.Series{Metric: labels.Labels{{Name: "job", Value: name}}}
+		for i := 0; i < n; i++ {
+			s.Points = append(s.Points, promql.Point{T: toMs(start.Add(time.Duration(i) * 30 * time.Second)), V: 1})
+		}
+		return s
+	}
+	var splits atomic.Int32
+	rt.setHandler(httpreq.ExtractQueryNoSeriesLimitMiddleware().Wrap(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		splits.Inc()
+		split, err := LokiCodec.DecodeRequest(r.Context(), r, nil)
+		require.NoError(t, err)
+		start := split.(*LokiRequest).StartTs
+		a, b := 2, 1
+		// the start of the first split is aligned to the step.
+		if start.Before(lreq.StartTs.Add(time.Minute)) {
+			a, b = 1, 20
+		}
+		data := promql.Matrix{series("a", start, a), series("b", start, b)}
+		// like the queriers, fail the split exceeding the series limit unless the frontend disabled it.
+		if !httpreq.SeriesLimitDisabled(r.Context()) {
+			http.Error(rw, logqlmodel.NewSeriesLimitError(1).Error(), http.StatusBadRequest)
+			return
+		}
+		if err := marshal.WriteQueryResponseJSON(logqlmodel.Result{Data: data}, rw); err != nil {
+			panic(err)
+		}
+	})))
+
+	resp, err := tpw(rt).RoundTrip(req)
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	var res loghttp.QueryResponse
+	require.NoError(t, res.UnmarshalJSON(body))
+	require.Equal(t, []string{logql.SeriesLimitWarning(1, 2)}, res.Warnings)
+	result := res.Data.Result.(loghttp.Matrix)
+	require.Len(t, result, 1)
+	require.Equal(t, "b", string(result[0].Metric["job"]))
+	// the samples of "b" are kept in all the splits.
+	require.Greater(t, splits.Load(), int32(1))
+	require.Len(t, result[0].Values, 20+int(splits.Load())-1)
+}
+
 func Test_MaxQueryParallelism(t *testing.T) {
 	maxQueryParallelism := 2
 	f, err := newfakeRoundTripper()
@@ -267,6 +417,50 @@ func Test_MaxQueryLookBack(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func Test_MaxQueryRangeAndAge(t *testing.T) {
+	now := time.Now()
+	for _, tc := range []struct {
+		desc     string
+		limits   fakeLimits
+		start    time.Time
+		override bool
+		err      string
+	}{
+		{desc: "no limits", start: now.Add(-6 * time.Hour)},
+		{desc: "within limits", limits: fakeLimits{maxQueryRange: 2 * time.Hour, maxQueryAge: 3 * time.Hour}, start: now.Add(-time.Hour)},
+		{desc: "range too long", limits: fakeLimits{maxQueryRange: 2 * time.Hour}, start: now.Add(-6 * time.Hour), err: "exceeds the max query range"},
+		{desc: "too old", limits: fakeLimits{maxQueryAge: 3 * time.Hour}, start: now.Add(-6 * time.Hour), err: "starts before the max query age"},
+		{desc: "override not allowed", limits: fakeLimits{maxQueryAge: 3 * time.Hour}, start: now.Add(-6 * time.Hour), override: true, err: "starts before the max query age"},
+		{desc: "override", limits: fakeLimits{maxQueryRange: 2 * time.Hour, maxQueryAge: 3 * time.Hour, overrideEnabled: true}, start: now.Add(-6 * time.Hour), override: true},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			called := false
+			h := NewLimitsMiddleware(tc.limits).Wrap(queryrangebase.HandlerFunc(func(context.Context, queryrangebase.Request) (queryrangebase.Response, error) {
+				called = true
+				return &LokiResponse{}, nil
+			}))
+
+			ctx := user.InjectOrgID(context.Background(), "1")
+			if tc.override {
+				ctx = context.WithValue(ctx, httpreq.QueryLimitsOverrideHTTPHeader, true)
+			}
+			_, err := h.Do(ctx, &LokiRequest{
+				Query:   `{app="foo"}`,
+				StartTs: tc.start,
+				EndTs:   now,
+			})
+			if tc.err != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.err)
+				require.False(t, called)
+				return
+			}
+			require.NoError(t, err)
+			require.True(t, called)
+		})
+	}
+}
+
 func Test_GenerateCacheKey_NoDivideZero(t *testing.T) {
 	l := cacheKeyLimits{WithSplitByLimits(nil, 0)}
 	start := time.Now()
@@ -283,3 +477,33 @@ func Test_GenerateCacheKey_NoDivideZero(t *testing.T) {
 	)
 
 }
+
+func Test_MaxQueryLookBackWithOffset(t *testing.T) {
+	now := time.Now()
+	var got []queryrangebase.Request
+	middleware := NewLimitsMiddleware(fakeLimits{maxQueryLookback: 7 * 24 * time.Hour, maxQueryLength: 48 * time.Hour}).Wrap(queryrangebase.HandlerFunc(func(_ context.Context, r queryrangebase.Request) (queryrangebase.Response, error) {
+		got = append(got, r)
+		return &LokiPromResponse{}, nil
+	}))
+	ctx := user.InjectOrgID(context.Background(), "1")
+
+	// the data read a week and a day earlier is entirely before the lookback.
+	_, err := middleware.Do(ctx, &LokiRequest{
+		Query:   `rate({app="foo"}[1m] offset 8d)`,
+		StartTs: now.Add(-12 * time.Hour),
+		EndTs:   now,
+	})
+	require.NoError(t, err)
+	require.Len(t, got, 0)
+
+	// the start is moved to read the data from the lookback on.
+	_, err = middleware.Do(ctx, &LokiRequest{
+		Query:   `rate({app="foo"}[1m] offset 6d)`,
+		StartTs: now.Add(-48 * time.Hour),
+		EndTs:   now,
+	})
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	require.InDelta(t, util.TimeToMillis(now.Add(-24*time.Hour)), got[0].GetStart(), float64(time.Minute/time.Millisecond))
+	require.Equal(t, util.TimeToMillis(now), got[0].GetEnd())
+}
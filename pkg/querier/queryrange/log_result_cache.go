@@ -21,6 +21,7 @@ import (
 	"github.com/grafana/loki/pkg/querier/queryrange/queryrangebase"
 	"github.com/grafana/loki/pkg/storage/chunk/cache"
 	"github.com/grafana/loki/pkg/tenant"
+	"github.com/grafana/loki/pkg/util/httpreq"
 	"github.com/grafana/loki/pkg/util/validation"
 )
 
@@ -85,6 +86,11 @@ func (l *logResultCache) Do(ctx context.Context, req queryrangebase.Request) (qu
 		return l.next.Do(ctx, req)
 	}
 
+	// the results of the queries restricted to the ingesters or the store are partial.
+	if httpreq.QuerySource(ctx) != "" {
+		return l.next.Do(ctx, req)
+	}
+
 	maxCacheFreshness := validation.MaxDurationPerTenant(tenantIDs, l.limits.MaxCacheFreshness)
 	maxCacheTime := int64(model.Now().Add(-maxCacheFreshness))
 	if req.GetEnd() > maxCacheTime {
@@ -105,6 +111,10 @@ func (l *logResultCache) Do(ctx context.Context, req queryrangebase.Request) (qu
 	alignedStart := time.Unix(0, lokiReq.GetStartTs().UnixNano()-(lokiReq.GetStartTs().UnixNano()%interval.Nanoseconds()))
 	// generate the cache key based on query, tenant and start time.
 	cacheKey := fmt.Sprintf("log:%s:%s:%d:%d", tenant.JoinTenantIDs(tenantIDs), req.GetQuery(), interval.Nanoseconds(), alignedStart.UnixNano()/(interval.Nanoseconds()))
+	// the scoped queries can be empty where the others aren't.
+	if scope, _ := httpreq.QueryScope(ctx); scope != "" {
+		cacheKey += ":" + scope
+	}
 
 	_, buff, _, err := l.cache.Fetch(ctx, []string{cache.HashKey(cacheKey)})
 	if err != nil {
@@ -13,6 +13,7 @@ type Metrics struct {
 	*logql.ShardingMetrics
 	*SplitByMetrics
 	*LogResultCacheMetrics
+	*SavepointMetrics
 }
 
 func NewMetrics(registerer prometheus.Registerer) *Metrics {
@@ -22,5 +23,6 @@ func NewMetrics(registerer prometheus.Registerer) *Metrics {
 		ShardingMetrics:             logql.NewShardingMetrics(registerer),
 		SplitByMetrics:              NewSplitByMetrics(registerer),
 		LogResultCacheMetrics:       NewLogResultCacheMetrics(registerer),
+		SavepointMetrics:            NewSavepointMetrics(registerer),
 	}
 }
@@ -90,8 +90,9 @@ func (p *LokiPromResponse) marshalVector() ([]byte, error) {
 			Result     loghttp.Vector `json:"result"`
 			Statistics stats.Result   `json:"stats,omitempty"`
 		} `json:"data,omitempty"`
-		ErrorType string `json:"errorType,omitempty"`
-		Error     string `json:"error,omitempty"`
+		ErrorType string   `json:"errorType,omitempty"`
+		Error     string   `json:"error,omitempty"`
+		Warnings  []string `json:"warnings,omitempty"`
 	}{
 		Error: p.Response.Error,
 		Data: struct {
@@ -105,6 +106,7 @@ func (p *LokiPromResponse) marshalVector() ([]byte, error) {
 		},
 		ErrorType: p.Response.ErrorType,
 		Status:    p.Response.Status,
+		Warnings:  responseWarnings(p.Response),
 	})
 }
 
@@ -116,8 +118,9 @@ func (p *LokiPromResponse) marshalMatrix() ([]byte, error) {
 			queryrangebase.PrometheusData
 			Statistics stats.Result `json:"stats,omitempty"`
 		} `json:"data,omitempty"`
-		ErrorType string `json:"errorType,omitempty"`
-		Error     string `json:"error,omitempty"`
+		ErrorType string   `json:"errorType,omitempty"`
+		Error     string   `json:"error,omitempty"`
+		Warnings  []string `json:"warnings,omitempty"`
 	}{
 		Error: p.Response.Error,
 		Data: struct {
@@ -129,5 +132,6 @@ func (p *LokiPromResponse) marshalMatrix() ([]byte, error) {
 		},
 		ErrorType: p.Response.ErrorType,
 		Status:    p.Response.Status,
+		Warnings:  responseWarnings(p.Response),
 	})
 }
@@ -18,6 +18,8 @@ var emptyStats = `"stats": {
 			"chunksDownloadTime": 0,
 			"totalChunksRef": 0,
 			"totalChunksDownloaded": 0,
+			"totalChunksQuarantined": 0,
+			"totalChunksBloomFiltered": 0,
 			"chunk" :{
 				"compressedBytes": 0,
 				"decompressedBytes": 0,
@@ -25,6 +27,11 @@ var emptyStats = `"stats": {
 				"headChunkBytes": 0,
 				"headChunkLines": 0,
 				"totalDuplicates": 0
+			},
+			"parsing": {
+				"totalLinesParsed": 0,
+				"jsonParserErrors": 0,
+				"logfmtParserErrors": 0
 			}
 		},
 		"totalBatches": 0,
@@ -37,6 +44,8 @@ var emptyStats = `"stats": {
 			"chunksDownloadTime": 0,
 			"totalChunksRef": 0,
 			"totalChunksDownloaded": 0,
+			"totalChunksQuarantined": 0,
+			"totalChunksBloomFiltered": 0,
 			"chunk" :{
 				"compressedBytes": 0,
 				"decompressedBytes": 0,
@@ -44,6 +53,11 @@ var emptyStats = `"stats": {
 				"headChunkBytes": 0,
 				"headChunkLines": 0,
 				"totalDuplicates": 0
+			},
+			"parsing": {
+				"totalLinesParsed": 0,
+				"jsonParserErrors": 0,
+				"logfmtParserErrors": 0
 			}
 		}
 	},
@@ -54,7 +68,9 @@ var emptyStats = `"stats": {
 		"queueTime": 0,
 		"subqueries": 0,
 		"totalBytesProcessed":0,
-		"totalLinesProcessed":0
+		"totalLinesProcessed":0,
+		"totalParseErrors": 0,
+		"parseErrorRate": 0
 	}
 }`
 
@@ -28,6 +28,7 @@ import (
 	"github.com/grafana/loki/pkg/logproto"
 	"github.com/grafana/loki/pkg/storage/chunk/cache"
 	"github.com/grafana/loki/pkg/tenant"
+	"github.com/grafana/loki/pkg/util/httpreq"
 	util_log "github.com/grafana/loki/pkg/util/log"
 	"github.com/grafana/loki/pkg/util/spanlogger"
 	"github.com/grafana/loki/pkg/util/validation"
@@ -187,6 +188,11 @@ func (s resultsCache) Do(ctx context.Context, r Request) (Response, error) {
 		return s.next.Do(ctx, r)
 	}
 
+	// the results of the queries restricted to the ingesters or the store are partial.
+	if httpreq.QuerySource(ctx) != "" {
+		return s.next.Do(ctx, r)
+	}
+
 	if s.cacheGenNumberLoader != nil {
 		ctx = cache.InjectCacheGenNumber(ctx, s.cacheGenNumberLoader.GetResultsCacheGenNumber(tenantIDs))
 	}
@@ -196,6 +202,14 @@ func (s resultsCache) Do(ctx context.Context, r Request) (Response, error) {
 		extents  []Extent
 		response Response
 	)
+	// the results masked by the queriers differ by role of the caller.
+	if role := httpreq.QueryRole(ctx); role != "" {
+		key += ":" + role
+	}
+	// so do the results of the scoped queries, by scope.
+	if scope, _ := httpreq.QueryScope(ctx); scope != "" {
+		key += ":" + scope
+	}
 
 	maxCacheFreshness := validation.MaxDurationPerTenant(tenantIDs, s.limits.MaxCacheFreshness)
 	maxCacheTime := int64(model.Now().Add(-maxCacheFreshness))
@@ -17,6 +17,7 @@ import (
 
 	"github.com/grafana/loki/pkg/logproto"
 	"github.com/grafana/loki/pkg/storage/chunk/cache"
+	"github.com/grafana/loki/pkg/util/httpreq"
 )
 
 const (
@@ -788,6 +789,14 @@ func TestResultsCache(t *testing.T) {
 	_, err = rc.Do(ctx, req)
 	require.NoError(t, err)
 	require.Equal(t, 2, calls)
+
+	// The results of the callers with a role are cached apart, as they can be masked differently.
+	_, err = rc.Do(httpreq.InjectQueryRole(ctx, "admin"), parsedRequest)
+	require.NoError(t, err)
+	require.Equal(t, 3, calls)
+	_, err = rc.Do(httpreq.InjectQueryRole(ctx, "admin"), parsedRequest)
+	require.NoError(t, err)
+	require.Equal(t, 3, calls)
 }
 
 func TestResultsCacheRecent(t *testing.T) {
@@ -14,6 +14,7 @@ import (
 
 	"github.com/grafana/loki/pkg/loghttp"
 	"github.com/grafana/loki/pkg/logql"
+	"github.com/grafana/loki/pkg/logql/syntax"
 	"github.com/grafana/loki/pkg/logqlmodel"
 	"github.com/grafana/loki/pkg/querier/astmapper"
 	"github.com/grafana/loki/pkg/querier/queryrange/queryrangebase"
@@ -89,8 +90,9 @@ type astMapperware struct {
 }
 
 func (ast *astMapperware) Do(ctx context.Context, r queryrangebase.Request) (queryrangebase.Response, error) {
-	conf, err := ast.confs.GetConf(r)
 	logger := util_log.WithContext(ctx, ast.logger)
+	// the shards are the ones of the period of the data read, which the offsets can move to a previous one.
+	conf, err := ast.confs.GetConf(offsetTimeRange(r))
 	// cannot shard with this timerange
 	if err != nil {
 		level.Warn(logger).Log("err", err.Error(), "msg", "skipped AST mapper for request")
@@ -144,13 +146,13 @@ func (ast *astMapperware) Do(ctx context.Context, r queryrangebase.Request) (que
 	switch res.Data.Type() {
 	case parser.ValueTypeMatrix:
 		return &LokiPromResponse{
-			Response: &queryrangebase.PrometheusResponse{
+			Response: withResultWarnings(&queryrangebase.PrometheusResponse{
 				Status: loghttp.QueryStatusSuccess,
 				Data: queryrangebase.PrometheusData{
 					ResultType: loghttp.ResultTypeMatrix,
 					Result:     toProtoMatrix(value.(loghttp.Matrix)),
 				},
-			},
+			}, res.Warnings),
 			Statistics: res.Statistics,
 		}, nil
 	case logqlmodel.ValueTypeStreams:
@@ -168,19 +170,33 @@ func (ast *astMapperware) Do(ctx context.Context, r queryrangebase.Request) (que
 	case parser.ValueTypeVector:
 		return &LokiPromResponse{
 			Statistics: res.Statistics,
-			Response: &queryrangebase.PrometheusResponse{
+			Response: withResultWarnings(&queryrangebase.PrometheusResponse{
 				Status: loghttp.QueryStatusSuccess,
 				Data: queryrangebase.PrometheusData{
 					ResultType: loghttp.ResultTypeVector,
 					Result:     toProtoVector(value.(loghttp.Vector)),
 				},
-			},
+			}, res.Warnings),
 		}, nil
 	default:
 		return nil, fmt.Errorf("unexpected downstream response type (%T)", res.Data.Type())
 	}
 }
 
+// withResultWarnings adds the warnings of a query result to its response.
+// Responses with warnings are incomplete and must not be cached.
+func withResultWarnings(res *queryrangebase.PrometheusResponse, warnings []string) *queryrangebase.PrometheusResponse {
+	if len(warnings) == 0 {
+		return res
+	}
+	addWarnings(res, warnings...)
+	res.Headers = append(res.Headers, &queryrangebase.PrometheusResponseHeader{
+		Name:   "Cache-Control",
+		Values: []string{"no-store"},
+	})
+	return res
+}
+
 // shardSplitter middleware will only shard appropriate requests that do not extend past the MinShardingLookback interval.
 // This is used to send nonsharded requests to the ingesters in order to not overload them.
 // TODO(owen-d): export in cortex so we don't duplicate code
@@ -200,10 +216,11 @@ func (splitter *shardSplitter) Do(ctx context.Context, r queryrangebase.Request)
 	if minShardingLookback == 0 {
 		return splitter.shardingware.Do(ctx, r)
 	}
+	_, end := offsetTimeRange(r)
 	cutoff := splitter.now().Add(-minShardingLookback)
-	// Only attempt to shard queries which are older than the sharding lookback
+	// Only attempt to shard queries reading data older than the sharding lookback
 	// (the period for which ingesters are also queried) or when the lookback is disabled.
-	if minShardingLookback == 0 || util.TimeFromMillis(r.GetEnd()).Before(cutoff) {
+	if minShardingLookback == 0 || util.TimeFromMillis(end).Before(cutoff) {
 		return splitter.shardingware.Do(ctx, r)
 	}
 	return splitter.next.Do(ctx, r)
@@ -218,6 +235,32 @@ func hasShards(confs ShardingConfigs) bool {
 	return false
 }
 
+// offsetTimeRange returns the time range of a query shifted back by the offsets of its range vectors, which read the
+// data of the time range that much earlier.
+func offsetTimeRange(r queryrangebase.Request) (int64, int64) {
+	expr, err := syntax.ParseExpr(r.GetQuery())
+	if err != nil {
+		// the error is returned when the query is parsed for its execution.
+		return r.GetStart(), r.GetEnd()
+	}
+	var (
+		maxOffset, minOffset time.Duration
+		seen                 bool
+	)
+	expr.Walk(func(e interface{}) {
+		if r, ok := e.(*syntax.LogRange); ok {
+			if r.Offset > maxOffset {
+				maxOffset = r.Offset
+			}
+			if !seen || r.Offset < minOffset {
+				minOffset = r.Offset
+			}
+			seen = true
+		}
+	})
+	return r.GetStart() - maxOffset.Milliseconds(), r.GetEnd() - minOffset.Milliseconds()
+}
+
 // ShardingConfigs is a slice of chunk shard configs
 type ShardingConfigs []chunk.PeriodConfig
 
@@ -241,9 +284,9 @@ func (confs ShardingConfigs) ValidRange(start, end int64) (chunk.PeriodConfig, e
 	return chunk.PeriodConfig{}, errInvalidShardingRange
 }
 
-// GetConf will extract a shardable config corresponding to a request and the shardingconfigs
-func (confs ShardingConfigs) GetConf(r queryrangebase.Request) (chunk.PeriodConfig, error) {
-	conf, err := confs.ValidRange(r.GetStart(), r.GetEnd())
+// GetConf will extract a shardable config corresponding to a time range and the shardingconfigs
+func (confs ShardingConfigs) GetConf(start, end int64) (chunk.PeriodConfig, error) {
+	conf, err := confs.ValidRange(start, end)
 	// query exists across multiple sharding configs
 	if err != nil {
 		return conf, err
@@ -301,7 +344,7 @@ type seriesShardingHandler struct {
 }
 
 func (ss *seriesShardingHandler) Do(ctx context.Context, r queryrangebase.Request) (queryrangebase.Response, error) {
-	conf, err := ss.confs.GetConf(r)
+	conf, err := ss.confs.GetConf(r.GetStart(), r.GetEnd())
 	// cannot shard with this timerange
 	if err != nil {
 		level.Warn(ss.logger).Log("err", err.Error(), "msg", "skipped sharding for request")
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/go-kit/log"
+	"github.com/prometheus/common/model"
 	"github.com/stretchr/testify/require"
 	"github.com/weaveworks/common/user"
 
@@ -81,6 +82,7 @@ func Test_shardSplitter(t *testing.T) {
 
 	for _, tc := range []struct {
 		desc        string
+		query       string
 		lookback    time.Duration
 		shouldShard bool
 	}{
@@ -104,6 +106,12 @@ func Test_shardSplitter(t *testing.T) {
 			lookback:    0,
 			shouldShard: true,
 		},
+		{
+			desc:        "offset older than lookback",
+			query:       fmt.Sprintf(`rate({app="foo"}[1m] offset %s)`, model.Duration(end.Sub(start)+2*time.Minute)),
+			lookback:    end.Sub(start) + 1,
+			shouldShard: true,
+		},
 	} {
 		t.Run(tc.desc, func(t *testing.T) {
 			var didShard bool
@@ -120,7 +128,11 @@ func Test_shardSplitter(t *testing.T) {
 				},
 			}
 
-			resp, err := splitter.Do(user.InjectOrgID(context.Background(), "1"), req)
+			r := req
+			if tc.query != "" {
+				r = req.WithQuery(tc.query)
+			}
+			resp, err := splitter.Do(user.InjectOrgID(context.Background(), "1"), r)
 			require.Nil(t, err)
 
 			require.Equal(t, tc.shouldShard, didShard)
@@ -382,3 +394,66 @@ func Test_SeriesShardingHandler(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, expected, actual)
 }
+
+func Test_offsetTimeRange(t *testing.T) {
+	for _, tc := range []struct {
+		query      string
+		start, end int64
+	}{
+		{query: `{app="foo"}`, start: 10 * 60e3, end: 20 * 60e3},
+		{query: `rate({app="foo"}[1m])`, start: 10 * 60e3, end: 20 * 60e3},
+		{query: `rate({app="foo"}[1m] offset 5m)`, start: 5 * 60e3, end: 15 * 60e3},
+		{query: `rate({app="foo"}[1m]) / rate({app="foo"}[1m] offset 5m)`, start: 5 * 60e3, end: 20 * 60e3},
+		{query: `sum(rate({app="foo"}[1m] offset 2m)) / sum(rate({app="foo"}[1m] offset 5m))`, start: 5 * 60e3, end: 18 * 60e3},
+		{query: `invalid`, start: 10 * 60e3, end: 20 * 60e3},
+	} {
+		t.Run(tc.query, func(t *testing.T) {
+			start, end := offsetTimeRange(&LokiRequest{Query: tc.query, StartTs: time.Unix(600, 0), EndTs: time.Unix(1200, 0)})
+			require.Equal(t, tc.start, start)
+			require.Equal(t, tc.end, end)
+		})
+	}
+}
+
+func Test_InstantShardingWithOffset(t *testing.T) {
+	ctx := user.InjectOrgID(context.Background(), "1")
+	day := 24 * time.Hour
+
+	var lock sync.Mutex
+	shards := []string{}
+	sharding := NewQueryShardMiddleware(log.NewNopLogger(), ShardingConfigs{
+		chunk.PeriodConfig{RowShards: 2},
+		chunk.PeriodConfig{From: chunk.DayTime{Time: model.Time(10 * day / time.Millisecond)}, RowShards: 3},
+	}, queryrangebase.NewInstrumentMiddlewareMetrics(nil),
+		nilShardingMetrics,
+		fakeLimits{
+			maxSeries:           math.MaxInt32,
+			maxQueryParallelism: 10,
+		})
+	handler := sharding.Wrap(queryrangebase.HandlerFunc(func(c context.Context, r queryrangebase.Request) (queryrangebase.Response, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		shards = append(shards, r.(*LokiInstantRequest).Shards...)
+		return &LokiPromResponse{Response: &queryrangebase.PrometheusResponse{
+			Data: queryrangebase.PrometheusData{ResultType: loghttp.ResultTypeVector},
+		}}, nil
+	}))
+
+	// the data read a week earlier is sharded with the shards of the previous period.
+	_, err := handler.Do(ctx, &LokiInstantRequest{
+		Query:  `rate({app="foo"}[1m] offset 7d)`,
+		TimeTs: time.Unix(0, 0).Add(12 * day),
+		Path:   "/v1/query",
+	})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"0_of_2", "1_of_2"}, shards)
+
+	shards = shards[:0]
+	_, err = handler.Do(ctx, &LokiInstantRequest{
+		Query:  `rate({app="foo"}[1m] offset 1d)`,
+		TimeTs: time.Unix(0, 0).Add(12 * day),
+		Path:   "/v1/query",
+	})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"0_of_3", "1_of_3", "2_of_3"}, shards)
+}
@@ -0,0 +1,162 @@
+package queryrange
+
+import (
+	"container/list"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/grafana/loki/pkg/loghttp"
+	"github.com/grafana/loki/pkg/querier/queryrange/queryrangebase"
+	"github.com/grafana/loki/pkg/tenant"
+	serverutil "github.com/grafana/loki/pkg/util/server"
+)
+
+// RecentQueriesPath is the path of the frontend endpoint listing the queries it recently received.
+const RecentQueriesPath = "/frontend/recent_queries"
+
+// RecentQuery is a query recently received by the frontend.
+type RecentQuery struct {
+	Tenant     string        `json:"tenant"`
+	Query      string        `json:"query"`
+	Start      time.Time     `json:"start"`
+	End        time.Time     `json:"end"`
+	Step       time.Duration `json:"step"`
+	Limit      uint32        `json:"limit"`
+	ReceivedAt time.Time     `json:"received_at"`
+}
+
+type recentQueryKey struct {
+	tenant, query string
+	length, step  time.Duration
+}
+
+func (q RecentQuery) key() recentQueryKey {
+	return recentQueryKey{tenant: q.Tenant, query: q.Query, length: q.End.Sub(q.Start), step: q.Step}
+}
+
+// RecentQueries tracks the distinct queries most recently received by the frontend, for the queriers to warm
+// their index and chunk caches up by running them on startup.
+type RecentQueries struct {
+	maxQueries int
+	now        func() time.Time
+
+	mtx     sync.Mutex
+	order   *list.List // of *RecentQuery, the most recent first.
+	queries map[recentQueryKey]*list.Element
+}
+
+// NewRecentQueries makes a new RecentQueries tracking up to maxQueries queries.
+func NewRecentQueries(maxQueries int) *RecentQueries {
+	return &RecentQueries{
+		maxQueries: maxQueries,
+		now:        time.Now,
+		order:      list.New(),
+		queries:    map[recentQueryKey]*list.Element{},
+	}
+}
+
+// Record records a query received by the frontend, the queries only differing by their time range being tracked once.
+func (r *RecentQueries) Record(q RecentQuery) {
+	if r.maxQueries <= 0 {
+		return
+	}
+	q.ReceivedAt = r.now()
+	key := q.key()
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if e, ok := r.queries[key]; ok {
+		*e.Value.(*RecentQuery) = q
+		r.order.MoveToFront(e)
+		return
+	}
+	r.queries[key] = r.order.PushFront(&q)
+
+	for r.order.Len() > r.maxQueries {
+		oldest := r.order.Back()
+		delete(r.queries, oldest.Value.(*RecentQuery).key())
+		r.order.Remove(oldest)
+	}
+}
+
+// Queries returns up to limit of the queries most recently received, the most recent first, all of them when limit is 0.
+func (r *RecentQueries) Queries(limit int) []RecentQuery {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	queries := make([]RecentQuery, 0, r.order.Len())
+	for e := r.order.Front(); e != nil && (limit <= 0 || len(queries) < limit); e = e.Next() {
+		queries = append(queries, *e.Value.(*RecentQuery))
+	}
+	return queries
+}
+
+// Wrap returns a RoundTripper recording the range and instant queries sent to next.
+func (r *RecentQueries) Wrap(next http.RoundTripper) http.RoundTripper {
+	return queryrangebase.RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+		r.recordRequest(req)
+		return next.RoundTrip(req)
+	})
+}
+
+func (r *RecentQueries) recordRequest(req *http.Request) {
+	if r.maxQueries <= 0 {
+		return
+	}
+	tenantID, err := tenant.TenantID(req.Context())
+	if err != nil {
+		return
+	}
+	if err := req.ParseForm(); err != nil {
+		return
+	}
+
+	switch getOperation(req.URL.Path) {
+	case QueryRangeOp:
+		rangeQuery, err := loghttp.ParseRangeQuery(req)
+		if err != nil {
+			return
+		}
+		r.Record(RecentQuery{
+			Tenant: tenantID,
+			Query:  rangeQuery.Query,
+			Start:  rangeQuery.Start,
+			End:    rangeQuery.End,
+			Step:   rangeQuery.Step,
+			Limit:  rangeQuery.Limit,
+		})
+	case InstantQueryOp:
+		instantQuery, err := loghttp.ParseInstantQuery(req)
+		if err != nil {
+			return
+		}
+		r.Record(RecentQuery{
+			Tenant: tenantID,
+			Query:  instantQuery.Query,
+			Start:  instantQuery.Ts,
+			End:    instantQuery.Ts,
+			Limit:  instantQuery.Limit,
+		})
+	}
+}
+
+// Handler lists the queries most recently received, up to the limit query parameter if set.
+func (r *RecentQueries) Handler(w http.ResponseWriter, req *http.Request) {
+	limit := 0
+	if s := req.URL.Query().Get("limit"); s != "" {
+		var err error
+		if limit, err = strconv.Atoi(s); err != nil || limit < 0 {
+			serverutil.JSONError(w, http.StatusBadRequest, "invalid limit %q", s)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(r.Queries(limit)); err != nil {
+		serverutil.JSONError(w, http.StatusInternalServerError, "error marshalling response: %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package queryrange
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/loki/pkg/querier/queryrange/queryrangebase"
+)
+
+func TestRecentQueries_Record(t *testing.T) {
+	now := time.Unix(1000, 0)
+	r := NewRecentQueries(2)
+	r.now = func() time.Time { return now }
+
+	start := time.Unix(0, 0)
+	r.Record(RecentQuery{Tenant: "a", Query: `{app="foo"}`, Start: start, End: start.Add(time.Hour)})
+	r.Record(RecentQuery{Tenant: "b", Query: `{app="foo"}`, Start: start, End: start.Add(time.Hour)})
+	// the same query over a later range of the same length is tracked once.
+	now = now.Add(time.Minute)
+	r.Record(RecentQuery{Tenant: "a", Query: `{app="foo"}`, Start: start.Add(time.Minute), End: start.Add(time.Hour + time.Minute)})
+
+	queries := r.Queries(0)
+	require.Len(t, queries, 2)
+	require.Equal(t, "a", queries[0].Tenant)
+	require.Equal(t, start.Add(time.Minute), queries[0].Start)
+	require.Equal(t, now, queries[0].ReceivedAt)
+	require.Equal(t, "b", queries[1].Tenant)
+
+	// the least recent query is evicted.
+	r.Record(RecentQuery{Tenant: "c", Query: `{app="bar"}`, Start: start, End: start.Add(time.Hour)})
+	queries = r.Queries(0)
+	require.Len(t, queries, 2)
+	require.Equal(t, "c", queries[0].Tenant)
+	require.Equal(t, "a", queries[1].Tenant)
+
+	require.Len(t, r.Queries(1), 1)
+}
+
+func TestRecentQueries_Wrap(t *testing.T) {
+	r := NewRecentQueries(10)
+	next := queryrangebase.RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK}, nil
+	})
+	rt := r.Wrap(next)
+
+	for _, u := range []string{
+		`/loki/api/v1/query_range?` + url.Values{"query": {`{app="foo"}`}, "start": {"0"}, "end": {"3600000000000"}, "step": {"60"}, "limit": {"100"}}.Encode(),
+		`/loki/api/v1/query?` + url.Values{"query": {`count_over_time({app="bar"}[1m])`}, "time": {"3600000000000"}}.Encode(),
+		`/loki/api/v1/labels`,
+	} {
+		req := httptest.NewRequest(http.MethodGet, u, nil)
+		req = req.WithContext(user.InjectOrgID(req.Context(), "fake"))
+		_, err := rt.RoundTrip(req)
+		require.NoError(t, err)
+	}
+
+	queries := r.Queries(0)
+	require.Len(t, queries, 2)
+	require.Equal(t, `count_over_time({app="bar"}[1m])`, queries[0].Query)
+	require.Equal(t, "fake", queries[0].Tenant)
+	require.Equal(t, `{app="foo"}`, queries[1].Query)
+	require.Equal(t, time.Minute, queries[1].Step)
+	require.Equal(t, uint32(100), queries[1].Limit)
+	require.Equal(t, time.Hour, queries[1].End.Sub(queries[1].Start))
+}
+
+func TestRecentQueries_Handler(t *testing.T) {
+	r := NewRecentQueries(10)
+	r.Record(RecentQuery{Tenant: "a", Query: `{app="foo"}`})
+	r.Record(RecentQuery{Tenant: "b", Query: `{app="bar"}`})
+
+	w := httptest.NewRecorder()
+	r.Handler(w, httptest.NewRequest(http.MethodGet, RecentQueriesPath+"?limit=1", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var queries []RecentQuery
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &queries))
+	require.Len(t, queries, 1)
+	require.Equal(t, "b", queries[0].Tenant)
+
+	w = httptest.NewRecorder()
+	r.Handler(w, httptest.NewRequest(http.MethodGet, RecentQueriesPath+"?limit=foo", nil))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
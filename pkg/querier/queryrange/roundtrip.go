@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/go-kit/log"
+	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/weaveworks/common/httpgrpc"
@@ -23,11 +24,29 @@ import (
 // Config is the configuration for the queryrange tripperware
 type Config struct {
 	queryrangebase.Config `yaml:",inline"`
+
+	InstantQuerySavepoints   bool          `yaml:"instant_query_savepoints"`
+	InstantQuerySavepointTTL time.Duration `yaml:"instant_query_savepoint_ttl"`
+	RecentQueriesTracked     int           `yaml:"recent_queries_tracked"`
 }
 
 // RegisterFlags adds the flags required to configure this flag set.
 func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	cfg.Config.RegisterFlags(f)
+	f.BoolVar(&cfg.InstantQuerySavepoints, "querier.instant-query-savepoints", false, "Save the results of the shards of instant queries in the results cache, so that an identical query retried after a failure or a timeout resumes from the shards already computed.")
+	f.DurationVar(&cfg.InstantQuerySavepointTTL, "querier.instant-query-savepoint-ttl", 10*time.Minute, "Period for which the saved results of the shards of an instant query can be resumed from.")
+	f.IntVar(&cfg.RecentQueriesTracked, "querier.recent-queries-tracked", 0, "Number of distinct queries most recently received tracked by the frontend and listed on "+RecentQueriesPath+", for the queriers to warm their caches up by running them on startup. 0 to disable.")
+}
+
+// Validate validates the config.
+func (cfg *Config) Validate() error {
+	if err := cfg.Config.Validate(); err != nil {
+		return err
+	}
+	if cfg.InstantQuerySavepoints && (!cfg.CacheResults || !cfg.ShardedQueries) {
+		return errors.New("instant query savepoints require the results cache and the parallelisation of shardable queries")
+	}
+	return nil
 }
 
 // Stopper gracefully shutdown resources created
@@ -82,7 +101,7 @@ func NewTripperware(
 		return nil, nil, err
 	}
 
-	instantMetricTripperware, err := NewInstantMetricTripperware(cfg, log, limits, schema, LokiCodec, metrics)
+	instantMetricTripperware, err := NewInstantMetricTripperware(cfg, log, limits, schema, LokiCodec, c, metrics)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -390,7 +409,7 @@ func NewMetricTripperware(
 	metrics *Metrics,
 	registerer prometheus.Registerer,
 ) (queryrangebase.Tripperware, error) {
-	queryRangeMiddleware := []queryrangebase.Middleware{StatsCollectorMiddleware(), NewLimitsMiddleware(limits)}
+	queryRangeMiddleware := []queryrangebase.Middleware{StatsCollectorMiddleware(), NewLimitsMiddleware(limits), NewSeriesTruncateMiddleware(limits)}
 	if cfg.AlignQueriesWithStep {
 		queryRangeMiddleware = append(
 			queryRangeMiddleware,
@@ -471,9 +490,10 @@ func NewInstantMetricTripperware(
 	limits Limits,
 	schema chunk.SchemaConfig,
 	codec queryrangebase.Codec,
+	c cache.Cache,
 	metrics *Metrics,
 ) (queryrangebase.Tripperware, error) {
-	queryRangeMiddleware := []queryrangebase.Middleware{StatsCollectorMiddleware(), NewLimitsMiddleware(limits)}
+	queryRangeMiddleware := []queryrangebase.Middleware{StatsCollectorMiddleware(), NewLimitsMiddleware(limits), NewSeriesTruncateMiddleware(limits)}
 
 	if cfg.ShardedQueries {
 		queryRangeMiddleware = append(queryRangeMiddleware,
@@ -487,6 +507,14 @@ func NewInstantMetricTripperware(
 		)
 	}
 
+	if cfg.InstantQuerySavepoints && c != nil {
+		queryRangeMiddleware = append(
+			queryRangeMiddleware,
+			queryrangebase.InstrumentMiddleware("savepoint", metrics.InstrumentMiddlewareMetrics),
+			NewInstantQuerySavepointMiddleware(log, limits, c, cfg.InstantQuerySavepointTTL, metrics.SavepointMetrics),
+		)
+	}
+
 	if cfg.MaxRetries > 0 {
 		queryRangeMiddleware = append(
 			queryRangeMiddleware,
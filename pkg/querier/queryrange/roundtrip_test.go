@@ -32,7 +32,7 @@ import (
 
 var (
 	testTime   = time.Date(2019, 12, 02, 11, 10, 10, 10, time.UTC)
-	testConfig = Config{queryrangebase.Config{
+	testConfig = Config{Config: queryrangebase.Config{
 		AlignQueriesWithStep: true,
 		MaxRetries:           3,
 		CacheResults:         true,
@@ -555,6 +555,9 @@ type fakeLimits struct {
 	maxSeries               int
 	splits                  map[string]time.Duration
 	minShardingLookback     time.Duration
+	maxQueryRange           time.Duration
+	maxQueryAge             time.Duration
+	overrideEnabled         bool
 }
 
 func (f fakeLimits) QuerySplitDuration(key string) time.Duration {
@@ -595,6 +598,18 @@ func (f fakeLimits) MinShardingLookback(string) time.Duration {
 	return f.minShardingLookback
 }
 
+func (f fakeLimits) MaxQueryRange(string) time.Duration {
+	return f.maxQueryRange
+}
+
+func (f fakeLimits) MaxQueryAge(string) time.Duration {
+	return f.maxQueryAge
+}
+
+func (f fakeLimits) QueryLimitsOverrideEnabled(string) bool {
+	return f.overrideEnabled
+}
+
 func counter() (*int, http.Handler) {
 	count := 0
 	var lock sync.Mutex
@@ -0,0 +1,164 @@
+package queryrange
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/gogo/protobuf/proto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/model"
+	"github.com/weaveworks/common/httpgrpc"
+
+	"github.com/grafana/loki/pkg/loghttp"
+	"github.com/grafana/loki/pkg/querier/queryrange/queryrangebase"
+	"github.com/grafana/loki/pkg/storage/chunk/cache"
+	"github.com/grafana/loki/pkg/tenant"
+	"github.com/grafana/loki/pkg/util/httpreq"
+	"github.com/grafana/loki/pkg/util/validation"
+)
+
+// SavepointMetrics is the metrics wrapper used by the instant query savepoints.
+type SavepointMetrics struct {
+	SavepointHit  prometheus.Counter
+	SavepointMiss prometheus.Counter
+}
+
+// NewSavepointMetrics creates metrics to be used by the instant query savepoints.
+func NewSavepointMetrics(registerer prometheus.Registerer) *SavepointMetrics {
+	return &SavepointMetrics{
+		SavepointHit: promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+			Namespace: "loki",
+			Name:      "query_frontend_instant_query_savepoint_hit_total",
+			Help:      "Total number of shards of instant queries resumed from a savepoint.",
+		}),
+		SavepointMiss: promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+			Namespace: "loki",
+			Name:      "query_frontend_instant_query_savepoint_miss_total",
+			Help:      "Total number of shards of instant queries executed without savepoint.",
+		}),
+	}
+}
+
+// NewInstantQuerySavepointMiddleware creates a middleware saving the results of the shards of instant queries in the cache.
+// An identical query retried after a failure or a timeout resumes from the shards already computed
+// instead of executing all of them again. Savepoints are only used for ttl.
+func NewInstantQuerySavepointMiddleware(logger log.Logger, limits Limits, c cache.Cache, ttl time.Duration, metrics *SavepointMetrics) queryrangebase.Middleware {
+	if metrics == nil {
+		metrics = NewSavepointMetrics(nil)
+	}
+	return queryrangebase.MiddlewareFunc(func(next queryrangebase.Handler) queryrangebase.Handler {
+		return &savepoints{
+			next:    next,
+			limits:  limits,
+			cache:   c,
+			ttl:     ttl,
+			logger:  logger,
+			metrics: metrics,
+			now:     time.Now,
+		}
+	})
+}
+
+type savepoints struct {
+	next   queryrangebase.Handler
+	limits Limits
+	cache  cache.Cache
+	ttl    time.Duration
+
+	metrics *SavepointMetrics
+	logger  log.Logger
+	now     func() time.Time
+}
+
+func (s *savepoints) Do(ctx context.Context, r queryrangebase.Request) (queryrangebase.Response, error) {
+	req, ok := r.(*LokiInstantRequest)
+	// only the shards of the sharded queries are saved, and not when restricted to the ingesters or the store.
+	if !ok || len(req.Shards) == 0 || httpreq.QuerySource(ctx) != "" {
+		return s.next.Do(ctx, r)
+	}
+
+	tenantIDs, err := tenant.TenantIDs(ctx)
+	if err != nil {
+		return nil, httpgrpc.Errorf(http.StatusBadRequest, err.Error())
+	}
+
+	// the results of recent queries might still change.
+	maxCacheFreshness := validation.MaxDurationPerTenant(tenantIDs, s.limits.MaxCacheFreshness)
+	if req.GetEnd() > int64(model.TimeFromUnixNano(s.now().Add(-maxCacheFreshness).UnixNano())) {
+		return s.next.Do(ctx, r)
+	}
+
+	key := cache.HashKey(fmt.Sprintf("savepoint:%s:%s:%s:%d:%d:%s",
+		tenant.JoinTenantIDs(tenantIDs), req.Query, strings.Join(req.Shards, ","), req.TimeTs.UnixNano(), req.Limit, req.Direction))
+	if res, ok := s.get(ctx, key); ok {
+		s.metrics.SavepointHit.Inc()
+		return res, nil
+	}
+	s.metrics.SavepointMiss.Inc()
+
+	res, err := s.next.Do(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	s.put(ctx, key, res)
+	return res, nil
+}
+
+// get returns the response saved for the key, if not older than the ttl.
+func (s *savepoints) get(ctx context.Context, key string) (*LokiPromResponse, bool) {
+	_, bufs, _, err := s.cache.Fetch(ctx, []string{key})
+	if err != nil {
+		level.Warn(s.logger).Log("msg", "error fetching savepoint", "err", err)
+		return nil, false
+	}
+	if len(bufs) != 1 || len(bufs[0]) < 8 {
+		return nil, false
+	}
+
+	savedAt := time.Unix(0, int64(binary.BigEndian.Uint64(bufs[0])))
+	if s.now().Sub(savedAt) > s.ttl {
+		return nil, false
+	}
+	var res LokiPromResponse
+	if err := proto.Unmarshal(bufs[0][8:], &res); err != nil {
+		level.Warn(s.logger).Log("msg", "error unmarshalling savepoint", "err", err)
+		return nil, false
+	}
+	return &res, true
+}
+
+// put saves the successful and complete responses.
+func (s *savepoints) put(ctx context.Context, key string, r queryrangebase.Response) {
+	res, ok := r.(*LokiPromResponse)
+	if !ok || res.Response == nil || res.Response.Status != loghttp.QueryStatusSuccess {
+		return
+	}
+	for _, header := range res.Response.Headers {
+		if header.Name != "Cache-Control" {
+			continue
+		}
+		for _, value := range header.Values {
+			if value == "no-store" {
+				return
+			}
+		}
+	}
+
+	data, err := proto.Marshal(res)
+	if err != nil {
+		level.Warn(s.logger).Log("msg", "error marshalling savepoint", "err", err)
+		return
+	}
+	buf := make([]byte, 8, 8+len(data))
+	binary.BigEndian.PutUint64(buf, uint64(s.now().UnixNano()))
+	if err := s.cache.Store(ctx, []string{key}, [][]byte{append(buf, data...)}); err != nil {
+		level.Warn(s.logger).Log("msg", "error storing savepoint", "err", err)
+	}
+}
@@ -0,0 +1,113 @@
+package queryrange
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/loki/pkg/loghttp"
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/loki/pkg/querier/queryrange/queryrangebase"
+	"github.com/grafana/loki/pkg/storage/chunk"
+	"github.com/grafana/loki/pkg/storage/chunk/cache"
+)
+
+// storeNotifyingCache signals each store of the cache.
+type storeNotifyingCache struct {
+	cache.Cache
+	stored chan struct{}
+}
+
+func (c *storeNotifyingCache) Store(ctx context.Context, keys []string, bufs [][]byte) error {
+	err := c.Cache.Store(ctx, keys, bufs)
+	c.stored <- struct{}{}
+	return err
+}
+
+func Test_InstantQuerySavepoints(t *testing.T) {
+	ctx := user.InjectOrgID(context.Background(), "1")
+	now := time.Now()
+	c := &storeNotifyingCache{Cache: cache.NewMockCache(), stored: make(chan struct{}, 10)}
+
+	var (
+		lock   sync.Mutex
+		called = map[string]int{}
+		fail   = true
+	)
+	handler := queryrangebase.HandlerFunc(func(_ context.Context, r queryrangebase.Request) (queryrangebase.Response, error) {
+		shard := r.(*LokiInstantRequest).Shards[0]
+		lock.Lock()
+		called[shard]++
+		failing := fail && shard == "1_of_3"
+		lock.Unlock()
+		if failing {
+			// fail once the other shards are saved.
+			<-c.stored
+			<-c.stored
+			return nil, errors.New("timeout")
+		}
+		return &LokiPromResponse{Response: &queryrangebase.PrometheusResponse{
+			Status: loghttp.QueryStatusSuccess,
+			Data: queryrangebase.PrometheusData{
+				ResultType: loghttp.ResultTypeVector,
+				Result: []queryrangebase.SampleStream{
+					{
+						Labels:  []logproto.LabelAdapter{{Name: "shard", Value: shard}},
+						Samples: []logproto.LegacySample{{Value: 10, TimestampMs: 10}},
+					},
+				},
+			},
+		}}, nil
+	})
+
+	savepointware := NewInstantQuerySavepointMiddleware(log.NewNopLogger(), fakeLimits{}, c, 10*time.Minute, nil)
+	handle := queryrangebase.MergeMiddlewares(
+		NewQueryShardMiddleware(log.NewNopLogger(), ShardingConfigs{chunk.PeriodConfig{RowShards: 3}},
+			queryrangebase.NewInstrumentMiddlewareMetrics(nil),
+			nilShardingMetrics,
+			fakeLimits{maxSeries: math.MaxInt32, maxQueryParallelism: 10},
+		),
+		queryrangebase.MiddlewareFunc(func(next queryrangebase.Handler) queryrangebase.Handler {
+			s := savepointware.Wrap(next).(*savepoints)
+			s.now = func() time.Time { return now }
+			return s
+		}),
+	).Wrap(handler)
+	req := &LokiInstantRequest{
+		Query:  `sum(rate({app="foo"}[1m]))`,
+		TimeTs: now.Add(-time.Hour),
+		Path:   "/v1/query",
+	}
+
+	_, err := handle.Do(ctx, req)
+	require.Error(t, err)
+	require.Equal(t, map[string]int{"0_of_3": 1, "1_of_3": 1, "2_of_3": 1}, called)
+
+	// the retried query only executes the failed shard.
+	fail = false
+	res, err := handle.Do(ctx, req)
+	require.NoError(t, err)
+	require.Len(t, res.(*LokiPromResponse).Response.Data.Result, 1)
+	require.Equal(t, map[string]int{"0_of_3": 1, "1_of_3": 2, "2_of_3": 1}, called)
+
+	// savepoints older than the ttl aren't resumed from.
+	now = now.Add(11 * time.Minute)
+	_, err = handle.Do(ctx, req)
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"0_of_3": 2, "1_of_3": 3, "2_of_3": 2}, called)
+
+	// recent queries aren't saved.
+	req.TimeTs = now
+	_, err = handle.Do(ctx, req)
+	require.NoError(t, err)
+	_, err = handle.Do(ctx, req)
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"0_of_3": 4, "1_of_3": 5, "2_of_3": 4}, called)
+}
@@ -0,0 +1,107 @@
+package querier
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/weaveworks/common/httpgrpc"
+
+	"github.com/grafana/loki/pkg/logql/syntax"
+	"github.com/grafana/loki/pkg/tenant"
+	"github.com/grafana/loki/pkg/util/httpreq"
+)
+
+// queryScope returns the matchers the query of the context is restricted to, set by the X-Query-Scope header.
+// The queries received over HTTP must be scoped on each of the query scope labels of the tenant, unless the
+// role of the caller is unscoped.
+func (q *SingleTenantQuerier) queryScope(ctx context.Context) ([]*labels.Matcher, error) {
+	scope, external := httpreq.QueryScope(ctx)
+
+	var matchers []*labels.Matcher
+	if scope != "" {
+		var err error
+		matchers, err = syntax.ParseMatchers(scope)
+		if err != nil {
+			return nil, httpgrpc.Errorf(http.StatusBadRequest, "invalid %s header: %s", httpreq.QueryScopeHTTPHeader, err)
+		}
+	}
+	if !external {
+		return matchers, nil
+	}
+
+	userID, err := tenant.TenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	role := httpreq.QueryRole(ctx)
+	for _, unscoped := range q.limits.QueryScopeUnscopedRoles(userID) {
+		if role != "" && role == unscoped {
+			return matchers, nil
+		}
+	}
+	for _, name := range q.limits.QueryScopeLabels(userID) {
+		if !restrictsLabel(matchers, name) {
+			return nil, httpgrpc.Errorf(http.StatusForbidden, "the queries must be scoped on the label %s with the %s header", name, httpreq.QueryScopeHTTPHeader)
+		}
+	}
+	return matchers, nil
+}
+
+// restrictsLabel tells whether the matchers restrict the label to some values, with an equality or a regex
+// matcher which doesn't match the streams without the label.
+func restrictsLabel(matchers []*labels.Matcher, name string) bool {
+	for _, m := range matchers {
+		if m.Name != name {
+			continue
+		}
+		if (m.Type == labels.MatchEqual || m.Type == labels.MatchRegexp) && !m.Matches("") {
+			return true
+		}
+	}
+	return false
+}
+
+// scopeQuery restricts the stream selectors of the query to the scope of the query of the context.
+func (q *SingleTenantQuerier) scopeQuery(ctx context.Context, query string) (string, error) {
+	scope, err := q.queryScope(ctx)
+	if err != nil || len(scope) == 0 {
+		return query, err
+	}
+	return scopeQuery(query, scope)
+}
+
+// scopeQuery appends the matchers of the scope to the stream selectors of the query.
+func scopeQuery(query string, scope []*labels.Matcher) (string, error) {
+	expr, err := syntax.ParseExpr(query)
+	if err != nil {
+		return "", httpgrpc.Errorf(http.StatusBadRequest, err.Error())
+	}
+	expr.Walk(func(e interface{}) {
+		if matchers, ok := e.(*syntax.MatchersExpr); ok {
+			matchers.AppendMatchers(scope)
+		}
+	})
+	return expr.String(), nil
+}
+
+// scopeGroups restricts the selectors of a series request to the scope of the query of the context, the scope
+// being the only selector when there are none.
+func (q *SingleTenantQuerier) scopeGroups(ctx context.Context, groups []string) ([]string, error) {
+	scope, err := q.queryScope(ctx)
+	if err != nil || len(scope) == 0 {
+		return groups, err
+	}
+	if len(groups) == 0 {
+		return []string{(&syntax.MatchersExpr{Mts: scope}).String()}, nil
+	}
+	scoped := make([]string, 0, len(groups))
+	for _, group := range groups {
+		s, err := scopeQuery(group, scope)
+		if err != nil {
+			return nil, err
+		}
+		scoped = append(scoped, s)
+	}
+	return scoped, nil
+}
@@ -0,0 +1,167 @@
+package querier
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/httpgrpc"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/loki/pkg/logql"
+	"github.com/grafana/loki/pkg/util/httpreq"
+	"github.com/grafana/loki/pkg/validation"
+)
+
+func Test_scopeQuery(t *testing.T) {
+	scope := []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "team", "a")}
+	for _, tc := range []struct {
+		query    string
+		expected string
+	}{
+		{`{app="foo"}`, `{app="foo", team="a"}`},
+		{`{app="foo"} |= "bar" | json`, `{app="foo", team="a"} |= "bar" | json`},
+		{`sum by (app) (rate({app="foo"}[5m]))`, `sum by(app)(rate({app="foo", team="a"}[5m]))`},
+		{`count_over_time({app="foo"}[1m]) / count_over_time({app="bar"}[1m])`, `(count_over_time({app="foo", team="a"}[1m]) / count_over_time({app="bar", team="a"}[1m]))`},
+	} {
+		t.Run(tc.query, func(t *testing.T) {
+			actual, err := scopeQuery(tc.query, scope)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, actual)
+		})
+	}
+
+	_, err := scopeQuery(`{app="foo"`, scope)
+	require.Error(t, err)
+}
+
+func TestQuerier_queryScope(t *testing.T) {
+	defaultLimits := defaultLimitsTestConfig()
+	defaultLimits.QueryScopeLabels = []string{"team"}
+	defaultLimits.QueryScopeUnscopedRoles = []string{"admin"}
+	limits, err := validation.NewOverrides(defaultLimits, nil)
+	require.NoError(t, err)
+	q := &SingleTenantQuerier{limits: limits}
+
+	for _, tc := range []struct {
+		name     string
+		ctx      func(context.Context) context.Context
+		expected string
+		status   int32
+	}{
+		{
+			name: "scoped",
+			ctx: func(ctx context.Context) context.Context {
+				return httpreq.InjectQueryScope(ctx, `{team=~"a|b"}`)
+			},
+			expected: `{team=~"a|b"}`,
+		},
+		{
+			name: "unscoped",
+			ctx: func(ctx context.Context) context.Context {
+				return httpreq.InjectQueryScope(ctx, "")
+			},
+			status: http.StatusForbidden,
+		},
+		{
+			name: "scoped on another label",
+			ctx: func(ctx context.Context) context.Context {
+				return httpreq.InjectQueryScope(ctx, `{env="prod"}`)
+			},
+			status: http.StatusForbidden,
+		},
+		{
+			name: "scope not restricting the label",
+			ctx: func(ctx context.Context) context.Context {
+				return httpreq.InjectQueryScope(ctx, `{env="prod", team=~".*"}`)
+			},
+			status: http.StatusForbidden,
+		},
+		{
+			name: "invalid scope",
+			ctx: func(ctx context.Context) context.Context {
+				return httpreq.InjectQueryScope(ctx, `team="a"`)
+			},
+			status: http.StatusBadRequest,
+		},
+		{
+			name: "unscoped role",
+			ctx: func(ctx context.Context) context.Context {
+				return httpreq.InjectQueryRole(httpreq.InjectQueryScope(ctx, ""), "admin")
+			},
+		},
+		{
+			name: "not received over HTTP",
+			ctx:  func(ctx context.Context) context.Context { return ctx },
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			scope, err := q.queryScope(tc.ctx(user.InjectOrgID(context.Background(), "test")))
+			if tc.status != 0 {
+				resp, ok := httpgrpc.HTTPResponseFromError(err)
+				require.True(t, ok, err)
+				require.Equal(t, tc.status, resp.Code)
+				return
+			}
+			require.NoError(t, err)
+			if tc.expected == "" {
+				require.Empty(t, scope)
+				return
+			}
+			require.Len(t, scope, 1)
+			require.Equal(t, tc.expected, "{"+scope[0].String()+"}")
+		})
+	}
+}
+
+func TestQuerier_ScopedQueries(t *testing.T) {
+	limits, err := validation.NewOverrides(defaultLimitsTestConfig(), nil)
+	require.NoError(t, err)
+
+	store := newStoreMock()
+	store.On("SelectLogs", mock.Anything, mock.Anything).Return(mockStreamIterator(1, 1), nil)
+	store.On("GetSeries", mock.Anything, mock.Anything).Return([]logproto.SeriesIdentifier{
+		{Labels: map[string]string{"app": "foo", "team": "a"}},
+		{Labels: map[string]string{"app": "bar", "team": "a", "env": "prod"}},
+	}, nil)
+
+	q, err := newQuerier(
+		mockQuerierConfig(),
+		mockIngesterClientConfig(),
+		newIngesterClientMockFactory(newQuerierClientMock()),
+		mockReadRingWithOneActiveIngester(),
+		&mockDeleteGettter{},
+		store, limits)
+	require.NoError(t, err)
+
+	ctx := user.InjectOrgID(context.Background(), "test")
+	ctx = httpreq.InjectQuerySource(ctx, httpreq.QuerySourceStore)
+	ctx = httpreq.InjectQueryScope(ctx, `{team="a"}`)
+
+	req := logproto.QueryRequest{
+		Selector:  `{app="foo"} |= "bar"`,
+		Limit:     10,
+		Start:     time.Unix(0, 0),
+		End:       time.Unix(10, 0),
+		Direction: logproto.FORWARD,
+	}
+	_, err = q.SelectLogs(ctx, logql.SelectLogParams{QueryRequest: &req})
+	require.NoError(t, err)
+	require.Equal(t, `{app="foo", team="a"} |= "bar"`, store.Calls[0].Arguments[1].(logql.SelectLogParams).Selector)
+
+	// the labels are those of the series in the scope.
+	start, end := time.Unix(0, 0), time.Unix(10, 0)
+	names, err := q.Label(ctx, &logproto.LabelRequest{Start: &start, End: &end})
+	require.NoError(t, err)
+	require.Equal(t, []string{"app", "env", "team"}, names.Values)
+
+	values, err := q.Label(ctx, &logproto.LabelRequest{Name: "app", Values: true, Start: &start, End: &end})
+	require.NoError(t, err)
+	require.Equal(t, []string{"bar", "foo"}, values.Values)
+	require.Equal(t, `{team="a"}`, store.Calls[1].Arguments[1].(logql.SelectLogParams).Selector)
+}
@@ -0,0 +1,77 @@
+package querier
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/weaveworks/common/httpgrpc"
+
+	"github.com/grafana/loki/pkg/iter"
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/loki/pkg/tenant"
+	"github.com/grafana/loki/pkg/validation"
+)
+
+// sortEntries loads up to limit entries of the iterator to iterate over them in the direction.
+// The entries are spilled to disk above the memory threshold when the spill directory is set.
+func (q *SingleTenantQuerier) sortEntries(ctx context.Context, it iter.EntryIterator, direction logproto.Direction, limit uint32) (iter.EntryIterator, error) {
+	if q.cfg.SpillDirectory == "" {
+		// NewReversedIter reverses the entries, the backward entries are iterated forward.
+		return iter.NewReversedIter(it, limit, true)
+	}
+	userID, err := tenant.TenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return iter.NewSpillingSortIterator(it, direction, limit, iter.SpillConfig{
+		Directory:       q.cfg.SpillDirectory,
+		MemoryThreshold: int64(q.cfg.SpillMemoryThreshold.Val()),
+		Budget:          q.spillBudgets.forTenant(userID),
+	})
+}
+
+// spillBudgets accounts for the bytes spilled to disk by the queries of each tenant.
+type spillBudgets struct {
+	limits *validation.Overrides
+
+	mtx  sync.Mutex
+	used map[string]int64
+}
+
+func newSpillBudgets(limits *validation.Overrides) *spillBudgets {
+	return &spillBudgets{limits: limits, used: map[string]int64{}}
+}
+
+func (b *spillBudgets) forTenant(userID string) iter.SpillBudget {
+	return &tenantSpillBudget{budgets: b, userID: userID}
+}
+
+type tenantSpillBudget struct {
+	budgets *spillBudgets
+	userID  string
+}
+
+func (t *tenantSpillBudget) Reserve(bytes int64) error {
+	b := t.budgets
+	limit := int64(b.limits.MaxQuerySpillBytes(t.userID))
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if limit > 0 && b.used[t.userID]+bytes > limit {
+		return httpgrpc.Errorf(http.StatusBadRequest,
+			"max query spill bytes exceeded, the query needs more disk than left by the queries of the tenant (limit: %d bytes)", limit)
+	}
+	b.used[t.userID] += bytes
+	return nil
+}
+
+func (t *tenantSpillBudget) Release(bytes int64) {
+	b := t.budgets
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.used[t.userID] -= bytes
+	if b.used[t.userID] <= 0 {
+		delete(b.used, t.userID)
+	}
+}
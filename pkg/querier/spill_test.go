@@ -0,0 +1,30 @@
+package querier
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/pkg/validation"
+)
+
+func TestSpillBudgets(t *testing.T) {
+	defaults := defaultLimitsTestConfig()
+	require.NoError(t, defaults.MaxQuerySpillBytes.Set("100"))
+	limits, err := validation.NewOverrides(defaults, nil)
+	require.NoError(t, err)
+	budgets := newSpillBudgets(limits)
+
+	first, second := budgets.forTenant("tenant-a"), budgets.forTenant("tenant-a")
+	require.NoError(t, first.Reserve(60))
+	// the queries of a tenant share its budget.
+	require.Error(t, second.Reserve(60))
+	require.NoError(t, second.Reserve(40))
+	// but not the other tenants.
+	require.NoError(t, budgets.forTenant("tenant-b").Reserve(60))
+
+	first.Release(60)
+	require.NoError(t, second.Reserve(60))
+	second.Release(100)
+	require.NotContains(t, budgets.used, "tenant-a")
+}
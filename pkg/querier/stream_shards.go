@@ -0,0 +1,98 @@
+package querier
+
+import (
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/grafana/loki/pkg/iter"
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/loki/pkg/logql/syntax"
+)
+
+// unsharder removes the stream shard label added by the distributors from the labels of the streams, for the shards
+// of a stream to be merged back in the results. It caches the labels of each stream, so it must not be shared across
+// goroutines.
+type unsharder struct {
+	streams map[string]string
+}
+
+func newUnsharder() *unsharder {
+	return &unsharder{streams: map[string]string{}}
+}
+
+func (u *unsharder) labels(original string) string {
+	if s, ok := u.streams[original]; ok {
+		return s
+	}
+
+	s := original
+	if lbls, err := syntax.ParseLabels(original); err == nil && lbls.Has(logproto.StreamShardLabel) {
+		s = labels.NewBuilder(lbls).Del(logproto.StreamShardLabel).Labels().String()
+	}
+	u.streams[original] = s
+	return s
+}
+
+// unshardedEntryIterator merges the shards of the streams of the entries of the iterator.
+type unshardedEntryIterator struct {
+	iter.EntryIterator
+	*unsharder
+}
+
+func newUnshardedEntryIterator(it iter.EntryIterator) iter.EntryIterator {
+	return &unshardedEntryIterator{
+		EntryIterator: it,
+		unsharder:     newUnsharder(),
+	}
+}
+
+func (i *unshardedEntryIterator) Labels() string {
+	return i.labels(i.EntryIterator.Labels())
+}
+
+// unshardedSampleIterator merges the shards of the streams of the samples of the iterator.
+type unshardedSampleIterator struct {
+	iter.SampleIterator
+	*unsharder
+}
+
+func newUnshardedSampleIterator(it iter.SampleIterator) iter.SampleIterator {
+	return &unshardedSampleIterator{
+		SampleIterator: it,
+		unsharder:      newUnsharder(),
+	}
+}
+
+func (i *unshardedSampleIterator) Labels() string {
+	return i.labels(i.SampleIterator.Labels())
+}
+
+// unshardedTailClient merges the shards of the streams tailed from an ingester.
+type unshardedTailClient struct {
+	logproto.Querier_TailClient
+	*unsharder
+}
+
+func unshardTailClients(clients map[string]logproto.Querier_TailClient) map[string]logproto.Querier_TailClient {
+	unsharded := make(map[string]logproto.Querier_TailClient, len(clients))
+	for addr, client := range clients {
+		unsharded[addr] = &unshardedTailClient{
+			Querier_TailClient: client,
+			unsharder:          newUnsharder(),
+		}
+	}
+	return unsharded
+}
+
+func (c *unshardedTailClient) Recv() (*logproto.TailResponse, error) {
+	resp, err := c.Querier_TailClient.Recv()
+	if err != nil {
+		return nil, err
+	}
+	if resp.Stream != nil {
+		resp.Stream.Labels = c.labels(resp.Stream.Labels)
+	}
+	for _, dropped := range resp.DroppedStreams {
+		dropped.Labels = c.labels(dropped.Labels)
+	}
+	return resp, nil
+}
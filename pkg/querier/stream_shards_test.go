@@ -0,0 +1,69 @@
+package querier
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/loki/pkg/iter"
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/loki/pkg/logql"
+	"github.com/grafana/loki/pkg/util/httpreq"
+	"github.com/grafana/loki/pkg/validation"
+)
+
+func TestQuerier_MergesStreamShards(t *testing.T) {
+	limits, err := validation.NewOverrides(defaultLimitsTestConfig(), nil)
+	require.NoError(t, err)
+
+	streams := []logproto.Stream{
+		{Labels: `{__stream_shard__="0", app="foo"}`, Entries: []logproto.Entry{{Timestamp: time.Unix(1, 0), Line: "1"}, {Timestamp: time.Unix(3, 0), Line: "3"}}},
+		{Labels: `{__stream_shard__="1", app="foo"}`, Entries: []logproto.Entry{{Timestamp: time.Unix(2, 0), Line: "2"}}},
+		{Labels: `{app="bar"}`, Entries: []logproto.Entry{{Timestamp: time.Unix(4, 0), Line: "4"}}},
+	}
+	store := newStoreMock()
+	store.On("SelectLogs", mock.Anything, mock.Anything).Return(iter.NewStreamsIterator(streams, logproto.FORWARD), nil)
+
+	q, err := newQuerier(
+		mockQuerierConfig(),
+		mockIngesterClientConfig(),
+		newIngesterClientMockFactory(newQuerierClientMock()),
+		mockReadRingWithOneActiveIngester(),
+		&mockDeleteGettter{},
+		store, limits)
+	require.NoError(t, err)
+
+	ctx := httpreq.InjectQuerySource(user.InjectOrgID(context.Background(), "test"), httpreq.QuerySourceStore)
+	res, err := q.SelectLogs(ctx, logql.SelectLogParams{QueryRequest: &logproto.QueryRequest{
+		Selector:  `{app=~".+"}`,
+		Limit:     10,
+		Start:     time.Unix(0, 0),
+		End:       time.Unix(10, 0),
+		Direction: logproto.FORWARD,
+	}})
+	require.NoError(t, err)
+
+	var actual []string
+	for res.Next() {
+		actual = append(actual, res.Labels()+" "+res.Entry().Line)
+	}
+	require.NoError(t, res.Error())
+	require.Equal(t, []string{`{app="foo"} 1`, `{app="foo"} 2`, `{app="foo"} 3`, `{app="bar"} 4`}, actual)
+}
+
+func TestUnshardTailClients(t *testing.T) {
+	client := newTailClientMock()
+	client.On("Recv").Return(&logproto.TailResponse{
+		Stream:         &logproto.Stream{Labels: `{__stream_shard__="1", app="foo"}`},
+		DroppedStreams: []*logproto.DroppedStream{{Labels: `{__stream_shard__="0", app="foo"}`}},
+	}, nil)
+
+	resp, err := unshardTailClients(map[string]logproto.Querier_TailClient{"ingester": client})["ingester"].Recv()
+	require.NoError(t, err)
+	require.Equal(t, `{app="foo"}`, resp.Stream.Labels)
+	require.Equal(t, `{app="foo"}`, resp.DroppedStreams[0].Labels)
+}
@@ -0,0 +1,185 @@
+package querier
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/concurrency"
+	"github.com/grafana/dskit/services"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/loki/pkg/logql"
+	"github.com/grafana/loki/pkg/querier/queryrange"
+	"github.com/grafana/loki/pkg/util/httpreq"
+)
+
+// WarmupConfig configures the warm up of the caches of the queriers on startup.
+type WarmupConfig struct {
+	FrontendAddress string        `yaml:"frontend_address"`
+	MaxQueries      int           `yaml:"max_queries"`
+	Concurrency     int           `yaml:"concurrency"`
+	Timeout         time.Duration `yaml:"timeout"`
+}
+
+// RegisterFlags register flags.
+func (cfg *WarmupConfig) RegisterFlags(f *flag.FlagSet) {
+	f.StringVar(&cfg.FrontendAddress, "querier.warmup.frontend-address", "", "HTTP address of the query frontend the queries it recently received are fetched from, to run them against the store on startup before the querier is ready, downloading the index and filling the chunks cache. Requires -querier.recent-queries-tracked on the frontend. Empty to disable the warm up.")
+	f.IntVar(&cfg.MaxQueries, "querier.warmup.max-queries", 20, "Maximum number of recent queries run to warm the querier up.")
+	f.IntVar(&cfg.Concurrency, "querier.warmup.concurrency", 4, "Number of recent queries run concurrently to warm the querier up.")
+	f.DurationVar(&cfg.Timeout, "querier.warmup.timeout", 5*time.Minute, "Maximum duration of the warm up, the querier becoming ready once it elapsed.")
+}
+
+type warmupEngine interface {
+	Query(logql.Params) logql.Query
+}
+
+// warmer runs the queries recently received by the frontend against the store, for a querier not to serve its
+// first queries with cold caches.
+type warmer struct {
+	services.Service
+
+	cfg    WarmupConfig
+	engine warmupEngine
+	client *http.Client
+	logger log.Logger
+	now    func() time.Time
+
+	// next is the service started once the warm up is done, if any.
+	next        services.Service
+	nextWatcher *services.FailureWatcher
+}
+
+// NewWarmupService returns a service warming the querier up before starting next, if not nil.
+func NewWarmupService(cfg WarmupConfig, engine *logql.Engine, next services.Service, logger log.Logger) services.Service {
+	return newWarmer(cfg, engine, next, logger)
+}
+
+func newWarmer(cfg WarmupConfig, engine warmupEngine, next services.Service, logger log.Logger) *warmer {
+	w := &warmer{
+		cfg:         cfg,
+		engine:      engine,
+		client:      &http.Client{Timeout: time.Minute},
+		logger:      log.With(logger, "component", "querier-warmup"),
+		now:         time.Now,
+		next:        next,
+		nextWatcher: services.NewFailureWatcher(),
+	}
+	w.Service = services.NewBasicService(w.starting, w.running, w.stopping)
+	return w
+}
+
+func (w *warmer) starting(ctx context.Context) error {
+	start := time.Now()
+	warmupCtx, cancel := context.WithTimeout(ctx, w.cfg.Timeout)
+	ran, err := w.warm(warmupCtx)
+	cancel()
+	if err != nil {
+		// the querier is not kept from starting by a failed warm up.
+		level.Warn(w.logger).Log("msg", "failed to warm the querier up", "queries", ran, "duration", time.Since(start), "err", err)
+	} else {
+		level.Info(w.logger).Log("msg", "warmed the querier up", "queries", ran, "duration", time.Since(start))
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if w.next == nil {
+		return nil
+	}
+	w.nextWatcher.WatchService(w.next)
+	return services.StartAndAwaitRunning(ctx, w.next)
+}
+
+func (w *warmer) running(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return nil
+	case err := <-w.nextWatcher.Chan():
+		return err
+	}
+}
+
+func (w *warmer) stopping(_ error) error {
+	if w.next == nil {
+		return nil
+	}
+	return services.StopAndAwaitTerminated(context.Background(), w.next)
+}
+
+// warm runs the recent queries of the frontend, returning the number of queries run.
+func (w *warmer) warm(ctx context.Context) (int, error) {
+	queries, err := w.recentQueries(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	jobs := make([]interface{}, 0, len(queries))
+	for _, q := range queries {
+		jobs = append(jobs, q)
+	}
+	err = concurrency.ForEach(ctx, jobs, w.cfg.Concurrency, func(ctx context.Context, job interface{}) error {
+		q := job.(queryrange.RecentQuery)
+		if err := w.run(ctx, q); err != nil {
+			// a failing query doesn't stop the warm up.
+			level.Debug(w.logger).Log("msg", "failed to run query", "tenant", q.Tenant, "query", q.Query, "err", err)
+		}
+		return nil
+	})
+	return len(queries), err
+}
+
+func (w *warmer) recentQueries(ctx context.Context) ([]queryrange.RecentQuery, error) {
+	address := strings.TrimSuffix(w.cfg.FrontendAddress, "/")
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s%s?limit=%d", address, queryrange.RecentQueriesPath, w.cfg.MaxQueries), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d fetching the recent queries of the frontend", resp.StatusCode)
+	}
+
+	var queries []queryrange.RecentQuery
+	if err := json.NewDecoder(resp.Body).Decode(&queries); err != nil {
+		return nil, err
+	}
+	return queries, nil
+}
+
+// run runs the query against the store, its time range being moved forward by the time elapsed since it was received.
+func (w *warmer) run(ctx context.Context, q queryrange.RecentQuery) error {
+	ctx = user.InjectOrgID(ctx, q.Tenant)
+	ctx = httpreq.InjectQuerySource(ctx, httpreq.QuerySourceStore)
+
+	elapsed := w.now().Sub(q.ReceivedAt)
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	params := logql.NewLiteralParams(
+		q.Query,
+		q.Start.Add(elapsed),
+		q.End.Add(elapsed),
+		q.Step,
+		0,
+		logproto.BACKWARD,
+		q.Limit,
+		nil,
+	)
+	_, err := w.engine.Query(params).Exec(ctx)
+	return err
+}
@@ -0,0 +1,121 @@
+package querier
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/services"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/loki/pkg/logql"
+	"github.com/grafana/loki/pkg/logqlmodel"
+	"github.com/grafana/loki/pkg/querier/queryrange"
+	"github.com/grafana/loki/pkg/util/httpreq"
+)
+
+type warmupQuery struct {
+	tenant, source string
+	params         logql.Params
+}
+
+type fakeWarmupEngine struct {
+	mtx     sync.Mutex
+	queries []warmupQuery
+}
+
+func (e *fakeWarmupEngine) Query(params logql.Params) logql.Query {
+	return queryFunc(func(ctx context.Context) (logqlmodel.Result, error) {
+		tenant, err := user.ExtractOrgID(ctx)
+		if err != nil {
+			return logqlmodel.Result{}, err
+		}
+		e.mtx.Lock()
+		defer e.mtx.Unlock()
+		e.queries = append(e.queries, warmupQuery{tenant: tenant, source: httpreq.QuerySource(ctx), params: params})
+		return logqlmodel.Result{}, nil
+	})
+}
+
+type queryFunc func(ctx context.Context) (logqlmodel.Result, error)
+
+func (f queryFunc) Exec(ctx context.Context) (logqlmodel.Result, error) {
+	return f(ctx)
+}
+
+func TestWarmer(t *testing.T) {
+	now := time.Unix(10000, 0).UTC()
+	received := []queryrange.RecentQuery{
+		{Tenant: "a", Query: `{app="foo"}`, Start: now.Add(-2 * time.Hour), End: now.Add(-time.Hour), Step: time.Minute, Limit: 100, ReceivedAt: now.Add(-time.Hour)},
+		{Tenant: "b", Query: `count_over_time({app="bar"}[1m])`, Start: now, End: now, ReceivedAt: now},
+	}
+
+	var limit string
+	frontend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, queryrange.RecentQueriesPath, r.URL.Path)
+		limit = r.URL.Query().Get("limit")
+		require.NoError(t, json.NewEncoder(w).Encode(received))
+	}))
+	defer frontend.Close()
+
+	engine := &fakeWarmupEngine{}
+	next := services.NewIdleService(nil, nil)
+	w := newWarmer(WarmupConfig{
+		FrontendAddress: frontend.URL,
+		MaxQueries:      5,
+		Concurrency:     1,
+		Timeout:         time.Minute,
+	}, engine, next, log.NewNopLogger())
+	w.now = func() time.Time { return now }
+
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), w))
+	require.Equal(t, services.Running, next.State())
+	require.Equal(t, "5", limit)
+
+	require.Len(t, engine.queries, 2)
+	for _, q := range engine.queries {
+		require.Equal(t, httpreq.QuerySourceStore, q.source)
+		switch q.tenant {
+		case "a":
+			// the time range is moved forward by the time elapsed since the query was received.
+			require.Equal(t, now.Add(-time.Hour), q.params.Start())
+			require.Equal(t, now, q.params.End())
+			require.Equal(t, time.Minute, q.params.Step())
+			require.Equal(t, uint32(100), q.params.Limit())
+		case "b":
+			require.Equal(t, `count_over_time({app="bar"}[1m])`, q.params.Query())
+			require.Equal(t, now, q.params.Start())
+		default:
+			t.Fatalf("unexpected tenant %s", q.tenant)
+		}
+	}
+
+	require.NoError(t, services.StopAndAwaitTerminated(context.Background(), w))
+	require.Equal(t, services.Terminated, next.State())
+}
+
+func TestWarmer_FrontendUnavailable(t *testing.T) {
+	frontend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer frontend.Close()
+
+	engine := &fakeWarmupEngine{}
+	w := newWarmer(WarmupConfig{
+		FrontendAddress: frontend.URL,
+		MaxQueries:      5,
+		Concurrency:     1,
+		Timeout:         time.Minute,
+	}, engine, nil, log.NewNopLogger())
+
+	// the querier still becomes ready.
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), w))
+	require.Empty(t, engine.queries)
+	require.NoError(t, services.StopAndAwaitTerminated(context.Background(), w))
+}
@@ -35,6 +35,7 @@ func newSchedulerProcessor(cfg Config, handler RequestHandler, log log.Logger, m
 		handler:        handler,
 		maxMessageSize: cfg.GRPCClientConfig.MaxSendMsgSize,
 		querierID:      cfg.QuerierID,
+		pool:           cfg.Pool,
 		grpcConfig:     cfg.GRPCClientConfig,
 
 		metrics: metrics,
@@ -57,6 +58,7 @@ type schedulerProcessor struct {
 	grpcConfig     grpcclient.Config
 	maxMessageSize int
 	querierID      string
+	pool           string
 
 	frontendPool *client.Pool
 	metrics      *Metrics
@@ -78,7 +80,7 @@ func (sp *schedulerProcessor) processQueriesOnSingleStream(ctx context.Context,
 
 	backoff := backoff.New(ctx, processorBackoffConfig)
 	for backoff.Ongoing() {
-		c, err := schedulerClient.QuerierLoop(ctx)
+		c, err := schedulerClient.QuerierLoop(schedulerpb.ContextWithQuerierPool(ctx, sp.pool))
 		if err == nil {
 			err = c.Send(&schedulerpb.QuerierToScheduler{QuerierID: sp.querierID})
 		}
@@ -31,6 +31,7 @@ type Config struct {
 	MaxConcurrentRequests int  `yaml:"-"` // Must be same as passed to LogQL Engine.
 
 	QuerierID string `yaml:"id"`
+	Pool      string `yaml:"pool"`
 
 	GRPCClientConfig grpcclient.Config `yaml:"grpc_client_config"`
 }
@@ -44,6 +45,7 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&cfg.Parallelism, "querier.worker-parallelism", 10, "Number of simultaneous queries to process per query-frontend or query-scheduler.")
 	f.BoolVar(&cfg.MatchMaxConcurrency, "querier.worker-match-max-concurrent", true, "Force worker concurrency to match the -querier.max-concurrent option. Overrides querier.worker-parallelism.")
 	f.StringVar(&cfg.QuerierID, "querier.id", "", "Querier ID, sent to frontend service to identify requests from the same querier. Defaults to hostname.")
+	f.StringVar(&cfg.Pool, "querier.pool", "", "Pool of the querier, sent to the query-scheduler. The queriers of a pool only handle the queries of the tenants whose querier_pool limit is set to the pool. Empty for the shared pool, handling the queries of all other tenants.")
 
 	cfg.GRPCClientConfig.RegisterFlagsWithPrefix("querier.frontend-client", f)
 }
@@ -57,6 +57,12 @@ func InitWorkerService(
 	// Create a couple Middlewares used to handle panics, perform auth, parse forms in http request, and set content type in response
 	handlerMiddleware := middleware.Merge(
 		httpreq.ExtractQueryTagsMiddleware(),
+		httpreq.ExtractSeriesLimitStrategyMiddleware(),
+		httpreq.ExtractQueryNoSeriesLimitMiddleware(),
+		httpreq.ExtractQueryStrictParsingMiddleware(),
+		httpreq.ExtractQuerySourceMiddleware(),
+		httpreq.ExtractQueryRoleMiddleware(),
+		httpreq.ExtractQueryScopeMiddleware(),
 		serverutil.RecoveryHTTPMiddleware,
 		authMiddleware,
 		serverutil.NewPrepopulateMiddleware(),
@@ -20,6 +20,7 @@ import (
 	"github.com/weaveworks/common/user"
 
 	"github.com/grafana/loki/pkg/logproto"
+	ruler_util "github.com/grafana/loki/pkg/ruler/util"
 	util_log "github.com/grafana/loki/pkg/util/log"
 )
 
@@ -129,6 +130,8 @@ type RulesLimits interface {
 	RulerTenantShardSize(userID string) int
 	RulerMaxRuleGroupsPerTenant(userID string) int
 	RulerMaxRulesPerRuleGroup(userID string) int
+	RulerExternalLabels(userID string) labels.Labels
+	RulerAlertRelabelConfigs(userID string) []*ruler_util.RelabelConfig
 }
 
 // EngineQueryFunc returns a new query function using the rules.EngineQueryFunc function
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"reflect"
 	"sync"
 
 	"github.com/go-kit/log"
@@ -13,6 +14,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/prometheus/config"
+	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/model/rulefmt"
 	"github.com/prometheus/prometheus/notifier"
 	promRules "github.com/prometheus/prometheus/rules"
@@ -20,12 +22,14 @@ import (
 	"golang.org/x/net/context/ctxhttp"
 
 	"github.com/grafana/loki/pkg/ruler/rulespb"
+	ruler_util "github.com/grafana/loki/pkg/ruler/util"
 )
 
 type DefaultMultiTenantManager struct {
 	cfg            Config
 	notifierCfg    *config.Config
 	managerFactory ManagerFactory
+	limits         RulesLimits
 
 	mapper *mapper
 
@@ -34,6 +38,8 @@ type DefaultMultiTenantManager struct {
 	userManagerMtx     sync.Mutex
 	userManagers       map[string]RulesManager
 	userManagerMetrics *ManagerMetrics
+	// External labels the users managers were last updated with.
+	userExternalLabels map[string]labels.Labels
 
 	// Per-user notifiers with separate queues.
 	notifiersMtx sync.Mutex
@@ -47,7 +53,7 @@ type DefaultMultiTenantManager struct {
 	logger                        log.Logger
 }
 
-func NewDefaultMultiTenantManager(cfg Config, managerFactory ManagerFactory, reg prometheus.Registerer, logger log.Logger) (*DefaultMultiTenantManager, error) {
+func NewDefaultMultiTenantManager(cfg Config, managerFactory ManagerFactory, limits RulesLimits, reg prometheus.Registerer, logger log.Logger) (*DefaultMultiTenantManager, error) {
 	ncfg, err := buildNotifierConfig(&cfg)
 	if err != nil {
 		return nil, err
@@ -62,9 +68,11 @@ func NewDefaultMultiTenantManager(cfg Config, managerFactory ManagerFactory, reg
 		cfg:                cfg,
 		notifierCfg:        ncfg,
 		managerFactory:     managerFactory,
+		limits:             limits,
 		notifiers:          map[string]*rulerNotifier{},
 		mapper:             newMapper(cfg.RulePath, logger),
 		userManagers:       map[string]RulesManager{},
+		userExternalLabels: map[string]labels.Labels{},
 		userManagerMetrics: userManagerMetrics,
 		managersTotal: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
 			Namespace: "cortex",
@@ -106,6 +114,7 @@ func (r *DefaultMultiTenantManager) SyncRuleGroups(ctx context.Context, ruleGrou
 		if _, exists := ruleGroups[userID]; !exists {
 			go mngr.Stop()
 			delete(r.userManagers, userID)
+			delete(r.userExternalLabels, userID)
 
 			r.mapper.cleanupUser(userID)
 			r.lastReloadSuccessful.DeleteLabelValues(userID)
@@ -131,8 +140,14 @@ func (r *DefaultMultiTenantManager) syncRulesToManager(ctx context.Context, user
 		return
 	}
 
+	if err := r.syncNotifierConfig(user); err != nil {
+		level.Error(r.logger).Log("msg", "unable to update notifier config", "user", user, "err", err)
+	}
+
+	// The rules manager is updated as well when the external labels of the user changed.
+	externalLabels := ruler_util.MergeExternalLabels(r.cfg.ExternalLabels, r.limits.RulerExternalLabels(user))
 	manager, exists := r.userManagers[user]
-	if !exists || update {
+	if !exists || update || !labels.Equal(externalLabels, r.userExternalLabels[user]) {
 		level.Debug(r.logger).Log("msg", "updating rules", "user", user)
 		r.configUpdatesTotal.WithLabelValues(user).Inc()
 		if !exists {
@@ -148,12 +163,13 @@ func (r *DefaultMultiTenantManager) syncRulesToManager(ctx context.Context, user
 			go manager.Run()
 			r.userManagers[user] = manager
 		}
-		err = manager.Update(r.cfg.EvaluationInterval, files, r.cfg.ExternalLabels, r.cfg.ExternalURL.String())
+		err = manager.Update(r.cfg.EvaluationInterval, files, externalLabels, r.cfg.ExternalURL.String())
 		if err != nil {
 			r.lastReloadSuccessful.WithLabelValues(user).Set(0)
 			level.Error(r.logger).Log("msg", "unable to update rule manager", "user", user, "err", err)
 			return
 		}
+		r.userExternalLabels[user] = externalLabels
 
 		r.lastReloadSuccessful.WithLabelValues(user).Set(1)
 		r.lastReloadSuccessfulTimestamp.WithLabelValues(user).SetToCurrentTime()
@@ -209,8 +225,8 @@ func (r *DefaultMultiTenantManager) getOrCreateNotifier(userID string) (*notifie
 
 	n.run()
 
-	// This should never fail, unless there's a programming mistake.
-	if err := n.applyConfig(r.notifierCfg); err != nil {
+	if err := r.applyNotifierConfig(userID, n); err != nil {
+		n.stop()
 		return nil, err
 	}
 
@@ -218,6 +234,46 @@ func (r *DefaultMultiTenantManager) getOrCreateNotifier(userID string) (*notifie
 	return n.notifier, nil
 }
 
+// syncNotifierConfig applies the notifier config to the existing notifier of the given user.
+func (r *DefaultMultiTenantManager) syncNotifierConfig(userID string) error {
+	r.notifiersMtx.Lock()
+	defer r.notifiersMtx.Unlock()
+
+	n, ok := r.notifiers[userID]
+	if !ok {
+		return nil
+	}
+	return r.applyNotifierConfig(userID, n)
+}
+
+// applyNotifierConfig applies the notifier config, along with the external labels
+// and alert relabel configs of the given user, unless they didn't change since
+// the config was last applied.
+func (r *DefaultMultiTenantManager) applyNotifierConfig(userID string, n *rulerNotifier) error {
+	externalLabels := ruler_util.MergeExternalLabels(r.cfg.ExternalLabels, r.limits.RulerExternalLabels(userID))
+	relabelConfigs := r.limits.RulerAlertRelabelConfigs(userID)
+	if n.configured && labels.Equal(n.externalLabels, externalLabels) && reflect.DeepEqual(n.alertRelabelConfigs, relabelConfigs) {
+		return nil
+	}
+
+	alertRelabelConfigs, err := ruler_util.ToPrometheusRelabelConfigs(relabelConfigs)
+	if err != nil {
+		return errors.Wrap(err, "invalid alert relabel configs")
+	}
+
+	cfg := *r.notifierCfg
+	cfg.GlobalConfig.ExternalLabels = externalLabels
+	cfg.AlertingConfig.AlertRelabelConfigs = alertRelabelConfigs
+	if err := n.applyConfig(&cfg); err != nil {
+		return err
+	}
+
+	n.configured = true
+	n.externalLabels = externalLabels
+	n.alertRelabelConfigs = relabelConfigs
+	return nil
+}
+
 func (r *DefaultMultiTenantManager) GetRules(userID string) []*promRules.Group {
 	var groups []*promRules.Group
 	r.userManagerMtx.Lock()
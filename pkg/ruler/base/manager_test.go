@@ -14,13 +14,14 @@ import (
 	"go.uber.org/atomic"
 
 	"github.com/grafana/loki/pkg/ruler/rulespb"
+	ruler_util "github.com/grafana/loki/pkg/ruler/util"
 	"github.com/grafana/loki/pkg/util/test"
 )
 
 func TestSyncRuleGroups(t *testing.T) {
 	dir := t.TempDir()
 
-	m, err := NewDefaultMultiTenantManager(Config{RulePath: dir}, factory, nil, log.NewNopLogger())
+	m, err := NewDefaultMultiTenantManager(Config{RulePath: dir}, factory, ruleLimits{}, nil, log.NewNopLogger())
 	require.NoError(t, err)
 
 	const user = "testUser"
@@ -92,6 +93,51 @@ func TestSyncRuleGroups(t *testing.T) {
 	})
 }
 
+func TestSyncRuleGroupsExternalLabels(t *testing.T) {
+	dir := t.TempDir()
+	limits := &ruleLimits{externalLabels: labels.FromStrings("env", "tenant")}
+	m, err := NewDefaultMultiTenantManager(Config{RulePath: dir, ExternalLabels: labels.FromStrings("cluster", "a", "env", "global")}, factory, limits, nil, log.NewNopLogger())
+	require.NoError(t, err)
+	t.Cleanup(m.Stop)
+
+	const user = "testUser"
+
+	userRules := map[string]rulespb.RuleGroupList{
+		user: {
+			&rulespb.RuleGroupDesc{
+				Name:      "group1",
+				Namespace: "ns",
+				Interval:  1 * time.Minute,
+				User:      user,
+			},
+		},
+	}
+	m.SyncRuleGroups(context.Background(), userRules)
+
+	mgr := getManager(m, user).(*mockRulesManager)
+	require.Equal(t, 1, mgr.updates)
+	require.Equal(t, labels.FromStrings("cluster", "a", "env", "tenant"), mgr.externalLabels)
+	require.Equal(t, labels.FromStrings("cluster", "a", "env", "tenant"), m.notifiers[user].externalLabels)
+
+	// Syncing the same rules and limits doesn't update the manager.
+	m.SyncRuleGroups(context.Background(), userRules)
+	require.Equal(t, 1, mgr.updates)
+
+	// Changing the external labels of the tenant updates the manager and the notifier.
+	limits.externalLabels = labels.FromStrings("team", "b")
+	limits.alertRelabelConfigs = []*ruler_util.RelabelConfig{{Action: "labeldrop", Regex: "cluster"}}
+	m.SyncRuleGroups(context.Background(), userRules)
+	require.Equal(t, 2, mgr.updates)
+	require.Equal(t, labels.FromStrings("cluster", "a", "env", "global", "team", "b"), mgr.externalLabels)
+	require.Equal(t, labels.FromStrings("cluster", "a", "env", "global", "team", "b"), m.notifiers[user].externalLabels)
+	require.Equal(t, limits.alertRelabelConfigs, m.notifiers[user].alertRelabelConfigs)
+
+	// Invalid alert relabel configs aren't applied.
+	limits.alertRelabelConfigs = []*ruler_util.RelabelConfig{{Action: "labeldrop", Regex: "("}}
+	m.SyncRuleGroups(context.Background(), userRules)
+	require.Equal(t, []*ruler_util.RelabelConfig{{Action: "labeldrop", Regex: "cluster"}}, m.notifiers[user].alertRelabelConfigs)
+}
+
 func getManager(m *DefaultMultiTenantManager, user string) RulesManager {
 	m.userManagerMtx.Lock()
 	defer m.userManagerMtx.Unlock()
@@ -106,6 +152,9 @@ func factory(_ context.Context, _ string, _ *notifier.Manager, _ log.Logger, _ p
 type mockRulesManager struct {
 	running atomic.Bool
 	done    chan struct{}
+
+	updates        int
+	externalLabels labels.Labels
 }
 
 func (m *mockRulesManager) Run() {
@@ -118,7 +167,9 @@ func (m *mockRulesManager) Stop() {
 	close(m.done)
 }
 
-func (m *mockRulesManager) Update(_ time.Duration, _ []string, _ labels.Labels, _ string) error {
+func (m *mockRulesManager) Update(_ time.Duration, _ []string, externalLabels labels.Labels, _ string) error {
+	m.updates++
+	m.externalLabels = externalLabels
 	return nil
 }
 
@@ -17,8 +17,10 @@ import (
 	"github.com/prometheus/prometheus/config"
 	"github.com/prometheus/prometheus/discovery"
 	"github.com/prometheus/prometheus/discovery/dns"
+	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/notifier"
 
+	ruler_util "github.com/grafana/loki/pkg/ruler/util"
 	"github.com/grafana/loki/pkg/util"
 )
 
@@ -41,6 +43,11 @@ type rulerNotifier struct {
 	sdManager *discovery.Manager
 	wg        sync.WaitGroup
 	logger    gklog.Logger
+
+	// Tenant overrides of the applied config, to only apply it again when they change.
+	configured          bool
+	externalLabels      labels.Labels
+	alertRelabelConfigs []*ruler_util.RelabelConfig
 }
 
 func newRulerNotifier(o *notifier.Options, l gklog.Logger) *rulerNotifier {
@@ -45,6 +45,7 @@ import (
 	"github.com/grafana/loki/pkg/ruler/rulespb"
 	"github.com/grafana/loki/pkg/ruler/rulestore"
 	"github.com/grafana/loki/pkg/ruler/rulestore/objectclient"
+	ruler_util "github.com/grafana/loki/pkg/ruler/util"
 	"github.com/grafana/loki/pkg/storage/chunk"
 	"github.com/grafana/loki/pkg/storage/chunk/hedging"
 	chunk_storage "github.com/grafana/loki/pkg/storage/chunk/storage"
@@ -82,6 +83,8 @@ type ruleLimits struct {
 	tenantShard          int
 	maxRulesPerRuleGroup int
 	maxRuleGroups        int
+	externalLabels       labels.Labels
+	alertRelabelConfigs  []*ruler_util.RelabelConfig
 }
 
 func (r ruleLimits) EvaluationDelay(_ string) time.Duration {
@@ -100,6 +103,14 @@ func (r ruleLimits) RulerMaxRulesPerRuleGroup(_ string) int {
 	return r.maxRulesPerRuleGroup
 }
 
+func (r ruleLimits) RulerExternalLabels(_ string) labels.Labels {
+	return r.externalLabels
+}
+
+func (r ruleLimits) RulerAlertRelabelConfigs(_ string) []*ruler_util.RelabelConfig {
+	return r.alertRelabelConfigs
+}
+
 func testQueryableFunc(q storage.Querier) storage.QueryableFunc {
 	if q != nil {
 		return func(ctx context.Context, mint, maxt int64) (storage.Querier, error) {
@@ -138,7 +149,7 @@ func testSetup(t *testing.T, q storage.Querier) (*promql.Engine, storage.Queryab
 
 func newManager(t *testing.T, cfg Config, q storage.Querier) *DefaultMultiTenantManager {
 	engine, queryable, pusher, logger, overrides, reg := testSetup(t, q)
-	manager, err := NewDefaultMultiTenantManager(cfg, DefaultTenantManagerFactory(cfg, pusher, queryable, engine, overrides, nil), reg, logger)
+	manager, err := NewDefaultMultiTenantManager(cfg, DefaultTenantManagerFactory(cfg, pusher, queryable, engine, overrides, nil), overrides, reg, logger)
 	require.NoError(t, err)
 
 	return manager
@@ -188,7 +199,7 @@ func buildRuler(t *testing.T, rulerConfig Config, q storage.Querier, clientMetri
 	require.NoError(t, err)
 
 	managerFactory := DefaultTenantManagerFactory(rulerConfig, pusher, queryable, engine, overrides, reg)
-	manager, err := NewDefaultMultiTenantManager(rulerConfig, managerFactory, reg, log.NewNopLogger())
+	manager, err := NewDefaultMultiTenantManager(rulerConfig, managerFactory, overrides, reg, log.NewNopLogger())
 	require.NoError(t, err)
 
 	ruler, err := newRuler(
@@ -20,10 +20,12 @@ import (
 	"github.com/grafana/loki/pkg/storage/chunk"
 	"github.com/grafana/loki/pkg/storage/chunk/aws"
 	"github.com/grafana/loki/pkg/storage/chunk/azure"
+	"github.com/grafana/loki/pkg/storage/chunk/baidubce"
 	"github.com/grafana/loki/pkg/storage/chunk/gcp"
 	"github.com/grafana/loki/pkg/storage/chunk/hedging"
 	"github.com/grafana/loki/pkg/storage/chunk/openstack"
 	"github.com/grafana/loki/pkg/storage/chunk/storage"
+	"github.com/grafana/loki/pkg/storage/chunk/tencent"
 )
 
 // RuleStoreConfig configures a rule store.
@@ -33,11 +35,13 @@ type RuleStoreConfig struct {
 	ConfigDB configClient.Config `yaml:"configdb"`
 
 	// Object Storage Configs
-	Azure azure.BlobStorageConfig `yaml:"azure"`
-	GCS   gcp.GCSConfig           `yaml:"gcs"`
-	S3    aws.S3Config            `yaml:"s3"`
-	Swift openstack.SwiftConfig   `yaml:"swift"`
-	Local local.Config            `yaml:"local"`
+	Azure azure.BlobStorageConfig   `yaml:"azure"`
+	GCS   gcp.GCSConfig             `yaml:"gcs"`
+	S3    aws.S3Config              `yaml:"s3"`
+	Swift openstack.SwiftConfig     `yaml:"swift"`
+	COS   tencent.CosConfig         `yaml:"cos"`
+	BOS   baidubce.BOSStorageConfig `yaml:"bos"`
+	Local local.Config              `yaml:"local"`
 
 	mock rulestore.RuleStore `yaml:"-"`
 }
@@ -49,9 +53,11 @@ func (cfg *RuleStoreConfig) RegisterFlags(f *flag.FlagSet) {
 	cfg.GCS.RegisterFlagsWithPrefix("ruler.storage.", f)
 	cfg.S3.RegisterFlagsWithPrefix("ruler.storage.", f)
 	cfg.Swift.RegisterFlagsWithPrefix("ruler.storage.", f)
+	cfg.COS.RegisterFlagsWithPrefix("ruler.storage.", f)
+	cfg.BOS.RegisterFlagsWithPrefix("ruler.storage.", f)
 	cfg.Local.RegisterFlagsWithPrefix("ruler.storage.", f)
 
-	f.StringVar(&cfg.Type, "ruler.storage.type", "configdb", "Method to use for backend rule storage (configdb, azure, gcs, s3, swift, local)")
+	f.StringVar(&cfg.Type, "ruler.storage.type", "configdb", "Method to use for backend rule storage (configdb, azure, gcs, s3, swift, cos, bos, local)")
 }
 
 // Validate config and returns error on failure
@@ -85,9 +91,6 @@ func NewLegacyRuleStore(cfg RuleStoreConfig, hedgeCfg hedging.Config, clientMetr
 		loader = promRules.FileLoader{}
 	}
 
-	var err error
-	var client chunk.ObjectClient
-
 	switch cfg.Type {
 	case "configdb":
 		c, err := configClient.New(cfg.ConfigDB)
@@ -95,20 +98,11 @@ func NewLegacyRuleStore(cfg RuleStoreConfig, hedgeCfg hedging.Config, clientMetr
 			return nil, err
 		}
 		return configdb.NewConfigRuleStore(c), nil
-	case "azure":
-		client, err = azure.NewBlobStorage(&cfg.Azure, clientMetrics.AzureMetrics, hedgeCfg)
-	case "gcs":
-		client, err = gcp.NewGCSObjectClient(context.Background(), cfg.GCS, hedgeCfg)
-	case "s3":
-		client, err = aws.NewS3ObjectClient(cfg.S3, hedgeCfg)
-	case "swift":
-		client, err = openstack.NewSwiftObjectClient(cfg.Swift, hedgeCfg)
 	case "local":
 		return local.NewLocalRulesClient(cfg.Local, loader)
-	default:
-		return nil, fmt.Errorf("unrecognized rule storage mode %v, choose one of: configdb, gcs, s3, swift, azure, local", cfg.Type)
 	}
 
+	client, err := NewLegacyRuleObjectClient(cfg, hedgeCfg, clientMetrics)
 	if err != nil {
 		return nil, err
 	}
@@ -116,6 +110,29 @@ func NewLegacyRuleStore(cfg RuleStoreConfig, hedgeCfg hedging.Config, clientMetr
 	return objectclient.NewRuleStore(client, loadRulesConcurrency, logger), nil
 }
 
+// NewLegacyRuleObjectClient returns the client of the object store of the rules of the provided cfg,
+// nil when the rules aren't stored in an object store.
+func NewLegacyRuleObjectClient(cfg RuleStoreConfig, hedgeCfg hedging.Config, clientMetrics storage.ClientMetrics) (chunk.ObjectClient, error) {
+	switch cfg.Type {
+	case "configdb", "local":
+		return nil, nil
+	case "azure":
+		return azure.NewBlobStorage(&cfg.Azure, clientMetrics.AzureMetrics, hedgeCfg)
+	case "gcs":
+		return gcp.NewGCSObjectClient(context.Background(), cfg.GCS, hedgeCfg)
+	case "s3":
+		return aws.NewS3ObjectClient(cfg.S3, hedgeCfg)
+	case "swift":
+		return openstack.NewSwiftObjectClient(cfg.Swift, hedgeCfg)
+	case "cos":
+		return tencent.NewCosObjectClient(cfg.COS, hedgeCfg)
+	case "bos":
+		return baidubce.NewBOSObjectClient(cfg.BOS, hedgeCfg)
+	default:
+		return nil, fmt.Errorf("unrecognized rule storage mode %v, choose one of: configdb, gcs, s3, swift, azure, cos, bos, local", cfg.Type)
+	}
+}
+
 // NewRuleStore returns a rule store backend client based on the provided cfg.
 func NewRuleStore(ctx context.Context, cfg rulestore.Config, cfgProvider bucket.TenantConfigProvider, loader promRules.GroupLoader, logger log.Logger, reg prometheus.Registerer) (rulestore.RuleStore, error) {
 	if cfg.Backend == configdb.Name {
@@ -19,11 +19,11 @@ import (
 	"github.com/prometheus/prometheus/model/relabel"
 	"github.com/prometheus/prometheus/storage"
 	"github.com/weaveworks/common/user"
-	"gopkg.in/yaml.v2"
 
 	"github.com/grafana/loki/pkg/ruler/storage/cleaner"
 	"github.com/grafana/loki/pkg/ruler/storage/instance"
 	"github.com/grafana/loki/pkg/ruler/storage/wal"
+	"github.com/grafana/loki/pkg/ruler/util"
 )
 
 type walRegistry struct {
@@ -181,6 +181,7 @@ func (r *walRegistry) getTenantConfig(tenant string) (instance.Config, error) {
 
 	conf.Name = tenant
 	conf.Tenant = tenant
+	conf.ExternalLabels = util.MergeExternalLabels(r.config.ExternalLabels, r.overrides.RulerExternalLabels(tenant))
 
 	// we don't need to send metadata - we have no scrape targets
 	r.config.RemoteWrite.Client.MetadataConfig.Send = false
@@ -302,30 +303,9 @@ func (r *walRegistry) getTenantRemoteWriteConfig(tenant string, base RemoteWrite
 // createRelabelConfigs converts the util.RelabelConfig into relabel.Config to allow for
 // more control over json/yaml unmarshaling
 func (r *walRegistry) createRelabelConfigs(tenant string) ([]*relabel.Config, error) {
-	configs := r.overrides.RulerRemoteWriteRelabelConfigs(tenant)
-
-	// zero value is nil, which we want to treat as "no override"
-	if configs == nil {
-		return nil, nil
-	}
-
+	// zero value is nil, which we want to treat as "no override", while
 	// we want to treat an empty slice as "no relabel configs"
-	relabelConfigs := make([]*relabel.Config, len(configs))
-	for i, config := range configs {
-		out, err := yaml.Marshal(config)
-		if err != nil {
-			return nil, err
-		}
-
-		var rc relabel.Config
-		if err = yaml.Unmarshal(out, &rc); err != nil {
-			return nil, err
-		}
-
-		relabelConfigs[i] = &rc
-	}
-
-	return relabelConfigs, nil
+	return util.ToPrometheusRelabelConfigs(r.overrides.RulerRemoteWriteRelabelConfigs(tenant))
 }
 
 var errNotReady = errors.New("appender not ready")
@@ -13,6 +13,7 @@ import (
 	promConfig "github.com/prometheus/common/config"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/config"
+	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/model/relabel"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -32,6 +33,7 @@ const customRelabelsTenant = "custom-relabels"
 const badRelabelsTenant = "bad-relabels"
 const nilRelabelsTenant = "nil-relabels"
 const emptySliceRelabelsTenant = "empty-slice-relabels"
+const externalLabelsTenant = "external-labels"
 
 const defaultCapacity = 1000
 
@@ -73,6 +75,9 @@ func newFakeLimits() fakeLimits {
 			emptySliceRelabelsTenant: {
 				RulerRemoteWriteRelabelConfigs: []*util.RelabelConfig{},
 			},
+			externalLabelsTenant: {
+				RulerExternalLabels: labels.FromStrings("env", "tenant", "team", "a"),
+			},
 			badRelabelsTenant: {
 				RulerRemoteWriteRelabelConfigs: []*util.RelabelConfig{
 					{
@@ -121,6 +126,7 @@ func setupRegistry(t *testing.T, dir string) *walRegistry {
 			Dir: dir,
 		},
 	}
+	cfg.ExternalLabels = labels.FromStrings("cluster", "a", "env", "global")
 
 	overrides, err := validation.NewOverrides(validation.Limits{}, newFakeLimits())
 	require.NoError(t, err)
@@ -275,6 +281,20 @@ func TestRelabelConfigOverridesWithErrors(t *testing.T) {
 	require.EqualError(t, err, "failed to parse relabel configs: labeldrop action requires only 'regex', and no other fields")
 }
 
+func TestExternalLabelsOverrides(t *testing.T) {
+	walDir := t.TempDir()
+	reg := setupRegistry(t, walDir)
+
+	tenantCfg, err := reg.getTenantConfig(enabledRWTenant)
+	require.NoError(t, err)
+	assert.Equal(t, labels.FromStrings("cluster", "a", "env", "global"), tenantCfg.ExternalLabels)
+
+	// the tenant external labels are merged with the ruler ones, overriding them
+	tenantCfg, err = reg.getTenantConfig(externalLabelsTenant)
+	require.NoError(t, err)
+	assert.Equal(t, labels.FromStrings("cluster", "a", "env", "tenant", "team", "a"), tenantCfg.ExternalLabels)
+}
+
 func TestWALRegistryCreation(t *testing.T) {
 	overrides, err := validation.NewOverrides(validation.Limits{}, nil)
 	require.NoError(t, err)
@@ -13,6 +13,7 @@ func NewRuler(cfg Config, engine *logql.Engine, reg prometheus.Registerer, logge
 	mgr, err := ruler.NewDefaultMultiTenantManager(
 		cfg.Config,
 		MultiTenantRuleManager(cfg, engine, limits, logger, reg),
+		limits,
 		reg,
 		logger,
 	)
@@ -31,6 +31,9 @@ import (
 const (
 	delim      = "/"
 	rulePrefix = "rules" + delim
+
+	// RulePrefix is the prefix of the keys of the rule groups in the object store.
+	RulePrefix = rulePrefix
 )
 
 // RuleStore allows cortex rules to be stored using an object store backend.
@@ -20,6 +20,7 @@ import (
 	"github.com/oklog/run"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/prometheus/config"
+	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/model/timestamp"
 	"github.com/prometheus/prometheus/scrape"
 	"github.com/prometheus/prometheus/storage"
@@ -56,6 +57,8 @@ type Config struct {
 	Tenant      string
 	Name        string
 	RemoteWrite []*config.RemoteWriteConfig
+	// Labels added to the remote-written series.
+	ExternalLabels labels.Labels `yaml:"external_labels,omitempty"`
 
 	Dir string `yaml:"dir"`
 
@@ -301,6 +304,7 @@ func (i *Instance) initialize(_ context.Context, reg prometheus.Registerer, cfg
 	remoteLogger := log.With(i.logger, "component", "remote")
 	i.remoteStore = remote.NewStorage(remoteLogger, reg, i.wal.StartTime, i.wal.Directory(), cfg.RemoteFlushDeadline, noopScrapeManager{})
 	err = i.remoteStore.ApplyConfig(&config.Config{
+		GlobalConfig:       config.GlobalConfig{ExternalLabels: cfg.ExternalLabels},
 		RemoteWriteConfigs: cfg.RemoteWrite,
 	})
 	if err != nil {
@@ -362,6 +366,7 @@ func (i *Instance) Update(c Config) (err error) {
 	i.cfg = c
 
 	err = i.remoteStore.ApplyConfig(&config.Config{
+		GlobalConfig:       config.GlobalConfig{ExternalLabels: c.ExternalLabels},
 		RemoteWriteConfigs: c.RemoteWrite,
 	})
 	if err != nil {
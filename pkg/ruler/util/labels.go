@@ -0,0 +1,18 @@
+package util
+
+import (
+	"github.com/prometheus/prometheus/model/labels"
+)
+
+// MergeExternalLabels returns the given external labels overridden by the given tenant external labels.
+func MergeExternalLabels(external, tenant labels.Labels) labels.Labels {
+	if len(tenant) == 0 {
+		return external
+	}
+
+	b := labels.NewBuilder(external)
+	for _, l := range tenant {
+		b.Set(l.Name, l.Value)
+	}
+	return b.Labels()
+}
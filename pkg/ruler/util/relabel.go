@@ -1,5 +1,10 @@
 package util
 
+import (
+	"github.com/prometheus/prometheus/model/relabel"
+	"gopkg.in/yaml.v2"
+)
+
 // copy and modification of github.com/prometheus/prometheus/model/relabel/relabel.go
 // reason: the custom types in github.com/prometheus/prometheus/model/relabel/relabel.go are difficult to unmarshal
 type RelabelConfig struct {
@@ -20,3 +25,28 @@ type RelabelConfig struct {
 	// Action is the action to be performed for the relabeling.
 	Action string `yaml:"action,omitempty" json:"action,omitempty"`
 }
+
+// ToPrometheusRelabelConfigs converts the given relabel configs into relabel.Config, validating them.
+// A nil slice is returned for nil configs, to differentiate them from an empty list.
+func ToPrometheusRelabelConfigs(configs []*RelabelConfig) ([]*relabel.Config, error) {
+	if configs == nil {
+		return nil, nil
+	}
+
+	relabelConfigs := make([]*relabel.Config, len(configs))
+	for i, config := range configs {
+		out, err := yaml.Marshal(config)
+		if err != nil {
+			return nil, err
+		}
+
+		var rc relabel.Config
+		if err = yaml.Unmarshal(out, &rc); err != nil {
+			return nil, err
+		}
+
+		relabelConfigs[i] = &rc
+	}
+
+	return relabelConfigs, nil
+}
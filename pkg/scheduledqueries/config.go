@@ -0,0 +1,131 @@
+package scheduledqueries
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"text/template"
+	"time"
+
+	"github.com/grafana/loki/pkg/logql/syntax"
+)
+
+// Config configures the scheduled queries.
+type Config struct {
+	QueryTimeout    time.Duration `yaml:"query_timeout"`
+	DeliveryTimeout time.Duration `yaml:"delivery_timeout"`
+	Queries         []QueryConfig `yaml:"queries"`
+}
+
+// RegisterFlags adds the flags required to config this to the given FlagSet.
+func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
+	f.DurationVar(&cfg.QueryTimeout, "scheduled-queries.query-timeout", 5*time.Minute, "Timeout of a single run of a scheduled query.")
+	f.DurationVar(&cfg.DeliveryTimeout, "scheduled-queries.delivery-timeout", 30*time.Second, "Timeout for delivering the result of a scheduled query to one of its sinks.")
+}
+
+// Validate validates the config.
+func (cfg *Config) Validate() error {
+	names := make(map[string]struct{}, len(cfg.Queries))
+	for i := range cfg.Queries {
+		q := &cfg.Queries[i]
+		if _, ok := names[q.Name]; ok {
+			return fmt.Errorf("duplicate scheduled query name %q", q.Name)
+		}
+		names[q.Name] = struct{}{}
+
+		if err := q.Validate(); err != nil {
+			return fmt.Errorf("invalid scheduled query %q: %w", q.Name, err)
+		}
+	}
+	return nil
+}
+
+// QueryConfig is a query to run on a schedule and where to deliver its results.
+type QueryConfig struct {
+	Name     string        `yaml:"name"`
+	Tenant   string        `yaml:"tenant"`
+	Query    string        `yaml:"query"`
+	Schedule string        `yaml:"schedule"`
+	Timezone string        `yaml:"timezone"`
+	Range    time.Duration `yaml:"range"`
+	Step     time.Duration `yaml:"step"`
+	Limit    uint32        `yaml:"limit"`
+
+	Webhook       *WebhookConfig       `yaml:"webhook,omitempty"`
+	ObjectStorage *ObjectStorageConfig `yaml:"object_storage,omitempty"`
+}
+
+// Validate validates the query config.
+func (cfg *QueryConfig) Validate() error {
+	if cfg.Name == "" {
+		return errors.New("name is required")
+	}
+	if cfg.Tenant == "" {
+		return errors.New("tenant is required")
+	}
+
+	expr, err := syntax.ParseExpr(cfg.Query)
+	if err != nil {
+		return fmt.Errorf("invalid query: %w", err)
+	}
+	if cfg.Range < 0 || cfg.Step < 0 {
+		return errors.New("range and step must not be negative")
+	}
+	if _, ok := expr.(syntax.SampleExpr); !ok && cfg.Range == 0 {
+		return errors.New("log queries require a range")
+	}
+
+	if _, err := ParseSchedule(cfg.Schedule); err != nil {
+		return err
+	}
+	if _, err := time.LoadLocation(cfg.Timezone); err != nil {
+		return fmt.Errorf("invalid timezone: %w", err)
+	}
+
+	if cfg.Webhook == nil && cfg.ObjectStorage == nil {
+		return errors.New("at least one sink (webhook or object_storage) is required")
+	}
+	if cfg.Webhook != nil {
+		if err := cfg.Webhook.Validate(); err != nil {
+			return fmt.Errorf("invalid webhook: %w", err)
+		}
+	}
+	if cfg.ObjectStorage != nil && cfg.ObjectStorage.Store == "" {
+		return errors.New("invalid object_storage: store is required")
+	}
+	return nil
+}
+
+// WebhookConfig configures the delivery of results with an HTTP POST request.
+type WebhookConfig struct {
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"`
+	// Template is a Go text/template used to render the request body. The JSON
+	// encoded report is sent when it's empty.
+	Template string `yaml:"template"`
+}
+
+// Validate validates the webhook config.
+func (cfg *WebhookConfig) Validate() error {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return fmt.Errorf("invalid url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid url %q: scheme must be http or https", cfg.URL)
+	}
+	if cfg.Template != "" {
+		if _, err := template.New("webhook").Parse(cfg.Template); err != nil {
+			return fmt.Errorf("invalid template: %w", err)
+		}
+	}
+	return nil
+}
+
+// ObjectStorageConfig configures the delivery of results as files in an object store.
+type ObjectStorageConfig struct {
+	// Store is the name of the object store to use, configured in the storage_config section.
+	Store  string `yaml:"store"`
+	Prefix string `yaml:"prefix"`
+}
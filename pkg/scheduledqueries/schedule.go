@@ -0,0 +1,186 @@
+package scheduledqueries
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxScheduleLookahead bounds the search of the next activation of a schedule,
+// so that schedules which can never fire (e.g. on February 30th) don't loop forever.
+const maxScheduleLookahead = 5 // years
+
+var scheduleDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+type scheduleField struct {
+	name     string
+	min, max int
+}
+
+var scheduleFields = []scheduleField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12},
+	// 7 is accepted as Sunday, like in most cron implementations.
+	{name: "day of week", min: 0, max: 7},
+}
+
+// Schedule is a parsed cron expression with the standard five fields:
+// minute, hour, day of month, month and day of week.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+
+	// When both the day of month and the day of week are restricted, a day
+	// matches if either of them does.
+	domStar, dowStar bool
+}
+
+// ParseSchedule parses a cron expression. Each field accepts `*`, single values,
+// ranges (`1-5`), steps (`*/15`, `0-30/10`, `5/15` running to the end of the
+// field's range) and comma-separated lists of those. Only numbers are accepted:
+// month and day names and the `?`, `L`, `W` and `#` extensions aren't supported.
+// The day of week is 0 to 7, both 0 and 7 being Sunday.
+//
+// Like in Vixie cron, when neither the day of month nor the day of week field is
+// exactly `*`, a day matches if either of them does: `0 0 13 * 5` fires on every
+// 13th and on every Friday. Otherwise only the other field applies.
+//
+// The descriptors @yearly, @annually, @monthly, @weekly, @daily, @midnight and
+// @hourly are accepted as well, but not @every and @reboot.
+func ParseSchedule(spec string) (*Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if d, ok := scheduleDescriptors[spec]; ok {
+		spec = d
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != len(scheduleFields) {
+		return nil, fmt.Errorf("invalid schedule %q: expected %d fields, got %d", spec, len(scheduleFields), len(fields))
+	}
+
+	bits := make([]uint64, len(fields))
+	for i, field := range fields {
+		b, err := parseScheduleField(field, scheduleFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %w", spec, err)
+		}
+		bits[i] = b
+	}
+
+	s := &Schedule{
+		minute:  bits[0],
+		hour:    bits[1],
+		dom:     bits[2],
+		month:   bits[3],
+		dow:     bits[4],
+		domStar: fields[2] == "*",
+		dowStar: fields[4] == "*",
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	return s, nil
+}
+
+func parseScheduleField(field string, f scheduleField) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(field, ",") {
+		rng, step := item, 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			var err error
+			rng = item[:i]
+			step, err = strconv.Atoi(item[i+1:])
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q in %s field", item[i+1:], f.name)
+			}
+		}
+
+		var from, to int
+		switch {
+		case rng == "*":
+			from, to = f.min, f.max
+		case strings.Contains(rng, "-"):
+			bounds := strings.SplitN(rng, "-", 2)
+			var err error
+			if from, err = parseScheduleValue(bounds[0], f); err != nil {
+				return 0, err
+			}
+			if to, err = parseScheduleValue(bounds[1], f); err != nil {
+				return 0, err
+			}
+			if from > to {
+				return 0, fmt.Errorf("invalid range %q in %s field", rng, f.name)
+			}
+		default:
+			v, err := parseScheduleValue(rng, f)
+			if err != nil {
+				return 0, err
+			}
+			from, to = v, v
+			// A single value with a step, like `5/15`, runs until the end of the range.
+			if step > 1 {
+				to = f.max
+			}
+		}
+
+		for v := from; v <= to; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func parseScheduleValue(s string, f scheduleField) (int, error) {
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q in %s field", s, f.name)
+	}
+	if v < f.min || v > f.max {
+		return 0, fmt.Errorf("value %d out of range [%d, %d] in %s field", v, f.min, f.max, f.name)
+	}
+	return v, nil
+}
+
+// Next returns the first activation of the schedule strictly after t, in the
+// location of t. It returns the zero time if the schedule doesn't fire in the
+// next few years.
+func (s *Schedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Year() + maxScheduleLookahead
+
+	for t.Year() <= limit {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, loc)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
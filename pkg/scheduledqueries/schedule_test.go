@@ -0,0 +1,113 @@
+package scheduledqueries
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSchedule_Invalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"@every 1h",
+		"@reboot",
+		// Names, and the extensions of some cron implementations, aren't supported.
+		"0 0 * JAN *",
+		"0 0 * * MON",
+		"0 0 ? * *",
+		"0 0 L * *",
+		"0 0 1W * *",
+		"0 0 * * 1#2",
+		"0 0 * * 5L",
+	} {
+		t.Run(spec, func(t *testing.T) {
+			_, err := ParseSchedule(spec)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestSchedule_Next(t *testing.T) {
+	utc := func(s string) time.Time {
+		ts, err := time.Parse(time.RFC3339, s)
+		require.NoError(t, err)
+		return ts
+	}
+
+	for _, tc := range []struct {
+		spec     string
+		from     string
+		expected string
+	}{
+		{"* * * * *", "2021-11-10T10:20:30Z", "2021-11-10T10:21:00Z"},
+		{"* * * * *", "2021-11-10T10:20:00Z", "2021-11-10T10:21:00Z"},
+		{"*/15 * * * *", "2021-11-10T10:20:00Z", "2021-11-10T10:30:00Z"},
+		{"5/15 * * * *", "2021-11-10T10:51:00Z", "2021-11-10T11:05:00Z"},
+		{"0,30 8-9 * * *", "2021-11-10T09:30:00Z", "2021-11-11T08:00:00Z"},
+		{"@hourly", "2021-11-10T10:20:00Z", "2021-11-10T11:00:00Z"},
+		{"@daily", "2021-11-10T10:20:00Z", "2021-11-11T00:00:00Z"},
+		// Wednesday to the next Sunday, with both 0 and 7 meaning Sunday.
+		{"@weekly", "2021-11-10T10:20:00Z", "2021-11-14T00:00:00Z"},
+		{"0 0 * * 7", "2021-11-10T10:20:00Z", "2021-11-14T00:00:00Z"},
+		{"@monthly", "2021-11-10T10:20:00Z", "2021-12-01T00:00:00Z"},
+		{"@yearly", "2021-11-10T10:20:00Z", "2022-01-01T00:00:00Z"},
+		{"0 0 31 * *", "2021-11-10T10:20:00Z", "2021-12-31T00:00:00Z"},
+		{"0 0 29 2 *", "2021-11-10T10:20:00Z", "2024-02-29T00:00:00Z"},
+		// Weekdays only.
+		{"0 8 * * 1-5", "2021-11-12T09:00:00Z", "2021-11-15T08:00:00Z"},
+		// Either the 1st of the month or a Monday when both days are restricted.
+		{"0 0 1 * 1", "2021-11-10T10:20:00Z", "2021-11-15T00:00:00Z"},
+		{"0 0 1 * 1", "2021-11-29T10:20:00Z", "2021-12-01T00:00:00Z"},
+		// Either the 13th or a Friday, not only Fridays the 13th.
+		{"0 0 13 * 5", "2021-11-10T10:20:00Z", "2021-11-12T00:00:00Z"},
+		{"0 0 13 * 5", "2021-11-12T10:20:00Z", "2021-11-13T00:00:00Z"},
+		{"0 0 13 * 5", "2021-11-13T10:20:00Z", "2021-11-19T00:00:00Z"},
+		// Only the restricted day field applies when the other one is `*`.
+		{"0 0 13 * *", "2021-11-10T10:20:00Z", "2021-11-13T00:00:00Z"},
+		{"0 0 * * 5", "2021-11-12T10:20:00Z", "2021-11-19T00:00:00Z"},
+		// A day of month range is not "the first Monday": any of the 7 days or any Monday.
+		{"0 0 1-7 * 1", "2021-11-10T10:20:00Z", "2021-11-15T00:00:00Z"},
+		{"0 0 1-7 * 1", "2021-11-29T10:20:00Z", "2021-12-01T00:00:00Z"},
+		// A field with a step is restricted, even when it starts with `*`.
+		{"0 0 */10 * 1", "2021-11-10T10:20:00Z", "2021-11-11T00:00:00Z"},
+		{"0 0 */10 * 1", "2021-11-11T10:20:00Z", "2021-11-15T00:00:00Z"},
+		{"0 0 * * */7", "2021-11-10T10:20:00Z", "2021-11-14T00:00:00Z"},
+		// Never fires.
+		{"0 0 30 2 *", "2021-11-10T10:20:00Z", "0001-01-01T00:00:00Z"},
+	} {
+		t.Run(tc.spec+"/"+tc.from, func(t *testing.T) {
+			s, err := ParseSchedule(tc.spec)
+			require.NoError(t, err)
+			require.Equal(t, utc(tc.expected).UTC(), s.Next(utc(tc.from)).UTC())
+		})
+	}
+}
+
+func TestSchedule_NextInLocation(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Paris")
+	require.NoError(t, err)
+
+	s, err := ParseSchedule("0 8 * * *")
+	require.NoError(t, err)
+
+	next := s.Next(time.Date(2021, 11, 10, 9, 0, 0, 0, loc))
+	require.Equal(t, time.Date(2021, 11, 11, 8, 0, 0, 0, loc), next)
+	require.Equal(t, time.Date(2021, 11, 11, 7, 0, 0, 0, time.UTC), next.UTC())
+
+	// 2:30 doesn't exist when switching to summer time.
+	s, err = ParseSchedule("30 2 * * *")
+	require.NoError(t, err)
+	next = s.Next(time.Date(2021, 3, 27, 3, 0, 0, 0, loc))
+	require.Equal(t, time.Date(2021, 3, 29, 2, 30, 0, 0, loc), next)
+}
@@ -0,0 +1,254 @@
+package scheduledqueries
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/services"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/loki/pkg/logql"
+	"github.com/grafana/loki/pkg/storage/chunk"
+)
+
+// defaultLogsLimit is the maximum number of log lines returned by log queries without a limit.
+const defaultLogsLimit = 100
+
+// QueryEngine runs LogQL queries.
+type QueryEngine interface {
+	Query(logql.Params) logql.Query
+}
+
+// ObjectClientFactory returns the object client of the store with the given name.
+type ObjectClientFactory func(store string) (chunk.ObjectClient, error)
+
+type metrics struct {
+	runs        *prometheus.CounterVec
+	deliveries  *prometheus.CounterVec
+	duration    *prometheus.HistogramVec
+	lastSuccess *prometheus.GaugeVec
+}
+
+func newMetrics(r prometheus.Registerer) *metrics {
+	return &metrics{
+		runs: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "loki",
+			Name:      "scheduled_query_runs_total",
+			Help:      "Total number of runs of scheduled queries, by status.",
+		}, []string{"name", "status"}),
+		deliveries: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "loki",
+			Name:      "scheduled_query_deliveries_total",
+			Help:      "Total number of deliveries of scheduled query results to sinks, by status.",
+		}, []string{"name", "sink", "status"}),
+		duration: promauto.With(r).NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "loki",
+			Name:      "scheduled_query_duration_seconds",
+			Help:      "Time spent running scheduled queries.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"name"}),
+		lastSuccess: promauto.With(r).NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "loki",
+			Name:      "scheduled_query_last_success_timestamp_seconds",
+			Help:      "Unix timestamp of the last successful run of scheduled queries.",
+		}, []string{"name"}),
+	}
+}
+
+type job struct {
+	cfg      QueryConfig
+	schedule *Schedule
+	location *time.Location
+	sinks    []Sink
+}
+
+// Scheduler runs the configured queries on their schedule and delivers the results to their sinks.
+type Scheduler struct {
+	services.Service
+
+	cfg     Config
+	engine  QueryEngine
+	jobs    []*job
+	metrics *metrics
+	logger  log.Logger
+}
+
+// NewScheduler creates a new scheduler for the configured queries.
+func NewScheduler(cfg Config, engine QueryEngine, objectClients ObjectClientFactory, r prometheus.Registerer, logger log.Logger) (*Scheduler, error) {
+	s := &Scheduler{
+		cfg:     cfg,
+		engine:  engine,
+		metrics: newMetrics(r),
+		logger:  logger,
+	}
+
+	httpClient := &http.Client{Timeout: cfg.DeliveryTimeout}
+	stores := map[string]chunk.ObjectClient{}
+	for _, q := range cfg.Queries {
+		schedule, err := ParseSchedule(q.Schedule)
+		if err != nil {
+			return nil, err
+		}
+		location, err := time.LoadLocation(q.Timezone)
+		if err != nil {
+			return nil, err
+		}
+		j := &job{cfg: q, schedule: schedule, location: location}
+
+		if q.Webhook != nil {
+			sink, err := newWebhookSink(*q.Webhook, httpClient)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create webhook sink of scheduled query %q: %w", q.Name, err)
+			}
+			j.sinks = append(j.sinks, sink)
+		}
+		if q.ObjectStorage != nil {
+			client, ok := stores[q.ObjectStorage.Store]
+			if !ok {
+				client, err = objectClients(q.ObjectStorage.Store)
+				if err != nil {
+					return nil, fmt.Errorf("failed to create object client of scheduled query %q: %w", q.Name, err)
+				}
+				stores[q.ObjectStorage.Store] = client
+			}
+			j.sinks = append(j.sinks, &objectStorageSink{cfg: *q.ObjectStorage, client: client})
+		}
+		s.jobs = append(s.jobs, j)
+	}
+
+	s.Service = services.NewBasicService(nil, s.running, func(_ error) error {
+		for _, client := range stores {
+			client.Stop()
+		}
+		return nil
+	})
+	return s, nil
+}
+
+func (s *Scheduler) running(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for _, j := range s.jobs {
+		wg.Add(1)
+		go func(j *job) {
+			defer wg.Done()
+			s.loop(ctx, j)
+		}(j)
+	}
+	wg.Wait()
+	return nil
+}
+
+func (s *Scheduler) loop(ctx context.Context, j *job) {
+	for {
+		next := j.schedule.Next(time.Now().In(j.location))
+		if next.IsZero() {
+			level.Warn(s.logger).Log("msg", "scheduled query will never run again", "name", j.cfg.Name, "schedule", j.cfg.Schedule)
+			return
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if err := s.run(ctx, j, next); err != nil {
+			level.Error(s.logger).Log("msg", "scheduled query failed", "name", j.cfg.Name, "tenant", j.cfg.Tenant, "err", err)
+		}
+	}
+}
+
+// run executes the query of the job for the activation at the given time and
+// delivers the report to all the sinks of the job.
+func (s *Scheduler) run(ctx context.Context, j *job, at time.Time) error {
+	start := time.Now()
+	report, err := s.query(ctx, j, at)
+	s.metrics.duration.WithLabelValues(j.cfg.Name).Observe(time.Since(start).Seconds())
+	if err != nil {
+		s.metrics.runs.WithLabelValues(j.cfg.Name, "failure").Inc()
+		return err
+	}
+
+	var failed int
+	for _, sink := range j.sinks {
+		if err := s.deliver(ctx, sink, report); err != nil {
+			failed++
+			s.metrics.deliveries.WithLabelValues(j.cfg.Name, sink.Name(), "failure").Inc()
+			level.Error(s.logger).Log("msg", "failed to deliver scheduled query result", "name", j.cfg.Name, "sink", sink.Name(), "err", err)
+			continue
+		}
+		s.metrics.deliveries.WithLabelValues(j.cfg.Name, sink.Name(), "success").Inc()
+	}
+	if failed > 0 {
+		s.metrics.runs.WithLabelValues(j.cfg.Name, "failure").Inc()
+		return fmt.Errorf("failed to deliver the result to %d out of %d sinks", failed, len(j.sinks))
+	}
+
+	s.metrics.runs.WithLabelValues(j.cfg.Name, "success").Inc()
+	s.metrics.lastSuccess.WithLabelValues(j.cfg.Name).SetToCurrentTime()
+	level.Info(s.logger).Log("msg", "scheduled query delivered", "name", j.cfg.Name, "tenant", j.cfg.Tenant, "duration", time.Since(start))
+	return nil
+}
+
+func (s *Scheduler) query(ctx context.Context, j *job, at time.Time) (Report, error) {
+	ctx = user.InjectOrgID(ctx, j.cfg.Tenant)
+	if s.cfg.QueryTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.cfg.QueryTimeout)
+		defer cancel()
+	}
+
+	params := queryParams(j.cfg, at)
+	res, err := s.engine.Query(params).Exec(ctx)
+	if err != nil {
+		return Report{}, err
+	}
+	return Report{
+		Name:   j.cfg.Name,
+		Tenant: j.cfg.Tenant,
+		Query:  j.cfg.Query,
+		Start:  params.Start(),
+		End:    params.End(),
+		Result: res,
+	}, nil
+}
+
+func (s *Scheduler) deliver(ctx context.Context, sink Sink, r Report) error {
+	if s.cfg.DeliveryTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.cfg.DeliveryTimeout)
+		defer cancel()
+	}
+	return sink.Deliver(ctx, r)
+}
+
+// queryParams returns the parameters of the query for the activation at the given time.
+// Without a range, the query is evaluated as an instant query at that time.
+func queryParams(cfg QueryConfig, at time.Time) logql.LiteralParams {
+	limit := cfg.Limit
+	if limit == 0 {
+		limit = defaultLogsLimit
+	}
+	if cfg.Range == 0 {
+		return logql.NewLiteralParams(cfg.Query, at, at, 0, 0, logproto.BACKWARD, limit, nil)
+	}
+
+	start := at.Add(-cfg.Range)
+	step := cfg.Step
+	if step == 0 {
+		// Same default as the query range API.
+		step = time.Duration(math.Max(math.Floor(cfg.Range.Seconds()/250), 1)) * time.Second
+	}
+	return logql.NewLiteralParams(cfg.Query, start, at, step, 0, logproto.BACKWARD, limit, nil)
+}
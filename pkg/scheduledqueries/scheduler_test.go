@@ -0,0 +1,221 @@
+package scheduledqueries
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/loki/pkg/logql"
+	"github.com/grafana/loki/pkg/logqlmodel"
+	"github.com/grafana/loki/pkg/storage/chunk"
+)
+
+type fakeEngine struct {
+	params []logql.Params
+	tenant string
+	err    error
+}
+
+func (e *fakeEngine) Query(p logql.Params) logql.Query {
+	e.params = append(e.params, p)
+	return fakeQuery{e}
+}
+
+type fakeQuery struct {
+	e *fakeEngine
+}
+
+func (q fakeQuery) Exec(ctx context.Context) (logqlmodel.Result, error) {
+	tenant, err := user.ExtractOrgID(ctx)
+	if err != nil {
+		return logqlmodel.Result{}, err
+	}
+	q.e.tenant = tenant
+	if q.e.err != nil {
+		return logqlmodel.Result{}, q.e.err
+	}
+	return logqlmodel.Result{
+		Data: promql.Vector{
+			{Metric: labels.Labels{{Name: "service", Value: "api"}}, Point: promql.Point{T: 1000, V: 42}},
+		},
+	}, nil
+}
+
+func newTestScheduler(t *testing.T, cfg Config, engine QueryEngine, store chunk.ObjectClient) *Scheduler {
+	t.Helper()
+	require.NoError(t, cfg.Validate())
+	s, err := NewScheduler(cfg, engine, func(name string) (chunk.ObjectClient, error) {
+		require.Equal(t, "inmemory", name)
+		return store, nil
+	}, prometheus.NewRegistry(), log.NewNopLogger())
+	require.NoError(t, err)
+	return s
+}
+
+func TestScheduler_Run(t *testing.T) {
+	var (
+		body        string
+		contentType string
+		auth        string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		body, contentType, auth = string(buf), r.Header.Get("Content-Type"), r.Header.Get("Authorization")
+	}))
+	defer server.Close()
+
+	engine := &fakeEngine{}
+	store := chunk.NewMockStorage()
+	s := newTestScheduler(t, Config{
+		Queries: []QueryConfig{
+			{
+				Name:     "errors",
+				Tenant:   "team-a",
+				Query:    `sum by (service) (count_over_time({env="prod"} |= "error" [1d]))`,
+				Schedule: "@daily",
+				Webhook: &WebhookConfig{
+					URL:      server.URL,
+					Headers:  map[string]string{"Authorization": "Bearer secret"},
+					Template: `{{ .Name }}:{{ range .Result.Data }} {{ .Metric }}={{ .V }}{{ end }}`,
+				},
+				ObjectStorage: &ObjectStorageConfig{Store: "inmemory", Prefix: "reports/"},
+			},
+		},
+	}, engine, store)
+
+	at := time.Date(2021, 11, 10, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, s.run(context.Background(), s.jobs[0], at))
+
+	require.Equal(t, "team-a", engine.tenant)
+	require.Len(t, engine.params, 1)
+	require.Equal(t, at, engine.params[0].Start())
+	require.Equal(t, at, engine.params[0].End())
+	require.Equal(t, time.Duration(0), engine.params[0].Step())
+
+	require.Equal(t, `errors: {service="api"}=42`, body)
+	require.Equal(t, "text/plain; charset=utf-8", contentType)
+	require.Equal(t, "Bearer secret", auth)
+
+	rc, _, err := store.GetObject(context.Background(), "reports/errors/2021-11-10T00-00-00Z.json")
+	require.NoError(t, err)
+	defer rc.Close()
+	buf, err := ioutil.ReadAll(rc)
+	require.NoError(t, err)
+	var report struct {
+		Name  string    `json:"name"`
+		Start time.Time `json:"start"`
+		End   time.Time `json:"end"`
+		Data  struct {
+			ResultType string          `json:"resultType"`
+			Result     json.RawMessage `json:"result"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(buf, &report))
+	require.Equal(t, "errors", report.Name)
+	require.Equal(t, at, report.Start)
+	require.Equal(t, at, report.End)
+	require.Equal(t, "vector", report.Data.ResultType)
+	require.JSONEq(t, `[{"metric": {"service": "api"}, "value": [1, "42"]}]`, string(report.Data.Result))
+
+	require.Equal(t, 1.0, testutil.ToFloat64(s.metrics.runs.WithLabelValues("errors", "success")))
+	require.Equal(t, 1.0, testutil.ToFloat64(s.metrics.deliveries.WithLabelValues("errors", "webhook", "success")))
+	require.Equal(t, 1.0, testutil.ToFloat64(s.metrics.deliveries.WithLabelValues("errors", "object_storage", "success")))
+}
+
+func TestScheduler_RunFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	engine := &fakeEngine{}
+	store := chunk.NewMockStorage()
+	s := newTestScheduler(t, Config{
+		Queries: []QueryConfig{
+			{
+				Name:          "logs",
+				Tenant:        "team-a",
+				Query:         `{env="prod"} |= "error"`,
+				Schedule:      "@hourly",
+				Range:         time.Hour,
+				Webhook:       &WebhookConfig{URL: server.URL},
+				ObjectStorage: &ObjectStorageConfig{Store: "inmemory"},
+			},
+		},
+	}, engine, store)
+
+	at := time.Date(2021, 11, 10, 10, 0, 0, 0, time.UTC)
+	err := s.run(context.Background(), s.jobs[0], at)
+	require.EqualError(t, err, "failed to deliver the result to 1 out of 2 sinks")
+
+	// The result is still delivered to the sinks that work.
+	require.Equal(t, 1, store.GetObjectCount())
+	require.Equal(t, at.Add(-time.Hour), engine.params[0].Start())
+	require.Equal(t, 14*time.Second, engine.params[0].Step())
+	require.Equal(t, uint32(defaultLogsLimit), engine.params[0].Limit())
+
+	engine.err = errors.New("query failed")
+	require.EqualError(t, s.run(context.Background(), s.jobs[0], at), "query failed")
+
+	require.Equal(t, 2.0, testutil.ToFloat64(s.metrics.runs.WithLabelValues("logs", "failure")))
+	require.Equal(t, 1.0, testutil.ToFloat64(s.metrics.deliveries.WithLabelValues("logs", "webhook", "failure")))
+}
+
+func TestConfig_Validate(t *testing.T) {
+	valid := func() QueryConfig {
+		return QueryConfig{
+			Name:     "errors",
+			Tenant:   "team-a",
+			Query:    `count_over_time({env="prod"}[1h])`,
+			Schedule: "0 * * * *",
+			Webhook:  &WebhookConfig{URL: "http://localhost/hook"},
+		}
+	}
+
+	for _, tc := range []struct {
+		name   string
+		modify func(cfg *QueryConfig)
+		err    string
+	}{
+		{"valid", func(cfg *QueryConfig) {}, ""},
+		{"missing name", func(cfg *QueryConfig) { cfg.Name = "" }, `invalid scheduled query "": name is required`},
+		{"missing tenant", func(cfg *QueryConfig) { cfg.Tenant = "" }, `invalid scheduled query "errors": tenant is required`},
+		{"invalid query", func(cfg *QueryConfig) { cfg.Query = "{" }, `invalid scheduled query "errors": invalid query: parse error at line 1, col 2: syntax error: unexpected $end, expecting IDENTIFIER`},
+		{"log query without range", func(cfg *QueryConfig) { cfg.Query = `{env="prod"}` }, `invalid scheduled query "errors": log queries require a range`},
+		{"invalid schedule", func(cfg *QueryConfig) { cfg.Schedule = "@never" }, `invalid scheduled query "errors": invalid schedule "@never": expected 5 fields, got 1`},
+		{"invalid timezone", func(cfg *QueryConfig) { cfg.Timezone = "Mars/Olympus" }, `invalid scheduled query "errors": invalid timezone: unknown time zone Mars/Olympus`},
+		{"no sink", func(cfg *QueryConfig) { cfg.Webhook = nil }, `invalid scheduled query "errors": at least one sink (webhook or object_storage) is required`},
+		{"invalid webhook url", func(cfg *QueryConfig) { cfg.Webhook.URL = "localhost" }, `invalid scheduled query "errors": invalid webhook: invalid url "localhost": scheme must be http or https`},
+		{"invalid template", func(cfg *QueryConfig) { cfg.Webhook.Template = "{{ .Name" }, `invalid scheduled query "errors": invalid webhook: invalid template: template: webhook:1: unclosed action`},
+		{"missing store", func(cfg *QueryConfig) { cfg.ObjectStorage = &ObjectStorageConfig{} }, `invalid scheduled query "errors": invalid object_storage: store is required`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			q := valid()
+			tc.modify(&q)
+			cfg := Config{Queries: []QueryConfig{q}}
+			err := cfg.Validate()
+			if tc.err == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tc.err)
+		})
+	}
+
+	cfg := Config{Queries: []QueryConfig{valid(), valid()}}
+	require.EqualError(t, cfg.Validate(), `duplicate scheduled query name "errors"`)
+}
@@ -0,0 +1,119 @@
+package scheduledqueries
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"text/template"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/grafana/loki/pkg/loghttp"
+	"github.com/grafana/loki/pkg/logqlmodel"
+	"github.com/grafana/loki/pkg/notifications"
+	"github.com/grafana/loki/pkg/storage/chunk"
+	"github.com/grafana/loki/pkg/util/marshal"
+)
+
+// Report is the result of a run of a scheduled query.
+type Report struct {
+	Name   string
+	Tenant string
+	Query  string
+	Start  time.Time
+	End    time.Time
+	Result logqlmodel.Result
+}
+
+type reportJSON struct {
+	Name     string                    `json:"name"`
+	Tenant   string                    `json:"tenant"`
+	Query    string                    `json:"query"`
+	Start    time.Time                 `json:"start"`
+	End      time.Time                 `json:"end"`
+	Data     loghttp.QueryResponseData `json:"data"`
+	Warnings []string                  `json:"warnings,omitempty"`
+}
+
+// MarshalJSON encodes the report, the result having the same format as the query API.
+func (r Report) MarshalJSON() ([]byte, error) {
+	value, err := marshal.NewResultValue(r.Result.Data)
+	if err != nil {
+		return nil, err
+	}
+	return jsoniter.Marshal(reportJSON{
+		Name:   r.Name,
+		Tenant: r.Tenant,
+		Query:  r.Query,
+		Start:  r.Start,
+		End:    r.End,
+		Data: loghttp.QueryResponseData{
+			ResultType: value.Type(),
+			Result:     value,
+			Statistics: r.Result.Statistics,
+		},
+		Warnings: r.Result.Warnings,
+	})
+}
+
+// Sink delivers the reports of a scheduled query.
+type Sink interface {
+	Name() string
+	Deliver(ctx context.Context, r Report) error
+}
+
+type webhookSink struct {
+	webhook notifications.Webhook
+	tmpl    *template.Template
+}
+
+func newWebhookSink(cfg WebhookConfig, client *http.Client) (*webhookSink, error) {
+	s := &webhookSink{webhook: notifications.Webhook{URL: cfg.URL, Headers: cfg.Headers, Client: client}}
+	if cfg.Template != "" {
+		tmpl, err := template.New("webhook").Parse(cfg.Template)
+		if err != nil {
+			return nil, err
+		}
+		s.tmpl = tmpl
+	}
+	return s, nil
+}
+
+func (s *webhookSink) Name() string { return "webhook" }
+
+func (s *webhookSink) Deliver(ctx context.Context, r Report) error {
+	var body bytes.Buffer
+	if s.tmpl != nil {
+		if err := s.tmpl.Execute(&body, r); err != nil {
+			return fmt.Errorf("failed to render webhook template: %w", err)
+		}
+		return s.webhook.Post(ctx, "text/plain; charset=utf-8", &body)
+	}
+	if err := jsoniter.NewEncoder(&body).Encode(r); err != nil {
+		return err
+	}
+	return s.webhook.Post(ctx, "application/json", &body)
+}
+
+// objectStorageSink writes every report as a JSON file named after the end of its time range.
+type objectStorageSink struct {
+	cfg    ObjectStorageConfig
+	client chunk.ObjectClient
+}
+
+func (s *objectStorageSink) Name() string { return "object_storage" }
+
+func (s *objectStorageSink) Deliver(ctx context.Context, r Report) error {
+	buf, err := jsoniter.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return s.client.PutObject(ctx, s.objectKey(r), bytes.NewReader(buf))
+}
+
+func (s *objectStorageSink) objectKey(r Report) string {
+	return s.cfg.Prefix + path.Join(r.Name, r.End.UTC().Format("2006-01-02T15-04-05Z")+".json")
+}
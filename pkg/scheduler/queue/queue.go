@@ -75,11 +75,11 @@ func NewRequestQueue(maxOutstandingPerTenant int, forgetDelay time.Duration, que
 }
 
 // EnqueueRequest puts the request into the queue. MaxQueries is user-specific value that specifies how many queriers can
-// this user use (zero or negative = all queriers). It is passed to each EnqueueRequest, because it can change
-// between calls.
+// this user use (zero or negative = all queriers). Pool is the pool of queriers dedicated to the user, empty for the
+// shared pool. Both are passed to each EnqueueRequest, because they can change between calls.
 //
 // If request is successfully enqueued, successFn is called with the lock held, before any querier can receive the request.
-func (q *RequestQueue) EnqueueRequest(userID string, req Request, maxQueriers int, successFn func()) error {
+func (q *RequestQueue) EnqueueRequest(userID string, req Request, maxQueriers int, pool string, successFn func()) error {
 	q.mtx.Lock()
 	defer q.mtx.Unlock()
 
@@ -87,7 +87,7 @@ func (q *RequestQueue) EnqueueRequest(userID string, req Request, maxQueriers in
 		return ErrStopped
 	}
 
-	queue := q.queues.getOrAddQueue(userID, maxQueriers)
+	queue := q.queues.getOrAddQueue(userID, maxQueriers, pool)
 	if queue == nil {
 		// This can only happen if userID is "".
 		return errors.New("no queue found")
@@ -191,12 +191,14 @@ func (q *RequestQueue) stopping(_ error) error {
 	return nil
 }
 
-func (q *RequestQueue) RegisterQuerierConnection(querier string) {
+// RegisterQuerierConnection registers a connection of the querier, which is
+// part of the given pool, empty for the shared pool.
+func (q *RequestQueue) RegisterQuerierConnection(querier, pool string) {
 	q.connectedQuerierWorkers.Inc()
 
 	q.mtx.Lock()
 	defer q.mtx.Unlock()
-	q.queues.addQuerierConnection(querier)
+	q.queues.addQuerierConnection(querier, pool)
 }
 
 func (q *RequestQueue) UnregisterQuerierConnection(querier string) {
@@ -205,6 +207,8 @@ func (q *RequestQueue) UnregisterQuerierConnection(querier string) {
 	q.mtx.Lock()
 	defer q.mtx.Unlock()
 	q.queues.removeQuerierConnection(querier, time.Now())
+	// Requests of a pool left without queriers go to the shared pool.
+	q.cond.Broadcast()
 }
 
 func (q *RequestQueue) NotifyQuerierShutdown(querierID string) {
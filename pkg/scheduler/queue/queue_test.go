@@ -3,6 +3,7 @@ package queue
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strconv"
 	"sync"
 	"testing"
@@ -29,14 +30,14 @@ func BenchmarkGetNextRequest(b *testing.B) {
 		queues = append(queues, queue)
 
 		for ix := 0; ix < queriers; ix++ {
-			queue.RegisterQuerierConnection(fmt.Sprintf("querier-%d", ix))
+			queue.RegisterQuerierConnection(fmt.Sprintf("querier-%d", ix), "")
 		}
 
 		for i := 0; i < maxOutstandingPerTenant; i++ {
 			for j := 0; j < numTenants; j++ {
 				userID := strconv.Itoa(j)
 
-				err := queue.EnqueueRequest(userID, "request", 0, nil)
+				err := queue.EnqueueRequest(userID, "request", 0, "", nil)
 				if err != nil {
 					b.Fatal(err)
 				}
@@ -85,7 +86,7 @@ func BenchmarkQueueRequest(b *testing.B) {
 		)
 
 		for ix := 0; ix < queriers; ix++ {
-			q.RegisterQuerierConnection(fmt.Sprintf("querier-%d", ix))
+			q.RegisterQuerierConnection(fmt.Sprintf("querier-%d", ix), "")
 		}
 
 		queues = append(queues, q)
@@ -100,7 +101,7 @@ func BenchmarkQueueRequest(b *testing.B) {
 	for n := 0; n < b.N; n++ {
 		for i := 0; i < maxOutstandingPerTenant; i++ {
 			for j := 0; j < numTenants; j++ {
-				err := queues[n].EnqueueRequest(users[j], requests[j], 0, nil)
+				err := queues[n].EnqueueRequest(users[j], requests[j], 0, "", nil)
 				if err != nil {
 					b.Fatal(err)
 				}
@@ -124,8 +125,8 @@ func TestRequestQueue_GetNextRequestForQuerier_ShouldGetRequestAfterReshardingBe
 	})
 
 	// Two queriers connect.
-	queue.RegisterQuerierConnection("querier-1")
-	queue.RegisterQuerierConnection("querier-2")
+	queue.RegisterQuerierConnection("querier-1", "")
+	queue.RegisterQuerierConnection("querier-2", "")
 
 	// Querier-2 waits for a new request.
 	querier2wg := sync.WaitGroup{}
@@ -141,7 +142,7 @@ func TestRequestQueue_GetNextRequestForQuerier_ShouldGetRequestAfterReshardingBe
 
 	// Enqueue a request from an user which would be assigned to querier-1.
 	// NOTE: "user-1" hash falls in the querier-1 shard.
-	require.NoError(t, queue.EnqueueRequest("user-1", "request", 1, nil))
+	require.NoError(t, queue.EnqueueRequest("user-1", "request", 1, "", nil))
 
 	startTime := time.Now()
 	querier2wg.Wait()
@@ -151,6 +152,60 @@ func TestRequestQueue_GetNextRequestForQuerier_ShouldGetRequestAfterReshardingBe
 	assert.GreaterOrEqual(t, waitTime.Milliseconds(), forgetDelay.Milliseconds())
 }
 
+func TestQueuesWithQuerierPools(t *testing.T) {
+	q := newUserQueues(10, 0)
+
+	q.addQuerierConnection("shared-1", "")
+	q.addQuerierConnection("shared-2", "")
+	q.addQuerierConnection("critical-1", "critical")
+
+	q.getOrAddQueue("noisy", 0, "")
+	q.getOrAddQueue("critical", 0, "critical")
+	// No querier of the pool is connected, the shared pool handles the tenant.
+	q.getOrAddQueue("orphan", 0, "missing")
+
+	usersOf := func(querierID string) []string {
+		var users []string
+		last := -1
+		for i := 0; i < len(q.users); i++ {
+			queue, user, idx := q.getNextQueueForQuerier(last, querierID)
+			if queue == nil {
+				break
+			}
+			last = idx
+			users = append(users, user)
+		}
+		sort.Strings(users)
+		return unique(users)
+	}
+	require.Equal(t, []string{"noisy", "orphan"}, usersOf("shared-1"))
+	require.Equal(t, []string{"noisy", "orphan"}, usersOf("shared-2"))
+	require.Equal(t, []string{"critical"}, usersOf("critical-1"))
+
+	// Shuffle sharding selects queriers within the pool.
+	q.addQuerierConnection("critical-2", "critical")
+	q.getOrAddQueue("critical", 1, "critical")
+	require.Len(t, q.userQueues["critical"].queriers, 1)
+	for id := range q.userQueues["critical"].queriers {
+		require.Contains(t, []string{"critical-1", "critical-2"}, id)
+	}
+
+	// The tenant goes back to the shared pool once the queriers of its pool are gone.
+	q.removeQuerierConnection("critical-1", time.Now())
+	q.removeQuerierConnection("critical-2", time.Now())
+	require.Equal(t, []string{"critical", "noisy", "orphan"}, usersOf("shared-1"))
+}
+
+func unique(s []string) []string {
+	var result []string
+	for i, v := range s {
+		if i == 0 || v != s[i-1] {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
 func TestContextCond(t *testing.T) {
 	t.Run("wait until broadcast", func(t *testing.T) {
 		t.Parallel()
@@ -18,6 +18,9 @@ type querier struct {
 
 	// When the last connection has been unregistered.
 	disconnectedAt time.Time
+
+	// Pool of the querier, empty for the shared pool.
+	pool string
 }
 
 // This struct holds user queues for pending requests. It also keeps track of connected queriers,
@@ -41,6 +44,9 @@ type queues struct {
 
 	// Sorted list of querier names, used when creating per-user shard.
 	sortedQueriers []string
+
+	// Number of registered queriers per dedicated pool.
+	pools map[string]int
 }
 
 type userQueue struct {
@@ -51,6 +57,9 @@ type userQueue struct {
 	queriers    map[string]struct{}
 	maxQueriers int
 
+	// Pool of queriers dedicated to the user, empty for the shared pool.
+	pool string
+
 	// Seed for shuffle sharding of queriers. This seed is based on userID only and is therefore consistent
 	// between different frontends.
 	seed int64
@@ -67,6 +76,7 @@ func newUserQueues(maxUserQueueSize int, forgetDelay time.Duration) *queues {
 		forgetDelay:      forgetDelay,
 		queriers:         map[string]*querier{},
 		sortedQueriers:   nil,
+		pools:            map[string]int{},
 	}
 }
 
@@ -93,7 +103,9 @@ func (q *queues) deleteQueue(userID string) {
 // MaxQueriers is used to compute which queriers should handle requests for this user.
 // If maxQueriers is <= 0, all queriers can handle this user's requests.
 // If maxQueriers has changed since the last call, queriers for this are recomputed.
-func (q *queues) getOrAddQueue(userID string, maxQueriers int) chan Request {
+// Pool is the pool of queriers dedicated to the user, requests go to the shared pool
+// if it's empty or no querier of the pool is connected.
+func (q *queues) getOrAddQueue(userID string, maxQueriers int, pool string) chan Request {
 	// Empty user is not allowed, as that would break our users list ("" is used for free spot).
 	if userID == "" {
 		return nil
@@ -129,9 +141,10 @@ func (q *queues) getOrAddQueue(userID string, maxQueriers int) chan Request {
 		}
 	}
 
-	if uq.maxQueriers != maxQueriers {
+	if uq.maxQueriers != maxQueriers || uq.pool != pool {
 		uq.maxQueriers = maxQueriers
-		uq.queriers = shuffleQueriersForUser(uq.seed, maxQueriers, q.sortedQueriers, nil)
+		uq.pool = pool
+		uq.queriers = shuffleQueriersForUser(uq.seed, maxQueriers, q.poolQueriers(pool), nil)
 	}
 
 	return uq.ch
@@ -157,21 +170,24 @@ func (q *queues) getNextQueueForQuerier(lastUserIndex int, querierID string) (ch
 			continue
 		}
 
-		q := q.userQueues[u]
+		uq := q.userQueues[u]
 
-		if q.queriers != nil {
-			if _, ok := q.queriers[querierID]; !ok {
+		if uq.queriers != nil {
+			if _, ok := uq.queriers[querierID]; !ok {
 				// This querier is not handling the user.
 				continue
 			}
+		} else if !q.servesPool(querierID, uq.pool) {
+			// This querier is not in the pool of the user.
+			continue
 		}
 
-		return q.ch, u, uid
+		return uq.ch, u, uid
 	}
 	return nil, "", uid
 }
 
-func (q *queues) addQuerierConnection(querierID string) {
+func (q *queues) addQuerierConnection(querierID, pool string) {
 	info := q.queriers[querierID]
 	if info != nil {
 		info.connections++
@@ -180,11 +196,18 @@ func (q *queues) addQuerierConnection(querierID string) {
 		info.shuttingDown = false
 		info.disconnectedAt = time.Time{}
 
+		if info.pool != pool {
+			q.removeFromPool(info.pool)
+			q.addToPool(pool)
+			info.pool = pool
+			q.recomputeUserQueriers()
+		}
 		return
 	}
 
 	// First connection from this querier.
-	q.queriers[querierID] = &querier{connections: 1}
+	q.queriers[querierID] = &querier{connections: 1, pool: pool}
+	q.addToPool(pool)
 	q.sortedQueriers = append(q.sortedQueriers, querierID)
 	sort.Strings(q.sortedQueriers)
 
@@ -217,6 +240,9 @@ func (q *queues) removeQuerierConnection(querierID string, now time.Time) {
 }
 
 func (q *queues) removeQuerier(querierID string) {
+	if info := q.queriers[querierID]; info != nil {
+		q.removeFromPool(info.pool)
+	}
 	delete(q.queriers, querierID)
 
 	ix := sort.SearchStrings(q.sortedQueriers, querierID)
@@ -274,10 +300,45 @@ func (q *queues) recomputeUserQueriers() {
 	scratchpad := make([]string, 0, len(q.sortedQueriers))
 
 	for _, uq := range q.userQueues {
-		uq.queriers = shuffleQueriersForUser(uq.seed, uq.maxQueriers, q.sortedQueriers, scratchpad)
+		uq.queriers = shuffleQueriersForUser(uq.seed, uq.maxQueriers, q.poolQueriers(uq.pool), scratchpad)
 	}
 }
 
+// servingPool returns the pool serving the users of the given pool: the pool
+// itself if any of its queriers is registered, the shared pool otherwise.
+func (q *queues) servingPool(pool string) string {
+	if q.pools[pool] > 0 {
+		return pool
+	}
+	return ""
+}
+
+// servesPool tells if the querier serves the users of the given pool.
+func (q *queues) servesPool(querierID, pool string) bool {
+	info := q.queriers[querierID]
+	if info == nil {
+		// Unknown queriers are in the shared pool.
+		return q.servingPool(pool) == ""
+	}
+	return info.pool == q.servingPool(pool)
+}
+
+// poolQueriers returns the sorted queriers serving the users of the given pool.
+func (q *queues) poolQueriers(pool string) []string {
+	if len(q.pools) == 0 {
+		return q.sortedQueriers
+	}
+
+	serving := q.servingPool(pool)
+	result := make([]string, 0, len(q.sortedQueriers))
+	for _, id := range q.sortedQueriers {
+		if q.queriers[id].pool == serving {
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
 // shuffleQueriersForUser returns nil if queriersToSelect is 0 or there are not enough queriers to select from.
 // In that case *all* queriers should be used.
 // Scratchpad is used for shuffling, to avoid new allocations. If nil, new slice is allocated.
@@ -303,3 +364,18 @@ func shuffleQueriersForUser(userSeed int64, queriersToSelect int, allSortedQueri
 
 	return result
 }
+
+func (q *queues) addToPool(pool string) {
+	if pool != "" {
+		q.pools[pool]++
+	}
+}
+
+func (q *queues) removeFromPool(pool string) {
+	if pool == "" {
+		return
+	}
+	if q.pools[pool]--; q.pools[pool] <= 0 {
+		delete(q.pools, pool)
+	}
+}
@@ -243,6 +243,9 @@ func NewScheduler(cfg Config, limits Limits, log log.Logger, registerer promethe
 type Limits interface {
 	// MaxQueriersPerUser returns max queriers to use per tenant, or 0 if shuffle sharding is disabled.
 	MaxQueriersPerUser(user string) int
+
+	// QuerierPool returns the pool of queriers dedicated to the tenant, or an empty string for the shared pool.
+	QuerierPool(user string) string
 }
 
 type schedulerRequest struct {
@@ -270,11 +273,13 @@ func (s *Scheduler) FrontendLoop(frontend schedulerpb.SchedulerForFrontend_Front
 	}
 	defer s.frontendDisconnected(frontendAddress)
 
-	// Response to INIT. If scheduler is not running, we skip for-loop, send SHUTTING_DOWN and exit this method.
-	if s.State() == services.Running && s.shouldRun.Load() {
-		if err := frontend.Send(&schedulerpb.SchedulerToFrontend{Status: schedulerpb.OK}); err != nil {
-			return err
-		}
+	// Response to INIT. If scheduler is not running, or not in the ReplicationSet of the scheduler ring yet, we send
+	// SHUTTING_DOWN and exit this method, for the frontend to connect again later.
+	if s.State() != services.Running || !s.shouldRun.Load() {
+		return frontend.Send(&schedulerpb.SchedulerToFrontend{Status: schedulerpb.SHUTTING_DOWN})
+	}
+	if err := frontend.Send(&schedulerpb.SchedulerToFrontend{Status: schedulerpb.OK}); err != nil {
+		return err
 	}
 
 	// We stop accepting new queries in Stopping state. By returning quickly, we disconnect frontends, which in turns
@@ -410,9 +415,10 @@ func (s *Scheduler) enqueueRequest(frontendContext context.Context, frontendAddr
 		return err
 	}
 	maxQueriers := validation.SmallestPositiveNonZeroIntPerTenant(tenantIDs, s.limits.MaxQueriersPerUser)
+	pool := s.querierPool(tenantIDs)
 
 	s.activeUsers.UpdateUserTimestamp(userID, now)
-	return s.requestQueue.EnqueueRequest(userID, req, maxQueriers, func() {
+	return s.requestQueue.EnqueueRequest(userID, req, maxQueriers, pool, func() {
 		shouldCancel = false
 
 		s.pendingRequestsMu.Lock()
@@ -421,6 +427,20 @@ func (s *Scheduler) enqueueRequest(frontendContext context.Context, frontendAddr
 	})
 }
 
+// querierPool returns the querier pool of the tenants, multi tenant queries go
+// to the shared pool unless all their tenants are in the same pool.
+func (s *Scheduler) querierPool(tenantIDs []string) string {
+	var pool string
+	for i, id := range tenantIDs {
+		p := s.limits.QuerierPool(id)
+		if i > 0 && p != pool {
+			return ""
+		}
+		pool = p
+	}
+	return pool
+}
+
 // This method doesn't do removal from the queue.
 func (s *Scheduler) cancelRequestAndRemoveFromPending(frontendAddr string, queryID uint64) {
 	s.pendingRequestsMu.Lock()
@@ -442,9 +462,10 @@ func (s *Scheduler) QuerierLoop(querier schedulerpb.SchedulerForQuerier_QuerierL
 	}
 
 	querierID := resp.GetQuerierID()
-	level.Debug(s.log).Log("msg", "querier connected", "querier", querierID)
+	pool := schedulerpb.QuerierPoolFromContext(querier.Context())
+	level.Debug(s.log).Log("msg", "querier connected", "querier", querierID, "pool", pool)
 
-	s.requestQueue.RegisterQuerierConnection(querierID)
+	s.requestQueue.RegisterQuerierConnection(querierID, pool)
 	defer s.requestQueue.UnregisterQuerierConnection(querierID)
 
 	lastUserIndex := queue.FirstUser()
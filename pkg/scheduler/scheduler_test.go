@@ -63,6 +63,22 @@ func TestScheduler_setRunState(t *testing.T) {
 
 }
 
+type poolLimits map[string]string
+
+func (l poolLimits) MaxQueriersPerUser(string) int { return 0 }
+
+func (l poolLimits) QuerierPool(user string) string { return l[user] }
+
+func TestScheduler_querierPool(t *testing.T) {
+	s := Scheduler{limits: poolLimits{"a": "critical", "b": "critical", "c": "other"}}
+
+	assert.Equal(t, "critical", s.querierPool([]string{"a"}))
+	assert.Equal(t, "critical", s.querierPool([]string{"a", "b"}))
+	assert.Equal(t, "", s.querierPool([]string{"a", "c"}))
+	assert.Equal(t, "", s.querierPool([]string{"a", "d"}))
+	assert.Equal(t, "", s.querierPool([]string{"d"}))
+}
+
 type mockSchedulerForFrontendFrontendLoopServer struct {
 	msg *schedulerpb.SchedulerToFrontend
 }
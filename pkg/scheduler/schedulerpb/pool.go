@@ -0,0 +1,33 @@
+package schedulerpb
+
+import (
+	"context"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// querierPoolMetadataKey is the gRPC metadata key holding the pool of the
+// querier opening a querier loop.
+const querierPoolMetadataKey = "x-loki-querier-pool"
+
+// ContextWithQuerierPool returns a context sending the querier pool to the
+// scheduler when opening a querier loop.
+func ContextWithQuerierPool(ctx context.Context, pool string) context.Context {
+	if pool == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, querierPoolMetadataKey, pool)
+}
+
+// QuerierPoolFromContext returns the querier pool of a querier loop, empty for
+// the shared pool.
+func QuerierPoolFromContext(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if values := md.Get(querierPoolMetadataKey); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/go-kit/log/level"
@@ -25,6 +26,7 @@ type IngesterQuerier interface {
 // It should never be used in ingesters otherwise it would start spiraling around doing queries over and over again to other ingesters.
 type AsyncStore struct {
 	chunk.Store
+	scfgMtx              sync.RWMutex
 	scfg                 chunk.SchemaConfig
 	ingesterQuerier      IngesterQuerier
 	queryIngestersWithin time.Duration
@@ -39,6 +41,24 @@ func NewAsyncStore(store chunk.Store, scfg chunk.SchemaConfig, querier IngesterQ
 	}
 }
 
+// AddPeriodConfig implements chunk.PeriodConfigAdder
+func (a *AsyncStore) AddPeriodConfig(cfg chunk.PeriodConfig) error {
+	if err := addPeriodConfig(a.Store, cfg); err != nil {
+		return err
+	}
+
+	a.scfgMtx.Lock()
+	defer a.scfgMtx.Unlock()
+	a.scfg.Configs = appendPeriodConfig(a.scfg.Configs, cfg)
+	return nil
+}
+
+func (a *AsyncStore) schemaConfig() chunk.SchemaConfig {
+	a.scfgMtx.RLock()
+	defer a.scfgMtx.RUnlock()
+	return a.scfg
+}
+
 func (a *AsyncStore) GetChunkRefs(ctx context.Context, userID string, from, through model.Time, matchers ...*labels.Matcher) ([][]chunk.Chunk, []*chunk.Fetcher, error) {
 	spanLogger := spanlogger.FromContext(ctx)
 
@@ -89,7 +109,7 @@ func (a *AsyncStore) GetChunkRefs(ctx context.Context, userID string, from, thro
 }
 
 func (a *AsyncStore) mergeIngesterAndStoreChunks(userID string, storeChunks [][]chunk.Chunk, fetchers []*chunk.Fetcher, ingesterChunkIDs []string) ([][]chunk.Chunk, []*chunk.Fetcher, error) {
-	ingesterChunkIDs = filterDuplicateChunks(a.scfg, storeChunks, ingesterChunkIDs)
+	ingesterChunkIDs = filterDuplicateChunks(a.schemaConfig(), storeChunks, ingesterChunkIDs)
 	level.Debug(util_log.Logger).Log("msg", "post-filtering ingester chunks", "count", len(ingesterChunkIDs))
 
 	fetcherToChunksGroupIdx := make(map[*chunk.Fetcher]int, len(fetchers))
@@ -105,9 +125,9 @@ func (a *AsyncStore) mergeIngesterAndStoreChunks(userID string, storeChunks [][]
 		}
 
 		// ToDo(Sandeep) possible optimization: Keep the chunk fetcher reference handy after first call since it is expected to stay the same.
-		fetcher := a.Store.GetChunkFetcher(chk.Through)
+		fetcher := a.Store.GetChunkFetcher(userID, chk.Through)
 		if fetcher == nil {
-			return nil, nil, fmt.Errorf("got a nil fetcher for chunk %s", a.scfg.ExternalKey(chk))
+			return nil, nil, fmt.Errorf("got a nil fetcher for chunk %s", a.schemaConfig().ExternalKey(chk))
 		}
 
 		if _, ok := fetcherToChunksGroupIdx[fetcher]; !ok {
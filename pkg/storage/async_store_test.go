@@ -30,8 +30,8 @@ func (s *storeMock) GetChunkRefs(ctx context.Context, userID string, from, throu
 	return args.Get(0).([][]chunk.Chunk), args.Get(1).([]*chunk.Fetcher), args.Error(2)
 }
 
-func (s *storeMock) GetChunkFetcher(tm model.Time) *chunk.Fetcher {
-	args := s.Called(tm)
+func (s *storeMock) GetChunkFetcher(userID string, tm model.Time) *chunk.Fetcher {
+	args := s.Called(userID, tm)
 	return args.Get(0).(*chunk.Fetcher)
 }
 
@@ -204,7 +204,7 @@ func TestAsyncStore_mergeIngesterAndStoreChunks(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			store := newStoreMock()
 			store.On("GetChunkRefs", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(tc.storeChunks, tc.storeFetcher, nil)
-			store.On("GetChunkFetcher", mock.Anything).Return(tc.ingesterFetcher)
+			store.On("GetChunkFetcher", mock.Anything, mock.Anything).Return(tc.ingesterFetcher)
 
 			ingesterQuerier := newIngesterQuerierMock()
 			ingesterQuerier.On("GetChunkIDs", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(tc.ingesterChunkIDs, nil)
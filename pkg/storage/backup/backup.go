@@ -0,0 +1,302 @@
+// Package backup snapshots the state of a cluster kept besides its chunks, the index files, the delete requests and
+// the rules, in a secondary store, and restores it into a fresh cluster. The chunks are expected to be replicated by
+// the object store itself.
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/concurrency"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+
+	"github.com/grafana/loki/pkg/storage/chunk"
+)
+
+const (
+	// ManifestVersion is the version of the format of the snapshots.
+	ManifestVersion = 1
+
+	// Kinds of the objects of the snapshots.
+	KindBoltDBShipper  = "boltdb-shipper"
+	KindTSDB           = "tsdb"
+	KindDeleteRequests = "delete_requests"
+	KindRules          = "rules"
+
+	manifestFile  = "manifest.json"
+	objectsPrefix = "objects/"
+)
+
+// Manifest describes a snapshot, it is stored once all its objects are.
+type Manifest struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	// Schema is the schema config of the cluster, in YAML.
+	Schema  string   `json:"schema"`
+	Objects []Object `json:"objects"`
+}
+
+// Object is an object of a store of the cluster captured in a snapshot.
+type Object struct {
+	Kind string `json:"kind"`
+	// Key is the key of the object relative to the prefix of its store.
+	Key    string `json:"key"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Store is a store of the objects of a kind of a cluster.
+type Store struct {
+	Kind   string
+	Client chunk.ObjectClient
+	// Prefix is the prefix of the keys of the objects of the store.
+	Prefix string
+	// Exclude are the prefixes of the keys, relative to the prefix of the store, of the objects of other stores.
+	Exclude []string
+}
+
+// Cluster holds the stores of the objects of a cluster and its schema config.
+type Cluster struct {
+	Stores []Store
+	Schema chunk.SchemaConfig
+}
+
+// Options configures the snapshots and the restores.
+type Options struct {
+	// Parallelism is the number of objects copied in parallel.
+	Parallelism int
+	// Force restores a snapshot into a cluster with objects, overwriting the ones of the snapshot.
+	Force  bool
+	Logger log.Logger
+}
+
+// Backup captures the objects of the stores of the cluster in the snapshot of the given name of the snapshot store.
+func Backup(ctx context.Context, cluster Cluster, snapshots chunk.ObjectClient, name string, opts Options) (*Manifest, error) {
+	if _, err := readManifest(ctx, snapshots, name); err == nil {
+		return nil, fmt.Errorf("snapshot %s already exists", name)
+	} else if !snapshots.IsObjectNotFoundErr(errors.Cause(err)) {
+		return nil, err
+	}
+
+	schema, err := yaml.Marshal(cluster.Schema)
+	if err != nil {
+		return nil, err
+	}
+	manifest := &Manifest{
+		Version:   ManifestVersion,
+		CreatedAt: time.Now().UTC(),
+		Schema:    string(schema),
+	}
+
+	for _, store := range cluster.Stores {
+		keys, err := listStore(ctx, store)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list the %s objects", store.Kind)
+		}
+		objects := make([]Object, len(keys))
+		err = concurrency.ForEachJob(ctx, len(keys), opts.Parallelism, func(ctx context.Context, i int) error {
+			objects[i] = Object{Kind: store.Kind, Key: keys[i]}
+			return copyObject(ctx, store.Client, store.Prefix+keys[i], snapshots, objectKey(name, objects[i]), &objects[i], false)
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to capture the %s objects", store.Kind)
+		}
+		level.Info(opts.Logger).Log("msg", "captured objects", "kind", store.Kind, "objects", len(objects))
+		manifest.Objects = append(manifest.Objects, objects...)
+	}
+
+	buf, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := snapshots.PutObject(ctx, name+"/"+manifestFile, bytes.NewReader(buf)); err != nil {
+		return nil, errors.Wrap(err, "failed to store the manifest")
+	}
+	return manifest, nil
+}
+
+// Restore copies the objects of the snapshot of the given name into the stores of the cluster, once its schema config
+// is validated. The cluster must not have any object unless forced.
+func Restore(ctx context.Context, cluster Cluster, snapshots chunk.ObjectClient, name string, opts Options) (*Manifest, error) {
+	manifest, err := readManifest(ctx, snapshots, name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read the manifest of snapshot %s", name)
+	}
+	if manifest.Version != ManifestVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d", manifest.Version)
+	}
+	if err := ValidateSchema(manifest, cluster.Schema); err != nil {
+		return nil, err
+	}
+
+	stores := make(map[string]Store, len(cluster.Stores))
+	for _, store := range cluster.Stores {
+		stores[store.Kind] = store
+	}
+	for _, object := range manifest.Objects {
+		if _, ok := stores[object.Kind]; !ok {
+			return nil, fmt.Errorf("the cluster has no store for the %s objects of the snapshot", object.Kind)
+		}
+	}
+	if !opts.Force {
+		for _, store := range cluster.Stores {
+			keys, err := listStore(ctx, store)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to list the %s objects", store.Kind)
+			}
+			if len(keys) > 0 {
+				return nil, fmt.Errorf("the cluster already has %s objects, e.g. %s", store.Kind, store.Prefix+keys[0])
+			}
+		}
+	}
+
+	err = concurrency.ForEachJob(ctx, len(manifest.Objects), opts.Parallelism, func(ctx context.Context, i int) error {
+		object := manifest.Objects[i]
+		store := stores[object.Kind]
+		return copyObject(ctx, snapshots, objectKey(name, object), store.Client, store.Prefix+object.Key, &object, true)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to restore the objects")
+	}
+	level.Info(opts.Logger).Log("msg", "restored objects", "objects", len(manifest.Objects))
+	return manifest, nil
+}
+
+// ValidateSchema verifies the periods of the schema config of the snapshot are the first ones of the schema config of
+// the cluster it is restored into, for the index and the chunks to be read the same.
+func ValidateSchema(manifest *Manifest, schema chunk.SchemaConfig) error {
+	var snapshot chunk.SchemaConfig
+	if err := yaml.UnmarshalStrict([]byte(manifest.Schema), &snapshot); err != nil {
+		return errors.Wrap(err, "failed to parse the schema config of the snapshot")
+	}
+	if len(schema.Configs) < len(snapshot.Configs) {
+		return fmt.Errorf("the schema config of the cluster has %d periods, fewer than the %d of the snapshot", len(schema.Configs), len(snapshot.Configs))
+	}
+	for i, period := range snapshot.Configs {
+		expected, err := yaml.Marshal(period)
+		if err != nil {
+			return err
+		}
+		actual, err := yaml.Marshal(schema.Configs[i])
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(expected, actual) {
+			return fmt.Errorf("the period from %s of the schema config of the cluster differs from the one of the snapshot:\n%s", period.From.String(), expected)
+		}
+	}
+	return nil
+}
+
+// List returns the names of the snapshots of the snapshot store, in order.
+func List(ctx context.Context, snapshots chunk.ObjectClient) ([]string, error) {
+	_, prefixes, err := snapshots.List(ctx, "", "/")
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, prefix := range prefixes {
+		name := strings.TrimSuffix(string(prefix), "/")
+		// the snapshots without manifest are incomplete.
+		if _, err := readManifest(ctx, snapshots, name); err == nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func readManifest(ctx context.Context, snapshots chunk.ObjectClient, name string) (*Manifest, error) {
+	r, _, err := snapshots.GetObject(ctx, name+"/"+manifestFile)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var manifest Manifest
+	if err := json.NewDecoder(r).Decode(&manifest); err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+// listStore returns the keys of the objects of the store relative to its prefix, in order.
+func listStore(ctx context.Context, store Store) ([]string, error) {
+	objects, _, err := store.Client.List(ctx, store.Prefix, "")
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(objects))
+outer:
+	for _, object := range objects {
+		key := strings.TrimPrefix(object.Key, store.Prefix)
+		for _, exclude := range store.Exclude {
+			if strings.HasPrefix(key, exclude) {
+				continue outer
+			}
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func objectKey(name string, object Object) string {
+	return name + "/" + objectsPrefix + object.Kind + "/" + object.Key
+}
+
+// copyObject copies an object between stores. It sets the size and the checksum of the object copied, or verifies
+// them when they are expected.
+func copyObject(ctx context.Context, from chunk.ObjectClient, fromKey string, to chunk.ObjectClient, toKey string, object *Object, verify bool) error {
+	r, _, err := from.GetObject(ctx, fromKey)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get %s", fromKey)
+	}
+	defer r.Close()
+
+	// the objects are spooled to disk, they can be large and have to be seekable to be put.
+	f, err := ioutil.TempFile("", "loki-backup-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+	}()
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(f, h), r)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read %s", fromKey)
+	}
+	checksum := hex.EncodeToString(h.Sum(nil))
+	if verify {
+		if size != object.Size || checksum != object.SHA256 {
+			return fmt.Errorf("the %s object %s of the snapshot is corrupt: %d bytes with checksum %s, expected %d bytes with checksum %s",
+				object.Kind, object.Key, size, checksum, object.Size, object.SHA256)
+		}
+	} else {
+		object.Size, object.SHA256 = size, checksum
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := to.PutObject(ctx, toKey, f); err != nil {
+		return errors.Wrapf(err, "failed to put %s", toKey)
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/pkg/storage/chunk"
+	"github.com/grafana/loki/pkg/storage/chunk/local"
+)
+
+var testSchema = chunk.SchemaConfig{Configs: []chunk.PeriodConfig{{
+	From:        chunk.DayTime{Time: 0},
+	IndexType:   "boltdb-shipper",
+	ObjectType:  "filesystem",
+	Schema:      "v11",
+	IndexTables: chunk.PeriodicTableConfig{Prefix: "index_", Period: 24 * time.Hour},
+	RowShards:   16,
+}}}
+
+func newClient(t *testing.T, dir string) *local.FSObjectClient {
+	client, err := local.NewFSObjectClient(local.FSConfig{Directory: dir})
+	require.NoError(t, err)
+	return client
+}
+
+func newCluster(client chunk.ObjectClient, schema chunk.SchemaConfig) Cluster {
+	return Cluster{
+		Stores: []Store{
+			{Kind: KindBoltDBShipper, Client: client, Prefix: "index/", Exclude: []string{"delete_requests/"}},
+			{Kind: KindDeleteRequests, Client: client, Prefix: "index/delete_requests/"},
+			{Kind: KindRules, Client: client, Prefix: "rules/"},
+		},
+		Schema: schema,
+	}
+}
+
+func readObject(t *testing.T, client chunk.ObjectClient, key string) string {
+	r, _, err := client.GetObject(context.Background(), key)
+	require.NoError(t, err)
+	defer r.Close()
+	buf, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	return string(buf)
+}
+
+func TestBackupRestore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	source := newClient(t, filepath.Join(dir, "source"))
+	snapshots := newClient(t, filepath.Join(dir, "snapshots"))
+	opts := Options{Parallelism: 2, Logger: log.NewNopLogger()}
+
+	objects := map[string]string{
+		"index/index_19000/compactor-1.gz":             "table 19000",
+		"index/index_19001/fake/ingester-1.gz":         "user table 19001",
+		"index/delete_requests/delete_requests.gz":     "delete requests",
+		"rules/fake/bmFtZXNwYWNl/Z3JvdXA":              "rule group",
+		"fake/5f3e8a5b0b3c2a1d:17f:180:1a2b3c4d":       "chunk",
+		"compactor-locks/index_19000":                  "lock",
+		"index/index_19001/fake/ingester-2.gz.partial": "partial",
+	}
+	for key, content := range objects {
+		require.NoError(t, source.PutObject(ctx, key, bytes.NewReader([]byte(content))))
+	}
+
+	manifest, err := Backup(ctx, newCluster(source, testSchema), snapshots, "snapshot-1", opts)
+	require.NoError(t, err)
+	actual := map[string]string{}
+	for _, object := range manifest.Objects {
+		require.Equal(t, int64(len(objects[prefix(object.Kind)+object.Key])), object.Size)
+		actual[object.Kind+":"+object.Key] = readObject(t, snapshots, objectKey("snapshot-1", object))
+	}
+	require.Equal(t, map[string]string{
+		"boltdb-shipper:index_19000/compactor-1.gz":             "table 19000",
+		"boltdb-shipper:index_19001/fake/ingester-1.gz":         "user table 19001",
+		"boltdb-shipper:index_19001/fake/ingester-2.gz.partial": "partial",
+		"delete_requests:delete_requests.gz":                    "delete requests",
+		"rules:fake/bmFtZXNwYWNl/Z3JvdXA":                       "rule group",
+	}, actual)
+
+	names, err := List(ctx, snapshots)
+	require.NoError(t, err)
+	require.Equal(t, []string{"snapshot-1"}, names)
+
+	_, err = Backup(ctx, newCluster(source, testSchema), snapshots, "snapshot-1", opts)
+	require.EqualError(t, err, "snapshot snapshot-1 already exists")
+
+	// restores into a fresh cluster.
+	target := newClient(t, filepath.Join(dir, "target"))
+	_, err = Restore(ctx, newCluster(target, testSchema), snapshots, "snapshot-1", opts)
+	require.NoError(t, err)
+	for _, key := range []string{"index/index_19000/compactor-1.gz", "index/index_19001/fake/ingester-1.gz", "index/delete_requests/delete_requests.gz", "rules/fake/bmFtZXNwYWNl/Z3JvdXA"} {
+		require.Equal(t, objects[key], readObject(t, target, key))
+	}
+
+	// the cluster isn't fresh anymore.
+	_, err = Restore(ctx, newCluster(target, testSchema), snapshots, "snapshot-1", opts)
+	require.Error(t, err)
+	opts.Force = true
+	_, err = Restore(ctx, newCluster(target, testSchema), snapshots, "snapshot-1", opts)
+	require.NoError(t, err)
+
+	// the cluster without store of the rules.
+	cluster := newCluster(newClient(t, filepath.Join(dir, "no-rules")), testSchema)
+	cluster.Stores = cluster.Stores[:2]
+	_, err = Restore(ctx, cluster, snapshots, "snapshot-1", opts)
+	require.EqualError(t, err, "the cluster has no store for the rules objects of the snapshot")
+
+	// a corrupt object of the snapshot.
+	require.NoError(t, snapshots.PutObject(ctx, "snapshot-1/objects/rules/fake/bmFtZXNwYWNl/Z3JvdXA", bytes.NewReader([]byte("corrupt"))))
+	_, err = Restore(ctx, newCluster(newClient(t, filepath.Join(dir, "corrupt")), testSchema), snapshots, "snapshot-1", opts)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "the rules object fake/bmFtZXNwYWNl/Z3JvdXA of the snapshot is corrupt")
+
+	_, err = Restore(ctx, newCluster(target, testSchema), snapshots, "snapshot-2", opts)
+	require.Error(t, err)
+}
+
+func prefix(kind string) string {
+	switch kind {
+	case KindDeleteRequests:
+		return "index/delete_requests/"
+	case KindRules:
+		return "rules/"
+	}
+	return "index/"
+}
+
+func TestValidateSchema(t *testing.T) {
+	manifest, err := Backup(context.Background(), Cluster{Schema: testSchema}, newClient(t, t.TempDir()), "snapshot", Options{Logger: log.NewNopLogger()})
+	require.NoError(t, err)
+
+	newPeriod := testSchema.Configs[0]
+	newPeriod.From = chunk.DayTime{Time: 86400 * 1000}
+	newPeriod.Schema = "v12"
+	changedPeriod := testSchema.Configs[0]
+	changedPeriod.RowShards = 32
+
+	for _, tc := range []struct {
+		name  string
+		cfg   []chunk.PeriodConfig
+		valid bool
+	}{
+		{name: "same schema", cfg: testSchema.Configs, valid: true},
+		{name: "new period", cfg: []chunk.PeriodConfig{testSchema.Configs[0], newPeriod}, valid: true},
+		{name: "changed period", cfg: []chunk.PeriodConfig{changedPeriod}},
+		{name: "missing period", cfg: []chunk.PeriodConfig{}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateSchema(manifest, chunk.SchemaConfig{Configs: tc.cfg})
+			if tc.valid {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+		})
+	}
+}
@@ -32,8 +32,10 @@ type ChunkMetrics struct {
 }
 
 const (
-	statusDiscarded = "discarded"
-	statusMatched   = "matched"
+	statusDiscarded     = "discarded"
+	statusMatched       = "matched"
+	statusQuarantined   = "quarantined"
+	statusBloomFiltered = "bloom_filtered"
 )
 
 func NewChunkMetrics(r prometheus.Registerer, maxBatchSize int) *ChunkMetrics {
@@ -684,10 +686,17 @@ func fetchLazyChunks(ctx context.Context, s chunk.SchemaConfig, chunks []*LazyCh
 		stats.AddChunksDownloaded(totalChunks)
 	}()
 
-	chksByFetcher := map[*chunk.Fetcher][]*LazyChunk{}
+	// the chunks are fetched by period too, to time the fetches of each period.
+	type fetchKey struct {
+		fetcher *chunk.Fetcher
+		period  int
+	}
+	periods := newPeriodsStats(s)
+	chksByFetcher := map[fetchKey][]*LazyChunk{}
 	for _, c := range chunks {
 		if c.Chunk.Data == nil {
-			chksByFetcher[c.Fetcher] = append(chksByFetcher[c.Fetcher], c)
+			key := fetchKey{fetcher: c.Fetcher, period: periods.periodFor(c.Chunk)}
+			chksByFetcher[key] = append(chksByFetcher[key], c)
 			totalChunks++
 		}
 	}
@@ -697,8 +706,9 @@ func fetchLazyChunks(ctx context.Context, s chunk.SchemaConfig, chunks []*LazyCh
 	level.Debug(logger).Log("msg", "loading lazy chunks", "chunks", totalChunks)
 
 	errChan := make(chan error)
-	for fetcher, chunks := range chksByFetcher {
+	for key, chunks := range chksByFetcher {
 		go func(fetcher *chunk.Fetcher, chunks []*LazyChunk) {
+			fetchStart := time.Now()
 			keys := make([]string, 0, len(chunks))
 			chks := make([]chunk.Chunk, 0, len(chunks))
 			index := make(map[string]*LazyChunk, len(chunks))
@@ -724,12 +734,17 @@ func fetchLazyChunks(ctx context.Context, s chunk.SchemaConfig, chunks []*LazyCh
 
 			}
 			// assign fetched chunk by key as FetchChunks doesn't guarantee the order.
+			var fetchedBytes int64
 			for _, chk := range chks {
 				index[s.ExternalKey(chk)].Chunk = chk
+				if chk.Data != nil {
+					fetchedBytes += int64(chk.Data.Size())
+				}
 			}
+			periods.addFetched(chunks[0].Chunk, int64(len(chunks)), fetchedBytes, time.Since(fetchStart))
 
 			errChan <- nil
-		}(fetcher, chunks)
+		}(key.fetcher, chunks)
 	}
 
 	var lastErr error
@@ -739,6 +754,7 @@ func fetchLazyChunks(ctx context.Context, s chunk.SchemaConfig, chunks []*LazyCh
 		}
 	}
 
+	periods.add(stats)
 	if lastErr != nil {
 		return lastErr
 	}
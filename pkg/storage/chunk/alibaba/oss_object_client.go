@@ -0,0 +1,140 @@
+package alibaba
+
+import (
+	"crypto/hmac"
+	"crypto/sha1" //nolint:gosec // OSS signatures are HMAC-SHA1.
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/grafana/dskit/backoff"
+	"github.com/grafana/dskit/flagext"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/weaveworks/common/instrument"
+
+	"github.com/grafana/loki/pkg/storage/chunk/hedging"
+	"github.com/grafana/loki/pkg/storage/chunk/restclient"
+	"github.com/grafana/loki/pkg/util/log"
+)
+
+var ossRequestDuration = instrument.NewHistogramCollector(prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "loki",
+	Name:      "oss_request_duration_seconds",
+	Help:      "Time spent doing Alibaba Cloud OSS requests.",
+	Buckets:   []float64{.025, .05, .1, .25, .5, 1, 2},
+}, []string{"operation", "status_code"}))
+
+func init() {
+	ossRequestDuration.Register()
+}
+
+// OssConfig is config for the Alibaba Cloud OSS Chunk Client.
+type OssConfig struct {
+	Endpoint        string         `yaml:"endpoint"`
+	Bucket          string         `yaml:"bucket"`
+	AccessKeyID     string         `yaml:"access_key_id"`
+	SecretAccessKey flagext.Secret `yaml:"secret_access_key"`
+	Insecure        bool           `yaml:"insecure"`
+	RequestTimeout  time.Duration  `yaml:"request_timeout"`
+	BackoffConfig   backoff.Config `yaml:"backoff_config"`
+}
+
+// RegisterFlags registers flags.
+func (cfg *OssConfig) RegisterFlags(f *flag.FlagSet) {
+	cfg.RegisterFlagsWithPrefix("", f)
+}
+
+// RegisterFlagsWithPrefix registers flags with prefix.
+func (cfg *OssConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.StringVar(&cfg.Endpoint, prefix+"oss.endpoint", "", "Endpoint of the region of the OSS bucket, e.g. oss-cn-hangzhou.aliyuncs.com.")
+	f.StringVar(&cfg.Bucket, prefix+"oss.bucketname", "", "Name of the OSS bucket to put chunks in.")
+	f.StringVar(&cfg.AccessKeyID, prefix+"oss.access-key-id", "", "Alibaba Cloud AccessKey ID.")
+	f.Var(&cfg.SecretAccessKey, prefix+"oss.secret-access-key", "Alibaba Cloud AccessKey secret.")
+	f.BoolVar(&cfg.Insecure, prefix+"oss.insecure", false, "Connect to the OSS endpoint over HTTP instead of HTTPS.")
+	f.DurationVar(&cfg.RequestTimeout, prefix+"oss.request-timeout", 30*time.Second, "Timeout of a request to OSS.")
+	f.DurationVar(&cfg.BackoffConfig.MinBackoff, prefix+"oss.min-backoff", 100*time.Millisecond, "Minimum backoff time when retrying OSS requests.")
+	f.DurationVar(&cfg.BackoffConfig.MaxBackoff, prefix+"oss.max-backoff", 3*time.Second, "Maximum backoff time when retrying OSS requests.")
+	f.IntVar(&cfg.BackoffConfig.MaxRetries, prefix+"oss.max-retries", 5, "Maximum number of times to retry OSS requests.")
+}
+
+// Validate config and returns error on failure
+func (cfg *OssConfig) Validate() error {
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return errors.New("the OSS endpoint and bucket must be set")
+	}
+	return nil
+}
+
+// OssObjectClient stores the chunks in an Alibaba Cloud OSS bucket, through the REST API of OSS.
+type OssObjectClient struct {
+	*restclient.Client
+}
+
+// NewOssObjectClient makes a new chunk.Client that writes chunks to Alibaba Cloud OSS.
+func NewOssObjectClient(cfg OssConfig, hedgingCfg hedging.Config) (*OssObjectClient, error) {
+	log.WarnExperimentalUse("Alibaba Cloud OSS Storage", log.Logger)
+	return newOssObjectClient(cfg, hedgingCfg, restclient.NewTransport())
+}
+
+func newOssObjectClient(cfg OssConfig, hedgingCfg hedging.Config, transport http.RoundTripper) (*OssObjectClient, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	scheme := "https"
+	if cfg.Insecure {
+		scheme = "http"
+	}
+	// the buckets are addressed by virtual host, OSS doesn't support path-style requests.
+	baseURL, err := url.Parse(fmt.Sprintf("%s://%s.%s", scheme, cfg.Bucket, cfg.Endpoint))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid OSS endpoint")
+	}
+
+	client, err := restclient.New(restclient.Config{
+		Service:         "OSS",
+		RequestTimeout:  cfg.RequestTimeout,
+		BackoffConfig:   cfg.BackoffConfig,
+		RequestDuration: ossRequestDuration,
+	}, &ossAPI{cfg: cfg, baseURL: baseURL}, hedgingCfg, transport)
+	if err != nil {
+		return nil, err
+	}
+	return &OssObjectClient{Client: client}, nil
+}
+
+// ossAPI builds and signs the requests of the REST API of OSS, which is compatible with the XML API of S3.
+type ossAPI struct {
+	restclient.XMLAPI
+
+	cfg     OssConfig
+	baseURL *url.URL
+}
+
+// URL implements restclient.Provider.
+func (a *ossAPI) URL(objectKey string, query url.Values) url.URL {
+	u := *a.baseURL
+	u.Path = "/" + objectKey
+	u.RawQuery = query.Encode()
+	return u
+}
+
+// Sign implements restclient.Provider, adding the signature of version 1 of OSS to the
+// request, which is computed over the canonicalized resource `/<bucket>/<object key>`.
+func (a *ossAPI) Sign(req *http.Request, objectKey string, _ url.Values) {
+	date := time.Now().UTC().Format(http.TimeFormat)
+	req.Header.Set("Date", date)
+
+	stringToSign := req.Method + "\n" +
+		req.Header.Get("Content-MD5") + "\n" +
+		req.Header.Get("Content-Type") + "\n" +
+		date + "\n" +
+		"/" + a.cfg.Bucket + "/" + objectKey
+	mac := hmac.New(sha1.New, []byte(a.cfg.SecretAccessKey.Value))
+	_, _ = mac.Write([]byte(stringToSign))
+	req.Header.Set("Authorization", "OSS "+a.cfg.AccessKeyID+":"+base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+}
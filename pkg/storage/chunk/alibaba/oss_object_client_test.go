@@ -0,0 +1,52 @@
+package alibaba
+
+import (
+	"crypto/hmac"
+	"crypto/sha1" //nolint:gosec
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/dskit/backoff"
+	"github.com/grafana/dskit/flagext"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/pkg/storage/chunk/hedging"
+	"github.com/grafana/loki/pkg/storage/chunk/restclient/restclienttest"
+)
+
+// validSignature checks the signature of version 1 of OSS of the request.
+func validSignature(r *http.Request, secret string) bool {
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	bucket := strings.SplitN(r.Host, ".", 2)[0]
+	stringToSign := r.Method + "\n\n" + r.Header.Get("Content-Type") + "\n" + r.Header.Get("Date") + "\n/" + bucket + "/" + key
+	mac := hmac.New(sha1.New, []byte(secret))
+	_, _ = mac.Write([]byte(stringToSign))
+	return r.Header.Get("Authorization") == "OSS id:"+base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func newTestClient(t *testing.T) (*OssObjectClient, *restclienttest.Bucket) {
+	bucket := &restclienttest.Bucket{T: t, Name: "loki", Secret: "secret", ValidSignature: validSignature}
+	client, err := newOssObjectClient(OssConfig{
+		Endpoint:        "oss-test.aliyuncs.com",
+		Bucket:          bucket.Name,
+		AccessKeyID:     "id",
+		SecretAccessKey: flagext.Secret{Value: bucket.Secret},
+		Insecure:        true,
+		BackoffConfig:   backoff.Config{MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond, MaxRetries: 3},
+	}, hedging.Config{}, bucket.NewTransport())
+	require.NoError(t, err)
+	return client, bucket
+}
+
+func TestOssObjectClient(t *testing.T) {
+	client, bucket := newTestClient(t)
+	restclienttest.TestObjectClient(t, client, bucket)
+}
+
+func TestOssObjectClient_Retries(t *testing.T) {
+	client, bucket := newTestClient(t)
+	restclienttest.TestRetries(t, client, bucket)
+}
@@ -0,0 +1,330 @@
+package aws
+
+import (
+	"context"
+	"flag"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/client/metadata"
+	"github.com/aws/aws-sdk-go/aws/request"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/aws/aws-sdk-go/private/protocol/query"
+	"github.com/pkg/errors"
+	"github.com/weaveworks/common/mtime"
+
+	"github.com/grafana/loki/pkg/storage/chunk"
+)
+
+const (
+	cloudWatchServiceName = "monitoring"
+	cloudWatchAPIVersion  = "2010-08-01"
+	dynamoDBNamespace     = "AWS/DynamoDB"
+
+	// DynamoDB publishes its metrics to CloudWatch every minute, with a few minutes of delay.
+	// The throttling is therefore averaged over a wider window than the Prometheus queries.
+	cloudWatchPeriod           = time.Minute
+	cloudWatchThrottleWindow   = 5 * time.Minute
+	cloudWatchWriteUsageWindow = 15 * time.Minute
+	cloudWatchReadUsageWindow  = time.Hour
+
+	cloudWatchQueueLengthID   = "queue_length"
+	cloudWatchWriteThrottleID = "write_throttle"
+	cloudWatchWriteUsageID    = "write_usage"
+	cloudWatchReadUsageID     = "read_usage"
+	cloudWatchReadThrottleID  = "read_throttle"
+)
+
+// CloudWatchMetricsConfig configures the metrics-based autoscaling to fetch the throttling and the consumed
+// capacity of the tables from CloudWatch, along with the ingester queue length published as a custom metric.
+type CloudWatchMetricsConfig struct {
+	Enabled              bool   `yaml:"enabled"`
+	Endpoint             string `yaml:"endpoint"`
+	QueueLengthNamespace string `yaml:"queue_length_namespace"`
+	QueueLengthMetric    string `yaml:"queue_length_metric"`
+}
+
+// RegisterFlags adds the flags required to config this to the given FlagSet
+func (cfg *CloudWatchMetricsConfig) RegisterFlags(f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, "metrics.cloudwatch.enabled", false, "Use metrics-based autoscaling with the metrics of CloudWatch instead of the Prometheus queries")
+	f.StringVar(&cfg.Endpoint, "metrics.cloudwatch.endpoint", "", "CloudWatch endpoint, deduced from the region of the DynamoDB URL when empty")
+	f.StringVar(&cfg.QueueLengthNamespace, "metrics.cloudwatch.queue-length-namespace", "Loki", "CloudWatch namespace of the ingester queue length metric")
+	f.StringVar(&cfg.QueueLengthMetric, "metrics.cloudwatch.queue-length-metric", "cortex_ingester_flush_queue_length", "CloudWatch name of the ingester queue length metric, summed over the ingesters")
+}
+
+// cloudWatchAutoScaling scales the tables like metricsData, from the metrics of CloudWatch. The metrics
+// of each table are fetched when the table is updated.
+type cloudWatchAutoScaling struct {
+	*metricsData
+	cloudWatch     cloudWatchAPI
+	cloudWatchCfg  CloudWatchMetricsConfig
+	queueLastQuery time.Time
+	tableLastQuery map[string]time.Time
+}
+
+func newCloudWatchAutoScaling(cfg DynamoDBConfig) (*cloudWatchAutoScaling, error) {
+	if cfg.Metrics.CloudWatch.QueueLengthNamespace == "" || cfg.Metrics.CloudWatch.QueueLengthMetric == "" {
+		return nil, errors.New("the CloudWatch metrics autoscaling requires the namespace and the name of the queue length metric")
+	}
+	session, err := awsSessionFromURL(cfg.DynamoDB.URL)
+	if err != nil {
+		return nil, err
+	}
+	return &cloudWatchAutoScaling{
+		metricsData: &metricsData{
+			cfg:                  cfg.Metrics,
+			tableLastUpdated:     make(map[string]time.Time),
+			tableReadLastUpdated: make(map[string]time.Time),
+			throttleRates:        make(map[string]float64),
+			usageRates:           make(map[string]float64),
+			usageReadRates:       make(map[string]float64),
+			readErrorRates:       make(map[string]float64),
+		},
+		cloudWatch:     newCloudWatchClient(session, cfg.Metrics.CloudWatch.Endpoint),
+		cloudWatchCfg:  cfg.Metrics.CloudWatch,
+		tableLastQuery: make(map[string]time.Time),
+	}, nil
+}
+
+func (c *cloudWatchAutoScaling) UpdateTable(ctx context.Context, current chunk.TableDesc, expected *chunk.TableDesc) error {
+	if !expected.WriteScale.Enabled && !expected.ReadScale.Enabled {
+		return nil
+	}
+	if err := c.update(ctx, expected.Name); err != nil {
+		return err
+	}
+
+	c.scaleTable(current, expected)
+	return nil
+}
+
+// update fetches the queue length and the metrics of the table, unless they were fetched recently.
+func (c *cloudWatchAutoScaling) update(ctx context.Context, table string) error {
+	now := mtime.Now()
+
+	var queries []*metricDataQuery
+	updateQueue := !c.queueLastQuery.After(now.Add(-cachePromDataFor))
+	if updateQueue {
+		queries = append(queries, newMetricDataQuery(cloudWatchQueueLengthID, c.cloudWatchCfg.QueueLengthNamespace, c.cloudWatchCfg.QueueLengthMetric, "Average", nil))
+	}
+	updateTable := !c.tableLastQuery[table].After(now.Add(-cachePromDataFor))
+	if updateTable {
+		dimensions := []*dimension{{Name: aws.String("TableName"), Value: aws.String(table)}}
+		queries = append(queries,
+			newMetricDataQuery(cloudWatchWriteThrottleID, dynamoDBNamespace, "WriteThrottleEvents", "Sum", dimensions),
+			newMetricDataQuery(cloudWatchWriteUsageID, dynamoDBNamespace, "ConsumedWriteCapacityUnits", "Sum", dimensions),
+			newMetricDataQuery(cloudWatchReadUsageID, dynamoDBNamespace, "ConsumedReadCapacityUnits", "Sum", dimensions),
+			newMetricDataQuery(cloudWatchReadThrottleID, dynamoDBNamespace, "ReadThrottleEvents", "Sum", dimensions),
+		)
+	}
+	if len(queries) == 0 {
+		return nil
+	}
+
+	series, err := c.getMetricData(ctx, queries, now.Add(-cloudWatchReadUsageWindow), now)
+	if err != nil {
+		return err
+	}
+
+	if updateQueue {
+		// Like the Prometheus query, the last three minutes of queue length tell whether it grows or shrinks.
+		queue := series[cloudWatchQueueLengthID]
+		if len(queue) < 3 {
+			return errors.Errorf("expected three values for queue: %d", len(queue))
+		}
+		c.queueLengths = make([]float64, 3)
+		for i, p := range queue[len(queue)-3:] {
+			c.queueLengths[i] = p.value
+		}
+		c.queueLastQuery = now
+	}
+
+	if updateTable {
+		c.throttleRates[table] = sumSince(series[cloudWatchWriteThrottleID], now.Add(-cloudWatchThrottleWindow)) / cloudWatchThrottleWindow.Seconds()
+		c.usageRates[table] = sumSince(series[cloudWatchWriteUsageID], now.Add(-cloudWatchWriteUsageWindow)) / cloudWatchWriteUsageWindow.Seconds()
+		c.usageReadRates[table] = sumSince(series[cloudWatchReadUsageID], now.Add(-cloudWatchReadUsageWindow)) / cloudWatchReadUsageWindow.Seconds()
+		// The Prometheus query counts the read errors over a minute.
+		c.readErrorRates[table] = sumSince(series[cloudWatchReadThrottleID], now.Add(-cloudWatchThrottleWindow)) / cloudWatchThrottleWindow.Minutes()
+		c.tableLastQuery[table] = now
+	}
+	return nil
+}
+
+type cloudWatchPoint struct {
+	timestamp time.Time
+	value     float64
+}
+
+// getMetricData returns the points of each query by id, in increasing order of time.
+func (c *cloudWatchAutoScaling) getMetricData(ctx context.Context, queries []*metricDataQuery, start, end time.Time) (map[string][]cloudWatchPoint, error) {
+	input := &getMetricDataInput{
+		MetricDataQueries: queries,
+		StartTime:         aws.Time(start),
+		EndTime:           aws.Time(end),
+	}
+	series := map[string][]cloudWatchPoint{}
+	for {
+		output, err := c.cloudWatch.GetMetricDataWithContext(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		for _, result := range output.MetricDataResults {
+			if len(result.Timestamps) != len(result.Values) {
+				return nil, errors.Errorf("got %d timestamps and %d values for %s", len(result.Timestamps), len(result.Values), aws.StringValue(result.ID))
+			}
+			id := aws.StringValue(result.ID)
+			for i := range result.Values {
+				series[id] = append(series[id], cloudWatchPoint{
+					timestamp: aws.TimeValue(result.Timestamps[i]),
+					value:     aws.Float64Value(result.Values[i]),
+				})
+			}
+		}
+		if aws.StringValue(output.NextToken) == "" {
+			break
+		}
+		input.NextToken = output.NextToken
+	}
+
+	for _, points := range series {
+		sort.Slice(points, func(i, j int) bool {
+			return points[i].timestamp.Before(points[j].timestamp)
+		})
+	}
+	return series, nil
+}
+
+func sumSince(points []cloudWatchPoint, since time.Time) float64 {
+	var sum float64
+	for _, p := range points {
+		if !p.timestamp.Before(since) {
+			sum += p.value
+		}
+	}
+	return sum
+}
+
+func newMetricDataQuery(id, namespace, name, stat string, dimensions []*dimension) *metricDataQuery {
+	return &metricDataQuery{
+		ID: aws.String(id),
+		MetricStat: &metricStat{
+			Metric: &metric{
+				Namespace:  aws.String(namespace),
+				MetricName: aws.String(name),
+				Dimensions: dimensions,
+			},
+			Period: aws.Int64(int64(cloudWatchPeriod / time.Second)),
+			Stat:   aws.String(stat),
+		},
+	}
+}
+
+// cloudWatchAPI is the part of the CloudWatch API used by the autoscaling.
+type cloudWatchAPI interface {
+	GetMetricDataWithContext(ctx aws.Context, input *getMetricDataInput) (*getMetricDataOutput, error)
+}
+
+// cloudWatchClient calls the GetMetricData operation of CloudWatch through the query protocol,
+// like the clients of the AWS SDK.
+type cloudWatchClient struct {
+	*client.Client
+}
+
+func newCloudWatchClient(p client.ConfigProvider, endpoint string) *cloudWatchClient {
+	// The session may have the endpoint of the DynamoDB URL.
+	c := p.ClientConfig(cloudWatchServiceName, &aws.Config{Endpoint: aws.String(endpoint)})
+	if c.SigningNameDerived || len(c.SigningName) == 0 {
+		c.SigningName = cloudWatchServiceName
+	}
+	svc := client.New(
+		*c.Config,
+		metadata.ClientInfo{
+			ServiceName:    cloudWatchServiceName,
+			ServiceID:      "CloudWatch",
+			SigningName:    c.SigningName,
+			SigningRegion:  c.SigningRegion,
+			PartitionID:    c.PartitionID,
+			Endpoint:       c.Endpoint,
+			APIVersion:     cloudWatchAPIVersion,
+			ResolvedRegion: c.ResolvedRegion,
+		},
+		c.Handlers,
+	)
+	svc.Handlers.Sign.PushBackNamed(v4.SignRequestHandler)
+	svc.Handlers.Build.PushBackNamed(query.BuildHandler)
+	svc.Handlers.Unmarshal.PushBackNamed(query.UnmarshalHandler)
+	svc.Handlers.UnmarshalMeta.PushBackNamed(query.UnmarshalMetaHandler)
+	svc.Handlers.UnmarshalError.PushBackNamed(query.UnmarshalErrorHandler)
+	return &cloudWatchClient{Client: svc}
+}
+
+func (c *cloudWatchClient) GetMetricDataWithContext(ctx aws.Context, input *getMetricDataInput) (*getMetricDataOutput, error) {
+	output := &getMetricDataOutput{}
+	req := c.NewRequest(&request.Operation{
+		Name:       "GetMetricData",
+		HTTPMethod: "POST",
+		HTTPPath:   "/",
+	}, input, output)
+	req.SetContext(ctx)
+	return output, req.Send()
+}
+
+// The shapes of the GetMetricData operation, see
+// https://docs.aws.amazon.com/AmazonCloudWatch/latest/APIReference/API_GetMetricData.html.
+
+type getMetricDataInput struct {
+	_ struct{} `type:"structure"`
+
+	MetricDataQueries []*metricDataQuery `type:"list" required:"true"`
+	StartTime         *time.Time         `type:"timestamp" required:"true"`
+	EndTime           *time.Time         `type:"timestamp" required:"true"`
+	NextToken         *string            `type:"string"`
+}
+
+type metricDataQuery struct {
+	_ struct{} `type:"structure"`
+
+	ID         *string     `locationName:"Id" type:"string" required:"true"`
+	MetricStat *metricStat `type:"structure"`
+}
+
+type metricStat struct {
+	_ struct{} `type:"structure"`
+
+	Metric *metric `type:"structure" required:"true"`
+	Period *int64  `type:"integer" required:"true"`
+	Stat   *string `type:"string" required:"true"`
+}
+
+type metric struct {
+	_ struct{} `type:"structure"`
+
+	Namespace  *string      `type:"string"`
+	MetricName *string      `type:"string"`
+	Dimensions []*dimension `type:"list"`
+}
+
+type dimension struct {
+	_ struct{} `type:"structure"`
+
+	Name  *string `type:"string" required:"true"`
+	Value *string `type:"string" required:"true"`
+}
+
+type getMetricDataOutput struct {
+	_ struct{} `type:"structure"`
+
+	MetricDataResults []*metricDataResult `type:"list"`
+	NextToken         *string             `type:"string"`
+}
+
+type metricDataResult struct {
+	_ struct{} `type:"structure"`
+
+	ID         *string      `locationName:"Id" type:"string"`
+	StatusCode *string      `type:"string"`
+	Timestamps []*time.Time `type:"list"`
+	Values     []*float64   `type:"list"`
+}
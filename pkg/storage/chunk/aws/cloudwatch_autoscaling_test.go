@@ -0,0 +1,267 @@
+package aws
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/mtime"
+
+	"github.com/grafana/loki/pkg/storage/chunk"
+)
+
+const getMetricDataResponse = `<GetMetricDataResponse xmlns="http://monitoring.amazonaws.com/doc/2010-08-01/">
+  <GetMetricDataResult>
+    <MetricDataResults>
+      <member>
+        <Id>write_usage</Id>
+        <StatusCode>Complete</StatusCode>
+        <Timestamps>
+          <member>2020-09-13T12:28:00Z</member>
+          <member>2020-09-13T12:27:00Z</member>
+        </Timestamps>
+        <Values>
+          <member>1200.5</member>
+          <member>600</member>
+        </Values>
+      </member>
+    </MetricDataResults>
+    <NextToken>next</NextToken>
+  </GetMetricDataResult>
+  <ResponseMetadata>
+    <RequestId>1</RequestId>
+  </ResponseMetadata>
+</GetMetricDataResponse>`
+
+func TestCloudWatchClient(t *testing.T) {
+	var (
+		form          map[string][]string
+		authorization string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		form = r.PostForm
+		authorization = r.Header.Get("Authorization")
+		_, _ = w.Write([]byte(getMetricDataResponse))
+	}))
+	defer server.Close()
+
+	sess, err := session.NewSession(aws.NewConfig().
+		WithRegion("us-east-1").
+		WithEndpoint("http://dynamodb:8000").
+		WithCredentials(credentials.NewStaticCredentials("id", "secret", "")))
+	require.NoError(t, err)
+
+	// The endpoint of the session is the one of DynamoDB.
+	require.Equal(t, "https://monitoring.us-east-1.amazonaws.com", newCloudWatchClient(sess, "").Endpoint)
+
+	client := newCloudWatchClient(sess, server.URL)
+	start := time.Unix(1600000000, 0).UTC()
+	output, err := client.GetMetricDataWithContext(context.Background(), &getMetricDataInput{
+		MetricDataQueries: []*metricDataQuery{
+			newMetricDataQuery(cloudWatchWriteUsageID, dynamoDBNamespace, "ConsumedWriteCapacityUnits", "Sum", []*dimension{
+				{Name: aws.String("TableName"), Value: aws.String("index_1")},
+			}),
+		},
+		StartTime: aws.Time(start),
+		EndTime:   aws.Time(start.Add(time.Hour)),
+	})
+	require.NoError(t, err)
+
+	require.Contains(t, authorization, "/us-east-1/monitoring/aws4_request")
+	for k, v := range map[string]string{
+		"Action":                        "GetMetricData",
+		"Version":                       cloudWatchAPIVersion,
+		"StartTime":                     "2020-09-13T12:26:40Z",
+		"EndTime":                       "2020-09-13T13:26:40Z",
+		"MetricDataQueries.member.1.Id": cloudWatchWriteUsageID,
+		"MetricDataQueries.member.1.MetricStat.Metric.Namespace":                 dynamoDBNamespace,
+		"MetricDataQueries.member.1.MetricStat.Metric.MetricName":                "ConsumedWriteCapacityUnits",
+		"MetricDataQueries.member.1.MetricStat.Metric.Dimensions.member.1.Name":  "TableName",
+		"MetricDataQueries.member.1.MetricStat.Metric.Dimensions.member.1.Value": "index_1",
+		"MetricDataQueries.member.1.MetricStat.Period":                           "60",
+		"MetricDataQueries.member.1.MetricStat.Stat":                             "Sum",
+	} {
+		require.Equal(t, []string{v}, form[k], k)
+	}
+
+	require.Equal(t, "next", aws.StringValue(output.NextToken))
+	require.Len(t, output.MetricDataResults, 1)
+	result := output.MetricDataResults[0]
+	require.Equal(t, cloudWatchWriteUsageID, aws.StringValue(result.ID))
+	require.Equal(t, []time.Time{start.Add(80 * time.Second), start.Add(20 * time.Second)}, aws.TimeValueSlice(result.Timestamps))
+	require.Equal(t, []float64{1200.5, 600}, aws.Float64ValueSlice(result.Values))
+}
+
+// mockCloudWatch returns the rates of the tables as a single point summing them over their window.
+type mockCloudWatch struct {
+	queueLengths  []float64
+	throttleRates map[string]float64
+	usageRates    map[string]float64
+}
+
+func (m *mockCloudWatch) setResponse(queueLengths []float64, throttleRates, usageRates map[string]float64) {
+	m.queueLengths = queueLengths
+	m.throttleRates = throttleRates
+	m.usageRates = usageRates
+}
+
+func (m *mockCloudWatch) GetMetricDataWithContext(_ aws.Context, input *getMetricDataInput) (*getMetricDataOutput, error) {
+	end := aws.TimeValue(input.EndTime)
+	point := func(id string, value float64) *metricDataResult {
+		return &metricDataResult{
+			ID:         aws.String(id),
+			Timestamps: []*time.Time{aws.Time(end.Add(-time.Minute))},
+			Values:     []*float64{aws.Float64(value)},
+		}
+	}
+
+	output := &getMetricDataOutput{}
+	for _, query := range input.MetricDataQueries {
+		id := aws.StringValue(query.ID)
+		if id == cloudWatchQueueLengthID {
+			result := &metricDataResult{ID: query.ID}
+			// in decreasing order of time like CloudWatch.
+			for i := range m.queueLengths {
+				result.Timestamps = append(result.Timestamps, aws.Time(end.Add(-time.Duration(i)*time.Minute)))
+				result.Values = append(result.Values, aws.Float64(m.queueLengths[len(m.queueLengths)-1-i]))
+			}
+			output.MetricDataResults = append(output.MetricDataResults, result)
+			continue
+		}
+
+		table := aws.StringValue(query.MetricStat.Metric.Dimensions[0].Value)
+		switch id {
+		case cloudWatchWriteThrottleID:
+			output.MetricDataResults = append(output.MetricDataResults, point(id, m.throttleRates[table]*cloudWatchThrottleWindow.Seconds()))
+		case cloudWatchWriteUsageID:
+			output.MetricDataResults = append(output.MetricDataResults, point(id, m.usageRates[table]*cloudWatchWriteUsageWindow.Seconds()))
+		}
+	}
+	return output, nil
+}
+
+func TestTableManagerCloudWatchAutoScaling(t *testing.T) {
+	dynamoDB := newMockDynamoDB(0, 0)
+	mockCW := &mockCloudWatch{}
+
+	client := dynamoTableClient{
+		DynamoDB: dynamoDB,
+		autoscale: &cloudWatchAutoScaling{
+			metricsData: &metricsData{
+				cfg: MetricsAutoScalingConfig{
+					TargetQueueLen: 100000,
+					ScaleUpFactor:  1.2,
+				},
+				tableLastUpdated:     make(map[string]time.Time),
+				tableReadLastUpdated: make(map[string]time.Time),
+				throttleRates:        make(map[string]float64),
+				usageRates:           make(map[string]float64),
+				usageReadRates:       make(map[string]float64),
+				readErrorRates:       make(map[string]float64),
+			},
+			cloudWatch:     mockCW,
+			tableLastQuery: make(map[string]time.Time),
+		},
+		metrics: newMetrics(nil),
+	}
+
+	// The chunk tables override the capacity bounds of the provision config.
+	chunkTables := fixturePeriodicTableConfig(chunkTablePrefix)
+	chunkTables.AutoScaling.Write = chunk.AutoScalingOverrides{MinCapacity: 50, MaxCapacity: 220}
+	cfg := chunk.SchemaConfig{
+		Configs: []chunk.PeriodConfig{
+			{
+				IndexType:   "aws-dynamo",
+				IndexTables: fixturePeriodicTableConfig(tablePrefix),
+				ChunkTables: chunkTables,
+			},
+		},
+	}
+	tbm := chunk.TableManagerConfig{
+		CreationGracePeriod: gracePeriod,
+		IndexTables:         fixtureProvisionConfig(0, fixtureWriteScale(), chunk.AutoScalingConfig{}),
+		ChunkTables:         fixtureProvisionConfig(0, fixtureWriteScale(), chunk.AutoScalingConfig{}),
+	}
+
+	tableManager, err := chunk.NewTableManager(tbm, cfg, maxChunkAge, client, nil, nil, nil, nil)
+	require.NoError(t, err)
+
+	startTime := time.Unix(0, 0).Add(maxChunkAge).Add(gracePeriod)
+
+	mockCW.setResponse([]float64{0, 0, 0}, nil, nil)
+	test(t, client, tableManager, "Create tables",
+		startTime,
+		staticTable(0, read, write, read, write),
+	)
+
+	mockCW.setResponse([]float64{120000, 130000, 140000}, map[string]float64{"cortex_0": 10, "chunks_0": 10}, nil)
+	test(t, client, tableManager, "Building queues",
+		startTime.Add(time.Minute*10),
+		staticTable(0, read, 240, read, 220), // - scale up both tables, up to the max capacity of the chunk tables
+	)
+
+	mockCW.setResponse([]float64{0, 0, 0}, nil, map[string]float64{"cortex_0": 120, "chunks_0": 20})
+	test(t, client, tableManager, "in cooldown period",
+		startTime.Add(time.Minute*11),
+		staticTable(0, read, 240, read, 220), // - no change; in cooldown period
+	)
+
+	test(t, client, tableManager, "No queues no throttling",
+		startTime.Add(time.Minute*20),
+		staticTable(0, read, 150, read, 50), // - scale down both tables, down to the min capacity of the chunk tables
+	)
+}
+
+func TestCloudWatchAutoScalingCachesMetrics(t *testing.T) {
+	mockCW := &countingCloudWatch{}
+	c := &cloudWatchAutoScaling{
+		metricsData: &metricsData{
+			throttleRates:  make(map[string]float64),
+			usageRates:     make(map[string]float64),
+			usageReadRates: make(map[string]float64),
+			readErrorRates: make(map[string]float64),
+		},
+		cloudWatch:     mockCW,
+		tableLastQuery: make(map[string]time.Time),
+	}
+	mockCW.queueLengths = []float64{1, 2, 3}
+
+	mtime.NowForce(time.Unix(1000, 0))
+	defer mtime.NowReset()
+
+	require.NoError(t, c.update(context.Background(), "a"))
+	require.Equal(t, []int{5}, mockCW.queries)
+	require.Equal(t, []float64{1, 2, 3}, c.queueLengths)
+
+	// the queue length was just fetched, only the metrics of the new table are.
+	require.NoError(t, c.update(context.Background(), "b"))
+	require.NoError(t, c.update(context.Background(), "a"))
+	require.Equal(t, []int{5, 4}, mockCW.queries)
+
+	mtime.NowForce(time.Unix(1000, 0).Add(cachePromDataFor))
+	require.NoError(t, c.update(context.Background(), "a"))
+	require.Equal(t, []int{5, 4, 5}, mockCW.queries)
+
+	// the queue length needs three points.
+	mockCW.queueLengths = []float64{1, 2}
+	mtime.NowForce(time.Unix(1000, 0).Add(2 * cachePromDataFor))
+	require.Error(t, c.update(context.Background(), "a"))
+}
+
+// countingCloudWatch records the number of queries of each call.
+type countingCloudWatch struct {
+	mockCloudWatch
+	queries []int
+}
+
+func (m *countingCloudWatch) GetMetricDataWithContext(ctx aws.Context, input *getMetricDataInput) (*getMetricDataOutput, error) {
+	m.queries = append(m.queries, len(input.MetricDataQueries))
+	return m.mockCloudWatch.GetMetricDataWithContext(ctx, input)
+}
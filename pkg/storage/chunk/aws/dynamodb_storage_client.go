@@ -424,7 +424,7 @@ func (a dynamoDBStorageClient) getDynamoDBChunks(ctx context.Context, chunks []c
 	for _, chunk := range chunks {
 		key := a.schemaCfg.ExternalKey(chunk)
 		chunksByKey[key] = chunk
-		tableName, err := a.schemaCfg.ChunkTableFor(chunk.From)
+		tableName, err := a.schemaCfg.ChunkTableFor(chunk.UserID, chunk.From)
 		if err != nil {
 			return nil, log.Error(err)
 		}
@@ -559,7 +559,7 @@ func (a dynamoDBStorageClient) DeleteChunk(ctx context.Context, userID, chunkID
 		return err
 	}
 
-	tableName, err := a.schemaCfg.ChunkTableFor(chunkRef.From)
+	tableName, err := a.schemaCfg.ChunkTableFor(chunkRef.UserID, chunkRef.From)
 	if err != nil {
 		return err
 	}
@@ -583,7 +583,7 @@ func (a dynamoDBStorageClient) writesForChunks(chunks []chunk.Chunk) (dynamoDBWr
 		}
 		key := a.schemaCfg.ExternalKey(chunks[i])
 
-		table, err := a.schemaCfg.ChunkTableFor(chunks[i].From)
+		table, err := a.schemaCfg.ChunkTableFor(chunks[i].UserID, chunks[i].From)
 		if err != nil {
 			return nil, err
 		}
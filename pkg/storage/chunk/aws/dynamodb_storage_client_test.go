@@ -2,9 +2,11 @@ package aws
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/prometheus/common/model"
 
 	"github.com/stretchr/testify/require"
@@ -51,3 +53,43 @@ func TestChunksPartialError(t *testing.T) {
 	require.Error(t, err)
 	require.Equal(t, dynamoDBMaxReadBatchSize, len(chunksWeGot))
 }
+
+func TestTableClientExportTable(t *testing.T) {
+	dynamoDB := newMockDynamoDB(0, 0)
+	tableClient := dynamoTableClient{
+		DynamoDB: dynamoDB,
+		metrics:  newMetrics(nil),
+	}
+
+	ctx := context.Background()
+	require.NoError(t, tableClient.CreateTable(ctx, chunk.TableDesc{Name: tableName}))
+	dynamoDB.tables[tableName].items = map[string][]mockDynamoDBItem{
+		"a": {
+			{hashKey: {S: aws.String("a")}, rangeKey: {B: []byte("1")}, valueKey: {B: []byte("value")}},
+			{hashKey: {S: aws.String("a")}, rangeKey: {B: []byte("2")}},
+		},
+		"b": {
+			{hashKey: {S: aws.String("b")}, rangeKey: {B: []byte("1")}},
+		},
+	}
+
+	var entries []chunk.IndexEntry
+	require.NoError(t, tableClient.ExportTable(ctx, tableName, func(entry chunk.IndexEntry) error {
+		entries = append(entries, entry)
+		return nil
+	}))
+	require.Equal(t, []chunk.IndexEntry{
+		{TableName: tableName, HashValue: "a", RangeValue: []byte("1"), Value: []byte("value")},
+		{TableName: tableName, HashValue: "a", RangeValue: []byte("2")},
+		{TableName: tableName, HashValue: "b", RangeValue: []byte("1")},
+	}, entries)
+
+	// the export stops at the first error of the callback.
+	calls := 0
+	err := tableClient.ExportTable(ctx, tableName, func(entry chunk.IndexEntry) error {
+		calls++
+		return errors.New("stop")
+	})
+	require.EqualError(t, err, "stop")
+	require.Equal(t, 1, calls)
+}
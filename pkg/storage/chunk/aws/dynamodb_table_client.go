@@ -53,7 +53,13 @@ func NewDynamoDBTableClient(cfg DynamoDBConfig, reg prometheus.Registerer) (chun
 	}
 
 	var autoscale autoscale
-	if cfg.Metrics.URL != "" {
+	switch {
+	case cfg.Metrics.CloudWatch.Enabled:
+		autoscale, err = newCloudWatchAutoScaling(cfg)
+		if err != nil {
+			return nil, err
+		}
+	case cfg.Metrics.URL != "":
 		autoscale, err = newMetricsAutoScaling(cfg)
 		if err != nil {
 			return nil, err
@@ -212,6 +218,35 @@ func (d dynamoTableClient) DeleteTable(ctx context.Context, name string) error {
 	})
 }
 
+// ExportTable implements chunk.TableExporter. The scan is not retried since it would
+// pass the entries already read again to the callback.
+func (d dynamoTableClient) ExportTable(ctx context.Context, name string, callback func(entry chunk.IndexEntry) error) error {
+	var callbackErr error
+	err := instrument.CollectedRequest(ctx, "DynamoDB.ScanPages", d.metrics.dynamoRequestDuration, instrument.ErrorCode, func(ctx context.Context) error {
+		input := &dynamodb.ScanInput{TableName: aws.String(name)}
+		return d.DynamoDB.ScanPagesWithContext(ctx, input, func(page *dynamodb.ScanOutput, _ bool) bool {
+			for _, item := range page.Items {
+				entry := chunk.IndexEntry{
+					TableName:  name,
+					HashValue:  aws.StringValue(item[hashKey].S),
+					RangeValue: item[rangeKey].B,
+				}
+				if value, ok := item[valueKey]; ok {
+					entry.Value = value.B
+				}
+				if callbackErr = callback(entry); callbackErr != nil {
+					return false
+				}
+			}
+			return true
+		})
+	})
+	if err != nil {
+		return err
+	}
+	return callbackErr
+}
+
 func (d dynamoTableClient) DescribeTable(ctx context.Context, name string) (desc chunk.TableDesc, isActive bool, err error) {
 	var tableARN *string
 	err = d.backoffAndRetry(ctx, func(ctx context.Context) error {
@@ -50,6 +50,8 @@ type MetricsAutoScalingConfig struct {
 	UsageQuery       string  `yaml:"write_usage_query"`     // Promql query to fetch write capacity usage per table
 	ReadUsageQuery   string  `yaml:"read_usage_query"`      // Promql query to fetch read usage per table
 	ReadErrorQuery   string  `yaml:"read_error_query"`      // Promql query to fetch read errors per table
+
+	CloudWatch CloudWatchMetricsConfig `yaml:"cloudwatch"` // Fetch the metrics from CloudWatch instead of Prometheus
 }
 
 // RegisterFlags adds the flags required to config this to the given FlagSet
@@ -63,6 +65,7 @@ func (cfg *MetricsAutoScalingConfig) RegisterFlags(f *flag.FlagSet) {
 	f.StringVar(&cfg.UsageQuery, "metrics.usage-query", defaultUsageQuery, "query to fetch write capacity usage per table")
 	f.StringVar(&cfg.ReadUsageQuery, "metrics.read-usage-query", defaultReadUsageQuery, "query to fetch read capacity usage per table")
 	f.StringVar(&cfg.ReadErrorQuery, "metrics.read-error-query", defaultReadErrorQuery, "query to fetch read errors per table")
+	cfg.CloudWatch.RegisterFlags(f)
 }
 
 type metricsData struct {
@@ -104,6 +107,12 @@ func (m *metricsData) UpdateTable(ctx context.Context, current chunk.TableDesc,
 		return err
 	}
 
+	m.scaleTable(current, expected)
+	return nil
+}
+
+// scaleTable sets the provisioned capacity of the expected table from the metrics last fetched.
+func (m *metricsData) scaleTable(current chunk.TableDesc, expected *chunk.TableDesc) {
 	if expected.WriteScale.Enabled {
 		// default if no action is taken is to use the currently provisioned setting
 		expected.ProvisionedWrite = current.ProvisionedWrite
@@ -204,8 +213,6 @@ func (m *metricsData) UpdateTable(ctx context.Context, current chunk.TableDesc,
 				nil)
 		}
 	}
-
-	return nil
 }
 
 func computeScaleUp(currentValue, maxValue int64, scaleFactor float64) int64 {
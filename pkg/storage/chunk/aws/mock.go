@@ -246,6 +246,34 @@ func (m *mockDynamoDBClient) QueryPagesWithContext(ctx aws.Context, input *dynam
 	return nil
 }
 
+func (m *mockDynamoDBClient) ScanPagesWithContext(_ aws.Context, input *dynamodb.ScanInput, fn func(*dynamodb.ScanOutput, bool) bool, _ ...request.Option) error {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+
+	table, ok := m.tables[*input.TableName]
+	if !ok {
+		return fmt.Errorf("table not found")
+	}
+
+	hashValues := make([]string, 0, len(table.items))
+	for hashValue := range table.items {
+		hashValues = append(hashValues, hashValue)
+	}
+	sort.Strings(hashValues)
+
+	// one page per hash value.
+	for i, hashValue := range hashValues {
+		page := &dynamodb.ScanOutput{}
+		for _, item := range table.items[hashValue] {
+			page.Items = append(page.Items, item)
+		}
+		if !fn(page, i == len(hashValues)-1) {
+			break
+		}
+	}
+	return nil
+}
+
 type dynamoDBMockRequest struct {
 	result interface{}
 	err    error
@@ -17,11 +17,14 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
 	"github.com/grafana/dskit/backoff"
 	"github.com/grafana/dskit/flagext"
 	"github.com/minio/minio-go/v7/pkg/signer"
@@ -44,6 +47,15 @@ const (
 var (
 	supportedSignatureVersions     = []string{SignatureVersionV4, SignatureVersionV2}
 	errUnsupportedSignatureVersion = errors.New("unsupported signature version")
+	errSSEKMSSignatureVersion      = errors.New("SSE-KMS requires the v4 signature version")
+	errRoleARNRequired             = errors.New("the role ARN is required by the external ID and the web identity token file")
+)
+
+const (
+	// stsDefaultRegion is used to assume roles when the region of the S3 client isn't set.
+	stsDefaultRegion = "us-east-1"
+	// stsExpiryWindow is how long before they expire the credentials of an assumed role are refreshed.
+	stsExpiryWindow = 5 * time.Minute
 )
 
 var s3RequestDuration = instrument.NewHistogramCollector(prometheus.NewHistogramVec(prometheus.HistogramOpts{
@@ -66,11 +78,19 @@ type S3Config struct {
 	S3               flagext.URLValue
 	S3ForcePathStyle bool
 
-	BucketNames      string
-	Endpoint         string              `yaml:"endpoint"`
-	Region           string              `yaml:"region"`
-	AccessKeyID      string              `yaml:"access_key_id"`
-	SecretAccessKey  string              `yaml:"secret_access_key"`
+	BucketNames     string
+	Endpoint        string `yaml:"endpoint"`
+	Region          string `yaml:"region"`
+	AccessKeyID     string `yaml:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key"`
+
+	// Role assumed with the credentials of the config or of the environment, or with a web identity token.
+	RoleARN              string        `yaml:"role_arn"`
+	ExternalID           string        `yaml:"external_id"`
+	RoleSessionName      string        `yaml:"role_session_name"`
+	WebIdentityTokenFile string        `yaml:"web_identity_token_file"`
+	AssumeRoleDuration   time.Duration `yaml:"assume_role_duration"`
+
 	Insecure         bool                `yaml:"insecure"`
 	SSEEncryption    bool                `yaml:"sse_encryption"`
 	HTTPConfig       HTTPConfig          `yaml:"http_config"`
@@ -78,6 +98,9 @@ type S3Config struct {
 	SSEConfig        bucket_s3.SSEConfig `yaml:"sse"`
 	BackoffConfig    backoff.Config      `yaml:"backoff_config"`
 
+	// KMS keys of the tenants whose chunks are encrypted with their own key, by tenant.
+	SSETenantKMSKeyIDs map[string]string `yaml:"sse_tenant_kms_key_ids"`
+
 	Inject InjectRequestMiddleware `yaml:"-"`
 }
 
@@ -99,12 +122,17 @@ func (cfg *S3Config) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
 	f.Var(&cfg.S3, prefix+"s3.url", "S3 endpoint URL with escaped Key and Secret encoded. "+
 		"If only region is specified as a host, proper endpoint will be deduced. Use inmemory:///<bucket-name> to use a mock in-memory implementation.")
 	f.BoolVar(&cfg.S3ForcePathStyle, prefix+"s3.force-path-style", false, "Set this to `true` to force the request to use path-style addressing.")
-	f.StringVar(&cfg.BucketNames, prefix+"s3.buckets", "", "Comma separated list of bucket names to evenly distribute chunks over. Overrides any buckets specified in s3.url flag")
+	f.StringVar(&cfg.BucketNames, prefix+"s3.buckets", "", "Comma separated list of bucket names to evenly distribute chunks over, by the hash of their key, e.g. to work around the request rate limits of a bucket. The list must not change once objects are written, as their bucket would change. Overrides any buckets specified in s3.url flag")
 
 	f.StringVar(&cfg.Endpoint, prefix+"s3.endpoint", "", "S3 Endpoint to connect to.")
 	f.StringVar(&cfg.Region, prefix+"s3.region", "", "AWS region to use.")
 	f.StringVar(&cfg.AccessKeyID, prefix+"s3.access-key-id", "", "AWS Access Key ID")
 	f.StringVar(&cfg.SecretAccessKey, prefix+"s3.secret-access-key", "", "AWS Secret Access Key")
+	f.StringVar(&cfg.RoleARN, prefix+"s3.role-arn", "", "ARN of the IAM role to assume with STS, using the access key or the credentials of the environment, or the web identity token file.")
+	f.StringVar(&cfg.ExternalID, prefix+"s3.external-id", "", "External ID required by the trust policy of the IAM role to assume.")
+	f.StringVar(&cfg.RoleSessionName, prefix+"s3.role-session-name", "loki", "Name of the sessions of the assumed IAM role.")
+	f.StringVar(&cfg.WebIdentityTokenFile, prefix+"s3.web-identity-token-file", "", "Path to the OIDC token assuming the IAM role with a web identity, e.g. the token of the Kubernetes service account with IAM roles for service accounts.")
+	f.DurationVar(&cfg.AssumeRoleDuration, prefix+"s3.assume-role-duration", time.Hour, "How long the credentials of the assumed IAM role are valid. They are refreshed before they expire.")
 	f.BoolVar(&cfg.Insecure, prefix+"s3.insecure", false, "Disable https on s3 connection.")
 
 	// TODO Remove in Cortex 1.10.0
@@ -128,6 +156,21 @@ func (cfg *S3Config) Validate() error {
 	if !util.StringsContain(supportedSignatureVersions, cfg.SignatureVersion) {
 		return errUnsupportedSignatureVersion
 	}
+	if err := cfg.SSEConfig.Validate(); err != nil {
+		return err
+	}
+	for tenant, keyID := range cfg.SSETenantKMSKeyIDs {
+		if keyID == "" {
+			return fmt.Errorf("empty KMS key id for the tenant %s", tenant)
+		}
+	}
+	if cfg.RoleARN == "" && (cfg.ExternalID != "" || cfg.WebIdentityTokenFile != "") {
+		return errRoleARNRequired
+	}
+	// KMS encrypted objects can only be written and read with signature v4 requests.
+	if cfg.SignatureVersion == SignatureVersionV2 && (cfg.SSEConfig.Type == bucket_s3.SSEKMS || len(cfg.SSETenantKMSKeyIDs) > 0) {
+		return errSSEKMSSignatureVersion
+	}
 	return nil
 }
 
@@ -138,6 +181,9 @@ type S3ObjectClient struct {
 	S3          s3iface.S3API
 	hedgedS3    s3iface.S3API
 	sseConfig   *SSEParsedConfig
+
+	// SSE configs of the tenants with their own KMS key, by tenant and by tenant prefix of schema v13+.
+	tenantSSEConfigs map[string]*SSEParsedConfig
 }
 
 // NewS3ObjectClient makes a new S3-backed ObjectClient.
@@ -159,13 +205,18 @@ func NewS3ObjectClient(cfg S3Config, hedgingCfg hedging.Config) (*S3ObjectClient
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to build SSE config")
 	}
+	tenantSSECfgs, err := buildTenantSSEParsedConfigs(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build tenant SSE configs")
+	}
 
 	client := S3ObjectClient{
-		cfg:         cfg,
-		S3:          s3Client,
-		hedgedS3:    s3ClientHedging,
-		bucketNames: bucketNames,
-		sseConfig:   sseCfg,
+		cfg:              cfg,
+		S3:               s3Client,
+		hedgedS3:         s3ClientHedging,
+		bucketNames:      bucketNames,
+		sseConfig:        sseCfg,
+		tenantSSEConfigs: tenantSSECfgs,
 	}
 	return &client, nil
 }
@@ -185,6 +236,26 @@ func buildSSEParsedConfig(cfg S3Config) (*SSEParsedConfig, error) {
 	return nil, nil
 }
 
+// buildTenantSSEParsedConfigs builds the SSE-KMS configs of the tenants with their own KMS key,
+// sharing the KMS encryption context of the SSE config. They are keyed by the first directory of
+// the keys of the objects of the tenant: the tenant before schema v13, its hash from schema v13.
+func buildTenantSSEParsedConfigs(cfg S3Config) (map[string]*SSEParsedConfig, error) {
+	configs := make(map[string]*SSEParsedConfig, 2*len(cfg.SSETenantKMSKeyIDs))
+	for tenant, keyID := range cfg.SSETenantKMSKeyIDs {
+		sseCfg, err := NewSSEParsedConfig(bucket_s3.SSEConfig{
+			Type:                 bucket_s3.SSEKMS,
+			KMSKeyID:             keyID,
+			KMSEncryptionContext: cfg.SSEConfig.KMSEncryptionContext,
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "tenant %s", tenant)
+		}
+		configs[tenant] = sseCfg
+		configs[strings.TrimSuffix(chunk.SchemaConfig{}.TenantPrefix(tenant), "/")] = sseCfg
+	}
+	return configs, nil
+}
+
 func v2SignRequestHandler(cfg S3Config) request.NamedHandler {
 	return request.NamedHandler{
 		Name: "v2.SignRequestHandler",
@@ -247,6 +318,14 @@ func buildS3Client(cfg S3Config, hedgingCfg hedging.Config, hedging bool) (*s3.S
 		s3Config = s3Config.WithCredentials(creds)
 	}
 
+	if cfg.RoleARN != "" {
+		svc, err := newSTSClient(s3Config)
+		if err != nil {
+			return nil, err
+		}
+		s3Config = s3Config.WithCredentials(assumeRoleCredentials(cfg, svc))
+	}
+
 	tlsConfig := &tls.Config{
 		InsecureSkipVerify: cfg.HTTPConfig.InsecureSkipVerify,
 	}
@@ -310,6 +389,43 @@ func buildS3Client(cfg S3Config, hedgingCfg hedging.Config, hedging bool) (*s3.S
 	return s3Client, nil
 }
 
+// newSTSClient makes the STS client assuming the roles, with the credentials and the region of the S3 config
+// but without its endpoint.
+func newSTSClient(s3Config *aws.Config) (stsiface.STSAPI, error) {
+	stsConfig := aws.NewConfig().WithCredentials(s3Config.Credentials)
+	if region := aws.StringValue(s3Config.Region); region != "" && region != "dummy" {
+		stsConfig = stsConfig.WithRegion(region)
+	}
+	sess, err := session.NewSession(stsConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create new sts session")
+	}
+	if aws.StringValue(sess.Config.Region) == "" {
+		sess.Config.Region = aws.String(stsDefaultRegion)
+	}
+	return sts.New(sess), nil
+}
+
+// assumeRoleCredentials returns the credentials of the role of the config, assumed with the web identity token
+// if set. They are refreshed before they expire.
+func assumeRoleCredentials(cfg S3Config, svc stsiface.STSAPI) *credentials.Credentials {
+	if cfg.WebIdentityTokenFile != "" {
+		return credentials.NewCredentials(stscreds.NewWebIdentityRoleProviderWithOptions(svc, cfg.RoleARN, cfg.RoleSessionName,
+			stscreds.FetchTokenPath(cfg.WebIdentityTokenFile), func(p *stscreds.WebIdentityRoleProvider) {
+				p.Duration = cfg.AssumeRoleDuration
+				p.ExpiryWindow = stsExpiryWindow
+			}))
+	}
+	return stscreds.NewCredentialsWithClient(svc, cfg.RoleARN, func(p *stscreds.AssumeRoleProvider) {
+		p.RoleSessionName = cfg.RoleSessionName
+		p.Duration = cfg.AssumeRoleDuration
+		p.ExpiryWindow = stsExpiryWindow
+		if cfg.ExternalID != "" {
+			p.ExternalID = aws.String(cfg.ExternalID)
+		}
+	})
+}
+
 func buckets(cfg S3Config) ([]string, error) {
 	// bucketnames
 	var bucketNames []string
@@ -318,7 +434,19 @@ func buckets(cfg S3Config) ([]string, error) {
 	}
 
 	if cfg.BucketNames != "" {
-		bucketNames = strings.Split(cfg.BucketNames, ",") // comma separated list of bucket names
+		bucketNames = bucketNames[:0]
+		// comma separated list of bucket names, the objects being sharded across them by the hash of their key.
+		for _, name := range strings.Split(cfg.BucketNames, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				return nil, errors.New("empty bucket name in the list of buckets")
+			}
+			// a bucket listed twice would get twice the objects, which would be listed twice.
+			if util.StringsContain(bucketNames, name) {
+				return nil, fmt.Errorf("duplicate bucket name %s in the list of buckets", name)
+			}
+			bucketNames = append(bucketNames, name)
+		}
 	}
 
 	if len(bucketNames) == 0 {
@@ -358,6 +486,16 @@ func (a *S3ObjectClient) bucketFromKey(key string) string {
 
 // GetObject returns a reader and the size for the specified object key from the configured S3 bucket.
 func (a *S3ObjectClient) GetObject(ctx context.Context, objectKey string) (io.ReadCloser, int64, error) {
+	return a.getObject(ctx, "S3.GetObject", objectKey, nil)
+}
+
+// GetObjectRange returns a reader for length bytes from offset of the specified object key from the configured S3 bucket.
+func (a *S3ObjectClient) GetObjectRange(ctx context.Context, objectKey string, offset, length int64) (io.ReadCloser, error) {
+	rc, _, err := a.getObject(ctx, "S3.GetObjectRange", objectKey, aws.String(fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)))
+	return rc, err
+}
+
+func (a *S3ObjectClient) getObject(ctx context.Context, operation, objectKey string, byteRange *string) (io.ReadCloser, int64, error) {
 	var resp *s3.GetObjectOutput
 
 	// Map the key into a bucket
@@ -369,11 +507,12 @@ func (a *S3ObjectClient) GetObject(ctx context.Context, objectKey string) (io.Re
 		if ctx.Err() != nil {
 			return nil, 0, errors.Wrap(ctx.Err(), "ctx related error during s3 getObject")
 		}
-		err = instrument.CollectedRequest(ctx, "S3.GetObject", s3RequestDuration, instrument.ErrorCode, func(ctx context.Context) error {
+		err = instrument.CollectedRequest(ctx, operation, s3RequestDuration, instrument.ErrorCode, func(ctx context.Context) error {
 			var requestErr error
 			resp, requestErr = a.hedgedS3.GetObjectWithContext(ctx, &s3.GetObjectInput{
 				Bucket: aws.String(bucket),
 				Key:    aws.String(objectKey),
+				Range:  byteRange,
 			})
 			return requestErr
 		})
@@ -398,10 +537,10 @@ func (a *S3ObjectClient) PutObject(ctx context.Context, objectKey string, object
 			Key:    aws.String(objectKey),
 		}
 
-		if a.sseConfig != nil {
-			putObjectInput.ServerSideEncryption = aws.String(a.sseConfig.ServerSideEncryption)
-			putObjectInput.SSEKMSKeyId = a.sseConfig.KMSKeyID
-			putObjectInput.SSEKMSEncryptionContext = a.sseConfig.KMSEncryptionContext
+		if sseConfig := a.sseConfigFor(objectKey); sseConfig != nil {
+			putObjectInput.ServerSideEncryption = aws.String(sseConfig.ServerSideEncryption)
+			putObjectInput.SSEKMSKeyId = sseConfig.KMSKeyID
+			putObjectInput.SSEKMSEncryptionContext = sseConfig.KMSEncryptionContext
 		}
 
 		_, err := a.S3.PutObjectWithContext(ctx, putObjectInput)
@@ -409,10 +548,23 @@ func (a *S3ObjectClient) PutObject(ctx context.Context, objectKey string, object
 	})
 }
 
+// sseConfigFor returns the SSE config of an object. The keys of the chunks start with their tenant,
+// or with its hash from schema v13 as the keys of the containers of chunks.
+func (a *S3ObjectClient) sseConfigFor(objectKey string) *SSEParsedConfig {
+	if i := strings.IndexByte(objectKey, '/'); i > 0 {
+		if sseConfig, ok := a.tenantSSEConfigs[objectKey[:i]]; ok {
+			return sseConfig
+		}
+	}
+	return a.sseConfig
+}
+
 // List implements chunk.ObjectClient.
 func (a *S3ObjectClient) List(ctx context.Context, prefix, delimiter string) ([]chunk.StorageObject, []chunk.StorageCommonPrefix, error) {
 	var storageObjects []chunk.StorageObject
 	var commonPrefixes []chunk.StorageCommonPrefix
+	// the common prefixes of the keys sharded across the buckets are returned by several buckets.
+	seenPrefixes := map[chunk.StorageCommonPrefix]struct{}{}
 
 	for i := range a.bucketNames {
 		err := instrument.CollectedRequest(ctx, "S3.List", s3RequestDuration, instrument.ErrorCode, func(ctx context.Context) error {
@@ -436,7 +588,12 @@ func (a *S3ObjectClient) List(ctx context.Context, prefix, delimiter string) ([]
 				}
 
 				for _, commonPrefix := range output.CommonPrefixes {
-					commonPrefixes = append(commonPrefixes, chunk.StorageCommonPrefix(aws.StringValue(commonPrefix.Prefix)))
+					prefix := chunk.StorageCommonPrefix(aws.StringValue(commonPrefix.Prefix))
+					if _, ok := seenPrefixes[prefix]; ok {
+						continue
+					}
+					seenPrefixes[prefix] = struct{}{}
+					commonPrefixes = append(commonPrefixes, prefix)
 				}
 
 				if output.IsTruncated == nil || !*output.IsTruncated {
@@ -6,17 +6,33 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/sts"
 	"github.com/grafana/dskit/backoff"
+	"github.com/grafana/dskit/flagext"
+	"github.com/prometheus/common/model"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/atomic"
 
+	"github.com/grafana/loki/pkg/logproto"
+	bucket_s3 "github.com/grafana/loki/pkg/storage/bucket/s3"
+	"github.com/grafana/loki/pkg/storage/chunk"
 	"github.com/grafana/loki/pkg/storage/chunk/hedging"
 )
 
@@ -148,3 +164,287 @@ func Test_Hedging(t *testing.T) {
 		})
 	}
 }
+
+func Test_TenantSSEKMSKeys(t *testing.T) {
+	headers := make(chan http.Header, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header.Clone()
+	}))
+	defer ts.Close()
+
+	client, err := NewS3ObjectClient(S3Config{
+		Endpoint:         ts.URL,
+		BucketNames:      "buck-o",
+		S3ForcePathStyle: true,
+		Insecure:         true,
+		AccessKeyID:      "key",
+		SecretAccessKey:  "secret",
+		SSEConfig: bucket_s3.SSEConfig{
+			Type:                 bucket_s3.SSEKMS,
+			KMSKeyID:             "default-key",
+			KMSEncryptionContext: `{"service":"loki"}`,
+		},
+		SSETenantKMSKeyIDs: map[string]string{"regulated": "regulated-key"},
+	}, hedging.Config{})
+	require.NoError(t, err)
+
+	schemaCfg := chunk.SchemaConfig{Configs: []chunk.PeriodConfig{
+		{From: chunk.DayTime{Time: 0}, Schema: "v13"},
+		{From: chunk.DayTime{Time: model.TimeFromUnix(86400)}, Schema: "v14"},
+	}}
+	chunkKey := func(userID string, from model.Time) string {
+		return schemaCfg.ExternalKey(chunk.Chunk{
+			ChunkRef: logproto.ChunkRef{UserID: userID, Fingerprint: 1, From: from, Through: from.Add(time.Hour), Checksum: 1},
+			Encoding: 1,
+		})
+	}
+	for _, tc := range []struct {
+		key      string
+		expected string
+	}{
+		{"regulated/chunk", "regulated-key"},
+		{"other/chunk", "default-key"},
+		{"regulated", "default-key"},
+		{chunkKey("regulated", 0), "regulated-key"},
+		{chunkKey("regulated", model.TimeFromUnix(86400)), "regulated-key"},
+		{chunkKey("other", model.TimeFromUnix(86400)), "default-key"},
+		{schemaCfg.ContainerKey("regulated", "1"), "regulated-key"},
+		{schemaCfg.ContainerKey("other", "1"), "default-key"},
+	} {
+		require.NoError(t, client.PutObject(context.Background(), tc.key, bytes.NewReader([]byte("bar"))))
+		header := <-headers
+		require.Equal(t, "aws:kms", header.Get("X-Amz-Server-Side-Encryption"), tc.key)
+		require.Equal(t, tc.expected, header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"), tc.key)
+		require.NotEmpty(t, header.Get("X-Amz-Server-Side-Encryption-Context"), tc.key)
+	}
+}
+
+func TestS3Config_Validate(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		cfg      S3Config
+		expected error
+	}{
+		{
+			name: "tenant kms keys",
+			cfg:  S3Config{SignatureVersion: SignatureVersionV4, SSETenantKMSKeyIDs: map[string]string{"tenant": "key"}},
+		},
+		{
+			name:     "unsupported signature version",
+			cfg:      S3Config{SignatureVersion: "v3"},
+			expected: errUnsupportedSignatureVersion,
+		},
+		{
+			name:     "empty tenant kms key",
+			cfg:      S3Config{SignatureVersion: SignatureVersionV4, SSETenantKMSKeyIDs: map[string]string{"tenant": ""}},
+			expected: errors.New("empty KMS key id for the tenant tenant"),
+		},
+		{
+			name:     "sse-kms with signature v2",
+			cfg:      S3Config{SignatureVersion: SignatureVersionV2, SSEConfig: bucket_s3.SSEConfig{Type: bucket_s3.SSEKMS, KMSKeyID: "key"}},
+			expected: errSSEKMSSignatureVersion,
+		},
+		{
+			name:     "tenant kms keys with signature v2",
+			cfg:      S3Config{SignatureVersion: SignatureVersionV2, SSETenantKMSKeyIDs: map[string]string{"tenant": "key"}},
+			expected: errSSEKMSSignatureVersion,
+		},
+		{
+			name: "assumed role",
+			cfg:  S3Config{SignatureVersion: SignatureVersionV4, RoleARN: "arn:aws:iam::123456789012:role/loki", ExternalID: "id"},
+		},
+		{
+			name:     "external id without role",
+			cfg:      S3Config{SignatureVersion: SignatureVersionV4, ExternalID: "id"},
+			expected: errRoleARNRequired,
+		},
+		{
+			name:     "web identity token without role",
+			cfg:      S3Config{SignatureVersion: SignatureVersionV4, WebIdentityTokenFile: "token"},
+			expected: errRoleARNRequired,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.cfg.Validate()
+			if tc.expected == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tc.expected.Error())
+		})
+	}
+}
+
+func Test_AssumeRoleCredentials(t *testing.T) {
+	var requests []url.Values
+	expiration := time.Now().Add(time.Hour).UTC()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		requests = append(requests, r.Form)
+		action := r.Form.Get("Action")
+		fmt.Fprintf(w, `<%[1]sResponse><%[1]sResult><Credentials>
+<AccessKeyId>key-%[2]d</AccessKeyId><SecretAccessKey>secret</SecretAccessKey><SessionToken>token</SessionToken>
+<Expiration>%[3]s</Expiration></Credentials></%[1]sResult></%[1]sResponse>`, action, len(requests), expiration.Format(time.RFC3339))
+	}))
+	defer server.Close()
+
+	sess, err := session.NewSession(aws.NewConfig().
+		WithEndpoint(server.URL).
+		WithRegion("eu-west-1").
+		WithCredentials(credentials.NewStaticCredentials("id", "secret", "")))
+	require.NoError(t, err)
+	svc := sts.New(sess)
+
+	cfg := S3Config{
+		RoleARN:            "arn:aws:iam::123456789012:role/loki",
+		ExternalID:         "external",
+		RoleSessionName:    "loki",
+		AssumeRoleDuration: time.Hour,
+	}
+	creds := assumeRoleCredentials(cfg, svc)
+	value, err := creds.Get()
+	require.NoError(t, err)
+	require.Equal(t, "key-1", value.AccessKeyID)
+	require.Equal(t, "token", value.SessionToken)
+	require.Equal(t, "AssumeRole", requests[0].Get("Action"))
+	require.Equal(t, cfg.RoleARN, requests[0].Get("RoleArn"))
+	require.Equal(t, "external", requests[0].Get("ExternalId"))
+	require.Equal(t, "3600", requests[0].Get("DurationSeconds"))
+
+	// the credentials are cached until they are about to expire.
+	_, err = creds.Get()
+	require.NoError(t, err)
+	require.Len(t, requests, 1)
+	creds.Expire()
+	value, err = creds.Get()
+	require.NoError(t, err)
+	require.Equal(t, "key-2", value.AccessKeyID)
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("oidc-token"), 0600))
+	cfg.WebIdentityTokenFile = tokenFile
+	value, err = assumeRoleCredentials(cfg, svc).Get()
+	require.NoError(t, err)
+	require.Equal(t, "key-3", value.AccessKeyID)
+	require.Equal(t, "AssumeRoleWithWebIdentity", requests[2].Get("Action"))
+	require.Equal(t, "oidc-token", requests[2].Get("WebIdentityToken"))
+	require.Equal(t, "loki", requests[2].Get("RoleSessionName"))
+}
+
+func Test_Buckets(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		cfg         S3Config
+		expected    []string
+		expectedErr string
+	}{
+		{
+			name:     "bucket of the url",
+			cfg:      S3Config{S3: flagext.URLValue{URL: &url.URL{Path: "/bucket"}}},
+			expected: []string{"bucket"},
+		},
+		{
+			name:     "list of buckets overriding the url",
+			cfg:      S3Config{S3: flagext.URLValue{URL: &url.URL{Path: "/bucket"}}, BucketNames: "bucket-1, bucket-2 ,bucket-3"},
+			expected: []string{"bucket-1", "bucket-2", "bucket-3"},
+		},
+		{
+			name:        "no bucket",
+			expectedErr: "at least one bucket name must be specified",
+		},
+		{
+			name:        "empty bucket name",
+			cfg:         S3Config{BucketNames: "bucket-1,,bucket-2"},
+			expectedErr: "empty bucket name in the list of buckets",
+		},
+		{
+			name:        "duplicate bucket name",
+			cfg:         S3Config{BucketNames: "bucket-1,bucket-2,bucket-1"},
+			expectedErr: "duplicate bucket name bucket-1 in the list of buckets",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			bucketNames, err := buckets(tc.cfg)
+			if tc.expectedErr != "" {
+				require.EqualError(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, bucketNames)
+		})
+	}
+}
+
+// bucketsMockS3 serves the objects of several buckets.
+type bucketsMockS3 struct {
+	s3iface.S3API
+
+	objects map[string]map[string][]byte
+}
+
+func (m *bucketsMockS3) PutObjectWithContext(_ aws.Context, input *s3.PutObjectInput, _ ...request.Option) (*s3.PutObjectOutput, error) {
+	buf, err := ioutil.ReadAll(input.Body)
+	if err != nil {
+		return nil, err
+	}
+	bucket := aws.StringValue(input.Bucket)
+	if m.objects[bucket] == nil {
+		m.objects[bucket] = map[string][]byte{}
+	}
+	m.objects[bucket][aws.StringValue(input.Key)] = buf
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (m *bucketsMockS3) ListObjectsV2WithContext(_ aws.Context, input *s3.ListObjectsV2Input, _ ...request.Option) (*s3.ListObjectsV2Output, error) {
+	output := &s3.ListObjectsV2Output{}
+	prefixes := map[string]struct{}{}
+	for key := range m.objects[aws.StringValue(input.Bucket)] {
+		if !strings.HasPrefix(key, aws.StringValue(input.Prefix)) {
+			continue
+		}
+		rest := strings.TrimPrefix(key, aws.StringValue(input.Prefix))
+		if i := strings.Index(rest, aws.StringValue(input.Delimiter)); aws.StringValue(input.Delimiter) != "" && i >= 0 {
+			prefix := aws.StringValue(input.Prefix) + rest[:i+1]
+			if _, ok := prefixes[prefix]; !ok {
+				prefixes[prefix] = struct{}{}
+				output.CommonPrefixes = append(output.CommonPrefixes, &s3.CommonPrefix{Prefix: aws.String(prefix)})
+			}
+			continue
+		}
+		output.Contents = append(output.Contents, &s3.Object{Key: aws.String(key), LastModified: aws.Time(time.Now())})
+	}
+	return output, nil
+}
+
+func Test_BucketSharding(t *testing.T) {
+	mock := &bucketsMockS3{objects: map[string]map[string][]byte{}}
+	client := &S3ObjectClient{
+		S3:          mock,
+		hedgedS3:    mock,
+		bucketNames: []string{"bucket-1", "bucket-2", "bucket-3"},
+	}
+
+	var keys []string
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("index/table_%d/file_%d", i%2, i)
+		keys = append(keys, key)
+		require.NoError(t, client.PutObject(context.Background(), key, bytes.NewReader([]byte("data"))))
+	}
+
+	// the objects are spread across all the buckets, and always map to the same one.
+	for _, bucket := range client.bucketNames {
+		require.NotEmpty(t, mock.objects[bucket], bucket)
+	}
+	for _, key := range keys {
+		require.Contains(t, mock.objects[client.bucketFromKey(key)], key)
+	}
+
+	objects, prefixes, err := client.List(context.Background(), "index/", "/")
+	require.NoError(t, err)
+	require.Empty(t, objects)
+	require.ElementsMatch(t, []chunk.StorageCommonPrefix{"index/table_0/", "index/table_1/"}, prefixes)
+
+	objects, _, err = client.List(context.Background(), "index/table_0/", "/")
+	require.NoError(t, err)
+	require.Len(t, objects, 50)
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/Azure/azure-pipeline-go/pipeline"
 	"github.com/Azure/azure-storage-blob-go/azblob"
 	"github.com/Azure/go-autorest/autorest/adal"
+	"github.com/go-kit/log/level"
 	"github.com/grafana/dskit/flagext"
 	"github.com/mattn/go-ieproxy"
 	"github.com/prometheus/client_golang/prometheus"
@@ -28,6 +29,9 @@ import (
 )
 
 const (
+	// tokenRefreshRetryDelay is the time to wait before refreshing again a managed identity token after a failure.
+	tokenRefreshRetryDelay = 30 * time.Second
+
 	// Environment
 	azureGlobal       = "AzureGlobal"
 	azureChinaCloud   = "AzureChinaCloud"
@@ -94,6 +98,8 @@ type BlobStorageConfig struct {
 	MinRetryDelay      time.Duration  `yaml:"min_retry_delay"`
 	MaxRetryDelay      time.Duration  `yaml:"max_retry_delay"`
 	UseManagedIdentity bool           `yaml:"use_managed_identity"`
+	UserAssignedID     string         `yaml:"user_assigned_id"`
+	SASToken           flagext.Secret `yaml:"sas_token"`
 }
 
 // RegisterFlags adds the flags required to config this to the given FlagSet
@@ -115,6 +121,8 @@ func (c *BlobStorageConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagS
 	f.DurationVar(&c.MinRetryDelay, prefix+"azure.min-retry-delay", 10*time.Millisecond, "Minimum time to wait before retrying a request.")
 	f.DurationVar(&c.MaxRetryDelay, prefix+"azure.max-retry-delay", 500*time.Millisecond, "Maximum time to wait before retrying a request.")
 	f.BoolVar(&c.UseManagedIdentity, prefix+"azure.use-managed-identity", false, "Use Managed Identity or not.")
+	f.StringVar(&c.UserAssignedID, prefix+"azure.user-assigned-id", "", "Client ID of the user assigned identity to authenticate with when using Managed Identity. The system assigned identity is used if empty.")
+	f.Var(&c.SASToken, prefix+"azure.sas-token", "Shared access signature token authorizing the requests, used instead of the account key.")
 }
 
 type BlobStorageMetrics struct {
@@ -206,7 +214,7 @@ func (b *BlobStorage) GetObject(ctx context.Context, objectKey string) (io.ReadC
 	)
 	err := instrument.CollectedRequest(ctx, "azure.GetObject", instrument.NewHistogramCollector(b.metrics.requestDuration), instrument.ErrorCode, func(ctx context.Context) error {
 		var err error
-		rc, size, err = b.getObject(ctx, objectKey)
+		rc, size, err = b.getObject(ctx, objectKey, 0, azblob.CountToEnd)
 		return err
 	})
 	b.metrics.egressBytesTotal.Add(float64(size))
@@ -219,14 +227,38 @@ func (b *BlobStorage) GetObject(ctx context.Context, objectKey string) (io.ReadC
 	return chunk_util.NewReadCloserWithContextCancelFunc(rc, cancel), size, nil
 }
 
-func (b *BlobStorage) getObject(ctx context.Context, objectKey string) (rc io.ReadCloser, size int64, err error) {
+// GetObjectRange returns a reader for length bytes from offset of the specified object key.
+func (b *BlobStorage) GetObjectRange(ctx context.Context, objectKey string, offset, length int64) (io.ReadCloser, error) {
+	var cancel context.CancelFunc = func() {}
+	if b.cfg.RequestTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, b.cfg.RequestTimeout)
+	}
+
+	var (
+		size int64
+		rc   io.ReadCloser
+	)
+	err := instrument.CollectedRequest(ctx, "azure.GetObjectRange", instrument.NewHistogramCollector(b.metrics.requestDuration), instrument.ErrorCode, func(ctx context.Context) error {
+		var err error
+		rc, size, err = b.getObject(ctx, objectKey, offset, length)
+		return err
+	})
+	b.metrics.egressBytesTotal.Add(float64(size))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return chunk_util.NewReadCloserWithContextCancelFunc(rc, cancel), nil
+}
+
+func (b *BlobStorage) getObject(ctx context.Context, objectKey string, offset, count int64) (rc io.ReadCloser, size int64, err error) {
 	blockBlobURL, err := b.getBlobURL(objectKey, true)
 	if err != nil {
 		return nil, 0, err
 	}
 
 	// Request access to the blob
-	downloadResponse, err := blockBlobURL.Download(ctx, 0, azblob.CountToEnd, azblob.BlobAccessConditions{}, false, noClientKey)
+	downloadResponse, err := blockBlobURL.Download(ctx, offset, count, azblob.BlobAccessConditions{}, false, noClientKey)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -258,6 +290,7 @@ func (b *BlobStorage) getBlobURL(blobID string, hedging bool) (azblob.BlockBlobU
 	if err != nil {
 		return azblob.BlockBlobURL{}, err
 	}
+	u.RawQuery = b.sasQuery()
 	pipeline := b.pipeline
 	if hedging {
 		pipeline = b.hedgingPipeline
@@ -271,10 +304,19 @@ func (b *BlobStorage) buildContainerURL() (azblob.ContainerURL, error) {
 	if err != nil {
 		return azblob.ContainerURL{}, err
 	}
+	u.RawQuery = b.sasQuery()
 
 	return azblob.NewContainerURL(*u, b.pipeline), nil
 }
 
+// sasQuery returns the query of the shared access signature, empty if none is used.
+func (b *BlobStorage) sasQuery() string {
+	if b.cfg.UseManagedIdentity {
+		return ""
+	}
+	return strings.TrimPrefix(b.cfg.SASToken.Value, "?")
+}
+
 func (b *BlobStorage) newPipeline(hedgingCfg hedging.Config, hedging bool) (pipeline.Pipeline, error) {
 	// defining the Azure Pipeline Options
 	opts := azblob.PipelineOptions{
@@ -287,7 +329,7 @@ func (b *BlobStorage) newPipeline(hedgingCfg hedging.Config, hedging bool) (pipe
 		},
 	}
 
-	credential, err := azblob.NewSharedKeyCredential(b.cfg.AccountName, b.cfg.AccountKey.Value)
+	credential, err := b.newCredential()
 	if err != nil {
 		return nil, err
 	}
@@ -314,45 +356,28 @@ func (b *BlobStorage) newPipeline(hedgingCfg hedging.Config, hedging bool) (pipe
 		})
 	}
 
-	if !b.cfg.UseManagedIdentity {
-		return azblob.NewPipeline(credential, opts), nil
-	}
+	return azblob.NewPipeline(credential, opts), nil
+}
 
-	tokenCredential, err := b.getOAuthToken()
-	if err != nil {
-		return nil, err
+func (b *BlobStorage) newCredential() (azblob.Credential, error) {
+	switch {
+	case b.cfg.UseManagedIdentity:
+		return b.getOAuthToken()
+	case b.cfg.SASToken.Value != "":
+		// the shared access signature is part of the URLs.
+		return azblob.NewAnonymousCredential(), nil
+	default:
+		return azblob.NewSharedKeyCredential(b.cfg.AccountName, b.cfg.AccountKey.Value)
 	}
-
-	return azblob.NewPipeline(*tokenCredential, opts), nil
 }
 
-func (b *BlobStorage) getOAuthToken() (*azblob.TokenCredential, error) {
+func (b *BlobStorage) getOAuthToken() (azblob.TokenCredential, error) {
 	spt, err := b.fetchMSIToken()
 	if err != nil {
 		return nil, err
 	}
 
-	// Refresh obtains a fresh token
-	err = spt.Refresh()
-	if err != nil {
-		return nil, err
-	}
-
-	tc := azblob.NewTokenCredential(spt.Token().AccessToken, func(tc azblob.TokenCredential) time.Duration {
-		err := spt.Refresh()
-		if err != nil {
-			// something went wrong, prevent the refresher from being triggered again
-			return 0
-		}
-
-		// set the new token value
-		tc.SetToken(spt.Token().AccessToken)
-
-		// get the next token slightly before the current one expires
-		return time.Until(spt.Token().Expires()) - 10*time.Second
-	})
-
-	return &tc, nil
+	return azblob.NewTokenCredential(spt.Token().AccessToken, newTokenRefresher(spt)), nil
 }
 
 func (b *BlobStorage) fetchMSIToken() (*adal.ServicePrincipalToken, error) {
@@ -360,8 +385,16 @@ func (b *BlobStorage) fetchMSIToken() (*adal.ServicePrincipalToken, error) {
 	// msiEndpoint := "http://169.254.169.254/metadata/identity/oauth2/token" for production Jobs
 	msiEndpoint, _ := adal.GetMSIVMEndpoint()
 
-	// both can be empty, systemAssignedMSI scenario
-	spt, err := adal.NewServicePrincipalTokenFromMSI(msiEndpoint, "https://storage.azure.com/")
+	var (
+		spt *adal.ServicePrincipalToken
+		err error
+	)
+	if b.cfg.UserAssignedID != "" {
+		spt, err = adal.NewServicePrincipalTokenFromMSIWithUserAssignedID(msiEndpoint, "https://storage.azure.com/", b.cfg.UserAssignedID)
+	} else {
+		// both can be empty, systemAssignedMSI scenario
+		spt, err = adal.NewServicePrincipalTokenFromMSI(msiEndpoint, "https://storage.azure.com/")
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -369,6 +402,28 @@ func (b *BlobStorage) fetchMSIToken() (*adal.ServicePrincipalToken, error) {
 	return spt, spt.Refresh()
 }
 
+type refreshableToken interface {
+	Refresh() error
+	Token() adal.Token
+}
+
+// newTokenRefresher returns a refresher updating the credential with a new token
+// slightly before the current one expires. Failed refreshes are retried.
+func newTokenRefresher(spt refreshableToken) azblob.TokenRefresher {
+	return func(tc azblob.TokenCredential) time.Duration {
+		if err := spt.Refresh(); err != nil {
+			level.Warn(log.Logger).Log("msg", "failed to refresh the Azure managed identity token", "err", err)
+			return tokenRefreshRetryDelay
+		}
+
+		// set the new token value
+		tc.SetToken(spt.Token().AccessToken)
+
+		// get the next token slightly before the current one expires
+		return time.Until(spt.Token().Expires()) - 10*time.Second
+	}
+}
+
 // List implements chunk.ObjectClient.
 func (b *BlobStorage) List(ctx context.Context, prefix, delimiter string) ([]chunk.StorageObject, []chunk.StorageCommonPrefix, error) {
 	var storageObjects []chunk.StorageObject
@@ -428,6 +483,12 @@ func (c *BlobStorageConfig) Validate() error {
 	if !util.StringsContain(supportedEnvironments, c.Environment) {
 		return fmt.Errorf("unsupported Azure blob storage environment: %s, please select one of: %s ", c.Environment, strings.Join(supportedEnvironments, ", "))
 	}
+	if c.UseManagedIdentity && c.SASToken.Value != "" {
+		return errors.New("the Azure managed identity and SAS token authentications can't be used together")
+	}
+	if c.UserAssignedID != "" && !c.UseManagedIdentity {
+		return errors.New("the Azure user assigned identity requires the managed identity authentication")
+	}
 	return nil
 }
 
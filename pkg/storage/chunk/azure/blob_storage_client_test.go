@@ -3,11 +3,17 @@ package azure
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/Azure/go-autorest/autorest/adal"
+	"github.com/grafana/dskit/flagext"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/atomic"
 
@@ -92,3 +98,66 @@ func Test_Hedging(t *testing.T) {
 		})
 	}
 }
+
+func Test_SASToken(t *testing.T) {
+	var requests []*http.Request
+	defaultClientFactory = func() *http.Client {
+		return &http.Client{
+			Transport: RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				requests = append(requests, req)
+				return nil, http.ErrNotSupported
+			}),
+		}
+	}
+	c, err := NewBlobStorage(&BlobStorageConfig{
+		ContainerName: "foo",
+		AccountName:   "account",
+		Environment:   azureGlobal,
+		MaxRetries:    1,
+		SASToken:      flagext.Secret{Value: "?sv=2020-08-04&sig=signature"},
+	}, metrics, hedging.Config{})
+	require.NoError(t, err)
+
+	_, _, _ = c.GetObject(context.Background(), "fake/chunk")
+	_, _, _ = c.List(context.Background(), "fake/", "/")
+	require.Len(t, requests, 2)
+	for _, req := range requests {
+		require.Equal(t, "signature", req.URL.Query().Get("sig"))
+		require.Equal(t, "2020-08-04", req.URL.Query().Get("sv"))
+		require.Empty(t, req.Header.Get("Authorization"))
+	}
+	require.Equal(t, "/foo/fake/chunk", requests[0].URL.Path)
+	require.Equal(t, "list", requests[1].URL.Query().Get("comp"))
+}
+
+type fakeToken struct {
+	token adal.Token
+	err   error
+}
+
+func (f *fakeToken) Refresh() error    { return f.err }
+func (f *fakeToken) Token() adal.Token { return f.token }
+
+func Test_TokenRefresher(t *testing.T) {
+	spt := &fakeToken{err: errors.New("unavailable")}
+	refresher := newTokenRefresher(spt)
+	tc := azblob.NewTokenCredential("initial", nil)
+
+	// failed refreshes are retried, the current token is kept.
+	require.Equal(t, tokenRefreshRetryDelay, refresher(tc))
+	require.Equal(t, "initial", tc.Token())
+
+	expiresOn := time.Now().Add(time.Hour)
+	spt.err = nil
+	spt.token = adal.Token{AccessToken: "refreshed", ExpiresOn: json.Number(strconv.FormatInt(expiresOn.Unix(), 10))}
+	next := refresher(tc)
+	require.Equal(t, "refreshed", tc.Token())
+	require.InDelta(t, time.Until(expiresOn)-10*time.Second, next, float64(2*time.Second))
+}
+
+func TestBlobStorageConfig_Validate(t *testing.T) {
+	require.NoError(t, (&BlobStorageConfig{Environment: azureGlobal, SASToken: flagext.Secret{Value: "sig=signature"}}).Validate())
+	require.NoError(t, (&BlobStorageConfig{Environment: azureGlobal, UseManagedIdentity: true, UserAssignedID: "id"}).Validate())
+	require.Error(t, (&BlobStorageConfig{Environment: azureGlobal, UseManagedIdentity: true, SASToken: flagext.Secret{Value: "sig=signature"}}).Validate())
+	require.Error(t, (&BlobStorageConfig{Environment: azureGlobal, UserAssignedID: "id"}).Validate())
+}
@@ -0,0 +1,273 @@
+package baidubce
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/dskit/backoff"
+	"github.com/grafana/dskit/flagext"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/weaveworks/common/instrument"
+
+	"github.com/grafana/loki/pkg/storage/chunk"
+	"github.com/grafana/loki/pkg/storage/chunk/hedging"
+	"github.com/grafana/loki/pkg/storage/chunk/restclient"
+	"github.com/grafana/loki/pkg/util/log"
+)
+
+const (
+	// signatureValidity is the duration the signatures of the requests are valid for.
+	signatureValidity = 30 * time.Minute
+
+	// bceTimeFormat is the format of the timestamps of the BCE signatures and of the BOS responses.
+	bceTimeFormat = "2006-01-02T15:04:05Z"
+)
+
+var bosRequestDuration = instrument.NewHistogramCollector(prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "loki",
+	Name:      "bos_request_duration_seconds",
+	Help:      "Time spent doing Baidu Cloud BOS requests.",
+	Buckets:   []float64{.025, .05, .1, .25, .5, 1, 2},
+}, []string{"operation", "status_code"}))
+
+func init() {
+	bosRequestDuration.Register()
+}
+
+// BOSStorageConfig is config for the Baidu Cloud BOS Chunk Client.
+type BOSStorageConfig struct {
+	Bucket          string         `yaml:"bucket_name"`
+	Endpoint        string         `yaml:"endpoint"`
+	AccessKeyID     string         `yaml:"access_key_id"`
+	SecretAccessKey flagext.Secret `yaml:"secret_access_key"`
+	Insecure        bool           `yaml:"insecure"`
+	RequestTimeout  time.Duration  `yaml:"request_timeout"`
+	BackoffConfig   backoff.Config `yaml:"backoff_config"`
+}
+
+// RegisterFlags registers flags.
+func (cfg *BOSStorageConfig) RegisterFlags(f *flag.FlagSet) {
+	cfg.RegisterFlagsWithPrefix("", f)
+}
+
+// RegisterFlagsWithPrefix registers flags with prefix.
+func (cfg *BOSStorageConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.StringVar(&cfg.Bucket, prefix+"bos.bucket-name", "", "Name of the BOS bucket to put chunks in.")
+	f.StringVar(&cfg.Endpoint, prefix+"bos.endpoint", "bj.bcebos.com", "Endpoint of the BOS service of the region of the bucket, e.g. gz.bcebos.com.")
+	f.StringVar(&cfg.AccessKeyID, prefix+"bos.access-key-id", "", "Baidu Cloud Access Key ID.")
+	f.Var(&cfg.SecretAccessKey, prefix+"bos.secret-access-key", "Baidu Cloud Secret Access Key.")
+	f.BoolVar(&cfg.Insecure, prefix+"bos.insecure", false, "Connect to the BOS endpoint over HTTP instead of HTTPS.")
+	f.DurationVar(&cfg.RequestTimeout, prefix+"bos.request-timeout", 30*time.Second, "Timeout of a request to BOS.")
+	f.DurationVar(&cfg.BackoffConfig.MinBackoff, prefix+"bos.min-backoff", 100*time.Millisecond, "Minimum backoff time when retrying BOS requests.")
+	f.DurationVar(&cfg.BackoffConfig.MaxBackoff, prefix+"bos.max-backoff", 3*time.Second, "Maximum backoff time when retrying BOS requests.")
+	f.IntVar(&cfg.BackoffConfig.MaxRetries, prefix+"bos.max-retries", 5, "Maximum number of times to retry BOS requests.")
+}
+
+// Validate config and returns error on failure
+func (cfg *BOSStorageConfig) Validate() error {
+	if cfg.Bucket == "" || cfg.Endpoint == "" {
+		return errors.New("the BOS bucket and endpoint must be set")
+	}
+	return nil
+}
+
+// BOSObjectClient stores the chunks in a Baidu Cloud BOS bucket, through the API of BOS.
+type BOSObjectClient struct {
+	*restclient.Client
+}
+
+// NewBOSObjectClient makes a new chunk.Client that writes chunks to Baidu Cloud BOS.
+func NewBOSObjectClient(cfg BOSStorageConfig, hedgingCfg hedging.Config) (*BOSObjectClient, error) {
+	log.WarnExperimentalUse("Baidu Cloud BOS Storage", log.Logger)
+	return newBOSObjectClient(cfg, hedgingCfg, restclient.NewTransport())
+}
+
+func newBOSObjectClient(cfg BOSStorageConfig, hedgingCfg hedging.Config, transport http.RoundTripper) (*BOSObjectClient, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	api, err := newBOSAPI(cfg)
+	if err != nil {
+		return nil, err
+	}
+	client, err := restclient.New(restclient.Config{
+		Service:         "BOS",
+		RequestTimeout:  cfg.RequestTimeout,
+		BackoffConfig:   cfg.BackoffConfig,
+		RequestDuration: bosRequestDuration,
+	}, api, hedgingCfg, transport)
+	if err != nil {
+		return nil, err
+	}
+	return &BOSObjectClient{Client: client}, nil
+}
+
+// bosAPI builds and signs the requests of the API of BOS, whose responses are JSON.
+type bosAPI struct {
+	cfg     BOSStorageConfig
+	baseURL *url.URL
+}
+
+func newBOSAPI(cfg BOSStorageConfig) (*bosAPI, error) {
+	scheme := "https"
+	if cfg.Insecure {
+		scheme = "http"
+	}
+	// the buckets are addressed by virtual host.
+	baseURL, err := url.Parse(fmt.Sprintf("%s://%s.%s", scheme, cfg.Bucket, cfg.Endpoint))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid BOS endpoint")
+	}
+	return &bosAPI{cfg: cfg, baseURL: baseURL}, nil
+}
+
+// URL implements restclient.Provider.
+func (a *bosAPI) URL(objectKey string, query url.Values) url.URL {
+	u := *a.baseURL
+	u.Path = "/" + objectKey
+	// the path is sent as it is signed.
+	u.RawPath = uriEncode(u.Path, false)
+	u.RawQuery = canonicalQuery(query)
+	return u
+}
+
+// Sign implements restclient.Provider, adding the BCE v1 signature to the request, which is
+// computed over the method, the path, the query parameters, the host and the date of the request.
+func (a *bosAPI) Sign(req *http.Request, objectKey string, query url.Values) {
+	timestamp := time.Now().UTC().Format(bceTimeFormat)
+	req.Header.Set("X-Bce-Date", timestamp)
+
+	headers := map[string]string{
+		"host":       req.URL.Host,
+		"x-bce-date": timestamp,
+	}
+	signedHeaders := make([]string, 0, len(headers))
+	canonicalHeaders := make([]string, 0, len(headers))
+	for name, value := range headers {
+		signedHeaders = append(signedHeaders, name)
+		canonicalHeaders = append(canonicalHeaders, uriEncode(name, true)+":"+uriEncode(strings.TrimSpace(value), true))
+	}
+	sort.Strings(signedHeaders)
+	sort.Strings(canonicalHeaders)
+
+	authPrefix := fmt.Sprintf("bce-auth-v1/%s/%s/%d", a.cfg.AccessKeyID, timestamp, int(signatureValidity.Seconds()))
+	canonicalRequest := req.Method + "\n" + uriEncode("/"+objectKey, false) + "\n" + canonicalQuery(query) + "\n" + strings.Join(canonicalHeaders, "\n")
+	signingKey := hmacSHA256([]byte(a.cfg.SecretAccessKey.Value), authPrefix)
+	signature := hmacSHA256([]byte(signingKey), canonicalRequest)
+
+	req.Header.Set("Authorization", authPrefix+"/"+strings.Join(signedHeaders, ";")+"/"+signature)
+}
+
+// ListQuery implements restclient.Provider.
+func (a *bosAPI) ListQuery(prefix, delimiter, marker string) url.Values {
+	query := url.Values{}
+	query.Set("prefix", prefix)
+	if delimiter != "" {
+		query.Set("delimiter", delimiter)
+	}
+	if marker != "" {
+		query.Set("marker", marker)
+	}
+	query.Set("maxKeys", strconv.Itoa(restclient.MaxListKeys))
+	return query
+}
+
+type listObjectsResult struct {
+	IsTruncated bool   `json:"isTruncated"`
+	NextMarker  string `json:"nextMarker"`
+	Contents    []struct {
+		Key          string `json:"key"`
+		LastModified string `json:"lastModified"`
+	} `json:"contents"`
+	CommonPrefixes []struct {
+		Prefix string `json:"prefix"`
+	} `json:"commonPrefixes"`
+}
+
+// DecodeListPage implements restclient.Provider.
+func (a *bosAPI) DecodeListPage(r io.Reader) (restclient.ListPage, error) {
+	var result listObjectsResult
+	if err := json.NewDecoder(r).Decode(&result); err != nil {
+		return restclient.ListPage{}, err
+	}
+
+	var page restclient.ListPage
+	for _, content := range result.Contents {
+		modifiedAt, err := time.Parse(bceTimeFormat, content.LastModified)
+		if err != nil {
+			return restclient.ListPage{}, errors.Wrapf(err, "invalid modification time of BOS object %s", content.Key)
+		}
+		page.Objects = append(page.Objects, chunk.StorageObject{
+			Key:        content.Key,
+			ModifiedAt: modifiedAt,
+		})
+	}
+	for _, commonPrefix := range result.CommonPrefixes {
+		page.CommonPrefixes = append(page.CommonPrefixes, chunk.StorageCommonPrefix(commonPrefix.Prefix))
+	}
+	if result.IsTruncated {
+		page.NextMarker = result.NextMarker
+	}
+	return page, nil
+}
+
+// DecodeError implements restclient.Provider.
+func (a *bosAPI) DecodeError(r io.Reader, err *restclient.Error) error {
+	var bosErr struct {
+		Code      string `json:"code"`
+		Message   string `json:"message"`
+		RequestID string `json:"requestId"`
+	}
+	if err := json.NewDecoder(r).Decode(&bosErr); err != nil {
+		return err
+	}
+	err.Code, err.Message, err.RequestID = bosErr.Code, bosErr.Message, bosErr.RequestID
+	return nil
+}
+
+// canonicalQuery returns the query parameters as `name=value` sorted and joined by `&`,
+// as they are signed by BOS.
+func canonicalQuery(query url.Values) string {
+	pairs := make([]string, 0, len(query))
+	for name := range query {
+		pairs = append(pairs, uriEncode(name, true)+"="+uriEncode(query.Get(name), true))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode percent-encodes all the characters but the unreserved ones of RFC 3986, and
+// the slashes of the paths unless encodeSlash is set.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case 'a' <= ch && ch <= 'z', 'A' <= ch && ch <= 'Z', '0' <= ch && ch <= '9',
+			ch == '-', ch == '_', ch == '.', ch == '~', ch == '/' && !encodeSlash:
+			b.WriteByte(ch)
+		default:
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, s string) string {
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write([]byte(s))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,124 @@
+package baidubce
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/dskit/backoff"
+	"github.com/grafana/dskit/flagext"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/pkg/storage/chunk/hedging"
+	"github.com/grafana/loki/pkg/storage/chunk/restclient"
+	"github.com/grafana/loki/pkg/storage/chunk/restclient/restclienttest"
+)
+
+// validSignature checks the BCE v1 signature of the request.
+func validSignature(r *http.Request, secret string) bool {
+	parts := strings.Split(r.Header.Get("Authorization"), "/")
+	if len(parts) != 6 || parts[0] != "bce-auth-v1" || parts[1] != "id" || parts[4] != "host;x-bce-date" {
+		return false
+	}
+	timestamp, err := time.Parse(bceTimeFormat, parts[2])
+	if err != nil || parts[2] != r.Header.Get("X-Bce-Date") || time.Since(timestamp) > signatureValidity {
+		return false
+	}
+
+	// the canonical request is rebuilt from the decoded path and query, as BOS does.
+	var params []string
+	for name, values := range r.URL.Query() {
+		params = append(params, url.QueryEscape(name)+"="+strings.ReplaceAll(url.QueryEscape(values[0]), "+", "%20"))
+	}
+	sort.Strings(params)
+	path := strings.ReplaceAll(url.PathEscape(r.URL.Path), "%2F", "/")
+	path = strings.ReplaceAll(path, ":", "%3A")
+	canonicalRequest := r.Method + "\n" + path + "\n" + strings.Join(params, "&") +
+		"\nhost:" + url.QueryEscape(r.Host) + "\nx-bce-date:" + url.QueryEscape(parts[2])
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write([]byte(strings.Join(parts[:4], "/")))
+	mac = hmac.New(sha256.New, []byte(hex.EncodeToString(mac.Sum(nil))))
+	_, _ = mac.Write([]byte(canonicalRequest))
+	return parts[5] == hex.EncodeToString(mac.Sum(nil))
+}
+
+func writeError(w http.ResponseWriter, status int, code string) {
+	w.WriteHeader(status)
+	_, _ = fmt.Fprintf(w, `{"code":%q,"message":"failed","requestId":"1"}`, code)
+}
+
+func newTestClient(t *testing.T) (*BOSObjectClient, *restclienttest.Bucket) {
+	bucket := &restclienttest.Bucket{T: t, Name: "loki", Secret: "secret", ValidSignature: validSignature, WriteError: writeError}
+	bucket.WriteListPage = func(w http.ResponseWriter, page restclient.ListPage) {
+		var result listObjectsResult
+		result.IsTruncated, result.NextMarker = page.NextMarker != "", page.NextMarker
+		for _, o := range page.Objects {
+			result.Contents = append(result.Contents, struct {
+				Key          string `json:"key"`
+				LastModified string `json:"lastModified"`
+			}{Key: o.Key, LastModified: o.ModifiedAt.UTC().Format(bceTimeFormat)})
+		}
+		for _, p := range page.CommonPrefixes {
+			result.CommonPrefixes = append(result.CommonPrefixes, struct {
+				Prefix string `json:"prefix"`
+			}{Prefix: string(p)})
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(result))
+	}
+	cfg := BOSStorageConfig{
+		Bucket:          bucket.Name,
+		Endpoint:        "gz.bcebos.com",
+		AccessKeyID:     "id",
+		SecretAccessKey: flagext.Secret{Value: bucket.Secret},
+		Insecure:        true,
+		BackoffConfig:   backoff.Config{MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond, MaxRetries: 3},
+	}
+	api, err := newBOSAPI(cfg)
+	require.NoError(t, err)
+	require.Equal(t, "loki.gz.bcebos.com", api.baseURL.Host)
+
+	client, err := newBOSObjectClient(cfg, hedging.Config{}, bucket.NewTransport())
+	require.NoError(t, err)
+	return client, bucket
+}
+
+func TestBOSObjectClient(t *testing.T) {
+	client, bucket := newTestClient(t)
+	restclienttest.TestObjectClient(t, client, bucket)
+}
+
+func TestBOSObjectClient_Retries(t *testing.T) {
+	client, bucket := newTestClient(t)
+	restclienttest.TestRetries(t, client, bucket)
+}
+
+func TestBOSAPI_DecodeListPage(t *testing.T) {
+	page, err := (&bosAPI{}).DecodeListPage(strings.NewReader(`{"isTruncated":true,"nextMarker":"fake/1/a:b","contents":[{"key":"fake/1/a:b","lastModified":"2022-01-02T03:04:05Z"}]}`))
+	require.NoError(t, err)
+	require.Equal(t, "fake/1/a:b", page.NextMarker)
+	require.Len(t, page.Objects, 1)
+	require.Equal(t, time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC), page.Objects[0].ModifiedAt)
+
+	_, err = (&bosAPI{}).DecodeListPage(strings.NewReader(`{"contents":[{"key":"fake/1/a:b","lastModified":"yesterday"}]}`))
+	require.Error(t, err)
+}
+
+func TestBOSStorageConfig_Validate(t *testing.T) {
+	require.Error(t, (&BOSStorageConfig{Endpoint: "bj.bcebos.com"}).Validate())
+	require.Error(t, (&BOSStorageConfig{Bucket: "loki"}).Validate())
+	require.NoError(t, (&BOSStorageConfig{Bucket: "loki", Endpoint: "bj.bcebos.com"}).Validate())
+}
+
+func TestURIEncode(t *testing.T) {
+	require.Equal(t, "/fake/1/a%3Ab%20c~", uriEncode("/fake/1/a:b c~", false))
+	require.Equal(t, "%2Ffake%2F1", uriEncode("/fake/1", true))
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 	"unsafe"
 
+	"github.com/cespare/xxhash/v2"
 	"github.com/dustin/go-humanize"
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
@@ -22,12 +23,27 @@ import (
 const (
 	elementSize    = int(unsafe.Sizeof(list.Element{}))
 	elementPrtSize = int(unsafe.Sizeof(&list.Element{}))
+	stringSize     = int(unsafe.Sizeof(""))
+
+	maxShards = 16
+	// Smaller caches use fewer shards, so that their evictions stay close to the ones of a single shard, and that
+	// each shard holds many of the largest entries, like chunks, as the entries larger than a shard aren't cached.
+	minShardSizeBytes = 64 << 20
+	minShardSizeItems = 1024
+	// The share of the capacity of a shard which can be held by the protected generation.
+	protectedRatio = 0.8
 )
 
-// This FIFO cache implementation supports two eviction methods - based on number of items in the cache, and based on memory usage.
+// This in-memory cache supports two eviction methods - based on number of items in the cache, and based on memory usage.
 // For the memory-based eviction, set FifoCacheConfig.MaxSizeBytes to a positive integer, indicating upper limit of memory allocated by items in the cache.
 // Alternatively, set FifoCacheConfig.MaxSizeItems to a positive integer, indicating maximum number of items in the cache.
 // If both parameters are set, both methods are enforced, whichever hits first.
+//
+// The keys are spread over shards, each one evicting its entries as a segmented LRU: the new entries are added to
+// a probation generation and are promoted to a protected generation when they are fetched again. The entries are
+// evicted from the probation generation first, so that scans of entries fetched once don't flush the entries fetched
+// repeatedly. The protected generation holds at most 80% of the capacity of the shard, its least recently used
+// entries are moved back to the probation generation.
 
 // FifoCacheConfig holds config for the FifoCache.
 type FifoCacheConfig struct {
@@ -67,16 +83,11 @@ func parsebytes(s string) (uint64, error) {
 	return bytes, nil
 }
 
-// FifoCache is a simple string -> interface{} cache which uses a fifo slide to
+// FifoCache is a sharded string -> []byte cache which uses a segmented LRU to
 // manage evictions.  O(1) inserts and updates, O(1) gets.
 type FifoCache struct {
-	lock          sync.RWMutex
-	maxSizeItems  int
-	maxSizeBytes  uint64
-	currSizeBytes uint64
-
-	entries map[string]*list.Element
-	lru     *list.List
+	ttl    time.Duration
+	shards []*fifoShard
 
 	done chan struct{}
 
@@ -91,9 +102,28 @@ type FifoCache struct {
 }
 
 type cacheEntry struct {
-	updated time.Time
-	key     string
-	value   []byte
+	updated   time.Time
+	key       string
+	value     []byte
+	size      uint64
+	protected bool
+}
+
+// fifoShard holds the entries of a share of the keys in two generations.
+type fifoShard struct {
+	c *FifoCache
+
+	lock              sync.Mutex
+	maxSizeItems      int
+	maxSizeBytes      uint64
+	maxProtectedItems int
+	maxProtectedBytes uint64
+	currSizeBytes     uint64
+	protectedBytes    uint64
+
+	entries   map[string]*list.Element
+	probation *list.List
+	protected *list.List
 }
 
 // NewFifoCache returns a new initialised FifoCache of size.
@@ -126,11 +156,7 @@ func NewFifoCache(name string, cfg FifoCacheConfig, reg prometheus.Registerer, l
 	}
 
 	cache := &FifoCache{
-		maxSizeItems: cfg.MaxSizeItems,
-		maxSizeBytes: maxSizeBytes,
-		entries:      make(map[string]*list.Element),
-		lru:          list.New(),
-
+		ttl:  cfg.TTL,
 		done: make(chan struct{}),
 
 		entriesAdded: promauto.With(reg).NewCounter(prometheus.CounterOpts{
@@ -185,7 +211,7 @@ func NewFifoCache(name string, cfg FifoCacheConfig, reg prometheus.Registerer, l
 			Namespace:   "querier",
 			Subsystem:   "cache",
 			Name:        "stale_gets_total",
-			Help:        "The total number of Get calls that had an entry which expired (deprecated)",
+			Help:        "The total number of Get calls that had an entry which expired",
 			ConstLabels: prometheus.Labels{"cache": name},
 		}),
 
@@ -198,6 +224,12 @@ func NewFifoCache(name string, cfg FifoCacheConfig, reg prometheus.Registerer, l
 		}),
 	}
 
+	shards := numShards(maxSizeBytes, cfg.MaxSizeItems)
+	cache.shards = make([]*fifoShard, shards)
+	for i := range cache.shards {
+		cache.shards[i] = newFifoShard(cache, maxSizeBytes/uint64(shards), (cfg.MaxSizeItems+shards-1)/shards)
+	}
+
 	if cfg.TTL > 0 {
 		go cache.runPruneJob(cfg.PurgeInterval, cfg.TTL)
 	}
@@ -221,18 +253,8 @@ func (c *FifoCache) runPruneJob(interval, ttl time.Duration) {
 
 // pruneExpiredItems prunes items in the cache that exceeded their ttl
 func (c *FifoCache) pruneExpiredItems(ttl time.Duration) {
-	c.lock.Lock()
-	defer c.lock.Unlock()
-
-	for k, v := range c.entries {
-		entry := v.Value.(*cacheEntry)
-		if time.Since(entry.updated) > ttl {
-			_ = c.lru.Remove(v).(*cacheEntry)
-			delete(c.entries, k)
-			c.currSizeBytes -= sizeOf(entry)
-			c.entriesCurrent.Dec()
-			c.entriesEvicted.Inc()
-		}
+	for _, s := range c.shards {
+		s.pruneExpiredItems(ttl)
 	}
 }
 
@@ -256,41 +278,96 @@ func (c *FifoCache) Fetch(ctx context.Context, keys []string) (found []string, b
 func (c *FifoCache) Store(ctx context.Context, keys []string, values [][]byte) error {
 	c.entriesAdded.Inc()
 
-	c.lock.Lock()
-	defer c.lock.Unlock()
-
 	for i := range keys {
-		c.put(keys[i], values[i])
+		c.shard(keys[i]).put(keys[i], values[i])
 	}
 	return nil
 }
 
 // Stop implements Cache.
 func (c *FifoCache) Stop() {
-	c.lock.Lock()
-	defer c.lock.Unlock()
-
 	close(c.done)
 
-	c.entriesEvicted.Add(float64(c.lru.Len()))
-
-	c.entries = make(map[string]*list.Element)
-	c.lru.Init()
-	c.currSizeBytes = 0
+	for _, s := range c.shards {
+		s.lock.Lock()
+		c.entriesEvicted.Add(float64(len(s.entries)))
+		s.reset()
+		s.lock.Unlock()
+	}
 
 	c.entriesCurrent.Set(float64(0))
 	c.memoryBytes.Set(float64(0))
 }
 
-func (c *FifoCache) put(key string, value []byte) {
+// Get returns the stored value against the key and when the key was last updated.
+func (c *FifoCache) Get(ctx context.Context, key string) ([]byte, bool) {
+	c.totalGets.Inc()
+
+	value, ok := c.shard(key).get(key, c.ttl)
+	if !ok {
+		c.totalMisses.Inc()
+	}
+	return value, ok
+}
+
+func (c *FifoCache) shard(key string) *fifoShard {
+	if len(c.shards) == 1 {
+		return c.shards[0]
+	}
+	return c.shards[xxhash.Sum64String(key)%uint64(len(c.shards))]
+}
+
+// numShards returns the number of shards of the cache, keeping a minimum capacity per shard.
+func numShards(maxSizeBytes uint64, maxSizeItems int) int {
+	n := maxShards
+	for n > 1 && ((maxSizeBytes > 0 && maxSizeBytes/uint64(n) < minShardSizeBytes) || (maxSizeItems > 0 && maxSizeItems/n < minShardSizeItems)) {
+		n /= 2
+	}
+	return n
+}
+
+func newFifoShard(c *FifoCache, maxSizeBytes uint64, maxSizeItems int) *fifoShard {
+	s := &fifoShard{
+		c:                 c,
+		maxSizeItems:      maxSizeItems,
+		maxSizeBytes:      maxSizeBytes,
+		maxProtectedItems: int(float64(maxSizeItems) * protectedRatio),
+		maxProtectedBytes: uint64(float64(maxSizeBytes) * protectedRatio),
+		probation:         list.New(),
+		protected:         list.New(),
+	}
+	s.reset()
+	return s
+}
+
+func (s *fifoShard) reset() {
+	s.entries = make(map[string]*list.Element)
+	s.probation.Init()
+	s.protected.Init()
+	s.currSizeBytes = 0
+	s.protectedBytes = 0
+}
+
+func (s *fifoShard) pruneExpiredItems(ttl time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, element := range s.entries {
+		if time.Since(element.Value.(*cacheEntry).updated) > ttl {
+			s.evict(element)
+		}
+	}
+}
+
+func (s *fifoShard) put(key string, value []byte) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
 	// See if we already have the item in the cache.
-	element, ok := c.entries[key]
+	element, ok := s.entries[key]
 	if ok {
 		// Remove the item from the cache.
-		entry := c.lru.Remove(element).(*cacheEntry)
-		delete(c.entries, key)
-		c.currSizeBytes -= sizeOf(entry)
-		c.entriesCurrent.Dec()
+		s.remove(element)
 	}
 
 	entry := &cacheEntry{
@@ -298,56 +375,96 @@ func (c *FifoCache) put(key string, value []byte) {
 		key:     key,
 		value:   value,
 	}
-	entrySz := sizeOf(entry)
+	entry.size = sizeOf(entry)
 
-	if c.maxSizeBytes > 0 && entrySz > c.maxSizeBytes {
+	if s.maxSizeBytes > 0 && entry.size > s.maxSizeBytes {
 		// Cannot keep this item in the cache.
 		if ok {
 			// We do not replace this item.
-			c.entriesEvicted.Inc()
+			s.c.entriesEvicted.Inc()
 		}
-		c.memoryBytes.Set(float64(c.currSizeBytes))
 		return
 	}
 
-	// Otherwise, see if we need to evict item(s).
-	for (c.maxSizeBytes > 0 && c.currSizeBytes+entrySz > c.maxSizeBytes) || (c.maxSizeItems > 0 && len(c.entries) >= c.maxSizeItems) {
-		lastElement := c.lru.Back()
+	// Otherwise, see if we need to evict item(s), the entries on probation first.
+	for (s.maxSizeBytes > 0 && s.currSizeBytes+entry.size > s.maxSizeBytes) || (s.maxSizeItems > 0 && len(s.entries) >= s.maxSizeItems) {
+		lastElement := s.probation.Back()
+		if lastElement == nil {
+			lastElement = s.protected.Back()
+		}
 		if lastElement == nil {
 			break
 		}
-		evicted := c.lru.Remove(lastElement).(*cacheEntry)
-		delete(c.entries, evicted.key)
-		c.currSizeBytes -= sizeOf(evicted)
-		c.entriesCurrent.Dec()
-		c.entriesEvicted.Inc()
+		s.evict(lastElement)
 	}
 
 	// Finally, we have space to add the item.
-	c.entries[key] = c.lru.PushFront(entry)
-	c.currSizeBytes += entrySz
+	s.entries[key] = s.probation.PushFront(entry)
+	s.currSizeBytes += entry.size
 	if !ok {
-		c.entriesAddedNew.Inc()
+		s.c.entriesAddedNew.Inc()
 	}
-	c.entriesCurrent.Inc()
-	c.memoryBytes.Set(float64(c.currSizeBytes))
+	s.c.entriesCurrent.Inc()
+	s.c.memoryBytes.Add(float64(entry.size))
 }
 
-// Get returns the stored value against the key and when the key was last updated.
-func (c *FifoCache) Get(ctx context.Context, key string) ([]byte, bool) {
-	c.totalGets.Inc()
+func (s *fifoShard) get(key string, ttl time.Duration) ([]byte, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
 
-	c.lock.RLock()
-	defer c.lock.RUnlock()
+	element, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*cacheEntry)
+	if ttl > 0 && time.Since(entry.updated) > ttl {
+		s.c.staleGets.Inc()
+		s.evict(element)
+		return nil, false
+	}
 
-	element, ok := c.entries[key]
-	if ok {
-		entry := element.Value.(*cacheEntry)
+	if entry.protected {
+		s.protected.MoveToFront(element)
 		return entry.value, true
 	}
 
-	c.totalMisses.Inc()
-	return nil, false
+	// The entry was fetched again since it was added, it is promoted to the protected generation.
+	s.probation.Remove(element)
+	entry.protected = true
+	s.entries[key] = s.protected.PushFront(entry)
+	s.protectedBytes += entry.size
+	for s.protected.Len() > 1 && ((s.maxProtectedBytes > 0 && s.protectedBytes > s.maxProtectedBytes) || (s.maxProtectedItems > 0 && s.protected.Len() > s.maxProtectedItems)) {
+		s.demote(s.protected.Back())
+	}
+	return entry.value, true
+}
+
+// demote moves the protected entry back to the probation generation.
+func (s *fifoShard) demote(element *list.Element) {
+	entry := s.protected.Remove(element).(*cacheEntry)
+	entry.protected = false
+	s.protectedBytes -= entry.size
+	s.entries[entry.key] = s.probation.PushFront(entry)
+}
+
+// evict removes the entry from the cache and counts its eviction.
+func (s *fifoShard) evict(element *list.Element) {
+	s.remove(element)
+	s.c.entriesEvicted.Inc()
+}
+
+func (s *fifoShard) remove(element *list.Element) {
+	entry := element.Value.(*cacheEntry)
+	if entry.protected {
+		s.protected.Remove(element)
+		s.protectedBytes -= entry.size
+	} else {
+		s.probation.Remove(element)
+	}
+	delete(s.entries, entry.key)
+	s.currSizeBytes -= entry.size
+	s.c.entriesCurrent.Dec()
+	s.c.memoryBytes.Sub(float64(entry.size))
 }
 
 func sizeOf(item *cacheEntry) uint64 {
@@ -355,5 +472,6 @@ func sizeOf(item *cacheEntry) uint64 {
 		len(item.key) + // size of key
 		cap(item.value) + // size of value
 		elementSize + // size of the element in linked list
+		stringSize + // size of the key in the map
 		elementPrtSize) // size of the pointer to an element in the map
 }
@@ -53,14 +53,14 @@ func TestFifoCacheEviction(t *testing.T) {
 		}
 		err := c.Store(ctx, keys, values)
 		require.NoError(t, err)
-		require.Len(t, c.entries, cnt)
+		require.Equal(t, cnt, countEntries(c))
 
 		assert.Equal(t, testutil.ToFloat64(c.entriesAdded), float64(1))
 		assert.Equal(t, testutil.ToFloat64(c.entriesAddedNew), float64(cnt))
 		assert.Equal(t, testutil.ToFloat64(c.entriesEvicted), float64(0))
 		assert.Equal(t, testutil.ToFloat64(c.entriesCurrent), float64(cnt))
-		assert.Equal(t, testutil.ToFloat64(c.entriesCurrent), float64(len(c.entries)))
-		assert.Equal(t, testutil.ToFloat64(c.entriesCurrent), float64(c.lru.Len()))
+		assert.Equal(t, testutil.ToFloat64(c.entriesCurrent), float64(countEntries(c)))
+		assert.Equal(t, testutil.ToFloat64(c.entriesCurrent), float64(countElements(c)))
 		assert.Equal(t, testutil.ToFloat64(c.totalGets), float64(0))
 		assert.Equal(t, testutil.ToFloat64(c.totalMisses), float64(0))
 		assert.Equal(t, testutil.ToFloat64(c.memoryBytes), float64(cnt*sizeOf(itemTemplate)))
@@ -76,16 +76,17 @@ func TestFifoCacheEviction(t *testing.T) {
 		assert.Equal(t, testutil.ToFloat64(c.entriesAddedNew), float64(cnt))
 		assert.Equal(t, testutil.ToFloat64(c.entriesEvicted), float64(0))
 		assert.Equal(t, testutil.ToFloat64(c.entriesCurrent), float64(cnt))
-		assert.Equal(t, testutil.ToFloat64(c.entriesCurrent), float64(len(c.entries)))
-		assert.Equal(t, testutil.ToFloat64(c.entriesCurrent), float64(c.lru.Len()))
+		assert.Equal(t, testutil.ToFloat64(c.entriesCurrent), float64(countEntries(c)))
+		assert.Equal(t, testutil.ToFloat64(c.entriesCurrent), float64(countElements(c)))
 		assert.Equal(t, testutil.ToFloat64(c.totalGets), float64(cnt))
 		assert.Equal(t, testutil.ToFloat64(c.totalMisses), float64(0))
 		assert.Equal(t, testutil.ToFloat64(c.memoryBytes), float64(cnt*sizeOf(itemTemplate)))
 
-		// Check evictions
+		// Check evictions: the entries fetched again are protected, the other ones are evicted first
+		// and the new entries are evicted before the protected ones.
 		keys = []string{}
 		values = [][]byte{}
-		for i := cnt - evicted; i < cnt+evicted; i++ {
+		for i := cnt; i < cnt+evicted; i++ {
 			key := fmt.Sprintf("%02d", i)
 			value := make([]byte, len(key))
 			copy(value, key)
@@ -94,23 +95,23 @@ func TestFifoCacheEviction(t *testing.T) {
 		}
 		err = c.Store(ctx, keys, values)
 		require.NoError(t, err)
-		require.Len(t, c.entries, cnt)
+		require.Equal(t, cnt, countEntries(c))
 
 		assert.Equal(t, testutil.ToFloat64(c.entriesAdded), float64(2))
 		assert.Equal(t, testutil.ToFloat64(c.entriesAddedNew), float64(cnt+evicted))
 		assert.Equal(t, testutil.ToFloat64(c.entriesEvicted), float64(evicted))
 		assert.Equal(t, testutil.ToFloat64(c.entriesCurrent), float64(cnt))
-		assert.Equal(t, testutil.ToFloat64(c.entriesCurrent), float64(len(c.entries)))
-		assert.Equal(t, testutil.ToFloat64(c.entriesCurrent), float64(c.lru.Len()))
+		assert.Equal(t, testutil.ToFloat64(c.entriesCurrent), float64(countEntries(c)))
+		assert.Equal(t, testutil.ToFloat64(c.entriesCurrent), float64(countElements(c)))
 		assert.Equal(t, testutil.ToFloat64(c.totalGets), float64(cnt))
 		assert.Equal(t, testutil.ToFloat64(c.totalMisses), float64(0))
 		assert.Equal(t, testutil.ToFloat64(c.memoryBytes), float64(cnt*sizeOf(itemTemplate)))
 
-		for i := 0; i < cnt-evicted; i++ {
+		for _, i := range []int{0, 1, 10, 11, 12} {
 			_, ok := c.Get(ctx, fmt.Sprintf("%02d", i))
 			require.False(t, ok)
 		}
-		for i := cnt - evicted; i < cnt+evicted; i++ {
+		for _, i := range []int{2, 3, 4, 5, 6, 7, 8, 9, 13, 14} {
 			key := fmt.Sprintf("%02d", i)
 			value, ok := c.Get(ctx, key)
 			require.True(t, ok)
@@ -121,16 +122,16 @@ func TestFifoCacheEviction(t *testing.T) {
 		assert.Equal(t, testutil.ToFloat64(c.entriesAddedNew), float64(cnt+evicted))
 		assert.Equal(t, testutil.ToFloat64(c.entriesEvicted), float64(evicted))
 		assert.Equal(t, testutil.ToFloat64(c.entriesCurrent), float64(cnt))
-		assert.Equal(t, testutil.ToFloat64(c.entriesCurrent), float64(len(c.entries)))
-		assert.Equal(t, testutil.ToFloat64(c.entriesCurrent), float64(c.lru.Len()))
+		assert.Equal(t, testutil.ToFloat64(c.entriesCurrent), float64(countEntries(c)))
+		assert.Equal(t, testutil.ToFloat64(c.entriesCurrent), float64(countElements(c)))
 		assert.Equal(t, testutil.ToFloat64(c.totalGets), float64(cnt*2+evicted))
-		assert.Equal(t, testutil.ToFloat64(c.totalMisses), float64(cnt-evicted))
+		assert.Equal(t, testutil.ToFloat64(c.totalMisses), float64(evicted))
 		assert.Equal(t, testutil.ToFloat64(c.memoryBytes), float64(cnt*sizeOf(itemTemplate)))
 
 		// Check updates work
 		keys = []string{}
 		values = [][]byte{}
-		for i := cnt; i < cnt+evicted; i++ {
+		for i := 2; i < 2+evicted; i++ {
 			keys = append(keys, fmt.Sprintf("%02d", i))
 			vstr := fmt.Sprintf("%02d", i*2)
 			value := make([]byte, len(vstr))
@@ -139,9 +140,9 @@ func TestFifoCacheEviction(t *testing.T) {
 		}
 		err = c.Store(ctx, keys, values)
 		require.NoError(t, err)
-		require.Len(t, c.entries, cnt)
+		require.Equal(t, cnt, countEntries(c))
 
-		for i := cnt; i < cnt+evicted; i++ {
+		for i := 2; i < 2+evicted; i++ {
 			value, ok := c.Get(ctx, fmt.Sprintf("%02d", i))
 			require.True(t, ok)
 			require.Equal(t, []byte(fmt.Sprintf("%02d", i*2)), value)
@@ -151,10 +152,10 @@ func TestFifoCacheEviction(t *testing.T) {
 		assert.Equal(t, testutil.ToFloat64(c.entriesAddedNew), float64(cnt+evicted))
 		assert.Equal(t, testutil.ToFloat64(c.entriesEvicted), float64(evicted))
 		assert.Equal(t, testutil.ToFloat64(c.entriesCurrent), float64(cnt))
-		assert.Equal(t, testutil.ToFloat64(c.entriesCurrent), float64(len(c.entries)))
-		assert.Equal(t, testutil.ToFloat64(c.entriesCurrent), float64(c.lru.Len()))
+		assert.Equal(t, testutil.ToFloat64(c.entriesCurrent), float64(countEntries(c)))
+		assert.Equal(t, testutil.ToFloat64(c.entriesCurrent), float64(countElements(c)))
 		assert.Equal(t, testutil.ToFloat64(c.totalGets), float64(cnt*2+evicted*2))
-		assert.Equal(t, testutil.ToFloat64(c.totalMisses), float64(cnt-evicted))
+		assert.Equal(t, testutil.ToFloat64(c.totalMisses), float64(evicted))
 		assert.Equal(t, testutil.ToFloat64(c.memoryBytes), float64(cnt*sizeOf(itemTemplate)))
 
 		c.Stop()
@@ -210,8 +211,8 @@ func TestFifoCacheExpiry(t *testing.T) {
 			assert.Equal(t, float64(4), testutil.ToFloat64(c.entriesAddedNew))
 			assert.Equal(t, float64(1), testutil.ToFloat64(c.entriesEvicted))
 			assert.Equal(t, float64(3), testutil.ToFloat64(c.entriesCurrent))
-			assert.Equal(t, float64(len(c.entries)), testutil.ToFloat64(c.entriesCurrent))
-			assert.Equal(t, float64(c.lru.Len()), testutil.ToFloat64(c.entriesCurrent))
+			assert.Equal(t, float64(countEntries(c)), testutil.ToFloat64(c.entriesCurrent))
+			assert.Equal(t, float64(countElements(c)), testutil.ToFloat64(c.entriesCurrent))
 			assert.Equal(t, float64(2), testutil.ToFloat64(c.totalGets))
 			assert.Equal(t, float64(1), testutil.ToFloat64(c.totalMisses))
 			assert.Equal(t, float64(memorySz), testutil.ToFloat64(c.memoryBytes))
@@ -225,17 +226,148 @@ func TestFifoCacheExpiry(t *testing.T) {
 			assert.Equal(t, float64(4), testutil.ToFloat64(c.entriesAddedNew))
 			assert.Equal(t, float64(4), testutil.ToFloat64(c.entriesEvicted))
 			assert.Equal(t, float64(0), testutil.ToFloat64(c.entriesCurrent))
-			assert.Equal(t, float64(len(c.entries)), testutil.ToFloat64(c.entriesCurrent))
-			assert.Equal(t, float64(c.lru.Len()), testutil.ToFloat64(c.entriesCurrent))
+			assert.Equal(t, float64(countEntries(c)), testutil.ToFloat64(c.entriesCurrent))
+			assert.Equal(t, float64(countElements(c)), testutil.ToFloat64(c.entriesCurrent))
 			assert.Equal(t, float64(3), testutil.ToFloat64(c.totalGets))
 			assert.Equal(t, float64(2), testutil.ToFloat64(c.totalMisses))
-			assert.Equal(t, float64(memorySz), testutil.ToFloat64(c.memoryBytes))
+			assert.Equal(t, float64(0), testutil.ToFloat64(c.memoryBytes))
 
 			c.Stop()
 		})
 	}
 }
 
+func TestFifoCacheStaleGet(t *testing.T) {
+	c := NewFifoCache("test-stale-get", FifoCacheConfig{MaxSizeItems: 10, TTL: 50 * time.Millisecond, PurgeInterval: time.Hour}, nil, log.NewNopLogger())
+	defer c.Stop()
+	ctx := context.Background()
+
+	require.NoError(t, c.Store(ctx, []string{"01"}, [][]byte{genBytes(32)}))
+	_, ok := c.Get(ctx, "01")
+	require.True(t, ok)
+
+	// The expired entries are removed when fetched, before they are purged.
+	time.Sleep(100 * time.Millisecond)
+	_, ok = c.Get(ctx, "01")
+	require.False(t, ok)
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(c.staleGets))
+	assert.Equal(t, float64(1), testutil.ToFloat64(c.totalMisses))
+	assert.Equal(t, float64(1), testutil.ToFloat64(c.entriesEvicted))
+	assert.Equal(t, float64(0), testutil.ToFloat64(c.entriesCurrent))
+	assert.Equal(t, float64(0), testutil.ToFloat64(c.memoryBytes))
+}
+
+func TestFifoCacheSharding(t *testing.T) {
+	for _, tc := range []struct {
+		maxSizeBytes uint64
+		maxSizeItems int
+		expected     int
+	}{
+		{maxSizeBytes: 100, expected: 1},
+		{maxSizeBytes: 16 << 20, expected: 1},
+		{maxSizeBytes: 4 * minShardSizeBytes, expected: 4},
+		{maxSizeBytes: 1 << 30, expected: maxShards},
+		{maxSizeItems: 3 * minShardSizeItems, expected: 2},
+		{maxSizeBytes: 1 << 30, maxSizeItems: minShardSizeItems, expected: 1},
+	} {
+		require.Equal(t, tc.expected, numShards(tc.maxSizeBytes, tc.maxSizeItems))
+	}
+
+	const cnt = 10000
+	c := NewFifoCache("test-sharding", FifoCacheConfig{MaxSizeItems: maxShards * minShardSizeItems}, nil, log.NewNopLogger())
+	defer c.Stop()
+	require.Len(t, c.shards, maxShards)
+
+	ctx := context.Background()
+	var (
+		keys   []string
+		values [][]byte
+		size   uint64
+	)
+	for i := 0; i < cnt; i++ {
+		key := strconv.Itoa(i)
+		keys = append(keys, key)
+		values = append(values, []byte(key))
+		size += sizeOf(&cacheEntry{key: key, value: values[i]})
+	}
+	require.NoError(t, c.Store(ctx, keys, values))
+
+	found, bufs, missing, err := c.Fetch(ctx, keys)
+	require.NoError(t, err)
+	require.Equal(t, keys, found)
+	require.Equal(t, values, bufs)
+	require.Empty(t, missing)
+
+	// The memory of the shards adds up to the memory of the entries.
+	require.Equal(t, cnt, countEntries(c))
+	require.Equal(t, float64(size), testutil.ToFloat64(c.memoryBytes))
+	var shardsSize uint64
+	for _, s := range c.shards {
+		require.NotEmpty(t, s.entries)
+		shardsSize += s.currSizeBytes
+	}
+	require.Equal(t, size, shardsSize)
+}
+
+func TestFifoCacheLargeEntry(t *testing.T) {
+	// a small cache holds entries as large as chunks.
+	c := NewFifoCache("test-large-entry", FifoCacheConfig{MaxSizeBytes: "16MB"}, nil, log.NewNopLogger())
+	defer c.Stop()
+	ctx := context.Background()
+
+	value := make([]byte, 1536<<10)
+	require.NoError(t, c.Store(ctx, []string{"chunk"}, [][]byte{value}))
+	found, ok := c.Get(ctx, "chunk")
+	require.True(t, ok)
+	require.Equal(t, value, found)
+}
+
+func TestFifoCacheProtectedGeneration(t *testing.T) {
+	c := NewFifoCache("test-protected", FifoCacheConfig{MaxSizeItems: 5}, nil, log.NewNopLogger())
+	defer c.Stop()
+	ctx := context.Background()
+
+	require.NoError(t, c.Store(ctx, []string{"a", "b", "c", "d", "e"}, [][]byte{{1}, {2}, {3}, {4}, {5}}))
+	// The protected generation holds at most 4 entries, "a" is moved back on probation.
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		_, ok := c.Get(ctx, key)
+		require.True(t, ok)
+	}
+	s := c.shards[0]
+	require.Equal(t, 4, s.protected.Len())
+	require.Equal(t, 1, s.probation.Len())
+
+	// A scan of new entries only evicts the entries on probation.
+	for _, key := range []string{"f", "g", "h"} {
+		require.NoError(t, c.Store(ctx, []string{key}, [][]byte{{0}}))
+	}
+	found, _, missing, err := c.Fetch(ctx, []string{"a", "b", "c", "d", "e", "f", "g", "h"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"b", "c", "d", "e", "h"}, found)
+	require.Equal(t, []string{"a", "f", "g"}, missing)
+}
+
+func countEntries(c *FifoCache) int {
+	n := 0
+	for _, s := range c.shards {
+		s.lock.Lock()
+		n += len(s.entries)
+		s.lock.Unlock()
+	}
+	return n
+}
+
+func countElements(c *FifoCache) int {
+	n := 0
+	for _, s := range c.shards {
+		s.lock.Lock()
+		n += s.probation.Len() + s.protected.Len()
+		s.lock.Unlock()
+	}
+	return n
+}
+
 func genBytes(n uint8) []byte {
 	arr := make([]byte, n)
 	for i := range arr {
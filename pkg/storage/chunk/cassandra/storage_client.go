@@ -484,7 +484,7 @@ func (s *ObjectClient) PutChunks(ctx context.Context, chunks []chunk.Chunk) erro
 			return errors.WithStack(err)
 		}
 		key := s.schemaCfg.ExternalKey(chunks[i])
-		tableName, err := s.schemaCfg.ChunkTableFor(chunks[i].From)
+		tableName, err := s.schemaCfg.ChunkTableFor(chunks[i].UserID, chunks[i].From)
 		if err != nil {
 			return err
 		}
@@ -513,7 +513,7 @@ func (s *ObjectClient) getChunk(ctx context.Context, decodeContext *chunk.Decode
 		defer s.querySemaphore.Release(1)
 	}
 
-	tableName, err := s.schemaCfg.ChunkTableFor(input.From)
+	tableName, err := s.schemaCfg.ChunkTableFor(input.UserID, input.From)
 	if err != nil {
 		return input, err
 	}
@@ -533,7 +533,7 @@ func (s *ObjectClient) DeleteChunk(ctx context.Context, userID, chunkID string)
 		return err
 	}
 
-	tableName, err := s.schemaCfg.ChunkTableFor(chunkRef.From)
+	tableName, err := s.schemaCfg.ChunkTableFor(chunkRef.UserID, chunkRef.From)
 	if err != nil {
 		return err
 	}
@@ -3,6 +3,7 @@ package chunk
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
 	"hash/crc32"
 	"reflect"
 	"strconv"
@@ -10,6 +11,7 @@ import (
 	"sync"
 	"unsafe"
 
+	"github.com/cespare/xxhash/v2"
 	"github.com/golang/snappy"
 	jsoniter "github.com/json-iterator/go"
 	"github.com/pkg/errors"
@@ -20,6 +22,7 @@ import (
 	"github.com/grafana/loki/pkg/logproto"
 	"github.com/grafana/loki/pkg/prom1/storage/metric"
 	prom_chunk "github.com/grafana/loki/pkg/storage/chunk/encoding"
+	"github.com/grafana/loki/pkg/storage/chunk/parquet"
 )
 
 const (
@@ -44,12 +47,21 @@ type Chunk struct {
 
 	// For old chunks, ChecksumSet will be false.
 	ChecksumSet bool `json:"-"`
+	// EncodingSet is only true for the chunks parsed from v14+ keys, which hold the encoding.
+	EncodingSet bool `json:"-"`
 
 	// We never use Delta encoding (the zero value), so if this entry is
 	// missing, we default to DoubleDelta.
 	Encoding prom_chunk.Encoding `json:"encoding"`
 	Data     prom_chunk.Chunk    `json:"-"`
 
+	// Container is set for the chunks packed with others in a single object.
+	Container ChunkContainer `json:"-"`
+
+	// KB and Entries are the size of the chunk recorded by the index, zero when the index doesn't record it.
+	KB      uint32 `json:"-"`
+	Entries uint32 `json:"-"`
+
 	// The encoded version of the chunk, held so we don't need to re-encode it
 	encoded []byte
 }
@@ -85,9 +97,37 @@ func NewChunk(userID string, fp model.Fingerprint, metric labels.Labels, c prom_
 //
 // v12+, fingerprint is now a prefix to support better read and write request parallelization:
 // `<user>/<fprint>/<start>:<end>:<checksum>`
+//
+// v13+, the user is hashed into a fixed width hex directory (see tenantPrefix),
+// followed by the number of the chunk table period (or day), to support
+// per-tenant and per-period object store lifecycle rules:
+// `<user hash>/<period>/<fprint>/<start>:<end>:<checksum>`
+//
+// v14+, the encoding of the chunk follows the checksum, so that both the
+// integrity and the format of the object can be checked when fetching it:
+// `<user hash>/<period>/<fprint>/<start>:<end>:<checksum>:<encoding>`
+//
+// The keys of the chunks packed in a container are followed by the ID of the
+// container, and the offset and length of the chunk in it:
+// `<key>@<container>:<offset>:<length>`
 func ParseExternalKey(userID, externalKey string) (Chunk, error) {
+	key, container, err := splitContainerKey(externalKey)
+	if err != nil {
+		return Chunk{}, err
+	}
+	chunk, err := parseExternalKey(userID, key)
+	if err != nil {
+		return Chunk{}, err
+	}
+	chunk.Container = container
+	return chunk, nil
+}
+
+func parseExternalKey(userID, externalKey string) (Chunk, error) {
 	if !strings.Contains(externalKey, "/") { // pre-checksum
 		return parseLegacyChunkID(userID, externalKey)
+	} else if prefix := tenantPrefix(userID); strings.HasPrefix(externalKey, prefix) { // v13+ and v14+
+		return parseTenantPrefixedExternalKey(userID, externalKey, prefix)
 	} else if strings.Count(externalKey, "/") == 2 { // v12+
 		return parseNewerExternalKey(userID, externalKey)
 	} else { // post-checksum
@@ -95,6 +135,12 @@ func ParseExternalKey(userID, externalKey string) (Chunk, error) {
 	}
 }
 
+// tenantPrefix returns the directory of the chunks of the user written with schema v13+, which is the
+// xxhash of the user ID: the user ID can hold characters that aren't safe in object keys.
+func tenantPrefix(userID string) string {
+	return fmt.Sprintf("%016x/", xxhash.Sum64String(userID))
+}
+
 // pre-checksum
 func parseLegacyChunkID(userID, key string) (Chunk, error) {
 	parts := strings.Split(key, ":")
@@ -186,7 +232,26 @@ func parseNewerExternalKey(userID, key string) (Chunk, error) {
 	if userID != key[:userIdx] {
 		return Chunk{}, errors.WithStack(ErrWrongMetadata)
 	}
-	hexParts := key[userIdx+1:]
+	return parseNewerExternalKeyParts(userID, key, key[userIdx+1:])
+}
+
+// v13+
+func parseTenantPrefixedExternalKey(userID, key, prefix string) (Chunk, error) {
+	// Parse period
+	rest := key[len(prefix):]
+	periodIdx := strings.Index(rest, "/")
+	if periodIdx <= 0 || periodIdx+1 >= len(rest) {
+		return Chunk{}, errors.Wrap(errInvalidChunkID(key), "decoding period")
+	}
+	if _, err := strconv.ParseInt(rest[:periodIdx], 10, 64); err != nil {
+		return Chunk{}, errors.Wrap(err, "parsing period")
+	}
+	return parseNewerExternalKeyParts(userID, key, rest[periodIdx+1:])
+}
+
+// parseNewerExternalKeyParts parses the `<fprint>/<start>:<end>:<checksum>` part of a v12+ key,
+// followed by `:<encoding>` for v14+ keys.
+func parseNewerExternalKeyParts(userID, key, hexParts string) (Chunk, error) {
 	partsBytes := unsafeGetBytes(hexParts)
 	// Parse fingerprint
 	h, i := readOneHexPart(partsBytes)
@@ -218,6 +283,18 @@ func parseNewerExternalKey(userID, key string) (Chunk, error) {
 		return Chunk{}, errors.Wrap(err, "parsing through")
 	}
 	partsBytes = partsBytes[i+1:]
+	// Parse encoding (v14+)
+	var enc uint64
+	if h, i = readOneHexPart(partsBytes); i != 0 {
+		if i+1 >= len(partsBytes) {
+			return Chunk{}, errors.Wrap(errInvalidChunkID(key), "decoding encoding")
+		}
+		enc, err = strconv.ParseUint(unsafeGetString(partsBytes[i+1:]), 16, 8)
+		if err != nil || enc == 0 {
+			return Chunk{}, errors.Wrap(errInvalidChunkID(key), "parsing encoding")
+		}
+		partsBytes = h
+	}
 	// Parse checksum
 	checksum, err := strconv.ParseUint(unsafeGetString(partsBytes), 16, 64)
 	if err != nil {
@@ -232,6 +309,8 @@ func parseNewerExternalKey(userID, key string) (Chunk, error) {
 			Checksum:    uint32(checksum),
 		},
 		ChecksumSet: true,
+		Encoding:    prom_chunk.Encoding(enc),
+		EncodingSet: enc != 0,
 	}, nil
 }
 
@@ -272,6 +351,9 @@ func (c *Chunk) EncodeTo(buf *bytes.Buffer) error {
 	if buf == nil {
 		buf = bytes.NewBuffer(nil)
 	}
+	if data, ok := c.Data.(prom_chunk.SelfDescribingChunk); ok && data.SelfDescribing() {
+		return c.encodeSelfDescribing(buf, data)
+	}
 	// Write 4 empty bytes first - we will come back and put the len in here.
 	metadataLenBytes := [4]byte{}
 	if _, err := buf.Write(metadataLenBytes[:]); err != nil {
@@ -316,6 +398,23 @@ func (c *Chunk) EncodeTo(buf *bytes.Buffer) error {
 	return nil
 }
 
+// encodeSelfDescribing writes the chunk data holding the chunk metadata, without the header.
+func (c *Chunk) encodeSelfDescribing(buf *bytes.Buffer, data prom_chunk.SelfDescribingChunk) error {
+	json := jsoniter.ConfigFastest
+	metadata, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := data.MarshalWithMetadata(buf, c.Metric, metadata); err != nil {
+		return err
+	}
+
+	c.encoded = buf.Bytes()
+	c.ChecksumSet = true
+	c.Checksum = crc32.Checksum(c.encoded, castagnoliTable)
+	return nil
+}
+
 // Encoded returns the buffer created by Encoded()
 func (c *Chunk) Encoded() ([]byte, error) {
 	if c.encoded == nil {
@@ -346,6 +445,11 @@ func (c *Chunk) Decode(decodeContext *DecodeContext, input []byte) error {
 	if c.ChecksumSet && c.Checksum != crc32.Checksum(input, castagnoliTable) {
 		return errors.WithStack(ErrInvalidChecksum)
 	}
+	// Chunks with a header start with the length of their metadata, which is far
+	// below the value of the Parquet magic number.
+	if parquet.IsFile(input) {
+		return c.decodeParquet(input)
+	}
 
 	// Now unmarshal the chunk metadata.
 	r := bytes.NewReader(input)
@@ -366,16 +470,50 @@ func (c *Chunk) Decode(decodeContext *DecodeContext, input []byte) error {
 		return errors.Wrapf(ErrMetadataLength, "expected %d, got %d", metadataLen, metadataRead)
 	}
 
+	var dataLen uint32
+	if err := binary.Read(r, binary.BigEndian, &dataLen); err != nil {
+		return errors.Wrap(err, "when reading data length from chunk")
+	}
+
+	remainingData := input[len(input)-r.Len():]
+	if int(dataLen) != len(remainingData) {
+		return ErrDataLength
+	}
+
+	return c.decodeData(tempMetadata, input, remainingData[:int(dataLen)])
+}
+
+// decodeParquet decodes a chunk stored as a Parquet file, its metadata being
+// held by the metadata of the file.
+func (c *Chunk) decodeParquet(input []byte) error {
+	f, err := parquet.Open(input)
+	if err != nil {
+		return errors.Wrap(err, "when reading parquet chunk")
+	}
+	metadata, ok := f.Metadata(parquet.ChunkMetadataKey)
+	if !ok {
+		return errors.New("parquet chunk without chunk metadata")
+	}
+	var tempMetadata Chunk
+	if err := jsoniter.ConfigFastest.UnmarshalFromString(metadata, &tempMetadata); err != nil {
+		return errors.Wrap(err, "when decoding chunk metadata")
+	}
+	return c.decodeData(tempMetadata, input, input)
+}
+
+// decodeData checks the decoded metadata matches the chunk we expected before
+// unmarshalling the chunk data.
+func (c *Chunk) decodeData(metadata Chunk, input, data []byte) error {
 	// Next, confirm the chunks matches what we expected.  Easiest way to do this
 	// is to compare what the decoded data thinks its external ID would be, but
 	// we don't write the checksum to s3, so we have to copy the checksum in.
 	if c.ChecksumSet {
-		tempMetadata.Checksum, tempMetadata.ChecksumSet = c.Checksum, c.ChecksumSet
-		if !equalByKey(*c, tempMetadata) {
+		metadata.Checksum, metadata.ChecksumSet = c.Checksum, c.ChecksumSet
+		if !equalByKey(*c, metadata) {
 			return errors.WithStack(ErrWrongMetadata)
 		}
 	}
-	*c = tempMetadata
+	*c = metadata
 
 	// Older chunks always used DoubleDelta and did not write Encoding
 	// to JSON, so override if it has the zero value (Delta)
@@ -384,28 +522,21 @@ func (c *Chunk) Decode(decodeContext *DecodeContext, input []byte) error {
 	}
 
 	// Finally, unmarshal the actual chunk data.
+	var err error
 	c.Data, err = prom_chunk.NewForEncoding(c.Encoding)
 	if err != nil {
 		return errors.Wrap(err, "when creating new chunk")
 	}
 
-	var dataLen uint32
-	if err := binary.Read(r, binary.BigEndian, &dataLen); err != nil {
-		return errors.Wrap(err, "when reading data length from chunk")
-	}
-
 	c.encoded = input
-	remainingData := input[len(input)-r.Len():]
-	if int(dataLen) != len(remainingData) {
-		return ErrDataLength
-	}
-
-	return c.Data.UnmarshalFromBuf(remainingData[:int(dataLen)])
+	return c.Data.UnmarshalFromBuf(data)
 }
 
 func equalByKey(a, b Chunk) bool {
 	return a.UserID == b.UserID && a.Fingerprint == b.Fingerprint &&
-		a.From == b.From && a.Through == b.Through && a.Checksum == b.Checksum
+		a.From == b.From && a.Through == b.Through && a.Checksum == b.Checksum &&
+		// the encoding is only part of the v14+ keys.
+		(!a.EncodingSet || a.Encoding == b.Encoding)
 }
 
 // Samples returns all SamplePairs for the chunk.
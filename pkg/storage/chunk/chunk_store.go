@@ -119,6 +119,21 @@ func (c *baseStore) Stop() {
 	c.index.Stop()
 }
 
+// putContainers writes the containers of the chunks packed in them, which are
+// then indexed one by one.
+func (c *baseStore) putContainers(ctx context.Context, chunks []Chunk) error {
+	var contained []Chunk
+	for _, chunk := range chunks {
+		if chunk.Contained() {
+			contained = append(contained, chunk)
+		}
+	}
+	if len(contained) == 0 {
+		return nil
+	}
+	return c.fetcher.storage.PutChunks(ctx, contained)
+}
+
 // LabelValuesForMetricName retrieves all label values for a single label name and metric name.
 func (c *baseStore) LabelValuesForMetricName(ctx context.Context, userID string, from, through model.Time, metricName, labelName string, matchers ...*labels.Matcher) ([]string, error) {
 	log, ctx := spanlogger.New(ctx, "ChunkStore.LabelValues")
@@ -348,6 +363,6 @@ func (c *baseStore) convertChunkIDsToChunks(_ context.Context, userID string, ch
 	return chunkSet, nil
 }
 
-func (c *baseStore) GetChunkFetcher(_ model.Time) *Fetcher {
+func (c *baseStore) GetChunkFetcher(_ string, _ model.Time) *Fetcher {
 	return c.fetcher
 }
@@ -2,6 +2,7 @@ package chunk
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/rand"
 	"reflect"
@@ -75,7 +76,7 @@ func newTestChunkStoreConfigWithMockStorage(t require.TestingT, schemaCfg Schema
 	require.NoError(t, err)
 	flagext.DefaultValues(&tbmConfig)
 	storage := NewMockStorage()
-	tableManager, err := NewTableManager(tbmConfig, schemaCfg, maxChunkAge, storage, nil, nil, nil)
+	tableManager, err := NewTableManager(tbmConfig, schemaCfg, maxChunkAge, storage, nil, nil, nil, nil)
 	require.NoError(t, err)
 
 	err = tableManager.SyncTables(context.Background())
@@ -253,20 +254,33 @@ func TestChunkStore_LabelNamesForMetricName(t *testing.T) {
 
 	for _, tc := range []struct {
 		metricName string
+		matchers   []*labels.Matcher
 		expect     []string
 	}{
 		{
 			`foo`,
+			nil,
 			[]string{labels.MetricName, "bar", "flip", "toms"},
 		},
 		{
 			`bar`,
+			nil,
+			[]string{labels.MetricName, "bar", "toms"},
+		},
+		{
+			`foo`,
+			[]*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "bar", "beep")},
 			[]string{labels.MetricName, "bar", "toms"},
 		},
+		{
+			`foo`,
+			[]*labels.Matcher{labels.MustNewMatcher(labels.MatchRegexp, "flip", ".+")},
+			[]string{labels.MetricName, "bar", "flip", "toms"},
+		},
 	} {
 		for _, schema := range schemas {
 			for _, storeCase := range stores {
-				t.Run(fmt.Sprintf("%s / %s / %s ", tc.metricName, schema, storeCase.name), func(t *testing.T) {
+				t.Run(fmt.Sprintf("%s / %s / %s / %s", tc.metricName, tc.matchers, schema, storeCase.name), func(t *testing.T) {
 					t.Log("========= Running labelNames with metricName", tc.metricName, "with schema", schema)
 					storeCfg := storeCase.configFn()
 					store, _ := newTestChunkStoreConfig(t, schema, storeCfg)
@@ -284,7 +298,7 @@ func TestChunkStore_LabelNamesForMetricName(t *testing.T) {
 					}
 
 					// Query with ordinary time-range
-					labelNames1, err := store.LabelNamesForMetricName(ctx, userID, now.Add(-time.Hour), now, tc.metricName)
+					labelNames1, err := store.LabelNamesForMetricName(ctx, userID, now.Add(-time.Hour), now, tc.metricName, tc.matchers...)
 					require.NoError(t, err)
 
 					if !reflect.DeepEqual(tc.expect, labelNames1) {
@@ -292,7 +306,7 @@ func TestChunkStore_LabelNamesForMetricName(t *testing.T) {
 					}
 
 					// Pushing end of time-range into future should yield exact same resultset
-					labelNames2, err := store.LabelNamesForMetricName(ctx, userID, now.Add(-time.Hour), now.Add(time.Hour*24*10), tc.metricName)
+					labelNames2, err := store.LabelNamesForMetricName(ctx, userID, now.Add(-time.Hour), now.Add(time.Hour*24*10), tc.metricName, tc.matchers...)
 					require.NoError(t, err)
 
 					if !reflect.DeepEqual(tc.expect, labelNames2) {
@@ -300,7 +314,7 @@ func TestChunkStore_LabelNamesForMetricName(t *testing.T) {
 					}
 
 					// Query with both begin & end of time-range in future should yield empty resultset
-					labelNames3, err := store.LabelNamesForMetricName(ctx, userID, now.Add(time.Hour), now.Add(time.Hour*2), tc.metricName)
+					labelNames3, err := store.LabelNamesForMetricName(ctx, userID, now.Add(time.Hour), now.Add(time.Hour*2), tc.metricName, tc.matchers...)
 					require.NoError(t, err)
 					if len(labelNames3) != 0 {
 						t.Fatalf("%s: future query should yield empty resultset ... actually got %v label names: %#v",
@@ -614,7 +628,7 @@ func TestIndexCachingWorks(t *testing.T) {
 	store, _ := newTestChunkStoreConfig(t, "v9", storeCfg)
 	defer store.Stop()
 
-	storage := store.(CompositeStore).stores[0].Store.(*seriesStore).fetcher.storage.(*MockStorage)
+	storage := store.(*CompositeStore).stores[0].Store.(*seriesStore).fetcher.storage.(*MockStorage)
 
 	fooChunk1 := dummyChunkFor(model.Time(0).Add(15*time.Second), metric)
 	err := fooChunk1.Encode()
@@ -780,7 +794,7 @@ func TestDisableIndexDeduplication(t *testing.T) {
 			store, _ := newTestChunkStoreConfig(t, "v9", storeCfg)
 			defer store.Stop()
 
-			storage := store.(CompositeStore).stores[0].Store.(*seriesStore).fetcher.storage.(*MockStorage)
+			storage := store.(*CompositeStore).stores[0].Store.(*seriesStore).fetcher.storage.(*MockStorage)
 
 			fooChunk1 := dummyChunkFor(model.Time(0).Add(15*time.Second), metric)
 			err := fooChunk1.Encode()
@@ -807,3 +821,55 @@ func TestDisableIndexDeduplication(t *testing.T) {
 		})
 	}
 }
+
+type failingTableIndexClient struct {
+	IndexClient
+	failingTable string
+	writes       map[string]int
+}
+
+func (f *failingTableIndexClient) BatchWrite(ctx context.Context, batch WriteBatch) error {
+	tables := map[string]struct{}{}
+	for _, insert := range batch.(*mockWriteBatch).inserts {
+		tables[insert.tableName] = struct{}{}
+	}
+	for table := range tables {
+		f.writes[table]++
+		if table == f.failingTable {
+			return errors.New("failed to write")
+		}
+	}
+	return f.IndexClient.BatchWrite(ctx, batch)
+}
+
+func TestPutOne_PartialIndexWriteFailure(t *testing.T) {
+	ctx := context.Background()
+	metric := labels.Labels{
+		{Name: labels.MetricName, Value: "foo"},
+		{Name: "bar", Value: "baz"},
+	}
+	store, schemaCfg := newTestChunkStoreConfig(t, "v9", stores[1].configFn())
+	defer store.Stop()
+
+	// the chunk spans the first two weekly tables.
+	chunk := dummyChunkFor(model.TimeFromUnix(0).Add(7*24*time.Hour+30*time.Minute), metric)
+	firstTable := schemaCfg.Configs[0].IndexTables.TableFor(chunk.From)
+	secondTable := schemaCfg.Configs[0].IndexTables.TableFor(chunk.Through)
+	require.NotEqual(t, firstTable, secondTable)
+
+	seriesStore := store.(*CompositeStore).stores[0].Store.(*seriesStore)
+	index := &failingTableIndexClient{IndexClient: seriesStore.index, failingTable: secondTable, writes: map[string]int{}}
+	seriesStore.index = index
+
+	require.Error(t, store.Put(ctx, []Chunk{chunk}))
+	require.Equal(t, map[string]int{firstTable: 1, secondTable: 1}, index.writes)
+
+	// the retry only writes the entries of the table which failed.
+	index.failingTable = ""
+	require.NoError(t, store.Put(ctx, []Chunk{chunk}))
+	require.Equal(t, map[string]int{firstTable: 1, secondTable: 2}, index.writes)
+
+	// every entry is written once the index is written.
+	require.NoError(t, store.Put(ctx, []Chunk{chunk}))
+	require.Equal(t, map[string]int{firstTable: 1, secondTable: 2}, index.writes)
+}
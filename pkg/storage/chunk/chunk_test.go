@@ -108,6 +108,19 @@ func TestChunkCodec(t *testing.T) {
 			err:   ErrWrongMetadata,
 			f:     func(c *Chunk, _ []byte) { c.UserID = "foo" },
 		},
+
+		// Encoding of v14+ keys should match
+		{
+			chunk: dummy,
+			err:   ErrWrongMetadata,
+			f:     func(c *Chunk, _ []byte) { c.Encoding, c.EncodingSet = encoding.Bigchunk, true },
+		},
+
+		// Encoding of older keys isn't checked
+		{
+			chunk: dummy,
+			f:     func(c *Chunk, _ []byte) { c.Encoding = encoding.Bigchunk },
+		},
 	} {
 		t.Run(fmt.Sprintf("[%d]", i), func(t *testing.T) {
 			err := c.chunk.Encode()
@@ -228,12 +241,78 @@ func TestParseExternalKey(t *testing.T) {
 			ChecksumSet: true,
 		}},
 
+		{key: tenantPrefix(userID) + "7/2/270d8f00:270d8f00:f84c5745", chunk: Chunk{
+			ChunkRef: logproto.ChunkRef{
+				UserID:      userID,
+				Fingerprint: uint64(2),
+				From:        model.Time(655200000),
+				Through:     model.Time(655200000),
+				Checksum:    4165752645,
+			},
+			ChecksumSet: true,
+		}},
+
+		{key: tenantPrefix(userID) + "7/2/270d8f00:270d8f00:f84c5745:3@0a1b:1f:2a", chunk: Chunk{
+			ChunkRef: logproto.ChunkRef{
+				UserID:      userID,
+				Fingerprint: uint64(2),
+				From:        model.Time(655200000),
+				Through:     model.Time(655200000),
+				Checksum:    4165752645,
+			},
+			ChecksumSet: true,
+			Encoding:    encoding.Bigchunk,
+			EncodingSet: true,
+			Container:   ChunkContainer{ID: "0a1b", Offset: 31, Length: 42},
+		}},
+
 		{key: "invalidUserID/2:270d8f00:270d8f00:f84c5745", chunk: Chunk{}, err: ErrWrongMetadata},
+		{key: "invalidUserID/7/2/270d8f00:270d8f00:f84c5745", chunk: Chunk{}, err: ErrWrongMetadata},
 	} {
 		chunk, err := ParseExternalKey(userID, c.key)
 		require.Equal(t, c.err, errors.Cause(err))
 		require.Equal(t, c.chunk, chunk)
 	}
+
+	for _, key := range []string{
+		tenantPrefix(userID) + "7/2/270d8f00:270d8f00:f84c5745@0a1b:1f",
+		tenantPrefix(userID) + "7/2/270d8f00:270d8f00:f84c5745@:1f:2a",
+		tenantPrefix(userID) + "7/2/270d8f00:270d8f00:f84c5745@0a1b:1f:zz",
+	} {
+		_, err := ParseExternalKey(userID, key)
+		require.Error(t, err, key)
+	}
+}
+
+func TestChunkContainer(t *testing.T) {
+	schema := SchemaConfig{Configs: []PeriodConfig{{From: DayTime{Time: 0}, Schema: "v12"}}}
+	now := model.Now()
+	chunks := []Chunk{
+		dummyChunkFor(now, labelsForDummyChunks),
+		dummyChunkFor(now, labels.Labels{{Name: labels.MetricName, Value: "bar"}}),
+		dummyChunkFor(now, labels.Labels{{Name: labels.MetricName, Value: "baz"}}),
+	}
+	require.NoError(t, PackChunks(chunks))
+	require.Len(t, GroupByContainer(append(chunks, dummyChunk(now))), 1)
+
+	container, err := EncodeContainer(chunks)
+	require.NoError(t, err)
+	_, err = EncodeContainer(chunks[1:])
+	require.Error(t, err)
+
+	for _, c := range chunks {
+		require.True(t, c.Contained())
+		ref, err := ParseExternalKey(userID, schema.ExternalKey(c))
+		require.NoError(t, err)
+		require.Equal(t, c.Container, ref.Container)
+
+		require.NoError(t, ref.DecodeContained(NewDecodeContext(), container))
+		require.Equal(t, c.Metric, ref.Metric)
+		require.Equal(t, c.Container, ref.Container)
+	}
+
+	last := chunks[len(chunks)-1]
+	require.Equal(t, ErrDataLength, errors.Cause(last.DecodeContained(NewDecodeContext(), container[:len(container)-1])))
 }
 
 func TestChunksToMatrix(t *testing.T) {
@@ -465,6 +544,52 @@ func TestChunkKeys(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "Versioned key (post-v14)",
+			chunk: Chunk{
+				ChunkRef: logproto.ChunkRef{
+					Fingerprint: 100,
+					UserID:      "fake",
+					From:        model.TimeFromUnix(1000),
+					Through:     model.TimeFromUnix(5000),
+					Checksum:    12345,
+				},
+				ChecksumSet: true,
+				Encoding:    encoding.Bigchunk,
+				EncodingSet: true,
+			},
+			schemaCfg: SchemaConfig{
+				Configs: []PeriodConfig{
+					{
+						From:      DayTime{Time: 0},
+						Schema:    "v14",
+						RowShards: 16,
+					},
+				},
+			},
+		},
+		{
+			name: "Tenant prefixed key (post-v13)",
+			chunk: Chunk{
+				ChunkRef: logproto.ChunkRef{
+					Fingerprint: 100,
+					UserID:      "fake",
+					From:        model.TimeFromUnix(1000),
+					Through:     model.TimeFromUnix(5000),
+					Checksum:    12345,
+				},
+				ChecksumSet: true,
+			},
+			schemaCfg: SchemaConfig{
+				Configs: []PeriodConfig{
+					{
+						From:      DayTime{Time: 0},
+						Schema:    "v13",
+						RowShards: 16,
+					},
+				},
+			},
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			key := tc.schemaCfg.ExternalKey(tc.chunk)
@@ -3,7 +3,9 @@ package chunk
 import (
 	"context"
 	"errors"
+	"fmt"
 	"sort"
+	"sync"
 	"time"
 
 	"github.com/prometheus/common/model"
@@ -30,21 +32,32 @@ type Store interface {
 	// using the corresponding Fetcher (fetchers[i].FetchChunks(ctx, chunks[i], ...)
 	GetChunkRefs(ctx context.Context, userID string, from, through model.Time, matchers ...*labels.Matcher) ([][]Chunk, []*Fetcher, error)
 	LabelValuesForMetricName(ctx context.Context, userID string, from, through model.Time, metricName string, labelName string, matchers ...*labels.Matcher) ([]string, error)
-	LabelNamesForMetricName(ctx context.Context, userID string, from, through model.Time, metricName string) ([]string, error)
-	GetChunkFetcher(tm model.Time) *Fetcher
+	// LabelNamesForMetricName retrieves the label names of the series of the metric matching the matchers,
+	// which can include a query shard.
+	LabelNamesForMetricName(ctx context.Context, userID string, from, through model.Time, metricName string, matchers ...*labels.Matcher) ([]string, error)
+	GetChunkFetcher(userID string, tm model.Time) *Fetcher
 
 	Stop()
 }
 
+// PeriodConfigAdder is implemented by the stores which can add the periods
+// appended to the schema config while they are in use.
+type PeriodConfigAdder interface {
+	AddPeriodConfig(cfg PeriodConfig) error
+}
+
 // CompositeStore is a Store which delegates to various stores depending
-// on when they were activated.
+// on when they were activated. Periods can be added while it is in use.
 type CompositeStore struct {
+	mtx sync.RWMutex
 	compositeStore
 }
 
 type compositeStore struct {
 	cacheGenNumLoader CacheGenNumLoader
 	stores            []compositeStoreEntry
+	// tenantStores replace stores for the tenants having their own schema config.
+	tenantStores map[string][]compositeStoreEntry
 }
 
 type compositeStoreEntry struct {
@@ -54,8 +67,8 @@ type compositeStoreEntry struct {
 
 // NewCompositeStore creates a new Store which delegates to different stores depending
 // on time.
-func NewCompositeStore(cacheGenNumLoader CacheGenNumLoader) CompositeStore {
-	return CompositeStore{compositeStore{cacheGenNumLoader: cacheGenNumLoader}}
+func NewCompositeStore(cacheGenNumLoader CacheGenNumLoader) *CompositeStore {
+	return &CompositeStore{compositeStore: compositeStore{cacheGenNumLoader: cacheGenNumLoader}}
 }
 
 // AddPeriod adds the configuration for a period of time to the CompositeStore
@@ -69,26 +82,133 @@ func (c *CompositeStore) AddPeriod(storeCfg StoreConfig, cfg PeriodConfig, index
 }
 
 func (c *CompositeStore) addSchema(storeCfg StoreConfig, schemaCfg SchemaConfig, schema BaseSchema, start model.Time, index IndexClient, chunks Client, limits StoreLimits, chunksCache, writeDedupeCache cache.Cache) error {
-	var (
-		err   error
-		store Store
-	)
+	store, err := newStoreForSchema(storeCfg, schemaCfg, schema, index, chunks, limits, chunksCache, writeDedupeCache)
+	if err != nil {
+		return err
+	}
+	return c.addStore(start, store)
+}
 
-	switch s := schema.(type) {
-	case SeriesStoreSchema:
-		store, err = newSeriesStore(storeCfg, schemaCfg, s, index, chunks, limits, chunksCache, writeDedupeCache)
-	default:
-		err = errors.New("invalid schema type")
+// addStore appends a store, which must start after the ones already added.
+func (c *CompositeStore) addStore(start model.Time, store Store) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if n := len(c.stores); n > 0 && c.stores[n-1].start >= start {
+		return fmt.Errorf("the period starting at %s must start after the last period, starting at %s", start.Time().UTC(), c.stores[n-1].start.Time().UTC())
 	}
+	// readers keep using the slice they got, appending never modifies their view of it.
+	c.stores = append(c.stores, compositeStoreEntry{start: start, Store: store})
+	return nil
+}
+
+// AddTenantPeriod adds the configuration for a period of time of a tenant having its own
+// schema config. Once a period is added for a tenant, the periods added with AddPeriod are
+// not used for it anymore.
+func (c *CompositeStore) AddTenantPeriod(userID string, storeCfg StoreConfig, cfg PeriodConfig, index IndexClient, chunks Client, limits StoreLimits, chunksCache, writeDedupeCache cache.Cache) error {
+	schema, err := cfg.CreateSchema()
 	if err != nil {
 		return err
 	}
-	c.stores = append(c.stores, compositeStoreEntry{start: start, Store: store})
+
+	store, err := newStoreForSchema(storeCfg, SchemaConfig{Configs: []PeriodConfig{cfg}}, schema, index, chunks, limits, chunksCache, writeDedupeCache)
+	if err != nil {
+		return err
+	}
+	c.addTenantStore(userID, cfg.From.Time, store)
 	return nil
 }
 
+// AddSeriesIndexPeriod adds the configuration for a period of time whose index type
+// indexes chunks through a SeriesIndex instead of an IndexClient.
+func (c *CompositeStore) AddSeriesIndexPeriod(storeCfg StoreConfig, cfg PeriodConfig, index SeriesIndex, chunks Client, limits StoreLimits, chunksCache cache.Cache) error {
+	store, err := newSeriesIndexStore(storeCfg, SchemaConfig{Configs: []PeriodConfig{cfg}}, index, chunks, limits, chunksCache)
+	if err != nil {
+		return err
+	}
+	return c.addStore(cfg.From.Time, store)
+}
+
+// AddTenantSeriesIndexPeriod is like AddTenantPeriod, for index types using a SeriesIndex.
+func (c *CompositeStore) AddTenantSeriesIndexPeriod(userID string, storeCfg StoreConfig, cfg PeriodConfig, index SeriesIndex, chunks Client, limits StoreLimits, chunksCache cache.Cache) error {
+	store, err := newSeriesIndexStore(storeCfg, SchemaConfig{Configs: []PeriodConfig{cfg}}, index, chunks, limits, chunksCache)
+	if err != nil {
+		return err
+	}
+	c.addTenantStore(userID, cfg.From.Time, store)
+	return nil
+}
+
+func (c *CompositeStore) addTenantStore(userID string, start model.Time, store Store) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	// copy the map since readers could be using it.
+	tenantStores := make(map[string][]compositeStoreEntry, len(c.tenantStores)+1)
+	for u, stores := range c.tenantStores {
+		tenantStores[u] = stores
+	}
+	tenantStores[userID] = append(tenantStores[userID], compositeStoreEntry{start: start, Store: store})
+	c.tenantStores = tenantStores
+}
+
+// current returns the stores added so far.
+func (c *CompositeStore) current() compositeStore {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	return c.compositeStore
+}
+
+func (c *CompositeStore) Put(ctx context.Context, chunks []Chunk) error {
+	return c.current().Put(ctx, chunks)
+}
+
+func (c *CompositeStore) PutOne(ctx context.Context, from, through model.Time, chunk Chunk) error {
+	return c.current().PutOne(ctx, from, through, chunk)
+}
+
+func (c *CompositeStore) LabelValuesForMetricName(ctx context.Context, userID string, from, through model.Time, metricName string, labelName string, matchers ...*labels.Matcher) ([]string, error) {
+	return c.current().LabelValuesForMetricName(ctx, userID, from, through, metricName, labelName, matchers...)
+}
+
+func (c *CompositeStore) LabelNamesForMetricName(ctx context.Context, userID string, from, through model.Time, metricName string, matchers ...*labels.Matcher) ([]string, error) {
+	return c.current().LabelNamesForMetricName(ctx, userID, from, through, metricName, matchers...)
+}
+
+func (c *CompositeStore) GetChunkRefs(ctx context.Context, userID string, from, through model.Time, matchers ...*labels.Matcher) ([][]Chunk, []*Fetcher, error) {
+	return c.current().GetChunkRefs(ctx, userID, from, through, matchers...)
+}
+
+func (c *CompositeStore) GetChunkFetcher(userID string, tm model.Time) *Fetcher {
+	return c.current().GetChunkFetcher(userID, tm)
+}
+
+func (c *CompositeStore) Stop() {
+	c.current().Stop()
+}
+
+func newStoreForSchema(storeCfg StoreConfig, schemaCfg SchemaConfig, schema BaseSchema, index IndexClient, chunks Client, limits StoreLimits, chunksCache, writeDedupeCache cache.Cache) (Store, error) {
+	switch s := schema.(type) {
+	case SeriesStoreSchema:
+		return newSeriesStore(storeCfg, schemaCfg, s, index, chunks, limits, chunksCache, writeDedupeCache)
+	default:
+		return nil, errors.New("invalid schema type")
+	}
+}
+
+// storesFor returns the stores to use for the given tenant.
+func (c compositeStore) storesFor(userID string) []compositeStoreEntry {
+	if stores, ok := c.tenantStores[userID]; ok {
+		return stores
+	}
+	return c.stores
+}
+
 func (c compositeStore) Put(ctx context.Context, chunks []Chunk) error {
 	for _, chunk := range chunks {
+		if chunk.Contained() {
+			continue
+		}
 		err := c.forStores(ctx, chunk.UserID, chunk.From, chunk.Through, func(innerCtx context.Context, from, through model.Time, store Store) error {
 			return store.PutOne(innerCtx, from, through, chunk)
 		})
@@ -96,6 +216,17 @@ func (c compositeStore) Put(ctx context.Context, chunks []Chunk) error {
 			return err
 		}
 	}
+
+	// the chunks packed in a container all start in the same period, whose store
+	// writes them together.
+	for _, contained := range GroupByContainer(chunks) {
+		err := c.forStores(ctx, contained[0].UserID, contained[0].From, contained[0].From, func(innerCtx context.Context, _, _ model.Time, store Store) error {
+			return store.Put(innerCtx, contained)
+		})
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -120,10 +251,10 @@ func (c compositeStore) LabelValuesForMetricName(ctx context.Context, userID str
 }
 
 // LabelNamesForMetricName retrieves all label names for a metric name.
-func (c compositeStore) LabelNamesForMetricName(ctx context.Context, userID string, from, through model.Time, metricName string) ([]string, error) {
+func (c compositeStore) LabelNamesForMetricName(ctx context.Context, userID string, from, through model.Time, metricName string, matchers ...*labels.Matcher) ([]string, error) {
 	var result UniqueStrings
 	err := c.forStores(ctx, userID, from, through, func(innerCtx context.Context, from, through model.Time, store Store) error {
-		labelNames, err := store.LabelNamesForMetricName(innerCtx, userID, from, through, metricName)
+		labelNames, err := store.LabelNamesForMetricName(innerCtx, userID, from, through, metricName, matchers...)
 		if err != nil {
 			return err
 		}
@@ -154,17 +285,19 @@ func (c compositeStore) GetChunkRefs(ctx context.Context, userID string, from, t
 	return chunkIDs, fetchers, err
 }
 
-func (c compositeStore) GetChunkFetcher(tm model.Time) *Fetcher {
+func (c compositeStore) GetChunkFetcher(userID string, tm model.Time) *Fetcher {
+	stores := c.storesFor(userID)
+
 	// find the schema with the lowest start _after_ tm
-	j := sort.Search(len(c.stores), func(j int) bool {
-		return c.stores[j].start > tm
+	j := sort.Search(len(stores), func(j int) bool {
+		return stores[j].start > tm
 	})
 
 	// reduce it by 1 because we want a schema with start <= tm
 	j--
 
-	if 0 <= j && j < len(c.stores) {
-		return c.stores[j].GetChunkFetcher(tm)
+	if 0 <= j && j < len(stores) {
+		return stores[j].GetChunkFetcher(userID, tm)
 	}
 
 	return nil
@@ -174,30 +307,36 @@ func (c compositeStore) Stop() {
 	for _, store := range c.stores {
 		store.Stop()
 	}
+	for _, stores := range c.tenantStores {
+		for _, store := range stores {
+			store.Stop()
+		}
+	}
 }
 
 func (c compositeStore) forStores(ctx context.Context, userID string, from, through model.Time, callback func(innerCtx context.Context, from, through model.Time, store Store) error) error {
-	if len(c.stores) == 0 {
+	stores := c.storesFor(userID)
+	if len(stores) == 0 {
 		return nil
 	}
 
 	ctx = c.injectCacheGen(ctx, []string{userID})
 
 	// first, find the schema with the highest start _before or at_ from
-	i := sort.Search(len(c.stores), func(i int) bool {
-		return c.stores[i].start > from
+	i := sort.Search(len(stores), func(i int) bool {
+		return stores[i].start > from
 	})
 	if i > 0 {
 		i--
 	} else {
 		// This could happen if we get passed a sample from before 1970.
 		i = 0
-		from = c.stores[0].start
+		from = stores[0].start
 	}
 
 	// next, find the schema with the lowest start _after_ through
-	j := sort.Search(len(c.stores), func(j int) bool {
-		return c.stores[j].start > through
+	j := sort.Search(len(stores), func(j int) bool {
+		return stores[j].start > through
 	})
 
 	min := func(a, b model.Time) model.Time {
@@ -210,12 +349,12 @@ func (c compositeStore) forStores(ctx context.Context, userID string, from, thro
 	start := from
 	for ; i < j; i++ {
 		nextSchemaStarts := model.Latest
-		if i+1 < len(c.stores) {
-			nextSchemaStarts = c.stores[i+1].start
+		if i+1 < len(stores) {
+			nextSchemaStarts = stores[i+1].start
 		}
 
 		end := min(through, nextSchemaStarts-1)
-		err := callback(ctx, start, end, c.stores[i].Store)
+		err := callback(ctx, start, end, stores[i].Store)
 		if err != nil {
 			return err
 		}
@@ -31,11 +31,11 @@ func (m mockStore) GetChunkRefs(tx context.Context, userID string, from, through
 	return nil, nil, nil
 }
 
-func (m mockStore) LabelNamesForMetricName(ctx context.Context, userID string, from, through model.Time, metricName string) ([]string, error) {
+func (m mockStore) LabelNamesForMetricName(ctx context.Context, userID string, from, through model.Time, metricName string, matchers ...*labels.Matcher) ([]string, error) {
 	return nil, nil
 }
 
-func (m mockStore) GetChunkFetcher(tm model.Time) *Fetcher {
+func (m mockStore) GetChunkFetcher(userID string, tm model.Time) *Fetcher {
 	return nil
 }
 
@@ -178,7 +178,7 @@ func (m mockStoreLabel) LabelValuesForMetricName(ctx context.Context, userID str
 	return m.values, nil
 }
 
-func (m mockStoreLabel) LabelNamesForMetricName(ctx context.Context, userID string, from, through model.Time, metricName string) ([]string, error) {
+func (m mockStoreLabel) LabelNamesForMetricName(ctx context.Context, userID string, from, through model.Time, metricName string, matchers ...*labels.Matcher) ([]string, error) {
 	return m.values, nil
 }
 
@@ -230,7 +230,7 @@ type mockStoreGetChunkFetcher struct {
 	chunkFetcher *Fetcher
 }
 
-func (m mockStoreGetChunkFetcher) GetChunkFetcher(tm model.Time) *Fetcher {
+func (m mockStoreGetChunkFetcher) GetChunkFetcher(userID string, tm model.Time) *Fetcher {
 	return m.chunkFetcher
 }
 
@@ -273,7 +273,75 @@ func TestCompositeStore_GetChunkFetcher(t *testing.T) {
 		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
-			require.Same(t, tc.expectedFetcher, cs.GetChunkFetcher(tc.tm))
+			require.Same(t, tc.expectedFetcher, cs.GetChunkFetcher("fake", tc.tm))
 		})
 	}
 }
+
+func TestCompositeStore_TenantStores(t *testing.T) {
+	type result struct {
+		from, through model.Time
+		store         Store
+	}
+	cs := compositeStore{
+		stores: []compositeStoreEntry{
+			{model.TimeFromUnix(0), mockStore(1)},
+			{model.TimeFromUnix(100), mockStore(2)},
+		},
+		tenantStores: map[string][]compositeStoreEntry{
+			"overridden": {
+				{model.TimeFromUnix(0), mockStore(1)},
+				{model.TimeFromUnix(50), mockStore(3)},
+			},
+		},
+	}
+
+	for _, tc := range []struct {
+		userID string
+		want   []result
+	}{
+		{
+			userID: "default",
+			want: []result{
+				{model.TimeFromUnix(10), model.TimeFromUnix(100) - 1, mockStore(1)},
+				{model.TimeFromUnix(100), model.TimeFromUnix(150), mockStore(2)},
+			},
+		},
+		{
+			userID: "overridden",
+			want: []result{
+				{model.TimeFromUnix(10), model.TimeFromUnix(50) - 1, mockStore(1)},
+				{model.TimeFromUnix(50), model.TimeFromUnix(150), mockStore(3)},
+			},
+		},
+	} {
+		t.Run(tc.userID, func(t *testing.T) {
+			have := []result{}
+			err := cs.forStores(context.Background(), tc.userID, model.TimeFromUnix(10), model.TimeFromUnix(150), func(_ context.Context, from, through model.Time, store Store) error {
+				have = append(have, result{from, through, store})
+				return nil
+			})
+			require.NoError(t, err)
+			require.Equal(t, tc.want, have)
+		})
+	}
+}
+
+func TestCompositeStore_AddStore(t *testing.T) {
+	cs := &CompositeStore{}
+	require.NoError(t, cs.addStore(model.TimeFromUnix(0), mockStore(1)))
+	require.NoError(t, cs.addStore(model.TimeFromUnix(100), mockStore(2)))
+
+	// a reader keeps its view of the stores while periods are added.
+	view := cs.current()
+	require.Error(t, cs.addStore(model.TimeFromUnix(100), mockStore(3)))
+	require.Error(t, cs.addStore(model.TimeFromUnix(50), mockStore(3)))
+	require.NoError(t, cs.addStore(model.TimeFromUnix(200), mockStore(3)))
+
+	require.Len(t, view.stores, 2)
+	require.Equal(t, []compositeStoreEntry{
+		{model.TimeFromUnix(0), mockStore(1)},
+		{model.TimeFromUnix(100), mockStore(2)},
+		{model.TimeFromUnix(200), mockStore(3)},
+	}, cs.current().stores)
+}
@@ -0,0 +1,163 @@
+package chunk
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// containerSeparator separates the external key of a chunk from the reference to
+// its container, tenant IDs can't contain it.
+const containerSeparator = "@"
+
+// ChunkContainer references the object holding a chunk packed with other chunks
+// of the same tenant, to write a single object for many small chunks.
+type ChunkContainer struct {
+	ID string
+	// Offset and Length of the encoded chunk in the container.
+	Offset uint32
+	Length uint32
+}
+
+func (c ChunkContainer) String() string {
+	return fmt.Sprintf("%s%s:%x:%x", containerSeparator, c.ID, c.Offset, c.Length)
+}
+
+// Contained tells whether the chunk is packed in a container.
+func (c *Chunk) Contained() bool {
+	return c.Container.ID != ""
+}
+
+// IsContainedKey tells whether the external key is the one of a chunk packed in a container.
+func IsContainedKey(externalKey string) bool {
+	return strings.Contains(externalKey, containerSeparator)
+}
+
+// splitContainerKey splits the external key of a chunk packed in a container
+// `<key>@<container>:<offset>:<length>` into the key of the chunk and its container.
+func splitContainerKey(externalKey string) (string, ChunkContainer, error) {
+	i := strings.LastIndex(externalKey, containerSeparator)
+	if i < 0 {
+		return externalKey, ChunkContainer{}, nil
+	}
+	parts := strings.Split(externalKey[i+len(containerSeparator):], ":")
+	if len(parts) != 3 || parts[0] == "" {
+		return "", ChunkContainer{}, errInvalidChunkID(externalKey)
+	}
+	offset, err := strconv.ParseUint(parts[1], 16, 32)
+	if err != nil {
+		return "", ChunkContainer{}, errInvalidChunkID(externalKey)
+	}
+	length, err := strconv.ParseUint(parts[2], 16, 32)
+	if err != nil {
+		return "", ChunkContainer{}, errInvalidChunkID(externalKey)
+	}
+	return externalKey[:i], ChunkContainer{ID: parts[0], Offset: uint32(offset), Length: uint32(length)}, nil
+}
+
+// PackChunks packs the encoded chunks one after the other in a container, setting
+// the container of each chunk. The container is identified by the hash of its
+// content, so that writing it again on retries is idempotent.
+func PackChunks(chunks []Chunk) error {
+	h := sha256.New()
+	var offset uint64
+	for i := range chunks {
+		buf, err := chunks[i].Encoded()
+		if err != nil {
+			return err
+		}
+		if offset+uint64(len(buf)) > uint64(^uint32(0)) {
+			return errors.New("chunk container too large")
+		}
+		_, _ = h.Write(buf)
+		chunks[i].Container = ChunkContainer{Offset: uint32(offset), Length: uint32(len(buf))}
+		offset += uint64(len(buf))
+	}
+	id := hex.EncodeToString(h.Sum(nil)[:16])
+	for i := range chunks {
+		chunks[i].Container.ID = id
+	}
+	return nil
+}
+
+// GroupByContainer returns the chunks packed in each container, in the order of
+// their first chunk. The chunks not packed in a container are skipped.
+func GroupByContainer(chunks []Chunk) [][]Chunk {
+	var (
+		groups  [][]Chunk
+		indexes = map[string]int{}
+	)
+	for _, c := range chunks {
+		if !c.Contained() {
+			continue
+		}
+		i, ok := indexes[c.Container.ID]
+		if !ok {
+			i = len(groups)
+			indexes[c.Container.ID] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], c)
+	}
+	return groups
+}
+
+// EncodeContainer returns the content of the container the chunks are packed in,
+// which must all be given.
+func EncodeContainer(chunks []Chunk) ([]byte, error) {
+	sorted := make([]Chunk, len(chunks))
+	copy(sorted, chunks)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Container.Offset < sorted[j].Container.Offset })
+
+	var size int
+	for _, c := range sorted {
+		size += int(c.Container.Length)
+	}
+	buf := make([]byte, 0, size)
+	for _, c := range sorted {
+		if c.Container.ID != sorted[0].Container.ID {
+			return nil, fmt.Errorf("chunks of containers %s and %s can't be encoded together", sorted[0].Container.ID, c.Container.ID)
+		}
+		encoded, err := c.Encoded()
+		if err != nil {
+			return nil, err
+		}
+		if int(c.Container.Offset) != len(buf) || int(c.Container.Length) != len(encoded) {
+			return nil, fmt.Errorf("chunks missing from container %s", c.Container.ID)
+		}
+		buf = append(buf, encoded...)
+	}
+	return buf, nil
+}
+
+// DecodeContained decodes the chunk from the content of its container.
+func (c *Chunk) DecodeContained(decodeContext *DecodeContext, container []byte) error {
+	end := uint64(c.Container.Offset) + uint64(c.Container.Length)
+	if end > uint64(len(container)) {
+		return errors.Wrapf(ErrDataLength, "container %s of %d bytes ends before chunk at %d", c.Container.ID, len(container), end)
+	}
+	// copied so that the cached chunk doesn't hold the whole container.
+	buf := make([]byte, c.Container.Length)
+	copy(buf, container[c.Container.Offset:end])
+	return c.DecodeContainedRange(decodeContext, buf)
+}
+
+// DecodeContainedRange decodes the chunk from the range of its container holding it,
+// read at its offset.
+func (c *Chunk) DecodeContainedRange(decodeContext *DecodeContext, input []byte) error {
+	if len(input) != int(c.Container.Length) {
+		return errors.Wrapf(ErrDataLength, "read %d bytes of chunk of %d bytes from container %s", len(input), c.Container.Length, c.Container.ID)
+	}
+	// decoding replaces the chunk by its metadata, which don't hold the container.
+	ref := c.Container
+	if err := c.Decode(decodeContext, input); err != nil {
+		return err
+	}
+	c.Container = ref
+	return nil
+}
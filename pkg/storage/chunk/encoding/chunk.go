@@ -22,6 +22,7 @@ import (
 	"sort"
 
 	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
 	errs "github.com/weaveworks/common/errors"
 
 	"github.com/grafana/loki/pkg/prom1/storage/metric"
@@ -71,6 +72,16 @@ type Chunk interface {
 	Size() int
 }
 
+// SelfDescribingChunk is implemented by chunks which can be stored in a self-describing format,
+// readable by other tools, instead of being prefixed by the header of the chunk store.
+type SelfDescribingChunk interface {
+	Chunk
+	// SelfDescribing tells whether the chunk is stored in a self-describing format.
+	SelfDescribing() bool
+	// MarshalWithMetadata writes the chunk of the given series along with the metadata of the chunk store.
+	MarshalWithMetadata(w io.Writer, metric labels.Labels, metadata []byte) error
+}
+
 // Iterator enables efficient access to the content of a chunk. It is
 // generally not safe to use an Iterator concurrently with or after chunk
 // mutation.
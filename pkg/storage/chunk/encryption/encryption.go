@@ -0,0 +1,286 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/pkg/errors"
+
+	"github.com/grafana/loki/pkg/storage/chunk"
+)
+
+// Supported key providers
+const (
+	KeyProviderFile  = "file"
+	KeyProviderVault = "vault"
+	KeyProviderKMS   = "kms"
+)
+
+const (
+	dataKeySize     = 32
+	unwrappedKeys   = 1024
+	envelopeVersion = 1
+)
+
+// magic prefixes the encrypted objects, the objects without it are read as they are.
+var magic = []byte("lokienc")
+
+var errTruncated = errors.New("truncated encrypted object")
+
+// Config configures the client-side encryption of the chunks.
+type Config struct {
+	KeyProvider           string        `yaml:"key_provider"`
+	KeyFile               string        `yaml:"key_file"`
+	Vault                 VaultConfig   `yaml:"vault"`
+	KMS                   KMSConfig     `yaml:"kms"`
+	DataKeyRotationPeriod time.Duration `yaml:"data_key_rotation_period"`
+}
+
+// RegisterFlags registers flags.
+func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
+	prefix := "store.chunk-encryption."
+	f.StringVar(&cfg.KeyProvider, prefix+"key-provider", "", fmt.Sprintf("Provider of the key encrypting the data keys of the chunks, one of: %s, %s, %s. Empty to store the chunks unencrypted.", KeyProviderFile, KeyProviderVault, KeyProviderKMS))
+	f.StringVar(&cfg.KeyFile, prefix+"key-file", "", "Path to the file holding the 32 bytes key, raw or base64 encoded, of the file key provider.")
+	f.DurationVar(&cfg.DataKeyRotationPeriod, prefix+"data-key-rotation-period", time.Hour, "How long a data key encrypts the chunks before a new one is generated.")
+	cfg.Vault.RegisterFlagsWithPrefix(prefix+"vault.", f)
+	cfg.KMS.RegisterFlagsWithPrefix(prefix+"kms.", f)
+}
+
+// Validate validates the config.
+func (cfg *Config) Validate() error {
+	switch cfg.KeyProvider {
+	case "":
+		return nil
+	case KeyProviderFile:
+		if cfg.KeyFile == "" {
+			return errors.New("the key file is required by the file key provider")
+		}
+	case KeyProviderVault:
+		if err := cfg.Vault.Validate(); err != nil {
+			return err
+		}
+	case KeyProviderKMS:
+		if cfg.KMS.KeyID == "" {
+			return errors.New("the key ID is required by the KMS key provider")
+		}
+	default:
+		return fmt.Errorf("unsupported key provider %q", cfg.KeyProvider)
+	}
+	if cfg.DataKeyRotationPeriod <= 0 {
+		return errors.New("the rotation period of the data keys must be positive")
+	}
+	return nil
+}
+
+// KeyProvider encrypts the data keys with a key it never exposes.
+type KeyProvider interface {
+	WrapKey(ctx context.Context, key []byte) ([]byte, error)
+	UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+// NewKeyProvider makes the key provider of the config, nil if the encryption is disabled.
+func NewKeyProvider(cfg Config) (KeyProvider, error) {
+	switch cfg.KeyProvider {
+	case "":
+		return nil, nil
+	case KeyProviderFile:
+		return newFileKeyProvider(cfg.KeyFile)
+	case KeyProviderVault:
+		return newVaultKeyProvider(cfg.Vault)
+	case KeyProviderKMS:
+		return newKMSKeyProvider(cfg.KMS)
+	default:
+		return nil, fmt.Errorf("unsupported key provider %q", cfg.KeyProvider)
+	}
+}
+
+// Envelope encrypts the objects with data keys, stored in the objects encrypted by the key provider.
+//
+// An encrypted object is made of the magic, the version, the length of the encrypted data key as
+// an uvarint, the encrypted data key, the nonce and the object sealed with AES-256-GCM.
+type Envelope struct {
+	provider       KeyProvider
+	rotationPeriod time.Duration
+	now            func() time.Time
+
+	mtx        sync.Mutex
+	dataKey    cipher.AEAD
+	wrappedKey []byte
+	createdAt  time.Time
+
+	// the data keys are shared by many objects, they are only unwrapped once.
+	unwrapped *lru.Cache
+}
+
+// NewEnvelope makes an envelope using a data key for the rotation period.
+func NewEnvelope(provider KeyProvider, rotationPeriod time.Duration) *Envelope {
+	unwrapped, _ := lru.New(unwrappedKeys)
+	return &Envelope{
+		provider:       provider,
+		rotationPeriod: rotationPeriod,
+		now:            time.Now,
+		unwrapped:      unwrapped,
+	}
+}
+
+func (e *Envelope) currentKey(ctx context.Context) (cipher.AEAD, []byte, error) {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	if e.dataKey != nil && e.now().Sub(e.createdAt) < e.rotationPeriod {
+		return e.dataKey, e.wrappedKey, nil
+	}
+	key := make([]byte, dataKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, nil, err
+	}
+	wrapped, err := e.provider.WrapKey(ctx, key)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to encrypt the data key")
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	e.dataKey, e.wrappedKey, e.createdAt = aead, wrapped, e.now()
+	e.unwrapped.Add(string(wrapped), aead)
+	return aead, wrapped, nil
+}
+
+// Encrypt encrypts the object.
+func (e *Envelope) Encrypt(ctx context.Context, object []byte) ([]byte, error) {
+	aead, wrapped, err := e.currentKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 0, len(magic)+1+binary.MaxVarintLen64+len(wrapped)+aead.NonceSize()+len(object)+aead.Overhead())
+	buf = append(buf, magic...)
+	buf = append(buf, envelopeVersion)
+	var size [binary.MaxVarintLen64]byte
+	buf = append(buf, size[:binary.PutUvarint(size[:], uint64(len(wrapped)))]...)
+	buf = append(buf, wrapped...)
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	buf = append(buf, nonce...)
+	return aead.Seal(buf, nonce, object, nil), nil
+}
+
+// Decrypt decrypts the object, or returns it as it is when it isn't encrypted.
+func (e *Envelope) Decrypt(ctx context.Context, object []byte) ([]byte, error) {
+	if !IsEncrypted(object) {
+		return object, nil
+	}
+	buf := object[len(magic):]
+	if buf[0] != envelopeVersion {
+		return nil, fmt.Errorf("unsupported encryption version %d", buf[0])
+	}
+	buf = buf[1:]
+	size, n := binary.Uvarint(buf)
+	if n <= 0 || uint64(len(buf)-n) < size {
+		return nil, errTruncated
+	}
+	wrapped := buf[n : n+int(size)]
+	buf = buf[n+int(size):]
+
+	aead, err := e.unwrap(ctx, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	if len(buf) < aead.NonceSize() {
+		return nil, errTruncated
+	}
+	plain, err := aead.Open(nil, buf[:aead.NonceSize()], buf[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decrypt object")
+	}
+	return plain, nil
+}
+
+func (e *Envelope) unwrap(ctx context.Context, wrapped []byte) (cipher.AEAD, error) {
+	if aead, ok := e.unwrapped.Get(string(wrapped)); ok {
+		return aead.(cipher.AEAD), nil
+	}
+	key, err := e.provider.UnwrapKey(ctx, wrapped)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decrypt the data key")
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	e.unwrapped.Add(string(wrapped), aead)
+	return aead, nil
+}
+
+// IsEncrypted returns whether the object was encrypted by an envelope.
+func IsEncrypted(object []byte) bool {
+	return len(object) > len(magic) && bytes.HasPrefix(object, magic)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// ObjectClient encrypts the objects before writing them to the object client, and decrypts them when read.
+// The objects written unencrypted are read as they are. It can't read ranges of objects, the chunks packed
+// in containers are read with their whole container.
+type ObjectClient struct {
+	chunk.ObjectClient
+	envelope *Envelope
+}
+
+// NewObjectClient makes an object client encrypting the objects of the object client.
+func NewObjectClient(client chunk.ObjectClient, envelope *Envelope) *ObjectClient {
+	return &ObjectClient{ObjectClient: client, envelope: envelope}
+}
+
+// PutObject implements chunk.ObjectClient.
+func (c *ObjectClient) PutObject(ctx context.Context, objectKey string, object io.ReadSeeker) error {
+	buf, err := ioutil.ReadAll(object)
+	if err != nil {
+		return err
+	}
+	encrypted, err := c.envelope.Encrypt(ctx, buf)
+	if err != nil {
+		return err
+	}
+	return c.ObjectClient.PutObject(ctx, objectKey, bytes.NewReader(encrypted))
+}
+
+// GetObject implements chunk.ObjectClient.
+func (c *ObjectClient) GetObject(ctx context.Context, objectKey string) (io.ReadCloser, int64, error) {
+	reader, _, err := c.ObjectClient.GetObject(ctx, objectKey)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer reader.Close()
+
+	buf, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, 0, err
+	}
+	plain, err := c.envelope.Decrypt(ctx, buf)
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "object %s", objectKey)
+	}
+	return ioutil.NopCloser(bytes.NewReader(plain)), int64(len(plain)), nil
+}
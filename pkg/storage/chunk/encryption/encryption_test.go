@@ -0,0 +1,211 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+	"github.com/grafana/dskit/flagext"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/pkg/storage/chunk"
+)
+
+func newTestKeyFile(t *testing.T) string {
+	key := make([]byte, dataKeySize)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "key")
+	require.NoError(t, ioutil.WriteFile(path, []byte(base64.StdEncoding.EncodeToString(key)+"\n"), 0600))
+	return path
+}
+
+type countingKeyProvider struct {
+	KeyProvider
+	wraps, unwraps int
+}
+
+func (p *countingKeyProvider) WrapKey(ctx context.Context, key []byte) ([]byte, error) {
+	p.wraps++
+	return p.KeyProvider.WrapKey(ctx, key)
+}
+
+func (p *countingKeyProvider) UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	p.unwraps++
+	return p.KeyProvider.UnwrapKey(ctx, wrapped)
+}
+
+func TestEnvelope(t *testing.T) {
+	ctx := context.Background()
+	fileProvider, err := NewKeyProvider(Config{KeyProvider: KeyProviderFile, KeyFile: newTestKeyFile(t)})
+	require.NoError(t, err)
+	provider := &countingKeyProvider{KeyProvider: fileProvider}
+
+	now := time.Unix(0, 0)
+	envelope := NewEnvelope(provider, time.Hour)
+	envelope.now = func() time.Time { return now }
+
+	first, err := envelope.Encrypt(ctx, []byte("first chunk"))
+	require.NoError(t, err)
+	require.True(t, IsEncrypted(first))
+	require.False(t, bytes.Contains(first, []byte("first chunk")))
+	second, err := envelope.Encrypt(ctx, []byte("second chunk"))
+	require.NoError(t, err)
+	// the data key is used until it is rotated.
+	require.Equal(t, 1, provider.wraps)
+
+	now = now.Add(time.Hour)
+	third, err := envelope.Encrypt(ctx, []byte("third chunk"))
+	require.NoError(t, err)
+	require.Equal(t, 2, provider.wraps)
+
+	// a new envelope only unwraps each data key once.
+	envelope = NewEnvelope(provider, time.Hour)
+	for _, tc := range []struct {
+		encrypted []byte
+		expected  string
+	}{
+		{first, "first chunk"},
+		{second, "second chunk"},
+		{third, "third chunk"},
+		{[]byte("unencrypted chunk"), "unencrypted chunk"},
+	} {
+		plain, err := envelope.Decrypt(ctx, tc.encrypted)
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, string(plain))
+	}
+	require.Equal(t, 2, provider.unwraps)
+
+	// the objects changed in the store fail to decrypt.
+	first[len(first)-1] ^= 1
+	_, err = envelope.Decrypt(ctx, first)
+	require.Error(t, err)
+	_, err = envelope.Decrypt(ctx, second[:len(magic)+3])
+	require.Error(t, err)
+}
+
+func TestObjectClient(t *testing.T) {
+	ctx := context.Background()
+	provider, err := NewKeyProvider(Config{KeyProvider: KeyProviderFile, KeyFile: newTestKeyFile(t)})
+	require.NoError(t, err)
+	store := chunk.NewMockStorage()
+	client := NewObjectClient(store, NewEnvelope(provider, time.Hour))
+
+	// the chunks written before the encryption was enabled are still readable.
+	require.NoError(t, store.PutObject(ctx, "fake/old", bytes.NewReader([]byte("old chunk"))))
+	require.NoError(t, client.PutObject(ctx, "fake/new", bytes.NewReader([]byte("new chunk"))))
+
+	reader, _, err := store.GetObject(ctx, "fake/new")
+	require.NoError(t, err)
+	stored, err := ioutil.ReadAll(reader)
+	require.NoError(t, err)
+	require.True(t, IsEncrypted(stored))
+
+	for key, expected := range map[string]string{"fake/old": "old chunk", "fake/new": "new chunk"} {
+		reader, size, err := client.GetObject(ctx, key)
+		require.NoError(t, err)
+		buf, err := ioutil.ReadAll(reader)
+		require.NoError(t, err)
+		require.Equal(t, expected, string(buf))
+		require.Equal(t, int64(len(expected)), size)
+	}
+
+	// the ranges of the encrypted objects can't be read.
+	var objectClient chunk.ObjectClient = client
+	_, ok := objectClient.(chunk.RangeObjectClient)
+	require.False(t, ok)
+}
+
+func TestVaultKeyProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "token", r.Header.Get("X-Vault-Token"))
+		var req map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		switch r.URL.Path {
+		case "/v1/transit/encrypt/loki":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]string{"ciphertext": "vault:v1:" + req["plaintext"]}})
+		case "/v1/transit/decrypt/loki":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]string{"plaintext": strings.TrimPrefix(req["ciphertext"], "vault:v1:")}})
+		default:
+			http.Error(w, "unknown path", http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	cfg := VaultConfig{Address: server.URL, Token: flagext.Secret{Value: "token"}, MountPath: "transit", KeyName: "loki", Timeout: time.Second}
+	provider, err := NewKeyProvider(Config{KeyProvider: KeyProviderVault, Vault: cfg})
+	require.NoError(t, err)
+	wrapped, err := provider.WrapKey(context.Background(), []byte("data key"))
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(string(wrapped), "vault:v1:"))
+	key, err := provider.UnwrapKey(context.Background(), wrapped)
+	require.NoError(t, err)
+	require.Equal(t, "data key", string(key))
+
+	cfg.KeyName = "unknown"
+	provider, err = NewKeyProvider(Config{KeyProvider: KeyProviderVault, Vault: cfg})
+	require.NoError(t, err)
+	_, err = provider.WrapKey(context.Background(), []byte("data key"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "404")
+}
+
+type fakeKMS struct {
+	kmsiface.KMSAPI
+}
+
+func (f *fakeKMS) EncryptWithContext(_ aws.Context, in *kms.EncryptInput, _ ...request.Option) (*kms.EncryptOutput, error) {
+	return &kms.EncryptOutput{CiphertextBlob: append([]byte(aws.StringValue(in.KeyId)+":"), in.Plaintext...), KeyId: in.KeyId}, nil
+}
+
+func (f *fakeKMS) DecryptWithContext(_ aws.Context, in *kms.DecryptInput, _ ...request.Option) (*kms.DecryptOutput, error) {
+	return &kms.DecryptOutput{Plaintext: bytes.SplitN(in.CiphertextBlob, []byte(":"), 2)[1]}, nil
+}
+
+func TestKMSKeyProvider(t *testing.T) {
+	provider := &kmsKeyProvider{keyID: "alias/loki", kms: &fakeKMS{}}
+	envelope := NewEnvelope(provider, time.Hour)
+	encrypted, err := envelope.Encrypt(context.Background(), []byte("chunk"))
+	require.NoError(t, err)
+	require.True(t, bytes.Contains(encrypted, []byte("alias/loki:")))
+
+	plain, err := NewEnvelope(provider, time.Hour).Decrypt(context.Background(), encrypted)
+	require.NoError(t, err)
+	require.Equal(t, "chunk", string(plain))
+}
+
+func TestConfig_Validate(t *testing.T) {
+	for _, tc := range []struct {
+		cfg Config
+		err bool
+	}{
+		{cfg: Config{}},
+		{cfg: Config{KeyProvider: KeyProviderFile, KeyFile: "key", DataKeyRotationPeriod: time.Hour}},
+		{cfg: Config{KeyProvider: KeyProviderFile, DataKeyRotationPeriod: time.Hour}, err: true},
+		{cfg: Config{KeyProvider: KeyProviderFile, KeyFile: "key"}, err: true},
+		{cfg: Config{KeyProvider: KeyProviderVault, Vault: VaultConfig{Address: "http://vault", KeyName: "loki"}, DataKeyRotationPeriod: time.Hour}},
+		{cfg: Config{KeyProvider: KeyProviderVault, Vault: VaultConfig{Address: "http://vault"}, DataKeyRotationPeriod: time.Hour}, err: true},
+		{cfg: Config{KeyProvider: KeyProviderKMS, KMS: KMSConfig{KeyID: "alias/loki"}, DataKeyRotationPeriod: time.Hour}},
+		{cfg: Config{KeyProvider: KeyProviderKMS, DataKeyRotationPeriod: time.Hour}, err: true},
+		{cfg: Config{KeyProvider: "unknown"}, err: true},
+	} {
+		if tc.err {
+			require.Error(t, tc.cfg.Validate(), "%+v", tc.cfg)
+		} else {
+			require.NoError(t, tc.cfg.Validate(), "%+v", tc.cfg)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+	"github.com/grafana/dskit/flagext"
+	"github.com/pkg/errors"
+)
+
+// fileKeyProvider encrypts the data keys with a key read from a file.
+type fileKeyProvider struct {
+	aead cipher.AEAD
+}
+
+func newFileKeyProvider(path string) (*fileKeyProvider, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the key file")
+	}
+	key := buf
+	if len(key) != dataKeySize {
+		key, err = base64.StdEncoding.DecodeString(strings.TrimSpace(string(buf)))
+		if err != nil || len(key) != dataKeySize {
+			return nil, fmt.Errorf("the key file must hold a %d bytes key, raw or base64 encoded", dataKeySize)
+		}
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &fileKeyProvider{aead: aead}, nil
+}
+
+func (p *fileKeyProvider) WrapKey(_ context.Context, key []byte) ([]byte, error) {
+	nonce := make([]byte, p.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return p.aead.Seal(nonce, nonce, key, nil), nil
+}
+
+func (p *fileKeyProvider) UnwrapKey(_ context.Context, wrapped []byte) ([]byte, error) {
+	if len(wrapped) < p.aead.NonceSize() {
+		return nil, errTruncated
+	}
+	return p.aead.Open(nil, wrapped[:p.aead.NonceSize()], wrapped[p.aead.NonceSize():], nil)
+}
+
+// VaultConfig configures the Vault transit secrets engine encrypting the data keys.
+type VaultConfig struct {
+	Address   string         `yaml:"address"`
+	Token     flagext.Secret `yaml:"token"`
+	MountPath string         `yaml:"mount_path"`
+	KeyName   string         `yaml:"key_name"`
+	Timeout   time.Duration  `yaml:"timeout"`
+}
+
+// RegisterFlagsWithPrefix registers flags with prefix.
+func (cfg *VaultConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.StringVar(&cfg.Address, prefix+"address", "", "Address of the Vault server, e.g. https://vault:8200.")
+	f.Var(&cfg.Token, prefix+"token", "Token authenticating to Vault.")
+	f.StringVar(&cfg.MountPath, prefix+"mount-path", "transit", "Path the transit secrets engine is mounted at.")
+	f.StringVar(&cfg.KeyName, prefix+"key-name", "", "Name of the transit key encrypting the data keys.")
+	f.DurationVar(&cfg.Timeout, prefix+"timeout", 10*time.Second, "Timeout of the requests to Vault.")
+}
+
+// Validate validates the config.
+func (cfg *VaultConfig) Validate() error {
+	if cfg.Address == "" || cfg.KeyName == "" {
+		return errors.New("the address and the key name are required by the vault key provider")
+	}
+	return nil
+}
+
+// vaultKeyProvider encrypts the data keys with the transit secrets engine of Vault.
+type vaultKeyProvider struct {
+	cfg    VaultConfig
+	client *http.Client
+}
+
+func newVaultKeyProvider(cfg VaultConfig) (*vaultKeyProvider, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &vaultKeyProvider{cfg: cfg, client: &http.Client{Timeout: cfg.Timeout}}, nil
+}
+
+func (p *vaultKeyProvider) WrapKey(ctx context.Context, key []byte) ([]byte, error) {
+	var resp struct {
+		Data struct {
+			Ciphertext string `json:"ciphertext"`
+		} `json:"data"`
+	}
+	if err := p.do(ctx, "encrypt", map[string]string{"plaintext": base64.StdEncoding.EncodeToString(key)}, &resp); err != nil {
+		return nil, err
+	}
+	return []byte(resp.Data.Ciphertext), nil
+}
+
+func (p *vaultKeyProvider) UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	var resp struct {
+		Data struct {
+			Plaintext string `json:"plaintext"`
+		} `json:"data"`
+	}
+	if err := p.do(ctx, "decrypt", map[string]string{"ciphertext": string(wrapped)}, &resp); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(resp.Data.Plaintext)
+}
+
+func (p *vaultKeyProvider) do(ctx context.Context, operation string, body interface{}, out interface{}) error {
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	url := fmt.Sprintf("%s/v1/%s/%s/%s", strings.TrimSuffix(p.cfg.Address, "/"), strings.Trim(p.cfg.MountPath, "/"), operation, p.cfg.KeyName)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Vault-Token", p.cfg.Token.Value)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("vault %s failed with status %d: %s", operation, resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// KMSConfig configures the AWS KMS key encrypting the data keys.
+type KMSConfig struct {
+	KeyID  string `yaml:"key_id"`
+	Region string `yaml:"region"`
+}
+
+// RegisterFlagsWithPrefix registers flags with prefix.
+func (cfg *KMSConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.StringVar(&cfg.KeyID, prefix+"key-id", "", "ID, ARN or alias of the KMS key encrypting the data keys.")
+	f.StringVar(&cfg.Region, prefix+"region", "", "AWS region of the KMS key. Defaults to the region of the AWS environment.")
+}
+
+// kmsKeyProvider encrypts the data keys with an AWS KMS key.
+type kmsKeyProvider struct {
+	keyID string
+	kms   kmsiface.KMSAPI
+}
+
+func newKMSKeyProvider(cfg KMSConfig) (*kmsKeyProvider, error) {
+	awsCfg := aws.NewConfig()
+	if cfg.Region != "" {
+		awsCfg = awsCfg.WithRegion(cfg.Region)
+	}
+	sess, err := session.NewSession(awsCfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create the AWS session")
+	}
+	return &kmsKeyProvider{keyID: cfg.KeyID, kms: kms.New(sess)}, nil
+}
+
+func (p *kmsKeyProvider) WrapKey(ctx context.Context, key []byte) ([]byte, error) {
+	out, err := p.kms.EncryptWithContext(ctx, &kms.EncryptInput{KeyId: aws.String(p.keyID), Plaintext: key})
+	if err != nil {
+		return nil, err
+	}
+	return out.CiphertextBlob, nil
+}
+
+// UnwrapKey doesn't pass the key ID, so that the data keys encrypted by a previous key can be decrypted.
+func (p *kmsKeyProvider) UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	out, err := p.kms.DecryptWithContext(ctx, &kms.DecryptInput{CiphertextBlob: wrapped})
+	if err != nil {
+		return nil, err
+	}
+	return out.Plaintext, nil
+}
@@ -33,8 +33,10 @@ var BenchmarkLabels = labels.Labels{
 	{Name: "pod_name", Value: "some-other-name-5j8s8"},
 }
 
-// DefaultSchemaConfig creates a simple schema config for testing
+// DefaultSchemaConfig creates a simple schema config for testing, starting on the day of from.
 func DefaultSchemaConfig(store, schema string, from model.Time) SchemaConfig {
+	// the period must start on a day, since its tables are weekly.
+	from -= from % model.Time(millisecondsInDay)
 	s := SchemaConfig{
 		Configs: []PeriodConfig{{
 			IndexType: store,
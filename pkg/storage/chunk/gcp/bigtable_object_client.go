@@ -55,7 +55,7 @@ func (s *bigtableObjectClient) PutChunks(ctx context.Context, chunks []chunk.Chu
 			return err
 		}
 		key := s.schemaCfg.ExternalKey(chunks[i])
-		tableName, err := s.schemaCfg.ChunkTableFor(chunks[i].From)
+		tableName, err := s.schemaCfg.ChunkTableFor(chunks[i].UserID, chunks[i].From)
 		if err != nil {
 			return err
 		}
@@ -89,7 +89,7 @@ func (s *bigtableObjectClient) GetChunks(ctx context.Context, input []chunk.Chun
 	chunks := map[string]map[string]chunk.Chunk{}
 	keys := map[string]bigtable.RowList{}
 	for _, c := range input {
-		tableName, err := s.schemaCfg.ChunkTableFor(c.From)
+		tableName, err := s.schemaCfg.ChunkTableFor(c.UserID, c.From)
 		if err != nil {
 			return nil, err
 		}
@@ -170,7 +170,7 @@ func (s *bigtableObjectClient) DeleteChunk(ctx context.Context, userID, chunkID
 		return err
 	}
 
-	tableName, err := s.schemaCfg.ChunkTableFor(chunkRef.From)
+	tableName, err := s.schemaCfg.ChunkTableFor(chunkRef.UserID, chunkRef.From)
 	if err != nil {
 		return err
 	}
@@ -4,11 +4,16 @@ import (
 	"context"
 	"flag"
 	"io"
+	"net"
+	"net/http"
 	"time"
 
 	"cloud.google.com/go/storage"
+	"github.com/grafana/dskit/backoff"
+	"github.com/grafana/dskit/flagext"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 
@@ -28,11 +33,17 @@ type GCSObjectClient struct {
 
 // GCSConfig is config for the GCS Chunk Client.
 type GCSConfig struct {
-	BucketName       string        `yaml:"bucket_name"`
-	ChunkBufferSize  int           `yaml:"chunk_buffer_size"`
-	RequestTimeout   time.Duration `yaml:"request_timeout"`
-	EnableOpenCensus bool          `yaml:"enable_opencensus"`
-	EnableHTTP2      bool          `yaml:"enable_http2"`
+	BucketName             string        `yaml:"bucket_name"`
+	ChunkBufferSize        int           `yaml:"chunk_buffer_size"`
+	ChunkedUploadThreshold int           `yaml:"chunked_upload_threshold"`
+	RequestTimeout         time.Duration `yaml:"request_timeout"`
+	EnableOpenCensus       bool          `yaml:"enable_opencensus"`
+	EnableHTTP2            bool          `yaml:"enable_http2"`
+
+	ImpersonateServiceAccount string                 `yaml:"impersonate_service_account"`
+	ImpersonationDelegates    flagext.StringSliceCSV `yaml:"impersonation_delegates"`
+
+	BackoffConfig backoff.Config `yaml:"backoff_config"`
 
 	Insecure bool `yaml:"-"`
 }
@@ -46,9 +57,15 @@ func (cfg *GCSConfig) RegisterFlags(f *flag.FlagSet) {
 func (cfg *GCSConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
 	f.StringVar(&cfg.BucketName, prefix+"gcs.bucketname", "", "Name of GCS bucket. Please refer to https://cloud.google.com/docs/authentication/production for more information about how to configure authentication.")
 	f.IntVar(&cfg.ChunkBufferSize, prefix+"gcs.chunk-buffer-size", 0, "The size of the buffer that GCS client for each PUT request. 0 to disable buffering.")
+	f.IntVar(&cfg.ChunkedUploadThreshold, prefix+"gcs.chunked-upload-threshold", 0, "Size in bytes of the objects up to which they are uploaded in a single request, the bigger objects are uploaded in chunks of the buffer size, which are retried on their own. 0 to always upload in chunks of the buffer size.")
 	f.DurationVar(&cfg.RequestTimeout, prefix+"gcs.request-timeout", 0, "The duration after which the requests to GCS should be timed out.")
 	f.BoolVar(&cfg.EnableOpenCensus, prefix+"gcs.enable-opencensus", true, "Enable OpenCensus (OC) instrumentation for all requests.")
 	f.BoolVar(&cfg.EnableHTTP2, prefix+"gcs.enable-http2", true, "Enable HTTP2 connections.")
+	f.StringVar(&cfg.ImpersonateServiceAccount, prefix+"gcs.impersonate-service-account", "", "Email of the service account to impersonate with the default credentials, which need the Service Account Token Creator role on it, so that no service account key needs to be exported.")
+	f.Var(&cfg.ImpersonationDelegates, prefix+"gcs.impersonation-delegates", "Comma separated emails of the service accounts delegating the impersonation, from the one the default credentials can impersonate to the one which can impersonate the target.")
+	f.DurationVar(&cfg.BackoffConfig.MinBackoff, prefix+"gcs.min-backoff", 100*time.Millisecond, "Minimum backoff time when retrying the GCS requests failing with a transient error.")
+	f.DurationVar(&cfg.BackoffConfig.MaxBackoff, prefix+"gcs.max-backoff", 10*time.Second, "Maximum backoff time when retrying the GCS requests failing with a transient error.")
+	f.IntVar(&cfg.BackoffConfig.MaxRetries, prefix+"gcs.max-retries", 5, "Maximum number of times the GCS requests failing with a transient error are tried, on top of the retries of the GCS client. 0 to disable.")
 }
 
 // NewGCSObjectClient makes a new chunk.Client that writes chunks to GCS.
@@ -77,7 +94,15 @@ func newGCSObjectClient(ctx context.Context, cfg GCSConfig, hedgingCfg hedging.C
 
 func newBucketHandle(ctx context.Context, cfg GCSConfig, hedgingCfg hedging.Config, enableHTTP2, hedging bool, clientFactory ClientFactory) (*storage.BucketHandle, error) {
 	var opts []option.ClientOption
-	httpClient, err := gcsInstrumentation(ctx, storage.ScopeReadWrite, cfg.Insecure, enableHTTP2)
+	authOption := option.WithScopes(storage.ScopeReadWrite)
+	if cfg.ImpersonateServiceAccount != "" {
+		tokenSource, err := newImpersonatedTokenSource(ctx, cfg.ImpersonateServiceAccount, cfg.ImpersonationDelegates, storage.ScopeReadWrite)
+		if err != nil {
+			return nil, err
+		}
+		authOption = option.WithTokenSource(tokenSource)
+	}
+	httpClient, err := gcsInstrumentation(ctx, authOption, cfg.Insecure, enableHTTP2)
 	if err != nil {
 		return nil, err
 	}
@@ -112,7 +137,7 @@ func (s *GCSObjectClient) GetObject(ctx context.Context, objectKey string) (io.R
 		ctx, cancel = context.WithTimeout(ctx, s.cfg.RequestTimeout)
 	}
 
-	rc, size, err := s.getObject(ctx, objectKey)
+	rc, size, err := s.getObject(ctx, objectKey, 0, -1)
 	if err != nil {
 		// cancel the context if there is an error.
 		cancel()
@@ -122,32 +147,87 @@ func (s *GCSObjectClient) GetObject(ctx context.Context, objectKey string) (io.R
 	return util.NewReadCloserWithContextCancelFunc(rc, cancel), size, nil
 }
 
-func (s *GCSObjectClient) getObject(ctx context.Context, objectKey string) (rc io.ReadCloser, size int64, err error) {
-	reader, err := s.getsBuckets.Object(objectKey).NewReader(ctx)
+// GetObjectRange returns a reader for length bytes from offset of the specified object key from the configured GCS bucket.
+func (s *GCSObjectClient) GetObjectRange(ctx context.Context, objectKey string, offset, length int64) (io.ReadCloser, error) {
+	var cancel context.CancelFunc = func() {}
+	if s.cfg.RequestTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, s.cfg.RequestTimeout)
+	}
+
+	rc, _, err := s.getObject(ctx, objectKey, offset, length)
 	if err != nil {
-		return nil, 0, err
+		cancel()
+		return nil, err
 	}
+	return util.NewReadCloserWithContextCancelFunc(rc, cancel), nil
+}
 
-	return reader, reader.Attrs.Size, nil
+func (s *GCSObjectClient) getObject(ctx context.Context, objectKey string, offset, length int64) (rc io.ReadCloser, size int64, err error) {
+	err = s.retry(ctx, func() error {
+		reader, err := s.getsBuckets.Object(objectKey).NewRangeReader(ctx, offset, length)
+		if err != nil {
+			return err
+		}
+		rc, size = reader, reader.Attrs.Size
+		return nil
+	})
+	return rc, size, err
 }
 
 // PutObject puts the specified bytes into the configured GCS bucket at the provided key
 func (s *GCSObjectClient) PutObject(ctx context.Context, objectKey string, object io.ReadSeeker) error {
-	writer := s.defaultBucket.Object(objectKey).NewWriter(ctx)
+	chunkSize, err := s.uploadChunkSize(object)
+	if err != nil {
+		return err
+	}
+	return s.retry(ctx, func() error {
+		if _, err := object.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		// the writer is canceled with its context when the copy fails, not to upload a partial object.
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		writer := s.defaultBucket.Object(objectKey).NewWriter(ctx)
+		writer.ChunkSize = chunkSize
+
+		if _, err := io.Copy(writer, object); err != nil {
+			cancel()
+			_ = writer.Close()
+			return err
+		}
+		return writer.Close()
+	})
+}
+
+// uploadChunkSize returns the size of the chunks the object is uploaded in.
+func (s *GCSObjectClient) uploadChunkSize(object io.Seeker) (int, error) {
 	// Default GCSChunkSize is 8M and for each call, 8M is allocated xD
 	// By setting it to 0, we just upload the object in a single a request
 	// which should work for our chunk sizes.
-	writer.ChunkSize = s.cfg.ChunkBufferSize
-
-	if _, err := io.Copy(writer, object); err != nil {
-		_ = writer.Close()
-		return err
+	if s.cfg.ChunkedUploadThreshold <= 0 {
+		return s.cfg.ChunkBufferSize, nil
+	}
+	size, err := object.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	if size <= int64(s.cfg.ChunkedUploadThreshold) {
+		return 0, nil
 	}
-	return writer.Close()
+	return s.cfg.ChunkBufferSize, nil
 }
 
 // List implements chunk.ObjectClient.
-func (s *GCSObjectClient) List(ctx context.Context, prefix, delimiter string) ([]chunk.StorageObject, []chunk.StorageCommonPrefix, error) {
+func (s *GCSObjectClient) List(ctx context.Context, prefix, delimiter string) (storageObjects []chunk.StorageObject, commonPrefixes []chunk.StorageCommonPrefix, err error) {
+	err = s.retry(ctx, func() error {
+		var err error
+		storageObjects, commonPrefixes, err = s.list(ctx, prefix, delimiter)
+		return err
+	})
+	return storageObjects, commonPrefixes, err
+}
+
+func (s *GCSObjectClient) list(ctx context.Context, prefix, delimiter string) ([]chunk.StorageObject, []chunk.StorageCommonPrefix, error) {
 	var storageObjects []chunk.StorageObject
 	var commonPrefixes []chunk.StorageCommonPrefix
 	q := &storage.Query{Prefix: prefix, Delimiter: delimiter}
@@ -194,12 +274,47 @@ func (s *GCSObjectClient) List(ctx context.Context, prefix, delimiter string) ([
 
 // DeleteObject deletes the specified object key from the configured GCS bucket.
 func (s *GCSObjectClient) DeleteObject(ctx context.Context, objectKey string) error {
-	err := s.defaultBucket.Object(objectKey).Delete(ctx)
-	if err != nil {
-		return err
+	return s.retry(ctx, func() error {
+		return s.defaultBucket.Object(objectKey).Delete(ctx)
+	})
+}
+
+// retry runs the operation until it succeeds, fails with an error which isn't transient or runs out of retries.
+func (s *GCSObjectClient) retry(ctx context.Context, op func() error) error {
+	if s.cfg.BackoffConfig.MaxRetries <= 0 {
+		return op()
+	}
+	retries := backoff.New(ctx, s.cfg.BackoffConfig)
+	var err error
+	for {
+		if err = op(); err == nil || !isRetryableErr(err) {
+			return err
+		}
+		retries.Wait()
+		if !retries.Ongoing() {
+			return err
+		}
 	}
+}
 
-	return nil
+// isRetryableErr returns whether the error is transient: the GCS server is unavailable, throttles
+// requests or the connection is interrupted.
+func isRetryableErr(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code == http.StatusTooManyRequests || apiErr.Code >= http.StatusInternalServerError
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return false
 }
 
 // IsObjectNotFoundErr returns true if error means that object is not found. Relevant to GetObject and DeleteObject operations.
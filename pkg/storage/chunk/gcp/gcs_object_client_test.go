@@ -3,14 +3,17 @@ package gcp
 import (
 	"bytes"
 	"context"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
 	"cloud.google.com/go/storage"
+	"github.com/grafana/dskit/backoff"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/atomic"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/option"
 
 	"github.com/grafana/loki/pkg/storage/chunk/hedging"
@@ -89,3 +92,46 @@ func fakeServer(t *testing.T, returnIn time.Duration, counter *atomic.Int32) *ht
 
 	return server
 }
+
+func Test_Retry(t *testing.T) {
+	c := &GCSObjectClient{cfg: GCSConfig{BackoffConfig: backoff.Config{MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond, MaxRetries: 3}}}
+	for _, tc := range []struct {
+		name  string
+		err   error
+		calls int
+	}{
+		{"success", nil, 1},
+		{"unavailable", &googleapi.Error{Code: http.StatusServiceUnavailable}, 3},
+		{"throttled", &googleapi.Error{Code: http.StatusTooManyRequests}, 3},
+		{"interrupted", io.ErrUnexpectedEOF, 3},
+		{"not found", storage.ErrObjectNotExist, 1},
+		{"forbidden", &googleapi.Error{Code: http.StatusForbidden}, 1},
+		{"canceled", context.Canceled, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			calls := 0
+			err := c.retry(context.Background(), func() error {
+				calls++
+				return tc.err
+			})
+			require.Equal(t, tc.err, err)
+			require.Equal(t, tc.calls, calls)
+		})
+	}
+}
+
+func Test_UploadChunkSize(t *testing.T) {
+	c := &GCSObjectClient{cfg: GCSConfig{ChunkBufferSize: 8, ChunkedUploadThreshold: 16}}
+	size, err := c.uploadChunkSize(bytes.NewReader(make([]byte, 16)))
+	require.NoError(t, err)
+	require.Equal(t, 0, size)
+	size, err = c.uploadChunkSize(bytes.NewReader(make([]byte, 17)))
+	require.NoError(t, err)
+	require.Equal(t, 8, size)
+
+	// the objects are always uploaded in chunks of the buffer size without threshold.
+	c.cfg.ChunkedUploadThreshold = 0
+	size, err = c.uploadChunkSize(bytes.NewReader(make([]byte, 1)))
+	require.NoError(t, err)
+	require.Equal(t, 8, size)
+}
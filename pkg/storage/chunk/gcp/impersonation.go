@@ -0,0 +1,106 @@
+package gcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	iamCredentialsURL  = "https://iamcredentials.googleapis.com/v1/"
+	cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+	// impersonationLifetime is the lifetime of the access tokens of the impersonated service account,
+	// they are renewed before they expire.
+	impersonationLifetime = time.Hour
+)
+
+// impersonatedTokenSource generates the access tokens of a target service account with the default
+// credentials, which need the Service Account Token Creator role on the target or on the last delegate.
+// No service account key needs to be exported.
+type impersonatedTokenSource struct {
+	ctx       context.Context
+	client    *http.Client
+	url       string
+	target    string
+	delegates []string
+	scopes    []string
+}
+
+// newImpersonatedTokenSource returns a token source of the target service account impersonated
+// through the chain of delegates.
+func newImpersonatedTokenSource(ctx context.Context, target string, delegates []string, scopes ...string) (oauth2.TokenSource, error) {
+	base, err := google.DefaultTokenSource(ctx, cloudPlatformScope)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find the default credentials to impersonate the service account")
+	}
+	return oauth2.ReuseTokenSource(nil, &impersonatedTokenSource{
+		ctx:       ctx,
+		client:    oauth2.NewClient(ctx, base),
+		url:       iamCredentialsURL,
+		target:    target,
+		delegates: delegates,
+		scopes:    scopes,
+	}), nil
+}
+
+type generateAccessTokenRequest struct {
+	Delegates []string `json:"delegates,omitempty"`
+	Scope     []string `json:"scope"`
+	Lifetime  string   `json:"lifetime"`
+}
+
+type generateAccessTokenResponse struct {
+	AccessToken string    `json:"accessToken"`
+	ExpireTime  time.Time `json:"expireTime"`
+}
+
+// Token implements oauth2.TokenSource.
+func (s *impersonatedTokenSource) Token() (*oauth2.Token, error) {
+	req := generateAccessTokenRequest{
+		Scope:    s.scopes,
+		Lifetime: fmt.Sprintf("%.0fs", impersonationLifetime.Seconds()),
+	}
+	for _, delegate := range s.delegates {
+		req.Delegates = append(req.Delegates, serviceAccountName(delegate))
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(s.ctx, http.MethodPost, s.url+serviceAccountName(s.target)+":generateAccessToken", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(httpReq)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to impersonate the service account")
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to impersonate the service account")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to impersonate the service account %s: %s: %s", s.target, resp.Status, respBody)
+	}
+
+	var token generateAccessTokenResponse
+	if err := json.Unmarshal(respBody, &token); err != nil {
+		return nil, errors.Wrap(err, "failed to decode the access token of the impersonated service account")
+	}
+	return &oauth2.Token{AccessToken: token.AccessToken, TokenType: "Bearer", Expiry: token.ExpireTime}, nil
+}
+
+func serviceAccountName(email string) string {
+	return "projects/-/serviceAccounts/" + email
+}
@@ -0,0 +1,48 @@
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestImpersonatedTokenSource(t *testing.T) {
+	expiry := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/projects/-/serviceAccounts/loki@project.iam.gserviceaccount.com:generateAccessToken" {
+			http.Error(w, "permission denied", http.StatusForbidden)
+			return
+		}
+		var req generateAccessTokenRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		require.Equal(t, generateAccessTokenRequest{
+			Delegates: []string{"projects/-/serviceAccounts/delegate@project.iam.gserviceaccount.com"},
+			Scope:     []string{"scope"},
+			Lifetime:  "3600s",
+		}, req)
+		_ = json.NewEncoder(w).Encode(generateAccessTokenResponse{AccessToken: "token", ExpireTime: expiry})
+	}))
+	defer server.Close()
+
+	source := &impersonatedTokenSource{
+		ctx:       context.Background(),
+		client:    server.Client(),
+		url:       server.URL + "/v1/",
+		target:    "loki@project.iam.gserviceaccount.com",
+		delegates: []string{"delegate@project.iam.gserviceaccount.com"},
+		scopes:    []string{"scope"},
+	}
+	token, err := source.Token()
+	require.NoError(t, err)
+	require.Equal(t, "token", token.AccessToken)
+	require.True(t, expiry.Equal(token.Expiry))
+
+	source.target = "unknown@project.iam.gserviceaccount.com"
+	_, err = source.Token()
+	require.Error(t, err)
+}
@@ -51,7 +51,7 @@ func bigtableInstrumentation() ([]grpc.UnaryClientInterceptor, []grpc.StreamClie
 		}
 }
 
-func gcsInstrumentation(ctx context.Context, scope string, insecure bool, http2 bool) (*http.Client, error) {
+func gcsInstrumentation(ctx context.Context, authOption option.ClientOption, insecure bool, http2 bool) (*http.Client, error) {
 	customTransport := &http.Transport{
 		Proxy: http.ProxyFromEnvironment,
 		DialContext: (&net.Dialer{
@@ -74,7 +74,7 @@ func gcsInstrumentation(ctx context.Context, scope string, insecure bool, http2
 	if insecure {
 		customTransport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
 	}
-	transport, err := google_http.NewTransport(ctx, customTransport, option.WithScopes(scope))
+	transport, err := google_http.NewTransport(ctx, customTransport, authOption)
 	if err != nil {
 		return nil, err
 	}
@@ -44,7 +44,7 @@ func (s *StorageClient) PutChunks(ctx context.Context, chunks []chunk.Chunk) err
 		}
 
 		key := s.schemaCfg.ExternalKey(chunks[i])
-		tableName, err := s.schemaCfg.ChunkTableFor(chunks[i].From)
+		tableName, err := s.schemaCfg.ChunkTableFor(chunks[i].UserID, chunks[i].From)
 		if err != nil {
 			return errors.WithStack(err)
 		}
@@ -85,7 +85,7 @@ func (s *StorageClient) GetChunks(ctx context.Context, input []chunk.Chunk) ([]c
 	for _, inputInfo := range input {
 		chunkInfo := &Chunk{}
 		// send the table name from upstream gRPC client as gRPC server is unaware of schema
-		chunkInfo.TableName, err = s.schemaCfg.ChunkTableFor(inputInfo.From)
+		chunkInfo.TableName, err = s.schemaCfg.ChunkTableFor(inputInfo.UserID, inputInfo.From)
 		if err != nil {
 			return nil, errors.WithStack(err)
 		}
@@ -58,6 +58,23 @@ func (cfg *Config) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
 	f.IntVar(&cfg.MaxPerSecond, prefix+"hedge-max-per-second", 5, "The maximun of hedge requests allowed per seconds.")
 }
 
+// Validate validates the hedging config.
+func (cfg *Config) Validate() error {
+	if cfg.At < 0 {
+		return errors.New("the hedging delay cannot be negative")
+	}
+	if cfg.At == 0 {
+		return nil
+	}
+	if cfg.UpTo < 1 {
+		return errors.New("the maximum of hedge requests must be greater than 0")
+	}
+	if cfg.MaxPerSecond < 1 {
+		return errors.New("the maximum of hedge requests per second must be greater than 0")
+	}
+	return nil
+}
+
 // Client returns a hedged http client.
 // The client transport will be mutated to use the hedged roundtripper.
 func (cfg *Config) Client(client *http.Client) (*http.Client, error) {
@@ -94,3 +94,26 @@ hedged_requests_total 1
 `,
 		), "hedged_requests_total", "hedged_requests_rate_limited_total"))
 }
+
+func TestConfig_Validate(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		cfg   Config
+		valid bool
+	}{
+		{"disabled", Config{}, true},
+		{"enabled", Config{At: time.Second, UpTo: 2, MaxPerSecond: 5}, true},
+		{"negative delay", Config{At: -time.Second, UpTo: 2, MaxPerSecond: 5}, false},
+		{"no request", Config{At: time.Second, UpTo: 0, MaxPerSecond: 5}, false},
+		{"no hedge request per second", Config{At: time.Second, UpTo: 2, MaxPerSecond: 0}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.cfg.Validate()
+			if tc.valid {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package chunk
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// indexTypeTableClient routes the tables of the tenant periods using index types which no global
+// period uses to the table clients of these index types, the other tables to the default client.
+type indexTypeTableClient struct {
+	// clients holds the default client followed by the clients ordered by index type.
+	clients []TableClient
+	// prefixes holds the client of each table prefix, the longest prefixes first.
+	prefixes []tablePrefixClient
+}
+
+type tablePrefixClient struct {
+	prefix string
+	// client is the index of the client in indexTypeTableClient.clients.
+	client int
+}
+
+// NewIndexTypeTableClient returns a TableClient managing the tables of the global periods, and of the
+// tenant periods using their index types, with the default client, which is the client of the index
+// type of the last global period. The tables of the tenant periods using other index types are managed
+// with the client of their index type in clients.
+func NewIndexTypeTableClient(defaultClient TableClient, clients map[string]TableClient, schemaCfg SchemaConfig) TableClient {
+	if len(clients) == 0 {
+		return defaultClient
+	}
+
+	indexTypes := make([]string, 0, len(clients))
+	for indexType := range clients {
+		indexTypes = append(indexTypes, indexType)
+	}
+	sort.Strings(indexTypes)
+	c := &indexTypeTableClient{clients: []TableClient{defaultClient}}
+	indexTypeClients := map[string]int{}
+	for _, indexType := range indexTypes {
+		indexTypeClients[indexType] = len(c.clients)
+		c.clients = append(c.clients, clients[indexType])
+	}
+
+	addPeriods := func(configs []PeriodConfig, tenant bool) {
+		for _, cfg := range configs {
+			client := 0
+			if i, ok := indexTypeClients[cfg.IndexType]; ok && tenant {
+				client = i
+			}
+			for _, prefix := range []string{cfg.IndexTables.Prefix, cfg.ChunkTables.Prefix} {
+				if prefix != "" {
+					c.prefixes = append(c.prefixes, tablePrefixClient{prefix: prefix, client: client})
+				}
+			}
+		}
+	}
+	addPeriods(schemaCfg.Configs, false)
+	for _, tenantCfg := range schemaCfg.TenantConfigs {
+		if tenantCfg != nil {
+			addPeriods(tenantCfg.Configs, true)
+		}
+	}
+	sort.SliceStable(c.prefixes, func(i, j int) bool {
+		return len(c.prefixes[i].prefix) > len(c.prefixes[j].prefix)
+	})
+	return c
+}
+
+// clientFor returns the index of the client of the longest prefix of the table.
+func (c *indexTypeTableClient) clientFor(name string) int {
+	for _, p := range c.prefixes {
+		if strings.HasPrefix(name, p.prefix) {
+			return p.client
+		}
+	}
+	return 0
+}
+
+// ListTables lists the tables of each client, except the ones another client manages.
+func (c *indexTypeTableClient) ListTables(ctx context.Context) ([]string, error) {
+	var tables []string
+	for i, client := range c.clients {
+		clientTables, err := client.ListTables(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, table := range clientTables {
+			if c.clientFor(table) == i {
+				tables = append(tables, table)
+			}
+		}
+	}
+	return tables, nil
+}
+
+func (c *indexTypeTableClient) CreateTable(ctx context.Context, desc TableDesc) error {
+	return c.clients[c.clientFor(desc.Name)].CreateTable(ctx, desc)
+}
+
+func (c *indexTypeTableClient) DeleteTable(ctx context.Context, name string) error {
+	return c.clients[c.clientFor(name)].DeleteTable(ctx, name)
+}
+
+func (c *indexTypeTableClient) DescribeTable(ctx context.Context, name string) (TableDesc, bool, error) {
+	return c.clients[c.clientFor(name)].DescribeTable(ctx, name)
+}
+
+func (c *indexTypeTableClient) UpdateTable(ctx context.Context, current, expected TableDesc) error {
+	return c.clients[c.clientFor(current.Name)].UpdateTable(ctx, current, expected)
+}
+
+// ExportTable implements TableExporter for the tables whose client can export them.
+func (c *indexTypeTableClient) ExportTable(ctx context.Context, name string, callback func(entry IndexEntry) error) error {
+	exporter, ok := c.clients[c.clientFor(name)].(TableExporter)
+	if !ok {
+		return fmt.Errorf("the table %s can't be exported by its table client", name)
+	}
+	return exporter.ExportTable(ctx, name, callback)
+}
+
+func (c *indexTypeTableClient) Stop() {
+	for _, client := range c.clients {
+		client.Stop()
+	}
+}
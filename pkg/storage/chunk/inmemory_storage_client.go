@@ -174,6 +174,37 @@ func (m *MockStorage) UpdateTable(_ context.Context, _, desc TableDesc) error {
 	return nil
 }
 
+// ExportTable implements TableExporter.
+func (m *MockStorage) ExportTable(_ context.Context, name string, callback func(entry IndexEntry) error) error {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+
+	table, ok := m.tables[name]
+	if !ok {
+		return fmt.Errorf("table not found")
+	}
+
+	hashValues := make([]string, 0, len(table.items))
+	for hashValue := range table.items {
+		hashValues = append(hashValues, hashValue)
+	}
+	sort.Strings(hashValues)
+
+	for _, hashValue := range hashValues {
+		for _, item := range table.items[hashValue] {
+			if err := callback(IndexEntry{
+				TableName:  name,
+				HashValue:  hashValue,
+				RangeValue: item.rangeValue,
+				Value:      item.value,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // NewWriteBatch implements StorageClient.
 func (m *MockStorage) NewWriteBatch() WriteBatch {
 	return &mockWriteBatch{}
@@ -425,6 +456,29 @@ func (m *MockStorage) GetObject(ctx context.Context, objectKey string) (io.ReadC
 	return ioutil.NopCloser(bytes.NewReader(buf)), int64(len(buf)), nil
 }
 
+func (m *MockStorage) GetObjectRange(ctx context.Context, objectKey string, offset, length int64) (io.ReadCloser, error) {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+
+	if m.mode == MockStorageModeWriteOnly {
+		return nil, errPermissionDenied
+	}
+
+	buf, ok := m.objects[objectKey]
+	if !ok {
+		return nil, errStorageObjectNotFound
+	}
+	if offset > int64(len(buf)) {
+		offset = int64(len(buf))
+	}
+	end := offset + length
+	if end > int64(len(buf)) {
+		end = int64(len(buf))
+	}
+
+	return ioutil.NopCloser(bytes.NewReader(buf[offset:end])), nil
+}
+
 func (m *MockStorage) PutObject(ctx context.Context, objectKey string, object io.ReadSeeker) error {
 	buf, err := ioutil.ReadAll(object)
 	if err != nil {
@@ -21,12 +21,17 @@ import (
 
 // FSConfig is the config for a FSObjectClient.
 type FSConfig struct {
-	Directory string `yaml:"directory"`
+	Directory              string        `yaml:"directory"`
+	RetentionPeriod        time.Duration `yaml:"retention_period"`
+	RetentionSweepInterval time.Duration `yaml:"retention_sweep_interval"`
 }
 
 // RegisterFlags registers flags.
+// The retention is only enforced on the filesystem object store of the storage config.
 func (cfg *FSConfig) RegisterFlags(f *flag.FlagSet) {
 	cfg.RegisterFlagsWithPrefix("", f)
+	f.DurationVar(&cfg.RetentionPeriod, "local.retention-period", 0, "Delete the chunk files older than this period, and the directories they leave empty, when running the single binary. 0 to disable.")
+	f.DurationVar(&cfg.RetentionSweepInterval, "local.retention-sweep-interval", time.Hour, "How often the chunk files out of retention are deleted.")
 }
 
 // RegisterFlags registers flags with prefix.
@@ -78,6 +83,18 @@ func (f *FSObjectClient) GetObject(_ context.Context, objectKey string) (io.Read
 	return fl, stats.Size(), nil
 }
 
+// GetObjectRange returns a reader for length bytes from offset of the object from the store
+func (f *FSObjectClient) GetObjectRange(_ context.Context, objectKey string, offset, length int64) (io.ReadCloser, error) {
+	fl, err := os.Open(filepath.Join(f.cfg.Directory, filepath.FromSlash(objectKey)))
+	if err != nil {
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.NewSectionReader(fl, offset, length), fl}, nil
+}
+
 // PutObject into the store
 func (f *FSObjectClient) PutObject(_ context.Context, objectKey string, object io.ReadSeeker) error {
 	fullPath := filepath.Join(f.cfg.Directory, filepath.FromSlash(objectKey))
@@ -196,15 +213,11 @@ func (f *FSObjectClient) DeleteObject(ctx context.Context, objectKey string) err
 
 // DeleteChunksBefore implements BucketClient
 func (f *FSObjectClient) DeleteChunksBefore(ctx context.Context, ts time.Time) error {
-	return filepath.Walk(f.cfg.Directory, func(path string, info os.FileInfo, err error) error {
-		if !info.IsDir() && info.ModTime().Before(ts) {
-			level.Info(util_log.Logger).Log("msg", "file has exceeded the retention period, removing it", "filepath", info.Name())
-			if err := os.Remove(path); err != nil {
-				return err
-			}
-		}
-		return nil
-	})
+	res, err := sweepDirectory(ctx, f.cfg.Directory, ts, nil)
+	if res.files > 0 {
+		level.Info(util_log.Logger).Log("msg", "files have exceeded the retention period, removed them", "files", res.files, "bytes", res.bytes)
+	}
+	return err
 }
 
 // IsObjectNotFoundErr returns true if error means that object is not found. Relevant to GetObject and DeleteObject operations.
@@ -200,3 +200,21 @@ func TestFSObjectClient_DeleteObject(t *testing.T) {
 	require.Len(t, commonPrefixes, 0)
 	require.Len(t, files, len(foldersWithFiles["folder2/"]))*/
 }
+
+func TestFSObjectClient_GetObjectRange(t *testing.T) {
+	bucketClient, err := NewFSObjectClient(FSConfig{
+		Directory: t.TempDir(),
+	})
+	require.NoError(t, err)
+	require.NoError(t, bucketClient.PutObject(context.Background(), "folder/file", bytes.NewReader([]byte("0123456789"))))
+
+	rc, err := bucketClient.GetObjectRange(context.Background(), "folder/file", 2, 5)
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(rc)
+	require.NoError(t, err)
+	require.NoError(t, rc.Close())
+	require.Equal(t, "23456", string(body))
+
+	_, err = bucketClient.GetObjectRange(context.Background(), "folder/missing", 2, 5)
+	require.True(t, bucketClient.IsObjectNotFoundErr(err))
+}
@@ -0,0 +1,189 @@
+package local
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/services"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+type fsRetentionMetrics struct {
+	reclaimedBytes     prometheus.Counter
+	deletedFiles       prometheus.Counter
+	removedDirectories prometheus.Counter
+	sweepFailures      prometheus.Counter
+	lastSweep          prometheus.Gauge
+}
+
+func newFSRetentionMetrics(r prometheus.Registerer) *fsRetentionMetrics {
+	return &fsRetentionMetrics{
+		reclaimedBytes: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Namespace: "loki",
+			Name:      "filesystem_retention_reclaimed_bytes_total",
+			Help:      "Total number of bytes reclaimed by deleting the files of the filesystem object store out of retention.",
+		}),
+		deletedFiles: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Namespace: "loki",
+			Name:      "filesystem_retention_deleted_files_total",
+			Help:      "Total number of files of the filesystem object store deleted because they were out of retention.",
+		}),
+		removedDirectories: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Namespace: "loki",
+			Name:      "filesystem_retention_removed_directories_total",
+			Help:      "Total number of empty directories of the filesystem object store removed.",
+		}),
+		sweepFailures: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Namespace: "loki",
+			Name:      "filesystem_retention_sweep_failures_total",
+			Help:      "Total number of sweeps of the filesystem object store which failed.",
+		}),
+		lastSweep: promauto.With(r).NewGauge(prometheus.GaugeOpts{
+			Namespace: "loki",
+			Name:      "filesystem_retention_last_successful_sweep_timestamp_seconds",
+			Help:      "Unix timestamp of the last successful sweep of the filesystem object store.",
+		}),
+	}
+}
+
+// sweepResult holds what a sweep reclaimed.
+type sweepResult struct {
+	files, directories int
+	bytes              int64
+}
+
+// FSRetentionSweeper periodically deletes the files of the filesystem object store older than the
+// retention period, and removes the directories left empty.
+type FSRetentionSweeper struct {
+	services.Service
+
+	directory       string
+	retentionPeriod time.Duration
+	ignoredPrefixes []string
+	now             func() time.Time
+	metrics         *fsRetentionMetrics
+	logger          log.Logger
+}
+
+// NewFSRetentionSweeper makes a sweeper of the filesystem object store of the config.
+// The objects with one of the ignored prefixes, like the index files managed by the
+// table manager or the compactor, are never deleted.
+func NewFSRetentionSweeper(cfg FSConfig, ignoredPrefixes []string, logger log.Logger, registerer prometheus.Registerer) *FSRetentionSweeper {
+	s := &FSRetentionSweeper{
+		directory:       filepath.Clean(cfg.Directory),
+		retentionPeriod: cfg.RetentionPeriod,
+		ignoredPrefixes: ignoredPrefixes,
+		now:             time.Now,
+		metrics:         newFSRetentionMetrics(registerer),
+		logger:          logger,
+	}
+	s.Service = services.NewTimerService(cfg.RetentionSweepInterval, s.iteration, s.iteration, nil)
+	return s
+}
+
+func (s *FSRetentionSweeper) iteration(ctx context.Context) error {
+	start := s.now()
+	res, err := sweepDirectory(ctx, s.directory, start.Add(-s.retentionPeriod), s.ignoredPrefixes)
+	s.metrics.reclaimedBytes.Add(float64(res.bytes))
+	s.metrics.deletedFiles.Add(float64(res.files))
+	s.metrics.removedDirectories.Add(float64(res.directories))
+	if err != nil {
+		s.metrics.sweepFailures.Inc()
+		level.Error(s.logger).Log("msg", "failed to enforce the retention of the filesystem object store", "err", err)
+		// don't return the error, otherwise the timer service would stop.
+		return nil
+	}
+	s.metrics.lastSweep.SetToCurrentTime()
+	level.Info(s.logger).Log("msg", "enforced the retention of the filesystem object store", "deleted_files", res.files,
+		"reclaimed_bytes", res.bytes, "removed_directories", res.directories, "duration", time.Since(start))
+	return nil
+}
+
+// sweepDirectory deletes the files of the directory modified before the given time, except the ones
+// with an ignored prefix, and removes the sub-directories left empty. The directory itself is kept.
+func sweepDirectory(ctx context.Context, directory string, before time.Time, ignoredPrefixes []string) (sweepResult, error) {
+	var res sweepResult
+	_, _, err := sweepDir(ctx, directory, "", before, ignoredPrefixes, &res)
+	return res, err
+}
+
+// sweepDir sweeps the directory at the relative path. It returns whether the directory is empty after
+// the sweep and whether the sweep deleted anything from it.
+func sweepDir(ctx context.Context, root, relPath string, before time.Time, ignoredPrefixes []string, res *sweepResult) (empty, swept bool, err error) {
+	infos, err := ioutil.ReadDir(filepath.Join(root, filepath.FromSlash(relPath)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, false, nil
+		}
+		return false, false, err
+	}
+
+	empty = true
+	for _, info := range infos {
+		if err := ctx.Err(); err != nil {
+			return false, swept, err
+		}
+		key := info.Name()
+		if relPath != "" {
+			key = relPath + "/" + key
+		}
+		if isIgnored(key, info.IsDir(), ignoredPrefixes) {
+			empty = false
+			continue
+		}
+		path := filepath.Join(root, filepath.FromSlash(key))
+
+		if info.IsDir() {
+			dirEmpty, dirSwept, err := sweepDir(ctx, root, key, before, ignoredPrefixes, res)
+			if err != nil {
+				return false, swept, err
+			}
+			swept = swept || dirSwept
+			// the directories just created by a write are kept until they're old enough.
+			if !dirEmpty || (!dirSwept && !info.ModTime().Before(before)) {
+				empty = false
+				continue
+			}
+			// the directory fails to be removed when it was written to since it was swept.
+			if err := os.Remove(path); err != nil {
+				empty = false
+				continue
+			}
+			res.directories++
+			swept = true
+			continue
+		}
+
+		if !info.ModTime().Before(before) {
+			empty = false
+			continue
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return false, swept, err
+		}
+		res.files++
+		res.bytes += info.Size()
+		swept = true
+	}
+	return empty, swept, nil
+}
+
+// isIgnored returns whether the object or directory key has one of the ignored prefixes.
+func isIgnored(key string, dir bool, ignoredPrefixes []string) bool {
+	if dir {
+		key += "/"
+	}
+	for _, prefix := range ignoredPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package local
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFSRetentionSweeper(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	old := now.Add(-48 * time.Hour)
+
+	for key, mtime := range map[string]time.Time{
+		"fake/old-chunk":                  old,
+		"fake/new-chunk":                  now,
+		"tenant/period/old-chunk":         old,
+		"tenant/other-period/old-chunk":   old,
+		"index/index_19000/old-index.gz":  old,
+		"tsdb-index/index_19000/old.tsdb": old,
+		"compactor-locks/index_19000":     old,
+		"quarantine/index_19000":          old,
+		"blooms/index_19000/old.bloom":    old,
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(key))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0750))
+		require.NoError(t, ioutil.WriteFile(path, []byte("chunk"), 0600))
+		require.NoError(t, os.Chtimes(path, mtime, mtime))
+	}
+	// the empty directories are removed once they're out of retention.
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "stale"), 0750))
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "stale"), old, old))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "fresh"), 0750))
+
+	reg := prometheus.NewRegistry()
+	sweeper := NewFSRetentionSweeper(FSConfig{Directory: dir, RetentionPeriod: 24 * time.Hour, RetentionSweepInterval: time.Hour},
+		[]string{"index/", "tsdb-index/", "compactor-locks/", "quarantine/", "blooms/"}, log.NewNopLogger(), reg)
+	require.NoError(t, sweeper.iteration(context.Background()))
+
+	for key, exists := range map[string]bool{
+		"fake/old-chunk":                  false,
+		"fake/new-chunk":                  true,
+		"tenant":                          false,
+		"stale":                           false,
+		"fresh":                           true,
+		"index/index_19000/old-index.gz":  true,
+		"tsdb-index/index_19000/old.tsdb": true,
+		"compactor-locks/index_19000":     true,
+		"quarantine/index_19000":          true,
+		"blooms/index_19000/old.bloom":    true,
+	} {
+		_, err := os.Stat(filepath.Join(dir, filepath.FromSlash(key)))
+		require.Equal(t, exists, err == nil, key)
+	}
+	require.Equal(t, 15., testutil.ToFloat64(sweeper.metrics.reclaimedBytes))
+	require.Equal(t, 3., testutil.ToFloat64(sweeper.metrics.deletedFiles))
+	require.Equal(t, 4., testutil.ToFloat64(sweeper.metrics.removedDirectories))
+	require.Equal(t, 0., testutil.ToFloat64(sweeper.metrics.sweepFailures))
+}
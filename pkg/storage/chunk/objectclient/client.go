@@ -4,14 +4,35 @@ import (
 	"bytes"
 	"context"
 	"encoding/base64"
+	"fmt"
+	"hash/fnv"
 	"strings"
+	"sync"
 
+	"github.com/go-kit/log/level"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 
 	"github.com/grafana/loki/pkg/storage/chunk"
 	"github.com/grafana/loki/pkg/storage/chunk/util"
+	util_log "github.com/grafana/loki/pkg/util/log"
 )
 
+// Reasons of the corrupt chunks.
+const (
+	corruptTruncated = "truncated"
+	corruptChecksum  = "checksum"
+	corruptMetadata  = "metadata"
+	corruptData      = "data"
+)
+
+var corruptChunks = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "loki",
+	Name:      "chunk_store_corrupt_chunks_total",
+	Help:      "Total count of corrupt chunks fetched from the object store, by reason.",
+}, []string{"reason"})
+
 // KeyEncoder is used to encode chunk keys before writing/retrieving chunks
 // from the underlying ObjectClient
 // Schema/Chunk are passed as arguments to allow this to improve over revisions
@@ -46,6 +67,9 @@ type Client struct {
 	keyEncoder          KeyEncoder
 	getChunkMaxParallel int
 	schema              chunk.SchemaConfig
+
+	// containerLocks serialize the rewrites of the containers of the deleted chunks.
+	containerLocks [64]sync.Mutex
 }
 
 // NewClient wraps the provided ObjectClient with a chunk.Client implementation
@@ -75,7 +99,20 @@ func (o *Client) PutChunks(ctx context.Context, chunks []chunk.Chunk) error {
 		chunkBufs [][]byte
 	)
 
+	// the chunks packed in a container are written in a single object.
+	for _, contained := range chunk.GroupByContainer(chunks) {
+		buf, err := chunk.EncodeContainer(contained)
+		if err != nil {
+			return err
+		}
+		chunkKeys = append(chunkKeys, o.schema.ContainerKey(contained[0].UserID, contained[0].Container.ID))
+		chunkBufs = append(chunkBufs, buf)
+	}
+
 	for i := range chunks {
+		if chunks[i].Contained() {
+			continue
+		}
 		buf, err := chunks[i].Encoded()
 		if err != nil {
 			return err
@@ -125,7 +162,12 @@ func (o *Client) getChunk(ctx context.Context, decodeContext *chunk.DecodeContex
 	}
 
 	key := o.schema.ExternalKey(c)
-	if o.keyEncoder != nil {
+	if c.Contained() {
+		key = o.schema.ContainerKey(c.UserID, c.Container.ID)
+		if store, ok := o.store.(chunk.RangeObjectClient); ok {
+			return o.getContainedChunk(ctx, decodeContext, store, key, c)
+		}
+	} else if o.keyEncoder != nil {
 		key = o.keyEncoder(o.schema, c)
 	}
 
@@ -144,14 +186,82 @@ func (o *Client) getChunk(ctx context.Context, decodeContext *chunk.DecodeContex
 		return chunk.Chunk{}, errors.WithStack(err)
 	}
 
-	if err := c.Decode(decodeContext, buf.Bytes()); err != nil {
+	decode := c.Decode
+	if c.Contained() {
+		decode = c.DecodeContained
+	}
+	// the objects of partial uploads fail the checksum of their key, or are shorter than their header announces.
+	if err := decode(decodeContext, buf.Bytes()); err != nil {
+		return chunk.Chunk{}, errors.WithStack(o.corruptChunk(key, err))
+	}
+	return c, nil
+}
+
+// getContainedChunk reads only the range of its container holding the chunk.
+func (o *Client) getContainedChunk(ctx context.Context, decodeContext *chunk.DecodeContext, store chunk.RangeObjectClient, key string, c chunk.Chunk) (chunk.Chunk, error) {
+	readCloser, err := store.GetObjectRange(ctx, key, int64(c.Container.Offset), int64(c.Container.Length))
+	if err != nil {
+		return chunk.Chunk{}, errors.WithStack(err)
+	}
+
+	defer readCloser.Close()
+
+	buf := bytes.NewBuffer(make([]byte, 0, int(c.Container.Length)+bytes.MinRead))
+	if _, err := buf.ReadFrom(readCloser); err != nil {
 		return chunk.Chunk{}, errors.WithStack(err)
 	}
+
+	if err := c.DecodeContainedRange(decodeContext, buf.Bytes()); err != nil {
+		return chunk.Chunk{}, errors.WithStack(o.corruptChunk(key, err))
+	}
 	return c, nil
 }
 
+// CorruptChunkError is returned for the chunks fetched from the object store which fail to be decoded.
+type CorruptChunkError struct {
+	Key    string
+	Reason string
+	Err    error
+}
+
+func (e *CorruptChunkError) Error() string {
+	return e.Err.Error()
+}
+
+// Cause returns the decoding error, for errors.Cause.
+func (e *CorruptChunkError) Cause() error {
+	return e.Err
+}
+
+func (e *CorruptChunkError) Unwrap() error {
+	return e.Err
+}
+
+func (o *Client) corruptChunk(key string, err error) error {
+	reason := corruptionReason(err)
+	corruptChunks.WithLabelValues(reason).Inc()
+	level.Error(util_log.Logger).Log("msg", "corrupt chunk fetched from the object store", "key", key, "reason", reason, "err", err)
+	return &CorruptChunkError{Key: key, Reason: reason, Err: err}
+}
+
+func corruptionReason(err error) string {
+	switch errors.Cause(err) {
+	case chunk.ErrInvalidChecksum:
+		return corruptChecksum
+	case chunk.ErrDataLength:
+		return corruptTruncated
+	case chunk.ErrWrongMetadata, chunk.ErrMetadataLength:
+		return corruptMetadata
+	default:
+		return corruptData
+	}
+}
+
 // GetChunks retrieves the specified chunks from the configured backend
 func (o *Client) DeleteChunk(ctx context.Context, userID, chunkID string) error {
+	if chunk.IsContainedKey(chunkID) {
+		return o.deleteContainedChunk(ctx, userID, chunkID)
+	}
 	key := chunkID
 	if o.keyEncoder != nil {
 		c, err := chunk.ParseExternalKey(userID, key)
@@ -163,6 +273,54 @@ func (o *Client) DeleteChunk(ctx context.Context, userID, chunkID string) error
 	return o.store.DeleteObject(ctx, key)
 }
 
+// deleteContainedChunk overwrites the chunk with zeros in its container, which keeps the offsets of the other
+// chunks packed in it, and deletes the container once all its chunks are deleted. The container is rewritten
+// under a lock held by the client, the chunks of a container must not be deleted by several processes at once.
+func (o *Client) deleteContainedChunk(ctx context.Context, userID, chunkID string) error {
+	c, err := chunk.ParseExternalKey(userID, chunkID)
+	if err != nil {
+		return err
+	}
+	key := o.schema.ContainerKey(userID, c.Container.ID)
+
+	mtx := &o.containerLocks[fnv32a(c.Container.ID)%uint32(len(o.containerLocks))]
+	mtx.Lock()
+	defer mtx.Unlock()
+
+	readCloser, size, err := o.store.GetObject(ctx, key)
+	if err != nil {
+		return err
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, size+bytes.MinRead))
+	_, err = buf.ReadFrom(readCloser)
+	readCloser.Close()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	container := buf.Bytes()
+	end := uint64(c.Container.Offset) + uint64(c.Container.Length)
+	if end > uint64(len(container)) {
+		return fmt.Errorf("chunk %s out of the %d bytes of its container", chunkID, len(container))
+	}
+	for i := c.Container.Offset; uint64(i) < end; i++ {
+		container[i] = 0
+	}
+	for _, b := range container {
+		if b != 0 {
+			return o.store.PutObject(ctx, key, bytes.NewReader(container))
+		}
+	}
+	return o.store.DeleteObject(ctx, key)
+}
+
+// fnv32a hashes the string with FNV-1a.
+func fnv32a(s string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(s))
+	return h.Sum32()
+}
+
 func (o *Client) IsChunkNotFoundErr(err error) bool {
 	return o.store.IsObjectNotFoundErr(err)
 }
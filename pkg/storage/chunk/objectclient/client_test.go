@@ -1,14 +1,23 @@
 package objectclient
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
 	"github.com/stretchr/testify/require"
 
 	"github.com/grafana/loki/pkg/logproto"
 	"github.com/grafana/loki/pkg/storage/chunk"
+	"github.com/grafana/loki/pkg/storage/chunk/encoding"
 )
 
 func MustParseDayTime(s string) chunk.DayTime {
@@ -82,3 +91,146 @@ func TestFSEncoder(t *testing.T) {
 		})
 	}
 }
+
+func TestGetChunksCorrupt(t *testing.T) {
+	schema := chunk.SchemaConfig{
+		Configs: []chunk.PeriodConfig{
+			{
+				From:      MustParseDayTime("2020-01-01"),
+				Schema:    "v14",
+				RowShards: 16,
+			},
+		},
+	}
+	store := chunk.NewMockStorage()
+	client := NewClient(store, nil, schema)
+
+	data, err := encoding.NewForEncoding(encoding.Bigchunk)
+	require.NoError(t, err)
+	_, err = data.Add(model.SamplePair{Timestamp: MustParseDayTime("2022-01-02").Time, Value: 1})
+	require.NoError(t, err)
+	metric := labels.Labels{{Name: labels.MetricName, Value: "logs"}}
+	chk := chunk.NewChunk("fake", model.Fingerprint(metric.Hash()), metric, data, MustParseDayTime("2022-01-02").Time, MustParseDayTime("2022-01-03").Time)
+	require.NoError(t, chk.Encode())
+	require.NoError(t, client.PutChunks(context.Background(), []chunk.Chunk{chk}))
+
+	key := schema.ExternalKey(chk)
+	ref, err := chunk.ParseExternalKey("fake", key)
+	require.NoError(t, err)
+	require.Equal(t, encoding.Bigchunk, ref.Encoding)
+
+	chks, err := client.GetChunks(context.Background(), []chunk.Chunk{ref})
+	require.NoError(t, err)
+	require.Len(t, chks, 1)
+
+	// a partial upload of the chunk.
+	encoded, err := chk.Encoded()
+	require.NoError(t, err)
+	require.NoError(t, store.PutObject(context.Background(), key, bytes.NewReader(encoded[:len(encoded)-10])))
+
+	before := testutil.ToFloat64(corruptChunks.WithLabelValues(corruptChecksum))
+	_, err = client.GetChunks(context.Background(), []chunk.Chunk{ref})
+	require.Error(t, err)
+	require.Equal(t, before+1, testutil.ToFloat64(corruptChunks.WithLabelValues(corruptChecksum)))
+
+	// the decoding errors are told apart from the fetching ones.
+	var corruptErr *CorruptChunkError
+	require.True(t, errors.As(err, &corruptErr))
+	require.Equal(t, corruptChecksum, corruptErr.Reason)
+	require.Equal(t, key, corruptErr.Key)
+	require.Equal(t, chunk.ErrInvalidChecksum, errors.Cause(err))
+}
+
+func TestChunkContainers(t *testing.T) {
+	schema := chunk.SchemaConfig{
+		Configs: []chunk.PeriodConfig{
+			{
+				From:   MustParseDayTime("2020-01-01"),
+				Schema: "v13",
+			},
+		},
+	}
+	store := &rangeRecordingStorage{MockStorage: chunk.NewMockStorage()}
+	client := NewClient(store, FSEncoder, schema)
+
+	var chunks []chunk.Chunk
+	for _, name := range []string{"a", "b", "c"} {
+		data, err := encoding.NewForEncoding(encoding.Bigchunk)
+		require.NoError(t, err)
+		_, err = data.Add(model.SamplePair{Timestamp: MustParseDayTime("2022-01-02").Time, Value: 1})
+		require.NoError(t, err)
+		metric := labels.Labels{{Name: labels.MetricName, Value: "logs"}, {Name: "name", Value: name}}
+		chk := chunk.NewChunk("fake", model.Fingerprint(metric.Hash()), metric, data, MustParseDayTime("2022-01-02").Time, MustParseDayTime("2022-01-03").Time)
+		require.NoError(t, chk.Encode())
+		chunks = append(chunks, chk)
+	}
+	require.NoError(t, chunk.PackChunks(chunks))
+	require.NoError(t, client.PutChunks(context.Background(), chunks))
+
+	// the chunks are written in a single object.
+	objects, _, err := store.List(context.Background(), "", "")
+	require.NoError(t, err)
+	require.Len(t, objects, 1)
+	require.Equal(t, schema.ContainerKey("fake", chunks[0].Container.ID), objects[0].Key)
+
+	var refs []chunk.Chunk
+	for _, c := range chunks {
+		ref, err := chunk.ParseExternalKey("fake", schema.ExternalKey(c))
+		require.NoError(t, err)
+		refs = append(refs, ref)
+	}
+	fetched, err := client.GetChunks(context.Background(), refs)
+	require.NoError(t, err)
+	require.Len(t, fetched, len(chunks))
+	for _, c := range fetched {
+		require.Equal(t, "logs", c.Metric.Get(labels.MetricName))
+		require.True(t, c.Contained())
+	}
+
+	// only the ranges of the chunks are read.
+	var expectedRanges []string
+	for _, c := range chunks {
+		expectedRanges = append(expectedRanges, fmt.Sprintf("%x-%x", c.Container.Offset, c.Container.Length))
+	}
+	require.ElementsMatch(t, expectedRanges, store.ranges)
+
+	// the containers are read whole by the stores not supporting range reads.
+	noRangeClient := NewClient(struct{ chunk.ObjectClient }{store.MockStorage}, FSEncoder, schema)
+	wholeFetched, err := noRangeClient.GetChunks(context.Background(), refs)
+	require.NoError(t, err)
+	require.ElementsMatch(t, fetched, wholeFetched)
+
+	// the deleted chunk is erased from the container shared with the other chunks.
+	require.NoError(t, client.DeleteChunk(context.Background(), "fake", schema.ExternalKey(chunks[0])))
+	_, err = client.GetChunks(context.Background(), refs[1:])
+	require.NoError(t, err)
+	_, err = client.GetChunks(context.Background(), refs[:1])
+	require.Error(t, err)
+	container, err := store.MockStorage.GetObjectRange(context.Background(), objects[0].Key, int64(chunks[0].Container.Offset), int64(chunks[0].Container.Length))
+	require.NoError(t, err)
+	erased, err := io.ReadAll(container)
+	require.NoError(t, err)
+	require.Equal(t, make([]byte, chunks[0].Container.Length), erased)
+
+	// the container is deleted with its last chunk.
+	for _, c := range chunks[1:] {
+		require.NoError(t, client.DeleteChunk(context.Background(), "fake", schema.ExternalKey(c)))
+	}
+	objects, _, err = store.List(context.Background(), "", "")
+	require.NoError(t, err)
+	require.Empty(t, objects)
+}
+
+type rangeRecordingStorage struct {
+	*chunk.MockStorage
+
+	mtx    sync.Mutex
+	ranges []string
+}
+
+func (s *rangeRecordingStorage) GetObjectRange(ctx context.Context, objectKey string, offset, length int64) (io.ReadCloser, error) {
+	s.mtx.Lock()
+	s.ranges = append(s.ranges, fmt.Sprintf("%x-%x", offset, length))
+	s.mtx.Unlock()
+	return s.MockStorage.GetObjectRange(ctx, objectKey, offset, length)
+}
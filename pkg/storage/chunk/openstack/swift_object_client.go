@@ -17,6 +17,7 @@ import (
 	bucket_swift "github.com/grafana/loki/pkg/storage/bucket/swift"
 	"github.com/grafana/loki/pkg/storage/chunk"
 	"github.com/grafana/loki/pkg/storage/chunk/hedging"
+	"github.com/grafana/loki/pkg/util/flagext"
 	"github.com/grafana/loki/pkg/util/log"
 )
 
@@ -36,6 +37,9 @@ type SwiftObjectClient struct {
 // SwiftConfig is config for the Swift Chunk Client.
 type SwiftConfig struct {
 	bucket_swift.Config `yaml:",inline"`
+
+	SegmentSize          flagext.ByteSize `yaml:"segment_size"`
+	SegmentContainerName string           `yaml:"segment_container_name"`
 }
 
 // RegisterFlags registers flags.
@@ -51,6 +55,15 @@ func (cfg *SwiftConfig) Validate() error {
 // RegisterFlagsWithPrefix registers flags with prefix.
 func (cfg *SwiftConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
 	cfg.Config.RegisterFlagsWithPrefix(prefix, f)
+	f.Var(&cfg.SegmentSize, prefix+"swift.segment-size", "Objects bigger than this are uploaded in segments of this size, as static large objects or dynamic large objects when the cluster doesn't support them. 0 to disable.")
+	f.StringVar(&cfg.SegmentContainerName, prefix+"swift.segment-container-name", "", "Name of the OpenStack Swift container to put the segments of large objects in. Defaults to the container name with the _segments suffix.")
+}
+
+func (cfg *SwiftConfig) segmentContainerName() string {
+	if cfg.SegmentContainerName != "" {
+		return cfg.SegmentContainerName
+	}
+	return cfg.ContainerName + "_segments"
 }
 
 // NewSwiftObjectClient makes a new chunk.Client that writes chunks to OpenStack Swift.
@@ -65,6 +78,11 @@ func NewSwiftObjectClient(cfg SwiftConfig, hedgingCfg hedging.Config) (*SwiftObj
 	if err := c.ContainerCreate(cfg.ContainerName, nil); err != nil {
 		return nil, err
 	}
+	if cfg.SegmentSize > 0 {
+		if err := c.ContainerCreate(cfg.segmentContainerName(), nil); err != nil {
+			return nil, err
+		}
+	}
 	hedging, err := createConnection(cfg, hedgingCfg, true)
 	if err != nil {
 		return nil, err
@@ -135,12 +153,61 @@ func (s *SwiftObjectClient) GetObject(ctx context.Context, objectKey string) (io
 	return ioutil.NopCloser(&buf), int64(buf.Len()), nil
 }
 
+// GetObjectRange returns a reader for length bytes from offset of the specified object key from the configured swift container.
+func (s *SwiftObjectClient) GetObjectRange(ctx context.Context, objectKey string, offset, length int64) (io.ReadCloser, error) {
+	var buf bytes.Buffer
+	_, err := s.hedgingConn.ObjectGet(s.cfg.ContainerName, objectKey, &buf, false, swift.Headers{
+		"Range": fmt.Sprintf("bytes=%d-%d", offset, offset+length-1),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.NopCloser(&buf), nil
+}
+
 // PutObject puts the specified bytes into the configured Swift container at the provided key
 func (s *SwiftObjectClient) PutObject(ctx context.Context, objectKey string, object io.ReadSeeker) error {
+	if s.cfg.SegmentSize > 0 {
+		size, err := object.Seek(0, io.SeekEnd)
+		if err != nil {
+			return err
+		}
+		if _, err := object.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		if size > int64(s.cfg.SegmentSize) {
+			return s.putLargeObject(objectKey, object)
+		}
+	}
+
 	_, err := s.conn.ObjectPut(s.cfg.ContainerName, objectKey, object, false, "", "", nil)
 	return err
 }
 
+// putLargeObject uploads the object in segments, with a static large object manifest
+// or a dynamic one if the cluster doesn't support static large objects.
+func (s *SwiftObjectClient) putLargeObject(objectKey string, object io.Reader) error {
+	opts := &swift.LargeObjectOpts{
+		Container:        s.cfg.ContainerName,
+		ObjectName:       objectKey,
+		ChunkSize:        int64(s.cfg.SegmentSize),
+		SegmentContainer: s.cfg.segmentContainerName(),
+	}
+	file, err := s.conn.StaticLargeObjectCreate(opts)
+	if errors.Is(err, swift.SLONotSupported) {
+		file, err = s.conn.DynamicLargeObjectCreate(opts)
+	}
+	if err != nil {
+		return err
+	}
+	// the manifest is only written on close, a failed upload leaves the segments behind.
+	if _, err := io.Copy(file, object); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
 // List only objects from the store non-recursively
 func (s *SwiftObjectClient) List(ctx context.Context, prefix, delimiter string) ([]chunk.StorageObject, []chunk.StorageCommonPrefix, error) {
 	if len(delimiter) > 1 {
@@ -154,7 +221,7 @@ func (s *SwiftObjectClient) List(ctx context.Context, prefix, delimiter string)
 		opts.Delimiter = []rune(delimiter)[0]
 	}
 
-	objs, err := s.conn.Objects(s.cfg.ContainerName, opts)
+	objs, err := s.conn.ObjectsAll(s.cfg.ContainerName, opts)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -180,7 +247,11 @@ func (s *SwiftObjectClient) List(ctx context.Context, prefix, delimiter string)
 }
 
 // DeleteObject deletes the specified object key from the configured Swift container.
+// The segments of large objects are deleted as well.
 func (s *SwiftObjectClient) DeleteObject(ctx context.Context, objectKey string) error {
+	if s.cfg.SegmentSize > 0 {
+		return s.conn.LargeObjectDelete(s.cfg.ContainerName, objectKey)
+	}
 	return s.conn.ObjectDelete(s.cfg.ContainerName, objectKey)
 }
 
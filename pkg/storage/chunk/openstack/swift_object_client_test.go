@@ -3,7 +3,16 @@ package openstack
 import (
 	"bytes"
 	"context"
+	"crypto/md5" //nolint:gosec
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -109,3 +118,191 @@ func Test_Hedging(t *testing.T) {
 		})
 	}
 }
+
+// fakeSwift serves the objects of a Swift account, with static and dynamic large objects.
+type fakeSwift struct {
+	t   *testing.T
+	url string
+	slo bool
+
+	mtx     sync.Mutex
+	objects map[string][]byte
+	headers map[string]http.Header
+}
+
+func (f *fakeSwift) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	switch {
+	case r.URL.Path == "/auth/v1.0":
+		w.Header().Set("X-Storage-Url", f.url+"/v1/AUTH_test")
+		w.Header().Set("X-Auth-Token", "token")
+		return
+	case r.URL.Path == "/info":
+		if f.slo {
+			_, _ = w.Write([]byte(`{"slo": {"min_segment_size": 1}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/v1/AUTH_test/")
+	if !strings.Contains(path, "/") {
+		switch r.Method {
+		case http.MethodPut:
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodGet:
+			f.list(w, path, r.URL.Query().Get("prefix"))
+		}
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(f.t, err)
+		w.Header().Set("Etag", fmt.Sprintf("%x", md5.Sum(body)))
+		header := http.Header{}
+		switch {
+		case r.URL.Query().Get("multipart-manifest") == "put":
+			var segments []struct {
+				Path string `json:"path"`
+			}
+			require.NoError(f.t, json.Unmarshal(body, &segments))
+			var manifest []map[string]interface{}
+			for _, segment := range segments {
+				manifest = append(manifest, map[string]interface{}{"name": "/" + segment.Path, "bytes": len(f.objects[segment.Path])})
+			}
+			body, err = json.Marshal(manifest)
+			require.NoError(f.t, err)
+			header.Set("X-Static-Large-Object", "True")
+		case r.Header.Get("X-Object-Manifest") != "":
+			header.Set("X-Object-Manifest", r.Header.Get("X-Object-Manifest"))
+		}
+		f.objects[path] = body
+		f.headers[path] = header
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodGet, http.MethodHead:
+		body, ok := f.objects[path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		header := f.headers[path]
+		if r.URL.Query().Get("multipart-manifest") != "get" {
+			body = f.content(path)
+		}
+		for name := range header {
+			w.Header().Set(name, header.Get(name))
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(body)
+		}
+	case http.MethodDelete:
+		if _, ok := f.objects[path]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(f.objects, path)
+		delete(f.headers, path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// content returns the content of an object, assembled from its segments for large objects.
+func (f *fakeSwift) content(path string) []byte {
+	header := f.headers[path]
+	switch {
+	case header.Get("X-Static-Large-Object") != "":
+		var manifest []struct {
+			Name string `json:"name"`
+		}
+		require.NoError(f.t, json.Unmarshal(f.objects[path], &manifest))
+		var content []byte
+		for _, segment := range manifest {
+			content = append(content, f.objects[strings.TrimPrefix(segment.Name, "/")]...)
+		}
+		return content
+	case header.Get("X-Object-Manifest") != "":
+		var content []byte
+		for _, segment := range f.keys(header.Get("X-Object-Manifest")) {
+			content = append(content, f.objects[segment]...)
+		}
+		return content
+	}
+	return f.objects[path]
+}
+
+func (f *fakeSwift) keys(prefix string) []string {
+	var keys []string
+	for key := range f.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (f *fakeSwift) list(w http.ResponseWriter, container, prefix string) {
+	objects := []map[string]interface{}{}
+	for _, key := range f.keys(container + "/" + prefix) {
+		objects = append(objects, map[string]interface{}{"name": strings.TrimPrefix(key, container+"/"), "bytes": len(f.objects[key])})
+	}
+	require.NoError(f.t, json.NewEncoder(w).Encode(objects))
+}
+
+func TestSwiftObjectClient_LargeObjects(t *testing.T) {
+	for _, slo := range []bool{true, false} {
+		slo := slo
+		t.Run(fmt.Sprintf("slo=%v", slo), func(t *testing.T) {
+			defaultTransport = http.DefaultTransport
+			fake := &fakeSwift{t: t, slo: slo, objects: map[string][]byte{}, headers: map[string]http.Header{}}
+			server := httptest.NewServer(fake)
+			defer server.Close()
+			fake.url = server.URL
+
+			c, err := NewSwiftObjectClient(SwiftConfig{
+				Config: swift.Config{
+					AuthVersion:    1,
+					AuthURL:        server.URL + "/auth/v1.0",
+					Password:       "passwd",
+					ContainerName:  "chunks",
+					ConnectTimeout: 10 * time.Second,
+					RequestTimeout: 10 * time.Second,
+				},
+				SegmentSize: 10,
+			}, hedging.Config{})
+			require.NoError(t, err)
+			ctx := context.Background()
+
+			// small objects aren't segmented.
+			require.NoError(t, c.PutObject(ctx, "small", bytes.NewReader([]byte("0123456789"))))
+			require.Equal(t, []string{"chunks/small"}, fake.keys(""))
+
+			large := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+			require.NoError(t, c.PutObject(ctx, "large", bytes.NewReader(large)))
+			require.Len(t, fake.keys("chunks_segments/"), 4)
+
+			rc, size, err := c.GetObject(ctx, "large")
+			require.NoError(t, err)
+			content, err := ioutil.ReadAll(rc)
+			require.NoError(t, err)
+			require.Equal(t, large, content)
+			require.Equal(t, int64(len(large)), size)
+
+			objects, _, err := c.List(ctx, "", "")
+			require.NoError(t, err)
+			require.Len(t, objects, 2)
+
+			// the segments are deleted with their object.
+			require.NoError(t, c.DeleteObject(ctx, "large"))
+			require.NoError(t, c.DeleteObject(ctx, "small"))
+			require.Empty(t, fake.keys(""))
+			require.True(t, c.IsObjectNotFoundErr(c.DeleteObject(ctx, "small")))
+		})
+	}
+}
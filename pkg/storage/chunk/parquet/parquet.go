@@ -0,0 +1,454 @@
+// Package parquet writes and reads the entries of a log stream as Parquet files, so that the chunks
+// stored in the object storage can be queried by analytics tools.
+//
+// Only the subset of the format needed by Loki is supported: a file holds a single row group with
+// the required columns timestamp (int64, nanoseconds), line (UTF-8 string) and labels (the
+// dictionary encoded labels of the stream), each column chunk being made of a single page.
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/golang/snappy"
+
+	"github.com/grafana/loki/pkg/logproto"
+)
+
+// Magic is the magic number starting and ending Parquet files.
+const Magic = "PAR1"
+
+// Names of the columns of the files.
+const (
+	TimestampColumn = "timestamp"
+	LineColumn      = "line"
+	LabelsColumn    = "labels"
+)
+
+// ChunkMetadataKey is the key of the metadata of the chunk store in the key-value metadata of the files.
+const ChunkMetadataKey = "loki.chunk"
+
+const createdBy = "loki"
+
+// Parquet physical types, repetition types, encodings, compression codecs and page types.
+const (
+	typeInt64     = 2
+	typeByteArray = 6
+
+	repetitionRequired = 0
+
+	convertedTypeUTF8 = 0
+
+	encodingPlain           = 0
+	encodingPlainDictionary = 2
+	encodingRLE             = 3
+
+	codecUncompressed = 0
+	codecSnappy       = 1
+
+	pageData       = 0
+	pageDictionary = 2
+)
+
+var (
+	errNotParquet      = errors.New("not a parquet file")
+	errMissingColumn   = errors.New("parquet file without timestamp or line column")
+	errUnsupportedFile = errors.New("unsupported parquet file")
+)
+
+// IsFile tells whether b is a Parquet file.
+func IsFile(b []byte) bool {
+	return len(b) >= 2*len(Magic)+4 && string(b[:len(Magic)]) == Magic && string(b[len(b)-len(Magic):]) == Magic
+}
+
+// Write writes the entries of the stream with the given labels as a Parquet file, the metadata
+// being written in the key-value metadata of the file.
+func Write(w io.Writer, stream string, entries []logproto.Entry, metadata map[string]string) error {
+	buf := bytes.NewBufferString(Magic)
+
+	timestamps := make([]byte, 0, 8*len(entries))
+	var lines []byte
+	minTs, maxTs := int64(0), int64(0)
+	for i, e := range entries {
+		ts := e.Timestamp.UnixNano()
+		if i == 0 || ts < minTs {
+			minTs = ts
+		}
+		if i == 0 || ts > maxTs {
+			maxTs = ts
+		}
+		timestamps = appendInt64(timestamps, ts)
+		lines = appendByteArray(lines, e.Line)
+	}
+
+	var stats *statistics
+	if len(entries) > 0 {
+		stats = &statistics{
+			min: appendInt64(nil, minTs),
+			max: appendInt64(nil, maxTs),
+		}
+	}
+	columns := []columnChunk{
+		writeColumn(buf, TimestampColumn, typeInt64, len(entries), nil, timestamps, encodingPlain, stats),
+		writeColumn(buf, LineColumn, typeByteArray, len(entries), nil, lines, encodingPlain, nil),
+		// the labels are the same for all entries, they are written once in the dictionary and all
+		// entries refer to it with a single run of the index 0 encoded on 0 bits.
+		writeColumn(buf, LabelsColumn, typeByteArray, len(entries), appendByteArray(nil, stream),
+			dictionaryRun(len(entries)), encodingPlainDictionary, nil),
+	}
+
+	footer := encodeFileMetadata(int64(len(entries)), columns, metadata)
+	buf.Write(footer)
+	var footerLen [4]byte
+	binary.LittleEndian.PutUint32(footerLen[:], uint32(len(footer)))
+	buf.Write(footerLen[:])
+	buf.WriteString(Magic)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func appendInt64(b []byte, v int64) []byte {
+	var enc [8]byte
+	binary.LittleEndian.PutUint64(enc[:], uint64(v))
+	return append(b, enc[:]...)
+}
+
+func appendByteArray(b []byte, s string) []byte {
+	var enc [4]byte
+	binary.LittleEndian.PutUint32(enc[:], uint32(len(s)))
+	return append(append(b, enc[:]...), s...)
+}
+
+// dictionaryRun returns the dictionary indexes of n values all referring to the first value of
+// the dictionary: the bit width of the indexes followed by their RLE run.
+func dictionaryRun(n int) []byte {
+	b := make([]byte, 1+binary.MaxVarintLen64)
+	return b[:1+binary.PutUvarint(b[1:], uint64(n)<<1)]
+}
+
+type statistics struct {
+	min, max []byte
+}
+
+type columnChunk struct {
+	name               string
+	typ                int32
+	encoding           int32
+	numValues          int64
+	dataPageOffset     int64
+	dictionaryOffset   int64
+	uncompressedSize   int64
+	compressedSize     int64
+	stats              *statistics
+	hasDictionaryPages bool
+}
+
+// writeColumn writes the pages of a column, its dictionary page first when there is a dictionary.
+func writeColumn(buf *bytes.Buffer, name string, typ int32, numValues int, dictionary, values []byte, encoding int32, stats *statistics) columnChunk {
+	c := columnChunk{
+		name:      name,
+		typ:       typ,
+		encoding:  encoding,
+		numValues: int64(numValues),
+		stats:     stats,
+	}
+	if dictionary != nil {
+		c.hasDictionaryPages = true
+		c.dictionaryOffset = int64(buf.Len())
+		c.writePage(buf, pageDictionary, 1, encodingPlainDictionary, dictionary)
+	}
+	c.dataPageOffset = int64(buf.Len())
+	c.writePage(buf, pageData, numValues, encoding, values)
+	return c
+}
+
+func (c *columnChunk) writePage(buf *bytes.Buffer, typ int32, numValues int, encoding int32, data []byte) {
+	compressed := snappy.Encode(nil, data)
+
+	w := newThriftWriter()
+	w.i32(1, typ)
+	w.i32(2, int32(len(data)))
+	w.i32(3, int32(len(compressed)))
+	if typ == pageDictionary {
+		w.structBegin(7)
+		w.i32(1, int32(numValues))
+		w.i32(2, encoding)
+		w.structEnd()
+	} else {
+		w.structBegin(5)
+		w.i32(1, int32(numValues))
+		w.i32(2, encoding)
+		w.i32(3, encodingRLE)
+		w.i32(4, encodingRLE)
+		w.structEnd()
+	}
+	header := w.bytes()
+
+	buf.Write(header)
+	buf.Write(compressed)
+	c.uncompressedSize += int64(len(header) + len(data))
+	c.compressedSize += int64(len(header) + len(compressed))
+}
+
+func encodeFileMetadata(numRows int64, columns []columnChunk, metadata map[string]string) []byte {
+	w := newThriftWriter()
+	w.i32(1, 1)
+
+	// schema: the root followed by the columns.
+	w.list(2, thriftStruct, len(columns)+1)
+	w.structBegin(0)
+	w.string(4, "schema")
+	w.i32(5, int32(len(columns)))
+	w.structEnd()
+	for _, c := range columns {
+		w.structBegin(0)
+		w.i32(1, c.typ)
+		w.i32(3, repetitionRequired)
+		w.string(4, c.name)
+		if c.typ == typeByteArray {
+			w.i32(6, convertedTypeUTF8)
+		}
+		// logical type: string or timestamp in nanoseconds adjusted to UTC.
+		w.structBegin(10)
+		if c.typ == typeByteArray {
+			w.structBegin(1)
+			w.structEnd()
+		} else {
+			w.structBegin(8)
+			w.bool(1, true)
+			w.structBegin(2)
+			w.structBegin(3)
+			w.structEnd()
+			w.structEnd()
+			w.structEnd()
+		}
+		w.structEnd()
+		w.structEnd()
+	}
+
+	w.i64(3, numRows)
+
+	var totalSize int64
+	for _, c := range columns {
+		totalSize += c.uncompressedSize
+	}
+	w.list(4, thriftStruct, 1)
+	w.structBegin(0)
+	w.list(1, thriftStruct, len(columns))
+	for _, c := range columns {
+		w.structBegin(0)
+		w.i64(2, c.dataPageOffset)
+		w.structBegin(3)
+		w.i32(1, c.typ)
+		w.list(2, thriftI32, 2)
+		w.varint(int64(c.encoding))
+		w.varint(encodingRLE)
+		w.list(3, thriftBinary, 1)
+		w.binaryValue([]byte(c.name))
+		w.i32(4, codecSnappy)
+		w.i64(5, c.numValues)
+		w.i64(6, c.uncompressedSize)
+		w.i64(7, c.compressedSize)
+		w.i64(9, c.dataPageOffset)
+		if c.hasDictionaryPages {
+			w.i64(11, c.dictionaryOffset)
+		}
+		if c.stats != nil {
+			w.structBegin(12)
+			w.binary(5, c.stats.max)
+			w.binary(6, c.stats.min)
+			w.structEnd()
+		}
+		w.structEnd()
+		w.structEnd()
+	}
+	w.i64(2, totalSize)
+	w.i64(3, numRows)
+	w.structEnd()
+
+	if len(metadata) > 0 {
+		keys := make([]string, 0, len(metadata))
+		for k := range metadata {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		w.list(5, thriftStruct, len(keys))
+		for _, k := range keys {
+			w.structBegin(0)
+			w.string(1, k)
+			w.string(2, metadata[k])
+			w.structEnd()
+		}
+	}
+	w.string(6, createdBy)
+	return w.bytes()
+}
+
+// File is a Parquet file written by Write.
+type File struct {
+	b        []byte
+	metadata thriftFields
+}
+
+// Open reads the metadata of the Parquet file b.
+func Open(b []byte) (*File, error) {
+	if !IsFile(b) {
+		return nil, errNotParquet
+	}
+	footerLen := int(binary.LittleEndian.Uint32(b[len(b)-len(Magic)-4:]))
+	footerEnd := len(b) - len(Magic) - 4
+	if footerLen > footerEnd-len(Magic) {
+		return nil, fmt.Errorf("invalid parquet footer length %d", footerLen)
+	}
+	r := thriftReader{b: b[footerEnd-footerLen : footerEnd]}
+	metadata, err := r.structure(0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid parquet footer: %w", err)
+	}
+	return &File{b: b, metadata: metadata}, nil
+}
+
+// NumRows returns the number of rows of the file.
+func (f *File) NumRows() int64 {
+	n, _ := f.metadata.int(3)
+	return n
+}
+
+// Metadata returns the value of the key-value metadata of the file.
+func (f *File) Metadata(key string) (string, bool) {
+	for _, kv := range f.metadata.list(5) {
+		if kv, ok := kv.(thriftFields); ok && kv.string(1) == key {
+			return kv.string(2), true
+		}
+	}
+	return "", false
+}
+
+// Entries decodes the entries of the file from its timestamp and line columns.
+func (f *File) Entries() ([]logproto.Entry, error) {
+	for _, s := range f.metadata.list(2) {
+		s, _ := s.(thriftFields)
+		if r, ok := s.int(3); ok && r != repetitionRequired {
+			return nil, fmt.Errorf("%w: column %s isn't required", errUnsupportedFile, s.string(4))
+		}
+	}
+
+	var entries []logproto.Entry
+	// every row takes at least the 8 bytes of its timestamp.
+	if n := f.NumRows(); n > 0 && n <= int64(len(f.b)/8) {
+		entries = make([]logproto.Entry, 0, n)
+	}
+	for _, rg := range f.metadata.list(4) {
+		rg, _ := rg.(thriftFields)
+		var timestamps, lines []byte
+		var numValues int64
+		found := 0
+		for _, c := range rg.list(1) {
+			c, _ := c.(thriftFields)
+			meta, _ := c.structure(3)
+			path := meta.list(3)
+			if len(path) != 1 {
+				continue
+			}
+			name, _ := path[0].([]byte)
+			switch string(name) {
+			case TimestampColumn, LineColumn:
+			default:
+				continue
+			}
+			values, err := f.readColumn(meta)
+			if err != nil {
+				return nil, fmt.Errorf("invalid parquet column %s: %w", name, err)
+			}
+			found++
+			if string(name) == TimestampColumn {
+				timestamps = values
+				numValues, _ = meta.int(5)
+			} else {
+				lines = values
+			}
+		}
+		if found != 2 {
+			return nil, errMissingColumn
+		}
+		if int64(len(timestamps)) != 8*numValues {
+			return nil, fmt.Errorf("%w: %d bytes of timestamps for %d values", errUnsupportedFile, len(timestamps), numValues)
+		}
+		for i := int64(0); i < numValues; i++ {
+			if len(lines) < 4 {
+				return nil, fmt.Errorf("%w: missing lines", errUnsupportedFile)
+			}
+			n := binary.LittleEndian.Uint32(lines)
+			if uint64(n) > uint64(len(lines)-4) {
+				return nil, fmt.Errorf("%w: line of %d bytes out of bounds", errUnsupportedFile, n)
+			}
+			entries = append(entries, logproto.Entry{
+				Timestamp: time.Unix(0, int64(binary.LittleEndian.Uint64(timestamps[8*i:]))),
+				Line:      string(lines[4 : 4+n]),
+			})
+			lines = lines[4+n:]
+		}
+	}
+	return entries, nil
+}
+
+// readColumn returns the plain encoded values of the pages of a column chunk.
+func (f *File) readColumn(meta thriftFields) ([]byte, error) {
+	codec, _ := meta.int(4)
+	numValues, _ := meta.int(5)
+	offset, _ := meta.int(9)
+	if dictOffset, ok := meta.int(11); ok && dictOffset > 0 && dictOffset < offset {
+		offset = dictOffset
+	}
+
+	var values []byte
+	for read := int64(0); read < numValues; {
+		if offset < 0 || offset >= int64(len(f.b)) {
+			return nil, fmt.Errorf("page offset %d out of bounds", offset)
+		}
+		r := thriftReader{b: f.b[offset:]}
+		header, err := r.structure(0)
+		if err != nil {
+			return nil, err
+		}
+		size, _ := header.int(3)
+		start := offset + int64(r.pos)
+		if size < 0 || start+size > int64(len(f.b)) {
+			return nil, fmt.Errorf("page of %d bytes out of bounds", size)
+		}
+		offset = start + size
+
+		typ, _ := header.int(1)
+		if typ != pageData {
+			continue
+		}
+		dataHeader, _ := header.structure(5)
+		if enc, _ := dataHeader.int(2); enc != encodingPlain {
+			return nil, fmt.Errorf("%w: encoding %d", errUnsupportedFile, enc)
+		}
+		n, _ := dataHeader.int(1)
+		if n <= 0 {
+			return nil, fmt.Errorf("invalid number of values %d", n)
+		}
+		read += n
+
+		page := f.b[start:offset]
+		switch codec {
+		case codecUncompressed:
+		case codecSnappy:
+			if page, err = snappy.Decode(nil, page); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("%w: compression codec %d", errUnsupportedFile, codec)
+		}
+		values = append(values, page...)
+	}
+	return values, nil
+}
@@ -0,0 +1,358 @@
+package parquet
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/jaeger-client-go/thrift"
+
+	"github.com/grafana/loki/pkg/logproto"
+)
+
+func TestWriteRead(t *testing.T) {
+	for _, n := range []int{0, 1, 20, 1000} {
+		t.Run(fmt.Sprintf("%d entries", n), func(t *testing.T) {
+			var entries []logproto.Entry
+			for i := 0; i < n; i++ {
+				entries = append(entries, logproto.Entry{
+					Timestamp: time.Unix(int64(i), int64(i%1000)).UTC(),
+					Line:      fmt.Sprintf("level=info msg=%q", bytes.Repeat([]byte{'a'}, i%50)),
+				})
+			}
+			metadata := map[string]string{"b": "2", "a": "1"}
+
+			var buf bytes.Buffer
+			require.NoError(t, Write(&buf, `{app="foo"}`, entries, metadata))
+			require.True(t, IsFile(buf.Bytes()))
+
+			f, err := Open(buf.Bytes())
+			require.NoError(t, err)
+			require.Equal(t, int64(n), f.NumRows())
+			for k, v := range metadata {
+				actual, ok := f.Metadata(k)
+				require.True(t, ok)
+				require.Equal(t, v, actual)
+			}
+			_, ok := f.Metadata("c")
+			require.False(t, ok)
+
+			actual, err := f.Entries()
+			require.NoError(t, err)
+			require.Len(t, actual, n)
+			for i := range entries {
+				require.Equal(t, entries[i].Timestamp.UnixNano(), actual[i].Timestamp.UnixNano())
+				require.Equal(t, entries[i].Line, actual[i].Line)
+			}
+		})
+	}
+}
+
+func TestOpenInvalid(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, `{app="foo"}`, []logproto.Entry{{Timestamp: time.Unix(1, 0), Line: "foo"}}, nil))
+	b := buf.Bytes()
+
+	for name, tc := range map[string][]byte{
+		"empty":          nil,
+		"missing footer": b[:len(b)-1],
+		"not parquet":    []byte("foo bar baz buzz"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			require.False(t, IsFile(tc))
+			_, err := Open(tc)
+			require.Error(t, err)
+		})
+	}
+
+	// a footer length beyond the file.
+	corrupted := append([]byte{}, b...)
+	copy(corrupted[len(corrupted)-8:], []byte{0xff, 0xff, 0xff, 0x00})
+	_, err := Open(corrupted)
+	require.Error(t, err)
+}
+
+func TestThriftRoundtrip(t *testing.T) {
+	w := newThriftWriter()
+	w.i32(1, -5)
+	w.bool(2, true)
+	w.bool(3, false)
+	// field deltas over 15 use the long form.
+	w.i64(100, 1<<40)
+	w.string(101, "foo")
+	w.list(102, thriftI32, 20)
+	for i := 0; i < 20; i++ {
+		w.varint(int64(i))
+	}
+	w.structBegin(103)
+	w.i32(1, 7)
+	w.structEnd()
+
+	r := thriftReader{b: w.bytes()}
+	fields, err := r.structure(0)
+	require.NoError(t, err)
+
+	v, _ := fields.int(1)
+	require.Equal(t, int64(-5), v)
+	require.Equal(t, true, fields[2])
+	require.Equal(t, false, fields[3])
+	v, _ = fields.int(100)
+	require.Equal(t, int64(1<<40), v)
+	require.Equal(t, "foo", fields.string(101))
+	require.Len(t, fields.list(102), 20)
+	require.Equal(t, int64(19), fields.list(102)[19])
+	nested, ok := fields.structure(103)
+	require.True(t, ok)
+	v, _ = nested.int(1)
+	require.Equal(t, int64(7), v)
+}
+
+// TestWriteConformance decodes the files written by Write with the thrift compact protocol of the
+// thrift library instead of the reader of this package, and checks them against the Parquet format
+// specification: https://github.com/apache/parquet-format/blob/master/src/main/thrift/parquet.thrift.
+func TestWriteConformance(t *testing.T) {
+	for _, n := range []int{0, 1, 1000} {
+		t.Run(fmt.Sprintf("%d entries", n), func(t *testing.T) {
+			var entries []logproto.Entry
+			for i := 0; i < n; i++ {
+				entries = append(entries, logproto.Entry{
+					Timestamp: time.Unix(int64(n-i), 0),
+					Line:      fmt.Sprintf("line %d", i),
+				})
+			}
+			var buf bytes.Buffer
+			require.NoError(t, Write(&buf, `{app="foo"}`, entries, map[string]string{"a": "1"}))
+			b := buf.Bytes()
+
+			// the file is `PAR1 <column chunks> <footer> <footer length> PAR1`.
+			require.Equal(t, []byte("PAR1"), b[:4])
+			require.Equal(t, []byte("PAR1"), b[len(b)-4:])
+			footerLen := int(binary.LittleEndian.Uint32(b[len(b)-8:]))
+			footerStart := len(b) - 8 - footerLen
+			footer, size := decodeSpec(t, b[footerStart:len(b)-8])
+			require.Equal(t, footerLen, size)
+
+			// FileMetaData
+			require.Equal(t, int32(1), specField(t, footer, 1, thrift.I32))
+			require.Equal(t, int64(n), specField(t, footer, 3, thrift.I64))
+			require.Equal(t, "loki", string(specField(t, footer, 6, thrift.STRING).([]byte)))
+			kvs := specList(t, footer, 5, thrift.STRUCT)
+			require.Len(t, kvs, 1)
+			require.Equal(t, "a", string(specField(t, kvs[0].(specStruct), 1, thrift.STRING).([]byte)))
+			require.Equal(t, "1", string(specField(t, kvs[0].(specStruct), 2, thrift.STRING).([]byte)))
+
+			// SchemaElement: the root followed by the required leaf columns.
+			schema := specList(t, footer, 2, thrift.STRUCT)
+			require.Len(t, schema, 4)
+			require.Equal(t, int32(3), specField(t, schema[0].(specStruct), 5, thrift.I32))
+			for i, c := range []struct {
+				name string
+				typ  int32
+			}{{TimestampColumn, typeInt64}, {LineColumn, typeByteArray}, {LabelsColumn, typeByteArray}} {
+				s := schema[i+1].(specStruct)
+				require.Equal(t, c.name, string(specField(t, s, 4, thrift.STRING).([]byte)))
+				require.Equal(t, c.typ, specField(t, s, 1, thrift.I32))
+				require.Equal(t, int32(repetitionRequired), specField(t, s, 3, thrift.I32))
+				logicalType := specField(t, s, 10, thrift.STRUCT).(specStruct)
+				if c.typ == typeByteArray {
+					require.Equal(t, int32(convertedTypeUTF8), specField(t, s, 6, thrift.I32))
+					specField(t, logicalType, 1, thrift.STRUCT) // STRING
+					continue
+				}
+				timestamp := specField(t, logicalType, 8, thrift.STRUCT).(specStruct)
+				require.Equal(t, true, specField(t, timestamp, 1, thrift.BOOL))
+				unit := specField(t, timestamp, 2, thrift.STRUCT).(specStruct)
+				specField(t, unit, 3, thrift.STRUCT) // NANOS
+			}
+
+			// RowGroup and its ColumnChunks, which follow each other from the magic to the footer.
+			rowGroups := specList(t, footer, 4, thrift.STRUCT)
+			require.Len(t, rowGroups, 1)
+			rowGroup := rowGroups[0].(specStruct)
+			require.Equal(t, int64(n), specField(t, rowGroup, 3, thrift.I64))
+			columns := specList(t, rowGroup, 1, thrift.STRUCT)
+			require.Len(t, columns, 3)
+
+			offset := int64(4)
+			var totalSize int64
+			values := map[string][]byte{}
+			for i, c := range columns {
+				specField(t, c.(specStruct), 2, thrift.I64) // file_offset
+				meta := specField(t, c.(specStruct), 3, thrift.STRUCT).(specStruct)
+				name := string(specList(t, meta, 3, thrift.STRING)[0].([]byte))
+				require.Equal(t, schema[i+1].(specStruct)[4].v, []byte(name))
+				require.Equal(t, specField(t, schema[i+1].(specStruct), 1, thrift.I32), specField(t, meta, 1, thrift.I32))
+				require.Equal(t, int32(codecSnappy), specField(t, meta, 4, thrift.I32))
+				require.Equal(t, int64(n), specField(t, meta, 5, thrift.I64))
+				encodings := specList(t, meta, 2, thrift.I32)
+
+				dataPageOffset := specField(t, meta, 9, thrift.I64).(int64)
+				if name == LabelsColumn {
+					require.Equal(t, offset, specField(t, meta, 11, thrift.I64))
+					require.Equal(t, []interface{}{int32(encodingPlainDictionary), int32(encodingRLE)}, encodings)
+				} else {
+					require.Equal(t, offset, dataPageOffset)
+					require.NotContains(t, meta, int16(11))
+					require.Equal(t, []interface{}{int32(encodingPlain), int32(encodingRLE)}, encodings)
+				}
+
+				// PageHeaders: the sizes of the column chunk include the headers of its pages.
+				var compressedSize, uncompressedSize int64
+				for page := 0; page == 0 || offset <= dataPageOffset; page++ {
+					header, headerSize := decodeSpec(t, b[offset:footerStart])
+					pageType := specField(t, header, 1, thrift.I32).(int32)
+					uncompressed := specField(t, header, 2, thrift.I32).(int32)
+					compressed := specField(t, header, 3, thrift.I32).(int32)
+					data, err := snappy.Decode(nil, b[offset+int64(headerSize):offset+int64(headerSize)+int64(compressed)])
+					require.NoError(t, err)
+					require.Len(t, data, int(uncompressed))
+
+					switch pageType {
+					case pageDictionary:
+						require.Equal(t, LabelsColumn, name)
+						dictionary := specField(t, header, 7, thrift.STRUCT).(specStruct)
+						require.Equal(t, int32(1), specField(t, dictionary, 1, thrift.I32))
+						require.Equal(t, int32(encodingPlainDictionary), specField(t, dictionary, 2, thrift.I32))
+						require.Equal(t, `{app="foo"}`, string(decodePlainByteArrays(t, data, 1)[0]))
+					case pageData:
+						require.Equal(t, dataPageOffset, offset)
+						dataPage := specField(t, header, 5, thrift.STRUCT).(specStruct)
+						require.Equal(t, int32(n), specField(t, dataPage, 1, thrift.I32))
+						require.Equal(t, encodings[0], specField(t, dataPage, 2, thrift.I32))
+						require.Equal(t, int32(encodingRLE), specField(t, dataPage, 3, thrift.I32))
+						require.Equal(t, int32(encodingRLE), specField(t, dataPage, 4, thrift.I32))
+						values[name] = data
+					default:
+						t.Fatalf("unexpected page type %d", pageType)
+					}
+					offset += int64(headerSize) + int64(compressed)
+					compressedSize += int64(headerSize) + int64(compressed)
+					uncompressedSize += int64(headerSize) + int64(uncompressed)
+				}
+				require.Equal(t, compressedSize, specField(t, meta, 7, thrift.I64))
+				require.Equal(t, uncompressedSize, specField(t, meta, 6, thrift.I64))
+				totalSize += uncompressedSize
+
+				if name == TimestampColumn && n > 0 {
+					stats := specField(t, meta, 12, thrift.STRUCT).(specStruct)
+					require.Equal(t, appendInt64(nil, entries[n-1].Timestamp.UnixNano()), specField(t, stats, 6, thrift.STRING))
+					require.Equal(t, appendInt64(nil, entries[0].Timestamp.UnixNano()), specField(t, stats, 5, thrift.STRING))
+				}
+			}
+			require.Equal(t, int64(footerStart), offset)
+			require.Equal(t, totalSize, specField(t, rowGroup, 2, thrift.I64))
+
+			// PLAIN int64 timestamps and byte array lines, RLE run of the dictionary index 0 encoded on 0 bits.
+			require.Len(t, values[TimestampColumn], 8*n)
+			lines := decodePlainByteArrays(t, values[LineColumn], n)
+			for i, e := range entries {
+				require.Equal(t, e.Timestamp.UnixNano(), int64(binary.LittleEndian.Uint64(values[TimestampColumn][8*i:])))
+				require.Equal(t, e.Line, string(lines[i]))
+			}
+			indexes := values[LabelsColumn]
+			require.Equal(t, byte(0), indexes[0])
+			run, size := binary.Uvarint(indexes[1:])
+			require.Equal(t, len(indexes)-1, size)
+			require.Equal(t, uint64(0), run&1)
+			require.Equal(t, uint64(n), run>>1)
+		})
+	}
+}
+
+type specValue struct {
+	typ thrift.TType
+	v   interface{}
+}
+
+type specStruct map[int16]specValue
+
+// decodeSpec decodes the structure at the start of b, and returns it with its size.
+func decodeSpec(t *testing.T, b []byte) (specStruct, int) {
+	buf := thrift.NewTMemoryBuffer()
+	buf.Write(b)
+	v := readSpecValue(t, thrift.NewTCompactProtocol(buf), thrift.STRUCT)
+	return v.(specStruct), len(b) - buf.Len()
+}
+
+func readSpecValue(t *testing.T, p *thrift.TCompactProtocol, typ thrift.TType) interface{} {
+	ctx := context.Background()
+	var (
+		v   interface{}
+		err error
+	)
+	switch typ {
+	case thrift.BOOL:
+		v, err = p.ReadBool(ctx)
+	case thrift.I32:
+		v, err = p.ReadI32(ctx)
+	case thrift.I64:
+		v, err = p.ReadI64(ctx)
+	case thrift.STRING:
+		v, err = p.ReadBinary(ctx)
+	case thrift.LIST:
+		elemType, size, err := p.ReadListBegin(ctx)
+		require.NoError(t, err)
+		list := []interface{}{}
+		for i := 0; i < size; i++ {
+			list = append(list, specValue{typ: elemType, v: readSpecValue(t, p, elemType)})
+		}
+		require.NoError(t, p.ReadListEnd(ctx))
+		return list
+	case thrift.STRUCT:
+		_, err := p.ReadStructBegin(ctx)
+		require.NoError(t, err)
+		s := specStruct{}
+		for {
+			_, fieldType, id, err := p.ReadFieldBegin(ctx)
+			require.NoError(t, err)
+			if fieldType == thrift.STOP {
+				break
+			}
+			require.NotContains(t, s, id)
+			s[id] = specValue{typ: fieldType, v: readSpecValue(t, p, fieldType)}
+			require.NoError(t, p.ReadFieldEnd(ctx))
+		}
+		require.NoError(t, p.ReadStructEnd(ctx))
+		return s
+	default:
+		t.Fatalf("thrift type %s isn't used by Parquet", typ)
+	}
+	require.NoError(t, err)
+	return v
+}
+
+// specField returns the value of the field of the given id, which must be of the given type.
+func specField(t *testing.T, s specStruct, id int16, typ thrift.TType) interface{} {
+	f, ok := s[id]
+	require.True(t, ok, "missing field %d", id)
+	require.Equal(t, typ, f.typ, "type of field %d", id)
+	return f.v
+}
+
+// thriftList returns the values of the list field of the given id, whose elements must be of the given type.
+func specList(t *testing.T, s specStruct, id int16, elemType thrift.TType) []interface{} {
+	var values []interface{}
+	for _, e := range specField(t, s, id, thrift.LIST).([]interface{}) {
+		require.Equal(t, elemType, e.(specValue).typ)
+		values = append(values, e.(specValue).v)
+	}
+	return values
+}
+
+func decodePlainByteArrays(t *testing.T, b []byte, n int) [][]byte {
+	var values [][]byte
+	for i := 0; i < n; i++ {
+		require.GreaterOrEqual(t, len(b), 4)
+		size := int(binary.LittleEndian.Uint32(b))
+		require.GreaterOrEqual(t, len(b)-4, size)
+		values = append(values, b[4:4+size])
+		b = b[4+size:]
+	}
+	require.Empty(t, b)
+	return values
+}
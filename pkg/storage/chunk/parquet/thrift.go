@@ -0,0 +1,299 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Types of the thrift compact protocol used to encode the metadata of Parquet files.
+const (
+	thriftStop       = 0
+	thriftTrue       = 1
+	thriftFalse      = 2
+	thriftByte       = 3
+	thriftI16        = 4
+	thriftI32        = 5
+	thriftI64        = 6
+	thriftDouble     = 7
+	thriftBinary     = 8
+	thriftList       = 9
+	thriftSet        = 10
+	thriftMap        = 11
+	thriftStruct     = 12
+	maxThriftNesting = 32
+)
+
+var errThriftNesting = errors.New("thrift structure nested too deeply")
+
+// thriftWriter writes thrift structures with the compact protocol.
+type thriftWriter struct {
+	buf bytes.Buffer
+	enc [binary.MaxVarintLen64]byte
+
+	// last field id of the structures being written, the field headers hold the delta with it.
+	lastField []int16
+}
+
+func newThriftWriter() *thriftWriter {
+	return &thriftWriter{lastField: []int16{0}}
+}
+
+func (w *thriftWriter) uvarint(v uint64) {
+	n := binary.PutUvarint(w.enc[:], v)
+	w.buf.Write(w.enc[:n])
+}
+
+func (w *thriftWriter) varint(v int64) {
+	n := binary.PutVarint(w.enc[:], v)
+	w.buf.Write(w.enc[:n])
+}
+
+func (w *thriftWriter) field(id int16, typ byte) {
+	last := &w.lastField[len(w.lastField)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		w.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		w.buf.WriteByte(typ)
+		w.varint(int64(id))
+	}
+	*last = id
+}
+
+func (w *thriftWriter) bool(id int16, v bool) {
+	if v {
+		w.field(id, thriftTrue)
+	} else {
+		w.field(id, thriftFalse)
+	}
+}
+
+func (w *thriftWriter) i32(id int16, v int32) {
+	w.field(id, thriftI32)
+	w.varint(int64(v))
+}
+
+func (w *thriftWriter) i64(id int16, v int64) {
+	w.field(id, thriftI64)
+	w.varint(v)
+}
+
+func (w *thriftWriter) binary(id int16, v []byte) {
+	w.field(id, thriftBinary)
+	w.binaryValue(v)
+}
+
+func (w *thriftWriter) string(id int16, v string) {
+	w.binary(id, []byte(v))
+}
+
+func (w *thriftWriter) binaryValue(v []byte) {
+	w.uvarint(uint64(len(v)))
+	w.buf.Write(v)
+}
+
+// structBegin starts a structure field, or a structure element of a list when id is 0.
+func (w *thriftWriter) structBegin(id int16) {
+	if id != 0 {
+		w.field(id, thriftStruct)
+	}
+	w.lastField = append(w.lastField, 0)
+}
+
+func (w *thriftWriter) structEnd() {
+	w.buf.WriteByte(thriftStop)
+	w.lastField = w.lastField[:len(w.lastField)-1]
+}
+
+func (w *thriftWriter) list(id int16, elemType byte, size int) {
+	w.field(id, thriftList)
+	if size < 15 {
+		w.buf.WriteByte(byte(size)<<4 | elemType)
+		return
+	}
+	w.buf.WriteByte(0xf0 | elemType)
+	w.uvarint(uint64(size))
+}
+
+func (w *thriftWriter) bytes() []byte {
+	w.buf.WriteByte(thriftStop)
+	return w.buf.Bytes()
+}
+
+// thriftFields holds the fields of a decoded thrift structure by id. Integers are decoded as int64,
+// binaries as []byte, lists and sets as []interface{} and structures as thriftFields.
+type thriftFields map[int16]interface{}
+
+func (f thriftFields) int(id int16) (int64, bool) {
+	v, ok := f[id].(int64)
+	return v, ok
+}
+
+func (f thriftFields) string(id int16) string {
+	v, _ := f[id].([]byte)
+	return string(v)
+}
+
+func (f thriftFields) list(id int16) []interface{} {
+	v, _ := f[id].([]interface{})
+	return v
+}
+
+func (f thriftFields) structure(id int16) (thriftFields, bool) {
+	v, ok := f[id].(thriftFields)
+	return v, ok
+}
+
+// thriftReader reads thrift structures encoded with the compact protocol.
+type thriftReader struct {
+	b   []byte
+	pos int
+}
+
+func (r *thriftReader) byte() (byte, error) {
+	if r.pos >= len(r.b) {
+		return 0, fmt.Errorf("unexpected end of thrift data at %d", r.pos)
+	}
+	r.pos++
+	return r.b[r.pos-1], nil
+}
+
+func (r *thriftReader) uvarint() (uint64, error) {
+	v, n := binary.Uvarint(r.b[r.pos:])
+	if n <= 0 {
+		return 0, fmt.Errorf("invalid thrift varint at %d", r.pos)
+	}
+	r.pos += n
+	return v, nil
+}
+
+func (r *thriftReader) varint() (int64, error) {
+	v, n := binary.Varint(r.b[r.pos:])
+	if n <= 0 {
+		return 0, fmt.Errorf("invalid thrift varint at %d", r.pos)
+	}
+	r.pos += n
+	return v, nil
+}
+
+func (r *thriftReader) binary() ([]byte, error) {
+	n, err := r.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(r.b)-r.pos) {
+		return nil, fmt.Errorf("thrift binary of %d bytes out of bounds at %d", n, r.pos)
+	}
+	v := r.b[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+	return v, nil
+}
+
+func (r *thriftReader) structure(depth int) (thriftFields, error) {
+	if depth > maxThriftNesting {
+		return nil, errThriftNesting
+	}
+	fields := thriftFields{}
+	var id int16
+	for {
+		header, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		typ := header & 0x0f
+		if typ == thriftStop {
+			return fields, nil
+		}
+		if delta := int16(header >> 4); delta != 0 {
+			id += delta
+		} else {
+			v, err := r.varint()
+			if err != nil {
+				return nil, err
+			}
+			id = int16(v)
+		}
+		switch typ {
+		case thriftTrue:
+			fields[id] = true
+		case thriftFalse:
+			fields[id] = false
+		default:
+			if fields[id], err = r.value(typ, depth); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+func (r *thriftReader) value(typ byte, depth int) (interface{}, error) {
+	switch typ {
+	case thriftTrue, thriftFalse:
+		// booleans within lists are written as a whole byte.
+		b, err := r.byte()
+		return b == thriftTrue, err
+	case thriftByte:
+		b, err := r.byte()
+		return int64(int8(b)), err
+	case thriftI16, thriftI32, thriftI64:
+		return r.varint()
+	case thriftDouble:
+		if len(r.b)-r.pos < 8 {
+			return nil, fmt.Errorf("unexpected end of thrift data at %d", r.pos)
+		}
+		v := math.Float64frombits(binary.LittleEndian.Uint64(r.b[r.pos:]))
+		r.pos += 8
+		return v, nil
+	case thriftBinary:
+		return r.binary()
+	case thriftList, thriftSet:
+		header, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		size := uint64(header >> 4)
+		if size == 15 {
+			if size, err = r.uvarint(); err != nil {
+				return nil, err
+			}
+		}
+		// every element takes at least a byte.
+		if size > uint64(len(r.b)-r.pos) {
+			return nil, fmt.Errorf("thrift list of %d elements out of bounds at %d", size, r.pos)
+		}
+		list := make([]interface{}, 0, size)
+		for i := uint64(0); i < size; i++ {
+			v, err := r.value(header&0x0f, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	case thriftMap:
+		size, err := r.uvarint()
+		if err != nil || size == 0 {
+			return nil, err
+		}
+		types, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		// maps aren't used by Parquet, they are only skipped.
+		for i := uint64(0); i < size; i++ {
+			if _, err := r.value(types>>4, depth+1); err != nil {
+				return nil, err
+			}
+			if _, err := r.value(types&0x0f, depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	case thriftStruct:
+		return r.structure(depth + 1)
+	default:
+		return nil, fmt.Errorf("invalid thrift type %d at %d", typ, r.pos)
+	}
+}
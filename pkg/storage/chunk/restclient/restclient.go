@@ -0,0 +1,338 @@
+// Package restclient implements the object clients of the object stores reached through their REST
+// API: the requests, their retries and hedging, and the listing of the objects are shared, the
+// object stores only build the URLs and sign the requests.
+package restclient
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/dskit/backoff"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/weaveworks/common/instrument"
+
+	"github.com/grafana/loki/pkg/storage/chunk"
+	"github.com/grafana/loki/pkg/storage/chunk/hedging"
+)
+
+const (
+	// MaxListKeys is the maximum number of objects returned by a list request.
+	MaxListKeys = 1000
+
+	// ErrCodeNoSuchKey is the code of the errors of the requests of missing objects.
+	ErrCodeNoSuchKey = "NoSuchKey"
+)
+
+// Provider builds and signs the requests of an object store, and decodes its responses.
+type Provider interface {
+	// URL returns the URL of the object, or of the bucket when the key is empty.
+	URL(objectKey string, query url.Values) url.URL
+	// Sign signs the request of the object, or of the bucket when the key is empty.
+	Sign(req *http.Request, objectKey string, query url.Values)
+	// ListQuery returns the query parameters of the request listing the objects following the marker.
+	ListQuery(prefix, delimiter, marker string) url.Values
+	// DecodeListPage decodes the response of a list request.
+	DecodeListPage(r io.Reader) (ListPage, error)
+	// DecodeError decodes the body of the response of a failed request.
+	DecodeError(r io.Reader, err *Error) error
+}
+
+// ListPage is a page of the objects of a list request.
+type ListPage struct {
+	Objects        []chunk.StorageObject
+	CommonPrefixes []chunk.StorageCommonPrefix
+	// NextMarker is the marker of the next page, empty for the last page.
+	NextMarker string
+}
+
+// Config is the config of a Client.
+type Config struct {
+	// Service is the name of the object store, e.g. OSS, prefixing the operations and the errors.
+	Service         string
+	RequestTimeout  time.Duration
+	BackoffConfig   backoff.Config
+	RequestDuration *instrument.HistogramCollector
+}
+
+// Client implements chunk.ObjectClient over the REST API of an object store.
+type Client struct {
+	cfg          Config
+	provider     Provider
+	client       *http.Client
+	hedgedClient *http.Client
+}
+
+// NewTransport returns the transport of the requests to an object store.
+func NewTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = 200
+	transport.MaxIdleConnsPerHost = 200
+	return transport
+}
+
+// New makes a new Client, the gets of the objects being hedged.
+func New(cfg Config, provider Provider, hedgingCfg hedging.Config, transport http.RoundTripper) (*Client, error) {
+	hedgedTransport, err := hedgingCfg.RoundTripperWithRegisterer(transport, prometheus.WrapRegistererWithPrefix("loki_", prometheus.DefaultRegisterer))
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		cfg:          cfg,
+		provider:     provider,
+		client:       &http.Client{Transport: transport, Timeout: cfg.RequestTimeout},
+		hedgedClient: &http.Client{Transport: hedgedTransport, Timeout: cfg.RequestTimeout},
+	}, nil
+}
+
+// Stop fulfills the chunk.ObjectClient interface
+func (c *Client) Stop() {}
+
+// GetObject returns a reader and the size for the specified object key from the bucket.
+func (c *Client) GetObject(ctx context.Context, objectKey string) (io.ReadCloser, int64, error) {
+	resp, err := c.request(ctx, "GetObject", c.hedgedClient, http.MethodGet, objectKey, nil, nil, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	return resp.Body, resp.ContentLength, nil
+}
+
+// GetObjectRange returns a reader for length bytes from offset of the specified object key from the bucket.
+func (c *Client) GetObjectRange(ctx context.Context, objectKey string, offset, length int64) (io.ReadCloser, error) {
+	header := http.Header{}
+	header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	resp, err := c.request(ctx, "GetObjectRange", c.hedgedClient, http.MethodGet, objectKey, nil, header, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// PutObject puts the specified bytes into the bucket at the provided key.
+func (c *Client) PutObject(ctx context.Context, objectKey string, object io.ReadSeeker) error {
+	resp, err := c.request(ctx, "PutObject", c.client, http.MethodPut, objectKey, nil, nil, object)
+	if err != nil {
+		return err
+	}
+	return drain(resp)
+}
+
+// DeleteObject deletes the specified object key from the bucket.
+func (c *Client) DeleteObject(ctx context.Context, objectKey string) error {
+	resp, err := c.request(ctx, "DeleteObject", c.client, http.MethodDelete, objectKey, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	return drain(resp)
+}
+
+// List implements chunk.ObjectClient.
+func (c *Client) List(ctx context.Context, prefix, delimiter string) ([]chunk.StorageObject, []chunk.StorageCommonPrefix, error) {
+	var (
+		storageObjects []chunk.StorageObject
+		commonPrefixes []chunk.StorageCommonPrefix
+		marker         string
+	)
+	for {
+		resp, err := c.request(ctx, "List", c.client, http.MethodGet, "", c.provider.ListQuery(prefix, delimiter, marker), nil, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		page, err := c.provider.DecodeListPage(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to decode %s list response", c.cfg.Service)
+		}
+
+		storageObjects = append(storageObjects, page.Objects...)
+		commonPrefixes = append(commonPrefixes, page.CommonPrefixes...)
+		if page.NextMarker == "" {
+			return storageObjects, commonPrefixes, nil
+		}
+		marker = page.NextMarker
+	}
+}
+
+// Error is the error returned by the object store for a failed request.
+type Error struct {
+	Service    string
+	StatusCode int
+	Code       string
+	Message    string
+	RequestID  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: status %d, code %s: %s (request id %s)", strings.ToLower(e.Service), e.StatusCode, e.Code, e.Message, e.RequestID)
+}
+
+// IsObjectNotFoundErr returns true if error means that object is not found. Relevant to GetObject and DeleteObject operations.
+func (c *Client) IsObjectNotFoundErr(err error) bool {
+	var restErr *Error
+	return errors.As(err, &restErr) && (restErr.Code == ErrCodeNoSuchKey || restErr.StatusCode == http.StatusNotFound)
+}
+
+// request sends the request of the object, or of the bucket when the key is empty,
+// retrying it on the network and server errors.
+func (c *Client) request(ctx context.Context, operation string, client *http.Client, method, objectKey string, query url.Values, header http.Header, body io.ReadSeeker) (*http.Response, error) {
+	var (
+		resp    *http.Response
+		err     error
+		retries = backoff.New(ctx, c.cfg.BackoffConfig)
+	)
+	for retries.Ongoing() {
+		err = instrument.CollectedRequest(ctx, c.cfg.Service+"."+operation, c.cfg.RequestDuration, instrument.ErrorCode, func(ctx context.Context) error {
+			var requestErr error
+			resp, requestErr = c.do(ctx, client, method, objectKey, query, header, body)
+			return requestErr
+		})
+		if err == nil || !isRetryable(ctx, err) {
+			return resp, err
+		}
+		retries.Wait()
+	}
+	if err == nil {
+		err = retries.Err()
+	}
+	return nil, errors.Wrapf(err, "failed to %s %s object %s", method, c.cfg.Service, objectKey)
+}
+
+func (c *Client) do(ctx context.Context, client *http.Client, method, objectKey string, query url.Values, header http.Header, body io.ReadSeeker) (*http.Response, error) {
+	u := c.provider.URL(objectKey, query)
+
+	var (
+		reqBody io.Reader
+		size    int64
+	)
+	if body != nil {
+		var err error
+		if size, err = body.Seek(0, io.SeekEnd); err != nil {
+			return nil, err
+		}
+		if _, err := body.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		// the body is not closed by the client, the caller owns it.
+		reqBody = ioutil.NopCloser(body)
+		if size == 0 {
+			reqBody = http.NoBody
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), reqBody)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if body != nil {
+		req.ContentLength = size
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+	c.provider.Sign(req, objectKey, query)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		restErr := &Error{Service: c.cfg.Service, StatusCode: resp.StatusCode}
+		// the responses to HEAD requests and some proxies don't have a body.
+		_ = c.provider.DecodeError(resp.Body, restErr)
+		return nil, restErr
+	}
+	return resp, nil
+}
+
+// isRetryable tells whether the request failed on a network, throttling or server error.
+func isRetryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var restErr *Error
+	if errors.As(err, &restErr) {
+		return restErr.StatusCode == http.StatusTooManyRequests || restErr.StatusCode >= 500
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+func drain(resp *http.Response) error {
+	_, err := io.Copy(ioutil.Discard, resp.Body)
+	if closeErr := resp.Body.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// XMLAPI implements the listing and the errors of the object stores whose API is compatible with the XML API of S3.
+type XMLAPI struct{}
+
+// ListQuery implements Provider.
+func (XMLAPI) ListQuery(prefix, delimiter, marker string) url.Values {
+	query := url.Values{}
+	query.Set("prefix", prefix)
+	query.Set("delimiter", delimiter)
+	query.Set("marker", marker)
+	query.Set("max-keys", strconv.Itoa(MaxListKeys))
+	return query
+}
+
+type listBucketResult struct {
+	IsTruncated bool   `xml:"IsTruncated"`
+	NextMarker  string `xml:"NextMarker"`
+	Contents    []struct {
+		Key          string    `xml:"Key"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	CommonPrefixes []struct {
+		Prefix string `xml:"Prefix"`
+	} `xml:"CommonPrefixes"`
+}
+
+// DecodeListPage implements Provider.
+func (XMLAPI) DecodeListPage(r io.Reader) (ListPage, error) {
+	var result listBucketResult
+	if err := xml.NewDecoder(r).Decode(&result); err != nil {
+		return ListPage{}, err
+	}
+
+	var page ListPage
+	for _, content := range result.Contents {
+		page.Objects = append(page.Objects, chunk.StorageObject{
+			Key:        content.Key,
+			ModifiedAt: content.LastModified,
+		})
+	}
+	for _, commonPrefix := range result.CommonPrefixes {
+		page.CommonPrefixes = append(page.CommonPrefixes, chunk.StorageCommonPrefix(commonPrefix.Prefix))
+	}
+	if result.IsTruncated {
+		page.NextMarker = result.NextMarker
+	}
+	return page, nil
+}
+
+// DecodeError implements Provider.
+func (XMLAPI) DecodeError(r io.Reader, err *Error) error {
+	var xmlErr struct {
+		Code      string `xml:"Code"`
+		Message   string `xml:"Message"`
+		RequestID string `xml:"RequestId"`
+	}
+	if err := xml.NewDecoder(r).Decode(&xmlErr); err != nil {
+		return err
+	}
+	err.Code, err.Message, err.RequestID = xmlErr.Code, xmlErr.Message, xmlErr.RequestID
+	return nil
+}
@@ -0,0 +1,231 @@
+// Package restclienttest implements a fake bucket of an object store to test the object clients
+// built with restclient.
+package restclienttest
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/pkg/storage/chunk"
+	"github.com/grafana/loki/pkg/storage/chunk/restclient"
+)
+
+// Bucket serves the objects of a bucket over the REST API of an object store, checking the signatures.
+type Bucket struct {
+	T      *testing.T
+	Name   string
+	Secret string
+
+	// ValidSignature tells whether the request is signed with the secret.
+	ValidSignature func(r *http.Request, secret string) bool
+	// WriteError writes the response of a failed request, restclient.XMLAPI errors by default.
+	WriteError func(w http.ResponseWriter, status int, code string)
+	// WriteListPage writes the response of a list request, restclient.XMLAPI pages by default.
+	WriteListPage func(w http.ResponseWriter, page restclient.ListPage)
+
+	mtx      sync.Mutex
+	Objects  map[string][]byte
+	Failures int
+}
+
+// NewTransport starts a server of the bucket, and returns a transport to which all the virtual hosts of the buckets resolve.
+func (b *Bucket) NewTransport() http.RoundTripper {
+	if b.Objects == nil {
+		b.Objects = map[string][]byte{}
+	}
+	if b.WriteError == nil {
+		b.WriteError = writeXMLError
+	}
+	if b.WriteListPage == nil {
+		b.WriteListPage = b.writeXMLListPage
+	}
+	server := httptest.NewServer(b)
+	b.T.Cleanup(server.Close)
+
+	return &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+		},
+	}
+}
+
+func (b *Bucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	require.True(b.T, strings.HasPrefix(r.Host, b.Name+"."), r.Host)
+	if !b.ValidSignature(r, b.Secret) {
+		b.WriteError(w, http.StatusForbidden, "SignatureDoesNotMatch")
+		return
+	}
+	if b.Failures > 0 {
+		b.Failures--
+		b.WriteError(w, http.StatusServiceUnavailable, "ServiceUnavailable")
+		return
+	}
+
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	switch {
+	case r.Method == http.MethodPut:
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(b.T, err)
+		b.Objects[key] = body
+	case r.Method == http.MethodGet && key == "":
+		b.list(w, r)
+	case r.Method == http.MethodGet:
+		body, ok := b.Objects[key]
+		if !ok {
+			b.WriteError(w, http.StatusNotFound, restclient.ErrCodeNoSuchKey)
+			return
+		}
+		http.ServeContent(w, r, key, time.Time{}, bytes.NewReader(body))
+	case r.Method == http.MethodDelete:
+		delete(b.Objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (b *Bucket) list(w http.ResponseWriter, r *http.Request) {
+	prefix, delimiter, marker := r.URL.Query().Get("prefix"), r.URL.Query().Get("delimiter"), r.URL.Query().Get("marker")
+	var keys []string
+	for key := range b.Objects {
+		if strings.HasPrefix(key, prefix) && key > marker {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	// a single object or common prefix per page, to test the pagination.
+	var page restclient.ListPage
+	for i := 0; i < len(keys); i++ {
+		key := keys[i]
+		if len(page.Objects)+len(page.CommonPrefixes) > 0 {
+			page.NextMarker = keys[i-1]
+			break
+		}
+		if j := strings.Index(key[len(prefix):], delimiter); delimiter != "" && j >= 0 {
+			commonPrefix := key[:len(prefix)+j+1]
+			page.CommonPrefixes = append(page.CommonPrefixes, chunk.StorageCommonPrefix(commonPrefix))
+			// the keys rolled up in the common prefix are skipped.
+			for i+1 < len(keys) && strings.HasPrefix(keys[i+1], commonPrefix) {
+				i++
+			}
+			continue
+		}
+		page.Objects = append(page.Objects, chunk.StorageObject{Key: key, ModifiedAt: time.Unix(0, 0).UTC()})
+	}
+	b.WriteListPage(w, page)
+}
+
+func writeXMLError(w http.ResponseWriter, status int, code string) {
+	w.WriteHeader(status)
+	_, _ = fmt.Fprintf(w, "<Error><Code>%s</Code><Message>failed</Message><RequestId>1</RequestId></Error>", code)
+}
+
+func (b *Bucket) writeXMLListPage(w http.ResponseWriter, page restclient.ListPage) {
+	type content struct {
+		Key          string    `xml:"Key"`
+		LastModified time.Time `xml:"LastModified"`
+	}
+	type commonPrefix struct {
+		Prefix string `xml:"Prefix"`
+	}
+	var result struct {
+		XMLName        xml.Name       `xml:"ListBucketResult"`
+		IsTruncated    bool           `xml:"IsTruncated"`
+		NextMarker     string         `xml:"NextMarker,omitempty"`
+		Contents       []content      `xml:"Contents"`
+		CommonPrefixes []commonPrefix `xml:"CommonPrefixes"`
+	}
+	result.IsTruncated, result.NextMarker = page.NextMarker != "", page.NextMarker
+	for _, o := range page.Objects {
+		result.Contents = append(result.Contents, content{Key: o.Key, LastModified: o.ModifiedAt})
+	}
+	for _, p := range page.CommonPrefixes {
+		result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{Prefix: string(p)})
+	}
+	require.NoError(b.T, xml.NewEncoder(w).Encode(result))
+}
+
+// ObjectClient is an object client able to read ranges of bytes of the objects.
+type ObjectClient interface {
+	chunk.ObjectClient
+	chunk.RangeObjectClient
+}
+
+// TestObjectClient tests the object client of the bucket, which must have no objects.
+func TestObjectClient(t *testing.T, client ObjectClient, bucket *Bucket) {
+	ctx := context.Background()
+
+	for _, key := range []string{"fake/1/a:b", "fake/1/c:d", "fake/2/e:f", "index/table"} {
+		require.NoError(t, client.PutObject(ctx, key, bytes.NewReader([]byte(key))))
+	}
+
+	rc, size, err := client.GetObject(ctx, "fake/1/a:b")
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(rc)
+	require.NoError(t, err)
+	require.NoError(t, rc.Close())
+	require.Equal(t, "fake/1/a:b", string(body))
+	require.Equal(t, int64(len(body)), size)
+
+	rc, err = client.GetObjectRange(ctx, "fake/1/a:b", 2, 3)
+	require.NoError(t, err)
+	body, err = ioutil.ReadAll(rc)
+	require.NoError(t, err)
+	require.NoError(t, rc.Close())
+	require.Equal(t, "ke/", string(body))
+
+	objects, prefixes, err := client.List(ctx, "fake/", "/")
+	require.NoError(t, err)
+	require.Empty(t, objects)
+	require.Equal(t, []chunk.StorageCommonPrefix{"fake/1/", "fake/2/"}, prefixes)
+
+	objects, _, err = client.List(ctx, "fake/1/", "")
+	require.NoError(t, err)
+	require.Len(t, objects, 2)
+	require.Equal(t, "fake/1/a:b", objects[0].Key)
+	require.Equal(t, "fake/1/c:d", objects[1].Key)
+	require.Equal(t, time.Unix(0, 0).UTC(), objects[0].ModifiedAt.UTC())
+
+	require.NoError(t, client.DeleteObject(ctx, "fake/1/a:b"))
+	_, _, err = client.GetObject(ctx, "fake/1/a:b")
+	require.True(t, client.IsObjectNotFoundErr(err))
+}
+
+// TestRetries tests the retries of the object client of the bucket, which must make 3 to 9 attempts of a request.
+func TestRetries(t *testing.T, client ObjectClient, bucket *Bucket) {
+	ctx := context.Background()
+
+	// the body is sent again on retries.
+	bucket.Failures = 2
+	require.NoError(t, client.PutObject(ctx, "key", bytes.NewReader([]byte("value"))))
+	require.Equal(t, "value", string(bucket.Objects["key"]))
+
+	bucket.Failures = 10
+	_, _, err := client.GetObject(ctx, "key")
+	require.Error(t, err)
+	require.False(t, client.IsObjectNotFoundErr(err))
+
+	// the errors of the client aren't retried.
+	bucket.Failures = 0
+	secret := bucket.Secret
+	bucket.Secret = "wrong"
+	defer func() { bucket.Secret = secret }()
+	_, _, err = client.GetObject(ctx, "key")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "SignatureDoesNotMatch")
+}
@@ -5,8 +5,11 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"reflect"
+	"sort"
 	"strconv"
 	"strings"
+	"text/template"
 	"time"
 
 	"github.com/go-kit/log/level"
@@ -19,17 +22,42 @@ import (
 )
 
 const (
-	secondsInDay      = int64(24 * time.Hour / time.Second)
-	millisecondsInDay = int64(24 * time.Hour / time.Millisecond)
-	v12               = "v12"
+	secondsInHour      = int64(time.Hour / time.Second)
+	secondsInDay       = int64(24 * time.Hour / time.Second)
+	millisecondsInHour = int64(time.Hour / time.Millisecond)
+	millisecondsInDay  = int64(24 * time.Hour / time.Millisecond)
+	v12                = "v12"
+	v13                = "v13"
+	v14                = "v14"
+
+	// minHourlyBucketsSchema is the oldest schema version supporting hourly index buckets.
+	minHourlyBucketsSchema = 11
+
+	// ChunkFormatParquet stores the chunks as Parquet files, readable by analytics tools.
+	ChunkFormatParquet = "parquet"
 )
 
 var (
-	errInvalidSchemaVersion     = errors.New("invalid schema version")
-	errInvalidTablePeriod       = errors.New("the table period must be a multiple of 24h (1h for schema v1)")
-	errConfigFileNotSet         = errors.New("schema config file needs to be set")
-	errConfigChunkPrefixNotSet  = errors.New("schema config for chunks is missing the 'prefix' setting")
-	errSchemaIncreasingFromTime = errors.New("from time in schemas must be distinct and in increasing order")
+	errInvalidSchemaVersion       = errors.New("invalid schema version")
+	errInvalidTablePeriod         = errors.New("the table period must be a multiple of the bucket period (24h by default)")
+	errInvalidBucketPeriod        = errors.New("the bucket period must be either 1h or 24h")
+	errHourlyBucketsSchema        = fmt.Errorf("hourly index buckets require schema v%d or newer", minHourlyBucketsSchema)
+	errConfigFileNotSet           = errors.New("schema config file needs to be set")
+	errConfigChunkPrefixNotSet    = errors.New("schema config for chunks is missing the 'prefix' setting")
+	errSchemaIncreasingFromTime   = errors.New("from time in schemas must be distinct and in increasing order")
+	errNoTenantPeriodConfig       = errors.New("at least one period config is required")
+	errTenantSharedIndexPrefix    = errors.New("a tenant period config sharing its index prefix with an overlapping period config must have the same store, schema, index period, bucket period, row shards and retention period, since they are used to manage the shared tables")
+	errTableNameFormatStore       = errors.New("table name formats aren't supported by the boltdb-shipper and tsdb stores")
+	errTSDBObjectStoreNotSet      = errors.New("the tsdb store requires the object_store setting")
+	errFromNotAligned             = errors.New("the from time must be aligned with the index and chunk table periods")
+	errPeriodConfigRemoved        = errors.New("period configs can't be removed at runtime")
+	errPeriodConfigChanged        = errors.New("period configs already loaded can't be changed at runtime")
+	errNewPeriodConfigNotInFuture = errors.New("period configs added at runtime must start in the future")
+	errInvalidRetentionPeriod     = errors.New("the retention period of a period config must be a multiple of its index table period")
+	errVersionedKeysStore         = errors.New("schema v14 isn't supported by the tsdb store, which doesn't index the encoding of the chunks")
+	errInvalidAutoScalingCapacity = errors.New("the autoscaling min_capacity of a table config can't be greater than its max_capacity")
+	errNegativeAutoScalingConfig  = errors.New("the autoscaling capacities and cooldowns of a table config can't be negative")
+	errInvalidChunkFormat         = fmt.Errorf("invalid chunk format, it must be either empty for the default format or %s", ChunkFormatParquet)
 )
 
 // PeriodConfig defines the schema and tables to use for a period of time
@@ -41,6 +69,15 @@ type PeriodConfig struct {
 	IndexTables PeriodicTableConfig `yaml:"index"`
 	ChunkTables PeriodicTableConfig `yaml:"chunks"`
 	RowShards   uint32              `yaml:"row_shards"`
+	// Period of the index buckets, each bucket having its own hash key. Defaults to 24h.
+	BucketPeriod time.Duration `yaml:"bucket_period,omitempty"`
+	// Retention of the data of this period, overriding the global retention when set.
+	RetentionPeriod model.Duration `yaml:"retention_period,omitempty"`
+	// Format of the chunks flushed during this period, the default Loki format when empty.
+	ChunkFormat string `yaml:"chunk_format,omitempty"`
+	// Middlewares wrapping the index client of this period, the first one being the outermost.
+	// The default middlewares when nil.
+	IndexClientMiddlewares []string `yaml:"index_client_middlewares,omitempty"`
 
 	// Integer representation of schema used for hot path calculation. Populated on unmarshaling.
 	schemaInt *int `yaml:"-"`
@@ -59,8 +96,24 @@ func (cfg *PeriodConfig) UnmarshalYAML(unmarshal func(interface{}) error) error
 	return err
 }
 
+// Retention returns the retention period of the data of the period, which is the
+// global one unless the period overrides it.
+func (cfg PeriodConfig) Retention(global time.Duration) time.Duration {
+	if cfg.RetentionPeriod > 0 {
+		return time.Duration(cfg.RetentionPeriod)
+	}
+	return global
+}
+
+// equal tells whether both period configs are the same, ignoring the fields populated on unmarshaling.
+func (cfg PeriodConfig) equal(other PeriodConfig) bool {
+	cfg.schemaInt, other.schemaInt = nil, nil
+	return reflect.DeepEqual(cfg, other)
+}
+
 // DayTime is a model.Time what holds day-aligned values, and marshals to/from
-// YAML in YYYY-MM-DD format.
+// YAML in YYYY-MM-DD format. Values which aren't day-aligned are marshalled as
+// RFC3339 timestamps, which are accepted when unmarshalling as well.
 type DayTime struct {
 	model.Time
 }
@@ -78,13 +131,19 @@ func (d *DayTime) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	}
 	t, err := time.Parse("2006-01-02", from)
 	if err != nil {
-		return err
+		var rfcErr error
+		if t, rfcErr = time.Parse(time.RFC3339, from); rfcErr != nil {
+			return fmt.Errorf("%q is neither in YYYY-MM-DD nor in RFC3339 format", from)
+		}
 	}
 	d.Time = model.TimeFromUnix(t.Unix())
 	return nil
 }
 
 func (d *DayTime) String() string {
+	if d.Time.Unix()%secondsInDay != 0 {
+		return d.Time.Time().UTC().Format(time.RFC3339)
+	}
 	return d.Time.Time().UTC().Format("2006-01-02")
 }
 
@@ -92,9 +151,20 @@ func (d *DayTime) String() string {
 type SchemaConfig struct {
 	Configs []PeriodConfig `yaml:"configs"`
 
+	// TenantConfigs replace the schema config of some tenants, keyed by tenant ID.
+	TenantConfigs map[string]*SchemaConfig `yaml:"-"`
+
 	fileName string
 }
 
+// ForTenant returns the schema config to use for the given tenant.
+func (cfg SchemaConfig) ForTenant(userID string) SchemaConfig {
+	if tenantCfg := cfg.TenantConfigs[userID]; tenantCfg != nil {
+		return *tenantCfg
+	}
+	return cfg
+}
+
 // RegisterFlags adds the flags required to config this to the given FlagSet.
 func (cfg *SchemaConfig) RegisterFlags(f *flag.FlagSet) {
 	f.StringVar(&cfg.fileName, "schema-config-file", "", "The path to the schema config file. The schema config is used only when running Cortex with the chunks storage.")
@@ -110,29 +180,157 @@ func (cfg *SchemaConfig) loadFromFile() error {
 	if err != nil {
 		return err
 	}
+	defer f.Close()
 
 	decoder := yaml.NewDecoder(f)
 	decoder.SetStrict(true)
 	return decoder.Decode(&cfg)
 }
 
+// LoadSchemaConfigFile loads a schema config from a yaml file.
+func LoadSchemaConfigFile(path string) (SchemaConfig, error) {
+	cfg := SchemaConfig{fileName: path}
+	if err := cfg.loadFromFile(); err != nil {
+		return SchemaConfig{}, err
+	}
+	return cfg, nil
+}
+
+// NewPeriods returns the periods appended to cfg by next, which is a reloaded version of cfg.
+// The periods of cfg can't be changed or removed since they are in use, and the new periods must
+// start after now so that they aren't used before they are picked up.
+func (cfg SchemaConfig) NewPeriods(next SchemaConfig, now model.Time) ([]PeriodConfig, error) {
+	if len(next.Configs) < len(cfg.Configs) {
+		return nil, errPeriodConfigRemoved
+	}
+	for i := range cfg.Configs {
+		if !cfg.Configs[i].equal(next.Configs[i]) {
+			return nil, fmt.Errorf("the period config starting at %s was changed: %w", cfg.Configs[i].From.String(), errPeriodConfigChanged)
+		}
+	}
+
+	added := next.Configs[len(cfg.Configs):]
+	for _, p := range added {
+		if p.From.Time <= now {
+			return nil, fmt.Errorf("the new period config starting at %s: %w", p.From.String(), errNewPeriodConfigNotInFuture)
+		}
+	}
+	return added, nil
+}
+
 // Validate the schema config and returns an error if the validation
-// doesn't pass
+// doesn't pass. The error is a *SchemaConfigError listing all the invalid settings.
 func (cfg *SchemaConfig) Validate() error {
+	errs := cfg.validate()
+	for userID, tenantCfg := range cfg.TenantConfigs {
+		if tenantCfg == nil {
+			continue
+		}
+		if len(tenantCfg.Configs) == 0 {
+			errs = append(errs, &PeriodConfigError{Tenant: userID, Index: -1, Field: "configs", Err: errNoTenantPeriodConfig})
+			continue
+		}
+		for _, err := range tenantCfg.validate() {
+			err.Tenant = userID
+			errs = append(errs, err)
+		}
+	}
+	// the periods are compared once the defaults of all of them are applied.
+	for userID, tenantCfg := range cfg.TenantConfigs {
+		if tenantCfg == nil {
+			continue
+		}
+		for _, err := range cfg.validateTenantPeriods(userID, tenantCfg) {
+			err.Tenant = userID
+			errs = append(errs, err)
+		}
+	}
+	return NewSchemaConfigError(errs)
+}
+
+// validateTenantPeriods checks that the periods of the tenant can be managed along with the
+// overlapping periods of the global schema config and of the other tenants.
+func (cfg *SchemaConfig) validateTenantPeriods(userID string, tenantCfg *SchemaConfig) []*PeriodConfigError {
+	var errs []*PeriodConfigError
+	for i, p := range tenantCfg.Configs {
+		from, through := periodInterval(tenantCfg.Configs, i)
+		var prefixShared bool
+		checkOverlapping := func(configs []PeriodConfig) {
+			for j, o := range configs {
+				if oFrom, oThrough := periodInterval(configs, j); oFrom >= through || from >= oThrough {
+					continue
+				}
+				if o.IndexTables.Prefix == p.IndexTables.Prefix && !p.sameIndexTables(o) {
+					prefixShared = true
+				}
+			}
+		}
+		checkOverlapping(cfg.Configs)
+		for otherID, otherCfg := range cfg.TenantConfigs {
+			if otherID != userID && otherCfg != nil {
+				checkOverlapping(otherCfg.Configs)
+			}
+		}
+
+		if prefixShared {
+			errs = append(errs, NewPeriodConfigError(i, p, "index.prefix", errTenantSharedIndexPrefix))
+		}
+	}
+	return errs
+}
+
+// periodInterval returns the time range [from, through) of the i-th period of the configs.
+func periodInterval(configs []PeriodConfig, i int) (model.Time, model.Time) {
+	if i+1 < len(configs) {
+		return configs[i].From.Time, configs[i+1].From.Time
+	}
+	return configs[i].From.Time, model.Latest
+}
+
+// sameIndexTables tells whether both period configs read and manage their index tables the same way.
+func (cfg PeriodConfig) sameIndexTables(other PeriodConfig) bool {
+	return cfg.IndexType == other.IndexType &&
+		cfg.Schema == other.Schema &&
+		cfg.IndexTables.Period == other.IndexTables.Period &&
+		cfg.BucketPeriod == other.BucketPeriod &&
+		cfg.RowShards == other.RowShards &&
+		cfg.RetentionPeriod == other.RetentionPeriod
+}
+
+// AllConfigs returns the period configs of the global schema config followed by the ones of
+// each tenant, ordered by tenant ID.
+func (cfg SchemaConfig) AllConfigs() [][]PeriodConfig {
+	userIDs := make([]string, 0, len(cfg.TenantConfigs))
+	for userID, tenantCfg := range cfg.TenantConfigs {
+		if tenantCfg != nil {
+			userIDs = append(userIDs, userID)
+		}
+	}
+	sort.Strings(userIDs)
+
+	all := make([][]PeriodConfig, 0, len(userIDs)+1)
+	all = append(all, cfg.Configs)
+	for _, userID := range userIDs {
+		all = append(all, cfg.TenantConfigs[userID].Configs)
+	}
+	return all
+}
+
+func (cfg *SchemaConfig) validate() []*PeriodConfigError {
+	var errs []*PeriodConfigError
 	for i := range cfg.Configs {
 		periodCfg := &cfg.Configs[i]
 		periodCfg.applyDefaults()
-		if err := periodCfg.validate(); err != nil {
-			return err
+		for _, err := range periodCfg.validate() {
+			err.Index = i
+			errs = append(errs, err)
 		}
 
-		if i+1 < len(cfg.Configs) {
-			if cfg.Configs[i].From.Time.Unix() >= cfg.Configs[i+1].From.Time.Unix() {
-				return errSchemaIncreasingFromTime
-			}
+		if i > 0 && cfg.Configs[i-1].From.Time.Unix() >= periodCfg.From.Time.Unix() {
+			errs = append(errs, NewPeriodConfigError(i, *periodCfg, "from", errSchemaIncreasingFromTime))
 		}
 	}
-	return nil
+	return errs
 }
 
 func defaultRowShards(schema string) uint32 {
@@ -160,7 +358,12 @@ func (cfg *SchemaConfig) ForEachAfter(t model.Time, f func(config *PeriodConfig)
 	}
 }
 
-func validateChunks(cfg PeriodConfig) error {
+func validateChunks(cfg PeriodConfig) (field string, err error) {
+	// the tsdb index type doesn't store chunks.
+	if cfg.IndexType == "tsdb" && cfg.ObjectType == "" {
+		return "object_store", errTSDBObjectStoreNotSet
+	}
+
 	objectStore := cfg.IndexType
 	if cfg.ObjectType != "" {
 		objectStore = cfg.ObjectType
@@ -168,17 +371,24 @@ func validateChunks(cfg PeriodConfig) error {
 	switch objectStore {
 	case "cassandra", "aws-dynamo", "bigtable-hashed", "gcp", "gcp-columnkey", "bigtable", "grpc-store":
 		if cfg.ChunkTables.Prefix == "" {
-			return errConfigChunkPrefixNotSet
+			return "chunks.prefix", errConfigChunkPrefixNotSet
 		}
-		return nil
+		return "", nil
 	default:
-		return nil
+		return "", nil
 	}
 }
 
 // CreateSchema returns the schema defined by the PeriodConfig
 func (cfg PeriodConfig) CreateSchema() (BaseSchema, error) {
-	buckets, bucketsPeriod := cfg.dailyBuckets, 24*time.Hour
+	bucketsPeriod, err := cfg.bucketsPeriod()
+	if err != nil {
+		return nil, err
+	}
+	buckets := cfg.dailyBuckets
+	if bucketsPeriod == time.Hour {
+		buckets = cfg.hourlyBuckets
+	}
 
 	// Ensure the tables period is a multiple of the bucket period
 	if cfg.IndexTables.Period > 0 && cfg.IndexTables.Period%bucketsPeriod != 0 {
@@ -192,9 +402,9 @@ func (cfg PeriodConfig) CreateSchema() (BaseSchema, error) {
 	switch cfg.Schema {
 	case "v9":
 		return newSeriesStoreSchema(buckets, v9Entries{}), nil
-	case "v10", "v11", v12:
+	case "v10", "v11", v12, v13, v14:
 		if cfg.RowShards == 0 {
-			return nil, fmt.Errorf("must have row_shards > 0 (current: %d) for schema (%s)", cfg.RowShards, cfg.Schema)
+			return nil, cfg.rowShardsError()
 		}
 
 		v10 := v10Entries{rowShards: cfg.RowShards}
@@ -202,7 +412,7 @@ func (cfg PeriodConfig) CreateSchema() (BaseSchema, error) {
 			return newSeriesStoreSchema(buckets, v10), nil
 		} else if cfg.Schema == "v11" {
 			return newSeriesStoreSchema(buckets, v11Entries{v10}), nil
-		} else { // v12
+		} else { // v12+, v13 and v14 only change the chunk keys
 			return newSeriesStoreSchema(buckets, v12Entries{v11Entries{v10}}), nil
 		}
 	default:
@@ -210,21 +420,121 @@ func (cfg PeriodConfig) CreateSchema() (BaseSchema, error) {
 	}
 }
 
+// bucketsPeriod returns the period of the index buckets of the period config.
+func (cfg PeriodConfig) bucketsPeriod() (time.Duration, error) {
+	switch cfg.BucketPeriod {
+	case 0, 24 * time.Hour:
+		return 24 * time.Hour, nil
+	case time.Hour:
+		if v, err := cfg.VersionAsInt(); err != nil || v < minHourlyBucketsSchema {
+			return 0, errHourlyBucketsSchema
+		}
+		return time.Hour, nil
+	default:
+		return 0, errInvalidBucketPeriod
+	}
+}
+
+func (cfg PeriodConfig) rowShardsError() error {
+	return fmt.Errorf("must have row_shards > 0 (current: %d) for schema (%s)", cfg.RowShards, cfg.Schema)
+}
+
 func (cfg *PeriodConfig) applyDefaults() {
 	if cfg.RowShards == 0 {
 		cfg.RowShards = defaultRowShards(cfg.Schema)
 	}
 }
 
-// Validate the period config.
-func (cfg PeriodConfig) validate() error {
-	validateError := validateChunks(cfg)
-	if validateError != nil {
-		return validateError
+// Validate the period config, returning the errors of all its invalid settings.
+func (cfg PeriodConfig) validate() []*PeriodConfigError {
+	var errs []*PeriodConfigError
+	add := func(field string, err error) {
+		errs = append(errs, NewPeriodConfigError(0, cfg, field, err))
 	}
 
-	_, err := cfg.CreateSchema()
-	return err
+	if field, err := validateChunks(cfg); err != nil {
+		add(field, err)
+	}
+
+	// a period starting in the middle of a table would share it with the previous period.
+	// Periods starting on a day, which was the only format supported at first, are not checked
+	// to keep supporting them along with weekly tables.
+	if cfg.From.Unix()%int64(24*time.Hour/time.Second) != 0 {
+		for _, period := range []time.Duration{cfg.IndexTables.Period, cfg.ChunkTables.Period} {
+			if period > 0 && cfg.From.Unix()%int64(period/time.Second) != 0 {
+				add("from", errFromNotAligned)
+				break
+			}
+		}
+	}
+
+	if cfg.RetentionPeriod > 0 && cfg.IndexTables.Period > 0 && time.Duration(cfg.RetentionPeriod)%cfg.IndexTables.Period != 0 {
+		add("retention_period", errInvalidRetentionPeriod)
+	}
+
+	if cfg.ChunkFormat != "" && cfg.ChunkFormat != ChunkFormatParquet {
+		add("chunk_format", errInvalidChunkFormat)
+	}
+
+	// the keys of the chunks are built from the chunk refs of the tsdb index, which only hold their checksum.
+	if cfg.IndexType == "tsdb" && cfg.Schema == v14 {
+		add("schema", errVersionedKeysStore)
+	}
+
+	cfg.validateTableNameFormats(add)
+
+	if err := cfg.IndexTables.AutoScaling.validate(); err != nil {
+		add("index.autoscaling", err)
+	}
+	if err := cfg.ChunkTables.AutoScaling.validate(); err != nil {
+		add("chunks.autoscaling", err)
+	}
+
+	// Ensure the tables period is a multiple of the bucket period
+	if bucketsPeriod, err := cfg.bucketsPeriod(); err != nil {
+		add("bucket_period", err)
+	} else {
+		if cfg.IndexTables.Period > 0 && cfg.IndexTables.Period%bucketsPeriod != 0 {
+			add("index.period", errInvalidTablePeriod)
+		}
+		if cfg.ChunkTables.Period > 0 && cfg.ChunkTables.Period%bucketsPeriod != 0 {
+			add("chunks.period", errInvalidTablePeriod)
+		}
+	}
+
+	switch cfg.Schema {
+	case "v9":
+	case "v10", "v11", v12, v13, v14:
+		if cfg.RowShards == 0 {
+			add("row_shards", cfg.rowShardsError())
+		}
+	default:
+		add("schema", errInvalidSchemaVersion)
+	}
+	return errs
+}
+
+func (cfg PeriodConfig) validateTableNameFormats(add func(field string, err error)) {
+	// These stores parse the period number out of the table names.
+	storeParsesNames := cfg.IndexType == "boltdb-shipper" || cfg.IndexType == "tsdb"
+	for _, table := range []struct {
+		field string
+		cfg   PeriodicTableConfig
+	}{
+		{field: "index.name_format", cfg: cfg.IndexTables},
+		{field: "chunks.name_format", cfg: cfg.ChunkTables},
+	} {
+		if table.cfg.NameFormat == "" {
+			continue
+		}
+		if storeParsesNames {
+			add(table.field, errTableNameFormatStore)
+			continue
+		}
+		if err := table.cfg.validateNameFormat(cfg.From.Time); err != nil {
+			add(table.field, err)
+		}
+	}
 }
 
 // Load the yaml file, or build the config from legacy command-line flags
@@ -281,6 +591,29 @@ func (cfg *PeriodConfig) dailyBuckets(from, through model.Time, userID string) [
 	return result
 }
 
+// hourlyBuckets works like dailyBuckets with buckets of an hour, which keeps
+// rows smaller for tenants with a lot of series.
+func (cfg *PeriodConfig) hourlyBuckets(from, through model.Time, userID string) []Bucket {
+	var (
+		fromHour    = from.Unix() / secondsInHour
+		throughHour = through.Unix() / secondsInHour
+		result      = []Bucket{}
+	)
+
+	for i := fromHour; i <= throughHour; i++ {
+		relativeFrom := math.Max64(0, int64(from)-(i*millisecondsInHour))
+		relativeThrough := math.Min64(millisecondsInHour, int64(through)-(i*millisecondsInHour))
+		result = append(result, Bucket{
+			from:       uint32(relativeFrom),
+			through:    uint32(relativeThrough),
+			tableName:  cfg.IndexTables.TableFor(model.TimeFromUnix(i * secondsInHour)),
+			hashKey:    fmt.Sprintf("%s:h%d", userID, i),
+			bucketSize: uint32(millisecondsInHour),
+		})
+	}
+	return result
+}
+
 func (cfg *PeriodConfig) VersionAsInt() (int, error) {
 	// Read memoized schema version. This is called during unmarshaling,
 	// but may be nil in the case of testware.
@@ -299,14 +632,40 @@ type PeriodicTableConfig struct {
 	Prefix string
 	Period time.Duration
 	Tags   Tags
+	// NameFormat is an optional Go template of the table names, see TableNameData
+	// for the available fields. Tables are named Prefix followed by the period
+	// number when empty.
+	NameFormat string
+	// AutoScaling overrides the autoscaling settings of the provision config
+	// of the table manager for these tables.
+	AutoScaling TableAutoScalingConfig
+
+	// Parsed NameFormat, populated on unmarshaling.
+	nameTemplate *template.Template
+}
+
+// TableNameData holds the fields available to the templates of the table names.
+// The dates are the ones of the start of the table period, in UTC.
+type TableNameData struct {
+	Prefix string
+	// Period is the period number, the number suffixing table names by default.
+	Period   int64
+	Year     int
+	Month    int
+	Day      int
+	Hour     int
+	Week     int // ISO 8601 week number.
+	WeekYear int // ISO 8601 year of the week.
 }
 
 // UnmarshalYAML implements the yaml.Unmarshaler interface.
 func (cfg *PeriodicTableConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	g := struct {
-		Prefix string         `yaml:"prefix"`
-		Period model.Duration `yaml:"period"`
-		Tags   Tags           `yaml:"tags"`
+		Prefix      string                 `yaml:"prefix"`
+		Period      model.Duration         `yaml:"period"`
+		Tags        Tags                   `yaml:"tags"`
+		NameFormat  string                 `yaml:"name_format"`
+		AutoScaling TableAutoScalingConfig `yaml:"autoscaling"`
 	}{}
 	if err := unmarshal(&g); err != nil {
 		return err
@@ -315,25 +674,100 @@ func (cfg *PeriodicTableConfig) UnmarshalYAML(unmarshal func(interface{}) error)
 	cfg.Prefix = g.Prefix
 	cfg.Period = time.Duration(g.Period)
 	cfg.Tags = g.Tags
+	cfg.NameFormat = g.NameFormat
+	cfg.AutoScaling = g.AutoScaling
+	cfg.nameTemplate = nil
 
+	if cfg.NameFormat != "" {
+		tmpl, err := parseTableNameFormat(cfg.NameFormat)
+		if err != nil {
+			return err
+		}
+		cfg.nameTemplate = tmpl
+	}
 	return nil
 }
 
 // MarshalYAML implements the yaml.Marshaler interface.
 func (cfg PeriodicTableConfig) MarshalYAML() (interface{}, error) {
 	g := &struct {
-		Prefix string         `yaml:"prefix"`
-		Period model.Duration `yaml:"period"`
-		Tags   Tags           `yaml:"tags"`
+		Prefix      string                  `yaml:"prefix"`
+		Period      model.Duration          `yaml:"period"`
+		Tags        Tags                    `yaml:"tags"`
+		NameFormat  string                  `yaml:"name_format,omitempty"`
+		AutoScaling *TableAutoScalingConfig `yaml:"autoscaling,omitempty"`
 	}{
-		Prefix: cfg.Prefix,
-		Period: model.Duration(cfg.Period),
-		Tags:   cfg.Tags,
+		Prefix:     cfg.Prefix,
+		Period:     model.Duration(cfg.Period),
+		Tags:       cfg.Tags,
+		NameFormat: cfg.NameFormat,
+	}
+	if cfg.AutoScaling != (TableAutoScalingConfig{}) {
+		g.AutoScaling = &cfg.AutoScaling
 	}
 
 	return g, nil
 }
 
+func parseTableNameFormat(format string) (*template.Template, error) {
+	tmpl, err := template.New("table_name").Option("missingkey=error").Parse(format)
+	if err != nil {
+		return nil, fmt.Errorf("invalid table name format %q: %w", format, err)
+	}
+	return tmpl, nil
+}
+
+// validateNameFormat checks that the name format renders table names starting
+// with the prefix, which the table manager relies on to delete tables, and
+// distinct for consecutive periods.
+func (cfg *PeriodicTableConfig) validateNameFormat(from model.Time) error {
+	if cfg.NameFormat == "" {
+		return nil
+	}
+	if cfg.Period == 0 {
+		return errors.New("a name format requires a table period")
+	}
+	tmpl, err := parseTableNameFormat(cfg.NameFormat)
+	if err != nil {
+		return err
+	}
+
+	first := from.Unix() / int64(cfg.Period/time.Second)
+	var previous string
+	for i := first; i < first+2; i++ {
+		name, err := cfg.renderTableName(tmpl, i)
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(name, cfg.Prefix) {
+			return fmt.Errorf("table name %q doesn't start with the prefix %q", name, cfg.Prefix)
+		}
+		if name == previous {
+			return fmt.Errorf("consecutive periods have the same table name %q", name)
+		}
+		previous = name
+	}
+	return nil
+}
+
+func (cfg *PeriodicTableConfig) renderTableName(tmpl *template.Template, i int64) (string, error) {
+	start := time.Unix(i*int64(cfg.Period/time.Second), 0).UTC()
+	weekYear, week := start.ISOWeek()
+
+	var sb strings.Builder
+	err := tmpl.Execute(&sb, TableNameData{
+		Prefix:   cfg.Prefix,
+		Period:   i,
+		Year:     start.Year(),
+		Month:    int(start.Month()),
+		Day:      start.Day(),
+		Hour:     start.Hour(),
+		Week:     week,
+		WeekYear: weekYear,
+	})
+	return sb.String(), err
+}
+
 // AutoScalingConfig for DynamoDB tables.
 type AutoScalingConfig struct {
 	Enabled     bool    `yaml:"enabled"`
@@ -345,6 +779,61 @@ type AutoScalingConfig struct {
 	TargetValue float64 `yaml:"target"`
 }
 
+// TableAutoScalingConfig overrides the autoscaling settings of the provision config
+// for the tables of a PeriodicTableConfig.
+type TableAutoScalingConfig struct {
+	Write AutoScalingOverrides `yaml:"write"`
+	Read  AutoScalingOverrides `yaml:"read"`
+}
+
+func (cfg TableAutoScalingConfig) validate() error {
+	if err := cfg.Write.validate(); err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	if err := cfg.Read.validate(); err != nil {
+		return fmt.Errorf("read: %w", err)
+	}
+	return nil
+}
+
+// AutoScalingOverrides are the capacity bounds and cooldowns of the autoscaling of a table
+// overriding the ones of its AutoScalingConfig, the zero values keep the ones of the AutoScalingConfig.
+type AutoScalingOverrides struct {
+	MinCapacity int64 `yaml:"min_capacity,omitempty"`
+	MaxCapacity int64 `yaml:"max_capacity,omitempty"`
+	OutCooldown int64 `yaml:"out_cooldown,omitempty"`
+	InCooldown  int64 `yaml:"in_cooldown,omitempty"`
+}
+
+func (o AutoScalingOverrides) validate() error {
+	if o.MinCapacity < 0 || o.MaxCapacity < 0 || o.OutCooldown < 0 || o.InCooldown < 0 {
+		return errNegativeAutoScalingConfig
+	}
+	if o.MinCapacity > 0 && o.MaxCapacity > 0 && o.MinCapacity > o.MaxCapacity {
+		return errInvalidAutoScalingCapacity
+	}
+	return nil
+}
+
+// apply overrides the settings of cfg, when its autoscaling is enabled.
+func (o AutoScalingOverrides) apply(cfg *AutoScalingConfig) {
+	if !cfg.Enabled {
+		return
+	}
+	if o.MinCapacity > 0 {
+		cfg.MinCapacity = o.MinCapacity
+	}
+	if o.MaxCapacity > 0 {
+		cfg.MaxCapacity = o.MaxCapacity
+	}
+	if o.OutCooldown > 0 {
+		cfg.OutCooldown = o.OutCooldown
+	}
+	if o.InCooldown > 0 {
+		cfg.InCooldown = o.InCooldown
+	}
+}
+
 // RegisterFlags adds the flags required to config this to the given FlagSet.
 func (cfg *AutoScalingConfig) RegisterFlags(argPrefix string, f *flag.FlagSet) {
 	f.BoolVar(&cfg.Enabled, argPrefix+".enabled", false, "Should we enable autoscale for the table.")
@@ -383,6 +872,8 @@ func (cfg *PeriodicTableConfig) periodicTables(from, through model.Time, pCfg Pr
 		// if now is within table [start - grace, end + grace), then we need some write throughput
 		if (i*periodSecs)-beginGraceSecs <= now && now < (i*periodSecs)+periodSecs+endGraceSecs {
 			table = pCfg.ActiveTableProvisionConfig.BuildTableDesc(tableName, cfg.Tags)
+			cfg.AutoScaling.Write.apply(&table.WriteScale)
+			cfg.AutoScaling.Read.apply(&table.ReadScale)
 
 			level.Debug(log.Logger).Log("msg", "Table is Active",
 				"tableName", table.Name,
@@ -398,6 +889,8 @@ func (cfg *PeriodicTableConfig) periodicTables(from, through model.Time, pCfg Pr
 			// the N last tables in that range will always be set to the inactive scaling settings.
 			disableAutoscale := i < (nowWeek - pCfg.InactiveWriteScaleLastN)
 			table = pCfg.InactiveTableProvisionConfig.BuildTableDesc(tableName, cfg.Tags, disableAutoscale)
+			cfg.AutoScaling.Write.apply(&table.WriteScale)
+			cfg.AutoScaling.Read.apply(&table.ReadScale)
 
 			level.Debug(log.Logger).Log("msg", "Table is Inactive",
 				"tableName", table.Name,
@@ -413,8 +906,9 @@ func (cfg *PeriodicTableConfig) periodicTables(from, through model.Time, pCfg Pr
 	return result
 }
 
-// ChunkTableFor calculates the chunk table shard for a given point in time.
-func (cfg SchemaConfig) ChunkTableFor(t model.Time) (string, error) {
+// ChunkTableFor calculates the chunk table shard of a tenant for a given point in time.
+func (cfg SchemaConfig) ChunkTableFor(userID string, t model.Time) (string, error) {
+	cfg = cfg.ForTenant(userID)
 	for i := range cfg.Configs {
 		if t >= cfg.Configs[i].From.Time && (i+1 == len(cfg.Configs) || t < cfg.Configs[i+1].From.Time) {
 			return cfg.Configs[i].ChunkTables.TableFor(t), nil
@@ -444,14 +938,39 @@ func (cfg *PeriodicTableConfig) TableFor(t model.Time) string {
 }
 
 func (cfg *PeriodicTableConfig) tableForPeriod(i int64) string {
-	return cfg.Prefix + strconv.Itoa(int(i))
+	if cfg.NameFormat == "" {
+		return cfg.Prefix + strconv.Itoa(int(i))
+	}
+
+	tmpl := cfg.nameTemplate
+	if tmpl == nil {
+		// The config wasn't unmarshaled, the format is validated with the schema config though.
+		tmpl = template.Must(parseTableNameFormat(cfg.NameFormat))
+	}
+	name, err := cfg.renderTableName(tmpl, i)
+	if err != nil {
+		// Validated with the schema config.
+		panic(err)
+	}
+	return name
 }
 
 // Generate the appropriate external key based on cfg.Schema, chunk.Checksum, and chunk.From
 func (cfg SchemaConfig) ExternalKey(chunk Chunk) string {
-	p, err := cfg.SchemaForTime(chunk.From)
+	if chunk.Contained() {
+		return cfg.externalKey(chunk) + chunk.Container.String()
+	}
+	return cfg.externalKey(chunk)
+}
+
+func (cfg SchemaConfig) externalKey(chunk Chunk) string {
+	p, err := cfg.ForTenant(chunk.UserID).SchemaForTime(chunk.From)
 	v, _ := p.VersionAsInt()
-	if err == nil && v >= 12 {
+	if err == nil && v >= 14 {
+		return cfg.versionedExternalKey(p, chunk)
+	} else if err == nil && v >= 13 {
+		return cfg.tenantPrefixedExternalKey(p, chunk)
+	} else if err == nil && v >= 12 {
 		return cfg.newerExternalKey(chunk)
 	} else if chunk.ChecksumSet {
 		return cfg.newExternalKey(chunk)
@@ -460,10 +979,23 @@ func (cfg SchemaConfig) ExternalKey(chunk Chunk) string {
 	}
 }
 
+// TenantPrefix returns the prefix of the keys of all the chunks of the given
+// user written with schema v13+, e.g. to configure per-tenant lifecycle rules
+// or delete all the chunks of a tenant. It is a directory named after the hash
+// of the user ID.
+func (cfg SchemaConfig) TenantPrefix(userID string) string {
+	return tenantPrefix(userID)
+}
+
+// ContainerKey returns the key of the object of the container of chunks of the given user.
+func (cfg SchemaConfig) ContainerKey(userID, containerID string) string {
+	return cfg.TenantPrefix(userID) + "containers/" + containerID
+}
+
 // VersionForChunk will return the schema version associated with the `From` timestamp of a chunk.
 // The schema and chunk must be valid+compatible as the errors are not checked.
 func (cfg SchemaConfig) VersionForChunk(c Chunk) int {
-	p, _ := cfg.SchemaForTime(c.From)
+	p, _ := cfg.ForTenant(c.UserID).SchemaForTime(c.From)
 	v, _ := p.VersionAsInt()
 	return v
 }
@@ -485,3 +1017,31 @@ func (cfg SchemaConfig) newExternalKey(chunk Chunk) string {
 func (cfg SchemaConfig) newerExternalKey(chunk Chunk) string {
 	return fmt.Sprintf("%s/%x/%x:%x:%x", chunk.UserID, chunk.Fingerprint, int64(chunk.From), int64(chunk.Through), chunk.Checksum)
 }
+
+// v13+
+func (cfg SchemaConfig) tenantPrefixedExternalKey(p PeriodConfig, chunk Chunk) string {
+	// This is the inverse of chunk.parseTenantPrefixedExternalKey.
+	return fmt.Sprintf("%s%x/%x:%x:%x", cfg.ChunkKeyPrefix(p, chunk.UserID, chunk.From), chunk.Fingerprint, int64(chunk.From), int64(chunk.Through), chunk.Checksum)
+}
+
+// ChunkKeyPrefix returns the prefix of the keys of the chunks of the given user
+// written with schema v13+ in the chunk key period of the given time.
+func (cfg SchemaConfig) ChunkKeyPrefix(p PeriodConfig, userID string, t model.Time) string {
+	return fmt.Sprintf("%s%d/", cfg.TenantPrefix(userID), p.chunkKeyPeriod(t))
+}
+
+// v14+
+func (cfg SchemaConfig) versionedExternalKey(p PeriodConfig, chunk Chunk) string {
+	// This is the inverse of chunk.parseTenantPrefixedExternalKey.
+	return fmt.Sprintf("%s:%x", cfg.tenantPrefixedExternalKey(p, chunk), byte(chunk.Encoding))
+}
+
+// chunkKeyPeriod returns the number of the period of the chunk tables the given
+// time belongs to, or of the day when the chunk tables aren't periodic.
+func (cfg PeriodConfig) chunkKeyPeriod(t model.Time) int64 {
+	period := cfg.ChunkTables.Period
+	if period == 0 {
+		period = 24 * time.Hour
+	}
+	return t.Unix() / int64(period/time.Second)
+}
@@ -0,0 +1,80 @@
+package chunk
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// PeriodConfigError is an invalid setting of a period config.
+type PeriodConfigError struct {
+	// Tenant is set for the errors of the schema config of a tenant.
+	Tenant string
+	// Index of the period config in the schema config, -1 for the errors not specific to a period config.
+	Index int
+	From  DayTime
+	// Field is the YAML path of the invalid setting in the period config, e.g. index.period.
+	Field string
+	Err   error
+}
+
+// NewPeriodConfigError returns the error of the field of the period config at the given index.
+func NewPeriodConfigError(index int, cfg PeriodConfig, field string, err error) *PeriodConfigError {
+	return &PeriodConfigError{Index: index, From: cfg.From, Field: field, Err: err}
+}
+
+func (e *PeriodConfigError) Error() string {
+	var sb strings.Builder
+	if e.Tenant != "" {
+		fmt.Fprintf(&sb, "tenant %s: ", e.Tenant)
+	}
+	if e.Index >= 0 {
+		fmt.Fprintf(&sb, "period config %d starting at %s: ", e.Index, e.From.String())
+	}
+	fmt.Fprintf(&sb, "%s: %v", e.Field, e.Err)
+	return sb.String()
+}
+
+func (e *PeriodConfigError) Unwrap() error {
+	return e.Err
+}
+
+// SchemaConfigError lists all the invalid settings of a schema config, so that they
+// can be fixed at once.
+type SchemaConfigError struct {
+	Errors []*PeriodConfigError
+}
+
+// NewSchemaConfigError returns a SchemaConfigError listing errs by tenant and period config,
+// or nil if there are none.
+func NewSchemaConfigError(errs []*PeriodConfigError) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	sort.SliceStable(errs, func(i, j int) bool {
+		if errs[i].Tenant != errs[j].Tenant {
+			return errs[i].Tenant < errs[j].Tenant
+		}
+		return errs[i].Index < errs[j].Index
+	})
+	return &SchemaConfigError{Errors: errs}
+}
+
+func (e *SchemaConfigError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Sprintf("invalid schema config: %s", strings.Join(msgs, "; "))
+}
+
+// Is tells whether any of the errors of the schema config is target.
+func (e *SchemaConfigError) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,13 +1,18 @@
 package chunk
 
 import (
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/prometheus/common/model"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/mtime"
 	yaml "gopkg.in/yaml.v2"
+
+	"github.com/grafana/loki/pkg/logproto"
 )
 
 func TestDailyBuckets(t *testing.T) {
@@ -112,6 +117,50 @@ func TestDailyBuckets(t *testing.T) {
 	}
 }
 
+func TestHourlyBuckets(t *testing.T) {
+	cfg := PeriodConfig{
+		IndexTables:  PeriodicTableConfig{Prefix: "table"},
+		BucketPeriod: time.Hour,
+	}
+
+	got := cfg.hourlyBuckets(model.TimeFromUnix(30*60), model.TimeFromUnix(2*3600+15*60), "0")
+	assert.Equal(t, []Bucket{{
+		from:       (30 * 60) * 1000, // ms
+		through:    3600 * 1000,      // ms
+		tableName:  "table",
+		hashKey:    "0:h0",
+		bucketSize: uint32(millisecondsInHour),
+	}, {
+		from:       0,
+		through:    3600 * 1000, // ms
+		tableName:  "table",
+		hashKey:    "0:h1",
+		bucketSize: uint32(millisecondsInHour),
+	}, {
+		from:       0,
+		through:    (15 * 60) * 1000, // ms
+		tableName:  "table",
+		hashKey:    "0:h2",
+		bucketSize: uint32(millisecondsInHour),
+	}}, got)
+
+	schema, err := cfg.CreateSchema()
+	require.Error(t, err)
+	require.Nil(t, schema)
+
+	cfg.Schema, cfg.RowShards = "v12", 16
+	schema, err = cfg.CreateSchema()
+	require.NoError(t, err)
+	entries, err := schema.GetReadQueriesForMetric(model.TimeFromUnix(0), model.TimeFromUnix(2*3600), "0", "logs")
+	require.NoError(t, err)
+	hashKeys := map[string]struct{}{}
+	for _, e := range entries {
+		hashKeys[e.HashValue[strings.Index(e.HashValue, ":")+1:]] = struct{}{}
+	}
+	require.Len(t, hashKeys, 3)
+	require.Contains(t, hashKeys, "0:h1:logs")
+}
+
 func TestChunkTableFor(t *testing.T) {
 	tablePeriod, err := time.ParseDuration("168h")
 	require.NoError(t, err)
@@ -194,13 +243,151 @@ func TestChunkTableFor(t *testing.T) {
 		ts, err := time.Parse(time.RFC3339, tc.timeStr)
 		require.NoError(t, err)
 
-		table, err := schemaCfg.ChunkTableFor(model.TimeFromUnix(ts.Unix()))
+		table, err := schemaCfg.ChunkTableFor("fake", model.TimeFromUnix(ts.Unix()))
 		require.NoError(t, err)
 
 		require.Equal(t, tc.chunkTable, table)
 	}
 }
 
+func TestSchemaConfig_ForTenant(t *testing.T) {
+	defaultCfg := PeriodConfig{
+		From:        MustParseDayTime("2020-01-01"),
+		IndexType:   "boltdb-shipper",
+		ObjectType:  "filesystem",
+		Schema:      "v11",
+		IndexTables: PeriodicTableConfig{Prefix: "index_", Period: 24 * time.Hour},
+		ChunkTables: PeriodicTableConfig{Prefix: "chunks_", Period: 24 * time.Hour},
+		RowShards:   16,
+	}
+	tenantCfg := defaultCfg
+	tenantCfg.Schema = "v12"
+	tenantCfg.IndexTables = PeriodicTableConfig{Prefix: "tenant_index_", Period: 24 * time.Hour}
+	tenantCfg.ChunkTables = PeriodicTableConfig{Prefix: "tenant_chunks_", Period: 24 * time.Hour}
+
+	schemaCfg := SchemaConfig{
+		Configs: []PeriodConfig{defaultCfg},
+		TenantConfigs: map[string]*SchemaConfig{
+			"overridden": {Configs: []PeriodConfig{tenantCfg}},
+			"nil":        nil,
+		},
+	}
+	require.NoError(t, schemaCfg.Validate())
+
+	require.Equal(t, []PeriodConfig{defaultCfg}, schemaCfg.ForTenant("default").Configs)
+	require.Equal(t, []PeriodConfig{defaultCfg}, schemaCfg.ForTenant("nil").Configs)
+	require.Equal(t, []PeriodConfig{tenantCfg}, schemaCfg.ForTenant("overridden").Configs)
+
+	ts := model.TimeFromUnix(time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC).Unix())
+	table, err := schemaCfg.ChunkTableFor("default", ts)
+	require.NoError(t, err)
+	require.Equal(t, "chunks_18263", table)
+	table, err = schemaCfg.ChunkTableFor("overridden", ts)
+	require.NoError(t, err)
+	require.Equal(t, "tenant_chunks_18263", table)
+
+	require.Equal(t, 11, schemaCfg.VersionForChunk(Chunk{ChunkRef: logproto.ChunkRef{UserID: "default", From: ts}}))
+	require.Equal(t, 12, schemaCfg.VersionForChunk(Chunk{ChunkRef: logproto.ChunkRef{UserID: "overridden", From: ts}}))
+
+	schemaCfg.TenantConfigs["empty"] = &SchemaConfig{}
+	require.ErrorIs(t, schemaCfg.Validate(), errNoTenantPeriodConfig)
+}
+
+func TestSchemaConfig_ValidateTenantPeriods(t *testing.T) {
+	global := []PeriodConfig{
+		{
+			From:        MustParseDayTime("2020-01-01"),
+			IndexType:   "boltdb-shipper",
+			ObjectType:  "filesystem",
+			Schema:      "v11",
+			IndexTables: PeriodicTableConfig{Prefix: "index_", Period: 24 * time.Hour},
+		},
+		{
+			From:        MustParseDayTime("2021-01-01"),
+			IndexType:   "tsdb",
+			ObjectType:  "filesystem",
+			Schema:      "v12",
+			IndexTables: PeriodicTableConfig{Prefix: "tsdb_index_", Period: 24 * time.Hour},
+		},
+	}
+	period := func(from, store, schema, prefix string) PeriodConfig {
+		return PeriodConfig{
+			From:        MustParseDayTime(from),
+			IndexType:   store,
+			ObjectType:  "s3",
+			Schema:      schema,
+			IndexTables: PeriodicTableConfig{Prefix: prefix, Period: 24 * time.Hour},
+		}
+	}
+	tsdb := period("2021-01-01", "tsdb", "v12", "tsdb_index_")
+
+	for name, tc := range map[string]struct {
+		tenants  map[string][]PeriodConfig
+		expected []error
+	}{
+		"own tables and object store": {
+			tenants: map[string][]PeriodConfig{
+				"a": {period("2020-06-01", "boltdb-shipper", "v11", "a_index_"), period("2021-01-01", "tsdb", "v12", "a_tsdb_index_")},
+			},
+		},
+		"shared tables": {
+			tenants: map[string][]PeriodConfig{
+				"a": {period("2020-06-01", "boltdb-shipper", "v11", "index_"), tsdb},
+			},
+		},
+		"other store": {
+			tenants: map[string][]PeriodConfig{
+				"a": {period("2020-06-01", "tsdb", "v12", "a_tsdb_index_")},
+			},
+		},
+		"shared tables in another store": {
+			tenants: map[string][]PeriodConfig{
+				"a": {period("2020-06-01", "tsdb", "v11", "index_")},
+			},
+			expected: []error{errTenantSharedIndexPrefix},
+		},
+		"shared tables with another schema": {
+			tenants: map[string][]PeriodConfig{
+				"a": {period("2020-06-01", "boltdb-shipper", "v12", "index_"), tsdb},
+			},
+			expected: []error{errTenantSharedIndexPrefix},
+		},
+		"tables shared with another tenant": {
+			tenants: map[string][]PeriodConfig{
+				"a": {period("2020-06-01", "boltdb-shipper", "v11", "ab_index_"), tsdb},
+				"b": {period("2020-06-01", "boltdb-shipper", "v12", "ab_index_"), tsdb},
+			},
+			expected: []error{errTenantSharedIndexPrefix, errTenantSharedIndexPrefix},
+		},
+		"tables of another tenant's past period": {
+			tenants: map[string][]PeriodConfig{
+				"a": {period("2020-06-01", "boltdb-shipper", "v11", "ab_index_"), period("2020-07-01", "boltdb-shipper", "v11", "a_index_"), tsdb},
+				"b": {period("2020-08-01", "boltdb-shipper", "v12", "ab_index_"), tsdb},
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg := SchemaConfig{Configs: append([]PeriodConfig(nil), global...), TenantConfigs: map[string]*SchemaConfig{}}
+			for userID, configs := range tc.tenants {
+				cfg.TenantConfigs[userID] = &SchemaConfig{Configs: configs}
+			}
+
+			err := cfg.Validate()
+			if len(tc.expected) == 0 {
+				require.NoError(t, err)
+				return
+			}
+			var schemaErr *SchemaConfigError
+			require.True(t, errors.As(err, &schemaErr))
+			var actual []error
+			for _, e := range schemaErr.Errors {
+				actual = append(actual, e.Err)
+			}
+			require.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
 func TestSchemaConfig_Validate(t *testing.T) {
 	t.Parallel()
 
@@ -236,6 +423,52 @@ func TestSchemaConfig_Validate(t *testing.T) {
 			},
 			err: errInvalidTablePeriod,
 		},
+		"should fail on unsupported bucket period": {
+			config: &SchemaConfig{
+				Configs: []PeriodConfig{
+					{
+						Schema:       "v12",
+						BucketPeriod: 6 * time.Hour,
+					},
+				},
+			},
+			err: errInvalidBucketPeriod,
+		},
+		"should fail on hourly buckets for schema v10": {
+			config: &SchemaConfig{
+				Configs: []PeriodConfig{
+					{
+						Schema:       "v10",
+						BucketPeriod: time.Hour,
+					},
+				},
+			},
+			err: errHourlyBucketsSchema,
+		},
+		"should pass on hourly buckets and table period multiple of 1h for schema v12": {
+			config: &SchemaConfig{
+				Configs: []PeriodConfig{
+					{
+						Schema:       "v12",
+						BucketPeriod: time.Hour,
+						IndexTables:  PeriodicTableConfig{Period: 6 * time.Hour},
+						ChunkTables:  PeriodicTableConfig{Period: 6 * time.Hour},
+					},
+				},
+			},
+			expected: &SchemaConfig{
+				Configs: []PeriodConfig{
+					{
+						Schema:       "v12",
+						RowShards:    16,
+						BucketPeriod: time.Hour,
+						IndexTables:  PeriodicTableConfig{Period: 6 * time.Hour},
+						ChunkTables:  PeriodicTableConfig{Period: 6 * time.Hour},
+					},
+				},
+			},
+			err: nil,
+		},
 		"should pass on index and chunk table period multiple of 24h for schema v10": {
 			config: &SchemaConfig{
 				Configs: []PeriodConfig{
@@ -408,6 +641,31 @@ func TestSchemaConfig_Validate(t *testing.T) {
 			},
 			err: errConfigChunkPrefixNotSet,
 		},
+		"should fail if object store is missing on IndexType: tsdb": {
+			config: &SchemaConfig{
+				Configs: []PeriodConfig{
+					{
+						Schema:      "v12",
+						IndexType:   "tsdb",
+						IndexTables: PeriodicTableConfig{Period: 24 * time.Hour},
+					},
+				},
+			},
+			err: errTSDBObjectStoreNotSet,
+		},
+		"should pass with IndexType: tsdb and an object store": {
+			config: &SchemaConfig{
+				Configs: []PeriodConfig{
+					{
+						Schema:      "v12",
+						IndexType:   "tsdb",
+						ObjectType:  "filesystem",
+						IndexTables: PeriodicTableConfig{Period: 24 * time.Hour},
+					},
+				},
+			},
+			err: nil,
+		},
 		"invalid schema with same from time configs": {
 			config: &SchemaConfig{
 				Configs: []PeriodConfig{
@@ -459,7 +717,11 @@ func TestSchemaConfig_Validate(t *testing.T) {
 
 		t.Run(testName, func(t *testing.T) {
 			actual := testData.config.Validate()
-			assert.Equal(t, testData.err, actual)
+			if testData.err == nil {
+				assert.NoError(t, actual)
+			} else {
+				assert.ErrorIs(t, actual, testData.err)
+			}
 			if testData.expected != nil {
 				require.Equal(t, testData.expected, testData.config)
 			}
@@ -467,11 +729,63 @@ func TestSchemaConfig_Validate(t *testing.T) {
 	}
 }
 
+func TestSchemaConfig_ValidateAllErrors(t *testing.T) {
+	cfg := &SchemaConfig{
+		Configs: []PeriodConfig{
+			{
+				From:        MustParseDayTime("1970-01-02"),
+				IndexType:   "aws-dynamo",
+				Schema:      "v11",
+				IndexTables: PeriodicTableConfig{Period: 25 * time.Hour},
+			},
+			{
+				From:         MustParseDayTime("1970-01-01"),
+				IndexType:    "boltdb",
+				Schema:       "v8",
+				BucketPeriod: 2 * time.Hour,
+			},
+		},
+		TenantConfigs: map[string]*SchemaConfig{
+			"b": {Configs: []PeriodConfig{{IndexType: "tsdb", Schema: "v12", RowShards: 16}}},
+			"a": {},
+		},
+	}
+
+	err := cfg.Validate()
+	var schemaErr *SchemaConfigError
+	require.True(t, errors.As(err, &schemaErr))
+
+	type fieldErr struct {
+		tenant string
+		index  int
+		field  string
+		err    error
+	}
+	var actual []fieldErr
+	for _, e := range schemaErr.Errors {
+		actual = append(actual, fieldErr{e.Tenant, e.Index, e.Field, e.Err})
+	}
+	require.Equal(t, []fieldErr{
+		{"", 0, "chunks.prefix", errConfigChunkPrefixNotSet},
+		{"", 0, "index.period", errInvalidTablePeriod},
+		{"", 1, "bucket_period", errInvalidBucketPeriod},
+		{"", 1, "schema", errInvalidSchemaVersion},
+		{"", 1, "from", errSchemaIncreasingFromTime},
+		{"a", -1, "configs", errNoTenantPeriodConfig},
+		{"b", 0, "object_store", errTSDBObjectStoreNotSet},
+		{"b", 0, "index.prefix", errTenantSharedIndexPrefix},
+	}, actual)
+	require.ErrorIs(t, err, errSchemaIncreasingFromTime)
+	require.Contains(t, err.Error(), "period config 1 starting at 1970-01-01: from: "+errSchemaIncreasingFromTime.Error())
+	require.Contains(t, err.Error(), "tenant a: configs: "+errNoTenantPeriodConfig.Error())
+}
+
 func TestPeriodConfig_Validate(t *testing.T) {
 	for _, tc := range []struct {
-		desc string
-		in   PeriodConfig
-		err  string
+		desc  string
+		in    PeriodConfig
+		field string
+		err   string
 	}{
 		{
 			desc: "ignore pre v10 sharding",
@@ -488,7 +802,8 @@ func TestPeriodConfig_Validate(t *testing.T) {
 				IndexTables: PeriodicTableConfig{Period: 0},
 				ChunkTables: PeriodicTableConfig{Period: 0},
 			},
-			err: "invalid schema version",
+			field: "schema",
+			err:   "invalid schema version",
 		},
 		{
 			desc: "v10 with shard factor",
@@ -515,7 +830,8 @@ func TestPeriodConfig_Validate(t *testing.T) {
 				IndexTables: PeriodicTableConfig{Period: 0},
 				ChunkTables: PeriodicTableConfig{Period: 0},
 			},
-			err: "must have row_shards > 0 (current: 0) for schema (v10)",
+			field: "row_shards",
+			err:   "must have row_shards > 0 (current: 0) for schema (v10)",
 		},
 		{
 			desc: "v12",
@@ -526,12 +842,129 @@ func TestPeriodConfig_Validate(t *testing.T) {
 				ChunkTables: PeriodicTableConfig{Period: 0},
 			},
 		},
+		{
+			desc: "from aligned with the table periods",
+			in: PeriodConfig{
+				From:         DayTime{model.TimeFromUnix(10 * 3600)},
+				Schema:       "v11",
+				RowShards:    16,
+				BucketPeriod: time.Hour,
+				IndexTables:  PeriodicTableConfig{Period: 2 * time.Hour},
+				ChunkTables:  PeriodicTableConfig{Period: time.Hour},
+			},
+		},
+		{
+			desc: "from on a day with weekly tables",
+			in: PeriodConfig{
+				From:        DayTime{model.TimeFromUnix(2 * 24 * 3600)},
+				Schema:      "v11",
+				RowShards:   16,
+				IndexTables: PeriodicTableConfig{Period: 7 * 24 * time.Hour},
+				ChunkTables: PeriodicTableConfig{Period: 7 * 24 * time.Hour},
+			},
+		},
+		{
+			desc: "error on from not aligned with the index table period",
+			in: PeriodConfig{
+				From:        DayTime{model.TimeFromUnix(10 * 3600)},
+				Schema:      "v11",
+				RowShards:   16,
+				IndexTables: PeriodicTableConfig{Period: 24 * time.Hour},
+			},
+			field: "from",
+			err:   errFromNotAligned.Error(),
+		},
+		{
+			desc: "error on from not aligned with the chunk table period",
+			in: PeriodConfig{
+				From:         DayTime{model.TimeFromUnix(10 * 3600)},
+				Schema:       "v11",
+				RowShards:    16,
+				BucketPeriod: time.Hour,
+				IndexTables:  PeriodicTableConfig{Period: time.Hour},
+				ChunkTables:  PeriodicTableConfig{Period: 4 * time.Hour},
+			},
+			field: "from",
+			err:   errFromNotAligned.Error(),
+		},
+		{
+			desc: "retention period multiple of the index table period",
+			in: PeriodConfig{
+				Schema:          "v11",
+				RowShards:       16,
+				IndexTables:     PeriodicTableConfig{Period: 24 * time.Hour},
+				RetentionPeriod: model.Duration(30 * 24 * time.Hour),
+			},
+		},
+		{
+			desc: "error on retention period not multiple of the index table period",
+			in: PeriodConfig{
+				Schema:          "v11",
+				RowShards:       16,
+				IndexTables:     PeriodicTableConfig{Period: 7 * 24 * time.Hour},
+				RetentionPeriod: model.Duration(30 * 24 * time.Hour),
+			},
+			field: "retention_period",
+			err:   errInvalidRetentionPeriod.Error(),
+		},
+		{
+			desc: "error on schema v14 with the tsdb store",
+			in: PeriodConfig{
+				Schema:     "v14",
+				IndexType:  "tsdb",
+				ObjectType: "filesystem",
+				RowShards:  16,
+			},
+			field: "schema",
+			err:   errVersionedKeysStore.Error(),
+		},
+		{
+			desc: "parquet chunk format",
+			in: PeriodConfig{
+				Schema:      "v11",
+				RowShards:   16,
+				ChunkFormat: ChunkFormatParquet,
+			},
+		},
+		{
+			desc: "error on unknown chunk format",
+			in: PeriodConfig{
+				Schema:      "v11",
+				RowShards:   16,
+				ChunkFormat: "orc",
+			},
+			field: "chunk_format",
+			err:   errInvalidChunkFormat.Error(),
+		},
+		{
+			desc: "error on autoscaling min capacity greater than max capacity",
+			in: PeriodConfig{
+				Schema:      "v11",
+				RowShards:   16,
+				ChunkTables: PeriodicTableConfig{AutoScaling: TableAutoScalingConfig{Read: AutoScalingOverrides{MinCapacity: 10, MaxCapacity: 5}}},
+			},
+			field: "chunks.autoscaling",
+			err:   "read: " + errInvalidAutoScalingCapacity.Error(),
+		},
+		{
+			desc: "error on negative autoscaling cooldown",
+			in: PeriodConfig{
+				Schema:      "v11",
+				RowShards:   16,
+				IndexTables: PeriodicTableConfig{AutoScaling: TableAutoScalingConfig{Write: AutoScalingOverrides{InCooldown: -1}}},
+			},
+			field: "index.autoscaling",
+			err:   "write: " + errNegativeAutoScalingConfig.Error(),
+		},
 	} {
 		t.Run(tc.desc, func(t *testing.T) {
+			errs := tc.in.validate()
 			if tc.err == "" {
-				require.Nil(t, tc.in.validate())
+				require.Empty(t, errs)
 			} else {
-				require.Error(t, tc.in.validate(), tc.err)
+				require.Len(t, errs, 1)
+				require.Equal(t, tc.field, errs[0].Field)
+				require.EqualError(t, errs[0].Err, tc.err)
 			}
 		})
 	}
@@ -572,6 +1005,91 @@ tags:
 	require.Equal(t, yamlFile, string(yamlGenerated))
 }
 
+func TestPeriodicTableConfigAutoScaling(t *testing.T) {
+	yamlFile := `prefix: cortex_
+period: 1w
+tags: {}
+autoscaling:
+  write:
+    min_capacity: 10
+    max_capacity: 100
+  read:
+    out_cooldown: 60
+`
+
+	cfg := PeriodicTableConfig{}
+	require.NoError(t, yaml.Unmarshal([]byte(yamlFile), &cfg))
+	require.Equal(t, TableAutoScalingConfig{
+		Write: AutoScalingOverrides{MinCapacity: 10, MaxCapacity: 100},
+		Read:  AutoScalingOverrides{OutCooldown: 60},
+	}, cfg.AutoScaling)
+
+	yamlGenerated, err := yaml.Marshal(&cfg)
+	require.NoError(t, err)
+	require.Equal(t, yamlFile, string(yamlGenerated))
+
+	// the overrides only apply to the autoscaling settings which are enabled.
+	mtime.NowForce(time.Unix(1, 0))
+	defer mtime.NowReset()
+	tables := cfg.periodicTables(0, model.TimeFromUnix(1), ProvisionConfig{
+		ActiveTableProvisionConfig: ActiveTableProvisionConfig{
+			WriteScale: AutoScalingConfig{Enabled: true, MinCapacity: 1, MaxCapacity: 1000, OutCooldown: 1800, InCooldown: 1800},
+		},
+	}, 0, 0, 0)
+	require.Len(t, tables, 1)
+	require.Equal(t, AutoScalingConfig{Enabled: true, MinCapacity: 10, MaxCapacity: 100, OutCooldown: 1800, InCooldown: 1800}, tables[0].WriteScale)
+	require.Equal(t, AutoScalingConfig{}, tables[0].ReadScale)
+}
+
+func TestPeriodicTableConfigNameFormat(t *testing.T) {
+	yamlFile := `prefix: loki_index_
+period: 168h
+name_format: '{{.Prefix}}{{.WeekYear}}_{{printf "%02d" .Week}}'
+`
+
+	cfg := PeriodicTableConfig{}
+	require.NoError(t, yaml.Unmarshal([]byte(yamlFile), &cfg))
+	require.Equal(t, "loki_index_2022_05", cfg.TableFor(model.TimeFromUnix(time.Date(2022, 2, 3, 10, 0, 0, 0, time.UTC).Unix())))
+	require.NoError(t, cfg.validateNameFormat(model.TimeFromUnix(0)))
+
+	yamlGenerated, err := yaml.Marshal(&cfg)
+	require.NoError(t, err)
+	require.Equal(t, "prefix: loki_index_\nperiod: 1w\ntags: {}\nname_format: '{{.Prefix}}{{.WeekYear}}_{{printf \"%02d\" .Week}}'\n", string(yamlGenerated))
+
+	// Configs built in code are templated too.
+	daily := PeriodicTableConfig{Prefix: "index_", Period: 24 * time.Hour, NameFormat: "{{.Prefix}}{{.Year}}{{printf \"%02d%02d\" .Month .Day}}"}
+	require.Equal(t, "index_20220203", daily.TableFor(model.TimeFromUnix(time.Date(2022, 2, 3, 10, 0, 0, 0, time.UTC).Unix())))
+
+	require.Error(t, yaml.Unmarshal([]byte("name_format: '{{.Prefix'"), &cfg))
+
+	for _, tc := range []struct {
+		cfg PeriodicTableConfig
+		err string
+	}{
+		{cfg: PeriodicTableConfig{Prefix: "index_", NameFormat: "{{.Prefix}}{{.Period}}"}, err: "a name format requires a table period"},
+		{cfg: PeriodicTableConfig{Prefix: "index_", Period: 24 * time.Hour, NameFormat: "{{.Foo}}"}, err: "can't evaluate field Foo"},
+		{cfg: PeriodicTableConfig{Prefix: "index_", Period: 24 * time.Hour, NameFormat: "loki_{{.Period}}"}, err: `table name "loki_0" doesn't start with the prefix "index_"`},
+		{cfg: PeriodicTableConfig{Prefix: "index_", Period: 24 * time.Hour, NameFormat: "{{.Prefix}}{{.Year}}"}, err: `consecutive periods have the same table name "index_1970"`},
+	} {
+		err := tc.cfg.validateNameFormat(model.TimeFromUnix(0))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), tc.err)
+	}
+
+	period := PeriodConfig{
+		From:        DayTime{model.TimeFromUnix(0)},
+		IndexType:   "boltdb-shipper",
+		Schema:      "v11",
+		IndexTables: daily,
+		RowShards:   16,
+	}
+	require.Equal(t, []*PeriodConfigError{
+		{From: period.From, Field: "index.name_format", Err: errTableNameFormatStore},
+	}, period.validate())
+	period.IndexType, period.ObjectType = "aws-dynamo", "s3"
+	require.Empty(t, period.validate())
+}
+
 func TestSchemaForTime(t *testing.T) {
 	schemaCfg := SchemaConfig{Configs: []PeriodConfig{
 		{
@@ -696,3 +1214,163 @@ store: boltdb-shipper
 
 	require.Equal(t, expected, cfg)
 }
+
+func TestDayTimeYAML(t *testing.T) {
+	for _, tc := range []struct {
+		in       string
+		expected model.Time
+		out      string
+		err      bool
+	}{
+		{in: "2020-07-31", expected: model.Time(1596153600000), out: "2020-07-31"},
+		{in: "2020-07-31T00:00:00Z", expected: model.Time(1596153600000), out: "2020-07-31"},
+		{in: "2020-07-31T04:00:00Z", expected: model.Time(1596168000000), out: "2020-07-31T04:00:00Z"},
+		{in: "2020-07-31T06:00:00+02:00", expected: model.Time(1596168000000), out: "2020-07-31T04:00:00Z"},
+		{in: "2020-07-31 04:00", err: true},
+	} {
+		t.Run(tc.in, func(t *testing.T) {
+			var d DayTime
+			err := yaml.Unmarshal([]byte(tc.in), &d)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, d.Time)
+			require.Equal(t, tc.out, d.String())
+
+			out, err := yaml.Marshal(d)
+			require.NoError(t, err)
+			var roundTrip DayTime
+			require.NoError(t, yaml.Unmarshal(out, &roundTrip))
+			require.Equal(t, d, roundTrip)
+		})
+	}
+}
+
+func TestUnmarshalPeriodConfigBucketPeriod(t *testing.T) {
+	input := `
+from: "2020-07-31"
+index:
+  period: 24h
+  prefix: loki_index_
+schema: v12
+store: boltdb-shipper
+bucket_period: 1h
+`
+
+	var cfg PeriodConfig
+	require.Nil(t, yaml.Unmarshal([]byte(input), &cfg))
+	require.Equal(t, time.Hour, cfg.BucketPeriod)
+}
+
+func TestSchemaConfig_TenantPrefixedExternalKey(t *testing.T) {
+	from := model.TimeFromUnix(int64(3 * 7 * 24 * time.Hour / time.Second)).Add(25 * time.Hour)
+	chk := Chunk{
+		ChunkRef: logproto.ChunkRef{
+			Fingerprint: 100,
+			UserID:      "fake",
+			From:        from,
+			Through:     from.Add(time.Hour),
+			Checksum:    12345,
+		},
+		ChecksumSet: true,
+	}
+
+	for _, tc := range []struct {
+		name        string
+		chunkTables PeriodicTableConfig
+		expected    string
+	}{
+		{
+			name:     "daily",
+			expected: "51d9d4388a3dccf4/22/64/",
+		},
+		{
+			name:        "weekly chunk tables",
+			chunkTables: PeriodicTableConfig{Prefix: "chunks_", Period: 7 * 24 * time.Hour},
+			expected:    "51d9d4388a3dccf4/3/64/",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := SchemaConfig{
+				Configs: []PeriodConfig{
+					{
+						From:        DayTime{Time: 0},
+						Schema:      "v13",
+						RowShards:   16,
+						ChunkTables: tc.chunkTables,
+					},
+				},
+			}
+
+			key := cfg.ExternalKey(chk)
+			require.True(t, strings.HasPrefix(key, tc.expected), key)
+			require.True(t, strings.HasPrefix(key, cfg.TenantPrefix(chk.UserID)), key)
+
+			parsed, err := ParseExternalKey(chk.UserID, key)
+			require.NoError(t, err)
+			require.Equal(t, chk, parsed)
+
+			_, err = ParseExternalKey("other", key)
+			require.Equal(t, ErrWrongMetadata, errors.Cause(err))
+		})
+	}
+}
+
+func TestSchemaConfig_NewPeriods(t *testing.T) {
+	now := model.TimeFromUnix(int64(10 * 24 * time.Hour / time.Second))
+	period := func(day int, schema string) PeriodConfig {
+		return PeriodConfig{
+			From:      DayTime{model.TimeFromUnix(int64(time.Duration(day) * 24 * time.Hour / time.Second))},
+			IndexType: "boltdb",
+			Schema:    schema,
+		}
+	}
+	current := SchemaConfig{Configs: []PeriodConfig{period(0, "v11"), period(5, "v11")}}
+
+	for _, tc := range []struct {
+		name     string
+		next     []PeriodConfig
+		expected []PeriodConfig
+		err      error
+	}{
+		{
+			name: "unchanged",
+			next: []PeriodConfig{period(0, "v11"), period(5, "v11")},
+		},
+		{
+			name:     "period added in the future",
+			next:     []PeriodConfig{period(0, "v11"), period(5, "v11"), period(11, "v12"), period(12, "v13")},
+			expected: []PeriodConfig{period(11, "v12"), period(12, "v13")},
+		},
+		{
+			name: "period added in the past",
+			next: []PeriodConfig{period(0, "v11"), period(5, "v11"), period(10, "v12")},
+			err:  errNewPeriodConfigNotInFuture,
+		},
+		{
+			name: "period removed",
+			next: []PeriodConfig{period(0, "v11")},
+			err:  errPeriodConfigRemoved,
+		},
+		{
+			name: "period changed",
+			next: []PeriodConfig{period(0, "v11"), period(5, "v12")},
+			err:  errPeriodConfigChanged,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			added, err := current.NewPeriods(SchemaConfig{Configs: tc.next}, now)
+			if tc.err != nil {
+				require.ErrorIs(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, added, len(tc.expected))
+			for i := range tc.expected {
+				require.True(t, tc.expected[i].equal(added[i]))
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package chunk
+
+import (
+	"context"
+
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/grafana/loki/pkg/querier/astmapper"
+	"github.com/grafana/loki/pkg/storage/chunk/cache"
+	"github.com/grafana/loki/pkg/util/spanlogger"
+)
+
+// SeriesIndex indexes chunks by the labels of their series, metric name included. It is implemented
+// by index types which don't store the entries generated by a schema in an IndexClient, like tsdb.
+type SeriesIndex interface {
+	// IndexChunk adds the chunk to the index for the given time range.
+	IndexChunk(ctx context.Context, from, through model.Time, chk Chunk) error
+	// GetChunkRefs returns the chunks of the series matching the matchers, without their data.
+	// When shard is not nil, only the series belonging to the shard are returned.
+	GetChunkRefs(ctx context.Context, userID string, from, through model.Time, shard *astmapper.ShardAnnotation, matchers ...*labels.Matcher) ([]Chunk, error)
+	// LabelNames returns the label names of the series matching the matchers, except the metric name.
+	// When shard is not nil, only the series belonging to the shard are considered.
+	LabelNames(ctx context.Context, userID string, from, through model.Time, shard *astmapper.ShardAnnotation, matchers ...*labels.Matcher) ([]string, error)
+	LabelValues(ctx context.Context, userID string, from, through model.Time, name string, matchers ...*labels.Matcher) ([]string, error)
+	Stop()
+}
+
+// seriesIndexStore is a Store which reads and writes its index through a SeriesIndex.
+type seriesIndexStore struct {
+	baseStore
+	seriesIndex SeriesIndex
+}
+
+func newSeriesIndexStore(cfg StoreConfig, scfg SchemaConfig, index SeriesIndex, chunks Client, limits StoreLimits, chunksCache cache.Cache) (Store, error) {
+	fetcher, err := NewChunkFetcher(chunksCache, cfg.chunkCacheStubs, scfg, chunks, cfg.ChunkCacheConfig.AsyncCacheWriteBackConcurrency, cfg.ChunkCacheConfig.AsyncCacheWriteBackBufferSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return &seriesIndexStore{
+		baseStore: baseStore{
+			cfg:       cfg,
+			schemaCfg: scfg,
+			chunks:    chunks,
+			limits:    limits,
+			fetcher:   fetcher,
+		},
+		seriesIndex: index,
+	}, nil
+}
+
+// Stop any background goroutines (ie in the cache.)
+func (c *seriesIndexStore) Stop() {
+	c.fetcher.storage.Stop()
+	c.fetcher.Stop()
+	c.seriesIndex.Stop()
+}
+
+// Put implements Store
+func (c *seriesIndexStore) Put(ctx context.Context, chunks []Chunk) error {
+	if err := c.putContainers(ctx, chunks); err != nil {
+		return err
+	}
+	for _, chunk := range chunks {
+		if err := c.PutOne(ctx, chunk.From, chunk.Through, chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PutOne implements Store. The chunks packed in a container are only indexed,
+// their container being written by Put.
+func (c *seriesIndexStore) PutOne(ctx context.Context, from, through model.Time, chunk Chunk) error {
+	log, ctx := spanlogger.New(ctx, "SeriesIndexStore.PutOne")
+	defer log.Finish()
+	writeChunk := true
+
+	// If this chunk is in cache it must already be in the database so we don't need to write it again
+	found, _, _, _ := c.fetcher.cache.Fetch(ctx, []string{c.schemaCfg.ExternalKey(chunk)})
+
+	if len(found) > 0 {
+		writeChunk = false
+		dedupedChunksTotal.Inc()
+	}
+
+	if !writeChunk && !c.cfg.DisableIndexDeduplication {
+		return nil
+	}
+
+	chunks := []Chunk{chunk}
+	if writeChunk && !chunk.Contained() {
+		if err := c.fetcher.storage.PutChunks(ctx, chunks); err != nil {
+			return err
+		}
+	}
+
+	if err := c.seriesIndex.IndexChunk(ctx, from, through, chunk); err != nil {
+		return err
+	}
+
+	// we already have the chunk in the cache so don't write it back to the cache.
+	if writeChunk {
+		if cacheErr := c.fetcher.writeBackCache(ctx, chunks); cacheErr != nil {
+			level.Warn(log).Log("msg", "could not store chunks in chunk cache", "err", cacheErr)
+		}
+	}
+
+	return nil
+}
+
+// GetChunkRefs implements Store
+func (c *seriesIndexStore) GetChunkRefs(ctx context.Context, userID string, from, through model.Time, allMatchers ...*labels.Matcher) ([][]Chunk, []*Fetcher, error) {
+	if ctx.Err() != nil {
+		return nil, nil, ctx.Err()
+	}
+	log, ctx := spanlogger.New(ctx, "SeriesIndexStore.GetChunkRefs")
+	defer log.Span.Finish()
+
+	// Validate the query is within reasonable bounds.
+	metricName, matchers, shortcut, err := c.validateQuery(ctx, userID, &from, &through, allMatchers)
+	if err != nil {
+		return nil, nil, err
+	} else if shortcut {
+		return nil, nil, nil
+	}
+
+	level.Debug(log).Log("metric", metricName)
+
+	shard, shardLabelIndex, err := astmapper.ShardFromMatchers(matchers)
+	if err != nil {
+		return nil, nil, err
+	}
+	if shard != nil {
+		matchers = append(matchers[:shardLabelIndex], matchers[shardLabelIndex+1:]...)
+	}
+	matchers = append(matchers, labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, metricName))
+
+	chunks, err := c.seriesIndex.GetChunkRefs(ctx, userID, from, through, shard, matchers...)
+	if err != nil {
+		level.Error(log).Log("msg", "GetChunkRefs", "err", err)
+		return nil, nil, err
+	}
+
+	chunks = filterChunksByTime(from, through, chunks)
+	level.Debug(log).Log("chunks-post-filtering", len(chunks))
+	chunksPerQuery.Observe(float64(len(chunks)))
+
+	// We should return an empty chunks slice if there are no chunks.
+	if len(chunks) == 0 {
+		return [][]Chunk{}, []*Fetcher{}, nil
+	}
+
+	return [][]Chunk{chunks}, []*Fetcher{c.fetcher}, nil
+}
+
+// LabelNamesForMetricName implements Store
+func (c *seriesIndexStore) LabelNamesForMetricName(ctx context.Context, userID string, from, through model.Time, metricName string, matchers ...*labels.Matcher) ([]string, error) {
+	log, ctx := spanlogger.New(ctx, "SeriesIndexStore.LabelNamesForMetricName")
+	defer log.Span.Finish()
+
+	shortcut, err := c.validateQueryTimeRange(ctx, userID, &from, &through)
+	if err != nil {
+		return nil, err
+	} else if shortcut {
+		return nil, nil
+	}
+
+	shard, shardLabelIndex, err := astmapper.ShardFromMatchers(matchers)
+	if err != nil {
+		return nil, err
+	}
+	// don't modify the matchers of the caller.
+	filtered := make([]*labels.Matcher, 0, len(matchers)+1)
+	for i, m := range matchers {
+		if shard != nil && i == shardLabelIndex {
+			continue
+		}
+		filtered = append(filtered, m)
+	}
+	filtered = append(filtered, labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, metricName))
+
+	return c.seriesIndex.LabelNames(ctx, userID, from, through, shard, filtered...)
+}
+
+// LabelValuesForMetricName implements Store
+func (c *seriesIndexStore) LabelValuesForMetricName(ctx context.Context, userID string, from, through model.Time, metricName string, labelName string, matchers ...*labels.Matcher) ([]string, error) {
+	log, ctx := spanlogger.New(ctx, "SeriesIndexStore.LabelValuesForMetricName")
+	defer log.Span.Finish()
+
+	shortcut, err := c.validateQueryTimeRange(ctx, userID, &from, &through)
+	if err != nil {
+		return nil, err
+	} else if shortcut {
+		return nil, nil
+	}
+
+	matchers = append([]*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, metricName)}, matchers...)
+	return c.seriesIndex.LabelValues(ctx, userID, from, through, labelName, matchers...)
+}
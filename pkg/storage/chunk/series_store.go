@@ -2,10 +2,12 @@ package chunk
 
 import (
 	"context"
+	"encoding/hex"
 	"fmt"
 	"time"
 
 	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/multierror"
 	jsoniter "github.com/json-iterator/go"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
@@ -146,7 +148,7 @@ func (c *seriesStore) GetChunkRefs(ctx context.Context, userID string, from, thr
 }
 
 // LabelNamesForMetricName retrieves all label names for a metric name.
-func (c *seriesStore) LabelNamesForMetricName(ctx context.Context, userID string, from, through model.Time, metricName string) ([]string, error) {
+func (c *seriesStore) LabelNamesForMetricName(ctx context.Context, userID string, from, through model.Time, metricName string, matchers ...*labels.Matcher) ([]string, error) {
 	log, ctx := spanlogger.New(ctx, "SeriesStore.LabelNamesForMetricName")
 	defer log.Span.Finish()
 
@@ -159,7 +161,7 @@ func (c *seriesStore) LabelNamesForMetricName(ctx context.Context, userID string
 	level.Debug(log).Log("metric", metricName)
 
 	// Fetch the series IDs from the index
-	seriesIDs, err := c.lookupSeriesByMetricNameMatchers(ctx, from, through, userID, metricName, nil)
+	seriesIDs, err := c.lookupSeriesByMetricNameMatchers(ctx, from, through, userID, metricName, matchers)
 	if err != nil {
 		return nil, err
 	}
@@ -416,6 +418,9 @@ func (c *seriesStore) lookupLabelNamesBySeries(ctx context.Context, from, throug
 
 // Put implements Store
 func (c *seriesStore) Put(ctx context.Context, chunks []Chunk) error {
+	if err := c.putContainers(ctx, chunks); err != nil {
+		return err
+	}
 	for _, chunk := range chunks {
 		if err := c.PutOne(ctx, chunk.From, chunk.Through, chunk); err != nil {
 			return err
@@ -424,7 +429,8 @@ func (c *seriesStore) Put(ctx context.Context, chunks []Chunk) error {
 	return nil
 }
 
-// PutOne implements Store
+// PutOne implements Store. The chunks packed in a container are only indexed,
+// their container being written by Put.
 func (c *seriesStore) PutOne(ctx context.Context, from, through model.Time, chunk Chunk) error {
 	log, ctx := spanlogger.New(ctx, "SeriesStore.PutOne")
 	defer log.Finish()
@@ -447,28 +453,54 @@ func (c *seriesStore) PutOne(ctx context.Context, from, through model.Time, chun
 
 	chunks := []Chunk{chunk}
 
-	writeReqs, keysToCache, err := c.calculateIndexEntries(ctx, from, through, chunk)
+	tableBatches, err := c.calculateIndexEntries(ctx, from, through, chunk)
 	if err != nil {
 		return err
 	}
 
+	var keysToCache []string
 	if oic, ok := c.fetcher.storage.(ObjectAndIndexClient); ok {
 		chunks := chunks
-		if !writeChunk {
+		if !writeChunk || chunk.Contained() {
 			chunks = []Chunk{}
 		}
+		writeReqs := c.index.NewWriteBatch()
+		for _, tb := range tableBatches {
+			tb.addTo(writeReqs)
+		}
 		if err = oic.PutChunksAndIndex(ctx, chunks, writeReqs); err != nil {
 			return err
 		}
+		for _, tb := range tableBatches {
+			keysToCache = append(keysToCache, tb.keys...)
+		}
 	} else {
 		// chunk not found, write it.
-		if writeChunk {
+		if writeChunk && !chunk.Contained() {
 			err := c.fetcher.storage.PutChunks(ctx, chunks)
 			if err != nil {
 				return err
 			}
 		}
-		if err := c.index.BatchWrite(ctx, writeReqs); err != nil {
+
+		// The tables are written one batch at a time, the dedupe keys of the tables written are cached even when
+		// the write of another table fails, for the retry of the flush not to write their entries again.
+		var errs multierror.MultiError
+		for _, tb := range tableBatches {
+			if len(tb.entries) == 0 {
+				keysToCache = append(keysToCache, tb.keys...)
+				continue
+			}
+			writeReqs := c.index.NewWriteBatch()
+			tb.addTo(writeReqs)
+			if err := c.index.BatchWrite(ctx, writeReqs); err != nil {
+				errs.Add(fmt.Errorf("failed to write the index of the table %s: %w", tb.tableName, err))
+				continue
+			}
+			keysToCache = append(keysToCache, tb.keys...)
+		}
+		if err := errs.Err(); err != nil {
+			c.storeWriteDedupeKeys(ctx, log, keysToCache)
 			return err
 		}
 	}
@@ -480,56 +512,115 @@ func (c *seriesStore) PutOne(ctx context.Context, from, through model.Time, chun
 		}
 	}
 
-	bufs := make([][]byte, len(keysToCache))
-	err = c.writeDedupeCache.Store(ctx, keysToCache, bufs)
-	if err != nil {
+	c.storeWriteDedupeKeys(ctx, log, keysToCache)
+	return nil
+}
+
+func (c *seriesStore) storeWriteDedupeKeys(ctx context.Context, log *spanlogger.SpanLogger, keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	bufs := make([][]byte, len(keys))
+	if err := c.writeDedupeCache.Store(ctx, keys, bufs); err != nil {
 		level.Warn(log).Log("msg", "could not Store store in write dedupe cache", "err", err)
 	}
-	return nil
 }
 
-// calculateIndexEntries creates a set of batched WriteRequests for all the chunks it is given.
-func (c *seriesStore) calculateIndexEntries(ctx context.Context, from, through model.Time, chunk Chunk) (WriteBatch, []string, error) {
-	seenIndexEntries := map[string]struct{}{}
-	entries := []IndexEntry{}
+// tableWriteBatch holds the index entries of a chunk written to a table, along with the write dedupe keys of the
+// entries, cached once the entries are written.
+type tableWriteBatch struct {
+	tableName string
+	entries   []IndexEntry
+	keys      []string
+}
+
+func (b tableWriteBatch) addTo(batch WriteBatch) {
+	for _, entry := range b.entries {
+		batch.Add(entry.TableName, entry.HashValue, entry.RangeValue, entry.Value)
+	}
+}
+
+// chunkEntriesCacheKey is the write dedupe key of the chunk entries of a chunk in a table.
+func chunkEntriesCacheKey(tableName, chunkID string) string {
+	return hex.EncodeToString([]byte(tableName + "-" + chunkID))
+}
 
+// calculateIndexEntries creates the batches of index entries of the chunk, one per table, skipping the entries
+// whose write dedupe key is cached.
+func (c *seriesStore) calculateIndexEntries(ctx context.Context, from, through model.Time, chunk Chunk) ([]tableWriteBatch, error) {
 	metricName := chunk.Metric.Get(labels.MetricName)
 	if metricName == "" {
-		return nil, nil, fmt.Errorf("no MetricNameLabel for chunk")
+		return nil, fmt.Errorf("no MetricNameLabel for chunk")
 	}
 
-	keys, labelEntries, err := c.schema.GetCacheKeysAndLabelWriteEntries(from, through, chunk.UserID, metricName, chunk.Metric, c.baseStore.schemaCfg.ExternalKey(chunk))
+	chunkID := c.baseStore.schemaCfg.ExternalKey(chunk)
+	keys, labelEntries, err := c.schema.GetCacheKeysAndLabelWriteEntries(from, through, chunk.UserID, metricName, chunk.Metric, chunkID)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
-	_, _, missing, _ := c.writeDedupeCache.Fetch(ctx, keys)
-	// keys and labelEntries are matched in order, but Fetch() may
-	// return missing keys in any order so check against all of them.
-	for _, missingKey := range missing {
-		for i, key := range keys {
-			if key == missingKey {
-				entries = append(entries, labelEntries[i]...)
-			}
+	chunkEntries, err := c.schema.GetChunkWriteEntries(from, through, chunk.UserID, metricName, chunk.Metric, chunkID)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		batches     []tableWriteBatch
+		batchByName = map[string]int{}
+	)
+	batchFor := func(tableName string) *tableWriteBatch {
+		i, ok := batchByName[tableName]
+		if !ok {
+			i = len(batches)
+			batchByName[tableName] = i
+			batches = append(batches, tableWriteBatch{tableName: tableName})
 		}
+		return &batches[i]
 	}
 
-	chunkEntries, err := c.schema.GetChunkWriteEntries(from, through, chunk.UserID, metricName, chunk.Metric, c.baseStore.schemaCfg.ExternalKey(chunk))
-	if err != nil {
-		return nil, nil, err
+	// the label entries of a key all belong to the table of its bucket, the chunk entries get a key per table.
+	entriesByKey := make(map[string][]IndexEntry, len(keys))
+	for i, key := range keys {
+		entriesByKey[key] = labelEntries[i]
+	}
+	for _, entry := range chunkEntries {
+		key := chunkEntriesCacheKey(entry.TableName, chunkID)
+		if _, ok := entriesByKey[key]; !ok {
+			keys = append(keys, key)
+		}
+		entriesByKey[key] = append(entriesByKey[key], entry)
 	}
-	entries = append(entries, chunkEntries...)
 
-	indexEntriesPerChunk.Observe(float64(len(entries)))
+	_, _, missing, _ := c.writeDedupeCache.Fetch(ctx, keys)
+	// Fetch() may return missing keys in any order, the batches follow the order of the keys.
+	missingKeys := make(map[string]struct{}, len(missing))
+	for _, key := range missing {
+		missingKeys[key] = struct{}{}
+	}
 
 	// Remove duplicate entries based on tableName:hashValue:rangeValue
-	result := c.index.NewWriteBatch()
-	for _, entry := range entries {
-		key := fmt.Sprintf("%s:%s:%x", entry.TableName, entry.HashValue, entry.RangeValue)
-		if _, ok := seenIndexEntries[key]; !ok {
-			seenIndexEntries[key] = struct{}{}
-			result.Add(entry.TableName, entry.HashValue, entry.RangeValue, entry.Value)
+	seenIndexEntries := map[string]struct{}{}
+	numEntries := 0
+	for _, key := range keys {
+		if _, ok := missingKeys[key]; !ok {
+			continue
+		}
+		for _, entry := range entriesByKey[key] {
+			numEntries++
+			seenKey := fmt.Sprintf("%s:%s:%x", entry.TableName, entry.HashValue, entry.RangeValue)
+			if _, ok := seenIndexEntries[seenKey]; ok {
+				continue
+			}
+			seenIndexEntries[seenKey] = struct{}{}
+			batch := batchFor(entry.TableName)
+			batch.entries = append(batch.entries, entry)
+		}
+		if entries := entriesByKey[key]; len(entries) > 0 {
+			batch := batchFor(entries[0].TableName)
+			batch.keys = append(batch.keys, key)
 		}
 	}
 
-	return result, missing, nil
+	indexEntriesPerChunk.Observe(float64(numEntries))
+
+	return batches, nil
 }
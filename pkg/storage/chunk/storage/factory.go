@@ -13,16 +13,20 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/grafana/loki/pkg/storage/chunk"
+	"github.com/grafana/loki/pkg/storage/chunk/alibaba"
 	"github.com/grafana/loki/pkg/storage/chunk/aws"
 	"github.com/grafana/loki/pkg/storage/chunk/azure"
+	"github.com/grafana/loki/pkg/storage/chunk/baidubce"
 	"github.com/grafana/loki/pkg/storage/chunk/cache"
 	"github.com/grafana/loki/pkg/storage/chunk/cassandra"
+	"github.com/grafana/loki/pkg/storage/chunk/encryption"
 	"github.com/grafana/loki/pkg/storage/chunk/gcp"
 	"github.com/grafana/loki/pkg/storage/chunk/grpc"
 	"github.com/grafana/loki/pkg/storage/chunk/hedging"
 	"github.com/grafana/loki/pkg/storage/chunk/local"
 	"github.com/grafana/loki/pkg/storage/chunk/objectclient"
 	"github.com/grafana/loki/pkg/storage/chunk/openstack"
+	"github.com/grafana/loki/pkg/storage/chunk/tencent"
 	"github.com/grafana/loki/pkg/storage/stores/shipper/downloads"
 	util_log "github.com/grafana/loki/pkg/util/log"
 )
@@ -35,10 +39,12 @@ const (
 
 // Supported storage clients
 const (
+	StorageTypeAlibabaCloud   = "alibabacloud-oss"
 	StorageTypeAWS            = "aws"
 	StorageTypeAWSDynamo      = "aws-dynamo"
 	StorageTypeAzure          = "azure"
 	StorageTypeBoltDB         = "boltdb"
+	StorageTypeBOS            = "bos"
 	StorageTypeCassandra      = "cassandra"
 	StorageTypeInMemory       = "inmemory"
 	StorageTypeBigTable       = "bigtable"
@@ -50,6 +56,7 @@ const (
 	StorageTypeGrpc           = "grpc-store"
 	StorageTypeS3             = "s3"
 	StorageTypeSwift          = "swift"
+	StorageTypeTencentCloud   = "tencentcloud-cos"
 )
 
 type indexStoreFactories struct {
@@ -71,6 +78,18 @@ func RegisterIndexStore(name string, indexClientFactory IndexClientFactoryFunc,
 	customIndexStores[name] = indexStoreFactories{indexClientFactory, tableClientFactory}
 }
 
+// SeriesIndexFactoryFunc defines signature of function which creates the chunk.SeriesIndex of a period.
+// name identifies the period, it is unique amongst the periods of the schema config.
+type SeriesIndexFactoryFunc func(period chunk.PeriodConfig, name string, limits StoreLimits, registerer prometheus.Registerer) (chunk.SeriesIndex, error)
+
+var customSeriesIndexes = map[string]SeriesIndexFactoryFunc{}
+
+// RegisterSeriesIndex is used for registering an index type which indexes chunks by their series
+// through a chunk.SeriesIndex instead of an IndexClient. The periods using it get a store built around it.
+func RegisterSeriesIndex(name string, factory SeriesIndexFactoryFunc) {
+	customSeriesIndexes[name] = factory
+}
+
 // StoreLimits helps get Limits specific to Queries for Stores
 type StoreLimits interface {
 	downloads.Limits
@@ -81,25 +100,35 @@ type StoreLimits interface {
 
 // Config chooses which storage client to use.
 type Config struct {
-	Engine                 string                  `yaml:"engine"`
-	AWSStorageConfig       aws.StorageConfig       `yaml:"aws"`
-	AzureStorageConfig     azure.BlobStorageConfig `yaml:"azure"`
-	GCPStorageConfig       gcp.Config              `yaml:"bigtable"`
-	GCSConfig              gcp.GCSConfig           `yaml:"gcs"`
-	CassandraStorageConfig cassandra.Config        `yaml:"cassandra"`
-	BoltDBConfig           local.BoltDBConfig      `yaml:"boltdb"`
-	FSConfig               local.FSConfig          `yaml:"filesystem"`
-	Swift                  openstack.SwiftConfig   `yaml:"swift"`
+	Engine                 string                    `yaml:"engine"`
+	AlibabaCloudConfig     alibaba.OssConfig         `yaml:"alibabacloud"`
+	AWSStorageConfig       aws.StorageConfig         `yaml:"aws"`
+	AzureStorageConfig     azure.BlobStorageConfig   `yaml:"azure"`
+	BOSStorageConfig       baidubce.BOSStorageConfig `yaml:"bos"`
+	GCPStorageConfig       gcp.Config                `yaml:"bigtable"`
+	GCSConfig              gcp.GCSConfig             `yaml:"gcs"`
+	CassandraStorageConfig cassandra.Config          `yaml:"cassandra"`
+	BoltDBConfig           local.BoltDBConfig        `yaml:"boltdb"`
+	FSConfig               local.FSConfig            `yaml:"filesystem"`
+	Swift                  openstack.SwiftConfig     `yaml:"swift"`
+	TencentCloudConfig     tencent.CosConfig         `yaml:"tencentcloud"`
 
 	IndexCacheValidity time.Duration `yaml:"index_cache_validity"`
 
 	IndexQueriesCacheConfig  cache.Config `yaml:"index_queries_cache_config"`
 	DisableBroadIndexQueries bool         `yaml:"disable_broad_index_queries"`
+	IndexQueriesRateLimit    float64      `yaml:"index_queries_rate_limit"`
 	MaxParallelGetChunk      int          `yaml:"max_parallel_get_chunk"`
 
 	GrpcConfig grpc.Config `yaml:"grpc_store"`
 
 	Hedging hedging.Config `yaml:"hedging"`
+
+	Mirror          MirrorConfig          `yaml:"mirror"`
+	ChunkEncryption encryption.Config     `yaml:"chunk_encryption"`
+	ObjectRateLimit ObjectRateLimitConfig `yaml:"object_rate_limit"`
+
+	HealthCheck HealthCheckConfig `yaml:"health_check"`
 }
 
 type ClientMetrics struct {
@@ -118,21 +147,29 @@ func (c *ClientMetrics) Unregister() {
 
 // RegisterFlags adds the flags required to configure this flag set.
 func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
+	cfg.AlibabaCloudConfig.RegisterFlags(f)
 	cfg.AWSStorageConfig.RegisterFlags(f)
 	cfg.AzureStorageConfig.RegisterFlags(f)
+	cfg.BOSStorageConfig.RegisterFlags(f)
 	cfg.GCPStorageConfig.RegisterFlags(f)
 	cfg.GCSConfig.RegisterFlags(f)
 	cfg.CassandraStorageConfig.RegisterFlags(f)
 	cfg.BoltDBConfig.RegisterFlags(f)
 	cfg.FSConfig.RegisterFlags(f)
 	cfg.Swift.RegisterFlags(f)
+	cfg.TencentCloudConfig.RegisterFlags(f)
 	cfg.GrpcConfig.RegisterFlags(f)
 	cfg.Hedging.RegisterFlagsWithPrefix("store.", f)
+	cfg.Mirror.RegisterFlags(f)
+	cfg.ChunkEncryption.RegisterFlags(f)
+	cfg.ObjectRateLimit.RegisterFlags(f)
+	cfg.HealthCheck.RegisterFlags(f)
 
 	f.StringVar(&cfg.Engine, "store.engine", "chunks", "The storage engine to use: chunks or blocks.")
 	cfg.IndexQueriesCacheConfig.RegisterFlagsWithPrefix("store.index-cache-read.", "Cache config for index entry reading.", f)
 	f.DurationVar(&cfg.IndexCacheValidity, "store.index-cache-validity", 5*time.Minute, "Cache validity for active index entries. Should be no higher than -ingester.max-chunk-idle.")
 	f.BoolVar(&cfg.DisableBroadIndexQueries, "store.disable-broad-index-queries", false, "Disable broad index queries which results in reduced cache usage and faster query performance at the expense of somewhat higher QPS on the index store.")
+	f.Float64Var(&cfg.IndexQueriesRateLimit, "store.index-queries-rate-limit", 0, "Maximum number of index queries per second of the index client of each period using the rate_limit index client middleware. 0 to disable.")
 	f.IntVar(&cfg.MaxParallelGetChunk, "store.max-parallel-get-chunk", 150, "Maximum number of parallel chunk reads.")
 }
 
@@ -159,6 +196,21 @@ func (cfg *Config) Validate() error {
 	if err := cfg.AWSStorageConfig.Validate(); err != nil {
 		return errors.Wrap(err, "invalid AWS Storage config")
 	}
+	if err := cfg.Hedging.Validate(); err != nil {
+		return errors.Wrap(err, "invalid Hedging config")
+	}
+	if err := cfg.Mirror.Validate(); err != nil {
+		return errors.Wrap(err, "invalid Mirror config")
+	}
+	if err := cfg.ChunkEncryption.Validate(); err != nil {
+		return errors.Wrap(err, "invalid Chunk Encryption config")
+	}
+	if err := cfg.ObjectRateLimit.Validate(); err != nil {
+		return errors.Wrap(err, "invalid Object Rate Limit config")
+	}
+	if err := cfg.HealthCheck.Validate(); err != nil {
+		return errors.Wrap(err, "invalid Health Check config")
+	}
 	return nil
 }
 
@@ -210,38 +262,146 @@ func NewStore(
 	}
 	stores := chunk.NewCompositeStore(cacheGenNumLoader)
 
-	for _, s := range schemaCfg.Configs {
-		indexClientReg := prometheus.WrapRegistererWith(
-			prometheus.Labels{"component": "index-store-" + s.From.String()}, reg)
-
-		index, err := NewIndexClient(s.IndexType, cfg, schemaCfg, limits, indexClientReg)
-		if err != nil {
-			return nil, errors.Wrap(err, "error creating index client")
-		}
-		index = newCachingIndexClient(index, indexReadCache, cfg.IndexCacheValidity, limits, logger, cfg.DisableBroadIndexQueries)
-
+	newChunkClient := func(s chunk.PeriodConfig, component string) (chunk.Client, error) {
 		objectStoreType := s.ObjectType
 		if objectStoreType == "" {
 			objectStoreType = s.IndexType
 		}
 
 		chunkClientReg := prometheus.WrapRegistererWith(
-			prometheus.Labels{"component": "chunk-store-" + s.From.String()}, reg)
+			prometheus.Labels{"component": "chunk-store-" + component}, reg)
 
 		chunks, err := NewChunkClient(objectStoreType, cfg, schemaCfg, clientMetrics, chunkClientReg)
 		if err != nil {
 			return nil, errors.Wrap(err, "error creating object client")
 		}
 
-		chunks = newMetricsChunkClient(chunks, chunkMetrics)
+		return newMetricsChunkClient(chunks, chunkMetrics), nil
+	}
+
+	indexClientMiddlewares := map[string]IndexClientMiddlewareFactoryFunc{
+		IndexClientMiddlewareCache: func(_ chunk.PeriodConfig, limits StoreLimits, _ prometheus.Registerer) (IndexClientMiddleware, error) {
+			return func(next chunk.IndexClient) chunk.IndexClient {
+				return newCachingIndexClient(next, indexReadCache, cfg.IndexCacheValidity, limits, logger, cfg.DisableBroadIndexQueries)
+			}, nil
+		},
+		IndexClientMiddlewareMetrics: func(_ chunk.PeriodConfig, _ StoreLimits, registerer prometheus.Registerer) (IndexClientMiddleware, error) {
+			return newInstrumentedIndexClientMiddleware(registerer), nil
+		},
+		IndexClientMiddlewareRateLimit: func(_ chunk.PeriodConfig, _ StoreLimits, _ prometheus.Registerer) (IndexClientMiddleware, error) {
+			return newRateLimitedIndexClientMiddleware(cfg.IndexQueriesRateLimit), nil
+		},
+	}
+
+	newClients := func(s chunk.PeriodConfig, component string) (chunk.IndexClient, chunk.Client, error) {
+		indexClientReg := prometheus.WrapRegistererWith(
+			prometheus.Labels{"component": "index-store-" + component}, reg)
+
+		index, err := NewIndexClient(s.IndexType, cfg, schemaCfg, limits, indexClientReg)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "error creating index client")
+		}
+		wrapped, err := wrapIndexClient(index, s, indexClientMiddlewares, limits, indexClientReg)
+		if err != nil {
+			index.Stop()
+			return nil, nil, err
+		}
+		index = wrapped
 
-		err = stores.AddPeriod(storeCfg, s, index, chunks, limits, chunksCache, writeDedupeCache)
+		chunks, err := newChunkClient(s, component)
 		if err != nil {
+			return nil, nil, err
+		}
+
+		return index, chunks, nil
+	}
+
+	newSeriesIndexClients := func(factory SeriesIndexFactoryFunc, s chunk.PeriodConfig, component string) (chunk.SeriesIndex, chunk.Client, error) {
+		indexReg := prometheus.WrapRegistererWith(
+			prometheus.Labels{"component": "index-store-" + component}, reg)
+
+		index, err := factory(s, component, limits, indexReg)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "error creating series index")
+		}
+
+		chunks, err := newChunkClient(s, component)
+		if err != nil {
+			index.Stop()
+			return nil, nil, err
+		}
+
+		return index, chunks, nil
+	}
+
+	addPeriod := func(s chunk.PeriodConfig) error {
+		if factory, ok := customSeriesIndexes[s.IndexType]; ok {
+			index, chunks, err := newSeriesIndexClients(factory, s, s.From.String())
+			if err != nil {
+				return err
+			}
+
+			return stores.AddSeriesIndexPeriod(storeCfg, s, index, chunks, limits, chunksCache)
+		}
+
+		index, chunks, err := newClients(s, s.From.String())
+		if err != nil {
+			return err
+		}
+
+		return stores.AddPeriod(storeCfg, s, index, chunks, limits, chunksCache, writeDedupeCache)
+	}
+
+	for _, s := range schemaCfg.Configs {
+		if err := addPeriod(s); err != nil {
 			return nil, err
 		}
 	}
 
-	return stores, nil
+	for userID, tenantCfg := range schemaCfg.TenantConfigs {
+		if tenantCfg == nil {
+			continue
+		}
+		for _, s := range tenantCfg.Configs {
+			component := s.From.String() + "-" + userID
+			if factory, ok := customSeriesIndexes[s.IndexType]; ok {
+				index, chunks, err := newSeriesIndexClients(factory, s, component)
+				if err != nil {
+					return nil, err
+				}
+
+				err = stores.AddTenantSeriesIndexPeriod(userID, storeCfg, s, index, chunks, limits, chunksCache)
+				if err != nil {
+					return nil, err
+				}
+				continue
+			}
+
+			index, chunks, err := newClients(s, component)
+			if err != nil {
+				return nil, err
+			}
+
+			err = stores.AddTenantPeriod(userID, storeCfg, s, index, chunks, limits, chunksCache, writeDedupeCache)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return &periodConfigAdderStore{CompositeStore: stores, addPeriod: addPeriod}, nil
+}
+
+// periodConfigAdderStore is the store returned by NewStore, the periods appended to the
+// schema config at runtime are added to it with AddPeriodConfig.
+type periodConfigAdderStore struct {
+	*chunk.CompositeStore
+	addPeriod func(chunk.PeriodConfig) error
+}
+
+// AddPeriodConfig implements chunk.PeriodConfigAdder
+func (s *periodConfigAdderStore) AddPeriodConfig(cfg chunk.PeriodConfig) error {
+	return s.addPeriod(cfg)
 }
 
 // NewIndexClient makes a new index client of the desired type.
@@ -285,6 +445,23 @@ func NewIndexClient(name string, cfg Config, schemaCfg chunk.SchemaConfig, limit
 
 // NewChunkClient makes a new chunk.Client of the desired types.
 func NewChunkClient(name string, cfg Config, schemaCfg chunk.SchemaConfig, clientMetrics ClientMetrics, registerer prometheus.Registerer) (chunk.Client, error) {
+	if (cfg.Mirror.Store != "" || cfg.ChunkEncryption.KeyProvider != "" || cfg.ObjectRateLimit.enabled()) && IsObjectStore(name) {
+		c, err := NewObjectClient(name, cfg, clientMetrics)
+		if err != nil {
+			return nil, err
+		}
+		c, err = WrapChunkObjectClient(c, cfg)
+		if err != nil {
+			c.Stop()
+			return nil, err
+		}
+		var encoder objectclient.KeyEncoder
+		if name == StorageTypeFileSystem {
+			encoder = objectclient.FSEncoder
+		}
+		return objectclient.NewClientWithMaxParallel(c, encoder, cfg.MaxParallelGetChunk, schemaCfg), nil
+	}
+
 	switch name {
 	case StorageTypeInMemory:
 		return chunk.NewMockStorage(), nil
@@ -325,6 +502,24 @@ func NewChunkClient(name string, cfg Config, schemaCfg chunk.SchemaConfig, clien
 			return nil, err
 		}
 		return objectclient.NewClientWithMaxParallel(c, nil, cfg.MaxParallelGetChunk, schemaCfg), nil
+	case StorageTypeAlibabaCloud:
+		c, err := alibaba.NewOssObjectClient(cfg.AlibabaCloudConfig, cfg.Hedging)
+		if err != nil {
+			return nil, err
+		}
+		return objectclient.NewClientWithMaxParallel(c, nil, cfg.MaxParallelGetChunk, schemaCfg), nil
+	case StorageTypeTencentCloud:
+		c, err := tencent.NewCosObjectClient(cfg.TencentCloudConfig, cfg.Hedging)
+		if err != nil {
+			return nil, err
+		}
+		return objectclient.NewClientWithMaxParallel(c, nil, cfg.MaxParallelGetChunk, schemaCfg), nil
+	case StorageTypeBOS:
+		c, err := baidubce.NewBOSObjectClient(cfg.BOSStorageConfig, cfg.Hedging)
+		if err != nil {
+			return nil, err
+		}
+		return objectclient.NewClientWithMaxParallel(c, nil, cfg.MaxParallelGetChunk, schemaCfg), nil
 	case StorageTypeCassandra:
 		return cassandra.NewObjectClient(cfg.CassandraStorageConfig, schemaCfg, registerer, cfg.MaxParallelGetChunk)
 	case StorageTypeFileSystem:
@@ -383,7 +578,102 @@ func NewBucketClient(storageConfig Config) (chunk.BucketClient, error) {
 }
 
 // NewObjectClient makes a new StorageClient of the desired types.
+// The objects are mirrored to the object store of the mirror config when it is set, and the operations
+// are limited by the rate limiter of the store, shared by all its clients, when the rate limits are set.
 func NewObjectClient(name string, cfg Config, clientMetrics ClientMetrics) (chunk.ObjectClient, error) {
+	client, err := newMirroredObjectClient(name, cfg, clientMetrics)
+	if err != nil || !cfg.ObjectRateLimit.enabled() {
+		return client, err
+	}
+	return NewRateLimitedObjectClient(client, sharedRateLimiter(name, cfg.ObjectRateLimit)), nil
+}
+
+func newMirroredObjectClient(name string, cfg Config, clientMetrics ClientMetrics) (chunk.ObjectClient, error) {
+	primary, err := newObjectClient(name, cfg, clientMetrics)
+	if err != nil || cfg.Mirror.Store == "" {
+		return primary, err
+	}
+	secondary, err := newObjectClient(cfg.Mirror.Store, cfg.Mirror.storageConfig(cfg), clientMetrics)
+	if err != nil {
+		primary.Stop()
+		return nil, errors.Wrap(err, "failed to create the mirror object client")
+	}
+	return NewMirroredObjectClient(primary, secondary, cfg.Mirror), nil
+}
+
+// WrapChunkObjectClient encrypts the chunks written to the object client when the encryption of the chunks is configured.
+func WrapChunkObjectClient(c chunk.ObjectClient, cfg Config) (chunk.ObjectClient, error) {
+	provider, err := encryption.NewKeyProvider(cfg.ChunkEncryption)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create the key provider of the chunk encryption")
+	}
+	if provider == nil {
+		return c, nil
+	}
+	return encryption.NewObjectClient(c, encryption.NewEnvelope(provider, cfg.ChunkEncryption.DataKeyRotationPeriod)), nil
+}
+
+// ObjectStoreBuckets returns the buckets the object store of the config holds the objects in: its buckets, its
+// container or its directory. It returns nil when the store isn't an object store or its bucket isn't configured.
+func (cfg *Config) ObjectStoreBuckets(name string) []string {
+	var buckets []string
+	switch name {
+	case StorageTypeAWS, StorageTypeS3:
+		s3Cfg := cfg.AWSStorageConfig.S3Config
+		if s3Cfg.BucketNames != "" {
+			for _, bucket := range strings.Split(s3Cfg.BucketNames, ",") {
+				buckets = append(buckets, strings.TrimSpace(bucket))
+			}
+		} else if s3Cfg.S3.URL != nil {
+			buckets = append(buckets, strings.TrimPrefix(s3Cfg.S3.URL.Path, "/"))
+		}
+	case StorageTypeGCS:
+		buckets = append(buckets, cfg.GCSConfig.BucketName)
+	case StorageTypeAzure:
+		// the containers of the storage accounts can have the same name.
+		if cfg.AzureStorageConfig.ContainerName != "" {
+			buckets = append(buckets, cfg.AzureStorageConfig.AccountName+"/"+cfg.AzureStorageConfig.ContainerName)
+		}
+	case StorageTypeSwift:
+		buckets = append(buckets, cfg.Swift.ContainerName)
+	case StorageTypeAlibabaCloud:
+		buckets = append(buckets, cfg.AlibabaCloudConfig.Bucket)
+	case StorageTypeTencentCloud:
+		buckets = append(buckets, cfg.TencentCloudConfig.Bucket)
+	case StorageTypeBOS:
+		buckets = append(buckets, cfg.BOSStorageConfig.Bucket)
+	case StorageTypeFileSystem:
+		buckets = append(buckets, cfg.FSConfig.Directory)
+	}
+
+	configured := buckets[:0]
+	for _, bucket := range buckets {
+		if bucket != "" {
+			configured = append(configured, bucket)
+		}
+	}
+	if len(configured) == 0 {
+		return nil
+	}
+	return configured
+}
+
+// MirrorBuckets returns the buckets of the secondary object store mirroring the primary ones.
+func (cfg *Config) MirrorBuckets() []string {
+	secondary := cfg.Mirror.storageConfig(*cfg)
+	return secondary.ObjectStoreBuckets(cfg.Mirror.Store)
+}
+
+// IsObjectStore returns whether the storage type is an object store supported by NewObjectClient.
+func IsObjectStore(name string) bool {
+	switch name {
+	case StorageTypeAWS, StorageTypeS3, StorageTypeGCS, StorageTypeAzure, StorageTypeSwift, StorageTypeAlibabaCloud, StorageTypeTencentCloud, StorageTypeBOS, StorageTypeFileSystem:
+		return true
+	}
+	return false
+}
+
+func newObjectClient(name string, cfg Config, clientMetrics ClientMetrics) (chunk.ObjectClient, error) {
 	switch name {
 	case StorageTypeAWS, StorageTypeS3:
 		return aws.NewS3ObjectClient(cfg.AWSStorageConfig.S3Config, cfg.Hedging)
@@ -393,11 +683,17 @@ func NewObjectClient(name string, cfg Config, clientMetrics ClientMetrics) (chun
 		return azure.NewBlobStorage(&cfg.AzureStorageConfig, clientMetrics.AzureMetrics, cfg.Hedging)
 	case StorageTypeSwift:
 		return openstack.NewSwiftObjectClient(cfg.Swift, cfg.Hedging)
+	case StorageTypeAlibabaCloud:
+		return alibaba.NewOssObjectClient(cfg.AlibabaCloudConfig, cfg.Hedging)
+	case StorageTypeTencentCloud:
+		return tencent.NewCosObjectClient(cfg.TencentCloudConfig, cfg.Hedging)
+	case StorageTypeBOS:
+		return baidubce.NewBOSObjectClient(cfg.BOSStorageConfig, cfg.Hedging)
 	case StorageTypeInMemory:
 		return chunk.NewMockStorage(), nil
 	case StorageTypeFileSystem:
 		return local.NewFSObjectClient(cfg.FSConfig)
 	default:
-		return nil, fmt.Errorf("Unrecognized storage client %v, choose one of: %v, %v, %v, %v, %v", name, StorageTypeAWS, StorageTypeS3, StorageTypeGCS, StorageTypeAzure, StorageTypeFileSystem)
+		return nil, fmt.Errorf("Unrecognized storage client %v, choose one of: %v, %v, %v, %v, %v, %v, %v, %v", name, StorageTypeAWS, StorageTypeS3, StorageTypeGCS, StorageTypeAzure, StorageTypeAlibabaCloud, StorageTypeTencentCloud, StorageTypeBOS, StorageTypeFileSystem)
 	}
 }
@@ -1,7 +1,11 @@
 package storage
 
 import (
+	"context"
+	"crypto/rand"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 	"time"
@@ -13,7 +17,9 @@ import (
 
 	"github.com/grafana/loki/pkg/storage/chunk"
 	"github.com/grafana/loki/pkg/storage/chunk/cassandra"
+	"github.com/grafana/loki/pkg/storage/chunk/encryption"
 	"github.com/grafana/loki/pkg/storage/chunk/local"
+	"github.com/grafana/loki/pkg/storage/chunk/testutils"
 	"github.com/grafana/loki/pkg/validation"
 )
 
@@ -194,6 +200,50 @@ func TestCassandraInMultipleSchemas(t *testing.T) {
 	store.Stop()
 }
 
+func TestNewChunkClient_Encryption(t *testing.T) {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	keyFile := filepath.Join(t.TempDir(), "key")
+	require.NoError(t, ioutil.WriteFile(keyFile, key, 0600))
+
+	cfg := Config{
+		FSConfig: local.FSConfig{Directory: t.TempDir()},
+		ChunkEncryption: encryption.Config{
+			KeyProvider:           encryption.KeyProviderFile,
+			KeyFile:               keyFile,
+			DataKeyRotationPeriod: time.Hour,
+		},
+	}
+	schemaCfg := chunk.SchemaConfig{Configs: []chunk.PeriodConfig{{From: chunk.DayTime{Time: 0}, Schema: "v11", RowShards: 16}}}
+	client, err := NewChunkClient(StorageTypeFileSystem, cfg, schemaCfg, ClientMetrics{}, nil)
+	require.NoError(t, err)
+	defer client.Stop()
+
+	_, chunks, err := testutils.CreateChunks(schemaCfg, 0, 5, model.Now().Add(-time.Hour), model.Now())
+	require.NoError(t, err)
+	require.NoError(t, client.PutChunks(context.Background(), chunks))
+
+	// the chunks are encrypted on disk, and decrypted when read.
+	err = filepath.Walk(cfg.FSConfig.Directory, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		buf, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		require.True(t, encryption.IsEncrypted(buf), path)
+		return nil
+	})
+	require.NoError(t, err)
+
+	fetched, err := client.GetChunks(context.Background(), chunks)
+	require.NoError(t, err)
+	require.Len(t, fetched, len(chunks))
+	for i := range chunks {
+		require.Equal(t, schemaCfg.ExternalKey(chunks[i]), schemaCfg.ExternalKey(fetched[i]))
+	}
+}
+
 // useful for cleaning up state after tests
 func unregisterAllCustomIndexStores() {
 	customIndexStores = map[string]indexStoreFactories{}
@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/services"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/loki/pkg/storage/chunk"
+)
+
+// Kinds of the storage backends probed by the health checker.
+const (
+	BackendKindIndex  = "index"
+	BackendKindObject = "object"
+)
+
+// HealthCheckConfig configures the health probes of the storage backends.
+type HealthCheckConfig struct {
+	Enabled       bool          `yaml:"enabled"`
+	Interval      time.Duration `yaml:"interval"`
+	Timeout       time.Duration `yaml:"timeout"`
+	CanaryKey     string        `yaml:"canary_key"`
+	FailReadiness bool          `yaml:"fail_readiness"`
+}
+
+// RegisterFlags registers flags.
+func (cfg *HealthCheckConfig) RegisterFlags(f *flag.FlagSet) {
+	prefix := "store.health-check."
+	f.BoolVar(&cfg.Enabled, prefix+"enabled", false, "Probe the index and object stores of the schema periodically, to tell whether their credentials or connectivity are broken.")
+	f.DurationVar(&cfg.Interval, prefix+"interval", time.Minute, "Interval between the probes of the storage backends.")
+	f.DurationVar(&cfg.Timeout, prefix+"timeout", 10*time.Second, "Timeout of each probe of a storage backend.")
+	f.StringVar(&cfg.CanaryKey, prefix+"canary-key", "loki-health-canary", "Key of the canary object listed in the object stores by the probes. The object doesn't need to exist.")
+	f.BoolVar(&cfg.FailReadiness, prefix+"fail-readiness", true, "Fail the readiness of the instance when a storage backend is unhealthy, so that the load balancers stop sending requests to it.")
+}
+
+// Validate validates the config.
+func (cfg *HealthCheckConfig) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.Interval <= 0 || cfg.Timeout <= 0 {
+		return errors.New("the interval and the timeout of the probes must be positive")
+	}
+	return nil
+}
+
+// HealthProbe checks the health of a storage backend.
+type HealthProbe struct {
+	Kind  string
+	Store string
+	Check func(ctx context.Context) error
+}
+
+func (p HealthProbe) name() string {
+	return p.Kind + "/" + p.Store
+}
+
+// NewObjectStoreProbe probes the object store by listing the canary key, which fails with expired
+// credentials even when the object doesn't exist.
+func NewObjectStoreProbe(store string, client chunk.ObjectClient, canaryKey string) HealthProbe {
+	return HealthProbe{Kind: BackendKindObject, Store: store, Check: func(ctx context.Context) error {
+		_, _, err := client.List(ctx, canaryKey, "")
+		return err
+	}}
+}
+
+// NewIndexStoreProbe probes the index store by listing its tables.
+func NewIndexStoreProbe(store string, client chunk.TableClient) HealthProbe {
+	return HealthProbe{Kind: BackendKindIndex, Store: store, Check: func(ctx context.Context) error {
+		_, err := client.ListTables(ctx)
+		return err
+	}}
+}
+
+// HealthChecker probes the storage backends periodically.
+type HealthChecker struct {
+	services.Service
+
+	cfg    HealthCheckConfig
+	probes []HealthProbe
+	logger log.Logger
+
+	healthy *prometheus.GaugeVec
+
+	mtx    sync.RWMutex
+	errors map[string]error
+}
+
+// NewHealthChecker makes a health checker running the probes.
+func NewHealthChecker(cfg HealthCheckConfig, probes []HealthProbe, logger log.Logger, registerer prometheus.Registerer) *HealthChecker {
+	c := &HealthChecker{
+		cfg:    cfg,
+		probes: probes,
+		logger: logger,
+		healthy: promauto.With(registerer).NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "loki",
+			Name:      "storage_backend_healthy",
+			Help:      "Whether the last probe of the storage backend succeeded.",
+		}, []string{"kind", "store"}),
+		errors: map[string]error{},
+	}
+	c.Service = services.NewTimerService(cfg.Interval, c.iteration, c.iteration, nil)
+	return c
+}
+
+func (c *HealthChecker) iteration(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for _, p := range c.probes {
+		wg.Add(1)
+		go func(p HealthProbe) {
+			defer wg.Done()
+			c.probe(ctx, p)
+		}(p)
+	}
+	wg.Wait()
+	// don't return an error, otherwise the timer service would stop.
+	return nil
+}
+
+func (c *HealthChecker) probe(ctx context.Context, p HealthProbe) {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	defer cancel()
+	err := p.Check(ctx)
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if err != nil {
+		c.healthy.WithLabelValues(p.Kind, p.Store).Set(0)
+		if c.errors[p.name()] == nil {
+			level.Warn(c.logger).Log("msg", "storage backend is unhealthy", "kind", p.Kind, "store", p.Store, "err", err)
+		}
+		c.errors[p.name()] = err
+		return
+	}
+	c.healthy.WithLabelValues(p.Kind, p.Store).Set(1)
+	if c.errors[p.name()] != nil {
+		level.Info(c.logger).Log("msg", "storage backend is healthy again", "kind", p.Kind, "store", p.Store)
+	}
+	delete(c.errors, p.name())
+}
+
+// CheckReady fails when the last probe of any storage backend failed, unless the readiness isn't
+// tied to the health of the storage backends.
+func (c *HealthChecker) CheckReady(_ context.Context) error {
+	if !c.cfg.FailReadiness {
+		return nil
+	}
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	if len(c.errors) == 0 {
+		return nil
+	}
+	failures := make([]string, 0, len(c.errors))
+	for name, err := range c.errors {
+		failures = append(failures, fmt.Sprintf("%s: %v", name, err))
+	}
+	sort.Strings(failures)
+	return fmt.Errorf("unhealthy storage backends: %s", strings.Join(failures, "; "))
+}
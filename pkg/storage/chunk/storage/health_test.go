@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/pkg/storage/chunk"
+)
+
+func TestHealthChecker(t *testing.T) {
+	objectClient := &failingObjectClient{MockStorage: chunk.NewMockStorage()}
+	reg := prometheus.NewPedanticRegistry()
+	cfg := HealthCheckConfig{Enabled: true, Interval: time.Minute, Timeout: time.Second, CanaryKey: "canary", FailReadiness: true}
+	checker := NewHealthChecker(cfg, []HealthProbe{
+		NewIndexStoreProbe("inmemory", chunk.NewMockStorage()),
+		NewObjectStoreProbe("s3", objectClient, cfg.CanaryKey),
+	}, log.NewNopLogger(), reg)
+
+	ctx := context.Background()
+	require.NoError(t, checker.iteration(ctx))
+	require.NoError(t, checker.CheckReady(ctx))
+
+	objectClient.err = errors.New("expired token")
+	require.NoError(t, checker.iteration(ctx))
+	require.EqualError(t, checker.CheckReady(ctx), "unhealthy storage backends: object/s3: expired token")
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP loki_storage_backend_healthy Whether the last probe of the storage backend succeeded.
+		# TYPE loki_storage_backend_healthy gauge
+		loki_storage_backend_healthy{kind="index",store="inmemory"} 1
+		loki_storage_backend_healthy{kind="object",store="s3"} 0
+	`), "loki_storage_backend_healthy"))
+
+	// the readiness isn't tied to the storage backends when disabled.
+	checker.cfg.FailReadiness = false
+	require.NoError(t, checker.CheckReady(ctx))
+	checker.cfg.FailReadiness = true
+
+	objectClient.err = nil
+	require.NoError(t, checker.iteration(ctx))
+	require.NoError(t, checker.CheckReady(ctx))
+}
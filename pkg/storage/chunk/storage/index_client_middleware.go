@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/weaveworks/common/instrument"
+	"golang.org/x/time/rate"
+
+	"github.com/grafana/loki/pkg/storage/chunk"
+)
+
+// Built-in index client middlewares.
+const (
+	// IndexClientMiddlewareCache caches the results of the index queries in the index read cache.
+	IndexClientMiddlewareCache = "cache"
+	// IndexClientMiddlewareMetrics measures the duration of the index requests.
+	IndexClientMiddlewareMetrics = "metrics"
+	// IndexClientMiddlewareRateLimit limits the rate of the index queries to -store.index-queries-rate-limit.
+	IndexClientMiddlewareRateLimit = "rate_limit"
+)
+
+// DefaultIndexClientMiddlewares are the middlewares wrapping the index client of the periods
+// which don't set their own.
+var DefaultIndexClientMiddlewares = []string{IndexClientMiddlewareCache}
+
+// IndexClientMiddleware wraps an index client.
+type IndexClientMiddleware func(next chunk.IndexClient) chunk.IndexClient
+
+// IndexClientMiddlewareFactoryFunc defines signature of function which creates the IndexClientMiddleware of a period.
+type IndexClientMiddlewareFactoryFunc func(period chunk.PeriodConfig, limits StoreLimits, registerer prometheus.Registerer) (IndexClientMiddleware, error)
+
+var customIndexClientMiddlewares = map[string]IndexClientMiddlewareFactoryFunc{}
+
+// RegisterIndexClientMiddleware is used for registering a custom index client middleware, which the periods
+// can then list in their index_client_middlewares.
+// When a middleware is registered here with same name as a built-in one, the registered one takes the precedence.
+func RegisterIndexClientMiddleware(name string, factory IndexClientMiddlewareFactoryFunc) {
+	customIndexClientMiddlewares[name] = factory
+}
+
+// wrapIndexClient wraps the index client of the period with its middlewares, the first middleware
+// being the outermost one.
+func wrapIndexClient(client chunk.IndexClient, period chunk.PeriodConfig, builtins map[string]IndexClientMiddlewareFactoryFunc, limits StoreLimits, registerer prometheus.Registerer) (chunk.IndexClient, error) {
+	names := period.IndexClientMiddlewares
+	if names == nil {
+		names = DefaultIndexClientMiddlewares
+	}
+
+	middlewares := make([]IndexClientMiddleware, 0, len(names))
+	for _, name := range names {
+		factory, ok := customIndexClientMiddlewares[name]
+		if !ok {
+			factory, ok = builtins[name]
+		}
+		if !ok {
+			return nil, fmt.Errorf("unrecognized index client middleware %s, choose one of: %s, %s, %s", name, IndexClientMiddlewareCache, IndexClientMiddlewareMetrics, IndexClientMiddlewareRateLimit)
+		}
+		middleware, err := factory(period, limits, registerer)
+		if err != nil {
+			return nil, fmt.Errorf("error creating index client middleware %s: %w", name, err)
+		}
+		middlewares = append(middlewares, middleware)
+	}
+
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		client = middlewares[i](client)
+	}
+	return client, nil
+}
+
+// instrumentedIndexClient records the duration of the requests of the index client it wraps.
+type instrumentedIndexClient struct {
+	chunk.IndexClient
+	requestDuration *instrument.HistogramCollector
+}
+
+func newInstrumentedIndexClientMiddleware(registerer prometheus.Registerer) IndexClientMiddleware {
+	requestDuration := instrument.NewHistogramCollector(promauto.With(registerer).NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "loki",
+		Name:      "index_client_request_duration_seconds",
+		Help:      "Time spent doing index client requests.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 8),
+	}, []string{"operation", "status_code"}))
+
+	return func(next chunk.IndexClient) chunk.IndexClient {
+		return &instrumentedIndexClient{
+			IndexClient:     next,
+			requestDuration: requestDuration,
+		}
+	}
+}
+
+func (c *instrumentedIndexClient) BatchWrite(ctx context.Context, batch chunk.WriteBatch) error {
+	return instrument.CollectedRequest(ctx, "BatchWrite", c.requestDuration, instrument.ErrorCode, func(ctx context.Context) error {
+		return c.IndexClient.BatchWrite(ctx, batch)
+	})
+}
+
+func (c *instrumentedIndexClient) QueryPages(ctx context.Context, queries []chunk.IndexQuery, callback chunk.QueryPagesCallback) error {
+	return instrument.CollectedRequest(ctx, "QueryPages", c.requestDuration, instrument.ErrorCode, func(ctx context.Context) error {
+		return c.IndexClient.QueryPages(ctx, queries, callback)
+	})
+}
+
+// rateLimitedIndexClient limits the rate of the queries of the index client it wraps.
+type rateLimitedIndexClient struct {
+	chunk.IndexClient
+	limiter *rate.Limiter
+}
+
+func newRateLimitedIndexClientMiddleware(limit float64) IndexClientMiddleware {
+	burst := int(limit)
+	if burst < 1 {
+		burst = 1
+	}
+	return func(next chunk.IndexClient) chunk.IndexClient {
+		if limit <= 0 {
+			return next
+		}
+		return &rateLimitedIndexClient{
+			IndexClient: next,
+			limiter:     rate.NewLimiter(rate.Limit(limit), burst),
+		}
+	}
+}
+
+func (c *rateLimitedIndexClient) QueryPages(ctx context.Context, queries []chunk.IndexQuery, callback chunk.QueryPagesCallback) error {
+	if err := c.limiter.Wait(ctx); err != nil {
+		return err
+	}
+	return c.IndexClient.QueryPages(ctx, queries, callback)
+}
@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/flagext"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/pkg/storage/chunk"
+	"github.com/grafana/loki/pkg/validation"
+)
+
+// recordingIndexClient records the name of the middleware in the calls of QueryPages going through it.
+type recordingIndexClient struct {
+	chunk.IndexClient
+	name  string
+	calls *[]string
+}
+
+func (c *recordingIndexClient) QueryPages(ctx context.Context, queries []chunk.IndexQuery, callback chunk.QueryPagesCallback) error {
+	*c.calls = append(*c.calls, c.name)
+	return c.IndexClient.QueryPages(ctx, queries, callback)
+}
+
+func recordingMiddleware(name string, calls *[]string) IndexClientMiddlewareFactoryFunc {
+	return func(_ chunk.PeriodConfig, _ StoreLimits, _ prometheus.Registerer) (IndexClientMiddleware, error) {
+		return func(next chunk.IndexClient) chunk.IndexClient {
+			return &recordingIndexClient{IndexClient: next, name: name, calls: calls}
+		}, nil
+	}
+}
+
+func TestWrapIndexClient(t *testing.T) {
+	defer func() {
+		customIndexClientMiddlewares = map[string]IndexClientMiddlewareFactoryFunc{}
+	}()
+
+	var calls []string
+	RegisterIndexClientMiddleware("a", recordingMiddleware("a", &calls))
+	RegisterIndexClientMiddleware("b", recordingMiddleware("b", &calls))
+	// registered middlewares take precedence over the built-in ones.
+	RegisterIndexClientMiddleware(IndexClientMiddlewareMetrics, recordingMiddleware("custom metrics", &calls))
+	builtins := map[string]IndexClientMiddlewareFactoryFunc{
+		IndexClientMiddlewareCache:   recordingMiddleware(IndexClientMiddlewareCache, &calls),
+		IndexClientMiddlewareMetrics: recordingMiddleware(IndexClientMiddlewareMetrics, &calls),
+	}
+
+	for _, tc := range []struct {
+		name          string
+		middlewares   []string
+		expectedCalls []string
+		expectedErr   bool
+	}{
+		{
+			name:          "defaults",
+			expectedCalls: []string{IndexClientMiddlewareCache},
+		},
+		{
+			name:        "disabled",
+			middlewares: []string{},
+		},
+		{
+			name:          "ordered",
+			middlewares:   []string{"b", IndexClientMiddlewareCache, "a"},
+			expectedCalls: []string{"b", IndexClientMiddlewareCache, "a"},
+		},
+		{
+			name:          "overridden built-in",
+			middlewares:   []string{IndexClientMiddlewareMetrics},
+			expectedCalls: []string{"custom metrics"},
+		},
+		{
+			name:        "unknown",
+			middlewares: []string{"a", "c"},
+			expectedErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			calls = nil
+			period := chunk.PeriodConfig{IndexType: "inmemory", IndexClientMiddlewares: tc.middlewares}
+
+			client, err := wrapIndexClient(chunk.NewMockStorage(), period, builtins, nil, nil)
+			if tc.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			require.NoError(t, client.QueryPages(context.Background(), nil, nil))
+			require.Equal(t, tc.expectedCalls, calls)
+		})
+	}
+}
+
+func TestNewStoreUnknownIndexClientMiddleware(t *testing.T) {
+	var (
+		cfg          Config
+		storeConfig  chunk.StoreConfig
+		schemaConfig chunk.SchemaConfig
+		defaults     validation.Limits
+	)
+	flagext.DefaultValues(&cfg, &storeConfig, &schemaConfig, &defaults)
+	schemaConfig.Configs = []chunk.PeriodConfig{
+		{
+			From:                   chunk.DayTime{Time: model.Time(0)},
+			IndexType:              "inmemory",
+			Schema:                 "v11",
+			RowShards:              16,
+			IndexClientMiddlewares: []string{IndexClientMiddlewareMetrics, "unknown"},
+		},
+	}
+
+	limits, err := validation.NewOverrides(defaults, nil)
+	require.NoError(t, err)
+	_, err = NewStore(cfg, storeConfig, schemaConfig, limits, ClientMetrics{}, nil, nil, log.NewNopLogger())
+	require.EqualError(t, err, "unrecognized index client middleware unknown, choose one of: cache, metrics, rate_limit")
+}
+
+func TestRateLimitedIndexClient(t *testing.T) {
+	client := newRateLimitedIndexClientMiddleware(1)(chunk.NewMockStorage())
+	require.NoError(t, client.QueryPages(context.Background(), nil, nil))
+
+	// the next query has to wait for a second.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.Error(t, client.QueryPages(ctx, nil, nil))
+
+	// no limit.
+	mock := chunk.NewMockStorage()
+	require.Equal(t, chunk.IndexClient(mock), newRateLimitedIndexClientMiddleware(0)(mock))
+}
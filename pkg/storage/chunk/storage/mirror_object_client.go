@@ -0,0 +1,352 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/loki/pkg/storage/chunk"
+	"github.com/grafana/loki/pkg/storage/chunk/alibaba"
+	"github.com/grafana/loki/pkg/storage/chunk/aws"
+	"github.com/grafana/loki/pkg/storage/chunk/azure"
+	"github.com/grafana/loki/pkg/storage/chunk/baidubce"
+	"github.com/grafana/loki/pkg/storage/chunk/gcp"
+	"github.com/grafana/loki/pkg/storage/chunk/local"
+	"github.com/grafana/loki/pkg/storage/chunk/openstack"
+	"github.com/grafana/loki/pkg/storage/chunk/tencent"
+	util_log "github.com/grafana/loki/pkg/util/log"
+)
+
+// Supported mirroring modes
+const (
+	MirrorModeSync  = "sync"
+	MirrorModeAsync = "async"
+)
+
+var (
+	mirrorWrites = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "loki",
+		Name:      "object_store_mirror_writes_total",
+		Help:      "Total number of writes and deletes mirrored to the secondary object store, by status.",
+	}, []string{"operation", "status"})
+	mirrorQueueLength = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "loki",
+		Name:      "object_store_mirror_queue_length",
+		Help:      "Number of writes and deletes waiting to be replicated to the secondary object store.",
+	})
+	mirrorFailovers = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "loki",
+		Name:      "object_store_mirror_failovers_total",
+		Help:      "Total number of reads served by the secondary object store after the primary one failed.",
+	}, []string{"operation"})
+	mirrorReplicationLag = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "loki",
+		Name:      "object_store_mirror_replication_lag_seconds",
+		Help:      "Time between the write or the delete acknowledged by the primary object store and its replication to the secondary one in async mode.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 4, 10),
+	})
+)
+
+// MirrorConfig configures a secondary object store mirroring the objects written to the primary object stores.
+type MirrorConfig struct {
+	Store             string `yaml:"store"`
+	Mode              string `yaml:"mode"`
+	FailoverReads     bool   `yaml:"failover_reads"`
+	ReadFromSecondary bool   `yaml:"read_from_secondary"`
+	AsyncQueueSize    int    `yaml:"async_queue_size"`
+	AsyncConcurrency  int    `yaml:"async_concurrency"`
+
+	S3           aws.S3Config              `yaml:"s3"`
+	GCS          gcp.GCSConfig             `yaml:"gcs"`
+	Azure        azure.BlobStorageConfig   `yaml:"azure"`
+	Swift        openstack.SwiftConfig     `yaml:"swift"`
+	AlibabaCloud alibaba.OssConfig         `yaml:"alibabacloud"`
+	TencentCloud tencent.CosConfig         `yaml:"tencentcloud"`
+	BOS          baidubce.BOSStorageConfig `yaml:"bos"`
+	FSConfig     local.FSConfig            `yaml:"filesystem"`
+}
+
+// RegisterFlags registers flags.
+func (cfg *MirrorConfig) RegisterFlags(f *flag.FlagSet) {
+	prefix := "store.mirror."
+	f.StringVar(&cfg.Store, prefix+"store", "", fmt.Sprintf("Object store mirroring the objects written to the primary object stores, one of: %v, %v, %v, %v, %v, %v, %v, %v, %v. Empty to disable mirroring.", StorageTypeS3, StorageTypeAWS, StorageTypeGCS, StorageTypeAzure, StorageTypeSwift, StorageTypeAlibabaCloud, StorageTypeTencentCloud, StorageTypeBOS, StorageTypeFileSystem))
+	f.StringVar(&cfg.Mode, prefix+"mode", MirrorModeSync, "How the objects are mirrored: sync writes them to both stores before acknowledging the write, async replicates them in the background after the primary store acknowledged the write.")
+	f.BoolVar(&cfg.FailoverReads, prefix+"failover-reads", true, "Read from the secondary object store when the primary one returns an error other than object not found.")
+	f.BoolVar(&cfg.ReadFromSecondary, prefix+"read-from-secondary", false, "Read from the secondary object store first, and fail the reads over to the primary one. Used to move the reads to the secondary object store while the primary one is unavailable, or by a passive cluster reading the objects replicated by the active one.")
+	f.IntVar(&cfg.AsyncQueueSize, prefix+"async-queue-size", 1000, "Maximum number of writes waiting to be replicated in async mode. The writes are dropped from the replication when the queue is full.")
+	f.IntVar(&cfg.AsyncConcurrency, prefix+"async-concurrency", 4, "Number of concurrent replications to the secondary object store in async mode.")
+
+	cfg.S3.RegisterFlagsWithPrefix(prefix, f)
+	cfg.GCS.RegisterFlagsWithPrefix(prefix, f)
+	cfg.Azure.RegisterFlagsWithPrefix(prefix, f)
+	cfg.Swift.RegisterFlagsWithPrefix(prefix, f)
+	cfg.AlibabaCloud.RegisterFlagsWithPrefix(prefix, f)
+	cfg.TencentCloud.RegisterFlagsWithPrefix(prefix, f)
+	cfg.BOS.RegisterFlagsWithPrefix(prefix, f)
+	cfg.FSConfig.RegisterFlagsWithPrefix(prefix, f)
+}
+
+// Validate validates the config.
+func (cfg *MirrorConfig) Validate() error {
+	if cfg.Store == "" {
+		return nil
+	}
+	if cfg.Mode != MirrorModeSync && cfg.Mode != MirrorModeAsync {
+		return fmt.Errorf("unsupported mirror mode %q, choose one of: %v, %v", cfg.Mode, MirrorModeSync, MirrorModeAsync)
+	}
+	if cfg.Mode == MirrorModeAsync && (cfg.AsyncQueueSize <= 0 || cfg.AsyncConcurrency <= 0) {
+		return errors.New("the queue size and the concurrency of the async mirror must be positive")
+	}
+	if cfg.Store == StorageTypeS3 || cfg.Store == StorageTypeAWS {
+		return cfg.S3.Validate()
+	}
+	return nil
+}
+
+// storageConfig returns the storage config of the secondary object store.
+func (cfg *MirrorConfig) storageConfig(primary Config) Config {
+	secondary := primary
+	secondary.AWSStorageConfig.S3Config = cfg.S3
+	secondary.GCSConfig = cfg.GCS
+	secondary.AzureStorageConfig = cfg.Azure
+	secondary.Swift = cfg.Swift
+	secondary.AlibabaCloudConfig = cfg.AlibabaCloud
+	secondary.TencentCloudConfig = cfg.TencentCloud
+	secondary.BOSStorageConfig = cfg.BOS
+	secondary.FSConfig = cfg.FSConfig
+	secondary.Mirror = MirrorConfig{}
+	return secondary
+}
+
+type mirrorOp struct {
+	key    string
+	object []byte
+	delete bool
+	// acknowledged is the time the write or the delete was acknowledged by the primary object store.
+	acknowledged time.Time
+}
+
+// MirroredObjectClient writes the objects to a primary and a secondary object store, and reads them
+// from the secondary one when the primary one fails, or the other way around when reading from the secondary one.
+type MirroredObjectClient struct {
+	primary   chunk.ObjectClient
+	secondary chunk.ObjectClient
+	cfg       MirrorConfig
+
+	queue chan mirrorOp
+	wg    sync.WaitGroup
+}
+
+// NewMirroredObjectClient makes a new object client mirroring the primary object client to the secondary one.
+// When the object client read first can read ranges of objects, so does the returned one.
+func NewMirroredObjectClient(primary, secondary chunk.ObjectClient, cfg MirrorConfig) chunk.ObjectClient {
+	c := &MirroredObjectClient{
+		primary:   primary,
+		secondary: secondary,
+		cfg:       cfg,
+	}
+	if cfg.Mode == MirrorModeAsync {
+		c.queue = make(chan mirrorOp, cfg.AsyncQueueSize)
+		for i := 0; i < cfg.AsyncConcurrency; i++ {
+			c.wg.Add(1)
+			go c.replicate()
+		}
+	}
+	if _, ok := c.reader().(chunk.RangeObjectClient); ok {
+		return &mirroredRangeObjectClient{c}
+	}
+	return c
+}
+
+func (c *MirroredObjectClient) replicate() {
+	defer c.wg.Done()
+	for op := range c.queue {
+		mirrorQueueLength.Dec()
+		var err error
+		if op.delete {
+			err = c.mirrorDelete(context.Background(), op.key)
+		} else {
+			err = c.mirrorPut(context.Background(), op.key, bytes.NewReader(op.object))
+		}
+		if err == nil {
+			mirrorReplicationLag.Observe(time.Since(op.acknowledged).Seconds())
+		}
+	}
+}
+
+func (c *MirroredObjectClient) enqueue(op mirrorOp) {
+	operation := "put"
+	if op.delete {
+		operation = "delete"
+	}
+	select {
+	case c.queue <- op:
+		mirrorQueueLength.Inc()
+	default:
+		mirrorWrites.WithLabelValues(operation, "dropped").Inc()
+		level.Warn(util_log.Logger).Log("msg", "mirror queue is full, the object isn't replicated to the secondary object store", "operation", operation, "key", op.key)
+	}
+}
+
+func (c *MirroredObjectClient) mirrorPut(ctx context.Context, objectKey string, object io.ReadSeeker) error {
+	if err := c.secondary.PutObject(ctx, objectKey, object); err != nil {
+		mirrorWrites.WithLabelValues("put", "failure").Inc()
+		level.Warn(util_log.Logger).Log("msg", "failed to mirror object to the secondary object store", "key", objectKey, "err", err)
+		return errors.Wrap(err, "failed to mirror object to the secondary object store")
+	}
+	mirrorWrites.WithLabelValues("put", "success").Inc()
+	return nil
+}
+
+func (c *MirroredObjectClient) mirrorDelete(ctx context.Context, objectKey string) error {
+	if err := c.secondary.DeleteObject(ctx, objectKey); err != nil && !c.secondary.IsObjectNotFoundErr(err) {
+		mirrorWrites.WithLabelValues("delete", "failure").Inc()
+		level.Warn(util_log.Logger).Log("msg", "failed to delete object from the secondary object store", "key", objectKey, "err", err)
+		return errors.Wrap(err, "failed to delete object from the secondary object store")
+	}
+	mirrorWrites.WithLabelValues("delete", "success").Inc()
+	return nil
+}
+
+// PutObject implements chunk.ObjectClient.
+func (c *MirroredObjectClient) PutObject(ctx context.Context, objectKey string, object io.ReadSeeker) error {
+	if err := c.primary.PutObject(ctx, objectKey, object); err != nil {
+		return err
+	}
+	if _, err := object.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	if c.cfg.Mode == MirrorModeAsync {
+		// the object is copied as the caller may reuse it once the write is acknowledged.
+		buf, err := ioutil.ReadAll(object)
+		if err != nil {
+			return err
+		}
+		c.enqueue(mirrorOp{key: objectKey, object: buf, acknowledged: time.Now()})
+		return nil
+	}
+	return c.mirrorPut(ctx, objectKey, object)
+}
+
+// GetObject implements chunk.ObjectClient.
+func (c *MirroredObjectClient) GetObject(ctx context.Context, objectKey string) (io.ReadCloser, int64, error) {
+	reader, size, err := c.reader().GetObject(ctx, objectKey)
+	if err == nil || !c.failover(err) {
+		return reader, size, err
+	}
+	mirrorFailovers.WithLabelValues("get").Inc()
+	level.Warn(util_log.Logger).Log("msg", "failed to read object, reading it from the failover object store", "key", objectKey, "failover", c.failoverName(), "err", err)
+	return c.failoverReader().GetObject(ctx, objectKey)
+}
+
+// List implements chunk.ObjectClient.
+func (c *MirroredObjectClient) List(ctx context.Context, prefix string, delimiter string) ([]chunk.StorageObject, []chunk.StorageCommonPrefix, error) {
+	objects, prefixes, err := c.reader().List(ctx, prefix, delimiter)
+	if err == nil || !c.failover(err) {
+		return objects, prefixes, err
+	}
+	mirrorFailovers.WithLabelValues("list").Inc()
+	level.Warn(util_log.Logger).Log("msg", "failed to list objects, listing the failover object store", "prefix", prefix, "failover", c.failoverName(), "err", err)
+	return c.failoverReader().List(ctx, prefix, delimiter)
+}
+
+// DeleteObject implements chunk.ObjectClient.
+func (c *MirroredObjectClient) DeleteObject(ctx context.Context, objectKey string) error {
+	if err := c.primary.DeleteObject(ctx, objectKey); err != nil {
+		return err
+	}
+	if c.cfg.Mode == MirrorModeAsync {
+		c.enqueue(mirrorOp{key: objectKey, delete: true, acknowledged: time.Now()})
+		return nil
+	}
+	return c.mirrorDelete(ctx, objectKey)
+}
+
+// IsObjectNotFoundErr implements chunk.ObjectClient.
+func (c *MirroredObjectClient) IsObjectNotFoundErr(err error) bool {
+	return c.primary.IsObjectNotFoundErr(err) || c.secondary.IsObjectNotFoundErr(err)
+}
+
+// Stop implements chunk.ObjectClient, after the pending replications are done.
+func (c *MirroredObjectClient) Stop() {
+	if c.queue != nil {
+		close(c.queue)
+		c.wg.Wait()
+	}
+	c.primary.Stop()
+	c.secondary.Stop()
+}
+
+// reader returns the object store read first, the primary one unless reading from the secondary one.
+func (c *MirroredObjectClient) reader() chunk.ObjectClient {
+	if c.cfg.ReadFromSecondary {
+		return c.secondary
+	}
+	return c.primary
+}
+
+// failoverReader returns the object store the failing reads fail over to.
+func (c *MirroredObjectClient) failoverReader() chunk.ObjectClient {
+	if c.cfg.ReadFromSecondary {
+		return c.primary
+	}
+	return c.secondary
+}
+
+func (c *MirroredObjectClient) failoverName() string {
+	if c.cfg.ReadFromSecondary {
+		return "primary"
+	}
+	return "secondary"
+}
+
+// failover returns whether a read failing in the object store read first is read from the failover one.
+// The objects not found aren't looked up: they are written to the primary object store first, and the
+// objects not replicated yet are expected to be missing from the secondary one.
+func (c *MirroredObjectClient) failover(err error) bool {
+	return c.cfg.FailoverReads && !c.reader().IsObjectNotFoundErr(err) && !errors.Is(err, context.Canceled)
+}
+
+// mirroredRangeObjectClient is a MirroredObjectClient whose object client read first can read ranges of objects.
+type mirroredRangeObjectClient struct {
+	*MirroredObjectClient
+}
+
+// GetObjectRange implements chunk.RangeObjectClient.
+func (c *mirroredRangeObjectClient) GetObjectRange(ctx context.Context, objectKey string, offset, length int64) (io.ReadCloser, error) {
+	reader, err := c.reader().(chunk.RangeObjectClient).GetObjectRange(ctx, objectKey, offset, length)
+	if err == nil || !c.failover(err) {
+		return reader, err
+	}
+	mirrorFailovers.WithLabelValues("get_range").Inc()
+	level.Warn(util_log.Logger).Log("msg", "failed to read object range, reading it from the failover object store", "key", objectKey, "failover", c.failoverName(), "err", err)
+	failover := c.failoverReader()
+	if rangeClient, ok := failover.(chunk.RangeObjectClient); ok {
+		return rangeClient.GetObjectRange(ctx, objectKey, offset, length)
+	}
+
+	// the failover object store reads the whole object and skips to the range.
+	reader, _, err = failover.GetObject(ctx, objectKey)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.CopyN(ioutil.Discard, reader, offset); err != nil {
+		_ = reader.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(reader, length), reader}, nil
+}
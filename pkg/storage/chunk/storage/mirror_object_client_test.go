@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/pkg/storage/chunk"
+	"github.com/grafana/loki/pkg/storage/chunk/local"
+)
+
+var errUnavailable = errors.New("store unavailable")
+
+type failingObjectClient struct {
+	*chunk.MockStorage
+	err error
+}
+
+func (f *failingObjectClient) PutObject(ctx context.Context, objectKey string, object io.ReadSeeker) error {
+	if f.err != nil {
+		return f.err
+	}
+	return f.MockStorage.PutObject(ctx, objectKey, object)
+}
+
+func (f *failingObjectClient) GetObject(ctx context.Context, objectKey string) (io.ReadCloser, int64, error) {
+	if f.err != nil {
+		return nil, 0, f.err
+	}
+	return f.MockStorage.GetObject(ctx, objectKey)
+}
+
+func (f *failingObjectClient) GetObjectRange(ctx context.Context, objectKey string, offset, length int64) (io.ReadCloser, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.MockStorage.GetObjectRange(ctx, objectKey, offset, length)
+}
+
+func (f *failingObjectClient) List(ctx context.Context, prefix, delimiter string) ([]chunk.StorageObject, []chunk.StorageCommonPrefix, error) {
+	if f.err != nil {
+		return nil, nil, f.err
+	}
+	return f.MockStorage.List(ctx, prefix, delimiter)
+}
+
+func readObject(t *testing.T, client chunk.ObjectClient, key string) string {
+	reader, _, err := client.GetObject(context.Background(), key)
+	require.NoError(t, err)
+	defer reader.Close()
+	buf, err := ioutil.ReadAll(reader)
+	require.NoError(t, err)
+	return string(buf)
+}
+
+func TestMirroredObjectClient_Sync(t *testing.T) {
+	ctx := context.Background()
+	primary := &failingObjectClient{MockStorage: chunk.NewMockStorage()}
+	secondary := &failingObjectClient{MockStorage: chunk.NewMockStorage()}
+	client := NewMirroredObjectClient(primary, secondary, MirrorConfig{Mode: MirrorModeSync, FailoverReads: true})
+	defer client.Stop()
+
+	require.NoError(t, client.PutObject(ctx, "fake/chunk", bytes.NewReader([]byte("content"))))
+	require.Equal(t, "content", readObject(t, primary, "fake/chunk"))
+	require.Equal(t, "content", readObject(t, secondary, "fake/chunk"))
+
+	// the writes fail when the secondary store fails.
+	secondary.err = errUnavailable
+	require.ErrorIs(t, client.PutObject(ctx, "fake/other", bytes.NewReader([]byte("other"))), errUnavailable)
+	secondary.err = nil
+
+	// the reads fail over to the secondary store.
+	primary.err = errUnavailable
+	require.Equal(t, "content", readObject(t, client, "fake/chunk"))
+	objects, _, err := client.List(ctx, "fake/", "")
+	require.NoError(t, err)
+	require.Len(t, objects, 1)
+
+	rangeClient, ok := client.(chunk.RangeObjectClient)
+	require.True(t, ok)
+	reader, err := rangeClient.GetObjectRange(ctx, "fake/chunk", 1, 3)
+	require.NoError(t, err)
+	buf, err := ioutil.ReadAll(reader)
+	require.NoError(t, err)
+	require.Equal(t, "ont", string(buf))
+	primary.err = nil
+
+	// the objects missing in the primary store aren't read from the secondary one.
+	require.NoError(t, primary.MockStorage.DeleteObject(ctx, "fake/chunk"))
+	_, _, err = client.GetObject(ctx, "fake/chunk")
+	require.True(t, client.IsObjectNotFoundErr(err))
+
+	require.NoError(t, client.PutObject(ctx, "fake/chunk", bytes.NewReader([]byte("content"))))
+	require.NoError(t, client.DeleteObject(ctx, "fake/chunk"))
+	require.Equal(t, 0, secondary.GetObjectCount())
+}
+
+func TestMirroredObjectClient_Async(t *testing.T) {
+	ctx := context.Background()
+	cfg := MirrorConfig{Mode: MirrorModeAsync, AsyncQueueSize: 10, AsyncConcurrency: 1}
+
+	// the failures of the secondary store don't fail the writes.
+	secondary := &failingObjectClient{MockStorage: chunk.NewMockStorage(), err: errUnavailable}
+	client := NewMirroredObjectClient(chunk.NewMockStorage(), secondary, cfg)
+	require.NoError(t, client.PutObject(ctx, "fake/lost", bytes.NewReader([]byte("lost"))))
+	client.Stop()
+	require.Equal(t, 0, secondary.GetObjectCount())
+
+	secondary = &failingObjectClient{MockStorage: chunk.NewMockStorage()}
+	client = NewMirroredObjectClient(chunk.NewMockStorage(), secondary, cfg)
+	object := []byte("content")
+	require.NoError(t, client.PutObject(ctx, "fake/chunk", bytes.NewReader(object)))
+	// the object replicated is a copy.
+	copy(object, "changed")
+	// the pending replications are done when stopping.
+	client.Stop()
+	require.Equal(t, "content", readObject(t, secondary, "fake/chunk"))
+}
+
+func TestMirroredObjectClient_ReadFromSecondary(t *testing.T) {
+	ctx := context.Background()
+	primary := &failingObjectClient{MockStorage: chunk.NewMockStorage()}
+	secondary := &failingObjectClient{MockStorage: chunk.NewMockStorage()}
+	client := NewMirroredObjectClient(primary, secondary, MirrorConfig{Mode: MirrorModeSync, FailoverReads: true, ReadFromSecondary: true})
+	defer client.Stop()
+
+	require.NoError(t, client.PutObject(ctx, "fake/chunk", bytes.NewReader([]byte("content"))))
+	require.NoError(t, secondary.MockStorage.PutObject(ctx, "fake/replicated", bytes.NewReader([]byte("replicated"))))
+	require.Equal(t, "replicated", readObject(t, client, "fake/replicated"))
+	objects, _, err := client.List(ctx, "fake/", "")
+	require.NoError(t, err)
+	require.Len(t, objects, 2)
+
+	// the reads fail over to the primary store.
+	secondary.err = errUnavailable
+	require.Equal(t, "content", readObject(t, client, "fake/chunk"))
+	reader, err := client.(chunk.RangeObjectClient).GetObjectRange(ctx, "fake/chunk", 1, 3)
+	require.NoError(t, err)
+	buf, err := ioutil.ReadAll(reader)
+	require.NoError(t, err)
+	require.Equal(t, "ont", string(buf))
+}
+
+func TestNewObjectClient_Mirror(t *testing.T) {
+	cfg := Config{
+		FSConfig: local.FSConfig{Directory: t.TempDir()},
+		Mirror: MirrorConfig{
+			Store:    StorageTypeFileSystem,
+			Mode:     MirrorModeSync,
+			FSConfig: local.FSConfig{Directory: t.TempDir()},
+		},
+	}
+	client, err := NewObjectClient(StorageTypeFileSystem, cfg, ClientMetrics{})
+	require.NoError(t, err)
+	defer client.Stop()
+	require.NoError(t, client.PutObject(context.Background(), "index/table", bytes.NewReader([]byte("content"))))
+
+	for _, dir := range []string{cfg.FSConfig.Directory, cfg.Mirror.FSConfig.Directory} {
+		buf, err := ioutil.ReadFile(dir + "/index/table")
+		require.NoError(t, err)
+		require.Equal(t, "content", string(buf))
+	}
+}
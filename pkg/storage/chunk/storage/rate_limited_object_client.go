@@ -0,0 +1,331 @@
+package storage
+
+import (
+	"context"
+	"flag"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"golang.org/x/time/rate"
+	"google.golang.org/api/googleapi"
+
+	"github.com/grafana/loki/pkg/storage/chunk"
+	"github.com/grafana/loki/pkg/tenant"
+	"github.com/grafana/loki/pkg/util/limiter"
+)
+
+const (
+	// adaptiveDecreaseFactor is the factor the rate limit is multiplied by when the object store throttles requests.
+	adaptiveDecreaseFactor = 0.5
+	// adaptiveInterval is the minimum interval between two adjustments of the adaptive rate limit.
+	adaptiveInterval = time.Second
+)
+
+var (
+	rateLimitWaitDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "loki",
+		Name:      "object_store_rate_limit_wait_duration_seconds",
+		Help:      "Time spent waiting for the rate limiters of the object store before reading.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 8),
+	}, []string{"operation"})
+	rateLimitOpsPerSecond = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "loki",
+		Name:      "object_store_rate_limit_ops_per_second",
+		Help:      "Current global rate limit of the operations on the object store.",
+	}, []string{"store"})
+	throttledRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "loki",
+		Name:      "object_store_throttled_requests_total",
+		Help:      "Total number of requests throttled by the object store.",
+	}, []string{"store", "operation"})
+)
+
+// ObjectRateLimitConfig configures the rate limits of the operations on the object stores.
+type ObjectRateLimitConfig struct {
+	OpsPerSecond            float64 `yaml:"ops_per_second"`
+	Burst                   int     `yaml:"burst"`
+	TenantOpsPerSecond      float64 `yaml:"tenant_ops_per_second"`
+	TenantBurst             int     `yaml:"tenant_burst"`
+	Adaptive                bool    `yaml:"adaptive"`
+	AdaptiveMinOpsPerSecond float64 `yaml:"adaptive_min_ops_per_second"`
+	AdaptiveIncrease        float64 `yaml:"adaptive_increase"`
+}
+
+// RegisterFlags registers flags.
+func (cfg *ObjectRateLimitConfig) RegisterFlags(f *flag.FlagSet) {
+	prefix := "store.object-rate-limit."
+	f.Float64Var(&cfg.OpsPerSecond, prefix+"ops-per-second", 0, "Maximum number of operations per second on the object store, shared by all the tenants. The writes are never delayed but count towards the limit, slowing down the reads instead. 0 to disable.")
+	f.IntVar(&cfg.Burst, prefix+"burst", 100, "Maximum number of operations on the object store allowed in a burst.")
+	f.Float64Var(&cfg.TenantOpsPerSecond, prefix+"tenant-ops-per-second", 0, "Maximum number of reads per second on the object store by each tenant. 0 to disable.")
+	f.IntVar(&cfg.TenantBurst, prefix+"tenant-burst", 50, "Maximum number of reads on the object store allowed in a burst by each tenant.")
+	f.BoolVar(&cfg.Adaptive, prefix+"adaptive", false, "Adapt the global rate limit to the object store: halve it when the object store throttles requests, and increase it when it doesn't, up to the maximum number of operations per second.")
+	f.Float64Var(&cfg.AdaptiveMinOpsPerSecond, prefix+"adaptive-min-ops-per-second", 10, "Minimum global rate limit in adaptive mode.")
+	f.Float64Var(&cfg.AdaptiveIncrease, prefix+"adaptive-increase", 10, "Number of operations per second the global rate limit is increased by every second without throttling in adaptive mode.")
+}
+
+// Validate validates the config.
+func (cfg *ObjectRateLimitConfig) Validate() error {
+	if cfg.OpsPerSecond < 0 || cfg.TenantOpsPerSecond < 0 {
+		return errors.New("the rate limits must not be negative")
+	}
+	if (cfg.OpsPerSecond > 0 && cfg.Burst <= 0) || (cfg.TenantOpsPerSecond > 0 && cfg.TenantBurst <= 0) {
+		return errors.New("the bursts of the rate limits must be positive")
+	}
+	if !cfg.Adaptive {
+		return nil
+	}
+	if cfg.OpsPerSecond == 0 {
+		return errors.New("the adaptive mode requires the global rate limit")
+	}
+	if cfg.AdaptiveMinOpsPerSecond <= 0 || cfg.AdaptiveMinOpsPerSecond > cfg.OpsPerSecond {
+		return errors.New("the minimum rate limit of the adaptive mode must be positive and lower than the global rate limit")
+	}
+	if cfg.AdaptiveIncrease <= 0 {
+		return errors.New("the increase of the rate limit of the adaptive mode must be positive")
+	}
+	return nil
+}
+
+func (cfg *ObjectRateLimitConfig) enabled() bool {
+	return cfg.OpsPerSecond > 0 || cfg.TenantOpsPerSecond > 0
+}
+
+// RateLimiter limits the operations on an object store, globally and by tenant.
+type RateLimiter struct {
+	cfg   ObjectRateLimitConfig
+	store string
+	now   func() time.Time
+
+	global *rate.Limiter
+
+	// adaptive rate limit.
+	adaptiveMtx  sync.Mutex
+	lastAdjusted time.Time
+
+	tenantsMtx        sync.Mutex
+	tenants           map[string]*rate.Limiter
+	tenantsLastPruned time.Time
+}
+
+// tenantsPruneInterval is how often the limiters of the idle tenants are evicted.
+const tenantsPruneInterval = time.Minute
+
+// NewRateLimiter makes the rate limiter of the operations on the object store.
+func NewRateLimiter(store string, cfg ObjectRateLimitConfig) *RateLimiter {
+	l := &RateLimiter{
+		cfg:     cfg,
+		store:   store,
+		now:     time.Now,
+		global:  rate.NewLimiter(rate.Inf, 0),
+		tenants: map[string]*rate.Limiter{},
+	}
+	if cfg.OpsPerSecond > 0 {
+		l.global = rate.NewLimiter(rate.Limit(cfg.OpsPerSecond), cfg.Burst)
+		rateLimitOpsPerSecond.WithLabelValues(store).Set(cfg.OpsPerSecond)
+	}
+	return l
+}
+
+var (
+	rateLimitersMtx sync.Mutex
+	rateLimiters    = map[string]*RateLimiter{}
+)
+
+// sharedRateLimiter returns the rate limiter of the store, shared by all its object clients of the process.
+func sharedRateLimiter(store string, cfg ObjectRateLimitConfig) *RateLimiter {
+	rateLimitersMtx.Lock()
+	defer rateLimitersMtx.Unlock()
+
+	if l, ok := rateLimiters[store]; ok {
+		return l
+	}
+	l := NewRateLimiter(store, cfg)
+	rateLimiters[store] = l
+	return l
+}
+
+// waitRead waits for the global rate limiter and the one of the tenant of the context if any.
+func (l *RateLimiter) waitRead(ctx context.Context, operation string) error {
+	start := l.now()
+	if tenantLimiter := l.tenantLimiter(ctx); tenantLimiter != nil {
+		if err := tenantLimiter.Wait(ctx); err != nil {
+			return errors.Wrap(err, "tenant object store rate limit")
+		}
+	}
+	if err := l.global.Wait(ctx); err != nil {
+		return errors.Wrap(err, "object store rate limit")
+	}
+	rateLimitWaitDuration.WithLabelValues(operation).Observe(l.now().Sub(start).Seconds())
+	return nil
+}
+
+// reserveWrite takes a token from the global rate limiter without waiting, the writes are never delayed.
+func (l *RateLimiter) reserveWrite() {
+	l.global.ReserveN(l.now(), 1)
+}
+
+func (l *RateLimiter) tenantLimiter(ctx context.Context) *rate.Limiter {
+	if l.cfg.TenantOpsPerSecond <= 0 {
+		return nil
+	}
+	userID, err := tenant.TenantID(ctx)
+	if err != nil {
+		// the operations without tenant, like the ones of the compactor, are only globally limited.
+		return nil
+	}
+
+	l.tenantsMtx.Lock()
+	defer l.tenantsMtx.Unlock()
+	l.pruneIdleTenants(l.now())
+	tenantLimiter, ok := l.tenants[userID]
+	if !ok {
+		tenantLimiter = rate.NewLimiter(rate.Limit(l.cfg.TenantOpsPerSecond), l.cfg.TenantBurst)
+		l.tenants[userID] = tenantLimiter
+	}
+	return tenantLimiter
+}
+
+// pruneIdleTenants evicts the limiters of the tenants idle long enough for their limiter to be full, which
+// behave like new limiters, so that the limiters of the tenants don't pile up. It must be called with the
+// tenants lock held.
+func (l *RateLimiter) pruneIdleTenants(now time.Time) {
+	if now.Sub(l.tenantsLastPruned) < tenantsPruneInterval {
+		return
+	}
+	l.tenantsLastPruned = now
+	for userID, tenantLimiter := range l.tenants {
+		if limiter.DelayN(tenantLimiter, now, tenantLimiter.Burst()) == 0 {
+			delete(l.tenants, userID)
+		}
+	}
+}
+
+// done adjusts the adaptive rate limit to the result of an operation.
+func (l *RateLimiter) done(operation string, err error) {
+	throttled := err != nil && isThrottlingErr(err)
+	if throttled {
+		throttledRequests.WithLabelValues(l.store, operation).Inc()
+	}
+	if !l.cfg.Adaptive || (err != nil && !throttled) {
+		return
+	}
+
+	now := l.now()
+	l.adaptiveMtx.Lock()
+	defer l.adaptiveMtx.Unlock()
+
+	elapsed := now.Sub(l.lastAdjusted)
+	if elapsed < adaptiveInterval {
+		return
+	}
+	limit := float64(l.global.Limit())
+	if throttled {
+		limit *= adaptiveDecreaseFactor
+		if limit < l.cfg.AdaptiveMinOpsPerSecond {
+			limit = l.cfg.AdaptiveMinOpsPerSecond
+		}
+	} else {
+		limit += l.cfg.AdaptiveIncrease * elapsed.Seconds()
+		if limit > l.cfg.OpsPerSecond {
+			limit = l.cfg.OpsPerSecond
+		}
+	}
+	l.lastAdjusted = now
+	l.global.SetLimitAt(now, rate.Limit(limit))
+	rateLimitOpsPerSecond.WithLabelValues(l.store).Set(limit)
+}
+
+// isThrottlingErr returns whether the error is the object store throttling the requests.
+func isThrottlingErr(err error) bool {
+	var awsErr awserr.RequestFailure
+	if errors.As(err, &awsErr) {
+		return awsErr.Code() == "SlowDown" || isThrottlingStatusCode(awsErr.StatusCode())
+	}
+	var gcsErr *googleapi.Error
+	if errors.As(err, &gcsErr) {
+		return isThrottlingStatusCode(gcsErr.Code)
+	}
+	var azureErr azblob.StorageError
+	if errors.As(err, &azureErr) && azureErr.Response() != nil {
+		return isThrottlingStatusCode(azureErr.Response().StatusCode)
+	}
+	return false
+}
+
+func isThrottlingStatusCode(code int) bool {
+	return code == http.StatusTooManyRequests || code == http.StatusServiceUnavailable
+}
+
+// RateLimitedObjectClient limits the operations on the object client with a rate limiter.
+type RateLimitedObjectClient struct {
+	chunk.ObjectClient
+	limiter *RateLimiter
+}
+
+type rateLimitedRangeObjectClient struct {
+	*RateLimitedObjectClient
+	rangeClient chunk.RangeObjectClient
+}
+
+// NewRateLimitedObjectClient makes an object client whose operations are limited by the rate limiter.
+// The returned client supports range reads if the object client does.
+func NewRateLimitedObjectClient(client chunk.ObjectClient, limiter *RateLimiter) chunk.ObjectClient {
+	c := &RateLimitedObjectClient{ObjectClient: client, limiter: limiter}
+	if rangeClient, ok := client.(chunk.RangeObjectClient); ok {
+		return &rateLimitedRangeObjectClient{RateLimitedObjectClient: c, rangeClient: rangeClient}
+	}
+	return c
+}
+
+// PutObject implements chunk.ObjectClient.
+func (c *RateLimitedObjectClient) PutObject(ctx context.Context, objectKey string, object io.ReadSeeker) error {
+	c.limiter.reserveWrite()
+	err := c.ObjectClient.PutObject(ctx, objectKey, object)
+	c.limiter.done("PutObject", err)
+	return err
+}
+
+// DeleteObject implements chunk.ObjectClient.
+func (c *RateLimitedObjectClient) DeleteObject(ctx context.Context, objectKey string) error {
+	c.limiter.reserveWrite()
+	err := c.ObjectClient.DeleteObject(ctx, objectKey)
+	c.limiter.done("DeleteObject", err)
+	return err
+}
+
+// GetObject implements chunk.ObjectClient.
+func (c *RateLimitedObjectClient) GetObject(ctx context.Context, objectKey string) (io.ReadCloser, int64, error) {
+	if err := c.limiter.waitRead(ctx, "GetObject"); err != nil {
+		return nil, 0, err
+	}
+	reader, size, err := c.ObjectClient.GetObject(ctx, objectKey)
+	c.limiter.done("GetObject", err)
+	return reader, size, err
+}
+
+// List implements chunk.ObjectClient.
+func (c *RateLimitedObjectClient) List(ctx context.Context, prefix, delimiter string) ([]chunk.StorageObject, []chunk.StorageCommonPrefix, error) {
+	if err := c.limiter.waitRead(ctx, "List"); err != nil {
+		return nil, nil, err
+	}
+	objects, prefixes, err := c.ObjectClient.List(ctx, prefix, delimiter)
+	c.limiter.done("List", err)
+	return objects, prefixes, err
+}
+
+// GetObjectRange implements chunk.RangeObjectClient.
+func (c *rateLimitedRangeObjectClient) GetObjectRange(ctx context.Context, objectKey string, offset, length int64) (io.ReadCloser, error) {
+	if err := c.limiter.waitRead(ctx, "GetObjectRange"); err != nil {
+		return nil, err
+	}
+	reader, err := c.rangeClient.GetObjectRange(ctx, objectKey, offset, length)
+	c.limiter.done("GetObjectRange", err)
+	return reader, err
+}
@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+	"google.golang.org/api/googleapi"
+
+	"github.com/grafana/loki/pkg/storage/chunk"
+)
+
+func TestRateLimitedObjectClient_Tenants(t *testing.T) {
+	store := chunk.NewMockStorage()
+	require.NoError(t, store.PutObject(context.Background(), "object", bytes.NewReader([]byte("data"))))
+	client := NewRateLimitedObjectClient(store, NewRateLimiter("test", ObjectRateLimitConfig{TenantOpsPerSecond: 0.001, TenantBurst: 1}))
+
+	read := func(tenant string) error {
+		ctx, cancel := context.WithTimeout(user.InjectOrgID(context.Background(), tenant), 100*time.Millisecond)
+		defer cancel()
+		_, _, err := client.GetObject(ctx, "object")
+		return err
+	}
+	require.NoError(t, read("tenant-a"))
+	// the tenant exhausted its burst, the other tenants are not affected.
+	require.Error(t, read("tenant-a"))
+	require.NoError(t, read("tenant-b"))
+
+	// the operations without tenant are not limited by tenant.
+	for i := 0; i < 3; i++ {
+		require.Equal(t, "data", readObject(t, client, "object"))
+	}
+
+	// the range reads are kept.
+	_, ok := client.(chunk.RangeObjectClient)
+	require.True(t, ok)
+}
+
+func TestRateLimiter_PruneIdleTenants(t *testing.T) {
+	l := NewRateLimiter("test", ObjectRateLimitConfig{TenantOpsPerSecond: 0.001, TenantBurst: 1})
+	now := time.Unix(0, 0)
+	l.now = func() time.Time { return now }
+
+	// tenant-a takes its token, tenant-b doesn't.
+	require.True(t, l.tenantLimiter(user.InjectOrgID(context.Background(), "tenant-a")).AllowN(now, 1))
+	l.tenantLimiter(user.InjectOrgID(context.Background(), "tenant-b"))
+	require.Len(t, l.tenants, 2)
+
+	// the limiters of the tenants are only pruned every interval.
+	now = now.Add(tenantsPruneInterval / 2)
+	l.tenantLimiter(user.InjectOrgID(context.Background(), "tenant-c"))
+	require.Len(t, l.tenants, 3)
+
+	// the full limiters are evicted, the one of tenant-a is still refilling.
+	now = now.Add(tenantsPruneInterval)
+	l.tenantLimiter(user.InjectOrgID(context.Background(), "tenant-a"))
+	require.Len(t, l.tenants, 1)
+	require.False(t, l.tenantLimiter(user.InjectOrgID(context.Background(), "tenant-a")).AllowN(now, 1))
+}
+
+func TestRateLimitedObjectClient_WritesFirst(t *testing.T) {
+	client := NewRateLimitedObjectClient(chunk.NewMockStorage(), NewRateLimiter("test", ObjectRateLimitConfig{OpsPerSecond: 0.001, Burst: 1}))
+
+	// the writes are never delayed, even when the limit is exceeded.
+	for i := 0; i < 3; i++ {
+		require.NoError(t, client.PutObject(context.Background(), fmt.Sprintf("object-%d", i), bytes.NewReader([]byte("data"))))
+	}
+
+	// but the reads wait for the tokens taken by the writes.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, _, err := client.GetObject(ctx, "object-0")
+	require.Error(t, err)
+}
+
+func TestRateLimiter_Adaptive(t *testing.T) {
+	now := time.Unix(0, 0)
+	limiter := NewRateLimiter("test", ObjectRateLimitConfig{OpsPerSecond: 100, Burst: 100, Adaptive: true, AdaptiveMinOpsPerSecond: 20, AdaptiveIncrease: 10})
+	limiter.now = func() time.Time { return now }
+	slowDown := awserr.NewRequestFailure(awserr.New("SlowDown", "Please reduce your request rate.", nil), 503, "id")
+
+	now = now.Add(time.Second)
+	limiter.done("GetObject", slowDown)
+	require.Equal(t, 50., float64(limiter.global.Limit()))
+
+	// the limit is adjusted at most once per interval.
+	limiter.done("GetObject", slowDown)
+	require.Equal(t, 50., float64(limiter.global.Limit()))
+
+	now = now.Add(time.Second)
+	limiter.done("GetObject", slowDown)
+	now = now.Add(time.Second)
+	limiter.done("GetObject", slowDown)
+	require.Equal(t, 20., float64(limiter.global.Limit()))
+
+	// the other errors don't change the limit.
+	now = now.Add(time.Second)
+	limiter.done("GetObject", errors.New("not found"))
+	require.Equal(t, 20., float64(limiter.global.Limit()))
+
+	limiter.done("GetObject", nil)
+	require.Equal(t, 30., float64(limiter.global.Limit()))
+	now = now.Add(time.Minute)
+	limiter.done("GetObject", nil)
+	require.Equal(t, 100., float64(limiter.global.Limit()))
+}
+
+func TestIsThrottlingErr(t *testing.T) {
+	for _, tc := range []struct {
+		err       error
+		throttled bool
+	}{
+		{err: errors.New("error")},
+		{err: awserr.NewRequestFailure(awserr.New("SlowDown", "", nil), 503, "id"), throttled: true},
+		{err: errors.Wrap(awserr.NewRequestFailure(awserr.New("Throttling", "", nil), 429, "id"), "wrapped"), throttled: true},
+		{err: awserr.NewRequestFailure(awserr.New("NoSuchKey", "", nil), 404, "id")},
+		{err: &googleapi.Error{Code: 429}, throttled: true},
+		{err: &googleapi.Error{Code: 404}},
+	} {
+		require.Equal(t, tc.throttled, isThrottlingErr(tc.err), "%v", tc.err)
+	}
+}
+
+func TestObjectRateLimitConfig_Validate(t *testing.T) {
+	for _, tc := range []struct {
+		cfg ObjectRateLimitConfig
+		err bool
+	}{
+		{cfg: ObjectRateLimitConfig{}},
+		{cfg: ObjectRateLimitConfig{OpsPerSecond: 100, Burst: 10, TenantOpsPerSecond: 10, TenantBurst: 5}},
+		{cfg: ObjectRateLimitConfig{OpsPerSecond: -1}, err: true},
+		{cfg: ObjectRateLimitConfig{OpsPerSecond: 100}, err: true},
+		{cfg: ObjectRateLimitConfig{OpsPerSecond: 100, Burst: 10, Adaptive: true, AdaptiveMinOpsPerSecond: 10, AdaptiveIncrease: 10}},
+		{cfg: ObjectRateLimitConfig{Adaptive: true, AdaptiveMinOpsPerSecond: 10, AdaptiveIncrease: 10}, err: true},
+		{cfg: ObjectRateLimitConfig{OpsPerSecond: 100, Burst: 10, Adaptive: true, AdaptiveMinOpsPerSecond: 200, AdaptiveIncrease: 10}, err: true},
+	} {
+		if tc.err {
+			require.Error(t, tc.cfg.Validate(), "%+v", tc.cfg)
+		} else {
+			require.NoError(t, tc.cfg.Validate(), "%+v", tc.cfg)
+		}
+	}
+}
@@ -84,6 +84,13 @@ type ObjectClient interface {
 	Stop()
 }
 
+// RangeObjectClient is implemented by the object clients able to read a range of bytes
+// of an object, used to read the chunks packed in containers without fetching them whole.
+type RangeObjectClient interface {
+	// NOTE: The consumer of GetObjectRange should always call the Close method when it is done reading.
+	GetObjectRange(ctx context.Context, objectKey string, offset, length int64) (io.ReadCloser, error)
+}
+
 // StorageObject represents an object being stored in an Object Store
 type StorageObject struct {
 	Key        string
@@ -0,0 +1,74 @@
+package chunk
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// TableExporter is implemented by the table clients able to read back all the entries of a table.
+type TableExporter interface {
+	// ExportTable calls the callback with each entry of the table, stopping at the first error.
+	ExportTable(ctx context.Context, name string, callback func(entry IndexEntry) error) error
+}
+
+// TableArchiver saves a table before the table manager deletes it for being out of retention.
+type TableArchiver interface {
+	ArchiveTable(ctx context.Context, name string) error
+}
+
+// objectTableArchiver exports the tables to an object store.
+type objectTableArchiver struct {
+	exporter TableExporter
+	client   ObjectClient
+	prefix   string
+}
+
+// NewObjectTableArchiver returns a TableArchiver exporting the tables to the object store as gzipped
+// newline-delimited JSON index entries, under the key <prefix><table name>.json.gz.
+func NewObjectTableArchiver(exporter TableExporter, client ObjectClient, prefix string) TableArchiver {
+	return &objectTableArchiver{
+		exporter: exporter,
+		client:   client,
+		prefix:   prefix,
+	}
+}
+
+// ArchiveKey returns the key of the object holding the archive of the table.
+func ArchiveKey(prefix, table string) string {
+	return prefix + table + ".json.gz"
+}
+
+func (a *objectTableArchiver) ArchiveTable(ctx context.Context, name string) (err error) {
+	// tables can be way larger than the memory, they are spooled to a temporary file.
+	f, err := ioutil.TempFile("", "table-archive-"+name)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := f.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+		_ = os.Remove(f.Name())
+	}()
+
+	gz := gzip.NewWriter(f)
+	enc := json.NewEncoder(gz)
+	if err := a.exporter.ExportTable(ctx, name, func(entry IndexEntry) error {
+		return enc.Encode(entry)
+	}); err != nil {
+		return fmt.Errorf("error exporting table %s: %w", name, err)
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return a.client.PutObject(ctx, ArchiveKey(a.prefix, name), f)
+}
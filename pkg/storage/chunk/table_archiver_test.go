@@ -0,0 +1,47 @@
+package chunk
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestObjectTableArchiver(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMockStorage()
+	require.NoError(t, storage.CreateTable(ctx, TableDesc{Name: "table"}))
+
+	batch := storage.NewWriteBatch()
+	batch.Add("table", "b", []byte("1"), nil)
+	batch.Add("table", "a", []byte("2"), []byte("value"))
+	batch.Add("table", "a", []byte("1"), nil)
+	require.NoError(t, storage.BatchWrite(ctx, batch))
+
+	archiver := NewObjectTableArchiver(storage, storage, "archive/")
+	require.NoError(t, archiver.ArchiveTable(ctx, "table"))
+	require.Error(t, archiver.ArchiveTable(ctx, "unknown"))
+
+	reader, _, err := storage.GetObject(ctx, ArchiveKey("archive/", "table"))
+	require.NoError(t, err)
+	defer reader.Close()
+	gz, err := gzip.NewReader(reader)
+	require.NoError(t, err)
+
+	var entries []IndexEntry
+	scanner := bufio.NewScanner(gz)
+	for scanner.Scan() {
+		var entry IndexEntry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+		entries = append(entries, entry)
+	}
+	require.NoError(t, scanner.Err())
+	require.Equal(t, []IndexEntry{
+		{TableName: "table", HashValue: "a", RangeValue: []byte("1")},
+		{TableName: "table", HashValue: "a", RangeValue: []byte("2"), Value: []byte("value")},
+		{TableName: "table", HashValue: "b", RangeValue: []byte("1")},
+	}, entries)
+}
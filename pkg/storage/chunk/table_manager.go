@@ -8,6 +8,7 @@ import (
 	"math/rand"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-kit/log/level"
@@ -34,6 +35,7 @@ type tableManagerMetrics struct {
 	tableCapacity      *prometheus.GaugeVec
 	createFailures     prometheus.Gauge
 	deleteFailures     prometheus.Gauge
+	pendingDeletes     prometheus.Gauge
 	lastSuccessfulSync prometheus.Gauge
 }
 
@@ -62,6 +64,11 @@ func newTableManagerMetrics(r prometheus.Registerer) *tableManagerMetrics {
 		Name:      "table_manager_delete_failures",
 		Help:      "Number of table deletion failures during the last table-manager reconciliation",
 	})
+	m.pendingDeletes = promauto.With(r).NewGauge(prometheus.GaugeOpts{
+		Namespace: "loki",
+		Name:      "table_manager_pending_deletes",
+		Help:      "Number of tables out of retention waiting for the end of the retention deletes grace period to be deleted",
+	})
 
 	m.lastSuccessfulSync = promauto.With(r).NewGauge(prometheus.GaugeOpts{
 		Namespace: "loki",
@@ -92,6 +99,13 @@ type TableManagerConfig struct {
 	// This is so that we can accept 1w, 1y in the YAML.
 	RetentionPeriodModel model.Duration `yaml:"retention_period"`
 
+	// How long tables stay out of retention before they are actually deleted
+	RetentionDeletesGracePeriod time.Duration `yaml:"retention_deletes_grace_period"`
+
+	// Object store the tables are exported to before being deleted
+	RetentionArchiveStore  string `yaml:"retention_archive_store"`
+	RetentionArchivePrefix string `yaml:"retention_archive_prefix"`
+
 	// Period with which the table manager will poll for tables.
 	PollInterval time.Duration `yaml:"poll_interval"`
 
@@ -141,6 +155,9 @@ func (cfg *TableManagerConfig) RegisterFlags(f *flag.FlagSet) {
 	f.BoolVar(&cfg.ThroughputUpdatesDisabled, "table-manager.throughput-updates-disabled", false, "If true, disable all changes to DB capacity")
 	f.BoolVar(&cfg.RetentionDeletesEnabled, "table-manager.retention-deletes-enabled", false, "If true, enables retention deletes of DB tables")
 	f.Var(&cfg.RetentionPeriodModel, "table-manager.retention-period", "Tables older than this retention period are deleted. Must be either 0 (disabled) or a multiple of 24h. When enabled, be aware this setting is destructive to data!")
+	f.DurationVar(&cfg.RetentionDeletesGracePeriod, "table-manager.retention-deletes-grace-period", 0, "Duration a table has to be out of the retention period before it is deleted, giving time to fix a retention misconfiguration. 0 deletes the tables as soon as they are out of retention.")
+	f.StringVar(&cfg.RetentionArchiveStore, "table-manager.retention-archive-store", "", "Object store the tables are exported to before being deleted by the retention, one of aws, s3, gcs, azure, swift, filesystem. Only supported by the aws-dynamo and inmemory index types. Empty disables the archival.")
+	f.StringVar(&cfg.RetentionArchivePrefix, "table-manager.retention-archive-prefix", "table_archive/", "Prefix of the objects holding the archived tables.")
 	f.DurationVar(&cfg.PollInterval, "table-manager.poll-interval", 2*time.Minute, "How frequently to poll backend to learn our capacity.")
 	f.DurationVar(&cfg.CreationGracePeriod, "table-manager.periodic-table.grace-period", 10*time.Minute, "Periodic tables grace period (duration which table will be created/deleted before/after it's needed).")
 
@@ -154,18 +171,24 @@ type TableManager struct {
 
 	client       TableClient
 	cfg          TableManagerConfig
+	schemaMtx    sync.RWMutex
 	schemaCfg    SchemaConfig
 	maxChunkAge  time.Duration
 	bucketClient BucketClient
 	metrics      *tableManagerMetrics
 	extraTables  []ExtraTables
+	archiver     TableArchiver
+
+	// outOfRetentionSince holds when the tables to delete were found out of retention.
+	outOfRetentionSince map[string]time.Time
 
 	bucketRetentionLoop services.Service
 }
 
-// NewTableManager makes a new TableManager
+// NewTableManager makes a new TableManager.
+// The archiver, if any, saves the tables before they are deleted.
 func NewTableManager(cfg TableManagerConfig, schemaCfg SchemaConfig, maxChunkAge time.Duration, tableClient TableClient,
-	objectClient BucketClient, extraTables []ExtraTables, registerer prometheus.Registerer) (*TableManager, error) {
+	objectClient BucketClient, archiver TableArchiver, extraTables []ExtraTables, registerer prometheus.Registerer) (*TableManager, error) {
 
 	if cfg.RetentionPeriod != 0 {
 		// Assume the newest config is the one to use for validation of retention
@@ -183,12 +206,37 @@ func NewTableManager(cfg TableManagerConfig, schemaCfg SchemaConfig, maxChunkAge
 		bucketClient: objectClient,
 		metrics:      newTableManagerMetrics(registerer),
 		extraTables:  extraTables,
+		archiver:     archiver,
+
+		outOfRetentionSince: map[string]time.Time{},
 	}
 
 	tm.Service = services.NewBasicService(tm.starting, tm.loop, tm.stopping)
 	return tm, nil
 }
 
+// AddPeriodConfig implements PeriodConfigAdder. The tables of the period are created
+// by the next sync within the creation grace period of its start.
+func (m *TableManager) AddPeriodConfig(cfg PeriodConfig) error {
+	m.schemaMtx.Lock()
+	defer m.schemaMtx.Unlock()
+
+	if n := len(m.schemaCfg.Configs); n > 0 && m.schemaCfg.Configs[n-1].From.Time >= cfg.From.Time {
+		return errSchemaIncreasingFromTime
+	}
+	// copy the configs since the previous ones could be in use.
+	configs := make([]PeriodConfig, 0, len(m.schemaCfg.Configs)+1)
+	configs = append(configs, m.schemaCfg.Configs...)
+	m.schemaCfg.Configs = append(configs, cfg)
+	return nil
+}
+
+func (m *TableManager) schemaConfig() SchemaConfig {
+	m.schemaMtx.RLock()
+	defer m.schemaMtx.RUnlock()
+	return m.schemaCfg
+}
+
 // Start the TableManager
 func (m *TableManager) starting(ctx context.Context) error {
 	if m.bucketClient != nil && m.cfg.RetentionPeriod != 0 && m.cfg.RetentionDeletesEnabled {
@@ -344,8 +392,26 @@ func (m *TableManager) SyncTables(ctx context.Context) error {
 
 func (m *TableManager) calculateExpectedTables() []TableDesc {
 	result := []TableDesc{}
+	// the tenants having their own periods share the tables of the periods with the same prefixes.
+	seen := map[string]struct{}{}
+	for _, configs := range m.schemaConfig().AllConfigs() {
+		for _, table := range m.expectedTables(configs) {
+			if _, ok := seen[table.Name]; ok {
+				continue
+			}
+			seen[table.Name] = struct{}{}
+			result = append(result, table)
+		}
+	}
 
-	for i, config := range m.schemaCfg.Configs {
+	sort.Sort(byName(result))
+	return result
+}
+
+// expectedTables returns the tables of the period configs, which must be ordered by start time.
+func (m *TableManager) expectedTables(configs []PeriodConfig) []TableDesc {
+	result := []TableDesc{}
+	for i, config := range configs {
 		// Consider configs which we are about to hit and requires tables to be created due to grace period
 		if config.From.Time.Time().After(mtime.Now().Add(m.cfg.CreationGracePeriod)) {
 			continue
@@ -363,9 +429,9 @@ func (m *TableManager) calculateExpectedTables() []TableDesc {
 				Tags:              config.IndexTables.Tags,
 			}
 			isActive := true
-			if i+1 < len(m.schemaCfg.Configs) {
+			if i+1 < len(configs) {
 				var (
-					endTime         = m.schemaCfg.Configs[i+1].From.Unix()
+					endTime         = configs[i+1].From.Unix()
 					gracePeriodSecs = int64(m.cfg.CreationGracePeriod / time.Second)
 					maxChunkAgeSecs = int64(m.maxChunkAge / time.Second)
 					now             = mtime.Now().Unix()
@@ -390,25 +456,36 @@ func (m *TableManager) calculateExpectedTables() []TableDesc {
 			result = append(result, table)
 		} else {
 			endTime := mtime.Now().Add(m.cfg.CreationGracePeriod)
-			if i+1 < len(m.schemaCfg.Configs) {
-				nextFrom := m.schemaCfg.Configs[i+1].From.Time.Time()
+			if i+1 < len(configs) {
+				nextFrom := configs[i+1].From.Time.Time()
 				if endTime.After(nextFrom) {
 					endTime = nextFrom
 				}
 			}
 			endModelTime := model.TimeFromUnix(endTime.Unix())
+			startModelTime, retention := config.From.Time, m.cfg.RetentionPeriod
+			if config.RetentionPeriod > 0 {
+				// The retention of the period applies from now, so that the tables of a period
+				// which already ended are dropped once all their data is out of retention.
+				retentionStart := model.TimeFromUnix(mtime.Now().Add(-time.Duration(config.RetentionPeriod)).Unix())
+				if retentionStart > startModelTime {
+					startModelTime = retentionStart
+				}
+				retention = 0
+			}
+			if startModelTime >= endModelTime {
+				continue
+			}
 			result = append(result, config.IndexTables.periodicTables(
-				config.From.Time, endModelTime, m.cfg.IndexTables, m.cfg.CreationGracePeriod, m.maxChunkAge, m.cfg.RetentionPeriod,
+				startModelTime, endModelTime, m.cfg.IndexTables, m.cfg.CreationGracePeriod, m.maxChunkAge, retention,
 			)...)
 			if config.ChunkTables.Prefix != "" {
 				result = append(result, config.ChunkTables.periodicTables(
-					config.From.Time, endModelTime, m.cfg.ChunkTables, m.cfg.CreationGracePeriod, m.maxChunkAge, m.cfg.RetentionPeriod,
+					startModelTime, endModelTime, m.cfg.ChunkTables, m.cfg.CreationGracePeriod, m.maxChunkAge, retention,
 				)...)
 			}
 		}
 	}
-
-	sort.Sort(byName(result))
 	return result
 }
 
@@ -438,15 +515,17 @@ func (m *TableManager) partitionTables(ctx context.Context, descriptions []Table
 		}
 	}
 
-	if m.cfg.RetentionPeriod > 0 {
+	if m.retentionEnabled() {
 		// Ensure we only delete tables which have a prefix managed by Cortex.
 		tablePrefixes := map[string]struct{}{}
-		for _, cfg := range m.schemaCfg.Configs {
-			if cfg.IndexTables.Prefix != "" {
-				tablePrefixes[cfg.IndexTables.Prefix] = struct{}{}
-			}
-			if cfg.ChunkTables.Prefix != "" {
-				tablePrefixes[cfg.ChunkTables.Prefix] = struct{}{}
+		for _, configs := range m.schemaConfig().AllConfigs() {
+			for _, cfg := range configs {
+				if cfg.IndexTables.Prefix != "" {
+					tablePrefixes[cfg.IndexTables.Prefix] = struct{}{}
+				}
+				if cfg.ChunkTables.Prefix != "" {
+					tablePrefixes[cfg.ChunkTables.Prefix] = struct{}{}
+				}
 			}
 		}
 
@@ -465,6 +544,21 @@ func (m *TableManager) partitionTables(ctx context.Context, descriptions []Table
 	return toCreate, toCheck, toDelete, nil
 }
 
+// retentionEnabled tells whether tables can be out of retention, either globally or for some periods.
+func (m *TableManager) retentionEnabled() bool {
+	if m.cfg.RetentionPeriod > 0 {
+		return true
+	}
+	for _, configs := range m.schemaConfig().AllConfigs() {
+		for _, cfg := range configs {
+			if cfg.RetentionPeriod > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (m *TableManager) createTables(ctx context.Context, descriptions []TableDesc) error {
 	numFailures := 0
 	merr := tsdb_errors.NewMulti()
@@ -486,21 +580,56 @@ func (m *TableManager) deleteTables(ctx context.Context, descriptions []TableDes
 	numFailures := 0
 	merr := tsdb_errors.NewMulti()
 
+	// forget the tables back in retention, e.g. once a retention misconfiguration got fixed.
+	toDelete := make(map[string]struct{}, len(descriptions))
+	for _, desc := range descriptions {
+		toDelete[desc.Name] = struct{}{}
+	}
+	for name := range m.outOfRetentionSince {
+		if _, ok := toDelete[name]; !ok {
+			delete(m.outOfRetentionSince, name)
+		}
+	}
+
+	now := mtime.Now()
 	for _, desc := range descriptions {
 		level.Info(util_log.Logger).Log("msg", "table has exceeded the retention period", "table", desc.Name)
 		if !m.cfg.RetentionDeletesEnabled {
 			continue
 		}
 
+		since, ok := m.outOfRetentionSince[desc.Name]
+		if !ok {
+			since = now
+			m.outOfRetentionSince[desc.Name] = since
+		}
+		if deleteAt := since.Add(m.cfg.RetentionDeletesGracePeriod); now.Before(deleteAt) {
+			level.Info(util_log.Logger).Log("msg", "table deletion pending until the end of the retention deletes grace period", "table", desc.Name, "delete_at", deleteAt)
+			continue
+		}
+
+		if m.archiver != nil {
+			level.Info(util_log.Logger).Log("msg", "archiving table", "table", desc.Name)
+			if err := m.archiver.ArchiveTable(ctx, desc.Name); err != nil {
+				// never delete a table which couldn't be archived.
+				numFailures++
+				merr.Add(fmt.Errorf("error archiving table %s: %w", desc.Name, err))
+				continue
+			}
+		}
+
 		level.Info(util_log.Logger).Log("msg", "deleting table", "table", desc.Name)
 		err := m.client.DeleteTable(ctx, desc.Name)
 		if err != nil {
 			numFailures++
 			merr.Add(err)
+			continue
 		}
+		delete(m.outOfRetentionSince, desc.Name)
 	}
 
 	m.metrics.deleteFailures.Set(float64(numFailures))
+	m.metrics.pendingDeletes.Set(float64(len(m.outOfRetentionSince)))
 	return merr.Err()
 }
 
@@ -2,6 +2,7 @@ package chunk
 
 import (
 	"context"
+	"errors"
 	"sync"
 	"testing"
 	"time"
@@ -185,7 +186,7 @@ func TestTableManager(t *testing.T) {
 			},
 		},
 	}
-	tableManager, err := NewTableManager(tbmConfig, cfg, maxChunkAge, client, nil, nil, nil)
+	tableManager, err := NewTableManager(tbmConfig, cfg, maxChunkAge, client, nil, nil, nil, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -377,7 +378,7 @@ func TestTableManagerAutoscaleInactiveOnly(t *testing.T) {
 			},
 		},
 	}
-	tableManager, err := NewTableManager(tbmConfig, cfg, maxChunkAge, client, nil, nil, nil)
+	tableManager, err := NewTableManager(tbmConfig, cfg, maxChunkAge, client, nil, nil, nil, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -473,7 +474,7 @@ func TestTableManagerDynamicIOModeInactiveOnly(t *testing.T) {
 			},
 		},
 	}
-	tableManager, err := NewTableManager(tbmConfig, cfg, maxChunkAge, client, nil, nil, nil)
+	tableManager, err := NewTableManager(tbmConfig, cfg, maxChunkAge, client, nil, nil, nil, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -556,7 +557,7 @@ func TestTableManagerTags(t *testing.T) {
 				IndexTables: PeriodicTableConfig{},
 			}},
 		}
-		tableManager, err := NewTableManager(TableManagerConfig{}, cfg, maxChunkAge, client, nil, nil, nil)
+		tableManager, err := NewTableManager(TableManagerConfig{}, cfg, maxChunkAge, client, nil, nil, nil, nil)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -580,7 +581,7 @@ func TestTableManagerTags(t *testing.T) {
 				},
 			}},
 		}
-		tableManager, err := NewTableManager(TableManagerConfig{}, cfg, maxChunkAge, client, nil, nil, nil)
+		tableManager, err := NewTableManager(TableManagerConfig{}, cfg, maxChunkAge, client, nil, nil, nil, nil)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -642,7 +643,7 @@ func TestTableManagerRetentionOnly(t *testing.T) {
 			},
 		},
 	}
-	tableManager, err := NewTableManager(tbmConfig, cfg, maxChunkAge, client, nil, nil, nil)
+	tableManager, err := NewTableManager(tbmConfig, cfg, maxChunkAge, client, nil, nil, nil, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -765,6 +766,331 @@ func TestTableManagerRetentionOnly(t *testing.T) {
 
 	// Test table manager retention not multiple of periodic config
 	tbmConfig.RetentionPeriod++
-	_, err = NewTableManager(tbmConfig, cfg, maxChunkAge, client, nil, nil, nil)
+	_, err = NewTableManager(tbmConfig, cfg, maxChunkAge, client, nil, nil, nil, nil)
 	require.Error(t, err)
 }
+
+func TestTableManagerPeriodRetention(t *testing.T) {
+	client := newMockTableClient()
+
+	cfg := SchemaConfig{
+		Configs: []PeriodConfig{
+			{
+				From:            DayTime{model.TimeFromUnix(baseTableStart.Unix())},
+				IndexTables:     PeriodicTableConfig{Prefix: tablePrefix, Period: tablePeriod},
+				RetentionPeriod: model.Duration(tableRetention),
+			},
+			{
+				From:        DayTime{model.TimeFromUnix(baseTableStart.Add(tablePeriod * 2).Unix())},
+				IndexTables: PeriodicTableConfig{Prefix: table2Prefix, Period: tablePeriod},
+			},
+		},
+	}
+	tbmConfig := TableManagerConfig{
+		RetentionDeletesEnabled: true,
+		CreationGracePeriod:     gracePeriod,
+	}
+	tableManager, err := NewTableManager(tbmConfig, cfg, maxChunkAge, client, nil, nil, nil, nil)
+	require.NoError(t, err)
+
+	tmTest(t, client, tableManager,
+		"Start of the second period",
+		baseTableStart.Add(tablePeriod*2),
+		[]TableDesc{
+			{Name: tablePrefix + "0"},
+			{Name: tablePrefix + "1"},
+			{Name: table2Prefix + "2"},
+		},
+	)
+
+	// The tables of the first period are dropped once out of its retention, even though it ended.
+	tmTest(t, client, tableManager,
+		"Move forward by one table period",
+		baseTableStart.Add(tablePeriod*3),
+		[]TableDesc{
+			{Name: tablePrefix + "1"},
+			{Name: table2Prefix + "2"},
+			{Name: table2Prefix + "3"},
+		},
+	)
+
+	// The second period doesn't have any retention.
+	tmTest(t, client, tableManager,
+		"Move forward out of the retention of the first period",
+		baseTableStart.Add(tablePeriod*4+24*time.Hour),
+		[]TableDesc{
+			{Name: table2Prefix + "2"},
+			{Name: table2Prefix + "3"},
+			{Name: table2Prefix + "4"},
+		},
+	)
+}
+
+// mockTableArchiver records the tables archived.
+type mockTableArchiver struct {
+	archived []string
+	err      error
+}
+
+func (m *mockTableArchiver) ArchiveTable(_ context.Context, name string) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.archived = append(m.archived, name)
+	return nil
+}
+
+func TestTableManagerRetentionDeletesGracePeriod(t *testing.T) {
+	client := newMockTableClient()
+	archiver := &mockTableArchiver{}
+
+	cfg := SchemaConfig{
+		Configs: []PeriodConfig{
+			{
+				From:        DayTime{model.TimeFromUnix(baseTableStart.Unix())},
+				IndexTables: PeriodicTableConfig{Prefix: tablePrefix, Period: tablePeriod},
+			},
+		},
+	}
+	tbmConfig := TableManagerConfig{
+		RetentionPeriod:             tableRetention,
+		RetentionDeletesEnabled:     true,
+		RetentionDeletesGracePeriod: 48 * time.Hour,
+		CreationGracePeriod:         gracePeriod,
+	}
+	tableManager, err := NewTableManager(tbmConfig, cfg, maxChunkAge, client, nil, archiver, nil, nil)
+	require.NoError(t, err)
+
+	tmTest(t, client, tableManager,
+		"Initial test",
+		baseTableStart,
+		[]TableDesc{
+			{Name: tablePrefix + "0"},
+		},
+	)
+
+	outOfRetention := baseTableStart.Add(tablePeriod * 3)
+	tables := []TableDesc{
+		{Name: tablePrefix + "0"},
+		{Name: tablePrefix + "1"},
+		{Name: tablePrefix + "2"},
+		{Name: tablePrefix + "3"},
+	}
+	tmTest(t, client, tableManager, "Out of retention table is kept", outOfRetention, tables)
+	tmTest(t, client, tableManager, "Out of retention table is kept during the grace period", outOfRetention.Add(47*time.Hour), tables)
+
+	// A table back in retention has to be out of it for the whole grace period again.
+	tableManager.cfg.RetentionPeriod = 2 * tableRetention
+	tmTest(t, client, tableManager, "Table back in retention", outOfRetention.Add(47*time.Hour), tables)
+	tableManager.cfg.RetentionPeriod = tableRetention
+	tmTest(t, client, tableManager, "Table out of retention again", outOfRetention.Add(48*time.Hour), tables)
+
+	// Tables failing to be archived are not deleted.
+	archiver.err = errors.New("archive failure")
+	mtime.NowForce(outOfRetention.Add(96 * time.Hour))
+	require.Error(t, tableManager.SyncTables(context.Background()))
+	mtime.NowReset()
+	require.NoError(t, ExpectTables(context.Background(), client, tables))
+
+	archiver.err = nil
+	tmTest(t, client, tableManager, "Table deleted at the end of the grace period", outOfRetention.Add(96*time.Hour), tables[1:])
+	require.Equal(t, []string{tablePrefix + "0"}, archiver.archived)
+}
+
+func TestTableManagerNameFormat(t *testing.T) {
+	client := newMockTableClient()
+
+	cfg := SchemaConfig{
+		Configs: []PeriodConfig{
+			{
+				From: DayTime{model.TimeFromUnix(baseTableStart.Unix())},
+				IndexTables: PeriodicTableConfig{
+					Prefix:     tablePrefix,
+					Period:     tablePeriod,
+					NameFormat: `{{.Prefix}}{{.WeekYear}}_{{printf "%02d" .Week}}`,
+				},
+			},
+		},
+	}
+	tbmConfig := TableManagerConfig{
+		RetentionPeriod:         tableRetention,
+		RetentionDeletesEnabled: true,
+		CreationGracePeriod:     gracePeriod,
+	}
+	tableManager, err := NewTableManager(tbmConfig, cfg, maxChunkAge, client, nil, nil, nil, nil)
+	require.NoError(t, err)
+
+	tmTest(t, client, tableManager,
+		"Initial test",
+		baseTableStart,
+		[]TableDesc{
+			{Name: tablePrefix + "1970_01"},
+		},
+	)
+
+	// Tables out of the retention period are deleted.
+	tmTest(t, client, tableManager,
+		"Move forward by four table periods",
+		baseTableStart.Add(tablePeriod*4),
+		[]TableDesc{
+			{Name: tablePrefix + "1970_03"},
+			{Name: tablePrefix + "1970_04"},
+			{Name: tablePrefix + "1970_05"},
+		},
+	)
+}
+
+func TestTableManager_AddPeriodConfig(t *testing.T) {
+	client := newMockTableClient()
+
+	cfg := SchemaConfig{
+		Configs: []PeriodConfig{
+			{
+				From:        DayTime{model.TimeFromUnix(0)},
+				IndexTables: PeriodicTableConfig{Prefix: tablePrefix, Period: tablePeriod},
+			},
+		},
+	}
+	tableManager, err := NewTableManager(TableManagerConfig{CreationGracePeriod: gracePeriod}, cfg, maxChunkAge, client, nil, nil, nil, nil)
+	require.NoError(t, err)
+
+	newPeriod := PeriodConfig{
+		From:        DayTime{model.TimeFromUnix(baseTableStart.Add(tablePeriod).Unix())},
+		IndexTables: PeriodicTableConfig{Prefix: table2Prefix, Period: tablePeriod},
+	}
+	require.Equal(t, errSchemaIncreasingFromTime, tableManager.AddPeriodConfig(cfg.Configs[0]))
+	require.NoError(t, tableManager.AddPeriodConfig(newPeriod))
+
+	// the tables of the new period are created ahead of its start.
+	tmTest(t, client, tableManager,
+		"Grace period before the new period",
+		baseTableStart.Add(tablePeriod).Add(-gracePeriod+time.Second),
+		[]TableDesc{
+			{Name: tablePrefix + "0"},
+			{Name: table2Prefix + "1"},
+		},
+	)
+}
+
+func TestTableManagerTenantConfigs(t *testing.T) {
+	client := newMockTableClient()
+
+	cfg := SchemaConfig{
+		Configs: []PeriodConfig{
+			{
+				From:        DayTime{model.TimeFromUnix(baseTableStart.Unix())},
+				IndexTables: PeriodicTableConfig{Prefix: tablePrefix, Period: tablePeriod},
+			},
+		},
+		TenantConfigs: map[string]*SchemaConfig{
+			"tenant": {Configs: []PeriodConfig{
+				{
+					From:        DayTime{model.TimeFromUnix(baseTableStart.Unix())},
+					IndexTables: PeriodicTableConfig{Prefix: tablePrefix, Period: tablePeriod},
+				},
+				{
+					From:            DayTime{model.TimeFromUnix(baseTableStart.Add(tablePeriod).Unix())},
+					IndexTables:     PeriodicTableConfig{Prefix: table2Prefix, Period: tablePeriod},
+					RetentionPeriod: model.Duration(tableRetention),
+				},
+			}},
+		},
+	}
+	tbmConfig := TableManagerConfig{
+		RetentionDeletesEnabled: true,
+		CreationGracePeriod:     gracePeriod,
+	}
+	tableManager, err := NewTableManager(tbmConfig, cfg, maxChunkAge, client, nil, nil, nil, nil)
+	require.NoError(t, err)
+
+	// the tables of the tenant periods are created along with the global ones, the shared ones once.
+	tmTest(t, client, tableManager,
+		"Start of the second period of the tenant",
+		baseTableStart.Add(tablePeriod),
+		[]TableDesc{
+			{Name: tablePrefix + "0"},
+			{Name: tablePrefix + "1"},
+			{Name: table2Prefix + "1"},
+		},
+	)
+
+	// and dropped once out of the retention of the tenant period.
+	tmTest(t, client, tableManager,
+		"Move out of the retention of the tenant period",
+		baseTableStart.Add(tablePeriod*4+24*time.Hour),
+		[]TableDesc{
+			{Name: tablePrefix + "0"},
+			{Name: tablePrefix + "1"},
+			{Name: tablePrefix + "2"},
+			{Name: tablePrefix + "3"},
+			{Name: tablePrefix + "4"},
+			{Name: table2Prefix + "2"},
+			{Name: table2Prefix + "3"},
+			{Name: table2Prefix + "4"},
+		},
+	)
+}
+
+func TestTableManagerTenantIndexTypes(t *testing.T) {
+	client, tsdbClient := newMockTableClient(), newMockTableClient()
+
+	cfg := SchemaConfig{
+		Configs: []PeriodConfig{
+			{
+				From:        DayTime{model.TimeFromUnix(baseTableStart.Unix())},
+				IndexType:   "boltdb-shipper",
+				IndexTables: PeriodicTableConfig{Prefix: tablePrefix, Period: tablePeriod},
+			},
+		},
+		TenantConfigs: map[string]*SchemaConfig{
+			"tenant": {Configs: []PeriodConfig{
+				{
+					From:        DayTime{model.TimeFromUnix(baseTableStart.Unix())},
+					IndexType:   "boltdb-shipper",
+					IndexTables: PeriodicTableConfig{Prefix: tablePrefix, Period: tablePeriod},
+				},
+				{
+					From:            DayTime{model.TimeFromUnix(baseTableStart.Add(tablePeriod).Unix())},
+					IndexType:       "tsdb",
+					IndexTables:     PeriodicTableConfig{Prefix: tablePrefix + "tsdb_", Period: tablePeriod},
+					RetentionPeriod: model.Duration(tableRetention),
+				},
+			}},
+		},
+	}
+	// a table of the global periods found in the store of the tenant index type isn't managed with it.
+	require.NoError(t, tsdbClient.CreateTable(context.Background(), TableDesc{Name: tablePrefix + "0"}))
+
+	tbmConfig := TableManagerConfig{
+		RetentionDeletesEnabled: true,
+		CreationGracePeriod:     gracePeriod,
+	}
+	tableClient := NewIndexTypeTableClient(client, map[string]TableClient{"tsdb": tsdbClient}, cfg)
+	tableManager, err := NewTableManager(tbmConfig, cfg, maxChunkAge, tableClient, nil, nil, nil, nil)
+	require.NoError(t, err)
+
+	// the tables of the tenant period using its own index type are created with the client of that index type.
+	mtime.NowForce(baseTableStart.Add(tablePeriod * 2))
+	require.NoError(t, tableManager.SyncTables(context.Background()))
+	require.NoError(t, ExpectTables(context.Background(), client, []TableDesc{
+		{Name: tablePrefix + "0"},
+		{Name: tablePrefix + "1"},
+		{Name: tablePrefix + "2"},
+	}))
+	require.NoError(t, ExpectTables(context.Background(), tsdbClient, []TableDesc{
+		{Name: tablePrefix + "0"},
+		{Name: tablePrefix + "tsdb_1"},
+		{Name: tablePrefix + "tsdb_2"},
+	}))
+
+	// and dropped with it once out of the retention of the tenant period.
+	mtime.NowForce(baseTableStart.Add(tablePeriod*4 + 24*time.Hour))
+	defer mtime.NowReset()
+	require.NoError(t, tableManager.SyncTables(context.Background()))
+	require.NoError(t, ExpectTables(context.Background(), tsdbClient, []TableDesc{
+		{Name: tablePrefix + "0"},
+		{Name: tablePrefix + "tsdb_2"},
+		{Name: tablePrefix + "tsdb_3"},
+		{Name: tablePrefix + "tsdb_4"},
+	}))
+}
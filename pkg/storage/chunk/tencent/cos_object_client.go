@@ -0,0 +1,200 @@
+package tencent
+
+import (
+	"crypto/hmac"
+	"crypto/sha1" //nolint:gosec // COS signatures are HMAC-SHA1.
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grafana/dskit/backoff"
+	"github.com/grafana/dskit/flagext"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/weaveworks/common/instrument"
+
+	"github.com/grafana/loki/pkg/storage/chunk/hedging"
+	"github.com/grafana/loki/pkg/storage/chunk/restclient"
+	"github.com/grafana/loki/pkg/util/log"
+)
+
+// signatureValidity is the duration the signatures of the requests are valid for.
+const signatureValidity = time.Hour
+
+var cosRequestDuration = instrument.NewHistogramCollector(prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "loki",
+	Name:      "cos_request_duration_seconds",
+	Help:      "Time spent doing Tencent Cloud COS requests.",
+	Buckets:   []float64{.025, .05, .1, .25, .5, 1, 2},
+}, []string{"operation", "status_code"}))
+
+func init() {
+	cosRequestDuration.Register()
+}
+
+// CosConfig is config for the Tencent Cloud COS Chunk Client.
+type CosConfig struct {
+	Region         string         `yaml:"region"`
+	Bucket         string         `yaml:"bucket"`
+	Endpoint       string         `yaml:"endpoint"`
+	SecretID       string         `yaml:"secret_id"`
+	SecretKey      flagext.Secret `yaml:"secret_key"`
+	Insecure       bool           `yaml:"insecure"`
+	RequestTimeout time.Duration  `yaml:"request_timeout"`
+	BackoffConfig  backoff.Config `yaml:"backoff_config"`
+}
+
+// RegisterFlags registers flags.
+func (cfg *CosConfig) RegisterFlags(f *flag.FlagSet) {
+	cfg.RegisterFlagsWithPrefix("", f)
+}
+
+// RegisterFlagsWithPrefix registers flags with prefix.
+func (cfg *CosConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.StringVar(&cfg.Region, prefix+"cos.region", "", "Region of the COS bucket, e.g. ap-guangzhou.")
+	f.StringVar(&cfg.Bucket, prefix+"cos.bucketname", "", "Name of the COS bucket to put chunks in, suffixed by the APPID of the account, e.g. loki-1250000000.")
+	f.StringVar(&cfg.Endpoint, prefix+"cos.endpoint", "", "Endpoint of the COS service, overriding the endpoint of the region cos.<region>.myqcloud.com.")
+	f.StringVar(&cfg.SecretID, prefix+"cos.secret-id", "", "Tencent Cloud SecretId.")
+	f.Var(&cfg.SecretKey, prefix+"cos.secret-key", "Tencent Cloud SecretKey.")
+	f.BoolVar(&cfg.Insecure, prefix+"cos.insecure", false, "Connect to the COS endpoint over HTTP instead of HTTPS.")
+	f.DurationVar(&cfg.RequestTimeout, prefix+"cos.request-timeout", 30*time.Second, "Timeout of a request to COS.")
+	f.DurationVar(&cfg.BackoffConfig.MinBackoff, prefix+"cos.min-backoff", 100*time.Millisecond, "Minimum backoff time when retrying COS requests.")
+	f.DurationVar(&cfg.BackoffConfig.MaxBackoff, prefix+"cos.max-backoff", 3*time.Second, "Maximum backoff time when retrying COS requests.")
+	f.IntVar(&cfg.BackoffConfig.MaxRetries, prefix+"cos.max-retries", 5, "Maximum number of times to retry COS requests.")
+}
+
+// Validate config and returns error on failure
+func (cfg *CosConfig) Validate() error {
+	if cfg.Bucket == "" || (cfg.Region == "" && cfg.Endpoint == "") {
+		return errors.New("the COS bucket and region or endpoint must be set")
+	}
+	return nil
+}
+
+func (cfg *CosConfig) endpoint() string {
+	if cfg.Endpoint != "" {
+		return cfg.Endpoint
+	}
+	return fmt.Sprintf("cos.%s.myqcloud.com", cfg.Region)
+}
+
+// CosObjectClient stores the chunks in a Tencent Cloud COS bucket, through the XML API of COS.
+type CosObjectClient struct {
+	*restclient.Client
+}
+
+// NewCosObjectClient makes a new chunk.Client that writes chunks to Tencent Cloud COS.
+func NewCosObjectClient(cfg CosConfig, hedgingCfg hedging.Config) (*CosObjectClient, error) {
+	log.WarnExperimentalUse("Tencent Cloud COS Storage", log.Logger)
+	return newCosObjectClient(cfg, hedgingCfg, restclient.NewTransport())
+}
+
+func newCosObjectClient(cfg CosConfig, hedgingCfg hedging.Config, transport http.RoundTripper) (*CosObjectClient, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	api, err := newCosAPI(cfg)
+	if err != nil {
+		return nil, err
+	}
+	client, err := restclient.New(restclient.Config{
+		Service:         "COS",
+		RequestTimeout:  cfg.RequestTimeout,
+		BackoffConfig:   cfg.BackoffConfig,
+		RequestDuration: cosRequestDuration,
+	}, api, hedgingCfg, transport)
+	if err != nil {
+		return nil, err
+	}
+	return &CosObjectClient{Client: client}, nil
+}
+
+// cosAPI builds and signs the requests of the XML API of COS, which is compatible with the XML API of S3.
+type cosAPI struct {
+	restclient.XMLAPI
+
+	cfg     CosConfig
+	baseURL *url.URL
+}
+
+func newCosAPI(cfg CosConfig) (*cosAPI, error) {
+	scheme := "https"
+	if cfg.Insecure {
+		scheme = "http"
+	}
+	// the buckets are addressed by virtual host.
+	baseURL, err := url.Parse(fmt.Sprintf("%s://%s.%s", scheme, cfg.Bucket, cfg.endpoint()))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid COS endpoint")
+	}
+	return &cosAPI{cfg: cfg, baseURL: baseURL}, nil
+}
+
+// URL implements restclient.Provider.
+func (a *cosAPI) URL(objectKey string, query url.Values) url.URL {
+	u := *a.baseURL
+	u.Path = "/" + objectKey
+	u.RawQuery = query.Encode()
+	return u
+}
+
+// Sign implements restclient.Provider, adding the signature of COS to the request, which
+// is computed over the method, the path, the query parameters and the host of the request.
+func (a *cosAPI) Sign(req *http.Request, objectKey string, query url.Values) {
+	now := time.Now()
+	keyTime := fmt.Sprintf("%d;%d", now.Unix(), now.Add(signatureValidity).Unix())
+	headers := url.Values{"Host": []string{req.URL.Host}}
+	paramList, params := canonicalize(query)
+	headerList, signedHeaders := canonicalize(headers)
+
+	httpString := strings.ToLower(req.Method) + "\n/" + objectKey + "\n" + params + "\n" + signedHeaders + "\n"
+	httpStringHash := sha1.Sum([]byte(httpString)) //nolint:gosec
+	stringToSign := "sha1\n" + keyTime + "\n" + hex.EncodeToString(httpStringHash[:]) + "\n"
+	signKey := hmacSHA1([]byte(a.cfg.SecretKey.Value), keyTime)
+	signature := hmacSHA1([]byte(signKey), stringToSign)
+
+	req.Header.Set("Authorization", strings.Join([]string{
+		"q-sign-algorithm=sha1",
+		"q-ak=" + a.cfg.SecretID,
+		"q-sign-time=" + keyTime,
+		"q-key-time=" + keyTime,
+		"q-header-list=" + headerList,
+		"q-url-param-list=" + paramList,
+		"q-signature=" + signature,
+	}, "&"))
+}
+
+// canonicalize returns the sorted lower case names of the values joined by `;`, and
+// the values as `name=value` joined by `&`, as they are signed by COS.
+func canonicalize(values url.Values) (string, string) {
+	names := make([]string, 0, len(values))
+	encoded := make(map[string]string, len(values))
+	for name := range values {
+		key := strings.ToLower(escape(name))
+		names = append(names, key)
+		encoded[key] = escape(values.Get(name))
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, name+"="+encoded[name])
+	}
+	return strings.Join(names, ";"), strings.Join(pairs, "&")
+}
+
+func escape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func hmacSHA1(key []byte, s string) string {
+	mac := hmac.New(sha1.New, key)
+	_, _ = mac.Write([]byte(s))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,96 @@
+package tencent
+
+import (
+	"crypto/hmac"
+	"crypto/sha1" //nolint:gosec
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/dskit/backoff"
+	"github.com/grafana/dskit/flagext"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/pkg/storage/chunk/hedging"
+	"github.com/grafana/loki/pkg/storage/chunk/restclient/restclienttest"
+)
+
+// validSignature checks the signature of COS of the request.
+func validSignature(r *http.Request, secret string) bool {
+	auth := url.Values{}
+	for _, pair := range strings.Split(r.Header.Get("Authorization"), "&") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return false
+		}
+		auth.Set(parts[0], parts[1])
+	}
+	if auth.Get("q-sign-algorithm") != "sha1" || auth.Get("q-ak") != "id" || auth.Get("q-header-list") != "host" {
+		return false
+	}
+	keyTime := auth.Get("q-key-time")
+	var start, end int64
+	if _, err := fmt.Sscanf(keyTime, "%d;%d", &start, &end); err != nil || time.Now().Unix() < start || time.Now().Unix() > end {
+		return false
+	}
+
+	var names, params []string
+	for name, values := range r.URL.Query() {
+		names = append(names, name)
+		params = append(params, name+"="+strings.ReplaceAll(url.QueryEscape(values[0]), "+", "%20"))
+	}
+	sort.Strings(names)
+	sort.Strings(params)
+	if auth.Get("q-url-param-list") != strings.Join(names, ";") {
+		return false
+	}
+
+	httpString := strings.ToLower(r.Method) + "\n" + r.URL.Path + "\n" + strings.Join(params, "&") + "\nhost=" + url.QueryEscape(r.Host) + "\n"
+	httpStringHash := sha1.Sum([]byte(httpString)) //nolint:gosec
+	mac := hmac.New(sha1.New, []byte(secret))
+	_, _ = mac.Write([]byte(keyTime))
+	mac = hmac.New(sha1.New, []byte(hex.EncodeToString(mac.Sum(nil))))
+	_, _ = mac.Write([]byte("sha1\n" + keyTime + "\n" + hex.EncodeToString(httpStringHash[:]) + "\n"))
+	return auth.Get("q-signature") == hex.EncodeToString(mac.Sum(nil))
+}
+
+func newTestClient(t *testing.T) (*CosObjectClient, *restclienttest.Bucket) {
+	bucket := &restclienttest.Bucket{T: t, Name: "loki-1250000000", Secret: "secret", ValidSignature: validSignature}
+	cfg := CosConfig{
+		Region:        "ap-test",
+		Bucket:        bucket.Name,
+		SecretID:      "id",
+		SecretKey:     flagext.Secret{Value: bucket.Secret},
+		Insecure:      true,
+		BackoffConfig: backoff.Config{MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond, MaxRetries: 3},
+	}
+	api, err := newCosAPI(cfg)
+	require.NoError(t, err)
+	require.Equal(t, "loki-1250000000.cos.ap-test.myqcloud.com", api.baseURL.Host)
+
+	client, err := newCosObjectClient(cfg, hedging.Config{}, bucket.NewTransport())
+	require.NoError(t, err)
+	return client, bucket
+}
+
+func TestCosObjectClient(t *testing.T) {
+	client, bucket := newTestClient(t)
+	restclienttest.TestObjectClient(t, client, bucket)
+}
+
+func TestCosObjectClient_Retries(t *testing.T) {
+	client, bucket := newTestClient(t)
+	restclienttest.TestRetries(t, client, bucket)
+}
+
+func TestCosConfig_Validate(t *testing.T) {
+	require.Error(t, (&CosConfig{Bucket: "loki-1250000000"}).Validate())
+	require.Error(t, (&CosConfig{Region: "ap-guangzhou"}).Validate())
+	require.NoError(t, (&CosConfig{Bucket: "loki-1250000000", Region: "ap-guangzhou"}).Validate())
+	require.NoError(t, (&CosConfig{Bucket: "loki-1250000000", Endpoint: "cos.example.com"}).Validate())
+}
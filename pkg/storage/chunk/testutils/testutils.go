@@ -50,7 +50,7 @@ func Setup(fixture Fixture, tableName string) (chunk.IndexClient, chunk.Client,
 		return nil, nil, nil, err
 	}
 
-	tableManager, err := chunk.NewTableManager(tbmConfig, schemaConfig, 12*time.Hour, tableClient, nil, nil, nil)
+	tableManager, err := chunk.NewTableManager(tbmConfig, schemaConfig, 12*time.Hour, tableClient, nil, nil, nil, nil)
 	if err != nil {
 		return nil, nil, nil, err
 	}
@@ -114,7 +114,7 @@ func SetupTestChunkStoreWithClients(indexClient chunk.IndexClient, chunksClient
 		schemaCfg = chunk.DefaultSchemaConfig("", "v10", 0)
 	)
 	flagext.DefaultValues(&tbmConfig)
-	tableManager, err := chunk.NewTableManager(tbmConfig, schemaCfg, 12*time.Hour, tableClient, nil, nil, nil)
+	tableManager, err := chunk.NewTableManager(tbmConfig, schemaCfg, 12*time.Hour, tableClient, nil, nil, nil, nil)
 	if err != nil {
 		return nil, err
 	}
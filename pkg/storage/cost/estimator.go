@@ -0,0 +1,215 @@
+package cost
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/grafana/loki/pkg/storage/chunk"
+	chunk_storage "github.com/grafana/loki/pkg/storage/chunk/storage"
+	"github.com/grafana/loki/pkg/storage/stores/shipper"
+)
+
+const (
+	indexTypeTSDB = "tsdb"
+
+	// seriesIndexEntryBytes is the average size of an entry of the index stores, with its hash
+	// and range keys and its value.
+	seriesIndexEntryBytes = 96
+	// tsdbLabelBytes is the average size of a label of a series in a TSDB index.
+	tsdbLabelBytes = 16
+	// tsdbChunkMetaBytes is the size of the reference of a chunk in a TSDB index.
+	tsdbChunkMetaBytes = 24
+
+	// firstSeriesStoreSchema is the first schema indexing the chunks by series rather than by label.
+	firstSeriesStoreSchema = 9
+)
+
+// Inputs are the observed ingestion and the ingester settings the costs are projected from.
+type Inputs struct {
+	// IngestionRate is the uncompressed ingestion rate in bytes per second.
+	IngestionRate    float64
+	ActiveStreams    int
+	LabelsPerStream  int
+	CompressionRatio float64
+	// ChunkTargetSize is the compressed size the chunks are cut at.
+	ChunkTargetSize int
+	MaxChunkAge     time.Duration
+	Ingesters       int
+	// Retention is the global retention, zero keeping the data forever. The retention of a
+	// period overrides it.
+	Retention time.Duration
+	// At is the time the stored data is projected at.
+	At time.Time
+}
+
+// Validate validates the inputs.
+func (in Inputs) Validate() error {
+	if in.IngestionRate <= 0 || in.ActiveStreams <= 0 {
+		return errors.New("the ingestion rate and the number of active streams must be positive")
+	}
+	if in.CompressionRatio < 1 {
+		return errors.New("the compression ratio must be at least 1")
+	}
+	if in.ChunkTargetSize <= 0 || in.MaxChunkAge <= 0 {
+		return errors.New("the chunk target size and the max chunk age must be positive")
+	}
+	if in.Ingesters <= 0 {
+		return errors.New("the number of ingesters must be positive")
+	}
+	if in.LabelsPerStream < 0 {
+		return errors.New("the number of labels per stream must not be negative")
+	}
+	return nil
+}
+
+// ChunkSize returns the average compressed size of the chunks, which are cut at the target size
+// or flushed at the max age, whichever comes first.
+func (in Inputs) ChunkSize() float64 {
+	streamRate := in.IngestionRate / in.CompressionRatio / float64(in.ActiveStreams)
+	return math.Min(float64(in.ChunkTargetSize), streamRate*in.MaxChunkAge.Seconds())
+}
+
+// PeriodEstimate is the projection of the data of a schema period stored at the time of the inputs.
+type PeriodEstimate struct {
+	Period chunk.PeriodConfig
+	// From and Through bound the data of the period kept under retention, they are equal when
+	// the period doesn't store any data at that time.
+	From, Through time.Time
+	Retention     time.Duration
+
+	ObjectStore  string
+	Chunks       float64
+	ChunkBytes   float64
+	IndexTables  int
+	IndexObjects float64
+	IndexBytes   float64
+	// PutRequests are the write requests of the chunks and of the index files to the object
+	// store, the reads depending on the queries.
+	PutRequests float64
+}
+
+// Estimate projects the objects, the bytes, the write requests and the index of each period
+// of the schema, for the data kept under retention at the time of the inputs.
+func Estimate(schemaCfg chunk.SchemaConfig, in Inputs) ([]PeriodEstimate, error) {
+	if err := in.Validate(); err != nil {
+		return nil, err
+	}
+	if len(schemaCfg.Configs) == 0 {
+		return nil, errors.New("the schema config doesn't have any period")
+	}
+
+	var (
+		compressedRate = in.IngestionRate / in.CompressionRatio
+		chunkRate      = compressedRate / in.ChunkSize()
+		estimates      = make([]PeriodEstimate, 0, len(schemaCfg.Configs))
+	)
+	for i, period := range schemaCfg.Configs {
+		from, through := period.From.Time.Time(), in.At
+		if i+1 < len(schemaCfg.Configs) {
+			through = minTime(through, schemaCfg.Configs[i+1].From.Time.Time())
+		}
+		retention := period.Retention(in.Retention)
+		if retention > 0 {
+			from = maxTime(from, in.At.Add(-retention))
+		}
+		if through.Before(from) {
+			through = from
+		}
+
+		e := PeriodEstimate{
+			Period:      period,
+			From:        from,
+			Through:     through,
+			Retention:   retention,
+			ObjectStore: period.ObjectType,
+		}
+		if e.ObjectStore == "" {
+			e.ObjectStore = period.IndexType
+		}
+		seconds := through.Sub(from).Seconds()
+		if seconds > 0 {
+			estimateChunks(&e, seconds, chunkRate, compressedRate)
+			if err := estimateIndex(&e, in, seconds, chunkRate); err != nil {
+				return nil, err
+			}
+		}
+		estimates = append(estimates, e)
+	}
+	return estimates, nil
+}
+
+func estimateChunks(e *PeriodEstimate, seconds, chunkRate, compressedRate float64) {
+	e.Chunks = chunkRate * seconds
+	e.ChunkBytes = compressedRate * seconds
+	// the chunks are written in the index store when it isn't an object store.
+	if chunk_storage.IsObjectStore(e.ObjectStore) {
+		e.PutRequests = e.Chunks
+	}
+}
+
+func estimateIndex(e *PeriodEstimate, in Inputs, seconds, chunkRate float64) error {
+	e.IndexTables = tablesBetween(e.From, e.Through, e.Period.IndexTables.Period)
+
+	// the series are indexed again in each bucket of the index, a day by default.
+	bucketPeriod := e.Period.BucketPeriod
+	if bucketPeriod == 0 {
+		bucketPeriod = 24 * time.Hour
+	}
+	buckets := math.Ceil(seconds / bucketPeriod.Seconds())
+	series := float64(in.ActiveStreams) * buckets
+	chunks := chunkRate * seconds
+
+	switch e.Period.IndexType {
+	case indexTypeTSDB:
+		e.IndexBytes = series*float64(in.LabelsPerStream)*tsdbLabelBytes + chunks*tsdbChunkMetaBytes
+	default:
+		schema, err := e.Period.VersionAsInt()
+		if err != nil {
+			return err
+		}
+		if schema >= firstSeriesStoreSchema {
+			// the labels are indexed once per series and bucket, and the chunks once per series.
+			e.IndexBytes = (series*float64(2*in.LabelsPerStream+1) + chunks) * seriesIndexEntryBytes
+		} else {
+			// the older schemas index each chunk by each of its labels.
+			e.IndexBytes = chunks * float64(in.LabelsPerStream+1) * seriesIndexEntryBytes
+		}
+	}
+
+	// the shippers upload the index files of the ingesters to the object store, and the
+	// compactor merges them into a file per table.
+	if e.Period.IndexType == shipper.BoltDBShipperType || e.Period.IndexType == indexTypeTSDB {
+		e.IndexObjects = float64(e.IndexTables)
+		e.PutRequests += float64(in.Ingesters)*seconds/shipper.UploadInterval.Seconds() + float64(e.IndexTables)
+	}
+	return nil
+}
+
+// tablesBetween returns the number of periodic tables holding the data between from and through,
+// a single table when the tables aren't periodic.
+func tablesBetween(from, through time.Time, period time.Duration) int {
+	if !through.After(from) {
+		return 0
+	}
+	if period <= 0 {
+		return 1
+	}
+	first := from.UnixNano() / int64(period)
+	last := (through.UnixNano() - 1) / int64(period)
+	return int(last-first) + 1
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,106 @@
+package cost
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/pkg/storage/chunk"
+)
+
+func dayTime(s string) chunk.DayTime {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return chunk.DayTime{Time: model.TimeFromUnix(t.Unix())}
+}
+
+func TestEstimate(t *testing.T) {
+	schemaCfg := chunk.SchemaConfig{Configs: []chunk.PeriodConfig{
+		{From: dayTime("2022-01-01"), IndexType: "boltdb-shipper", ObjectType: "s3", Schema: "v11", IndexTables: chunk.PeriodicTableConfig{Period: 24 * time.Hour}},
+		{From: dayTime("2022-03-01"), IndexType: "tsdb", ObjectType: "gcs", Schema: "v12", IndexTables: chunk.PeriodicTableConfig{Period: 24 * time.Hour}},
+	}}
+	in := Inputs{
+		IngestionRate:    10 << 20,
+		ActiveStreams:    1000,
+		LabelsPerStream:  5,
+		CompressionRatio: 10,
+		ChunkTargetSize:  1 << 20,
+		MaxChunkAge:      2 * time.Hour,
+		Ingesters:        3,
+		Retention:        30 * 24 * time.Hour,
+		At:               time.Date(2022, 3, 11, 0, 0, 0, 0, time.UTC),
+	}
+	// 1MB/s compressed over 1000 streams fill 7.2MB in 2h, the chunks are cut at the target size.
+	require.Equal(t, float64(1<<20), in.ChunkSize())
+
+	estimates, err := Estimate(schemaCfg, in)
+	require.NoError(t, err)
+	require.Len(t, estimates, 2)
+
+	// the retention keeps the last 20 days of the first period and the 10 days of the second one.
+	first, second := estimates[0], estimates[1]
+	require.Equal(t, time.Date(2022, 2, 9, 0, 0, 0, 0, time.UTC), first.From.UTC())
+	require.Equal(t, time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC), first.Through.UTC())
+	require.Equal(t, "s3", first.ObjectStore)
+	require.Equal(t, 20, first.IndexTables)
+	require.Equal(t, float64(20), first.IndexObjects)
+	require.InDelta(t, 20*86400, first.Chunks, 1)
+	require.InDelta(t, 20*86400*(1<<20), first.ChunkBytes, 1)
+	// a chunk put per chunk, an index upload per ingester and minute, and the compacted tables.
+	require.InDelta(t, 20*86400+3*20*1440+20, first.PutRequests, 1)
+	require.InDelta(t, (1000*20*11+20*86400)*seriesIndexEntryBytes, first.IndexBytes, 1)
+
+	require.Equal(t, 10, second.IndexTables)
+	require.InDelta(t, 10*86400, second.Chunks, 1)
+	require.InDelta(t, 1000*10*5*tsdbLabelBytes+10*86400*tsdbChunkMetaBytes, second.IndexBytes, 1)
+}
+
+func TestEstimate_FuturePeriod(t *testing.T) {
+	schemaCfg := chunk.SchemaConfig{Configs: []chunk.PeriodConfig{
+		{From: dayTime("2022-01-01"), IndexType: "bigtable", Schema: "v6", RowShards: 16},
+		{From: dayTime("2022-06-01"), IndexType: "boltdb-shipper", ObjectType: "s3", Schema: "v11", IndexTables: chunk.PeriodicTableConfig{Period: 24 * time.Hour}},
+	}}
+	in := Inputs{
+		IngestionRate:    1 << 20,
+		ActiveStreams:    100,
+		LabelsPerStream:  3,
+		CompressionRatio: 4,
+		ChunkTargetSize:  16 << 20,
+		MaxChunkAge:      time.Hour,
+		Ingesters:        1,
+		At:               time.Date(2022, 1, 2, 0, 0, 0, 0, time.UTC),
+	}
+	// 2.5KB/s per stream only fill 9MB in an hour, the chunks are flushed at the max age.
+	require.Equal(t, float64(1<<20)/4/100*3600, in.ChunkSize())
+
+	estimates, err := Estimate(schemaCfg, in)
+	require.NoError(t, err)
+
+	// the chunks are kept forever in the index store, which isn't an object store.
+	require.Equal(t, "bigtable", estimates[0].ObjectStore)
+	require.InDelta(t, 100*24, estimates[0].Chunks, 0.001)
+	require.Zero(t, estimates[0].PutRequests)
+	require.Zero(t, estimates[0].IndexObjects)
+	require.Equal(t, 1, estimates[0].IndexTables)
+	require.InDelta(t, 100*24*4*seriesIndexEntryBytes, estimates[0].IndexBytes, 0.001)
+
+	// the period doesn't hold any data yet.
+	require.Equal(t, estimates[1].From, estimates[1].Through)
+	require.Zero(t, estimates[1].Chunks)
+	require.Zero(t, estimates[1].IndexTables)
+}
+
+func TestInputs_Validate(t *testing.T) {
+	in := Inputs{IngestionRate: 1, ActiveStreams: 1, CompressionRatio: 1, ChunkTargetSize: 1, MaxChunkAge: time.Hour, Ingesters: 1}
+	require.NoError(t, in.Validate())
+
+	in.CompressionRatio = 0.5
+	require.Error(t, in.Validate())
+
+	_, err := Estimate(chunk.SchemaConfig{}, Inputs{})
+	require.Error(t, err)
+}
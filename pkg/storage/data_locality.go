@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/common/model"
+
+	"github.com/grafana/loki/pkg/storage/chunk"
+	"github.com/grafana/loki/pkg/storage/chunk/storage"
+	"github.com/grafana/loki/pkg/storage/stores/shipper"
+	"github.com/grafana/loki/pkg/storage/tsdb"
+	"github.com/grafana/loki/pkg/validation"
+)
+
+// DataLocalityLimits pins the data of the tenants to stores, e.g. to keep it in a region.
+type DataLocalityLimits interface {
+	// AllowedObjectStores returns the stores the data of the tenant is pinned to, empty when it isn't pinned.
+	AllowedObjectStores(userID string) []string
+	AllByUserID() map[string]*validation.Limits
+}
+
+// DataLocalityError is returned when the data of a tenant would be written to or read from a store it isn't pinned to.
+type DataLocalityError struct {
+	UserID  string
+	Store   string
+	Period  chunk.DayTime
+	Allowed []string
+}
+
+func (e *DataLocalityError) Error() string {
+	tenant := "tenant " + e.UserID
+	if e.UserID == "" {
+		tenant = "the tenants"
+	}
+	return fmt.Sprintf("the data of %s is pinned to the stores %s, the period config starting at %s uses the store %s",
+		tenant, strings.Join(e.Allowed, ", "), e.Period.String(), e.Store)
+}
+
+// PeriodStores returns the stores holding the chunks and the index of the period, the secondary store mirroring the
+// object stores included. The object stores are qualified by their buckets, as `<store>:<bucket>`, when configured.
+func (cfg *Config) PeriodStores(p chunk.PeriodConfig) []string {
+	chunkStore := p.ObjectType
+	if chunkStore == "" {
+		chunkStore = p.IndexType
+	}
+	indexStore := p.IndexType
+	// the index store is an object store only when shipped.
+	var indexBuckets []string
+	switch p.IndexType {
+	case shipper.BoltDBShipperType:
+		indexStore = cfg.BoltDBShipperConfig.SharedStoreType
+		indexBuckets = cfg.ObjectStoreBuckets(indexStore)
+	case tsdb.IndexType:
+		indexStore = cfg.TSDBShipperConfig.SharedStoreType
+		indexBuckets = cfg.ObjectStoreBuckets(indexStore)
+	}
+	var chunkBuckets []string
+	if p.ObjectType != "" {
+		chunkBuckets = cfg.ObjectStoreBuckets(chunkStore)
+	}
+
+	var stores []string
+	add := func(store string, buckets []string) {
+		for _, s := range qualifiedStores(store, buckets) {
+			if !contains(stores, s) {
+				stores = append(stores, s)
+			}
+		}
+	}
+	add(chunkStore, chunkBuckets)
+	add(indexStore, indexBuckets)
+	if cfg.Mirror.Store != "" && (storage.IsObjectStore(chunkStore) || storage.IsObjectStore(indexStore)) {
+		add(cfg.Mirror.Store, cfg.MirrorBuckets())
+	}
+	return stores
+}
+
+// qualifiedStores returns the store qualified by each of its buckets, or the store alone without buckets.
+func qualifiedStores(store string, buckets []string) []string {
+	if len(buckets) == 0 {
+		return []string{store}
+	}
+	stores := make([]string, 0, len(buckets))
+	for _, bucket := range buckets {
+		stores = append(stores, store+":"+bucket)
+	}
+	return stores
+}
+
+// isAllowedStore returns whether the store, qualified by its bucket or not, is allowed. A store allowed without a
+// bucket allows all its buckets.
+func isAllowedStore(allowed []string, store string) bool {
+	if contains(allowed, store) {
+		return true
+	}
+	if i := strings.Index(store, ":"); i >= 0 {
+		return contains(allowed, store[:i])
+	}
+	return false
+}
+
+// checkDataLocality returns a DataLocalityError when a period of the tenant overlapping the time range uses a store
+// the tenant isn't pinned to.
+func checkDataLocality(cfg *Config, schemaCfg chunk.SchemaConfig, userID string, allowed []string, from, through model.Time) error {
+	if len(allowed) == 0 {
+		return nil
+	}
+	configs := schemaCfg.ForTenant(userID).Configs
+	for i, p := range configs {
+		if p.From.Time > through || (i+1 < len(configs) && configs[i+1].From.Time <= from) {
+			continue
+		}
+		for _, s := range cfg.PeriodStores(p) {
+			if !isAllowedStore(allowed, s) {
+				return &DataLocalityError{UserID: userID, Store: s, Period: p.From, Allowed: allowed}
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateDataLocality returns an error when the current or upcoming periods of a pinned tenant, from its own schema
+// config or the global one, use a store it isn't pinned to. The past periods are only refused when read.
+func ValidateDataLocality(cfg *Config, schemaCfg chunk.SchemaConfig, limits DataLocalityLimits, now model.Time) error {
+	// the tenants without overrides are pinned by the default limits, under the empty tenant ID.
+	tenants := map[string]struct{}{"": {}}
+	for userID := range limits.AllByUserID() {
+		tenants[userID] = struct{}{}
+	}
+	for userID := range schemaCfg.TenantConfigs {
+		tenants[userID] = struct{}{}
+	}
+	userIDs := make([]string, 0, len(tenants))
+	for userID := range tenants {
+		userIDs = append(userIDs, userID)
+	}
+	sort.Strings(userIDs)
+
+	for _, userID := range userIDs {
+		if err := checkDataLocality(cfg, schemaCfg, userID, limits.AllowedObjectStores(userID), now, model.Latest); err != nil {
+			if userID == "" {
+				return fmt.Errorf("invalid default allowed object stores: %w", err)
+			}
+			return fmt.Errorf("invalid allowed object stores: %w", err)
+		}
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
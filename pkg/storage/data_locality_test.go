@@ -0,0 +1,200 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/pkg/storage/chunk"
+	"github.com/grafana/loki/pkg/validation"
+)
+
+// fakeDataLocalityLimits pins the tenants to the stores by tenant ID, the empty ID being the default.
+type fakeDataLocalityLimits map[string][]string
+
+func (l fakeDataLocalityLimits) AllowedObjectStores(userID string) []string {
+	if allowed, ok := l[userID]; ok {
+		return allowed
+	}
+	return l[""]
+}
+
+func (l fakeDataLocalityLimits) AllByUserID() map[string]*validation.Limits {
+	all := map[string]*validation.Limits{}
+	for userID := range l {
+		if userID != "" {
+			all[userID] = &validation.Limits{}
+		}
+	}
+	return all
+}
+
+func dayTime(t time.Time) chunk.DayTime {
+	return chunk.DayTime{Time: model.TimeFromUnixNano(t.UnixNano())}
+}
+
+func TestConfig_PeriodStores(t *testing.T) {
+	var cfg Config
+	cfg.BoltDBShipperConfig.SharedStoreType = "gcs"
+	cfg.TSDBShipperConfig.SharedStoreType = "s3"
+
+	for _, tc := range []struct {
+		name     string
+		period   chunk.PeriodConfig
+		mirror   string
+		expected []string
+	}{
+		{
+			name:     "boltdb-shipper",
+			period:   chunk.PeriodConfig{IndexType: "boltdb-shipper", ObjectType: "gcs"},
+			expected: []string{"gcs"},
+		},
+		{
+			name:     "index in the shared store of another type",
+			period:   chunk.PeriodConfig{IndexType: "tsdb", ObjectType: "gcs"},
+			expected: []string{"gcs", "s3"},
+		},
+		{
+			name:     "chunks in the index store",
+			period:   chunk.PeriodConfig{IndexType: "cassandra"},
+			expected: []string{"cassandra"},
+		},
+		{
+			name:     "mirrored object store",
+			period:   chunk.PeriodConfig{IndexType: "boltdb-shipper", ObjectType: "gcs"},
+			mirror:   "azure",
+			expected: []string{"gcs", "azure"},
+		},
+		{
+			name:     "mirror ignored without object store",
+			period:   chunk.PeriodConfig{IndexType: "cassandra"},
+			mirror:   "azure",
+			expected: []string{"cassandra"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := cfg
+			cfg.Mirror.Store = tc.mirror
+			require.Equal(t, tc.expected, cfg.PeriodStores(tc.period))
+		})
+	}
+}
+
+func TestConfig_PeriodStoresBuckets(t *testing.T) {
+	var cfg Config
+	cfg.BoltDBShipperConfig.SharedStoreType = "gcs"
+	cfg.GCSConfig.BucketName = "eu-chunks"
+	cfg.AWSStorageConfig.S3Config.BucketNames = "eu-chunks-1, eu-chunks-2"
+	cfg.Mirror.Store = "s3"
+	cfg.Mirror.S3.BucketNames = "us-mirror"
+
+	require.Equal(t, []string{"gcs:eu-chunks", "s3:us-mirror"}, cfg.PeriodStores(chunk.PeriodConfig{IndexType: "boltdb-shipper", ObjectType: "gcs"}))
+	require.Equal(t, []string{"s3:eu-chunks-1", "s3:eu-chunks-2", "gcs:eu-chunks", "s3:us-mirror"}, cfg.PeriodStores(chunk.PeriodConfig{IndexType: "boltdb-shipper", ObjectType: "s3"}))
+	// the stores which aren't object stores have no buckets.
+	require.Equal(t, []string{"cassandra"}, cfg.PeriodStores(chunk.PeriodConfig{IndexType: "cassandra"}))
+
+	cfg.Mirror.Store = "azure"
+	cfg.Mirror.Azure.AccountName = "us"
+	cfg.Mirror.Azure.ContainerName = "loki"
+	require.Equal(t, []string{"gcs:eu-chunks", "azure:us/loki"}, cfg.PeriodStores(chunk.PeriodConfig{IndexType: "boltdb-shipper", ObjectType: "gcs"}))
+}
+
+func TestValidateDataLocality(t *testing.T) {
+	var cfg Config
+	cfg.BoltDBShipperConfig.SharedStoreType = "gcs"
+
+	now := time.Date(2022, 6, 15, 0, 0, 0, 0, time.UTC)
+	schemaCfg := chunk.SchemaConfig{
+		Configs: []chunk.PeriodConfig{
+			{From: dayTime(now.Add(-30 * 24 * time.Hour)), IndexType: "boltdb-shipper", ObjectType: "s3"},
+			{From: dayTime(now.Add(-24 * time.Hour)), IndexType: "boltdb-shipper", ObjectType: "gcs"},
+		},
+		TenantConfigs: map[string]*chunk.SchemaConfig{
+			"eu": {Configs: []chunk.PeriodConfig{
+				{From: dayTime(now.Add(-30 * 24 * time.Hour)), IndexType: "boltdb-shipper", ObjectType: "gcs"},
+				{From: dayTime(now.Add(24 * time.Hour)), IndexType: "boltdb-shipper", ObjectType: "azure"},
+			}},
+		},
+	}
+
+	for _, tc := range []struct {
+		name   string
+		limits fakeDataLocalityLimits
+		err    string
+	}{
+		{
+			name:   "not pinned",
+			limits: fakeDataLocalityLimits{},
+		},
+		{
+			name:   "past period ignored",
+			limits: fakeDataLocalityLimits{"": {"gcs"}, "eu": {"gcs", "azure"}},
+		},
+		{
+			name:   "default refused",
+			limits: fakeDataLocalityLimits{"": {"s3"}},
+			err:    "invalid default allowed object stores: the data of the tenants is pinned to the stores s3, the period config starting at 2022-06-14 uses the store gcs",
+		},
+		{
+			name:   "upcoming period of the tenant refused",
+			limits: fakeDataLocalityLimits{"eu": {"gcs"}},
+			err:    "invalid allowed object stores: the data of tenant eu is pinned to the stores gcs, the period config starting at 2022-06-16 uses the store azure",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateDataLocality(&cfg, schemaCfg, tc.limits, model.TimeFromUnixNano(now.UnixNano()))
+			if tc.err == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tc.err)
+			var localityErr *DataLocalityError
+			require.True(t, errors.As(err, &localityErr))
+		})
+	}
+}
+
+func Test_checkDataLocality(t *testing.T) {
+	var cfg Config
+	cfg.BoltDBShipperConfig.SharedStoreType = "gcs"
+
+	now := time.Date(2022, 6, 15, 0, 0, 0, 0, time.UTC)
+	schemaCfg := chunk.SchemaConfig{Configs: []chunk.PeriodConfig{
+		{From: dayTime(now.Add(-30 * 24 * time.Hour)), IndexType: "boltdb-shipper", ObjectType: "s3"},
+		{From: dayTime(now.Add(-24 * time.Hour)), IndexType: "boltdb-shipper", ObjectType: "gcs"},
+	}}
+	allowed := []string{"gcs"}
+	at := func(d time.Duration) model.Time {
+		return model.TimeFromUnixNano(now.Add(d).UnixNano())
+	}
+
+	require.NoError(t, checkDataLocality(&cfg, schemaCfg, "user", nil, at(-10*24*time.Hour), at(0)))
+	require.NoError(t, checkDataLocality(&cfg, schemaCfg, "user", allowed, at(-time.Hour), at(0)))
+	require.Error(t, checkDataLocality(&cfg, schemaCfg, "user", allowed, at(-10*24*time.Hour), at(-9*24*time.Hour)))
+	// the ranges overlapping both periods are refused.
+	require.Error(t, checkDataLocality(&cfg, schemaCfg, "user", allowed, at(-2*24*time.Hour), at(0)))
+}
+
+func Test_checkDataLocalityBuckets(t *testing.T) {
+	var cfg Config
+	cfg.BoltDBShipperConfig.SharedStoreType = "s3"
+	cfg.AWSStorageConfig.S3Config.BucketNames = "eu-chunks"
+	cfg.Mirror.Store = "s3"
+	cfg.Mirror.S3.BucketNames = "us-mirror"
+
+	schemaCfg := chunk.SchemaConfig{Configs: []chunk.PeriodConfig{
+		{From: dayTime(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)), IndexType: "boltdb-shipper", ObjectType: "s3"},
+	}}
+
+	// the stores allowed without bucket allow all their buckets.
+	require.NoError(t, checkDataLocality(&cfg, schemaCfg, "user", []string{"s3"}, 0, model.Latest))
+	require.NoError(t, checkDataLocality(&cfg, schemaCfg, "user", []string{"s3:eu-chunks", "s3:us-mirror"}, 0, model.Latest))
+	// the mirror bucket of the same store type is told apart.
+	err := checkDataLocality(&cfg, schemaCfg, "user", []string{"s3:eu-chunks"}, 0, model.Latest)
+	var localityErr *DataLocalityError
+	require.True(t, errors.As(err, &localityErr))
+	require.Equal(t, "s3:us-mirror", localityErr.Store)
+}
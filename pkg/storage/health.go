@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/grafana/loki/pkg/storage/chunk/storage"
+)
+
+// NewHealthChecker makes a health checker of the index and object stores of the schema periods.
+func NewHealthChecker(cfg Config, schemaCfg SchemaConfig, clientMetrics storage.ClientMetrics, logger log.Logger, registerer prometheus.Registerer) (*storage.HealthChecker, error) {
+	var (
+		probes []storage.HealthProbe
+		seen   = map[string]bool{}
+	)
+	for _, period := range schemaCfg.Configs {
+		if !seen[storage.BackendKindIndex+"/"+period.IndexType] {
+			seen[storage.BackendKindIndex+"/"+period.IndexType] = true
+			// the clients of the probes don't register their metrics, not to conflict with the ones of the store.
+			tableClient, err := storage.NewTableClient(period.IndexType, cfg.Config, prometheus.NewRegistry())
+			if err != nil {
+				return nil, err
+			}
+			probes = append(probes, storage.NewIndexStoreProbe(period.IndexType, tableClient))
+		}
+
+		// the chunks not kept in an object store are kept in the index store.
+		objectType := period.ObjectType
+		if objectType == "" {
+			objectType = period.IndexType
+		}
+		if !storage.IsObjectStore(objectType) || seen[storage.BackendKindObject+"/"+objectType] {
+			continue
+		}
+		seen[storage.BackendKindObject+"/"+objectType] = true
+		objectClient, err := storage.NewObjectClient(objectType, cfg.Config, clientMetrics)
+		if err != nil {
+			return nil, err
+		}
+		probes = append(probes, storage.NewObjectStoreProbe(objectType, objectClient, cfg.HealthCheck.CanaryKey))
+	}
+	return storage.NewHealthChecker(cfg.HealthCheck, probes, logger, registerer), nil
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/loki/pkg/storage/chunk"
+	"github.com/grafana/loki/pkg/tenant"
+)
+
+// Stats returns the number of streams and chunks matching the matchers in the time range, and the bytes and entries
+// of the chunks as recorded by the index. The chunks of which the index doesn't record the size, indexed before it
+// was or by index types other than tsdb, are counted with the average size of the others, if any.
+// The chunks aren't fetched: the ones overlapping the time range are counted in full.
+func (s *store) Stats(ctx context.Context, from, through model.Time, matchers ...*labels.Matcher) (*logproto.IndexStatsResponse, error) {
+	userID, err := tenant.TenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	nameLabelMatcher, err := labels.NewMatcher(labels.MatchEqual, labels.MetricName, "logs")
+	if err != nil {
+		return nil, err
+	}
+	chks, _, err := s.GetChunkRefs(ctx, userID, from, through, append(matchers, nameLabelMatcher)...)
+	if err != nil {
+		return nil, err
+	}
+
+	schemaCfg := s.schemaConfig()
+	for i := range chks {
+		chks[i] = filterChunksByTime(from, through, chks[i])
+	}
+	return indexStats(schemaCfg, chks), nil
+}
+
+// indexStats sums the chunks, the ones returned several times, e.g. by the ingesters and the index, counted once.
+func indexStats(schemaCfg chunk.SchemaConfig, chks [][]chunk.Chunk) *logproto.IndexStatsResponse {
+	sizes := map[string]chunk.Chunk{}
+	streams := map[uint64]struct{}{}
+	for _, group := range chks {
+		for _, c := range group {
+			key := schemaCfg.ExternalKey(c)
+			if prev, ok := sizes[key]; ok && prev.KB > 0 {
+				continue
+			}
+			sizes[key] = c
+			streams[c.Fingerprint] = struct{}{}
+		}
+	}
+
+	res := &logproto.IndexStatsResponse{
+		Streams: uint64(len(streams)),
+		Chunks:  uint64(len(sizes)),
+	}
+	var sized uint64
+	for _, c := range sizes {
+		if c.KB == 0 && c.Entries == 0 {
+			continue
+		}
+		sized++
+		res.Bytes += uint64(c.KB) << 10
+		res.Entries += uint64(c.Entries)
+	}
+	if sized > 0 && sized < res.Chunks {
+		res.Bytes += res.Bytes / sized * (res.Chunks - sized)
+		res.Entries += res.Entries / sized * (res.Chunks - sized)
+	}
+	return res
+}
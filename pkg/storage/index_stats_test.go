@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/loki/pkg/storage/chunk"
+)
+
+func Test_indexStats(t *testing.T) {
+	schemaCfg := chunk.SchemaConfig{
+		Configs: []chunk.PeriodConfig{
+			{
+				From:      chunk.DayTime{Time: 0},
+				Schema:    "v11",
+				RowShards: 16,
+			},
+		},
+	}
+	ref := func(fp model.Fingerprint, from model.Time, kb, entries uint32) chunk.Chunk {
+		return chunk.Chunk{
+			ChunkRef: logproto.ChunkRef{
+				Fingerprint: uint64(fp),
+				UserID:      "fake",
+				From:        from,
+				Through:     from + 1000,
+				Checksum:    uint32(from),
+			},
+			ChecksumSet: true,
+			KB:          kb,
+			Entries:     entries,
+		}
+	}
+
+	for _, tc := range []struct {
+		name     string
+		chks     [][]chunk.Chunk
+		expected *logproto.IndexStatsResponse
+	}{
+		{
+			name:     "no chunks",
+			expected: &logproto.IndexStatsResponse{},
+		},
+		{
+			name: "sized chunks",
+			chks: [][]chunk.Chunk{
+				{ref(1, 0, 2, 100), ref(1, 1000, 3, 200), ref(2, 0, 1, 50)},
+			},
+			expected: &logproto.IndexStatsResponse{Streams: 2, Chunks: 3, Bytes: 6 << 10, Entries: 350},
+		},
+		{
+			name: "duplicate chunks counted once, keeping the sized one",
+			chks: [][]chunk.Chunk{
+				{ref(1, 0, 0, 0)},
+				{ref(1, 0, 2, 100)},
+				{ref(1, 0, 0, 0)},
+			},
+			expected: &logproto.IndexStatsResponse{Streams: 1, Chunks: 1, Bytes: 2 << 10, Entries: 100},
+		},
+		{
+			name: "unsized chunks estimated from the sized ones",
+			chks: [][]chunk.Chunk{
+				{ref(1, 0, 2, 100), ref(2, 0, 4, 300), ref(3, 0, 0, 0)},
+			},
+			expected: &logproto.IndexStatsResponse{Streams: 3, Chunks: 3, Bytes: 9 << 10, Entries: 600},
+		},
+		{
+			name: "no sized chunks",
+			chks: [][]chunk.Chunk{
+				{ref(1, 0, 0, 0), ref(2, 0, 0, 0)},
+			},
+			expected: &logproto.IndexStatsResponse{Streams: 2, Chunks: 2},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, indexStats(schemaCfg, tc.chks))
+		})
+	}
+}
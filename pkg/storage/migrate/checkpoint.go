@@ -0,0 +1,99 @@
+package migrate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+// checkpointParams are the parameters determining the shards of a migration.
+// A checkpoint can only be resumed with the same parameters.
+type checkpointParams struct {
+	SourcePeriod string        `json:"source_period"`
+	DestPeriod   string        `json:"dest_period"`
+	From         model.Time    `json:"from"`
+	Through      model.Time    `json:"through"`
+	ShardBy      time.Duration `json:"shard_by"`
+	Match        string        `json:"match"`
+}
+
+// checkpoint records the shards migrated for every tenant, by start time.
+type checkpoint struct {
+	mtx  sync.Mutex
+	path string
+
+	Params    checkpointParams               `json:"params"`
+	Completed map[string]map[model.Time]bool `json:"completed"`
+}
+
+// loadCheckpoint loads the checkpoint at the given path, or returns a new one if it doesn't exist.
+func loadCheckpoint(path string, params checkpointParams) (*checkpoint, error) {
+	c := &checkpoint{
+		path:      path,
+		Params:    params,
+		Completed: map[string]map[model.Time]bool{},
+	}
+
+	buf, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(buf, c); err != nil {
+		return nil, fmt.Errorf("failed to decode checkpoint %s: %w", path, err)
+	}
+	if c.Params != params {
+		return nil, fmt.Errorf("checkpoint %s was created with different parameters (%+v), remove it to start over", path, c.Params)
+	}
+	if c.Completed == nil {
+		c.Completed = map[string]map[model.Time]bool{}
+	}
+	return c, nil
+}
+
+func (c *checkpoint) isCompleted(tenant string, shard model.Time) bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.Completed[tenant][shard]
+}
+
+// complete records the shard of the tenant as migrated and saves the checkpoint.
+func (c *checkpoint) complete(tenant string, shard model.Time) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.Completed[tenant] == nil {
+		c.Completed[tenant] = map[model.Time]bool{}
+	}
+	c.Completed[tenant][shard] = true
+
+	buf, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so that a crash never leaves a truncated checkpoint.
+	tmp, err := ioutil.TempFile(filepath.Dir(c.path), filepath.Base(c.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(buf); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path)
+}
@@ -0,0 +1,67 @@
+package migrate
+
+import (
+	"errors"
+	"flag"
+	"time"
+
+	"github.com/grafana/dskit/backoff"
+	"github.com/grafana/dskit/flagext"
+)
+
+// Config configures the migration of the chunks of a period config to another one.
+type Config struct {
+	SourcePeriod   flagext.DayValue       `yaml:"source_period"`
+	DestPeriod     flagext.DayValue       `yaml:"dest_period"`
+	Tenants        flagext.StringSliceCSV `yaml:"tenants"`
+	From           flagext.Time           `yaml:"from"`
+	To             flagext.Time           `yaml:"to"`
+	Match          string                 `yaml:"match"`
+	ShardBy        time.Duration          `yaml:"shard_by"`
+	BatchSize      int                    `yaml:"batch_size"`
+	Parallelism    int                    `yaml:"parallelism"`
+	CheckpointFile string                 `yaml:"checkpoint_file"`
+	// Backoff between the attempts of reading or writing a batch of chunks.
+	Backoff backoff.Config `yaml:"backoff"`
+}
+
+// RegisterFlags adds the flags required to config this to the given FlagSet.
+func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
+	f.Var(&cfg.SourcePeriod, "migrate-schema.source-period", "The 'from' date (YYYY-MM-DD) of the period config to read chunks from.")
+	f.Var(&cfg.DestPeriod, "migrate-schema.dest-period", "The 'from' date (YYYY-MM-DD) of the period config to rewrite chunks with. It must start after the source period.")
+	f.Var(&cfg.Tenants, "migrate-schema.tenants", "Comma-separated list of tenants to migrate.")
+	f.Var(&cfg.From, "migrate-schema.from", "Only migrate chunks after this time. Defaults to the start of the source period.")
+	f.Var(&cfg.To, "migrate-schema.to", "Only migrate chunks before this time. Defaults to the end of the source period.")
+	f.StringVar(&cfg.Match, "migrate-schema.match", "", "Optional label matchers selecting the streams to migrate, e.g. {app=\"foo\"}.")
+	f.DurationVar(&cfg.ShardBy, "migrate-schema.shard-by", 6*time.Hour, "Split the time range into shards of this size. A shard is the unit of parallelism and of progress saved in the checkpoint.")
+	f.IntVar(&cfg.BatchSize, "migrate-schema.batch-size", 500, "Number of chunks to read and write in one batch.")
+	f.IntVar(&cfg.Parallelism, "migrate-schema.parallelism", 8, "Number of shards migrated in parallel.")
+	f.StringVar(&cfg.CheckpointFile, "migrate-schema.checkpoint-file", "migrate-schema-checkpoint.json", "File in which the migrated shards are recorded. Running the migration again with the same parameters resumes it.")
+	cfg.Backoff.RegisterFlagsWithPrefix("migrate-schema", f)
+}
+
+// Validate validates the config.
+func (cfg *Config) Validate() error {
+	if !cfg.SourcePeriod.IsSet() || !cfg.DestPeriod.IsSet() {
+		return errors.New("both the source and dest periods are required")
+	}
+	if cfg.DestPeriod.Time <= cfg.SourcePeriod.Time {
+		return errors.New("the dest period must start after the source period")
+	}
+	if len(cfg.Tenants) == 0 {
+		return errors.New("at least one tenant is required")
+	}
+	if cfg.ShardBy <= 0 {
+		return errors.New("shard-by must be positive")
+	}
+	if cfg.BatchSize <= 0 || cfg.Parallelism <= 0 {
+		return errors.New("batch-size and parallelism must be positive")
+	}
+	if cfg.CheckpointFile == "" {
+		return errors.New("a checkpoint file is required")
+	}
+	if cfg.Backoff.MaxRetries <= 0 {
+		return errors.New("backoff-retries must be positive")
+	}
+	return nil
+}
@@ -0,0 +1,344 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/backoff"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/weaveworks/common/user"
+	"go.uber.org/atomic"
+
+	"github.com/grafana/loki/pkg/logql/syntax"
+	"github.com/grafana/loki/pkg/storage"
+	"github.com/grafana/loki/pkg/storage/chunk"
+	chunk_storage "github.com/grafana/loki/pkg/storage/chunk/storage"
+	"github.com/grafana/loki/pkg/storage/stores/shipper"
+)
+
+// Migrator rewrites the chunks and index of a period config with the schema
+// and stores of a newer period config.
+//
+// The chunks are written with the newer period config starting at the start
+// of the older one. Once the migration is done, the older period config can be
+// removed and the newer one moved to its start.
+type Migrator struct {
+	cfg          Config
+	sourceSchema chunk.SchemaConfig
+	source, dest chunk.Store
+	matchers     []*labels.Matcher
+
+	from, through model.Time
+	checkpoint    *checkpoint
+
+	chunks, bytes atomic.Int64
+	logger        log.Logger
+}
+
+// New creates a migrator reading and writing chunks with the stores of the given configuration.
+func New(cfg Config, storageCfg storage.Config, storeCfg chunk.StoreConfig, schemaCfg chunk.SchemaConfig, limits chunk_storage.StoreLimits, logger log.Logger) (*Migrator, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	sourceSchema, destSchema, end, err := migrationSchemas(cfg, schemaCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// The index has to be written and uploaded when the dest period uses boltdb-shipper.
+	storageCfg.BoltDBShipperConfig.Mode = shipper.ModeReadWrite
+	if storageCfg.BoltDBShipperConfig.IngesterName == "" {
+		storageCfg.BoltDBShipperConfig.IngesterName = "migrate-schema"
+	}
+
+	clientMetrics := chunk_storage.NewClientMetrics()
+	storage.RegisterCustomIndexClients(&storageCfg, clientMetrics, prometheus.NewRegistry())
+
+	// Stores register their metrics, use a registry per store to avoid duplicates.
+	source, err := chunk_storage.NewStore(storageCfg.Config, storeCfg, sourceSchema, limits, clientMetrics, prometheus.NewRegistry(), nil, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create source store: %w", err)
+	}
+	dest, err := chunk_storage.NewStore(storageCfg.Config, storeCfg, destSchema, limits, clientMetrics, prometheus.NewRegistry(), nil, logger)
+	if err != nil {
+		source.Stop()
+		return nil, fmt.Errorf("failed to create dest store: %w", err)
+	}
+
+	m, err := newMigrator(cfg, sourceSchema, source, dest, end, logger)
+	if err != nil {
+		source.Stop()
+		dest.Stop()
+		return nil, err
+	}
+	return m, nil
+}
+
+func newMigrator(cfg Config, sourceSchema chunk.SchemaConfig, source, dest chunk.Store, end model.Time, logger log.Logger) (*Migrator, error) {
+	from, through := sourceSchema.Configs[0].From.Time, end
+	if t := time.Time(cfg.From); !t.IsZero() {
+		if f := model.TimeFromUnixNano(t.UnixNano()); f > from {
+			from = f
+		}
+	}
+	if t := time.Time(cfg.To); !t.IsZero() {
+		if to := model.TimeFromUnixNano(t.UnixNano()); to < through {
+			through = to
+		}
+	}
+	if now := model.Now(); now < through {
+		through = now
+	}
+	if from >= through {
+		return nil, fmt.Errorf("nothing to migrate between %s and %s", from.Time().UTC(), through.Time().UTC())
+	}
+
+	matchers := []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, "logs")}
+	if cfg.Match != "" {
+		m, err := syntax.ParseMatchers(cfg.Match)
+		if err != nil {
+			return nil, fmt.Errorf("invalid match: %w", err)
+		}
+		matchers = append(matchers, m...)
+	}
+
+	checkpoint, err := loadCheckpoint(cfg.CheckpointFile, checkpointParams{
+		SourcePeriod: cfg.SourcePeriod.String(),
+		DestPeriod:   cfg.DestPeriod.String(),
+		From:         from,
+		Through:      through,
+		ShardBy:      cfg.ShardBy,
+		Match:        cfg.Match,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		cfg:          cfg,
+		sourceSchema: sourceSchema,
+		source:       source,
+		dest:         dest,
+		matchers:     matchers,
+		from:         from,
+		through:      through,
+		checkpoint:   checkpoint,
+		logger:       logger,
+	}, nil
+}
+
+// migrationSchemas returns the schema configs to read chunks from and write
+// them to, and the end of the source period.
+func migrationSchemas(cfg Config, schemaCfg chunk.SchemaConfig) (source, dest chunk.SchemaConfig, end model.Time, err error) {
+	sourceIdx, destIdx := -1, -1
+	for i, p := range schemaCfg.Configs {
+		switch p.From.Time {
+		case cfg.SourcePeriod.Time:
+			sourceIdx = i
+		case cfg.DestPeriod.Time:
+			destIdx = i
+		}
+	}
+	if sourceIdx < 0 {
+		return source, dest, 0, fmt.Errorf("no period config starts at the source period %s", cfg.SourcePeriod)
+	}
+	if destIdx < 0 {
+		return source, dest, 0, fmt.Errorf("no period config starts at the dest period %s", cfg.DestPeriod)
+	}
+
+	end = schemaCfg.Configs[sourceIdx+1].From.Time
+
+	sourcePeriod := schemaCfg.Configs[sourceIdx]
+	destPeriod := schemaCfg.Configs[destIdx]
+	destPeriod.From = sourcePeriod.From
+
+	return chunk.SchemaConfig{Configs: []chunk.PeriodConfig{sourcePeriod}},
+		chunk.SchemaConfig{Configs: []chunk.PeriodConfig{destPeriod}},
+		end, nil
+}
+
+type shard struct {
+	tenant        string
+	from, through model.Time
+	// first tells if this is the first shard of the tenant, the only one
+	// migrating chunks starting before its time range.
+	first bool
+}
+
+// shards returns the shards of all tenants that aren't migrated yet, and the total number of shards.
+func (m *Migrator) shards() ([]shard, int) {
+	var (
+		shards []shard
+		total  int
+	)
+	for _, tenant := range m.cfg.Tenants {
+		for from := m.from; from < m.through; from = from.Add(m.cfg.ShardBy) {
+			total++
+			if m.checkpoint.isCompleted(tenant, from) {
+				continue
+			}
+			through := from.Add(m.cfg.ShardBy) - 1
+			if through >= m.through {
+				through = m.through - 1
+			}
+			shards = append(shards, shard{tenant: tenant, from: from, through: through, first: from == m.from})
+		}
+	}
+	return shards, total
+}
+
+// Run migrates all the shards that aren't recorded in the checkpoint yet. It
+// stops at the first shard failing to migrate, the next run resuming from there.
+func (m *Migrator) Run(ctx context.Context) error {
+	shards, total := m.shards()
+	level.Info(m.logger).Log("msg", "starting migration", "from", m.from.Time().UTC(), "through", m.through.Time().UTC(),
+		"tenants", len(m.cfg.Tenants), "shards", total, "already_migrated", total-len(shards))
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+		done     = atomic.NewInt64(int64(total - len(shards)))
+		start    = time.Now()
+		shardsCh = make(chan shard)
+	)
+	for i := 0; i < m.cfg.Parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for s := range shardsCh {
+				if err := m.migrateShard(ctx, s); err != nil {
+					errOnce.Do(func() {
+						firstErr = fmt.Errorf("failed to migrate shard %s-%s of tenant %s: %w", s.from.Time().UTC(), s.through.Time().UTC(), s.tenant, err)
+						cancel()
+					})
+					continue
+				}
+				level.Info(m.logger).Log("msg", "shard migrated", "tenant", s.tenant, "from", s.from.Time().UTC(), "through", s.through.Time().UTC(),
+					"progress", fmt.Sprintf("%d/%d", done.Inc(), total), "chunks", m.chunks.Load(), "bytes", m.bytes.Load(), "elapsed", time.Since(start))
+			}
+		}()
+	}
+
+dispatch:
+	for _, s := range shards {
+		select {
+		case shardsCh <- s:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(shardsCh)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	level.Info(m.logger).Log("msg", "migration done", "chunks", m.chunks.Load(), "bytes", m.bytes.Load(), "elapsed", time.Since(start))
+	return nil
+}
+
+func (m *Migrator) migrateShard(ctx context.Context, s shard) error {
+	ctx = user.InjectOrgID(ctx, s.tenant)
+
+	groups, fetchers, err := m.source.GetChunkRefs(ctx, s.tenant, s.from, s.through, m.matchers...)
+	if err != nil {
+		return fmt.Errorf("failed to query index: %w", err)
+	}
+
+	for i, fetcher := range fetchers {
+		// Chunks overlapping several shards are only migrated with the shard they start in.
+		chunks := make([]chunk.Chunk, 0, len(groups[i]))
+		for _, c := range groups[i] {
+			if c.From >= s.from || s.first {
+				chunks = append(chunks, c)
+			}
+		}
+		// FetchChunks requires chunks to be ordered by external key.
+		sort.Slice(chunks, func(x, y int) bool {
+			return m.sourceSchema.ExternalKey(chunks[x]) < m.sourceSchema.ExternalKey(chunks[y])
+		})
+
+		for j := 0; j < len(chunks); j += m.cfg.BatchSize {
+			k := j + m.cfg.BatchSize
+			if k > len(chunks) {
+				k = len(chunks)
+			}
+			if err := m.migrateBatch(ctx, fetcher, chunks[j:k]); err != nil {
+				return err
+			}
+		}
+	}
+
+	return m.checkpoint.complete(s.tenant, s.from)
+}
+
+func (m *Migrator) migrateBatch(ctx context.Context, fetcher *chunk.Fetcher, chunks []chunk.Chunk) error {
+	keys := make([]string, 0, len(chunks))
+	for _, c := range chunks {
+		keys = append(keys, m.sourceSchema.ExternalKey(c))
+	}
+
+	var fetched []chunk.Chunk
+	err := m.retry(ctx, "fetch chunks", func() (err error) {
+		fetched, err = fetcher.FetchChunks(ctx, chunks, keys)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to fetch chunks: %w", err)
+	}
+
+	var bytes int
+	for _, c := range fetched {
+		enc, err := c.Encoded()
+		if err != nil {
+			return fmt.Errorf("failed to encode chunk: %w", err)
+		}
+		bytes += len(enc)
+	}
+
+	err = m.retry(ctx, "write chunks", func() error {
+		return m.dest.Put(ctx, fetched)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write chunks: %w", err)
+	}
+
+	m.chunks.Add(int64(len(fetched)))
+	m.bytes.Add(int64(bytes))
+	return nil
+}
+
+// retry calls f until it succeeds, backing off between the attempts.
+func (m *Migrator) retry(ctx context.Context, op string, f func() error) error {
+	retries := backoff.New(ctx, m.cfg.Backoff)
+	err := ctx.Err()
+	for retries.Ongoing() {
+		if err = f(); err == nil {
+			return nil
+		}
+		level.Warn(m.logger).Log("msg", "failed to "+op+", retrying", "retry", retries.NumRetries(), "err", err)
+		retries.Wait()
+	}
+	return err
+}
+
+// Stop stops the stores, flushing the index written to the dest store.
+func (m *Migrator) Stop() {
+	m.source.Stop()
+	m.dest.Stop()
+}
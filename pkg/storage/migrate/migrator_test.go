@@ -0,0 +1,213 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/backoff"
+	"github.com/grafana/dskit/flagext"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/pkg/ingester/client"
+	"github.com/grafana/loki/pkg/storage/chunk"
+	"github.com/grafana/loki/pkg/storage/chunk/cache"
+	promchunk "github.com/grafana/loki/pkg/storage/chunk/encoding"
+	"github.com/grafana/loki/pkg/util/validation"
+)
+
+var (
+	sourcePeriod = chunk.PeriodConfig{
+		From:        chunk.DayTime{Time: model.TimeFromUnix(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).Unix())},
+		IndexType:   "inmemory",
+		Schema:      "v9",
+		IndexTables: chunk.PeriodicTableConfig{Prefix: "source_index_"},
+	}
+	destPeriod = chunk.PeriodConfig{
+		From:        chunk.DayTime{Time: model.TimeFromUnix(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC).Unix())},
+		IndexType:   "inmemory",
+		Schema:      "v12",
+		RowShards:   16,
+		IndexTables: chunk.PeriodicTableConfig{Prefix: "dest_index_"},
+	}
+	schemaCfg = chunk.SchemaConfig{Configs: []chunk.PeriodConfig{sourcePeriod, destPeriod}}
+)
+
+func newTestStore(t *testing.T, period chunk.PeriodConfig) (chunk.Store, *chunk.MockStorage) {
+	t.Helper()
+	storage := chunk.NewMockStorage()
+	require.NoError(t, storage.CreateTable(context.Background(), chunk.TableDesc{Name: period.IndexTables.Prefix}))
+
+	var limits validation.Limits
+	flagext.DefaultValues(&limits)
+	overrides, err := validation.NewOverrides(limits, nil)
+	require.NoError(t, err)
+
+	var storeCfg chunk.StoreConfig
+	flagext.DefaultValues(&storeCfg)
+
+	store := chunk.NewCompositeStore(nil)
+	require.NoError(t, store.AddPeriod(storeCfg, period, storage, storage, overrides, cache.NewNoopCache(), cache.NewNoopCache()))
+	return store, storage
+}
+
+func newTestChunk(t *testing.T, tenant, app string, from, through model.Time) chunk.Chunk {
+	t.Helper()
+	metric := labels.Labels{
+		{Name: labels.MetricName, Value: "logs"},
+		{Name: "app", Value: app},
+	}
+	cs := promchunk.New()
+	for ts := from; ts <= through; ts = ts.Add(time.Minute) {
+		_, err := cs.Add(model.SamplePair{Timestamp: ts, Value: 0})
+		require.NoError(t, err)
+	}
+	c := chunk.NewChunk(tenant, client.Fingerprint(metric), metric, cs, from, through)
+	require.NoError(t, c.Encode())
+	return c
+}
+
+type failingStore struct {
+	chunk.Store
+	fail bool
+}
+
+func (s *failingStore) Put(ctx context.Context, chunks []chunk.Chunk) error {
+	if s.fail {
+		return errors.New("unavailable")
+	}
+	return s.Store.Put(ctx, chunks)
+}
+
+func TestMigrationSchemas(t *testing.T) {
+	cfg := Config{SourcePeriod: flagext.NewDayValue(sourcePeriod.From.Time), DestPeriod: flagext.NewDayValue(destPeriod.From.Time)}
+	source, dest, end, err := migrationSchemas(cfg, schemaCfg)
+	require.NoError(t, err)
+	require.Equal(t, []chunk.PeriodConfig{sourcePeriod}, source.Configs)
+	require.Equal(t, destPeriod.From.Time, end)
+
+	// The dest period is moved to the start of the source period.
+	require.Len(t, dest.Configs, 1)
+	require.Equal(t, sourcePeriod.From, dest.Configs[0].From)
+	require.Equal(t, "v12", dest.Configs[0].Schema)
+
+	cfg.SourcePeriod = flagext.NewDayValue(model.TimeFromUnix(0))
+	_, _, _, err = migrationSchemas(cfg, schemaCfg)
+	require.EqualError(t, err, "no period config starts at the source period 1970-01-01T00:00:00Z")
+}
+
+func TestMigrator(t *testing.T) {
+	var (
+		ctx        = context.Background()
+		start      = sourcePeriod.From.Time
+		checkpoint = filepath.Join(t.TempDir(), "checkpoint.json")
+		cfg        = Config{
+			SourcePeriod:   flagext.NewDayValue(sourcePeriod.From.Time),
+			DestPeriod:     flagext.NewDayValue(destPeriod.From.Time),
+			Tenants:        []string{"a", "b"},
+			To:             flagext.Time(start.Add(24 * time.Hour).Time()),
+			ShardBy:        6 * time.Hour,
+			BatchSize:      2,
+			Parallelism:    2,
+			CheckpointFile: checkpoint,
+			Backoff:        backoff.Config{MinBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond, MaxRetries: 3},
+		}
+	)
+	require.NoError(t, cfg.Validate())
+
+	sourceSchema, destSchema, end, err := migrationSchemas(cfg, schemaCfg)
+	require.NoError(t, err)
+	source, _ := newTestStore(t, sourceSchema.Configs[0])
+	dest, _ := newTestStore(t, destSchema.Configs[0])
+	failing := &failingStore{Store: dest, fail: true}
+
+	chunks := []chunk.Chunk{
+		newTestChunk(t, "a", "foo", start.Add(time.Hour), start.Add(2*time.Hour)),
+		newTestChunk(t, "a", "bar", start.Add(time.Hour), start.Add(2*time.Hour)),
+		// Overlaps two shards.
+		newTestChunk(t, "a", "foo", start.Add(5*time.Hour), start.Add(7*time.Hour)),
+		newTestChunk(t, "a", "foo", start.Add(20*time.Hour), start.Add(21*time.Hour)),
+		newTestChunk(t, "b", "foo", start.Add(13*time.Hour), start.Add(14*time.Hour)),
+		// Out of the time range to migrate.
+		newTestChunk(t, "b", "foo", start.Add(30*time.Hour), start.Add(31*time.Hour)),
+	}
+	require.NoError(t, source.Put(ctx, chunks))
+
+	// The first run fails to write chunks, only the shards without chunks are recorded.
+	m, err := newMigrator(cfg, sourceSchema, source, failing, end, log.NewNopLogger())
+	require.NoError(t, err)
+	require.Error(t, m.Run(ctx))
+
+	// Resuming the migration only migrates the shards that failed.
+	failing.fail = false
+	m, err = newMigrator(cfg, sourceSchema, source, failing, end, log.NewNopLogger())
+	require.NoError(t, err)
+	require.NoError(t, m.Run(ctx))
+	require.Equal(t, int64(5), m.chunks.Load())
+
+	for _, tenant := range []string{"a", "b"} {
+		expected, _, err := source.GetChunkRefs(ctx, tenant, start, start.Add(24*time.Hour), m.matchers...)
+		require.NoError(t, err)
+		actual, _, err := dest.GetChunkRefs(ctx, tenant, start, start.Add(24*time.Hour), m.matchers...)
+		require.NoError(t, err)
+		require.Len(t, actual, 1)
+		require.ElementsMatch(t, chunkIDs(destSchema, expected[0]), chunkIDs(destSchema, actual[0]))
+	}
+
+	// Nothing is left to migrate.
+	m, err = newMigrator(cfg, sourceSchema, source, dest, end, log.NewNopLogger())
+	require.NoError(t, err)
+	shards, total := m.shards()
+	require.Empty(t, shards)
+	require.Equal(t, 8, total)
+	require.NoError(t, m.Run(ctx))
+	require.Equal(t, int64(0), m.chunks.Load())
+
+	// The checkpoint can't be resumed with different parameters.
+	cfg.ShardBy = time.Hour
+	_, err = newMigrator(cfg, sourceSchema, source, dest, end, log.NewNopLogger())
+	require.Error(t, err)
+}
+
+func TestMigrator_Retry(t *testing.T) {
+	m := &Migrator{
+		cfg:    Config{Backoff: backoff.Config{MinBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond, MaxRetries: 3}},
+		logger: log.NewNopLogger(),
+	}
+	failures := func(n int, calls *int) func() error {
+		return func() error {
+			*calls++
+			if *calls <= n {
+				return errors.New("unavailable")
+			}
+			return nil
+		}
+	}
+
+	var calls int
+	require.NoError(t, m.retry(context.Background(), "write chunks", failures(2, &calls)))
+	require.Equal(t, 3, calls)
+
+	calls = 0
+	require.EqualError(t, m.retry(context.Background(), "write chunks", failures(3, &calls)), "unavailable")
+	require.Equal(t, 3, calls)
+
+	calls = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.Equal(t, context.Canceled, m.retry(ctx, "write chunks", failures(0, &calls)))
+	require.Equal(t, 0, calls)
+}
+
+func chunkIDs(schema chunk.SchemaConfig, chunks []chunk.Chunk) []string {
+	ids := make([]string, 0, len(chunks))
+	for _, c := range chunks {
+		ids = append(ids, schema.ExternalKey(c))
+	}
+	return ids
+}
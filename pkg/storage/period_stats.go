@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"sync"
+	"time"
+
+	"github.com/grafana/loki/pkg/logqlmodel/stats"
+	"github.com/grafana/loki/pkg/storage/chunk"
+)
+
+// periodsStats accumulates the statistics of the chunks of a query by period of the schema config,
+// to tell which period of the store is slow.
+type periodsStats struct {
+	configs []chunk.PeriodConfig
+
+	mtx     sync.Mutex
+	periods map[int]*stats.Period
+}
+
+func newPeriodsStats(schemaCfg chunk.SchemaConfig) *periodsStats {
+	return &periodsStats{
+		configs: schemaCfg.Configs,
+		periods: map[int]*stats.Period{},
+	}
+}
+
+// periodFor returns the index of the period config of the chunk, or -1 when there is none.
+func (p *periodsStats) periodFor(c chunk.Chunk) int {
+	for i := len(p.configs) - 1; i >= 0; i-- {
+		if c.From >= p.configs[i].From.Time {
+			return i
+		}
+	}
+	return -1
+}
+
+// period returns the statistics of the period of the chunk, nil when there is none. The lock must be held.
+func (p *periodsStats) period(c chunk.Chunk) *stats.Period {
+	i := p.periodFor(c)
+	if i < 0 {
+		return nil
+	}
+	if period, ok := p.periods[i]; ok {
+		return period
+	}
+	cfg := p.configs[i]
+	period := &stats.Period{
+		From:        cfg.From.String(),
+		Store:       cfg.IndexType,
+		ObjectStore: cfg.ObjectType,
+		Schema:      cfg.Schema,
+	}
+	p.periods[i] = period
+	return period
+}
+
+// addRefs counts the chunk references fetched from the index.
+func (p *periodsStats) addRefs(chunks []chunk.Chunk) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	for _, c := range chunks {
+		if period := p.period(c); period != nil {
+			period.TotalChunksRef++
+		}
+	}
+}
+
+// addFetched adds a fetch of chunks of the same period as the chunk.
+func (p *periodsStats) addFetched(c chunk.Chunk, chunks, bytes int64, d time.Duration) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if period := p.period(c); period != nil {
+		period.TotalChunksDownloaded += chunks
+		period.ChunksDownloadedBytes += bytes
+		period.ChunksDownloadTime += int64(d)
+	}
+}
+
+// add adds the statistics of the periods to the statistics of the query.
+func (p *periodsStats) add(ctx *stats.Context) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	for _, period := range p.periods {
+		ctx.AddPeriod(*period)
+	}
+}
@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/services"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/model"
+
+	"github.com/grafana/loki/pkg/storage/chunk"
+)
+
+var errSchemaConfigFileAndConfigs = errors.New("the period configs can't be set both in the config and in the schema config file")
+
+// LoadFile loads the period configs from the schema config file, if any.
+func (cfg *SchemaConfig) LoadFile() error {
+	if cfg.File == "" {
+		return nil
+	}
+	if len(cfg.Configs) > 0 {
+		return errSchemaConfigFileAndConfigs
+	}
+
+	loaded, err := chunk.LoadSchemaConfigFile(cfg.File)
+	if err != nil {
+		return fmt.Errorf("failed to load the schema config file: %w", err)
+	}
+	cfg.Configs = loaded.Configs
+	return nil
+}
+
+// addPeriodConfig adds a period config to a store implementing chunk.PeriodConfigAdder.
+func addPeriodConfig(store chunk.Store, cfg chunk.PeriodConfig) error {
+	adder, ok := store.(chunk.PeriodConfigAdder)
+	if !ok {
+		return fmt.Errorf("the store %T doesn't support adding period configs", store)
+	}
+	return adder.AddPeriodConfig(cfg)
+}
+
+// appendPeriodConfig appends to a copy of configs, which could be in use.
+func appendPeriodConfig(configs []chunk.PeriodConfig, cfg chunk.PeriodConfig) []chunk.PeriodConfig {
+	appended := make([]chunk.PeriodConfig, 0, len(configs)+1)
+	appended = append(appended, configs...)
+	return append(appended, cfg)
+}
+
+// SchemaConfigWatcher reloads the schema config file periodically, and adds the period configs
+// appended to it to the registered chunk.PeriodConfigAdder.
+type SchemaConfigWatcher struct {
+	services.Service
+
+	file    string
+	logger  log.Logger
+	reloads *prometheus.CounterVec
+
+	mtx     sync.Mutex
+	current chunk.SchemaConfig
+	adders  []chunk.PeriodConfigAdder
+}
+
+// NewSchemaConfigWatcher creates a SchemaConfigWatcher for the schema config file of cfg.
+// The period configs of cfg are the ones in use, loaded from the file at startup.
+func NewSchemaConfigWatcher(cfg SchemaConfig, logger log.Logger, registerer prometheus.Registerer) *SchemaConfigWatcher {
+	w := &SchemaConfigWatcher{
+		file:    cfg.File,
+		logger:  log.With(logger, "component", "schema-config-watcher"),
+		current: chunk.SchemaConfig{Configs: cfg.Configs},
+		reloads: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "loki",
+			Name:      "schema_config_reloads_total",
+			Help:      "Total number of reloads of the schema config file.",
+		}, []string{"status"}),
+	}
+	w.Service = services.NewTimerService(cfg.ReloadPeriod, nil, w.iteration, nil)
+	return w
+}
+
+// AddPeriodConfigAdder registers a component to add the new period configs to.
+func (w *SchemaConfigWatcher) AddPeriodConfigAdder(adder chunk.PeriodConfigAdder) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	w.adders = append(w.adders, adder)
+}
+
+func (w *SchemaConfigWatcher) iteration(_ context.Context) error {
+	if err := w.Reload(); err != nil {
+		w.reloads.WithLabelValues("failure").Inc()
+		level.Error(w.logger).Log("msg", "failed to reload the schema config file", "file", w.file, "err", err)
+		return nil
+	}
+	w.reloads.WithLabelValues("success").Inc()
+	return nil
+}
+
+// Reload loads the schema config file and adds its new period configs to the registered components.
+func (w *SchemaConfigWatcher) Reload() error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	loaded, err := chunk.LoadSchemaConfigFile(w.file)
+	if err != nil {
+		return err
+	}
+	next := SchemaConfig{SchemaConfig: loaded}
+	if err := next.Validate(); err != nil {
+		return err
+	}
+	added, err := w.current.NewPeriods(next.SchemaConfig, model.Now())
+	if err != nil {
+		return err
+	}
+
+	for _, p := range added {
+		// the stores of these index types are configured at startup depending on the components running.
+		if IsObjectStorageIndex(p.IndexType) && !usingIndexType(w.current.Configs, p.IndexType) {
+			return fmt.Errorf("switching to the %s index type requires a restart", p.IndexType)
+		}
+	}
+
+	for _, p := range added {
+		for _, adder := range w.adders {
+			if err := adder.AddPeriodConfig(p); err != nil {
+				// the period could have been added to some components already, they need a restart to be consistent.
+				return fmt.Errorf("failed to add the period config starting at %s, a restart is required: %w", p.From.String(), err)
+			}
+		}
+		w.current.Configs = appendPeriodConfig(w.current.Configs, p)
+		level.Info(w.logger).Log("msg", "added period config", "from", p.From.String(), "store", p.IndexType, "schema", p.Schema)
+	}
+	return nil
+}
+
+func usingIndexType(configs []chunk.PeriodConfig, indexType string) bool {
+	for _, c := range configs {
+		if c.IndexType == indexType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/pkg/storage/chunk"
+)
+
+type mockPeriodConfigAdder struct {
+	added []chunk.PeriodConfig
+}
+
+func (m *mockPeriodConfigAdder) AddPeriodConfig(cfg chunk.PeriodConfig) error {
+	m.added = append(m.added, cfg)
+	return nil
+}
+
+func writeSchemaConfigFile(t *testing.T, path string, periods ...string) {
+	t.Helper()
+
+	content := "configs:\n"
+	for _, p := range periods {
+		content += p
+	}
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+}
+
+func schemaConfigPeriod(from model.Time, store string) string {
+	return fmt.Sprintf(`- from: %s
+  store: %s
+  object_store: filesystem
+  schema: v11
+  index:
+    prefix: index_
+    period: 24h
+`, from.Time().UTC().Format("2006-01-02"), store)
+}
+
+func TestSchemaConfigWatcher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schema.yaml")
+	day := func(n int) model.Time {
+		return model.TimeFromUnix(time.Now().Truncate(24 * time.Hour).Add(time.Duration(n) * 24 * time.Hour).Unix())
+	}
+	writeSchemaConfigFile(t, path, schemaConfigPeriod(day(-10), "boltdb-shipper"))
+
+	cfg := SchemaConfig{File: path}
+	require.NoError(t, cfg.LoadFile())
+	require.NoError(t, cfg.Validate())
+	require.Len(t, cfg.Configs, 1)
+
+	adder := &mockPeriodConfigAdder{}
+	w := NewSchemaConfigWatcher(cfg, log.NewNopLogger(), prometheus.NewRegistry())
+	w.AddPeriodConfigAdder(adder)
+
+	// nothing changed.
+	require.NoError(t, w.Reload())
+	require.Empty(t, adder.added)
+
+	// periods can't be added in the past.
+	writeSchemaConfigFile(t, path, schemaConfigPeriod(day(-10), "boltdb-shipper"), schemaConfigPeriod(day(-1), "boltdb-shipper"))
+	require.Error(t, w.Reload())
+	require.Empty(t, adder.added)
+
+	// switching to another object storage index requires a restart.
+	writeSchemaConfigFile(t, path, schemaConfigPeriod(day(-10), "boltdb-shipper"), schemaConfigPeriod(day(2), "tsdb"))
+	require.Error(t, w.Reload())
+	require.Empty(t, adder.added)
+
+	writeSchemaConfigFile(t, path, schemaConfigPeriod(day(-10), "boltdb-shipper"), schemaConfigPeriod(day(2), "boltdb-shipper"))
+	require.NoError(t, w.Reload())
+	require.Len(t, adder.added, 1)
+	require.Equal(t, day(2), adder.added[0].From.Time)
+
+	// the added period is now in use.
+	require.NoError(t, w.Reload())
+	require.Len(t, adder.added, 1)
+}
+
+func TestSchemaConfig_LoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schema.yaml")
+	writeSchemaConfigFile(t, path, schemaConfigPeriod(0, "boltdb"))
+
+	cfg := SchemaConfig{File: path}
+	require.NoError(t, cfg.LoadFile())
+	require.Len(t, cfg.Configs, 1)
+
+	// the periods can't be set in both places.
+	require.Equal(t, errSchemaConfigFileAndConfigs, cfg.LoadFile())
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"flag"
 	"sort"
+	"sync"
 	"time"
 
 	"github.com/go-kit/log"
@@ -17,12 +18,18 @@ import (
 	"github.com/grafana/loki/pkg/iter"
 	"github.com/grafana/loki/pkg/logproto"
 	"github.com/grafana/loki/pkg/logql"
+	logqllog "github.com/grafana/loki/pkg/logql/log"
+	"github.com/grafana/loki/pkg/logql/syntax"
 	"github.com/grafana/loki/pkg/logqlmodel/stats"
 	"github.com/grafana/loki/pkg/querier/astmapper"
 	"github.com/grafana/loki/pkg/storage/chunk"
 	chunk_local "github.com/grafana/loki/pkg/storage/chunk/local"
 	"github.com/grafana/loki/pkg/storage/chunk/storage"
 	"github.com/grafana/loki/pkg/storage/stores/shipper"
+	"github.com/grafana/loki/pkg/storage/stores/shipper/bloom"
+	"github.com/grafana/loki/pkg/storage/stores/shipper/indexgateway"
+	"github.com/grafana/loki/pkg/storage/stores/shipper/quarantine"
+	"github.com/grafana/loki/pkg/storage/tsdb"
 	"github.com/grafana/loki/pkg/tenant"
 	"github.com/grafana/loki/pkg/usagestats"
 	"github.com/grafana/loki/pkg/util"
@@ -40,20 +47,37 @@ var (
 // Config is the loki storage configuration
 type Config struct {
 	storage.Config      `yaml:",inline"`
-	MaxChunkBatchSize   int            `yaml:"max_chunk_batch_size"`
-	BoltDBShipperConfig shipper.Config `yaml:"boltdb_shipper"`
+	MaxChunkBatchSize   int               `yaml:"max_chunk_batch_size"`
+	BoltDBShipperConfig shipper.Config    `yaml:"boltdb_shipper"`
+	TSDBShipperConfig   tsdb.Config       `yaml:"tsdb_shipper"`
+	ChunkQuarantine     quarantine.Config `yaml:"chunk_quarantine"`
+	ChunkBlooms         bloom.Config      `yaml:"chunk_blooms"`
 }
 
 // RegisterFlags adds the flags required to configure this flag set.
 func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	cfg.Config.RegisterFlags(f)
 	cfg.BoltDBShipperConfig.RegisterFlags(f)
+	cfg.TSDBShipperConfig.RegisterFlags(f)
+	cfg.ChunkQuarantine.RegisterFlags(f)
+	cfg.ChunkBlooms.RegisterFlags(f)
 	f.IntVar(&cfg.MaxChunkBatchSize, "store.max-chunk-batch-size", 50, "The maximum number of chunks to fetch per batch.")
 }
 
 // SchemaConfig contains the config for our chunk index schemas
 type SchemaConfig struct {
 	chunk.SchemaConfig `yaml:",inline"`
+
+	// File holds the period configs instead of the config file. It is watched for new periods.
+	File         string        `yaml:"file"`
+	ReloadPeriod time.Duration `yaml:"reload_period"`
+}
+
+// RegisterFlags adds the flags required to config this to the given FlagSet.
+func (cfg *SchemaConfig) RegisterFlags(f *flag.FlagSet) {
+	cfg.SchemaConfig.RegisterFlags(f)
+	f.StringVar(&cfg.File, "schema-config.file", "", "The path to a file holding the period configs, which is reloaded at runtime. New period configs starting in the future are picked up without a restart.")
+	f.DurationVar(&cfg.ReloadPeriod, "schema-config.reload-period", time.Minute, "How often the schema config file is reloaded.")
 }
 
 // Validate the schema config and returns an error if the validation doesn't pass
@@ -61,19 +85,43 @@ func (cfg *SchemaConfig) Validate() error {
 	if len(cfg.Configs) == 0 {
 		return errZeroLengthConfig
 	}
-	activePCIndex := ActivePeriodConfig((*cfg).Configs)
+	errs := validateBoltdbShipperPeriods(cfg.Configs)
+	for userID, tenantCfg := range cfg.TenantConfigs {
+		if tenantCfg == nil || len(tenantCfg.Configs) == 0 {
+			continue
+		}
+		for _, err := range validateBoltdbShipperPeriods(tenantCfg.Configs) {
+			err.Tenant = userID
+			errs = append(errs, err)
+		}
+	}
 
+	// report the errors of the whole schema config at once.
+	err := cfg.SchemaConfig.Validate()
+	var schemaErr *chunk.SchemaConfigError
+	if errors.As(err, &schemaErr) {
+		errs = append(errs, schemaErr.Errors...)
+	} else if err != nil {
+		return err
+	}
+	return chunk.NewSchemaConfigError(errs)
+}
+
+// validateBoltdbShipperPeriods checks the index period of the current and upcoming boltdb-shipper period configs.
+func validateBoltdbShipperPeriods(configs []chunk.PeriodConfig) []*chunk.PeriodConfigError {
+	activePCIndex := ActivePeriodConfig(configs)
+
+	var errs []*chunk.PeriodConfigError
 	// if current index type is boltdb-shipper and there are no upcoming index types then it should be set to 24 hours.
-	if cfg.Configs[activePCIndex].IndexType == shipper.BoltDBShipperType && cfg.Configs[activePCIndex].IndexTables.Period != 24*time.Hour && len(cfg.Configs)-1 == activePCIndex {
-		return errCurrentBoltdbShipperNon24Hours
+	if configs[activePCIndex].IndexType == shipper.BoltDBShipperType && configs[activePCIndex].IndexTables.Period != 24*time.Hour && len(configs)-1 == activePCIndex {
+		errs = append(errs, chunk.NewPeriodConfigError(activePCIndex, configs[activePCIndex], "index.period", errCurrentBoltdbShipperNon24Hours))
 	}
 
 	// if upcoming index type is boltdb-shipper, it should always be set to 24 hours.
-	if len(cfg.Configs)-1 > activePCIndex && (cfg.Configs[activePCIndex+1].IndexType == shipper.BoltDBShipperType && cfg.Configs[activePCIndex+1].IndexTables.Period != 24*time.Hour) {
-		return errUpcomingBoltdbShipperNon24Hours
+	if len(configs)-1 > activePCIndex && (configs[activePCIndex+1].IndexType == shipper.BoltDBShipperType && configs[activePCIndex+1].IndexTables.Period != 24*time.Hour) {
+		errs = append(errs, chunk.NewPeriodConfigError(activePCIndex+1, configs[activePCIndex+1], "index.period", errUpcomingBoltdbShipperNon24Hours))
 	}
-
-	return cfg.SchemaConfig.Validate()
+	return errs
 }
 
 type ChunkStoreConfig struct {
@@ -104,8 +152,15 @@ type Store interface {
 	SelectSamples(ctx context.Context, req logql.SelectSampleParams) (iter.SampleIterator, error)
 	SelectLogs(ctx context.Context, req logql.SelectLogParams) (iter.EntryIterator, error)
 	GetSeries(ctx context.Context, req logql.SelectLogParams) ([]logproto.SeriesIdentifier, error)
+	// Stats returns the statistics of the chunks of the streams matching the matchers, estimated from the index.
+	Stats(ctx context.Context, from, through model.Time, matchers ...*labels.Matcher) (*logproto.IndexStatsResponse, error)
 	GetSchemaConfigs() []chunk.PeriodConfig
+	// GetTenantSchemaConfigs returns the period configs used for the tenant, its own ones when it has some.
+	GetTenantSchemaConfigs(userID string) []chunk.PeriodConfig
 	SetChunkFilterer(chunkFilter RequestChunkFilterer)
+	SetChunkQuarantine(chunkQuarantine ChunkQuarantine)
+	SetChunkBlooms(chunkBlooms ChunkBlooms)
+	SetDataLocality(limits DataLocalityLimits)
 }
 
 // RequestChunkFilterer creates ChunkFilterer for a given request context.
@@ -118,13 +173,32 @@ type ChunkFilterer interface {
 	ShouldFilter(metric labels.Labels) bool
 }
 
+// ChunkQuarantine tells the chunks quarantined as corrupt, which are skipped by the queries.
+type ChunkQuarantine interface {
+	Quarantined(externalKey string) bool
+	// Empty returns whether no chunk is quarantined.
+	Empty() bool
+}
+
+// ChunkBlooms tells the chunks which can't contain the needles of the line filters of a query, from the blooms of
+// their lines built by the compactor.
+type ChunkBlooms interface {
+	// MayContain returns whether the chunk of the external key, indexed in the table, may contain the needles. It
+	// does when the chunk has no bloom.
+	MayContain(ctx context.Context, tableName, userID, externalKey string, needles bloom.Needles) bool
+}
+
 type store struct {
 	chunk.Store
 	cfg          Config
 	chunkMetrics *ChunkMetrics
+	schemaMtx    sync.RWMutex
 	schemaCfg    SchemaConfig
 
-	chunkFilterer RequestChunkFilterer
+	chunkFilterer   RequestChunkFilterer
+	chunkQuarantine ChunkQuarantine
+	chunkBlooms     ChunkBlooms
+	dataLocality    DataLocalityLimits
 }
 
 // NewStore creates a new Loki Store using configuration supplied.
@@ -207,8 +281,67 @@ func (s *store) SetChunkFilterer(chunkFilterer RequestChunkFilterer) {
 	s.chunkFilterer = chunkFilterer
 }
 
+func (s *store) SetChunkQuarantine(chunkQuarantine ChunkQuarantine) {
+	s.chunkQuarantine = chunkQuarantine
+}
+
+func (s *store) SetChunkBlooms(chunkBlooms ChunkBlooms) {
+	s.chunkBlooms = chunkBlooms
+}
+
+func (s *store) SetDataLocality(limits DataLocalityLimits) {
+	s.dataLocality = limits
+}
+
+// checkDataLocality returns a DataLocalityError when the data of the tenant in the time range is held by a store
+// the tenant isn't pinned to.
+func (s *store) checkDataLocality(userID string, from, through model.Time) error {
+	if s.dataLocality == nil {
+		return nil
+	}
+	return checkDataLocality(&s.cfg, s.schemaConfig(), userID, s.dataLocality.AllowedObjectStores(userID), from, through)
+}
+
+func (s *store) Put(ctx context.Context, chunks []chunk.Chunk) error {
+	for _, c := range chunks {
+		if err := s.checkDataLocality(c.UserID, c.From, c.Through); err != nil {
+			return err
+		}
+	}
+	return s.Store.Put(ctx, chunks)
+}
+
+func (s *store) PutOne(ctx context.Context, from, through model.Time, c chunk.Chunk) error {
+	if err := s.checkDataLocality(c.UserID, from, through); err != nil {
+		return err
+	}
+	return s.Store.PutOne(ctx, from, through, c)
+}
+
+func (s *store) GetChunkRefs(ctx context.Context, userID string, from, through model.Time, matchers ...*labels.Matcher) ([][]chunk.Chunk, []*chunk.Fetcher, error) {
+	if err := s.checkDataLocality(userID, from, through); err != nil {
+		return nil, nil, err
+	}
+	return s.Store.GetChunkRefs(ctx, userID, from, through, matchers...)
+}
+
+func (s *store) LabelValuesForMetricName(ctx context.Context, userID string, from, through model.Time, metricName string, labelName string, matchers ...*labels.Matcher) ([]string, error) {
+	if err := s.checkDataLocality(userID, from, through); err != nil {
+		return nil, err
+	}
+	return s.Store.LabelValuesForMetricName(ctx, userID, from, through, metricName, labelName, matchers...)
+}
+
+func (s *store) LabelNamesForMetricName(ctx context.Context, userID string, from, through model.Time, metricName string, matchers ...*labels.Matcher) ([]string, error) {
+	if err := s.checkDataLocality(userID, from, through); err != nil {
+		return nil, err
+	}
+	return s.Store.LabelNamesForMetricName(ctx, userID, from, through, metricName, matchers...)
+}
+
 // lazyChunks is an internal function used to resolve a set of lazy chunks from the store without actually loading them. It's used internally by `LazyQuery` and `GetSeries`
-func (s *store) lazyChunks(ctx context.Context, matchers []*labels.Matcher, from, through model.Time) ([]*LazyChunk, error) {
+// The chunks which can't contain the needles, from their blooms, are skipped.
+func (s *store) lazyChunks(ctx context.Context, matchers []*labels.Matcher, from, through model.Time, needles bloom.Needles) ([]*LazyChunk, error) {
 	userID, err := tenant.TenantID(ctx)
 	if err != nil {
 		return nil, err
@@ -223,14 +356,43 @@ func (s *store) lazyChunks(ctx context.Context, matchers []*labels.Matcher, from
 
 	var prefiltered int
 	var filtered int
+	periods := newPeriodsStats(s.schemaConfig())
 	for i := range chks {
 		prefiltered += len(chks[i])
 		stats.AddChunksRef(int64(len(chks[i])))
+		periods.addRefs(chks[i])
 		chks[i] = filterChunksByTime(from, through, chks[i])
 		filtered += len(chks[i])
 	}
+	periods.add(stats)
 
-	s.chunkMetrics.refs.WithLabelValues(statusDiscarded).Add(float64(prefiltered - filtered))
+	var quarantined int
+	if s.chunkQuarantine != nil && !s.chunkQuarantine.Empty() {
+		schemaCfg := s.schemaConfig()
+		for i := range chks {
+			var n int
+			chks[i], n = filterQuarantinedChunks(schemaCfg, s.chunkQuarantine, chks[i])
+			quarantined += n
+		}
+		filtered -= quarantined
+		stats.AddChunksQuarantined(int64(quarantined))
+	}
+
+	var bloomFiltered int
+	if s.chunkBlooms != nil && len(needles) > 0 {
+		schemaCfg := s.schemaConfig()
+		for i := range chks {
+			var n int
+			chks[i], n = filterChunksByBlooms(ctx, schemaCfg, s.chunkBlooms, userID, needles, chks[i])
+			bloomFiltered += n
+		}
+		filtered -= bloomFiltered
+		stats.AddChunksBloomFiltered(int64(bloomFiltered))
+	}
+
+	s.chunkMetrics.refs.WithLabelValues(statusDiscarded).Add(float64(prefiltered - filtered - quarantined - bloomFiltered))
+	s.chunkMetrics.refs.WithLabelValues(statusQuarantined).Add(float64(quarantined))
+	s.chunkMetrics.refs.WithLabelValues(statusBloomFiltered).Add(float64(bloomFiltered))
 	s.chunkMetrics.refs.WithLabelValues(statusMatched).Add(float64(filtered))
 
 	// creates lazychunks with chunks ref.
@@ -268,7 +430,7 @@ func (s *store) GetSeries(ctx context.Context, req logql.SelectLogParams) ([]log
 		}
 	}
 
-	lazyChunks, err := s.lazyChunks(ctx, matchers, from, through)
+	lazyChunks, err := s.lazyChunks(ctx, matchers, from, through, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -307,7 +469,7 @@ func (s *store) GetSeries(ctx context.Context, req logql.SelectLogParams) ([]log
 	}
 
 	for _, group := range groups {
-		err = fetchLazyChunks(ctx, s.schemaCfg.SchemaConfig, group)
+		err = fetchLazyChunks(ctx, s.schemaConfig(), group)
 		if err != nil {
 			return nil, err
 		}
@@ -346,12 +508,12 @@ func (s *store) SelectLogs(ctx context.Context, req logql.SelectLogParams) (iter
 		return nil, err
 	}
 
-	lazyChunks, err := s.lazyChunks(ctx, matchers, from, through)
+	expr, err := req.LogSelector()
 	if err != nil {
 		return nil, err
 	}
 
-	expr, err := req.LogSelector()
+	lazyChunks, err := s.lazyChunks(ctx, matchers, from, through, s.bloomNeedles(expr))
 	if err != nil {
 		return nil, err
 	}
@@ -360,6 +522,7 @@ func (s *store) SelectLogs(ctx context.Context, req logql.SelectLogParams) (iter
 	if err != nil {
 		return nil, err
 	}
+	pipeline = logqllog.PipelineWithParseStats(pipeline, stats.FromContext(ctx))
 
 	if len(lazyChunks) == 0 {
 		return iter.NoopIterator, nil
@@ -369,7 +532,7 @@ func (s *store) SelectLogs(ctx context.Context, req logql.SelectLogParams) (iter
 		chunkFilterer = s.chunkFilterer.ForRequest(ctx)
 	}
 
-	return newLogBatchIterator(ctx, s.schemaCfg.SchemaConfig, s.chunkMetrics, lazyChunks, s.cfg.MaxChunkBatchSize, matchers, pipeline, req.Direction, req.Start, req.End, chunkFilterer)
+	return newLogBatchIterator(ctx, s.schemaConfig(), s.chunkMetrics, lazyChunks, s.cfg.MaxChunkBatchSize, matchers, pipeline, req.Direction, req.Start, req.End, chunkFilterer)
 }
 
 func (s *store) SelectSamples(ctx context.Context, req logql.SelectSampleParams) (iter.SampleIterator, error) {
@@ -387,8 +550,9 @@ func (s *store) SelectSamples(ctx context.Context, req logql.SelectSampleParams)
 	if err != nil {
 		return nil, err
 	}
+	extractor = logqllog.SampleExtractorWithParseStats(extractor, stats.FromContext(ctx))
 
-	lazyChunks, err := s.lazyChunks(ctx, matchers, from, through)
+	lazyChunks, err := s.lazyChunks(ctx, matchers, from, through, s.bloomNeedles(expr.Selector()))
 	if err != nil {
 		return nil, err
 	}
@@ -401,11 +565,74 @@ func (s *store) SelectSamples(ctx context.Context, req logql.SelectSampleParams)
 		chunkFilterer = s.chunkFilterer.ForRequest(ctx)
 	}
 
-	return newSampleBatchIterator(ctx, s.schemaCfg.SchemaConfig, s.chunkMetrics, lazyChunks, s.cfg.MaxChunkBatchSize, matchers, extractor, req.Start, req.End, chunkFilterer)
+	return newSampleBatchIterator(ctx, s.schemaConfig(), s.chunkMetrics, lazyChunks, s.cfg.MaxChunkBatchSize, matchers, extractor, req.Start, req.End, chunkFilterer)
 }
 
 func (s *store) GetSchemaConfigs() []chunk.PeriodConfig {
-	return s.schemaCfg.Configs
+	return s.schemaConfig().Configs
+}
+
+func (s *store) GetTenantSchemaConfigs(userID string) []chunk.PeriodConfig {
+	return s.schemaConfig().ForTenant(userID).Configs
+}
+
+func (s *store) schemaConfig() chunk.SchemaConfig {
+	s.schemaMtx.RLock()
+	defer s.schemaMtx.RUnlock()
+	return s.schemaCfg.SchemaConfig
+}
+
+// AddPeriodConfig implements chunk.PeriodConfigAdder. The periods using stores the tenants aren't pinned to are refused.
+func (s *store) AddPeriodConfig(cfg chunk.PeriodConfig) error {
+	if s.dataLocality != nil {
+		schemaCfg := s.schemaConfig()
+		schemaCfg.Configs = appendPeriodConfig(schemaCfg.Configs, cfg)
+		if err := ValidateDataLocality(&s.cfg, schemaCfg, s.dataLocality, model.Now()); err != nil {
+			return err
+		}
+	}
+	if err := addPeriodConfig(s.Store, cfg); err != nil {
+		return err
+	}
+
+	s.schemaMtx.Lock()
+	defer s.schemaMtx.Unlock()
+	s.schemaCfg.Configs = appendPeriodConfig(s.schemaCfg.Configs, cfg)
+	return nil
+}
+
+// filterQuarantinedChunks removes the chunks quarantined as corrupt, and returns how many were removed.
+func filterQuarantinedChunks(schemaCfg chunk.SchemaConfig, quarantine ChunkQuarantine, chunks []chunk.Chunk) ([]chunk.Chunk, int) {
+	filtered := chunks[:0]
+	for _, c := range chunks {
+		if quarantine.Quarantined(schemaCfg.ExternalKey(c)) {
+			continue
+		}
+		filtered = append(filtered, c)
+	}
+	return filtered, len(chunks) - len(filtered)
+}
+
+// bloomNeedles returns the needles of the line filters of the log selector, nil when the chunks have no blooms.
+func (s *store) bloomNeedles(expr syntax.LogSelectorExpr) bloom.Needles {
+	if s.chunkBlooms == nil {
+		return nil
+	}
+	return bloom.NewNeedles(syntax.LineFilterNeedles(expr))
+}
+
+// filterChunksByBlooms removes the chunks which can't contain the needles, and returns how many were removed.
+func filterChunksByBlooms(ctx context.Context, schemaCfg chunk.SchemaConfig, blooms ChunkBlooms, userID string, needles bloom.Needles, chunks []chunk.Chunk) ([]chunk.Chunk, int) {
+	filtered := chunks[:0]
+	for _, c := range chunks {
+		// the chunks are indexed in the table of their start, amongst others.
+		if period, err := schemaCfg.SchemaForTime(c.From); err == nil &&
+			!blooms.MayContain(ctx, period.IndexTables.TableFor(c.From), userID, schemaCfg.ExternalKey(c), needles) {
+			continue
+		}
+		filtered = append(filtered, c)
+	}
+	return filtered, len(chunks) - len(filtered)
 }
 
 func filterChunksByTime(from, through model.Time, chunks []chunk.Chunk) []chunk.Chunk {
@@ -430,7 +657,8 @@ func RegisterCustomIndexClients(cfg *Config, cm storage.ClientMetrics, registere
 			return boltDBIndexClientWithShipper, nil
 		}
 
-		if cfg.BoltDBShipperConfig.Mode == shipper.ModeReadOnly && cfg.BoltDBShipperConfig.IndexGatewayClientConfig.Address != "" {
+		if cfg.BoltDBShipperConfig.Mode == shipper.ModeReadOnly &&
+			(cfg.BoltDBShipperConfig.IndexGatewayClientConfig.Address != "" || cfg.BoltDBShipperConfig.IndexGatewayClientConfig.Mode == indexgateway.RingMode) {
 			gateway, err := shipper.NewGatewayClient(cfg.BoltDBShipperConfig.IndexGatewayClientConfig, registerer)
 			if err != nil {
 				return nil, err
@@ -456,6 +684,23 @@ func RegisterCustomIndexClients(cfg *Config, cm storage.ClientMetrics, registere
 
 		return shipper.NewBoltDBShipperTableClient(objectClient, cfg.BoltDBShipperConfig.SharedStoreKeyPrefix), nil
 	})
+
+	storage.RegisterSeriesIndex(tsdb.IndexType, func(period chunk.PeriodConfig, name string, _ storage.StoreLimits, registerer prometheus.Registerer) (chunk.SeriesIndex, error) {
+		objectClient, err := storage.NewObjectClient(cfg.TSDBShipperConfig.SharedStoreType, cfg.Config, cm)
+		if err != nil {
+			return nil, err
+		}
+
+		return tsdb.NewIndexShipper(cfg.TSDBShipperConfig, period, name, objectClient, registerer)
+	})
+	storage.RegisterIndexStore(tsdb.IndexType, nil, func() (chunk.TableClient, error) {
+		objectClient, err := storage.NewObjectClient(cfg.TSDBShipperConfig.SharedStoreType, cfg.Config, cm)
+		if err != nil {
+			return nil, err
+		}
+
+		return tsdb.NewTableClient(objectClient, cfg.TSDBShipperConfig.SharedStoreKeyPrefix), nil
+	})
 }
 
 // ActivePeriodConfig returns index of active PeriodicConfig which would be applicable to logs that would be pushed starting now.
@@ -471,6 +716,33 @@ func ActivePeriodConfig(configs []chunk.PeriodConfig) int {
 	return i
 }
 
+// UsingObjectStorageIndex checks whether current or the next index type keeps the index in the object storage,
+// i.e. boltdb-shipper or tsdb, returns true if yes.
+func UsingObjectStorageIndex(configs []chunk.PeriodConfig) bool {
+	activePCIndex := ActivePeriodConfig(configs)
+	if IsObjectStorageIndex(configs[activePCIndex].IndexType) ||
+		(len(configs)-1 > activePCIndex && IsObjectStorageIndex(configs[activePCIndex+1].IndexType)) {
+		return true
+	}
+
+	return false
+}
+
+// AnyTenantUsing tells whether the global period configs or the ones of a tenant satisfy using, like UsingBoltdbShipper.
+func AnyTenantUsing(cfg chunk.SchemaConfig, using func([]chunk.PeriodConfig) bool) bool {
+	for _, configs := range cfg.AllConfigs() {
+		if len(configs) > 0 && using(configs) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsObjectStorageIndex returns whether the index type keeps the index in the object storage.
+func IsObjectStorageIndex(indexType string) bool {
+	return indexType == shipper.BoltDBShipperType || indexType == tsdb.IndexType
+}
+
 // UsingBoltdbShipper checks whether current or the next index type is boltdb-shipper, returns true if yes.
 func UsingBoltdbShipper(configs []chunk.PeriodConfig) bool {
 	activePCIndex := ActivePeriodConfig(configs)
@@ -481,3 +753,14 @@ func UsingBoltdbShipper(configs []chunk.PeriodConfig) bool {
 
 	return false
 }
+
+// UsingTSDB checks whether current or the next index type is tsdb, returns true if yes.
+func UsingTSDB(configs []chunk.PeriodConfig) bool {
+	activePCIndex := ActivePeriodConfig(configs)
+	if configs[activePCIndex].IndexType == tsdb.IndexType ||
+		(len(configs)-1 > activePCIndex && configs[activePCIndex+1].IndexType == tsdb.IndexType) {
+		return true
+	}
+
+	return false
+}
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net/http"
 	_ "net/http/pprof"
@@ -23,11 +24,13 @@ import (
 	"github.com/grafana/loki/pkg/iter"
 	"github.com/grafana/loki/pkg/logproto"
 	"github.com/grafana/loki/pkg/logql"
+	"github.com/grafana/loki/pkg/logqlmodel/stats"
 	"github.com/grafana/loki/pkg/querier/astmapper"
 	"github.com/grafana/loki/pkg/storage/chunk"
 	chunk_local "github.com/grafana/loki/pkg/storage/chunk/local"
 	"github.com/grafana/loki/pkg/storage/chunk/storage"
 	"github.com/grafana/loki/pkg/storage/stores/shipper"
+	"github.com/grafana/loki/pkg/storage/stores/shipper/bloom"
 	util_log "github.com/grafana/loki/pkg/util/log"
 	"github.com/grafana/loki/pkg/util/marshal"
 	"github.com/grafana/loki/pkg/validation"
@@ -197,7 +200,7 @@ func getLocalStore(cm storage.ClientMetrics) Store {
 	}
 
 	schemaConfig := SchemaConfig{
-		chunk.SchemaConfig{
+		SchemaConfig: chunk.SchemaConfig{
 			Configs: []chunk.PeriodConfig{
 				{
 					From:       chunk.DayTime{Time: start},
@@ -673,6 +676,193 @@ func Test_ChunkFilterer(t *testing.T) {
 	}
 }
 
+// fakeChunkQuarantine quarantines the chunks of the given keys.
+type fakeChunkQuarantine map[string]struct{}
+
+func (q fakeChunkQuarantine) Quarantined(key string) bool {
+	_, ok := q[key]
+	return ok
+}
+
+func (q fakeChunkQuarantine) Empty() bool {
+	return len(q) == 0
+}
+
+func Test_ChunkQuarantine(t *testing.T) {
+	s := &store{
+		Store: storeFixture,
+		cfg: Config{
+			MaxChunkBatchSize: 10,
+		},
+		chunkMetrics: NilMetrics,
+		schemaCfg:    SchemaConfig{SchemaConfig: storeFixture.schemas},
+	}
+	quarantine := fakeChunkQuarantine{}
+	for _, c := range storeFixture.chunks {
+		if c.Metric.Get("foo") == "bazz" {
+			quarantine[storeFixture.schemas.ExternalKey(c)] = struct{}{}
+		}
+	}
+	require.NotEmpty(t, quarantine)
+	s.SetChunkQuarantine(quarantine)
+
+	statsCtx, ctx := stats.NewContext(user.InjectOrgID(context.Background(), "test-user"))
+	it, err := s.SelectLogs(ctx, logql.SelectLogParams{QueryRequest: newQuery("{foo=~\"ba.*\"}", from, from.Add(1*time.Hour), nil)})
+	require.NoError(t, err)
+	defer it.Close()
+	var streams []string
+	for it.Next() {
+		streams = append(streams, it.Labels())
+	}
+	require.NoError(t, it.Error())
+	require.NotEmpty(t, streams)
+	require.NotContains(t, streams, `{foo="bazz"}`)
+	require.Equal(t, int64(len(quarantine)), statsCtx.Result(0, 0).TotalChunksQuarantined())
+}
+
+// fakeChunkBlooms tells the chunks of the given keys can't contain the needles, and records the tables queried.
+type fakeChunkBlooms struct {
+	skipped map[string]struct{}
+	tables  map[string]struct{}
+}
+
+func (b *fakeChunkBlooms) MayContain(_ context.Context, tableName, _, externalKey string, needles bloom.Needles) bool {
+	b.tables[tableName] = struct{}{}
+	_, ok := b.skipped[externalKey]
+	return !ok || len(needles) == 0
+}
+
+func Test_ChunkBlooms(t *testing.T) {
+	schemaCfg := chunk.SchemaConfig{Configs: []chunk.PeriodConfig{
+		{From: chunk.DayTime{Time: 0}, IndexType: "boltdb-shipper", ObjectType: "filesystem", Schema: "v11", IndexTables: chunk.PeriodicTableConfig{Prefix: "index_", Period: 24 * time.Hour}},
+	}}
+	s := &store{
+		Store: storeFixture,
+		cfg: Config{
+			MaxChunkBatchSize: 10,
+		},
+		chunkMetrics: NilMetrics,
+		schemaCfg:    SchemaConfig{SchemaConfig: schemaCfg},
+	}
+	blooms := &fakeChunkBlooms{skipped: map[string]struct{}{}, tables: map[string]struct{}{}}
+	for _, c := range storeFixture.chunks {
+		if c.Metric.Get("foo") == "bazz" {
+			blooms.skipped[schemaCfg.ExternalKey(c)] = struct{}{}
+		}
+	}
+	require.NotEmpty(t, blooms.skipped)
+	s.SetChunkBlooms(blooms)
+
+	selectStreams := func(query string) ([]string, stats.Result) {
+		statsCtx, ctx := stats.NewContext(user.InjectOrgID(context.Background(), "test-user"))
+		it, err := s.SelectLogs(ctx, logql.SelectLogParams{QueryRequest: newQuery(query, from, from.Add(1*time.Hour), nil)})
+		require.NoError(t, err)
+		defer it.Close()
+		var streams []string
+		for it.Next() {
+			streams = append(streams, it.Labels())
+		}
+		require.NoError(t, it.Error())
+		return streams, statsCtx.Result(0, 0)
+	}
+
+	// the queries without line filter long enough to be tested don't use the blooms.
+	streams, result := selectStreams(`{foo=~"ba.*"} |= "1"`)
+	require.Contains(t, streams, `{foo="bazz"}`)
+	require.Equal(t, int64(0), result.TotalChunksBloomFiltered())
+	require.Empty(t, blooms.tables)
+
+	_, result = selectStreams(`{foo=~"ba.*"} |= "line"`)
+	require.Equal(t, int64(len(blooms.skipped)), result.TotalChunksBloomFiltered())
+	require.Equal(t, map[string]struct{}{schemaCfg.Configs[0].IndexTables.TableFor(model.TimeFromUnixNano(from.UnixNano())): {}}, blooms.tables)
+}
+
+func Test_DataLocality(t *testing.T) {
+	schemaCfg := chunk.SchemaConfig{Configs: []chunk.PeriodConfig{
+		{From: chunk.DayTime{Time: 0}, IndexType: "boltdb-shipper", ObjectType: "filesystem", Schema: "v11", IndexTables: chunk.PeriodicTableConfig{Prefix: "index_", Period: 24 * time.Hour}},
+	}}
+	s := &store{
+		Store: storeFixture,
+		cfg: Config{
+			MaxChunkBatchSize: 10,
+		},
+		chunkMetrics: NilMetrics,
+		schemaCfg:    SchemaConfig{SchemaConfig: schemaCfg},
+	}
+	s.cfg.BoltDBShipperConfig.SharedStoreType = "filesystem"
+	s.SetDataLocality(fakeDataLocalityLimits{"pinned-user": {"gcs"}})
+
+	selectLogs := func(userID string) error {
+		ctx := user.InjectOrgID(context.Background(), userID)
+		it, err := s.SelectLogs(ctx, logql.SelectLogParams{QueryRequest: newQuery("{foo=~\"ba.*\"}", from, from.Add(1*time.Hour), nil)})
+		if err != nil {
+			return err
+		}
+		return it.Close()
+	}
+	require.NoError(t, selectLogs("test-user"))
+
+	var localityErr *DataLocalityError
+	require.True(t, errors.As(selectLogs("pinned-user"), &localityErr))
+	require.Equal(t, "filesystem", localityErr.Store)
+
+	c := storeFixture.chunks[0]
+	require.NoError(t, s.Put(context.Background(), []chunk.Chunk{c}))
+	c.UserID = "pinned-user"
+	require.True(t, errors.As(s.Put(context.Background(), []chunk.Chunk{c}), &localityErr))
+	require.True(t, errors.As(s.PutOne(context.Background(), c.From, c.Through, c), &localityErr))
+}
+
+func Test_PeriodsStats(t *testing.T) {
+	schemaCfg := chunk.SchemaConfig{Configs: []chunk.PeriodConfig{
+		{From: chunk.DayTime{Time: 0}, IndexType: "cassandra", ObjectType: "cassandra", Schema: "v11"},
+		{From: chunk.DayTime{Time: model.TimeFromUnix(86400)}, IndexType: "boltdb-shipper", ObjectType: "gcs", Schema: "v11"},
+	}}
+	s := &store{
+		Store: storeFixture,
+		cfg: Config{
+			MaxChunkBatchSize: 10,
+		},
+		chunkMetrics: NilMetrics,
+		schemaCfg:    SchemaConfig{SchemaConfig: schemaCfg},
+	}
+
+	statsCtx, ctx := stats.NewContext(user.InjectOrgID(context.Background(), "test-user"))
+	it, err := s.SelectLogs(ctx, logql.SelectLogParams{QueryRequest: newQuery("{foo=~\"ba.*\"}", from, from.Add(1*time.Hour), nil)})
+	require.NoError(t, err)
+	for it.Next() {
+	}
+	require.NoError(t, it.Error())
+	require.NoError(t, it.Close())
+
+	// the chunks of the fixture are all in the first period.
+	res := statsCtx.Result(0, 0)
+	require.Len(t, res.Querier.Periods, 1)
+	period := res.Querier.Periods[0]
+	require.Equal(t, "1970-01-01", period.From)
+	require.Equal(t, "cassandra", period.Store)
+	require.Equal(t, "cassandra", period.ObjectStore)
+	require.Equal(t, "v11", period.Schema)
+	require.Equal(t, res.TotalChunksRef(), period.TotalChunksRef)
+	require.Equal(t, res.TotalChunksDownloaded(), period.TotalChunksDownloaded)
+	require.NotZero(t, period.TotalChunksDownloaded)
+	require.NotZero(t, period.ChunksDownloadedBytes)
+
+	periods := newPeriodsStats(schemaCfg)
+	periods.addRefs([]chunk.Chunk{
+		{ChunkRef: logproto.ChunkRef{From: model.TimeFromUnix(3600)}},
+		{ChunkRef: logproto.ChunkRef{From: model.TimeFromUnix(86400 + 3600)}},
+		{ChunkRef: logproto.ChunkRef{From: model.TimeFromUnix(86400 + 7200)}},
+	})
+	periods.addFetched(chunk.Chunk{ChunkRef: logproto.ChunkRef{From: model.TimeFromUnix(86400 + 3600)}}, 2, 1024, time.Second)
+	statsCtx, _ = stats.NewContext(context.Background())
+	periods.add(statsCtx)
+	require.Equal(t, []stats.Period{
+		{From: "1970-01-01", Store: "cassandra", ObjectStore: "cassandra", Schema: "v11", TotalChunksRef: 1},
+		{From: "1970-01-02", Store: "boltdb-shipper", ObjectStore: "gcs", Schema: "v11", TotalChunksRef: 2, TotalChunksDownloaded: 2, ChunksDownloadedBytes: 1024, ChunksDownloadTime: int64(time.Second)},
+	}, statsCtx.Result(0, 0).Querier.Periods)
+}
+
 func Test_store_GetSeries(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -809,7 +999,7 @@ func TestStore_MultipleBoltDBShippersInConfig(t *testing.T) {
 	}
 
 	schemaConfig := SchemaConfig{
-		chunk.SchemaConfig{
+		SchemaConfig: chunk.SchemaConfig{
 			Configs: []chunk.PeriodConfig{
 				{
 					From:       chunk.DayTime{Time: timeToModelTime(firstStoreDate)},
@@ -1007,10 +1197,24 @@ func TestUsingBoltdbShipper(t *testing.T) {
 	assert.Equal(t, true, UsingBoltdbShipper(cfg.Configs))
 }
 
+func TestAnyTenantUsing(t *testing.T) {
+	cfg := chunk.SchemaConfig{
+		Configs: []chunk.PeriodConfig{{IndexType: "boltdb-shipper"}},
+	}
+	require.True(t, AnyTenantUsing(cfg, UsingBoltdbShipper))
+	require.False(t, AnyTenantUsing(cfg, UsingTSDB))
+
+	cfg.TenantConfigs = map[string]*chunk.SchemaConfig{"tenant": {Configs: []chunk.PeriodConfig{{IndexType: "tsdb"}}}}
+	require.True(t, AnyTenantUsing(cfg, UsingTSDB))
+}
+
 func TestSchemaConfig_Validate(t *testing.T) {
+	// period configs not starting on a day must be aligned with their tables.
+	today := model.TimeFromUnix(time.Now().Truncate(24 * time.Hour).Unix())
 	for _, tc := range []struct {
 		name    string
 		configs []chunk.PeriodConfig
+		tenants map[string][]chunk.PeriodConfig
 		err     error
 	}{
 		{
@@ -1021,7 +1225,7 @@ func TestSchemaConfig_Validate(t *testing.T) {
 		{
 			name: "NOT using boltdb-shipper",
 			configs: []chunk.PeriodConfig{{
-				From:      chunk.DayTime{Time: model.Now().Add(-24 * time.Hour)},
+				From:      chunk.DayTime{Time: today.Add(-24 * time.Hour)},
 				IndexType: "boltdb",
 				Schema:    "v9",
 				IndexTables: chunk.PeriodicTableConfig{
@@ -1032,7 +1236,7 @@ func TestSchemaConfig_Validate(t *testing.T) {
 		{
 			name: "current config boltdb-shipper with 7 days periodic config, without future index type changes",
 			configs: []chunk.PeriodConfig{{
-				From:      chunk.DayTime{Time: model.Now().Add(-24 * time.Hour)},
+				From:      chunk.DayTime{Time: today.Add(-24 * time.Hour)},
 				IndexType: "boltdb-shipper",
 				Schema:    "v9",
 				IndexTables: chunk.PeriodicTableConfig{
@@ -1044,7 +1248,7 @@ func TestSchemaConfig_Validate(t *testing.T) {
 		{
 			name: "current config boltdb-shipper with 1 day periodic config, without future index type changes",
 			configs: []chunk.PeriodConfig{{
-				From:      chunk.DayTime{Time: model.Now().Add(-24 * time.Hour)},
+				From:      chunk.DayTime{Time: today.Add(-24 * time.Hour)},
 				IndexType: "boltdb-shipper",
 				Schema:    "v9",
 				IndexTables: chunk.PeriodicTableConfig{
@@ -1055,14 +1259,14 @@ func TestSchemaConfig_Validate(t *testing.T) {
 		{
 			name: "current config boltdb-shipper with 7 days periodic config, upcoming config NOT boltdb-shipper",
 			configs: []chunk.PeriodConfig{{
-				From:      chunk.DayTime{Time: model.Now().Add(-24 * time.Hour)},
+				From:      chunk.DayTime{Time: today.Add(-24 * time.Hour)},
 				IndexType: "boltdb-shipper",
 				Schema:    "v9",
 				IndexTables: chunk.PeriodicTableConfig{
 					Period: 24 * time.Hour,
 				},
 			}, {
-				From:      chunk.DayTime{Time: model.Now().Add(time.Hour)},
+				From:      chunk.DayTime{Time: today.Add(24 * time.Hour)},
 				IndexType: "boltdb",
 				Schema:    "v9",
 				IndexTables: chunk.PeriodicTableConfig{
@@ -1073,14 +1277,14 @@ func TestSchemaConfig_Validate(t *testing.T) {
 		{
 			name: "current and upcoming config boltdb-shipper with 7 days periodic config",
 			configs: []chunk.PeriodConfig{{
-				From:      chunk.DayTime{Time: model.Now().Add(-24 * time.Hour)},
+				From:      chunk.DayTime{Time: today.Add(-24 * time.Hour)},
 				IndexType: "boltdb-shipper",
 				Schema:    "v9",
 				IndexTables: chunk.PeriodicTableConfig{
 					Period: 24 * time.Hour,
 				},
 			}, {
-				From:      chunk.DayTime{Time: model.Now().Add(time.Hour)},
+				From:      chunk.DayTime{Time: today.Add(24 * time.Hour)},
 				IndexType: "boltdb-shipper",
 				Schema:    "v9",
 				IndexTables: chunk.PeriodicTableConfig{
@@ -1092,14 +1296,14 @@ func TestSchemaConfig_Validate(t *testing.T) {
 		{
 			name: "current config NOT boltdb-shipper, upcoming config boltdb-shipper with 7 days periodic config",
 			configs: []chunk.PeriodConfig{{
-				From:      chunk.DayTime{Time: model.Now().Add(-24 * time.Hour)},
+				From:      chunk.DayTime{Time: today.Add(-24 * time.Hour)},
 				IndexType: "boltdb",
 				Schema:    "v9",
 				IndexTables: chunk.PeriodicTableConfig{
 					Period: 24 * time.Hour,
 				},
 			}, {
-				From:      chunk.DayTime{Time: model.Now().Add(time.Hour)},
+				From:      chunk.DayTime{Time: today.Add(24 * time.Hour)},
 				IndexType: "boltdb-shipper",
 				Schema:    "v9",
 				IndexTables: chunk.PeriodicTableConfig{
@@ -1108,14 +1312,43 @@ func TestSchemaConfig_Validate(t *testing.T) {
 			}},
 			err: errUpcomingBoltdbShipperNon24Hours,
 		},
+		{
+			name: "current config NOT boltdb-shipper, tenant config boltdb-shipper with 7 days periodic config",
+			configs: []chunk.PeriodConfig{{
+				From:      chunk.DayTime{Time: today.Add(-24 * time.Hour)},
+				IndexType: "boltdb",
+				Schema:    "v9",
+				IndexTables: chunk.PeriodicTableConfig{
+					Period: 24 * time.Hour,
+				},
+			}},
+			tenants: map[string][]chunk.PeriodConfig{
+				"tenant": {{
+					From:      chunk.DayTime{Time: today.Add(-24 * time.Hour)},
+					IndexType: "boltdb-shipper",
+					Schema:    "v9",
+					IndexTables: chunk.PeriodicTableConfig{
+						Prefix: "tenant_",
+						Period: 7 * 24 * time.Hour,
+					},
+				}},
+			},
+			err: errCurrentBoltdbShipperNon24Hours,
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
-			cfg := SchemaConfig{chunk.SchemaConfig{Configs: tc.configs}}
+			cfg := SchemaConfig{SchemaConfig: chunk.SchemaConfig{Configs: tc.configs}}
+			for userID, configs := range tc.tenants {
+				if cfg.TenantConfigs == nil {
+					cfg.TenantConfigs = map[string]*chunk.SchemaConfig{}
+				}
+				cfg.TenantConfigs[userID] = &chunk.SchemaConfig{Configs: configs}
+			}
 			err := cfg.Validate()
 			if tc.err == nil {
 				require.NoError(t, err)
 			} else {
-				require.EqualError(t, err, tc.err.Error())
+				require.ErrorIs(t, err, tc.err)
 			}
 		})
 	}
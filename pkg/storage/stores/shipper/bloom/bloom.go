@@ -0,0 +1,159 @@
+package bloom
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+const (
+	// NGramLength is the length in bytes of the n-grams of the lines added to the blooms. The strings shorter than it
+	// can't be tested against the blooms.
+	NGramLength = 4
+
+	// falsePositiveRate is the rate the blooms are sized for, unless they exceed their maximum size.
+	falsePositiveRate = 0.01
+	maxHashes         = 16
+)
+
+// Bloom is a bloom filter of the n-grams of the lines of a chunk.
+type Bloom struct {
+	hashes uint32
+	bits   []uint64
+}
+
+// Builder accumulates the n-grams of the lines of a chunk to build its bloom.
+type Builder struct {
+	grams map[uint64]struct{}
+}
+
+// NewBuilder makes a builder of a bloom.
+func NewBuilder() *Builder {
+	return &Builder{grams: map[uint64]struct{}{}}
+}
+
+// AddLine adds the n-grams of a line.
+func (b *Builder) AddLine(line string) {
+	for i := 0; i+NGramLength <= len(line); i++ {
+		b.grams[xxhash.Sum64String(line[i:i+NGramLength])] = struct{}{}
+	}
+}
+
+// Build returns the bloom of the n-grams added, sized for the false positive rate up to the maximum size in bytes.
+func (b *Builder) Build(maxSize int) *Bloom {
+	n := float64(len(b.grams))
+	if n == 0 {
+		n = 1
+	}
+	bits := math.Ceil(-n * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2))
+	if maxBits := float64(maxSize * 8); bits > maxBits {
+		bits = maxBits
+	}
+	words := int(math.Ceil(bits / 64))
+	if words < 1 {
+		words = 1
+	}
+	hashes := uint32(math.Round(float64(words*64) / n * math.Ln2))
+	if hashes < 1 {
+		hashes = 1
+	} else if hashes > maxHashes {
+		hashes = maxHashes
+	}
+
+	bloom := &Bloom{hashes: hashes, bits: make([]uint64, words)}
+	for h := range b.grams {
+		bloom.add(h)
+	}
+	return bloom
+}
+
+// add sets the bits of a hashed n-gram, the indexes of the bits being derived from its hash by double hashing.
+func (b *Bloom) add(h uint64) {
+	m := uint64(len(b.bits) * 64)
+	h1, h2 := h&math.MaxUint32, h>>32
+	for i := uint64(0); i < uint64(b.hashes); i++ {
+		idx := (h1 + i*h2) % m
+		b.bits[idx/64] |= 1 << (idx % 64)
+	}
+}
+
+func (b *Bloom) test(h uint64) bool {
+	m := uint64(len(b.bits) * 64)
+	h1, h2 := h&math.MaxUint32, h>>32
+	for i := uint64(0); i < uint64(b.hashes); i++ {
+		idx := (h1 + i*h2) % m
+		if b.bits[idx/64]&(1<<(idx%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// MayContain returns whether the lines of the chunk may contain the needles, false meaning they surely don't.
+func (b *Bloom) MayContain(needles Needles) bool {
+	for _, h := range needles {
+		if !b.test(h) {
+			return false
+		}
+	}
+	return true
+}
+
+// Needles are the hashed n-grams of the strings the lines selected by a query contain, tested against the blooms.
+type Needles []uint64
+
+// NewNeedles returns the needles of the strings, nil when none of them is long enough to be tested.
+func NewNeedles(strs []string) Needles {
+	var needles Needles
+	seen := map[uint64]struct{}{}
+	for _, s := range strs {
+		for i := 0; i+NGramLength <= len(s); i++ {
+			h := xxhash.Sum64String(s[i : i+NGramLength])
+			if _, ok := seen[h]; ok {
+				continue
+			}
+			seen[h] = struct{}{}
+			needles = append(needles, h)
+		}
+	}
+	return needles
+}
+
+func (b *Bloom) appendBinary(buf []byte) []byte {
+	buf = appendUvarint(buf, uint64(b.hashes))
+	buf = appendUvarint(buf, uint64(len(b.bits)))
+	var word [8]byte
+	for _, w := range b.bits {
+		binary.LittleEndian.PutUint64(word[:], w)
+		buf = append(buf, word[:]...)
+	}
+	return buf
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	return append(buf, tmp[:binary.PutUvarint(tmp[:], v)]...)
+}
+
+var errMalformedBloom = errors.New("malformed bloom")
+
+func decodeBloom(buf []byte) (*Bloom, []byte, error) {
+	hashes, n := binary.Uvarint(buf)
+	if n <= 0 || hashes == 0 || hashes > maxHashes {
+		return nil, nil, errMalformedBloom
+	}
+	buf = buf[n:]
+	words, n := binary.Uvarint(buf)
+	if n <= 0 || words == 0 || words > uint64(len(buf[n:])/8) {
+		return nil, nil, errMalformedBloom
+	}
+	buf = buf[n:]
+
+	b := &Bloom{hashes: uint32(hashes), bits: make([]uint64, words)}
+	for i := range b.bits {
+		b.bits[i] = binary.LittleEndian.Uint64(buf[i*8:])
+	}
+	return b, buf[words*8:], nil
+}
@@ -0,0 +1,71 @@
+package bloom
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBloom_MayContain(t *testing.T) {
+	builder := NewBuilder()
+	for i := 0; i < 1000; i++ {
+		builder.AddLine(fmt.Sprintf("level=info msg=\"request served\" path=/api/v1/users/%d status=200", i))
+	}
+	builder.AddLine("level=error msg=\"connection refused\"")
+	b := builder.Build(64 << 10)
+
+	// no false negative.
+	for _, s := range []string{"request served", "users/42", "connection refused", "level=error"} {
+		require.True(t, b.MayContain(NewNeedles([]string{s})), s)
+	}
+	require.True(t, b.MayContain(NewNeedles([]string{"status=200", "level=info"})))
+
+	// the needles of all the strings must be contained.
+	require.False(t, b.MayContain(NewNeedles([]string{"request served", "timeout exceeded"})))
+	require.False(t, b.MayContain(NewNeedles([]string{"panic: runtime error"})))
+
+	// nothing to test.
+	require.True(t, b.MayContain(nil))
+}
+
+func TestBloom_MaxSize(t *testing.T) {
+	builder := NewBuilder()
+	for i := 0; i < 10000; i++ {
+		builder.AddLine(fmt.Sprintf("trace_id=%016x", i*7919))
+	}
+	b := builder.Build(128)
+	require.Len(t, b.bits, 16)
+	require.True(t, b.MayContain(NewNeedles([]string{fmt.Sprintf("trace_id=%016x", 42*7919)})))
+
+	// an empty chunk has the smallest bloom.
+	b = NewBuilder().Build(128)
+	require.Len(t, b.bits, 1)
+	require.False(t, b.MayContain(NewNeedles([]string{"anything"})))
+}
+
+func TestNewNeedles(t *testing.T) {
+	require.Nil(t, NewNeedles(nil))
+	require.Nil(t, NewNeedles([]string{"foo", ""}))
+	require.Len(t, NewNeedles([]string{"fooo"}), 1)
+	require.Len(t, NewNeedles([]string{"foobar"}), 3)
+	// the n-grams are deduplicated across the strings.
+	require.Len(t, NewNeedles([]string{"foobar", "obar", "aaaaaa"}), 4)
+}
+
+func TestBloom_Encoding(t *testing.T) {
+	builder := NewBuilder()
+	builder.AddLine("hello world")
+	b := builder.Build(1024)
+
+	buf := b.appendBinary([]byte("prefix"))
+	decoded, rest, err := decodeBloom(append(buf[len("prefix"):], "rest"...))
+	require.NoError(t, err)
+	require.Equal(t, b, decoded)
+	require.Equal(t, "rest", string(rest))
+
+	_, _, err = decodeBloom(buf[len("prefix") : len(buf)-1])
+	require.Equal(t, errMalformedBloom, err)
+	_, _, err = decodeBloom(nil)
+	require.Equal(t, errMalformedBloom, err)
+}
@@ -0,0 +1,121 @@
+package bloom
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/services"
+	"go.uber.org/atomic"
+)
+
+type loadedBlooms struct {
+	modifiedAt time.Time
+	blooms     map[string]*Bloom
+	// used tells whether the blooms were queried since the previous refresh.
+	used atomic.Bool
+}
+
+// Filter tells the chunks which can't contain the needles of a query from their blooms. The objects of the blooms are
+// listed periodically, and the blooms of a tenant of a table are loaded when first queried.
+type Filter struct {
+	services.Service
+
+	store  *Store
+	logger log.Logger
+
+	mtx     sync.RWMutex
+	objects map[string]time.Time
+	loaded  map[string]*loadedBlooms
+}
+
+// NewFilter makes a filter of the chunks from the blooms of the store.
+func NewFilter(store *Store, refreshInterval time.Duration, logger log.Logger) *Filter {
+	f := &Filter{
+		store:   store,
+		logger:  logger,
+		objects: map[string]time.Time{},
+		loaded:  map[string]*loadedBlooms{},
+	}
+	f.Service = services.NewTimerService(refreshInterval, f.iteration, f.iteration, nil).WithName("chunk blooms")
+	return f
+}
+
+func (f *Filter) iteration(ctx context.Context) error {
+	if err := f.refresh(ctx); err != nil {
+		level.Error(f.logger).Log("msg", "failed to list the chunk blooms", "err", err)
+	}
+	// don't return the error, otherwise the timer service would stop.
+	return nil
+}
+
+// refresh lists the objects of the blooms, and unloads the blooms modified, removed or not queried since the previous
+// refresh.
+func (f *Filter) refresh(ctx context.Context) error {
+	list, err := f.store.list(ctx)
+	if err != nil {
+		return err
+	}
+	objects := make(map[string]time.Time, len(list))
+	for _, o := range list {
+		objects[o.Key] = o.ModifiedAt
+	}
+
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	for key, loaded := range f.loaded {
+		if modifiedAt, ok := objects[key]; !ok || !modifiedAt.Equal(loaded.modifiedAt) || !loaded.used.CAS(true, false) {
+			delete(f.loaded, key)
+		}
+	}
+	f.objects = objects
+	return nil
+}
+
+// MayContain returns whether the chunk of the external key, indexed in the table, may contain the needles. It does
+// when the chunk has no bloom, or its blooms fail to be loaded.
+func (f *Filter) MayContain(ctx context.Context, tableName, userID, chunkKey string, needles Needles) bool {
+	loaded := f.load(ctx, f.store.key(tableName, userID))
+	if loaded == nil {
+		return true
+	}
+	loaded.used.Store(true)
+
+	b, ok := loaded.blooms[chunkKey]
+	if !ok {
+		return true
+	}
+	return b.MayContain(needles)
+}
+
+// load returns the blooms of the object, loading them when they aren't yet. It returns nil when they aren't built.
+func (f *Filter) load(ctx context.Context, key string) *loadedBlooms {
+	f.mtx.RLock()
+	modifiedAt, ok := f.objects[key]
+	loaded := f.loaded[key]
+	f.mtx.RUnlock()
+	if !ok {
+		return nil
+	}
+	if loaded != nil && loaded.modifiedAt.Equal(modifiedAt) {
+		return loaded
+	}
+
+	blooms, err := f.store.get(ctx, key)
+	if err != nil {
+		level.Warn(f.logger).Log("msg", "failed to load the chunk blooms", "key", key, "err", err)
+		return nil
+	}
+	loaded = &loadedBlooms{modifiedAt: modifiedAt, blooms: blooms}
+
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	if current, ok := f.loaded[key]; ok && current.modifiedAt.Equal(modifiedAt) {
+		// loaded concurrently by another query.
+		return current
+	}
+	f.loaded[key] = loaded
+	return loaded
+}
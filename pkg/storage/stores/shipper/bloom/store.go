@@ -0,0 +1,169 @@
+package bloom
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grafana/loki/pkg/storage/chunk"
+	shipper_util "github.com/grafana/loki/pkg/storage/stores/shipper/util"
+)
+
+const (
+	bloomsSuffix = ".bloom"
+	magic        = "LBF1"
+)
+
+// Config configures the blooms of the chunks built by the compactor, the queries with line filters skipping the
+// chunks which can't match them.
+type Config struct {
+	Enabled         bool          `yaml:"enabled"`
+	KeyPrefix       string        `yaml:"key_prefix"`
+	RefreshInterval time.Duration `yaml:"refresh_interval"`
+}
+
+// RegisterFlags registers flags.
+func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, "store.chunk-blooms.enabled", false, "(Experimental) Skip the chunks which can't match the |= line filters of the queries, from the blooms of the n-grams of their lines built by the bloom builder of the compactor.")
+	f.StringVar(&cfg.KeyPrefix, "store.chunk-blooms.key-prefix", "blooms/", "Prefix of the objects of the shared store of boltdb-shipper holding the blooms of the chunks of the index tables. It must not be the prefix of the index.")
+	f.DurationVar(&cfg.RefreshInterval, "store.chunk-blooms.refresh-interval", 5*time.Minute, "Interval at which the queriers list the blooms built, the blooms of the tables not queried since the previous listing being unloaded.")
+}
+
+// Validate validates the config.
+func (cfg *Config) Validate() error {
+	if cfg.Enabled && cfg.RefreshInterval <= 0 {
+		return errors.New("the refresh interval of the chunk blooms must be positive")
+	}
+	return shipper_util.ValidateSharedStoreKeyPrefix(cfg.KeyPrefix)
+}
+
+// Store stores the blooms of the chunks of the index tables in an object store, an object per table and tenant.
+type Store struct {
+	objectClient chunk.ObjectClient
+	keyPrefix    string
+}
+
+// NewStore makes a store of the blooms under the key prefix of the object store.
+func NewStore(objectClient chunk.ObjectClient, keyPrefix string) *Store {
+	return &Store{objectClient: objectClient, keyPrefix: keyPrefix}
+}
+
+func (s *Store) key(tableName, userID string) string {
+	return s.keyPrefix + tableName + "/" + userID + bloomsSuffix
+}
+
+// Put stores the blooms of the chunks of a tenant of a table, by external key of the chunks.
+func (s *Store) Put(ctx context.Context, tableName, userID string, blooms map[string]*Bloom) error {
+	keys := make([]string, 0, len(blooms))
+	for k := range blooms {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	buf := append([]byte(magic), NGramLength)
+	buf = appendUvarint(buf, uint64(len(keys)))
+	for _, k := range keys {
+		buf = appendUvarint(buf, uint64(len(k)))
+		buf = append(buf, k...)
+		buf = blooms[k].appendBinary(buf)
+	}
+	return s.objectClient.PutObject(ctx, s.key(tableName, userID), bytes.NewReader(buf))
+}
+
+// Get returns the blooms of the chunks of a tenant of a table, nil when they aren't built.
+func (s *Store) Get(ctx context.Context, tableName, userID string) (map[string]*Bloom, error) {
+	return s.get(ctx, s.key(tableName, userID))
+}
+
+func (s *Store) get(ctx context.Context, key string) (map[string]*Bloom, error) {
+	rc, _, err := s.objectClient.GetObject(ctx, key)
+	if err != nil {
+		if s.objectClient.IsObjectNotFoundErr(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer rc.Close()
+
+	buf, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+	blooms, err := decodeBlooms(buf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode the blooms %s: %w", key, err)
+	}
+	return blooms, nil
+}
+
+func decodeBlooms(buf []byte) (map[string]*Bloom, error) {
+	if len(buf) < len(magic)+1 || string(buf[:len(magic)]) != magic {
+		return nil, errors.New("invalid header")
+	}
+	if buf[len(magic)] != NGramLength {
+		return nil, fmt.Errorf("unsupported n-gram length %d", buf[len(magic)])
+	}
+	buf = buf[len(magic)+1:]
+
+	count, n := binary.Uvarint(buf)
+	if n <= 0 {
+		return nil, errMalformedBloom
+	}
+	buf = buf[n:]
+
+	blooms := make(map[string]*Bloom, count)
+	for i := uint64(0); i < count; i++ {
+		l, n := binary.Uvarint(buf)
+		if n <= 0 || l > uint64(len(buf[n:])) {
+			return nil, errMalformedBloom
+		}
+		key := string(buf[n : n+int(l)])
+		buf = buf[n+int(l):]
+
+		b, rest, err := decodeBloom(buf)
+		if err != nil {
+			return nil, err
+		}
+		blooms[key] = b
+		buf = rest
+	}
+	return blooms, nil
+}
+
+// Tables returns the tables of which blooms are built.
+func (s *Store) Tables(ctx context.Context) (map[string]struct{}, error) {
+	objects, err := s.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tables := map[string]struct{}{}
+	for _, o := range objects {
+		rel := strings.TrimPrefix(o.Key, s.keyPrefix)
+		if i := strings.Index(rel, "/"); i > 0 {
+			tables[rel[:i]] = struct{}{}
+		}
+	}
+	return tables, nil
+}
+
+// list returns the objects holding the blooms of the tenants of the tables.
+func (s *Store) list(ctx context.Context) ([]chunk.StorageObject, error) {
+	objects, _, err := s.objectClient.List(ctx, s.keyPrefix, "")
+	if err != nil {
+		return nil, err
+	}
+	filtered := objects[:0]
+	for _, o := range objects {
+		if strings.HasSuffix(o.Key, bloomsSuffix) {
+			filtered = append(filtered, o)
+		}
+	}
+	return filtered, nil
+}
@@ -0,0 +1,111 @@
+package bloom
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/pkg/storage/chunk/local"
+)
+
+func newTestStore(t *testing.T) (*Store, *local.FSObjectClient) {
+	objectClient, err := local.NewFSObjectClient(local.FSConfig{Directory: t.TempDir()})
+	require.NoError(t, err)
+	return NewStore(objectClient, "blooms/"), objectClient
+}
+
+func buildBloom(lines ...string) *Bloom {
+	builder := NewBuilder()
+	for _, l := range lines {
+		builder.AddLine(l)
+	}
+	return builder.Build(1024)
+}
+
+func TestStore(t *testing.T) {
+	store, objectClient := newTestStore(t)
+	ctx := context.Background()
+
+	blooms, err := store.Get(ctx, "index_19000", "fake")
+	require.NoError(t, err)
+	require.Nil(t, blooms)
+
+	expected := map[string]*Bloom{
+		"fake/1:2:3:4": buildBloom("level=error msg=timeout"),
+		"fake/5:6:7:8": buildBloom("level=info msg=done"),
+	}
+	require.NoError(t, store.Put(ctx, "index_19000", "fake", expected))
+	require.NoError(t, store.Put(ctx, "index_19001", "user1", map[string]*Bloom{}))
+	// the other objects under the prefix are ignored.
+	require.NoError(t, objectClient.PutObject(ctx, "blooms/README", bytes.NewReader([]byte("chunk blooms"))))
+
+	blooms, err = store.Get(ctx, "index_19000", "fake")
+	require.NoError(t, err)
+	require.Equal(t, expected, blooms)
+
+	blooms, err = store.Get(ctx, "index_19001", "user1")
+	require.NoError(t, err)
+	require.Empty(t, blooms)
+
+	tables, err := store.Tables(ctx)
+	require.NoError(t, err)
+	require.Equal(t, map[string]struct{}{"index_19000": {}, "index_19001": {}}, tables)
+
+	require.NoError(t, objectClient.PutObject(ctx, "blooms/index_19002/fake.bloom", bytes.NewReader([]byte("LBF1\x03"))))
+	_, err = store.Get(ctx, "index_19002", "fake")
+	require.Error(t, err)
+}
+
+func TestFilter(t *testing.T) {
+	store, objectClient := newTestStore(t)
+	ctx := context.Background()
+	filter := NewFilter(store, time.Minute, log.NewNopLogger())
+	needles := NewNeedles([]string{"timeout"})
+
+	require.NoError(t, filter.refresh(ctx))
+	require.True(t, filter.MayContain(ctx, "index_19000", "fake", "fake/1:2:3:4", needles))
+
+	require.NoError(t, store.Put(ctx, "index_19000", "fake", map[string]*Bloom{
+		"fake/1:2:3:4": buildBloom("level=error msg=timeout"),
+		"fake/5:6:7:8": buildBloom("level=info msg=done"),
+	}))
+	require.NoError(t, filter.refresh(ctx))
+	require.True(t, filter.MayContain(ctx, "index_19000", "fake", "fake/1:2:3:4", needles))
+	require.False(t, filter.MayContain(ctx, "index_19000", "fake", "fake/5:6:7:8", needles))
+	// the chunks without bloom, and the tenants and tables without blooms, may contain anything.
+	require.True(t, filter.MayContain(ctx, "index_19000", "fake", "fake/9:a:b:c", needles))
+	require.True(t, filter.MayContain(ctx, "index_19000", "user1", "user1/1:2:3:4", needles))
+	require.True(t, filter.MayContain(ctx, "index_19001", "fake", "fake/1:2:3:4", needles))
+	require.Len(t, filter.loaded, 1)
+
+	// the blooms queried since the previous refresh are kept, the others unloaded.
+	require.NoError(t, filter.refresh(ctx))
+	require.Len(t, filter.loaded, 1)
+	require.NoError(t, filter.refresh(ctx))
+	require.Len(t, filter.loaded, 0)
+
+	// the blooms of the tables removed, like by the retention, are dropped.
+	require.False(t, filter.MayContain(ctx, "index_19000", "fake", "fake/5:6:7:8", needles))
+	require.NoError(t, objectClient.DeleteObject(ctx, "blooms/index_19000/fake.bloom"))
+	require.NoError(t, filter.refresh(ctx))
+	require.Len(t, filter.loaded, 0)
+	require.True(t, filter.MayContain(ctx, "index_19000", "fake", "fake/5:6:7:8", needles))
+}
+
+func TestConfig_Validate(t *testing.T) {
+	cfg := Config{KeyPrefix: "blooms/"}
+	require.NoError(t, cfg.Validate())
+
+	cfg.Enabled = true
+	require.Error(t, cfg.Validate())
+
+	cfg.RefreshInterval = time.Minute
+	require.NoError(t, cfg.Validate())
+
+	cfg.KeyPrefix = "blooms"
+	require.Error(t, cfg.Validate())
+}
@@ -0,0 +1,214 @@
+package compactor
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
+
+	"github.com/grafana/loki/pkg/chunkenc"
+	"github.com/grafana/loki/pkg/logproto"
+	logql_log "github.com/grafana/loki/pkg/logql/log"
+	loki_storage "github.com/grafana/loki/pkg/storage"
+	"github.com/grafana/loki/pkg/storage/chunk"
+	chunk_util "github.com/grafana/loki/pkg/storage/chunk/util"
+	"github.com/grafana/loki/pkg/storage/stores/shipper/bloom"
+	"github.com/grafana/loki/pkg/storage/stores/shipper/compactor/retention"
+	shipper_storage "github.com/grafana/loki/pkg/storage/stores/shipper/storage"
+	util_log "github.com/grafana/loki/pkg/util/log"
+)
+
+const (
+	bloomStatusBuilt   = "built"
+	bloomStatusFailure = "failure"
+)
+
+// bloomBuilder builds the blooms of the chunks of a compacted table at every run, the most recent table without
+// blooms first. The queries skip the chunks of which the blooms tell they can't match their line filters.
+type bloomBuilder struct {
+	workingDir         string
+	maxBloomSize       int
+	schemaConfig       loki_storage.SchemaConfig
+	indexStorageClient shipper_storage.Client
+	chunkClient        chunk.Client
+	store              *bloom.Store
+	limiter            *rate.Limiter
+	metrics            *metrics
+	logger             log.Logger
+	now                func() time.Time
+	// ownsTable filters the tables of which blooms are built, all of them when nil.
+	ownsTable func(tableName string) bool
+
+	// built are the tables built by the compactor, some of them without any chunk of which blooms would be stored.
+	built map[string]struct{}
+}
+
+func newBloomBuilder(cfg Config, schemaConfig loki_storage.SchemaConfig, indexStorageClient shipper_storage.Client,
+	chunkClient chunk.Client, store *bloom.Store, metrics *metrics) (*bloomBuilder, error) {
+	workingDir := filepath.Join(cfg.WorkingDirectory, "blooms")
+	if err := chunk_util.EnsureDirectory(workingDir); err != nil {
+		return nil, err
+	}
+	return &bloomBuilder{
+		workingDir:         workingDir,
+		maxBloomSize:       cfg.BloomBuilderMaxBloomSize,
+		schemaConfig:       schemaConfig,
+		indexStorageClient: indexStorageClient,
+		chunkClient:        chunkClient,
+		store:              store,
+		limiter:            rate.NewLimiter(rate.Limit(cfg.BloomBuilderRateLimit), 1),
+		metrics:            metrics,
+		logger:             log.With(util_log.Logger, "component", "bloom-builder"),
+		now:                time.Now,
+		built:              map[string]struct{}{},
+	}, nil
+}
+
+// run builds the blooms of a table at every interval until the context is done.
+func (b *bloomBuilder) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := b.buildNextTable(ctx); err != nil && ctx.Err() == nil {
+				level.Error(b.logger).Log("msg", "failed to build the chunk blooms", "err", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// buildNextTable builds the blooms of the most recent table not written anymore without blooms. It returns the name
+// of the table, empty when all the tables have blooms.
+func (b *bloomBuilder) buildNextTable(ctx context.Context) (string, error) {
+	tables, err := b.indexStorageClient.ListTables(ctx)
+	if err != nil {
+		return "", err
+	}
+	withBlooms, err := b.store.Tables(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	maxEnd := b.now().Add(-scrubMinTableAge)
+	eligible := tables[:0]
+	for _, table := range tables {
+		if retention.ExtractIntervalFromTableName(table).End.Time().After(maxEnd) {
+			continue
+		}
+		if _, ok := retention.SchemaPeriodForTable(b.schemaConfig, table); !ok {
+			continue
+		}
+		if b.ownsTable != nil && !b.ownsTable(table) {
+			continue
+		}
+		if _, ok := withBlooms[table]; ok {
+			continue
+		}
+		if _, ok := b.built[table]; ok {
+			continue
+		}
+		eligible = append(eligible, table)
+	}
+	if len(eligible) == 0 {
+		return "", nil
+	}
+	sort.Strings(eligible)
+
+	next := eligible[len(eligible)-1]
+	return next, b.buildTable(ctx, next)
+}
+
+// buildTable builds and stores the blooms of the chunks of the table, by tenant. The chunks failing to be fetched
+// have no bloom, they are never skipped by the queries.
+func (b *bloomBuilder) buildTable(ctx context.Context, tableName string) error {
+	start := b.now()
+	logger := log.With(b.logger, "table-name", tableName)
+
+	chunks := map[string]map[string]struct{}{}
+	if err := forEachTableChunk(ctx, b.workingDir, b.indexStorageClient, b.schemaConfig, tableName, b.logger, func(entry retention.ChunkEntry) {
+		// the entries point to the memory of the db, which is closed after the iteration.
+		userID := string(entry.UserID)
+		if chunks[userID] == nil {
+			chunks[userID] = map[string]struct{}{}
+		}
+		chunks[userID][string(entry.ChunkID)] = struct{}{}
+	}); err != nil {
+		return errors.Wrap(err, "failed to list the chunks of the table")
+	}
+
+	built, failed := 0, 0
+	for userID, chunkIDs := range chunks {
+		blooms := make(map[string]*bloom.Bloom, len(chunkIDs))
+		for chunkID := range chunkIDs {
+			if err := b.limiter.Wait(ctx); err != nil {
+				return err
+			}
+			bl, err := b.buildChunk(ctx, userID, chunkID)
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				failed++
+				b.metrics.bloomBuiltChunksTotal.WithLabelValues(bloomStatusFailure).Inc()
+				level.Warn(logger).Log("msg", "failed to build the bloom of chunk", "chunk", chunkID, "err", err)
+				continue
+			}
+			built++
+			b.metrics.bloomBuiltChunksTotal.WithLabelValues(bloomStatusBuilt).Inc()
+			blooms[chunkID] = bl
+		}
+		if err := b.store.Put(ctx, tableName, userID, blooms); err != nil {
+			return errors.Wrap(err, "failed to store the chunk blooms")
+		}
+	}
+
+	b.built[tableName] = struct{}{}
+	b.metrics.bloomBuildLastSuccess.SetToCurrentTime()
+	level.Info(logger).Log("msg", "built chunk blooms", "built", built, "failed", failed, "duration", time.Since(start))
+	return nil
+}
+
+// buildChunk fetches the chunk and builds the bloom of the n-grams of its lines.
+func (b *bloomBuilder) buildChunk(ctx context.Context, userID, chunkID string) (*bloom.Bloom, error) {
+	ref, err := chunk.ParseExternalKey(userID, chunkID)
+	if err != nil {
+		return nil, err
+	}
+	chks, err := b.chunkClient.GetChunks(ctx, []chunk.Chunk{ref})
+	if err != nil {
+		return nil, err
+	}
+	if len(chks) != 1 {
+		return nil, errors.New("chunk not found")
+	}
+	facade, ok := chks[0].Data.(*chunkenc.Facade)
+	if !ok {
+		return nil, errors.New("unsupported chunk encoding")
+	}
+
+	it, err := facade.LokiChunk().Iterator(ctx, time.Unix(0, 0), time.Unix(0, math.MaxInt64), logproto.FORWARD,
+		logql_log.NewNoopPipeline().ForStream(chks[0].Metric))
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	builder := bloom.NewBuilder()
+	for it.Next() {
+		builder.AddLine(it.Entry().Line)
+	}
+	if err := it.Error(); err != nil {
+		return nil, err
+	}
+	return builder.Build(b.maxBloomSize), nil
+}
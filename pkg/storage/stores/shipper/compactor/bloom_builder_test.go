@@ -0,0 +1,98 @@
+package compactor
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/pkg/storage/stores/shipper/bloom"
+	shipper_storage "github.com/grafana/loki/pkg/storage/stores/shipper/storage"
+)
+
+func (s *scrubberTestStore) newBloomBuilder() (*bloomBuilder, *bloom.Store) {
+	bloomStore := bloom.NewStore(s.objectClient, "blooms/")
+	builder, err := newBloomBuilder(Config{
+		WorkingDirectory:         filepath.Join(s.dir, "compactor"),
+		BloomBuilderRateLimit:    1000,
+		BloomBuilderMaxBloomSize: 1024,
+	}, scrubberSchemaCfg, shipper_storage.NewIndexStorageClient(s.objectClient, "index/"), s.chunkClient, bloomStore, newMetrics(nil))
+	require.NoError(s.t, err)
+	return builder, bloomStore
+}
+
+func TestBloomBuilder(t *testing.T) {
+	s := newScrubberTestStore(t)
+	ctx := context.Background()
+
+	day := time.Now().Add(-72*time.Hour).Unix() / 86400
+	tableName := fmt.Sprintf("index_%d", day)
+	from := model.TimeFromUnix(day * 86400).Add(time.Hour)
+
+	foo := s.newChunk("fake", labels.Labels{{Name: "app", Value: "foo"}}, from)
+	bar := s.newChunk("fake", labels.Labels{{Name: "app", Value: "bar"}}, from)
+	missing := s.newChunk("fake", labels.Labels{{Name: "app", Value: "missing"}}, from)
+	userChunk := s.newChunk("user1", labels.Labels{{Name: "app", Value: "user"}}, from)
+	require.NoError(t, s.chunkClient.DeleteChunk(ctx, "fake", scrubberSchemaCfg.ExternalKey(missing)))
+
+	s.writeIndex(tableName, "", "compactor-1", foo, bar, missing)
+	s.writeIndex(tableName, "user1", "compactor-1", userChunk)
+	// the current table is still written, its blooms aren't built.
+	s.writeIndex(fmt.Sprintf("index_%d", time.Now().Unix()/86400), "", "ingester-1", s.newChunk("fake", labels.Labels{{Name: "app", Value: "today"}}, model.Now()))
+
+	builder, bloomStore := s.newBloomBuilder()
+	built, err := builder.buildNextTable(ctx)
+	require.NoError(t, err)
+	require.Equal(t, tableName, built)
+	require.Equal(t, float64(3), testutil.ToFloat64(builder.metrics.bloomBuiltChunksTotal.WithLabelValues(bloomStatusBuilt)))
+	require.Equal(t, float64(1), testutil.ToFloat64(builder.metrics.bloomBuiltChunksTotal.WithLabelValues(bloomStatusFailure)))
+
+	blooms, err := bloomStore.Get(ctx, tableName, "fake")
+	require.NoError(t, err)
+	// the chunks failing to be fetched have no bloom.
+	require.Len(t, blooms, 2)
+	for _, c := range []string{scrubberSchemaCfg.ExternalKey(foo), scrubberSchemaCfg.ExternalKey(bar)} {
+		require.True(t, blooms[c].MayContain(bloom.NewNeedles([]string{"line 7"})))
+		require.False(t, blooms[c].MayContain(bloom.NewNeedles([]string{"timeout"})))
+	}
+
+	blooms, err = bloomStore.Get(ctx, tableName, "user1")
+	require.NoError(t, err)
+	require.Len(t, blooms, 1)
+
+	// the tables with blooms aren't built again.
+	built, err = builder.buildNextTable(ctx)
+	require.NoError(t, err)
+	require.Empty(t, built)
+	builder, _ = s.newBloomBuilder()
+	built, err = builder.buildNextTable(ctx)
+	require.NoError(t, err)
+	require.Empty(t, built)
+}
+
+func TestBloomBuilder_NextTable(t *testing.T) {
+	s := newScrubberTestStore(t)
+	ctx := context.Background()
+
+	day := time.Now().Add(-72*time.Hour).Unix() / 86400
+	for i := int64(0); i < 3; i++ {
+		s.writeIndex(fmt.Sprintf("index_%d", day-i), "", "compactor-1", s.newChunk("fake", labels.Labels{{Name: "app", Value: "foo"}}, model.TimeFromUnix((day-i)*86400)))
+	}
+
+	// the most recent tables are built first.
+	builder, _ := s.newBloomBuilder()
+	for i := int64(0); i < 3; i++ {
+		built, err := builder.buildNextTable(ctx)
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprintf("index_%d", day-i), built)
+	}
+	built, err := builder.buildNextTable(ctx)
+	require.NoError(t, err)
+	require.Empty(t, built)
+}
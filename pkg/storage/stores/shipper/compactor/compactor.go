@@ -9,6 +9,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/cespare/xxhash/v2"
 	"github.com/go-kit/log/level"
 	"github.com/grafana/dskit/kv"
 	"github.com/grafana/dskit/ring"
@@ -17,13 +18,16 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/model"
 
+	"github.com/grafana/loki/pkg/notifications"
 	loki_storage "github.com/grafana/loki/pkg/storage"
-	"github.com/grafana/loki/pkg/storage/chunk/local"
+	"github.com/grafana/loki/pkg/storage/chunk"
 	"github.com/grafana/loki/pkg/storage/chunk/objectclient"
 	"github.com/grafana/loki/pkg/storage/chunk/storage"
 	chunk_util "github.com/grafana/loki/pkg/storage/chunk/util"
+	"github.com/grafana/loki/pkg/storage/stores/shipper/bloom"
 	"github.com/grafana/loki/pkg/storage/stores/shipper/compactor/deletion"
 	"github.com/grafana/loki/pkg/storage/stores/shipper/compactor/retention"
+	"github.com/grafana/loki/pkg/storage/stores/shipper/quarantine"
 	shipper_storage "github.com/grafana/loki/pkg/storage/stores/shipper/storage"
 	shipper_util "github.com/grafana/loki/pkg/storage/stores/shipper/util"
 	"github.com/grafana/loki/pkg/usagestats"
@@ -51,6 +55,13 @@ const (
 	// ringNumTokens sets our single token in the ring,
 	// we only need to insert 1 token to be used for leader election purposes.
 	ringNumTokens = 1
+
+	// ringNumTokensSharding is the number of tokens of the compactors sharding the tables, for the tables
+	// to be evenly spread across the compactors of the ring.
+	ringNumTokensSharding = 128
+
+	// schemaPeriodsCheckInterval is the interval at which the compactor checks for period configs starting.
+	schemaPeriodsCheckInterval = time.Minute
 )
 
 var (
@@ -59,17 +70,42 @@ var (
 )
 
 type Config struct {
-	WorkingDirectory          string          `yaml:"working_directory"`
-	SharedStoreType           string          `yaml:"shared_store"`
-	SharedStoreKeyPrefix      string          `yaml:"shared_store_key_prefix"`
-	CompactionInterval        time.Duration   `yaml:"compaction_interval"`
-	ApplyRetentionInterval    time.Duration   `yaml:"apply_retention_interval"`
-	RetentionEnabled          bool            `yaml:"retention_enabled"`
-	RetentionDeleteDelay      time.Duration   `yaml:"retention_delete_delay"`
-	RetentionDeleteWorkCount  int             `yaml:"retention_delete_worker_count"`
-	DeleteRequestCancelPeriod time.Duration   `yaml:"delete_request_cancel_period"`
-	MaxCompactionParallelism  int             `yaml:"max_compaction_parallelism"`
-	CompactorRing             util.RingConfig `yaml:"compactor_ring,omitempty"`
+	WorkingDirectory              string          `yaml:"working_directory"`
+	SharedStoreType               string          `yaml:"shared_store"`
+	SharedStoreKeyPrefix          string          `yaml:"shared_store_key_prefix"`
+	IndexCompression              string          `yaml:"index_compression"`
+	CompactionInterval            time.Duration   `yaml:"compaction_interval"`
+	ApplyRetentionInterval        time.Duration   `yaml:"apply_retention_interval"`
+	RetentionEnabled              bool            `yaml:"retention_enabled"`
+	RetentionDeleteDelay          time.Duration   `yaml:"retention_delete_delay"`
+	RetentionDeleteWorkCount      int             `yaml:"retention_delete_worker_count"`
+	DeleteRequestCancelPeriod     time.Duration   `yaml:"delete_request_cancel_period"`
+	MaxCompactionParallelism      int             `yaml:"max_compaction_parallelism"`
+	ChunkPackingMaxChunkSize      int             `yaml:"chunk_packing_max_chunk_size"`
+	ChunkPackingMaxSize           int             `yaml:"chunk_packing_max_container_size"`
+	ChunkPackingMinAge            time.Duration   `yaml:"chunk_packing_min_age"`
+	ChunkScrubberEnabled          bool            `yaml:"chunk_scrubber_enabled"`
+	ChunkScrubberInterval         time.Duration   `yaml:"chunk_scrubber_interval"`
+	ChunkScrubberSampleSize       int             `yaml:"chunk_scrubber_sample_size"`
+	ChunkScrubberRateLimit        float64         `yaml:"chunk_scrubber_rate_limit"`
+	BloomBuilderEnabled           bool            `yaml:"bloom_builder_enabled"`
+	BloomBuilderInterval          time.Duration   `yaml:"bloom_builder_interval"`
+	BloomBuilderRateLimit         float64         `yaml:"bloom_builder_rate_limit"`
+	BloomBuilderMaxBloomSize      int             `yaml:"bloom_builder_max_bloom_size"`
+	OrphanScrubberEnabled         bool            `yaml:"orphan_scrubber_enabled"`
+	OrphanScrubberInterval        time.Duration   `yaml:"orphan_scrubber_interval"`
+	OrphanScrubberMinAge          time.Duration   `yaml:"orphan_scrubber_min_age"`
+	OrphanScrubberDelete          bool            `yaml:"orphan_scrubber_delete"`
+	OrphanScrubberDeleteRateLimit float64         `yaml:"orphan_scrubber_delete_rate_limit"`
+	CompactorRing                 util.RingConfig `yaml:"compactor_ring,omitempty"`
+	ShardingEnabled               bool            `yaml:"sharding_enabled"`
+	ShardingTableLockPrefix       string          `yaml:"sharding_table_lock_key_prefix"`
+	ShardingTableLockTTL          time.Duration   `yaml:"sharding_table_lock_ttl"`
+
+	// ChunkQuarantine is the quarantine of the storage config, the scrubber quarantines the corrupt chunks in.
+	ChunkQuarantine quarantine.Config `yaml:"-"`
+	// ChunkBlooms are the blooms of the storage config, the bloom builder stores the blooms of the chunks in.
+	ChunkBlooms bloom.Config `yaml:"-"`
 }
 
 // RegisterFlags registers flags.
@@ -77,6 +113,7 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.StringVar(&cfg.WorkingDirectory, "boltdb.shipper.compactor.working-directory", "", "Directory where files can be downloaded for compaction.")
 	f.StringVar(&cfg.SharedStoreType, "boltdb.shipper.compactor.shared-store", "", "Shared store used for storing boltdb files. Supported types: gcs, s3, azure, swift, filesystem")
 	f.StringVar(&cfg.SharedStoreKeyPrefix, "boltdb.shipper.compactor.shared-store.key-prefix", "index/", "Prefix to add to Object Keys in Shared store. Path separator(if any) should always be a '/'. Prefix should never start with a separator but should always end with it.")
+	f.StringVar(&cfg.IndexCompression, "boltdb.shipper.compactor.index-compression", shipper_util.CompressionGzip, "Compression of the compacted index files uploaded to the shared store. Supported values: gzip, zstd.")
 	f.DurationVar(&cfg.CompactionInterval, "boltdb.shipper.compactor.compaction-interval", 10*time.Minute, "Interval at which to re-run the compaction operation.")
 	f.DurationVar(&cfg.ApplyRetentionInterval, "boltdb.shipper.compactor.apply-retention-interval", 0, "Interval at which to apply/enforce retention. 0 means run at same interval as compaction. If non-zero, it should always be a multiple of compaction interval.")
 	f.DurationVar(&cfg.RetentionDeleteDelay, "boltdb.shipper.compactor.retention-delete-delay", 2*time.Hour, "Delay after which chunks will be fully deleted during retention.")
@@ -84,7 +121,26 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&cfg.RetentionDeleteWorkCount, "boltdb.shipper.compactor.retention-delete-worker-count", 150, "The total amount of worker to use to delete chunks.")
 	f.DurationVar(&cfg.DeleteRequestCancelPeriod, "boltdb.shipper.compactor.delete-request-cancel-period", 24*time.Hour, "Allow cancellation of delete request until duration after they are created. Data would be deleted only after delete requests have been older than this duration. Ideally this should be set to at least 24h.")
 	f.IntVar(&cfg.MaxCompactionParallelism, "boltdb.shipper.compactor.max-compaction-parallelism", 1, "Maximum number of tables to compact in parallel. While increasing this value, please make sure compactor has enough disk space allocated to be able to store and compact as many tables.")
+	f.IntVar(&cfg.ChunkPackingMaxChunkSize, "boltdb.shipper.compactor.chunk-packing-max-chunk-size", 0, "(Experimental) Maximum size in bytes of the chunks packed in containers while applying retention, to reduce the number of objects of historical tables. 0 disables the packing. Requires retention to be enabled.")
+	f.IntVar(&cfg.ChunkPackingMaxSize, "boltdb.shipper.compactor.chunk-packing-max-container-size", 4<<20, "Maximum size in bytes of the containers the chunks are packed in.")
+	f.DurationVar(&cfg.ChunkPackingMinAge, "boltdb.shipper.compactor.chunk-packing-min-age", 24*time.Hour, "Minimum age of the end of the chunks packed in containers.")
+	f.BoolVar(&cfg.ChunkScrubberEnabled, "boltdb.shipper.compactor.chunk-scrubber-enabled", false, "(Experimental) Verify in the background a sample of the chunks of the compacted tables, their checksum and the decoding of their entries, and quarantine the corrupt ones. See the chunk quarantine of the storage config.")
+	f.DurationVar(&cfg.ChunkScrubberInterval, "boltdb.shipper.compactor.chunk-scrubber-interval", time.Hour, "Interval at which the chunk scrubber verifies the chunks of the next compacted table.")
+	f.IntVar(&cfg.ChunkScrubberSampleSize, "boltdb.shipper.compactor.chunk-scrubber-sample-size", 100, "Number of chunks of a table verified by the chunk scrubber at each run.")
+	f.Float64Var(&cfg.ChunkScrubberRateLimit, "boltdb.shipper.compactor.chunk-scrubber-rate-limit", 1, "Maximum number of chunks fetched per second by the chunk scrubber, to keep its load on the object store low.")
+	f.BoolVar(&cfg.BloomBuilderEnabled, "boltdb.shipper.compactor.bloom-builder-enabled", false, "(Experimental) Build in the background the blooms of the n-grams of the lines of the chunks of the compacted tables, for the queries to skip the chunks which can't match their line filters. See the chunk blooms of the storage config.")
+	f.DurationVar(&cfg.BloomBuilderInterval, "boltdb.shipper.compactor.bloom-builder-interval", 10*time.Minute, "Interval at which the bloom builder builds the blooms of the next compacted table without blooms.")
+	f.Float64Var(&cfg.BloomBuilderRateLimit, "boltdb.shipper.compactor.bloom-builder-rate-limit", 50, "Maximum number of chunks fetched per second by the bloom builder.")
+	f.IntVar(&cfg.BloomBuilderMaxBloomSize, "boltdb.shipper.compactor.bloom-builder-max-bloom-size", 64<<10, "Maximum size in bytes of the bloom of a chunk, the blooms of the chunks with many distinct n-grams having more false positives.")
+	f.BoolVar(&cfg.OrphanScrubberEnabled, "boltdb.shipper.compactor.orphan-scrubber-enabled", false, "(Experimental) Cross-check in the background the chunks of the object store with the index of the compacted tables, and report the chunks no index entry references, e.g. left behind by crashed flushes. Only the tables of schema v12 and later are scrubbed.")
+	f.DurationVar(&cfg.OrphanScrubberInterval, "boltdb.shipper.compactor.orphan-scrubber-interval", time.Hour, "Interval at which the orphan scrubber cross-checks the chunks of the next compacted table.")
+	f.DurationVar(&cfg.OrphanScrubberMinAge, "boltdb.shipper.compactor.orphan-scrubber-min-age", 24*time.Hour, "Minimum age of the chunk objects reported as orphaned, for the chunks of the flushes in flight not to be.")
+	f.BoolVar(&cfg.OrphanScrubberDelete, "boltdb.shipper.compactor.orphan-scrubber-delete", false, "Delete the orphaned chunks found by the orphan scrubber, rather than only reporting them.")
+	f.Float64Var(&cfg.OrphanScrubberDeleteRateLimit, "boltdb.shipper.compactor.orphan-scrubber-delete-rate-limit", 10, "Maximum number of orphaned chunks deleted per second by the orphan scrubber.")
 	cfg.CompactorRing.RegisterFlagsWithPrefix("boltdb.shipper.compactor.", "collectors/", f)
+	f.BoolVar(&cfg.ShardingEnabled, "boltdb.shipper.compactor.sharding-enabled", false, "(Experimental) Shard the tables across all the compactors of the ring instead of running a single compactor, each compactor compacting and applying retention to the tables it owns. The delete requests aren't supported when sharding the tables.")
+	f.StringVar(&cfg.ShardingTableLockPrefix, "boltdb.shipper.compactor.sharding-table-lock-key-prefix", "compactor-locks/", "Prefix of the objects of the shared store locking the tables compacted by the sharded compactors. It must not be the prefix of the index.")
+	f.DurationVar(&cfg.ShardingTableLockTTL, "boltdb.shipper.compactor.sharding-table-lock-ttl", time.Hour, "Duration after which the lock of a table held by a sharded compactor expires, it must be longer than the compaction of a table.")
 }
 
 // Validate verifies the config does not contain inappropriate values
@@ -92,9 +148,40 @@ func (cfg *Config) Validate() error {
 	if cfg.MaxCompactionParallelism < 1 {
 		return errors.New("max compaction parallelism must be >= 1")
 	}
+	if err := shipper_util.ValidateCompression(cfg.IndexCompression); err != nil {
+		return err
+	}
 	if cfg.RetentionEnabled && cfg.ApplyRetentionInterval != 0 && cfg.ApplyRetentionInterval%cfg.CompactionInterval != 0 {
 		return errors.New("interval for applying retention should either be set to a 0 or a multiple of compaction interval")
 	}
+	if cfg.ChunkPackingMaxChunkSize > 0 {
+		if !cfg.RetentionEnabled {
+			return errors.New("chunk packing requires retention to be enabled")
+		}
+		if cfg.ChunkPackingMaxSize < cfg.ChunkPackingMaxChunkSize {
+			return errors.New("chunk packing max container size must be >= max chunk size")
+		}
+	}
+	if cfg.ChunkScrubberEnabled && (cfg.ChunkScrubberInterval <= 0 || cfg.ChunkScrubberSampleSize <= 0 || cfg.ChunkScrubberRateLimit <= 0) {
+		return errors.New("chunk scrubber interval, sample size and rate limit must be > 0")
+	}
+	if cfg.BloomBuilderEnabled && (cfg.BloomBuilderInterval <= 0 || cfg.BloomBuilderRateLimit <= 0 || cfg.BloomBuilderMaxBloomSize <= 0) {
+		return errors.New("bloom builder interval, rate limit and max bloom size must be > 0")
+	}
+	if cfg.OrphanScrubberEnabled && (cfg.OrphanScrubberInterval <= 0 || cfg.OrphanScrubberMinAge <= 0 || cfg.OrphanScrubberDeleteRateLimit <= 0) {
+		return errors.New("orphan scrubber interval, min age and delete rate limit must be > 0")
+	}
+	if cfg.ShardingEnabled {
+		if cfg.ShardingTableLockTTL <= 0 {
+			return errors.New("sharding table lock ttl must be > 0")
+		}
+		if err := shipper_util.ValidateSharedStoreKeyPrefix(cfg.ShardingTableLockPrefix); err != nil {
+			return err
+		}
+		if cfg.ShardingTableLockPrefix == cfg.SharedStoreKeyPrefix {
+			return errors.New("sharding table lock key prefix must not be the prefix of the index")
+		}
+	}
 
 	return shipper_util.ValidateSharedStoreKeyPrefix(cfg.SharedStoreKeyPrefix)
 }
@@ -106,25 +193,33 @@ type Compactor struct {
 	indexStorageClient    shipper_storage.Client
 	tableMarker           retention.TableMarker
 	sweeper               *retention.Sweeper
+	undeleter             *undeleter
+	scrubber              *chunkScrubber
+	bloomBuilder          *bloomBuilder
+	orphanScrubber        *orphanScrubber
 	deleteRequestsStore   deletion.DeleteRequestsStore
 	DeleteRequestsHandler *deletion.DeleteRequestHandler
 	deleteRequestsManager *deletion.DeleteRequestsManager
 	expirationChecker     retention.ExpirationChecker
+	schemaConfig          chunk.SchemaConfig
+	notifier              notifications.Notifier
 	metrics               *metrics
 	running               bool
 	wg                    sync.WaitGroup
 
-	// Ring used for running a single compactor
+	// Ring used for running a single compactor, or sharding the tables across the compactors
 	ringLifecycler *ring.BasicLifecycler
 	ring           *ring.Ring
 	ringPollPeriod time.Duration
+	ringNumTokens  int
+	tableLocker    *tableLocker
 
 	// Subservices manager.
 	subservices        *services.Manager
 	subservicesWatcher *services.FailureWatcher
 }
 
-func NewCompactor(cfg Config, storageConfig storage.Config, schemaConfig loki_storage.SchemaConfig, limits retention.Limits, clientMetrics storage.ClientMetrics, r prometheus.Registerer) (*Compactor, error) {
+func NewCompactor(cfg Config, storageConfig storage.Config, schemaConfig loki_storage.SchemaConfig, limits retention.Limits, clientMetrics storage.ClientMetrics, notifier notifications.Notifier, r prometheus.Registerer) (*Compactor, error) {
 	retentionEnabledStats.Set("false")
 	if cfg.RetentionEnabled {
 		retentionEnabledStats.Set("true")
@@ -138,7 +233,13 @@ func NewCompactor(cfg Config, storageConfig storage.Config, schemaConfig loki_st
 
 	compactor := &Compactor{
 		cfg:            cfg,
+		schemaConfig:   schemaConfig.SchemaConfig,
+		notifier:       notifier,
 		ringPollPeriod: 5 * time.Second,
+		ringNumTokens:  ringNumTokens,
+	}
+	if cfg.ShardingEnabled {
+		compactor.ringNumTokens = ringNumTokensSharding
 	}
 
 	ringStore, err := kv.NewClient(
@@ -150,7 +251,7 @@ func NewCompactor(cfg Config, storageConfig storage.Config, schemaConfig loki_st
 	if err != nil {
 		return nil, errors.Wrap(err, "create KV store client")
 	}
-	lifecyclerCfg, err := cfg.CompactorRing.ToLifecyclerConfig(ringNumTokens, util_log.Logger)
+	lifecyclerCfg, err := cfg.CompactorRing.ToLifecyclerConfig(compactor.ringNumTokens, util_log.Logger)
 	if err != nil {
 		return nil, errors.Wrap(err, "invalid ring lifecycler config")
 	}
@@ -201,33 +302,92 @@ func (c *Compactor) init(storageConfig storage.Config, schemaConfig loki_storage
 	c.indexStorageClient = shipper_storage.NewIndexStorageClient(objectClient, c.cfg.SharedStoreKeyPrefix)
 	c.metrics = newMetrics(r)
 
-	if c.cfg.RetentionEnabled {
-		var encoder objectclient.KeyEncoder
-		if _, ok := objectClient.(*local.FSObjectClient); ok {
+	var (
+		chunkClient       chunk.Client
+		chunkObjectClient chunk.ObjectClient
+		encoder           objectclient.KeyEncoder
+	)
+	if c.cfg.RetentionEnabled || c.cfg.ChunkScrubberEnabled || c.cfg.BloomBuilderEnabled || c.cfg.OrphanScrubberEnabled {
+		if c.cfg.SharedStoreType == storage.StorageTypeFileSystem {
 			encoder = objectclient.FSEncoder
 		}
 
-		chunkClient := objectclient.NewClient(objectClient, encoder, schemaConfig.SchemaConfig)
+		chunkObjectClient, err = storage.WrapChunkObjectClient(objectClient, storageConfig)
+		if err != nil {
+			return err
+		}
+		chunkClient = objectclient.NewClient(chunkObjectClient, encoder, schemaConfig.SchemaConfig)
+	}
+
+	if c.cfg.ShardingEnabled {
+		c.tableLocker = newTableLocker(objectClient, c.cfg.ShardingTableLockPrefix, c.ringLifecycler.GetInstanceID(), c.cfg.ShardingTableLockTTL)
+	}
 
-		retentionWorkDir := filepath.Join(c.cfg.WorkingDirectory, "retention")
-		c.sweeper, err = retention.NewSweeper(retentionWorkDir, chunkClient, c.cfg.RetentionDeleteWorkCount, c.cfg.RetentionDeleteDelay, r)
+	if c.cfg.ChunkScrubberEnabled {
+		quarantineStore := quarantine.NewStore(objectClient, c.cfg.ChunkQuarantine.KeyPrefix)
+		c.scrubber, err = newChunkScrubber(c.cfg, schemaConfig, c.indexStorageClient, chunkClient, quarantineStore, c.metrics)
 		if err != nil {
 			return err
 		}
+		if c.cfg.ShardingEnabled {
+			c.scrubber.ownsTable = c.ownsTableOrFalse
+		}
+	}
 
-		deletionWorkDir := filepath.Join(c.cfg.WorkingDirectory, "deletion")
+	if c.cfg.BloomBuilderEnabled {
+		bloomStore := bloom.NewStore(objectClient, c.cfg.ChunkBlooms.KeyPrefix)
+		c.bloomBuilder, err = newBloomBuilder(c.cfg, schemaConfig, c.indexStorageClient, chunkClient, bloomStore, c.metrics)
+		if err != nil {
+			return err
+		}
+		if c.cfg.ShardingEnabled {
+			c.bloomBuilder.ownsTable = c.ownsTableOrFalse
+		}
+	}
+
+	if c.cfg.OrphanScrubberEnabled {
+		c.orphanScrubber, err = newOrphanScrubber(c.cfg, schemaConfig, c.indexStorageClient, chunkObjectClient, encoder, c.metrics)
+		if err != nil {
+			return err
+		}
+		if c.cfg.ShardingEnabled {
+			c.orphanScrubber.ownsTable = c.ownsTableOrFalse
+		}
+	}
 
-		c.deleteRequestsStore, err = deletion.NewDeleteStore(deletionWorkDir, c.indexStorageClient)
+	if c.cfg.RetentionEnabled {
+		retentionWorkDir := filepath.Join(c.cfg.WorkingDirectory, "retention")
+		c.sweeper, err = retention.NewSweeper(retentionWorkDir, chunkClient, c.cfg.RetentionDeleteWorkCount, c.cfg.RetentionDeleteDelay, r)
+		if err != nil {
+			return err
+		}
+		c.undeleter, err = newUndeleter(c.cfg, schemaConfig, c.indexStorageClient, chunkClient, c.sweeper)
 		if err != nil {
 			return err
 		}
 
-		c.DeleteRequestsHandler = deletion.NewDeleteRequestHandler(c.deleteRequestsStore, time.Hour, r)
-		c.deleteRequestsManager = deletion.NewDeleteRequestsManager(c.deleteRequestsStore, c.cfg.DeleteRequestCancelPeriod, r)
+		retentionExpiryChecker := retention.NewPeriodsExpirationChecker(retention.NewExpirationChecker(limits), schemaConfig.SchemaConfig)
+		if c.cfg.ShardingEnabled {
+			// the delete requests are tracked by a single compactor, the sharded compactors only apply the retention.
+			c.expirationChecker = retentionExpiryChecker
+		} else {
+			deletionWorkDir := filepath.Join(c.cfg.WorkingDirectory, "deletion")
 
-		c.expirationChecker = newExpirationChecker(retention.NewExpirationChecker(limits), c.deleteRequestsManager)
+			c.deleteRequestsStore, err = deletion.NewDeleteStore(deletionWorkDir, c.indexStorageClient)
+			if err != nil {
+				return err
+			}
 
-		c.tableMarker, err = retention.NewMarker(retentionWorkDir, schemaConfig, c.expirationChecker, chunkClient, r)
+			c.DeleteRequestsHandler = deletion.NewDeleteRequestHandler(c.deleteRequestsStore, c.cfg.DeleteRequestCancelPeriod, r)
+			c.deleteRequestsManager = deletion.NewDeleteRequestsManager(c.deleteRequestsStore, c.cfg.DeleteRequestCancelPeriod, c.notifier, r)
+			c.expirationChecker = newExpirationChecker(retentionExpiryChecker, c.deleteRequestsManager)
+		}
+
+		c.tableMarker, err = retention.NewMarker(retentionWorkDir, schemaConfig, c.expirationChecker, chunkClient, retention.PackingConfig{
+			MaxChunkSize:     c.cfg.ChunkPackingMaxChunkSize,
+			MaxContainerSize: c.cfg.ChunkPackingMaxSize,
+			MinAge:           c.cfg.ChunkPackingMinAge,
+		}, r)
 		if err != nil {
 			return err
 		}
@@ -285,7 +445,7 @@ func (c *Compactor) starting(ctx context.Context) (err error) {
 }
 
 func (c *Compactor) loop(ctx context.Context) error {
-	if c.cfg.RetentionEnabled {
+	if c.deleteRequestsStore != nil {
 		defer c.deleteRequestsStore.Stop()
 		defer c.deleteRequestsManager.Stop()
 	}
@@ -306,19 +466,24 @@ func (c *Compactor) loop(ctx context.Context) error {
 			level.Info(util_log.Logger).Log("msg", "compactor exiting")
 			return nil
 		case <-syncTicker.C:
-			bufDescs, bufHosts, bufZones := ring.MakeBuffersForGet()
-			rs, err := c.ring.Get(ringKeyOfLeader, ring.Write, bufDescs, bufHosts, bufZones)
-			if err != nil {
-				level.Error(util_log.Logger).Log("msg", "error asking ring for who should run the compactor, will check again", "err", err)
+			if c.cfg.ShardingEnabled {
+				// every compactor runs the compactions, of the tables it owns in the ring.
+				if !c.running {
+					level.Info(util_log.Logger).Log("msg", "sharding the tables across the compactors, starting compactor")
+					runningCtx, runningCancel = context.WithCancel(ctx)
+					go c.runCompactions(runningCtx)
+					c.running = true
+					c.metrics.compactorRunning.Set(1)
+				}
 				continue
 			}
 
-			addrs := rs.GetAddresses()
-			if len(addrs) != 1 {
-				level.Error(util_log.Logger).Log("msg", "too many addresses (more that one) return when asking the ring who should run the compactor, will check again")
+			leader, err := c.ownsKey(ringKeyOfLeader)
+			if err != nil {
+				level.Error(util_log.Logger).Log("msg", "error asking ring for who should run the compactor, will check again", "err", err)
 				continue
 			}
-			if c.ringLifecycler.GetInstanceAddr() == addrs[0] {
+			if leader {
 				// If not running, start
 				if !c.running {
 					level.Info(util_log.Logger).Log("msg", "this instance has been chosen to run the compactor, starting compactor")
@@ -347,14 +512,17 @@ func (c *Compactor) runCompactions(ctx context.Context) {
 	// this allows the ring to settle if there are a lot of ring changes and gives
 	// time for existing compactors to shutdown before this starts to avoid
 	// multiple compactors running at the same time.
-	t := time.NewTimer(c.cfg.CompactionInterval)
-	level.Info(util_log.Logger).Log("msg", fmt.Sprintf("waiting %v for ring to stay stable and previous compactions to finish before starting compactor", c.cfg.CompactionInterval))
-	select {
-	case <-ctx.Done():
-		return
-	case <-t.C:
-		level.Info(util_log.Logger).Log("msg", "compactor startup delay completed")
-		break
+	// The sharded compactors don't wait, they lock the tables they compact.
+	if !c.cfg.ShardingEnabled {
+		t := time.NewTimer(c.cfg.CompactionInterval)
+		level.Info(util_log.Logger).Log("msg", fmt.Sprintf("waiting %v for ring to stay stable and previous compactions to finish before starting compactor", c.cfg.CompactionInterval))
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			level.Info(util_log.Logger).Log("msg", "compactor startup delay completed")
+			break
+		}
 	}
 
 	lastRetentionRunAt := time.Unix(0, 0)
@@ -392,6 +560,11 @@ func (c *Compactor) runCompactions(ctx context.Context) {
 			}
 		}
 	}()
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.notifySchemaPeriods(ctx)
+	}()
 	if c.cfg.RetentionEnabled {
 		c.wg.Add(1)
 		go func() {
@@ -404,6 +577,27 @@ func (c *Compactor) runCompactions(ctx context.Context) {
 			<-ctx.Done()
 		}()
 	}
+	if c.cfg.ChunkScrubberEnabled {
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			c.scrubber.run(ctx, c.cfg.ChunkScrubberInterval)
+		}()
+	}
+	if c.cfg.BloomBuilderEnabled {
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			c.bloomBuilder.run(ctx, c.cfg.BloomBuilderInterval)
+		}()
+	}
+	if c.cfg.OrphanScrubberEnabled {
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			c.orphanScrubber.run(ctx, c.cfg.OrphanScrubberInterval)
+		}()
+	}
 	level.Info(util_log.Logger).Log("msg", "compactor started")
 }
 
@@ -412,7 +606,7 @@ func (c *Compactor) stopping(_ error) error {
 }
 
 func (c *Compactor) CompactTable(ctx context.Context, tableName string, applyRetention bool) error {
-	table, err := newTable(ctx, filepath.Join(c.cfg.WorkingDirectory, tableName), c.indexStorageClient,
+	table, err := newTable(ctx, filepath.Join(c.cfg.WorkingDirectory, tableName), c.indexStorageClient, c.cfg.IndexCompression,
 		c.tableMarker, c.expirationChecker)
 	if err != nil {
 		level.Error(util_log.Logger).Log("msg", "failed to initialize table for compaction", "table", tableName, "err", err)
@@ -458,6 +652,10 @@ func (c *Compactor) RunCompaction(ctx context.Context, applyRetention bool) erro
 			} else {
 				c.expirationChecker.MarkPhaseFailed()
 			}
+			c.notifier.Notify(notifications.NewEvent(notifications.RetentionRunFinished, "", map[string]string{
+				"status":   status,
+				"duration": runtime.String(),
+			}))
 		}
 		if runtime > c.cfg.CompactionInterval {
 			level.Warn(util_log.Logger).Log("msg", fmt.Sprintf("last compaction took %s which is longer than the compaction interval of %s, this can lead to duplicate compactors running if not running a standalone compactor instance.", runtime, c.cfg.CompactionInterval))
@@ -487,8 +685,25 @@ func (c *Compactor) RunCompaction(ctx context.Context, applyRetention bool) erro
 						return
 					}
 
+					if c.tableLocker != nil {
+						var locked bool
+						locked, err = c.tableLocker.lock(ctx, tableName)
+						if err != nil {
+							return
+						}
+						if !locked {
+							level.Info(util_log.Logger).Log("msg", "table locked by another compactor, skipping it", "table-name", tableName)
+							continue
+						}
+					}
+
 					level.Info(util_log.Logger).Log("msg", "compacting table", "table-name", tableName)
 					err = c.CompactTable(ctx, tableName, applyRetention)
+					if c.tableLocker != nil {
+						if unlockErr := c.tableLocker.unlock(context.Background(), tableName); unlockErr != nil {
+							level.Error(util_log.Logger).Log("msg", "failed to unlock table", "table-name", tableName, "err", unlockErr)
+						}
+					}
 					if err != nil {
 						return
 					}
@@ -506,6 +721,9 @@ func (c *Compactor) RunCompaction(ctx context.Context, applyRetention bool) erro
 				// we do not want to compact or apply retention on delete requests table
 				continue
 			}
+			if c.cfg.ShardingEnabled && !c.ownsTableOrFalse(tableName) {
+				continue
+			}
 
 			select {
 			case compactTablesChan <- tableName:
@@ -530,6 +748,66 @@ func (c *Compactor) RunCompaction(ctx context.Context, applyRetention bool) erro
 	return firstErr
 }
 
+// notifySchemaPeriods notifies the period configs starting while the compactor is running, including the ones of tenants.
+func (c *Compactor) notifySchemaPeriods(ctx context.Context) {
+	ticker := time.NewTicker(schemaPeriodsCheckInterval)
+	defer ticker.Stop()
+
+	last := model.Now()
+	for {
+		select {
+		case <-ticker.C:
+			now := model.Now()
+			if c.cfg.ShardingEnabled {
+				// only the leader of the sharded compactors notifies the periods.
+				if leader, err := c.ownsKey(ringKeyOfLeader); err != nil || !leader {
+					last = now
+					continue
+				}
+			}
+			c.notifyActivatedPeriods("", c.schemaConfig.Configs, last, now)
+			for tenant, cfg := range c.schemaConfig.TenantConfigs {
+				c.notifyActivatedPeriods(tenant, cfg.Configs, last, now)
+			}
+			last = now
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// ownsKey tells whether this compactor owns the key in the ring.
+func (c *Compactor) ownsKey(key uint32) (bool, error) {
+	return util.IsInReplicationSet(c.ring, key, c.ringLifecycler.GetInstanceAddr())
+}
+
+// ownsTableOrFalse tells whether this compactor owns the table in the ring, the tables being sharded by name.
+// It's false when the ring can't be read, the table being compacted at a later run.
+func (c *Compactor) ownsTableOrFalse(tableName string) bool {
+	// the names of the tables only differ by their period number, xxhash spreads them across the ring.
+	owned, err := c.ownsKey(uint32(xxhash.Sum64String(tableName)))
+	if err != nil {
+		level.Error(util_log.Logger).Log("msg", "error asking ring for the compactor owning the table", "table-name", tableName, "err", err)
+		return false
+	}
+	return owned
+}
+
+// notifyActivatedPeriods notifies the periods starting in (after, through].
+func (c *Compactor) notifyActivatedPeriods(tenant string, periods []chunk.PeriodConfig, after, through model.Time) {
+	for _, p := range periods {
+		if p.From.Time <= after || p.From.Time > through {
+			continue
+		}
+		c.notifier.Notify(notifications.NewEvent(notifications.SchemaPeriodActivated, tenant, map[string]string{
+			"from":        p.From.String(),
+			"schema":      p.Schema,
+			"index_type":  p.IndexType,
+			"object_type": p.ObjectType,
+		}))
+	}
+}
+
 type expirationChecker struct {
 	retentionExpiryChecker retention.ExpirationChecker
 	deletionExpiryChecker  retention.ExpirationChecker
@@ -539,7 +817,7 @@ func newExpirationChecker(retentionExpiryChecker, deletionExpiryChecker retentio
 	return &expirationChecker{retentionExpiryChecker, deletionExpiryChecker}
 }
 
-func (e *expirationChecker) Expired(ref retention.ChunkEntry, now model.Time) (bool, []model.Interval) {
+func (e *expirationChecker) Expired(ref retention.ChunkEntry, now model.Time) (bool, []retention.IntervalFilter) {
 	if expired, nonDeletedIntervals := e.retentionExpiryChecker.Expired(ref, now); expired {
 		return expired, nonDeletedIntervals
 	}
@@ -547,6 +825,21 @@ func (e *expirationChecker) Expired(ref retention.ChunkEntry, now model.Time) (b
 	return e.deletionExpiryChecker.Expired(ref, now)
 }
 
+// AuditsRemoval audits the removals of the delete requests, the chunks expired by the retention not being audited.
+func (e *expirationChecker) AuditsRemoval(ref retention.ChunkEntry, now model.Time) bool {
+	if expired, _ := e.retentionExpiryChecker.Expired(ref, now); expired {
+		return false
+	}
+	auditor, ok := e.deletionExpiryChecker.(retention.RemovalAuditor)
+	return ok && auditor.AuditsRemoval(ref, now)
+}
+
+func (e *expirationChecker) RemovedLines(ref retention.ChunkEntry, lines int) {
+	if auditor, ok := e.deletionExpiryChecker.(retention.RemovalAuditor); ok {
+		auditor.RemovedLines(ref, lines)
+	}
+}
+
 func (e *expirationChecker) MarkPhaseStarted() {
 	e.retentionExpiryChecker.MarkPhaseStarted()
 	e.deletionExpiryChecker.MarkPhaseStarted()
@@ -580,7 +873,7 @@ func (c *Compactor) OnRingInstanceRegister(_ *ring.BasicLifecycler, ringDesc rin
 	}
 
 	takenTokens := ringDesc.GetTokens()
-	newTokens := ring.GenerateTokens(ringNumTokens-len(tokens), takenTokens)
+	newTokens := ring.GenerateTokens(c.ringNumTokens-len(tokens), takenTokens)
 
 	// Tokens sorting will be enforced by the parent caller.
 	tokens = append(tokens, newTokens...)
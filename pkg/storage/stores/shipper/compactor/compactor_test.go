@@ -1,17 +1,29 @@
 package compactor
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/go-kit/log"
 	"github.com/grafana/dskit/flagext"
+	"github.com/grafana/dskit/kv/consul"
+	"github.com/grafana/dskit/ring"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
 	"github.com/stretchr/testify/require"
 
+	"github.com/grafana/loki/pkg/notifications"
 	loki_storage "github.com/grafana/loki/pkg/storage"
+	"github.com/grafana/loki/pkg/storage/chunk"
 	"github.com/grafana/loki/pkg/storage/chunk/local"
+	"github.com/grafana/loki/pkg/storage/chunk/objectclient"
 	"github.com/grafana/loki/pkg/storage/chunk/storage"
 	"github.com/grafana/loki/pkg/storage/stores/shipper/testutil"
 	loki_net "github.com/grafana/loki/pkg/util/net"
@@ -30,7 +42,7 @@ func setupTestCompactor(t *testing.T, tempDir string, clientMetrics storage.Clie
 
 	require.NoError(t, cfg.Validate())
 
-	c, err := NewCompactor(cfg, storage.Config{FSConfig: local.FSConfig{Directory: tempDir}}, loki_storage.SchemaConfig{}, nil, clientMetrics, nil)
+	c, err := NewCompactor(cfg, storage.Config{FSConfig: local.FSConfig{Directory: tempDir}}, loki_storage.SchemaConfig{}, nil, clientMetrics, notifications.Noop, nil)
 	require.NoError(t, err)
 
 	return c
@@ -121,3 +133,184 @@ func TestCompactor_RunCompaction(t *testing.T) {
 		compareCompactedTable(t, filepath.Join(tablesPath, name), filepath.Join(tablesCopyPath, name))
 	}
 }
+
+func TestCompactor_RunCompactionSharded(t *testing.T) {
+	tempDir := t.TempDir()
+	tablesPath := filepath.Join(tempDir, "index")
+
+	var tableNames []string
+	for i := 0; i < 8; i++ {
+		name := fmt.Sprintf("table%d", i)
+		tableNames = append(tableNames, name)
+		testutil.SetupDBsAtPath(t, filepath.Join(tablesPath, name), map[string]testutil.DBConfig{
+			"db1": {DBRecords: testutil.DBRecords{Start: 0, NumRecords: 10}},
+			"db2": {DBRecords: testutil.DBRecords{Start: 10, NumRecords: 10}},
+		}, nil)
+	}
+	numFiles := func(tableName string) int {
+		files, err := ioutil.ReadDir(filepath.Join(tablesPath, tableName))
+		require.NoError(t, err)
+		return len(files)
+	}
+
+	kvClient, closer := consul.NewInMemoryClient(ring.GetCodec(), log.NewNopLogger(), nil)
+	t.Cleanup(func() { _ = closer.Close() })
+
+	cm := storage.NewClientMetrics()
+	defer cm.Unregister()
+	var compactors []*Compactor
+	for i := 0; i < 2; i++ {
+		cfg := Config{}
+		flagext.DefaultValues(&cfg)
+		cfg.WorkingDirectory = filepath.Join(tempDir, fmt.Sprintf("compactor-%d", i))
+		cfg.SharedStoreType = "filesystem"
+		cfg.ShardingEnabled = true
+		cfg.CompactorRing.KVStore.Mock = kvClient
+		cfg.CompactorRing.InstanceID = fmt.Sprintf("compactor-%d", i)
+		cfg.CompactorRing.InstanceAddr = "127.0.0.1"
+		cfg.CompactorRing.InstancePort = 9095 + i
+		require.NoError(t, cfg.Validate())
+
+		c, err := NewCompactor(cfg, storage.Config{FSConfig: local.FSConfig{Directory: tempDir}}, loki_storage.SchemaConfig{}, nil, cm, notifications.Noop, nil)
+		require.NoError(t, err)
+		require.NoError(t, c.starting(context.Background()))
+		t.Cleanup(func() {
+			require.NoError(t, c.stopping(nil))
+		})
+		compactors = append(compactors, c)
+	}
+	for _, c := range compactors {
+		c := c
+		require.Eventually(t, func() bool {
+			return c.ring.InstancesCount() == 2
+		}, 5*time.Second, 10*time.Millisecond)
+	}
+
+	// the tables are owned by a single compactor.
+	owners := map[string]int{}
+	for _, tableName := range tableNames {
+		for i, c := range compactors {
+			if c.ownsTableOrFalse(tableName) {
+				_, ok := owners[tableName]
+				require.False(t, ok, tableName)
+				owners[tableName] = i
+			}
+		}
+	}
+	require.Len(t, owners, len(tableNames))
+
+	// a table owned by the first compactor is locked by another one, it isn't compacted.
+	var locked string
+	for _, tableName := range tableNames {
+		if owners[tableName] == 0 {
+			locked = tableName
+			break
+		}
+	}
+	require.NotEmpty(t, locked)
+	otherLocker := newTableLocker(compactors[0].tableLocker.objectClient, compactors[0].tableLocker.keyPrefix, "other", time.Hour)
+	ok, err := otherLocker.lock(context.Background(), locked)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	require.NoError(t, compactors[0].RunCompaction(context.Background(), false))
+	for _, tableName := range tableNames {
+		expected := 2
+		if owners[tableName] == 0 && tableName != locked {
+			expected = 1
+		}
+		require.Equal(t, expected, numFiles(tableName), tableName)
+	}
+
+	require.NoError(t, otherLocker.unlock(context.Background(), locked))
+	require.NoError(t, compactors[0].RunCompaction(context.Background(), false))
+	require.NoError(t, compactors[1].RunCompaction(context.Background(), false))
+	for _, tableName := range tableNames {
+		require.Equal(t, 1, numFiles(tableName), tableName)
+	}
+}
+
+type recordingNotifier struct {
+	events []notifications.Event
+}
+
+func (n *recordingNotifier) Notify(e notifications.Event) {
+	n.events = append(n.events, e)
+}
+
+func TestCompactor_NotifyActivatedPeriods(t *testing.T) {
+	notifier := &recordingNotifier{}
+	c := &Compactor{notifier: notifier}
+
+	periods := []chunk.PeriodConfig{
+		{From: chunk.DayTime{Time: model.TimeFromUnix(0)}, Schema: "v11", IndexType: "boltdb-shipper", ObjectType: "filesystem"},
+		{From: chunk.DayTime{Time: model.TimeFromUnix(86400)}, Schema: "v12", IndexType: "boltdb-shipper", ObjectType: "filesystem"},
+	}
+
+	c.notifyActivatedPeriods("", periods, model.TimeFromUnix(3600), model.TimeFromUnix(7200))
+	require.Empty(t, notifier.events)
+
+	c.notifyActivatedPeriods("tenant", periods, model.TimeFromUnix(86340), model.TimeFromUnix(86400))
+	require.Len(t, notifier.events, 1)
+	require.Equal(t, notifications.SchemaPeriodActivated, notifier.events[0].Type)
+	require.Equal(t, "tenant", notifier.events[0].Tenant)
+	require.Equal(t, "v12", notifier.events[0].Details["schema"])
+	require.Equal(t, "1970-01-02", notifier.events[0].Details["from"])
+}
+
+func TestCompactor_MirroredFilesystemStore(t *testing.T) {
+	s := newScrubberTestStoreWithSchema(t, orphanSchemaCfg)
+	ctx := context.Background()
+
+	day := time.Now().Add(-72*time.Hour).Unix() / 86400
+	tableName := fmt.Sprintf("index_%d", day)
+	from := model.TimeFromUnix(day * 86400).Add(time.Hour)
+	old := time.Now().Add(-48 * time.Hour)
+
+	mirrorDir := filepath.Join(s.dir, "mirror")
+	mirror, err := local.NewFSObjectClient(local.FSConfig{Directory: mirrorDir})
+	require.NoError(t, err)
+
+	indexed := s.newChunk("fake", labels.Labels{{Name: "app", Value: "indexed"}}, from)
+	orphaned := s.newChunk("fake", labels.Labels{{Name: "app", Value: "orphaned"}}, from)
+	key := func(c chunk.Chunk) string { return objectclient.FSEncoder(s.schemaCfg.SchemaConfig, c) }
+	for _, c := range []chunk.Chunk{indexed, orphaned} {
+		buf, err := ioutil.ReadFile(filepath.Join(s.dir, "store", filepath.FromSlash(key(c))))
+		require.NoError(t, err)
+		require.NoError(t, mirror.PutObject(ctx, key(c), bytes.NewReader(buf)))
+		s.age(key(c), old)
+	}
+	s.writeIndex(tableName, "", "compactor-1", indexed)
+
+	cfg := Config{}
+	flagext.DefaultValues(&cfg)
+	cfg.WorkingDirectory = filepath.Join(s.dir, workingDirName)
+	cfg.SharedStoreType = "filesystem"
+	cfg.OrphanScrubberEnabled = true
+	cfg.OrphanScrubberDelete = true
+	if loopbackIFace, err := loki_net.LoopbackInterfaceName(); err == nil {
+		cfg.CompactorRing.InstanceInterfaceNames = append(cfg.CompactorRing.InstanceInterfaceNames, loopbackIFace)
+	}
+	require.NoError(t, cfg.Validate())
+
+	storageCfg := storage.Config{FSConfig: local.FSConfig{Directory: filepath.Join(s.dir, "store")}}
+	flagext.DefaultValues(&storageCfg.Mirror)
+	storageCfg.Mirror.Store = storage.StorageTypeFileSystem
+	storageCfg.Mirror.FSConfig = local.FSConfig{Directory: mirrorDir}
+
+	cm := storage.NewClientMetrics()
+	defer cm.Unregister()
+	c, err := NewCompactor(cfg, storageCfg, orphanSchemaCfg, nil, cm, notifications.Noop, nil)
+	require.NoError(t, err)
+
+	// the chunk keys of the mirrored filesystem store are still encoded for the filesystem.
+	require.NoError(t, c.orphanScrubber.scrubNextTable(ctx))
+	exists := func(dir string, c chunk.Chunk) bool {
+		_, err := os.Stat(filepath.Join(dir, filepath.FromSlash(key(c))))
+		return err == nil
+	}
+	for _, dir := range []string{filepath.Join(s.dir, "store"), mirrorDir} {
+		require.True(t, exists(dir, indexed))
+		require.False(t, exists(dir, orphaned))
+	}
+}
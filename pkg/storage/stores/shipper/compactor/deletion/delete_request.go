@@ -1,11 +1,15 @@
 package deletion
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/labels"
-	"github.com/prometheus/prometheus/promql/parser"
 
+	"github.com/grafana/loki/pkg/logql/syntax"
 	"github.com/grafana/loki/pkg/storage/stores/shipper/compactor/retention"
+	"github.com/grafana/loki/pkg/util/filter"
 )
 
 // DeleteRequest holds all the details about a delete request.
@@ -19,9 +23,52 @@ type DeleteRequest struct {
 
 	UserID   string              `json:"-"`
 	Matchers [][]*labels.Matcher `json:"-"`
+
+	// logSelectorExprs holds the parsed selectors, parsed on the first use of the request.
+	logSelectorExprs []syntax.LogSelectorExpr
+}
+
+// parseDeleteSelector parses the selector of a delete request, a LogQL stream selector optionally followed by line filters.
+func parseDeleteSelector(selector string) (syntax.LogSelectorExpr, error) {
+	expr, err := syntax.ParseLogSelector(selector, true)
+	if err != nil {
+		return nil, err
+	}
+
+	onlyLineFilters := true
+	expr.Walk(func(e interface{}) {
+		switch e.(type) {
+		case *syntax.MatchersExpr, *syntax.PipelineExpr, *syntax.LineFilterExpr:
+		default:
+			onlyLineFilters = false
+		}
+	})
+	if !onlyLineFilters {
+		return nil, fmt.Errorf("invalid selector %s: only line filters are supported after the stream selector of a delete request", selector)
+	}
+	return expr, nil
 }
 
-func (d *DeleteRequest) IsDeleted(entry retention.ChunkEntry) (bool, []model.Interval) {
+func (d *DeleteRequest) parseSelectors() error {
+	if len(d.logSelectorExprs) == len(d.Selectors) {
+		return nil
+	}
+
+	exprs := make([]syntax.LogSelectorExpr, 0, len(d.Selectors))
+	for _, selector := range d.Selectors {
+		expr, err := parseDeleteSelector(selector)
+		if err != nil {
+			return err
+		}
+		exprs = append(exprs, expr)
+	}
+	d.logSelectorExprs = exprs
+	return nil
+}
+
+// IsDeleted tells whether the chunk is deleted, completely or partially, by the request. The intervals of the chunk to
+// retain are returned for a partially deleted chunk, along with the filter of the lines to drop from them.
+func (d *DeleteRequest) IsDeleted(entry retention.ChunkEntry) (bool, []retention.IntervalFilter) {
 	if d.UserID != unsafeGetString(entry.UserID) {
 		return false, nil
 	}
@@ -36,52 +83,103 @@ func (d *DeleteRequest) IsDeleted(entry retention.ChunkEntry) (bool, []model.Int
 		return false, nil
 	}
 
-	matchers := make([][]*labels.Matcher, len(d.Selectors))
-	var err error
-
-	for j, selector := range d.Selectors {
-		matchers[j], err = parser.ParseMetricSelector(selector)
-
-		if err != nil {
-			return false, nil
-		}
+	if err := d.parseSelectors(); err != nil {
+		return false, nil
 	}
 
-	matches := false
-	for _, matchers := range matchers {
-		if labels.Selector(matchers).Matches(entry.Labels) {
+	var (
+		matches     bool
+		lineFilters []filter.Func
+	)
+	for _, expr := range d.logSelectorExprs {
+		if !labels.Selector(expr.Matchers()).Matches(entry.Labels) {
+			continue
+		}
+		if !expr.HasFilter() {
+			// the selector deletes all the lines of the stream.
 			matches = true
+			lineFilters = nil
 			break
 		}
+
+		lineFilter, err := d.lineFilter(expr, entry.Labels)
+		if err != nil {
+			continue
+		}
+		matches = true
+		lineFilters = append(lineFilters, lineFilter)
 	}
 
 	if !matches {
 		return false, nil
 	}
 
+	if len(lineFilters) > 0 {
+		// the whole chunk is rewritten, omitting the lines matching the filters within the interval of the request.
+		return true, []retention.IntervalFilter{
+			{
+				Interval: model.Interval{
+					Start: entry.From,
+					End:   entry.Through,
+				},
+				Filter: func(ts time.Time, line string) bool {
+					for _, lineFilter := range lineFilters {
+						if lineFilter(ts, line) {
+							return true
+						}
+					}
+					return false
+				},
+			},
+		}
+	}
+
 	if d.StartTime <= entry.From && d.EndTime >= entry.Through {
 		return true, nil
 	}
 
-	intervals := make([]model.Interval, 0, 2)
+	intervals := make([]retention.IntervalFilter, 0, 2)
 
 	if d.StartTime > entry.From {
-		intervals = append(intervals, model.Interval{
-			Start: entry.From,
-			End:   d.StartTime - 1,
+		intervals = append(intervals, retention.IntervalFilter{
+			Interval: model.Interval{
+				Start: entry.From,
+				End:   d.StartTime - 1,
+			},
 		})
 	}
 
 	if d.EndTime < entry.Through {
-		intervals = append(intervals, model.Interval{
-			Start: d.EndTime + 1,
-			End:   entry.Through,
+		intervals = append(intervals, retention.IntervalFilter{
+			Interval: model.Interval{
+				Start: d.EndTime + 1,
+				End:   entry.Through,
+			},
 		})
 	}
 
 	return true, intervals
 }
 
+// lineFilter returns the filter dropping the lines within the interval of the request which match the line filters of the selector.
+func (d *DeleteRequest) lineFilter(expr syntax.LogSelectorExpr, lbls labels.Labels) (filter.Func, error) {
+	pipeline, err := expr.Pipeline()
+	if err != nil {
+		return nil, err
+	}
+	streamPipeline := pipeline.ForStream(lbls)
+	start, end := d.StartTime, d.EndTime
+
+	return func(ts time.Time, line string) bool {
+		t := model.TimeFromUnixNano(ts.UnixNano())
+		if t < start || t > end {
+			return false
+		}
+		_, _, matches := streamPipeline.ProcessString(ts.UnixNano(), line)
+		return matches
+	}, nil
+}
+
 func intervalsOverlap(interval1, interval2 model.Interval) bool {
 	if interval1.Start > interval2.End || interval2.Start > interval1.End {
 		return false
@@ -29,7 +29,7 @@ func TestDeleteRequest_IsDeleted(t *testing.T) {
 
 	type resp struct {
 		isDeleted           bool
-		nonDeletedIntervals []model.Interval
+		nonDeletedIntervals []retention.IntervalFilter
 	}
 
 	for _, tc := range []struct {
@@ -60,10 +60,12 @@ func TestDeleteRequest_IsDeleted(t *testing.T) {
 			},
 			expectedResp: resp{
 				isDeleted: true,
-				nonDeletedIntervals: []model.Interval{
+				nonDeletedIntervals: []retention.IntervalFilter{
 					{
-						Start: now.Add(-2*time.Hour) + 1,
-						End:   now.Add(-time.Hour),
+						Interval: model.Interval{
+							Start: now.Add(-2*time.Hour) + 1,
+							End:   now.Add(-time.Hour),
+						},
 					},
 				},
 			},
@@ -78,10 +80,12 @@ func TestDeleteRequest_IsDeleted(t *testing.T) {
 			},
 			expectedResp: resp{
 				isDeleted: true,
-				nonDeletedIntervals: []model.Interval{
+				nonDeletedIntervals: []retention.IntervalFilter{
 					{
-						Start: now.Add(-3 * time.Hour),
-						End:   now.Add(-2*time.Hour) - 1,
+						Interval: model.Interval{
+							Start: now.Add(-3 * time.Hour),
+							End:   now.Add(-2*time.Hour) - 1,
+						},
 					},
 				},
 			},
@@ -96,10 +100,12 @@ func TestDeleteRequest_IsDeleted(t *testing.T) {
 			},
 			expectedResp: resp{
 				isDeleted: true,
-				nonDeletedIntervals: []model.Interval{
+				nonDeletedIntervals: []retention.IntervalFilter{
 					{
-						Start: now.Add(-3 * time.Hour),
-						End:   now.Add(-2*time.Hour) - 1,
+						Interval: model.Interval{
+							Start: now.Add(-3 * time.Hour),
+							End:   now.Add(-2*time.Hour) - 1,
+						},
 					},
 				},
 			},
@@ -114,14 +120,18 @@ func TestDeleteRequest_IsDeleted(t *testing.T) {
 			},
 			expectedResp: resp{
 				isDeleted: true,
-				nonDeletedIntervals: []model.Interval{
+				nonDeletedIntervals: []retention.IntervalFilter{
 					{
-						Start: now.Add(-3 * time.Hour),
-						End:   now.Add(-(2*time.Hour + 30*time.Minute)) - 1,
+						Interval: model.Interval{
+							Start: now.Add(-3 * time.Hour),
+							End:   now.Add(-(2*time.Hour + 30*time.Minute)) - 1,
+						},
 					},
 					{
-						Start: now.Add(-(time.Hour + 30*time.Minute)) + 1,
-						End:   now.Add(-time.Hour),
+						Interval: model.Interval{
+							Start: now.Add(-(time.Hour + 30*time.Minute)) + 1,
+							End:   now.Add(-time.Hour),
+						},
 					},
 				},
 			},
@@ -171,6 +181,87 @@ func TestDeleteRequest_IsDeleted(t *testing.T) {
 	}
 }
 
+func TestDeleteRequest_IsDeleted_LineFilter(t *testing.T) {
+	now := model.Now()
+	user1 := "user1"
+
+	chunkEntry := retention.ChunkEntry{
+		ChunkRef: retention.ChunkRef{
+			UserID:  []byte(user1),
+			From:    now.Add(-3 * time.Hour),
+			Through: now.Add(-time.Hour),
+		},
+		Labels: mustParseLabel(`{foo="bar", fizz="buzz"}`),
+	}
+
+	deleteRequest := DeleteRequest{
+		UserID:    user1,
+		StartTime: now.Add(-2 * time.Hour),
+		EndTime:   now,
+		Selectors: []string{`{foo="bar"} |= "password" != "redacted"`, `{fizz="buzz"} |~ "secret.*"`},
+	}
+
+	isDeleted, intervals := deleteRequest.IsDeleted(chunkEntry)
+	require.True(t, isDeleted)
+	require.Len(t, intervals, 1)
+	require.Equal(t, model.Interval{Start: chunkEntry.From, End: chunkEntry.Through}, intervals[0].Interval)
+
+	lineFilter := intervals[0].Filter
+	require.NotNil(t, lineFilter)
+	inRange := now.Add(-90 * time.Minute).Time()
+	for _, tc := range []struct {
+		ts       time.Time
+		line     string
+		filtered bool
+	}{
+		{ts: inRange, line: "the password is foo", filtered: true},
+		{ts: inRange, line: "the secret is foo", filtered: true},
+		{ts: inRange, line: "the password is redacted", filtered: false},
+		{ts: inRange, line: "nothing to see", filtered: false},
+		{ts: now.Add(-150 * time.Minute).Time(), line: "the password is foo", filtered: false},
+	} {
+		require.Equal(t, tc.filtered, lineFilter(tc.ts, tc.line), tc.line)
+	}
+
+	// a selector without line filters deletes the whole interval of the request.
+	deleteRequest.Selectors = append(deleteRequest.Selectors, `{foo="bar"}`)
+	deleteRequest.logSelectorExprs = nil
+	isDeleted, intervals = deleteRequest.IsDeleted(chunkEntry)
+	require.True(t, isDeleted)
+	require.Equal(t, []retention.IntervalFilter{
+		{
+			Interval: model.Interval{
+				Start: chunkEntry.From,
+				End:   now.Add(-2*time.Hour) - 1,
+			},
+		},
+	}, intervals)
+}
+
+func TestParseDeleteSelector(t *testing.T) {
+	for _, tc := range []struct {
+		selector string
+		valid    bool
+	}{
+		{selector: `{foo="bar"}`, valid: true},
+		{selector: `{foo="bar"} |= "password"`, valid: true},
+		{selector: `{foo="bar"} |= "password" !~ "redacted.*"`, valid: true},
+		{selector: `{foo="bar"} | json`, valid: false},
+		{selector: `{foo="bar"} |= "password" | level="error"`, valid: false},
+		{selector: `{foo="bar"} | line_format "{{.foo}}"`, valid: false},
+		{selector: `foo`, valid: false},
+	} {
+		t.Run(tc.selector, func(t *testing.T) {
+			_, err := parseDeleteSelector(tc.selector)
+			if tc.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
 func mustParseLabel(input string) labels.Labels {
 	lbls, err := syntax.ParseLabels(input)
 	if err != nil {
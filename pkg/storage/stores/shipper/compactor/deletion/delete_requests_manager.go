@@ -3,6 +3,8 @@ package deletion
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -10,7 +12,9 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/model"
 
+	"github.com/grafana/loki/pkg/notifications"
 	"github.com/grafana/loki/pkg/storage/stores/shipper/compactor/retention"
+	"github.com/grafana/loki/pkg/util/filter"
 	util_log "github.com/grafana/loki/pkg/util/log"
 )
 
@@ -24,20 +28,26 @@ type DeleteRequestsManager struct {
 	deleteRequestCancelPeriod time.Duration
 
 	deleteRequestsToProcess []DeleteRequest
-	chunkIntervalsToRetain  []model.Interval
+	chunkIntervalsToRetain  []retention.IntervalFilter
 	// WARN: If by any chance we change deleteRequestsToProcessMtx to sync.RWMutex to be able to check multiple chunks at a time,
 	// please take care of chunkIntervalsToRetain which should be unique per chunk.
 	deleteRequestsToProcessMtx sync.Mutex
-	metrics                    *deleteRequestsManagerMetrics
-	wg                         sync.WaitGroup
-	done                       chan struct{}
+	// removals holds the lines removed from the streams by the delete requests processed, by request.
+	removals    map[string]map[string]*StreamRemoval
+	removalsMtx sync.Mutex
+	notifier    notifications.Notifier
+	metrics     *deleteRequestsManagerMetrics
+	wg          sync.WaitGroup
+	done        chan struct{}
 }
 
-func NewDeleteRequestsManager(store DeleteRequestsStore, deleteRequestCancelPeriod time.Duration, registerer prometheus.Registerer) *DeleteRequestsManager {
+func NewDeleteRequestsManager(store DeleteRequestsStore, deleteRequestCancelPeriod time.Duration, notifier notifications.Notifier, registerer prometheus.Registerer) *DeleteRequestsManager {
 	dm := &DeleteRequestsManager{
 		deleteRequestsStore:       store,
 		deleteRequestCancelPeriod: deleteRequestCancelPeriod,
+		notifier:                  notifier,
 		metrics:                   newDeleteRequestsManagerMetrics(registerer),
+		removals:                  map[string]map[string]*StreamRemoval{},
 		done:                      make(chan struct{}),
 	}
 
@@ -123,7 +133,7 @@ func (d *DeleteRequestsManager) loadDeleteRequestsToProcess() error {
 	return nil
 }
 
-func (d *DeleteRequestsManager) Expired(ref retention.ChunkEntry, _ model.Time) (bool, []model.Interval) {
+func (d *DeleteRequestsManager) Expired(ref retention.ChunkEntry, _ model.Time) (bool, []retention.IntervalFilter) {
 	d.deleteRequestsToProcessMtx.Lock()
 	defer d.deleteRequestsToProcessMtx.Unlock()
 
@@ -132,22 +142,28 @@ func (d *DeleteRequestsManager) Expired(ref retention.ChunkEntry, _ model.Time)
 	}
 
 	d.chunkIntervalsToRetain = d.chunkIntervalsToRetain[:0]
-	d.chunkIntervalsToRetain = append(d.chunkIntervalsToRetain, model.Interval{
-		Start: ref.From,
-		End:   ref.Through,
+	d.chunkIntervalsToRetain = append(d.chunkIntervalsToRetain, retention.IntervalFilter{
+		Interval: model.Interval{
+			Start: ref.From,
+			End:   ref.Through,
+		},
 	})
 
-	for _, deleteRequest := range d.deleteRequestsToProcess {
-		rebuiltIntervals := make([]model.Interval, 0, len(d.chunkIntervalsToRetain))
+	for i := range d.deleteRequestsToProcess {
+		rebuiltIntervals := make([]retention.IntervalFilter, 0, len(d.chunkIntervalsToRetain))
 		for _, interval := range d.chunkIntervalsToRetain {
 			entry := ref
-			entry.From = interval.Start
-			entry.Through = interval.End
-			isDeleted, newIntervalsToRetain := deleteRequest.IsDeleted(entry)
+			entry.From = interval.Interval.Start
+			entry.Through = interval.Interval.End
+			isDeleted, newIntervalsToRetain := d.deleteRequestsToProcess[i].IsDeleted(entry)
 			if !isDeleted {
 				rebuiltIntervals = append(rebuiltIntervals, interval)
-			} else {
-				rebuiltIntervals = append(rebuiltIntervals, newIntervalsToRetain...)
+				continue
+			}
+			// the lines filtered out by the previous requests are still dropped from the intervals retained.
+			for _, newInterval := range newIntervalsToRetain {
+				newInterval.Filter = orFilters(interval.Filter, newInterval.Filter)
+				rebuiltIntervals = append(rebuiltIntervals, newInterval)
 			}
 		}
 
@@ -158,7 +174,8 @@ func (d *DeleteRequestsManager) Expired(ref retention.ChunkEntry, _ model.Time)
 		}
 	}
 
-	if len(d.chunkIntervalsToRetain) == 1 && d.chunkIntervalsToRetain[0].Start == ref.From && d.chunkIntervalsToRetain[0].End == ref.Through {
+	if len(d.chunkIntervalsToRetain) == 1 && d.chunkIntervalsToRetain[0].Interval.Start == ref.From &&
+		d.chunkIntervalsToRetain[0].Interval.End == ref.Through && d.chunkIntervalsToRetain[0].Filter == nil {
 		return false, nil
 	}
 
@@ -166,7 +183,73 @@ func (d *DeleteRequestsManager) Expired(ref retention.ChunkEntry, _ model.Time)
 	return true, d.chunkIntervalsToRetain
 }
 
+// orFilters returns the filter dropping the lines dropped by any of the filters.
+func orFilters(a, b filter.Func) filter.Func {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	return func(ts time.Time, line string) bool {
+		return a(ts, line) || b(ts, line)
+	}
+}
+
+// AuditsRemoval tells whether the chunk is expired by a delete request, the lines it removes being audited.
+func (d *DeleteRequestsManager) AuditsRemoval(ref retention.ChunkEntry, _ model.Time) bool {
+	d.deleteRequestsToProcessMtx.Lock()
+	defer d.deleteRequestsToProcessMtx.Unlock()
+
+	for i := range d.deleteRequestsToProcess {
+		if isDeleted, _ := d.deleteRequestsToProcess[i].IsDeleted(ref); isDeleted {
+			return true
+		}
+	}
+	return false
+}
+
+// RemovedLines records the lines removed from the chunk in the audit of each delete request deleting it.
+func (d *DeleteRequestsManager) RemovedLines(ref retention.ChunkEntry, lines int) {
+	d.deleteRequestsToProcessMtx.Lock()
+	defer d.deleteRequestsToProcessMtx.Unlock()
+
+	stream := ref.Labels.String()
+	for i := range d.deleteRequestsToProcess {
+		deleteRequest := &d.deleteRequestsToProcess[i]
+		if isDeleted, _ := deleteRequest.IsDeleted(ref); !isDeleted {
+			continue
+		}
+
+		d.removalsMtx.Lock()
+		key := deleteRequest.UserID + ":" + deleteRequest.RequestID
+		streams, ok := d.removals[key]
+		if !ok {
+			streams = map[string]*StreamRemoval{}
+			d.removals[key] = streams
+		}
+		removal, ok := streams[stream]
+		if !ok {
+			removal = &StreamRemoval{Stream: stream}
+			streams[stream] = removal
+		}
+		removal.Lines += int64(lines)
+		removal.Chunks++
+		d.removalsMtx.Unlock()
+
+		d.metrics.deleteRequestsRemovedLinesTotal.WithLabelValues(deleteRequest.UserID).Add(float64(lines))
+	}
+}
+
+func (d *DeleteRequestsManager) resetRemovals() {
+	d.removalsMtx.Lock()
+	defer d.removalsMtx.Unlock()
+
+	d.removals = map[string]map[string]*StreamRemoval{}
+}
+
 func (d *DeleteRequestsManager) MarkPhaseStarted() {
+	d.resetRemovals()
 	status := statusSuccess
 	if err := d.loadDeleteRequestsToProcess(); err != nil {
 		status = statusFail
@@ -180,20 +263,47 @@ func (d *DeleteRequestsManager) MarkPhaseFailed() {
 	defer d.deleteRequestsToProcessMtx.Unlock()
 
 	d.deleteRequestsToProcess = d.deleteRequestsToProcess[:0]
+	d.resetRemovals()
 }
 
 func (d *DeleteRequestsManager) MarkPhaseFinished() {
 	d.deleteRequestsToProcessMtx.Lock()
 	defer d.deleteRequestsToProcessMtx.Unlock()
+	defer d.resetRemovals()
 
 	for _, deleteRequest := range d.deleteRequestsToProcess {
+		if err := d.deleteRequestsStore.AddDeleteRequestAudit(context.Background(), deleteRequest.UserID, deleteRequest.RequestID, d.requestRemovals(deleteRequest)); err != nil {
+			level.Error(util_log.Logger).Log("msg", fmt.Sprintf("failed to record the audit of delete request %s for user %s", deleteRequest.RequestID, deleteRequest.UserID), "err", err)
+		}
 		if err := d.deleteRequestsStore.UpdateStatus(context.Background(), deleteRequest.UserID, deleteRequest.RequestID, StatusProcessed); err != nil {
 			level.Error(util_log.Logger).Log("msg", fmt.Sprintf("failed to mark delete request %s for user %s as processed", deleteRequest.RequestID, deleteRequest.UserID), "err", err)
 		}
 		d.metrics.deleteRequestsProcessedTotal.WithLabelValues(deleteRequest.UserID).Inc()
+		d.notifier.Notify(notifications.NewEvent(notifications.DeleteRequestCompleted, deleteRequest.UserID, map[string]string{
+			"request_id": deleteRequest.RequestID,
+			"start":      deleteRequest.StartTime.Time().UTC().Format(time.RFC3339),
+			"end":        deleteRequest.EndTime.Time().UTC().Format(time.RFC3339),
+			"selectors":  strings.Join(deleteRequest.Selectors, ","),
+		}))
 	}
 }
 
+// requestRemovals returns the lines removed from the streams by the delete request, sorted by stream.
+func (d *DeleteRequestsManager) requestRemovals(deleteRequest DeleteRequest) []StreamRemoval {
+	d.removalsMtx.Lock()
+	defer d.removalsMtx.Unlock()
+
+	streams := d.removals[deleteRequest.UserID+":"+deleteRequest.RequestID]
+	removals := make([]StreamRemoval, 0, len(streams))
+	for _, removal := range streams {
+		removals = append(removals, *removal)
+	}
+	sort.Slice(removals, func(i, j int) bool {
+		return removals[i].Stream < removals[j].Stream
+	})
+	return removals
+}
+
 func (d *DeleteRequestsManager) IntervalMayHaveExpiredChunks(_ model.Interval, userID string) bool {
 	d.deleteRequestsToProcessMtx.Lock()
 	defer d.deleteRequestsToProcessMtx.Unlock()
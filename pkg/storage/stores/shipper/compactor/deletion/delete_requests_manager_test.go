@@ -9,6 +9,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/grafana/loki/pkg/logql/syntax"
+	"github.com/grafana/loki/pkg/notifications"
 	"github.com/grafana/loki/pkg/storage/stores/shipper/compactor/retention"
 )
 
@@ -16,6 +17,7 @@ const testUserID = "test-user"
 
 type mockDeleteRequestsStore struct {
 	deleteRequests []DeleteRequest
+	audits         map[string][]StreamRemoval
 }
 
 func (m mockDeleteRequestsStore) GetDeleteRequestsByStatus(ctx context.Context, status DeleteRequestStatus) ([]DeleteRequest, error) {
@@ -50,6 +52,17 @@ func (m mockDeleteRequestsStore) RemoveDeleteRequest(ctx context.Context, userID
 	panic("implement me")
 }
 
+func (m mockDeleteRequestsStore) AddDeleteRequestAudit(ctx context.Context, userID, requestID string, removals []StreamRemoval) error {
+	if m.audits != nil {
+		m.audits[requestID] = removals
+	}
+	return nil
+}
+
+func (m mockDeleteRequestsStore) GetDeleteRequestAudit(ctx context.Context, userID, requestID string) (*DeleteRequestAudit, error) {
+	panic("implement me")
+}
+
 func (m mockDeleteRequestsStore) Stop() {
 	panic("implement me")
 }
@@ -57,7 +70,7 @@ func (m mockDeleteRequestsStore) Stop() {
 func TestDeleteRequestsManager_Expired(t *testing.T) {
 	type resp struct {
 		isExpired           bool
-		nonDeletedIntervals []model.Interval
+		nonDeletedIntervals []retention.IntervalFilter
 	}
 
 	now := model.Now()
@@ -181,18 +194,24 @@ func TestDeleteRequestsManager_Expired(t *testing.T) {
 			},
 			expectedResp: resp{
 				isExpired: true,
-				nonDeletedIntervals: []model.Interval{
+				nonDeletedIntervals: []retention.IntervalFilter{
 					{
-						Start: now.Add(-11*time.Hour) + 1,
-						End:   now.Add(-10*time.Hour) - 1,
+						Interval: model.Interval{
+							Start: now.Add(-11*time.Hour) + 1,
+							End:   now.Add(-10*time.Hour) - 1,
+						},
 					},
 					{
-						Start: now.Add(-8*time.Hour) + 1,
-						End:   now.Add(-6*time.Hour) - 1,
+						Interval: model.Interval{
+							Start: now.Add(-8*time.Hour) + 1,
+							End:   now.Add(-6*time.Hour) - 1,
+						},
 					},
 					{
-						Start: now.Add(-5*time.Hour) + 1,
-						End:   now.Add(-2*time.Hour) - 1,
+						Interval: model.Interval{
+							Start: now.Add(-5*time.Hour) + 1,
+							End:   now.Add(-2*time.Hour) - 1,
+						},
 					},
 				},
 			},
@@ -247,7 +266,7 @@ func TestDeleteRequestsManager_Expired(t *testing.T) {
 		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
-			mgr := NewDeleteRequestsManager(mockDeleteRequestsStore{deleteRequests: tc.deleteRequestsFromStore}, time.Hour, nil)
+			mgr := NewDeleteRequestsManager(mockDeleteRequestsStore{deleteRequests: tc.deleteRequestsFromStore}, time.Hour, notifications.Noop, nil)
 			require.NoError(t, mgr.loadDeleteRequestsToProcess())
 
 			isExpired, nonDeletedIntervals := mgr.Expired(chunkEntry, model.Now())
@@ -256,3 +275,152 @@ func TestDeleteRequestsManager_Expired(t *testing.T) {
 		})
 	}
 }
+
+func TestDeleteRequestsManager_Expired_LineFilters(t *testing.T) {
+	now := model.Now()
+	chunkEntry := retention.ChunkEntry{
+		ChunkRef: retention.ChunkRef{
+			UserID:  []byte(testUserID),
+			From:    now.Add(-12 * time.Hour),
+			Through: now.Add(-time.Hour),
+		},
+		Labels: mustParseLabel(`{foo="bar"}`),
+	}
+
+	mgr := NewDeleteRequestsManager(mockDeleteRequestsStore{deleteRequests: []DeleteRequest{
+		{
+			UserID:    testUserID,
+			Selectors: []string{`{foo="bar"} |= "password"`},
+			StartTime: now.Add(-24 * time.Hour),
+			EndTime:   now,
+		},
+		{
+			UserID:    testUserID,
+			Selectors: []string{`{foo="bar"}`},
+			StartTime: now.Add(-12 * time.Hour),
+			EndTime:   now.Add(-6 * time.Hour),
+		},
+		{
+			UserID:    testUserID,
+			Selectors: []string{`{foo="bar"} |= "secret"`},
+			StartTime: now.Add(-3 * time.Hour),
+			EndTime:   now,
+		},
+	}}, time.Hour, notifications.Noop, nil)
+	defer mgr.Stop()
+	require.NoError(t, mgr.loadDeleteRequestsToProcess())
+
+	isExpired, intervals := mgr.Expired(chunkEntry, model.Now())
+	require.True(t, isExpired)
+	require.Len(t, intervals, 1)
+	require.Equal(t, model.Interval{Start: now.Add(-6*time.Hour) + 1, End: chunkEntry.Through}, intervals[0].Interval)
+
+	// the lines matching the filters of both requests are dropped from the interval retained.
+	for _, tc := range []struct {
+		ts       model.Time
+		line     string
+		filtered bool
+	}{
+		{ts: now.Add(-5 * time.Hour), line: "password", filtered: true},
+		{ts: now.Add(-5 * time.Hour), line: "secret", filtered: false},
+		{ts: now.Add(-2 * time.Hour), line: "secret", filtered: true},
+		{ts: now.Add(-2 * time.Hour), line: "hello", filtered: false},
+	} {
+		require.Equal(t, tc.filtered, intervals[0].Filter(tc.ts.Time(), tc.line), tc.line)
+	}
+}
+
+type recordingNotifier struct {
+	events []notifications.Event
+}
+
+func (n *recordingNotifier) Notify(e notifications.Event) {
+	n.events = append(n.events, e)
+}
+
+func TestDeleteRequestsManager_NotifiesCompletedRequests(t *testing.T) {
+	now := model.Now()
+	notifier := &recordingNotifier{}
+	mgr := NewDeleteRequestsManager(mockDeleteRequestsStore{deleteRequests: []DeleteRequest{
+		{
+			RequestID: "1",
+			UserID:    testUserID,
+			Selectors: []string{`{foo="bar"}`},
+			StartTime: now.Add(-24 * time.Hour),
+			EndTime:   now.Add(-12 * time.Hour),
+			CreatedAt: now.Add(-48 * time.Hour),
+		},
+	}}, time.Hour, notifier, nil)
+	require.NoError(t, mgr.loadDeleteRequestsToProcess())
+
+	mgr.MarkPhaseStarted()
+	require.Empty(t, notifier.events)
+
+	mgr.MarkPhaseFinished()
+	require.Len(t, notifier.events, 1)
+	require.Equal(t, notifications.DeleteRequestCompleted, notifier.events[0].Type)
+	require.Equal(t, testUserID, notifier.events[0].Tenant)
+	require.Equal(t, "1", notifier.events[0].Details["request_id"])
+	require.Equal(t, `{foo="bar"}`, notifier.events[0].Details["selectors"])
+}
+
+func TestDeleteRequestsManager_AuditsRemovedLines(t *testing.T) {
+	now := model.Now()
+	store := mockDeleteRequestsStore{
+		deleteRequests: []DeleteRequest{
+			{
+				RequestID: "1",
+				UserID:    testUserID,
+				Selectors: []string{`{foo="bar"}`},
+				StartTime: now.Add(-24 * time.Hour),
+				EndTime:   now,
+			},
+			{
+				RequestID: "2",
+				UserID:    testUserID,
+				Selectors: []string{`{foo="bar", fizz="buzz"}`},
+				StartTime: now.Add(-24 * time.Hour),
+				EndTime:   now,
+			},
+		},
+		audits: map[string][]StreamRemoval{},
+	}
+	mgr := NewDeleteRequestsManager(store, time.Hour, notifications.Noop, nil)
+	defer mgr.Stop()
+	require.NoError(t, mgr.loadDeleteRequestsToProcess())
+
+	chunkEntry := func(lbls string) retention.ChunkEntry {
+		return retention.ChunkEntry{
+			ChunkRef: retention.ChunkRef{
+				UserID:  []byte(testUserID),
+				From:    now.Add(-12 * time.Hour),
+				Through: now.Add(-time.Hour),
+			},
+			Labels: mustParseLabel(lbls),
+		}
+	}
+
+	mgr.MarkPhaseStarted()
+	require.False(t, mgr.AuditsRemoval(chunkEntry(`{fizz="buzz"}`), now))
+	require.True(t, mgr.AuditsRemoval(chunkEntry(`{foo="bar"}`), now))
+
+	mgr.RemovedLines(chunkEntry(`{foo="bar"}`), 10)
+	mgr.RemovedLines(chunkEntry(`{foo="bar"}`), 5)
+	mgr.RemovedLines(chunkEntry(`{fizz="buzz", foo="bar"}`), 3)
+	mgr.MarkPhaseFinished()
+
+	require.Equal(t, map[string][]StreamRemoval{
+		"1": {
+			{Stream: `{fizz="buzz", foo="bar"}`, Lines: 3, Chunks: 1},
+			{Stream: `{foo="bar"}`, Lines: 15, Chunks: 2},
+		},
+		"2": {
+			{Stream: `{fizz="buzz", foo="bar"}`, Lines: 3, Chunks: 1},
+		},
+	}, store.audits)
+
+	// the removals are reset once recorded.
+	mgr.MarkPhaseStarted()
+	mgr.MarkPhaseFinished()
+	require.Empty(t, store.audits["1"])
+}
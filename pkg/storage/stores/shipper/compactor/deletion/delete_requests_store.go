@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"hash/fnv"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -31,6 +32,7 @@ const (
 
 	deleteRequestID      indexType = "1"
 	deleteRequestDetails indexType = "2"
+	deleteRequestAudit   indexType = "3"
 
 	tempFileSuffix          = ".temp"
 	DeleteRequestsTableName = "delete_requests"
@@ -45,9 +47,26 @@ type DeleteRequestsStore interface {
 	UpdateStatus(ctx context.Context, userID, requestID string, newStatus DeleteRequestStatus) error
 	GetDeleteRequest(ctx context.Context, userID, requestID string) (*DeleteRequest, error)
 	RemoveDeleteRequest(ctx context.Context, userID, requestID string, createdAt, startTime, endTime model.Time) error
+	AddDeleteRequestAudit(ctx context.Context, userID, requestID string, removals []StreamRemoval) error
+	GetDeleteRequestAudit(ctx context.Context, userID, requestID string) (*DeleteRequestAudit, error)
 	Stop()
 }
 
+// StreamRemoval is the number of lines and chunks removed from a stream by a delete request.
+type StreamRemoval struct {
+	Stream string `json:"stream"`
+	Lines  int64  `json:"lines"`
+	Chunks int64  `json:"chunks"`
+}
+
+// DeleteRequestAudit is the audit trail of what a processed delete request removed.
+type DeleteRequestAudit struct {
+	RequestID string          `json:"request_id"`
+	Lines     int64           `json:"lines"`
+	Chunks    int64           `json:"chunks"`
+	Streams   []StreamRemoval `json:"streams"`
+}
+
 // deleteRequestsStore provides all the methods required to manage lifecycle of delete request and things related to it.
 type deleteRequestsStore struct {
 	indexClient chunk.IndexClient
@@ -130,7 +149,7 @@ func (ds *deleteRequestsStore) GetAllDeleteRequestsForUser(ctx context.Context,
 	return ds.queryDeleteRequests(ctx, chunk.IndexQuery{
 		TableName:        DeleteRequestsTableName,
 		HashValue:        string(deleteRequestID),
-		RangeValuePrefix: []byte(userID),
+		RangeValuePrefix: []byte(userID + ":"),
 	})
 }
 
@@ -235,6 +254,83 @@ func (ds *deleteRequestsStore) RemoveDeleteRequest(ctx context.Context, userID,
 	return ds.indexClient.BatchWrite(ctx, writeBatch)
 }
 
+// AddDeleteRequestAudit records the lines and chunks removed from the streams by a delete request, adding up with
+// the removals already recorded, by another compactor or a previous run.
+func (ds *deleteRequestsStore) AddDeleteRequestAudit(ctx context.Context, userID, requestID string, removals []StreamRemoval) error {
+	if len(removals) == 0 {
+		return nil
+	}
+
+	writeBatch := ds.indexClient.NewWriteBatch()
+	hashValue := fmt.Sprintf("%s:%s:%s", deleteRequestAudit, userID, requestID)
+	// The range values are made unique by the time of the write, for the removals not to overwrite each other.
+	recordedAt := time.Now().UnixNano()
+	for _, removal := range removals {
+		writeBatch.Add(DeleteRequestsTableName, hashValue, []byte(fmt.Sprintf("%x:%s", recordedAt, removal.Stream)),
+			[]byte(fmt.Sprintf("%x:%x", removal.Lines, removal.Chunks)))
+	}
+
+	return ds.indexClient.BatchWrite(ctx, writeBatch)
+}
+
+// GetDeleteRequestAudit returns the audit trail of a delete request, the removals of each stream being added up.
+func (ds *deleteRequestsStore) GetDeleteRequestAudit(ctx context.Context, userID, requestID string) (*DeleteRequestAudit, error) {
+	removals := map[string]*StreamRemoval{}
+	var parseError error
+	err := ds.indexClient.QueryPages(ctx, []chunk.IndexQuery{
+		{
+			TableName: DeleteRequestsTableName,
+			HashValue: fmt.Sprintf("%s:%s:%s", deleteRequestAudit, userID, requestID),
+		},
+	}, func(query chunk.IndexQuery, batch chunk.ReadBatch) (shouldContinue bool) {
+		itr := batch.Iterator()
+		for itr.Next() {
+			rangeValue := string(itr.RangeValue())
+			idx := strings.Index(rangeValue, ":")
+			if idx < 0 {
+				parseError = errors.New("invalid key in parsing delete request audit lookup response")
+				return false
+			}
+			stream := rangeValue[idx+1:]
+
+			var lines, chunks int64
+			if _, err := fmt.Sscanf(string(itr.Value()), "%x:%x", &lines, &chunks); err != nil {
+				parseError = err
+				return false
+			}
+
+			removal, ok := removals[stream]
+			if !ok {
+				removal = &StreamRemoval{Stream: stream}
+				removals[stream] = removal
+			}
+			removal.Lines += lines
+			removal.Chunks += chunks
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if parseError != nil {
+		return nil, parseError
+	}
+
+	audit := &DeleteRequestAudit{
+		RequestID: requestID,
+		Streams:   make([]StreamRemoval, 0, len(removals)),
+	}
+	for _, removal := range removals {
+		audit.Lines += removal.Lines
+		audit.Chunks += removal.Chunks
+		audit.Streams = append(audit.Streams, *removal)
+	}
+	sort.Slice(audit.Streams, func(i, j int) bool {
+		return audit.Streams[i].Stream < audit.Streams[j].Stream
+	})
+	return audit, nil
+}
+
 func parseDeleteRequestTimestamps(rangeValue []byte, deleteRequest DeleteRequest) (DeleteRequest, error) {
 	hexParts := strings.Split(string(rangeValue), ":")
 	if len(hexParts) != 3 {
@@ -154,6 +154,47 @@ func TestDeleteRequestsStore(t *testing.T) {
 	compareRequests(t, remainingRequests, deleteRequests)
 }
 
+func TestDeleteRequestsStore_Audit(t *testing.T) {
+	tempDir := t.TempDir()
+	objectClient, err := local.NewFSObjectClient(local.FSConfig{
+		Directory: filepath.Join(tempDir, "object-store"),
+	})
+	require.NoError(t, err)
+	testDeleteRequestsStore, err := NewDeleteStore(filepath.Join(tempDir, "working-dir"), storage.NewIndexStorageClient(objectClient, ""))
+	require.NoError(t, err)
+	defer testDeleteRequestsStore.Stop()
+
+	ctx := context.Background()
+	require.NoError(t, testDeleteRequestsStore.AddDeleteRequestAudit(ctx, "user1", "request1", []StreamRemoval{
+		{Stream: `{foo="bar"}`, Lines: 10, Chunks: 1},
+		{Stream: `{foo="bar", fizz="buzz"}`, Lines: 100, Chunks: 3},
+	}))
+	// the removals recorded by another run add up.
+	require.NoError(t, testDeleteRequestsStore.AddDeleteRequestAudit(ctx, "user1", "request1", []StreamRemoval{
+		{Stream: `{foo="bar"}`, Lines: 5, Chunks: 1},
+	}))
+	require.NoError(t, testDeleteRequestsStore.AddDeleteRequestAudit(ctx, "user2", "request1", []StreamRemoval{
+		{Stream: `{foo="bar"}`, Lines: 1, Chunks: 1},
+	}))
+
+	audit, err := testDeleteRequestsStore.GetDeleteRequestAudit(ctx, "user1", "request1")
+	require.NoError(t, err)
+	require.Equal(t, &DeleteRequestAudit{
+		RequestID: "request1",
+		Lines:     115,
+		Chunks:    5,
+		Streams: []StreamRemoval{
+			{Stream: `{foo="bar", fizz="buzz"}`, Lines: 100, Chunks: 3},
+			{Stream: `{foo="bar"}`, Lines: 15, Chunks: 2},
+		},
+	}, audit)
+
+	// a request without removals has an empty audit.
+	audit, err = testDeleteRequestsStore.GetDeleteRequestAudit(ctx, "user1", "request2")
+	require.NoError(t, err)
+	require.Equal(t, &DeleteRequestAudit{RequestID: "request2", Streams: []StreamRemoval{}}, audit)
+}
+
 func compareRequests(t *testing.T, expected []DeleteRequest, actual []DeleteRequest) {
 	require.Len(t, actual, len(expected))
 	sort.Slice(expected, func(i, j int) bool {
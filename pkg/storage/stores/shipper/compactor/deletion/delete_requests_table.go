@@ -65,7 +65,7 @@ func (t *deleteRequestsTable) init() error {
 
 	_, err := os.Stat(t.dbPath)
 	if err != nil {
-		err = shipper_util.DownloadFileFromStorage(t.dbPath, true,
+		err = shipper_util.DownloadFileFromStorage(t.dbPath, shipper_util.CompressionGzip,
 			true, shipper_util.LoggerWithFilename(util_log.Logger, deleteRequestsIndexFileName), func() (io.ReadCloser, error) {
 				return t.indexStorageClient.GetFile(context.Background(), DeleteRequestsTableName, deleteRequestsIndexFileName)
 			})
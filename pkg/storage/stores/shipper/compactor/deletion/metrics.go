@@ -24,6 +24,7 @@ func newDeleteRequestHandlerMetrics(r prometheus.Registerer) *deleteRequestHandl
 type deleteRequestsManagerMetrics struct {
 	deleteRequestsProcessedTotal         *prometheus.CounterVec
 	deleteRequestsChunksSelectedTotal    *prometheus.CounterVec
+	deleteRequestsRemovedLinesTotal      *prometheus.CounterVec
 	loadPendingRequestsAttemptsTotal     *prometheus.CounterVec
 	oldestPendingDeleteRequestAgeSeconds prometheus.Gauge
 	pendingDeleteRequestsCount           prometheus.Gauge
@@ -42,6 +43,11 @@ func newDeleteRequestsManagerMetrics(r prometheus.Registerer) *deleteRequestsMan
 		Name:      "compactor_delete_requests_chunks_selected_total",
 		Help:      "Number of chunks selected while building delete plans per user",
 	}, []string{"user"})
+	m.deleteRequestsRemovedLinesTotal = promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+		Namespace: "loki",
+		Name:      "compactor_delete_requests_removed_lines_total",
+		Help:      "Number of lines removed by the delete requests per user",
+	}, []string{"user"})
 	m.loadPendingRequestsAttemptsTotal = promauto.With(r).NewCounterVec(prometheus.CounterOpts{
 		Namespace: "loki",
 		Name:      "compactor_load_pending_requests_attempts_total",
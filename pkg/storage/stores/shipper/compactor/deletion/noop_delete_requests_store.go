@@ -36,4 +36,12 @@ func (d *noOpDeleteRequestsStore) RemoveDeleteRequest(ctx context.Context, userI
 	return nil
 }
 
+func (d *noOpDeleteRequestsStore) AddDeleteRequestAudit(ctx context.Context, userID, requestID string, removals []StreamRemoval) error {
+	return nil
+}
+
+func (d *noOpDeleteRequestsStore) GetDeleteRequestAudit(ctx context.Context, userID, requestID string) (*DeleteRequestAudit, error) {
+	return nil, nil
+}
+
 func (d *noOpDeleteRequestsStore) Stop() {}
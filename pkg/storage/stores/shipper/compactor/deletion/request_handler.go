@@ -8,7 +8,6 @@ import (
 	"github.com/go-kit/log/level"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/model"
-	"github.com/prometheus/prometheus/promql/parser"
 
 	"github.com/grafana/loki/pkg/tenant"
 	"github.com/grafana/loki/pkg/util"
@@ -51,7 +50,7 @@ func (dm *DeleteRequestHandler) AddDeleteRequestHandler(w http.ResponseWriter, r
 	}
 
 	for i := range match {
-		_, err := parser.ParseMetricSelector(match[i])
+		_, err := parseDeleteSelector(match[i])
 		if err != nil {
 			serverutil.JSONError(w, http.StatusBadRequest, err.Error())
 			return
@@ -108,6 +107,12 @@ func (dm *DeleteRequestHandler) GetAllDeleteRequestsHandler(w http.ResponseWrite
 		return
 	}
 
+	status := DeleteRequestStatus(r.URL.Query().Get("status"))
+	if status != "" && status != StatusReceived && status != StatusProcessed {
+		serverutil.JSONError(w, http.StatusBadRequest, "invalid status %q, it must be %q or %q", status, StatusReceived, StatusProcessed)
+		return
+	}
+
 	deleteRequests, err := dm.deleteRequestsStore.GetAllDeleteRequestsForUser(ctx, userID)
 	if err != nil {
 		level.Error(util_log.Logger).Log("msg", "error getting delete requests from the store", "err", err)
@@ -115,6 +120,10 @@ func (dm *DeleteRequestHandler) GetAllDeleteRequestsHandler(w http.ResponseWrite
 		return
 	}
 
+	if status != "" {
+		deleteRequests = filterDeleteRequestsByStatus(deleteRequests, status)
+	}
+
 	if err := json.NewEncoder(w).Encode(deleteRequests); err != nil {
 		level.Error(util_log.Logger).Log("msg", "error marshalling response", "err", err)
 		serverutil.JSONError(w, http.StatusInternalServerError, "error marshalling response: %v", err)
@@ -134,6 +143,10 @@ func (dm *DeleteRequestHandler) CancelDeleteRequestHandler(w http.ResponseWriter
 	requestID := params.Get("request_id")
 
 	deleteRequest, err := dm.deleteRequestsStore.GetDeleteRequest(ctx, userID, requestID)
+	if err == ErrDeleteRequestNotFound {
+		serverutil.JSONError(w, http.StatusNotFound, "could not find delete request with given id")
+		return
+	}
 	if err != nil {
 		level.Error(util_log.Logger).Log("msg", "error getting delete request from the store", "err", err)
 		serverutil.JSONError(w, http.StatusInternalServerError, err.Error())
@@ -163,3 +176,69 @@ func (dm *DeleteRequestHandler) CancelDeleteRequestHandler(w http.ResponseWriter
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// GetDeleteRequestAuditHandler handles get the audit trail of the delete requests processed, of the one with the
+// request_id given or of all of them.
+func (dm *DeleteRequestHandler) GetDeleteRequestAuditHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, err := tenant.TenantID(ctx)
+	if err != nil {
+		serverutil.JSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var requestIDs []string
+	if requestID := r.URL.Query().Get("request_id"); requestID != "" {
+		deleteRequest, err := dm.deleteRequestsStore.GetDeleteRequest(ctx, userID, requestID)
+		if err == ErrDeleteRequestNotFound {
+			serverutil.JSONError(w, http.StatusNotFound, "could not find delete request with given id")
+			return
+		}
+		if err != nil {
+			level.Error(util_log.Logger).Log("msg", "error getting delete request from the store", "err", err)
+			serverutil.JSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if deleteRequest == nil {
+			serverutil.JSONError(w, http.StatusNotFound, "could not find delete request with given id")
+			return
+		}
+		requestIDs = append(requestIDs, deleteRequest.RequestID)
+	} else {
+		deleteRequests, err := dm.deleteRequestsStore.GetAllDeleteRequestsForUser(ctx, userID)
+		if err != nil {
+			level.Error(util_log.Logger).Log("msg", "error getting delete requests from the store", "err", err)
+			serverutil.JSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		for _, deleteRequest := range filterDeleteRequestsByStatus(deleteRequests, StatusProcessed) {
+			requestIDs = append(requestIDs, deleteRequest.RequestID)
+		}
+	}
+
+	audits := make([]*DeleteRequestAudit, 0, len(requestIDs))
+	for _, requestID := range requestIDs {
+		audit, err := dm.deleteRequestsStore.GetDeleteRequestAudit(ctx, userID, requestID)
+		if err != nil {
+			level.Error(util_log.Logger).Log("msg", "error getting delete request audit from the store", "err", err)
+			serverutil.JSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		audits = append(audits, audit)
+	}
+
+	if err := json.NewEncoder(w).Encode(audits); err != nil {
+		level.Error(util_log.Logger).Log("msg", "error marshalling response", "err", err)
+		serverutil.JSONError(w, http.StatusInternalServerError, "error marshalling response: %v", err)
+	}
+}
+
+func filterDeleteRequestsByStatus(deleteRequests []DeleteRequest, status DeleteRequestStatus) []DeleteRequest {
+	filtered := make([]DeleteRequest, 0, len(deleteRequests))
+	for _, deleteRequest := range deleteRequests {
+		if deleteRequest.Status == status {
+			filtered = append(filtered, deleteRequest)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,108 @@
+package deletion
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/loki/pkg/storage/chunk/local"
+	"github.com/grafana/loki/pkg/storage/stores/shipper/storage"
+)
+
+func TestDeleteRequestHandler(t *testing.T) {
+	tempDir := t.TempDir()
+	objectClient, err := local.NewFSObjectClient(local.FSConfig{
+		Directory: filepath.Join(tempDir, "object-store"),
+	})
+	require.NoError(t, err)
+	store, err := NewDeleteStore(filepath.Join(tempDir, "working-dir"), storage.NewIndexStorageClient(objectClient, ""))
+	require.NoError(t, err)
+	defer store.Stop()
+
+	ctx := user.InjectOrgID(context.Background(), "user1")
+	now := model.Now()
+	for _, userID := range []string{"user1", "user10"} {
+		require.NoError(t, store.AddDeleteRequest(context.Background(), userID, now.Add(-time.Hour), now, []string{`{foo="bar"}`}))
+	}
+	require.NoError(t, store.AddDeleteRequest(ctx, "user1", now.Add(-2*time.Hour), now, []string{`{fizz="buzz"}`}))
+
+	deleteRequests, err := store.GetAllDeleteRequestsForUser(ctx, "user1")
+	require.NoError(t, err)
+	require.Len(t, deleteRequests, 2)
+	processed, pending := deleteRequests[0], deleteRequests[1]
+	require.NoError(t, store.UpdateStatus(ctx, "user1", processed.RequestID, StatusProcessed))
+	require.NoError(t, store.AddDeleteRequestAudit(ctx, "user1", processed.RequestID, []StreamRemoval{{Stream: `{foo="bar"}`, Lines: 10, Chunks: 1}}))
+
+	handler := NewDeleteRequestHandler(store, time.Hour, nil)
+	do := func(h http.HandlerFunc, method, url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h(w, httptest.NewRequest(method, url, nil).WithContext(ctx))
+		return w
+	}
+
+	t.Run("list by status", func(t *testing.T) {
+		for status, expected := range map[string][]string{
+			"":                      {processed.RequestID, pending.RequestID},
+			string(StatusReceived):  {pending.RequestID},
+			string(StatusProcessed): {processed.RequestID},
+		} {
+			w := do(handler.GetAllDeleteRequestsHandler, http.MethodGet, "/loki/api/admin/delete?status="+status)
+			require.Equal(t, http.StatusOK, w.Code)
+
+			var deleteRequests []DeleteRequest
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &deleteRequests))
+			requestIDs := []string{}
+			for _, deleteRequest := range deleteRequests {
+				requestIDs = append(requestIDs, deleteRequest.RequestID)
+			}
+			require.ElementsMatch(t, expected, requestIDs, status)
+		}
+
+		w := do(handler.GetAllDeleteRequestsHandler, http.MethodGet, "/loki/api/admin/delete?status=canceled")
+		require.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("audit", func(t *testing.T) {
+		expected := []DeleteRequestAudit{{
+			RequestID: processed.RequestID,
+			Lines:     10,
+			Chunks:    1,
+			Streams:   []StreamRemoval{{Stream: `{foo="bar"}`, Lines: 10, Chunks: 1}},
+		}}
+		for _, url := range []string{"/loki/api/admin/delete_audit", "/loki/api/admin/delete_audit?request_id=" + processed.RequestID} {
+			w := do(handler.GetDeleteRequestAuditHandler, http.MethodGet, url)
+			require.Equal(t, http.StatusOK, w.Code)
+
+			var audits []DeleteRequestAudit
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &audits))
+			require.Equal(t, expected, audits, url)
+		}
+
+		w := do(handler.GetDeleteRequestAuditHandler, http.MethodGet, "/loki/api/admin/delete_audit?request_id=unknown")
+		require.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		w := do(handler.CancelDeleteRequestHandler, http.MethodPost, "/loki/api/admin/cancel_delete_request?request_id=unknown")
+		require.Equal(t, http.StatusNotFound, w.Code)
+
+		w = do(handler.CancelDeleteRequestHandler, http.MethodPost, "/loki/api/admin/cancel_delete_request?request_id="+processed.RequestID)
+		require.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = do(handler.CancelDeleteRequestHandler, http.MethodPost, "/loki/api/admin/cancel_delete_request?request_id="+pending.RequestID)
+		require.Equal(t, http.StatusNoContent, w.Code)
+
+		deleteRequests, err := store.GetAllDeleteRequestsForUser(ctx, "user1")
+		require.NoError(t, err)
+		require.Len(t, deleteRequests, 1)
+		require.Equal(t, processed.RequestID, deleteRequests[0].RequestID)
+	})
+}
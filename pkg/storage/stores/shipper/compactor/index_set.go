@@ -27,6 +27,7 @@ type indexSet struct {
 	tableName, userID string
 	workingDir        string
 	baseIndexSet      storage.IndexSet
+	compression       string
 
 	compactedDBRecreated bool
 	uploadCompactedDB    bool
@@ -41,7 +42,7 @@ type indexSet struct {
 
 // newCommonIndex initializes a new index set for common index. It simply creates instance of indexSet without any processing.
 func newCommonIndex(ctx context.Context, tableName, workingDir string, compactedDB *bbolt.DB, uploadCompactedDB bool,
-	sourceFiles []storage.IndexFile, removeSourceFiles bool, baseCommonIndexSet storage.IndexSet, logger log.Logger) (*indexSet, error) {
+	sourceFiles []storage.IndexFile, removeSourceFiles bool, baseCommonIndexSet storage.IndexSet, compression string, logger log.Logger) (*indexSet, error) {
 	if baseCommonIndexSet.IsUserBasedIndexSet() {
 		return nil, fmt.Errorf("base index set is not for common index")
 	}
@@ -51,6 +52,7 @@ func newCommonIndex(ctx context.Context, tableName, workingDir string, compacted
 		tableName:           tableName,
 		workingDir:          workingDir,
 		baseIndexSet:        baseCommonIndexSet,
+		compression:         compression,
 		compactedDB:         compactedDB,
 		uploadCompactedDB:   uploadCompactedDB,
 		sourceObjects:       sourceFiles,
@@ -64,7 +66,8 @@ func newCommonIndex(ctx context.Context, tableName, workingDir string, compacted
 }
 
 // newUserIndex intializes a new index set for user index. Other than creating instance of indexSet, it also compacts down the source index.
-func newUserIndex(ctx context.Context, tableName, userID string, baseUserIndexSet storage.IndexSet, workingDir string, logger log.Logger) (*indexSet, error) {
+func newUserIndex(ctx context.Context, tableName, userID string, baseUserIndexSet storage.IndexSet, workingDir, compression string,
+	logger log.Logger) (*indexSet, error) {
 	if !baseUserIndexSet.IsUserBasedIndexSet() {
 		return nil, fmt.Errorf("base index set is not for user index")
 	}
@@ -79,6 +82,7 @@ func newUserIndex(ctx context.Context, tableName, userID string, baseUserIndexSe
 		userID:       userID,
 		workingDir:   workingDir,
 		baseIndexSet: baseUserIndexSet,
+		compression:  compression,
 		logger:       log.With(logger, "user-id", userID),
 		ready:        make(chan struct{}),
 	}
@@ -108,7 +112,7 @@ func (is *indexSet) initUserIndexSet(workingDir string) {
 			seedFileIdx = 0
 		}
 		compactedDBName = filepath.Join(workingDir, is.sourceObjects[seedFileIdx].Name)
-		is.err = shipper_util.DownloadFileFromStorage(compactedDBName, shipper_util.IsCompressedFile(is.sourceObjects[seedFileIdx].Name),
+		is.err = shipper_util.DownloadFileFromStorage(compactedDBName, shipper_util.FileCompression(is.sourceObjects[seedFileIdx].Name),
 			false, shipper_util.LoggerWithFilename(is.logger, is.sourceObjects[seedFileIdx].Name),
 			func() (io.ReadCloser, error) {
 				return is.baseIndexSet.GetFile(ctx, is.tableName, is.userID, is.sourceObjects[seedFileIdx].Name)
@@ -135,7 +139,7 @@ func (is *indexSet) initUserIndexSet(workingDir string) {
 		}
 		downloadAt := filepath.Join(workingDir, object.Name)
 
-		is.err = shipper_util.DownloadFileFromStorage(downloadAt, shipper_util.IsCompressedFile(object.Name),
+		is.err = shipper_util.DownloadFileFromStorage(downloadAt, shipper_util.FileCompression(object.Name),
 			false, shipper_util.LoggerWithFilename(is.logger, object.Name),
 			func() (io.ReadCloser, error) {
 				return is.baseIndexSet.GetFile(ctx, is.tableName, is.userID, object.Name)
@@ -264,13 +268,13 @@ func (is *indexSet) upload() error {
 
 	is.compactedDB = nil
 
-	fileNameFormat := "%s.gz"
+	fileName := shipper_util.BuildIndexFileName(is.tableName, uploaderName, fmt.Sprint(time.Now().Unix()))
 	if is.compactedDBRecreated {
-		fileNameFormat = "%s" + recreatedCompactedDBSuffix
+		fileName += recreatedCompactedDBSuffix
 	}
-	fileName := fmt.Sprintf(fileNameFormat, shipper_util.BuildIndexFileName(is.tableName, uploaderName, fmt.Sprint(time.Now().Unix())))
+	fileName = shipper_util.CompressedFileName(fileName, is.compression)
 
-	return uploadFile(compactedDBPath, func(file io.ReadSeeker) error {
+	return uploadFile(compactedDBPath, is.compression, func(file io.ReadSeeker) error {
 		return is.baseIndexSet.PutFile(is.ctx, is.tableName, is.userID, fileName, file)
 	}, is.logger)
 }
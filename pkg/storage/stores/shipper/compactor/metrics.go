@@ -16,6 +16,13 @@ type metrics struct {
 	compactTablesOperationLastSuccess     prometheus.Gauge
 	applyRetentionLastSuccess             prometheus.Gauge
 	compactorRunning                      prometheus.Gauge
+	scrubbedChunksTotal                   *prometheus.CounterVec
+	quarantinedChunksTotal                *prometheus.CounterVec
+	chunkScrubLastSuccess                 prometheus.Gauge
+	bloomBuiltChunksTotal                 *prometheus.CounterVec
+	bloomBuildLastSuccess                 prometheus.Gauge
+	orphanedChunksTotal                   *prometheus.CounterVec
+	orphanScrubLastSuccess                prometheus.Gauge
 }
 
 func newMetrics(r prometheus.Registerer) *metrics {
@@ -45,6 +52,41 @@ func newMetrics(r prometheus.Registerer) *metrics {
 			Name:      "compactor_running",
 			Help:      "Value will be 1 if compactor is currently running on this instance",
 		}),
+		scrubbedChunksTotal: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "loki_boltdb_shipper",
+			Name:      "compactor_scrubbed_chunks_total",
+			Help:      "Total number of chunks verified by the chunk scrubber, by status",
+		}, []string{"status"}),
+		quarantinedChunksTotal: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "loki_boltdb_shipper",
+			Name:      "compactor_quarantined_chunks_total",
+			Help:      "Total number of corrupt chunks quarantined by the chunk scrubber, by reason",
+		}, []string{"reason"}),
+		chunkScrubLastSuccess: promauto.With(r).NewGauge(prometheus.GaugeOpts{
+			Namespace: "loki_boltdb_shipper",
+			Name:      "compactor_chunk_scrub_last_successful_run_timestamp_seconds",
+			Help:      "Unix timestamp of the last successful run of the chunk scrubber",
+		}),
+		bloomBuiltChunksTotal: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "loki_boltdb_shipper",
+			Name:      "compactor_bloom_built_chunks_total",
+			Help:      "Total number of chunks of which the bloom builder built the blooms, by status",
+		}, []string{"status"}),
+		bloomBuildLastSuccess: promauto.With(r).NewGauge(prometheus.GaugeOpts{
+			Namespace: "loki_boltdb_shipper",
+			Name:      "compactor_bloom_build_last_successful_run_timestamp_seconds",
+			Help:      "Unix timestamp of the last successful run of the bloom builder",
+		}),
+		orphanedChunksTotal: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "loki_boltdb_shipper",
+			Name:      "compactor_orphaned_chunks_total",
+			Help:      "Total number of orphaned chunks found by the orphan scrubber, by status: orphaned when only reported, deleted or failure to delete",
+		}, []string{"status"}),
+		orphanScrubLastSuccess: promauto.With(r).NewGauge(prometheus.GaugeOpts{
+			Namespace: "loki_boltdb_shipper",
+			Name:      "compactor_orphan_scrub_last_successful_run_timestamp_seconds",
+			Help:      "Unix timestamp of the last successful run of the orphan scrubber",
+		}),
 	}
 
 	return &m
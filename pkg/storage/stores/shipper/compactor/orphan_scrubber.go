@@ -0,0 +1,249 @@
+package compactor
+
+import (
+	"context"
+	"encoding/base64"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"golang.org/x/time/rate"
+
+	loki_storage "github.com/grafana/loki/pkg/storage"
+	"github.com/grafana/loki/pkg/storage/chunk"
+	"github.com/grafana/loki/pkg/storage/chunk/objectclient"
+	chunk_util "github.com/grafana/loki/pkg/storage/chunk/util"
+	"github.com/grafana/loki/pkg/storage/stores/shipper/compactor/retention"
+	shipper_storage "github.com/grafana/loki/pkg/storage/stores/shipper/storage"
+	util_log "github.com/grafana/loki/pkg/util/log"
+)
+
+const (
+	orphanStatusOrphaned = "orphaned"
+	orphanStatusDeleted  = "deleted"
+	orphanStatusFailure  = "failure"
+
+	// orphanMinSchemaVersion is the first schema version of which the chunk keys are prefixed by their user.
+	orphanMinSchemaVersion = 12
+)
+
+// orphanScrubber cross-checks the chunks of the object store starting in a compacted table with the chunks of
+// its index at every run, the tables being scrubbed in turn. The chunks no index entry references anymore, e.g.
+// left behind by crashed flushes, are reported and, unless in dry-run, deleted.
+type orphanScrubber struct {
+	workingDir         string
+	minAge             time.Duration
+	delete             bool
+	schemaConfig       loki_storage.SchemaConfig
+	indexStorageClient shipper_storage.Client
+	objectClient       chunk.ObjectClient
+	keyEncoder         objectclient.KeyEncoder
+	limiter            *rate.Limiter
+	metrics            *metrics
+	logger             log.Logger
+	now                func() time.Time
+	// ownsTable filters the tables scrubbed, all of them when nil.
+	ownsTable func(tableName string) bool
+
+	lastTable string
+}
+
+func newOrphanScrubber(cfg Config, schemaConfig loki_storage.SchemaConfig, indexStorageClient shipper_storage.Client,
+	objectClient chunk.ObjectClient, keyEncoder objectclient.KeyEncoder, metrics *metrics) (*orphanScrubber, error) {
+	workingDir := filepath.Join(cfg.WorkingDirectory, "orphans")
+	if err := chunk_util.EnsureDirectory(workingDir); err != nil {
+		return nil, err
+	}
+	return &orphanScrubber{
+		workingDir:         workingDir,
+		minAge:             cfg.OrphanScrubberMinAge,
+		delete:             cfg.OrphanScrubberDelete,
+		schemaConfig:       schemaConfig,
+		indexStorageClient: indexStorageClient,
+		objectClient:       objectClient,
+		keyEncoder:         keyEncoder,
+		limiter:            rate.NewLimiter(rate.Limit(cfg.OrphanScrubberDeleteRateLimit), 1),
+		metrics:            metrics,
+		logger:             log.With(util_log.Logger, "component", "orphan-scrubber"),
+		now:                time.Now,
+	}, nil
+}
+
+// run scrubs a table at every interval until the context is done.
+func (s *orphanScrubber) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.scrubNextTable(ctx); err != nil && ctx.Err() == nil {
+				level.Error(s.logger).Log("msg", "failed to scrub the orphaned chunks", "err", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// scrubNextTable scrubs the table following the last one scrubbed, the tables being sorted by name.
+func (s *orphanScrubber) scrubNextTable(ctx context.Context) error {
+	tables, err := s.indexStorageClient.ListTables(ctx)
+	if err != nil {
+		return err
+	}
+
+	// only the tables not written anymore, and of a period of the schema with user prefixed chunk keys, are scrubbed.
+	maxEnd := s.now().Add(-scrubMinTableAge)
+	eligible := tables[:0]
+	for _, table := range tables {
+		if retention.ExtractIntervalFromTableName(table).End.Time().After(maxEnd) {
+			continue
+		}
+		period, ok := retention.SchemaPeriodForTable(s.schemaConfig, table)
+		if !ok {
+			continue
+		}
+		if v, err := period.VersionAsInt(); err != nil || v < orphanMinSchemaVersion {
+			continue
+		}
+		if s.ownsTable != nil && !s.ownsTable(table) {
+			continue
+		}
+		eligible = append(eligible, table)
+	}
+	if len(eligible) == 0 {
+		return nil
+	}
+	sort.Strings(eligible)
+
+	next := eligible[0]
+	if i := sort.SearchStrings(eligible, s.lastTable+"\x00"); i < len(eligible) {
+		next = eligible[i]
+	}
+	s.lastTable = next
+	return s.scrubTable(ctx, next)
+}
+
+// scrubTable lists the chunks of the users of the table starting in its interval, and reports or deletes the ones
+// its index doesn't reference. The chunks overlapping several tables are scrubbed with the table they start in.
+func (s *orphanScrubber) scrubTable(ctx context.Context, tableName string) error {
+	start := s.now()
+	logger := log.With(s.logger, "table-name", tableName, "dry-run", !s.delete)
+
+	// the hashes of the referenced chunks, a collision only hiding an orphaned chunk.
+	referenced := map[uint64]struct{}{}
+	users := map[string]struct{}{}
+	if err := forEachTableChunk(ctx, s.workingDir, s.indexStorageClient, s.schemaConfig, tableName, s.logger, func(entry retention.ChunkEntry) {
+		referenced[xxhash.Sum64(entry.ChunkID)] = struct{}{}
+		if _, ok := users[string(entry.UserID)]; !ok {
+			users[string(entry.UserID)] = struct{}{}
+		}
+	}); err != nil {
+		return errors.Wrap(err, "failed to list the chunks of the table")
+	}
+
+	period, _ := retention.SchemaPeriodForTable(s.schemaConfig, tableName)
+	interval := retention.ExtractIntervalFromTableName(tableName)
+	maxModifiedAt := s.now().Add(-s.minAge)
+
+	listed, orphaned, deleted := 0, 0, 0
+	for userID := range users {
+		for _, prefix := range s.chunkKeyPrefixes(period, userID, interval) {
+			objects, _, err := s.objectClient.List(ctx, prefix, "")
+			if err != nil {
+				return errors.Wrap(err, "failed to list the chunks of the object store")
+			}
+			for _, object := range objects {
+				c, externalKey, ok := s.parseObjectKey(userID, object.Key)
+				if !ok || c.From < interval.Start || c.From > interval.End {
+					continue
+				}
+				listed++
+				if _, ok := referenced[xxhash.Sum64String(externalKey)]; ok {
+					continue
+				}
+				// the chunks of the flushes in flight aren't indexed yet, the objects without modification time
+				// are never known to be old enough.
+				if object.ModifiedAt.IsZero() || object.ModifiedAt.After(maxModifiedAt) {
+					continue
+				}
+
+				orphaned++
+				if !s.delete {
+					s.metrics.orphanedChunksTotal.WithLabelValues(orphanStatusOrphaned).Inc()
+					level.Info(logger).Log("msg", "found orphaned chunk", "key", object.Key, "user-id", userID, "modified-at", object.ModifiedAt)
+					continue
+				}
+				if err := s.limiter.Wait(ctx); err != nil {
+					return err
+				}
+				if err := s.objectClient.DeleteObject(ctx, object.Key); err != nil && !s.objectClient.IsObjectNotFoundErr(err) {
+					s.metrics.orphanedChunksTotal.WithLabelValues(orphanStatusFailure).Inc()
+					level.Warn(logger).Log("msg", "failed to delete orphaned chunk", "key", object.Key, "err", err)
+					continue
+				}
+				deleted++
+				s.metrics.orphanedChunksTotal.WithLabelValues(orphanStatusDeleted).Inc()
+				level.Info(logger).Log("msg", "deleted orphaned chunk", "key", object.Key, "user-id", userID, "modified-at", object.ModifiedAt)
+			}
+		}
+	}
+
+	s.metrics.orphanScrubLastSuccess.SetToCurrentTime()
+	level.Info(logger).Log("msg", "scrubbed orphaned chunks", "users", len(users), "listed", listed, "orphaned", orphaned, "deleted", deleted, "duration", time.Since(start))
+	return nil
+}
+
+// chunkKeyPrefixes returns the prefixes of the keys of the chunks of the user starting in the interval: the ones of
+// the chunk key periods of the interval with schema v13+, the one of the user otherwise.
+func (s *orphanScrubber) chunkKeyPrefixes(period chunk.PeriodConfig, userID string, interval model.Interval) []string {
+	if v, _ := period.VersionAsInt(); v < 13 {
+		return []string{userID + "/"}
+	}
+	var prefixes []string
+	for t := interval.Start; t <= interval.End; t = t.Add(time.Hour) {
+		prefix := s.schemaConfig.ChunkKeyPrefix(period, userID, t)
+		if len(prefixes) == 0 || prefixes[len(prefixes)-1] != prefix {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	return prefixes
+}
+
+// parseObjectKey parses the key of an object of the store as the one of a chunk of the user. It returns the chunk
+// and its external key, and false when the object isn't a chunk stored with the key of its external key.
+func (s *orphanScrubber) parseObjectKey(userID, key string) (chunk.Chunk, string, bool) {
+	c, err := chunk.ParseExternalKey(userID, key)
+	if err != nil {
+		// the filesystem key encoder encodes the last part of the keys in base64.
+		i := strings.LastIndexByte(key, '/')
+		tail, err := base64.StdEncoding.DecodeString(key[i+1:])
+		if err != nil {
+			return chunk.Chunk{}, "", false
+		}
+		if c, err = chunk.ParseExternalKey(userID, key[:i+1]+string(tail)); err != nil {
+			return chunk.Chunk{}, "", false
+		}
+	}
+	if c.Contained() {
+		return chunk.Chunk{}, "", false
+	}
+
+	externalKey := s.schemaConfig.ExternalKey(c)
+	objectKey := externalKey
+	if s.keyEncoder != nil {
+		objectKey = s.keyEncoder(s.schemaConfig.SchemaConfig, c)
+	}
+	// never deletes an object which only looks like a chunk.
+	if objectKey != key {
+		return chunk.Chunk{}, "", false
+	}
+	return c, externalKey, true
+}
@@ -0,0 +1,125 @@
+package compactor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+
+	loki_storage "github.com/grafana/loki/pkg/storage"
+	"github.com/grafana/loki/pkg/storage/chunk"
+	"github.com/grafana/loki/pkg/storage/chunk/objectclient"
+	shipper_storage "github.com/grafana/loki/pkg/storage/stores/shipper/storage"
+)
+
+var orphanSchemaCfg = loki_storage.SchemaConfig{SchemaConfig: chunk.SchemaConfig{Configs: []chunk.PeriodConfig{{
+	From:        chunk.DayTime{Time: 0},
+	IndexType:   "boltdb-shipper",
+	ObjectType:  "filesystem",
+	Schema:      "v13",
+	IndexTables: chunk.PeriodicTableConfig{Prefix: "index_", Period: 24 * time.Hour},
+	RowShards:   16,
+}}}}
+
+func (s *scrubberTestStore) newOrphanScrubber(minAge time.Duration, deleteChunks bool) *orphanScrubber {
+	scrubber, err := newOrphanScrubber(Config{
+		WorkingDirectory:              filepath.Join(s.dir, "compactor"),
+		OrphanScrubberMinAge:          minAge,
+		OrphanScrubberDelete:          deleteChunks,
+		OrphanScrubberDeleteRateLimit: 1000,
+	}, s.schemaCfg, shipper_storage.NewIndexStorageClient(s.objectClient, "index/"), s.objectClient, objectclient.FSEncoder, newMetrics(nil))
+	require.NoError(s.t, err)
+	return scrubber
+}
+
+// age sets the modification time of the object of the key.
+func (s *scrubberTestStore) age(key string, modifiedAt time.Time) {
+	require.NoError(s.t, os.Chtimes(filepath.Join(s.dir, "store", filepath.FromSlash(key)), modifiedAt, modifiedAt))
+}
+
+func TestOrphanScrubber(t *testing.T) {
+	s := newScrubberTestStoreWithSchema(t, orphanSchemaCfg)
+	ctx := context.Background()
+
+	day := time.Now().Add(-72*time.Hour).Unix() / 86400
+	tableName := fmt.Sprintf("index_%d", day)
+	from := model.TimeFromUnix(day * 86400).Add(time.Hour)
+	old := time.Now().Add(-48 * time.Hour)
+
+	indexed := s.newChunk("fake", labels.Labels{{Name: "app", Value: "indexed"}}, from)
+	orphaned := s.newChunk("fake", labels.Labels{{Name: "app", Value: "orphaned"}}, from)
+	recent := s.newChunk("fake", labels.Labels{{Name: "app", Value: "recent"}}, from)
+	nextDay := s.newChunk("fake", labels.Labels{{Name: "app", Value: "next-day"}}, from.Add(24*time.Hour))
+	key := func(c chunk.Chunk) string { return objectclient.FSEncoder(s.schemaCfg.SchemaConfig, c) }
+	for _, c := range []chunk.Chunk{indexed, orphaned, nextDay} {
+		s.age(key(c), old)
+	}
+	// an object of the prefix of the chunks of the user which isn't a chunk.
+	other := s.schemaCfg.ChunkKeyPrefix(s.schemaCfg.Configs[0], "fake", from) + "other"
+	require.NoError(t, s.objectClient.PutObject(ctx, other, bytes.NewReader([]byte("other"))))
+	s.age(other, old)
+
+	s.writeIndex(tableName, "", "compactor-1", indexed)
+
+	exists := func(c chunk.Chunk) bool {
+		_, err := os.Stat(filepath.Join(s.dir, "store", filepath.FromSlash(key(c))))
+		return err == nil
+	}
+
+	// the dry-run only reports the orphaned chunk.
+	dryRun := s.newOrphanScrubber(24*time.Hour, false)
+	require.NoError(t, dryRun.scrubNextTable(ctx))
+	require.Equal(t, tableName, dryRun.lastTable)
+	require.Equal(t, float64(1), testutil.ToFloat64(dryRun.metrics.orphanedChunksTotal.WithLabelValues(orphanStatusOrphaned)))
+	require.Equal(t, float64(0), testutil.ToFloat64(dryRun.metrics.orphanedChunksTotal.WithLabelValues(orphanStatusDeleted)))
+	require.True(t, exists(orphaned))
+
+	scrubber := s.newOrphanScrubber(24*time.Hour, true)
+	require.NoError(t, scrubber.scrubNextTable(ctx))
+	require.Equal(t, float64(1), testutil.ToFloat64(scrubber.metrics.orphanedChunksTotal.WithLabelValues(orphanStatusDeleted)))
+	require.False(t, exists(orphaned))
+	for _, c := range []chunk.Chunk{indexed, recent, nextDay} {
+		require.True(t, exists(c))
+	}
+	_, err := os.Stat(filepath.Join(s.dir, "store", other))
+	require.NoError(t, err)
+}
+
+func TestOrphanScrubber_SkipsSchemasWithoutUserPrefix(t *testing.T) {
+	s := newScrubberTestStore(t)
+	ctx := context.Background()
+
+	day := time.Now().Add(-72*time.Hour).Unix() / 86400
+	s.writeIndex(fmt.Sprintf("index_%d", day), "", "compactor-1", s.newChunk("fake", labels.Labels{{Name: "app", Value: "foo"}}, model.TimeFromUnix(day*86400)))
+
+	scrubber := s.newOrphanScrubber(time.Nanosecond, true)
+	require.NoError(t, scrubber.scrubNextTable(ctx))
+	require.Empty(t, scrubber.lastTable)
+}
+
+func TestOrphanScrubber_parseObjectKey(t *testing.T) {
+	s := newScrubberTestStoreWithSchema(t, orphanSchemaCfg)
+	scrubber := s.newOrphanScrubber(time.Hour, false)
+
+	c := s.newChunk("fake", labels.Labels{{Name: "app", Value: "foo"}}, model.TimeFromUnix(86400))
+	parsed, externalKey, ok := scrubber.parseObjectKey("fake", objectclient.FSEncoder(s.schemaCfg.SchemaConfig, c))
+	require.True(t, ok)
+	require.Equal(t, s.schemaCfg.ExternalKey(c), externalKey)
+	require.Equal(t, c.From, parsed.From)
+
+	// the key of another user, and the unencoded key with the filesystem encoder.
+	_, _, ok = scrubber.parseObjectKey("other", objectclient.FSEncoder(s.schemaCfg.SchemaConfig, c))
+	require.False(t, ok)
+	_, _, ok = scrubber.parseObjectKey("fake", s.schemaCfg.ExternalKey(c))
+	require.False(t, ok)
+	_, _, ok = scrubber.parseObjectKey("fake", s.schemaCfg.ContainerKey("fake", "abc"))
+	require.False(t, ok)
+}
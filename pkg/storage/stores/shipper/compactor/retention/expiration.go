@@ -8,12 +8,20 @@ import (
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/labels"
 
+	"github.com/grafana/loki/pkg/storage/chunk"
+	"github.com/grafana/loki/pkg/util/filter"
 	util_log "github.com/grafana/loki/pkg/util/log"
 	"github.com/grafana/loki/pkg/validation"
 )
 
+// IntervalFilter is an interval of a chunk to retain, along with the filter of the lines to drop from it if set.
+type IntervalFilter struct {
+	Interval model.Interval
+	Filter   filter.Func
+}
+
 type ExpirationChecker interface {
-	Expired(ref ChunkEntry, now model.Time) (bool, []model.Interval)
+	Expired(ref ChunkEntry, now model.Time) (bool, []IntervalFilter)
 	IntervalMayHaveExpiredChunks(interval model.Interval, userID string) bool
 	MarkPhaseStarted()
 	MarkPhaseFailed()
@@ -21,6 +29,14 @@ type ExpirationChecker interface {
 	DropFromIndex(ref ChunkEntry, tableEndTime model.Time, now model.Time) bool
 }
 
+// RemovalAuditor is implemented by the ExpirationCheckers auditing the lines removed from the chunks they expire.
+type RemovalAuditor interface {
+	// AuditsRemoval tells whether the lines removed from the chunk expired at now are audited.
+	AuditsRemoval(ref ChunkEntry, now model.Time) bool
+	// RemovedLines records the number of lines removed from the expired chunk, all of its lines when it is deleted.
+	RemovedLines(ref ChunkEntry, lines int)
+}
+
 type expirationChecker struct {
 	tenantsRetention         *TenantsRetention
 	latestRetentionStartTime latestRetentionStartTime
@@ -40,7 +56,7 @@ func NewExpirationChecker(limits Limits) ExpirationChecker {
 }
 
 // Expired tells if a ref chunk is expired based on retention rules.
-func (e *expirationChecker) Expired(ref ChunkEntry, now model.Time) (bool, []model.Interval) {
+func (e *expirationChecker) Expired(ref ChunkEntry, now model.Time) (bool, []IntervalFilter) {
 	userID := unsafeGetString(ref.UserID)
 	period := e.tenantsRetention.RetentionPeriodFor(userID, ref.Labels)
 	return now.Sub(ref.Through) > period, nil
@@ -81,6 +97,73 @@ func (e *expirationChecker) IntervalMayHaveExpiredChunks(interval model.Interval
 	return interval.Start.Before(latestRetentionStartTime)
 }
 
+// periodsExpirationChecker applies the retention period set on schema period configs to the data
+// of those periods, and relies on the wrapped ExpirationChecker for all the others.
+type periodsExpirationChecker struct {
+	ExpirationChecker
+	schemaCfg  chunk.SchemaConfig
+	phaseStart model.Time
+}
+
+// NewPeriodsExpirationChecker returns an ExpirationChecker which honors the retention period of schema period configs,
+// including the ones of the tenants having their own schema config.
+func NewPeriodsExpirationChecker(checker ExpirationChecker, schemaCfg chunk.SchemaConfig) ExpirationChecker {
+	for _, configs := range schemaCfg.AllConfigs() {
+		for _, cfg := range configs {
+			if cfg.RetentionPeriod > 0 {
+				return &periodsExpirationChecker{
+					ExpirationChecker: checker,
+					schemaCfg:         schemaCfg,
+				}
+			}
+		}
+	}
+	return checker
+}
+
+// periodRetention returns the retention period of the schema period config the tenant uses at t, if it has one.
+func (e *periodsExpirationChecker) periodRetention(userID string, t model.Time) (time.Duration, bool) {
+	cfg, err := e.schemaCfg.ForTenant(userID).SchemaForTime(t)
+	if err != nil || cfg.RetentionPeriod <= 0 {
+		return 0, false
+	}
+	return time.Duration(cfg.RetentionPeriod), true
+}
+
+func (e *periodsExpirationChecker) Expired(ref ChunkEntry, now model.Time) (bool, []IntervalFilter) {
+	if period, ok := e.periodRetention(unsafeGetString(ref.UserID), ref.From); ok {
+		return now.Sub(ref.Through) > period, nil
+	}
+	return e.ExpirationChecker.Expired(ref, now)
+}
+
+func (e *periodsExpirationChecker) DropFromIndex(ref ChunkEntry, tableEndTime model.Time, now model.Time) bool {
+	if period, ok := e.periodRetention(unsafeGetString(ref.UserID), tableEndTime); ok {
+		return now.Sub(tableEndTime) > period
+	}
+	return e.ExpirationChecker.DropFromIndex(ref, tableEndTime, now)
+}
+
+func (e *periodsExpirationChecker) MarkPhaseStarted() {
+	e.phaseStart = model.Now()
+	e.ExpirationChecker.MarkPhaseStarted()
+}
+
+func (e *periodsExpirationChecker) IntervalMayHaveExpiredChunks(interval model.Interval, userID string) bool {
+	if userID == "" {
+		// the interval of a whole table may hold the chunks of the tenants having their own periods.
+		for tenant := range e.schemaCfg.TenantConfigs {
+			if period, ok := e.periodRetention(tenant, interval.Start); ok && interval.Start.Before(e.phaseStart.Add(-period)) {
+				return true
+			}
+		}
+	}
+	if period, ok := e.periodRetention(userID, interval.Start); ok {
+		return interval.Start.Before(e.phaseStart.Add(-period))
+	}
+	return e.ExpirationChecker.IntervalMayHaveExpiredChunks(interval, userID)
+}
+
 type TenantsRetention struct {
 	limits Limits
 }
@@ -8,6 +8,7 @@ import (
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/stretchr/testify/require"
 
+	"github.com/grafana/loki/pkg/storage/chunk"
 	"github.com/grafana/loki/pkg/validation"
 )
 
@@ -88,6 +89,65 @@ func Test_expirationChecker_Expired(t *testing.T) {
 	}
 }
 
+func Test_periodsExpirationChecker(t *testing.T) {
+	now := model.Now()
+	periodStart := now.Add(-10 * 24 * time.Hour)
+	schemaCfg := chunk.SchemaConfig{
+		Configs: []chunk.PeriodConfig{
+			{From: chunk.DayTime{Time: 0}, RetentionPeriod: model.Duration(30 * 24 * time.Hour)},
+			{From: chunk.DayTime{Time: periodStart}},
+		},
+	}
+	e := NewPeriodsExpirationChecker(NewExpirationChecker(&fakeLimits{
+		perTenant: map[string]retentionLimit{
+			"1": {retentionPeriod: 48 * time.Hour},
+		},
+	}), schemaCfg)
+	e.MarkPhaseStarted()
+
+	for _, tc := range []struct {
+		name string
+		ref  ChunkEntry
+		want bool
+	}{
+		{"expired with the period retention", newChunkEntry("1", `{foo="bar"}`, now.Add(-40*24*time.Hour), now.Add(-31*24*time.Hour)), true},
+		{"not expired with the period retention", newChunkEntry("1", `{foo="bar"}`, now.Add(-20*24*time.Hour), now.Add(-19*24*time.Hour)), false},
+		{"expired with the tenant retention", newChunkEntry("1", `{foo="bar"}`, periodStart, now.Add(-72*time.Hour)), true},
+		{"not expired with the tenant retention", newChunkEntry("1", `{foo="bar"}`, periodStart, now.Add(-time.Hour)), false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, _ := e.Expired(tc.ref, now)
+			require.Equal(t, tc.want, actual)
+		})
+	}
+
+	require.True(t, e.IntervalMayHaveExpiredChunks(model.Interval{Start: now.Add(-31 * 24 * time.Hour), End: now.Add(-30 * 24 * time.Hour)}, ""))
+	require.False(t, e.IntervalMayHaveExpiredChunks(model.Interval{Start: now.Add(-20 * 24 * time.Hour), End: now.Add(-19 * 24 * time.Hour)}, ""))
+	require.True(t, e.DropFromIndex(ChunkEntry{}, now.Add(-31*24*time.Hour), now))
+	require.False(t, e.DropFromIndex(ChunkEntry{}, now.Add(-29*24*time.Hour), now))
+
+	// the retention of the periods of the tenants having their own schema config applies to their data only.
+	schemaCfg.TenantConfigs = map[string]*chunk.SchemaConfig{
+		"2": {Configs: []chunk.PeriodConfig{{From: chunk.DayTime{Time: 0}, RetentionPeriod: model.Duration(5 * 24 * time.Hour)}}},
+	}
+	e = NewPeriodsExpirationChecker(NewExpirationChecker(&fakeLimits{
+		perTenant: map[string]retentionLimit{
+			"1": {retentionPeriod: 10 * 24 * time.Hour},
+		},
+	}), schemaCfg)
+	e.MarkPhaseStarted()
+	expired, _ := e.Expired(newChunkEntry("2", `{foo="bar"}`, now.Add(-7*24*time.Hour), now.Add(-6*24*time.Hour)), now)
+	require.True(t, expired)
+	expired, _ = e.Expired(newChunkEntry("1", `{foo="bar"}`, now.Add(-7*24*time.Hour), now.Add(-6*24*time.Hour)), now)
+	require.False(t, expired)
+	require.True(t, e.IntervalMayHaveExpiredChunks(model.Interval{Start: now.Add(-20 * 24 * time.Hour), End: now.Add(-19 * 24 * time.Hour)}, ""))
+	require.False(t, e.IntervalMayHaveExpiredChunks(model.Interval{Start: now.Add(-20 * 24 * time.Hour), End: now.Add(-19 * 24 * time.Hour)}, "1"))
+
+	// without any period retention, the checker is used as is.
+	checker := NewExpirationChecker(&fakeLimits{})
+	require.Equal(t, checker, NewPeriodsExpirationChecker(checker, chunk.SchemaConfig{Configs: []chunk.PeriodConfig{{}}}))
+}
+
 func TestFindLatestRetentionStartTime(t *testing.T) {
 	const dayDuration = 24 * time.Hour
 	now := model.Now()
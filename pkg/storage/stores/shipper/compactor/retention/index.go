@@ -73,8 +73,10 @@ func parseChunkRef(hashKey, rangeKey []byte) (ChunkRef, bool, error) {
 	}
 	chunkID := components[len(components)-2]
 
-	userID, hexFrom, hexThrough, ok := parseChunkID(chunkID)
-	if !ok {
+	// the chunk IDs of schema v13+ start with the hash of the user ID, the hash key starts with the user ID.
+	userID := userFromHash(hashKey)
+	_, hexFrom, hexThrough, ok := parseChunkID(chunkID)
+	if !ok || len(userID) == 0 {
 		return ChunkRef{}, false, newInvalidIndexKeyError(hashKey, rangeKey)
 	}
 	from, err := strconv.ParseInt(unsafeGetString(hexFrom), 16, 64)
@@ -115,10 +117,12 @@ func parseChunkID(chunkID []byte) (userID []byte, hexFrom, hexThrough []byte, va
 		return nil, nil, nil, false
 	}
 
-	// v12+ chunk id format `<user>/<fprint>/<start>:<end>:<checksum>`
+	// v14+ chunk id format `<user hash>/<period>/<fprint>/<start>:<end>:<checksum>:<encoding>`
+	// v13+ chunk id format `<user hash>/<period>/<fprint>/<start>:<end>:<checksum>`
+	// v12 chunk id format `<user>/<fprint>/<start>:<end>:<checksum>`
 	// older than v12 chunk id format `<user id>/<fingerprint>:<start time>:<end time>:<checksum>`
-	if idx := bytes.IndexByte(hex, '/'); idx != -1 {
-		// v12+ chunk id format, let us skip through the fingerprint using '/`
+	if idx := bytes.LastIndexByte(hex, '/'); idx != -1 {
+		// v12+ chunk id format, let us skip through the period and fingerprint using '/`
 		hex = hex[idx+1:]
 	} else {
 		// older than v12 chunk id format, let us skip through the fingerprint using ':'
@@ -228,10 +232,21 @@ func validatePeriods(config storage.SchemaConfig) error {
 	return nil
 }
 
-func schemaPeriodForTable(config storage.SchemaConfig, tableName string) (chunk.PeriodConfig, bool) {
+// SchemaPeriodForTable returns the period of the schema config the daily table belongs to, looking
+// for it in the periods of the tenants having their own schema config when it isn't a global table.
+func SchemaPeriodForTable(config storage.SchemaConfig, tableName string) (chunk.PeriodConfig, bool) {
+	for _, configs := range config.AllConfigs() {
+		if period, ok := schemaPeriodForTable(configs, tableName); ok {
+			return period, true
+		}
+	}
+	return chunk.PeriodConfig{}, false
+}
+
+func schemaPeriodForTable(configs []chunk.PeriodConfig, tableName string) (chunk.PeriodConfig, bool) {
 	// first round removes configs that does not have the prefix.
 	candidates := []chunk.PeriodConfig{}
-	for _, schema := range config.Configs {
+	for _, schema := range configs {
 		if strings.HasPrefix(tableName, schema.IndexTables.Prefix) {
 			candidates = append(candidates, schema)
 		}
@@ -257,6 +272,18 @@ func schemaPeriodForTable(config storage.SchemaConfig, tableName string) (chunk.
 	return matched, found
 }
 
+// userFromHash returns the user ID of the hash key `<user>:d<day>:<series>`, the user ID can contain colons.
+func userFromHash(h []byte) (userID []byte) {
+	i := bytes.LastIndexByte(h, ':')
+	if i == -1 {
+		return nil
+	}
+	if i = bytes.LastIndexByte(h[:i], ':'); i == -1 {
+		return nil
+	}
+	return h[:i]
+}
+
 func seriesFromHash(h []byte) (seriesID []byte) {
 	var index int
 	for i := range h {
@@ -16,6 +16,12 @@ func Test_schemaPeriodForTable(t *testing.T) {
 	indexFromTime := func(t time.Time) string {
 		return fmt.Sprintf("%d", t.Unix()/int64(24*time.Hour/time.Second))
 	}
+	tenantPeriod := schemaCfg.Configs[3]
+	tenantPeriod.IndexTables.Prefix = "tenant_index_"
+	tenantSchemaCfg := schemaCfg
+	tenantSchemaCfg.TenantConfigs = map[string]*chunk.SchemaConfig{
+		"tenant": {Configs: []chunk.PeriodConfig{tenantPeriod}},
+	}
 	tests := []struct {
 		name          string
 		config        storage.SchemaConfig
@@ -29,10 +35,13 @@ func Test_schemaPeriodForTable(t *testing.T) {
 		{"second schema", schemaCfg, "index_" + indexFromTime(dayFromTime(start.Add(28*time.Hour)).Time.Time()), schemaCfg.Configs[1], true},
 		{"third schema", schemaCfg, "index_" + indexFromTime(dayFromTime(start.Add(75*time.Hour)).Time.Time()), schemaCfg.Configs[2], true},
 		{"now", schemaCfg, "index_" + indexFromTime(time.Now()), schemaCfg.Configs[3], true},
+		{"tenant table", tenantSchemaCfg, "tenant_index_" + indexFromTime(time.Now()), tenantPeriod, true},
+		{"global table with tenant configs", tenantSchemaCfg, "index_" + indexFromTime(time.Now()), schemaCfg.Configs[3], true},
+		{"tenant table out of scope", tenantSchemaCfg, "tenant_index_" + indexFromTime(start.Time().Add(-24*time.Hour)), chunk.PeriodConfig{}, false},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			actual, actualFound := schemaPeriodForTable(tt.config, tt.tableName)
+			actual, actualFound := SchemaPeriodForTable(tt.config, tt.tableName)
 			require.Equal(t, tt.expected, actual)
 			require.Equal(t, tt.expectedFound, actualFound)
 		})
@@ -70,6 +79,16 @@ func TestParseChunkID(t *testing.T) {
 				valid:   true,
 			},
 		},
+		{
+			name:    "v13+ chunk format",
+			chunkID: "fake/17636/57f628c7f6d57aad/162c699f000:162c69a07eb:eb242d99",
+			expectedResp: resp{
+				userID:  "fake",
+				from:    1523750400000,
+				through: 1523750406123,
+				valid:   true,
+			},
+		},
 		{
 			name:    "invalid format",
 			chunkID: "fake:57f628c7f6d57aad:162c699f000:162c69a07eb:eb242d99",
@@ -95,3 +114,9 @@ func TestParseChunkID(t *testing.T) {
 		})
 	}
 }
+
+func TestUserFromHash(t *testing.T) {
+	require.Equal(t, "fake", string(userFromHash([]byte("fake:d19000:c2VyaWVz"))))
+	require.Equal(t, "fake:1", string(userFromHash([]byte("fake:1:d19000:c2VyaWVz"))))
+	require.Empty(t, userFromHash([]byte("d19000:c2VyaWVz")))
+}
@@ -1,8 +1,10 @@
 package retention
 
 import (
+	"bytes"
 	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io/fs"
 	"io/ioutil"
@@ -24,10 +26,12 @@ import (
 var (
 	minListMarkDelay = time.Minute
 	maxMarkPerFile   = int64(100000)
+	// markerFileLockTimeout is how long the marks are waited for to be unmarked in a marker file being written.
+	markerFileLockTimeout = time.Second
 )
 
 type MarkerStorageWriter interface {
-	Put(chunkID []byte) error
+	Put(userID, chunkID []byte) error
 	Count() int64
 	Close() error
 }
@@ -100,7 +104,7 @@ func (m *markerStorageWriter) closeFile() error {
 	return nil
 }
 
-func (m *markerStorageWriter) Put(chunkID []byte) error {
+func (m *markerStorageWriter) Put(userID, chunkID []byte) error {
 	if m.currentFileCount > maxMarkPerFile { // roll files when max marks is reached.
 		if err := m.closeFile(); err != nil {
 			return err
@@ -116,10 +120,9 @@ func (m *markerStorageWriter) Put(chunkID []byte) error {
 		return err
 	}
 	binary.BigEndian.PutUint64(m.buf, id) // insert in order using sequence id.
-	// boltdb requires the value to be valid for the whole tx.
-	// so we make a copy.
-	value := make([]byte, len(chunkID))
-	copy(value, chunkID)
+	// boltdb requires the value to be valid for the whole tx,
+	// encoding the mark makes a copy.
+	value := encodeMark(userID, chunkID)
 	if err := m.bucket.Put(m.buf, value); err != nil {
 		return err
 	}
@@ -136,11 +139,47 @@ func (m *markerStorageWriter) Close() error {
 	return m.closeFile()
 }
 
+// markWithUserID starts the marks holding the user ID of the chunk before the chunk ID, followed by the length of the
+// user ID as an uvarint: the chunk IDs of schema v13 and later start with the hash of the user ID rather than the user ID.
+// The marks written before only hold the chunk ID, and no chunk ID starts with a zero byte.
+const markWithUserID = 0
+
+func encodeMark(userID, chunkID []byte) []byte {
+	mark := make([]byte, 1+binary.MaxVarintLen64, 1+binary.MaxVarintLen64+len(userID)+len(chunkID))
+	mark[0] = markWithUserID
+	n := binary.PutUvarint(mark[1:], uint64(len(userID)))
+	mark = append(mark[:1+n], userID...)
+	return append(mark, chunkID...)
+}
+
+// decodeMark returns the user ID and the chunk ID of the mark, the user ID of the marks holding only the chunk ID being
+// the first part of the chunk ID.
+func decodeMark(mark []byte) (userID, chunkID []byte, err error) {
+	if len(mark) > 0 && mark[0] == markWithUserID {
+		length, n := binary.Uvarint(mark[1:])
+		if n <= 0 || length == 0 || length > uint64(len(mark)-1-n) {
+			return nil, nil, fmt.Errorf("invalid mark %q", mark)
+		}
+		start := 1 + n
+		end := start + int(length)
+		return mark[start:end], mark[end:], nil
+	}
+
+	idx := bytes.IndexByte(mark, '/')
+	if idx <= 0 {
+		return nil, nil, fmt.Errorf("invalid chunk ID %q", mark)
+	}
+	return mark[:idx], mark, nil
+}
+
 type MarkerProcessor interface {
 	// Start starts parsing marks and calling deleteFunc for each.
 	// If deleteFunc returns no error the mark is deleted from the storage.
 	// Otherwise the mark will reappears in future iteration.
-	Start(deleteFunc func(ctx context.Context, chunkId []byte) error)
+	Start(deleteFunc func(ctx context.Context, userID, chunkID []byte) error)
+	// Unmark calls unmark with the chunk IDs of the marks matching, and deletes the marks if unmark returns no error.
+	// The marks aren't processed meanwhile. The marker files being written are skipped, their count is returned.
+	Unmark(ctx context.Context, match func(userID, chunkID []byte) bool, unmark func(ctx context.Context, chunkIDs [][]byte) error) (int, error)
 	// Stop stops processing marks.
 	Stop()
 }
@@ -153,6 +192,8 @@ type markerProcessor struct {
 	ctx    context.Context
 	cancel context.CancelFunc
 	wg     sync.WaitGroup
+	// mtx serializes the processing of the marker files and the unmarking.
+	mtx sync.Mutex
 
 	sweeperMetrics *sweeperMetrics
 }
@@ -174,7 +215,7 @@ func newMarkerStorageReader(workingDir string, maxParallelism int, minAgeFile ti
 	}, nil
 }
 
-func (r *markerProcessor) Start(deleteFunc func(ctx context.Context, chunkId []byte) error) {
+func (r *markerProcessor) Start(deleteFunc func(ctx context.Context, userID, chunkID []byte) error) {
 	level.Info(util_log.Logger).Log("msg", "mark processor started", "workers", r.maxParallelism, "delay", r.minAgeFile)
 	r.wg.Wait() // only one start at a time.
 	r.wg.Add(1)
@@ -210,7 +251,9 @@ func (r *markerProcessor) Start(deleteFunc func(ctx context.Context, chunkId []b
 					return
 				}
 				r.sweeperMetrics.markerFileCurrentTime.Set(float64(times[i].UnixNano()) / 1e9)
+				r.mtx.Lock()
 				if err := r.processPath(path, deleteFunc); err != nil {
+					r.mtx.Unlock()
 					level.Warn(util_log.Logger).Log("msg", "failed to process marks", "path", path, "err", err)
 					continue
 				}
@@ -218,6 +261,7 @@ func (r *markerProcessor) Start(deleteFunc func(ctx context.Context, chunkId []b
 				if err := r.deleteEmptyMarks(path); err != nil {
 					level.Warn(util_log.Logger).Log("msg", "failed to delete marks", "path", path, "err", err)
 				}
+				r.mtx.Unlock()
 			}
 
 		}
@@ -247,7 +291,7 @@ func (r *markerProcessor) Start(deleteFunc func(ctx context.Context, chunkId []b
 	}()
 }
 
-func (r *markerProcessor) processPath(path string, deleteFunc func(ctx context.Context, chunkId []byte) error) error {
+func (r *markerProcessor) processPath(path string, deleteFunc func(ctx context.Context, userID, chunkID []byte) error) error {
 	var (
 		wg    sync.WaitGroup
 		queue = make(chan *keyPair)
@@ -326,9 +370,12 @@ func (r *markerProcessor) processPath(path string, deleteFunc func(ctx context.C
 	})
 }
 
-func processKey(ctx context.Context, key *keyPair, db *bbolt.DB, deleteFunc func(ctx context.Context, chunkId []byte) error) error {
-	chunkID := key.value.Bytes()
-	if err := deleteFunc(ctx, chunkID); err != nil {
+func processKey(ctx context.Context, key *keyPair, db *bbolt.DB, deleteFunc func(ctx context.Context, userID, chunkID []byte) error) error {
+	userID, chunkID, err := decodeMark(key.value.Bytes())
+	if err != nil {
+		return err
+	}
+	if err := deleteFunc(ctx, userID, chunkID); err != nil {
 		return err
 	}
 	return db.Batch(func(tx *bbolt.Tx) error {
@@ -372,6 +419,11 @@ func (r *markerProcessor) deleteEmptyMarks(path string) error {
 
 // availablePath returns markers path in chronological order, skipping file that are not old enough.
 func (r *markerProcessor) availablePath() ([]string, []time.Time, error) {
+	return r.markerFiles(r.minAgeFile)
+}
+
+// markerFiles returns markers path in chronological order, skipping file younger than minAge.
+func (r *markerProcessor) markerFiles(minAge time.Duration) ([]string, []time.Time, error) {
 	found := []int64{}
 	if err := filepath.WalkDir(r.folder, func(path string, d fs.DirEntry, err error) error {
 		if d == nil || err != nil {
@@ -391,7 +443,7 @@ func (r *markerProcessor) availablePath() ([]string, []time.Time, error) {
 			return nil
 		}
 
-		if time.Since(time.Unix(0, i)) > r.minAgeFile {
+		if time.Since(time.Unix(0, i)) > minAge {
 			found = append(found, i)
 		}
 		return nil
@@ -411,6 +463,102 @@ func (r *markerProcessor) availablePath() ([]string, []time.Time, error) {
 	return res, resTime, nil
 }
 
+func (r *markerProcessor) Unmark(ctx context.Context, match func(userID, chunkID []byte) bool, unmark func(ctx context.Context, chunkIDs [][]byte) error) (int, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	paths, _, err := r.markerFiles(-1)
+	if err != nil {
+		return 0, err
+	}
+
+	// the marker files with marks matching are kept open until the marks are deleted.
+	type markerFile struct {
+		db   *bbolt.DB
+		keys [][]byte
+	}
+	var (
+		files    []markerFile
+		chunkIDs [][]byte
+		skipped  int
+	)
+	defer func() {
+		for _, f := range files {
+			if err := f.db.Close(); err != nil {
+				level.Warn(util_log.Logger).Log("msg", "failed to close db", "err", err)
+			}
+		}
+	}()
+	for _, path := range paths {
+		if ctx.Err() != nil {
+			return skipped, ctx.Err()
+		}
+		db, err := bbolt.Open(path, 0o666, &bbolt.Options{Timeout: markerFileLockTimeout})
+		if errors.Is(err, bbolt.ErrTimeout) {
+			level.Warn(util_log.Logger).Log("msg", "skipping marker file being written", "path", path)
+			skipped++
+			continue
+		}
+		if err != nil {
+			return skipped, err
+		}
+		f := markerFile{db: db}
+		err = db.View(func(tx *bbolt.Tx) error {
+			b := tx.Bucket(chunkBucket)
+			if b == nil {
+				return nil
+			}
+			c := b.Cursor()
+			for k, v := c.First(); k != nil; k, v = c.Next() {
+				userID, chunkID, err := decodeMark(v)
+				if err != nil {
+					level.Warn(util_log.Logger).Log("msg", "skipping invalid mark", "path", path, "err", err)
+					continue
+				}
+				if match(userID, chunkID) {
+					// the keys and the values are only valid for the transaction.
+					f.keys = append(f.keys, append([]byte(nil), k...))
+					chunkIDs = append(chunkIDs, append([]byte(nil), chunkID...))
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			_ = db.Close()
+			return skipped, err
+		}
+		if len(f.keys) == 0 {
+			if err := db.Close(); err != nil {
+				return skipped, err
+			}
+			continue
+		}
+		files = append(files, f)
+	}
+	if len(chunkIDs) == 0 {
+		return skipped, nil
+	}
+
+	if err := unmark(ctx, chunkIDs); err != nil {
+		return skipped, err
+	}
+	for _, f := range files {
+		err := f.db.Update(func(tx *bbolt.Tx) error {
+			b := tx.Bucket(chunkBucket)
+			for _, k := range f.keys {
+				if err := b.Delete(k); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return skipped, err
+		}
+	}
+	return skipped, nil
+}
+
 func (r *markerProcessor) Stop() {
 	r.cancel()
 	r.wg.Wait()
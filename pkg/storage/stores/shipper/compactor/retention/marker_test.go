@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -25,13 +26,13 @@ func initAndFeedMarkerProcessor(t *testing.T, deleteWorkerCount int) *markerProc
 		w, err := NewMarkerStorageWriter(dir)
 		require.NoError(t, err)
 
-		require.NoError(t, w.Put([]byte("1")))
-		require.NoError(t, w.Put([]byte("2")))
+		require.NoError(t, w.Put([]byte("fake"), []byte("1")))
+		require.NoError(t, w.Put([]byte("fake"), []byte("2")))
 		require.NoError(t, w.Close())
 		w, err = NewMarkerStorageWriter(dir)
 		require.NoError(t, err)
-		require.NoError(t, w.Put([]byte("3")))
-		require.NoError(t, w.Put([]byte("4")))
+		require.NoError(t, w.Put([]byte("fake"), []byte("3")))
+		require.NoError(t, w.Put([]byte("fake"), []byte("4")))
 		require.NoError(t, w.Close())
 	}()
 	return p
@@ -44,13 +45,13 @@ func Test_marlkerProcessor_Deadlock(t *testing.T) {
 	w, err := NewMarkerStorageWriter(dir)
 	require.NoError(t, err)
 	for i := 0; i <= 2000; i++ {
-		require.NoError(t, w.Put([]byte(fmt.Sprintf("%d", i))))
+		require.NoError(t, w.Put([]byte("fake"), []byte(fmt.Sprintf("%d", i))))
 	}
 	require.NoError(t, w.Close())
 	paths, _, err := p.availablePath()
 	require.NoError(t, err)
 	for _, path := range paths {
-		require.NoError(t, p.processPath(path, func(ctx context.Context, chunkId []byte) error { return nil }))
+		require.NoError(t, p.processPath(path, func(ctx context.Context, userID, chunkID []byte) error { return nil }))
 		require.NoError(t, p.deleteEmptyMarks(path))
 	}
 	paths, _, err = p.availablePath()
@@ -64,7 +65,7 @@ func Test_markerProcessor_StartRetryKey(t *testing.T) {
 	counts := map[string]int{}
 	l := sync.Mutex{}
 
-	p.Start(func(ctx context.Context, id []byte) error {
+	p.Start(func(ctx context.Context, _, id []byte) error {
 		l.Lock()
 		defer l.Unlock()
 		counts[string(id)]++
@@ -93,7 +94,7 @@ func Test_markerProcessor_StartDeleteOnSuccess(t *testing.T) {
 	counts := map[string]int{}
 	l := sync.Mutex{}
 
-	p.Start(func(ctx context.Context, id []byte) error {
+	p.Start(func(ctx context.Context, _, id []byte) error {
 		l.Lock()
 		defer l.Unlock()
 		counts[string(id)]++
@@ -169,7 +170,7 @@ func Test_MarkFileRotation(t *testing.T) {
 	require.NoError(t, err)
 	totalMarks := int64(2 * int(maxMarkPerFile))
 	for i := int64(0); i < totalMarks; i++ {
-		require.NoError(t, w.Put([]byte(fmt.Sprintf("%d", i))))
+		require.NoError(t, w.Put([]byte("fake"), []byte(fmt.Sprintf("%d", i))))
 	}
 	require.NoError(t, w.Close())
 	paths, _, err := p.availablePath()
@@ -178,3 +179,69 @@ func Test_MarkFileRotation(t *testing.T) {
 	require.Len(t, paths, 2)
 	require.Equal(t, totalMarks, w.Count())
 }
+
+func Test_markerProcessor_Unmark(t *testing.T) {
+	dir := t.TempDir()
+	p, err := newMarkerStorageReader(dir, 1, time.Hour, sweepMetrics)
+	require.NoError(t, err)
+	w, err := NewMarkerStorageWriter(dir)
+	require.NoError(t, err)
+	for _, id := range []string{"fake/1", "other/2", "fake/3"} {
+		require.NoError(t, w.Put([]byte(strings.Split(id, "/")[0]), []byte(id)))
+	}
+	require.NoError(t, w.Close())
+	matchFake := func(userID, _ []byte) bool { return string(userID) == "fake" }
+
+	// the marks are kept when the unmarking fails.
+	_, err = p.Unmark(context.Background(), matchFake, func(ctx context.Context, chunkIDs [][]byte) error {
+		return errors.New("failed")
+	})
+	require.Error(t, err)
+
+	var unmarked []string
+	skipped, err := p.Unmark(context.Background(), matchFake, func(ctx context.Context, chunkIDs [][]byte) error {
+		for _, id := range chunkIDs {
+			unmarked = append(unmarked, string(id))
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 0, skipped)
+	require.Equal(t, []string{"fake/1", "fake/3"}, unmarked)
+
+	// the marker files being written are skipped.
+	markerFileLockTimeout = 10 * time.Millisecond
+	w, err = NewMarkerStorageWriter(dir)
+	require.NoError(t, err)
+	require.NoError(t, w.Put([]byte("fake"), []byte("fake/4")))
+
+	var remaining []string
+	skipped, err = p.Unmark(context.Background(), func(_, _ []byte) bool { return true }, func(ctx context.Context, chunkIDs [][]byte) error {
+		for _, id := range chunkIDs {
+			remaining = append(remaining, string(id))
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, skipped)
+	require.Equal(t, []string{"other/2"}, remaining)
+	require.NoError(t, w.Close())
+}
+
+func Test_decodeMark(t *testing.T) {
+	userID, chunkID, err := decodeMark(encodeMark([]byte("fake:1"), []byte("51d9d4388a3dccf4/19000/1/2:3:4")))
+	require.NoError(t, err)
+	require.Equal(t, "fake:1", string(userID))
+	require.Equal(t, "51d9d4388a3dccf4/19000/1/2:3:4", string(chunkID))
+
+	// the marks written before only hold the chunk ID, starting with the user ID.
+	userID, chunkID, err = decodeMark([]byte("fake/1:2:3:4"))
+	require.NoError(t, err)
+	require.Equal(t, "fake", string(userID))
+	require.Equal(t, "fake/1:2:3:4", string(chunkID))
+
+	for _, mark := range []string{"", "1:2:3:4", "\x00", "\x00\x05fake"} {
+		_, _, err := decodeMark([]byte(mark))
+		require.Error(t, err, mark)
+	}
+}
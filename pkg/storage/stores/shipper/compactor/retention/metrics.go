@@ -20,6 +20,7 @@ type sweeperMetrics struct {
 	markerFileCurrentTime      prometheus.Gauge
 	markerFilesCurrent         prometheus.Gauge
 	markerFilesDeletedTotal    prometheus.Counter
+	marksUndeletedTotal        prometheus.Counter
 }
 
 func newSweeperMetrics(r prometheus.Registerer) *sweeperMetrics {
@@ -45,12 +46,18 @@ func newSweeperMetrics(r prometheus.Registerer) *sweeperMetrics {
 			Name:      "retention_sweeper_marker_files_deleted_total",
 			Help:      "The total of marker files deleted after being fully processed.",
 		}),
+		marksUndeletedTotal: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Namespace: "loki_boltdb_shipper",
+			Name:      "retention_sweeper_marks_undeleted_total",
+			Help:      "The total of marks deleted after the chunks marked were undeleted.",
+		}),
 	}
 }
 
 type markerMetrics struct {
 	tableProcessedTotal           *prometheus.CounterVec
 	tableMarksCreatedTotal        *prometheus.CounterVec
+	tableChunksPackedTotal        *prometheus.CounterVec
 	tableProcessedDurationSeconds *prometheus.HistogramVec
 }
 
@@ -66,6 +73,11 @@ func newMarkerMetrics(r prometheus.Registerer) *markerMetrics {
 			Name:      "retention_marker_count_total",
 			Help:      "Total count of markers created per table.",
 		}, []string{"table"}),
+		tableChunksPackedTotal: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "loki_boltdb_shipper",
+			Name:      "retention_marker_chunks_packed_total",
+			Help:      "Total count of chunks packed in containers per table.",
+		}, []string{"table"}),
 		tableProcessedDurationSeconds: promauto.With(r).NewHistogramVec(prometheus.HistogramOpts{
 			Namespace: "loki_boltdb_shipper",
 			Name:      "retention_marker_table_processed_duration_seconds",
@@ -0,0 +1,234 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"go.etcd.io/bbolt"
+
+	"github.com/grafana/loki/pkg/storage/chunk"
+)
+
+// packBatchSize is the number of chunks fetched at once to be packed.
+const packBatchSize = 1000
+
+// PackingConfig configures the packing of the small chunks of historical tables in
+// containers, to cut the number of objects of the tenants having many tiny chunks.
+type PackingConfig struct {
+	// MaxChunkSize is the maximum size of the chunks to pack, 0 disables the packing.
+	MaxChunkSize     int
+	MaxContainerSize int
+	// MinAge is the minimum age of the end of the chunks to pack.
+	MinAge time.Duration
+}
+
+func (cfg PackingConfig) enabled() bool {
+	return cfg.MaxChunkSize > 0
+}
+
+// chunkPacker rewrites the small chunks only indexed in a table into containers, replacing
+// their index entries by the ones of the packed chunks. The replaced chunks are marked for
+// deletion, so that they are deleted by the sweeper once the queriers have the new index.
+type chunkPacker struct {
+	cfg           PackingConfig
+	chunkClient   chunk.Client
+	tableName     string
+	tableInterval model.Interval
+	bucket        *bbolt.Bucket
+	scfg          chunk.SchemaConfig
+
+	seriesStoreSchema chunk.SeriesStoreSchema
+}
+
+func newChunkPacker(cfg PackingConfig, chunkClient chunk.Client, schemaCfg chunk.PeriodConfig, tableName string, bucket *bbolt.Bucket) (*chunkPacker, error) {
+	schema, err := schemaCfg.CreateSchema()
+	if err != nil {
+		return nil, err
+	}
+
+	seriesStoreSchema, ok := schema.(chunk.SeriesStoreSchema)
+	if !ok {
+		return nil, errors.New("invalid schema")
+	}
+
+	return &chunkPacker{
+		cfg:               cfg,
+		chunkClient:       chunkClient,
+		tableName:         tableName,
+		tableInterval:     ExtractIntervalFromTableName(tableName),
+		bucket:            bucket,
+		scfg:              chunk.SchemaConfig{Configs: []chunk.PeriodConfig{schemaCfg}},
+		seriesStoreSchema: seriesStoreSchema,
+	}, nil
+}
+
+// packChunks packs the small chunks of the table, and returns the number of chunks packed.
+func (p *chunkPacker) packChunks(ctx context.Context, marker MarkerStorageWriter, chunkIt ChunkEntryIterator) (int, error) {
+	candidates, err := p.candidates(chunkIt)
+	if err != nil {
+		return 0, err
+	}
+
+	userIDs := make([]string, 0, len(candidates))
+	for userID := range candidates {
+		userIDs = append(userIDs, userID)
+	}
+	sort.Strings(userIDs)
+
+	var packed int
+	for _, userID := range userIDs {
+		chunkIDs := candidates[userID]
+		for len(chunkIDs) > 0 {
+			if ctx.Err() != nil {
+				return packed, ctx.Err()
+			}
+			batch := chunkIDs
+			if len(batch) > packBatchSize {
+				batch = batch[:packBatchSize]
+			}
+			chunkIDs = chunkIDs[len(batch):]
+
+			n, err := p.packBatch(ctx, marker, userID, batch)
+			if err != nil {
+				return packed, err
+			}
+			packed += n
+		}
+	}
+	return packed, nil
+}
+
+// candidates returns the IDs of the chunks which can be packed by user. They must only be
+// indexed in this table, be old enough, and not be packed already.
+func (p *chunkPacker) candidates(chunkIt ChunkEntryIterator) (map[string][]string, error) {
+	var (
+		candidates = map[string][]string{}
+		seen       = map[string]struct{}{}
+		maxThrough = model.Now().Add(-p.cfg.MinAge)
+	)
+	for chunkIt.Next() {
+		if chunkIt.Err() != nil {
+			return nil, chunkIt.Err()
+		}
+		c := chunkIt.Entry()
+		if c.From < p.tableInterval.Start || c.Through > p.tableInterval.End || c.Through > maxThrough {
+			continue
+		}
+		// the entry is only valid during the iteration.
+		chunkID := string(c.ChunkID)
+		if chunk.IsContainedKey(chunkID) {
+			continue
+		}
+		if _, ok := seen[chunkID]; ok {
+			continue
+		}
+		seen[chunkID] = struct{}{}
+		userID := string(c.UserID)
+		candidates[userID] = append(candidates[userID], chunkID)
+	}
+	return candidates, chunkIt.Err()
+}
+
+func (p *chunkPacker) packBatch(ctx context.Context, marker MarkerStorageWriter, userID string, chunkIDs []string) (int, error) {
+	refs := make([]chunk.Chunk, 0, len(chunkIDs))
+	for _, chunkID := range chunkIDs {
+		ref, err := chunk.ParseExternalKey(userID, chunkID)
+		if err != nil {
+			return 0, err
+		}
+		refs = append(refs, ref)
+	}
+	chunks, err := p.chunkClient.GetChunks(ctx, refs)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		packed    int
+		container []chunk.Chunk
+		size      int
+	)
+	flush := func() error {
+		// a single chunk isn't worth a container.
+		if len(container) > 1 {
+			if err := p.writeContainer(ctx, marker, container); err != nil {
+				return err
+			}
+			packed += len(container)
+		}
+		container, size = nil, 0
+		return nil
+	}
+	for _, c := range chunks {
+		encoded, err := c.Encoded()
+		if err != nil {
+			return packed, err
+		}
+		if len(encoded) > p.cfg.MaxChunkSize {
+			continue
+		}
+		if size+len(encoded) > p.cfg.MaxContainerSize {
+			if err := flush(); err != nil {
+				return packed, err
+			}
+		}
+		container = append(container, c)
+		size += len(encoded)
+	}
+	return packed, flush()
+}
+
+// writeContainer writes the container of the chunks, then replaces their index entries
+// and marks the replaced chunks for deletion.
+func (p *chunkPacker) writeContainer(ctx context.Context, marker MarkerStorageWriter, chunks []chunk.Chunk) error {
+	oldIDs := make([]string, len(chunks))
+	for i := range chunks {
+		oldIDs[i] = p.scfg.ExternalKey(chunks[i])
+	}
+	if err := chunk.PackChunks(chunks); err != nil {
+		return err
+	}
+	if err := p.chunkClient.PutChunks(ctx, chunks); err != nil {
+		return err
+	}
+
+	for i, c := range chunks {
+		if err := p.updateEntries(c, oldIDs[i], true); err != nil {
+			return err
+		}
+		if err := p.updateEntries(c, p.scfg.ExternalKey(c), false); err != nil {
+			return err
+		}
+		if err := marker.Put([]byte(c.UserID), []byte(oldIDs[i])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateEntries deletes or writes the entries of this table indexing the chunk under the given ID.
+func (p *chunkPacker) updateEntries(c chunk.Chunk, chunkID string, deleteEntries bool) error {
+	entries, err := p.seriesStoreSchema.GetChunkWriteEntries(c.From, c.Through, c.UserID, logMetricName, c.Metric, chunkID)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.TableName != p.tableName {
+			continue
+		}
+		key := []byte(entry.HashValue + separator + string(entry.RangeValue))
+		if deleteEntries {
+			err = p.bucket.Delete(key)
+		} else {
+			err = p.bucket.Put(key, nil)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update the index entry of chunk %s: %w", chunkID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package retention
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/bbolt"
+
+	"github.com/grafana/loki/pkg/storage/chunk"
+	"github.com/grafana/loki/pkg/storage/chunk/local"
+	"github.com/grafana/loki/pkg/storage/chunk/objectclient"
+	"github.com/grafana/loki/pkg/storage/chunk/storage"
+)
+
+type recordingWriter struct {
+	chunkIDs []string
+}
+
+func (w *recordingWriter) Put(_, chunkID []byte) error {
+	w.chunkIDs = append(w.chunkIDs, string(chunkID))
+	return nil
+}
+func (w *recordingWriter) Count() int64 { return int64(len(w.chunkIDs)) }
+func (w *recordingWriter) Close() error { return nil }
+
+func TestChunkPacker(t *testing.T) {
+	schema := allSchemas[3]
+	cm := storage.NewClientMetrics()
+	defer cm.Unregister()
+	store := newTestStore(t, cm)
+
+	var (
+		c1 = createChunk(t, "1", labels.Labels{labels.Label{Name: "foo", Value: "1"}}, schema.from, schema.from.Add(time.Hour))
+		c2 = createChunk(t, "1", labels.Labels{labels.Label{Name: "foo", Value: "2"}}, schema.from, schema.from.Add(2*time.Hour))
+		c3 = createChunk(t, "1", labels.Labels{labels.Label{Name: "foo", Value: "1"}}, schema.from.Add(2*time.Hour), schema.from.Add(3*time.Hour))
+		// spans 2 tables.
+		c4 = createChunk(t, "1", labels.Labels{labels.Label{Name: "foo", Value: "3"}}, schema.from.Add(23*time.Hour), schema.from.Add(25*time.Hour))
+		// alone for its tenant.
+		c5 = createChunk(t, "2", labels.Labels{labels.Label{Name: "foo", Value: "1"}}, schema.from, schema.from.Add(time.Hour))
+	)
+	require.NoError(t, store.Put(context.TODO(), []chunk.Chunk{c1, c2, c3, c4, c5}))
+	store.Stop()
+
+	chunkClient := objectclient.NewClient(newTestObjectClient(store.chunkDir, cm), objectclient.FSEncoder, schemaCfg.SchemaConfig)
+	cfg := PackingConfig{MaxChunkSize: 1 << 20, MaxContainerSize: 4 << 20, MinAge: time.Hour}
+	marker := &recordingWriter{}
+	tableName := schema.config.IndexTables.TableFor(schema.from)
+	for _, table := range store.indexTables() {
+		err := table.DB.Update(func(tx *bbolt.Tx) error {
+			bucket := tx.Bucket(local.IndexBucketName)
+			it, err := NewChunkIndexIterator(bucket, schema.config)
+			require.NoError(t, err)
+			packer, err := newChunkPacker(cfg, chunkClient, schema.config, table.name, bucket)
+			require.NoError(t, err)
+			packed, err := packer.packChunks(context.Background(), marker, it)
+			require.NoError(t, err)
+			if table.name == tableName {
+				require.Equal(t, 3, packed)
+			} else {
+				require.Equal(t, 0, packed)
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		require.NoError(t, table.Close())
+	}
+
+	expectedMarks := []string{
+		schemaCfg.ExternalKey(c1),
+		schemaCfg.ExternalKey(c2),
+		schemaCfg.ExternalKey(c3),
+	}
+	sort.Strings(expectedMarks)
+	sort.Strings(marker.chunkIDs)
+	require.Equal(t, expectedMarks, marker.chunkIDs)
+
+	store.open()
+	defer store.Stop()
+	for _, c := range []chunk.Chunk{c1, c2, c3} {
+		chunks := store.GetChunks(c.UserID, c.From, c.Through, c.Metric)
+		var found bool
+		for _, chk := range chunks {
+			if chk.Fingerprint != c.Fingerprint || chk.From != c.From || chk.Through != c.Through {
+				continue
+			}
+			require.True(t, chk.Contained())
+			expected, err := c.Encoded()
+			require.NoError(t, err)
+			actual, err := chk.Encoded()
+			require.NoError(t, err)
+			require.Equal(t, expected, actual)
+			found = true
+		}
+		require.True(t, found)
+	}
+	for _, c := range []chunk.Chunk{c4, c5} {
+		require.True(t, store.HasChunk(c))
+	}
+}
@@ -1,7 +1,6 @@
 package retention
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -16,6 +15,7 @@ import (
 	"github.com/grafana/loki/pkg/chunkenc"
 	"github.com/grafana/loki/pkg/storage"
 	"github.com/grafana/loki/pkg/storage/chunk"
+	"github.com/grafana/loki/pkg/storage/chunk/encoding"
 	"github.com/grafana/loki/pkg/storage/chunk/local"
 	util_log "github.com/grafana/loki/pkg/util/log"
 )
@@ -42,9 +42,10 @@ type Marker struct {
 	expiration       ExpirationChecker
 	markerMetrics    *markerMetrics
 	chunkClient      chunk.Client
+	packing          PackingConfig
 }
 
-func NewMarker(workingDirectory string, config storage.SchemaConfig, expiration ExpirationChecker, chunkClient chunk.Client, r prometheus.Registerer) (*Marker, error) {
+func NewMarker(workingDirectory string, config storage.SchemaConfig, expiration ExpirationChecker, chunkClient chunk.Client, packing PackingConfig, r prometheus.Registerer) (*Marker, error) {
 	if err := validatePeriods(config); err != nil {
 		return nil, err
 	}
@@ -55,6 +56,7 @@ func NewMarker(workingDirectory string, config storage.SchemaConfig, expiration
 		expiration:       expiration,
 		markerMetrics:    metrics,
 		chunkClient:      chunkClient,
+		packing:          packing,
 	}, nil
 }
 
@@ -77,7 +79,7 @@ func (t *Marker) MarkForDelete(ctx context.Context, tableName, userID string, db
 }
 
 func (t *Marker) markTable(ctx context.Context, tableName, userID string, db *bbolt.DB) (bool, bool, error) {
-	schemaCfg, ok := schemaPeriodForTable(t.config, tableName)
+	schemaCfg, ok := SchemaPeriodForTable(t.config, tableName)
 	if !ok {
 		return false, false, fmt.Errorf("could not find schema for table: %s", tableName)
 	}
@@ -110,6 +112,15 @@ func (t *Marker) markTable(ctx context.Context, tableName, userID string, db *bb
 		if err != nil {
 			return err
 		}
+		if !empty && t.packing.enabled() && t.chunkClient != nil {
+			packed, err := t.packChunks(ctx, tableName, markerWriter, bucket, schemaCfg)
+			if err != nil {
+				return err
+			}
+			if packed > 0 {
+				modified = true
+			}
+		}
 		t.markerMetrics.tableMarksCreatedTotal.WithLabelValues(tableName).Add(float64(markerWriter.Count()))
 		if err := markerWriter.Close(); err != nil {
 			return fmt.Errorf("failed to close marker writer: %w", err)
@@ -131,6 +142,23 @@ func (t *Marker) markTable(ctx context.Context, tableName, userID string, db *bb
 	return empty, modified, nil
 }
 
+func (t *Marker) packChunks(ctx context.Context, tableName string, markerWriter MarkerStorageWriter, bucket *bbolt.Bucket, schemaCfg chunk.PeriodConfig) (int, error) {
+	chunkIt, err := NewChunkIndexIterator(bucket, schemaCfg)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create chunk index iterator: %w", err)
+	}
+	packer, err := newChunkPacker(t.packing, t.chunkClient, schemaCfg, tableName, bucket)
+	if err != nil {
+		return 0, err
+	}
+	packed, err := packer.packChunks(ctx, markerWriter, chunkIt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to pack chunks: %w", err)
+	}
+	t.markerMetrics.tableChunksPackedTotal.WithLabelValues(tableName).Add(float64(packed))
+	return packed, nil
+}
+
 func markforDelete(ctx context.Context, tableName string, marker MarkerStorageWriter, chunkIt ChunkEntryIterator, seriesCleaner SeriesCleaner, expiration ExpirationChecker, chunkRewriter *chunkRewriter) (bool, bool, error) {
 	seriesMap := newUserSeriesMap()
 	// tableInterval holds the interval for which the table is expected to have the chunks indexed
@@ -150,10 +178,12 @@ func markforDelete(ctx context.Context, tableName string, marker MarkerStorageWr
 
 		// see if the chunk is deleted completely or partially
 		if expired, nonDeletedIntervals := expiration.Expired(c, now); expired {
+			removedLines := 0
 			if len(nonDeletedIntervals) > 0 {
-				wroteChunks, err := chunkRewriter.rewriteChunk(ctx, c, nonDeletedIntervals)
+				wroteChunks, removed, err := chunkRewriter.rewriteChunk(ctx, c, nonDeletedIntervals)
+				removedLines = removed
 				if err != nil {
-					return false, false, fmt.Errorf("failed to rewrite chunk %s for interval %s with error %s", c.ChunkID, nonDeletedIntervals, err)
+					return false, false, fmt.Errorf("failed to rewrite chunk %s for intervals %v with error %s", c.ChunkID, nonDeletedIntervals, err)
 				}
 
 				if wroteChunks {
@@ -172,7 +202,13 @@ func markforDelete(ctx context.Context, tableName string, marker MarkerStorageWr
 			// For a partially deleted chunk, if we delete the source chunk before all the tables which index it are processed then
 			// the retention would fail because it would fail to find it in the storage.
 			if len(nonDeletedIntervals) == 0 || c.Through <= tableInterval.End {
-				if err := marker.Put(c.ChunkID); err != nil {
+				if err := marker.Put(c.UserID, c.ChunkID); err != nil {
+					return false, false, err
+				}
+			}
+			// The removal is audited once, in the last table which indexes the chunk.
+			if c.Through <= tableInterval.End {
+				if err := auditRemoval(ctx, expiration, chunkRewriter, c, now, len(nonDeletedIntervals) == 0, removedLines); err != nil {
 					return false, false, err
 				}
 			}
@@ -240,19 +276,14 @@ func NewSweeper(workingDir string, deleteClient ChunkClient, deleteWorkerCount i
 }
 
 func (s *Sweeper) Start() {
-	s.markerProcessor.Start(func(ctx context.Context, chunkId []byte) error {
+	s.markerProcessor.Start(func(ctx context.Context, userID, chunkID []byte) error {
 		status := statusSuccess
 		start := time.Now()
 		defer func() {
 			s.sweeperMetrics.deleteChunkDurationSeconds.WithLabelValues(status).Observe(time.Since(start).Seconds())
 		}()
-		chunkIDString := unsafeGetString(chunkId)
-		userID, err := getUserIDFromChunkID(chunkId)
-		if err != nil {
-			return err
-		}
-
-		err = s.chunkClient.DeleteChunk(ctx, unsafeGetString(userID), chunkIDString)
+		chunkIDString := unsafeGetString(chunkID)
+		err := s.chunkClient.DeleteChunk(ctx, unsafeGetString(userID), chunkIDString)
 		if s.chunkClient.IsChunkNotFoundErr(err) {
 			status = statusNotFound
 			level.Debug(util_log.Logger).Log("msg", "delete on not found chunk", "chunkID", chunkIDString)
@@ -266,19 +297,50 @@ func (s *Sweeper) Start() {
 	})
 }
 
-func getUserIDFromChunkID(chunkID []byte) ([]byte, error) {
-	idx := bytes.IndexByte(chunkID, '/')
-	if idx <= 0 {
-		return nil, fmt.Errorf("invalid chunk ID %q", chunkID)
+// Undelete calls restore with the chunks of the user marked for deletion, not swept yet, overlapping the interval, and
+// deletes their marks once restored. It returns the number of marker files being written, skipped.
+func (s *Sweeper) Undelete(ctx context.Context, userID string, from, through model.Time, restore func(ctx context.Context, chunkIDs [][]byte) error) (int, error) {
+	match := func(markUserID, chunkID []byte) bool {
+		if string(markUserID) != userID {
+			return false
+		}
+		c, err := chunk.ParseExternalKey(userID, string(chunkID))
+		if err != nil {
+			return false
+		}
+		return c.From <= through && from <= c.Through
 	}
-
-	return chunkID[:idx], nil
+	return s.markerProcessor.Unmark(ctx, match, func(ctx context.Context, chunkIDs [][]byte) error {
+		if err := restore(ctx, chunkIDs); err != nil {
+			return err
+		}
+		s.sweeperMetrics.marksUndeletedTotal.Add(float64(len(chunkIDs)))
+		return nil
+	})
 }
 
 func (s *Sweeper) Stop() {
 	s.markerProcessor.Stop()
 }
 
+// auditRemoval records the lines removed from the expired chunk when the expiration checker audits them, counting the
+// lines of the chunk when it is deleted.
+func auditRemoval(ctx context.Context, expiration ExpirationChecker, chunkRewriter *chunkRewriter, c ChunkEntry, now model.Time, deleted bool, removedLines int) error {
+	auditor, ok := expiration.(RemovalAuditor)
+	if !ok || !auditor.AuditsRemoval(c, now) {
+		return nil
+	}
+	if deleted {
+		lines, err := chunkRewriter.countLines(ctx, c)
+		if err != nil {
+			return fmt.Errorf("failed to count the lines of chunk %s with error %s", c.ChunkID, err)
+		}
+		removedLines = lines
+	}
+	auditor.RemovedLines(c, removedLines)
+	return nil
+}
+
 type chunkRewriter struct {
 	chunkClient chunk.Client
 	tableName   string
@@ -309,52 +371,80 @@ func newChunkRewriter(chunkClient chunk.Client, schemaCfg chunk.PeriodConfig,
 	}, nil
 }
 
-func (c *chunkRewriter) rewriteChunk(ctx context.Context, ce ChunkEntry, intervals []model.Interval) (bool, error) {
+func (c *chunkRewriter) getChunk(ctx context.Context, ce ChunkEntry) (chunk.Chunk, *chunkenc.Facade, error) {
 	userID := unsafeGetString(ce.UserID)
 	chunkID := unsafeGetString(ce.ChunkID)
 
 	chk, err := chunk.ParseExternalKey(userID, chunkID)
 	if err != nil {
-		return false, err
+		return chunk.Chunk{}, nil, err
 	}
 
 	chks, err := c.chunkClient.GetChunks(ctx, []chunk.Chunk{chk})
 	if err != nil {
-		return false, err
+		return chunk.Chunk{}, nil, err
 	}
 
 	if len(chks) != 1 {
-		return false, fmt.Errorf("expected 1 entry for chunk %s but found %d in storage", chunkID, len(chks))
+		return chunk.Chunk{}, nil, fmt.Errorf("expected 1 entry for chunk %s but found %d in storage", chunkID, len(chks))
+	}
+
+	facade, ok := chks[0].Data.(*chunkenc.Facade)
+	if !ok {
+		return chunk.Chunk{}, nil, errors.New("invalid chunk type")
+	}
+	return chks[0], facade, nil
+}
+
+// countLines returns the number of lines of the chunk.
+func (c *chunkRewriter) countLines(ctx context.Context, ce ChunkEntry) (int, error) {
+	_, facade, err := c.getChunk(ctx, ce)
+	if err != nil {
+		return 0, err
+	}
+	return facade.LokiChunk().Size(), nil
+}
+
+// rewriteChunk writes the chunks of the intervals to retain of the chunk indexed in the table, returning whether chunks
+// were written and the number of lines of the chunk not retained.
+func (c *chunkRewriter) rewriteChunk(ctx context.Context, ce ChunkEntry, intervalFilters []IntervalFilter) (bool, int, error) {
+	userID := unsafeGetString(ce.UserID)
+
+	chk, facade, err := c.getChunk(ctx, ce)
+	if err != nil {
+		return false, 0, err
 	}
 
 	wroteChunks := false
+	removedLines := facade.LokiChunk().Size()
 
-	for _, interval := range intervals {
-		newChunkData, err := chks[0].Data.Rebound(interval.Start, interval.End)
+	for _, ivf := range intervalFilters {
+		interval := ivf.Interval
+		newChunkData, err := facade.ReboundWithFilter(interval.Start, interval.End, ivf.Filter)
 		if err != nil {
-			return false, err
-		}
-
-		facade, ok := newChunkData.(*chunkenc.Facade)
-		if !ok {
-			return false, errors.New("invalid chunk type")
+			if err == encoding.ErrSliceNoDataInRange {
+				// all the lines of the interval are filtered out.
+				continue
+			}
+			return false, 0, err
 		}
+		removedLines -= newChunkData.(*chunkenc.Facade).LokiChunk().Size()
 
 		newChunk := chunk.NewChunk(
-			userID, chks[0].FingerprintModel(), chks[0].Metric,
-			facade,
+			userID, chk.FingerprintModel(), chk.Metric,
+			newChunkData,
 			interval.Start,
 			interval.End,
 		)
 
 		err = newChunk.Encode()
 		if err != nil {
-			return false, err
+			return false, 0, err
 		}
 
 		entries, err := c.seriesStoreSchema.GetChunkWriteEntries(interval.Start, interval.End, userID, "logs", newChunk.Metric, c.scfg.ExternalKey(newChunk))
 		if err != nil {
-			return false, err
+			return false, 0, err
 		}
 
 		uploadChunk := false
@@ -364,7 +454,7 @@ func (c *chunkRewriter) rewriteChunk(ctx context.Context, ce ChunkEntry, interva
 			if entry.TableName == c.tableName {
 				key := entry.HashValue + separator + string(entry.RangeValue)
 				if err := c.bucket.Put([]byte(key), nil); err != nil {
-					return false, err
+					return false, 0, err
 				}
 				uploadChunk = true
 			}
@@ -374,11 +464,11 @@ func (c *chunkRewriter) rewriteChunk(ctx context.Context, ce ChunkEntry, interva
 		if uploadChunk {
 			err = c.chunkClient.PutChunks(ctx, []chunk.Chunk{newChunk})
 			if err != nil {
-				return false, err
+				return false, 0, err
 			}
 			wroteChunks = true
 		}
 	}
 
-	return wroteChunks, nil
+	return wroteChunks, removedLines, nil
 }
@@ -5,6 +5,7 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"fmt"
+	"os"
 	"path/filepath"
 	"sort"
 	"strconv"
@@ -153,7 +154,7 @@ func Test_Retention(t *testing.T) {
 			sweep.Start()
 			defer sweep.Stop()
 
-			marker, err := NewMarker(workDir, store.schemaCfg, expiration, nil, prometheus.NewRegistry())
+			marker, err := NewMarker(workDir, store.schemaCfg, expiration, nil, PackingConfig{}, prometheus.NewRegistry())
 			require.NoError(t, err)
 			for _, table := range store.indexTables() {
 				_, _, err := marker.MarkForDelete(context.Background(), table.name, "", table.DB, util_log.Logger)
@@ -185,11 +186,106 @@ func Test_Retention(t *testing.T) {
 	}
 }
 
+// tenantHashedSchemaCfg has the periods of the schemas whose chunk keys start with the hash of the user ID.
+var tenantHashedSchemaCfg = chunk.SchemaConfig{Configs: []chunk.PeriodConfig{
+	{
+		From:        dayFromTime(start),
+		IndexType:   "boltdb-shipper",
+		ObjectType:  "filesystem",
+		Schema:      "v13",
+		IndexTables: chunk.PeriodicTableConfig{Prefix: "index_", Period: 24 * time.Hour},
+	},
+	{
+		From:        dayFromTime(start.Add(48 * time.Hour)),
+		IndexType:   "boltdb-shipper",
+		ObjectType:  "filesystem",
+		Schema:      "v14",
+		IndexTables: chunk.PeriodicTableConfig{Prefix: "index_", Period: 24 * time.Hour},
+	},
+}}
+
+// newTenantHashedChunks stores a v13 and a v14 chunk of the user in the filesystem object store of the directory, and
+// marks them for deletion in the working directory.
+func newTenantHashedChunks(t *testing.T, dir, workDir, userID string) (chunk.Client, []chunk.Chunk) {
+	objectClient, err := local.NewFSObjectClient(local.FSConfig{Directory: dir})
+	require.NoError(t, err)
+	chunkClient := objectclient.NewClient(objectClient, objectclient.FSEncoder, tenantHashedSchemaCfg)
+
+	lbs := labels.Labels{labels.Label{Name: "foo", Value: "bar"}}
+	chunks := []chunk.Chunk{
+		createChunk(t, userID, lbs, start, start.Add(time.Hour)),
+		createChunk(t, userID, lbs, start.Add(49*time.Hour), start.Add(50*time.Hour)),
+	}
+	require.NoError(t, chunkClient.PutChunks(context.Background(), chunks))
+
+	w, err := NewMarkerStorageWriter(workDir)
+	require.NoError(t, err)
+	for _, c := range chunks {
+		require.NoError(t, w.Put([]byte(c.UserID), []byte(tenantHashedSchemaCfg.ExternalKey(c))))
+	}
+	require.NoError(t, w.Close())
+	return chunkClient, chunks
+}
+
+func TestSweeper_TenantHashedChunkKeys(t *testing.T) {
+	dir := t.TempDir()
+	workDir := filepath.Join(dir, "retention")
+	// the user ID isn't the first part of the chunk keys, and can contain colons.
+	chunkClient, chunks := newTenantHashedChunks(t, filepath.Join(dir, "chunks"), workDir, "fake:1")
+
+	sweep, err := NewSweeper(workDir, chunkClient, 1, 0, nil)
+	require.NoError(t, err)
+	sweep.Start()
+	defer sweep.Stop()
+
+	require.Eventually(t, func() bool {
+		for _, c := range chunks {
+			key := objectclient.FSEncoder(tenantHashedSchemaCfg, c)
+			if _, err := os.Stat(filepath.Join(dir, "chunks", filepath.FromSlash(key))); !os.IsNotExist(err) {
+				return false
+			}
+		}
+		return true
+	}, 10*time.Second, 100*time.Millisecond)
+}
+
+func TestSweeper_UndeleteTenantHashedChunkKeys(t *testing.T) {
+	dir := t.TempDir()
+	workDir := filepath.Join(dir, "retention")
+	chunkClient, chunks := newTenantHashedChunks(t, filepath.Join(dir, "chunks"), workDir, "fake")
+	_, others := newTenantHashedChunks(t, filepath.Join(dir, "chunks"), workDir, "other")
+
+	sweep, err := NewSweeper(workDir, chunkClient, 1, time.Hour, nil)
+	require.NoError(t, err)
+
+	var restored []string
+	restore := func(_ context.Context, chunkIDs [][]byte) error {
+		for _, id := range chunkIDs {
+			restored = append(restored, string(id))
+		}
+		return nil
+	}
+	// the chunks of the other user and out of the interval aren't undeleted.
+	_, err = sweep.Undelete(context.Background(), "fake", start.Add(48*time.Hour), model.Now(), restore)
+	require.NoError(t, err)
+	require.Equal(t, []string{tenantHashedSchemaCfg.ExternalKey(chunks[1])}, restored)
+
+	restored = nil
+	_, err = sweep.Undelete(context.Background(), "fake", 0, model.Now(), restore)
+	require.NoError(t, err)
+	require.Equal(t, []string{tenantHashedSchemaCfg.ExternalKey(chunks[0])}, restored)
+
+	restored = nil
+	_, err = sweep.Undelete(context.Background(), "other", 0, model.Now(), restore)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{tenantHashedSchemaCfg.ExternalKey(others[0]), tenantHashedSchemaCfg.ExternalKey(others[1])}, restored)
+}
+
 type noopWriter struct{}
 
-func (noopWriter) Put(chunkID []byte) error { return nil }
-func (noopWriter) Count() int64             { return 0 }
-func (noopWriter) Close() error             { return nil }
+func (noopWriter) Put(userID, chunkID []byte) error { return nil }
+func (noopWriter) Count() int64                     { return 0 }
+func (noopWriter) Close() error                     { return nil }
 
 type noopCleaner struct{}
 
@@ -315,7 +411,7 @@ func TestChunkRewriter(t *testing.T) {
 	for _, tt := range []struct {
 		name             string
 		chunk            chunk.Chunk
-		rewriteIntervals []model.Interval
+		rewriteIntervals []IntervalFilter
 	}{
 		{
 			name:  "no rewrites",
@@ -328,56 +424,72 @@ func TestChunkRewriter(t *testing.T) {
 		{
 			name:  "rewrite first half",
 			chunk: createChunk(t, "1", labels.Labels{labels.Label{Name: "foo", Value: "bar"}}, now.Add(-2*time.Hour), now),
-			rewriteIntervals: []model.Interval{
+			rewriteIntervals: []IntervalFilter{
 				{
-					Start: now.Add(-2 * time.Hour),
-					End:   now.Add(-1 * time.Hour),
+					Interval: model.Interval{
+						Start: now.Add(-2 * time.Hour),
+						End:   now.Add(-1 * time.Hour),
+					},
 				},
 			},
 		},
 		{
 			name:  "rewrite second half",
 			chunk: createChunk(t, "1", labels.Labels{labels.Label{Name: "foo", Value: "bar"}}, now.Add(-2*time.Hour), now),
-			rewriteIntervals: []model.Interval{
+			rewriteIntervals: []IntervalFilter{
 				{
-					Start: now.Add(-time.Hour),
-					End:   now,
+					Interval: model.Interval{
+						Start: now.Add(-time.Hour),
+						End:   now,
+					},
 				},
 			},
 		},
 		{
 			name:  "rewrite multiple intervals",
 			chunk: createChunk(t, "1", labels.Labels{labels.Label{Name: "foo", Value: "bar"}}, now.Add(-12*time.Hour), now),
-			rewriteIntervals: []model.Interval{
+			rewriteIntervals: []IntervalFilter{
 				{
-					Start: now.Add(-12 * time.Hour),
-					End:   now.Add(-10 * time.Hour),
+					Interval: model.Interval{
+						Start: now.Add(-12 * time.Hour),
+						End:   now.Add(-10 * time.Hour),
+					},
 				},
 				{
-					Start: now.Add(-9 * time.Hour),
-					End:   now.Add(-5 * time.Hour),
+					Interval: model.Interval{
+						Start: now.Add(-9 * time.Hour),
+						End:   now.Add(-5 * time.Hour),
+					},
 				},
 				{
-					Start: now.Add(-2 * time.Hour),
-					End:   now,
+					Interval: model.Interval{
+						Start: now.Add(-2 * time.Hour),
+						End:   now,
+					},
 				},
 			},
 		},
 		{
 			name:  "rewrite chunk spanning multiple days with multiple intervals",
 			chunk: createChunk(t, "1", labels.Labels{labels.Label{Name: "foo", Value: "bar"}}, now.Add(-72*time.Hour), now),
-			rewriteIntervals: []model.Interval{
+			rewriteIntervals: []IntervalFilter{
 				{
-					Start: now.Add(-71 * time.Hour),
-					End:   now.Add(-47 * time.Hour),
+					Interval: model.Interval{
+						Start: now.Add(-71 * time.Hour),
+						End:   now.Add(-47 * time.Hour),
+					},
 				},
 				{
-					Start: now.Add(-40 * time.Hour),
-					End:   now.Add(-30 * time.Hour),
+					Interval: model.Interval{
+						Start: now.Add(-40 * time.Hour),
+						End:   now.Add(-30 * time.Hour),
+					},
 				},
 				{
-					Start: now.Add(-2 * time.Hour),
-					End:   now,
+					Interval: model.Interval{
+						Start: now.Add(-2 * time.Hour),
+						End:   now,
+					},
 				},
 			},
 		},
@@ -401,7 +513,7 @@ func TestChunkRewriter(t *testing.T) {
 					cr, err := newChunkRewriter(chunkClient, store.schemaCfg.SchemaConfig.Configs[0], indexTable.name, bucket)
 					require.NoError(t, err)
 
-					wroteChunks, err := cr.rewriteChunk(context.Background(), entryFromChunk(store.schemaCfg.SchemaConfig, tt.chunk), tt.rewriteIntervals)
+					wroteChunks, _, err := cr.rewriteChunk(context.Background(), entryFromChunk(store.schemaCfg.SchemaConfig, tt.chunk), tt.rewriteIntervals)
 					require.NoError(t, err)
 					if len(tt.rewriteIntervals) == 0 {
 						require.False(t, wroteChunks)
@@ -418,7 +530,7 @@ func TestChunkRewriter(t *testing.T) {
 			// number of chunks should be the new re-written chunks + the source chunk
 			require.Len(t, chunks, len(tt.rewriteIntervals)+1)
 			for _, interval := range tt.rewriteIntervals {
-				expectedChk := createChunk(t, tt.chunk.UserID, labels.Labels{labels.Label{Name: "foo", Value: "bar"}}, interval.Start, interval.End)
+				expectedChk := createChunk(t, tt.chunk.UserID, labels.Labels{labels.Label{Name: "foo", Value: "bar"}}, interval.Interval.Start, interval.Interval.End)
 				for i, chk := range chunks {
 					if store.schemaCfg.ExternalKey(chk) == store.schemaCfg.ExternalKey(expectedChk) {
 						chunks = append(chunks[:i], chunks[i+1:]...)
@@ -451,7 +563,7 @@ func (s *seriesCleanedRecorder) Cleanup(userID []byte, lbls labels.Labels) error
 
 type chunkExpiry struct {
 	isExpired           bool
-	nonDeletedIntervals []model.Interval
+	nonDeletedIntervals []IntervalFilter
 }
 
 type mockExpirationChecker struct {
@@ -463,7 +575,7 @@ func newMockExpirationChecker(chunksExpiry map[string]chunkExpiry) mockExpiratio
 	return mockExpirationChecker{chunksExpiry: chunksExpiry}
 }
 
-func (m mockExpirationChecker) Expired(ref ChunkEntry, now model.Time) (bool, []model.Interval) {
+func (m mockExpirationChecker) Expired(ref ChunkEntry, now model.Time) (bool, []IntervalFilter) {
 	ce := m.chunksExpiry[string(ref.ChunkID)]
 	return ce.isExpired, ce.nonDeletedIntervals
 }
@@ -534,9 +646,11 @@ func TestMarkForDelete_SeriesCleanup(t *testing.T) {
 			expiry: []chunkExpiry{
 				{
 					isExpired: true,
-					nonDeletedIntervals: []model.Interval{{
-						Start: todaysTableInterval.Start,
-						End:   todaysTableInterval.Start.Add(15 * time.Minute),
+					nonDeletedIntervals: []IntervalFilter{{
+						Interval: model.Interval{
+							Start: todaysTableInterval.Start,
+							End:   todaysTableInterval.Start.Add(15 * time.Minute),
+						},
 					}},
 				},
 			},
@@ -586,9 +700,11 @@ func TestMarkForDelete_SeriesCleanup(t *testing.T) {
 				},
 				{
 					isExpired: true,
-					nonDeletedIntervals: []model.Interval{{
-						Start: todaysTableInterval.Start,
-						End:   todaysTableInterval.Start.Add(15 * time.Minute),
+					nonDeletedIntervals: []IntervalFilter{{
+						Interval: model.Interval{
+							Start: todaysTableInterval.Start,
+							End:   todaysTableInterval.Start.Add(15 * time.Minute),
+						},
 					}},
 				},
 			},
@@ -610,9 +726,11 @@ func TestMarkForDelete_SeriesCleanup(t *testing.T) {
 			expiry: []chunkExpiry{
 				{
 					isExpired: true,
-					nonDeletedIntervals: []model.Interval{{
-						Start: todaysTableInterval.Start,
-						End:   now,
+					nonDeletedIntervals: []IntervalFilter{{
+						Interval: model.Interval{
+							Start: todaysTableInterval.Start,
+							End:   now,
+						},
 					}},
 				},
 			},
@@ -634,9 +752,11 @@ func TestMarkForDelete_SeriesCleanup(t *testing.T) {
 			expiry: []chunkExpiry{
 				{
 					isExpired: true,
-					nonDeletedIntervals: []model.Interval{{
-						Start: todaysTableInterval.Start.Add(-30 * time.Minute),
-						End:   now,
+					nonDeletedIntervals: []IntervalFilter{{
+						Interval: model.Interval{
+							Start: todaysTableInterval.Start.Add(-30 * time.Minute),
+							End:   now,
+						},
 					}},
 				},
 			},
@@ -694,6 +814,75 @@ func TestMarkForDelete_SeriesCleanup(t *testing.T) {
 	}
 }
 
+type auditingExpirationChecker struct {
+	mockExpirationChecker
+	removedLines map[string][]int
+}
+
+func (a *auditingExpirationChecker) AuditsRemoval(_ ChunkEntry, _ model.Time) bool {
+	return true
+}
+
+func (a *auditingExpirationChecker) RemovedLines(ref ChunkEntry, lines int) {
+	a.removedLines[string(ref.ChunkID)] = append(a.removedLines[string(ref.ChunkID)], lines)
+}
+
+func TestMarkForDelete_AuditRemoval(t *testing.T) {
+	now := model.Now()
+	schema := allSchemas[2]
+	userID := "1"
+	todaysTableInterval := ExtractIntervalFromTableName(schema.config.IndexTables.TableFor(now))
+
+	// a line per minute.
+	deleted := createChunk(t, userID, labels.Labels{labels.Label{Name: "foo", Value: "1"}}, todaysTableInterval.Start, todaysTableInterval.Start.Add(30*time.Minute))
+	partiallyDeleted := createChunk(t, userID, labels.Labels{labels.Label{Name: "foo", Value: "2"}}, todaysTableInterval.Start, todaysTableInterval.Start.Add(30*time.Minute))
+	deletedAcrossTables := createChunk(t, userID, labels.Labels{labels.Label{Name: "foo", Value: "3"}}, todaysTableInterval.Start.Add(-30*time.Minute), todaysTableInterval.Start.Add(30*time.Minute))
+	retained := createChunk(t, userID, labels.Labels{labels.Label{Name: "foo", Value: "4"}}, todaysTableInterval.Start, todaysTableInterval.Start.Add(30*time.Minute))
+
+	cm := storage.NewClientMetrics()
+	defer cm.Unregister()
+	store := newTestStore(t, cm)
+	require.NoError(t, store.Put(context.TODO(), []chunk.Chunk{deleted, partiallyDeleted, deletedAcrossTables, retained}))
+
+	expirationChecker := &auditingExpirationChecker{
+		mockExpirationChecker: newMockExpirationChecker(map[string]chunkExpiry{
+			store.schemaCfg.ExternalKey(deleted): {isExpired: true},
+			store.schemaCfg.ExternalKey(partiallyDeleted): {isExpired: true, nonDeletedIntervals: []IntervalFilter{{
+				Interval: model.Interval{
+					Start: todaysTableInterval.Start,
+					End:   todaysTableInterval.Start.Add(15 * time.Minute),
+				},
+			}}},
+			store.schemaCfg.ExternalKey(deletedAcrossTables): {isExpired: true},
+		}),
+		removedLines: map[string][]int{},
+	}
+	store.Stop()
+
+	chunkClient := objectclient.NewClient(newTestObjectClient(store.chunkDir, cm), objectclient.FSEncoder, schemaCfg.SchemaConfig)
+	tables := store.indexTables()
+	require.Len(t, tables, 2)
+	for _, table := range tables {
+		err := table.DB.Update(func(tx *bbolt.Tx) error {
+			it, err := NewChunkIndexIterator(tx.Bucket(local.IndexBucketName), schema.config)
+			require.NoError(t, err)
+			cr, err := newChunkRewriter(chunkClient, schema.config, table.name, tx.Bucket(local.IndexBucketName))
+			require.NoError(t, err)
+			_, _, err = markforDelete(context.Background(), table.name, noopWriter{}, it, noopCleaner{}, expirationChecker, cr)
+			require.NoError(t, err)
+			return nil
+		})
+		require.NoError(t, err)
+	}
+
+	// the removals are audited once per chunk.
+	require.Equal(t, map[string][]int{
+		store.schemaCfg.ExternalKey(deleted):             {31},
+		store.schemaCfg.ExternalKey(partiallyDeleted):    {15},
+		store.schemaCfg.ExternalKey(deletedAcrossTables): {61},
+	}, expirationChecker.removedLines)
+}
+
 func TestMarkForDelete_DropChunkFromIndex(t *testing.T) {
 	schema := allSchemas[2]
 	cm := storage.NewClientMetrics()
@@ -0,0 +1,345 @@
+package compactor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"go.etcd.io/bbolt"
+	"golang.org/x/time/rate"
+
+	"github.com/grafana/loki/pkg/chunkenc"
+	"github.com/grafana/loki/pkg/logproto"
+	logql_log "github.com/grafana/loki/pkg/logql/log"
+	loki_storage "github.com/grafana/loki/pkg/storage"
+	"github.com/grafana/loki/pkg/storage/chunk"
+	"github.com/grafana/loki/pkg/storage/chunk/local"
+	"github.com/grafana/loki/pkg/storage/chunk/objectclient"
+	chunk_util "github.com/grafana/loki/pkg/storage/chunk/util"
+	"github.com/grafana/loki/pkg/storage/stores/shipper/compactor/retention"
+	"github.com/grafana/loki/pkg/storage/stores/shipper/quarantine"
+	shipper_storage "github.com/grafana/loki/pkg/storage/stores/shipper/storage"
+	shipper_util "github.com/grafana/loki/pkg/storage/stores/shipper/util"
+	util_log "github.com/grafana/loki/pkg/util/log"
+)
+
+const (
+	// scrubMinTableAge is how long after their end the tables are scrubbed, once they aren't written anymore.
+	scrubMinTableAge = 24 * time.Hour
+
+	scrubStatusValid   = "valid"
+	scrubStatusCorrupt = "corrupt"
+	scrubStatusFailure = "failure"
+
+	// Reasons of the corrupt chunks, besides the ones of the chunk client failing to decode them.
+	corruptMissing = "missing"
+	corruptEntries = "entries"
+)
+
+// sampledChunk is a chunk of the index sampled for scrubbing.
+type sampledChunk struct {
+	userID, chunkID string
+}
+
+// chunkSample is a uniform sample of the chunks of a table, by reservoir sampling.
+type chunkSample struct {
+	size   int
+	seen   int
+	rand   *rand.Rand
+	chunks []sampledChunk
+}
+
+func (s *chunkSample) add(entry retention.ChunkEntry) {
+	s.seen++
+	i := len(s.chunks)
+	if i >= s.size {
+		if i = s.rand.Intn(s.seen); i >= s.size {
+			return
+		}
+	}
+	// the entries point to the memory of the db, which is closed after the sampling.
+	c := sampledChunk{userID: string(entry.UserID), chunkID: string(entry.ChunkID)}
+	if i == len(s.chunks) {
+		s.chunks = append(s.chunks, c)
+		return
+	}
+	s.chunks[i] = c
+}
+
+// chunkScrubber verifies a sample of the chunks of a compacted table at every run, the tables being
+// scrubbed in turn: the checksum of the chunks and the decoding of their entries. The corrupt chunks
+// are quarantined with an annotation of their table, for the queries to skip them.
+type chunkScrubber struct {
+	workingDir         string
+	sampleSize         int
+	schemaConfig       loki_storage.SchemaConfig
+	indexStorageClient shipper_storage.Client
+	chunkClient        chunk.Client
+	quarantine         *quarantine.Store
+	limiter            *rate.Limiter
+	metrics            *metrics
+	logger             log.Logger
+	rand               *rand.Rand
+	now                func() time.Time
+	// ownsTable filters the tables scrubbed, all of them when nil.
+	ownsTable func(tableName string) bool
+
+	lastTable string
+}
+
+func newChunkScrubber(cfg Config, schemaConfig loki_storage.SchemaConfig, indexStorageClient shipper_storage.Client,
+	chunkClient chunk.Client, quarantineStore *quarantine.Store, metrics *metrics) (*chunkScrubber, error) {
+	workingDir := filepath.Join(cfg.WorkingDirectory, "scrubber")
+	if err := chunk_util.EnsureDirectory(workingDir); err != nil {
+		return nil, err
+	}
+	return &chunkScrubber{
+		workingDir:         workingDir,
+		sampleSize:         cfg.ChunkScrubberSampleSize,
+		schemaConfig:       schemaConfig,
+		indexStorageClient: indexStorageClient,
+		chunkClient:        chunkClient,
+		quarantine:         quarantineStore,
+		limiter:            rate.NewLimiter(rate.Limit(cfg.ChunkScrubberRateLimit), 1),
+		metrics:            metrics,
+		logger:             log.With(util_log.Logger, "component", "chunk-scrubber"),
+		rand:               rand.New(rand.NewSource(time.Now().UnixNano())),
+		now:                time.Now,
+	}, nil
+}
+
+// run scrubs a table at every interval until the context is done.
+func (s *chunkScrubber) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.scrubNextTable(ctx); err != nil && ctx.Err() == nil {
+				level.Error(s.logger).Log("msg", "failed to scrub the chunks", "err", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// scrubNextTable scrubs the table following the last one scrubbed, the tables being sorted by name.
+func (s *chunkScrubber) scrubNextTable(ctx context.Context) error {
+	tables, err := s.indexStorageClient.ListTables(ctx)
+	if err != nil {
+		return err
+	}
+
+	// only the tables not written anymore, and of a period of the schema, are scrubbed.
+	maxEnd := s.now().Add(-scrubMinTableAge)
+	eligible := tables[:0]
+	for _, table := range tables {
+		if retention.ExtractIntervalFromTableName(table).End.Time().After(maxEnd) {
+			continue
+		}
+		if _, ok := retention.SchemaPeriodForTable(s.schemaConfig, table); !ok {
+			continue
+		}
+		if s.ownsTable != nil && !s.ownsTable(table) {
+			continue
+		}
+		eligible = append(eligible, table)
+	}
+	if len(eligible) == 0 {
+		return nil
+	}
+	sort.Strings(eligible)
+
+	next := eligible[0]
+	if i := sort.SearchStrings(eligible, s.lastTable+"\x00"); i < len(eligible) {
+		next = eligible[i]
+	}
+	s.lastTable = next
+	return s.scrubTable(ctx, next)
+}
+
+// scrubTable verifies a sample of the chunks of the table and quarantines the corrupt ones.
+func (s *chunkScrubber) scrubTable(ctx context.Context, tableName string) error {
+	start := s.now()
+	logger := log.With(s.logger, "table-name", tableName)
+
+	sample, err := s.sampleTable(ctx, tableName)
+	if err != nil {
+		return errors.Wrap(err, "failed to sample the chunks of the table")
+	}
+
+	var corrupt []quarantine.Entry
+	for _, c := range sample {
+		if err := s.limiter.Wait(ctx); err != nil {
+			return err
+		}
+		reason, err := s.verifyChunk(ctx, c)
+		switch {
+		case err != nil:
+			// the chunks failing to be fetched aren't known to be corrupt.
+			s.metrics.scrubbedChunksTotal.WithLabelValues(scrubStatusFailure).Inc()
+			level.Warn(logger).Log("msg", "failed to verify chunk", "chunk", c.chunkID, "err", err)
+		case reason != "":
+			s.metrics.scrubbedChunksTotal.WithLabelValues(scrubStatusCorrupt).Inc()
+			s.metrics.quarantinedChunksTotal.WithLabelValues(reason).Inc()
+			level.Error(logger).Log("msg", "quarantining corrupt chunk", "chunk", c.chunkID, "user-id", c.userID, "reason", reason)
+			corrupt = append(corrupt, quarantine.Entry{ChunkKey: c.chunkID, Reason: reason, QuarantinedAt: s.now()})
+		default:
+			s.metrics.scrubbedChunksTotal.WithLabelValues(scrubStatusValid).Inc()
+		}
+	}
+
+	if len(corrupt) > 0 {
+		if err := s.quarantine.Add(ctx, tableName, corrupt); err != nil {
+			return errors.Wrap(err, "failed to quarantine the corrupt chunks")
+		}
+	}
+	s.metrics.chunkScrubLastSuccess.SetToCurrentTime()
+	level.Info(logger).Log("msg", "scrubbed chunks", "sampled", len(sample), "corrupt", len(corrupt), "duration", time.Since(start))
+	return nil
+}
+
+// sampleTable samples the chunks of the compacted common and user index files of the table.
+func (s *chunkScrubber) sampleTable(ctx context.Context, tableName string) ([]sampledChunk, error) {
+	sample := &chunkSample{size: s.sampleSize, rand: s.rand}
+	if err := forEachTableChunk(ctx, s.workingDir, s.indexStorageClient, s.schemaConfig, tableName, s.logger, sample.add); err != nil {
+		return nil, err
+	}
+	return sample.chunks, nil
+}
+
+// forEachTableChunk calls f with the chunk entries of the common and user index files of the table, downloaded in
+// turn under the working directory. The entries point to the memory of the files, valid only during the call.
+func forEachTableChunk(ctx context.Context, workingDir string, indexStorageClient shipper_storage.Client, schemaConfig loki_storage.SchemaConfig,
+	tableName string, logger log.Logger, f func(retention.ChunkEntry)) error {
+	period, _ := retention.SchemaPeriodForTable(schemaConfig, tableName)
+
+	workingDir = filepath.Join(workingDir, tableName)
+	if err := chunk_util.EnsureDirectory(workingDir); err != nil {
+		return err
+	}
+	defer func() {
+		if err := os.RemoveAll(workingDir); err != nil {
+			level.Error(logger).Log("msg", "failed to remove the working directory", "path", workingDir, "err", err)
+		}
+	}()
+
+	files, users, err := indexStorageClient.ListFiles(ctx, tableName)
+	if err != nil {
+		return err
+	}
+	downloaded := 0
+	forEachFileChunk := func(fileName string, getFile shipper_util.GetFileFunc) error {
+		downloaded++
+		path := filepath.Join(workingDir, fmt.Sprintf("%d", downloaded))
+		defer os.Remove(path)
+
+		if err := shipper_util.DownloadFileFromStorage(path, shipper_util.FileCompression(fileName), false,
+			shipper_util.LoggerWithFilename(logger, fileName), getFile); err != nil {
+			return err
+		}
+		db, err := shipper_util.SafeOpenBoltdbFile(path)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		return db.View(func(tx *bbolt.Tx) error {
+			bucket := tx.Bucket(local.IndexBucketName)
+			if bucket == nil {
+				return nil
+			}
+			it, err := retention.NewChunkIndexIterator(bucket, period)
+			if err != nil {
+				return err
+			}
+			for it.Next() {
+				f(it.Entry())
+			}
+			return it.Err()
+		})
+	}
+
+	for _, file := range files {
+		name := file.Name
+		if err := forEachFileChunk(name, func() (io.ReadCloser, error) {
+			return indexStorageClient.GetFile(ctx, tableName, name)
+		}); err != nil {
+			return err
+		}
+	}
+	for _, userID := range users {
+		files, err := indexStorageClient.ListUserFiles(ctx, tableName, userID)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			userID, name := userID, file.Name
+			if err := forEachFileChunk(name, func() (io.ReadCloser, error) {
+				return indexStorageClient.GetUserFile(ctx, tableName, userID, name)
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// verifyChunk fetches the chunk and decodes its entries. It returns the reason the chunk is corrupt,
+// empty when it is valid, or the error failing to fetch it.
+func (s *chunkScrubber) verifyChunk(ctx context.Context, c sampledChunk) (string, error) {
+	ref, err := chunk.ParseExternalKey(c.userID, c.chunkID)
+	if err != nil {
+		return "", err
+	}
+	chks, err := s.chunkClient.GetChunks(ctx, []chunk.Chunk{ref})
+	if err != nil {
+		var corruptErr *objectclient.CorruptChunkError
+		if errors.As(err, &corruptErr) {
+			return corruptErr.Reason, nil
+		}
+		if s.chunkClient.IsChunkNotFoundErr(errors.Cause(err)) {
+			return corruptMissing, nil
+		}
+		return "", err
+	}
+	if len(chks) != 1 {
+		return corruptMissing, nil
+	}
+
+	facade, ok := chks[0].Data.(*chunkenc.Facade)
+	if !ok {
+		return "", nil
+	}
+	it, err := facade.LokiChunk().Iterator(ctx, time.Unix(0, 0), time.Unix(0, math.MaxInt64), logproto.FORWARD,
+		logql_log.NewNoopPipeline().ForStream(chks[0].Metric))
+	if err != nil {
+		return s.entriesError(ctx, c, err)
+	}
+	defer it.Close()
+	for it.Next() {
+	}
+	if err := it.Error(); err != nil {
+		return s.entriesError(ctx, c, err)
+	}
+	return "", nil
+}
+
+func (s *chunkScrubber) entriesError(ctx context.Context, c sampledChunk, err error) (string, error) {
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+	level.Debug(s.logger).Log("msg", "failed to decode the entries of chunk", "chunk", c.chunkID, "err", err)
+	return corruptEntries, nil
+}
@@ -0,0 +1,212 @@
+package compactor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/bbolt"
+
+	"github.com/grafana/loki/pkg/chunkenc"
+	"github.com/grafana/loki/pkg/logproto"
+	loki_storage "github.com/grafana/loki/pkg/storage"
+	"github.com/grafana/loki/pkg/storage/chunk"
+	"github.com/grafana/loki/pkg/storage/chunk/local"
+	"github.com/grafana/loki/pkg/storage/chunk/objectclient"
+	chunk_util "github.com/grafana/loki/pkg/storage/chunk/util"
+	"github.com/grafana/loki/pkg/storage/stores/shipper/compactor/retention"
+	"github.com/grafana/loki/pkg/storage/stores/shipper/quarantine"
+	shipper_storage "github.com/grafana/loki/pkg/storage/stores/shipper/storage"
+	shipper_util "github.com/grafana/loki/pkg/storage/stores/shipper/util"
+)
+
+var scrubberSchemaCfg = loki_storage.SchemaConfig{SchemaConfig: chunk.SchemaConfig{Configs: []chunk.PeriodConfig{{
+	From:        chunk.DayTime{Time: 0},
+	IndexType:   "boltdb-shipper",
+	ObjectType:  "filesystem",
+	Schema:      "v11",
+	IndexTables: chunk.PeriodicTableConfig{Prefix: "index_", Period: 24 * time.Hour},
+	RowShards:   16,
+}}}}
+
+type scrubberTestStore struct {
+	t            *testing.T
+	schemaCfg    loki_storage.SchemaConfig
+	dir          string
+	objectClient *local.FSObjectClient
+	chunkClient  *objectclient.Client
+}
+
+func newScrubberTestStore(t *testing.T) *scrubberTestStore {
+	return newScrubberTestStoreWithSchema(t, scrubberSchemaCfg)
+}
+
+func newScrubberTestStoreWithSchema(t *testing.T, schemaCfg loki_storage.SchemaConfig) *scrubberTestStore {
+	dir := t.TempDir()
+	objectClient, err := local.NewFSObjectClient(local.FSConfig{Directory: filepath.Join(dir, "store")})
+	require.NoError(t, err)
+	return &scrubberTestStore{
+		t:            t,
+		schemaCfg:    schemaCfg,
+		dir:          dir,
+		objectClient: objectClient,
+		chunkClient:  objectclient.NewClient(objectClient, objectclient.FSEncoder, schemaCfg.SchemaConfig),
+	}
+}
+
+func (s *scrubberTestStore) newChunk(userID string, lbs labels.Labels, from model.Time) chunk.Chunk {
+	metric := labels.NewBuilder(lbs).Set(labels.MetricName, "logs").Labels()
+	mc := chunkenc.NewMemChunk(chunkenc.EncSnappy, chunkenc.UnorderedHeadBlockFmt, 256*1024, 0)
+	for i := 0; i < 10; i++ {
+		require.NoError(s.t, mc.Append(&logproto.Entry{Timestamp: from.Add(time.Duration(i) * time.Second).Time(), Line: fmt.Sprintf("line %d", i)}))
+	}
+	require.NoError(s.t, mc.Close())
+	c := chunk.NewChunk(userID, model.Fingerprint(lbs.Hash()), metric, chunkenc.NewFacade(mc, 256*1024, 0), from, from.Add(10*time.Second))
+	require.NoError(s.t, c.Encode())
+	require.NoError(s.t, s.chunkClient.PutChunks(context.Background(), []chunk.Chunk{c}))
+	return c
+}
+
+// writeIndex uploads an index file of the table indexing the chunks, a common one when the user is empty.
+func (s *scrubberTestStore) writeIndex(tableName, userID, fileName string, chunks ...chunk.Chunk) {
+	schema, err := s.schemaCfg.Configs[0].CreateSchema()
+	require.NoError(s.t, err)
+	seriesSchema := schema.(chunk.SeriesStoreSchema)
+
+	dir := filepath.Join(s.dir, "store", "index", tableName, userID)
+	require.NoError(s.t, chunk_util.EnsureDirectory(dir))
+	db, err := shipper_util.SafeOpenBoltdbFile(filepath.Join(dir, fileName))
+	require.NoError(s.t, err)
+	require.NoError(s.t, db.Update(func(tx *bbolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(local.IndexBucketName)
+		if err != nil {
+			return err
+		}
+		for _, c := range chunks {
+			externalKey := s.schemaCfg.ExternalKey(c)
+			_, labelEntries, err := seriesSchema.GetCacheKeysAndLabelWriteEntries(c.From, c.Through, c.UserID, "logs", c.Metric, externalKey)
+			if err != nil {
+				return err
+			}
+			entries, err := seriesSchema.GetChunkWriteEntries(c.From, c.Through, c.UserID, "logs", c.Metric, externalKey)
+			if err != nil {
+				return err
+			}
+			for _, batch := range labelEntries {
+				entries = append(entries, batch...)
+			}
+			for _, e := range entries {
+				if e.TableName != tableName {
+					continue
+				}
+				if err := bucket.Put([]byte(e.HashValue+"\000"+string(e.RangeValue)), e.Value); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}))
+	require.NoError(s.t, db.Close())
+}
+
+func (s *scrubberTestStore) newScrubber(sampleSize int) (*chunkScrubber, *quarantine.Store) {
+	quarantineStore := quarantine.NewStore(s.objectClient, "quarantine/")
+	scrubber, err := newChunkScrubber(Config{
+		WorkingDirectory:        filepath.Join(s.dir, "compactor"),
+		ChunkScrubberSampleSize: sampleSize,
+		ChunkScrubberRateLimit:  1000,
+	}, scrubberSchemaCfg, shipper_storage.NewIndexStorageClient(s.objectClient, "index/"), s.chunkClient, quarantineStore, newMetrics(nil))
+	require.NoError(s.t, err)
+	return scrubber, quarantineStore
+}
+
+func TestChunkScrubber(t *testing.T) {
+	s := newScrubberTestStore(t)
+	ctx := context.Background()
+
+	day := time.Now().Add(-72*time.Hour).Unix() / 86400
+	tableName := fmt.Sprintf("index_%d", day)
+	from := model.TimeFromUnix(day * 86400).Add(time.Hour)
+
+	valid := s.newChunk("fake", labels.Labels{{Name: "app", Value: "valid"}}, from)
+	truncated := s.newChunk("fake", labels.Labels{{Name: "app", Value: "truncated"}}, from)
+	missing := s.newChunk("fake", labels.Labels{{Name: "app", Value: "missing"}}, from)
+	userChunk := s.newChunk("user1", labels.Labels{{Name: "app", Value: "user"}}, from)
+
+	// a partial upload and a chunk deleted out of band.
+	encoded, err := truncated.Encoded()
+	require.NoError(t, err)
+	require.NoError(t, s.objectClient.PutObject(ctx, objectclient.FSEncoder(scrubberSchemaCfg.SchemaConfig, truncated), bytes.NewReader(encoded[:len(encoded)-10])))
+	require.NoError(t, s.chunkClient.DeleteChunk(ctx, "fake", scrubberSchemaCfg.ExternalKey(missing)))
+
+	s.writeIndex(tableName, "", "compactor-1", valid, truncated, missing)
+	s.writeIndex(tableName, "user1", "compactor-1", userChunk)
+	// the current table is still written, it isn't scrubbed.
+	s.writeIndex(fmt.Sprintf("index_%d", time.Now().Unix()/86400), "", "ingester-1", s.newChunk("fake", labels.Labels{{Name: "app", Value: "today"}}, model.Now()))
+
+	scrubber, quarantineStore := s.newScrubber(10)
+	require.NoError(t, scrubber.scrubNextTable(ctx))
+	require.Equal(t, tableName, scrubber.lastTable)
+
+	entries, err := quarantineStore.Get(ctx, tableName)
+	require.NoError(t, err)
+	reasons := map[string]string{}
+	for _, e := range entries {
+		reasons[e.ChunkKey] = e.Reason
+	}
+	require.Equal(t, map[string]string{
+		scrubberSchemaCfg.ExternalKey(truncated): "checksum",
+		scrubberSchemaCfg.ExternalKey(missing):   corruptMissing,
+	}, reasons)
+	require.Equal(t, float64(2), testutil.ToFloat64(scrubber.metrics.scrubbedChunksTotal.WithLabelValues(scrubStatusValid)))
+	require.Equal(t, float64(2), testutil.ToFloat64(scrubber.metrics.scrubbedChunksTotal.WithLabelValues(scrubStatusCorrupt)))
+
+	// the only table is scrubbed again at the next run, the quarantined chunks keep their annotation.
+	require.NoError(t, scrubber.scrubNextTable(ctx))
+	entries2, err := quarantineStore.Get(ctx, tableName)
+	require.NoError(t, err)
+	require.Equal(t, entries, entries2)
+}
+
+func TestChunkScrubber_NextTable(t *testing.T) {
+	s := newScrubberTestStore(t)
+	ctx := context.Background()
+
+	day := time.Now().Add(-72*time.Hour).Unix() / 86400
+	var tables []string
+	for i := int64(0); i < 3; i++ {
+		tableName := fmt.Sprintf("index_%d", day-i)
+		tables = append([]string{tableName}, tables...)
+		s.writeIndex(tableName, "", "compactor-1", s.newChunk("fake", labels.Labels{{Name: "app", Value: "foo"}}, model.TimeFromUnix((day-i)*86400)))
+	}
+
+	scrubber, _ := s.newScrubber(1)
+	for _, expected := range append(tables, tables[0]) {
+		require.NoError(t, scrubber.scrubNextTable(ctx))
+		require.Equal(t, expected, scrubber.lastTable)
+	}
+	require.Equal(t, float64(4), testutil.ToFloat64(scrubber.metrics.scrubbedChunksTotal.WithLabelValues(scrubStatusValid)))
+}
+
+func TestChunkSample(t *testing.T) {
+	sample := &chunkSample{size: 10, rand: rand.New(rand.NewSource(1))}
+	for i := 0; i < 1000; i++ {
+		sample.add(retention.ChunkEntry{ChunkRef: retention.ChunkRef{UserID: []byte("fake"), ChunkID: []byte(fmt.Sprint(i))}})
+	}
+	require.Equal(t, 1000, sample.seen)
+	require.Len(t, sample.chunks, 10)
+
+	seen := map[string]struct{}{}
+	for _, c := range sample.chunks {
+		seen[c.chunkID] = struct{}{}
+	}
+	require.Len(t, seen, 10)
+}
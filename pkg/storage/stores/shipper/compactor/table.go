@@ -68,7 +68,7 @@ const (
 	// this is to avoid recreation of the DB too often which would be too costly in a large cluster.
 	recreateCompactedDBOlderThan = 12 * time.Hour
 	dropFreePagesTxMaxSize       = 100 * 1024 * 1024 // 100MB
-	recreatedCompactedDBSuffix   = ".r.gz"
+	recreatedCompactedDBSuffix   = ".r"
 )
 
 type indexEntry struct {
@@ -83,6 +83,7 @@ type table struct {
 	name               string
 	workingDirectory   string
 	indexStorageClient storage.Client
+	compression        string
 	tableMarker        retention.TableMarker
 	expirationChecker  tableExpirationChecker
 
@@ -98,7 +99,7 @@ type table struct {
 	ctx context.Context
 }
 
-func newTable(ctx context.Context, workingDirectory string, indexStorageClient storage.Client,
+func newTable(ctx context.Context, workingDirectory string, indexStorageClient storage.Client, compression string,
 	tableMarker retention.TableMarker, expirationChecker tableExpirationChecker) (*table, error) {
 	err := chunk_util.EnsureDirectory(workingDirectory)
 	if err != nil {
@@ -110,6 +111,7 @@ func newTable(ctx context.Context, workingDirectory string, indexStorageClient s
 		name:               filepath.Base(workingDirectory),
 		workingDirectory:   workingDirectory,
 		indexStorageClient: indexStorageClient,
+		compression:        compression,
 		tableMarker:        tableMarker,
 		expirationChecker:  expirationChecker,
 		indexSets:          map[string]*indexSet{},
@@ -164,7 +166,7 @@ func (t *table) compact(applyRetention bool) error {
 		// we have just 1 common index file which is already compacted.
 		// initialize common compacted db if we need to apply retention, or we need to recreate it
 		downloadAt := filepath.Join(t.workingDirectory, indexFiles[0].Name)
-		err = shipper_util.DownloadFileFromStorage(downloadAt, shipper_util.IsCompressedFile(indexFiles[0].Name),
+		err = shipper_util.DownloadFileFromStorage(downloadAt, shipper_util.FileCompression(indexFiles[0].Name),
 			false, shipper_util.LoggerWithFilename(t.logger, indexFiles[0].Name),
 			func() (io.ReadCloser, error) {
 				return t.baseCommonIndexSet.GetFile(t.ctx, t.name, "", indexFiles[0].Name)
@@ -183,7 +185,7 @@ func (t *table) compact(applyRetention bool) error {
 	if t.compactedDB != nil {
 		// remove the source files if we did a compaction which gets reflected in dbsCompacted
 		t.indexSets[""], err = newCommonIndex(t.ctx, t.name, t.workingDirectory, t.compactedDB, t.uploadCompactedDB,
-			indexFiles, dbsCompacted, t.baseCommonIndexSet, t.logger)
+			indexFiles, dbsCompacted, t.baseCommonIndexSet, t.compression, t.logger)
 		if err != nil {
 			return err
 		}
@@ -304,7 +306,7 @@ func (t *table) compactFiles(files []storage.IndexFile) error {
 		compactedDBName = filepath.Join(t.workingDirectory, files[seedSourceFileIdx].Name)
 
 		level.Info(t.logger).Log("msg", fmt.Sprintf("using %s as seed file", files[seedSourceFileIdx].Name))
-		err = shipper_util.DownloadFileFromStorage(compactedDBName, shipper_util.IsCompressedFile(files[seedSourceFileIdx].Name),
+		err = shipper_util.DownloadFileFromStorage(compactedDBName, shipper_util.FileCompression(files[seedSourceFileIdx].Name),
 			false, shipper_util.LoggerWithFilename(t.logger, files[seedSourceFileIdx].Name), func() (io.ReadCloser, error) {
 				return t.baseCommonIndexSet.GetFile(t.ctx, t.name, "", files[seedSourceFileIdx].Name)
 			})
@@ -328,7 +330,7 @@ func (t *table) compactFiles(files []storage.IndexFile) error {
 		fileName := files[idx].Name
 		downloadAt := filepath.Join(t.workingDirectory, fileName)
 
-		err = shipper_util.DownloadFileFromStorage(downloadAt, shipper_util.IsCompressedFile(fileName),
+		err = shipper_util.DownloadFileFromStorage(downloadAt, shipper_util.FileCompression(fileName),
 			false, shipper_util.LoggerWithFilename(t.logger, fileName), func() (io.ReadCloser, error) {
 				return t.baseCommonIndexSet.GetFile(t.ctx, t.name, "", fileName)
 			})
@@ -393,7 +395,7 @@ func (t *table) getOrCreateUserIndex(userID string) (*indexSet, error) {
 			level.Info(t.logger).Log("msg", fmt.Sprintf("initializing indexSet for user %s", userID))
 
 			var err error
-			ui, err = newUserIndex(t.ctx, t.name, userID, t.baseUserIndexSet, filepath.Join(t.workingDirectory, userID), t.logger)
+			ui, err = newUserIndex(t.ctx, t.name, userID, t.baseUserIndexSet, filepath.Join(t.workingDirectory, userID), t.compression, t.logger)
 			if err != nil {
 				return nil, err
 			}
@@ -491,10 +493,10 @@ func readFile(logger log.Logger, path string, writeBatch func(userID string, bat
 }
 
 // uploadFile uploads the compacted db in compressed format.
-func uploadFile(compactedDBPath string, putFileFunc func(file io.ReadSeeker) error, logger log.Logger) error {
+func uploadFile(compactedDBPath, compression string, putFileFunc func(file io.ReadSeeker) error, logger log.Logger) error {
 	// compress the compactedDB.
-	compressedDBPath := fmt.Sprintf("%s.gz", compactedDBPath)
-	err := shipper_util.CompressFile(compactedDBPath, compressedDBPath, false)
+	compressedDBPath := shipper_util.CompressedFileName(compactedDBPath, compression)
+	err := shipper_util.CompressFile(compactedDBPath, compressedDBPath, compression, false)
 	if err != nil {
 		return err
 	}
@@ -534,5 +536,5 @@ func mustRecreateCompactedDB(sourceFiles []storage.IndexFile) bool {
 	}
 
 	// recreate the compacted db only if we have not recreated it before
-	return !strings.HasSuffix(sourceFiles[0].Name, recreatedCompactedDBSuffix)
+	return !strings.HasSuffix(shipper_util.UncompressedFileName(sourceFiles[0].Name), recreatedCompactedDBSuffix)
 }
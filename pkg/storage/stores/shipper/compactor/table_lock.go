@@ -0,0 +1,103 @@
+package compactor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/grafana/loki/pkg/storage/chunk"
+)
+
+const tableLockSuffix = ".lock"
+
+type tableLock struct {
+	Owner   string    `json:"owner"`
+	Expires time.Time `json:"expires"`
+}
+
+// tableLocker locks the tables compacted by the sharded compactors in the object store, for a table not to be
+// compacted by two instances while its ownership moves between the instances of the ring.
+type tableLocker struct {
+	objectClient chunk.ObjectClient
+	keyPrefix    string
+	owner        string
+	ttl          time.Duration
+	now          func() time.Time
+}
+
+func newTableLocker(objectClient chunk.ObjectClient, keyPrefix, owner string, ttl time.Duration) *tableLocker {
+	return &tableLocker{
+		objectClient: objectClient,
+		keyPrefix:    keyPrefix,
+		owner:        owner,
+		ttl:          ttl,
+		now:          time.Now,
+	}
+}
+
+func (l *tableLocker) key(tableName string) string {
+	return l.keyPrefix + tableName + tableLockSuffix
+}
+
+// lock acquires the lock of the table for the ttl, it fails without error when the lock is held by another instance.
+func (l *tableLocker) lock(ctx context.Context, tableName string) (bool, error) {
+	existing, err := l.get(ctx, tableName)
+	if err != nil {
+		return false, err
+	}
+	if existing != nil && existing.Owner != l.owner && l.now().Before(existing.Expires) {
+		return false, nil
+	}
+
+	buf, err := json.Marshal(tableLock{Owner: l.owner, Expires: l.now().Add(l.ttl)})
+	if err != nil {
+		return false, err
+	}
+	if err := l.objectClient.PutObject(ctx, l.key(tableName), bytes.NewReader(buf)); err != nil {
+		return false, err
+	}
+
+	// the object stores don't compare and swap, the lock is read back to detect an instance locking the table concurrently.
+	written, err := l.get(ctx, tableName)
+	if err != nil {
+		return false, err
+	}
+	return written != nil && written.Owner == l.owner, nil
+}
+
+// unlock releases the lock of the table, if still held by the instance.
+func (l *tableLocker) unlock(ctx context.Context, tableName string) error {
+	existing, err := l.get(ctx, tableName)
+	if err != nil || existing == nil || existing.Owner != l.owner {
+		return err
+	}
+	err = l.objectClient.DeleteObject(ctx, l.key(tableName))
+	if err != nil && !l.objectClient.IsObjectNotFoundErr(err) {
+		return err
+	}
+	return nil
+}
+
+func (l *tableLocker) get(ctx context.Context, tableName string) (*tableLock, error) {
+	rc, _, err := l.objectClient.GetObject(ctx, l.key(tableName))
+	if err != nil {
+		if l.objectClient.IsObjectNotFoundErr(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer rc.Close()
+
+	buf, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+	var lock tableLock
+	if err := json.Unmarshal(buf, &lock); err != nil {
+		return nil, fmt.Errorf("failed to decode the lock of the table %s: %w", tableName, err)
+	}
+	return &lock, nil
+}
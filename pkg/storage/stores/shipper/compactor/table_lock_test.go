@@ -0,0 +1,59 @@
+package compactor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/pkg/storage/chunk/local"
+)
+
+func TestTableLocker(t *testing.T) {
+	objectClient, err := local.NewFSObjectClient(local.FSConfig{Directory: t.TempDir()})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	now := time.Unix(0, 0)
+	newLocker := func(owner string) *tableLocker {
+		l := newTableLocker(objectClient, "compactor-locks/", owner, time.Hour)
+		l.now = func() time.Time { return now }
+		return l
+	}
+	a, b := newLocker("a"), newLocker("b")
+
+	ok, err := a.lock(ctx, "table1")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// the lock is held by a until it expires, a can extend it.
+	ok, err = b.lock(ctx, "table1")
+	require.NoError(t, err)
+	require.False(t, ok)
+	ok, err = a.lock(ctx, "table1")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// the other tables aren't locked.
+	ok, err = b.lock(ctx, "table2")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// b can't unlock the table locked by a.
+	require.NoError(t, b.unlock(ctx, "table1"))
+	ok, err = b.lock(ctx, "table1")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	// the expired locks are taken over.
+	now = now.Add(2 * time.Hour)
+	ok, err = b.lock(ctx, "table1")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	require.NoError(t, b.unlock(ctx, "table1"))
+	ok, err = a.lock(ctx, "table1")
+	require.NoError(t, err)
+	require.True(t, ok)
+}
@@ -20,6 +20,7 @@ import (
 	"github.com/grafana/loki/pkg/storage/stores/shipper/compactor/retention"
 	"github.com/grafana/loki/pkg/storage/stores/shipper/storage"
 	"github.com/grafana/loki/pkg/storage/stores/shipper/testutil"
+	shipper_util "github.com/grafana/loki/pkg/storage/stores/shipper/util"
 )
 
 const (
@@ -206,7 +207,7 @@ func TestTable_Compaction(t *testing.T) {
 					objectClient, err := local.NewFSObjectClient(local.FSConfig{Directory: objectStoragePath})
 					require.NoError(t, err)
 
-					table, err := newTable(context.Background(), tableWorkingDirectory, storage.NewIndexStorageClient(objectClient, ""),
+					table, err := newTable(context.Background(), tableWorkingDirectory, storage.NewIndexStorageClient(objectClient, ""), shipper_util.CompressionGzip,
 						nil, nil)
 					require.NoError(t, err)
 
@@ -255,7 +256,7 @@ func TestTable_Compaction(t *testing.T) {
 					compareCompactedTable(t, tablePathInStorage, filepath.Join(objectStoragePath, "test-copy"))
 
 					// running compaction again should not do anything.
-					table, err = newTable(context.Background(), tableWorkingDirectory, storage.NewIndexStorageClient(objectClient, ""),
+					table, err = newTable(context.Background(), tableWorkingDirectory, storage.NewIndexStorageClient(objectClient, ""), shipper_util.CompressionGzip,
 						nil, nil)
 					require.NoError(t, err)
 
@@ -368,7 +369,7 @@ func TestTable_CompactionRetention(t *testing.T) {
 				objectClient, err := local.NewFSObjectClient(local.FSConfig{Directory: objectStoragePath})
 				require.NoError(t, err)
 
-				table, err := newTable(context.Background(), tableWorkingDirectory, storage.NewIndexStorageClient(objectClient, ""),
+				table, err := newTable(context.Background(), tableWorkingDirectory, storage.NewIndexStorageClient(objectClient, ""), shipper_util.CompressionGzip,
 					tt.tableMarker, IntervalMayHaveExpiredChunksFunc(func(interval model.Interval, userID string) bool {
 						return true
 					}))
@@ -448,7 +449,7 @@ func TestTable_CompactionFailure(t *testing.T) {
 	objectClient, err := local.NewFSObjectClient(local.FSConfig{Directory: objectStoragePath})
 	require.NoError(t, err)
 
-	table, err := newTable(context.Background(), tableWorkingDirectory, storage.NewIndexStorageClient(objectClient, ""), nil, nil)
+	table, err := newTable(context.Background(), tableWorkingDirectory, storage.NewIndexStorageClient(objectClient, ""), shipper_util.CompressionGzip, nil, nil)
 	require.NoError(t, err)
 
 	// compaction should fail due to a non-boltdb file.
@@ -465,7 +466,7 @@ func TestTable_CompactionFailure(t *testing.T) {
 	// remove the non-boltdb file and ensure that compaction succeeds now.
 	require.NoError(t, os.Remove(filepath.Join(tablePathInStorage, "fail.txt")))
 
-	table, err = newTable(context.Background(), tableWorkingDirectory, storage.NewIndexStorageClient(objectClient, ""), nil, nil)
+	table, err = newTable(context.Background(), tableWorkingDirectory, storage.NewIndexStorageClient(objectClient, ""), shipper_util.CompressionGzip, nil, nil)
 	require.NoError(t, err)
 	require.NoError(t, table.compact(false))
 
@@ -473,6 +474,33 @@ func TestTable_CompactionFailure(t *testing.T) {
 	require.NoFileExists(t, tableWorkingDirectory)
 }
 
+func TestTable_CompactionCompression(t *testing.T) {
+	tempDir := t.TempDir()
+
+	objectStoragePath := filepath.Join(tempDir, objectsStorageDirName)
+	tablePathInStorage := filepath.Join(objectStoragePath, tableName)
+	tableWorkingDirectory := filepath.Join(tempDir, workingDirName, tableName)
+
+	// the source files are a mix of gzip compressed and uncompressed ones.
+	commonDBsConfig := testutil.DBsConfig{NumUnCompactedDBs: 4}
+	perUserDBsConfig := testutil.PerUserDBsConfig{DBsConfig: testutil.DBsConfig{NumUnCompactedDBs: 4}, NumUsers: 2}
+	testutil.SetupTable(t, tablePathInStorage, commonDBsConfig, perUserDBsConfig)
+	testutil.SetupTable(t, filepath.Join(objectStoragePath, fmt.Sprintf("%s-copy", tableName)), commonDBsConfig, perUserDBsConfig)
+
+	objectClient, err := local.NewFSObjectClient(local.FSConfig{Directory: objectStoragePath})
+	require.NoError(t, err)
+
+	table, err := newTable(context.Background(), tableWorkingDirectory, storage.NewIndexStorageClient(objectClient, ""), shipper_util.CompressionZstd, nil, nil)
+	require.NoError(t, err)
+	require.NoError(t, table.compact(false))
+
+	// the compacted files are compressed with the compression of the compactor.
+	validateTable(t, tablePathInStorage, 1, 2, func(filename string) {
+		require.Equal(t, shipper_util.CompressionZstd, shipper_util.FileCompression(filename))
+	})
+	compareCompactedTable(t, tablePathInStorage, filepath.Join(objectStoragePath, fmt.Sprintf("%s-copy", tableName)))
+}
+
 func compareCompactedTable(t *testing.T, srcTable, compactedTable string) {
 	require.Equal(t, readTable(t, srcTable), readTable(t, compactedTable))
 }
@@ -499,7 +527,7 @@ func readTable(t *testing.T, tablePath string) map[string]map[string]string {
 		}
 
 		filePath := filepath.Join(tablePath, fileInfo.Name())
-		if strings.HasSuffix(filePath, ".gz") {
+		if shipper_util.FileCompression(filePath) != "" {
 			filePath = filepath.Join(tempDir, fileInfo.Name())
 			testutil.DecompressFile(t, filepath.Join(tablePath, fileInfo.Name()), filePath)
 		}
@@ -553,7 +581,7 @@ func TestTable_RecreateCompactedDB(t *testing.T) {
 			assert: func(t *testing.T, storagePath, tableName string) {
 				validateTable(t, filepath.Join(storagePath, tableName), 1, 10, func(filename string) {
 					require.True(t, strings.HasSuffix(filename, ".gz"))
-					require.False(t, strings.HasSuffix(filename, recreatedCompactedDBSuffix))
+					require.False(t, strings.HasSuffix(shipper_util.UncompressedFileName(filename), recreatedCompactedDBSuffix))
 				})
 				compareCompactedTable(t, filepath.Join(storagePath, tableName), filepath.Join(storagePath, fmt.Sprintf("%s-copy", tableName)))
 			},
@@ -570,7 +598,7 @@ func TestTable_RecreateCompactedDB(t *testing.T) {
 			assert: func(t *testing.T, storagePath, tableName string) {
 				validateTable(t, filepath.Join(storagePath, tableName), 1, 10, func(filename string) {
 					require.True(t, strings.HasSuffix(filename, ".gz"))
-					require.False(t, strings.HasSuffix(filename, recreatedCompactedDBSuffix))
+					require.False(t, strings.HasSuffix(shipper_util.UncompressedFileName(filename), recreatedCompactedDBSuffix))
 				})
 				compareCompactedTable(t, filepath.Join(storagePath, tableName), filepath.Join(storagePath, fmt.Sprintf("%s-copy", tableName)))
 			},
@@ -584,7 +612,7 @@ func TestTable_RecreateCompactedDB(t *testing.T) {
 			assert: func(t *testing.T, storagePath, tableName string) {
 				validateTable(t, filepath.Join(storagePath, tableName), 1, 10, func(filename string) {
 					require.True(t, strings.HasSuffix(filename, ".gz"))
-					require.False(t, strings.HasSuffix(filename, recreatedCompactedDBSuffix))
+					require.False(t, strings.HasSuffix(shipper_util.UncompressedFileName(filename), recreatedCompactedDBSuffix))
 				})
 				compareCompactedTable(t, filepath.Join(storagePath, tableName), filepath.Join(storagePath, fmt.Sprintf("%s-copy", tableName)))
 			},
@@ -615,7 +643,7 @@ func TestTable_RecreateCompactedDB(t *testing.T) {
 			dbCount: 1,
 			assert: func(t *testing.T, storagePath, tableName string) {
 				validateTable(t, filepath.Join(storagePath, tableName), 1, 10, func(filename string) {
-					require.True(t, strings.HasSuffix(filename, recreatedCompactedDBSuffix))
+					require.True(t, strings.HasSuffix(shipper_util.UncompressedFileName(filename), recreatedCompactedDBSuffix))
 				})
 				compareCompactedTable(t, filepath.Join(storagePath, tableName), filepath.Join(storagePath, fmt.Sprintf("%s-copy", tableName)))
 			},
@@ -672,7 +700,7 @@ func TestTable_RecreateCompactedDB(t *testing.T) {
 			objectClient, err := local.NewFSObjectClient(local.FSConfig{Directory: objectStoragePath})
 			require.NoError(t, err)
 
-			table, err := newTable(context.Background(), tableWorkingDirectory, storage.NewIndexStorageClient(objectClient, ""),
+			table, err := newTable(context.Background(), tableWorkingDirectory, storage.NewIndexStorageClient(objectClient, ""), shipper_util.CompressionGzip,
 				tt.tableMarker, IntervalMayHaveExpiredChunksFunc(func(interval model.Interval, userID string) bool {
 					return true
 				}))
@@ -697,7 +725,7 @@ func TestTable_RecreateCompactedDB(t *testing.T) {
 				})
 				require.NoError(t, err)
 
-				table, err := newTable(context.Background(), tableWorkingDirectory, storage.NewIndexStorageClient(objectClient, ""),
+				table, err := newTable(context.Background(), tableWorkingDirectory, storage.NewIndexStorageClient(objectClient, ""), shipper_util.CompressionGzip,
 					tt.tableMarker, IntervalMayHaveExpiredChunksFunc(func(interval model.Interval, userID string) bool {
 						return true
 					}))
@@ -0,0 +1,233 @@
+package compactor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"go.etcd.io/bbolt"
+
+	loki_storage "github.com/grafana/loki/pkg/storage"
+	"github.com/grafana/loki/pkg/storage/chunk"
+	"github.com/grafana/loki/pkg/storage/chunk/local"
+	chunk_util "github.com/grafana/loki/pkg/storage/chunk/util"
+	"github.com/grafana/loki/pkg/storage/stores/shipper/compactor/retention"
+	shipper_storage "github.com/grafana/loki/pkg/storage/stores/shipper/storage"
+	shipper_util "github.com/grafana/loki/pkg/storage/stores/shipper/util"
+	"github.com/grafana/loki/pkg/tenant"
+	"github.com/grafana/loki/pkg/util"
+	util_log "github.com/grafana/loki/pkg/util/log"
+	serverutil "github.com/grafana/loki/pkg/util/server"
+)
+
+const undeleteUploaderName = "undelete"
+
+// UndeleteResult is the result of an undelete.
+type UndeleteResult struct {
+	// Undeleted is the number of chunks indexed again.
+	Undeleted int `json:"undeleted"`
+	// Missing is the number of chunks marked which were already deleted.
+	Missing int `json:"missing"`
+	// SkippedMarkerFiles is the number of marker files skipped as they were being written by the retention.
+	SkippedMarkerFiles int `json:"skipped_marker_files"`
+}
+
+// undeleter restores the chunks marked for deletion by the retention and not swept yet: it uploads index files
+// indexing them again in their tables, and deletes their marks.
+type undeleter struct {
+	workingDir         string
+	compression        string
+	schemaConfig       loki_storage.SchemaConfig
+	indexStorageClient shipper_storage.Client
+	chunkClient        chunk.Client
+	sweeper            *retention.Sweeper
+	logger             log.Logger
+}
+
+func newUndeleter(cfg Config, schemaConfig loki_storage.SchemaConfig, indexStorageClient shipper_storage.Client,
+	chunkClient chunk.Client, sweeper *retention.Sweeper) (*undeleter, error) {
+	workingDir := filepath.Join(cfg.WorkingDirectory, "undelete")
+	if err := chunk_util.EnsureDirectory(workingDir); err != nil {
+		return nil, err
+	}
+	return &undeleter{
+		workingDir:         workingDir,
+		compression:        cfg.IndexCompression,
+		schemaConfig:       schemaConfig,
+		indexStorageClient: indexStorageClient,
+		chunkClient:        chunkClient,
+		sweeper:            sweeper,
+		logger:             log.With(util_log.Logger, "component", "undeleter"),
+	}, nil
+}
+
+// undelete restores the chunks of the user overlapping the interval marked for deletion.
+func (u *undeleter) undelete(ctx context.Context, userID string, from, through model.Time) (UndeleteResult, error) {
+	var result UndeleteResult
+	skipped, err := u.sweeper.Undelete(ctx, userID, from, through, func(ctx context.Context, chunkIDs [][]byte) error {
+		return u.restore(ctx, userID, chunkIDs, &result)
+	})
+	result.SkippedMarkerFiles = skipped
+	if err != nil {
+		return result, err
+	}
+	level.Info(u.logger).Log("msg", "undeleted chunks", "user", userID, "undeleted", result.Undeleted, "missing", result.Missing, "skipped_marker_files", skipped)
+	return result, nil
+}
+
+// restore uploads an index file indexing the chunks in each of their tables.
+func (u *undeleter) restore(ctx context.Context, userID string, chunkIDs [][]byte, result *UndeleteResult) error {
+	dbs := map[string]*bbolt.DB{}
+	paths := map[string]string{}
+	defer func() {
+		for tableName, db := range dbs {
+			_ = db.Close()
+			_ = os.Remove(paths[tableName])
+		}
+	}()
+
+	for _, chunkID := range chunkIDs {
+		externalKey := string(chunkID)
+		c, err := chunk.ParseExternalKey(userID, externalKey)
+		if err != nil {
+			return err
+		}
+		// the labels of the chunk are read from the chunk.
+		chunks, err := u.chunkClient.GetChunks(ctx, []chunk.Chunk{c})
+		if u.chunkClient.IsChunkNotFoundErr(errors.Cause(err)) {
+			result.Missing++
+			continue
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to get chunk %s", externalKey)
+		}
+		if len(chunks) != 1 {
+			return fmt.Errorf("expected 1 entry for chunk %s but found %d in storage", externalKey, len(chunks))
+		}
+
+		entries, err := u.indexEntries(chunks[0], externalKey)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			db, ok := dbs[entry.TableName]
+			if !ok {
+				path := filepath.Join(u.workingDir, fmt.Sprintf("%s-%d", entry.TableName, time.Now().UnixNano()))
+				db, err = shipper_util.SafeOpenBoltdbFile(path)
+				if err != nil {
+					return err
+				}
+				dbs[entry.TableName], paths[entry.TableName] = db, path
+			}
+			err := db.Update(func(tx *bbolt.Tx) error {
+				bucket, err := tx.CreateBucketIfNotExists(local.IndexBucketName)
+				if err != nil {
+					return err
+				}
+				return bucket.Put([]byte(entry.HashValue+"\000"+string(entry.RangeValue)), entry.Value)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		result.Undeleted++
+	}
+
+	for tableName, db := range dbs {
+		fileName := shipper_util.BuildIndexFileName(tableName, undeleteUploaderName, fmt.Sprint(time.Now().UnixNano()))
+		fileName = shipper_util.CompressedFileName(fileName, u.compression)
+		if err := db.Close(); err != nil {
+			return err
+		}
+		err := uploadFile(paths[tableName], u.compression, func(file io.ReadSeeker) error {
+			return u.indexStorageClient.PutFile(ctx, tableName, fileName, file)
+		}, u.logger)
+		if err != nil {
+			return errors.Wrapf(err, "failed to upload the index file of table %s", tableName)
+		}
+	}
+	return nil
+}
+
+// indexEntries returns the index entries of the chunk and of its series.
+func (u *undeleter) indexEntries(c chunk.Chunk, externalKey string) ([]chunk.IndexEntry, error) {
+	period, err := u.schemaConfig.SchemaForTime(c.From)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := period.CreateSchema()
+	if err != nil {
+		return nil, err
+	}
+	seriesSchema, ok := schema.(chunk.SeriesStoreSchema)
+	if !ok {
+		return nil, fmt.Errorf("unsupported schema %s", period.Schema)
+	}
+
+	entries, err := seriesSchema.GetChunkWriteEntries(c.From, c.Through, c.UserID, "logs", c.Metric, externalKey)
+	if err != nil {
+		return nil, err
+	}
+	_, labelEntries, err := seriesSchema.GetCacheKeysAndLabelWriteEntries(c.From, c.Through, c.UserID, "logs", c.Metric, externalKey)
+	if err != nil {
+		return nil, err
+	}
+	for _, batch := range labelEntries {
+		entries = append(entries, batch...)
+	}
+	return entries, nil
+}
+
+// UndeleteHandler undeletes the chunks of the tenant marked for deletion by the retention, and not swept yet, overlapping
+// the interval of the start and end parameters.
+func (c *Compactor) UndeleteHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, err := tenant.TenantID(ctx)
+	if err != nil {
+		serverutil.JSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if c.undeleter == nil {
+		serverutil.JSONError(w, http.StatusBadRequest, "retention is not enabled")
+		return
+	}
+
+	params := r.URL.Query()
+	startTime, endTime := int64(0), int64(model.Now())
+	if start := params.Get("start"); start != "" {
+		if startTime, err = util.ParseTime(start); err != nil {
+			serverutil.JSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if end := params.Get("end"); end != "" {
+		if endTime, err = util.ParseTime(end); err != nil {
+			serverutil.JSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if startTime > endTime {
+		serverutil.JSONError(w, http.StatusBadRequest, "start time can't be greater than end time")
+		return
+	}
+
+	result, err := c.undeleter.undelete(ctx, userID, model.Time(startTime), model.Time(endTime))
+	if err != nil {
+		level.Error(util_log.Logger).Log("msg", "error undeleting chunks", "user", userID, "err", err)
+		serverutil.JSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		level.Error(util_log.Logger).Log("msg", "error marshalling the undelete result", "err", err)
+	}
+}
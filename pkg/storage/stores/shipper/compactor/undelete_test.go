@@ -0,0 +1,69 @@
+package compactor
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/pkg/storage/chunk"
+	"github.com/grafana/loki/pkg/storage/stores/shipper/compactor/retention"
+	shipper_storage "github.com/grafana/loki/pkg/storage/stores/shipper/storage"
+)
+
+func TestUndeleter(t *testing.T) {
+	s := newScrubberTestStore(t)
+	ctx := context.Background()
+	cfg := Config{WorkingDirectory: filepath.Join(s.dir, "compactor"), IndexCompression: "gzip"}
+	retentionDir := filepath.Join(cfg.WorkingDirectory, "retention")
+
+	day := time.Now().Add(-72*time.Hour).Unix() / 86400
+	tableName := fmt.Sprintf("index_%d", day)
+	from := model.TimeFromUnix(day * 86400).Add(time.Hour)
+	marked := s.newChunk("fake", labels.Labels{{Name: "app", Value: "marked"}}, from)
+	swept := s.newChunk("fake", labels.Labels{{Name: "app", Value: "swept"}}, from)
+	other := s.newChunk("other", labels.Labels{{Name: "app", Value: "other"}}, from)
+	require.NoError(t, s.chunkClient.DeleteChunk(ctx, "fake", s.schemaCfg.ExternalKey(swept)))
+
+	w, err := retention.NewMarkerStorageWriter(retentionDir)
+	require.NoError(t, err)
+	for _, c := range []chunk.Chunk{marked, swept, other} {
+		require.NoError(t, w.Put([]byte(c.UserID), []byte(s.schemaCfg.ExternalKey(c))))
+	}
+	require.NoError(t, w.Close())
+
+	sweeper, err := retention.NewSweeper(retentionDir, s.chunkClient, 1, time.Hour, nil)
+	require.NoError(t, err)
+	indexStorageClient := shipper_storage.NewIndexStorageClient(s.objectClient, "index/")
+	u, err := newUndeleter(cfg, s.schemaCfg, indexStorageClient, s.chunkClient, sweeper)
+	require.NoError(t, err)
+
+	// the chunks out of the interval aren't undeleted.
+	result, err := u.undelete(ctx, "fake", 0, from-1)
+	require.NoError(t, err)
+	require.Equal(t, UndeleteResult{}, result)
+
+	result, err = u.undelete(ctx, "fake", 0, model.Now())
+	require.NoError(t, err)
+	require.Equal(t, UndeleteResult{Undeleted: 1, Missing: 1}, result)
+
+	var indexed []string
+	require.NoError(t, forEachTableChunk(ctx, filepath.Join(s.dir, "scrub"), indexStorageClient, s.schemaCfg, tableName, log.NewNopLogger(), func(entry retention.ChunkEntry) {
+		indexed = append(indexed, string(entry.ChunkID))
+	}))
+	require.Equal(t, []string{s.schemaCfg.ExternalKey(marked)}, indexed)
+
+	// the marks of the chunks undeleted are deleted.
+	result, err = u.undelete(ctx, "fake", 0, model.Now())
+	require.NoError(t, err)
+	require.Equal(t, UndeleteResult{}, result)
+	result, err = u.undelete(ctx, "other", 0, model.Now())
+	require.NoError(t, err)
+	require.Equal(t, UndeleteResult{Undeleted: 1}, result)
+}
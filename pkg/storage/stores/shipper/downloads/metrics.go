@@ -14,6 +14,7 @@ type metrics struct {
 	queryTimeTableDownloadDurationSeconds  *prometheus.CounterVec
 	tablesSyncOperationTotal               *prometheus.CounterVec
 	tablesDownloadOperationDurationSeconds prometheus.Gauge
+	tablesDownloadQueueLength              *prometheus.GaugeVec
 }
 
 func newMetrics(r prometheus.Registerer) *metrics {
@@ -33,6 +34,11 @@ func newMetrics(r prometheus.Registerer) *metrics {
 			Name:      "tables_download_operation_duration_seconds",
 			Help:      "Time (in seconds) spent in downloading updated files for all the tables",
 		}),
+		tablesDownloadQueueLength: promauto.With(r).NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "loki_boltdb_shipper",
+			Name:      "tables_download_queue_length",
+			Help:      "Number of tables waiting to be downloaded or queried by queue, query or prefetch",
+		}, []string{"queue"}),
 	}
 
 	return m
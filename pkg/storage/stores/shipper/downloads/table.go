@@ -157,13 +157,20 @@ func (t *table) MultiQueries(ctx context.Context, queries []chunk.IndexQuery, ca
 		return err
 	}
 
-	// query both user and common index
-	for _, uid := range []string{userID, ""} {
+	// get both user and common index first, for them to be downloaded concurrently when missing.
+	uids := []string{userID, ""}
+	indexSets := make([]IndexSet, 0, len(uids))
+	for _, uid := range uids {
 		indexSet, err := t.getOrCreateIndexSet(ctx, uid, true)
 		if err != nil {
 			return err
 		}
+		indexSets = append(indexSets, indexSet)
+	}
 
+	// query both user and common index
+	for i, uid := range uids {
+		indexSet := indexSets[i]
 		if indexSet.Err() != nil {
 			level.Error(util_log.WithContext(ctx, t.logger)).Log("msg", fmt.Sprintf("index set %s has some problem, cleaning it up", uid), "err", indexSet.Err())
 			if err := indexSet.DropAllDBs(); err != nil {
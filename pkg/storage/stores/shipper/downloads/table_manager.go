@@ -11,12 +11,15 @@ import (
 	"time"
 
 	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/concurrency"
 	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/atomic"
 
 	"github.com/grafana/loki/pkg/storage/chunk"
 	chunk_util "github.com/grafana/loki/pkg/storage/chunk/util"
 	"github.com/grafana/loki/pkg/storage/stores/shipper/storage"
 	"github.com/grafana/loki/pkg/storage/stores/shipper/util"
+	"github.com/grafana/loki/pkg/tenant"
 	util_log "github.com/grafana/loki/pkg/util/log"
 	"github.com/grafana/loki/pkg/validation"
 )
@@ -24,6 +27,10 @@ import (
 const (
 	cacheCleanupInterval = time.Hour
 	durationDay          = 24 * time.Hour
+	prefetchQueueSize    = 100
+
+	queueQuery    = "query"
+	queuePrefetch = "prefetch"
 )
 
 type Limits interface {
@@ -36,7 +43,15 @@ type Config struct {
 	SyncInterval      time.Duration
 	CacheTTL          time.Duration
 	QueryReadyNumDays int
+	// DownloadParallelism is the number of tables downloaded concurrently, at query time, for the query readiness and
+	// for the prefetch.
+	DownloadParallelism int
+	// PrefetchNumTables is the number of tables preceding the ones queried by a tenant which are downloaded in the
+	// background, 0 to disable the prefetch.
+	PrefetchNumTables int
 	Limits            Limits
+	// OwnsTenant filters the tenants whose index is kept query ready, all of them when nil.
+	OwnsTenant func(userID string) bool
 }
 
 type TableManager struct {
@@ -48,6 +63,8 @@ type TableManager struct {
 	tablesMtx sync.RWMutex
 	metrics   *metrics
 
+	prefetchQueue chan prefetchRequest
+
 	ctx    context.Context
 	cancel context.CancelFunc
 	wg     sync.WaitGroup
@@ -58,6 +75,10 @@ func NewTableManager(cfg Config, boltIndexClient BoltDBIndexClient, indexStorage
 		return nil, err
 	}
 
+	if cfg.DownloadParallelism <= 0 {
+		cfg.DownloadParallelism = 1
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	tm := &TableManager{
 		cfg:                cfg,
@@ -65,10 +86,13 @@ func NewTableManager(cfg Config, boltIndexClient BoltDBIndexClient, indexStorage
 		indexStorageClient: indexStorageClient,
 		tables:             make(map[string]Table),
 		metrics:            newMetrics(registerer),
+		prefetchQueue:      make(chan prefetchRequest, prefetchQueueSize),
 		ctx:                ctx,
 		cancel:             cancel,
 	}
 
+	start := time.Now()
+
 	// load the existing tables first.
 	err := tm.loadLocalTables()
 	if err != nil {
@@ -77,6 +101,14 @@ func NewTableManager(cfg Config, boltIndexClient BoltDBIndexClient, indexStorage
 		return nil, err
 	}
 
+	// warm up the tables loaded, for the queries not to be served from the index cached before the restart.
+	err = tm.syncTables(ctx)
+	if err != nil {
+		// call Stop to close open file references.
+		tm.Stop()
+		return nil, err
+	}
+
 	// download the missing tables.
 	err = tm.ensureQueryReadiness(ctx)
 	if err != nil {
@@ -85,6 +117,15 @@ func NewTableManager(cfg Config, boltIndexClient BoltDBIndexClient, indexStorage
 		return nil, err
 	}
 
+	level.Info(util_log.Logger).Log("msg", "warmed up the index tables", "duration", time.Since(start))
+
+	if cfg.PrefetchNumTables > 0 {
+		for i := 0; i < cfg.DownloadParallelism; i++ {
+			tm.wg.Add(1)
+			go tm.prefetchLoop()
+		}
+	}
+
 	go tm.loop()
 	return tm, nil
 }
@@ -135,16 +176,32 @@ func (tm *TableManager) Stop() {
 	}
 }
 
+// QueryPages queries the tables concurrently, downloading the ones missing, and queues the prefetch of the tables
+// preceding them.
 func (tm *TableManager) QueryPages(ctx context.Context, queries []chunk.IndexQuery, callback chunk.QueryPagesCallback) error {
 	queriesByTable := util.QueriesByTable(queries)
-	for tableName, queries := range queriesByTable {
-		err := tm.query(ctx, tableName, queries, callback)
-		if err != nil {
-			return err
-		}
+	tableNames := make([]string, 0, len(queriesByTable))
+	for tableName := range queriesByTable {
+		tableNames = append(tableNames, tableName)
 	}
 
-	return nil
+	if tm.cfg.PrefetchNumTables > 0 {
+		tm.queuePrefetch(ctx, tableNames)
+	}
+
+	queueLength := tm.metrics.tablesDownloadQueueLength.WithLabelValues(queueQuery)
+	queueLength.Add(float64(len(tableNames)))
+	dequeued := atomic.NewInt64(0)
+	defer func() {
+		// the tables not dequeued when a query failed are removed from the queue.
+		queueLength.Sub(float64(int64(len(tableNames)) - dequeued.Load()))
+	}()
+
+	return concurrency.ForEachJob(ctx, len(tableNames), tm.cfg.DownloadParallelism, func(ctx context.Context, idx int) error {
+		dequeued.Inc()
+		queueLength.Dec()
+		return tm.query(ctx, tableNames[idx], queriesByTable[tableNames[idx]], callback)
+	})
 }
 
 func (tm *TableManager) query(ctx context.Context, tableName string, queries []chunk.IndexQuery, callback chunk.QueryPagesCallback) error {
@@ -208,14 +265,15 @@ func (tm *TableManager) syncTables(ctx context.Context) error {
 
 	level.Info(util_log.Logger).Log("msg", "syncing tables")
 
+	tables := make([]Table, 0, len(tm.tables))
 	for _, table := range tm.tables {
-		err = table.Sync(ctx)
-		if err != nil {
-			return err
-		}
+		tables = append(tables, table)
 	}
 
-	return nil
+	err = concurrency.ForEachJob(ctx, len(tables), tm.cfg.DownloadParallelism, func(ctx context.Context, idx int) error {
+		return tables[idx].Sync(ctx)
+	})
+	return err
 }
 
 func (tm *TableManager) cleanupCache() error {
@@ -275,6 +333,8 @@ func (tm *TableManager) ensureQueryReadiness(ctx context.Context) error {
 		return err
 	}
 
+	var tablesToBeQueryReady []string
+	var tableNumbers []int64
 	for _, tableName := range tables {
 		if !re.MatchString(tableName) {
 			continue
@@ -290,6 +350,13 @@ func (tm *TableManager) ensureQueryReadiness(ctx context.Context) error {
 			continue
 		}
 
+		tablesToBeQueryReady = append(tablesToBeQueryReady, tableName)
+		tableNumbers = append(tableNumbers, tableNumber)
+	}
+
+	return concurrency.ForEachJob(ctx, len(tablesToBeQueryReady), tm.cfg.DownloadParallelism, func(ctx context.Context, idx int) error {
+		tableName, tableNumber := tablesToBeQueryReady[idx], tableNumbers[idx]
+
 		// list the users that have dedicated index files for this table
 		_, usersWithIndex, err := tm.indexStorageClient.ListFiles(ctx, tableName)
 		if err != nil {
@@ -299,9 +366,9 @@ func (tm *TableManager) ensureQueryReadiness(ctx context.Context) error {
 		// find the users whos index we need to keep ready for querying from this table
 		usersToBeQueryReadyFor := tm.findUsersInTableForQueryReadiness(tableNumber, usersWithIndex, queryReadinessNumByUserID)
 
-		// continue if both user index and common index is not required to be downloaded for query readiness
+		// return early if both user index and common index is not required to be downloaded for query readiness
 		if len(usersToBeQueryReadyFor) == 0 && activeTableNumber-tableNumber > int64(tm.cfg.QueryReadyNumDays) {
-			continue
+			return nil
 		}
 
 		table, err := tm.getOrCreateTable(tableName)
@@ -309,12 +376,91 @@ func (tm *TableManager) ensureQueryReadiness(ctx context.Context) error {
 			return err
 		}
 
-		if err := table.EnsureQueryReadiness(ctx, usersToBeQueryReadyFor); err != nil {
-			return err
+		return table.EnsureQueryReadiness(ctx, usersToBeQueryReadyFor)
+	})
+}
+
+type prefetchRequest struct {
+	tableName string
+	userID    string
+}
+
+// queuePrefetch queues the prefetch of the tables preceding the oldest of the tables queried by the tenant, the
+// queries of a range being likely to be followed by the ones of the range before it.
+func (tm *TableManager) queuePrefetch(ctx context.Context, tableNames []string) {
+	userID, err := tenant.TenantID(ctx)
+	if err != nil {
+		return
+	}
+
+	oldestTableNumberByPrefix := map[string]int64{}
+	for _, tableName := range tableNames {
+		prefix, tableNumber, ok := splitTableName(tableName)
+		if !ok {
+			continue
+		}
+		if oldest, ok := oldestTableNumberByPrefix[prefix]; !ok || tableNumber < oldest {
+			oldestTableNumberByPrefix[prefix] = tableNumber
 		}
 	}
 
-	return nil
+	queueLength := tm.metrics.tablesDownloadQueueLength.WithLabelValues(queuePrefetch)
+	for prefix, oldest := range oldestTableNumberByPrefix {
+		for i := int64(1); i <= int64(tm.cfg.PrefetchNumTables) && oldest-i >= 0; i++ {
+			req := prefetchRequest{tableName: fmt.Sprintf("%s%d", prefix, oldest-i), userID: userID}
+			select {
+			case tm.prefetchQueue <- req:
+				queueLength.Inc()
+			default:
+				level.Debug(util_log.Logger).Log("msg", "prefetch queue is full, skipping the prefetch of table", "table-name", req.tableName)
+				return
+			}
+		}
+	}
+}
+
+func (tm *TableManager) prefetchLoop() {
+	defer tm.wg.Done()
+
+	queueLength := tm.metrics.tablesDownloadQueueLength.WithLabelValues(queuePrefetch)
+	for {
+		select {
+		case req := <-tm.prefetchQueue:
+			queueLength.Dec()
+			if err := tm.prefetch(tm.ctx, req); err != nil {
+				level.Error(util_log.Logger).Log("msg", "failed to prefetch table", "table-name", req.tableName, "user-id", req.userID, "err", err)
+			}
+		case <-tm.ctx.Done():
+			return
+		}
+	}
+}
+
+// prefetch downloads the common index and the index of the user of the table.
+func (tm *TableManager) prefetch(ctx context.Context, req prefetchRequest) error {
+	table, err := tm.getOrCreateTable(req.tableName)
+	if err != nil {
+		return err
+	}
+
+	return table.EnsureQueryReadiness(ctx, []string{req.userID})
+}
+
+// splitTableName splits the name of a periodic table into its prefix and its number.
+func splitTableName(tableName string) (string, int64, bool) {
+	idx := len(tableName)
+	for idx > 0 && tableName[idx-1] >= '0' && tableName[idx-1] <= '9' {
+		idx--
+	}
+	if idx == len(tableName) {
+		return "", 0, false
+	}
+
+	tableNumber, err := strconv.ParseInt(tableName[idx:], 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return tableName[:idx], tableNumber, true
 }
 
 // findUsersInTableForQueryReadiness returns the users that needs their index to be query ready based on the tableNumber and
@@ -325,6 +471,10 @@ func (tm *TableManager) findUsersInTableForQueryReadiness(tableNumber int64, use
 	usersToBeQueryReadyFor := []string{}
 
 	for _, userID := range usersWithIndexInTable {
+		if tm.cfg.OwnsTenant != nil && !tm.cfg.OwnsTenant(userID) {
+			continue
+		}
+
 		// use the query readiness config for the user if it exists or use the default config
 		queryReadyNumDays, ok := queryReadinessNumByUserID[userID]
 		if !ok {
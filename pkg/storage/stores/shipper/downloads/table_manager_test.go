@@ -8,6 +8,7 @@ import (
 	"testing"
 	"time"
 
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
 
 	"github.com/grafana/loki/pkg/storage/chunk"
@@ -83,6 +84,77 @@ func TestTableManager_QueryPages(t *testing.T) {
 	})
 }
 
+func TestTableManager_Prefetch(t *testing.T) {
+	tempDir := t.TempDir()
+	objectStoragePath := filepath.Join(tempDir, objectsStorageDirName)
+
+	var queries []chunk.IndexQuery
+	for i, name := range []string{"table_19000", "table_19001", "table_19002", "table_19003", "table_19004"} {
+		testutil.SetupTable(t, filepath.Join(objectStoragePath, name), testutil.DBsConfig{
+			NumUnCompactedDBs: 5,
+			DBRecordsStart:    i * 1000,
+		}, testutil.PerUserDBsConfig{
+			DBsConfig: testutil.DBsConfig{
+				NumUnCompactedDBs: 5,
+				DBRecordsStart:    i*1000 + 500,
+			},
+			NumUsers: 1,
+		})
+		if i >= 3 {
+			queries = append(queries, chunk.IndexQuery{TableName: name})
+		}
+	}
+
+	boltDBIndexClient, indexStorageClient := buildTestClients(t, tempDir)
+	defer boltDBIndexClient.Stop()
+	tableManager, err := NewTableManager(Config{
+		CacheDir:            filepath.Join(tempDir, cacheDirName),
+		SyncInterval:        time.Hour,
+		CacheTTL:            time.Hour,
+		DownloadParallelism: 2,
+		PrefetchNumTables:   2,
+		Limits:              &mockLimits{},
+	}, boltDBIndexClient, indexStorageClient, nil)
+	require.NoError(t, err)
+	defer tableManager.Stop()
+
+	// the tables are queried concurrently.
+	testutil.TestMultiTableQuery(t, testutil.BuildUserID(0), queries, tableManager, 3000, 2000)
+	require.Equal(t, float64(0), promtestutil.ToFloat64(tableManager.metrics.tablesDownloadQueueLength.WithLabelValues(queueQuery)))
+
+	// the 2 tables preceding the oldest table queried are prefetched.
+	require.Eventually(t, func() bool {
+		return promtestutil.ToFloat64(tableManager.metrics.tablesDownloadQueueLength.WithLabelValues(queuePrefetch)) == 0 &&
+			len(tableManager.tables) == 4
+	}, 10*time.Second, 10*time.Millisecond)
+
+	tableManager.tablesMtx.RLock()
+	_, ok := tableManager.tables["table_19000"]
+	tableManager.tablesMtx.RUnlock()
+	require.False(t, ok)
+
+	// the prefetched tables are queried without downloading them.
+	testutil.TestMultiTableQuery(t, testutil.BuildUserID(0), []chunk.IndexQuery{{TableName: "table_19001"}, {TableName: "table_19002"}}, tableManager, 1000, 2000)
+}
+
+func TestSplitTableName(t *testing.T) {
+	for tableName, expected := range map[string]struct {
+		prefix      string
+		tableNumber int64
+		ok          bool
+	}{
+		"index_19000": {prefix: "index_", tableNumber: 19000, ok: true},
+		"19000":       {prefix: "", tableNumber: 19000, ok: true},
+		"index_":      {},
+		"table1a":     {},
+	} {
+		prefix, tableNumber, ok := splitTableName(tableName)
+		require.Equal(t, expected.ok, ok, tableName)
+		require.Equal(t, expected.prefix, prefix, tableName)
+		require.Equal(t, expected.tableNumber, tableNumber, tableName)
+	}
+}
+
 func TestTableManager_cleanupCache(t *testing.T) {
 	tempDir := t.TempDir()
 
@@ -121,8 +193,9 @@ func TestTableManager_ensureQueryReadiness(t *testing.T) {
 	}
 
 	cfg := Config{
-		SyncInterval: time.Hour,
-		CacheTTL:     time.Hour,
+		SyncInterval:        time.Hour,
+		CacheTTL:            time.Hour,
+		DownloadParallelism: 2,
 	}
 
 	tableManager := &TableManager{
@@ -159,6 +232,7 @@ func TestTableManager_ensureQueryReadiness(t *testing.T) {
 		name                 string
 		queryReadyNumDaysCfg int
 		queryReadinessLimits mockLimits
+		ownsTenant           func(userID string) bool
 
 		expectedQueryReadinessDoneForUsers map[string][]string
 	}{
@@ -257,11 +331,26 @@ func TestTableManager_ensureQueryReadiness(t *testing.T) {
 				buildTableName(3): {"user2"},
 			},
 		},
+		{
+			name: "user index default: 2 days, only user2 owned",
+			queryReadinessLimits: mockLimits{
+				queryReadyIndexNumDaysDefault: 2,
+			},
+			ownsTenant: func(userID string) bool {
+				return userID == "user2"
+			},
+			expectedQueryReadinessDoneForUsers: map[string][]string{
+				buildTableName(0): {"user2"},
+				buildTableName(1): {"user2"},
+				buildTableName(2): {"user2"},
+			},
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			resetTables()
 			tableManager.cfg.QueryReadyNumDays = tc.queryReadyNumDaysCfg
 			tableManager.cfg.Limits = &tc.queryReadinessLimits
+			tableManager.cfg.OwnsTenant = tc.ownsTenant
 			require.NoError(t, tableManager.ensureQueryReadiness(context.Background()))
 
 			for name, table := range tableManager.tables {
@@ -4,20 +4,31 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"hash/fnv"
 	"io"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/go-kit/log/level"
 	"github.com/grafana/dskit/concurrency"
 	"github.com/grafana/dskit/grpcclient"
+	"github.com/grafana/dskit/ring"
+	ring_client "github.com/grafana/dskit/ring/client"
+	"github.com/grafana/dskit/services"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/thanos-io/thanos/pkg/discovery/dns"
 	"github.com/weaveworks/common/instrument"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health/grpc_health_v1"
 
 	"github.com/grafana/loki/pkg/storage/chunk"
+	"github.com/grafana/loki/pkg/storage/stores/shipper/indexgateway"
 	"github.com/grafana/loki/pkg/storage/stores/shipper/indexgateway/indexgatewaypb"
 	shipper_util "github.com/grafana/loki/pkg/storage/stores/shipper/util"
+	"github.com/grafana/loki/pkg/tenant"
 	util_log "github.com/grafana/loki/pkg/util/log"
 	util_math "github.com/grafana/loki/pkg/util/math"
 )
@@ -30,6 +41,12 @@ const (
 type IndexGatewayClientConfig struct {
 	Address          string            `yaml:"server_address,omitempty"`
 	GRPCClientConfig grpcclient.Config `yaml:"grpc_client_config"`
+	DNSLookupPeriod  time.Duration     `yaml:"dns_lookup_period"`
+
+	// Mode, Ring and Limits are injected when the index gateways run in ring mode.
+	Mode   indexgateway.Mode   `yaml:"-"`
+	Ring   ring.ReadRing       `yaml:"-"`
+	Limits indexgateway.Limits `yaml:"-"`
 }
 
 // RegisterFlags registers flags.
@@ -41,15 +58,29 @@ func (cfg *IndexGatewayClientConfig) RegisterFlags(f *flag.FlagSet) {
 func (cfg *IndexGatewayClientConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
 	cfg.GRPCClientConfig.RegisterFlagsWithPrefix(prefix, f)
 
-	f.StringVar(&cfg.Address, prefix+".server-address", "", "Hostname or IP of the Index Gateway gRPC server.")
+	f.StringVar(&cfg.Address, prefix+".server-address", "", "Hostname or IP of the Index Gateway gRPC server. "+
+		"Use a DNS service discovery address, e.g. dns+index-gateway:9095, to pool the connections to all the instances of the Index Gateway, the queries of a tenant being sent to the same instance.")
+	f.DurationVar(&cfg.DNSLookupPeriod, prefix+".dns-lookup-period", 10*time.Second, "How often the DNS service discovery address of the Index Gateway is resolved.")
+}
+
+// Validate validates the config.
+func (cfg *IndexGatewayClientConfig) Validate() error {
+	if qtype, _ := dns.GetQTypeName(cfg.Address); qtype != "" && cfg.DNSLookupPeriod <= 0 {
+		return errors.New("the DNS lookup period of the index gateway client must be positive")
+	}
+	return nil
 }
 
 type GatewayClient struct {
 	cfg IndexGatewayClientConfig
 
 	storeGatewayClientRequestDuration *prometheus.HistogramVec
-	conn                              *grpc.ClientConn
-	grpcClient                        indexgatewaypb.IndexGatewayClient
+	dialOpts                          []grpc.DialOption
+	dnsProvider                       *dns.Provider
+	pool                              *ring_client.Pool
+
+	quit chan struct{}
+	wait sync.WaitGroup
 }
 
 func NewGatewayClient(cfg IndexGatewayClientConfig, r prometheus.Registerer) (*GatewayClient, error) {
@@ -61,24 +92,101 @@ func NewGatewayClient(cfg IndexGatewayClientConfig, r prometheus.Registerer) (*G
 			Help:      "Time (in seconds) spent serving requests when using boltdb shipper store gateway",
 			Buckets:   instrument.DefBuckets,
 		}, []string{"operation", "status_code"}),
+		dnsProvider: dns.NewProvider(util_log.Logger, prometheus.WrapRegistererWithPrefix("cortex_", prometheus.WrapRegistererWith(prometheus.Labels{
+			"name": "index-gateway-client",
+		}, r)), dns.GolangResolverType),
+		quit: make(chan struct{}),
 	}
 
-	dialOpts, err := cfg.GRPCClientConfig.DialOption(grpcclient.Instrument(sgClient.storeGatewayClientRequestDuration))
+	var err error
+	sgClient.dialOpts, err = cfg.GRPCClientConfig.DialOption(grpcclient.Instrument(sgClient.storeGatewayClientRequestDuration))
 	if err != nil {
 		return nil, err
 	}
 
-	sgClient.conn, err = grpc.Dial(cfg.Address, dialOpts...)
-	if err != nil {
+	if cfg.Mode != indexgateway.RingMode {
+		// the queries fail until the address is resolved, which is retried at every DNS lookup period.
+		sgClient.resolve()
+	}
+
+	clients := promauto.With(r).NewGauge(prometheus.GaugeOpts{
+		Namespace: "loki_boltdb_shipper",
+		Name:      "index_gateway_clients",
+		Help:      "The current number of index gateway clients.",
+	})
+	poolCfg := ring_client.PoolConfig{
+		CheckInterval:      5 * time.Second,
+		HealthCheckEnabled: true,
+		HealthCheckTimeout: 1 * time.Second,
+	}
+	sgClient.pool = ring_client.NewPool("index-gateway", poolCfg, sgClient.discoverInstances, sgClient.createClient, clients, util_log.Logger)
+	if err := services.StartAndAwaitRunning(context.Background(), sgClient.pool); err != nil {
 		return nil, err
 	}
 
-	sgClient.grpcClient = indexgatewaypb.NewIndexGatewayClient(sgClient.conn)
+	// only the DNS service discovery addresses are resolved again.
+	if qtype, _ := dns.GetQTypeName(cfg.Address); qtype != "" && cfg.Mode != indexgateway.RingMode {
+		sgClient.wait.Add(1)
+		go sgClient.updateLoop()
+	}
 	return sgClient, nil
 }
 
+func (s *GatewayClient) resolve() {
+	if err := s.dnsProvider.Resolve(context.Background(), []string{s.cfg.Address}); err != nil {
+		level.Error(util_log.Logger).Log("msg", "failed to resolve the index gateway address", "address", s.cfg.Address, "err", err)
+	}
+}
+
+func (s *GatewayClient) updateLoop() {
+	defer s.wait.Done()
+	ticker := time.NewTicker(s.cfg.DNSLookupPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.resolve()
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// discoverInstances returns the addresses of all the index gateway instances, the clients of the other ones are removed from the pool.
+func (s *GatewayClient) discoverInstances() ([]string, error) {
+	if s.cfg.Mode == indexgateway.RingMode {
+		rs, err := s.cfg.Ring.GetAllHealthy(indexgateway.IndexesRead)
+		if err != nil {
+			return nil, err
+		}
+		return rs.GetAddresses(), nil
+	}
+	return s.dnsProvider.Addresses(), nil
+}
+
+func (s *GatewayClient) createClient(addr string) (ring_client.PoolClient, error) {
+	conn, err := grpc.Dial(addr, s.dialOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return &gatewayPoolClient{
+		IndexGatewayClient: indexgatewaypb.NewIndexGatewayClient(conn),
+		HealthClient:       grpc_health_v1.NewHealthClient(conn),
+		Closer:             conn,
+	}, nil
+}
+
 func (s *GatewayClient) Stop() {
-	s.conn.Close()
+	close(s.quit)
+	s.wait.Wait()
+
+	if err := services.StopAndAwaitTerminated(context.Background(), s.pool); err != nil {
+		level.Error(util_log.Logger).Log("msg", "failed to stop the index gateway client pool", "err", err)
+	}
+	// the pool doesn't close its clients when stopped.
+	for _, addr := range s.pool.RegisteredAddresses() {
+		s.pool.RemoveClientFor(addr)
+	}
 }
 
 func (s *GatewayClient) QueryPages(ctx context.Context, queries []chunk.IndexQuery, callback chunk.QueryPagesCallback) error {
@@ -96,6 +204,15 @@ func (s *GatewayClient) QueryPages(ctx context.Context, queries []chunk.IndexQue
 }
 
 func (s *GatewayClient) doQueries(ctx context.Context, queries []chunk.IndexQuery, callback chunk.QueryPagesCallback) error {
+	userID, err := tenant.TenantID(ctx)
+	if err != nil {
+		return err
+	}
+	addresses, err := s.instancesFor(userID)
+	if err != nil {
+		return err
+	}
+
 	queryKeyQueryMap := make(map[string]chunk.IndexQuery, len(queries))
 	gatewayQueries := make([]*indexgatewaypb.IndexQuery, 0, len(queries))
 
@@ -110,7 +227,31 @@ func (s *GatewayClient) doQueries(ctx context.Context, queries []chunk.IndexQuer
 		})
 	}
 
-	streamer, err := s.grpcClient.QueryIndex(ctx, &indexgatewaypb.QueryIndexRequest{Queries: gatewayQueries})
+	// the queries are retried on the next instance as long as no response was passed to the callback.
+	for i, addr := range addresses {
+		var received bool
+		err = s.queryInstance(ctx, addr, gatewayQueries, queryKeyQueryMap, func(query chunk.IndexQuery, batch chunk.ReadBatch) bool {
+			received = true
+			return callback(query, batch)
+		})
+		if err == nil || received || ctx.Err() != nil {
+			return err
+		}
+		if i < len(addresses)-1 {
+			level.Warn(util_log.Logger).Log("msg", "failed to query index gateway, retrying on the next instance", "address", addr, "err", err)
+		}
+	}
+	return err
+}
+
+func (s *GatewayClient) queryInstance(ctx context.Context, addr string, gatewayQueries []*indexgatewaypb.IndexQuery,
+	queryKeyQueryMap map[string]chunk.IndexQuery, callback chunk.QueryPagesCallback) error {
+	poolClient, err := s.pool.GetClientFor(addr)
+	if err != nil {
+		return err
+	}
+
+	streamer, err := poolClient.(indexgatewaypb.IndexGatewayClient).QueryIndex(ctx, &indexgatewaypb.QueryIndexRequest{Queries: gatewayQueries})
 	if err != nil {
 		return err
 	}
@@ -136,6 +277,44 @@ func (s *GatewayClient) doQueries(ctx context.Context, queries []chunk.IndexQuer
 	return nil
 }
 
+// instancesFor returns the addresses of the index gateway instances serving the tenant in the order they are queried.
+func (s *GatewayClient) instancesFor(userID string) ([]string, error) {
+	if s.cfg.Mode == indexgateway.RingMode {
+		rs, err := indexgateway.TenantReplicationSet(s.cfg.Ring, s.cfg.Limits, userID)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get the index gateways of the tenant from the ring")
+		}
+		return rs.GetAddresses(), nil
+	}
+	addresses := instancesForTenant(s.dnsProvider.Addresses(), userID)
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("no index gateway instance resolved for the address %s", s.cfg.Address)
+	}
+	return addresses, nil
+}
+
+// instancesForTenant returns the addresses of the index gateway instances in the order they are queried for
+// the tenant: a tenant is always served by the same instance, which avoids every instance downloading its index.
+func instancesForTenant(addresses []string, userID string) []string {
+	if len(addresses) == 0 {
+		return nil
+	}
+	sorted := make([]string, len(addresses))
+	copy(sorted, addresses)
+	sort.Strings(sorted)
+
+	hasher := fnv.New32a()
+	hasher.Write([]byte(userID)) //nolint: errcheck
+	start := int(hasher.Sum32() % uint32(len(sorted)))
+	return append(sorted[start:], sorted[:start]...)
+}
+
+type gatewayPoolClient struct {
+	indexgatewaypb.IndexGatewayClient
+	grpc_health_v1.HealthClient
+	io.Closer
+}
+
 func (s *GatewayClient) NewWriteBatch() chunk.WriteBatch {
 	panic("unsupported")
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"os"
@@ -13,12 +14,20 @@ import (
 	"testing"
 	"time"
 
+	gokit_log "github.com/go-kit/log"
 	"github.com/grafana/dskit/flagext"
+	"github.com/grafana/dskit/kv/consul"
+	"github.com/grafana/dskit/ring"
+	ring_client "github.com/grafana/dskit/ring/client"
+	"github.com/grafana/dskit/services"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/discovery/dns"
 	"github.com/weaveworks/common/middleware"
 	"github.com/weaveworks/common/user"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/test/bufconn"
 
 	"github.com/grafana/loki/pkg/storage/chunk"
@@ -127,6 +136,7 @@ func TestGatewayClient(t *testing.T) {
 
 	gatewayClient, err := NewGatewayClient(cfg, nil)
 	require.NoError(t, err)
+	defer gatewayClient.Stop()
 
 	ctx := user.InjectOrgID(context.Background(), "fake")
 
@@ -241,8 +251,7 @@ func benchmarkIndexQueries(b *testing.B, queries []chunk.IndexQuery) {
 	ctx, _ = user.InjectIntoGRPCRequest(ctx)
 
 	// initialize the gateway client
-	gatewayClient := GatewayClient{}
-	gatewayClient.grpcClient = indexgatewaypb.NewIndexGatewayClient(conn)
+	gatewayClient := newTestGatewayClient(b, map[string]*grpc.ClientConn{"bufconn": conn})
 
 	// build the response we expect to get from queries
 	expected := map[string]int{}
@@ -296,3 +305,190 @@ func Benchmark_QueriesMatchingLargeNumOfRows(b *testing.B) {
 	}
 	benchmarkIndexQueries(b, queries)
 }
+
+// newTestGatewayClient makes a gateway client querying the index gateways of the connections, by address.
+func newTestGatewayClient(t testing.TB, conns map[string]*grpc.ClientConn) *GatewayClient {
+	var addresses []string
+	for addr := range conns {
+		addresses = append(addresses, addr)
+	}
+	gatewayClient := &GatewayClient{dnsProvider: dns.NewProvider(gokit_log.NewNopLogger(), nil, dns.GolangResolverType)}
+	require.NoError(t, gatewayClient.dnsProvider.Resolve(context.Background(), addresses))
+	gatewayClient.pool = ring_client.NewPool("index-gateway", ring_client.PoolConfig{}, nil, func(addr string) (ring_client.PoolClient, error) {
+		return &gatewayPoolClient{
+			IndexGatewayClient: indexgatewaypb.NewIndexGatewayClient(conns[addr]),
+			HealthClient:       grpc_health_v1.NewHealthClient(conns[addr]),
+			Closer:             io.NopCloser(nil),
+		}, nil
+	}, prometheus.NewGauge(prometheus.GaugeOpts{}), gokit_log.NewNopLogger())
+	return gatewayClient
+}
+
+// tenantsIndexGatewayServer records the tenants it serves, failing the queries when unavailable.
+type tenantsIndexGatewayServer struct {
+	unavailable bool
+	tenants     map[string]int
+}
+
+func (m *tenantsIndexGatewayServer) QueryIndex(request *indexgatewaypb.QueryIndexRequest, server indexgatewaypb.IndexGateway_QueryIndexServer) error {
+	if m.unavailable {
+		return errors.New("unavailable")
+	}
+	userID, err := user.ExtractOrgID(server.Context())
+	if err != nil {
+		return err
+	}
+	m.tenants[userID]++
+	for _, query := range request.Queries {
+		if err := server.Send(&indexgatewaypb.QueryIndexResponse{
+			QueryKey: util.QueryKey(chunk.IndexQuery{TableName: query.TableName, HashValue: query.HashValue}),
+			Rows:     []*indexgatewaypb.Row{{RangeValue: []byte(userID), Value: []byte(userID)}},
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestGatewayClient_Instances(t *testing.T) {
+	servers := map[string]*tenantsIndexGatewayServer{}
+	conns := map[string]*grpc.ClientConn{}
+	for _, addr := range []string{"index-gateway-1", "index-gateway-2", "index-gateway-3"} {
+		listener := bufconn.Listen(1024 * 1024)
+		s := grpc.NewServer(grpc.StreamInterceptor(middleware.StreamServerUserHeaderInterceptor))
+		servers[addr] = &tenantsIndexGatewayServer{tenants: map[string]int{}}
+		indexgatewaypb.RegisterIndexGatewayServer(s, servers[addr])
+		go func() {
+			_ = s.Serve(listener)
+		}()
+		conn, err := grpc.DialContext(context.Background(), "", grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return listener.Dial()
+		}), grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithStreamInterceptor(middleware.StreamClientUserHeaderInterceptor))
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			conn.Close()
+			s.Stop()
+		})
+		conns[addr] = conn
+	}
+	gatewayClient := newTestGatewayClient(t, conns)
+
+	query := func(userID string) {
+		ctx := user.InjectOrgID(context.Background(), userID)
+		calls := 0
+		err := gatewayClient.QueryPages(ctx, []chunk.IndexQuery{{TableName: "table", HashValue: "hash"}}, func(_ chunk.IndexQuery, batch chunk.ReadBatch) bool {
+			itr := batch.Iterator()
+			require.True(t, itr.Next())
+			require.Equal(t, userID, string(itr.Value()))
+			calls++
+			return true
+		})
+		require.NoError(t, err)
+		require.Equal(t, 1, calls)
+	}
+
+	// the queries of a tenant are always sent to the same instance.
+	for i := 0; i < 10; i++ {
+		for _, userID := range []string{"tenant-1", "tenant-2", "tenant-3", "tenant-4"} {
+			query(userID)
+		}
+	}
+	for _, userID := range []string{"tenant-1", "tenant-2", "tenant-3", "tenant-4"} {
+		instances := 0
+		for _, server := range servers {
+			if server.tenants[userID] > 0 {
+				require.Equal(t, 10, server.tenants[userID])
+				instances++
+			}
+		}
+		require.Equal(t, 1, instances, userID)
+	}
+
+	// the queries fail over to the next instance when the instance of the tenant is unavailable.
+	addr := instancesForTenant(gatewayClient.dnsProvider.Addresses(), "tenant-1")[0]
+	servers[addr].unavailable = true
+	query("tenant-1")
+	next := instancesForTenant(gatewayClient.dnsProvider.Addresses(), "tenant-1")[1]
+	require.Equal(t, 1, servers[next].tenants["tenant-1"])
+
+	// the queries without tenant are rejected.
+	err := gatewayClient.QueryPages(context.Background(), []chunk.IndexQuery{{TableName: "table"}}, func(chunk.IndexQuery, chunk.ReadBatch) bool {
+		return true
+	})
+	require.Error(t, err)
+}
+
+type shardSizeLimits map[string]int
+
+func (l shardSizeLimits) IndexGatewayShardSize(userID string) int {
+	return l[userID]
+}
+
+func TestGatewayClient_RingMode(t *testing.T) {
+	kvClient, closer := consul.NewInMemoryClient(ring.GetCodec(), gokit_log.NewNopLogger(), nil)
+	t.Cleanup(func() { _ = closer.Close() })
+
+	desc := ring.NewDesc()
+	for i, state := range []ring.InstanceState{ring.ACTIVE, ring.ACTIVE, ring.ACTIVE, ring.LEAVING} {
+		// fixed tokens for the tenants to be deterministically sharded.
+		var tokens []uint32
+		for j := 0; j < 128; j++ {
+			tokens = append(tokens, uint32(j)<<25+uint32(i)<<20)
+		}
+		desc.AddIngester(fmt.Sprintf("index-gateway-%d", i), fmt.Sprintf("index-gateway-%d:9095", i), "", tokens, state, time.Now())
+	}
+	require.NoError(t, kvClient.CAS(context.Background(), "index-gateway", func(interface{}) (interface{}, bool, error) {
+		return desc, true, nil
+	}))
+
+	var ringCfg ring.Config
+	flagext.DefaultValues(&ringCfg)
+	ringCfg.ReplicationFactor = 2
+	r, err := ring.NewWithStoreClientAndStrategy(ringCfg, "index-gateway", "index-gateway", kvClient, ring.NewIgnoreUnhealthyInstancesReplicationStrategy(), nil, gokit_log.NewNopLogger())
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), r))
+	t.Cleanup(func() {
+		require.NoError(t, services.StopAndAwaitTerminated(context.Background(), r))
+	})
+
+	limits := shardSizeLimits{"shuffle-sharded": 1}
+	gatewayClient := &GatewayClient{cfg: IndexGatewayClientConfig{Mode: indexgateway.RingMode, Ring: r, Limits: limits}}
+
+	// the pool only keeps the clients of the ACTIVE instances.
+	instances, err := gatewayClient.discoverInstances()
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"index-gateway-0:9095", "index-gateway-1:9095", "index-gateway-2:9095"}, instances)
+
+	for userID, expected := range map[string]int{"fake": 2, "user1": 2, "shuffle-sharded": 1} {
+		addresses, err := gatewayClient.instancesFor(userID)
+		require.NoError(t, err)
+		require.Len(t, addresses, expected, userID)
+		require.NotContains(t, addresses, "index-gateway-3:9095")
+
+		rs, err := indexgateway.TenantReplicationSet(r, limits, userID)
+		require.NoError(t, err)
+		require.Equal(t, rs.GetAddresses(), addresses)
+	}
+}
+
+func Test_instancesForTenant(t *testing.T) {
+	require.Empty(t, instancesForTenant(nil, "fake"))
+
+	addresses := []string{"c:9095", "a:9095", "b:9095"}
+	instances := instancesForTenant(addresses, "fake")
+	require.ElementsMatch(t, addresses, instances)
+	// the order depends on the tenant only, not on the order of resolution.
+	require.Equal(t, instances, instancesForTenant([]string{"b:9095", "c:9095", "a:9095"}, "fake"))
+	require.Equal(t, []string{"c:9095", "a:9095", "b:9095"}, addresses)
+}
+
+func TestIndexGatewayClientConfig_Validate(t *testing.T) {
+	cfg := IndexGatewayClientConfig{Address: "index-gateway:9095"}
+	require.NoError(t, cfg.Validate())
+
+	cfg.Address = "dns+index-gateway:9095"
+	require.Error(t, cfg.Validate())
+
+	cfg.DNSLookupPeriod = time.Second
+	require.NoError(t, cfg.Validate())
+}
@@ -0,0 +1,279 @@
+package indexgateway
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/kv"
+	"github.com/grafana/dskit/ring"
+	"github.com/grafana/dskit/services"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	lokiutil "github.com/grafana/loki/pkg/util"
+)
+
+const (
+	// ringKey is the key under which the index gateways ring is stored in the KV store.
+	ringKey = "index-gateway"
+
+	// ringName is the name of the index gateways ring.
+	ringName = "index-gateway"
+
+	// ringNumTokens is the number of tokens of each index gateway in the ring.
+	ringNumTokens = 128
+
+	// ringAutoForgetUnhealthyPeriods is how many consecutive timeout periods an unhealthy instance
+	// in the ring will be automatically removed.
+	ringAutoForgetUnhealthyPeriods = 10
+)
+
+// IndexesRead is the operation of reading the index of a tenant, only served by the ACTIVE index gateways.
+// The replication set is extended to the next instance when an instance isn't ACTIVE.
+var IndexesRead = ring.NewOp([]ring.InstanceState{ring.ACTIVE}, func(s ring.InstanceState) bool {
+	return s != ring.ACTIVE
+})
+
+// Mode is the way the queriers pick the index gateways serving the index of a tenant.
+type Mode string
+
+const (
+	// SimpleMode sends the queries to the instances of the configured index gateway address.
+	SimpleMode Mode = "simple"
+	// RingMode shards the tenants across the index gateways of a ring.
+	RingMode Mode = "ring"
+)
+
+// String implements flag.Value.
+func (m Mode) String() string {
+	return string(m)
+}
+
+// Set implements flag.Value.
+func (m *Mode) Set(v string) error {
+	switch Mode(v) {
+	case SimpleMode, RingMode:
+		*m = Mode(v)
+		return nil
+	default:
+		return fmt.Errorf("invalid index gateway mode %q, supported modes are %s and %s", v, SimpleMode, RingMode)
+	}
+}
+
+// RingCfg is the config of the index gateways ring.
+type RingCfg struct {
+	lokiutil.RingConfig `yaml:",inline"`
+
+	ReplicationFactor int `yaml:"replication_factor"`
+}
+
+// Config is the config of the index gateway.
+type Config struct {
+	Mode Mode    `yaml:"mode"`
+	Ring RingCfg `yaml:"ring,omitempty"`
+}
+
+// RegisterFlags registers flags.
+func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
+	cfg.Mode = SimpleMode
+	f.Var(&cfg.Mode, "index-gateway.mode", "Mode of the index gateway: simple or ring. In simple mode, the queriers send the queries to the instances of -boltdb.shipper.index-gateway-client.server-address. "+
+		"In ring mode, the index gateways join a ring and the index of each tenant is only served by the instances owning the tenant in the ring.")
+	cfg.Ring.RegisterFlagsWithPrefix("index-gateway.", "collectors/", f)
+	f.IntVar(&cfg.Ring.ReplicationFactor, "index-gateway.ring.replication-factor", 3, "Number of index gateways serving the index of each tenant in ring mode.")
+}
+
+// Validate validates the config.
+func (cfg *Config) Validate() error {
+	if err := cfg.Mode.Set(string(cfg.Mode)); err != nil {
+		return err
+	}
+	if cfg.Mode == RingMode && cfg.Ring.ReplicationFactor <= 0 {
+		return errors.New("the replication factor of the index gateway ring must be positive")
+	}
+	return nil
+}
+
+// Limits are the per tenant limits of the index gateways.
+type Limits interface {
+	IndexGatewayShardSize(userID string) int
+}
+
+// TenantReplicationSet returns the index gateways of the ring serving the index of the tenant, the primary one first.
+// The tenant is shuffle sharded across its shard size of index gateways when it's positive.
+func TenantReplicationSet(r ring.ReadRing, limits Limits, userID string) (ring.ReplicationSet, error) {
+	if shardSize := limits.IndexGatewayShardSize(userID); shardSize > 0 {
+		r = r.ShuffleShard(userID, shardSize)
+	}
+	bufDescs, bufHosts, bufZones := ring.MakeBuffersForGet()
+	return r.Get(lokiutil.TokenFor(userID, ""), IndexesRead, bufDescs, bufHosts, bufZones)
+}
+
+// RingManager manages the ring of the index gateways. The index gateways join the ring while the
+// queriers only watch it to find the index gateways of the tenants.
+type RingManager struct {
+	services.Service
+
+	cfg    Config
+	limits Limits
+	log    log.Logger
+
+	Ring           *ring.Ring
+	ringLifecycler *ring.BasicLifecycler
+
+	subservices        *services.Manager
+	subservicesWatcher *services.FailureWatcher
+}
+
+// NewRingManager creates the ring manager, joining the ring when server is true.
+func NewRingManager(server bool, cfg Config, limits Limits, logger log.Logger, registerer prometheus.Registerer) (*RingManager, error) {
+	rm := &RingManager{
+		cfg:    cfg,
+		limits: limits,
+		log:    logger,
+	}
+
+	ringStore, err := kv.NewClient(
+		cfg.Ring.KVStore,
+		ring.GetCodec(),
+		kv.RegistererWithKVName(prometheus.WrapRegistererWithPrefix("loki_", registerer), "index-gateway"),
+		logger,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "create KV store client")
+	}
+
+	ringCfg := cfg.Ring.ToRingConfig(cfg.Ring.ReplicationFactor)
+	rm.Ring, err = ring.NewWithStoreClientAndStrategy(ringCfg, ringName, ringKey, ringStore, ring.NewIgnoreUnhealthyInstancesReplicationStrategy(), prometheus.WrapRegistererWithPrefix("cortex_", registerer), logger)
+	if err != nil {
+		return nil, errors.Wrap(err, "create ring client")
+	}
+	svcs := []services.Service{rm.Ring}
+
+	if server {
+		lifecyclerCfg, err := cfg.Ring.ToLifecyclerConfig(ringNumTokens, logger)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid ring lifecycler config")
+		}
+
+		// Define lifecycler delegates in reverse order (last to be called defined first because they're
+		// chained via "next delegate").
+		delegate := ring.BasicLifecyclerDelegate(rm)
+		delegate = ring.NewLeaveOnStoppingDelegate(delegate, logger)
+		delegate = ring.NewTokensPersistencyDelegate(cfg.Ring.TokensFilePath, ring.JOINING, delegate, logger)
+		delegate = ring.NewAutoForgetDelegate(ringAutoForgetUnhealthyPeriods*cfg.Ring.HeartbeatTimeout, delegate, logger)
+
+		rm.ringLifecycler, err = ring.NewBasicLifecycler(lifecyclerCfg, ringName, ringKey, ringStore, delegate, logger, registerer)
+		if err != nil {
+			return nil, errors.Wrap(err, "create ring lifecycler")
+		}
+		svcs = append(svcs, rm.ringLifecycler)
+	}
+
+	rm.subservices, err = services.NewManager(svcs...)
+	if err != nil {
+		return nil, err
+	}
+	rm.subservicesWatcher = services.NewFailureWatcher()
+	rm.subservicesWatcher.WatchManager(rm.subservices)
+
+	rm.Service = services.NewBasicService(rm.starting, rm.running, rm.stopping)
+	return rm, nil
+}
+
+func (rm *RingManager) starting(ctx context.Context) (err error) {
+	// In case this function will return error we want to unregister the instance
+	// from the ring. We do it ensuring dependencies are gracefully stopped if they
+	// were already started.
+	defer func() {
+		if err == nil {
+			return
+		}
+
+		if stopErr := services.StopManagerAndAwaitStopped(context.Background(), rm.subservices); stopErr != nil {
+			level.Error(rm.log).Log("msg", "failed to gracefully stop index gateway ring dependencies", "err", stopErr)
+		}
+	}()
+
+	if err := services.StartManagerAndAwaitHealthy(ctx, rm.subservices); err != nil {
+		return errors.Wrap(err, "unable to start index gateway ring subservices")
+	}
+
+	if rm.ringLifecycler == nil {
+		return nil
+	}
+
+	// The index gateway doesn't have any work to do before serving the queries, it becomes ACTIVE right away.
+	level.Info(rm.log).Log("msg", "waiting until index gateway is JOINING in the ring")
+	if err := ring.WaitInstanceState(ctx, rm.Ring, rm.ringLifecycler.GetInstanceID(), ring.JOINING); err != nil {
+		return err
+	}
+	level.Info(rm.log).Log("msg", "index gateway is JOINING in the ring")
+
+	if err = rm.ringLifecycler.ChangeState(ctx, ring.ACTIVE); err != nil {
+		return errors.Wrapf(err, "switch instance to %s in the ring", ring.ACTIVE)
+	}
+
+	level.Info(rm.log).Log("msg", "waiting until index gateway is ACTIVE in the ring")
+	if err := ring.WaitInstanceState(ctx, rm.Ring, rm.ringLifecycler.GetInstanceID(), ring.ACTIVE); err != nil {
+		return err
+	}
+	level.Info(rm.log).Log("msg", "index gateway is ACTIVE in the ring")
+	return nil
+}
+
+func (rm *RingManager) running(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return nil
+	case err := <-rm.subservicesWatcher.Chan():
+		return errors.Wrap(err, "index gateway ring subservice failed")
+	}
+}
+
+func (rm *RingManager) stopping(_ error) error {
+	return services.StopManagerAndAwaitStopped(context.Background(), rm.subservices)
+}
+
+// OwnsTenant tells whether this index gateway serves the index of the tenant.
+// It's always false while the instance isn't ACTIVE in the ring.
+func (rm *RingManager) OwnsTenant(userID string) bool {
+	if rm.ringLifecycler == nil {
+		return false
+	}
+	rs, err := TenantReplicationSet(rm.Ring, rm.limits, userID)
+	if err != nil {
+		return false
+	}
+	return rs.Includes(rm.ringLifecycler.GetInstanceAddr())
+}
+
+func (rm *RingManager) OnRingInstanceRegister(_ *ring.BasicLifecycler, ringDesc ring.Desc, instanceExists bool, instanceID string, instanceDesc ring.InstanceDesc) (ring.InstanceState, ring.Tokens) {
+	// When we initialize the index gateway instance in the ring we want to start from
+	// a clean situation, so whatever is the state we set it JOINING, while we keep existing
+	// tokens (if any) or the ones loaded from file.
+	var tokens []uint32
+	if instanceExists {
+		tokens = instanceDesc.GetTokens()
+	}
+
+	takenTokens := ringDesc.GetTokens()
+	newTokens := ring.GenerateTokens(ringNumTokens-len(tokens), takenTokens)
+
+	// Tokens sorting will be enforced by the parent caller.
+	tokens = append(tokens, newTokens...)
+
+	return ring.JOINING, tokens
+}
+
+func (rm *RingManager) OnRingInstanceTokens(_ *ring.BasicLifecycler, _ ring.Tokens) {}
+func (rm *RingManager) OnRingInstanceStopping(_ *ring.BasicLifecycler)              {}
+func (rm *RingManager) OnRingInstanceHeartbeat(_ *ring.BasicLifecycler, _ *ring.Desc, _ *ring.InstanceDesc) {
+}
+
+func (rm *RingManager) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	rm.Ring.ServeHTTP(w, req)
+}
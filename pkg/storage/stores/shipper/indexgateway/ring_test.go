@@ -0,0 +1,111 @@
+package indexgateway
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/kv/consul"
+	"github.com/grafana/dskit/ring"
+	"github.com/grafana/dskit/services"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeLimits struct {
+	shardSize map[string]int
+}
+
+func (f fakeLimits) IndexGatewayShardSize(userID string) int {
+	return f.shardSize[userID]
+}
+
+func TestConfig_Validate(t *testing.T) {
+	var m Mode
+	require.NoError(t, m.Set("ring"))
+	require.Equal(t, RingMode, m)
+	require.Error(t, m.Set("foo"))
+
+	cfg := Config{Mode: SimpleMode}
+	require.NoError(t, cfg.Validate())
+
+	cfg.Mode = RingMode
+	require.Error(t, cfg.Validate())
+	cfg.Ring.ReplicationFactor = 2
+	require.NoError(t, cfg.Validate())
+
+	cfg.Mode = "foo"
+	require.Error(t, cfg.Validate())
+}
+
+func TestRingManager(t *testing.T) {
+	kvClient, closer := consul.NewInMemoryClient(ring.GetCodec(), log.NewNopLogger(), nil)
+	t.Cleanup(func() { _ = closer.Close() })
+
+	limits := fakeLimits{shardSize: map[string]int{"shuffle-sharded": 1}}
+	newManager := func(server bool, id string, port int) *RingManager {
+		cfg := Config{Mode: RingMode}
+		cfg.Ring.ReplicationFactor = 2
+		cfg.Ring.KVStore.Mock = kvClient
+		cfg.Ring.HeartbeatPeriod = 100 * time.Millisecond
+		cfg.Ring.HeartbeatTimeout = time.Minute
+		cfg.Ring.InstanceID = id
+		cfg.Ring.InstanceAddr = "127.0.0.1"
+		cfg.Ring.InstancePort = port
+
+		rm, err := NewRingManager(server, cfg, limits, log.NewNopLogger(), prometheus.NewRegistry())
+		require.NoError(t, err)
+		require.NoError(t, services.StartAndAwaitRunning(context.Background(), rm))
+		t.Cleanup(func() {
+			require.NoError(t, services.StopAndAwaitTerminated(context.Background(), rm))
+		})
+		return rm
+	}
+
+	var gateways []*RingManager
+	for i := 0; i < 3; i++ {
+		gateways = append(gateways, newManager(true, fmt.Sprintf("index-gateway-%d", i), 9095+i))
+	}
+	querier := newManager(false, "querier", 9095)
+	require.Eventually(t, func() bool {
+		rs, err := querier.Ring.GetAllHealthy(IndexesRead)
+		return err == nil && len(rs.Instances) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool {
+		for _, g := range gateways {
+			if g.Ring.InstancesCount() != 3 {
+				return false
+			}
+		}
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// the querier doesn't serve the index of any tenant.
+	require.False(t, querier.OwnsTenant("fake"))
+
+	for _, tc := range []struct {
+		userID    string
+		instances int
+	}{
+		{userID: "fake", instances: 2},
+		{userID: "user1", instances: 2},
+		{userID: "shuffle-sharded", instances: 1},
+	} {
+		t.Run(tc.userID, func(t *testing.T) {
+			rs, err := TenantReplicationSet(querier.Ring, limits, tc.userID)
+			require.NoError(t, err)
+			require.Len(t, rs.Instances, tc.instances)
+
+			// the index gateways agree with the querier on the instances serving the tenant.
+			var owners []string
+			for _, g := range gateways {
+				if g.OwnsTenant(tc.userID) {
+					owners = append(owners, g.ringLifecycler.GetInstanceAddr())
+				}
+			}
+			require.ElementsMatch(t, rs.GetAddresses(), owners)
+		})
+	}
+}
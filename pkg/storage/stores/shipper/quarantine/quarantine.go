@@ -0,0 +1,241 @@
+package quarantine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/services"
+
+	"github.com/grafana/loki/pkg/storage/chunk"
+	shipper_util "github.com/grafana/loki/pkg/storage/stores/shipper/util"
+)
+
+const annotationsSuffix = ".json"
+
+// Config configures the quarantine of the corrupt chunks found by the chunk scrubber of the compactor.
+type Config struct {
+	Enabled         bool          `yaml:"enabled"`
+	KeyPrefix       string        `yaml:"key_prefix"`
+	RefreshInterval time.Duration `yaml:"refresh_interval"`
+}
+
+// RegisterFlags registers flags.
+func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, "store.chunk-quarantine.enabled", false, "(Experimental) Skip the chunks quarantined as corrupt by the chunk scrubber of the compactor in the queries, which return a warning instead of failing.")
+	f.StringVar(&cfg.KeyPrefix, "store.chunk-quarantine.key-prefix", "quarantine/", "Prefix of the objects of the shared store of boltdb-shipper holding the quarantine annotations of the index tables. It must not be the prefix of the index.")
+	f.DurationVar(&cfg.RefreshInterval, "store.chunk-quarantine.refresh-interval", 5*time.Minute, "Interval at which the queriers reload the quarantine annotations.")
+}
+
+// Validate validates the config.
+func (cfg *Config) Validate() error {
+	if cfg.Enabled && cfg.RefreshInterval <= 0 {
+		return errors.New("the refresh interval of the chunk quarantine must be positive")
+	}
+	return shipper_util.ValidateSharedStoreKeyPrefix(cfg.KeyPrefix)
+}
+
+// Entry annotates a chunk of an index table as corrupt.
+type Entry struct {
+	// ChunkKey is the external key of the chunk, as indexed.
+	ChunkKey      string    `json:"chunk_key"`
+	Reason        string    `json:"reason"`
+	QuarantinedAt time.Time `json:"quarantined_at"`
+}
+
+type annotations struct {
+	Table  string  `json:"table"`
+	Chunks []Entry `json:"chunks"`
+}
+
+// Store stores the quarantine annotations of the index tables in an object store, an object per table.
+type Store struct {
+	objectClient chunk.ObjectClient
+	keyPrefix    string
+}
+
+// NewStore makes a store of the annotations under the key prefix of the object store.
+func NewStore(objectClient chunk.ObjectClient, keyPrefix string) *Store {
+	return &Store{objectClient: objectClient, keyPrefix: keyPrefix}
+}
+
+func (s *Store) key(tableName string) string {
+	return s.keyPrefix + tableName + annotationsSuffix
+}
+
+// Add quarantines the chunks of a table, the chunks already quarantined keep their annotation.
+func (s *Store) Add(ctx context.Context, tableName string, entries []Entry) error {
+	existing, err := s.Get(ctx, tableName)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]struct{}, len(existing))
+	for _, e := range existing {
+		seen[e.ChunkKey] = struct{}{}
+	}
+	added := false
+	for _, e := range entries {
+		if _, ok := seen[e.ChunkKey]; ok {
+			continue
+		}
+		seen[e.ChunkKey] = struct{}{}
+		existing = append(existing, e)
+		added = true
+	}
+	if !added {
+		return nil
+	}
+
+	buf, err := json.Marshal(annotations{Table: tableName, Chunks: existing})
+	if err != nil {
+		return err
+	}
+	return s.objectClient.PutObject(ctx, s.key(tableName), bytes.NewReader(buf))
+}
+
+// Get returns the quarantined chunks of a table.
+func (s *Store) Get(ctx context.Context, tableName string) ([]Entry, error) {
+	return s.get(ctx, s.key(tableName))
+}
+
+func (s *Store) get(ctx context.Context, key string) ([]Entry, error) {
+	rc, _, err := s.objectClient.GetObject(ctx, key)
+	if err != nil {
+		if s.objectClient.IsObjectNotFoundErr(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer rc.Close()
+
+	buf, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+	var a annotations
+	if err := json.Unmarshal(buf, &a); err != nil {
+		return nil, fmt.Errorf("failed to decode the quarantine annotations %s: %w", key, err)
+	}
+	return a.Chunks, nil
+}
+
+// list returns the objects holding the annotations of the tables.
+func (s *Store) list(ctx context.Context) ([]chunk.StorageObject, error) {
+	objects, _, err := s.objectClient.List(ctx, s.keyPrefix, "")
+	if err != nil {
+		return nil, err
+	}
+	filtered := objects[:0]
+	for _, o := range objects {
+		if strings.HasSuffix(o.Key, annotationsSuffix) {
+			filtered = append(filtered, o)
+		}
+	}
+	return filtered, nil
+}
+
+type loadedAnnotations struct {
+	modifiedAt time.Time
+	chunkKeys  []string
+}
+
+// Filter tells the chunks quarantined as corrupt, reloading the annotations of the tables periodically.
+type Filter struct {
+	services.Service
+
+	store  *Store
+	logger log.Logger
+
+	mtx    sync.RWMutex
+	tables map[string]loadedAnnotations
+	chunks map[string]struct{}
+}
+
+// NewFilter makes a filter of the chunks quarantined in the store.
+func NewFilter(store *Store, refreshInterval time.Duration, logger log.Logger) *Filter {
+	f := &Filter{
+		store:  store,
+		logger: logger,
+		tables: map[string]loadedAnnotations{},
+		chunks: map[string]struct{}{},
+	}
+	f.Service = services.NewTimerService(refreshInterval, f.iteration, f.iteration, nil).WithName("chunk quarantine")
+	return f
+}
+
+func (f *Filter) iteration(ctx context.Context) error {
+	if err := f.refresh(ctx); err != nil {
+		level.Error(f.logger).Log("msg", "failed to reload the quarantined chunks", "err", err)
+	}
+	// don't return the error, otherwise the timer service would stop.
+	return nil
+}
+
+// refresh reloads the annotations of the tables modified since they were last loaded.
+func (f *Filter) refresh(ctx context.Context) error {
+	objects, err := f.store.list(ctx)
+	if err != nil {
+		return err
+	}
+
+	f.mtx.RLock()
+	tables := make(map[string]loadedAnnotations, len(objects))
+	for _, o := range objects {
+		if loaded, ok := f.tables[o.Key]; ok && loaded.modifiedAt.Equal(o.ModifiedAt) {
+			tables[o.Key] = loaded
+		}
+	}
+	f.mtx.RUnlock()
+
+	for _, o := range objects {
+		if _, ok := tables[o.Key]; ok {
+			continue
+		}
+		entries, err := f.store.get(ctx, o.Key)
+		if err != nil {
+			return err
+		}
+		loaded := loadedAnnotations{modifiedAt: o.ModifiedAt, chunkKeys: make([]string, 0, len(entries))}
+		for _, e := range entries {
+			loaded.chunkKeys = append(loaded.chunkKeys, e.ChunkKey)
+		}
+		tables[o.Key] = loaded
+	}
+
+	chunks := map[string]struct{}{}
+	for _, loaded := range tables {
+		for _, key := range loaded.chunkKeys {
+			chunks[key] = struct{}{}
+		}
+	}
+
+	f.mtx.Lock()
+	f.tables, f.chunks = tables, chunks
+	f.mtx.Unlock()
+	return nil
+}
+
+// Quarantined returns whether the chunk of the external key is quarantined.
+func (f *Filter) Quarantined(chunkKey string) bool {
+	f.mtx.RLock()
+	defer f.mtx.RUnlock()
+	_, ok := f.chunks[chunkKey]
+	return ok
+}
+
+// Empty returns whether no chunk is quarantined, to skip the filtering of the chunks.
+func (f *Filter) Empty() bool {
+	f.mtx.RLock()
+	defer f.mtx.RUnlock()
+	return len(f.chunks) == 0
+}
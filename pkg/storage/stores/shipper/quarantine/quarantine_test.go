@@ -0,0 +1,83 @@
+package quarantine
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/pkg/storage/chunk/local"
+)
+
+func newTestStore(t *testing.T) (*Store, *local.FSObjectClient) {
+	objectClient, err := local.NewFSObjectClient(local.FSConfig{Directory: t.TempDir()})
+	require.NoError(t, err)
+	return NewStore(objectClient, "quarantine/"), objectClient
+}
+
+func TestStore(t *testing.T) {
+	store, _ := newTestStore(t)
+	ctx := context.Background()
+	now := time.Unix(1000, 0).UTC()
+
+	entries, err := store.Get(ctx, "index_19000")
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	require.NoError(t, store.Add(ctx, "index_19000", []Entry{{ChunkKey: "fake/1:2:3:4", Reason: "checksum", QuarantinedAt: now}}))
+	// the chunks already quarantined keep their annotation.
+	require.NoError(t, store.Add(ctx, "index_19000", []Entry{
+		{ChunkKey: "fake/1:2:3:4", Reason: "missing", QuarantinedAt: now.Add(time.Hour)},
+		{ChunkKey: "fake/5:6:7:8", Reason: "entries", QuarantinedAt: now},
+	}))
+
+	entries, err = store.Get(ctx, "index_19000")
+	require.NoError(t, err)
+	require.Equal(t, []Entry{
+		{ChunkKey: "fake/1:2:3:4", Reason: "checksum", QuarantinedAt: now},
+		{ChunkKey: "fake/5:6:7:8", Reason: "entries", QuarantinedAt: now},
+	}, entries)
+}
+
+func TestFilter(t *testing.T) {
+	store, objectClient := newTestStore(t)
+	ctx := context.Background()
+	filter := NewFilter(store, time.Minute, log.NewNopLogger())
+
+	require.NoError(t, filter.refresh(ctx))
+	require.True(t, filter.Empty())
+
+	require.NoError(t, store.Add(ctx, "index_19000", []Entry{{ChunkKey: "fake/1:2:3:4"}}))
+	require.NoError(t, store.Add(ctx, "index_19001", []Entry{{ChunkKey: "fake/5:6:7:8"}}))
+	// the other objects under the prefix are ignored.
+	require.NoError(t, objectClient.PutObject(ctx, "quarantine/README", bytes.NewReader([]byte("quarantined chunks"))))
+
+	require.NoError(t, filter.refresh(ctx))
+	require.False(t, filter.Empty())
+	require.True(t, filter.Quarantined("fake/1:2:3:4"))
+	require.True(t, filter.Quarantined("fake/5:6:7:8"))
+	require.False(t, filter.Quarantined("fake/9:a:b:c"))
+
+	// the annotations of the tables removed, like by the retention, are dropped.
+	require.NoError(t, objectClient.DeleteObject(ctx, "quarantine/index_19000.json"))
+	require.NoError(t, filter.refresh(ctx))
+	require.False(t, filter.Quarantined("fake/1:2:3:4"))
+	require.True(t, filter.Quarantined("fake/5:6:7:8"))
+}
+
+func TestConfig_Validate(t *testing.T) {
+	cfg := Config{KeyPrefix: "quarantine/"}
+	require.NoError(t, cfg.Validate())
+
+	cfg.Enabled = true
+	require.Error(t, cfg.Validate())
+
+	cfg.RefreshInterval = time.Minute
+	require.NoError(t, cfg.Validate())
+
+	cfg.KeyPrefix = "quarantine"
+	require.Error(t, cfg.Validate())
+}
@@ -2,6 +2,7 @@ package shipper
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
@@ -61,11 +62,16 @@ type Config struct {
 	CacheTTL                 time.Duration            `yaml:"cache_ttl"`
 	ResyncInterval           time.Duration            `yaml:"resync_interval"`
 	QueryReadyNumDays        int                      `yaml:"query_ready_num_days"`
+	DownloadParallelism      int                      `yaml:"download_parallelism"`
+	PrefetchNumTables        int                      `yaml:"prefetch_num_tables"`
 	IndexGatewayClientConfig IndexGatewayClientConfig `yaml:"index_gateway_client"`
 	BuildPerTenantIndex      bool                     `yaml:"build_per_tenant_index"`
+	IndexCompression         string                   `yaml:"index_compression"`
 	IngesterName             string                   `yaml:"-"`
 	Mode                     int                      `yaml:"-"`
 	IngesterDBRetainPeriod   time.Duration            `yaml:"-"`
+	// OwnsTenant filters the tenants whose index is kept query ready, all of them when nil.
+	OwnsTenant func(userID string) bool `yaml:"-"`
 }
 
 // RegisterFlags registers flags.
@@ -79,10 +85,25 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.DurationVar(&cfg.CacheTTL, "boltdb.shipper.cache-ttl", 24*time.Hour, "TTL for boltDB files restored in cache for queries")
 	f.DurationVar(&cfg.ResyncInterval, "boltdb.shipper.resync-interval", 5*time.Minute, "Resync downloaded files with the storage")
 	f.IntVar(&cfg.QueryReadyNumDays, "boltdb.shipper.query-ready-num-days", 0, "Number of days of common index to be kept downloaded for queries. For per tenant index query readiness, use limits overrides config.")
+	f.IntVar(&cfg.DownloadParallelism, "boltdb.shipper.download-parallelism", 10, "Number of tables downloaded concurrently for the queries, the query readiness and the prefetch.")
+	f.IntVar(&cfg.PrefetchNumTables, "boltdb.shipper.prefetch-num-tables", 0, "Number of tables preceding the ones queried by a tenant which are downloaded in the background, for the queries of the range before to not wait for them. 0 to disable.")
 	f.BoolVar(&cfg.BuildPerTenantIndex, "boltdb.shipper.build-per-tenant-index", false, "Build per tenant index files")
+	f.StringVar(&cfg.IndexCompression, "boltdb.shipper.index-compression", shipper_util.CompressionGzip, "Compression of the index files uploaded to the shared store. Supported values: gzip, zstd. The files are downloaded whatever their compression, uncompressed ones included.")
 }
 
 func (cfg *Config) Validate() error {
+	if err := cfg.IndexGatewayClientConfig.Validate(); err != nil {
+		return err
+	}
+	if cfg.DownloadParallelism <= 0 {
+		return errors.New("the download parallelism must be greater than 0")
+	}
+	if cfg.PrefetchNumTables < 0 {
+		return errors.New("the number of tables to prefetch can't be negative")
+	}
+	if err := shipper_util.ValidateCompression(cfg.IndexCompression); err != nil {
+		return err
+	}
 	return shipper_util.ValidateSharedStoreKeyPrefix(cfg.SharedStoreKeyPrefix)
 }
 
@@ -141,6 +162,7 @@ func (s *Shipper) init(storageClient chunk.ObjectClient, limits downloads.Limits
 			UploadInterval:       UploadInterval,
 			DBRetainPeriod:       s.cfg.IngesterDBRetainPeriod,
 			MakePerTenantBuckets: s.cfg.BuildPerTenantIndex,
+			Compression:          s.cfg.IndexCompression,
 		}
 		uploadsManager, err := uploads.NewTableManager(cfg, s.boltDBIndexClient, indexStorageClient, registerer)
 		if err != nil {
@@ -152,11 +174,14 @@ func (s *Shipper) init(storageClient chunk.ObjectClient, limits downloads.Limits
 
 	if s.cfg.Mode != ModeWriteOnly {
 		cfg := downloads.Config{
-			CacheDir:          s.cfg.CacheLocation,
-			SyncInterval:      s.cfg.ResyncInterval,
-			CacheTTL:          s.cfg.CacheTTL,
-			QueryReadyNumDays: s.cfg.QueryReadyNumDays,
-			Limits:            limits,
+			CacheDir:            s.cfg.CacheLocation,
+			SyncInterval:        s.cfg.ResyncInterval,
+			CacheTTL:            s.cfg.CacheTTL,
+			QueryReadyNumDays:   s.cfg.QueryReadyNumDays,
+			DownloadParallelism: s.cfg.DownloadParallelism,
+			PrefetchNumTables:   s.cfg.PrefetchNumTables,
+			Limits:              limits,
+			OwnsTenant:          s.cfg.OwnsTenant,
 		}
 		downloadsManager, err := downloads.NewTableManager(cfg, s.boltDBIndexClient, indexStorageClient, registerer)
 		if err != nil {
@@ -7,10 +7,12 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"sync"
 	"testing"
 
 	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
 	"github.com/stretchr/testify/require"
 	"github.com/weaveworks/common/user"
 	"go.etcd.io/bbolt"
@@ -193,9 +195,17 @@ func DecompressFile(t *testing.T, src, dest string) {
 	compressedFile, err := os.Open(src)
 	require.NoError(t, err)
 
-	// get a compressed reader
-	compressedReader, err := gzip.NewReader(compressedFile)
-	require.NoError(t, err)
+	// get a compressed reader, for the compression of the file.
+	var compressedReader io.Reader
+	if strings.HasSuffix(src, ".zst") {
+		zstdReader, err := zstd.NewReader(compressedFile)
+		require.NoError(t, err)
+		defer zstdReader.Close()
+		compressedReader = zstdReader
+	} else {
+		compressedReader, err = gzip.NewReader(compressedFile)
+		require.NoError(t, err)
+	}
 
 	decompressedFile, err := os.Create(dest)
 	require.NoError(t, err)
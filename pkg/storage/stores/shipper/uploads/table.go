@@ -16,7 +16,6 @@ import (
 	"github.com/go-kit/log/level"
 	"go.etcd.io/bbolt"
 
-	"github.com/grafana/loki/pkg/chunkenc"
 	"github.com/grafana/loki/pkg/storage/chunk"
 	"github.com/grafana/loki/pkg/storage/chunk/local"
 	chunk_util "github.com/grafana/loki/pkg/storage/chunk/util"
@@ -59,6 +58,7 @@ type Table struct {
 	storageClient        StorageClient
 	boltdbIndexClient    BoltDBIndexClient
 	makePerTenantBuckets bool
+	compression          string
 
 	dbs    map[string]*bbolt.DB
 	dbsMtx sync.RWMutex
@@ -72,18 +72,19 @@ type Table struct {
 }
 
 // NewTable create a new Table without looking for any existing local dbs belonging to the table.
-func NewTable(path, uploader string, storageClient StorageClient, boltdbIndexClient BoltDBIndexClient, makePerTenantBuckets bool) (*Table, error) {
+func NewTable(path, uploader string, storageClient StorageClient, boltdbIndexClient BoltDBIndexClient, makePerTenantBuckets bool,
+	compression string) (*Table, error) {
 	err := chunk_util.EnsureDirectory(path)
 	if err != nil {
 		return nil, err
 	}
 
-	return newTableWithDBs(map[string]*bbolt.DB{}, path, uploader, storageClient, boltdbIndexClient, makePerTenantBuckets)
+	return newTableWithDBs(map[string]*bbolt.DB{}, path, uploader, storageClient, boltdbIndexClient, makePerTenantBuckets, compression)
 }
 
 // LoadTable loads local dbs belonging to the table and creates a new Table with references to dbs if there are any otherwise it doesn't create a table
 func LoadTable(path, uploader string, storageClient StorageClient, boltdbIndexClient BoltDBIndexClient,
-	makePerTenantBuckets bool, metrics *metrics) (*Table, error) {
+	makePerTenantBuckets bool, compression string, metrics *metrics) (*Table, error) {
 	dbs, err := loadBoltDBsFromDir(path, metrics)
 	if err != nil {
 		return nil, err
@@ -93,11 +94,11 @@ func LoadTable(path, uploader string, storageClient StorageClient, boltdbIndexCl
 		return nil, nil
 	}
 
-	return newTableWithDBs(dbs, path, uploader, storageClient, boltdbIndexClient, makePerTenantBuckets)
+	return newTableWithDBs(dbs, path, uploader, storageClient, boltdbIndexClient, makePerTenantBuckets, compression)
 }
 
 func newTableWithDBs(dbs map[string]*bbolt.DB, path, uploader string, storageClient StorageClient, boltdbIndexClient BoltDBIndexClient,
-	makePerTenantBuckets bool) (*Table, error) {
+	makePerTenantBuckets bool, compression string) (*Table, error) {
 	return &Table{
 		name:                 filepath.Base(path),
 		path:                 path,
@@ -109,6 +110,7 @@ func newTableWithDBs(dbs map[string]*bbolt.DB, path, uploader string, storageCli
 		dbUploadTime:         map[string]time.Time{},
 		modifyShardsSince:    time.Now().Unix(),
 		makePerTenantBuckets: makePerTenantBuckets,
+		compression:          compression,
 	}, nil
 }
 
@@ -407,8 +409,9 @@ func (lt *Table) uploadDB(ctx context.Context, name string, db *bbolt.DB) error
 	}()
 
 	err = db.View(func(tx *bbolt.Tx) (err error) {
-		compressedWriter := chunkenc.Gzip.GetWriter(f)
-		defer chunkenc.Gzip.PutWriter(compressedWriter)
+		compressionPool := shipper_util.CompressionWriterPool(lt.compression)
+		compressedWriter := compressionPool.GetWriter(f)
+		defer compressionPool.PutWriter(compressedWriter)
 
 		defer func() {
 			cerr := compressedWriter.Close()
@@ -484,7 +487,7 @@ func (lt *Table) buildFileName(dbName string) string {
 		fileName = lt.uploader
 	}
 
-	return fmt.Sprintf("%s.gz", fileName)
+	return shipper_util.CompressedFileName(fileName, lt.compression)
 }
 
 func loadBoltDBsFromDir(dir string, metrics *metrics) (map[string]*bbolt.DB, error) {
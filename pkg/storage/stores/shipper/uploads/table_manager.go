@@ -27,6 +27,7 @@ type Config struct {
 	UploadInterval       time.Duration
 	DBRetainPeriod       time.Duration
 	MakePerTenantBuckets bool
+	Compression          string
 }
 
 type TableManager struct {
@@ -150,7 +151,7 @@ func (tm *TableManager) getOrCreateTable(tableName string) (*Table, error) {
 		if !ok {
 			var err error
 			table, err = NewTable(filepath.Join(tm.cfg.IndexDir, tableName), tm.cfg.Uploader, tm.storageClient,
-				tm.boltIndexClient, tm.cfg.MakePerTenantBuckets)
+				tm.boltIndexClient, tm.cfg.MakePerTenantBuckets, tm.cfg.Compression)
 			if err != nil {
 				return nil, err
 			}
@@ -238,7 +239,7 @@ func (tm *TableManager) loadTables() (map[string]*Table, error) {
 
 		level.Info(util_log.Logger).Log("msg", fmt.Sprintf("loading table %s", fileInfo.Name()))
 		table, err := LoadTable(filepath.Join(tm.cfg.IndexDir, fileInfo.Name()), tm.cfg.Uploader, tm.storageClient,
-			tm.boltIndexClient, tm.cfg.MakePerTenantBuckets, tm.metrics)
+			tm.boltIndexClient, tm.cfg.MakePerTenantBuckets, tm.cfg.Compression, tm.metrics)
 		if err != nil {
 			return nil, err
 		}
@@ -3,7 +3,6 @@ package uploads
 import (
 	"context"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -12,7 +11,6 @@ import (
 	"testing"
 	"time"
 
-	"github.com/klauspost/compress/gzip"
 	"github.com/stretchr/testify/require"
 	"github.com/weaveworks/common/user"
 
@@ -20,6 +18,7 @@ import (
 	"github.com/grafana/loki/pkg/storage/chunk/local"
 	"github.com/grafana/loki/pkg/storage/stores/shipper/storage"
 	"github.com/grafana/loki/pkg/storage/stores/shipper/testutil"
+	shipper_util "github.com/grafana/loki/pkg/storage/stores/shipper/util"
 )
 
 const (
@@ -43,11 +42,11 @@ func buildTestClients(t *testing.T, path string) (*local.BoltIndexClient, Storag
 
 type stopFunc func()
 
-func buildTestTable(t *testing.T, path string, makePerTenantBuckets bool) (*Table, *local.BoltIndexClient, stopFunc) {
+func buildTestTable(t *testing.T, path string, makePerTenantBuckets bool, compression string) (*Table, *local.BoltIndexClient, stopFunc) {
 	boltDBIndexClient, fsObjectClient := buildTestClients(t, path)
 	indexPath := filepath.Join(path, indexDirName)
 
-	table, err := NewTable(indexPath, "test", fsObjectClient, boltDBIndexClient, makePerTenantBuckets)
+	table, err := NewTable(indexPath, "test", fsObjectClient, boltDBIndexClient, makePerTenantBuckets, compression)
 	require.NoError(t, err)
 
 	return table, boltDBIndexClient, func() {
@@ -106,7 +105,7 @@ func TestLoadTable(t *testing.T) {
 	require.Error(t, err)
 
 	// try loading the table.
-	table, err := LoadTable(tablePath, "test", nil, boltDBIndexClient, false, newMetrics(nil))
+	table, err := LoadTable(tablePath, "test", nil, boltDBIndexClient, false, shipper_util.CompressionGzip, newMetrics(nil))
 	require.NoError(t, err)
 	require.NotNil(t, table)
 
@@ -130,7 +129,7 @@ func TestTable_Write(t *testing.T) {
 		t.Run(fmt.Sprintf("withPerTenantBucket=%v", withPerTenantBucket), func(t *testing.T) {
 			tempDir := t.TempDir()
 
-			table, boltIndexClient, stopFunc := buildTestTable(t, tempDir, withPerTenantBucket)
+			table, boltIndexClient, stopFunc := buildTestTable(t, tempDir, withPerTenantBucket, shipper_util.CompressionGzip)
 			defer stopFunc()
 
 			now := time.Now()
@@ -193,40 +192,42 @@ func TestTable_Write(t *testing.T) {
 }
 
 func TestTable_Upload(t *testing.T) {
-	for _, withPerTenantBucket := range []bool{false, true} {
-		t.Run(fmt.Sprintf("withPerTenantBucket=%v", withPerTenantBucket), func(t *testing.T) {
-			tempDir := t.TempDir()
+	for _, compression := range []string{shipper_util.CompressionGzip, shipper_util.CompressionZstd} {
+		for _, withPerTenantBucket := range []bool{false, true} {
+			t.Run(fmt.Sprintf("compression=%s/withPerTenantBucket=%v", compression, withPerTenantBucket), func(t *testing.T) {
+				tempDir := t.TempDir()
 
-			table, boltIndexClient, stopFunc := buildTestTable(t, tempDir, withPerTenantBucket)
-			defer stopFunc()
+				table, boltIndexClient, stopFunc := buildTestTable(t, tempDir, withPerTenantBucket, compression)
+				defer stopFunc()
 
-			now := time.Now()
+				now := time.Now()
 
-			// write a batch for now
-			batch := boltIndexClient.NewWriteBatch()
-			testutil.AddRecordsToBatch(batch, "test", 0, 10)
-			require.NoError(t, table.write(user.InjectOrgID(context.Background(), userID), now, batch.(*local.BoltWriteBatch).Writes["test"]))
+				// write a batch for now
+				batch := boltIndexClient.NewWriteBatch()
+				testutil.AddRecordsToBatch(batch, "test", 0, 10)
+				require.NoError(t, table.write(user.InjectOrgID(context.Background(), userID), now, batch.(*local.BoltWriteBatch).Writes["test"]))
 
-			// upload the table
-			require.NoError(t, table.Upload(context.Background(), true))
-			require.Len(t, table.dbs, 1)
+				// upload the table
+				require.NoError(t, table.Upload(context.Background(), true))
+				require.Len(t, table.dbs, 1)
 
-			// compare the local dbs for the table with the dbs in remote storage after upload to ensure they have same data
-			objectStorageDir := filepath.Join(tempDir, objectsStorageDirName)
-			compareTableWithStorage(t, table, objectStorageDir)
+				// compare the local dbs for the table with the dbs in remote storage after upload to ensure they have same data
+				objectStorageDir := filepath.Join(tempDir, objectsStorageDirName)
+				compareTableWithStorage(t, table, objectStorageDir)
 
-			// write a batch to another shard
-			batch = boltIndexClient.NewWriteBatch()
-			testutil.AddRecordsToBatch(batch, "test", 20, 10)
-			require.NoError(t, table.write(user.InjectOrgID(context.Background(), userID), now.Add(ShardDBsByDuration), batch.(*local.BoltWriteBatch).Writes["test"]))
+				// write a batch to another shard
+				batch = boltIndexClient.NewWriteBatch()
+				testutil.AddRecordsToBatch(batch, "test", 20, 10)
+				require.NoError(t, table.write(user.InjectOrgID(context.Background(), userID), now.Add(ShardDBsByDuration), batch.(*local.BoltWriteBatch).Writes["test"]))
 
-			// upload the dbs to storage
-			require.NoError(t, table.Upload(context.Background(), true))
-			require.Len(t, table.dbs, 2)
+				// upload the dbs to storage
+				require.NoError(t, table.Upload(context.Background(), true))
+				require.Len(t, table.dbs, 2)
 
-			// check local dbs with remote dbs to ensure they have same data
-			compareTableWithStorage(t, table, objectStorageDir)
-		})
+				// check local dbs with remote dbs to ensure they have same data
+				compareTableWithStorage(t, table, objectStorageDir)
+			})
+		}
 	}
 }
 
@@ -237,26 +238,9 @@ func compareTableWithStorage(t *testing.T, table *Table, storageDir string) {
 	for name, db := range table.dbs {
 		fileName := table.buildFileName(name)
 
-		// open compressed file from storage
-		compressedFile, err := os.Open(filepath.Join(storageDir, table.name, fileName))
-		require.NoError(t, err)
-
-		// get a compressed reader
-		compressedReader, err := gzip.NewReader(compressedFile)
-		require.NoError(t, err)
-
-		// create a temp file for writing decompressed file
+		// decompress the file from storage
 		decompressedFilePath := filepath.Join(tempDir, filepath.Base(fileName))
-		decompressedFile, err := os.Create(decompressedFilePath)
-		require.NoError(t, err)
-
-		// do the decompression
-		_, err = io.Copy(decompressedFile, compressedReader)
-		require.NoError(t, err)
-
-		// close the references
-		require.NoError(t, compressedFile.Close())
-		require.NoError(t, decompressedFile.Close())
+		testutil.DecompressFile(t, filepath.Join(storageDir, table.name, fileName), decompressedFilePath)
 
 		storageDB, err := local.OpenBoltdbFile(decompressedFilePath)
 		require.NoError(t, err)
@@ -290,7 +274,7 @@ func TestTable_Cleanup(t *testing.T) {
 	testutil.AddRecordsToDB(t, notUploaded, boltDBIndexClient, 20, 10, nil)
 
 	// load existing dbs
-	table, err := LoadTable(indexPath, "test", storageClient, boltDBIndexClient, false, newMetrics(nil))
+	table, err := LoadTable(indexPath, "test", storageClient, boltDBIndexClient, false, shipper_util.CompressionGzip, newMetrics(nil))
 	require.NoError(t, err)
 	require.Len(t, table.dbs, 3)
 
@@ -426,7 +410,7 @@ func TestTable_ImmutableUploads(t *testing.T) {
 	tableName := "test-table"
 	tablePath := testutil.SetupDBsAtPath(t, filepath.Join(indexPath, tableName), dbs, nil)
 
-	table, err := LoadTable(tablePath, "test", storageClient, boltDBIndexClient, false, newMetrics(nil))
+	table, err := LoadTable(tablePath, "test", storageClient, boltDBIndexClient, false, shipper_util.CompressionGzip, newMetrics(nil))
 	require.NoError(t, err)
 	require.NotNil(t, table)
 
@@ -522,7 +506,7 @@ func TestTable_MultiQueries(t *testing.T) {
 	}, []byte(user1))
 
 	// try loading the table.
-	table, err := LoadTable(tablePath, "test", nil, boltDBIndexClient, false, newMetrics(nil))
+	table, err := LoadTable(tablePath, "test", nil, boltDBIndexClient, false, shipper_util.CompressionGzip, newMetrics(nil))
 	require.NoError(t, err)
 	require.NotNil(t, table)
 
@@ -17,6 +17,7 @@ import (
 	gzip "github.com/klauspost/pgzip"
 	"go.etcd.io/bbolt"
 
+	"github.com/grafana/loki/pkg/chunkenc"
 	"github.com/grafana/loki/pkg/storage/chunk"
 	"github.com/grafana/loki/pkg/storage/chunk/local"
 	util_log "github.com/grafana/loki/pkg/util/log"
@@ -25,8 +26,19 @@ import (
 const (
 	delimiter = "/"
 	sep       = "\xff"
+
+	// CompressionGzip and CompressionZstd are the compressions of the index files shipped to the object store.
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
 )
 
+// compressionExtensions are the extensions of the files per compression, the compression of a file downloaded being
+// detected from its extension.
+var compressionExtensions = map[string]string{
+	CompressionGzip: ".gz",
+	CompressionZstd: ".zst",
+}
+
 var (
 	gzipReader = sync.Pool{}
 	gzipWriter = sync.Pool{}
@@ -69,6 +81,79 @@ func putGzipWriter(writer io.WriteCloser) {
 	gzipWriter.Put(writer)
 }
 
+// getCompressedReader gets a reader decompressing src with the compression.
+func getCompressedReader(compression string, src io.Reader) io.Reader {
+	if compression == CompressionZstd {
+		return chunkenc.Zstd.GetReader(src)
+	}
+	return getGzipReader(src)
+}
+
+// putCompressedReader places back in the pool a reader got with getCompressedReader.
+func putCompressedReader(compression string, reader io.Reader) {
+	if compression == CompressionZstd {
+		chunkenc.Zstd.PutReader(reader)
+		return
+	}
+	putGzipReader(reader)
+}
+
+// getCompressedWriter gets a writer compressing to dst with the compression.
+func getCompressedWriter(compression string, dst io.Writer) io.WriteCloser {
+	if compression == CompressionZstd {
+		return chunkenc.Zstd.GetWriter(dst)
+	}
+	return getGzipWriter(dst)
+}
+
+// putCompressedWriter places back in the pool a writer got with getCompressedWriter.
+func putCompressedWriter(compression string, writer io.WriteCloser) {
+	if compression == CompressionZstd {
+		chunkenc.Zstd.PutWriter(writer)
+		return
+	}
+	putGzipWriter(writer)
+}
+
+// CompressionWriterPool returns the pool of the writers of the compression.
+func CompressionWriterPool(compression string) chunkenc.WriterPool {
+	if compression == CompressionZstd {
+		return &chunkenc.Zstd
+	}
+	return &chunkenc.Gzip
+}
+
+// ValidateCompression validates the compression of the index files.
+func ValidateCompression(compression string) error {
+	if _, ok := compressionExtensions[compression]; !ok {
+		return fmt.Errorf("unsupported index compression %q, supported values: %s, %s", compression, CompressionGzip, CompressionZstd)
+	}
+	return nil
+}
+
+// CompressedFileName returns the name of the file compressed with the compression.
+func CompressedFileName(fileName, compression string) string {
+	return fileName + compressionExtensions[compression]
+}
+
+// FileCompression returns the compression of the file detected from its extension, empty when it isn't compressed.
+func FileCompression(filename string) string {
+	for compression, extension := range compressionExtensions {
+		if strings.HasSuffix(filename, extension) {
+			return compression
+		}
+	}
+	return ""
+}
+
+// UncompressedFileName returns the name of the file without the extension of its compression.
+func UncompressedFileName(filename string) string {
+	if compression := FileCompression(filename); compression != "" {
+		return strings.TrimSuffix(filename, compressionExtensions[compression])
+	}
+	return filename
+}
+
 type IndexStorageClient interface {
 	GetFile(ctx context.Context, tableName, fileName string) (io.ReadCloser, error)
 	GetUserFile(ctx context.Context, tableName, userID, fileName string) (io.ReadCloser, error)
@@ -76,8 +161,9 @@ type IndexStorageClient interface {
 
 type GetFileFunc func() (io.ReadCloser, error)
 
-// DownloadFileFromStorage downloads a file from storage to given location.
-func DownloadFileFromStorage(destination string, decompressFile bool, sync bool, logger log.Logger, getFileFunc GetFileFunc) error {
+// DownloadFileFromStorage downloads a file from storage to given location, decompressing it with the compression unless
+// empty.
+func DownloadFileFromStorage(destination string, compression string, sync bool, logger log.Logger, getFileFunc GetFileFunc) error {
 	start := time.Now()
 	readCloser, err := getFileFunc()
 	if err != nil {
@@ -101,9 +187,9 @@ func DownloadFileFromStorage(destination string, decompressFile bool, sync bool,
 		}
 	}()
 	var objectReader io.Reader = readCloser
-	if decompressFile {
-		decompressedReader := getGzipReader(readCloser)
-		defer putGzipReader(decompressedReader)
+	if compression != "" {
+		decompressedReader := getCompressedReader(compression, readCloser)
+		defer putCompressedReader(compression, decompressedReader)
 
 		objectReader = decompressedReader
 	}
@@ -132,8 +218,9 @@ func BuildIndexFileName(tableName, uploader, dbName string) string {
 	return objectKey
 }
 
-func CompressFile(src, dest string, sync bool) error {
-	level.Info(util_log.Logger).Log("msg", "compressing the file", "src", src, "dest", dest)
+// CompressFile compresses the file src to dest with the compression.
+func CompressFile(src, dest, compression string, sync bool) error {
+	level.Info(util_log.Logger).Log("msg", "compressing the file", "src", src, "dest", dest, "compression", compression)
 	uncompressedFile, err := os.Open(src)
 	if err != nil {
 		return err
@@ -156,8 +243,8 @@ func CompressFile(src, dest string, sync bool) error {
 		}
 	}()
 
-	compressedWriter := getGzipWriter(compressedFile)
-	defer putGzipWriter(compressedWriter)
+	compressedWriter := getCompressedWriter(compression, compressedFile)
+	defer putCompressedWriter(compression, compressedWriter)
 
 	_, err = io.Copy(compressedWriter, uncompressedFile)
 	if err != nil {
@@ -165,7 +252,7 @@ func CompressFile(src, dest string, sync bool) error {
 	}
 
 	err = compressedWriter.Close()
-	if err == nil {
+	if err != nil {
 		return err
 	}
 	if sync {
@@ -243,10 +330,6 @@ func QueryKey(q chunk.IndexQuery) string {
 	return ret
 }
 
-func IsCompressedFile(filename string) bool {
-	return strings.HasSuffix(filename, ".gz")
-}
-
 func LoggerWithFilename(logger log.Logger, filename string) log.Logger {
 	return log.With(logger, "file-name", filename)
 }
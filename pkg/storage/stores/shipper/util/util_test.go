@@ -31,7 +31,7 @@ func Test_GetFileFromStorage(t *testing.T) {
 
 	indexStorageClient := storage.NewIndexStorageClient(objectClient, "")
 
-	require.NoError(t, DownloadFileFromStorage(filepath.Join(tempDir, "dest"), false,
+	require.NoError(t, DownloadFileFromStorage(filepath.Join(tempDir, "dest"), "",
 		false, util_log.Logger, func() (io.ReadCloser, error) {
 			return indexStorageClient.GetFile(context.Background(), tableName, "src")
 		}))
@@ -42,21 +42,25 @@ func Test_GetFileFromStorage(t *testing.T) {
 
 	require.Equal(t, testData, b)
 
-	// compress the file in storage
-	err = CompressFile(filepath.Join(tempDir, tableName, "src"), filepath.Join(tempDir, tableName, "src.gz"), true)
-	require.NoError(t, err)
+	for _, compression := range []string{CompressionGzip, CompressionZstd} {
+		compressedFileName := CompressedFileName("src", compression)
 
-	// get the compressed file from storage
-	require.NoError(t, DownloadFileFromStorage(filepath.Join(tempDir, "dest.gz"), true,
-		false, util_log.Logger, func() (io.ReadCloser, error) {
-			return indexStorageClient.GetFile(context.Background(), tableName, "src.gz")
-		}))
+		// compress the file in storage
+		err = CompressFile(filepath.Join(tempDir, tableName, "src"), filepath.Join(tempDir, tableName, compressedFileName), compression, true)
+		require.NoError(t, err)
 
-	// verify the contents of the downloaded gz file.
-	b, err = ioutil.ReadFile(filepath.Join(tempDir, "dest.gz"))
-	require.NoError(t, err)
+		// get the compressed file from storage
+		require.NoError(t, DownloadFileFromStorage(filepath.Join(tempDir, compressedFileName), FileCompression(compressedFileName),
+			false, util_log.Logger, func() (io.ReadCloser, error) {
+				return indexStorageClient.GetFile(context.Background(), tableName, compressedFileName)
+			}))
 
-	require.Equal(t, testData, b)
+		// verify the contents of the downloaded compressed file.
+		b, err = ioutil.ReadFile(filepath.Join(tempDir, compressedFileName))
+		require.NoError(t, err)
+
+		require.Equal(t, testData, b)
+	}
 }
 
 func Test_CompressFile(t *testing.T) {
@@ -70,7 +74,7 @@ func Test_CompressFile(t *testing.T) {
 
 	require.NoError(t, ioutil.WriteFile(uncompressedFilePath, testData, 0666))
 
-	require.NoError(t, CompressFile(uncompressedFilePath, compressedFilePath, true))
+	require.NoError(t, CompressFile(uncompressedFilePath, compressedFilePath, CompressionGzip, true))
 	require.FileExists(t, compressedFilePath)
 
 	testutil.DecompressFile(t, compressedFilePath, decompressedFilePath)
@@ -79,3 +83,22 @@ func Test_CompressFile(t *testing.T) {
 
 	require.Equal(t, testData, b)
 }
+
+func Test_FileCompression(t *testing.T) {
+	for _, tc := range []struct {
+		fileName    string
+		compression string
+	}{
+		{fileName: "ingester-1-1600000000", compression: ""},
+		{fileName: "ingester-1-1600000000.gz", compression: CompressionGzip},
+		{fileName: "compactor-1600000000.r.zst", compression: CompressionZstd},
+	} {
+		t.Run(tc.fileName, func(t *testing.T) {
+			require.Equal(t, tc.compression, FileCompression(tc.fileName))
+		})
+	}
+
+	require.Equal(t, "db.zst", CompressedFileName("db", CompressionZstd))
+	require.NoError(t, ValidateCompression(CompressionGzip))
+	require.Error(t, ValidateCompression("snappy"))
+}
@@ -0,0 +1,42 @@
+package tsdb
+
+import (
+	"flag"
+	"time"
+
+	shipper_util "github.com/grafana/loki/pkg/storage/stores/shipper/util"
+)
+
+// IndexType holds the index type for using tsdb with a shipper which keeps uploading the index files to a shared storage.
+const IndexType = "tsdb"
+
+type Config struct {
+	ActiveIndexDirectory string        `yaml:"active_index_directory"`
+	SharedStoreType      string        `yaml:"shared_store"`
+	SharedStoreKeyPrefix string        `yaml:"shared_store_key_prefix"`
+	CacheLocation        string        `yaml:"cache_location"`
+	BuildInterval        time.Duration `yaml:"build_interval"`
+	ResyncInterval       time.Duration `yaml:"resync_interval"`
+	IndexCompression     string        `yaml:"index_compression"`
+	IngesterName         string        `yaml:"-"`
+	// Mode is one of the boltdb-shipper modes: shipper.ModeReadWrite, shipper.ModeReadOnly or shipper.ModeWriteOnly.
+	Mode int `yaml:"-"`
+}
+
+// RegisterFlags registers flags.
+func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
+	f.StringVar(&cfg.ActiveIndexDirectory, "tsdb.shipper.active-index-directory", "", "Directory where ingesters would write tsdb index files which would then be uploaded by shipper to configured storage")
+	f.StringVar(&cfg.SharedStoreType, "tsdb.shipper.shared-store", "", "Shared store for keeping tsdb index files. Supported types: gcs, s3, azure, filesystem")
+	f.StringVar(&cfg.SharedStoreKeyPrefix, "tsdb.shipper.shared-store.key-prefix", "tsdb-index/", "Prefix to add to Object Keys in Shared store. Path separator(if any) should always be a '/'. Prefix should never start with a separator but should always end with it")
+	f.StringVar(&cfg.CacheLocation, "tsdb.shipper.cache-location", "", "Cache location for restoring tsdb index files for queries")
+	f.DurationVar(&cfg.BuildInterval, "tsdb.shipper.build-interval", 15*time.Minute, "How often the index of the recently written chunks is built into a tsdb file and uploaded")
+	f.DurationVar(&cfg.ResyncInterval, "tsdb.shipper.resync-interval", 5*time.Minute, "Resync downloaded files with the storage")
+	f.StringVar(&cfg.IndexCompression, "tsdb.shipper.index-compression", shipper_util.CompressionGzip, "Compression of the tsdb index files uploaded to the shared store. Supported values: gzip, zstd. The files are downloaded whatever their compression, uncompressed ones included.")
+}
+
+func (cfg *Config) Validate() error {
+	if err := shipper_util.ValidateCompression(cfg.IndexCompression); err != nil {
+		return err
+	}
+	return shipper_util.ValidateSharedStoreKeyPrefix(cfg.SharedStoreKeyPrefix)
+}
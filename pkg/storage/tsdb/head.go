@@ -0,0 +1,179 @@
+package tsdb
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/grafana/loki/pkg/storage/tsdb/index"
+)
+
+// Head is an in-memory Index holding the chunks of a tenant which have not been built into a tsdb file yet.
+// Lookups scan every series, heads are expected to only hold the chunks written since the last build.
+type Head struct {
+	mtx        sync.RWMutex
+	series     map[string]*headSeries
+	mint, maxt model.Time
+}
+
+type headSeries struct {
+	labels labels.Labels
+	fp     model.Fingerprint
+	chunks index.ChunkMetas
+}
+
+func NewHead() *Head {
+	return &Head{series: make(map[string]*headSeries)}
+}
+
+// Append adds a chunk to the series identified by the labels.
+func (h *Head) Append(ls labels.Labels, fp model.Fingerprint, chk index.ChunkMeta) {
+	id := ls.String()
+
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	s, ok := h.series[id]
+	if !ok {
+		s = &headSeries{labels: ls, fp: fp}
+		h.series[id] = s
+	}
+	s.chunks = append(s.chunks, chk)
+
+	if h.mint == 0 || chk.From() < h.mint {
+		h.mint = chk.From()
+	}
+	if chk.Through() > h.maxt {
+		h.maxt = chk.Through()
+	}
+}
+
+// Empty returns whether no chunk was appended to the head.
+func (h *Head) Empty() bool {
+	h.mtx.RLock()
+	defer h.mtx.RUnlock()
+	return len(h.series) == 0
+}
+
+func (h *Head) Bounds() (model.Time, model.Time) {
+	h.mtx.RLock()
+	defer h.mtx.RUnlock()
+	return h.mint, h.maxt
+}
+
+// Build writes the content of the head to a tsdb file at the given path.
+func (h *Head) Build(ctx context.Context, path string) error {
+	b := index.NewBuilder()
+
+	h.mtx.RLock()
+	for _, s := range h.series {
+		b.AddSeries(s.labels, s.fp, s.chunks)
+	}
+	h.mtx.RUnlock()
+
+	return b.Build(ctx, path)
+}
+
+// forSeries calls fn for every series matching the shard and the matchers.
+// It is called while holding the read lock, fn must not retain the chunks.
+func (h *Head) forSeries(shard *index.ShardAnnotation, fn func(*headSeries), matchers ...*labels.Matcher) {
+	h.mtx.RLock()
+	defer h.mtx.RUnlock()
+
+outer:
+	for _, s := range h.series {
+		if shard != nil && !shard.Match(s.fp) {
+			continue
+		}
+		for _, m := range matchers {
+			if !m.Matches(s.labels.Get(m.Name)) {
+				continue outer
+			}
+		}
+		fn(s)
+	}
+}
+
+func (h *Head) GetChunkRefs(_ context.Context, userID string, from, through model.Time, res []ChunkRef, shard *index.ShardAnnotation, matchers ...*labels.Matcher) ([]ChunkRef, error) {
+	queryBounds := newBounds(from, through)
+	if res == nil {
+		res = ChunkRefsPool.Get()
+	}
+	res = res[:0]
+
+	h.forSeries(shard, func(s *headSeries) {
+		for _, chk := range s.chunks {
+			if !Overlap(queryBounds, chk) {
+				continue
+			}
+
+			res = append(res, ChunkRef{
+				User:        userID,
+				Fingerprint: s.fp,
+				Start:       chk.From(),
+				End:         chk.Through(),
+				Checksum:    chk.Checksum,
+				KB:          chk.KB,
+				Entries:     chk.Entries,
+			})
+		}
+	}, matchers...)
+
+	return res, nil
+}
+
+func (h *Head) Series(_ context.Context, _ string, from, through model.Time, res []Series, shard *index.ShardAnnotation, matchers ...*labels.Matcher) ([]Series, error) {
+	queryBounds := newBounds(from, through)
+	if res == nil {
+		res = SeriesPool.Get()
+	}
+	res = res[:0]
+
+	h.forSeries(shard, func(s *headSeries) {
+		for _, chk := range s.chunks {
+			if Overlap(queryBounds, chk) {
+				res = append(res, Series{
+					Labels:      s.labels.Copy(),
+					Fingerprint: s.fp,
+				})
+				break
+			}
+		}
+	}, matchers...)
+
+	return res, nil
+}
+
+func (h *Head) LabelNames(_ context.Context, _ string, _, _ model.Time, matchers ...*labels.Matcher) ([]string, error) {
+	seen := make(map[string]struct{})
+	h.forSeries(nil, func(s *headSeries) {
+		for _, l := range s.labels {
+			seen[l.Name] = struct{}{}
+		}
+	}, matchers...)
+
+	return sortedKeys(seen), nil
+}
+
+func (h *Head) LabelValues(_ context.Context, _ string, _, _ model.Time, name string, matchers ...*labels.Matcher) ([]string, error) {
+	seen := make(map[string]struct{})
+	h.forSeries(nil, func(s *headSeries) {
+		if v := s.labels.Get(name); v != "" {
+			seen[v] = struct{}{}
+		}
+	}, matchers...)
+
+	return sortedKeys(seen), nil
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	res := make([]string, 0, len(m))
+	for k := range m {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	return res
+}
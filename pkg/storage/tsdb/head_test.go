@@ -0,0 +1,73 @@
+package tsdb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/pkg/storage/tsdb/index"
+)
+
+func TestHead(t *testing.T) {
+	h := NewHead()
+	require.True(t, h.Empty())
+
+	fooA := mustParseLabels(`{foo="bar", app="a"}`)
+	fooB := mustParseLabels(`{foo="bar", app="b"}`)
+	h.Append(fooA, model.Fingerprint(1), index.ChunkMeta{Checksum: 1, MinTime: 10, MaxTime: 20})
+	h.Append(fooA, model.Fingerprint(1), index.ChunkMeta{Checksum: 2, MinTime: 20, MaxTime: 30})
+	h.Append(fooB, model.Fingerprint(2), index.ChunkMeta{Checksum: 3, MinTime: 5, MaxTime: 15})
+
+	require.False(t, h.Empty())
+	from, through := h.Bounds()
+	require.Equal(t, model.Time(5), from)
+	require.Equal(t, model.Time(30), through)
+
+	ctx := context.Background()
+	refs, err := h.GetChunkRefs(ctx, "fake", 0, 100, nil, nil, labels.MustNewMatcher(labels.MatchEqual, "app", "a"))
+	require.NoError(t, err)
+	require.ElementsMatch(t, []ChunkRef{
+		{User: "fake", Fingerprint: 1, Start: 10, End: 20, Checksum: 1},
+		{User: "fake", Fingerprint: 1, Start: 20, End: 30, Checksum: 2},
+	}, refs)
+
+	refs, err = h.GetChunkRefs(ctx, "fake", 0, 12, nil, nil, labels.MustNewMatcher(labels.MatchEqual, "foo", "bar"))
+	require.NoError(t, err)
+	require.ElementsMatch(t, []ChunkRef{
+		{User: "fake", Fingerprint: 1, Start: 10, End: 20, Checksum: 1},
+		{User: "fake", Fingerprint: 2, Start: 5, End: 15, Checksum: 3},
+	}, refs)
+
+	series, err := h.Series(ctx, "fake", 21, 100, nil, nil, labels.MustNewMatcher(labels.MatchRegexp, "app", ".+"))
+	require.NoError(t, err)
+	require.Equal(t, []Series{{Labels: fooA, Fingerprint: 1}}, series)
+
+	names, err := h.LabelNames(ctx, "fake", 0, 100)
+	require.NoError(t, err)
+	require.Equal(t, []string{"app", "foo"}, names)
+
+	values, err := h.LabelValues(ctx, "fake", 0, 100, "app", labels.MustNewMatcher(labels.MatchNotEqual, "app", "a"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"b"}, values)
+}
+
+func TestHead_Build(t *testing.T) {
+	h := NewHead()
+	ls := mustParseLabels(`{foo="bar"}`)
+	// the fingerprint of the series is kept even though it doesn't match the hash of its labels.
+	h.Append(ls, model.Fingerprint(42), index.ChunkMeta{Checksum: 1, MinTime: 10, MaxTime: 20})
+
+	path := t.TempDir() + "/index.tsdb"
+	require.NoError(t, h.Build(context.Background(), path))
+
+	reader, err := index.NewFileReader(path)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	refs, err := NewTSDBIndex(reader).GetChunkRefs(context.Background(), "fake", 0, 100, nil, nil, labels.MustNewMatcher(labels.MatchEqual, "foo", "bar"))
+	require.NoError(t, err)
+	require.Equal(t, []ChunkRef{{User: "fake", Fingerprint: 42, Start: 10, End: 20, Checksum: 1}}, refs)
+}
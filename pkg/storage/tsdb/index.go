@@ -19,6 +19,9 @@ type ChunkRef struct {
 	Fingerprint model.Fingerprint
 	Start, End  model.Time
 	Checksum    uint32
+	// KB and Entries are the size of the chunk, zero for the chunks indexed before it was recorded.
+	KB      uint32
+	Entries uint32
 }
 
 // Compares by (Start, End)
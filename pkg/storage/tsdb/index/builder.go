@@ -4,6 +4,7 @@ import (
 	"context"
 	"sort"
 
+	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/storage"
 )
@@ -19,6 +20,7 @@ type Builder struct {
 
 type stream struct {
 	labels labels.Labels
+	fp     model.Fingerprint
 	chunks ChunkMetas
 }
 
@@ -26,12 +28,13 @@ func NewBuilder() *Builder {
 	return &Builder{streams: make(map[string]*stream)}
 }
 
-func (b *Builder) AddSeries(ls labels.Labels, chks []ChunkMeta) {
+func (b *Builder) AddSeries(ls labels.Labels, fp model.Fingerprint, chks []ChunkMeta) {
 	id := ls.String()
 	s, ok := b.streams[id]
 	if !ok {
 		s = &stream{
 			labels: ls,
+			fp:     fp,
 		}
 		b.streams[id] = s
 	}
@@ -52,7 +55,7 @@ func (b *Builder) Build(ctx context.Context, dir string) error {
 		streams = append(streams, s)
 	}
 	sort.Slice(streams, func(i, j int) bool {
-		return streams[i].labels.Hash() < streams[j].labels.Hash()
+		return streams[i].fp < streams[j].fp
 	})
 
 	// Build symbols
@@ -80,7 +83,7 @@ func (b *Builder) Build(ctx context.Context, dir string) error {
 
 	// Add series
 	for i, s := range streams {
-		if err := writer.AddSeries(storage.SeriesRef(i), s.labels, s.chunks.finalize()...); err != nil {
+		if err := writer.AddSeries(storage.SeriesRef(i), s.labels, s.fp, s.chunks.finalize()...); err != nil {
 			return err
 		}
 	}
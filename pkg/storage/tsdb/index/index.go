@@ -30,6 +30,7 @@ import (
 	"unsafe"
 
 	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/storage"
 	tsdb_enc "github.com/prometheus/prometheus/tsdb/encoding"
@@ -434,12 +435,15 @@ func (w *Writer) writeMeta() error {
 }
 
 // AddSeries adds the series one at a time along with its chunks.
-func (w *Writer) AddSeries(ref storage.SeriesRef, lset labels.Labels, chunks ...ChunkMeta) error {
+// Series must be added in order of their fingerprint, which is stored
+// in place of the label hash so callers can preserve fingerprints
+// that were remapped on collision.
+func (w *Writer) AddSeries(ref storage.SeriesRef, lset labels.Labels, fp model.Fingerprint, chunks ...ChunkMeta) error {
 	if err := w.ensureStage(idxStageSeries); err != nil {
 		return err
 	}
 
-	labelHash := lset.Hash()
+	labelHash := uint64(fp)
 	if labelHash < w.lastSeries {
 		return errors.Errorf("out-of-order series added with label set %q", lset)
 	}
@@ -0,0 +1,821 @@
+package tsdb
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/loki/pkg/querier/astmapper"
+	"github.com/grafana/loki/pkg/storage/chunk"
+	chunk_util "github.com/grafana/loki/pkg/storage/chunk/util"
+	"github.com/grafana/loki/pkg/storage/stores/shipper"
+	"github.com/grafana/loki/pkg/storage/stores/shipper/storage"
+	shipper_util "github.com/grafana/loki/pkg/storage/stores/shipper/util"
+	"github.com/grafana/loki/pkg/storage/tsdb/index"
+	util_log "github.com/grafana/loki/pkg/util/log"
+)
+
+const (
+	indexFileExtension = ".tsdb"
+	walDirName         = "wal"
+
+	statusSuccess = "success"
+	statusFailure = "failure"
+)
+
+// tenantHeads holds the heads by table and tenant.
+type tenantHeads map[string]map[string]*Head
+
+func (t tenantHeads) get(table, userID string) *Head {
+	return t[table][userID]
+}
+
+func (t tenantHeads) getOrCreate(table, userID string) *Head {
+	h := t.get(table, userID)
+	if h == nil {
+		h = NewHead()
+		t.set(table, userID, h)
+	}
+	return h
+}
+
+func (t tenantHeads) set(table, userID string, h *Head) {
+	users, ok := t[table]
+	if !ok {
+		users = map[string]*Head{}
+		t[table] = users
+	}
+	users[userID] = h
+}
+
+// rotatedHeads are heads waiting to be built into tsdb files.
+type rotatedHeads struct {
+	heads tenantHeads
+	// walSegment is the last segment of the WAL holding chunks of these heads.
+	walSegment int
+}
+
+// indexFile is a tsdb file opened for queries.
+type indexFile struct {
+	*TSDBIndex
+	name     string
+	path     string
+	reader   *index.Reader
+	uploaded time.Time
+}
+
+func openIndexFile(path string) (*indexFile, error) {
+	reader, err := index.NewFileReader(path)
+	if err != nil {
+		return nil, err
+	}
+	return &indexFile{
+		TSDBIndex: NewTSDBIndex(reader),
+		name:      filepath.Base(path),
+		path:      path,
+		reader:    reader,
+	}, nil
+}
+
+// remoteIndexSet holds the files of the shared store for a table and tenant.
+type remoteIndexSet struct {
+	table, userID string
+	dir           string
+
+	mtx   sync.RWMutex
+	files map[string]*indexFile
+}
+
+type metrics struct {
+	builds  *prometheus.CounterVec
+	uploads *prometheus.CounterVec
+	syncs   *prometheus.CounterVec
+}
+
+func newMetrics(r prometheus.Registerer) *metrics {
+	return &metrics{
+		builds: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "loki_tsdb_shipper",
+			Name:      "index_builds_total",
+			Help:      "Total number of tsdb index files built from the heads.",
+		}, []string{"status"}),
+		uploads: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "loki_tsdb_shipper",
+			Name:      "uploads_total",
+			Help:      "Total number of tsdb index files uploaded to the shared store.",
+		}, []string{"status"}),
+		syncs: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "loki_tsdb_shipper",
+			Name:      "syncs_total",
+			Help:      "Total number of syncs of the downloaded tsdb index files with the shared store.",
+		}, []string{"status"}),
+	}
+}
+
+// IndexShipper is the chunk.SeriesIndex of a period using the tsdb index type.
+// Chunks are added to in-memory heads, one per table and tenant, which are periodically built into
+// tsdb files and uploaded to the shared store. Queries read the heads, the files not uploaded for long
+// and the files downloaded from the shared store.
+type IndexShipper struct {
+	cfg           Config
+	period        chunk.PeriodConfig
+	uploader      string
+	dir, cacheDir string
+	storageClient storage.Client
+	metrics       *metrics
+	logger        log.Logger
+
+	headsMtx sync.RWMutex
+	heads    tenantHeads
+	rotated  []rotatedHeads
+	wal      *headWAL
+
+	localMtx sync.RWMutex
+	local    map[string]map[string][]*indexFile
+
+	remoteMtx sync.Mutex
+	remote    map[string]*remoteIndexSet
+
+	quit     chan struct{}
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+}
+
+// NewIndexShipper creates the index of a period. name must be unique amongst the periods, it is used
+// for keeping the local files of the periods apart.
+func NewIndexShipper(cfg Config, period chunk.PeriodConfig, name string, objectClient chunk.ObjectClient, registerer prometheus.Registerer) (*IndexShipper, error) {
+	s := &IndexShipper{
+		cfg:           cfg,
+		period:        period,
+		dir:           filepath.Join(cfg.ActiveIndexDirectory, name),
+		cacheDir:      filepath.Join(cfg.CacheLocation, name),
+		storageClient: storage.NewIndexStorageClient(objectClient, cfg.SharedStoreKeyPrefix),
+		metrics:       newMetrics(registerer),
+		logger:        log.With(util_log.Logger, "index-store", IndexType, "period", name),
+		heads:         tenantHeads{},
+		local:         map[string]map[string][]*indexFile{},
+		remote:        map[string]*remoteIndexSet{},
+		quit:          make(chan struct{}),
+	}
+
+	if cfg.Mode != shipper.ModeReadOnly {
+		if err := s.initWrites(); err != nil {
+			return nil, err
+		}
+		s.wg.Add(1)
+		go s.buildLoop()
+	}
+
+	if cfg.Mode != shipper.ModeWriteOnly {
+		if err := chunk_util.EnsureDirectory(s.cacheDir); err != nil {
+			return nil, err
+		}
+		s.wg.Add(1)
+		go s.resyncLoop()
+	}
+
+	level.Info(s.logger).Log("msg", fmt.Sprintf("starting tsdb shipper in %d mode", cfg.Mode))
+	return s, nil
+}
+
+func (s *IndexShipper) initWrites() error {
+	s.uploader = s.cfg.IngesterName
+	if s.uploader == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return err
+		}
+		s.uploader = hostname
+	}
+
+	// load the files which were built but possibly not uploaded before a restart.
+	tables, err := ioutil.ReadDir(s.dir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, table := range tables {
+		if !table.IsDir() || table.Name() == walDirName {
+			continue
+		}
+		users, err := ioutil.ReadDir(filepath.Join(s.dir, table.Name()))
+		if err != nil {
+			return err
+		}
+		for _, user := range users {
+			files, err := ioutil.ReadDir(filepath.Join(s.dir, table.Name(), user.Name()))
+			if err != nil {
+				return err
+			}
+			for _, f := range files {
+				if !strings.HasSuffix(f.Name(), indexFileExtension) {
+					continue
+				}
+				path := filepath.Join(s.dir, table.Name(), user.Name(), f.Name())
+				idx, err := openIndexFile(path)
+				if err != nil {
+					// the heads are rebuilt from the WAL when a build did not complete.
+					level.Warn(s.logger).Log("msg", "removing unreadable tsdb index file", "file", path, "err", err)
+					if err := os.Remove(path); err != nil {
+						return err
+					}
+					continue
+				}
+				s.addLocal(table.Name(), user.Name(), idx)
+			}
+		}
+	}
+
+	s.wal, err = openHeadWAL(filepath.Join(s.dir, walDirName), s.logger, func(rec walRecord) {
+		s.heads.getOrCreate(rec.Table, rec.User).Append(rec.Labels, rec.Fingerprint, rec.Chunk)
+	})
+	return err
+}
+
+func (s *IndexShipper) addLocal(table, userID string, idx *indexFile) {
+	s.localMtx.Lock()
+	defer s.localMtx.Unlock()
+
+	users, ok := s.local[table]
+	if !ok {
+		users = map[string][]*indexFile{}
+		s.local[table] = users
+	}
+	users[userID] = append(users[userID], idx)
+}
+
+// tables returns the names of the tables of the period overlapping the time range.
+func (s *IndexShipper) tables(from, through model.Time) []string {
+	cfg := s.period.IndexTables
+	if cfg.Period == 0 {
+		return []string{cfg.TableFor(from)}
+	}
+
+	periodSecs := int64(cfg.Period / time.Second)
+	tables := make([]string, 0, through.Unix()/periodSecs-from.Unix()/periodSecs+1)
+	for i := from.Unix() / periodSecs; i <= through.Unix()/periodSecs; i++ {
+		tables = append(tables, cfg.TableFor(model.TimeFromUnix(i*periodSecs)))
+	}
+	return tables
+}
+
+// IndexChunk implements chunk.SeriesIndex
+func (s *IndexShipper) IndexChunk(_ context.Context, from, through model.Time, chk chunk.Chunk) error {
+	if s.cfg.Mode == shipper.ModeReadOnly {
+		return fmt.Errorf("tsdb shipper is running in read-only mode")
+	}
+
+	meta := index.ChunkMeta{
+		Checksum: chk.Checksum,
+		MinTime:  int64(chk.From),
+		MaxTime:  int64(chk.Through),
+	}
+	if chk.Data != nil {
+		meta.KB = uint32(chk.Data.Size()+1<<9) >> 10
+		meta.Entries = uint32(chk.Data.Len())
+	}
+
+	tables := s.tables(from, through)
+	recs := make([]walRecord, 0, len(tables))
+	for _, table := range tables {
+		recs = append(recs, walRecord{
+			Table:       table,
+			User:        chk.UserID,
+			Labels:      chk.Metric,
+			Fingerprint: model.Fingerprint(chk.Fingerprint),
+			Chunk:       meta,
+		})
+	}
+
+	s.headsMtx.RLock()
+	defer s.headsMtx.RUnlock()
+
+	if err := s.wal.Log(recs...); err != nil {
+		return err
+	}
+	for _, rec := range recs {
+		s.heads.getOrCreate(rec.Table, rec.User).Append(rec.Labels, rec.Fingerprint, rec.Chunk)
+	}
+	return nil
+}
+
+func (s *IndexShipper) buildLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.cfg.BuildInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.buildAndUpload(context.Background())
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// buildAndUpload builds the heads into tsdb files, uploads the files and removes the uploaded ones
+// which were kept long enough for the queriers to have downloaded them.
+func (s *IndexShipper) buildAndUpload(ctx context.Context) {
+	if err := s.build(ctx); err != nil {
+		level.Error(s.logger).Log("msg", "failed to build tsdb index files", "err", err)
+	}
+	s.upload(ctx)
+	s.cleanupUploaded()
+}
+
+func (s *IndexShipper) build(ctx context.Context) error {
+	s.headsMtx.Lock()
+	segment, err := s.wal.Cut()
+	if err != nil {
+		s.headsMtx.Unlock()
+		return err
+	}
+	if len(s.heads) > 0 {
+		s.rotated = append(s.rotated, rotatedHeads{heads: s.heads, walSegment: segment})
+		s.heads = tenantHeads{}
+	}
+	rotated := s.rotated
+	s.headsMtx.Unlock()
+
+	// heads failing to build are kept for the next build.
+	pending := make([]rotatedHeads, 0, len(rotated))
+	for _, r := range rotated {
+		failed := tenantHeads{}
+		for table, users := range r.heads {
+			for userID, head := range users {
+				if err := s.buildHead(ctx, table, userID, head); err != nil {
+					level.Error(s.logger).Log("msg", "failed to build tsdb index file", "table", table, "user", userID, "err", err)
+					failed.set(table, userID, head)
+				}
+			}
+		}
+		if len(failed) > 0 {
+			pending = append(pending, rotatedHeads{heads: failed, walSegment: r.walSegment})
+		}
+	}
+
+	s.headsMtx.Lock()
+	s.rotated = pending
+	s.headsMtx.Unlock()
+
+	// the WAL segments are still needed for the pending heads.
+	truncateTo := segment
+	if len(pending) > 0 {
+		truncateTo = -1
+		for _, r := range rotated {
+			if r.walSegment >= pending[0].walSegment {
+				break
+			}
+			truncateTo = r.walSegment
+		}
+	}
+	if truncateTo < 0 {
+		return nil
+	}
+	return s.wal.Truncate(truncateTo)
+}
+
+func (s *IndexShipper) buildHead(ctx context.Context, table, userID string, head *Head) error {
+	dir := filepath.Join(s.dir, table, userID)
+	if err := chunk_util.EnsureDirectory(dir); err != nil {
+		return err
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("%s-%d%s", s.uploader, time.Now().UnixNano(), indexFileExtension))
+	if err := head.Build(ctx, path); err != nil {
+		s.metrics.builds.WithLabelValues(statusFailure).Inc()
+		_ = os.Remove(path)
+		return err
+	}
+
+	idx, err := openIndexFile(path)
+	if err != nil {
+		s.metrics.builds.WithLabelValues(statusFailure).Inc()
+		_ = os.Remove(path)
+		return err
+	}
+	s.metrics.builds.WithLabelValues(statusSuccess).Inc()
+	s.addLocal(table, userID, idx)
+	return nil
+}
+
+func (s *IndexShipper) upload(ctx context.Context) {
+	s.localMtx.RLock()
+	defer s.localMtx.RUnlock()
+
+	for table, users := range s.local {
+		for userID, files := range users {
+			for _, idx := range files {
+				if !idx.uploaded.IsZero() {
+					continue
+				}
+				if err := s.uploadFile(ctx, table, userID, idx); err != nil {
+					s.metrics.uploads.WithLabelValues(statusFailure).Inc()
+					level.Error(s.logger).Log("msg", "failed to upload tsdb index file", "table", table, "user", userID, "file", idx.name, "err", err)
+					continue
+				}
+				s.metrics.uploads.WithLabelValues(statusSuccess).Inc()
+				idx.uploaded = time.Now()
+			}
+		}
+	}
+}
+
+// uploadFile uploads the file compressed, the local file being kept uncompressed for the queries.
+func (s *IndexShipper) uploadFile(ctx context.Context, table, userID string, idx *indexFile) error {
+	compressedPath := idx.path + ".tmp"
+	if err := shipper_util.CompressFile(idx.path, compressedPath, s.cfg.IndexCompression, false); err != nil {
+		_ = os.Remove(compressedPath)
+		return err
+	}
+	defer func() {
+		if err := os.Remove(compressedPath); err != nil {
+			level.Warn(s.logger).Log("msg", "failed to remove compressed tsdb index file", "file", compressedPath, "err", err)
+		}
+	}()
+
+	f, err := os.Open(compressedPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return s.storageClient.PutUserFile(ctx, table, userID, shipper_util.CompressedFileName(idx.name, s.cfg.IndexCompression), f)
+}
+
+// cleanupUploaded removes the local files uploaded for longer than a resync of the queriers.
+func (s *IndexShipper) cleanupUploaded() {
+	s.localMtx.Lock()
+	defer s.localMtx.Unlock()
+
+	for table, users := range s.local {
+		for userID, files := range users {
+			kept := files[:0]
+			for _, idx := range files {
+				if idx.uploaded.IsZero() || time.Since(idx.uploaded) < 2*s.cfg.ResyncInterval {
+					kept = append(kept, idx)
+					continue
+				}
+				if err := idx.reader.Close(); err != nil {
+					level.Warn(s.logger).Log("msg", "failed to close tsdb index file", "file", idx.path, "err", err)
+				}
+				if err := os.Remove(idx.path); err != nil {
+					level.Warn(s.logger).Log("msg", "failed to remove tsdb index file", "file", idx.path, "err", err)
+				}
+			}
+			users[userID] = kept
+			if len(kept) == 0 {
+				delete(users, userID)
+			}
+		}
+		if len(users) == 0 {
+			delete(s.local, table)
+		}
+	}
+}
+
+func (s *IndexShipper) resyncLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.cfg.ResyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.remoteMtx.Lock()
+			sets := make([]*remoteIndexSet, 0, len(s.remote))
+			for _, set := range s.remote {
+				sets = append(sets, set)
+			}
+			s.remoteMtx.Unlock()
+
+			for _, set := range sets {
+				if err := s.sync(context.Background(), set); err != nil {
+					level.Error(s.logger).Log("msg", "failed to sync tsdb index files", "table", set.table, "user", set.userID, "err", err)
+				}
+			}
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// remoteSet returns the files of the shared store for the table and tenant, downloading them on first use.
+func (s *IndexShipper) remoteSet(ctx context.Context, table, userID string) (*remoteIndexSet, error) {
+	key := table + "/" + userID
+
+	s.remoteMtx.Lock()
+	set, ok := s.remote[key]
+	if ok {
+		s.remoteMtx.Unlock()
+		return set, nil
+	}
+	set = &remoteIndexSet{
+		table:  table,
+		userID: userID,
+		dir:    filepath.Join(s.cacheDir, table, userID),
+		files:  map[string]*indexFile{},
+	}
+	s.remote[key] = set
+	// hold the lock of the set until the first sync is done, concurrent queries wait for it.
+	set.mtx.Lock()
+	s.remoteMtx.Unlock()
+
+	err := s.syncLocked(ctx, set)
+	set.mtx.Unlock()
+	if err != nil {
+		s.remoteMtx.Lock()
+		delete(s.remote, key)
+		s.remoteMtx.Unlock()
+		return nil, err
+	}
+	return set, nil
+}
+
+func (s *IndexShipper) sync(ctx context.Context, set *remoteIndexSet) error {
+	set.mtx.Lock()
+	defer set.mtx.Unlock()
+	return s.syncLocked(ctx, set)
+}
+
+// syncLocked downloads the new files of the set and drops the ones removed from the shared store.
+func (s *IndexShipper) syncLocked(ctx context.Context, set *remoteIndexSet) (err error) {
+	defer func() {
+		status := statusSuccess
+		if err != nil {
+			status = statusFailure
+		}
+		s.metrics.syncs.WithLabelValues(status).Inc()
+	}()
+
+	files, err := s.storageClient.ListUserFiles(ctx, set.table, set.userID)
+	if err != nil {
+		return err
+	}
+	if err := chunk_util.EnsureDirectory(set.dir); err != nil {
+		return err
+	}
+
+	listed := make(map[string]struct{}, len(files))
+	for _, f := range files {
+		listed[f.Name] = struct{}{}
+		if _, ok := set.files[f.Name]; ok {
+			continue
+		}
+
+		path := filepath.Join(set.dir, f.Name)
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			if err := s.download(ctx, set, f.Name, path); err != nil {
+				return err
+			}
+		}
+
+		idx, err := openIndexFile(path)
+		if err != nil {
+			return err
+		}
+		set.files[f.Name] = idx
+	}
+
+	for name, idx := range set.files {
+		if _, ok := listed[name]; ok {
+			continue
+		}
+		delete(set.files, name)
+		if err := idx.reader.Close(); err != nil {
+			level.Warn(s.logger).Log("msg", "failed to close tsdb index file", "file", idx.path, "err", err)
+		}
+		if err := os.Remove(idx.path); err != nil {
+			level.Warn(s.logger).Log("msg", "failed to remove tsdb index file", "file", idx.path, "err", err)
+		}
+	}
+	return nil
+}
+
+func (s *IndexShipper) download(ctx context.Context, set *remoteIndexSet, name, path string) error {
+	// download to a temporary file so that a partial download is never opened. The file is decompressed, it keeps the
+	// name it has in the shared store for the files uploaded with different compressions to not collide.
+	tmp := path + ".tmp"
+	err := shipper_util.DownloadFileFromStorage(tmp, shipper_util.FileCompression(name), true, s.logger, func() (io.ReadCloser, error) {
+		return s.storageClient.GetUserFile(ctx, set.table, set.userID, name)
+	})
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// forIndex calls fn with an Index over all the heads and files of the tenant overlapping the time range.
+// fn is not called when there is nothing to query.
+func (s *IndexShipper) forIndex(ctx context.Context, userID string, from, through model.Time, fn func(Index) error) error {
+	var indices []Index
+
+	tables := s.tables(from, through)
+	if s.cfg.Mode != shipper.ModeReadOnly {
+		s.headsMtx.RLock()
+		for _, table := range tables {
+			if h := s.heads.get(table, userID); h != nil {
+				indices = append(indices, h)
+			}
+			for _, r := range s.rotated {
+				if h := r.heads.get(table, userID); h != nil {
+					indices = append(indices, h)
+				}
+			}
+		}
+		s.headsMtx.RUnlock()
+	}
+
+	// the locks are held until fn returns since files could otherwise be closed while being read.
+	s.localMtx.RLock()
+	defer s.localMtx.RUnlock()
+	for _, table := range tables {
+		for _, idx := range s.local[table][userID] {
+			indices = append(indices, idx)
+		}
+	}
+
+	if s.cfg.Mode != shipper.ModeWriteOnly {
+		for _, table := range tables {
+			set, err := s.remoteSet(ctx, table, userID)
+			if err != nil {
+				return err
+			}
+			set.mtx.RLock()
+			defer set.mtx.RUnlock()
+			for _, idx := range set.files {
+				indices = append(indices, idx)
+			}
+		}
+	}
+
+	if len(indices) == 0 {
+		return nil
+	}
+
+	idx, err := NewMultiIndex(indices...)
+	if err != nil {
+		return err
+	}
+	return fn(idx)
+}
+
+// GetChunkRefs implements chunk.SeriesIndex
+func (s *IndexShipper) GetChunkRefs(ctx context.Context, userID string, from, through model.Time, shard *astmapper.ShardAnnotation, matchers ...*labels.Matcher) ([]chunk.Chunk, error) {
+	var chunks []chunk.Chunk
+	err := s.forIndex(ctx, userID, from, through, func(idx Index) error {
+		refs, err := idx.GetChunkRefs(ctx, userID, from, through, nil, nil, matchers...)
+		if err != nil {
+			return err
+		}
+		defer ChunkRefsPool.Put(refs)
+
+		chunks = make([]chunk.Chunk, 0, len(refs))
+		for _, ref := range refs {
+			// the shards of the queries don't follow the tsdb sharding.
+			if shard != nil && !shard.Match(ref.Fingerprint) {
+				continue
+			}
+			chunks = append(chunks, chunk.Chunk{
+				ChunkRef: logproto.ChunkRef{
+					Fingerprint: uint64(ref.Fingerprint),
+					UserID:      ref.User,
+					From:        ref.Start,
+					Through:     ref.End,
+					Checksum:    ref.Checksum,
+				},
+				ChecksumSet: true,
+				KB:          ref.KB,
+				Entries:     ref.Entries,
+			})
+		}
+		return nil
+	})
+	return chunks, err
+}
+
+// LabelNames implements chunk.SeriesIndex
+func (s *IndexShipper) LabelNames(ctx context.Context, userID string, from, through model.Time, shard *astmapper.ShardAnnotation, matchers ...*labels.Matcher) ([]string, error) {
+	var names []string
+	err := s.forIndex(ctx, userID, from, through, func(idx Index) error {
+		if shard != nil {
+			var err error
+			names, err = shardLabelNames(ctx, idx, userID, from, through, shard, matchers...)
+			return err
+		}
+
+		all, err := idx.LabelNames(ctx, userID, from, through, matchers...)
+		if err != nil {
+			return err
+		}
+
+		names = make([]string, 0, len(all))
+		for _, name := range all {
+			if name != labels.MetricName {
+				names = append(names, name)
+			}
+		}
+		return nil
+	})
+	return names, err
+}
+
+// shardLabelNames returns the label names of the series of the shard matching the matchers, except the metric name.
+func shardLabelNames(ctx context.Context, idx Index, userID string, from, through model.Time, shard *astmapper.ShardAnnotation, matchers ...*labels.Matcher) ([]string, error) {
+	series, err := idx.Series(ctx, userID, from, through, nil, nil, matchers...)
+	if err != nil {
+		return nil, err
+	}
+	defer SeriesPool.Put(series)
+
+	seen := map[string]struct{}{}
+	for _, s := range series {
+		// the shards of the queries don't follow the tsdb sharding.
+		if !shard.Match(s.Fingerprint) {
+			continue
+		}
+		for _, l := range s.Labels {
+			if l.Name != labels.MetricName {
+				seen[l.Name] = struct{}{}
+			}
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// LabelValues implements chunk.SeriesIndex
+func (s *IndexShipper) LabelValues(ctx context.Context, userID string, from, through model.Time, name string, matchers ...*labels.Matcher) ([]string, error) {
+	var values []string
+	err := s.forIndex(ctx, userID, from, through, func(idx Index) error {
+		var err error
+		values, err = idx.LabelValues(ctx, userID, from, through, name, matchers...)
+		return err
+	})
+	return values, err
+}
+
+// Stop builds and uploads the heads, then releases the files.
+func (s *IndexShipper) Stop() {
+	s.stopOnce.Do(s.stop)
+}
+
+func (s *IndexShipper) stop() {
+	close(s.quit)
+	s.wg.Wait()
+
+	if s.cfg.Mode != shipper.ModeReadOnly {
+		if err := s.build(context.Background()); err != nil {
+			level.Error(s.logger).Log("msg", "failed to build tsdb index files", "err", err)
+		}
+		s.upload(context.Background())
+		if err := s.wal.Close(); err != nil {
+			level.Warn(s.logger).Log("msg", "failed to close tsdb head WAL", "err", err)
+		}
+	}
+
+	s.localMtx.Lock()
+	for _, users := range s.local {
+		for _, files := range users {
+			for _, idx := range files {
+				_ = idx.reader.Close()
+			}
+		}
+	}
+	s.localMtx.Unlock()
+
+	s.remoteMtx.Lock()
+	for _, set := range s.remote {
+		set.mtx.Lock()
+		for _, idx := range set.files {
+			_ = idx.reader.Close()
+		}
+		set.mtx.Unlock()
+	}
+	s.remoteMtx.Unlock()
+
+	s.storageClient.Stop()
+}
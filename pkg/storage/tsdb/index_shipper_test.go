@@ -0,0 +1,228 @@
+package tsdb
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/loki/pkg/querier/astmapper"
+	"github.com/grafana/loki/pkg/storage/chunk"
+	"github.com/grafana/loki/pkg/storage/chunk/local"
+	"github.com/grafana/loki/pkg/storage/stores/shipper"
+	shipper_util "github.com/grafana/loki/pkg/storage/stores/shipper/util"
+)
+
+func newTestIndexShipper(t *testing.T, dir string, mode int) *IndexShipper {
+	return newTestIndexShipperWithCompression(t, dir, mode, shipper_util.CompressionGzip)
+}
+
+func newTestIndexShipperWithCompression(t *testing.T, dir string, mode int, compression string) *IndexShipper {
+	objectClient, err := local.NewFSObjectClient(local.FSConfig{Directory: filepath.Join(dir, "objects")})
+	require.NoError(t, err)
+
+	cfg := Config{
+		ActiveIndexDirectory: filepath.Join(dir, "active"),
+		CacheLocation:        filepath.Join(dir, "cache"),
+		SharedStoreKeyPrefix: "tsdb-index/",
+		BuildInterval:        time.Hour,
+		ResyncInterval:       time.Hour,
+		IndexCompression:     compression,
+		IngesterName:         "ingester-1",
+		Mode:                 mode,
+	}
+	period := chunk.PeriodConfig{
+		IndexType: IndexType,
+		IndexTables: chunk.PeriodicTableConfig{
+			Prefix: "index_",
+			Period: 24 * time.Hour,
+		},
+	}
+
+	s, err := NewIndexShipper(cfg, period, "test", objectClient, nil)
+	require.NoError(t, err)
+	return s
+}
+
+func testChunk(ls labels.Labels, checksum uint32, from, through model.Time) chunk.Chunk {
+	return chunk.Chunk{
+		ChunkRef: logproto.ChunkRef{
+			Fingerprint: ls.Hash(),
+			UserID:      "fake",
+			From:        from,
+			Through:     through,
+			Checksum:    checksum,
+		},
+		Metric:      ls,
+		ChecksumSet: true,
+	}
+}
+
+func requireChunks(t *testing.T, s *IndexShipper, expected ...chunk.Chunk) {
+	t.Helper()
+
+	chunks, err := s.GetChunkRefs(context.Background(), "fake", 0, model.Time(48*time.Hour/time.Millisecond), nil,
+		labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, "logs"))
+	require.NoError(t, err)
+
+	for i := range expected {
+		expected[i].Metric = nil
+	}
+	require.ElementsMatch(t, expected, chunks)
+}
+
+func TestIndexShipper(t *testing.T) {
+	dir := t.TempDir()
+	ls := mustParseLabels(`{__name__="logs", foo="bar"}`)
+	// the second chunk spans two tables.
+	chk1 := testChunk(ls, 1, 0, 1000)
+	chk2 := testChunk(ls, 2, model.Time(23*time.Hour/time.Millisecond), model.Time(25*time.Hour/time.Millisecond))
+
+	s := newTestIndexShipper(t, dir, shipper.ModeReadWrite)
+	defer s.Stop()
+	require.NoError(t, s.IndexChunk(context.Background(), chk1.From, chk1.Through, chk1))
+	require.NoError(t, s.IndexChunk(context.Background(), chk2.From, chk2.Through, chk2))
+
+	// queried from the heads.
+	requireChunks(t, s, chk1, chk2)
+
+	names, err := s.LabelNames(context.Background(), "fake", 0, chk2.Through, nil, labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, "logs"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"foo"}, names)
+
+	values, err := s.LabelValues(context.Background(), "fake", 0, chk2.Through, "foo", labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, "logs"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"bar"}, values)
+
+	// queried from the built files, which are both kept locally and uploaded.
+	s.buildAndUpload(context.Background())
+	require.True(t, s.heads.get("index_0", "fake") == nil)
+	requireChunks(t, s, chk1, chk2)
+
+	files, err := s.storageClient.ListUserFiles(context.Background(), "index_1", "fake")
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	// a reader only sees the uploaded files.
+	reader := newTestIndexShipper(t, dir, shipper.ModeReadOnly)
+	defer reader.Stop()
+	requireChunks(t, reader, chk1, chk2)
+
+	require.Error(t, reader.IndexChunk(context.Background(), chk1.From, chk1.Through, chk1))
+}
+
+func TestIndexShipper_Compression(t *testing.T) {
+	dir := t.TempDir()
+	ls := mustParseLabels(`{__name__="logs", foo="bar"}`)
+	chk1 := testChunk(ls, 1, 0, 1000)
+	chk2 := testChunk(ls, 2, 2000, 3000)
+
+	// the files are uploaded with the compression of the writer, whatever the compression of the reader.
+	s := newTestIndexShipperWithCompression(t, dir, shipper.ModeReadWrite, shipper_util.CompressionZstd)
+	defer s.Stop()
+	require.NoError(t, s.IndexChunk(context.Background(), chk1.From, chk1.Through, chk1))
+	s.buildAndUpload(context.Background())
+
+	files, err := s.storageClient.ListUserFiles(context.Background(), "index_0", "fake")
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	require.Equal(t, shipper_util.CompressionZstd, shipper_util.FileCompression(files[0].Name))
+
+	// the files uploaded uncompressed before are still downloaded.
+	require.NoError(t, s.IndexChunk(context.Background(), chk2.From, chk2.Through, chk2))
+	s.buildAndUpload(context.Background())
+	for _, idx := range s.local["index_0"]["fake"] {
+		if idx.name == shipper_util.UncompressedFileName(files[0].Name) {
+			continue
+		}
+		f, err := os.Open(idx.path)
+		require.NoError(t, err)
+		require.NoError(t, s.storageClient.PutUserFile(context.Background(), "index_0", "fake", idx.name, f))
+		require.NoError(t, f.Close())
+		require.NoError(t, s.storageClient.DeleteUserFile(context.Background(), "index_0", "fake",
+			shipper_util.CompressedFileName(idx.name, shipper_util.CompressionZstd)))
+	}
+
+	files, err = s.storageClient.ListUserFiles(context.Background(), "index_0", "fake")
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	reader := newTestIndexShipperWithCompression(t, dir, shipper.ModeReadOnly, shipper_util.CompressionGzip)
+	defer reader.Stop()
+	requireChunks(t, reader, chk1, chk2)
+}
+
+func TestIndexShipper_LabelNamesSharded(t *testing.T) {
+	s := newTestIndexShipper(t, t.TempDir(), shipper.ModeReadWrite)
+	defer s.Stop()
+
+	chunks := []chunk.Chunk{
+		testChunk(mustParseLabels(`{__name__="logs", foo="bar"}`), 1, 0, 1000),
+		testChunk(mustParseLabels(`{__name__="logs", foo="baz", bar="1"}`), 2, 0, 1000),
+		testChunk(mustParseLabels(`{__name__="logs", foo="bar", buzz="1"}`), 3, 0, 1000),
+	}
+	for _, chk := range chunks {
+		require.NoError(t, s.IndexChunk(context.Background(), chk.From, chk.Through, chk))
+	}
+
+	for i := 0; i < 2; i++ {
+		shard := &astmapper.ShardAnnotation{Shard: i, Of: 2}
+
+		var expected chunk.UniqueStrings
+		for _, chk := range chunks {
+			if !shard.Match(model.Fingerprint(chk.Fingerprint)) {
+				continue
+			}
+			for _, l := range chk.Metric {
+				if l.Name != labels.MetricName {
+					expected.Add(l.Name)
+				}
+			}
+		}
+
+		names, err := s.LabelNames(context.Background(), "fake", 0, 1000, shard, labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, "logs"))
+		require.NoError(t, err)
+		require.ElementsMatch(t, expected.Strings(), names, "shard %d", i)
+
+		// the matchers apply within the shard.
+		names, err = s.LabelNames(context.Background(), "fake", 0, 1000, shard,
+			labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, "logs"),
+			labels.MustNewMatcher(labels.MatchEqual, "foo", "nope"))
+		require.NoError(t, err)
+		require.Empty(t, names)
+	}
+}
+
+func TestIndexShipper_WALReplay(t *testing.T) {
+	dir := t.TempDir()
+	ls := mustParseLabels(`{__name__="logs", foo="bar"}`)
+	chk := testChunk(ls, 1, 0, 1000)
+
+	s := newTestIndexShipper(t, dir, shipper.ModeWriteOnly)
+	require.NoError(t, s.IndexChunk(context.Background(), chk.From, chk.Through, chk))
+	// simulate a crash: the heads are not built.
+	close(s.quit)
+	s.wg.Wait()
+	require.NoError(t, s.wal.Close())
+	s.storageClient.Stop()
+
+	s = newTestIndexShipper(t, dir, shipper.ModeWriteOnly)
+	requireChunks(t, s, chk)
+
+	// the WAL is truncated once the heads are built.
+	s.Stop()
+	segments, err := listSegments(filepath.Join(dir, "active", "test", walDirName))
+	require.NoError(t, err)
+	require.Len(t, segments, 1)
+
+	s = newTestIndexShipper(t, dir, shipper.ModeWriteOnly)
+	defer s.Stop()
+	require.True(t, s.heads.get("index_0", "fake") == nil)
+	requireChunks(t, s, chk)
+}
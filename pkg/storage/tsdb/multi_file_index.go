@@ -64,6 +64,7 @@ func (i *MultiIndex) forIndices(ctx context.Context, from, through model.Time, f
 	ch := make(chan interface{}, len(i.indices))
 
 	for _, idx := range i.indices {
+		idx := idx
 		// ignore indices which can't match this query
 		if Overlap(queryBounds, idx) {
 			// run all queries in linked goroutines (cancel after first err),
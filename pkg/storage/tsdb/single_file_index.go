@@ -80,6 +80,8 @@ func (i *TSDBIndex) GetChunkRefs(_ context.Context, userID string, from, through
 					Start:       chk.From(),
 					End:         chk.Through(),
 					Checksum:    chk.Checksum,
+					KB:          chk.KB,
+					Entries:     chk.Entries,
 				})
 			}
 		},
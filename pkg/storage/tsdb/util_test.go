@@ -4,6 +4,7 @@ import (
 	"context"
 	"testing"
 
+	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/stretchr/testify/require"
 
@@ -20,7 +21,7 @@ func BuildIndex(t *testing.T, cases []LoadableSeries) *TSDBIndex {
 	b := index.NewBuilder()
 
 	for _, s := range cases {
-		b.AddSeries(s.Labels, s.Chunks)
+		b.AddSeries(s.Labels, model.Fingerprint(s.Labels.Hash()), s.Chunks)
 	}
 
 	require.Nil(t, b.Build(context.Background(), dir))
@@ -0,0 +1,169 @@
+package tsdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+
+	chunk_util "github.com/grafana/loki/pkg/storage/chunk/util"
+	"github.com/grafana/loki/pkg/storage/tsdb/index"
+)
+
+// walRecord is the WAL entry of a chunk added to the head of a table for a tenant.
+type walRecord struct {
+	Table       string            `json:"table"`
+	User        string            `json:"user"`
+	Labels      labels.Labels     `json:"labels"`
+	Fingerprint model.Fingerprint `json:"fingerprint"`
+	Chunk       index.ChunkMeta   `json:"chunk"`
+}
+
+// headWAL logs the chunks added to the heads so they can be rebuilt after a restart.
+// It is split in segments: a new one is cut whenever the heads are rotated, and the segments
+// of the heads which were built are then removed.
+type headWAL struct {
+	dir string
+
+	mtx     sync.Mutex
+	segment int
+	f       *os.File
+	enc     *json.Encoder
+}
+
+// openHeadWAL replays the existing segments of the WAL in dir, then starts a new segment.
+func openHeadWAL(dir string, logger log.Logger, replay func(walRecord)) (*headWAL, error) {
+	if err := chunk_util.EnsureDirectory(dir); err != nil {
+		return nil, err
+	}
+
+	segments, err := listSegments(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	last := -1
+	for _, segment := range segments {
+		if err := replaySegment(filepath.Join(dir, segmentName(segment)), replay); err != nil {
+			// the last record of a segment could have been partially written before a crash.
+			level.Warn(logger).Log("msg", "failed to replay the whole tsdb head WAL segment", "segment", segment, "err", err)
+		}
+		last = segment
+	}
+
+	w := &headWAL{dir: dir, segment: last}
+	if err := w.openSegment(last + 1); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func listSegments(dir string) ([]int, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	segments := make([]int, 0, len(files))
+	for _, f := range files {
+		segment, err := strconv.Atoi(f.Name())
+		if err != nil {
+			continue
+		}
+		segments = append(segments, segment)
+	}
+	sort.Ints(segments)
+	return segments, nil
+}
+
+func segmentName(segment int) string {
+	return fmt.Sprintf("%08d", segment)
+}
+
+func replaySegment(path string, replay func(walRecord)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	for {
+		var rec walRecord
+		if err := dec.Decode(&rec); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		replay(rec)
+	}
+}
+
+func (w *headWAL) openSegment(segment int) error {
+	f, err := os.OpenFile(filepath.Join(w.dir, segmentName(segment)), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	w.segment, w.f, w.enc = segment, f, json.NewEncoder(f)
+	return nil
+}
+
+// Log appends the records to the current segment.
+func (w *headWAL) Log(recs ...walRecord) error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	for _, rec := range recs {
+		if err := w.enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Cut closes the current segment and starts a new one. It returns the closed segment.
+func (w *headWAL) Cut() (int, error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	closed := w.segment
+	if err := w.f.Close(); err != nil {
+		return 0, err
+	}
+	return closed, w.openSegment(closed + 1)
+}
+
+// Truncate removes the segments up to and including the given one.
+func (w *headWAL) Truncate(segment int) error {
+	segments, err := listSegments(w.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range segments {
+		if s > segment {
+			break
+		}
+		if err := os.Remove(filepath.Join(w.dir, segmentName(s))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *headWAL) Close() error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	return w.f.Close()
+}
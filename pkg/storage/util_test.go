@@ -173,7 +173,7 @@ func (m *mockChunkStore) LabelValuesForMetricName(ctx context.Context, userID st
 	return nil, nil
 }
 
-func (m *mockChunkStore) LabelNamesForMetricName(ctx context.Context, userID string, from, through model.Time, metricName string) ([]string, error) {
+func (m *mockChunkStore) LabelNamesForMetricName(ctx context.Context, userID string, from, through model.Time, metricName string, matchers ...*labels.Matcher) ([]string, error) {
 	return nil, nil
 }
 
@@ -189,7 +189,7 @@ func (m *mockChunkStore) Get(ctx context.Context, userID string, from, through m
 	return nil, nil
 }
 
-func (m *mockChunkStore) GetChunkFetcher(_ model.Time) *chunk.Fetcher {
+func (m *mockChunkStore) GetChunkFetcher(_ string, _ model.Time) *chunk.Fetcher {
 	return nil
 }
 
@@ -0,0 +1,34 @@
+package verify
+
+import (
+	"flag"
+	"fmt"
+	"time"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Config configures the verification of the storage of the schema config.
+type Config struct {
+	Timeout    time.Duration `yaml:"timeout"`
+	WriteProbe bool          `yaml:"write_probe"`
+	Format     string        `yaml:"format"`
+}
+
+// RegisterFlags adds the flags required to config this to the given FlagSet.
+func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
+	f.DurationVar(&cfg.Timeout, "config-verify.timeout", time.Minute, "Timeout of the verification of the stores of a period config.")
+	f.BoolVar(&cfg.WriteProbe, "config-verify.write-probe", true, "Check write permissions by writing, reading and deleting a small object in the object stores.")
+	f.StringVar(&cfg.Format, "config-verify.format", FormatText, "Format of the report printed to stdout: text or json.")
+}
+
+// Validate validates the config.
+func (cfg *Config) Validate() error {
+	if cfg.Format != FormatText && cfg.Format != FormatJSON {
+		return fmt.Errorf("unsupported config-verify format %q, choose one of text, json", cfg.Format)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package verify
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// Status is the outcome of a check.
+type Status string
+
+const (
+	StatusOK      Status = "ok"
+	StatusWarning Status = "warning"
+	StatusFailed  Status = "failed"
+	StatusSkipped Status = "skipped"
+)
+
+// Check is the outcome of verifying one aspect of the stores of a period config.
+type Check struct {
+	Name    string `json:"name"`
+	Status  Status `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// PeriodReport holds the checks of a period config.
+type PeriodReport struct {
+	Tenant     string  `json:"tenant,omitempty"`
+	From       string  `json:"from"`
+	IndexType  string  `json:"index_type"`
+	ObjectType string  `json:"object_type"`
+	Checks     []Check `json:"checks"`
+}
+
+func (r *PeriodReport) add(name string, status Status, format string, args ...interface{}) {
+	r.Checks = append(r.Checks, Check{Name: name, Status: status, Message: fmt.Sprintf(format, args...)})
+}
+
+// Report holds the checks of all period configs.
+type Report struct {
+	Periods []PeriodReport `json:"periods"`
+}
+
+// Failed tells if any check failed.
+func (r Report) Failed() bool {
+	for _, p := range r.Periods {
+		for _, c := range p.Checks {
+			if c.Status == StatusFailed {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Write writes the report in the given format.
+func (r Report) Write(w io.Writer, format string) error {
+	if format == FormatJSON {
+		enc := jsoniter.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PERIOD\tTENANT\tINDEX\tOBJECT\tCHECK\tSTATUS\tMESSAGE")
+	for _, p := range r.Periods {
+		tenant := p.Tenant
+		if tenant == "" {
+			tenant = "-"
+		}
+		for _, c := range p.Checks {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", p.From, tenant, p.IndexType, p.ObjectType, c.Name, c.Status, c.Message)
+		}
+	}
+	return tw.Flush()
+}
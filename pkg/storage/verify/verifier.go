@@ -0,0 +1,214 @@
+package verify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/common/model"
+
+	"github.com/grafana/loki/pkg/storage/chunk"
+	"github.com/grafana/loki/pkg/storage/chunk/storage"
+	"github.com/grafana/loki/pkg/storage/stores/shipper"
+)
+
+// probePrefix is the prefix of the objects written to check write permissions.
+const probePrefix = "loki-config-verify/"
+
+// TableClientFactory creates the table client of an index or chunk store type.
+type TableClientFactory func(name string) (chunk.TableClient, error)
+
+// ObjectClientFactory creates the object client of an object store type.
+type ObjectClientFactory func(name string) (chunk.ObjectClient, error)
+
+// Verifier checks that the stores of the period configs of a schema config
+// exist and can be accessed.
+type Verifier struct {
+	cfg             Config
+	schemaCfg       chunk.SchemaConfig
+	newTableClient  TableClientFactory
+	newObjectClient ObjectClientFactory
+	logger          log.Logger
+}
+
+// New creates a verifier of the given schema config.
+func New(cfg Config, schemaCfg chunk.SchemaConfig, newTableClient TableClientFactory, newObjectClient ObjectClientFactory, logger log.Logger) *Verifier {
+	return &Verifier{
+		cfg:             cfg,
+		schemaCfg:       schemaCfg,
+		newTableClient:  newTableClient,
+		newObjectClient: newObjectClient,
+		logger:          logger,
+	}
+}
+
+// Run verifies all the period configs, including the ones of tenants.
+func (v *Verifier) Run(ctx context.Context) Report {
+	now := model.Now()
+
+	var report Report
+	report.Periods = append(report.Periods, v.verifySchema(ctx, "", v.schemaCfg.Configs, now)...)
+
+	tenants := make([]string, 0, len(v.schemaCfg.TenantConfigs))
+	for tenant := range v.schemaCfg.TenantConfigs {
+		tenants = append(tenants, tenant)
+	}
+	sort.Strings(tenants)
+	for _, tenant := range tenants {
+		if cfg := v.schemaCfg.TenantConfigs[tenant]; cfg != nil {
+			report.Periods = append(report.Periods, v.verifySchema(ctx, tenant, cfg.Configs, now)...)
+		}
+	}
+	return report
+}
+
+func (v *Verifier) verifySchema(ctx context.Context, tenant string, periods []chunk.PeriodConfig, now model.Time) []PeriodReport {
+	reports := make([]PeriodReport, 0, len(periods))
+	for i, p := range periods {
+		// The end of the period is the start of the next one, if any.
+		end := model.Latest
+		if i+1 < len(periods) {
+			end = periods[i+1].From.Time
+		}
+
+		level.Info(v.logger).Log("msg", "verifying period config", "from", p.From.String(), "tenant", tenant)
+		ctx, cancel := context.WithTimeout(ctx, v.cfg.Timeout)
+		reports = append(reports, v.verifyPeriod(ctx, tenant, p, end, now))
+		cancel()
+	}
+	return reports
+}
+
+func (v *Verifier) verifyPeriod(ctx context.Context, tenant string, p chunk.PeriodConfig, end, now model.Time) PeriodReport {
+	objectType := p.ObjectType
+	if objectType == "" {
+		objectType = p.IndexType
+	}
+	r := PeriodReport{Tenant: tenant, From: p.From.String(), IndexType: p.IndexType, ObjectType: objectType}
+
+	v.verifyTables(ctx, &r, "index", p.IndexType, p.IndexTables, p, end, now)
+
+	switch {
+	case isObjectStore(objectType):
+		v.verifyObjectStore(ctx, &r, objectType)
+	case p.ChunkTables.Prefix != "":
+		v.verifyTables(ctx, &r, "chunk", objectType, p.ChunkTables, p, end, now)
+	default:
+		r.add("chunk store", StatusSkipped, "chunks are stored in the index tables")
+	}
+	return r
+}
+
+// verifyTables checks that the tables of the store can be listed and that the
+// table of the period being written, or the last one, exists.
+func (v *Verifier) verifyTables(ctx context.Context, r *PeriodReport, kind, storeType string, tables chunk.PeriodicTableConfig, p chunk.PeriodConfig, end, now model.Time) {
+	client, err := v.newTableClient(storeType)
+	if err != nil {
+		r.add(kind+" store client", StatusFailed, "%v", err)
+		return
+	}
+	defer client.Stop()
+
+	existing, err := client.ListTables(ctx)
+	if err != nil {
+		r.add(kind+" store access", StatusFailed, "failed to list tables: %v", err)
+		return
+	}
+	r.add(kind+" store access", StatusOK, "%d tables listed", len(existing))
+
+	check := kind + " table exists"
+	if p.From.Time > now {
+		table := tables.TableFor(p.From.Time)
+		if !contains(existing, table) {
+			r.add(check, StatusSkipped, "period not started yet, table %s doesn't exist yet", table)
+			return
+		}
+		r.add(check, StatusOK, "table %s exists", table)
+		return
+	}
+
+	t := now
+	if end <= now {
+		t = end - 1
+	}
+	table := tables.TableFor(t)
+	switch {
+	case contains(existing, table):
+		r.add(check, StatusOK, "table %s exists", table)
+	case storeType == shipper.BoltDBShipperType || storeType == storage.StorageTypeBoltDB:
+		// These tables are created by writing to them, not by the table manager.
+		r.add(check, StatusWarning, "table %s doesn't exist, no logs have been written for its time range", table)
+	default:
+		r.add(check, StatusFailed, "table %s doesn't exist, it must be created by the table manager", table)
+	}
+}
+
+// verifyObjectStore checks that objects can be listed and, if enabled,
+// written, read and deleted.
+func (v *Verifier) verifyObjectStore(ctx context.Context, r *PeriodReport, storeType string) {
+	client, err := v.newObjectClient(storeType)
+	if err != nil {
+		r.add("chunk store client", StatusFailed, "%v", err)
+		return
+	}
+	defer client.Stop()
+
+	if _, _, err := client.List(ctx, "", "/"); err != nil {
+		r.add("chunk store access", StatusFailed, "failed to list objects: %v", err)
+		return
+	}
+	r.add("chunk store access", StatusOK, "objects listed")
+
+	if !v.cfg.WriteProbe {
+		r.add("chunk store write", StatusSkipped, "write probe disabled")
+		return
+	}
+
+	key := fmt.Sprintf("%s%d", probePrefix, time.Now().UnixNano())
+	content := []byte("loki config-verify probe")
+	if err := client.PutObject(ctx, key, bytes.NewReader(content)); err != nil {
+		r.add("chunk store write", StatusFailed, "failed to write object %s: %v", key, err)
+		return
+	}
+	defer func() {
+		if err := client.DeleteObject(ctx, key); err != nil {
+			r.add("chunk store delete", StatusFailed, "failed to delete object %s: %v", key, err)
+		}
+	}()
+
+	rc, _, err := client.GetObject(ctx, key)
+	if err != nil {
+		r.add("chunk store write", StatusFailed, "failed to read back object %s: %v", key, err)
+		return
+	}
+	defer rc.Close()
+
+	read, err := ioutil.ReadAll(rc)
+	if err != nil || !bytes.Equal(read, content) {
+		r.add("chunk store write", StatusFailed, "object %s read back doesn't match what was written: %v", key, err)
+		return
+	}
+	r.add("chunk store write", StatusOK, "object written and read back")
+}
+
+func isObjectStore(storeType string) bool {
+	switch storeType {
+	case storage.StorageTypeAWS, storage.StorageTypeS3, storage.StorageTypeGCS, storage.StorageTypeAzure, storage.StorageTypeSwift, storage.StorageTypeAlibabaCloud, storage.StorageTypeTencentCloud, storage.StorageTypeBOS, storage.StorageTypeFileSystem:
+		return true
+	}
+	return false
+}
+
+func contains(tables []string, table string) bool {
+	for _, t := range tables {
+		if t == table {
+			return true
+		}
+	}
+	return false
+}
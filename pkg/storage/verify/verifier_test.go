@@ -0,0 +1,113 @@
+package verify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/pkg/storage/chunk"
+)
+
+func TestVerifier(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		today = model.TimeFromUnix(time.Now().Truncate(24 * time.Hour).Unix())
+		day   = 24 * time.Hour
+
+		dynamo = chunk.NewMockStorage()
+		s3     = chunk.NewMockStorage()
+		shared = chunk.NewMockStorage()
+
+		periods = []chunk.PeriodConfig{
+			{
+				From:        chunk.DayTime{Time: today.Add(-10 * day)},
+				IndexType:   "aws-dynamo",
+				ObjectType:  "s3",
+				IndexTables: chunk.PeriodicTableConfig{Prefix: "index_", Period: 24 * time.Hour},
+			},
+			{
+				From:        chunk.DayTime{Time: today.Add(-day)},
+				IndexType:   "boltdb-shipper",
+				ObjectType:  "filesystem",
+				IndexTables: chunk.PeriodicTableConfig{Prefix: "index_", Period: 24 * time.Hour},
+			},
+			{
+				From:        chunk.DayTime{Time: today.Add(10 * day)},
+				IndexType:   "aws-dynamo",
+				IndexTables: chunk.PeriodicTableConfig{Prefix: "index_", Period: 24 * time.Hour},
+			},
+		}
+		tableClients  = map[string]chunk.TableClient{"aws-dynamo": dynamo, "boltdb-shipper": shared}
+		objectClients = map[string]chunk.ObjectClient{"s3": s3}
+	)
+
+	// The last table of the first period exists.
+	lastTable := fmt.Sprintf("index_%d", today.Add(-day-time.Millisecond).Unix()/86400)
+	require.NoError(t, dynamo.CreateTable(ctx, chunk.TableDesc{Name: lastTable}))
+	// The chunk store of the first period is read-only.
+	s3.SetMode(chunk.MockStorageModeReadOnly)
+
+	v := New(Config{Timeout: time.Minute, WriteProbe: true, Format: FormatText}, chunk.SchemaConfig{
+		Configs:       periods,
+		TenantConfigs: map[string]*chunk.SchemaConfig{"tenant": {Configs: periods[:2]}},
+	}, func(name string) (chunk.TableClient, error) {
+		if c, ok := tableClients[name]; ok {
+			return c, nil
+		}
+		return nil, fmt.Errorf("unknown table client %s", name)
+	}, func(name string) (chunk.ObjectClient, error) {
+		if c, ok := objectClients[name]; ok {
+			return c, nil
+		}
+		return nil, fmt.Errorf("unknown object client %s", name)
+	}, log.NewNopLogger())
+
+	report := v.Run(ctx)
+	require.True(t, report.Failed())
+	require.Len(t, report.Periods, 5)
+
+	statuses := func(p PeriodReport) map[string]Status {
+		m := map[string]Status{}
+		for _, c := range p.Checks {
+			m[c.Name] = c.Status
+		}
+		return m
+	}
+	require.Equal(t, map[string]Status{
+		"index store access": StatusOK,
+		"index table exists": StatusOK,
+		"chunk store access": StatusOK,
+		"chunk store write":  StatusFailed,
+	}, statuses(report.Periods[0]))
+	require.Equal(t, map[string]Status{
+		"index store access": StatusOK,
+		"index table exists": StatusWarning,
+		"chunk store client": StatusFailed,
+	}, statuses(report.Periods[1]))
+	require.Equal(t, map[string]Status{
+		"index store access": StatusOK,
+		"index table exists": StatusSkipped,
+		"chunk store":        StatusSkipped,
+	}, statuses(report.Periods[2]))
+	require.Equal(t, "tenant", report.Periods[3].Tenant)
+	require.Equal(t, statuses(report.Periods[0]), statuses(report.Periods[3]))
+
+	// The write probe can be disabled.
+	s3.SetMode(chunk.MockStorageModeReadWrite)
+	v.cfg.WriteProbe = false
+	report = v.Run(ctx)
+	require.Equal(t, StatusSkipped, statuses(report.Periods[0])["chunk store write"])
+
+	var buf bytes.Buffer
+	require.NoError(t, report.Write(&buf, FormatText))
+	require.Contains(t, buf.String(), "index table exists  warning")
+	buf.Reset()
+	require.NoError(t, report.Write(&buf, FormatJSON))
+	require.Contains(t, buf.String(), `"status": "warning"`)
+}
@@ -0,0 +1,221 @@
+package tenantmigration
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/pkg/errors"
+	"github.com/weaveworks/common/httpgrpc"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/loki/pkg/iter"
+	"github.com/grafana/loki/pkg/logproto"
+)
+
+const (
+	// PushPath receives the pushes duplicated by the other cluster.
+	PushPath = "/tenant-migration/push"
+	// SelectLogsPath streams the logs selected for the other cluster.
+	SelectLogsPath = "/tenant-migration/select_logs"
+	// SelectSamplesPath streams the samples selected for the other cluster.
+	SelectSamplesPath = "/tenant-migration/select_samples"
+
+	protobufContentType = "application/x-protobuf"
+	maxErrorBodySize    = 1024
+)
+
+// The responses of the selections are streamed as frames, each one made of its type,
+// the uvarint size of its payload and its payload.
+const (
+	frameBatch byte = 'b'
+	frameError byte = 'e'
+	frameDone  byte = 'd'
+)
+
+// client pushes to and selects from the remote cluster.
+type client struct {
+	url  *url.URL
+	http *http.Client
+}
+
+func newClient(cfg Config) *client {
+	return &client{
+		url:  cfg.RemoteURL.URL,
+		http: &http.Client{},
+	}
+}
+
+func (c *client) endpoint(p string) string {
+	u := *c.url
+	u.Path = path.Join(u.Path, p)
+	return u.String()
+}
+
+func (c *client) post(ctx context.Context, tenant, p string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint(p), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", protobufContentType)
+	if err := user.InjectOrgIDIntoHTTPRequest(user.InjectOrgID(ctx, tenant), req); err != nil {
+		return nil, err
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		return nil, httpgrpc.Errorf(resp.StatusCode, "remote cluster: %s", bytes.TrimSpace(msg))
+	}
+	return resp, nil
+}
+
+// push pushes the streams of the tenant to the remote cluster.
+func (c *client) push(ctx context.Context, tenant string, streams []logproto.Stream) error {
+	buf, err := proto.Marshal(&logproto.PushRequest{Streams: streams})
+	if err != nil {
+		return err
+	}
+	resp, err := c.post(ctx, tenant, PushPath, snappy.Encode(nil, buf))
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	return resp.Body.Close()
+}
+
+// selectLogs selects the logs of the tenant in the remote cluster.
+func (c *client) selectLogs(ctx context.Context, tenant string, req *logproto.QueryRequest) (iter.EntryIterator, error) {
+	buf, err := proto.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.post(ctx, tenant, SelectLogsPath, buf)
+	if err != nil {
+		return nil, err
+	}
+	return iter.NewQueryClientIterator(&logsStream{newFrameReader(ctx, resp.Body)}, req.Direction), nil
+}
+
+// selectSamples selects the samples of the tenant in the remote cluster.
+func (c *client) selectSamples(ctx context.Context, tenant string, req *logproto.SampleQueryRequest) (iter.SampleIterator, error) {
+	buf, err := proto.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.post(ctx, tenant, SelectSamplesPath, buf)
+	if err != nil {
+		return nil, err
+	}
+	return iter.NewSampleQueryClientIterator(&samplesStream{newFrameReader(ctx, resp.Body)}), nil
+}
+
+// frameReader reads the batches of a streamed selection.
+type frameReader struct {
+	ctx  context.Context
+	body io.ReadCloser
+	r    *bufio.Reader
+}
+
+func newFrameReader(ctx context.Context, body io.ReadCloser) *frameReader {
+	return &frameReader{ctx: ctx, body: body, r: bufio.NewReader(body)}
+}
+
+// recv reads the next batch, returning io.EOF once the selection is done.
+func (f *frameReader) recv(batch proto.Message) error {
+	typ, err := f.r.ReadByte()
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	} else if err != nil {
+		return err
+	}
+	if typ == frameDone {
+		return io.EOF
+	}
+
+	size, err := binary.ReadUvarint(f.r)
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(f.r, payload); err != nil {
+		return unexpectedEOF(err)
+	}
+
+	switch typ {
+	case frameBatch:
+		return proto.Unmarshal(payload, batch)
+	case frameError:
+		return errors.Errorf("remote cluster: %s", payload)
+	default:
+		return fmt.Errorf("unexpected frame type %q", typ)
+	}
+}
+
+func (f *frameReader) Context() context.Context { return f.ctx }
+func (f *frameReader) CloseSend() error         { return f.body.Close() }
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+type logsStream struct {
+	*frameReader
+}
+
+func (s *logsStream) Recv() (*logproto.QueryResponse, error) {
+	var batch logproto.QueryResponse
+	if err := s.recv(&batch); err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+type samplesStream struct {
+	*frameReader
+}
+
+func (s *samplesStream) Recv() (*logproto.SampleQueryResponse, error) {
+	var batch logproto.SampleQueryResponse
+	if err := s.recv(&batch); err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// frameWriter writes the batches of a streamed selection.
+type frameWriter struct {
+	w   io.Writer
+	buf [binary.MaxVarintLen64]byte
+}
+
+func (f *frameWriter) write(typ byte, payload []byte) error {
+	if _, err := f.w.Write([]byte{typ}); err != nil {
+		return err
+	}
+	if typ == frameDone {
+		return nil
+	}
+	n := binary.PutUvarint(f.buf[:], uint64(len(payload)))
+	if _, err := f.w.Write(f.buf[:n]); err != nil {
+		return err
+	}
+	_, err := f.w.Write(payload)
+	return err
+}
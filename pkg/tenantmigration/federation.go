@@ -0,0 +1,252 @@
+package tenantmigration
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/go-kit/log/level"
+	"github.com/gogo/protobuf/proto"
+
+	"github.com/grafana/loki/pkg/iter"
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/loki/pkg/logql"
+	"github.com/grafana/loki/pkg/querier"
+	"github.com/grafana/loki/pkg/tenant"
+	serverutil "github.com/grafana/loki/pkg/util/server"
+)
+
+const (
+	selectBatchSize       = 128
+	selectSampleBatchSize = 512
+)
+
+// FederatedQuerier merges the logs and samples selected in the other cluster for the migrating tenants.
+type FederatedQuerier struct {
+	querier.Querier
+	m *Migrator
+}
+
+// NewFederatedQuerier returns a querier merging the other cluster in the queries of the migrating tenants.
+// The querier must be a single tenant querier.
+func NewFederatedQuerier(q querier.Querier, m *Migrator) *FederatedQuerier {
+	return &FederatedQuerier{Querier: q, m: m}
+}
+
+func (q *FederatedQuerier) SelectLogs(ctx context.Context, params logql.SelectLogParams) (iter.EntryIterator, error) {
+	tenantID, err := tenant.TenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	phase, ok := q.m.Phase(tenantID)
+	if !ok {
+		return q.Querier.SelectLogs(ctx, params)
+	}
+
+	// the local querier modifies the request, it is copied first for the other cluster.
+	remoteReq := *params.QueryRequest
+	remoteReq.Deletes = nil
+	local, err := q.Querier.SelectLogs(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	q.m.metrics.federatedQueries.Inc()
+	remote, err := q.m.client.selectLogs(ctx, tenantID, &remoteReq)
+	if err != nil {
+		if err := q.federationFailed(phase, tenantID, err); err != nil {
+			_ = local.Close()
+			return nil, err
+		}
+		return local, nil
+	}
+	if phase != PhaseCutover {
+		remote = &tolerantEntryIterator{EntryIterator: remote, failed: func(err error) { _ = q.federationFailed(phase, tenantID, err) }}
+	}
+	// the entries written in both clusters are deduplicated.
+	return iter.NewMergeEntryIterator(ctx, []iter.EntryIterator{local, remote}, params.Direction), nil
+}
+
+func (q *FederatedQuerier) SelectSamples(ctx context.Context, params logql.SelectSampleParams) (iter.SampleIterator, error) {
+	tenantID, err := tenant.TenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	phase, ok := q.m.Phase(tenantID)
+	if !ok {
+		return q.Querier.SelectSamples(ctx, params)
+	}
+
+	remoteReq := *params.SampleQueryRequest
+	remoteReq.Deletes = nil
+	local, err := q.Querier.SelectSamples(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	q.m.metrics.federatedQueries.Inc()
+	remote, err := q.m.client.selectSamples(ctx, tenantID, &remoteReq)
+	if err != nil {
+		if err := q.federationFailed(phase, tenantID, err); err != nil {
+			_ = local.Close()
+			return nil, err
+		}
+		return local, nil
+	}
+	if phase != PhaseCutover {
+		remote = &tolerantSampleIterator{SampleIterator: remote, failed: func(err error) { _ = q.federationFailed(phase, tenantID, err) }}
+	}
+	return iter.NewMergeSampleIterator(ctx, []iter.SampleIterator{local, remote}), nil
+}
+
+// federationFailed returns the error when the other cluster is authoritative, and only logs it otherwise.
+func (q *FederatedQuerier) federationFailed(phase Phase, tenantID string, err error) error {
+	q.m.metrics.federationFailures.Inc()
+	if phase == PhaseCutover {
+		return err
+	}
+	level.Warn(q.m.logger).Log("msg", "failed to select from the other cluster", "tenant", tenantID, "err", err)
+	return nil
+}
+
+// tolerantEntryIterator ends without error when the iterator of a non authoritative cluster fails.
+type tolerantEntryIterator struct {
+	iter.EntryIterator
+	failed func(error)
+}
+
+func (it *tolerantEntryIterator) Next() bool {
+	if it.EntryIterator.Next() {
+		return true
+	}
+	if err := it.EntryIterator.Error(); err != nil {
+		it.failed(err)
+	}
+	return false
+}
+
+func (it *tolerantEntryIterator) Error() error { return nil }
+
+// tolerantSampleIterator ends without error when the iterator of a non authoritative cluster fails.
+type tolerantSampleIterator struct {
+	iter.SampleIterator
+	failed func(error)
+}
+
+func (it *tolerantSampleIterator) Next() bool {
+	if it.SampleIterator.Next() {
+		return true
+	}
+	if err := it.SampleIterator.Error(); err != nil {
+		it.failed(err)
+	}
+	return false
+}
+
+func (it *tolerantSampleIterator) Error() error { return nil }
+
+// SelectLogsHandler streams the logs selected by the querier for the query request of the other cluster.
+// The querier must not be federated, so that the clusters never query each other back.
+func SelectLogsHandler(q logql.Querier) http.Handler {
+	return selectHandler(func(ctx context.Context, body []byte, frames *frameWriter, flush func()) error {
+		var req logproto.QueryRequest
+		if err := proto.Unmarshal(body, &req); err != nil {
+			return err
+		}
+		it, err := q.SelectLogs(ctx, logql.SelectLogParams{QueryRequest: &req})
+		if err != nil {
+			return err
+		}
+		defer it.Close()
+
+		return writeBatches(frames, flush, func() (proto.Message, bool, error) {
+			size := uint32(selectBatchSize)
+			if req.Limit > 0 && req.Limit < size {
+				size = req.Limit
+			}
+			batch, n, err := iter.ReadBatch(it, size)
+			if err != nil || n == 0 {
+				return nil, false, err
+			}
+			if req.Limit > 0 {
+				req.Limit -= n
+				if req.Limit == 0 {
+					// the batch is the last one.
+					return batch, false, nil
+				}
+			}
+			return batch, true, nil
+		})
+	})
+}
+
+// SelectSamplesHandler streams the samples selected by the querier for the query request of the other cluster.
+// The querier must not be federated, so that the clusters never query each other back.
+func SelectSamplesHandler(q logql.Querier) http.Handler {
+	return selectHandler(func(ctx context.Context, body []byte, frames *frameWriter, flush func()) error {
+		var req logproto.SampleQueryRequest
+		if err := proto.Unmarshal(body, &req); err != nil {
+			return err
+		}
+		it, err := q.SelectSamples(ctx, logql.SelectSampleParams{SampleQueryRequest: &req})
+		if err != nil {
+			return err
+		}
+		defer it.Close()
+
+		return writeBatches(frames, flush, func() (proto.Message, bool, error) {
+			batch, n, err := iter.ReadSampleBatch(it, selectSampleBatchSize)
+			if err != nil || n == 0 {
+				return nil, false, err
+			}
+			return batch, true, nil
+		})
+	})
+}
+
+func selectHandler(selectFn func(ctx context.Context, body []byte, frames *frameWriter, flush func()) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			serverutil.JSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", protobufContentType)
+		frames := &frameWriter{w: w}
+		flush := func() {
+			if f, ok := w.(http.Flusher); ok {
+				f.Flush()
+			}
+		}
+		// the status is already sent, the errors are sent in the stream.
+		if err := selectFn(r.Context(), body, frames, flush); err != nil {
+			_ = frames.write(frameError, []byte(err.Error()))
+			return
+		}
+		_ = frames.write(frameDone, nil)
+	})
+}
+
+// writeBatches writes the batches read until there are no more.
+func writeBatches(frames *frameWriter, flush func(), read func() (proto.Message, bool, error)) error {
+	for {
+		batch, more, err := read()
+		if err != nil {
+			return err
+		}
+		if batch != nil {
+			buf, err := proto.Marshal(batch)
+			if err != nil {
+				return err
+			}
+			if err := frames.write(frameBatch, buf); err != nil {
+				return err
+			}
+			flush()
+		}
+		if !more {
+			return nil
+		}
+	}
+}
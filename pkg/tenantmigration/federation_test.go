@@ -0,0 +1,136 @@
+package tenantmigration
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/loki/pkg/iter"
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/loki/pkg/logql"
+	"github.com/grafana/loki/pkg/querier"
+)
+
+type fakeQuerier struct {
+	querier.Querier
+	streams []logproto.Stream
+	series  []logproto.Series
+	err     error
+}
+
+func (q *fakeQuerier) SelectLogs(_ context.Context, params logql.SelectLogParams) (iter.EntryIterator, error) {
+	if q.err != nil {
+		return nil, q.err
+	}
+	return iter.NewStreamsIterator(q.streams, params.Direction), nil
+}
+
+func (q *fakeQuerier) SelectSamples(_ context.Context, _ logql.SelectSampleParams) (iter.SampleIterator, error) {
+	if q.err != nil {
+		return nil, q.err
+	}
+	return iter.NewMultiSeriesIterator(q.series), nil
+}
+
+func newFederatedTest(t *testing.T, local, remote *fakeQuerier) (*Migrator, *FederatedQuerier) {
+	router := mux.NewRouter()
+	router.Path(SelectLogsPath).Handler(SelectLogsHandler(remote))
+	router.Path(SelectSamplesPath).Handler(SelectSamplesHandler(remote))
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	m := newTestMigrator(t, server.URL)
+	return m, NewFederatedQuerier(local, m)
+}
+
+func entries(it iter.EntryIterator) ([]string, error) {
+	var lines []string
+	for it.Next() {
+		lines = append(lines, it.Entry().Line)
+	}
+	return lines, it.Error()
+}
+
+func TestFederatedQuerier_SelectLogs(t *testing.T) {
+	local := &fakeQuerier{streams: []logproto.Stream{{Labels: `{app="foo"}`, Entries: []logproto.Entry{
+		{Timestamp: time.Unix(1, 0), Line: "1"},
+		{Timestamp: time.Unix(3, 0), Line: "3"},
+	}}}}
+	remote := &fakeQuerier{streams: []logproto.Stream{{Labels: `{app="foo"}`, Entries: []logproto.Entry{
+		{Timestamp: time.Unix(2, 0), Line: "2"},
+		{Timestamp: time.Unix(3, 0), Line: "3"},
+	}}}}
+	m, q := newFederatedTest(t, local, remote)
+	ctx := user.InjectOrgID(context.Background(), "a")
+	params := func() logql.SelectLogParams {
+		return logql.SelectLogParams{QueryRequest: &logproto.QueryRequest{
+			Selector:  `{app="foo"}`,
+			Start:     time.Unix(0, 0),
+			End:       time.Unix(10, 0),
+			Limit:     100,
+			Direction: logproto.FORWARD,
+		}}
+	}
+
+	// the tenants not migrating only query the local cluster.
+	it, err := q.SelectLogs(ctx, params())
+	require.NoError(t, err)
+	lines, err := entries(it)
+	require.NoError(t, err)
+	require.Equal(t, []string{"1", "3"}, lines)
+
+	// the entries written in both clusters are deduplicated.
+	require.NoError(t, m.setPhase(ctx, "a", PhaseDualWrite))
+	it, err = q.SelectLogs(ctx, params())
+	require.NoError(t, err)
+	lines, err = entries(it)
+	require.NoError(t, err)
+	require.Equal(t, []string{"1", "2", "3"}, lines)
+
+	// the failures of the other cluster are tolerated until it is authoritative.
+	remote.err = errors.New("remote failure")
+	it, err = q.SelectLogs(ctx, params())
+	require.NoError(t, err)
+	lines, err = entries(it)
+	require.NoError(t, err)
+	require.Equal(t, []string{"1", "3"}, lines)
+
+	require.NoError(t, m.setPhase(ctx, "a", PhaseCutover))
+	it, err = q.SelectLogs(ctx, params())
+	require.NoError(t, err)
+	_, err = entries(it)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "remote failure")
+}
+
+func TestFederatedQuerier_SelectSamples(t *testing.T) {
+	local := &fakeQuerier{series: []logproto.Series{{Labels: `{app="foo"}`, Samples: []logproto.Sample{
+		{Timestamp: time.Unix(1, 0).UnixNano(), Hash: 1, Value: 1},
+	}}}}
+	remote := &fakeQuerier{series: []logproto.Series{{Labels: `{app="foo"}`, Samples: []logproto.Sample{
+		{Timestamp: time.Unix(1, 0).UnixNano(), Hash: 1, Value: 1},
+		{Timestamp: time.Unix(2, 0).UnixNano(), Hash: 2, Value: 1},
+	}}}}
+	m, q := newFederatedTest(t, local, remote)
+	ctx := user.InjectOrgID(context.Background(), "a")
+	require.NoError(t, m.setPhase(ctx, "a", PhaseDualWrite))
+
+	it, err := q.SelectSamples(ctx, logql.SelectSampleParams{SampleQueryRequest: &logproto.SampleQueryRequest{
+		Selector: `count_over_time({app="foo"}[1m])`,
+		Start:    time.Unix(0, 0),
+		End:      time.Unix(10, 0),
+	}})
+	require.NoError(t, err)
+	var timestamps []int64
+	for it.Next() {
+		timestamps = append(timestamps, it.Sample().Timestamp)
+	}
+	require.NoError(t, it.Error())
+	require.Equal(t, []int64{time.Unix(1, 0).UnixNano(), time.Unix(2, 0).UnixNano()}, timestamps)
+}
@@ -0,0 +1,302 @@
+package tenantmigration
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/gorilla/mux"
+	"github.com/grafana/dskit/flagext"
+	"github.com/grafana/dskit/kv"
+	"github.com/grafana/dskit/services"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/loki/pkg/util"
+	serverutil "github.com/grafana/loki/pkg/util/server"
+)
+
+const migrationsKey = "tenant-migrations"
+
+var errInvalidTransition = errors.New("invalid tenant migration")
+
+// Config configures the migration of tenants to or from another cluster.
+type Config struct {
+	Enabled       bool             `yaml:"enabled"`
+	RemoteURL     flagext.URLValue `yaml:"remote_url"`
+	RemoteTimeout time.Duration    `yaml:"remote_timeout"`
+}
+
+// RegisterFlags registers flags.
+func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, "tenant-migration.enabled", false, "Enable the migration of tenants with the other cluster: the pushes of the migrating tenants are duplicated to the other cluster and their queries merge both clusters.")
+	f.Var(&cfg.RemoteURL, "tenant-migration.remote-url", "URL of the other cluster, serving the pushes and the queries of the migrating tenants.")
+	f.DurationVar(&cfg.RemoteTimeout, "tenant-migration.remote-timeout", 10*time.Second, "Timeout of the pushes duplicated to the other cluster.")
+}
+
+// Validate validates the config.
+func (cfg *Config) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.RemoteURL.URL == nil {
+		return errors.New("the URL of the other cluster is required to migrate tenants")
+	}
+	if cfg.RemoteTimeout <= 0 {
+		return errors.New("the timeout of the other cluster must be positive")
+	}
+	return nil
+}
+
+type metrics struct {
+	teedEntries        prometheus.Counter
+	teeFailures        prometheus.Counter
+	federatedQueries   prometheus.Counter
+	federationFailures prometheus.Counter
+}
+
+func newMetrics(reg prometheus.Registerer) *metrics {
+	return &metrics{
+		teedEntries: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Namespace: "loki",
+			Name:      "tenant_migration_teed_entries_total",
+			Help:      "Total number of entries of migrating tenants pushed to the other cluster.",
+		}),
+		teeFailures: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Namespace: "loki",
+			Name:      "tenant_migration_tee_failures_total",
+			Help:      "Total number of pushes of migrating tenants that failed in the other cluster.",
+		}),
+		federatedQueries: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Namespace: "loki",
+			Name:      "tenant_migration_federated_selects_total",
+			Help:      "Total number of selections of migrating tenants merged with the other cluster.",
+		}),
+		federationFailures: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Namespace: "loki",
+			Name:      "tenant_migration_federation_failures_total",
+			Help:      "Total number of selections of migrating tenants that failed in the other cluster.",
+		}),
+	}
+}
+
+// Migrator tracks the migrations of the tenants in the KV store, duplicates their pushes to
+// the other cluster and merges the other cluster in their queries.
+type Migrator struct {
+	services.Service
+
+	cfg     Config
+	kv      kv.Client
+	client  *client
+	logger  log.Logger
+	metrics *metrics
+	now     func() time.Time
+
+	mtx        sync.RWMutex
+	migrations *Migrations
+}
+
+// NewMigrator creates a migrator storing the migrations in the KV store.
+func NewMigrator(cfg Config, kvConfig kv.Config, logger log.Logger, reg prometheus.Registerer) (*Migrator, error) {
+	kvClient, err := kv.NewClient(kvConfig, JSONCodec, kv.RegistererWithKVName(reg, "tenant-migration"), logger)
+	if err != nil {
+		return nil, errors.Wrap(err, "create tenant migration KV store client")
+	}
+	return newMigrator(cfg, kvClient, logger, reg), nil
+}
+
+func newMigrator(cfg Config, kvClient kv.Client, logger log.Logger, reg prometheus.Registerer) *Migrator {
+	m := &Migrator{
+		cfg:     cfg,
+		kv:      kvClient,
+		client:  newClient(cfg),
+		logger:  log.With(logger, "component", "tenant-migration"),
+		metrics: newMetrics(reg),
+		now:     time.Now,
+	}
+	m.Service = services.NewBasicService(m.starting, m.running, nil)
+	return m
+}
+
+func (m *Migrator) starting(ctx context.Context) error {
+	value, err := m.kv.Get(ctx, migrationsKey)
+	if err != nil {
+		return errors.Wrap(err, "get tenant migrations")
+	}
+	m.update(value)
+	return nil
+}
+
+func (m *Migrator) running(ctx context.Context) error {
+	m.kv.WatchKey(ctx, migrationsKey, func(value interface{}) bool {
+		m.update(value)
+		return true
+	})
+	return nil
+}
+
+func (m *Migrator) update(value interface{}) {
+	migrations, ok := value.(*Migrations)
+	if !ok || migrations == nil {
+		return
+	}
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.migrations = migrations.Clone().(*Migrations)
+}
+
+// Phase returns the phase of the migration of the tenant, and false if the tenant isn't migrating.
+func (m *Migrator) Phase(tenant string) (Phase, bool) {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+	return m.migrations.active(tenant)
+}
+
+// setPhase starts the migration of the tenant or moves it to the phase.
+func (m *Migrator) setPhase(ctx context.Context, tenant string, phase Phase) error {
+	return m.cas(ctx, func(migrations *Migrations) error {
+		if _, ok := migrations.active(tenant); !ok && phase != PhaseDualWrite {
+			return errors.Wrapf(errInvalidTransition, "the migration of the tenant %s must start with the %s phase", tenant, PhaseDualWrite)
+		}
+		migrations.Tenants[tenant] = TenantMigration{Phase: phase, UpdatedAt: m.now()}
+		return nil
+	})
+}
+
+// finish finishes the migration of the tenant.
+func (m *Migrator) finish(ctx context.Context, tenant string) error {
+	return m.cas(ctx, func(migrations *Migrations) error {
+		current, ok := migrations.Tenants[tenant]
+		if !ok || current.Finished {
+			return errors.Wrapf(errInvalidTransition, "the tenant %s isn't migrating", tenant)
+		}
+		migrations.Tenants[tenant] = TenantMigration{Phase: current.Phase, UpdatedAt: m.now(), Finished: true}
+		return nil
+	})
+}
+
+func (m *Migrator) cas(ctx context.Context, f func(*Migrations) error) error {
+	var updated *Migrations
+	err := m.kv.CAS(ctx, migrationsKey, func(in interface{}) (out interface{}, retry bool, err error) {
+		migrations, _ := in.(*Migrations)
+		if migrations == nil {
+			migrations = &Migrations{}
+		}
+		migrations = migrations.Clone().(*Migrations)
+		if err := f(migrations); err != nil {
+			return nil, false, err
+		}
+		updated = migrations
+		return migrations, true, nil
+	})
+	if err != nil {
+		return err
+	}
+	m.update(updated)
+	return nil
+}
+
+// Duplicate pushes the streams of the migrating tenants to the other cluster.
+// The failures of the other cluster only fail the push once it is authoritative.
+func (m *Migrator) Duplicate(ctx context.Context, tenant string, streams []logproto.Stream) error {
+	phase, ok := m.Phase(tenant)
+	// pushes duplicated by the other cluster are never sent back.
+	if !ok || isDuplicate(ctx) {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, m.cfg.RemoteTimeout)
+	defer cancel()
+	if err := m.client.push(ctx, tenant, streams); err != nil {
+		m.metrics.teeFailures.Inc()
+		if phase == PhaseCutover {
+			return err
+		}
+		level.Warn(m.logger).Log("msg", "failed to push to the other cluster", "tenant", tenant, "err", err)
+		return nil
+	}
+
+	entries := 0
+	for _, stream := range streams {
+		entries += len(stream.Entries)
+	}
+	m.metrics.teedEntries.Add(float64(entries))
+	return nil
+}
+
+type duplicateKey struct{}
+
+func isDuplicate(ctx context.Context) bool {
+	return ctx.Value(duplicateKey{}) != nil
+}
+
+// PushHandler receives the pushes duplicated by the other cluster with the push handler,
+// without duplicating them again.
+func PushHandler(push http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		push.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), duplicateKey{}, true)))
+	})
+}
+
+// TenantMigrationStatus is the status of the migration of a tenant returned by the API.
+type TenantMigrationStatus struct {
+	Tenant    string    `json:"tenant"`
+	Phase     Phase     `json:"phase"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TenantsHandler lists the migrating tenants.
+func (m *Migrator) TenantsHandler(w http.ResponseWriter, _ *http.Request) {
+	m.mtx.RLock()
+	statuses := []TenantMigrationStatus{}
+	if m.migrations != nil {
+		for tenant, migration := range m.migrations.Tenants {
+			if !migration.Finished {
+				statuses = append(statuses, TenantMigrationStatus{Tenant: tenant, Phase: migration.Phase, UpdatedAt: migration.UpdatedAt})
+			}
+		}
+	}
+	m.mtx.RUnlock()
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Tenant < statuses[j].Tenant })
+	util.WriteJSONResponse(w, statuses)
+}
+
+// TenantHandler moves the tenant of the path to the phase of the request with POST,
+// and finishes its migration with DELETE.
+func (m *Migrator) TenantHandler(w http.ResponseWriter, r *http.Request) {
+	tenant := mux.Vars(r)["tenant"]
+	if tenant == "" {
+		serverutil.JSONError(w, http.StatusBadRequest, "missing tenant")
+		return
+	}
+
+	var err error
+	switch r.Method {
+	case http.MethodDelete:
+		err = m.finish(r.Context(), tenant)
+	default:
+		phase := Phase(r.FormValue("phase"))
+		if !phase.Valid() {
+			serverutil.JSONError(w, http.StatusBadRequest, "invalid phase, expected one of "+string(PhaseDualWrite)+" or "+string(PhaseCutover))
+			return
+		}
+		err = m.setPhase(r.Context(), tenant, phase)
+	}
+	if errors.Is(err, errInvalidTransition) {
+		serverutil.JSONError(w, http.StatusBadRequest, err.Error())
+		return
+	} else if err != nil {
+		serverutil.JSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	level.Info(m.logger).Log("msg", "updated tenant migration", "tenant", tenant, "method", r.Method, "phase", r.FormValue("phase"))
+	w.WriteHeader(http.StatusNoContent)
+}
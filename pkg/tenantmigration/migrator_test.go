@@ -0,0 +1,144 @@
+package tenantmigration
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/gorilla/mux"
+	"github.com/grafana/dskit/flagext"
+	"github.com/grafana/dskit/kv/consul"
+	"github.com/grafana/dskit/services"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/loki/pkg/logproto"
+)
+
+func newTestMigrator(t *testing.T, remoteURL string) *Migrator {
+	u, err := url.Parse(remoteURL)
+	require.NoError(t, err)
+	kvClient, closer := consul.NewInMemoryClient(JSONCodec, log.NewNopLogger(), nil)
+	t.Cleanup(func() { _ = closer.Close() })
+
+	m := newMigrator(Config{Enabled: true, RemoteURL: flagext.URLValue{URL: u}, RemoteTimeout: time.Second}, kvClient, log.NewNopLogger(), nil)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), m))
+	t.Cleanup(func() { _ = services.StopAndAwaitTerminated(context.Background(), m) })
+	return m
+}
+
+func TestMigrator_API(t *testing.T) {
+	m := newTestMigrator(t, "http://remote")
+	router := mux.NewRouter()
+	router.Path("/tenant-migration/tenants").Methods("GET").HandlerFunc(m.TenantsHandler)
+	router.Path("/tenant-migration/tenants/{tenant}").Methods("POST", "DELETE").HandlerFunc(m.TenantHandler)
+
+	do := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	// migrations start with dual writes.
+	require.Equal(t, http.StatusBadRequest, do("POST", "/tenant-migration/tenants/a?phase=cutover").Code)
+	require.Equal(t, http.StatusBadRequest, do("POST", "/tenant-migration/tenants/a?phase=unknown").Code)
+	require.Equal(t, http.StatusNoContent, do("POST", "/tenant-migration/tenants/a?phase=dual_write").Code)
+	require.Equal(t, http.StatusNoContent, do("POST", "/tenant-migration/tenants/b?phase=dual_write").Code)
+	require.Equal(t, http.StatusNoContent, do("POST", "/tenant-migration/tenants/a?phase=cutover").Code)
+
+	phase, ok := m.Phase("a")
+	require.True(t, ok)
+	require.Equal(t, PhaseCutover, phase)
+
+	require.Equal(t, http.StatusNoContent, do("DELETE", "/tenant-migration/tenants/b").Code)
+	require.Equal(t, http.StatusBadRequest, do("DELETE", "/tenant-migration/tenants/b").Code)
+	_, ok = m.Phase("b")
+	require.False(t, ok)
+
+	w := do("GET", "/tenant-migration/tenants")
+	require.Equal(t, http.StatusOK, w.Code)
+	var statuses []TenantMigrationStatus
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &statuses))
+	require.Len(t, statuses, 1)
+	require.Equal(t, "a", statuses[0].Tenant)
+	require.Equal(t, PhaseCutover, statuses[0].Phase)
+}
+
+type fakeRemotePushes struct {
+	mtx        sync.Mutex
+	status     int
+	tenants    []string
+	requests   []logproto.PushRequest
+	duplicates []bool
+}
+
+func (f *fakeRemotePushes) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	if f.status != 0 {
+		http.Error(w, "rejected", f.status)
+		return
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+	buf, _ := snappy.Decode(nil, body)
+	var req logproto.PushRequest
+	_ = proto.Unmarshal(buf, &req)
+	f.tenants = append(f.tenants, r.Header.Get(user.OrgIDHeaderName))
+	f.requests = append(f.requests, req)
+	f.duplicates = append(f.duplicates, isDuplicate(r.Context()))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestMigrator_Duplicate(t *testing.T) {
+	remote := &fakeRemotePushes{}
+	router := mux.NewRouter()
+	router.Path(PushPath).Handler(PushHandler(remote))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	ctx := context.Background()
+	m := newTestMigrator(t, server.URL)
+	streams := []logproto.Stream{{Labels: `{app="foo"}`, Entries: []logproto.Entry{{Timestamp: time.Unix(1, 0), Line: "line"}}}}
+
+	// tenants not migrating aren't duplicated.
+	require.NoError(t, m.Duplicate(ctx, "a", streams))
+	require.Empty(t, remote.requests)
+
+	require.NoError(t, m.setPhase(ctx, "a", PhaseDualWrite))
+	require.NoError(t, m.Duplicate(ctx, "a", streams))
+	require.Equal(t, []string{"a"}, remote.tenants)
+	require.Equal(t, streams[0].Labels, remote.requests[0].Streams[0].Labels)
+	require.Equal(t, "line", remote.requests[0].Streams[0].Entries[0].Line)
+	require.Equal(t, []bool{true}, remote.duplicates)
+
+	// the pushes received from the other cluster aren't sent back.
+	dupCtx := context.WithValue(ctx, duplicateKey{}, true)
+	require.NoError(t, m.Duplicate(dupCtx, "a", streams))
+	require.Len(t, remote.requests, 1)
+
+	// the failures of the other cluster only fail the pushes once it is authoritative.
+	remote.status = http.StatusTooManyRequests
+	require.NoError(t, m.Duplicate(ctx, "a", streams))
+	require.NoError(t, m.setPhase(ctx, "a", PhaseCutover))
+	err := m.Duplicate(ctx, "a", streams)
+	require.Error(t, err)
+	require.True(t, strings.Contains(err.Error(), "rejected"), err.Error())
+}
+
+func TestConfig_Validate(t *testing.T) {
+	u, _ := url.Parse("http://remote")
+	require.NoError(t, (&Config{}).Validate())
+	require.NoError(t, (&Config{Enabled: true, RemoteURL: flagext.URLValue{URL: u}, RemoteTimeout: time.Second}).Validate())
+	require.Error(t, (&Config{Enabled: true, RemoteTimeout: time.Second}).Validate())
+	require.Error(t, (&Config{Enabled: true, RemoteURL: flagext.URLValue{URL: u}}).Validate())
+}
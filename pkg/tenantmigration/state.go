@@ -0,0 +1,137 @@
+package tenantmigration
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/grafana/dskit/kv/memberlist"
+	jsoniter "github.com/json-iterator/go"
+)
+
+// Phase is the phase of the migration of a tenant.
+type Phase string
+
+const (
+	// PhaseDualWrite duplicates the pushes of the tenant to the remote cluster and merges the remote
+	// cluster in its queries. The local cluster stays authoritative: failures of the remote cluster
+	// are only logged.
+	PhaseDualWrite Phase = "dual_write"
+	// PhaseCutover makes the remote cluster authoritative: pushes and queries of the tenant fail
+	// when the remote cluster fails.
+	PhaseCutover Phase = "cutover"
+)
+
+// Valid returns whether the phase is known.
+func (p Phase) Valid() bool {
+	return p == PhaseDualWrite || p == PhaseCutover
+}
+
+// TenantMigration is the state of the migration of a tenant.
+type TenantMigration struct {
+	Phase     Phase     `json:"phase"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Finished migrations are kept as tombstones until they are removed from the KV store,
+	// so that they are propagated by memberlist.
+	Finished bool `json:"finished,omitempty"`
+}
+
+// Migrations are the migrations of the tenants of the cluster, stored in the KV store.
+type Migrations struct {
+	Tenants map[string]TenantMigration `json:"tenants"`
+}
+
+// Merge implements the memberlist.Mergeable interface.
+// The most recently updated migration of each tenant wins.
+func (m *Migrations) Merge(mergeable memberlist.Mergeable, localCAS bool) (memberlist.Mergeable, error) {
+	if mergeable == nil {
+		return nil, nil
+	}
+	other, ok := mergeable.(*Migrations)
+	if !ok {
+		return nil, fmt.Errorf("expected *tenantmigration.Migrations, got %T", mergeable)
+	}
+	if other == nil {
+		return nil, nil
+	}
+
+	change := &Migrations{Tenants: map[string]TenantMigration{}}
+	for tenant, migration := range other.Tenants {
+		if current, ok := m.Tenants[tenant]; ok && !migration.UpdatedAt.After(current.UpdatedAt) {
+			continue
+		}
+		if m.Tenants == nil {
+			m.Tenants = map[string]TenantMigration{}
+		}
+		m.Tenants[tenant] = migration
+		change.Tenants[tenant] = migration
+	}
+	if len(change.Tenants) == 0 {
+		return nil, nil
+	}
+	return change, nil
+}
+
+// MergeContent returns the tenants of the migrations.
+func (m *Migrations) MergeContent() []string {
+	tenants := make([]string, 0, len(m.Tenants))
+	for tenant := range m.Tenants {
+		tenants = append(tenants, tenant)
+	}
+	return tenants
+}
+
+// RemoveTombstones removes the migrations finished before the limit.
+func (m *Migrations) RemoveTombstones(limit time.Time) (total, removed int) {
+	for tenant, migration := range m.Tenants {
+		if !migration.Finished {
+			continue
+		}
+		if limit.IsZero() || migration.UpdatedAt.Before(limit) {
+			delete(m.Tenants, tenant)
+			removed++
+			continue
+		}
+		total++
+	}
+	return total, removed
+}
+
+// Clone implements the memberlist.Mergeable interface.
+func (m *Migrations) Clone() memberlist.Mergeable {
+	clone := &Migrations{Tenants: make(map[string]TenantMigration, len(m.Tenants))}
+	for tenant, migration := range m.Tenants {
+		clone.Tenants[tenant] = migration
+	}
+	return clone
+}
+
+// active returns the phase of the migration of the tenant, and false if the tenant isn't migrating.
+func (m *Migrations) active(tenant string) (Phase, bool) {
+	if m == nil {
+		return "", false
+	}
+	migration, ok := m.Tenants[tenant]
+	if !ok || migration.Finished {
+		return "", false
+	}
+	return migration.Phase, true
+}
+
+// JSONCodec is the codec of the migrations in the KV store.
+var JSONCodec = jsonCodec{}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Decode(data []byte) (interface{}, error) {
+	var migrations Migrations
+	if err := jsoniter.ConfigFastest.Unmarshal(data, &migrations); err != nil {
+		return nil, err
+	}
+	return &migrations, nil
+}
+
+func (jsonCodec) Encode(obj interface{}) ([]byte, error) {
+	return jsoniter.ConfigFastest.Marshal(obj)
+}
+
+func (jsonCodec) CodecID() string { return "tenantmigration.jsonCodec" }
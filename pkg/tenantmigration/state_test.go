@@ -0,0 +1,60 @@
+package tenantmigration
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrations_Merge(t *testing.T) {
+	now := time.Now()
+	local := &Migrations{Tenants: map[string]TenantMigration{
+		"a": {Phase: PhaseDualWrite, UpdatedAt: now},
+		"b": {Phase: PhaseCutover, UpdatedAt: now},
+	}}
+	remote := &Migrations{Tenants: map[string]TenantMigration{
+		"a": {Phase: PhaseCutover, UpdatedAt: now.Add(time.Second)},
+		"b": {Phase: PhaseDualWrite, UpdatedAt: now.Add(-time.Second)},
+		"c": {Phase: PhaseDualWrite, UpdatedAt: now, Finished: true},
+	}}
+
+	change, err := local.Merge(remote, false)
+	require.NoError(t, err)
+	require.Equal(t, &Migrations{Tenants: map[string]TenantMigration{
+		"a": {Phase: PhaseCutover, UpdatedAt: now.Add(time.Second)},
+		"c": {Phase: PhaseDualWrite, UpdatedAt: now, Finished: true},
+	}}, change)
+	require.Equal(t, PhaseCutover, local.Tenants["a"].Phase)
+	require.Equal(t, PhaseCutover, local.Tenants["b"].Phase)
+
+	// merging again doesn't change anything.
+	change, err = local.Merge(remote, false)
+	require.NoError(t, err)
+	require.Nil(t, change)
+
+	phase, ok := local.active("a")
+	require.True(t, ok)
+	require.Equal(t, PhaseCutover, phase)
+	_, ok = local.active("c")
+	require.False(t, ok)
+
+	total, removed := local.RemoveTombstones(now.Add(-time.Minute))
+	require.Equal(t, 1, total)
+	require.Equal(t, 0, removed)
+	total, removed = local.RemoveTombstones(now.Add(time.Minute))
+	require.Equal(t, 0, total)
+	require.Equal(t, 1, removed)
+	require.ElementsMatch(t, []string{"a", "b"}, local.MergeContent())
+}
+
+func TestJSONCodec(t *testing.T) {
+	migrations := &Migrations{Tenants: map[string]TenantMigration{
+		"a": {Phase: PhaseDualWrite, UpdatedAt: time.Unix(10, 0).UTC()},
+	}}
+	buf, err := JSONCodec.Encode(migrations)
+	require.NoError(t, err)
+	decoded, err := JSONCodec.Decode(buf)
+	require.NoError(t, err)
+	require.Equal(t, migrations, decoded)
+}
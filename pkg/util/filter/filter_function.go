@@ -0,0 +1,6 @@
+package filter
+
+import "time"
+
+// Func is a function filtering out the log lines for which it returns true.
+type Func func(ts time.Time, line string) bool
@@ -0,0 +1,35 @@
+package flagext
+
+import "strings"
+
+// StringSliceCSV is a slice of strings parsed from a comma separated string, the empty string being the empty slice
+// unlike the dskit type, so that the empty defaults survive the YAML round trip of the limits.
+// It implements flag.Value and the YAML marshalers.
+type StringSliceCSV []string
+
+func (v StringSliceCSV) String() string {
+	return strings.Join(v, ",")
+}
+
+func (v *StringSliceCSV) Set(s string) error {
+	if s == "" {
+		*v = nil
+		return nil
+	}
+	*v = strings.Split(s, ",")
+	return nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (v *StringSliceCSV) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	return v.Set(s)
+}
+
+// MarshalYAML implements yaml.Marshaler.
+func (v StringSliceCSV) MarshalYAML() (interface{}, error) {
+	return v.String(), nil
+}
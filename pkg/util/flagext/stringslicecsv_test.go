@@ -0,0 +1,36 @@
+package flagext
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func Test_StringSliceCSV(t *testing.T) {
+	for _, tc := range []struct {
+		in  string
+		out StringSliceCSV
+	}{
+		{in: "", out: nil},
+		{in: "gcs", out: StringSliceCSV{"gcs"}},
+		{in: "gcs,s3", out: StringSliceCSV{"gcs", "s3"}},
+	} {
+		t.Run(tc.in, func(t *testing.T) {
+			var v StringSliceCSV
+			require.NoError(t, v.Set(tc.in))
+			require.Equal(t, tc.out, v)
+			require.Equal(t, tc.in, v.String())
+
+			out, err := yaml.Marshal(struct {
+				V StringSliceCSV `yaml:"v"`
+			}{v})
+			require.NoError(t, err)
+			var back struct {
+				V StringSliceCSV `yaml:"v"`
+			}
+			require.NoError(t, yaml.Unmarshal(out, &back))
+			require.Equal(t, tc.out, back.V)
+		})
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"net/http"
 	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/weaveworks/common/middleware"
@@ -18,6 +20,48 @@ var (
 	safeQueryTags              = regexp.MustCompile("[^a-zA-Z0-9-=, ]+") // only alpha-numeric, ' ', ',', '=' and `-`
 
 	QueryQueueTimeHTTPHeader ctxKey = "X-Query-Queue-Time"
+
+	// QuerySeriesLimitStrategyHTTPHeader selects what happens when a metric query
+	// returns more series than allowed: fail the query (the default) or truncate the result.
+	QuerySeriesLimitStrategyHTTPHeader ctxKey = "X-Query-Series-Limit-Strategy"
+
+	// QueryNoSeriesLimitHTTPHeader is set by the query frontend on the splits and shards of the metric queries whose
+	// merged result it truncates to the maximum number of series: the queriers neither limit nor truncate their series.
+	QueryNoSeriesLimitHTTPHeader ctxKey = "X-Query-No-Series-Limit"
+
+	// QueryLimitsOverrideHTTPHeader asks the query frontend to ignore the max query range
+	// and max query age limits, for the tenants allowing it.
+	QueryLimitsOverrideHTTPHeader ctxKey = "X-Query-Limits-Override"
+
+	// QueryStrictParsingHTTPHeader asks for the lines which failed to be parsed by the json and
+	// logfmt parsers of a query to be reported with a warning, instead of only with __error__ labels.
+	QueryStrictParsingHTTPHeader ctxKey = "X-Query-Strict-Parsing"
+
+	// QuerySourceHTTPHeader restricts a query to the data of the ingesters or of the store.
+	// It can also be set with the source URL parameter.
+	QuerySourceHTTPHeader ctxKey = "X-Query-Source"
+
+	// QueryRoleHTTPHeader is the role of the caller, which the query masking policies of the
+	// tenant check to show the raw values to. It is trusted as is, so it must be set by a proxy.
+	QueryRoleHTTPHeader ctxKey = "X-Query-Role"
+
+	// QueryScopeHTTPHeader restricts a query to the streams matching a selector, e.g. {team="a"}, which
+	// the queriers add to the selectors of the query. Like the role, it must be set by a proxy.
+	QueryScopeHTTPHeader ctxKey = "X-Query-Scope"
+)
+
+// Query sources accepted in the QuerySourceHTTPHeader header.
+const (
+	QuerySourceIngesters = "ingesters"
+	QuerySourceStore     = "store"
+
+	querySourceParam = "source"
+)
+
+// Series limit strategies accepted in the QuerySeriesLimitStrategyHTTPHeader header.
+const (
+	SeriesLimitStrategyError    = "error"
+	SeriesLimitStrategyTruncate = "truncate"
 )
 
 func ExtractQueryTagsMiddleware() middleware.Interface {
@@ -54,3 +98,175 @@ func ExtractQueryMetricsMiddleware() middleware.Interface {
 		})
 	})
 }
+
+func ExtractSeriesLimitStrategyMiddleware() middleware.Interface {
+	return middleware.Func(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			strategy := req.Header.Get(string(QuerySeriesLimitStrategyHTTPHeader))
+			if strings.EqualFold(strategy, SeriesLimitStrategyTruncate) {
+				ctx := context.WithValue(req.Context(), QuerySeriesLimitStrategyHTTPHeader, SeriesLimitStrategyTruncate)
+				req = req.WithContext(ctx)
+			}
+			next.ServeHTTP(w, req)
+		})
+	})
+}
+
+// TruncateSeries tells if the query of the context asked for its result to be
+// truncated instead of failing when it exceeds the maximum number of series.
+func TruncateSeries(ctx context.Context) bool {
+	strategy, _ := ctx.Value(QuerySeriesLimitStrategyHTTPHeader).(string)
+	return strategy == SeriesLimitStrategyTruncate
+}
+
+// ExtractQueryNoSeriesLimitMiddleware extracts the series limit disabled by the query frontend. It is only used by
+// the queriers, the query frontend disables it itself for the queries it truncates.
+func ExtractQueryNoSeriesLimitMiddleware() middleware.Interface {
+	return middleware.Func(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if disabled, err := strconv.ParseBool(req.Header.Get(string(QueryNoSeriesLimitHTTPHeader))); err == nil && disabled {
+				req = req.WithContext(WithoutSeriesLimit(req.Context()))
+			}
+			next.ServeHTTP(w, req)
+		})
+	})
+}
+
+// WithoutSeriesLimit returns a context for the sub-queries of a query whose merged
+// result is truncated to the maximum number of series by the caller: their
+// results are neither limited nor truncated, so that the series with the most
+// samples are chosen once, out of all of them.
+func WithoutSeriesLimit(ctx context.Context) context.Context {
+	return context.WithValue(ctx, QueryNoSeriesLimitHTTPHeader, true)
+}
+
+// SeriesLimitDisabled tells if the query of the context must not limit its number of series.
+func SeriesLimitDisabled(ctx context.Context) bool {
+	disabled, _ := ctx.Value(QueryNoSeriesLimitHTTPHeader).(bool)
+	return disabled
+}
+
+func ExtractQueryLimitsOverrideMiddleware() middleware.Interface {
+	return middleware.Func(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if override, err := strconv.ParseBool(req.Header.Get(string(QueryLimitsOverrideHTTPHeader))); err == nil && override {
+				ctx := context.WithValue(req.Context(), QueryLimitsOverrideHTTPHeader, true)
+				req = req.WithContext(ctx)
+			}
+			next.ServeHTTP(w, req)
+		})
+	})
+}
+
+// OverrideQueryLimits tells if the query of the context asked for the max query
+// range and max query age limits to be ignored.
+func OverrideQueryLimits(ctx context.Context) bool {
+	override, _ := ctx.Value(QueryLimitsOverrideHTTPHeader).(bool)
+	return override
+}
+
+func ExtractQueryStrictParsingMiddleware() middleware.Interface {
+	return middleware.Func(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if strict, err := strconv.ParseBool(req.Header.Get(string(QueryStrictParsingHTTPHeader))); err == nil && strict {
+				ctx := context.WithValue(req.Context(), QueryStrictParsingHTTPHeader, true)
+				req = req.WithContext(ctx)
+			}
+			next.ServeHTTP(w, req)
+		})
+	})
+}
+
+// StrictParsing tells if the query of the context asked for the lines failing to be
+// parsed to be reported with a warning.
+func StrictParsing(ctx context.Context) bool {
+	strict, _ := ctx.Value(QueryStrictParsingHTTPHeader).(bool)
+	return strict
+}
+
+func ExtractQuerySourceMiddleware() middleware.Interface {
+	return middleware.Func(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			source := req.Header.Get(string(QuerySourceHTTPHeader))
+			if source == "" {
+				source = req.URL.Query().Get(querySourceParam)
+			}
+			source = strings.ToLower(source)
+			if source == QuerySourceIngesters || source == QuerySourceStore {
+				ctx := context.WithValue(req.Context(), QuerySourceHTTPHeader, source)
+				req = req.WithContext(ctx)
+			}
+			next.ServeHTTP(w, req)
+		})
+	})
+}
+
+// QuerySource returns the source the query of the context is restricted to,
+// QuerySourceIngesters or QuerySourceStore, or an empty string when both are queried.
+func QuerySource(ctx context.Context) string {
+	source, _ := ctx.Value(QuerySourceHTTPHeader).(string)
+	return source
+}
+
+// InjectQuerySource restricts the query of the context to the data of the source,
+// QuerySourceIngesters or QuerySourceStore.
+func InjectQuerySource(ctx context.Context, source string) context.Context {
+	return context.WithValue(ctx, QuerySourceHTTPHeader, source)
+}
+
+// QueryIngesters tells if the query of the context can query the ingesters.
+func QueryIngesters(ctx context.Context) bool {
+	return QuerySource(ctx) != QuerySourceStore
+}
+
+// QueryStore tells if the query of the context can query the store.
+func QueryStore(ctx context.Context) bool {
+	return QuerySource(ctx) != QuerySourceIngesters
+}
+
+func ExtractQueryRoleMiddleware() middleware.Interface {
+	return middleware.Func(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if role := strings.TrimSpace(req.Header.Get(string(QueryRoleHTTPHeader))); role != "" {
+				req = req.WithContext(InjectQueryRole(req.Context(), role))
+			}
+			next.ServeHTTP(w, req)
+		})
+	})
+}
+
+// QueryRole returns the role of the caller of the query of the context, empty when unknown.
+func QueryRole(ctx context.Context) string {
+	role, _ := ctx.Value(QueryRoleHTTPHeader).(string)
+	return role
+}
+
+// InjectQueryRole sets the role of the caller of the query of the context.
+func InjectQueryRole(ctx context.Context, role string) context.Context {
+	return context.WithValue(ctx, QueryRoleHTTPHeader, role)
+}
+
+// ExtractQueryScopeMiddleware extracts the scope of the queries, marking them as received over HTTP even
+// when they aren't scoped: unlike the queries of the ruler, they must be scoped when the tenant requires it.
+func ExtractQueryScopeMiddleware() middleware.Interface {
+	return middleware.Func(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			scope := strings.TrimSpace(req.Header.Get(string(QueryScopeHTTPHeader)))
+			req = req.WithContext(InjectQueryScope(req.Context(), scope))
+			next.ServeHTTP(w, req)
+		})
+	})
+}
+
+// QueryScope returns the selector the query of the context is restricted to, empty when it isn't scoped,
+// and whether the query was received over HTTP.
+func QueryScope(ctx context.Context) (string, bool) {
+	scope, ok := ctx.Value(QueryScopeHTTPHeader).(string)
+	return scope, ok
+}
+
+// InjectQueryScope restricts the query of the context to the streams matching the selector, and marks it as
+// received over HTTP.
+func InjectQueryScope(ctx context.Context, selector string) context.Context {
+	return context.WithValue(ctx, QueryScopeHTTPHeader, selector)
+}
@@ -1,6 +1,7 @@
 package httpreq
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -91,3 +92,209 @@ func TestQueryMetrics(t *testing.T) {
 		})
 	}
 }
+
+func TestSeriesLimitStrategy(t *testing.T) {
+	for _, tc := range []struct {
+		in  string
+		exp bool
+	}{
+		{in: ``, exp: false},
+		{in: `error`, exp: false},
+		{in: `truncate`, exp: true},
+		{in: `Truncate`, exp: true},
+		{in: `foo`, exp: false},
+	} {
+		t.Run(tc.in, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://testing.com", nil)
+			req.Header.Set(string(QuerySeriesLimitStrategyHTTPHeader), tc.in)
+
+			w := httptest.NewRecorder()
+			checked := false
+			mware := ExtractSeriesLimitStrategyMiddleware().Wrap(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				require.Equal(t, tc.exp, TruncateSeries(req.Context()))
+				checked = true
+			}))
+
+			mware.ServeHTTP(w, req)
+
+			require.True(t, checked)
+		})
+	}
+}
+
+func TestQueryLimitsOverride(t *testing.T) {
+	for _, tc := range []struct {
+		in  string
+		exp bool
+	}{
+		{in: ``, exp: false},
+		{in: `false`, exp: false},
+		{in: `true`, exp: true},
+		{in: `1`, exp: true},
+		{in: `foo`, exp: false},
+	} {
+		t.Run(tc.in, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://testing.com", nil)
+			req.Header.Set(string(QueryLimitsOverrideHTTPHeader), tc.in)
+
+			w := httptest.NewRecorder()
+			checked := false
+			mware := ExtractQueryLimitsOverrideMiddleware().Wrap(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				require.Equal(t, tc.exp, OverrideQueryLimits(req.Context()))
+				checked = true
+			}))
+
+			mware.ServeHTTP(w, req)
+
+			require.True(t, checked)
+		})
+	}
+}
+
+func TestQueryNoSeriesLimit(t *testing.T) {
+	for _, tc := range []struct {
+		in  string
+		exp bool
+	}{
+		{in: ``, exp: false},
+		{in: `false`, exp: false},
+		{in: `true`, exp: true},
+		{in: `foo`, exp: false},
+	} {
+		t.Run(tc.in, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://testing.com", nil)
+			req.Header.Set(string(QueryNoSeriesLimitHTTPHeader), tc.in)
+
+			w := httptest.NewRecorder()
+			checked := false
+			mware := ExtractQueryNoSeriesLimitMiddleware().Wrap(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				require.Equal(t, tc.exp, SeriesLimitDisabled(req.Context()))
+				checked = true
+			}))
+
+			mware.ServeHTTP(w, req)
+
+			require.True(t, checked)
+		})
+	}
+}
+
+func TestQueryStrictParsing(t *testing.T) {
+	for _, tc := range []struct {
+		in  string
+		exp bool
+	}{
+		{in: ``, exp: false},
+		{in: `false`, exp: false},
+		{in: `true`, exp: true},
+		{in: `foo`, exp: false},
+	} {
+		t.Run(tc.in, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://testing.com", nil)
+			req.Header.Set(string(QueryStrictParsingHTTPHeader), tc.in)
+
+			w := httptest.NewRecorder()
+			checked := false
+			mware := ExtractQueryStrictParsingMiddleware().Wrap(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				require.Equal(t, tc.exp, StrictParsing(req.Context()))
+				checked = true
+			}))
+
+			mware.ServeHTTP(w, req)
+
+			require.True(t, checked)
+		})
+	}
+}
+
+func TestQuerySource(t *testing.T) {
+	for _, tc := range []struct {
+		header string
+		param  string
+		exp    string
+	}{
+		{exp: ``},
+		{header: `ingesters`, exp: QuerySourceIngesters},
+		{header: `Store`, exp: QuerySourceStore},
+		{header: `foo`, exp: ``},
+		{param: `store`, exp: QuerySourceStore},
+		{header: `ingesters`, param: `store`, exp: QuerySourceIngesters},
+	} {
+		t.Run(tc.header+"/"+tc.param, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://testing.com?source="+tc.param, nil)
+			req.Header.Set(string(QuerySourceHTTPHeader), tc.header)
+
+			w := httptest.NewRecorder()
+			checked := false
+			mware := ExtractQuerySourceMiddleware().Wrap(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				require.Equal(t, tc.exp, QuerySource(req.Context()))
+				require.Equal(t, tc.exp != QuerySourceStore, QueryIngesters(req.Context()))
+				require.Equal(t, tc.exp != QuerySourceIngesters, QueryStore(req.Context()))
+				checked = true
+			}))
+
+			mware.ServeHTTP(w, req)
+
+			require.True(t, checked)
+		})
+	}
+}
+
+func TestQueryRole(t *testing.T) {
+	for _, tc := range []struct {
+		header string
+		exp    string
+	}{
+		{exp: ``},
+		{header: `admin`, exp: `admin`},
+		{header: ` viewer `, exp: `viewer`},
+	} {
+		t.Run(tc.header, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://testing.com", nil)
+			req.Header.Set(string(QueryRoleHTTPHeader), tc.header)
+
+			w := httptest.NewRecorder()
+			checked := false
+			mware := ExtractQueryRoleMiddleware().Wrap(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				require.Equal(t, tc.exp, QueryRole(req.Context()))
+				checked = true
+			}))
+
+			mware.ServeHTTP(w, req)
+
+			require.True(t, checked)
+		})
+	}
+}
+
+func TestQueryScope(t *testing.T) {
+	for _, tc := range []struct {
+		header string
+		exp    string
+	}{
+		{exp: ``},
+		{header: ` {team="a"} `, exp: `{team="a"}`},
+	} {
+		t.Run(tc.header, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://testing.com", nil)
+			req.Header.Set(string(QueryScopeHTTPHeader), tc.header)
+
+			w := httptest.NewRecorder()
+			checked := false
+			mware := ExtractQueryScopeMiddleware().Wrap(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				scope, external := QueryScope(req.Context())
+				require.Equal(t, tc.exp, scope)
+				require.True(t, external)
+				checked = true
+			}))
+
+			mware.ServeHTTP(w, req)
+
+			require.True(t, checked)
+		})
+	}
+
+	// the queries not received over HTTP, e.g. by the ruler, are never scoped.
+	_, external := QueryScope(context.Background())
+	require.False(t, external)
+}
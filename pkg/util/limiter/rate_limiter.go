@@ -0,0 +1,195 @@
+package limiter
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/grafana/dskit/limiter"
+	"golang.org/x/time/rate"
+)
+
+// Reservation tells whether the tokens were taken from a rate limiter, and the state of the limiter.
+type Reservation struct {
+	OK bool
+	// RetryAfter is how long to wait for the tokens to be available, or for the limiter to be full
+	// when more tokens than the burst were asked for. It is zero when the tokens were taken.
+	RetryAfter time.Duration
+	Limit      float64
+	Burst      int
+	// Remaining is the number of tokens left in the limiter.
+	Remaining int
+}
+
+// ReserveN takes n tokens from the limiter when they're available at time now. Unlike AllowN,
+// it tells how long to wait when the tokens aren't available.
+func ReserveN(lim *rate.Limiter, now time.Time, n int) Reservation {
+	res := Reservation{Limit: float64(lim.Limit()), Burst: lim.Burst()}
+	if lim.Limit() == rate.Inf {
+		res.OK, res.Remaining = true, res.Burst
+		return res
+	}
+	if n <= res.Burst {
+		r := lim.ReserveN(now, n)
+		switch delay := r.DelayFrom(now); {
+		case !r.OK():
+			// the limiter doesn't refill with a zero limit.
+		case delay > 0:
+			r.CancelAt(now)
+			res.RetryAfter = delay
+		default:
+			res.OK = true
+		}
+	} else {
+		res.RetryAfter = DelayN(lim, now, n)
+	}
+	res.Remaining = tokensAt(lim, now)
+	return res
+}
+
+// UsageN returns the state of the limiter at time now, and how long to wait for n tokens to be
+// available without taking them.
+func UsageN(lim *rate.Limiter, now time.Time, n int) Reservation {
+	res := Reservation{Limit: float64(lim.Limit()), Burst: lim.Burst(), RetryAfter: DelayN(lim, now, n)}
+	res.OK = res.RetryAfter == 0
+	res.Remaining = tokensAt(lim, now)
+	if lim.Limit() == rate.Inf {
+		res.Remaining = res.Burst
+	}
+	return res
+}
+
+// DelayN returns how long to wait for n tokens to be available in the limiter without taking them,
+// or for the limiter to be full when n is greater than the burst.
+func DelayN(lim *rate.Limiter, now time.Time, n int) time.Duration {
+	if lim.Limit() == rate.Inf {
+		return 0
+	}
+	if n > lim.Burst() {
+		n = lim.Burst()
+	}
+	r := lim.ReserveN(now, n)
+	if !r.OK() {
+		return 0
+	}
+	delay := r.DelayFrom(now)
+	r.CancelAt(now)
+	return delay
+}
+
+// tokensAt returns the number of tokens available in the limiter, computed from the delay
+// of a reservation of the whole burst.
+func tokensAt(lim *rate.Limiter, now time.Time) int {
+	limit, burst := float64(lim.Limit()), lim.Burst()
+	if limit <= 0 {
+		return 0
+	}
+	tokens := burst - int(math.Ceil(DelayN(lim, now, burst).Seconds()*limit))
+	if tokens < 0 {
+		return 0
+	}
+	return tokens
+}
+
+// RateLimiterStrategy gives the limit and the burst of each tenant, they can change over time.
+type RateLimiterStrategy = limiter.RateLimiterStrategy
+
+// RateLimiter is a multi-tenant local rate limiter like the one of dskit, whose reservations tell
+// the clients how long to wait before retrying.
+type RateLimiter struct {
+	strategy      RateLimiterStrategy
+	recheckPeriod time.Duration
+
+	tenantsLock sync.RWMutex
+	tenants     map[string]*tenantLimiter
+}
+
+type tenantLimiter struct {
+	// mtx makes the reservations of the tenant atomic, as telling the tokens left takes another reservation.
+	mtx       sync.Mutex
+	limiter   *rate.Limiter
+	recheckAt time.Time
+}
+
+// NewRateLimiter makes a new multi-tenant rate limiter. The limit and the burst of each tenant
+// are rechecked every recheckPeriod.
+func NewRateLimiter(strategy RateLimiterStrategy, recheckPeriod time.Duration) *RateLimiter {
+	return &RateLimiter{
+		strategy:      strategy,
+		recheckPeriod: recheckPeriod,
+		tenants:       map[string]*tenantLimiter{},
+	}
+}
+
+// AllowN reports whether n tokens of the tenant may be consumed at time now.
+func (l *RateLimiter) AllowN(now time.Time, tenantID string, n int) bool {
+	return l.ReserveN(now, tenantID, n).OK
+}
+
+// ReserveN takes n tokens of the tenant when they're available at time now.
+func (l *RateLimiter) ReserveN(now time.Time, tenantID string, n int) Reservation {
+	entry := l.getTenantLimiter(now, tenantID)
+	entry.mtx.Lock()
+	defer entry.mtx.Unlock()
+	return ReserveN(entry.limiter, now, n)
+}
+
+// Usage returns the state of the limiter of the tenant at time now without taking any token.
+func (l *RateLimiter) Usage(now time.Time, tenantID string) Reservation {
+	return l.ReserveN(now, tenantID, 0)
+}
+
+// Limit returns the currently configured maximum overall tokens rate.
+func (l *RateLimiter) Limit(now time.Time, tenantID string) float64 {
+	return float64(l.getTenantLimiter(now, tenantID).limiter.Limit())
+}
+
+// Burst returns the currently configured maximum burst size.
+func (l *RateLimiter) Burst(now time.Time, tenantID string) int {
+	return l.getTenantLimiter(now, tenantID).limiter.Burst()
+}
+
+func (l *RateLimiter) getTenantLimiter(now time.Time, tenantID string) *tenantLimiter {
+	l.tenantsLock.RLock()
+	entry, ok := l.tenants[tenantID]
+	recheck := ok && !now.Before(entry.recheckAt)
+	l.tenantsLock.RUnlock()
+
+	if recheck {
+		l.recheckTenantLimiter(now, tenantID)
+	}
+	if ok {
+		return entry
+	}
+
+	limiter := rate.NewLimiter(rate.Limit(l.strategy.Limit(tenantID)), l.strategy.Burst(tenantID))
+
+	l.tenantsLock.Lock()
+	defer l.tenantsLock.Unlock()
+	if entry, ok = l.tenants[tenantID]; !ok {
+		entry = &tenantLimiter{limiter: limiter, recheckAt: now.Add(l.recheckPeriod)}
+		l.tenants[tenantID] = entry
+	}
+	return entry
+}
+
+func (l *RateLimiter) recheckTenantLimiter(now time.Time, tenantID string) {
+	limit := rate.Limit(l.strategy.Limit(tenantID))
+	burst := l.strategy.Burst(tenantID)
+
+	l.tenantsLock.Lock()
+	defer l.tenantsLock.Unlock()
+
+	entry := l.tenants[tenantID]
+	// the limiter may have been rechecked in the meantime.
+	if now.Before(entry.recheckAt) {
+		return
+	}
+	if entry.limiter.Limit() != limit {
+		entry.limiter.SetLimitAt(now, limit)
+	}
+	if entry.limiter.Burst() != burst {
+		entry.limiter.SetBurstAt(now, burst)
+	}
+	entry.recheckAt = now.Add(l.recheckPeriod)
+}
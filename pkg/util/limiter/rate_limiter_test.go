@@ -0,0 +1,71 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+type staticStrategy struct {
+	limit float64
+	burst int
+}
+
+func (s staticStrategy) Limit(string) float64 { return s.limit }
+func (s staticStrategy) Burst(string) int     { return s.burst }
+
+func TestRateLimiter_ReserveN(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := NewRateLimiter(staticStrategy{limit: 10, burst: 20}, time.Minute)
+
+	res := l.ReserveN(now, "tenant", 15)
+	require.Equal(t, Reservation{OK: true, Limit: 10, Burst: 20, Remaining: 5}, res)
+
+	// the 3 missing tokens are available in 300ms, and the failed reservation doesn't take any token.
+	res = l.ReserveN(now, "tenant", 8)
+	require.Equal(t, Reservation{RetryAfter: 300 * time.Millisecond, Limit: 10, Burst: 20, Remaining: 5}, res)
+	require.Equal(t, 5, l.Usage(now, "tenant").Remaining)
+
+	res = l.ReserveN(now.Add(300*time.Millisecond), "tenant", 8)
+	require.True(t, res.OK)
+	require.Equal(t, 0, res.Remaining)
+
+	// more tokens than the burst are never available, the clients are told to wait for a full limiter.
+	res = l.ReserveN(now.Add(300*time.Millisecond), "tenant", 30)
+	require.False(t, res.OK)
+	require.Equal(t, 2*time.Second, res.RetryAfter)
+
+	// the tenants have their own limiter.
+	require.Equal(t, 20, l.Usage(now, "other").Remaining)
+	require.True(t, l.AllowN(now, "other", 20))
+	require.False(t, l.AllowN(now, "other", 1))
+}
+
+func TestRateLimiter_Recheck(t *testing.T) {
+	now := time.Unix(0, 0)
+	strategy := &staticStrategy{limit: 10, burst: 10}
+	l := NewRateLimiter(strategy, time.Second)
+	require.Equal(t, 10, l.Usage(now, "tenant").Remaining)
+
+	strategy.limit, strategy.burst = 20, 5
+	require.Equal(t, float64(10), l.Limit(now, "tenant"))
+	require.Equal(t, float64(20), l.Limit(now.Add(time.Second), "tenant"))
+	require.Equal(t, 5, l.Burst(now.Add(time.Second), "tenant"))
+}
+
+func TestUsageN(t *testing.T) {
+	now := time.Unix(0, 0)
+	lim := rate.NewLimiter(100, 100)
+	require.True(t, lim.AllowN(now, 100))
+
+	res := UsageN(lim, now, 50)
+	require.Equal(t, Reservation{RetryAfter: 500 * time.Millisecond, Limit: 100, Burst: 100}, res)
+	// the usage doesn't take any token.
+	require.True(t, lim.AllowN(now.Add(500*time.Millisecond), 50))
+
+	res = UsageN(rate.NewLimiter(rate.Inf, 10), now, 50)
+	require.True(t, res.OK)
+	require.Equal(t, 10, res.Remaining)
+}
@@ -49,6 +49,8 @@ var queryTests = []struct {
 						"chunksDownloadTime": 0,
 						"totalChunksRef": 0,
 						"totalChunksDownloaded": 0,
+						"totalChunksQuarantined": 0,
+						"totalChunksBloomFiltered": 0,
 						"chunk" :{
 							"compressedBytes": 0,
 							"decompressedBytes": 0,
@@ -56,6 +58,11 @@ var queryTests = []struct {
 							"headChunkBytes": 0,
 							"headChunkLines": 0,
 							"totalDuplicates": 0
+						},
+						"parsing": {
+							"totalLinesParsed": 0,
+							"jsonParserErrors": 0,
+							"logfmtParserErrors": 0
 						}
 					},
 					"totalBatches": 0,
@@ -68,6 +75,8 @@ var queryTests = []struct {
 						"chunksDownloadTime": 0,
 						"totalChunksRef": 0,
 						"totalChunksDownloaded": 0,
+						"totalChunksQuarantined": 0,
+						"totalChunksBloomFiltered": 0,
 						"chunk" :{
 							"compressedBytes": 0,
 							"decompressedBytes": 0,
@@ -75,6 +84,11 @@ var queryTests = []struct {
 							"headChunkBytes": 0,
 							"headChunkLines": 0,
 							"totalDuplicates": 0
+						},
+						"parsing": {
+							"totalLinesParsed": 0,
+							"jsonParserErrors": 0,
+							"logfmtParserErrors": 0
 						}
 					}
 				},
@@ -85,7 +99,9 @@ var queryTests = []struct {
 					"queueTime": 0,
 					"subqueries": 0,
 					"totalBytesProcessed":0,
-					"totalLinesProcessed":0
+					"totalLinesProcessed":0,
+					"totalParseErrors": 0,
+					"parseErrorRate": 0
 				}
 			}
 		}`,
@@ -30,6 +30,7 @@ func WriteQueryResponseJSON(v logqlmodel.Result, w io.Writer) error {
 			Result:     value,
 			Statistics: v.Statistics,
 		},
+		Warnings: v.Warnings,
 	}
 
 	return jsoniter.NewEncoder(w).Encode(q)
@@ -80,6 +81,17 @@ func WriteSeriesResponseJSON(r logproto.SeriesResponse, w io.Writer) error {
 	return jsoniter.NewEncoder(w).Encode(adapter)
 }
 
+// WriteIndexStatsResponseJSON marshals a logproto.IndexStatsResponse to v1 loghttp JSON and then
+// writes it to the provided io.Writer.
+func WriteIndexStatsResponseJSON(r logproto.IndexStatsResponse, w io.Writer) error {
+	v1Response := loghttp.IndexStatsResponse{
+		Status: "success",
+		Data:   r,
+	}
+
+	return jsoniter.NewEncoder(w).Encode(v1Response)
+}
+
 // This struct exists primarily because we can't specify a repeated map in proto v3.
 // Otherwise, we'd use that + gogoproto.jsontag to avoid this layer of indirection
 type seriesResponseAdapter struct {
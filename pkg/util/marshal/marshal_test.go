@@ -55,6 +55,8 @@ var queryTests = []struct {
 							"chunksDownloadTime": 0,
 							"totalChunksRef": 0,
 							"totalChunksDownloaded": 0,
+							"totalChunksQuarantined": 0,
+							"totalChunksBloomFiltered": 0,
 							"chunk" :{
 								"compressedBytes": 0,
 								"decompressedBytes": 0,
@@ -62,6 +64,11 @@ var queryTests = []struct {
 								"headChunkBytes": 0,
 								"headChunkLines": 0,
 								"totalDuplicates": 0
+							},
+							"parsing": {
+								"totalLinesParsed": 0,
+								"jsonParserErrors": 0,
+								"logfmtParserErrors": 0
 							}
 						},
 						"totalBatches": 0,
@@ -74,6 +81,8 @@ var queryTests = []struct {
 							"chunksDownloadTime": 0,
 							"totalChunksRef": 0,
 							"totalChunksDownloaded": 0,
+							"totalChunksQuarantined": 0,
+							"totalChunksBloomFiltered": 0,
 							"chunk" :{
 								"compressedBytes": 0,
 								"decompressedBytes": 0,
@@ -81,6 +90,11 @@ var queryTests = []struct {
 								"headChunkBytes": 0,
 								"headChunkLines": 0,
 								"totalDuplicates": 0
+							},
+							"parsing": {
+								"totalLinesParsed": 0,
+								"jsonParserErrors": 0,
+								"logfmtParserErrors": 0
 							}
 						}
 					},
@@ -91,7 +105,9 @@ var queryTests = []struct {
 						"queueTime": 0,
 						"subqueries": 0,
 						"totalBytesProcessed":0,
-						"totalLinesProcessed":0
+						"totalLinesProcessed":0,
+						"totalParseErrors": 0,
+						"parseErrorRate": 0
 					}
 				}
 			}
@@ -164,6 +180,8 @@ var queryTests = []struct {
 						"chunksDownloadTime": 0,
 						"totalChunksRef": 0,
 						"totalChunksDownloaded": 0,
+						"totalChunksQuarantined": 0,
+						"totalChunksBloomFiltered": 0,
 						"chunk" :{
 							"compressedBytes": 0,
 							"decompressedBytes": 0,
@@ -171,6 +189,11 @@ var queryTests = []struct {
 							"headChunkBytes": 0,
 							"headChunkLines": 0,
 							"totalDuplicates": 0
+						},
+						"parsing": {
+							"totalLinesParsed": 0,
+							"jsonParserErrors": 0,
+							"logfmtParserErrors": 0
 						}
 					},
 					"totalBatches": 0,
@@ -183,6 +206,8 @@ var queryTests = []struct {
 						"chunksDownloadTime": 0,
 						"totalChunksRef": 0,
 						"totalChunksDownloaded": 0,
+						"totalChunksQuarantined": 0,
+						"totalChunksBloomFiltered": 0,
 						"chunk" :{
 							"compressedBytes": 0,
 							"decompressedBytes": 0,
@@ -190,6 +215,11 @@ var queryTests = []struct {
 							"headChunkBytes": 0,
 							"headChunkLines": 0,
 							"totalDuplicates": 0
+						},
+						"parsing": {
+							"totalLinesParsed": 0,
+							"jsonParserErrors": 0,
+							"logfmtParserErrors": 0
 						}
 					}
 				},
@@ -200,7 +230,9 @@ var queryTests = []struct {
 					"queueTime": 0,
 					"subqueries": 0,
 					"totalBytesProcessed":0,
-					"totalLinesProcessed":0
+					"totalLinesProcessed":0,
+					"totalParseErrors": 0,
+					"parseErrorRate": 0
 				}
 			  }
 			},
@@ -290,6 +322,8 @@ var queryTests = []struct {
 						"chunksDownloadTime": 0,
 						"totalChunksRef": 0,
 						"totalChunksDownloaded": 0,
+						"totalChunksQuarantined": 0,
+						"totalChunksBloomFiltered": 0,
 						"chunk" :{
 							"compressedBytes": 0,
 							"decompressedBytes": 0,
@@ -297,6 +331,11 @@ var queryTests = []struct {
 							"headChunkBytes": 0,
 							"headChunkLines": 0,
 							"totalDuplicates": 0
+						},
+						"parsing": {
+							"totalLinesParsed": 0,
+							"jsonParserErrors": 0,
+							"logfmtParserErrors": 0
 						}
 					},
 					"totalBatches": 0,
@@ -309,6 +348,8 @@ var queryTests = []struct {
 						"chunksDownloadTime": 0,
 						"totalChunksRef": 0,
 						"totalChunksDownloaded": 0,
+						"totalChunksQuarantined": 0,
+						"totalChunksBloomFiltered": 0,
 						"chunk" :{
 							"compressedBytes": 0,
 							"decompressedBytes": 0,
@@ -316,6 +357,11 @@ var queryTests = []struct {
 							"headChunkBytes": 0,
 							"headChunkLines": 0,
 							"totalDuplicates": 0
+						},
+						"parsing": {
+							"totalLinesParsed": 0,
+							"jsonParserErrors": 0,
+							"logfmtParserErrors": 0
 						}
 					}
 				},
@@ -326,7 +372,9 @@ var queryTests = []struct {
 					"queueTime": 0,
 					"subqueries": 0,
 					"totalBytesProcessed":0,
-					"totalLinesProcessed":0
+					"totalLinesProcessed":0,
+					"totalParseErrors": 0,
+					"parseErrorRate": 0
 				}
 			  }
 			},
@@ -420,6 +468,14 @@ func Test_WriteLabelResponseJSON(t *testing.T) {
 	}
 }
 
+func Test_WriteIndexStatsResponseJSON(t *testing.T) {
+	var b bytes.Buffer
+	err := WriteIndexStatsResponseJSON(logproto.IndexStatsResponse{Streams: 2, Chunks: 5, Bytes: 10240, Entries: 300}, &b)
+	require.NoError(t, err)
+
+	testJSONBytesEqual(t, []byte(`{"status":"success","data":{"streams":2,"chunks":5,"bytes":10240,"entries":300}}`), b.Bytes(), "Index stats test failed")
+}
+
 func Test_MarshalTailResponse(t *testing.T) {
 	for i, tailTest := range tailTests {
 		// convert logproto to model objects
@@ -6,7 +6,10 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
+	"github.com/grafana/dskit/ring"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
@@ -28,26 +31,96 @@ const (
 	ErrDeadlineExceeded = "Request timed out, decrease the duration of the request or add more label matchers (prefer exact match over regex match) to reduce the amount of data processed."
 )
 
+// Types of the errors, set in the body and the headers of the error responses so that the clients
+// can tell which requests are worth retrying.
+const (
+	ErrorTypeLimitExceeded              = "limit_exceeded"
+	ErrorTypeParseError                 = "parse_error"
+	ErrorTypeTimeout                    = "timeout"
+	ErrorTypeStoreUnavailable           = "store_unavailable"
+	ErrorTypeTooManyOutstandingRequests = "too_many_outstanding_requests"
+	ErrorTypeCanceled                   = "canceled"
+	ErrorTypeBadRequest                 = "bad_request"
+	ErrorTypeNotFound                   = "not_found"
+	ErrorTypeInternal                   = "internal"
+)
+
+const (
+	// ErrorTypeHeader is the header holding the type of the error of the response.
+	ErrorTypeHeader = "X-Loki-Error-Type"
+	// ErrorRetryableHeader is the header telling whether the request failing with the error can be retried.
+	ErrorRetryableHeader = "X-Loki-Error-Retryable"
+)
+
+// errTooManyOutstandingRequests is the message of the errors of the full queues of the query frontend and scheduler.
+const errTooManyOutstandingRequests = "too many outstanding requests"
+
 type ErrorResponseBody struct {
-	Code    int    `json:"code"`
-	Status  string `json:"status"`
-	Message string `json:"message"`
+	Code      int    `json:"code"`
+	Status    string `json:"status"`
+	Message   string `json:"message"`
+	ErrorType string `json:"errorType,omitempty"`
+	Retryable bool   `json:"retryable"`
+}
+
+// IsRetryableErrorType returns whether the requests failing with the type of error may succeed when retried.
+func IsRetryableErrorType(errorType string) bool {
+	switch errorType {
+	case ErrorTypeTimeout, ErrorTypeStoreUnavailable, ErrorTypeTooManyOutstandingRequests, ErrorTypeInternal:
+		return true
+	}
+	return false
 }
 
 func NotFoundHandler(w http.ResponseWriter, r *http.Request) {
 	JSONError(w, 404, "not found")
 }
 
+// JSONError writes an error response of the type inferred from the status code.
 func JSONError(w http.ResponseWriter, code int, message string, args ...interface{}) {
+	JSONErrorWithType(w, code, errorTypeFromStatus(code, ""), message, args...)
+}
+
+// JSONErrorWithType writes an error response of the type.
+func JSONErrorWithType(w http.ResponseWriter, code int, errorType string, message string, args ...interface{}) {
+	retryable := IsRetryableErrorType(errorType)
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set(ErrorTypeHeader, errorType)
+	w.Header().Set(ErrorRetryableHeader, strconv.FormatBool(retryable))
 	w.WriteHeader(code)
 	_ = json.NewEncoder(w).Encode(ErrorResponseBody{
-		Code:    code,
-		Status:  "error",
-		Message: fmt.Sprintf(message, args...),
+		Code:      code,
+		Status:    "error",
+		Message:   fmt.Sprintf(message, args...),
+		ErrorType: errorType,
+		Retryable: retryable,
 	})
 }
 
+// errorTypeFromStatus infers the type of an error from its status code and message. The limits
+// enforced by the queriers fail with a bad request whose message tells that the limit is exceeded.
+func errorTypeFromStatus(code int, message string) string {
+	switch {
+	case code == http.StatusTooManyRequests && strings.Contains(message, errTooManyOutstandingRequests):
+		return ErrorTypeTooManyOutstandingRequests
+	case code == http.StatusTooManyRequests, code == http.StatusRequestEntityTooLarge,
+		code == http.StatusBadRequest && strings.Contains(message, "exceed"):
+		return ErrorTypeLimitExceeded
+	case code == http.StatusNotFound:
+		return ErrorTypeNotFound
+	case code == StatusClientClosedRequest:
+		return ErrorTypeCanceled
+	case code == http.StatusServiceUnavailable:
+		return ErrorTypeStoreUnavailable
+	case code == http.StatusGatewayTimeout:
+		return ErrorTypeTimeout
+	case code/100 == 4:
+		return ErrorTypeBadRequest
+	default:
+		return ErrorTypeInternal
+	}
+}
+
 // WriteError write a go error with the correct status code.
 func WriteError(err error, w http.ResponseWriter) {
 	var (
@@ -73,15 +146,35 @@ func WriteError(err error, w http.ResponseWriter) {
 	case errors.Is(err, context.DeadlineExceeded) ||
 		(isRPC && s.Code() == codes.DeadlineExceeded):
 		JSONError(w, http.StatusGatewayTimeout, ErrDeadlineExceeded)
+	case errors.Is(err, logqlmodel.ErrLimit):
+		JSONErrorWithType(w, http.StatusBadRequest, ErrorTypeLimitExceeded, err.Error())
+	case errors.Is(err, logqlmodel.ErrParse):
+		JSONErrorWithType(w, http.StatusBadRequest, ErrorTypeParseError, err.Error())
 	case errors.As(err, &queryErr),
-		errors.Is(err, logqlmodel.ErrLimit) || errors.Is(err, logqlmodel.ErrParse) || errors.Is(err, logqlmodel.ErrPipeline),
+		errors.Is(err, logqlmodel.ErrPipeline),
 		errors.Is(err, user.ErrNoOrgID):
 		JSONError(w, http.StatusBadRequest, err.Error())
+	case (isRPC && s.Code() == codes.Unavailable) ||
+		errors.Is(err, ring.ErrTooManyUnhealthyInstances) || errors.Is(err, ring.ErrEmptyRing):
+		JSONError(w, http.StatusServiceUnavailable, err.Error())
 	default:
 		if grpcErr, ok := httpgrpc.HTTPResponseFromError(err); ok {
-			JSONError(w, int(grpcErr.Code), string(grpcErr.Body))
+			CopyHeaders(w, grpcErr.Headers)
+			writeHTTPResponseError(w, int(grpcErr.Code), grpcErr.Body)
 			return
 		}
 		JSONError(w, http.StatusInternalServerError, err.Error())
 	}
 }
+
+// writeHTTPResponseError writes the error of an HTTP response. The error responses of the
+// downstream queriers are passed on as they are, instead of being nested in the message.
+func writeHTTPResponseError(w http.ResponseWriter, code int, body []byte) {
+	var downstream ErrorResponseBody
+	if err := json.Unmarshal(body, &downstream); err == nil && downstream.Status == "error" && downstream.ErrorType != "" {
+		JSONErrorWithType(w, code, downstream.ErrorType, "%s", downstream.Message)
+		return
+	}
+	message := string(body)
+	JSONErrorWithType(w, code, errorTypeFromStatus(code, message), "%s", message)
+}
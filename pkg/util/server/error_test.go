@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -20,6 +21,7 @@ import (
 	"github.com/grafana/loki/pkg/logqlmodel"
 	"github.com/grafana/loki/pkg/storage/chunk"
 	"github.com/grafana/loki/pkg/util"
+	"github.com/grafana/loki/pkg/util/limiter"
 )
 
 func Test_writeError(t *testing.T) {
@@ -110,3 +112,67 @@ func Test_writeError(t *testing.T) {
 		})
 	}
 }
+
+func Test_writeErrorType(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+
+		err               error
+		expectedType      string
+		expectedStatus    int
+		expectedRetryable bool
+	}{
+		{"cancelled", context.Canceled, ErrorTypeCanceled, StatusClientClosedRequest, false},
+		{"deadline", context.DeadlineExceeded, ErrorTypeTimeout, http.StatusGatewayTimeout, true},
+		{"parse error", logqlmodel.NewParseError("unexpected", 1, 2), ErrorTypeParseError, http.StatusBadRequest, false},
+		{"limit", fmt.Errorf("%w: too many series", logqlmodel.ErrLimit), ErrorTypeLimitExceeded, http.StatusBadRequest, false},
+		{"query limit", httpgrpc.Errorf(http.StatusBadRequest, "max entries limit per query exceeded, limit > max_entries_limit (%d > %d)", 10, 5), ErrorTypeLimitExceeded, http.StatusBadRequest, false},
+		{"rate limit", httpgrpc.Errorf(http.StatusTooManyRequests, "ingestion rate limit exceeded"), ErrorTypeLimitExceeded, http.StatusTooManyRequests, false},
+		{"outstanding requests", httpgrpc.Errorf(http.StatusTooManyRequests, "too many outstanding requests"), ErrorTypeTooManyOutstandingRequests, http.StatusTooManyRequests, true},
+		{"bad request", httpgrpc.Errorf(http.StatusBadRequest, "invalid direction"), ErrorTypeBadRequest, http.StatusBadRequest, false},
+		{"unavailable", status.New(codes.Unavailable, "connection refused").Err(), ErrorTypeStoreUnavailable, http.StatusServiceUnavailable, true},
+		{"internal", errors.New("foo"), ErrorTypeInternal, http.StatusInternalServerError, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			WriteError(tt.err, rec)
+			res := &ErrorResponseBody{}
+			require.NoError(t, json.NewDecoder(rec.Result().Body).Decode(res))
+			require.Equal(t, tt.expectedStatus, rec.Result().StatusCode)
+			require.Equal(t, tt.expectedType, res.ErrorType)
+			require.Equal(t, tt.expectedRetryable, res.Retryable)
+			require.Equal(t, tt.expectedType, rec.Result().Header.Get(ErrorTypeHeader))
+			require.Equal(t, fmt.Sprint(tt.expectedRetryable), rec.Result().Header.Get(ErrorRetryableHeader))
+		})
+	}
+}
+
+func Test_writeErrorDownstream(t *testing.T) {
+	// the error response of a querier, passed on by the query frontend.
+	downstream := httptest.NewRecorder()
+	WriteError(logqlmodel.NewParseError("unexpected", 1, 2), downstream)
+
+	rec := httptest.NewRecorder()
+	WriteError(httpgrpc.Errorf(downstream.Code, downstream.Body.String()), rec)
+	res := &ErrorResponseBody{}
+	require.NoError(t, json.NewDecoder(rec.Result().Body).Decode(res))
+	require.Equal(t, http.StatusBadRequest, res.Code)
+	require.Equal(t, ErrorTypeParseError, res.ErrorType)
+	require.Equal(t, logqlmodel.NewParseError("unexpected", 1, 2).Error(), res.Message)
+}
+
+func Test_writeErrorRateLimited(t *testing.T) {
+	rec := httptest.NewRecorder()
+	WriteError(RateLimitedError(limiter.Reservation{RetryAfter: 1500 * time.Millisecond, Limit: 10, Burst: 20, Remaining: 5}, "rate limited"), rec)
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	require.Equal(t, "2", rec.Header().Get(RetryAfterHeader))
+	require.Equal(t, "10", rec.Header().Get(RateLimitLimitHeader))
+	require.Equal(t, "20", rec.Header().Get(RateLimitBurstHeader))
+	require.Equal(t, "5", rec.Header().Get(RateLimitRemainingHeader))
+	require.Equal(t, ErrorTypeLimitExceeded, rec.Header().Get(ErrorTypeHeader))
+
+	// the successful reservations don't tell to retry.
+	h := RateLimitHeaders(limiter.Reservation{OK: true, Limit: 10, Burst: 20, Remaining: 5})
+	require.Empty(t, h.Get(RetryAfterHeader))
+	require.Equal(t, "5", h.Get(RateLimitRemainingHeader))
+}
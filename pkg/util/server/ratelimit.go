@@ -0,0 +1,71 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/weaveworks/common/httpgrpc"
+
+	"github.com/grafana/loki/pkg/util/limiter"
+)
+
+const (
+	// RetryAfterHeader tells the rate limited clients how many seconds to wait before retrying.
+	RetryAfterHeader = "Retry-After"
+	// RateLimitLimitHeader is the rate of the limiter of the tenant, per second.
+	RateLimitLimitHeader = "X-Loki-Ratelimit-Limit"
+	// RateLimitBurstHeader is the burst of the limiter of the tenant.
+	RateLimitBurstHeader = "X-Loki-Ratelimit-Burst"
+	// RateLimitRemainingHeader is what's left in the limiter of the tenant, so that the clients can self-throttle.
+	RateLimitRemainingHeader = "X-Loki-Ratelimit-Remaining"
+)
+
+// RateLimitHeaders returns the headers telling the state of the rate limiter of the reservation,
+// and how long to wait before retrying when the reservation failed.
+func RateLimitHeaders(res limiter.Reservation) http.Header {
+	h := http.Header{}
+	if !math.IsInf(res.Limit, 1) {
+		h.Set(RateLimitLimitHeader, strconv.FormatInt(int64(res.Limit), 10))
+		h.Set(RateLimitBurstHeader, strconv.Itoa(res.Burst))
+		h.Set(RateLimitRemainingHeader, strconv.Itoa(res.Remaining))
+	}
+	if !res.OK && res.RetryAfter > 0 {
+		h.Set(RetryAfterHeader, RetryAfterSeconds(res.RetryAfter))
+	}
+	return h
+}
+
+// RetryAfterSeconds formats the delay as a Retry-After value, rounded up to the next second.
+func RetryAfterSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
+
+// RateLimitedError returns a 429 error holding the rate limit headers of the failed reservation.
+func RateLimitedError(res limiter.Reservation, format string, args ...interface{}) error {
+	return ErrorWithHeaders(http.StatusTooManyRequests, RateLimitHeaders(res), format, args...)
+}
+
+// ErrorWithHeaders returns an HTTP error holding the headers, which are set in the error response.
+func ErrorWithHeaders(code int, headers http.Header, format string, args ...interface{}) error {
+	resp := &httpgrpc.HTTPResponse{Code: int32(code), Body: []byte(fmt.Sprintf(format, args...))}
+	keys := make([]string, 0, len(headers))
+	for k := range headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		resp.Headers = append(resp.Headers, &httpgrpc.Header{Key: k, Values: headers[k]})
+	}
+	return httpgrpc.ErrorFromHTTPResponse(resp)
+}
+
+// CopyHeaders sets the headers of the HTTP response of an error.
+func CopyHeaders(w http.ResponseWriter, headers []*httpgrpc.Header) {
+	for _, h := range headers {
+		w.Header()[h.Key] = h.Values
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"regexp"
 	"strconv"
 	"time"
 
@@ -34,10 +35,19 @@ const (
 	// is used to keep track of the current number of healthy distributor replicas.
 	GlobalIngestionRateStrategy = "global"
 
+	// InvalidUTF8Accept ingests the lines with invalid UTF-8 as they are.
+	InvalidUTF8Accept = "accept"
+	// InvalidUTF8Reject discards the lines with invalid UTF-8.
+	InvalidUTF8Reject = "reject"
+	// InvalidUTF8Sanitize replaces the invalid UTF-8 sequences of the lines with the Unicode replacement character.
+	InvalidUTF8Sanitize = "sanitize"
+
 	bytesInMB = 1048576
 
 	defaultPerStreamRateLimit  = 3 << 20 // 3MB
 	defaultPerStreamBurstLimit = 5 * defaultPerStreamRateLimit
+
+	defaultMaxQuerySpillBytes = 1 << 30 // 1GB
 )
 
 // Limits describe all the limits for users; can be used to describe global default
@@ -58,36 +68,57 @@ type Limits struct {
 	EnforceMetricName      bool             `yaml:"enforce_metric_name" json:"enforce_metric_name"`
 	MaxLineSize            flagext.ByteSize `yaml:"max_line_size" json:"max_line_size"`
 	MaxLineSizeTruncate    bool             `yaml:"max_line_size_truncate" json:"max_line_size_truncate"`
+	InvalidUTF8Handling    string           `yaml:"invalid_utf8_handling" json:"invalid_utf8_handling"`
+	StripControlChars      bool             `yaml:"strip_control_characters" json:"strip_control_characters"`
+	ShardStreams           bool             `yaml:"shard_streams" json:"shard_streams"`
+	MaxStreamShards        int              `yaml:"max_stream_shards" json:"max_stream_shards"`
 
 	// Ingester enforced limits.
 	MaxLocalStreamsPerUser  int              `yaml:"max_streams_per_user" json:"max_streams_per_user"`
 	MaxGlobalStreamsPerUser int              `yaml:"max_global_streams_per_user" json:"max_global_streams_per_user"`
 	UnorderedWrites         bool             `yaml:"unordered_writes" json:"unordered_writes"`
+	OutOfOrderTimeWindow    model.Duration   `yaml:"out_of_order_time_window" json:"out_of_order_time_window"`
 	PerStreamRateLimit      flagext.ByteSize `yaml:"per_stream_rate_limit" json:"per_stream_rate_limit"`
 	PerStreamRateLimitBurst flagext.ByteSize `yaml:"per_stream_rate_limit_burst" json:"per_stream_rate_limit_burst"`
 
+	// Store enforced limits.
+	AllowedObjectStores flagext.StringSliceCSV `yaml:"allowed_object_stores" json:"allowed_object_stores"`
+
 	// Querier enforced limits.
-	MaxChunksPerQuery          int            `yaml:"max_chunks_per_query" json:"max_chunks_per_query"`
-	MaxQuerySeries             int            `yaml:"max_query_series" json:"max_query_series"`
-	MaxQueryLookback           model.Duration `yaml:"max_query_lookback" json:"max_query_lookback"`
-	MaxQueryLength             model.Duration `yaml:"max_query_length" json:"max_query_length"`
-	MaxQueryParallelism        int            `yaml:"max_query_parallelism" json:"max_query_parallelism"`
-	CardinalityLimit           int            `yaml:"cardinality_limit" json:"cardinality_limit"`
-	MaxStreamsMatchersPerQuery int            `yaml:"max_streams_matchers_per_query" json:"max_streams_matchers_per_query"`
-	MaxConcurrentTailRequests  int            `yaml:"max_concurrent_tail_requests" json:"max_concurrent_tail_requests"`
-	MaxEntriesLimitPerQuery    int            `yaml:"max_entries_limit_per_query" json:"max_entries_limit_per_query"`
-	MaxCacheFreshness          model.Duration `yaml:"max_cache_freshness_per_query" json:"max_cache_freshness_per_query"`
-	MaxQueriersPerTenant       int            `yaml:"max_queriers_per_tenant" json:"max_queriers_per_tenant"`
-	QueryReadyIndexNumDays     int            `yaml:"query_ready_index_num_days" json:"query_ready_index_num_days"`
+	MaxChunksPerQuery          int              `yaml:"max_chunks_per_query" json:"max_chunks_per_query"`
+	MaxQuerySeries             int              `yaml:"max_query_series" json:"max_query_series"`
+	MaxQueryLookback           model.Duration   `yaml:"max_query_lookback" json:"max_query_lookback"`
+	MaxQueryLength             model.Duration   `yaml:"max_query_length" json:"max_query_length"`
+	MaxQueryParallelism        int              `yaml:"max_query_parallelism" json:"max_query_parallelism"`
+	CardinalityLimit           int              `yaml:"cardinality_limit" json:"cardinality_limit"`
+	MaxStreamsMatchersPerQuery int              `yaml:"max_streams_matchers_per_query" json:"max_streams_matchers_per_query"`
+	MaxConcurrentTailRequests  int              `yaml:"max_concurrent_tail_requests" json:"max_concurrent_tail_requests"`
+	MaxEntriesLimitPerQuery    int              `yaml:"max_entries_limit_per_query" json:"max_entries_limit_per_query"`
+	MaxQuerySpillBytes         flagext.ByteSize `yaml:"max_query_spill_bytes" json:"max_query_spill_bytes"`
+	MaxCacheFreshness          model.Duration   `yaml:"max_cache_freshness_per_query" json:"max_cache_freshness_per_query"`
+	MaxQueriersPerTenant       int              `yaml:"max_queriers_per_tenant" json:"max_queriers_per_tenant"`
+	QuerierPool                string           `yaml:"querier_pool" json:"querier_pool"`
+	QueryReadyIndexNumDays     int              `yaml:"query_ready_index_num_days" json:"query_ready_index_num_days"`
+	IndexGatewayShardSize      int              `yaml:"index_gateway_shard_size" json:"index_gateway_shard_size"`
 
 	// Query frontend enforced limits. The default is actually parameterized by the queryrange config.
-	QuerySplitDuration  model.Duration `yaml:"split_queries_by_interval" json:"split_queries_by_interval"`
-	MinShardingLookback model.Duration `yaml:"min_sharding_lookback" json:"min_sharding_lookback"`
+	QuerySplitDuration         model.Duration `yaml:"split_queries_by_interval" json:"split_queries_by_interval"`
+	MinShardingLookback        model.Duration `yaml:"min_sharding_lookback" json:"min_sharding_lookback"`
+	MaxQueryRange              model.Duration `yaml:"max_query_range" json:"max_query_range"`
+	MaxQueryAge                model.Duration `yaml:"max_query_age" json:"max_query_age"`
+	QueryLimitsOverrideEnabled bool           `yaml:"query_limits_override_enabled" json:"query_limits_override_enabled"`
+
+	// Scoping of the queries of the callers to the streams of some label values.
+	QueryScopeLabels        flagext.StringSliceCSV `yaml:"query_scope_labels" json:"query_scope_labels"`
+	QueryScopeUnscopedRoles flagext.StringSliceCSV `yaml:"query_scope_unscoped_roles" json:"query_scope_unscoped_roles"`
 
 	// Ruler defaults and limits.
 	RulerEvaluationDelay        model.Duration `yaml:"ruler_evaluation_delay_duration" json:"ruler_evaluation_delay_duration"`
 	RulerMaxRulesPerRuleGroup   int            `yaml:"ruler_max_rules_per_rule_group" json:"ruler_max_rules_per_rule_group"`
 	RulerMaxRuleGroupsPerTenant int            `yaml:"ruler_max_rule_groups_per_tenant" json:"ruler_max_rule_groups_per_tenant"`
+	// External labels and alert relabel configs are merged with and appended to the ruler ones.
+	RulerExternalLabels      labels.Labels         `yaml:"ruler_external_labels,omitempty" json:"ruler_external_labels,omitempty"`
+	RulerAlertRelabelConfigs []*util.RelabelConfig `yaml:"ruler_alert_relabel_configs,omitempty" json:"ruler_alert_relabel_configs,omitempty"`
 
 	// TODO(dannyk): add HTTP client overrides (basic auth / tls config, etc)
 	// Ruler remote-write limits.
@@ -112,6 +143,12 @@ type Limits struct {
 	RetentionPeriod model.Duration    `yaml:"retention_period" json:"retention_period"`
 	StreamRetention []StreamRetention `yaml:"retention_stream,omitempty" json:"retention_stream,omitempty"`
 
+	// Per stream chunk flushing policies.
+	StreamChunkPolicies []StreamChunkPolicy `yaml:"stream_chunk_policies,omitempty" json:"stream_chunk_policies,omitempty"`
+
+	// Per stream masking of the query results, depending on the role of the caller.
+	QueryMaskingPolicies []QueryMaskingPolicy `yaml:"query_masking_policies,omitempty" json:"query_masking_policies,omitempty"`
+
 	// Config for overrides, convenient if it goes here.
 	PerTenantOverrideConfig string         `yaml:"per_tenant_override_config" json:"per_tenant_override_config"`
 	PerTenantOverridePeriod model.Duration `yaml:"per_tenant_override_period" json:"per_tenant_override_period"`
@@ -124,6 +161,75 @@ type StreamRetention struct {
 	Matchers []*labels.Matcher `yaml:"-" json:"-"` // populated during validation.
 }
 
+// StreamChunkPolicy shortens the chunk idle period and the max chunk age of the ingesters
+// for the streams matching the selector.
+type StreamChunkPolicy struct {
+	Selector        string            `yaml:"selector" json:"selector"`
+	ChunkIdlePeriod model.Duration    `yaml:"chunk_idle_period" json:"chunk_idle_period"`
+	MaxChunkAge     model.Duration    `yaml:"max_chunk_age" json:"max_chunk_age"`
+	Matchers        []*labels.Matcher `yaml:"-" json:"-"` // populated during validation.
+}
+
+// Matches tells whether the labels of the stream match the selector of the policy.
+func (p StreamChunkPolicy) Matches(lbs labels.Labels) bool {
+	for _, m := range p.Matchers {
+		if !m.Matches(lbs.Get(m.Name)) {
+			return false
+		}
+	}
+	return true
+}
+
+// DefaultMaskingReplacement replaces the masked values of the query masking policies
+// not setting a replacement.
+const DefaultMaskingReplacement = "<redacted>"
+
+// QueryMaskingPolicy masks the parts of the lines and of the label values matching the patterns, in the
+// results of the queries of the streams matching the selector, unless the role of the caller is unmasked.
+type QueryMaskingPolicy struct {
+	Selector      string            `yaml:"selector" json:"selector"`
+	Patterns      []string          `yaml:"patterns" json:"patterns"`
+	Replacement   string            `yaml:"replacement" json:"replacement"`
+	UnmaskedRoles []string          `yaml:"unmasked_roles" json:"unmasked_roles"`
+	Matchers      []*labels.Matcher `yaml:"-" json:"-"` // populated during validation.
+	Regexps       []*regexp.Regexp  `yaml:"-" json:"-"` // populated during validation.
+}
+
+// Matches tells whether the labels of the stream match the selector of the policy.
+func (p QueryMaskingPolicy) Matches(lbs labels.Labels) bool {
+	for _, m := range p.Matchers {
+		if !m.Matches(lbs.Get(m.Name)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Unmasked tells whether the callers with the role see the values masked by the policy.
+func (p QueryMaskingPolicy) Unmasked(role string) bool {
+	if role == "" {
+		return false
+	}
+	for _, r := range p.UnmaskedRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Mask replaces the parts of s matching the patterns of the policy.
+func (p QueryMaskingPolicy) Mask(s string) string {
+	replacement := p.Replacement
+	if replacement == "" {
+		replacement = DefaultMaskingReplacement
+	}
+	for _, re := range p.Regexps {
+		s = re.ReplaceAllLiteralString(s, replacement)
+	}
+	return s
+}
+
 // RegisterFlags adds the flags required to config this to the given FlagSet
 func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	f.StringVar(&l.IngestionRateStrategy, "distributor.ingestion-rate-limit-strategy", "global", "Whether the ingestion rate limit should be applied individually to each distributor instance (local), or evenly shared across the cluster (global).")
@@ -131,6 +237,10 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	f.Float64Var(&l.IngestionBurstSizeMB, "distributor.ingestion-burst-size-mb", 6, "Per-user allowed ingestion burst size (in sample size). Units in MB.")
 	f.Var(&l.MaxLineSize, "distributor.max-line-size", "maximum line length allowed, i.e. 100mb. Default (0) means unlimited.")
 	f.BoolVar(&l.MaxLineSizeTruncate, "distributor.max-line-size-truncate", false, "Whether to truncate lines that exceed max_line_size")
+	f.StringVar(&l.InvalidUTF8Handling, "distributor.invalid-utf8-handling", InvalidUTF8Accept, "How the lines with invalid UTF-8 are handled: accept to ingest them as they are, reject to discard them, or sanitize to replace the invalid sequences with the Unicode replacement character.")
+	f.BoolVar(&l.StripControlChars, "distributor.strip-control-characters", false, "Whether to strip the control characters of the lines, except tabs and newlines.")
+	f.BoolVar(&l.ShardStreams, "distributor.shard-streams", false, "Whether to shard the streams pushed faster than the per-stream rate limit across several streams, distinguished by the __stream_shard__ label. The queries merge the shards back.")
+	f.IntVar(&l.MaxStreamShards, "distributor.max-stream-shards", 32, "Maximum number of shards of a stream when the streams are sharded.")
 	f.IntVar(&l.MaxLabelNameLength, "validation.max-length-label-name", 1024, "Maximum length accepted for label names")
 	f.IntVar(&l.MaxLabelValueLength, "validation.max-length-label-value", 2048, "Maximum length accepted for label value. This setting also applies to the metric name")
 	f.IntVar(&l.MaxLabelNamesPerSeries, "validation.max-label-names-per-series", 30, "Maximum number of label names per series.")
@@ -142,21 +252,26 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	f.Var(&l.CreationGracePeriod, "validation.create-grace-period", "Duration which table will be created/deleted before/after it's needed; we won't accept sample from before this time.")
 	f.BoolVar(&l.EnforceMetricName, "validation.enforce-metric-name", true, "Enforce every sample has a metric name.")
 	f.IntVar(&l.MaxEntriesLimitPerQuery, "validation.max-entries-limit", 5000, "Per-user entries limit per query")
+	_ = l.MaxQuerySpillBytes.Set(strconv.Itoa(defaultMaxQuerySpillBytes))
+	f.Var(&l.MaxQuerySpillBytes, "querier.max-query-spill-bytes", "Maximum size of the log entries spilled to disk at once by the queries of a tenant on each querier, when -querier.spill-directory is set. Queries spilling more fail. 0 to disable.")
 
 	f.IntVar(&l.MaxLocalStreamsPerUser, "ingester.max-streams-per-user", 0, "Maximum number of active streams per user, per ingester. 0 to disable.")
 	f.IntVar(&l.MaxGlobalStreamsPerUser, "ingester.max-global-streams-per-user", 5000, "Maximum number of active streams per user, across the cluster. 0 to disable.")
 	f.BoolVar(&l.UnorderedWrites, "ingester.unordered-writes", true, "Allow out of order writes.")
+	f.Var(&l.OutOfOrderTimeWindow, "ingester.out-of-order-time-window", "How far behind the newest entry of a stream its entries are accepted when out of order writes are allowed. Entries older are rejected as too far behind. 0 to accept the entries up to half the max chunk age behind.")
 
 	_ = l.PerStreamRateLimit.Set(strconv.Itoa(defaultPerStreamRateLimit))
 	f.Var(&l.PerStreamRateLimit, "ingester.per-stream-rate-limit", "Maximum byte rate per second per stream, also expressible in human readable forms (1MB, 256KB, etc).")
 	_ = l.PerStreamRateLimitBurst.Set(strconv.Itoa(defaultPerStreamBurstLimit))
 	f.Var(&l.PerStreamRateLimitBurst, "ingester.per-stream-rate-limit-burst", "Maximum burst bytes per stream, also expressible in human readable forms (1MB, 256KB, etc).")
 
+	f.Var(&l.AllowedObjectStores, "store.allowed-object-stores", "Comma separated list of the stores the chunks and the index of the tenant can be written to and read from, as named in the object_store and the store of the period configs and in the shared_store of the index shippers, e.g. to keep the data of the tenant in a region. An object store can be restricted to a bucket, container or directory as <store>:<bucket>, e.g. s3:eu-chunks. The writes and the reads of the periods using other stores fail, and the configs scheduling the data of the tenant elsewhere are refused. Empty to allow all the stores.")
+
 	f.IntVar(&l.MaxChunksPerQuery, "store.query-chunk-limit", 2e6, "Maximum number of chunks that can be fetched in a single query.")
 
 	_ = l.MaxQueryLength.Set("721h")
 	f.Var(&l.MaxQueryLength, "store.max-query-length", "Limit to length of chunk store queries, 0 to disable.")
-	f.IntVar(&l.MaxQuerySeries, "querier.max-query-series", 500, "Limit the maximum of unique series returned by a metric query. When the limit is reached an error is returned.")
+	f.IntVar(&l.MaxQuerySeries, "querier.max-query-series", 500, "Limit the maximum of unique series returned by a metric query. When the limit is reached an error is returned, unless the request asks for the result to be truncated with the X-Query-Series-Limit-Strategy header.")
 
 	_ = l.MaxQueryLookback.Set("0s")
 	f.Var(&l.MaxQueryLookback, "querier.max-query-lookback", "Limit how long back data (series and metadata) can be queried, up until <lookback> duration ago. This limit is enforced in the query-frontend, querier and ruler. If the requested time range is outside the allowed range, the request will not fail but will be manipulated to only query data within the allowed time range. 0 to disable.")
@@ -168,11 +283,21 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	_ = l.MinShardingLookback.Set("0s")
 	f.Var(&l.MinShardingLookback, "frontend.min-sharding-lookback", "Limit the sharding time range.Queries with time range that fall between now and now minus the sharding lookback are not sharded. 0 to disable.")
 
+	_ = l.MaxQueryRange.Set("0s")
+	f.Var(&l.MaxQueryRange, "frontend.max-query-range", "Maximum time range (end - start) of queries received by the query frontend. Longer queries are rejected. 0 to disable.")
+	_ = l.MaxQueryAge.Set("0s")
+	f.Var(&l.MaxQueryAge, "frontend.max-query-age", "Maximum lookback of queries received by the query frontend: queries starting before now minus this duration are rejected, unlike -querier.max-query-lookback which shortens them. 0 to disable.")
+	f.BoolVar(&l.QueryLimitsOverrideEnabled, "frontend.query-limits-override-enabled", false, "Allow queries with the X-Query-Limits-Override header set to true to bypass the max query range and max query age limits. Only enable it when the header is set by a trusted proxy for privileged users.")
+	f.Var(&l.QueryScopeLabels, "querier.query-scope-labels", "Comma separated list of the labels the queries of the tenant must be scoped on, with the X-Query-Scope header restricting them to the streams of some values of the labels, e.g. {team=\"a\"}. The queries received without such a scope are refused, except for the callers with an unscoped role. Empty to accept the unscoped queries.")
+	f.Var(&l.QueryScopeUnscopedRoles, "querier.query-scope-unscoped-roles", "Comma separated list of the roles, as set by the X-Query-Role header, of which the queries don't need to be scoped on the query scope labels.")
+
 	_ = l.MaxCacheFreshness.Set("1m")
 	f.Var(&l.MaxCacheFreshness, "frontend.max-cache-freshness", "Most recent allowed cacheable result per-tenant, to prevent caching very recent results that might still be in flux.")
 
 	f.IntVar(&l.MaxQueriersPerTenant, "frontend.max-queriers-per-tenant", 0, "Maximum number of queriers that can handle requests for a single tenant. If set to 0 or value higher than number of available queriers, *all* queriers will handle requests for the tenant. Each frontend (or query-scheduler, if used) will select the same set of queriers for the same tenant (given that all queriers are connected to all frontends / query-schedulers). This option only works with queriers connecting to the query-frontend / query-scheduler, not when using downstream URL.")
+	f.StringVar(&l.QuerierPool, "query-scheduler.querier-pool", "", "Pool of queriers, as set by -querier.pool, dedicated to the queries of the tenant. The queries are handled by the queriers of the shared pool while no querier of the pool is connected. Only supported by the query-scheduler. Empty to use the shared pool.")
 	f.IntVar(&l.QueryReadyIndexNumDays, "store.query-ready-index-num-days", 0, "Number of days of index to be kept always downloaded for queries. Applies only to per user index in boltdb-shipper index store. 0 to disable.")
+	f.IntVar(&l.IndexGatewayShardSize, "index-gateway.shard-size", 0, "Number of index gateways the index of the tenant is shuffle sharded across when the index gateway runs in ring mode. 0 to spread the tenant across all the index gateways.")
 
 	_ = l.RulerEvaluationDelay.Set("0s")
 	f.Var(&l.RulerEvaluationDelay, "ruler.evaluation-delay-duration", "Duration to delay the evaluation of rules to ensure the underlying metrics have been pushed to Cortex.")
@@ -226,6 +351,46 @@ func (l *Limits) Validate() error {
 			l.StreamRetention[i].Matchers = matchers
 		}
 	}
+	if l.ShardStreams && l.MaxStreamShards < 1 {
+		return fmt.Errorf("the maximum number of stream shards must be positive when the streams are sharded, was %d", l.MaxStreamShards)
+	}
+	switch l.InvalidUTF8Handling {
+	case "", InvalidUTF8Accept, InvalidUTF8Reject, InvalidUTF8Sanitize:
+	default:
+		return fmt.Errorf("invalid UTF-8 handling %q, supported values are %s, %s and %s", l.InvalidUTF8Handling, InvalidUTF8Accept, InvalidUTF8Reject, InvalidUTF8Sanitize)
+	}
+	for i, policy := range l.StreamChunkPolicies {
+		matchers, err := syntax.ParseMatchers(policy.Selector)
+		if err != nil {
+			return fmt.Errorf("invalid labels matchers of the stream chunk policy: %w", err)
+		}
+		if policy.ChunkIdlePeriod < 0 || policy.MaxChunkAge < 0 {
+			return fmt.Errorf("the chunk idle period and the max chunk age of the stream chunk policy %s must not be negative", policy.Selector)
+		}
+		if policy.ChunkIdlePeriod == 0 && policy.MaxChunkAge == 0 {
+			return fmt.Errorf("the stream chunk policy %s sets neither a chunk idle period nor a max chunk age", policy.Selector)
+		}
+		l.StreamChunkPolicies[i].Matchers = matchers
+	}
+	for i, policy := range l.QueryMaskingPolicies {
+		matchers, err := syntax.ParseMatchers(policy.Selector)
+		if err != nil {
+			return fmt.Errorf("invalid labels matchers of the query masking policy: %w", err)
+		}
+		if len(policy.Patterns) == 0 {
+			return fmt.Errorf("the query masking policy %s sets no patterns", policy.Selector)
+		}
+		regexps := make([]*regexp.Regexp, 0, len(policy.Patterns))
+		for _, pattern := range policy.Patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("invalid pattern of the query masking policy %s: %w", policy.Selector, err)
+			}
+			regexps = append(regexps, re)
+		}
+		l.QueryMaskingPolicies[i].Matchers = matchers
+		l.QueryMaskingPolicies[i].Regexps = regexps
+	}
 	return nil
 }
 
@@ -359,6 +524,21 @@ func (o *Overrides) MaxQueriersPerUser(userID string) int {
 	return o.getOverridesForUser(userID).MaxQueriersPerTenant
 }
 
+// QuerierPool returns the pool of queriers dedicated to the user, empty for the shared pool.
+func (o *Overrides) QuerierPool(userID string) string {
+	return o.getOverridesForUser(userID).QuerierPool
+}
+
+// AllowedObjectStores returns the stores the data of the tenant is pinned to, empty when it isn't pinned.
+func (o *Overrides) AllowedObjectStores(userID string) []string {
+	return o.getOverridesForUser(userID).AllowedObjectStores
+}
+
+// IndexGatewayShardSize returns the number of index gateways the index of the tenant is shuffle sharded across.
+func (o *Overrides) IndexGatewayShardSize(userID string) int {
+	return o.getOverridesForUser(userID).IndexGatewayShardSize
+}
+
 // QueryReadyIndexNumDays returns the number of days for which we have to be query ready for a user.
 func (o *Overrides) QueryReadyIndexNumDays(userID string) int {
 	return o.getOverridesForUser(userID).QueryReadyIndexNumDays
@@ -390,6 +570,21 @@ func (o *Overrides) MinShardingLookback(userID string) time.Duration {
 	return time.Duration(o.getOverridesForUser(userID).MinShardingLookback)
 }
 
+// MaxQueryRange returns the maximum time range of queries received by the query frontend.
+func (o *Overrides) MaxQueryRange(userID string) time.Duration {
+	return time.Duration(o.getOverridesForUser(userID).MaxQueryRange)
+}
+
+// MaxQueryAge returns the maximum lookback of queries received by the query frontend.
+func (o *Overrides) MaxQueryAge(userID string) time.Duration {
+	return time.Duration(o.getOverridesForUser(userID).MaxQueryAge)
+}
+
+// QueryLimitsOverrideEnabled returns whether the query range and age limits can be bypassed with a header.
+func (o *Overrides) QueryLimitsOverrideEnabled(userID string) bool {
+	return o.getOverridesForUser(userID).QueryLimitsOverrideEnabled
+}
+
 // QuerySplitDuration returns the tenant specific splitby interval applied in the query frontend.
 func (o *Overrides) QuerySplitDuration(userID string) time.Duration {
 	return time.Duration(o.getOverridesForUser(userID).QuerySplitDuration)
@@ -410,11 +605,36 @@ func (o *Overrides) MaxLineSizeTruncate(userID string) bool {
 	return o.getOverridesForUser(userID).MaxLineSizeTruncate
 }
 
+// InvalidUTF8Handling returns how the lines with invalid UTF-8 are handled: accepted, rejected or sanitized.
+func (o *Overrides) InvalidUTF8Handling(userID string) string {
+	return o.getOverridesForUser(userID).InvalidUTF8Handling
+}
+
+// StripControlCharacters returns whether the control characters of the lines are stripped.
+func (o *Overrides) StripControlCharacters(userID string) bool {
+	return o.getOverridesForUser(userID).StripControlChars
+}
+
+// ShardStreams returns whether the streams of a given user pushed faster than the per-stream rate limit are sharded.
+func (o *Overrides) ShardStreams(userID string) bool {
+	return o.getOverridesForUser(userID).ShardStreams
+}
+
+// MaxStreamShards returns the maximum number of shards of a stream of a given user.
+func (o *Overrides) MaxStreamShards(userID string) int {
+	return o.getOverridesForUser(userID).MaxStreamShards
+}
+
 // MaxEntriesLimitPerQuery returns the limit to number of entries the querier should return per query.
 func (o *Overrides) MaxEntriesLimitPerQuery(userID string) int {
 	return o.getOverridesForUser(userID).MaxEntriesLimitPerQuery
 }
 
+// MaxQuerySpillBytes returns the maximum size of the log entries the queries of the user can spill to disk at once on a querier.
+func (o *Overrides) MaxQuerySpillBytes(userID string) int {
+	return o.getOverridesForUser(userID).MaxQuerySpillBytes.Val()
+}
+
 func (o *Overrides) MaxCacheFreshness(userID string) time.Duration {
 	return time.Duration(o.getOverridesForUser(userID).MaxCacheFreshness)
 }
@@ -445,6 +665,18 @@ func (o *Overrides) RulerMaxRuleGroupsPerTenant(userID string) int {
 	return o.getOverridesForUser(userID).RulerMaxRuleGroupsPerTenant
 }
 
+// RulerExternalLabels returns the external labels to add to the alerts and the remote-written
+// series of a given user, on top of the ruler ones.
+func (o *Overrides) RulerExternalLabels(userID string) labels.Labels {
+	return o.getOverridesForUser(userID).RulerExternalLabels
+}
+
+// RulerAlertRelabelConfigs returns the relabel configs to apply to the alerts of a given user
+// before sending them to the Alertmanager.
+func (o *Overrides) RulerAlertRelabelConfigs(userID string) []*util.RelabelConfig {
+	return o.getOverridesForUser(userID).RulerAlertRelabelConfigs
+}
+
 // RulerRemoteWriteDisabled returns whether remote-write is disabled for a given user or not.
 func (o *Overrides) RulerRemoteWriteDisabled(userID string) bool {
 	return o.getOverridesForUser(userID).RulerRemoteWriteDisabled
@@ -520,10 +752,35 @@ func (o *Overrides) StreamRetention(userID string) []StreamRetention {
 	return o.getOverridesForUser(userID).StreamRetention
 }
 
+// StreamChunkPolicies returns the chunk flushing policies of the streams of a given user.
+func (o *Overrides) StreamChunkPolicies(userID string) []StreamChunkPolicy {
+	return o.getOverridesForUser(userID).StreamChunkPolicies
+}
+
+// QueryScopeLabels returns the labels the queries of a given user must be scoped on.
+func (o *Overrides) QueryScopeLabels(userID string) []string {
+	return o.getOverridesForUser(userID).QueryScopeLabels
+}
+
+// QueryScopeUnscopedRoles returns the roles of which the queries of a given user don't need to be scoped.
+func (o *Overrides) QueryScopeUnscopedRoles(userID string) []string {
+	return o.getOverridesForUser(userID).QueryScopeUnscopedRoles
+}
+
+// QueryMaskingPolicies returns the masking policies of the query results of a given user.
+func (o *Overrides) QueryMaskingPolicies(userID string) []QueryMaskingPolicy {
+	return o.getOverridesForUser(userID).QueryMaskingPolicies
+}
+
 func (o *Overrides) UnorderedWrites(userID string) bool {
 	return o.getOverridesForUser(userID).UnorderedWrites
 }
 
+// OutOfOrderTimeWindow returns how far behind the newest entry of a stream of a given user its entries are accepted.
+func (o *Overrides) OutOfOrderTimeWindow(userID string) time.Duration {
+	return time.Duration(o.getOverridesForUser(userID).OutOfOrderTimeWindow)
+}
+
 func (o *Overrides) DefaultLimits() *Limits {
 	return o.defaultLimits
 }
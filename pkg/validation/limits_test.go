@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"gopkg.in/yaml.v2"
@@ -239,3 +240,66 @@ reject_old_samples: true
 		})
 	}
 }
+
+func TestLimitsValidation_StreamChunkPolicies(t *testing.T) {
+	var l Limits
+	require.NoError(t, yaml.UnmarshalStrict([]byte(`
+stream_chunk_policies:
+  - selector: '{job="debug"}'
+    chunk_idle_period: 1m
+    max_chunk_age: 10m
+`), &l))
+	require.NoError(t, l.Validate())
+	require.Len(t, l.StreamChunkPolicies, 1)
+	require.True(t, l.StreamChunkPolicies[0].Matches(labels.Labels{{Name: "job", Value: "debug"}, {Name: "pod", Value: "a"}}))
+	require.False(t, l.StreamChunkPolicies[0].Matches(labels.Labels{{Name: "job", Value: "api"}}))
+
+	l.StreamChunkPolicies = []StreamChunkPolicy{{Selector: `{job="debug"}`}}
+	require.EqualError(t, l.Validate(), `the stream chunk policy {job="debug"} sets neither a chunk idle period nor a max chunk age`)
+
+	l.StreamChunkPolicies = []StreamChunkPolicy{{Selector: `job="debug"`, ChunkIdlePeriod: model.Duration(time.Minute)}}
+	require.Error(t, l.Validate())
+}
+
+func TestLimitsValidation_InvalidUTF8Handling(t *testing.T) {
+	var l Limits
+	require.NoError(t, yaml.UnmarshalStrict([]byte(`
+invalid_utf8_handling: sanitize
+strip_control_characters: true
+`), &l))
+	require.NoError(t, l.Validate())
+	require.Equal(t, InvalidUTF8Sanitize, l.InvalidUTF8Handling)
+	require.True(t, l.StripControlChars)
+
+	l.InvalidUTF8Handling = "drop"
+	require.EqualError(t, l.Validate(), `invalid UTF-8 handling "drop", supported values are accept, reject and sanitize`)
+}
+
+func TestLimitsValidation_QueryMaskingPolicies(t *testing.T) {
+	var l Limits
+	require.NoError(t, yaml.UnmarshalStrict([]byte(`
+query_masking_policies:
+  - selector: '{app="payments"}'
+    patterns: ['\d{4}-\d{4}-\d{4}-\d{4}', 'password=\S+']
+    unmasked_roles: [admin]
+`), &l))
+	require.NoError(t, l.Validate())
+	require.Len(t, l.QueryMaskingPolicies, 1)
+
+	policy := l.QueryMaskingPolicies[0]
+	require.True(t, policy.Matches(labels.Labels{{Name: "app", Value: "payments"}, {Name: "pod", Value: "a"}}))
+	require.False(t, policy.Matches(labels.Labels{{Name: "app", Value: "api"}}))
+	require.True(t, policy.Unmasked("admin"))
+	require.False(t, policy.Unmasked("viewer"))
+	require.False(t, policy.Unmasked(""))
+	require.Equal(t, "card=<redacted> <redacted> ok", policy.Mask("card=1234-5678-9012-3456 password=hunter2 ok"))
+
+	policy.Replacement = "***"
+	require.Equal(t, "card=***", policy.Mask("card=1234-5678-9012-3456"))
+
+	l.QueryMaskingPolicies = []QueryMaskingPolicy{{Selector: `{app="payments"}`}}
+	require.EqualError(t, l.Validate(), `the query masking policy {app="payments"} sets no patterns`)
+
+	l.QueryMaskingPolicies = []QueryMaskingPolicy{{Selector: `{app="payments"}`, Patterns: []string{"("}}}
+	require.Error(t, l.Validate())
+}
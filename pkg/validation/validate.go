@@ -23,6 +23,11 @@ const (
 	// LineTooLong is a reason for discarding too long log lines.
 	LineTooLong         = "line_too_long"
 	LineTooLongErrorMsg = "Max entry size '%d' bytes exceeded for stream '%s' while adding an entry with length '%d' bytes"
+	// InvalidUTF8 is a reason for discarding or sanitizing log lines which aren't valid UTF-8.
+	InvalidUTF8         = "invalid_utf8"
+	InvalidUTF8ErrorMsg = "entry for stream '%s' is not valid UTF-8"
+	// ControlCharacters is a reason for sanitizing log lines which contain control characters.
+	ControlCharacters = "control_characters"
 	// StreamLimit is a reason for discarding lines when we can't create a new stream
 	// because the limit of active streams has been reached.
 	StreamLimit         = "stream_limit"
@@ -72,7 +77,7 @@ var MutatedSamples = prometheus.NewCounterVec(
 		Name:      "mutated_samples_total",
 		Help:      "The total number of samples that have been mutated.",
 	},
-	[]string{ReasonLabel, "truncated"},
+	[]string{ReasonLabel, "tenant"},
 )
 
 // MutatedBytes is a metric of the total mutated bytes, by reason.
@@ -82,7 +87,7 @@ var MutatedBytes = prometheus.NewCounterVec(
 		Name:      "mutated_bytes_total",
 		Help:      "The total number of bytes that have been mutated.",
 	},
-	[]string{ReasonLabel, "truncated"},
+	[]string{ReasonLabel, "tenant"},
 )
 
 // DiscardedBytes is a metric of the total discarded bytes, by reason.
@@ -106,5 +111,5 @@ var DiscardedSamples = prometheus.NewCounterVec(
 )
 
 func init() {
-	prometheus.MustRegister(DiscardedSamples, DiscardedBytes)
+	prometheus.MustRegister(DiscardedSamples, DiscardedBytes, MutatedSamples, MutatedBytes)
 }
@@ -7,6 +7,7 @@ import (
 	"log"
 	"strconv"
 
+	"github.com/prometheus/common/model"
 	"go.etcd.io/bbolt"
 	"gopkg.in/yaml.v2"
 
@@ -80,7 +81,7 @@ func main() {
 				return it.Err()
 			}
 			entry := it.Entry()
-			builder.AddSeries(entry.Labels, []index.ChunkMeta{{
+			builder.AddSeries(entry.Labels, model.Fingerprint(entry.Labels.Hash()), []index.ChunkMeta{{
 				Checksum: extractChecksumFromChunkID(entry.ChunkID),
 				MinTime:  int64(entry.From),
 				MaxTime:  int64(entry.Through),